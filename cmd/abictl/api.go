@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/middleware"
+)
+
+// apiClient calls the admin API of a running service
+type apiClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// newAPIClient creates a client for the API. Without --api-url it calls the
+// server on this host with the port, prefix and TLS of the configuration.
+func (a *app) newAPIClient() (*apiClient, error) {
+	apiKey := a.apiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ABICTL_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, errors.New("an API key of an administrator is required, pass --api-key or set ABICTL_API_KEY")
+	}
+
+	baseURL := a.apiURL
+	if baseURL == "" {
+		scheme := "http"
+		if a.cfg.Server.TLSEnabled() {
+			scheme = "https"
+		}
+		baseURL = fmt.Sprintf("%s://localhost:%d%s", scheme, a.cfg.Server.Port, a.cfg.API.Prefix)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.caCert != "" {
+		pem, err := os.ReadFile(a.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", a.caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if a.clientCert != "" || a.clientKey != "" {
+		cert, err := tls.LoadX509KeyPair(a.clientCert, a.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// post sends a request with a JSON body, when body is not nil, and decodes a
+// JSON response into out, when out is not nil. Error responses are returned
+// as errors with the message of the server.
+func (c *apiClient) post(path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(middleware.APIKeyHeader, c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/spf13/cobra"
+)
+
+func newKeysCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage encryption keys",
+	}
+
+	var force bool
+	rotate := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate the data key of personal data",
+		Long: `Replaces the data key of users' personal data once it is older than
+ENCRYPTION_KEY_ROTATION_DAYS, or at once with --force, encrypts all personal
+data under the active key and retires the previous keys.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if err := a.openDB(ctx); err != nil {
+				return err
+			}
+			keyRepo := repository.NewPIIKeyRepository(database.DB, a.logger)
+			piiCipher, err := pii.New(ctx, &a.cfg.Encryption, keyRepo)
+			if err != nil {
+				return err
+			}
+			userRepo := repository.NewUserRepository(database.DB)
+			userRepo.SetCipher(piiCipher)
+
+			rotation := service.NewKeyRotationService(piiCipher, userRepo, keyRepo, a.cfg.Encryption.KeyRotationDays)
+			result, err := rotation.Rotate(ctx, force)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if result.Rotated {
+				fmt.Fprintf(out, "Created data key %d\n", result.KeyID)
+			} else {
+				fmt.Fprintf(out, "Data key %d is not due for rotation\n", result.KeyID)
+			}
+			fmt.Fprintf(out, "Re-encrypted the personal data of %d users\n", result.Reencrypted)
+			fmt.Fprintf(out, "Retired %d data keys\n", result.Retired)
+			return nil
+		},
+	}
+	rotate.Flags().BoolVar(&force, "force", false, "rotate even if the data key is not due")
+
+	cmd.AddCommand(rotate)
+	return cmd
+}
@@ -0,0 +1,108 @@
+// Command abictl runs operations tasks against a deployment: creating
+// administrators, unlocking users, running the payment scheduler, rotating the
+// data key of personal data and sending failed notifications again. Tasks that
+// the service performs itself go through its admin API, the others work on the
+// database with the configuration of the service.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// app holds what the commands share: the configuration of the service and
+// the settings of the admin API client
+type app struct {
+	cfg    *config.Config
+	logger *logrus.Logger
+
+	apiURL     string
+	apiKey     string
+	clientCert string
+	clientKey  string
+	caCert     string
+
+	secrets *secrets.Manager
+}
+
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	err := newRootCommand(&app{logger: logger}).Execute()
+	database.CloseDB()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand(a *app) *cobra.Command {
+	root := &cobra.Command{
+		Use:          "abictl",
+		Short:        "Operations tool for abi_banking",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.loadConfig()
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&a.apiURL, "api-url", "", "base URL of the API including its prefix (default: the local server)")
+	flags.StringVar(&a.apiKey, "api-key", "", "API key of an administrator, $ABICTL_API_KEY when omitted")
+	flags.StringVar(&a.clientCert, "cert", "", "client certificate for admin routes that require mutual TLS")
+	flags.StringVar(&a.clientKey, "key", "", "private key of the client certificate")
+	flags.StringVar(&a.caCert, "cacert", "", "CA certificate to verify the server with")
+
+	root.AddCommand(
+		newUserCommand(a),
+		newSchedulerCommand(a),
+		newKeysCommand(a),
+		newNotificationsCommand(a),
+	)
+	return root
+}
+
+// loadConfig reads the configuration of the service from the environment and
+// .env, like the service does
+func (a *app) loadConfig() error {
+	if err := godotenv.Load(); err != nil {
+		a.logger.Debugf("Error loading .env file: %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	level, err := logrus.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	a.logger.SetLevel(level)
+
+	a.cfg = cfg
+	a.secrets = secrets.NewManager(&cfg.Secrets, a.logger)
+	return nil
+}
+
+// openDB connects to the database of the service with the encryption keys
+// resolved, for the commands that work on it directly
+func (a *app) openDB(ctx context.Context) error {
+	dbPassword, err := a.secrets.Secret(a.cfg.Database.Password)
+	if err != nil {
+		return fmt.Errorf("invalid database password setting: %w", err)
+	}
+	if err := a.secrets.ResolveEncryptionKeys(ctx, &a.cfg.Encryption); err != nil {
+		return fmt.Errorf("failed to fetch encryption keys: %w", err)
+	}
+	return database.InitDB(a.cfg, dbPassword, a.logger)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/spf13/cobra"
+)
+
+func newNotificationsCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "notifications",
+		Short: "Manage email and SMS notifications",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "resend",
+		Short: "Send failed notifications again now",
+		Long: `Makes the email and SMS notifications whose last attempt failed due at once,
+so the outbox relay of the service sends them on its next poll instead of after
+their backoff of up to an hour.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if err := a.openDB(ctx); err != nil {
+				return err
+			}
+			relay := outbox.NewRelay(repository.NewOutboxRepository(database.DB, a.logger), &a.cfg.Outbox, a.logger)
+			count, err := relay.RetryFailed(ctx, models.OutboxTopicEmail, models.OutboxTopicSMS)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Queued %d failed notifications to be sent again\n", count)
+			return nil
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newSchedulerCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scheduler",
+		Short: "Control the credit payment scheduler",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "run",
+		Short: "Run the credit payment scheduler now, through the admin API",
+		Long: `Runs the credit payment scheduler of the service now instead of waiting for
+its next run. The run continues in the service after the command returns.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := a.newAPIClient()
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				Status string `json:"status"`
+			}
+			if err := client.post("/admin/scheduler/run", nil, &resp); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Payment scheduler run %s\n", resp.Status)
+			return nil
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/spf13/cobra"
+)
+
+func newUserCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+	cmd.AddCommand(newCreateAdminCommand(a), newUnlockCommand(a))
+	return cmd
+}
+
+func newCreateAdminCommand(a *app) *cobra.Command {
+	req := &service.RegisterRequest{}
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create an administrator in the database",
+		Long: `Creates an administrator in the database. The password is checked against
+the password policy and read from standard input when --password is omitted,
+which keeps it out of the shell history.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if req.Password == "" {
+				password, err := readPassword(cmd)
+				if err != nil {
+					return err
+				}
+				req.Password = password
+			}
+			if err := middleware.Validate(req); err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if err := a.openDB(ctx); err != nil {
+				return err
+			}
+			users, err := a.newUserService(ctx)
+			if err != nil {
+				return err
+			}
+			user, err := users.CreateAdmin(ctx, req)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created administrator %s with ID %d\n", user.Username, user.ID)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&req.Username, "username", "", "username of the administrator")
+	flags.StringVar(&req.Email, "email", "", "email of the administrator")
+	flags.StringVar(&req.Password, "password", "", "password, read from standard input when omitted")
+	flags.StringVar(&req.BranchCode, "branch", "", "code of the branch, the default branch when omitted")
+	cmd.MarkFlagRequired("username")
+	cmd.MarkFlagRequired("email")
+	return cmd
+}
+
+// newUserService creates the user service with what creating users needs
+func (a *app) newUserService(ctx context.Context) (*service.UserService, error) {
+	piiCipher, err := pii.New(ctx, &a.cfg.Encryption, repository.NewPIIKeyRepository(database.DB, a.logger))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize personal data encryption: %w", err)
+	}
+	userRepo := repository.NewUserRepository(database.DB)
+	userRepo.SetCipher(piiCipher)
+	auditRepo := repository.NewAuditRepository(database.DB, a.logger)
+	branchService := service.NewBranchService(
+		repository.NewBranchRepository(database.DB, a.logger), userRepo, auditRepo, &a.cfg.Branches, a.logger,
+	)
+
+	return service.NewUserService(
+		userRepo, repository.NewUserSettingsRepository(database.DB, a.logger), nil, nil, auditRepo, nil,
+		service.NewPasswordPolicy(&a.cfg.PasswordPolicy, a.logger), branchService, nil, &a.cfg.Login, a.logger,
+	), nil
+}
+
+// readPassword reads the password from the first line of standard input
+func readPassword(cmd *cobra.Command) (string, error) {
+	fmt.Fprint(cmd.ErrOrStderr(), "Password: ")
+	line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given on standard input")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func newUnlockCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlock <user-id>",
+		Short: "Unlock a user locked out after failed logins, through the admin API",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || userID <= 0 {
+				return fmt.Errorf("invalid user ID %q", args[0])
+			}
+
+			client, err := a.newAPIClient()
+			if err != nil {
+				return err
+			}
+			if err := client.post(fmt.Sprintf("/admin/users/%d/unlock", userID), nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Unlocked user %d\n", userID)
+			return nil
+		},
+	}
+}
@@ -2,18 +2,39 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/cache"
 	"github.com/Abigotado/abi_banking/internal/config"
 	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/events"
+	"github.com/Abigotado/abi_banking/internal/grpcapi"
 	"github.com/Abigotado/abi_banking/internal/handlers"
+	"github.com/Abigotado/abi_banking/internal/integration/billers"
+	"github.com/Abigotado/abi_banking/internal/integration/cbr"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/logging"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
 	"github.com/Abigotado/abi_banking/internal/router"
+	"github.com/Abigotado/abi_banking/internal/scheduler"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/Abigotado/abi_banking/internal/storage"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -41,36 +62,259 @@ func main() {
 		level = logrus.InfoLevel
 	}
 	logger.SetLevel(level)
+	logger.SetFormatter(logging.NewFormatter(&cfg.Log))
+
+	// Secret settings may refer to Vault or AWS KMS instead of holding the
+	// secret; the JWT secret and the database password are fetched on first use
+	// and again when their cache expires, the encryption keys once at startup
+	secretManager := secrets.NewManager(&cfg.Secrets, logger)
+	jwtSecret, err := secretManager.Secret(cfg.JWT.Secret)
+	if err != nil {
+		logger.Fatalf("Invalid JWT secret setting: %v", err)
+	}
+	middleware.SetJWTSecret(jwtSecret)
+	dbPassword, err := secretManager.Secret(cfg.Database.Password)
+	if err != nil {
+		logger.Fatalf("Invalid database password setting: %v", err)
+	}
+	if err := secretManager.ResolveEncryptionKeys(context.Background(), &cfg.Encryption); err != nil {
+		logger.Fatalf("Failed to fetch encryption keys: %v", err)
+	}
+	if cfg.Storage.LocalURLSecret, err = secretManager.Resolve(context.Background(), cfg.Storage.LocalURLSecret); err != nil {
+		logger.Fatalf("Failed to fetch storage URL signing key: %v", err)
+	}
+	if cfg.Receipts.Secret, err = secretManager.Resolve(context.Background(), cfg.Receipts.Secret); err != nil {
+		logger.Fatalf("Failed to fetch receipt signing key: %v", err)
+	}
 
 	// Initialize database
-	if err := database.InitDB(cfg, logger); err != nil {
+	if err := database.InitDB(cfg, dbPassword, logger); err != nil {
 		logger.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.CloseDB()
 
+	// "migrate" manages the schema instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(logger, os.Args[2:]); err != nil {
+			logger.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// "rotate-keys" rotates the data key of personal data and re-encrypts it
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		if err := runRotateKeys(cfg, logger, os.Args[2:]); err != nil {
+			logger.Fatalf("Key rotation failed: %v", err)
+		}
+		return
+	}
+
+	// "seed" fills the database with demo data
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(cfg, logger, os.Args[2:]); err != nil {
+			logger.Fatalf("Seeding failed: %v", err)
+		}
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		if err := autoMigrate(logger); err != nil {
+			logger.Fatalf("Failed to apply migrations: %v", err)
+		}
+	}
+	metrics.RegisterDBStats(database.DB)
+
+	// Initialize alerting and health monitoring
+	alerter := alerting.NewAlerter(&cfg.Alerting, &cfg.SMTP, logger)
+	healthMonitor := alerting.NewHealthMonitor(database.DB, alerter, &cfg.Alerting, logger)
+	healthMonitor.Start()
+	defer healthMonitor.Stop()
+
+	// Initialize the shared cache of hot reads; when disabled every read goes
+	// to the database
+	appCache := cache.New(&cfg.Cache, logger)
+	defer appCache.Close()
+
+	// Initialize CBR rate cache, refreshed before entries expire. Sandbox mode
+	// serves fixed rates instead of calling the CBR.
+	var rateSource service.RateSource = cbr.NewClient(&cfg.CBR)
+	if cfg.Sandbox.Enabled {
+		logger.Warn("Sandbox mode is enabled: CBR rates are fixed, emails are captured and test data can be wiped")
+		rateSource = cbr.NewSandbox(cfg.Sandbox.KeyRate)
+	}
+	rateService := service.NewRateService(rateSource, cfg.CBR.CacheTTL, logger)
+	rateService.SetCache(appCache)
+	rateScheduler := scheduler.NewRateScheduler(rateService, cfg.CBR.CacheTTL/2, logger)
+	rateScheduler.SetHealthMonitor(healthMonitor)
+	rateScheduler.Start()
+
+	// Initialize background job runner; handlers register their job types
+	// before it starts polling
+	jobRunner := jobs.NewRunner(repository.NewJobRepository(database.DB, logger), &cfg.Jobs, logger)
+	jobRunner.SetHealthMonitor(healthMonitor)
+
+	// Initialize the outbox relay; services register publishers for their topics
+	// before it starts polling
+	relay := outbox.NewRelay(repository.NewOutboxRepository(database.DB, logger), &cfg.Outbox, logger)
+	relay.SetHealthMonitor(healthMonitor)
+
+	// Initialize the domain event publisher; it delivers through the relay and
+	// its producer is closed once the relay has stopped on shutdown
+	eventPublisher, err := events.NewPublisher(&cfg.Events, relay, logger)
+	if err != nil {
+		logger.Fatalf("Failed to initialize event publisher: %v", err)
+	}
+	defer func() {
+		if err := eventPublisher.Close(); err != nil {
+			logger.Errorf("Failed to close event publisher: %v", err)
+		}
+	}()
+
+	// Initialize the stream of account and card changes pushed to clients. The
+	// database notifies the changes, listened to on a connection of its own;
+	// open streams are ended when the server shuts down.
+	eventStream := events.NewStream(&cfg.Stream, logger)
+	if cfg.Stream.Enabled {
+		streamCtx, stopStream := context.WithCancel(context.Background())
+		defer stopStream()
+		go database.Listen(streamCtx, &cfg.Database, dbPassword, events.StreamChannel, eventStream.Dispatch, logger)
+	}
+
+	// Initialize business parameters shared by all services
+	parameterService := service.NewParameterService(
+		repository.NewParameterRepository(database.DB, logger),
+		repository.NewAuditRepository(database.DB, logger),
+		cfg,
+		logger,
+	)
+
+	// Initialize the encryption of users' personal data; without a master key
+	// it is stored in plain text
+	piiCipher, err := pii.New(context.Background(), &cfg.Encryption, repository.NewPIIKeyRepository(database.DB, logger))
+	if err != nil {
+		logger.Fatalf("Failed to initialize personal data encryption: %v", err)
+	}
+
+	// Initialize the object storage of identity documents, statements and
+	// exported reports
+	objectStore, err := storage.New(&cfg.Storage)
+	if err != nil {
+		logger.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	if cfg.Storage.Backend == config.StorageBackendLocal && cfg.Storage.LocalURLSecret == "" {
+		logger.Warn("No storage URL signing key configured, signed URLs will stop working after a restart")
+	}
+
+	// Initialize the providers that pay bills to the billers of the catalog
+	billerProviders, err := billers.NewRegistry(&cfg.Billers)
+	if err != nil {
+		logger.Fatalf("Failed to initialize biller providers: %v", err)
+	}
+
 	// Initialize handlers
-	h := handlers.New(cfg, logger)
+	h := handlers.New(
+		cfg, logger, healthMonitor, rateService, jobRunner, relay, eventPublisher, eventStream, parameterService, appCache,
+		piiCipher, objectStore, billerProviders,
+	)
+
+	// Number the accounts opened before account numbers were issued
+	if assigned, err := h.AccountService().AssignAccountNumbers(context.Background()); err != nil {
+		logger.Errorf("Failed to assign account numbers: %v", err)
+	} else if assigned > 0 {
+		logger.Infof("Assigned account numbers to %d accounts", assigned)
+	}
+
+	jobRunner.Start()
+
+	relay.Start()
+
+	// Start the credit payment scheduler; when disabled it only runs on a
+	// manual trigger from POST /admin/scheduler/run
+	paymentScheduler := h.PaymentScheduler()
+	if cfg.Scheduler.Enabled {
+		paymentScheduler.SetHealthMonitor(healthMonitor)
+		paymentScheduler.Start()
+	} else {
+		logger.Info("Payment scheduler is disabled")
+	}
+
+	// Start the nightly reconciliation of account balances
+	reconciliationScheduler := h.ReconciliationScheduler()
+	if cfg.Reconciliation.Enabled {
+		reconciliationScheduler.SetHealthMonitor(healthMonitor)
+		reconciliationScheduler.Start()
+	} else {
+		logger.Info("Reconciliation scheduler is disabled")
+	}
+
+	// Start the periodic jobs enabled in the configuration: statements, card
+	// expiry, external transfers, user erasure, payment requests, credit rates,
+	// collections, deposits, dormancy, alerts, budgets and AML monitoring
+	jobScheduler := h.JobScheduler()
+	jobScheduler.SetHealthMonitor(healthMonitor)
+	jobScheduler.Start()
+
+	// Initialize the rate limiter buckets, shared between instances through
+	// Redis if configured
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore(cfg.RateLimit.ExpiryTime)
+	if cfg.RateLimit.Backend == config.RateLimitBackendRedis {
+		redisClient := cache.NewRedisClient(&cfg.Cache)
+		defer redisClient.Close()
+		rateLimitStore = middleware.NewRedisRateLimitStore(redisClient, cfg.Cache.KeyPrefix, cfg.RateLimit.ExpiryTime)
+	}
 
 	// Initialize router
-	r := router.NewRouter(cfg, h, logger)
+	r := router.NewRouter(cfg, h, rateLimitStore, jwtSecret, logger)
 
 	// Create HTTP server
 	server := &http.Server{
-		Addr:         ":" + cfg.App.Port,
+		Addr:         net.JoinHostPort(cfg.Server.Host, cfg.App.Port),
 		Handler:      r,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
+	// Shutdown waits for open connections, which event streams never close
+	server.RegisterOnShutdown(eventStream.Close)
+	if cfg.Server.TLSEnabled() {
+		if server.TLSConfig, err = newTLSConfig(&cfg.Server); err != nil {
+			logger.Fatalf("Failed to configure TLS: %v", err)
+		}
+	}
 
 	// Start server in a goroutine
 	go func() {
-		logger.Infof("Starting server on port %s", cfg.App.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			logger.Infof("Starting server with TLS on %s (mutual TLS on admin routes: %t)", server.Addr, cfg.Server.MutualTLSEnabled())
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			logger.Infof("Starting server on %s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
+	// Start the gRPC API for internal services on its own port
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Enabled {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPC.Port))
+		if err != nil {
+			logger.Fatalf("Failed to listen on gRPC port %d: %v", cfg.GRPC.Port, err)
+		}
+		grpcServer = grpcapi.NewServer(jwtSecret, h.UserService(), h.AccountService(), h.CreditService(), h.CardService(), logger)
+		go func() {
+			logger.Infof("Starting gRPC server on port %d", cfg.GRPC.Port)
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	} else {
+		logger.Info("gRPC server is disabled")
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -78,14 +322,69 @@ func main() {
 	logger.Info("Server is shutting down...")
 
 	// Create a deadline to wait for
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
+	}
+
+	// Let in-flight gRPC calls finish within the same deadline
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			logger.Warn("gRPC server forced to stop")
+			grpcServer.Stop()
+		}
 	}
 
+	// Drain the background workers within the same deadline. The schedulers go
+	// first as their runs queue jobs and outbox messages, then the job runner,
+	// whose jobs add outbox messages, and the relay last.
+	rateScheduler.Stop(ctx)
+	paymentScheduler.Stop(ctx)
+	reconciliationScheduler.Stop(ctx)
+	jobScheduler.Stop(ctx)
+	jobRunner.Stop(ctx)
+	relay.Stop(ctx)
+
 	logger.Info("Server exited properly")
 }
+
+// newTLSConfig builds the TLS configuration of the HTTP server. With a client CA
+// configured, clients may present a certificate, which is verified against it;
+// the routes that require one are decided by the router.
+func newTLSConfig(cfg *config.ServerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	switch cfg.TLSMinVersion {
+	case "", "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, use 1.2 or 1.3", cfg.TLSMinVersion)
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
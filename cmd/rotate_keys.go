@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const rotateKeysUsage = `usage: rotate-keys [--force]
+
+Replaces the data key of users' personal data once it is older than
+ENCRYPTION_KEY_ROTATION_DAYS, or at once with --force, encrypts all personal
+data under the active key and retires the previous keys.`
+
+// runRotateKeys executes the rotate-keys subcommand
+func runRotateKeys(cfg *config.Config, logger *logrus.Logger, args []string) error {
+	force := false
+	for _, arg := range args {
+		if arg != "--force" {
+			return fmt.Errorf("unknown argument %q\n%s", arg, rotateKeysUsage)
+		}
+		force = true
+	}
+
+	ctx := context.Background()
+	keyRepo := repository.NewPIIKeyRepository(database.DB, logger)
+	piiCipher, err := pii.New(ctx, &cfg.Encryption, keyRepo)
+	if err != nil {
+		return err
+	}
+	userRepo := repository.NewUserRepository(database.DB)
+	userRepo.SetCipher(piiCipher)
+
+	rotation := service.NewKeyRotationService(piiCipher, userRepo, keyRepo, cfg.Encryption.KeyRotationDays)
+	result, err := rotation.Rotate(ctx, force)
+	if err != nil {
+		return err
+	}
+	if result.Rotated {
+		logger.Infof("Created data key %d", result.KeyID)
+	} else {
+		logger.Infof("Data key %d is not due for rotation", result.KeyID)
+	}
+	logger.Infof("Re-encrypted the personal data of %d users", result.Reencrypted)
+	logger.Infof("Retired %d data keys", result.Retired)
+	return nil
+}
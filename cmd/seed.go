@@ -0,0 +1,599 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const seedUsage = `usage: seed [flags]
+
+Fills the database with demo data: verified users with accounts in several
+currencies, their transaction history and active and overdue credits. The same
+flags give the same data, dated back from the day of the run.
+
+flags:
+  --users N          users to create (default 20)
+  --accounts N       at most N accounts per user, the first in RUB (default 3, at most 4)
+  --transactions N   transactions per account besides salaries and credits (default 40)
+  --credits N        users with a credit (default 8)
+  --overdue N        how many of these credits have overdue installments (default 3)
+  --months N         length of the history, from 2 to 60 months (default 6)
+  --seed N           seed of the random generator (default 1)
+  --password P       password of every demo user (default demo-password)`
+
+// seedOptions are the parameters of the seed subcommand
+type seedOptions struct {
+	users        int
+	accounts     int
+	transactions int
+	credits      int
+	overdue      int
+	months       int
+	seed         int64
+	password     string
+}
+
+// seedCurrency is a currency demo accounts are opened in, with the range of
+// the amount of a single transaction
+type seedCurrency struct {
+	code      string
+	minAmount float64
+	maxAmount float64
+}
+
+// seedCurrencies lists the currencies of demo accounts; every user has a RUB
+// account, which receives the salary and repays the credit
+var seedCurrencies = []seedCurrency{
+	{code: "RUB", minAmount: 150, maxAmount: 15000},
+	{code: "USD", minAmount: 5, maxAmount: 400},
+	{code: "EUR", minAmount: 5, maxAmount: 400},
+	{code: "CNY", minAmount: 30, maxAmount: 3000},
+}
+
+type seedName struct {
+	ru    string
+	latin string
+}
+
+var seedMaleFirstNames = []seedName{
+	{"Александр", "aleksandr"}, {"Дмитрий", "dmitry"}, {"Сергей", "sergey"}, {"Андрей", "andrey"},
+	{"Иван", "ivan"}, {"Михаил", "mikhail"}, {"Алексей", "aleksey"}, {"Николай", "nikolay"},
+}
+
+var seedFemaleFirstNames = []seedName{
+	{"Анна", "anna"}, {"Мария", "maria"}, {"Елена", "elena"}, {"Ольга", "olga"},
+	{"Наталья", "natalya"}, {"Татьяна", "tatyana"}, {"Екатерина", "ekaterina"}, {"Ирина", "irina"},
+}
+
+// seedLastNames are given in the male form; the female form adds "а"
+var seedLastNames = []seedName{
+	{"Иванов", "ivanov"}, {"Смирнов", "smirnov"}, {"Кузнецов", "kuznetsov"}, {"Попов", "popov"},
+	{"Соколов", "sokolov"}, {"Лебедев", "lebedev"}, {"Козлов", "kozlov"}, {"Новиков", "novikov"},
+	{"Морозов", "morozov"}, {"Волков", "volkov"},
+}
+
+// maxAccountNumberDraws bounds the serials drawn for one account
+const maxAccountNumberDraws = 10
+
+// runSeed executes the seed subcommand
+func runSeed(cfg *config.Config, logger *logrus.Logger, args []string) error {
+	opts, err := parseSeedOptions(args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	branch, err := repository.NewBranchRepository(database.DB, logger).GetByCode(ctx, cfg.Branches.DefaultCode)
+	if err != nil {
+		return fmt.Errorf("failed to get the default branch: %w", err)
+	}
+	piiCipher, err := pii.New(ctx, &cfg.Encryption, repository.NewPIIKeyRepository(database.DB, logger))
+	if err != nil {
+		return err
+	}
+	userRepo := repository.NewUserRepository(database.DB)
+	userRepo.SetCipher(piiCipher)
+
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	s := &seeder{
+		opts:        opts,
+		rng:         rand.New(rand.NewSource(opts.seed)),
+		start:       today.AddDate(0, -opts.months, 0),
+		today:       today,
+		branchID:    branch.ID,
+		bank:        &cfg.Bank,
+		userRepo:    userRepo,
+		kycRepo:     repository.NewKYCRepository(database.DB, logger),
+		accountRepo: repository.NewAccountRepository(database.DB, logger),
+		creditRepo:  repository.NewCreditRepository(database.DB),
+		logger:      logger,
+		balances:    make(map[int64]float64),
+		repayments:  make(map[int64][]*seedEvent),
+	}
+	return s.run(ctx)
+}
+
+func parseSeedOptions(args []string) (*seedOptions, error) {
+	opts := &seedOptions{}
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.IntVar(&opts.users, "users", 20, "")
+	flags.IntVar(&opts.accounts, "accounts", 3, "")
+	flags.IntVar(&opts.transactions, "transactions", 40, "")
+	flags.IntVar(&opts.credits, "credits", 8, "")
+	flags.IntVar(&opts.overdue, "overdue", 3, "")
+	flags.IntVar(&opts.months, "months", 6, "")
+	flags.Int64Var(&opts.seed, "seed", 1, "")
+	flags.StringVar(&opts.password, "password", "demo-password", "")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%v\n%s", err, seedUsage)
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unknown argument %q\n%s", flags.Arg(0), seedUsage)
+	}
+
+	switch {
+	case opts.users <= 0:
+		return nil, errors.New("--users must be positive")
+	case opts.accounts <= 0 || opts.accounts > len(seedCurrencies):
+		return nil, fmt.Errorf("--accounts must be between 1 and %d", len(seedCurrencies))
+	case opts.transactions < 0:
+		return nil, errors.New("--transactions cannot be negative")
+	case opts.credits < 0 || opts.credits > opts.users:
+		return nil, errors.New("--credits must be between 0 and --users")
+	case opts.overdue < 0 || opts.overdue > opts.credits:
+		return nil, errors.New("--overdue must be between 0 and --credits")
+	case opts.months < 2 || opts.months > 60:
+		return nil, errors.New("--months must be between 2 and 60")
+	case opts.password == "":
+		return nil, errors.New("--password cannot be empty")
+	}
+	return opts, nil
+}
+
+// seeder creates the demo data. Every random choice is drawn from rng in a
+// fixed order, so a seed always gives the same data.
+type seeder struct {
+	opts        *seedOptions
+	rng         *rand.Rand
+	start       time.Time // first day of the history
+	today       time.Time // the history ends before today
+	branchID    int64     // users join the default branch, as on registration
+	bank        *config.BankConfig
+	userRepo    *repository.UserRepository
+	kycRepo     *repository.KYCRepository
+	accountRepo *repository.AccountRepository
+	creditRepo  *repository.CreditRepository
+	logger      *logrus.Logger
+
+	users  []*seedUser
+	events []*seedEvent
+	// balances follows the balance of every account while the history is
+	// posted, so that spending never overdraws an account
+	balances map[int64]float64
+	// repayments holds the credit payments of each account, which spending
+	// leaves money for
+	repayments map[int64][]*seedEvent
+}
+
+// seedUser is a demo user with the accounts opened for them
+type seedUser struct {
+	user     *models.User
+	salary   float64
+	accounts []*models.Account // the first is in RUB
+	// salaryUntil ends the salary of a borrower who stopped repaying
+	salaryUntil time.Time
+}
+
+// seedEvent is a transaction of the history, posted in the order of time
+type seedEvent struct {
+	transaction *models.Transaction
+	// spending is dropped when the account cannot afford it; salaries and
+	// credit payments are always posted
+	spending bool
+}
+
+func (s *seeder) run(ctx context.Context) error {
+	hash := &models.User{Password: s.opts.password}
+	if err := hash.HashPassword(); err != nil {
+		return err
+	}
+
+	for i := 0; i < s.opts.users; i++ {
+		u := s.newUser(i, hash.Password)
+		if i == 0 {
+			exists, err := s.userRepo.CheckUsernameExists(ctx, u.user.Username)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("demo data of seed %d is already loaded, choose another --seed", s.opts.seed)
+			}
+		}
+		if err := s.createUser(ctx, u); err != nil {
+			return fmt.Errorf("failed to create user %s: %w", u.user.Username, err)
+		}
+		s.users = append(s.users, u)
+	}
+
+	borrowers := s.rng.Perm(len(s.users))[:s.opts.credits]
+	for i, index := range borrowers {
+		if err := s.createCredit(ctx, s.users[index], i < s.opts.overdue); err != nil {
+			return fmt.Errorf("failed to create credit of user %s: %w", s.users[index].user.Username, err)
+		}
+	}
+
+	for _, u := range s.users {
+		s.planHistory(u)
+	}
+	posted, err := s.postHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("posted %d transactions before failing: %w", posted, err)
+	}
+
+	accounts := 0
+	for _, u := range s.users {
+		accounts += len(u.accounts)
+	}
+	s.logger.Infof("Created %d users with %d accounts, %d transactions and %d credits (%d overdue)",
+		len(s.users), accounts, posted, s.opts.credits, s.opts.overdue)
+	return nil
+}
+
+// newUser draws the profile of the i-th user
+func (s *seeder) newUser(i int, passwordHash string) *seedUser {
+	var first seedName
+	last := seedLastNames[s.rng.Intn(len(seedLastNames))]
+	if s.rng.Intn(2) == 0 {
+		first = seedMaleFirstNames[s.rng.Intn(len(seedMaleFirstNames))]
+	} else {
+		first = seedFemaleFirstNames[s.rng.Intn(len(seedFemaleFirstNames))]
+		last = seedName{ru: last.ru + "а", latin: last.latin + "a"}
+	}
+
+	username := fmt.Sprintf("%s.%s.%d.%d", first.latin, last.latin, s.opts.seed, i+1)
+	return &seedUser{
+		user: &models.User{
+			Username:    username,
+			Email:       username + "@demo.example.com",
+			Password:    passwordHash,
+			BranchID:    s.branchID,
+			FirstName:   first.ru,
+			LastName:    last.ru,
+			PhoneNumber: fmt.Sprintf("+79%09d", s.rng.Intn(1_000_000_000)),
+		},
+		salary: math.Round(s.amount(60000, 250000)/1000) * 1000,
+	}
+}
+
+// createUser creates a verified user with a RUB account and, up to --accounts,
+// accounts in other currencies
+func (s *seeder) createUser(ctx context.Context, u *seedUser) error {
+	if err := s.userRepo.Create(ctx, u.user); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateProfile(ctx, u.user); err != nil {
+		return err
+	}
+	if err := s.verify(ctx, u.user.ID); err != nil {
+		return err
+	}
+
+	currencies := []seedCurrency{seedCurrencies[0]}
+	others := s.rng.Perm(len(seedCurrencies) - 1)[:s.rng.Intn(s.opts.accounts)]
+	for _, i := range others {
+		currencies = append(currencies, seedCurrencies[i+1])
+	}
+
+	for _, currency := range currencies {
+		opened := s.start.AddDate(0, 0, -1-s.rng.Intn(30))
+		account := &models.Account{
+			UserID:    u.user.ID,
+			Currency:  currency.code,
+			CreatedAt: opened,
+			UpdatedAt: opened,
+		}
+		if err := s.createAccount(ctx, account); err != nil {
+			return err
+		}
+		u.accounts = append(u.accounts, account)
+	}
+	return nil
+}
+
+// verify passes the identity verification of a user, as credits are only
+// granted to verified users
+func (s *seeder) verify(ctx context.Context, userID int64) error {
+	if _, err := s.kycRepo.Submit(ctx, userID); err != nil {
+		return err
+	}
+
+	tx, err := s.kycRepo.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	verification := &models.KYCVerification{UserID: userID, Status: models.KYCStatusVerified, ReviewedAt: &now}
+	if _, err := s.kycRepo.ReviewTx(ctx, tx, verification); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createAccount numbers an account with a drawn serial and creates it
+func (s *seeder) createAccount(ctx context.Context, account *models.Account) error {
+	for draw := 1; ; draw++ {
+		number, err := models.NewAccountNumber(s.bank.BIC, s.bank.BalanceAccount, account.Currency, s.bank.BranchCode,
+			fmt.Sprintf("%07d", s.rng.Intn(10_000_000)))
+		if err != nil {
+			return err
+		}
+		account.Number = number
+
+		err = s.accountRepo.Create(ctx, account)
+		if err == nil || !errors.Is(err, repository.ErrAccountNumberTaken) || draw == maxAccountNumberDraws {
+			return err
+		}
+	}
+}
+
+// createCredit grants a fixed-rate credit on the RUB account of a user some
+// time after the history starts, with the installments due so far paid. An
+// overdue credit leaves its last installments unpaid and the borrower without
+// a salary from a month before the first of them.
+func (s *seeder) createCredit(ctx context.Context, u *seedUser, overdue bool) error {
+	account := u.accounts[0]
+	days := int(s.today.Sub(s.start).Hours()/24) - 32
+	issuedAt := s.start.AddDate(0, 0, 1+s.rng.Intn(days)).Add(11 * time.Hour)
+	elapsed := 0
+	for issuedAt.AddDate(0, elapsed+1, 0).Before(s.today) {
+		elapsed++
+	}
+
+	var terms []int
+	for _, term := range []int{12, 24, 36, 60} {
+		if term > elapsed {
+			terms = append(terms, term)
+		}
+	}
+	credit := &models.Credit{
+		UserID:       u.user.ID,
+		AccountID:    account.ID,
+		Amount:       u.salary * float64(2+s.rng.Intn(7)),
+		InterestRate: 12 + 0.5*float64(s.rng.Intn(25)),
+		RateType:     models.RateTypeFixed,
+		TermMonths:   terms[s.rng.Intn(len(terms))],
+		ScheduleType: models.ScheduleTypeAnnuity,
+		Status:       string(models.CreditStatusActive),
+	}
+	if s.rng.Intn(5) == 0 {
+		credit.ScheduleType = models.ScheduleTypeDifferentiated
+	}
+	// Keep the installments within 40% of the salary
+	first := models.BuildInstallments(credit.Amount, credit.InterestRate/12/100, credit.TermMonths, credit.ScheduleType)[0]
+	if limit := u.salary * 0.4; first.Amount > limit {
+		credit.Amount *= limit / first.Amount
+	}
+	credit.Amount = math.Max(math.Floor(credit.Amount/10000)*10000, 10000)
+	credit.RemainingAmount = credit.Amount
+
+	unpaid := 0
+	if overdue {
+		unpaid = 1 + s.rng.Intn(min(3, elapsed))
+	}
+
+	tx, err := s.creditRepo.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.creditRepo.CreateTx(ctx, tx, credit); err != nil {
+		return err
+	}
+	schedule := models.GeneratePaymentSchedule(credit, issuedAt.AddDate(0, 1, 0))
+	for i := range schedule {
+		payment := &schedule[i]
+		if i >= elapsed-unpaid && i < elapsed {
+			payment.Status = models.PaymentStatusOverdue
+		}
+		if err := s.creditRepo.CreatePaymentScheduleTx(ctx, tx, payment); err != nil {
+			return err
+		}
+		if i >= elapsed-unpaid {
+			continue
+		}
+
+		paidAt := payment.DueDate
+		payment.Status = models.PaymentStatusPaid
+		payment.PaidAmount = payment.Amount
+		payment.PaidAt = &paidAt
+		if err := s.creditRepo.UpdatePaymentPaidTx(ctx, tx, payment); err != nil {
+			return err
+		}
+		credit.RemainingAmount = math.Round((credit.RemainingAmount-payment.Principal)*100) / 100
+
+		repayment := &seedEvent{transaction: &models.Transaction{
+			FromAccountID: account.ID,
+			Amount:        payment.Amount,
+			Type:          models.TransactionTypeCreditRepayment,
+			Description:   fmt.Sprintf("Payment of credit #%d", credit.ID),
+			CreatedAt:     paidAt,
+		}}
+		s.events = append(s.events, repayment)
+		s.repayments[account.ID] = append(s.repayments[account.ID], repayment)
+	}
+	if err := s.creditRepo.UpdateTx(ctx, tx, credit); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if overdue {
+		u.salaryUntil = schedule[elapsed-unpaid].DueDate.AddDate(0, -1, 0)
+	}
+	s.events = append(s.events, &seedEvent{transaction: &models.Transaction{
+		ToAccountID: account.ID,
+		Amount:      credit.Amount,
+		Type:        models.TransactionTypeCreditDisbursement,
+		Description: fmt.Sprintf("Disbursement of credit #%d", credit.ID),
+		CreatedAt:   issuedAt,
+	}})
+	return nil
+}
+
+// planHistory draws the transactions of a user: an opening deposit on every
+// account, a monthly salary on the RUB account and --transactions cash
+// deposits, withdrawals and transfers to other users per account
+func (s *seeder) planHistory(u *seedUser) {
+	for payday := time.Date(s.start.Year(), s.start.Month(), 5, 9, 0, 0, 0, time.Local); payday.Before(s.today); payday = payday.AddDate(0, 1, 0) {
+		if payday.Before(s.start) || (!u.salaryUntil.IsZero() && !payday.Before(u.salaryUntil)) {
+			continue
+		}
+		s.events = append(s.events, &seedEvent{transaction: &models.Transaction{
+			ToAccountID: u.accounts[0].ID,
+			Amount:      u.salary,
+			Type:        models.TransactionTypeDeposit,
+			Description: "Salary",
+			CreatedAt:   payday,
+		}})
+	}
+
+	for i, account := range u.accounts {
+		currency := currencyOf(account.Currency)
+		opening := s.amount(currency.maxAmount, currency.maxAmount*10)
+		if i == 0 {
+			opening = u.salary * (0.2 + 0.8*s.rng.Float64())
+		}
+		s.events = append(s.events, &seedEvent{transaction: &models.Transaction{
+			ToAccountID: account.ID,
+			Amount:      math.Round(opening),
+			Type:        models.TransactionTypeDeposit,
+			Description: "Cash deposit",
+			CreatedAt:   s.start.Add(time.Duration(s.rng.Int63n(int64(48 * time.Hour)))),
+		}})
+
+		for n := 0; n < s.opts.transactions; n++ {
+			at := s.start.Add(time.Duration(s.rng.Int63n(int64(s.today.Sub(s.start)))))
+			amount := s.amount(currency.minAmount, currency.maxAmount)
+			if account.Currency == "RUB" {
+				amount = math.Round(amount)
+			}
+
+			transaction := &models.Transaction{Amount: amount, CreatedAt: at}
+			switch kind := s.rng.Intn(10); {
+			case kind < 2:
+				transaction.ToAccountID = account.ID
+				transaction.Type = models.TransactionTypeDeposit
+				transaction.Description = "Cash deposit"
+			case kind < 5:
+				if to := s.recipient(u, account.Currency); to != nil {
+					transaction.FromAccountID = account.ID
+					transaction.ToAccountID = to.ID
+					transaction.Type = models.TransactionTypeTransfer
+					transaction.Description = "Transfer"
+					break
+				}
+				fallthrough
+			default:
+				transaction.FromAccountID = account.ID
+				transaction.Type = models.TransactionTypeWithdrawal
+				transaction.Description = "ATM withdrawal"
+			}
+			s.events = append(s.events, &seedEvent{transaction: transaction, spending: transaction.FromAccountID != 0})
+		}
+	}
+}
+
+// recipient draws an account of another user in the currency, or nil when
+// no other user has one
+func (s *seeder) recipient(from *seedUser, currency string) *models.Account {
+	var accounts []*models.Account
+	for _, u := range s.users {
+		if u == from {
+			continue
+		}
+		for _, account := range u.accounts {
+			if account.Currency == currency {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	return accounts[s.rng.Intn(len(accounts))]
+}
+
+// postHistory posts the planned transactions in the order of time. Spending
+// that would overdraw the account, or leave it short of a credit payment due
+// within a month, is dropped.
+func (s *seeder) postHistory(ctx context.Context) (int, error) {
+	sort.SliceStable(s.events, func(i, j int) bool {
+		return s.events[i].transaction.CreatedAt.Before(s.events[j].transaction.CreatedAt)
+	})
+
+	posted := 0
+	for _, event := range s.events {
+		transaction := event.transaction
+		if event.spending {
+			available := s.balances[transaction.FromAccountID] - s.reserve(transaction.FromAccountID, transaction.CreatedAt)
+			if transaction.Amount > available {
+				continue
+			}
+		}
+
+		if err := s.accountRepo.PostTransaction(ctx, transaction); err != nil {
+			return posted, err
+		}
+		s.balances[transaction.FromAccountID] -= transaction.Amount
+		s.balances[transaction.ToAccountID] += transaction.Amount
+		posted++
+	}
+	return posted, nil
+}
+
+// reserve returns the credit payments of an account due within a month of at
+func (s *seeder) reserve(accountID int64, at time.Time) float64 {
+	var reserve float64
+	for _, repayment := range s.repayments[accountID] {
+		due := repayment.transaction.CreatedAt
+		if due.After(at) && due.Before(at.AddDate(0, 1, 0)) {
+			reserve += repayment.transaction.Amount
+		}
+	}
+	return reserve
+}
+
+// amount draws an amount between low and high, small amounts being as likely
+// as large ones in proportion, rounded to cents
+func (s *seeder) amount(low, high float64) float64 {
+	return math.Round(low*math.Pow(high/low, s.rng.Float64())*100) / 100
+}
+
+func currencyOf(code string) seedCurrency {
+	for _, currency := range seedCurrencies {
+		if currency.code == code {
+			return currency
+		}
+	}
+	return seedCurrencies[0]
+}
@@ -0,0 +1,128 @@
+// Package accounting renders the accounting export of the ledger in the formats
+// accounting systems import: CSV for a generic general ledger import and the XML
+// exchange file loaded by 1C data processing.
+package accounting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// Export formats
+const (
+	FormatCSV = "csv"
+	FormatXML = "xml"
+)
+
+// descriptions hold the content of the entries of each transaction type, in
+// Russian as the accounting systems expect it
+var descriptions = map[string]string{
+	models.TransactionTypeTransfer:           "Перевод между счетами клиентов",
+	models.TransactionTypeDeposit:            "Взнос наличных на счет",
+	models.TransactionTypeWithdrawal:         "Выдача наличных со счета",
+	models.TransactionTypeOverdraftInterest:  "Проценты за пользование овердрафтом",
+	models.TransactionTypeCardPayment:        "Расчеты по операциям с картами",
+	models.TransactionTypeCreditRepayment:    "Погашение кредита",
+	models.TransactionTypeCreditDisbursement: "Выдача кредита",
+	models.TransactionTypeExternalTransfer:   "Перевод в другой банк",
+	models.TransactionTypeExternalReturn:     "Возврат перевода из другого банка",
+	models.TransactionTypeDisputeCredit:      "Зачисление по оспариваемой операции",
+	models.TransactionTypeDisputeReversal:    "Списание ранее зачисленной суммы по оспариваемой операции",
+	models.TransactionTypeBillPayment:        "Оплата услуг поставщика",
+	models.TransactionTypeBillRefund:         "Возврат неисполненной оплаты услуг",
+	models.TransactionTypeDepositOpening:     "Размещение срочного вклада",
+	models.TransactionTypeDepositReturn:      "Возврат срочного вклада",
+	models.TransactionTypeDepositInterest:    "Выплата процентов по срочному вкладу",
+	models.TransactionTypeDormancyFee:        "Комиссия за обслуживание неактивного счета",
+}
+
+// Description returns the content of an entry of the transaction type
+func Description(transactionType string) string {
+	if description, ok := descriptions[transactionType]; ok {
+		return description
+	}
+	return transactionType
+}
+
+// RenderCSV renders the export as CSV with a header row, one row per entry
+func RenderCSV(export *models.AccountingExport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{
+		"date", "debit_account", "credit_account", "amount", "currency",
+		"description", "transaction_type", "transactions",
+	})
+	for _, entry := range export.Entries {
+		w.Write([]string{
+			entry.Date.Format("2006-01-02"),
+			entry.DebitAccount,
+			entry.CreditAccount,
+			fmt.Sprintf("%.2f", entry.Amount),
+			entry.Currency,
+			Description(entry.TransactionType),
+			entry.TransactionType,
+			fmt.Sprint(entry.Transactions),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exchangeFile is the XML exchange file of the 1C import: the period and one
+// element per entry with the debit and credit accounts as attributes
+type exchangeFile struct {
+	XMLName     xml.Name        `xml:"ФайлОбмена"`
+	Version     string          `xml:"ВерсияФормата,attr"`
+	Period      string          `xml:"Период,attr"`
+	From        string          `xml:"ДатаНачала,attr"`
+	To          string          `xml:"ДатаОкончания,attr"`
+	GeneratedAt string          `xml:"ДатаВыгрузки,attr"`
+	Entries     []exchangeEntry `xml:"Проводка"`
+}
+
+type exchangeEntry struct {
+	Date          string `xml:"Дата,attr"`
+	DebitAccount  string `xml:"СчетДт,attr"`
+	CreditAccount string `xml:"СчетКт,attr"`
+	Amount        string `xml:"Сумма,attr"`
+	Currency      string `xml:"Валюта,attr"`
+	Transactions  int    `xml:"КоличествоОпераций,attr"`
+	Content       string `xml:"Содержание"`
+}
+
+// RenderXML renders the export as the XML exchange file of the 1C import
+func RenderXML(export *models.AccountingExport) ([]byte, error) {
+	const dateTime = "2006-01-02T15:04:05"
+	file := exchangeFile{
+		Version:     "1.0",
+		Period:      export.Period,
+		From:        export.From.Format(dateTime),
+		To:          export.To.AddDate(0, 0, -1).Format("2006-01-02") + "T23:59:59",
+		GeneratedAt: export.GeneratedAt.Format(dateTime),
+		Entries:     make([]exchangeEntry, 0, len(export.Entries)),
+	}
+	for _, entry := range export.Entries {
+		file.Entries = append(file.Entries, exchangeEntry{
+			Date:          entry.Date.Format(dateTime),
+			DebitAccount:  entry.DebitAccount,
+			CreditAccount: entry.CreditAccount,
+			Amount:        fmt.Sprintf("%.2f", entry.Amount),
+			Currency:      entry.Currency,
+			Transactions:  entry.Transactions,
+			Content:       Description(entry.TransactionType),
+		})
+	}
+
+	payload, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), payload...), nil
+}
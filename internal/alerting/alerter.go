@@ -0,0 +1,203 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Severity represents the urgency of an alert
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Alert represents an operational alert sent to the ops channel
+type Alert struct {
+	// Key identifies the underlying problem; alerts with the same key are deduplicated
+	Key      string    `json:"key"`
+	Severity Severity  `json:"severity"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Channel delivers alerts to operators
+type Channel interface {
+	Send(alert *Alert) error
+}
+
+// Alerter fans alerts out to the configured channels, suppressing repeats of the
+// same alert until its cool-down has elapsed
+type Alerter struct {
+	channels []Channel
+	cooldown time.Duration
+	logger   *logrus.Logger
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time
+	suppressed map[string]int
+}
+
+// NewAlerter creates a new Alerter from configuration. A disabled alerter only logs.
+func NewAlerter(cfg *config.AlertingConfig, smtpCfg *config.SMTPConfig, logger *logrus.Logger) *Alerter {
+	a := &Alerter{
+		cooldown:   cfg.Cooldown,
+		logger:     logger,
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+
+	if !cfg.Enabled {
+		return a
+	}
+
+	if len(cfg.EmailRecipients) > 0 && smtpCfg.Host != "" {
+		a.channels = append(a.channels, NewEmailChannel(smtp.NewClient(smtpCfg), cfg.EmailRecipients))
+	}
+	if cfg.WebhookURL != "" {
+		a.channels = append(a.channels, NewWebhookChannel(cfg.WebhookURL, cfg.WebhookType, cfg.TelegramChatID))
+	}
+
+	return a
+}
+
+// Alert sends an alert unless the same key was alerted within the cool-down period.
+// Delivery happens in the background so callers are never blocked by a slow channel.
+func (a *Alerter) Alert(alert *Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+
+	a.mu.Lock()
+	if last, ok := a.lastSent[alert.Key]; ok && alert.Time.Sub(last) < a.cooldown {
+		a.suppressed[alert.Key]++
+		a.mu.Unlock()
+		a.logger.WithField("alert", alert.Key).Debug("Alert suppressed by cool-down")
+		return
+	}
+	if n := a.suppressed[alert.Key]; n > 0 {
+		alert.Message = fmt.Sprintf("%s (%d similar alerts suppressed)", alert.Message, n)
+	}
+	a.lastSent[alert.Key] = alert.Time
+	a.suppressed[alert.Key] = 0
+	a.mu.Unlock()
+
+	a.logger.WithFields(logrus.Fields{
+		"alert":    alert.Key,
+		"severity": alert.Severity,
+	}).Warn(alert.Title + ": " + alert.Message)
+
+	go a.dispatch(alert)
+}
+
+// Resolve clears the cool-down of an alert so a recurrence is reported immediately
+func (a *Alerter) Resolve(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.lastSent, key)
+	delete(a.suppressed, key)
+}
+
+func (a *Alerter) dispatch(alert *Alert) {
+	for _, channel := range a.channels {
+		if err := channel.Send(alert); err != nil {
+			a.logger.WithError(err).WithField("alert", alert.Key).Error("Failed to deliver alert")
+		}
+	}
+}
+
+// EmailChannel delivers alerts to an email distribution list via SMTP
+type EmailChannel struct {
+	client     *smtp.Client
+	recipients []string
+}
+
+// NewEmailChannel creates a new EmailChannel
+func NewEmailChannel(client *smtp.Client, recipients []string) *EmailChannel {
+	return &EmailChannel{
+		client:     client,
+		recipients: recipients,
+	}
+}
+
+// Send emails the alert to every recipient
+func (c *EmailChannel) Send(alert *Alert) error {
+	var notifications []*models.Notification
+	for _, recipient := range c.recipients {
+		notifications = append(notifications, &models.Notification{
+			Type:      models.NotificationTypeEmail,
+			Priority:  models.PriorityHigh,
+			Status:    models.NotificationStatusPending,
+			Subject:   fmt.Sprintf("[%s] %s", alert.Severity, alert.Title),
+			Content:   fmt.Sprintf("<p>%s</p><p>%s</p>", alert.Message, alert.Time.Format(time.RFC3339)),
+			Recipient: recipient,
+		})
+	}
+
+	if errs := c.client.SendBulkEmails(notifications); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// WebhookChannel delivers alerts to a chat webhook (Slack, Telegram) or a generic JSON endpoint
+type WebhookChannel struct {
+	url        string
+	kind       string
+	chatID     string
+	httpClient *http.Client
+}
+
+// NewWebhookChannel creates a new WebhookChannel
+func NewWebhookChannel(url, kind, chatID string) *WebhookChannel {
+	return &WebhookChannel{
+		url:    url,
+		kind:   kind,
+		chatID: chatID,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Send posts the alert to the webhook in the format the receiver expects
+func (c *WebhookChannel) Send(alert *Alert) error {
+	text := fmt.Sprintf("[%s] %s\n%s", alert.Severity, alert.Title, alert.Message)
+
+	var payload interface{}
+	switch c.kind {
+	case "slack":
+		payload = map[string]string{"text": text}
+	case "telegram":
+		payload = map[string]string{"chat_id": c.chatID, "text": text}
+	default:
+		payload = alert
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package alerting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const alertKeyReadiness = "readiness"
+
+type heartbeat struct {
+	last   time.Time
+	maxAge time.Duration
+}
+
+// HealthMonitor periodically runs readiness checks and watches background job
+// heartbeats, raising alerts when the service degrades
+type HealthMonitor struct {
+	db               *sql.DB
+	alerter          *Alerter
+	interval         time.Duration
+	failureThreshold int
+	logger           *logrus.Logger
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	heartbeats          map[string]*heartbeat
+	stalled             map[string]bool
+
+	ticker *time.Ticker
+	done   chan bool
+}
+
+// NewHealthMonitor creates a new HealthMonitor
+func NewHealthMonitor(db *sql.DB, alerter *Alerter, cfg *config.AlertingConfig, logger *logrus.Logger) *HealthMonitor {
+	return &HealthMonitor{
+		db:               db,
+		alerter:          alerter,
+		interval:         cfg.CheckInterval,
+		failureThreshold: cfg.ReadinessFailureThreshold,
+		logger:           logger,
+		heartbeats:       make(map[string]*heartbeat),
+		stalled:          make(map[string]bool),
+		done:             make(chan bool),
+	}
+}
+
+// Alerter returns the alerter used by the monitor so other components can raise alerts
+func (m *HealthMonitor) Alerter() *Alerter {
+	return m.alerter
+}
+
+// Start begins periodic health checks
+func (m *HealthMonitor) Start() {
+	m.logger.Info("Starting health monitor")
+	m.ticker = time.NewTicker(m.interval)
+	go m.run()
+}
+
+// Stop stops periodic health checks
+func (m *HealthMonitor) Stop() {
+	m.logger.Info("Stopping health monitor")
+	m.ticker.Stop()
+	m.done <- true
+}
+
+func (m *HealthMonitor) run() {
+	for {
+		select {
+		case <-m.ticker.C:
+			m.check()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// RegisterHeartbeat starts watching a background job that is expected to call
+// Beat at least once every maxAge
+func (m *HealthMonitor) RegisterHeartbeat(name string, maxAge time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeats[name] = &heartbeat{last: time.Now(), maxAge: maxAge}
+}
+
+// Beat records that a background job has completed a run
+func (m *HealthMonitor) Beat(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hb, ok := m.heartbeats[name]; ok {
+		hb.last = time.Now()
+	}
+}
+
+// Ready checks whether the service can serve traffic
+func (m *HealthMonitor) Ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := m.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Status reports the readiness of the service and the heartbeats of its
+// background processes, sorted by name
+func (m *HealthMonitor) Status(ctx context.Context) *models.HealthStatus {
+	status := &models.HealthStatus{Ready: true, Heartbeats: []*models.HeartbeatStatus{}}
+	if err := m.Ready(ctx); err != nil {
+		status.Ready = false
+		status.DatabaseError = err.Error()
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	for name, hb := range m.heartbeats {
+		status.Heartbeats = append(status.Heartbeats, &models.HeartbeatStatus{
+			Name:     name,
+			LastBeat: hb.last,
+			MaxAge:   hb.maxAge.String(),
+			Stalled:  now.Sub(hb.last) > hb.maxAge,
+		})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(status.Heartbeats, func(i, j int) bool {
+		return status.Heartbeats[i].Name < status.Heartbeats[j].Name
+	})
+	return status
+}
+
+func (m *HealthMonitor) check() {
+	m.checkReadiness()
+	m.checkHeartbeats()
+}
+
+func (m *HealthMonitor) checkReadiness() {
+	err := m.Ready(context.Background())
+
+	m.mu.Lock()
+	if err == nil {
+		recovered := m.consecutiveFailures >= m.failureThreshold
+		m.consecutiveFailures = 0
+		m.mu.Unlock()
+		if recovered {
+			m.logger.Info("Readiness check recovered")
+			m.alerter.Resolve(alertKeyReadiness)
+		}
+		return
+	}
+	m.consecutiveFailures++
+	failures := m.consecutiveFailures
+	m.mu.Unlock()
+
+	m.logger.WithError(err).Warn("Readiness check failed")
+	if failures >= m.failureThreshold {
+		m.alerter.Alert(&Alert{
+			Key:      alertKeyReadiness,
+			Severity: SeverityCritical,
+			Title:    "Readiness check failing",
+			Message:  fmt.Sprintf("%d consecutive readiness failures: %v", failures, err),
+		})
+	}
+}
+
+func (m *HealthMonitor) checkHeartbeats() {
+	now := time.Now()
+
+	m.mu.Lock()
+	var stalled, recovered []string
+	for name, hb := range m.heartbeats {
+		isStalled := now.Sub(hb.last) > hb.maxAge
+		if isStalled {
+			stalled = append(stalled, name)
+		} else if m.stalled[name] {
+			recovered = append(recovered, name)
+		}
+		m.stalled[name] = isStalled
+	}
+	m.mu.Unlock()
+
+	for _, name := range stalled {
+		m.alerter.Alert(&Alert{
+			Key:      "stalled:" + name,
+			Severity: SeverityCritical,
+			Title:    "Background job stalled",
+			Message:  fmt.Sprintf("%s has not completed a run within its expected interval", name),
+		})
+	}
+	for _, name := range recovered {
+		m.logger.Infof("Background job %s recovered", name)
+		m.alerter.Resolve("stalled:" + name)
+	}
+}
@@ -0,0 +1,152 @@
+package aml
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// Report formats
+const (
+	FormatCSV = "csv"
+	FormatXML = "xml"
+)
+
+// Control kinds of the reported operations: those at or above the threshold are
+// reported under mandatory control, the others as suspicious
+const (
+	controlMandatory  = "mandatory"
+	controlSuspicious = "suspicious"
+)
+
+func controlKind(rule string) string {
+	if rule == models.AMLRuleLargeTransaction {
+		return controlMandatory
+	}
+	return controlSuspicious
+}
+
+// RenderCSV renders the report as CSV with a header row, one row per reported flag
+func RenderCSV(report *models.AMLReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{
+		"flag_id", "control", "rule", "transaction_id", "transaction_date", "transaction_type",
+		"amount", "currency", "amount_rub", "account_number", "counterpart_number",
+		"client_id", "client_name", "reason", "reviewer_id", "reviewed_at", "review_comment",
+	})
+	for _, item := range report.Items {
+		flag := item.Flag
+		w.Write([]string{
+			fmt.Sprint(flag.ID),
+			controlKind(flag.Rule),
+			flag.Rule,
+			fmt.Sprint(flag.TransactionID),
+			item.TransactionDate.UTC().Format(time.RFC3339),
+			item.TransactionType,
+			fmt.Sprintf("%.2f", flag.Amount),
+			flag.Currency,
+			fmt.Sprintf("%.2f", flag.AmountRUB),
+			item.AccountNumber,
+			item.CounterpartNumber,
+			fmt.Sprint(flag.UserID),
+			item.ClientName,
+			flag.Reason,
+			reviewer(flag),
+			reviewedAt(flag),
+			flag.ReviewComment,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type xmlReport struct {
+	XMLName     xml.Name       `xml:"AMLReport"`
+	Period      string         `xml:"period,attr"`
+	BankBIC     string         `xml:"bic,attr"`
+	GeneratedAt string         `xml:"generated,attr"`
+	Operations  []xmlOperation `xml:"Operation"`
+}
+
+type xmlOperation struct {
+	FlagID        int64     `xml:"id,attr"`
+	Control       string    `xml:"control,attr"`
+	Rule          string    `xml:"Rule"`
+	Reason        string    `xml:"Reason"`
+	TransactionID int64     `xml:"Transaction>ID"`
+	Date          string    `xml:"Transaction>Date"`
+	Type          string    `xml:"Transaction>Type"`
+	Amount        xmlAmount `xml:"Transaction>Amount"`
+	AmountRUB     string    `xml:"Transaction>AmountRUB"`
+	Account       string    `xml:"Transaction>Account"`
+	Counterpart   string    `xml:"Transaction>Counterpart,omitempty"`
+	ClientID      int64     `xml:"Client>ID"`
+	ClientName    string    `xml:"Client>Name"`
+	ReviewerID    string    `xml:"Review>ReviewerID"`
+	ReviewedAt    string    `xml:"Review>Date"`
+	Comment       string    `xml:"Review>Comment"`
+}
+
+type xmlAmount struct {
+	Currency string `xml:"currency,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// RenderXML renders the report as XML, one Operation element per reported flag
+func RenderXML(report *models.AMLReport) ([]byte, error) {
+	doc := xmlReport{
+		Period:      report.Period,
+		BankBIC:     report.BankBIC,
+		GeneratedAt: report.GeneratedAt.UTC().Format(time.RFC3339),
+		Operations:  make([]xmlOperation, 0, len(report.Items)),
+	}
+	for _, item := range report.Items {
+		flag := item.Flag
+		doc.Operations = append(doc.Operations, xmlOperation{
+			FlagID:        flag.ID,
+			Control:       controlKind(flag.Rule),
+			Rule:          flag.Rule,
+			Reason:        flag.Reason,
+			TransactionID: flag.TransactionID,
+			Date:          item.TransactionDate.UTC().Format(time.RFC3339),
+			Type:          item.TransactionType,
+			Amount:        xmlAmount{Currency: flag.Currency, Value: fmt.Sprintf("%.2f", flag.Amount)},
+			AmountRUB:     fmt.Sprintf("%.2f", flag.AmountRUB),
+			Account:       item.AccountNumber,
+			Counterpart:   item.CounterpartNumber,
+			ClientID:      flag.UserID,
+			ClientName:    item.ClientName,
+			ReviewerID:    reviewer(flag),
+			ReviewedAt:    reviewedAt(flag),
+			Comment:       flag.ReviewComment,
+		})
+	}
+
+	payload, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), payload...), nil
+}
+
+func reviewer(flag *models.AMLFlag) string {
+	if flag.ReviewerID == nil {
+		return ""
+	}
+	return fmt.Sprint(*flag.ReviewerID)
+}
+
+func reviewedAt(flag *models.AMLFlag) string {
+	if flag.ReviewedAt == nil {
+		return ""
+	}
+	return flag.ReviewedAt.UTC().Format(time.RFC3339)
+}
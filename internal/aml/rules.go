@@ -0,0 +1,88 @@
+package aml
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// unmonitoredTypes are the transactions the bank makes itself rather than on
+// the instruction of a client
+var unmonitoredTypes = map[string]bool{
+	models.TransactionTypeOverdraftInterest:  true,
+	models.TransactionTypeCreditDisbursement: true,
+	models.TransactionTypeDepositReturn:      true,
+	models.TransactionTypeDepositInterest:    true,
+	models.TransactionTypeDormancyFee:        true,
+}
+
+// rule checks a posted transaction for one pattern. The check returns the reason
+// the transaction is flagged, or an empty string when it is not.
+type rule struct {
+	name  string
+	check func(ctx context.Context, s *scan) (string, error)
+}
+
+var rules = []rule{
+	{models.AMLRuleLargeTransaction, checkLargeTransaction},
+	{models.AMLRuleStructuring, checkStructuring},
+	{models.AMLRuleRapidMovement, checkRapidMovement},
+}
+
+// scan carries a transaction through the rules with its amount in roubles and
+// the rate it was converted at
+type scan struct {
+	t         *models.AMLTransaction
+	amountRUB float64
+	rate      float64 // roubles per unit of the transaction currency
+	repo      *repository.AMLRepository
+	cfg       *config.AMLConfig
+}
+
+// checkLargeTransaction flags transactions at or above the mandatory control threshold
+func checkLargeTransaction(_ context.Context, s *scan) (string, error) {
+	if s.amountRUB < s.cfg.Threshold {
+		return "", nil
+	}
+	return fmt.Sprintf("amount of %.2f RUB is at or above the threshold of %.2f RUB", s.amountRUB, s.cfg.Threshold), nil
+}
+
+// checkStructuring flags an amount just below the threshold when the user has
+// made several such transactions within the window, as if splitting a large one
+func checkStructuring(ctx context.Context, s *scan) (string, error) {
+	floor := s.cfg.Threshold * s.cfg.StructuringShare
+	if s.cfg.StructuringCount <= 0 || s.amountRUB < floor || s.amountRUB >= s.cfg.Threshold {
+		return "", nil
+	}
+
+	count, err := s.repo.CountSimilar(ctx, s.t.UserID, s.t.Currency, floor/s.rate, s.cfg.Threshold/s.rate,
+		s.t.CreatedAt.Add(-s.cfg.StructuringWindow), s.t.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+	if count < s.cfg.StructuringCount {
+		return "", nil
+	}
+	return fmt.Sprintf("%d transactions from %.2f RUB up to the threshold within %s", count, floor, s.cfg.StructuringWindow), nil
+}
+
+// checkRapidMovement flags money leaving an account shortly after most of it
+// arrived, as when an account is used for transit
+func checkRapidMovement(ctx context.Context, s *scan) (string, error) {
+	if !s.t.Outgoing || s.cfg.RapidMovementShare <= 0 || s.amountRUB < s.cfg.RapidMovementMinAmount {
+		return "", nil
+	}
+
+	incoming, err := s.repo.IncomingTotal(ctx, s.t.AccountID, s.t.CreatedAt.Add(-s.cfg.RapidMovementWindow), s.t.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+	if incoming < s.t.Amount*s.cfg.RapidMovementShare {
+		return "", nil
+	}
+	return fmt.Sprintf("%.2f %s arrived at the account within %s before %.2f %s left it",
+		incoming, s.t.Currency, s.cfg.RapidMovementWindow, s.t.Amount, s.t.Currency), nil
+}
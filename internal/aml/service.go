@@ -0,0 +1,294 @@
+// Package aml monitors posted transactions for money laundering: transactions
+// above the mandatory control threshold and suspicious patterns are flagged,
+// reviewed by compliance staff and reported to the regulator every month.
+package aml
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	auditEntityAMLFlag   = "aml_flag"
+	auditEntityAMLPeriod = "aml_period"
+
+	// scanBatchSize is the number of transactions scanned and saved at a time
+	scanBatchSize = 500
+	// scanSettleTime keeps the scan off the transactions posted in the last
+	// moments, so one committed late with a lower ID is not skipped
+	scanSettleTime = time.Minute
+)
+
+// Service scans the posted transactions with the AML rules and manages the
+// review of the flags and the regulator reports
+type Service struct {
+	cfg         *config.AMLConfig
+	repo        *repository.AMLRepository
+	userRepo    repository.UserStore
+	auditRepo   *repository.AuditRepository
+	rateService *service.RateService
+	bankBIC     string
+	logger      *logrus.Logger
+}
+
+// NewService creates a new AML Service; the BIC identifies the bank in the reports
+func NewService(
+	cfg *config.AMLConfig,
+	repo *repository.AMLRepository,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	rateService *service.RateService,
+	bankBIC string,
+	logger *logrus.Logger,
+) *Service {
+	return &Service{
+		cfg:         cfg,
+		repo:        repo,
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		rateService: rateService,
+		bankBIC:     bankBIC,
+		logger:      logger,
+	}
+}
+
+// Scan runs the rules over the transactions posted since the previous scan and
+// returns how many flags it raised. A scan stopped by an error resumes with
+// the batch it failed on.
+func (s *Service) Scan(ctx context.Context, now time.Time) (int, error) {
+	lastID, err := s.repo.LastScanned(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	flagged := 0
+	for {
+		transactions, err := s.repo.ListTransactionsAfter(ctx, lastID, now.Add(-scanSettleTime), scanBatchSize)
+		if err != nil || len(transactions) == 0 {
+			return flagged, err
+		}
+
+		var flags []*models.AMLFlag
+		for _, t := range transactions {
+			hits, err := s.evaluate(ctx, t)
+			if err != nil {
+				return flagged, fmt.Errorf("transaction %d: %w", t.ID, err)
+			}
+			flags = append(flags, hits...)
+		}
+
+		lastID = transactions[len(transactions)-1].ID
+		if err := s.repo.SaveScan(ctx, flags, lastID); err != nil {
+			return flagged, err
+		}
+		flagged += len(flags)
+		for _, flag := range flags {
+			s.logger.WithFields(logrus.Fields{
+				"transaction_id": flag.TransactionID,
+				"user_id":        flag.UserID,
+				"rule":           flag.Rule,
+			}).Warn("Transaction flagged by AML rules")
+		}
+
+		if len(transactions) < scanBatchSize {
+			return flagged, nil
+		}
+	}
+}
+
+// evaluate runs every rule over a transaction and returns a flag per rule it matched
+func (s *Service) evaluate(ctx context.Context, t *models.AMLTransaction) ([]*models.AMLFlag, error) {
+	if unmonitoredTypes[t.Type] {
+		return nil, nil
+	}
+
+	rate, err := s.rateService.CrossRate(t.Currency, models.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	sc := &scan{t: t, amountRUB: t.Amount * rate, rate: rate, repo: s.repo, cfg: s.cfg}
+
+	var flags []*models.AMLFlag
+	for _, r := range rules {
+		reason, err := r.check(ctx, sc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.name, err)
+		}
+		if reason == "" {
+			continue
+		}
+		flags = append(flags, &models.AMLFlag{
+			TransactionID: t.ID,
+			UserID:        t.UserID,
+			AccountID:     t.AccountID,
+			Rule:          r.name,
+			Reason:        reason,
+			Amount:        t.Amount,
+			Currency:      t.Currency,
+			AmountRUB:     sc.amountRUB,
+			Status:        models.AMLFlagStatusOpen,
+		})
+	}
+	return flags, nil
+}
+
+// ListFlags returns a page of the flags, optionally of one rule, and how many there are
+func (s *Service) ListFlags(ctx context.Context, rule string, p pagination.Params) ([]*models.AMLFlag, int, error) {
+	flags, total, err := s.repo.List(ctx, rule, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return flags, total, nil
+}
+
+// GetFlag returns a flag
+func (s *Service) GetFlag(ctx context.Context, id int64) (*models.AMLFlag, error) {
+	flag, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "aml flag not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return flag, nil
+}
+
+// TakeFlag puts an open flag under the review of the reviewer. Nobody reviews
+// the flags of their own transactions.
+func (s *Service) TakeFlag(ctx context.Context, reviewerID, id int64) (*models.AMLFlag, error) {
+	flag, err := s.GetFlag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if flag.Status != models.AMLFlagStatusOpen {
+		return nil, errors.New("aml flag is not open")
+	}
+	if flag.UserID == reviewerID {
+		return nil, errors.New("aml flag must be reviewed by someone else")
+	}
+
+	flag.Status = models.AMLFlagStatusUnderReview
+	flag.ReviewerID = &reviewerID
+	if err := s.updateReview(ctx, flag, models.AMLFlagStatusOpen); err != nil {
+		return nil, err
+	}
+	return flag, nil
+}
+
+// ReportFlag confirms a flag under the review of the reviewer, which puts it in
+// the regulator report of the month
+func (s *Service) ReportFlag(ctx context.Context, reviewerID, id int64, comment string) (*models.AMLFlag, error) {
+	return s.closeFlag(ctx, reviewerID, id, comment, models.AMLFlagStatusReported, models.AuditActionAMLFlagReported)
+}
+
+// DismissFlag closes a flag under the review of the reviewer as not suspicious
+func (s *Service) DismissFlag(ctx context.Context, reviewerID, id int64, comment string) (*models.AMLFlag, error) {
+	return s.closeFlag(ctx, reviewerID, id, comment, models.AMLFlagStatusDismissed, models.AuditActionAMLFlagDismissed)
+}
+
+func (s *Service) closeFlag(ctx context.Context, reviewerID, id int64, comment string, status models.AMLFlagStatus, action models.AuditAction) (*models.AMLFlag, error) {
+	flag, err := s.GetFlag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if flag.Status != models.AMLFlagStatusUnderReview {
+		return nil, errors.New("aml flag is not under review")
+	}
+	if flag.ReviewerID == nil || *flag.ReviewerID != reviewerID {
+		return nil, errors.New("aml flag is under review by someone else")
+	}
+
+	now := time.Now().UTC()
+	flag.Status = status
+	flag.ReviewComment = comment
+	flag.ReviewedAt = &now
+	if err := s.updateReview(ctx, flag, models.AMLFlagStatusUnderReview); err != nil {
+		return nil, err
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"rule": flag.Rule, "comment": comment})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    reviewerID,
+		Action:     action,
+		EntityType: auditEntityAMLFlag,
+		EntityID:   flag.ID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit %s of AML flag %d", action, flag.ID)
+	}
+	return flag, nil
+}
+
+func (s *Service) updateReview(ctx context.Context, flag *models.AMLFlag, previous models.AMLFlagStatus) error {
+	updated, err := s.repo.UpdateReview(ctx, flag, previous)
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	if !updated {
+		return errors.New("aml flag was changed by someone else")
+	}
+	return nil
+}
+
+// Report lists the flags reported within a month, given as YYYY-MM, with their
+// clients for the regulator. Generating a report is audited.
+func (s *Service) Report(ctx context.Context, actorID int64, period string) (*models.AMLReport, error) {
+	from, err := time.Parse("2006-01", period)
+	if err != nil {
+		return nil, errors.New("period must be given as YYYY-MM")
+	}
+	now := time.Now().UTC()
+	if from.After(now) {
+		return nil, errors.New("period is in the future")
+	}
+
+	report := &models.AMLReport{
+		Period:      period,
+		From:        from,
+		To:          from.AddDate(0, 1, 0),
+		BankBIC:     s.bankBIC,
+		GeneratedAt: now,
+	}
+	report.Items, err = s.repo.ListReported(ctx, report.From, report.To)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	names := make(map[int64]string)
+	for _, item := range report.Items {
+		name, ok := names[item.Flag.UserID]
+		if !ok {
+			user, err := s.userRepo.GetByID(ctx, item.Flag.UserID)
+			if err != nil {
+				s.logger.WithError(err).Errorf("Failed to get client %d of AML flag %d", item.Flag.UserID, item.Flag.ID)
+				return nil, errors.New("internal server error")
+			}
+			name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			names[item.Flag.UserID] = name
+		}
+		item.ClientName = name
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"period": period, "items": len(report.Items)})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    actorID,
+		Action:     models.AuditActionAMLReportGenerated,
+		EntityType: auditEntityAMLPeriod,
+		EntityID:   int64(from.Year()*100 + int(from.Month())),
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit AML report")
+	}
+	return report, nil
+}
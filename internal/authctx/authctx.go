@@ -0,0 +1,56 @@
+// Package authctx carries the authenticated user and the request ID in a
+// request context. The keys are unexported, so values can only be stored and
+// read through this package and a writer and a reader cannot disagree on a key.
+package authctx
+
+import (
+	"context"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	roleKey
+	sessionIDKey
+	requestIDKey
+)
+
+// WithUser stores the authenticated user ID, role and the token ID of the
+// session in the context
+func WithUser(ctx context.Context, userID int64, role models.UserRole, sessionID string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	ctx = context.WithValue(ctx, roleKey, role)
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// UserIDFromContext retrieves the authenticated user ID
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey).(int64)
+	return userID, ok
+}
+
+// RoleFromContext retrieves the authenticated user's role
+func RoleFromContext(ctx context.Context) (models.UserRole, bool) {
+	role, ok := ctx.Value(roleKey).(models.UserRole)
+	return role, ok
+}
+
+// SessionIDFromContext retrieves the token ID of the authenticated session
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey).(string)
+	return sessionID, ok
+}
+
+// WithRequestID stores the ID of the request in the context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext retrieves the ID of the request
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok
+}
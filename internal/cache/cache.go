@@ -0,0 +1,164 @@
+// Package cache keeps the results of hot reads in Redis so that repeated
+// lookups spare the database. The cache is never the source of truth: a failed
+// cache call is treated as a miss and the value is read from the database.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Cache stores values by key for a limited time. Values are gob-encoded, so
+// every exported field survives the round trip, including those left out of
+// the JSON of the API.
+type Cache interface {
+	// Get decodes the value stored under key into dest and tells whether it was found
+	Get(ctx context.Context, key string, dest interface{}) bool
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration)
+	// Delete removes the values stored under the keys
+	Delete(ctx context.Context, keys ...string)
+	// Close releases the connections of the cache
+	Close() error
+}
+
+// New creates the cache described by cfg, or a Nop cache when caching is disabled
+func New(cfg *config.CacheConfig, logger *logrus.Logger) Cache {
+	if !cfg.Enabled {
+		return Nop{}
+	}
+
+	c := &Redis{
+		client:  NewRedisClient(cfg),
+		prefix:  cfg.KeyPrefix,
+		timeout: cfg.Timeout,
+		logger:  logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		logger.WithError(err).Warn("Redis is unavailable, reads go to the database until it is back")
+	}
+	return c
+}
+
+// NewRedisClient connects to the Redis given by cfg, which other features such
+// as the rate limiter share with the cache
+func NewRedisClient(cfg *config.CacheConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+}
+
+// Nop is a disabled cache: nothing is stored and every lookup misses
+type Nop struct{}
+
+// Get always misses
+func (Nop) Get(context.Context, string, interface{}) bool { return false }
+
+// Set does nothing
+func (Nop) Set(context.Context, string, interface{}, time.Duration) {}
+
+// Delete does nothing
+func (Nop) Delete(context.Context, ...string) {}
+
+// Close does nothing
+func (Nop) Close() error { return nil }
+
+// Redis is a cache kept in Redis and shared by all instances of the service
+type Redis struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+	logger  *logrus.Logger
+}
+
+// Get decodes the value stored under key into dest and tells whether it was found
+func (c *Redis) Get(ctx context.Context, key string, dest interface{}) bool {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		metrics.CacheRequests.Inc(name(key), "get", "miss")
+		return false
+	}
+	if err == nil {
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(dest)
+	}
+	if err != nil {
+		metrics.CacheRequests.Inc(name(key), "get", metrics.OutcomeError)
+		c.logger.WithError(err).Debugf("Failed to read %s from the cache", key)
+		return false
+	}
+
+	metrics.CacheRequests.Inc(name(key), "get", "hit")
+	return true
+}
+
+// Set stores value under key for ttl. Nothing is stored without a positive
+// ttl, which would keep the value forever.
+func (c *Redis) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	if err == nil {
+		err = c.client.Set(ctx, c.prefix+key, buf.Bytes(), ttl).Err()
+	}
+	if err != nil {
+		metrics.CacheRequests.Inc(name(key), "set", metrics.OutcomeError)
+		c.logger.WithError(err).Debugf("Failed to write %s to the cache", key)
+		return
+	}
+	metrics.CacheRequests.Inc(name(key), "set", metrics.OutcomeSuccess)
+}
+
+// Delete removes the values stored under the keys. A value that cannot be
+// removed is served until it expires, so the failure is logged as a warning.
+func (c *Redis) Delete(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	if err := c.client.Del(ctx, prefixed...).Err(); err != nil {
+		metrics.CacheRequests.Inc(name(keys[0]), "delete", metrics.OutcomeError)
+		c.logger.WithError(err).Warnf("Failed to invalidate %s in the cache", strings.Join(keys, ", "))
+		return
+	}
+	metrics.CacheRequests.Inc(name(keys[0]), "delete", metrics.OutcomeSuccess)
+}
+
+// Close closes the connections to Redis
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
+
+// name returns the kind of the cached value, the part of its key before the
+// first colon, such as "account" for "account:42"
+func name(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
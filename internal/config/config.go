@@ -11,25 +11,92 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server     ServerConfig     `json:"server"`
-	Database   DatabaseConfig   `json:"database"`
-	JWT        JWTConfig        `json:"jwt"`
-	SMTP       SMTPConfig       `json:"smtp"`
-	CBR        CBRConfig        `json:"cbr"`
-	Encryption EncryptionConfig `json:"encryption"`
-	RateLimit  RateLimitConfig  `json:"rate_limit"`
-	API        APIConfig        `json:"api"`
-	Log        LogConfig        `json:"log"`
-	App        AppConfig        `json:"app"`
+	Server            ServerConfig            `json:"server"`
+	Database          DatabaseConfig          `json:"database"`
+	JWT               JWTConfig               `json:"jwt"`
+	SMTP              SMTPConfig              `json:"smtp"`
+	SMS               SMSConfig               `json:"sms"`
+	CBR               CBRConfig               `json:"cbr"`
+	Encryption        EncryptionConfig        `json:"encryption"`
+	RateLimit         RateLimitConfig         `json:"rate_limit"`
+	API               APIConfig               `json:"api"`
+	Log               LogConfig               `json:"log"`
+	App               AppConfig               `json:"app"`
+	Alerting          AlertingConfig          `json:"alerting"`
+	Bank              BankConfig              `json:"bank"`
+	Card              CardConfig              `json:"card"`
+	Jobs              JobsConfig              `json:"jobs"`
+	Outbox            OutboxConfig            `json:"outbox"`
+	Events            EventsConfig            `json:"events"`
+	Stream            StreamConfig            `json:"stream"`
+	GRPC              GRPCConfig              `json:"grpc"`
+	Scheduler         SchedulerConfig         `json:"scheduler"`
+	Credit            CreditConfig            `json:"credit"`
+	VariableRates     VariableRatesConfig     `json:"variable_rates"`
+	Collections       CollectionsConfig       `json:"collections"`
+	Deposits          DepositsConfig          `json:"deposits"`
+	Dormancy          DormancyConfig          `json:"dormancy"`
+	Alerts            AlertsConfig            `json:"alerts"`
+	Budgets           BudgetsConfig           `json:"budgets"`
+	Parameters        ParametersConfig        `json:"parameters"`
+	Replay            ReplayConfig            `json:"replay"`
+	Fraud             FraudConfig             `json:"fraud"`
+	Limits            LimitsConfig            `json:"limits"`
+	Statements        StatementsConfig        `json:"statements"`
+	CardExpiry        CardExpiryConfig        `json:"card_expiry"`
+	ExternalTransfers ExternalTransfersConfig `json:"external_transfers"`
+	IncomingPayments  IncomingPaymentsConfig  `json:"incoming_payments"`
+	Acquiring         AcquiringConfig         `json:"acquiring"`
+	Reconciliation    ReconciliationConfig    `json:"reconciliation"`
+	UserErasure       UserErasureConfig       `json:"user_erasure"`
+	Login             LoginConfig             `json:"login"`
+	PasswordPolicy    PasswordPolicyConfig    `json:"password_policy"`
+	Cache             CacheConfig             `json:"cache"`
+	APIKeys           APIKeysConfig           `json:"api_keys"`
+	Secrets           SecretsConfig           `json:"secrets"`
+	Branches          BranchesConfig          `json:"branches"`
+	Accounting        AccountingConfig        `json:"accounting"`
+	AML               AMLConfig               `json:"aml"`
+	Storage           StorageConfig           `json:"storage"`
+	KYC               KYCConfig               `json:"kyc"`
+	Receipts          ReceiptConfig           `json:"receipts"`
+	Disputes          DisputesConfig          `json:"disputes"`
+	Collateral        CollateralConfig        `json:"collateral"`
+	Payees            PayeesConfig            `json:"payees"`
+	PaymentRequests   PaymentRequestsConfig   `json:"payment_requests"`
+	TransferBatches   TransferBatchesConfig   `json:"transfer_batches"`
+	Billers           BillersConfig           `json:"billers"`
+	Sandbox           SandboxConfig           `json:"sandbox"`
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
-	Host         string        `json:"host"`
+	Host         string        `json:"host"` // interface to listen on, all when empty
 	Port         int           `json:"port"`
 	ReadTimeout  time.Duration `json:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout"`
 	IdleTimeout  time.Duration `json:"idle_timeout"`
+	// ShutdownTimeout bounds the graceful shutdown: in-flight requests and the
+	// work in progress of the background workers are cut short once it passes
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	// TLS termination in the server. Without a certificate and key the server
+	// speaks plain HTTP and is expected to sit behind a terminating proxy.
+	TLSCertFile   string `json:"tls_cert_file"`
+	TLSKeyFile    string `json:"tls_key_file"`
+	TLSMinVersion string `json:"tls_min_version"` // "1.2" or "1.3"
+	// TLSClientCAFile holds the CAs of the client certificates that admin and
+	// internal routes require; other routes accept clients without one
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+}
+
+// TLSEnabled tells whether the server terminates TLS itself
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// MutualTLSEnabled tells whether admin and internal routes require a client certificate
+func (c *ServerConfig) MutualTLSEnabled() bool {
+	return c.TLSEnabled() && c.TLSClientCAFile != ""
 }
 
 // DatabaseConfig represents database configuration
@@ -40,6 +107,16 @@ type DatabaseConfig struct {
 	Password string `json:"password"`
 	DBName   string `json:"dbname"`
 	SSLMode  string `json:"sslmode"`
+	// Driver is the database/sql driver, "postgres" (lib/pq) or "pgx"
+	Driver string `json:"driver"`
+	// Connection pool limits. Zero open connections, lifetime or idle time
+	// means unlimited; zero idle connections closes connections after use.
+	MaxOpenConns    int           `json:"max_open_conns"`
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+	// AutoMigrate applies pending migrations when the server starts
+	AutoMigrate bool `json:"auto_migrate"`
 }
 
 // JWTConfig represents JWT configuration
@@ -60,6 +137,17 @@ type SMTPConfig struct {
 	TLS      bool   `json:"tls"`
 }
 
+// SMSConfig represents SMS gateway configuration
+type SMSConfig struct {
+	Provider         string        `json:"provider"` // twilio or smsc; empty disables SMS
+	From             string        `json:"from"`     // sender number, Twilio messaging service SID or SMSC sender name
+	Timeout          time.Duration `json:"timeout"`
+	TwilioAccountSID string        `json:"twilio_account_sid"`
+	TwilioAuthToken  string        `json:"twilio_auth_token"`
+	SMSCLogin        string        `json:"smsc_login"`
+	SMSCPassword     string        `json:"smsc_password"`
+}
+
 // CBRConfig represents Central Bank of Russia API configuration
 type CBRConfig struct {
 	BaseURL      string        `json:"base_url"`
@@ -67,35 +155,66 @@ type CBRConfig struct {
 	RetryCount   int           `json:"retry_count"`
 	RetryDelay   time.Duration `json:"retry_delay"`
 	RateEndpoint string        `json:"rate_endpoint"`
+	CacheTTL     time.Duration `json:"cache_ttl"`
 }
 
 // EncryptionConfig represents encryption configuration
 type EncryptionConfig struct {
 	CardDataKey     string `json:"card_data_key"`
-	HMACSecret      string `json:"hmac_secret"`
+	PIIMasterKey    string `json:"pii_master_key"` // 32 bytes in base64 wrapping the keys of users' personal data; stored in plain text when empty
+	HMACSecret      string `json:"hmac_secret"`    // also keys the blind indexes of encrypted emails and phone numbers
 	PGPPrivateKey   string `json:"pgp_private_key"`
 	PGPPublicKey    string `json:"pgp_public_key"`
-	KeyRotationDays int    `json:"key_rotation_days"`
+	KeyRotationDays int    `json:"key_rotation_days"` // age at which rotate-keys replaces the data key
 }
 
-// RateLimitConfig represents rate limiting configuration
+// RateLimitConfig represents rate limiting configuration. Every client has a
+// token bucket per policy: it holds BurstSize requests and refills at
+// RequestsPerHour. Clients are told apart by the user of their token, or by
+// their address without one.
 type RateLimitConfig struct {
-	Enabled         bool          `json:"enabled"`
-	RequestsPerHour int           `json:"requests_per_hour"`
-	BurstSize       int           `json:"burst_size"`
-	ExpiryTime      time.Duration `json:"expiry_time"`
+	Enabled         bool                       `json:"enabled"`
+	RequestsPerHour int                        `json:"requests_per_hour"`
+	BurstSize       int                        `json:"burst_size"`
+	ExpiryTime      time.Duration              `json:"expiry_time"` // idle buckets are dropped after this time
+	Backend         string                     `json:"backend"`     // "memory", or "redis" to share the buckets between instances through the cache Redis
+	Policies        map[string]RateLimitPolicy `json:"policies"`    // per endpoint, keyed by method and route template, e.g. "POST /api/v1/public/login"
+}
+
+// RateLimitPolicy is the token bucket of an endpoint, used instead of the
+// default one of RateLimitConfig
+type RateLimitPolicy struct {
+	RequestsPerHour int `json:"requests_per_hour"`
+	BurstSize       int `json:"burst_size"`
 }
 
+// Rate limiter backends
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
 // APIConfig represents API configuration
 type APIConfig struct {
 	Version            string   `json:"version"`
 	Prefix             string   `json:"prefix"`
 	CORSAllowedOrigins []string `json:"cors_allowed_origins"`
+	MaxBodySize        int      `json:"max_body_size"` // largest request body accepted, in bytes
+	// SecurityHeaders sets X-Content-Type-Options, X-Frame-Options, Referrer-Policy
+	// and Content-Security-Policy on every response, and HSTS for a positive max age
+	SecurityHeaders bool          `json:"security_headers"`
+	HSTSMaxAge      time.Duration `json:"hsts_max_age"`
+	// Double-submit CSRF protection of cookie-based sessions. It stays off
+	// while CSRFSessionCookie is empty, as tokens are only taken from headers.
+	CSRFSessionCookie string `json:"csrf_session_cookie"`
+	CSRFTokenCookie   string `json:"csrf_token_cookie"`
+	CSRFTokenHeader   string `json:"csrf_token_header"`
 }
 
 // LogConfig represents logging configuration
 type LogConfig struct {
-	Level string `json:"level"`
+	Level  string `json:"level"`
+	Format string `json:"format"` // "text", or "json" for one JSON object per entry
 }
 
 // AppConfig represents application configuration
@@ -103,6 +222,444 @@ type AppConfig struct {
 	Port string `json:"port"`
 }
 
+// BankConfig identifies the bank in the account numbers it issues
+type BankConfig struct {
+	BIC                  string `json:"bic"`                   // 9-digit bank identification code, keys the check digit
+	BranchCode           string `json:"branch_code"`           // 4-digit code of the branch that holds the accounts
+	BalanceAccount       string `json:"balance_account"`       // 5-digit balance sheet account, 40817 for personal accounts
+	Name                 string `json:"name"`                  // legal name printed on receipts
+	TaxID                string `json:"tax_id"`                // taxpayer number (INN), printed on receipts when set
+	CorrespondentAccount string `json:"correspondent_account"` // at the Bank of Russia, printed on receipts when set
+}
+
+// CardConfig represents card processing configuration. Its values are the
+// defaults of business parameters that have no version configured.
+type CardConfig struct {
+	FXMarkupPercent float64 `json:"fx_markup_percent"` // card scheme markup on foreign currency payments
+}
+
+// CreditConfig represents credit product configuration. Its values are the
+// defaults of business parameters that have no version configured.
+type CreditConfig struct {
+	InterestRatePercent       float64 `json:"interest_rate_percent"`        // annual rate of new credits
+	EarlyRepaymentFeePercent  float64 `json:"early_repayment_fee_percent"`  // charged on the principal repaid early
+	PenaltyRatePercent        float64 `json:"penalty_rate_percent"`         // annual rate accrued daily on overdue installments
+	VariableRateMarginPercent float64 `json:"variable_rate_margin_percent"` // over the key rate, for new variable-rate credits
+}
+
+// VariableRatesConfig represents configuration of the job that reprices
+// variable-rate credits when the CBR key rate changes
+type VariableRatesConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks of the key rate
+}
+
+// CollectionsConfig represents configuration of the job that moves credits
+// overdue for long to delinquent and collections
+type CollectionsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks of the overdue credits
+}
+
+// DepositsConfig represents configuration of the job that accrues the interest
+// of term deposits and pays out the deposits that matured
+type DepositsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between accruals
+}
+
+// DormancyConfig represents configuration of the job that marks accounts with
+// no activity as dormant and charges the dormant account fee
+type DormancyConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks of the accounts
+}
+
+// AlertsConfig represents configuration of the job that evaluates the alerts
+// users set on their accounts
+type AlertsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between evaluations
+}
+
+// BudgetsConfig represents configuration of the job that notifies users whose
+// spending reached a threshold of a monthly budget
+type BudgetsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks of the budgets
+}
+
+// ReplayConfig represents request replay protection configuration. Each route
+// class is either "off" or "required", when every request must be signed with
+// the signing key returned at login.
+type ReplayConfig struct {
+	MaxClockSkew time.Duration `json:"max_clock_skew"` // accepted difference between the request timestamp and server time
+	Payments     string        `json:"payments"`       // transfers, deposits, withdrawals, card purchases and credit repayments
+	Lending      string        `json:"lending"`        // credit applications and restructuring requests
+}
+
+// FraudConfig represents fraud detection configuration for transfers and
+// withdrawals. Each rule acts in one of the modes "off", "flag" (the transaction
+// goes through and is marked), "hold" (it waits for an administrator) or
+// "reject"; an unknown mode holds the transaction.
+type FraudConfig struct {
+	Enabled        bool   `json:"enabled"`
+	ClientIPHeader string `json:"client_ip_header"` // header with the client address set by a proxy, empty to use the connection address
+	CountryHeader  string `json:"country_header"`   // header with the ISO country code set by the edge, empty to skip the country rule
+
+	VelocityWindow    time.Duration `json:"velocity_window"`
+	VelocityMaxCount  int           `json:"velocity_max_count"`  // outgoing transactions allowed within the window, 0 for no limit
+	VelocityMaxAmount float64       `json:"velocity_max_amount"` // amount allowed to leave within the window, 0 for no limit
+	VelocityAction    string        `json:"velocity_action"`
+
+	HistoryLookback         time.Duration `json:"history_lookback"`           // period the usual amounts and client addresses are taken from
+	UnusualAmountMinHistory int           `json:"unusual_amount_min_history"` // transactions needed before amounts are compared
+	UnusualAmountMultiplier float64       `json:"unusual_amount_multiplier"`  // times the average amount considered unusual
+	UnusualAmountAction     string        `json:"unusual_amount_action"`
+
+	NewRecipientAmount float64 `json:"new_recipient_amount"` // transfers to a new recipient from this amount on are checked
+	NewRecipientAction string  `json:"new_recipient_action"`
+
+	NewNetworkAction string `json:"new_network_action"` // client address outside every network the user transacted from
+	NewCountryAction string `json:"new_country_action"` // client country the user never transacted from
+}
+
+// LimitsConfig represents the default cumulative limits on the transfers and
+// withdrawals of a user over a rolling day and 30 days. Administrators override
+// them per user; 0 leaves a limit unset.
+type LimitsConfig struct {
+	DailyTransfer     float64 `json:"daily_transfer"`
+	MonthlyTransfer   float64 `json:"monthly_transfer"`
+	DailyWithdrawal   float64 `json:"daily_withdrawal"`
+	MonthlyWithdrawal float64 `json:"monthly_withdrawal"`
+	// Limits of the users whose identity is not verified, on their transfers
+	// and withdrawals alike. They apply over any lower limit; 0 leaves it unset.
+	UnverifiedDaily   float64 `json:"unverified_daily"`
+	UnverifiedMonthly float64 `json:"unverified_monthly"`
+}
+
+// ParametersConfig represents business parameter configuration
+type ParametersConfig struct {
+	CacheTTL time.Duration `json:"cache_ttl"` // how long other instances may serve a parameter changed elsewhere
+}
+
+// JobsConfig represents background job runner configuration
+type JobsConfig struct {
+	PollInterval time.Duration `json:"poll_interval"`
+	LeaseTimeout time.Duration `json:"lease_timeout"` // running jobs older than this are picked up again
+	Workers      int           `json:"workers"`       // jobs run at the same time by one instance
+}
+
+// OutboxConfig represents transactional outbox relay configuration
+type OutboxConfig struct {
+	PollInterval time.Duration `json:"poll_interval"`
+	LeaseTimeout time.Duration `json:"lease_timeout"` // messages claimed longer ago than this are published again
+}
+
+// EventsConfig represents domain event publishing configuration
+type EventsConfig struct {
+	Broker       string        `json:"broker"` // none, kafka or nats
+	KafkaBrokers []string      `json:"kafka_brokers"`
+	NATSURL      string        `json:"nats_url"`
+	TopicPrefix  string        `json:"topic_prefix"` // prepended to the event type to form the topic or subject
+	WriteTimeout time.Duration `json:"write_timeout"`
+}
+
+// StreamConfig represents configuration of the server-sent event stream that
+// pushes balance changes, incoming transfers and card status changes to clients
+type StreamConfig struct {
+	Enabled               bool          `json:"enabled"`
+	HeartbeatInterval     time.Duration `json:"heartbeat_interval"`       // time between keep-alive comments, the session is checked again at each
+	MaxConnectionsPerUser int           `json:"max_connections_per_user"` // streams a user may hold open at once
+}
+
+// GRPCConfig represents configuration of the gRPC API for internal services
+type GRPCConfig struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+}
+
+// SchedulerConfig represents credit payment scheduler configuration
+type SchedulerConfig struct {
+	Enabled  bool          `json:"enabled"`  // disabled schedulers still run on a manual trigger
+	Interval time.Duration `json:"interval"` // time between runs
+}
+
+// StatementsConfig represents monthly statement email configuration
+type StatementsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks for users whose month has ended
+}
+
+// CardExpiryConfig represents configuration of the card expiry job
+type CardExpiryConfig struct {
+	Enabled    bool          `json:"enabled"`
+	Interval   time.Duration `json:"interval"`    // time between checks for expiring and expired cards
+	NoticeDays int           `json:"notice_days"` // how many days before expiry the holder is told
+}
+
+// UserErasureConfig represents configuration of the anonymization of closed users
+type UserErasureConfig struct {
+	Enabled       bool          `json:"enabled"`
+	Interval      time.Duration `json:"interval"`       // time between runs
+	RetentionDays int           `json:"retention_days"` // how long personal data is kept after closure
+}
+
+// LoginConfig represents configuration of the protection against password guessing
+type LoginConfig struct {
+	MaxFailedAttempts      int           `json:"max_failed_attempts"`        // failed logins in a row that lock the user
+	FailureWindow          time.Duration `json:"failure_window"`             // time within which failed logins are counted
+	LockoutDuration        time.Duration `json:"lockout_duration"`           // how long a locked user cannot log in
+	MaxFailedAttemptsPerIP int           `json:"max_failed_attempts_per_ip"` // failed logins from an address within the window before it is refused
+}
+
+// PasswordPolicyConfig represents the rules a new password has to follow
+type PasswordPolicyConfig struct {
+	MinLength          int           `json:"min_length"`
+	RequireUppercase   bool          `json:"require_uppercase"`
+	RequireLowercase   bool          `json:"require_lowercase"`
+	RequireDigit       bool          `json:"require_digit"`
+	RequireSymbol      bool          `json:"require_symbol"`
+	DisallowIdentity   bool          `json:"disallow_identity"`    // refuses passwords containing the username or the email
+	BreachCheck        bool          `json:"breach_check"`         // refuses passwords found in Have I Been Pwned
+	BreachCheckURL     string        `json:"breach_check_url"`     // Pwned Passwords range API
+	BreachCheckTimeout time.Duration `json:"breach_check_timeout"` // a check that times out lets the password through
+}
+
+// CacheConfig represents configuration of the Redis cache of hot reads. With
+// the cache disabled every read goes to the database.
+type CacheConfig struct {
+	Enabled       bool          `json:"enabled"`
+	RedisAddr     string        `json:"redis_addr"`
+	RedisPassword string        `json:"redis_password"`
+	RedisDB       int           `json:"redis_db"`
+	KeyPrefix     string        `json:"key_prefix"` // keeps the keys apart from other users of the Redis database
+	Timeout       time.Duration `json:"timeout"`    // a slower cache call counts as a miss
+	AccountTTL    time.Duration `json:"account_ttl"`
+	SessionTTL    time.Duration `json:"session_ttl"` // how long a revoked token may still pass on another instance if the invalidation fails
+	AnalyticsTTL  time.Duration `json:"analytics_ttl"`
+}
+
+// APIKeysConfig represents configuration of the API keys of machine-to-machine clients
+type APIKeysConfig struct {
+	RotationGracePeriod time.Duration `json:"rotation_grace_period"` // how long a rotated key keeps working
+}
+
+// BranchesConfig represents configuration of the branches of the bank
+type BranchesConfig struct {
+	DefaultCode string `json:"default_code"` // branch of the users who register without choosing one
+}
+
+// AccountingConfig maps the bank's own ledger accounts to the balance sheet
+// accounts of the accounting export. Customer accounts are exported under the
+// balance sheet account their number starts with.
+type AccountingConfig struct {
+	CashAccount             string `json:"cash_account"`
+	CardSettlementAccount   string `json:"card_settlement_account"`
+	LoansAccount            string `json:"loans_account"`
+	InterestIncomeAccount   string `json:"interest_income_account"`
+	ExternalClearingAccount string `json:"external_clearing_account"`
+	DisputesAccount         string `json:"disputes_account"`
+	BillersAccount          string `json:"billers_account"`
+	TermDepositsAccount     string `json:"term_deposits_account"`
+	InterestExpenseAccount  string `json:"interest_expense_account"`
+	FeeIncomeAccount        string `json:"fee_income_account"`
+}
+
+// AMLConfig represents configuration of the anti-money laundering monitoring of
+// posted transactions. Amounts are in roubles; transactions in other currencies
+// are converted at the official rate.
+type AMLConfig struct {
+	Enabled   bool          `json:"enabled"`
+	Interval  time.Duration `json:"interval"`  // time between scans of the new transactions
+	Threshold float64       `json:"threshold"` // mandatory control amount, transactions from it on are flagged
+
+	StructuringShare  float64       `json:"structuring_share"`  // share of the threshold from which an amount is just below it
+	StructuringCount  int           `json:"structuring_count"`  // such amounts of a user within the window that are flagged
+	StructuringWindow time.Duration `json:"structuring_window"` // period the amounts just below the threshold are counted in
+
+	RapidMovementWindow    time.Duration `json:"rapid_movement_window"`     // period before an outgoing transaction the incoming are summed over
+	RapidMovementMinAmount float64       `json:"rapid_movement_min_amount"` // outgoing transactions from this amount on are checked
+	RapidMovementShare     float64       `json:"rapid_movement_share"`      // share of the outgoing amount that arrived within the window
+}
+
+// Object storage backends
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+)
+
+// StorageConfig represents configuration of the object storage files such as
+// identity documents, statements and exported reports are kept in. Files are
+// handed out through signed URLs: presigned by S3, or served by the API itself
+// for the local backend.
+type StorageConfig struct {
+	Backend   string        `json:"backend"`    // StorageBackendLocal or StorageBackendS3
+	LocalDir  string        `json:"local_dir"`  // directory of the local backend
+	URLExpiry time.Duration `json:"url_expiry"` // how long a signed URL stays valid
+	// LocalBaseURL is the public address of the API prefix signed URLs of the
+	// local backend point at
+	LocalBaseURL string `json:"local_base_url"`
+	// LocalURLSecret signs the URLs of the local backend; without it a random
+	// key is generated, and URLs no longer work after a restart
+	LocalURLSecret string   `json:"local_url_secret"`
+	S3             S3Config `json:"s3"`
+}
+
+// S3Config represents configuration of an S3 bucket. Objects are addressed by
+// path, which S3-compatible stores support as well.
+type S3Config struct {
+	Bucket          string        `json:"bucket"`
+	Region          string        `json:"region"`
+	Endpoint        string        `json:"endpoint"` // overrides the regional endpoint, e.g. for an S3-compatible store
+	AccessKeyID     string        `json:"access_key_id"`
+	SecretAccessKey string        `json:"secret_access_key"`
+	SessionToken    string        `json:"session_token"`
+	Timeout         time.Duration `json:"timeout"`
+}
+
+// KYCConfig represents configuration of the verification of users' identity documents
+type KYCConfig struct {
+	MaxDocumentSize int64 `json:"max_document_size"` // largest document accepted, in bytes
+}
+
+// DisputesConfig represents configuration of the disputes of transactions
+type DisputesConfig struct {
+	Window            time.Duration `json:"window"`              // how long after a transaction it can be disputed
+	MaxAttachments    int           `json:"max_attachments"`     // files a dispute can be opened with
+	MaxAttachmentSize int64         `json:"max_attachment_size"` // largest file accepted, in bytes
+}
+
+// CollateralConfig represents configuration of the collateral securing credits
+type CollateralConfig struct {
+	MaxDocumentSize int64 `json:"max_document_size"` // largest document accepted, in bytes
+}
+
+// PayeesConfig represents configuration of the payees users save. The first
+// transfer to a payee is confirmed with a code sent to the user by email.
+type PayeesConfig struct {
+	CodeTTL         time.Duration `json:"code_ttl"`          // how long a confirmation code is valid
+	MaxCodeAttempts int           `json:"max_code_attempts"` // wrong codes after which a new one must be requested
+}
+
+// PaymentRequestsConfig represents configuration of the requests for money
+// users send each other and of the job that expires them
+type PaymentRequestsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks for expired requests
+	TTL      time.Duration `json:"ttl"`      // how long a request waits for the payer unless the requester sets otherwise
+	MaxTTL   time.Duration `json:"max_ttl"`  // latest expiry a requester can set
+}
+
+// TransferBatchesConfig represents configuration of the batches of transfers
+// users send from one account, such as payroll payouts
+type TransferBatchesConfig struct {
+	MaxTransfers int `json:"max_transfers"` // transfers a single batch may contain
+}
+
+// SandboxConfig represents the sandbox mode integrators develop against. The
+// CBR and SMTP clients are replaced by deterministic fakes and the test data
+// can be wiped through the API, so it must never be enabled with real data.
+type SandboxConfig struct {
+	Enabled bool    `json:"enabled"`
+	KeyRate float64 `json:"key_rate"` // key rate reported by the fake CBR client, in percent
+}
+
+// BillersConfig represents the providers that pay bills on behalf of the bank,
+// such as mobile operators and utility payment aggregators. Billers of the
+// catalog name the provider that serves them.
+type BillersConfig struct {
+	Timeout   time.Duration                   `json:"timeout"`   // bounds a payment request to a provider
+	Providers map[string]BillerProviderConfig `json:"providers"` // keyed by provider name
+}
+
+// BillerProviderConfig represents a bill payment provider and its credentials
+type BillerProviderConfig struct {
+	Type   string `json:"type"`    // http for a provider API, sandbox to confirm every payment in development
+	URL    string `json:"url"`     // base URL of the provider API
+	APIKey string `json:"api_key"` // sent as a bearer token
+	Secret string `json:"secret"`  // signs the request bodies with HMAC-SHA256 when set
+}
+
+// ReceiptConfig represents configuration of transaction receipts. Their
+// verification codes are an HMAC of the transaction details keyed with the
+// secret, so replacing it invalidates the codes of receipts already issued.
+// Without a secret no receipts are issued.
+type ReceiptConfig struct {
+	Secret    string `json:"secret"`
+	VerifyURL string `json:"verify_url"` // public verification endpoint printed on receipts
+}
+
+// SecretsConfig represents configuration of the key management systems secrets
+// are fetched from. A secret setting of the form "vault:<path>#<field>" is read
+// from a Vault KV version 2 secret and one of the form "kms:<base64 ciphertext>"
+// is decrypted with AWS KMS; other values are used as they are.
+type SecretsConfig struct {
+	CacheTTL time.Duration `json:"cache_ttl"` // fetched secrets are fetched again after this time, which picks up rotations
+	Vault    VaultConfig   `json:"vault"`
+	KMS      KMSConfig     `json:"kms"`
+}
+
+// VaultConfig represents configuration of HashiCorp Vault
+type VaultConfig struct {
+	Address   string        `json:"address"` // Vault is off when empty
+	Token     string        `json:"token"`
+	Namespace string        `json:"namespace"`
+	Mount     string        `json:"mount"` // path of the KV version 2 secrets engine
+	Timeout   time.Duration `json:"timeout"`
+}
+
+// KMSConfig represents configuration of AWS KMS
+type KMSConfig struct {
+	Region          string        `json:"region"`   // KMS is off when empty
+	Endpoint        string        `json:"endpoint"` // overrides the regional endpoint, e.g. for a VPC endpoint
+	AccessKeyID     string        `json:"access_key_id"`
+	SecretAccessKey string        `json:"secret_access_key"`
+	SessionToken    string        `json:"session_token"`
+	Timeout         time.Duration `json:"timeout"`
+}
+
+// ReconciliationConfig represents configuration of the nightly reconciliation of account balances
+type ReconciliationConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // time between checks whether the run of the day is due
+	Hour     int           `json:"hour"`     // hour of the day from which the run is due
+}
+
+// ExternalTransfersConfig represents configuration of transfers to other banks
+type ExternalTransfersConfig struct {
+	Enabled         bool          `json:"enabled"`          // runs the processor that submits and settles transfers
+	Interval        time.Duration `json:"interval"`         // time between processor runs
+	SettlementDelay time.Duration `json:"settlement_delay"` // time a submitted transfer takes to settle
+}
+
+// IncomingPaymentsConfig represents configuration of the credit advices received
+// from the payment rail
+type IncomingPaymentsConfig struct {
+	Secret       string        `json:"secret"`         // shared HMAC key of the rail; the endpoint is off when empty
+	MaxClockSkew time.Duration `json:"max_clock_skew"` // accepted difference between the message timestamp and server time
+}
+
+// AcquiringConfig represents configuration of the card payment authorizations
+// requested by the acquirer
+type AcquiringConfig struct {
+	Secret           string        `json:"secret"`            // shared HMAC key of the acquirer; the endpoints are off when empty
+	MaxClockSkew     time.Duration `json:"max_clock_skew"`    // accepted difference between the message timestamp and server time
+	AuthorizationTTL time.Duration `json:"authorization_ttl"` // time an authorization holds funds waiting for capture
+}
+
+// AlertingConfig represents operational alerting configuration
+type AlertingConfig struct {
+	Enabled                   bool          `json:"enabled"`
+	EmailRecipients           []string      `json:"email_recipients"`
+	WebhookURL                string        `json:"webhook_url"`
+	WebhookType               string        `json:"webhook_type"` // slack, telegram or generic
+	TelegramChatID            string        `json:"telegram_chat_id"`
+	Cooldown                  time.Duration `json:"cooldown"`
+	CheckInterval             time.Duration `json:"check_interval"`
+	ReadinessFailureThreshold int           `json:"readiness_failure_threshold"`
+}
+
 // LoadConfig loads configuration from a JSON file
 func LoadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
@@ -124,11 +681,12 @@ func LoadConfig(path string) (*Config, error) {
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:         "localhost",
-			Port:         8080,
-			ReadTimeout:  15 * time.Second,
-			WriteTimeout: 15 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			Port:            8080,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+			TLSMinVersion:   "1.2",
 		},
 		App: AppConfig{
 			Port: "8080",
@@ -140,25 +698,303 @@ func DefaultConfig() *Config {
 			Password: "postgres",
 			DBName:   "abi_banking",
 			SSLMode:  "disable",
+			Driver:   "postgres",
+			// Stay well below the server's default max_connections of 100 so
+			// several instances and migrations can connect at once
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
 		},
 		Log: LogConfig{
-			Level: "debug",
+			Level:  "debug",
+			Format: "text",
+		},
+		SMTP: SMTPConfig{
+			Port: 587,
+			TLS:  true,
+		},
+		SMS: SMSConfig{
+			Timeout: 10 * time.Second,
 		},
 		JWT: JWTConfig{
 			ExpirationTime:   24 * time.Hour,
 			RefreshDuration:  7 * 24 * time.Hour,
 			SigningAlgorithm: "HS256",
 		},
+		Encryption: EncryptionConfig{
+			KeyRotationDays: 90,
+		},
 		RateLimit: RateLimitConfig{
 			Enabled:         true,
-			RequestsPerHour: 100,
-			BurstSize:       50,
+			RequestsPerHour: 6000,
+			BurstSize:       100,
 			ExpiryTime:      1 * time.Hour,
+			Backend:         RateLimitBackendMemory,
+			Policies: map[string]RateLimitPolicy{
+				"POST /api/v1/public/login":    {RequestsPerHour: 60, BurstSize: 10},
+				"POST /api/v1/public/register": {RequestsPerHour: 20, BurstSize: 5},
+			},
 		},
 		API: APIConfig{
 			Version:            "v1",
 			Prefix:             "/api/v1",
 			CORSAllowedOrigins: []string{"http://localhost:3000", "http://localhost:8080"},
+			MaxBodySize:        1 << 20,
+			SecurityHeaders:    true,
+			HSTSMaxAge:         365 * 24 * time.Hour,
+			CSRFTokenCookie:    "csrf_token",
+			CSRFTokenHeader:    "X-CSRF-Token",
+		},
+		CBR: CBRConfig{
+			BaseURL:      "https://www.cbr.ru",
+			RateEndpoint: "/DailyInfoWebServ/DailyInfo.asmx",
+			Timeout:      10 * time.Second,
+			RetryCount:   3,
+			RetryDelay:   time.Second,
+			CacheTTL:     time.Hour,
+		},
+		Bank: BankConfig{
+			BIC:            "044525999",
+			BranchCode:     "0000",
+			BalanceAccount: "40817",
+			Name:           "Abi Bank",
+		},
+		Card: CardConfig{
+			FXMarkupPercent: 1.5,
+		},
+		Credit: CreditConfig{
+			InterestRatePercent:       18,
+			EarlyRepaymentFeePercent:  0,
+			PenaltyRatePercent:        20,
+			VariableRateMarginPercent: 4,
+		},
+		VariableRates: VariableRatesConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+		},
+		Collections: CollectionsConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+		},
+		Deposits: DepositsConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+		},
+		Dormancy: DormancyConfig{
+			Enabled:  true,
+			Interval: 24 * time.Hour,
+		},
+		Alerts: AlertsConfig{
+			Enabled:  true,
+			Interval: time.Minute,
+		},
+		Budgets: BudgetsConfig{
+			Enabled:  true,
+			Interval: 10 * time.Minute,
+		},
+		Replay: ReplayConfig{
+			MaxClockSkew: 5 * time.Minute,
+			Payments:     "required",
+			Lending:      "required",
+		},
+		Parameters: ParametersConfig{
+			CacheTTL: time.Minute,
+		},
+		Fraud: FraudConfig{
+			Enabled:                 true,
+			VelocityWindow:          time.Hour,
+			VelocityMaxCount:        10,
+			VelocityMaxAmount:       1000000,
+			VelocityAction:          "reject",
+			HistoryLookback:         90 * 24 * time.Hour,
+			UnusualAmountMinHistory: 5,
+			UnusualAmountMultiplier: 5,
+			UnusualAmountAction:     "flag",
+			NewRecipientAmount:      100000,
+			NewRecipientAction:      "hold",
+			NewNetworkAction:        "flag",
+			NewCountryAction:        "hold",
+		},
+		Limits: LimitsConfig{
+			DailyTransfer:     500000,
+			MonthlyTransfer:   5000000,
+			DailyWithdrawal:   100000,
+			MonthlyWithdrawal: 1000000,
+			UnverifiedDaily:   15000,
+			UnverifiedMonthly: 40000,
+		},
+		Jobs: JobsConfig{
+			PollInterval: 5 * time.Second,
+			LeaseTimeout: 15 * time.Minute,
+			Workers:      4,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: 2 * time.Second,
+			LeaseTimeout: 5 * time.Minute,
+		},
+		Events: EventsConfig{
+			Broker:       "none",
+			TopicPrefix:  "abi_banking.",
+			WriteTimeout: 10 * time.Second,
+		},
+		Stream: StreamConfig{
+			Enabled:               true,
+			HeartbeatInterval:     15 * time.Second,
+			MaxConnectionsPerUser: 5,
+		},
+		GRPC: GRPCConfig{
+			Enabled: true,
+			Port:    9090,
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:  true,
+			Interval: 12 * time.Hour,
+		},
+		Statements: StatementsConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+		},
+		CardExpiry: CardExpiryConfig{
+			Enabled:    true,
+			Interval:   time.Hour,
+			NoticeDays: 30,
+		},
+		ExternalTransfers: ExternalTransfersConfig{
+			Enabled:         true,
+			Interval:        time.Minute,
+			SettlementDelay: time.Hour,
+		},
+		IncomingPayments: IncomingPaymentsConfig{
+			MaxClockSkew: 5 * time.Minute,
+		},
+		Acquiring: AcquiringConfig{
+			MaxClockSkew:     5 * time.Minute,
+			AuthorizationTTL: 7 * 24 * time.Hour,
+		},
+		Reconciliation: ReconciliationConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+			Hour:     2,
+		},
+		UserErasure: UserErasureConfig{
+			Enabled:       true,
+			Interval:      24 * time.Hour,
+			RetentionDays: 30,
+		},
+		Login: LoginConfig{
+			MaxFailedAttempts:      5,
+			FailureWindow:          15 * time.Minute,
+			LockoutDuration:        30 * time.Minute,
+			MaxFailedAttemptsPerIP: 20,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:          8,
+			RequireUppercase:   true,
+			RequireLowercase:   true,
+			RequireDigit:       true,
+			DisallowIdentity:   true,
+			BreachCheckURL:     "https://api.pwnedpasswords.com",
+			BreachCheckTimeout: 3 * time.Second,
+		},
+		Cache: CacheConfig{
+			Enabled:      false,
+			RedisAddr:    "localhost:6379",
+			KeyPrefix:    "abi_banking:",
+			Timeout:      100 * time.Millisecond,
+			AccountTTL:   30 * time.Second,
+			SessionTTL:   time.Minute,
+			AnalyticsTTL: 5 * time.Minute,
+		},
+		Secrets: SecretsConfig{
+			CacheTTL: 5 * time.Minute,
+			Vault: VaultConfig{
+				Mount:   "secret",
+				Timeout: 10 * time.Second,
+			},
+			KMS: KMSConfig{
+				Timeout: 10 * time.Second,
+			},
+		},
+		APIKeys: APIKeysConfig{
+			RotationGracePeriod: 24 * time.Hour,
+		},
+		Branches: BranchesConfig{
+			DefaultCode: "HQ",
+		},
+		AML: AMLConfig{
+			Enabled:                true,
+			Interval:               10 * time.Minute,
+			Threshold:              1000000,
+			StructuringShare:       0.9,
+			StructuringCount:       3,
+			StructuringWindow:      7 * 24 * time.Hour,
+			RapidMovementWindow:    24 * time.Hour,
+			RapidMovementMinAmount: 600000,
+			RapidMovementShare:     0.9,
+		},
+		Accounting: AccountingConfig{
+			CashAccount:             "20202",
+			CardSettlementAccount:   "30233",
+			LoansAccount:            "45507",
+			InterestIncomeAccount:   "70601",
+			ExternalClearingAccount: "30102",
+			DisputesAccount:         "47423",
+			BillersAccount:          "30232",
+			TermDepositsAccount:     "42305",
+			InterestExpenseAccount:  "70606",
+			FeeIncomeAccount:        "70601",
+		},
+		Storage: StorageConfig{
+			Backend:      StorageBackendLocal,
+			LocalDir:     "./data/storage",
+			URLExpiry:    15 * time.Minute,
+			LocalBaseURL: "http://localhost:8080/api/v1",
+			S3: S3Config{
+				Timeout: 30 * time.Second,
+			},
+		},
+		KYC: KYCConfig{
+			MaxDocumentSize: 10 << 20,
+		},
+		Receipts: ReceiptConfig{
+			VerifyURL: "http://localhost:8080/api/v1/public/receipts/verify",
+		},
+		Disputes: DisputesConfig{
+			Window:            120 * 24 * time.Hour,
+			MaxAttachments:    5,
+			MaxAttachmentSize: 5 << 20,
+		},
+		Collateral: CollateralConfig{
+			MaxDocumentSize: 10 << 20,
+		},
+		Payees: PayeesConfig{
+			CodeTTL:         10 * time.Minute,
+			MaxCodeAttempts: 5,
+		},
+		PaymentRequests: PaymentRequestsConfig{
+			Enabled:  true,
+			Interval: 15 * time.Minute,
+			TTL:      7 * 24 * time.Hour,
+			MaxTTL:   30 * 24 * time.Hour,
+		},
+		TransferBatches: TransferBatchesConfig{
+			MaxTransfers: 500,
+		},
+		Billers: BillersConfig{
+			Timeout:   30 * time.Second,
+			Providers: map[string]BillerProviderConfig{},
+		},
+		Sandbox: SandboxConfig{
+			Enabled: false,
+			KeyRate: 16,
+		},
+		Alerting: AlertingConfig{
+			Enabled:                   false,
+			WebhookType:               "generic",
+			Cooldown:                  15 * time.Minute,
+			CheckInterval:             30 * time.Second,
+			ReadinessFailureThreshold: 3,
 		},
 	}
 }
@@ -180,6 +1016,64 @@ func getEnvList(key string, defaultValue []string) []string {
 	return strings.Split(value, ",")
 }
 
+// getEnvRateLimitPolicies reads endpoint policies given as a comma-separated
+// list of "METHOD /route/template=requests_per_hour:burst_size"
+func getEnvRateLimitPolicies(key string, defaultValue map[string]RateLimitPolicy) map[string]RateLimitPolicy {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	policies := make(map[string]RateLimitPolicy)
+	for _, entry := range strings.Split(value, ",") {
+		endpoint, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return defaultValue
+		}
+		rate, burst, ok := strings.Cut(limits, ":")
+		if !ok {
+			return defaultValue
+		}
+		requestsPerHour, err := strconv.Atoi(strings.TrimSpace(rate))
+		if err != nil {
+			return defaultValue
+		}
+		burstSize, err := strconv.Atoi(strings.TrimSpace(burst))
+		if err != nil {
+			return defaultValue
+		}
+		policies[strings.Join(strings.Fields(endpoint), " ")] = RateLimitPolicy{RequestsPerHour: requestsPerHour, BurstSize: burstSize}
+	}
+	return policies
+}
+
+// getEnvBillerProviders reads the providers given as a comma-separated list of
+// "name=type" and their credentials from BILLER_<NAME>_URL, BILLER_<NAME>_API_KEY
+// and BILLER_<NAME>_SECRET
+func getEnvBillerProviders(key string, defaultValue map[string]BillerProviderConfig) map[string]BillerProviderConfig {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	providers := make(map[string]BillerProviderConfig)
+	for _, entry := range strings.Split(value, ",") {
+		name, providerType, ok := strings.Cut(entry, "=")
+		if !ok {
+			return defaultValue
+		}
+		name = strings.TrimSpace(name)
+		prefix := "BILLER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers[name] = BillerProviderConfig{
+			Type:   strings.TrimSpace(providerType),
+			URL:    os.Getenv(prefix + "URL"),
+			APIKey: os.Getenv(prefix + "API_KEY"),
+			Secret: os.Getenv(prefix + "SECRET"),
+		}
+	}
+	return providers
+}
+
 func getEnvIntOrDefault(key string, defaultValue int) int {
 	value := os.Getenv(key)
 	if value == "" {
@@ -192,6 +1086,42 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return intValue
 }
 
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return floatValue
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return boolValue
+}
+
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return duration
+}
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
@@ -199,17 +1129,236 @@ func Load() (*Config, error) {
 	// Override with environment variables if set
 	cfg.Server.Host = getEnvOrDefault("SERVER_HOST", cfg.Server.Host)
 	cfg.Server.Port = getEnvIntOrDefault("SERVER_PORT", cfg.Server.Port)
+	cfg.Server.ReadTimeout = getEnvDurationOrDefault("SERVER_READ_TIMEOUT", cfg.Server.ReadTimeout)
+	cfg.Server.WriteTimeout = getEnvDurationOrDefault("SERVER_WRITE_TIMEOUT", cfg.Server.WriteTimeout)
+	cfg.Server.IdleTimeout = getEnvDurationOrDefault("SERVER_IDLE_TIMEOUT", cfg.Server.IdleTimeout)
+	cfg.Server.ShutdownTimeout = getEnvDurationOrDefault("SERVER_SHUTDOWN_TIMEOUT", cfg.Server.ShutdownTimeout)
+	cfg.Server.TLSCertFile = getEnvOrDefault("SERVER_TLS_CERT_FILE", cfg.Server.TLSCertFile)
+	cfg.Server.TLSKeyFile = getEnvOrDefault("SERVER_TLS_KEY_FILE", cfg.Server.TLSKeyFile)
+	cfg.Server.TLSMinVersion = getEnvOrDefault("SERVER_TLS_MIN_VERSION", cfg.Server.TLSMinVersion)
+	cfg.Server.TLSClientCAFile = getEnvOrDefault("SERVER_TLS_CLIENT_CA_FILE", cfg.Server.TLSClientCAFile)
 	cfg.Database.Host = getEnvOrDefault("DB_HOST", cfg.Database.Host)
 	cfg.Database.Port = getEnvIntOrDefault("DB_PORT", cfg.Database.Port)
 	cfg.Database.User = getEnvOrDefault("DB_USER", cfg.Database.User)
 	cfg.Database.Password = getEnvOrDefault("DB_PASSWORD", cfg.Database.Password)
 	cfg.Database.DBName = getEnvOrDefault("DB_NAME", cfg.Database.DBName)
 	cfg.Database.SSLMode = getEnvOrDefault("DB_SSL_MODE", cfg.Database.SSLMode)
+	cfg.Database.Driver = getEnvOrDefault("DB_DRIVER", cfg.Database.Driver)
+	cfg.Database.MaxOpenConns = getEnvIntOrDefault("DB_MAX_OPEN_CONNS", cfg.Database.MaxOpenConns)
+	cfg.Database.MaxIdleConns = getEnvIntOrDefault("DB_MAX_IDLE_CONNS", cfg.Database.MaxIdleConns)
+	cfg.Database.ConnMaxLifetime = getEnvDurationOrDefault("DB_CONN_MAX_LIFETIME", cfg.Database.ConnMaxLifetime)
+	cfg.Database.ConnMaxIdleTime = getEnvDurationOrDefault("DB_CONN_MAX_IDLE_TIME", cfg.Database.ConnMaxIdleTime)
+	cfg.Database.AutoMigrate = getEnvBoolOrDefault("DB_AUTO_MIGRATE", cfg.Database.AutoMigrate)
 	cfg.App.Port = getEnvOrDefault("APP_PORT", cfg.App.Port)
 	cfg.Log.Level = getEnvOrDefault("LOG_LEVEL", cfg.Log.Level)
+	cfg.Log.Format = getEnvOrDefault("LOG_FORMAT", cfg.Log.Format)
 	cfg.JWT.Secret = getEnvOrDefault("JWT_SECRET", cfg.JWT.Secret)
+	cfg.Encryption.PIIMasterKey = getEnvOrDefault("ENCRYPTION_PII_MASTER_KEY", cfg.Encryption.PIIMasterKey)
+	cfg.Encryption.HMACSecret = getEnvOrDefault("ENCRYPTION_HMAC_SECRET", cfg.Encryption.HMACSecret)
+	cfg.Encryption.KeyRotationDays = getEnvIntOrDefault("ENCRYPTION_KEY_ROTATION_DAYS", cfg.Encryption.KeyRotationDays)
 	cfg.API.Prefix = getEnvOrDefault("API_PREFIX", cfg.API.Prefix)
 	cfg.API.CORSAllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", cfg.API.CORSAllowedOrigins)
+	cfg.API.MaxBodySize = getEnvIntOrDefault("API_MAX_BODY_SIZE", cfg.API.MaxBodySize)
+	cfg.API.SecurityHeaders = getEnvBoolOrDefault("API_SECURITY_HEADERS", cfg.API.SecurityHeaders)
+	cfg.API.HSTSMaxAge = getEnvDurationOrDefault("API_HSTS_MAX_AGE", cfg.API.HSTSMaxAge)
+	cfg.API.CSRFSessionCookie = getEnvOrDefault("API_CSRF_SESSION_COOKIE", cfg.API.CSRFSessionCookie)
+	cfg.API.CSRFTokenCookie = getEnvOrDefault("API_CSRF_TOKEN_COOKIE", cfg.API.CSRFTokenCookie)
+	cfg.API.CSRFTokenHeader = getEnvOrDefault("API_CSRF_TOKEN_HEADER", cfg.API.CSRFTokenHeader)
+	cfg.RateLimit.Enabled = getEnvBoolOrDefault("RATE_LIMIT_ENABLED", cfg.RateLimit.Enabled)
+	cfg.RateLimit.RequestsPerHour = getEnvIntOrDefault("RATE_LIMIT_REQUESTS_PER_HOUR", cfg.RateLimit.RequestsPerHour)
+	cfg.RateLimit.BurstSize = getEnvIntOrDefault("RATE_LIMIT_BURST_SIZE", cfg.RateLimit.BurstSize)
+	cfg.RateLimit.ExpiryTime = getEnvDurationOrDefault("RATE_LIMIT_EXPIRY_TIME", cfg.RateLimit.ExpiryTime)
+	cfg.RateLimit.Backend = getEnvOrDefault("RATE_LIMIT_BACKEND", cfg.RateLimit.Backend)
+	cfg.RateLimit.Policies = getEnvRateLimitPolicies("RATE_LIMIT_POLICIES", cfg.RateLimit.Policies)
+	cfg.SMTP.Host = getEnvOrDefault("SMTP_HOST", cfg.SMTP.Host)
+	cfg.SMTP.Port = getEnvIntOrDefault("SMTP_PORT", cfg.SMTP.Port)
+	cfg.SMTP.Username = getEnvOrDefault("SMTP_USERNAME", cfg.SMTP.Username)
+	cfg.SMTP.Password = getEnvOrDefault("SMTP_PASSWORD", cfg.SMTP.Password)
+	cfg.SMTP.From = getEnvOrDefault("SMTP_FROM", cfg.SMTP.From)
+	cfg.SMS.Provider = getEnvOrDefault("SMS_PROVIDER", cfg.SMS.Provider)
+	cfg.SMS.From = getEnvOrDefault("SMS_FROM", cfg.SMS.From)
+	cfg.SMS.Timeout = getEnvDurationOrDefault("SMS_TIMEOUT", cfg.SMS.Timeout)
+	cfg.SMS.TwilioAccountSID = getEnvOrDefault("TWILIO_ACCOUNT_SID", cfg.SMS.TwilioAccountSID)
+	cfg.SMS.TwilioAuthToken = getEnvOrDefault("TWILIO_AUTH_TOKEN", cfg.SMS.TwilioAuthToken)
+	cfg.SMS.SMSCLogin = getEnvOrDefault("SMSC_LOGIN", cfg.SMS.SMSCLogin)
+	cfg.SMS.SMSCPassword = getEnvOrDefault("SMSC_PASSWORD", cfg.SMS.SMSCPassword)
+	cfg.CBR.BaseURL = getEnvOrDefault("CBR_BASE_URL", cfg.CBR.BaseURL)
+	cfg.CBR.CacheTTL = getEnvDurationOrDefault("CBR_CACHE_TTL", cfg.CBR.CacheTTL)
+	cfg.Bank.BIC = getEnvOrDefault("BANK_BIC", cfg.Bank.BIC)
+	cfg.Bank.BranchCode = getEnvOrDefault("BANK_BRANCH_CODE", cfg.Bank.BranchCode)
+	cfg.Bank.BalanceAccount = getEnvOrDefault("BANK_BALANCE_ACCOUNT", cfg.Bank.BalanceAccount)
+	cfg.Bank.Name = getEnvOrDefault("BANK_NAME", cfg.Bank.Name)
+	cfg.Bank.TaxID = getEnvOrDefault("BANK_TAX_ID", cfg.Bank.TaxID)
+	cfg.Bank.CorrespondentAccount = getEnvOrDefault("BANK_CORRESPONDENT_ACCOUNT", cfg.Bank.CorrespondentAccount)
+	cfg.Card.FXMarkupPercent = getEnvFloatOrDefault("CARD_FX_MARKUP_PERCENT", cfg.Card.FXMarkupPercent)
+	cfg.Credit.InterestRatePercent = getEnvFloatOrDefault("CREDIT_INTEREST_RATE_PERCENT", cfg.Credit.InterestRatePercent)
+	cfg.Credit.EarlyRepaymentFeePercent = getEnvFloatOrDefault("CREDIT_EARLY_REPAYMENT_FEE_PERCENT", cfg.Credit.EarlyRepaymentFeePercent)
+	cfg.Credit.PenaltyRatePercent = getEnvFloatOrDefault("CREDIT_PENALTY_RATE_PERCENT", cfg.Credit.PenaltyRatePercent)
+	cfg.Credit.VariableRateMarginPercent = getEnvFloatOrDefault("CREDIT_VARIABLE_RATE_MARGIN_PERCENT", cfg.Credit.VariableRateMarginPercent)
+	cfg.VariableRates.Enabled = getEnvBoolOrDefault("VARIABLE_RATES_ENABLED", cfg.VariableRates.Enabled)
+	cfg.VariableRates.Interval = getEnvDurationOrDefault("VARIABLE_RATES_INTERVAL", cfg.VariableRates.Interval)
+	cfg.Collections.Enabled = getEnvBoolOrDefault("COLLECTIONS_ENABLED", cfg.Collections.Enabled)
+	cfg.Collections.Interval = getEnvDurationOrDefault("COLLECTIONS_INTERVAL", cfg.Collections.Interval)
+	cfg.Deposits.Enabled = getEnvBoolOrDefault("DEPOSITS_ENABLED", cfg.Deposits.Enabled)
+	cfg.Deposits.Interval = getEnvDurationOrDefault("DEPOSITS_INTERVAL", cfg.Deposits.Interval)
+	cfg.Dormancy.Enabled = getEnvBoolOrDefault("DORMANCY_ENABLED", cfg.Dormancy.Enabled)
+	cfg.Dormancy.Interval = getEnvDurationOrDefault("DORMANCY_INTERVAL", cfg.Dormancy.Interval)
+	cfg.Alerts.Enabled = getEnvBoolOrDefault("ALERTS_ENABLED", cfg.Alerts.Enabled)
+	cfg.Alerts.Interval = getEnvDurationOrDefault("ALERTS_INTERVAL", cfg.Alerts.Interval)
+	cfg.Budgets.Enabled = getEnvBoolOrDefault("BUDGETS_ENABLED", cfg.Budgets.Enabled)
+	cfg.Budgets.Interval = getEnvDurationOrDefault("BUDGETS_INTERVAL", cfg.Budgets.Interval)
+	cfg.Jobs.PollInterval = getEnvDurationOrDefault("JOB_POLL_INTERVAL", cfg.Jobs.PollInterval)
+	cfg.Jobs.LeaseTimeout = getEnvDurationOrDefault("JOB_LEASE_TIMEOUT", cfg.Jobs.LeaseTimeout)
+	cfg.Jobs.Workers = getEnvIntOrDefault("JOB_WORKERS", cfg.Jobs.Workers)
+	cfg.Outbox.PollInterval = getEnvDurationOrDefault("OUTBOX_POLL_INTERVAL", cfg.Outbox.PollInterval)
+	cfg.Outbox.LeaseTimeout = getEnvDurationOrDefault("OUTBOX_LEASE_TIMEOUT", cfg.Outbox.LeaseTimeout)
+	cfg.Events.Broker = getEnvOrDefault("EVENTS_BROKER", cfg.Events.Broker)
+	cfg.Events.KafkaBrokers = getEnvList("EVENTS_KAFKA_BROKERS", cfg.Events.KafkaBrokers)
+	cfg.Events.NATSURL = getEnvOrDefault("EVENTS_NATS_URL", cfg.Events.NATSURL)
+	cfg.Events.TopicPrefix = getEnvOrDefault("EVENTS_TOPIC_PREFIX", cfg.Events.TopicPrefix)
+	cfg.Events.WriteTimeout = getEnvDurationOrDefault("EVENTS_WRITE_TIMEOUT", cfg.Events.WriteTimeout)
+	cfg.Stream.Enabled = getEnvBoolOrDefault("STREAM_ENABLED", cfg.Stream.Enabled)
+	cfg.Stream.HeartbeatInterval = getEnvDurationOrDefault("STREAM_HEARTBEAT_INTERVAL", cfg.Stream.HeartbeatInterval)
+	cfg.Stream.MaxConnectionsPerUser = getEnvIntOrDefault("STREAM_MAX_CONNECTIONS_PER_USER", cfg.Stream.MaxConnectionsPerUser)
+	cfg.GRPC.Enabled = getEnvBoolOrDefault("GRPC_ENABLED", cfg.GRPC.Enabled)
+	cfg.GRPC.Port = getEnvIntOrDefault("GRPC_PORT", cfg.GRPC.Port)
+	cfg.Scheduler.Enabled = getEnvBoolOrDefault("SCHEDULER_ENABLED", cfg.Scheduler.Enabled)
+	cfg.Scheduler.Interval = getEnvDurationOrDefault("SCHEDULER_INTERVAL", cfg.Scheduler.Interval)
+	cfg.Statements.Enabled = getEnvBoolOrDefault("STATEMENTS_ENABLED", cfg.Statements.Enabled)
+	cfg.Statements.Interval = getEnvDurationOrDefault("STATEMENTS_INTERVAL", cfg.Statements.Interval)
+	cfg.CardExpiry.Enabled = getEnvBoolOrDefault("CARD_EXPIRY_ENABLED", cfg.CardExpiry.Enabled)
+	cfg.CardExpiry.Interval = getEnvDurationOrDefault("CARD_EXPIRY_INTERVAL", cfg.CardExpiry.Interval)
+	cfg.CardExpiry.NoticeDays = getEnvIntOrDefault("CARD_EXPIRY_NOTICE_DAYS", cfg.CardExpiry.NoticeDays)
+	cfg.ExternalTransfers.Enabled = getEnvBoolOrDefault("EXTERNAL_TRANSFERS_ENABLED", cfg.ExternalTransfers.Enabled)
+	cfg.ExternalTransfers.Interval = getEnvDurationOrDefault("EXTERNAL_TRANSFERS_INTERVAL", cfg.ExternalTransfers.Interval)
+	cfg.ExternalTransfers.SettlementDelay = getEnvDurationOrDefault("EXTERNAL_TRANSFERS_SETTLEMENT_DELAY", cfg.ExternalTransfers.SettlementDelay)
+	cfg.IncomingPayments.Secret = getEnvOrDefault("INCOMING_PAYMENTS_SECRET", cfg.IncomingPayments.Secret)
+	cfg.IncomingPayments.MaxClockSkew = getEnvDurationOrDefault("INCOMING_PAYMENTS_MAX_CLOCK_SKEW", cfg.IncomingPayments.MaxClockSkew)
+	cfg.Acquiring.Secret = getEnvOrDefault("ACQUIRING_SECRET", cfg.Acquiring.Secret)
+	cfg.Acquiring.MaxClockSkew = getEnvDurationOrDefault("ACQUIRING_MAX_CLOCK_SKEW", cfg.Acquiring.MaxClockSkew)
+	cfg.Acquiring.AuthorizationTTL = getEnvDurationOrDefault("CARD_AUTHORIZATION_TTL", cfg.Acquiring.AuthorizationTTL)
+	cfg.Reconciliation.Enabled = getEnvBoolOrDefault("RECONCILIATION_ENABLED", cfg.Reconciliation.Enabled)
+	cfg.Reconciliation.Interval = getEnvDurationOrDefault("RECONCILIATION_INTERVAL", cfg.Reconciliation.Interval)
+	cfg.Reconciliation.Hour = getEnvIntOrDefault("RECONCILIATION_HOUR", cfg.Reconciliation.Hour)
+	cfg.UserErasure.Enabled = getEnvBoolOrDefault("USER_ERASURE_ENABLED", cfg.UserErasure.Enabled)
+	cfg.UserErasure.Interval = getEnvDurationOrDefault("USER_ERASURE_INTERVAL", cfg.UserErasure.Interval)
+	cfg.UserErasure.RetentionDays = getEnvIntOrDefault("USER_ERASURE_RETENTION_DAYS", cfg.UserErasure.RetentionDays)
+	cfg.Login.MaxFailedAttempts = getEnvIntOrDefault("LOGIN_MAX_FAILED_ATTEMPTS", cfg.Login.MaxFailedAttempts)
+	cfg.Login.FailureWindow = getEnvDurationOrDefault("LOGIN_FAILURE_WINDOW", cfg.Login.FailureWindow)
+	cfg.Login.LockoutDuration = getEnvDurationOrDefault("LOGIN_LOCKOUT_DURATION", cfg.Login.LockoutDuration)
+	cfg.Login.MaxFailedAttemptsPerIP = getEnvIntOrDefault("LOGIN_MAX_FAILED_ATTEMPTS_PER_IP", cfg.Login.MaxFailedAttemptsPerIP)
+	cfg.PasswordPolicy.MinLength = getEnvIntOrDefault("PASSWORD_MIN_LENGTH", cfg.PasswordPolicy.MinLength)
+	cfg.PasswordPolicy.RequireUppercase = getEnvBoolOrDefault("PASSWORD_REQUIRE_UPPERCASE", cfg.PasswordPolicy.RequireUppercase)
+	cfg.PasswordPolicy.RequireLowercase = getEnvBoolOrDefault("PASSWORD_REQUIRE_LOWERCASE", cfg.PasswordPolicy.RequireLowercase)
+	cfg.PasswordPolicy.RequireDigit = getEnvBoolOrDefault("PASSWORD_REQUIRE_DIGIT", cfg.PasswordPolicy.RequireDigit)
+	cfg.PasswordPolicy.RequireSymbol = getEnvBoolOrDefault("PASSWORD_REQUIRE_SYMBOL", cfg.PasswordPolicy.RequireSymbol)
+	cfg.PasswordPolicy.DisallowIdentity = getEnvBoolOrDefault("PASSWORD_DISALLOW_IDENTITY", cfg.PasswordPolicy.DisallowIdentity)
+	cfg.PasswordPolicy.BreachCheck = getEnvBoolOrDefault("PASSWORD_BREACH_CHECK", cfg.PasswordPolicy.BreachCheck)
+	cfg.PasswordPolicy.BreachCheckURL = getEnvOrDefault("PASSWORD_BREACH_CHECK_URL", cfg.PasswordPolicy.BreachCheckURL)
+	cfg.PasswordPolicy.BreachCheckTimeout = getEnvDurationOrDefault("PASSWORD_BREACH_CHECK_TIMEOUT", cfg.PasswordPolicy.BreachCheckTimeout)
+	cfg.Cache.Enabled = getEnvBoolOrDefault("CACHE_ENABLED", cfg.Cache.Enabled)
+	cfg.Cache.RedisAddr = getEnvOrDefault("REDIS_ADDR", cfg.Cache.RedisAddr)
+	cfg.Cache.RedisPassword = getEnvOrDefault("REDIS_PASSWORD", cfg.Cache.RedisPassword)
+	cfg.Cache.RedisDB = getEnvIntOrDefault("REDIS_DB", cfg.Cache.RedisDB)
+	cfg.Cache.KeyPrefix = getEnvOrDefault("CACHE_KEY_PREFIX", cfg.Cache.KeyPrefix)
+	cfg.Cache.Timeout = getEnvDurationOrDefault("CACHE_TIMEOUT", cfg.Cache.Timeout)
+	cfg.Cache.AccountTTL = getEnvDurationOrDefault("CACHE_ACCOUNT_TTL", cfg.Cache.AccountTTL)
+	cfg.Cache.SessionTTL = getEnvDurationOrDefault("CACHE_SESSION_TTL", cfg.Cache.SessionTTL)
+	cfg.Cache.AnalyticsTTL = getEnvDurationOrDefault("CACHE_ANALYTICS_TTL", cfg.Cache.AnalyticsTTL)
+	cfg.APIKeys.RotationGracePeriod = getEnvDurationOrDefault("API_KEY_ROTATION_GRACE_PERIOD", cfg.APIKeys.RotationGracePeriod)
+	cfg.Branches.DefaultCode = getEnvOrDefault("BRANCH_DEFAULT_CODE", cfg.Branches.DefaultCode)
+	cfg.AML.Enabled = getEnvBoolOrDefault("AML_ENABLED", cfg.AML.Enabled)
+	cfg.AML.Interval = getEnvDurationOrDefault("AML_INTERVAL", cfg.AML.Interval)
+	cfg.AML.Threshold = getEnvFloatOrDefault("AML_THRESHOLD", cfg.AML.Threshold)
+	cfg.AML.StructuringShare = getEnvFloatOrDefault("AML_STRUCTURING_SHARE", cfg.AML.StructuringShare)
+	cfg.AML.StructuringCount = getEnvIntOrDefault("AML_STRUCTURING_COUNT", cfg.AML.StructuringCount)
+	cfg.AML.StructuringWindow = getEnvDurationOrDefault("AML_STRUCTURING_WINDOW", cfg.AML.StructuringWindow)
+	cfg.AML.RapidMovementWindow = getEnvDurationOrDefault("AML_RAPID_MOVEMENT_WINDOW", cfg.AML.RapidMovementWindow)
+	cfg.AML.RapidMovementMinAmount = getEnvFloatOrDefault("AML_RAPID_MOVEMENT_MIN_AMOUNT", cfg.AML.RapidMovementMinAmount)
+	cfg.AML.RapidMovementShare = getEnvFloatOrDefault("AML_RAPID_MOVEMENT_SHARE", cfg.AML.RapidMovementShare)
+	cfg.Accounting.CashAccount = getEnvOrDefault("ACCOUNTING_CASH_ACCOUNT", cfg.Accounting.CashAccount)
+	cfg.Accounting.CardSettlementAccount = getEnvOrDefault("ACCOUNTING_CARD_SETTLEMENT_ACCOUNT", cfg.Accounting.CardSettlementAccount)
+	cfg.Accounting.LoansAccount = getEnvOrDefault("ACCOUNTING_LOANS_ACCOUNT", cfg.Accounting.LoansAccount)
+	cfg.Accounting.InterestIncomeAccount = getEnvOrDefault("ACCOUNTING_INTEREST_INCOME_ACCOUNT", cfg.Accounting.InterestIncomeAccount)
+	cfg.Accounting.ExternalClearingAccount = getEnvOrDefault("ACCOUNTING_EXTERNAL_CLEARING_ACCOUNT", cfg.Accounting.ExternalClearingAccount)
+	cfg.Accounting.DisputesAccount = getEnvOrDefault("ACCOUNTING_DISPUTES_ACCOUNT", cfg.Accounting.DisputesAccount)
+	cfg.Accounting.BillersAccount = getEnvOrDefault("ACCOUNTING_BILLERS_ACCOUNT", cfg.Accounting.BillersAccount)
+	cfg.Accounting.TermDepositsAccount = getEnvOrDefault("ACCOUNTING_TERM_DEPOSITS_ACCOUNT", cfg.Accounting.TermDepositsAccount)
+	cfg.Accounting.InterestExpenseAccount = getEnvOrDefault("ACCOUNTING_INTEREST_EXPENSE_ACCOUNT", cfg.Accounting.InterestExpenseAccount)
+	cfg.Accounting.FeeIncomeAccount = getEnvOrDefault("ACCOUNTING_FEE_INCOME_ACCOUNT", cfg.Accounting.FeeIncomeAccount)
+	cfg.Secrets.CacheTTL = getEnvDurationOrDefault("SECRETS_CACHE_TTL", cfg.Secrets.CacheTTL)
+	cfg.Secrets.Vault.Address = getEnvOrDefault("VAULT_ADDR", cfg.Secrets.Vault.Address)
+	cfg.Secrets.Vault.Token = getEnvOrDefault("VAULT_TOKEN", cfg.Secrets.Vault.Token)
+	cfg.Secrets.Vault.Namespace = getEnvOrDefault("VAULT_NAMESPACE", cfg.Secrets.Vault.Namespace)
+	cfg.Secrets.Vault.Mount = getEnvOrDefault("VAULT_KV_MOUNT", cfg.Secrets.Vault.Mount)
+	cfg.Secrets.KMS.Region = getEnvOrDefault("AWS_REGION", cfg.Secrets.KMS.Region)
+	cfg.Secrets.KMS.Endpoint = getEnvOrDefault("AWS_KMS_ENDPOINT", cfg.Secrets.KMS.Endpoint)
+	cfg.Secrets.KMS.AccessKeyID = getEnvOrDefault("AWS_ACCESS_KEY_ID", cfg.Secrets.KMS.AccessKeyID)
+	cfg.Secrets.KMS.SecretAccessKey = getEnvOrDefault("AWS_SECRET_ACCESS_KEY", cfg.Secrets.KMS.SecretAccessKey)
+	cfg.Secrets.KMS.SessionToken = getEnvOrDefault("AWS_SESSION_TOKEN", cfg.Secrets.KMS.SessionToken)
+	cfg.Parameters.CacheTTL = getEnvDurationOrDefault("PARAMETER_CACHE_TTL", cfg.Parameters.CacheTTL)
+	cfg.Replay.MaxClockSkew = getEnvDurationOrDefault("REPLAY_MAX_CLOCK_SKEW", cfg.Replay.MaxClockSkew)
+	cfg.Replay.Payments = getEnvOrDefault("REPLAY_PROTECTION_PAYMENTS", cfg.Replay.Payments)
+	cfg.Replay.Lending = getEnvOrDefault("REPLAY_PROTECTION_LENDING", cfg.Replay.Lending)
+	cfg.Fraud.Enabled = getEnvBoolOrDefault("FRAUD_ENABLED", cfg.Fraud.Enabled)
+	cfg.Fraud.ClientIPHeader = getEnvOrDefault("FRAUD_CLIENT_IP_HEADER", cfg.Fraud.ClientIPHeader)
+	cfg.Fraud.CountryHeader = getEnvOrDefault("FRAUD_COUNTRY_HEADER", cfg.Fraud.CountryHeader)
+	cfg.Fraud.VelocityWindow = getEnvDurationOrDefault("FRAUD_VELOCITY_WINDOW", cfg.Fraud.VelocityWindow)
+	cfg.Fraud.VelocityMaxCount = getEnvIntOrDefault("FRAUD_VELOCITY_MAX_COUNT", cfg.Fraud.VelocityMaxCount)
+	cfg.Fraud.VelocityMaxAmount = getEnvFloatOrDefault("FRAUD_VELOCITY_MAX_AMOUNT", cfg.Fraud.VelocityMaxAmount)
+	cfg.Fraud.VelocityAction = getEnvOrDefault("FRAUD_VELOCITY_ACTION", cfg.Fraud.VelocityAction)
+	cfg.Fraud.HistoryLookback = getEnvDurationOrDefault("FRAUD_HISTORY_LOOKBACK", cfg.Fraud.HistoryLookback)
+	cfg.Fraud.UnusualAmountMinHistory = getEnvIntOrDefault("FRAUD_UNUSUAL_AMOUNT_MIN_HISTORY", cfg.Fraud.UnusualAmountMinHistory)
+	cfg.Fraud.UnusualAmountMultiplier = getEnvFloatOrDefault("FRAUD_UNUSUAL_AMOUNT_MULTIPLIER", cfg.Fraud.UnusualAmountMultiplier)
+	cfg.Fraud.UnusualAmountAction = getEnvOrDefault("FRAUD_UNUSUAL_AMOUNT_ACTION", cfg.Fraud.UnusualAmountAction)
+	cfg.Fraud.NewRecipientAmount = getEnvFloatOrDefault("FRAUD_NEW_RECIPIENT_AMOUNT", cfg.Fraud.NewRecipientAmount)
+	cfg.Fraud.NewRecipientAction = getEnvOrDefault("FRAUD_NEW_RECIPIENT_ACTION", cfg.Fraud.NewRecipientAction)
+	cfg.Fraud.NewNetworkAction = getEnvOrDefault("FRAUD_NEW_NETWORK_ACTION", cfg.Fraud.NewNetworkAction)
+	cfg.Fraud.NewCountryAction = getEnvOrDefault("FRAUD_NEW_COUNTRY_ACTION", cfg.Fraud.NewCountryAction)
+	cfg.Limits.DailyTransfer = getEnvFloatOrDefault("LIMIT_DAILY_TRANSFER", cfg.Limits.DailyTransfer)
+	cfg.Limits.MonthlyTransfer = getEnvFloatOrDefault("LIMIT_MONTHLY_TRANSFER", cfg.Limits.MonthlyTransfer)
+	cfg.Limits.DailyWithdrawal = getEnvFloatOrDefault("LIMIT_DAILY_WITHDRAWAL", cfg.Limits.DailyWithdrawal)
+	cfg.Limits.MonthlyWithdrawal = getEnvFloatOrDefault("LIMIT_MONTHLY_WITHDRAWAL", cfg.Limits.MonthlyWithdrawal)
+	cfg.Limits.UnverifiedDaily = getEnvFloatOrDefault("LIMIT_UNVERIFIED_DAILY", cfg.Limits.UnverifiedDaily)
+	cfg.Limits.UnverifiedMonthly = getEnvFloatOrDefault("LIMIT_UNVERIFIED_MONTHLY", cfg.Limits.UnverifiedMonthly)
+	cfg.Storage.Backend = getEnvOrDefault("STORAGE_BACKEND", cfg.Storage.Backend)
+	cfg.Storage.LocalDir = getEnvOrDefault("STORAGE_LOCAL_DIR", cfg.Storage.LocalDir)
+	cfg.Storage.URLExpiry = getEnvDurationOrDefault("STORAGE_URL_EXPIRY", cfg.Storage.URLExpiry)
+	cfg.Storage.LocalBaseURL = getEnvOrDefault("STORAGE_LOCAL_BASE_URL", cfg.Storage.LocalBaseURL)
+	cfg.Storage.LocalURLSecret = getEnvOrDefault("STORAGE_LOCAL_URL_SECRET", cfg.Storage.LocalURLSecret)
+	cfg.Storage.S3.Bucket = getEnvOrDefault("STORAGE_S3_BUCKET", cfg.Storage.S3.Bucket)
+	cfg.Storage.S3.Region = getEnvOrDefault("STORAGE_S3_REGION", cfg.Storage.S3.Region)
+	cfg.Storage.S3.Endpoint = getEnvOrDefault("STORAGE_S3_ENDPOINT", cfg.Storage.S3.Endpoint)
+	cfg.Storage.S3.AccessKeyID = getEnvOrDefault("STORAGE_S3_ACCESS_KEY_ID", cfg.Storage.S3.AccessKeyID)
+	cfg.Storage.S3.SecretAccessKey = getEnvOrDefault("STORAGE_S3_SECRET_ACCESS_KEY", cfg.Storage.S3.SecretAccessKey)
+	cfg.Storage.S3.SessionToken = getEnvOrDefault("STORAGE_S3_SESSION_TOKEN", cfg.Storage.S3.SessionToken)
+	cfg.Storage.S3.Timeout = getEnvDurationOrDefault("STORAGE_S3_TIMEOUT", cfg.Storage.S3.Timeout)
+	cfg.KYC.MaxDocumentSize = int64(getEnvIntOrDefault("KYC_MAX_DOCUMENT_SIZE", int(cfg.KYC.MaxDocumentSize)))
+	cfg.Receipts.Secret = getEnvOrDefault("RECEIPT_SECRET", cfg.Receipts.Secret)
+	cfg.Receipts.VerifyURL = getEnvOrDefault("RECEIPT_VERIFY_URL", cfg.Receipts.VerifyURL)
+	cfg.Disputes.Window = getEnvDurationOrDefault("DISPUTE_WINDOW", cfg.Disputes.Window)
+	cfg.Disputes.MaxAttachments = getEnvIntOrDefault("DISPUTE_MAX_ATTACHMENTS", cfg.Disputes.MaxAttachments)
+	cfg.Disputes.MaxAttachmentSize = int64(getEnvIntOrDefault("DISPUTE_MAX_ATTACHMENT_SIZE", int(cfg.Disputes.MaxAttachmentSize)))
+	cfg.Collateral.MaxDocumentSize = int64(getEnvIntOrDefault("COLLATERAL_MAX_DOCUMENT_SIZE", int(cfg.Collateral.MaxDocumentSize)))
+	cfg.Payees.CodeTTL = getEnvDurationOrDefault("PAYEE_CODE_TTL", cfg.Payees.CodeTTL)
+	cfg.Payees.MaxCodeAttempts = getEnvIntOrDefault("PAYEE_MAX_CODE_ATTEMPTS", cfg.Payees.MaxCodeAttempts)
+	cfg.PaymentRequests.Enabled = getEnvBoolOrDefault("PAYMENT_REQUESTS_ENABLED", cfg.PaymentRequests.Enabled)
+	cfg.PaymentRequests.Interval = getEnvDurationOrDefault("PAYMENT_REQUESTS_INTERVAL", cfg.PaymentRequests.Interval)
+	cfg.PaymentRequests.TTL = getEnvDurationOrDefault("PAYMENT_REQUEST_TTL", cfg.PaymentRequests.TTL)
+	cfg.PaymentRequests.MaxTTL = getEnvDurationOrDefault("PAYMENT_REQUEST_MAX_TTL", cfg.PaymentRequests.MaxTTL)
+	cfg.TransferBatches.MaxTransfers = getEnvIntOrDefault("TRANSFER_BATCH_MAX_TRANSFERS", cfg.TransferBatches.MaxTransfers)
+	cfg.Billers.Timeout = getEnvDurationOrDefault("BILLERS_TIMEOUT", cfg.Billers.Timeout)
+	cfg.Billers.Providers = getEnvBillerProviders("BILLER_PROVIDERS", cfg.Billers.Providers)
+	cfg.Sandbox.Enabled = getEnvBoolOrDefault("SANDBOX_ENABLED", cfg.Sandbox.Enabled)
+	cfg.Sandbox.KeyRate = getEnvFloatOrDefault("SANDBOX_KEY_RATE", cfg.Sandbox.KeyRate)
+	cfg.Alerting.Enabled = getEnvBoolOrDefault("ALERT_ENABLED", cfg.Alerting.Enabled)
+	cfg.Alerting.EmailRecipients = getEnvList("ALERT_EMAILS", cfg.Alerting.EmailRecipients)
+	cfg.Alerting.WebhookURL = getEnvOrDefault("ALERT_WEBHOOK_URL", cfg.Alerting.WebhookURL)
+	cfg.Alerting.WebhookType = getEnvOrDefault("ALERT_WEBHOOK_TYPE", cfg.Alerting.WebhookType)
+	cfg.Alerting.TelegramChatID = getEnvOrDefault("ALERT_TELEGRAM_CHAT_ID", cfg.Alerting.TelegramChatID)
+	cfg.Alerting.Cooldown = getEnvDurationOrDefault("ALERT_COOLDOWN", cfg.Alerting.Cooldown)
+	cfg.Alerting.CheckInterval = getEnvDurationOrDefault("ALERT_CHECK_INTERVAL", cfg.Alerting.CheckInterval)
+	cfg.Alerting.ReadinessFailureThreshold = getEnvIntOrDefault("ALERT_READINESS_FAILURE_THRESHOLD", cfg.Alerting.ReadinessFailureThreshold)
 
 	// Debug logging
 	fmt.Printf("Database configuration: %+v\n", cfg.Database)
@@ -1,37 +1,119 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"strings"
 
 	"github.com/Abigotado/abi_banking/internal/config"
-	_ "github.com/lib/pq"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
 var DB *sql.DB
 
-func InitDB(cfg *config.Config, logger *logrus.Logger) error {
-	// Construct connection string
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+// Supported database/sql drivers. Both accept the same key/value connection string.
+const (
+	DriverPQ  = "postgres"
+	DriverPGX = "pgx"
+)
 
-	// Open database connection
-	var err error
-	DB, err = sql.Open("postgres", connStr)
-	if err != nil {
-		return fmt.Errorf("failed to open database connection: %w", err)
+// InitDB connects to the database. The password is fetched for every new
+// connection, so connections opened after it is rotated use the new one.
+func InitDB(cfg *config.Config, password *secrets.Secret, logger *logrus.Logger) error {
+	driverName := cfg.Database.Driver
+	if driverName == "" {
+		driverName = DriverPQ
+	}
+	if driverName != DriverPQ && driverName != DriverPGX {
+		return fmt.Errorf("unsupported database driver %q", driverName)
 	}
 
+	// Open database connection
+	DB = sql.OpenDB(&passwordConnector{driverName: driverName, cfg: &cfg.Database, password: password})
+
+	// Size the connection pool
+	DB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	DB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	DB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	DB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+
 	// Test the connection
-	if err = DB.Ping(); err != nil {
+	if err := DB.Ping(); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	logger.Info("Successfully connected to database")
+	logger.WithFields(logrus.Fields{
+		"driver":         driverName,
+		"max_open_conns": cfg.Database.MaxOpenConns,
+		"max_idle_conns": cfg.Database.MaxIdleConns,
+	}).Info("Successfully connected to database")
 	return nil
 }
 
+// passwordConnector opens connections with the current password of the database
+type passwordConnector struct {
+	driverName string
+	cfg        *config.DatabaseConfig
+	password   *secrets.Secret
+}
+
+// Connect opens a connection. A refused connection makes the password be
+// fetched again and, if it has been rotated, the connection is tried once more.
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		if changed, refreshErr := c.password.Refresh(ctx); refreshErr == nil && changed {
+			return c.connect(ctx)
+		}
+	}
+	return conn, err
+}
+
+func (c *passwordConnector) connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.password.Value(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	connStr := connString(c.cfg, password)
+
+	var connector driver.Connector
+	if c.driverName == DriverPGX {
+		connector, err = stdlib.GetDefaultDriver().(driver.DriverContext).OpenConnector(connStr)
+	} else {
+		connector, err = pq.NewConnector(connStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the underlying driver
+func (c *passwordConnector) Driver() driver.Driver {
+	if c.driverName == DriverPGX {
+		return stdlib.GetDefaultDriver()
+	}
+	return &pq.Driver{}
+}
+
+// connString builds the key/value connection string of the database
+func connString(cfg *config.DatabaseConfig, password string) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, quoteConnValue(password), cfg.DBName, cfg.SSLMode)
+}
+
+// quoteConnValue quotes a value of a key/value connection string, which a
+// fetched password may need for its spaces and quotes
+func quoteConnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
 func CloseDB() error {
 	if DB != nil {
 		return DB.Close()
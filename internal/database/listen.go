@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	listenMinReconnect = time.Second
+	listenMaxReconnect = time.Minute
+	// listenPingInterval is the time between checks of an idle listening
+	// connection, so a dead one is noticed and re-established
+	listenPingInterval = time.Minute
+)
+
+// Listen receives the notifications sent on a Postgres channel and passes their
+// payloads to handle until ctx is done. It listens on a connection of its own,
+// outside the pool and whatever the configured driver, which is re-established
+// when lost; notifications sent while it is down are lost.
+func Listen(
+	ctx context.Context,
+	cfg *config.DatabaseConfig,
+	password *secrets.Secret,
+	channel string,
+	handle func(payload string),
+	logger *logrus.Logger,
+) {
+	for {
+		err := listen(ctx, cfg, password, channel, handle, logger)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.WithError(err).Warnf("Listening on %s failed, retrying", channel)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenMaxReconnect):
+		}
+	}
+}
+
+func listen(
+	ctx context.Context,
+	cfg *config.DatabaseConfig,
+	password *secrets.Secret,
+	channel string,
+	handle func(payload string),
+	logger *logrus.Logger,
+) error {
+	value, err := password.Value(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := make(chan error, 1)
+	listener := pq.NewListener(connString(cfg, value), listenMinReconnect, listenMaxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventConnectionAttemptFailed:
+				select {
+				case failed <- err:
+				default:
+				}
+			case pq.ListenerEventReconnected:
+				logger.Warnf("Reconnected to listen on %s, notifications sent meanwhile are lost", channel)
+			}
+		})
+	defer listener.Close()
+
+	// Listen blocks until the connection is up, which takes a new listener if
+	// the password has been rotated
+	listening := make(chan error, 1)
+	go func() { listening <- listener.Listen(channel) }()
+
+	ping := time.NewTicker(listenPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-listening:
+			if err != nil {
+				return err
+			}
+			logger.Infof("Listening on %s", channel)
+		case err := <-failed:
+			// The listener reconnects with the password it was created with
+			if changed, refreshErr := password.Refresh(ctx); refreshErr == nil && changed {
+				return fmt.Errorf("failed to connect: %w", err)
+			}
+		case n := <-listener.Notify:
+			// A nil notification tells of a reconnection
+			if n != nil {
+				handle(n.Extra)
+			}
+		case <-ping.C:
+			// An error means there is no connection, which is being re-established
+			listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// AdvisoryLocker provides locks shared by all instances using the same database,
+// backed by Postgres session advisory locks
+type AdvisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker creates a locker on db
+func NewAdvisoryLocker(db *sql.DB) *AdvisoryLocker {
+	return &AdvisoryLocker{db: db}
+}
+
+// TryLock acquires the named lock without waiting. It returns false if another
+// session holds the lock. The lock is tied to a dedicated connection, so it is
+// also released if the instance dies; otherwise the caller must call unlock.
+func (l *AdvisoryLocker) TryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	// Lock keys are 64-bit integers, hashtext maps the name onto one
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock = func() {
+		// Unlock even when ctx is done. If that fails the connection is
+		// discarded instead of returned to the pool, which ends the session
+		// and with it the lock.
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, name); err != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
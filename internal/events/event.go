@@ -0,0 +1,76 @@
+// Package events publishes domain events to a message broker for downstream
+// consumers such as fraud detection and the data warehouse. Events are written
+// to the transactional outbox together with the change they describe and handed
+// to the broker by the outbox relay, so consumers see every committed change at
+// least once and never a rolled back one.
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SchemaVersion is the version of the envelope and event payloads below. It is
+// raised on any change that is not backwards compatible for consumers.
+const SchemaVersion = 1
+
+// Source identifies this service in the envelope
+const Source = "abi_banking"
+
+// Domain event types. The broker topic or subject of an event is the configured
+// prefix followed by its type.
+const (
+	TypeUserRegistered    = "user.registered"
+	TypeTransferCompleted = "transfer.completed"
+	TypeCreditOverdue     = "credit.overdue"
+	TypeCardBlocked       = "card.blocked"
+)
+
+// Event is the JSON envelope published for every domain event. ID is stable
+// across redeliveries, so consumers deduplicate on it.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	Source        string          `json:"source"`
+	Key           string          `json:"key"` // partition key, events with the same key keep their order
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// UserRegistered is published when a user signs up
+type UserRegistered struct {
+	UserID       int64     `json:"user_id"`
+	Username     string    `json:"username"`
+	BranchID     int64     `json:"branch_id"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// TransferCompleted is published when money moves between two accounts
+type TransferCompleted struct {
+	TransactionID int64     `json:"transaction_id"`
+	FromAccountID int64     `json:"from_account_id"`
+	FromUserID    int64     `json:"from_user_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	ToUserID      int64     `json:"to_user_id"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	CompletedAt   time.Time `json:"completed_at"`
+}
+
+// CreditOverdue is published when an installment is left unpaid past its due date
+type CreditOverdue struct {
+	CreditID  int64     `json:"credit_id"`
+	PaymentID int64     `json:"payment_id"`
+	UserID    int64     `json:"user_id"`
+	Amount    float64   `json:"amount"`
+	DueDate   time.Time `json:"due_date"`
+}
+
+// CardBlocked is published when a card is blocked
+type CardBlocked struct {
+	CardID    int64     `json:"card_id"`
+	AccountID int64     `json:"account_id"`
+	UserID    int64     `json:"user_id"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
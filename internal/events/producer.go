@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// Supported brokers
+const (
+	BrokerNone  = "none"
+	BrokerKafka = "kafka"
+	BrokerNATS  = "nats"
+)
+
+// Event headers set on every broker message
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+)
+
+// Producer writes serialized events to a message broker
+type Producer interface {
+	// Produce writes one event and returns once the broker has accepted it
+	Produce(ctx context.Context, topic string, event *Event, value []byte) error
+	// Close flushes pending writes and releases the connection
+	Close() error
+}
+
+// newProducer creates the producer of the configured broker, or nil when event
+// publishing is disabled
+func newProducer(cfg *config.EventsConfig) (Producer, error) {
+	switch cfg.Broker {
+	case "", BrokerNone:
+		return nil, nil
+	case BrokerKafka:
+		if len(cfg.KafkaBrokers) == 0 {
+			return nil, fmt.Errorf("kafka event broker needs at least one broker address")
+		}
+		return newKafkaProducer(cfg), nil
+	case BrokerNATS:
+		if cfg.NATSURL == "" {
+			return nil, fmt.Errorf("nats event broker needs a server url")
+		}
+		return newNATSProducer(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported event broker %q", cfg.Broker)
+	}
+}
+
+// kafkaProducer writes events to Kafka, keyed so that events of one entity land
+// in the same partition
+type kafkaProducer struct {
+	writer *kafka.Writer
+}
+
+func newKafkaProducer(cfg *config.EventsConfig) *kafkaProducer {
+	return &kafkaProducer{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.KafkaBrokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			WriteTimeout:           cfg.WriteTimeout,
+			BatchTimeout:           10 * time.Millisecond,
+		},
+	}
+}
+
+func (p *kafkaProducer) Produce(ctx context.Context, topic string, event *Event, value []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic: topic,
+		Key:   []byte(event.Key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderEventID, Value: []byte(event.ID)},
+			{Key: HeaderEventType, Value: []byte(event.Type)},
+			{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(event.SchemaVersion))},
+		},
+		Time: event.OccurredAt,
+	})
+}
+
+func (p *kafkaProducer) Close() error {
+	return p.writer.Close()
+}
+
+// natsProducer publishes events to NATS subjects. The message ID header lets
+// JetStream streams drop redeliveries of the same event.
+type natsProducer struct {
+	conn *nats.Conn
+}
+
+func newNATSProducer(cfg *config.EventsConfig) (*natsProducer, error) {
+	conn, err := nats.Connect(cfg.NATSURL, nats.Name(Source), nats.Timeout(cfg.WriteTimeout), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	return &natsProducer{conn: conn}, nil
+}
+
+func (p *natsProducer) Produce(ctx context.Context, topic string, event *Event, value []byte) error {
+	msg := &nats.Msg{
+		Subject: topic,
+		Header:  nats.Header{},
+		Data:    value,
+	}
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	msg.Header.Set(HeaderEventType, event.Type)
+	msg.Header.Set(HeaderSchemaVersion, strconv.Itoa(event.SchemaVersion))
+
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// Publishing is buffered, flushing confirms the server received the event
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *natsProducer) Close() error {
+	return p.conn.Drain()
+}
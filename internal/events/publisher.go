@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Publisher records domain events in the outbox and delivers them to the broker.
+// With no broker configured events are not recorded at all.
+type Publisher struct {
+	cfg      *config.EventsConfig
+	relay    *outbox.Relay
+	producer Producer
+	logger   *logrus.Logger
+}
+
+// NewPublisher connects to the configured broker and registers the delivery of
+// domain events with the outbox relay
+func NewPublisher(cfg *config.EventsConfig, relay *outbox.Relay, logger *logrus.Logger) (*Publisher, error) {
+	producer, err := newProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		cfg:      cfg,
+		relay:    relay,
+		producer: producer,
+		logger:   logger,
+	}
+	if producer == nil {
+		logger.Info("Domain event publishing is disabled")
+		return p, nil
+	}
+
+	relay.Register(models.OutboxTopicDomain, p.deliver)
+	logger.Infof("Publishing domain events to %s", cfg.Broker)
+	return p, nil
+}
+
+// PublishTx records an event within the transaction of the change it describes
+func (p *Publisher) PublishTx(ctx context.Context, tx repository.Tx, eventType string, key int64, data interface{}) error {
+	if p.producer == nil {
+		return nil
+	}
+	event, err := newEvent(eventType, key, data)
+	if err != nil {
+		return err
+	}
+	return p.relay.AddTx(ctx, tx, models.OutboxTopicDomain, event)
+}
+
+// Publish records an event of a change made without a transaction
+func (p *Publisher) Publish(ctx context.Context, eventType string, key int64, data interface{}) error {
+	if p.producer == nil {
+		return nil
+	}
+	event, err := newEvent(eventType, key, data)
+	if err != nil {
+		return err
+	}
+	return p.relay.Add(ctx, models.OutboxTopicDomain, event)
+}
+
+// Close flushes and closes the broker connection. It must be called after the
+// outbox relay has stopped, so no delivery is in progress.
+func (p *Publisher) Close() error {
+	if p.producer == nil {
+		return nil
+	}
+	p.logger.Info("Closing domain event producer")
+	return p.producer.Close()
+}
+
+func newEvent(eventType string, key int64, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return &Event{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		Source:        Source,
+		Key:           fmt.Sprint(key),
+		OccurredAt:    time.Now().UTC(),
+		Data:          raw,
+	}, nil
+}
+
+// deliver hands an event recorded in the outbox to the broker
+func (p *Publisher) deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	var event Event
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		return fmt.Errorf("invalid domain event in outbox: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.WriteTimeout)
+	defer cancel()
+
+	return p.producer.Produce(ctx, p.cfg.TopicPrefix+event.Type, &event, msg.Payload)
+}
@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// StreamChannel is the Postgres channel the database notifies the changes
+// streamed to users on
+const StreamChannel = "user_events"
+
+// Types of the events streamed to users
+const (
+	StreamBalanceChanged    = "balance.changed"
+	StreamTransferReceived  = "transfer.received"
+	StreamCardStatusChanged = "card.status_changed"
+)
+
+// streamBuffer is the number of events a subscription holds for a client that
+// has not written the previous ones out yet
+const streamBuffer = 64
+
+// ErrTooManyStreams is returned when a user already has the most streams open
+var ErrTooManyStreams = errors.New("too many open streams")
+
+// StreamEvent is an event pushed to the streams of a user
+type StreamEvent struct {
+	Type string
+	Data json.RawMessage
+}
+
+// Stream fans the changes notified by the database out to the open streams of
+// their users. Each instance receives every notification and delivers it to the
+// streams connected to it.
+type Stream struct {
+	cfg    *config.StreamConfig
+	logger *logrus.Logger
+
+	mu     sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+	closed bool
+}
+
+// NewStream creates a stream with no subscriptions
+func NewStream(cfg *config.StreamConfig, logger *logrus.Logger) *Stream {
+	return &Stream{
+		cfg:    cfg,
+		logger: logger,
+		subs:   make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// HeartbeatInterval returns the time between keep-alive comments on a stream
+func (s *Stream) HeartbeatInterval() time.Duration {
+	return s.cfg.HeartbeatInterval
+}
+
+// Subscription receives the events of a user on C. C is closed when the
+// subscriber falls too far behind or the stream is closed, and the client is
+// expected to reconnect and read the current state again.
+type Subscription struct {
+	C <-chan StreamEvent
+
+	c      chan StreamEvent
+	userID int64
+	stream *Stream
+	once   sync.Once
+}
+
+// Subscribe opens a subscription to the events of a user
+func (s *Stream) Subscribe(userID int64) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errors.New("stream is closed")
+	}
+	if s.cfg.MaxConnectionsPerUser > 0 && len(s.subs[userID]) >= s.cfg.MaxConnectionsPerUser {
+		return nil, ErrTooManyStreams
+	}
+
+	c := make(chan StreamEvent, streamBuffer)
+	sub := &Subscription{C: c, c: c, userID: userID, stream: s}
+	if s.subs[userID] == nil {
+		s.subs[userID] = make(map[*Subscription]struct{})
+	}
+	s.subs[userID][sub] = struct{}{}
+	return sub, nil
+}
+
+// Close ends the subscription. It may be called more than once.
+func (sub *Subscription) Close() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.stream.remove(sub)
+}
+
+// remove drops a subscription and closes its channel; the caller holds the lock
+func (s *Stream) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(s.subs[sub.userID], sub)
+		if len(s.subs[sub.userID]) == 0 {
+			delete(s.subs, sub.userID)
+		}
+		close(sub.c)
+	})
+}
+
+// Dispatch delivers the event of a database notification to the subscriptions
+// of its user
+func (s *Stream) Dispatch(payload string) {
+	var n struct {
+		UserID int64           `json:"user_id"`
+		Type   string          `json:"type"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(payload), &n); err != nil || n.UserID == 0 || n.Type == "" {
+		s.logger.WithError(err).Warn("Ignoring malformed stream notification")
+		return
+	}
+	s.Publish(n.UserID, StreamEvent{Type: n.Type, Data: n.Data})
+}
+
+// Publish delivers an event to the subscriptions of a user. A subscription
+// whose buffer is full is dropped rather than made to miss events unnoticed.
+func (s *Stream) Publish(userID int64, event StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subs[userID] {
+		select {
+		case sub.c <- event:
+		default:
+			s.logger.WithField("user_id", userID).Warn("Dropping a stream that fell behind")
+			s.remove(sub)
+		}
+	}
+}
+
+// Close ends all subscriptions and refuses new ones, so open streams finish on
+// shutdown
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, subs := range s.subs {
+		for sub := range subs {
+			s.remove(sub)
+		}
+	}
+}
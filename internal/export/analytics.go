@@ -0,0 +1,144 @@
+package export
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+)
+
+// WriteTransactionAnalytics writes the transaction analytics of a user as a
+// workbook: the totals, the transactions by category, the monthly summaries and
+// the number of transactions per day
+func WriteTransactionAnalytics(w io.Writer, analytics *service.TransactionAnalytics, report *models.TransactionReport) error {
+	b := NewWorkbook(w)
+
+	if err := b.Sheet("Summary", "Metric", "Value"); err != nil {
+		return err
+	}
+	rows := [][]interface{}{
+		{"Period start", report.From},
+		{"Period end", report.To},
+		{"Transactions", analytics.TotalTransactions},
+		{"Total amount", analytics.TotalAmount},
+		{"Average amount", analytics.AverageAmount},
+		{"Largest amount", analytics.MaxAmount},
+		{"Smallest amount", analytics.MinAmount},
+		{"Overdraft limit", analytics.OverdraftLimit},
+		{"Overdraft used", analytics.OverdraftUsed},
+		{"Overdraft interest charged", analytics.OverdraftInterestCharged},
+	}
+	for _, row := range rows {
+		if err := b.Row(row...); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Sheet("By category", "Category", "Currency", "Transactions", "Amount"); err != nil {
+		return err
+	}
+	for _, c := range report.Categories {
+		if err := b.Row(c.Category, c.Currency, c.Transactions, c.Amount); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Sheet("Monthly", "Month", "Currency", "Transactions", "Income", "Expenses", "Net"); err != nil {
+		return err
+	}
+	for _, m := range report.Months {
+		if err := b.Row(m.Month, m.Currency, m.Transactions, m.Income, m.Expenses, m.Net); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Sheet("By day", "Date", "Transactions"); err != nil {
+		return err
+	}
+	days := make([]string, 0, len(analytics.TransactionsByDay))
+	for day := range analytics.TransactionsByDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		if err := b.Row(day, analytics.TransactionsByDay[day]); err != nil {
+			return err
+		}
+	}
+
+	return b.Close()
+}
+
+// ScheduleFunc retrieves the payment schedule of a credit
+type ScheduleFunc func(ctx context.Context, creditID int64) ([]*models.PaymentSchedule, error)
+
+// WriteCreditAnalytics writes the credit analytics of a user as a workbook: the
+// totals, the credits and their payment schedules. The schedules are retrieved
+// one credit at a time as their rows are written.
+func WriteCreditAnalytics(
+	ctx context.Context,
+	w io.Writer,
+	analytics *service.CreditAnalytics,
+	credits []*models.Credit,
+	schedule ScheduleFunc,
+) error {
+	b := NewWorkbook(w)
+
+	if err := b.Sheet("Summary", "Metric", "Value"); err != nil {
+		return err
+	}
+	rows := [][]interface{}{
+		{"Credits", analytics.TotalCredits},
+		{"Total amount", analytics.TotalAmount},
+		{"Paid", analytics.TotalPaid},
+		{"Remaining", analytics.TotalRemaining},
+		{"Average interest rate", analytics.AverageInterest},
+		{"Next payment date", analytics.NextPaymentDate},
+		{"Next payment amount", analytics.NextPaymentAmount},
+		{"Overdue payments", analytics.OverduePayments},
+		{"Overdue amount", analytics.OverdueAmount},
+		{"Penalties", analytics.TotalPenalties},
+	}
+	for _, row := range rows {
+		if err := b.Row(row...); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Sheet("Credits", "Credit", "Account", "Amount", "Remaining principal", "Interest rate",
+		"Term, months", "Schedule type", "Status", "Opened"); err != nil {
+		return err
+	}
+	for _, c := range credits {
+		if err := b.Row(c.ID, c.AccountID, c.Amount, c.RemainingAmount, c.InterestRate, c.TermMonths,
+			string(c.ScheduleType), c.Status, c.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Sheet("Schedule", "Credit", "Due date", "Amount", "Principal", "Interest", "Penalty",
+		"Paid", "Remaining", "Status", "Paid at"); err != nil {
+		return err
+	}
+	for _, c := range credits {
+		payments, err := schedule(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		for _, p := range payments {
+			if strings.EqualFold(string(p.Status), string(models.PaymentStatusArchived)) {
+				// Replaced by a restructuring or a repricing
+				continue
+			}
+			if err := b.Row(c.ID, p.DueDate, p.Amount, p.Principal, p.Interest, p.Penalty,
+				p.PaidAmount, p.AmountDue(), string(p.Status), p.PaidAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return b.Close()
+}
@@ -0,0 +1,213 @@
+// Package export renders the analytics of a user as XLSX spreadsheets. The
+// workbook is written to the response as it is built: rows go straight into the
+// zip stream one sheet at a time, and cells hold their text inline, so nothing
+// but the names of the sheets is kept until the workbook is closed.
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of XLSX workbooks
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// FormatXLSX is the format query value requesting a spreadsheet
+const FormatXLSX = "xlsx"
+
+const (
+	sheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetFooter = `</sheetData></worksheet>`
+)
+
+// Workbook writes an XLSX workbook sheet by sheet. Rows are added to the sheet
+// started last; sheets cannot be returned to.
+type Workbook struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	sheets []string
+	row    int
+}
+
+// NewWorkbook starts a workbook written to w
+func NewWorkbook(w io.Writer) *Workbook {
+	return &Workbook{zw: zip.NewWriter(w)}
+}
+
+// Sheet finishes the current sheet and starts a new one with a header row
+func (b *Workbook) Sheet(name string, header ...string) error {
+	if err := b.finishSheet(); err != nil {
+		return err
+	}
+
+	b.sheets = append(b.sheets, name)
+	f, err := b.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(b.sheets)))
+	if err != nil {
+		return err
+	}
+	b.sheet = bufio.NewWriter(f)
+	b.row = 0
+	if _, err := b.sheet.WriteString(sheetHeader); err != nil {
+		return err
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	cells := make([]interface{}, len(header))
+	for i, title := range header {
+		cells[i] = title
+	}
+	return b.Row(cells...)
+}
+
+// Row appends a row to the current sheet. Strings are written as text, numbers
+// as numbers and times as YYYY-MM-DD dates; nil leaves the cell empty.
+func (b *Workbook) Row(cells ...interface{}) error {
+	if b.sheet == nil {
+		return errors.New("no sheet started")
+	}
+
+	b.row++
+	fmt.Fprintf(b.sheet, `<row r="%d">`, b.row)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(b.row)
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case string:
+			b.text(ref, v)
+		case time.Time:
+			b.text(ref, v.Format("2006-01-02"))
+		case *time.Time:
+			if v != nil {
+				b.text(ref, v.Format("2006-01-02"))
+			}
+		case float64:
+			fmt.Fprintf(b.sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case int:
+			fmt.Fprintf(b.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			fmt.Fprintf(b.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		default:
+			b.text(ref, fmt.Sprint(v))
+		}
+	}
+	_, err := b.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (b *Workbook) text(ref, value string) {
+	fmt.Fprintf(b.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	xml.EscapeText(b.sheet, []byte(value))
+	b.sheet.WriteString(`</t></is></c>`)
+}
+
+func (b *Workbook) finishSheet() error {
+	if b.sheet == nil {
+		return nil
+	}
+	if _, err := b.sheet.WriteString(sheetFooter); err != nil {
+		return err
+	}
+	err := b.sheet.Flush()
+	b.sheet = nil
+	return err
+}
+
+// Close finishes the last sheet and writes the parts tying the sheets into a
+// workbook. It does not close the underlying writer.
+func (b *Workbook) Close() error {
+	if err := b.finishSheet(); err != nil {
+		return err
+	}
+	if len(b.sheets) == 0 {
+		// A workbook needs a sheet to open
+		if err := b.Sheet("Sheet1"); err != nil {
+			return err
+		}
+		if err := b.finishSheet(); err != nil {
+			return err
+		}
+	}
+
+	var contentTypes, workbook, rels strings.Builder
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range b.sheets {
+		n := i + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		workbook.WriteString(`<sheet name="`)
+		xml.EscapeText(&workbook, []byte(sheetName(name)))
+		fmt.Fprintf(&workbook, `" sheetId="%d" r:id="rId%d"/>`, n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" ` +
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+			`Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+	}
+	for _, part := range parts {
+		f, err := b.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return b.zw.Close()
+}
+
+// columnName returns the letters of the column at a zero-based index: A to Z,
+// then AA and on
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// sheetName drops the characters spreadsheets do not allow in sheet names and
+// cuts the name to 31 characters
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
@@ -0,0 +1,211 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Rule names recorded with the hits
+const (
+	RuleVelocity      = "velocity"
+	RuleUnusualAmount = "unusual_amount"
+	RuleNewRecipient  = "new_recipient"
+	RuleNewNetwork    = "new_network"
+	RuleNewCountry    = "new_country"
+)
+
+// Rule modes besides the decisions themselves
+const modeOff = "off"
+
+// rule checks a transaction for one suspicious pattern. The check returns the
+// reason the transaction looks suspicious, or an empty string when it does not.
+type rule struct {
+	name     string
+	decision models.FraudDecision
+	check    func(ctx context.Context, e *evaluation) (string, error)
+}
+
+// evaluation carries a transaction through the rules and loads the history
+// several rules share once
+type evaluation struct {
+	op   *models.FraudOperation
+	now  time.Time
+	repo *repository.FraudRepository
+	cfg  *config.FraudConfig
+
+	locations       []models.ClientLocation
+	locationsLoaded bool
+}
+
+func (e *evaluation) clientLocations(ctx context.Context) ([]models.ClientLocation, error) {
+	if !e.locationsLoaded {
+		locations, err := e.repo.GetClientLocations(ctx, e.op.UserID, e.now.Add(-e.cfg.HistoryLookback))
+		if err != nil {
+			return nil, err
+		}
+		e.locations, e.locationsLoaded = locations, true
+	}
+	return e.locations, nil
+}
+
+// newRules builds the rules enabled in the configuration
+func newRules(cfg *config.FraudConfig, logger *logrus.Logger) []rule {
+	candidates := []struct {
+		name  string
+		mode  string
+		check func(ctx context.Context, e *evaluation) (string, error)
+	}{
+		{RuleVelocity, cfg.VelocityAction, checkVelocity},
+		{RuleUnusualAmount, cfg.UnusualAmountAction, checkUnusualAmount},
+		{RuleNewRecipient, cfg.NewRecipientAction, checkNewRecipient},
+		{RuleNewNetwork, cfg.NewNetworkAction, checkNewNetwork},
+		{RuleNewCountry, cfg.NewCountryAction, checkNewCountry},
+	}
+
+	var rules []rule
+	for _, candidate := range candidates {
+		var decision models.FraudDecision
+		switch models.FraudDecision(candidate.mode) {
+		case models.FraudDecisionFlag, models.FraudDecisionHold, models.FraudDecisionReject:
+			decision = models.FraudDecision(candidate.mode)
+		default:
+			if candidate.mode == modeOff {
+				continue
+			}
+			logger.Warnf("Unknown mode %q of fraud rule %s, holding matching transactions", candidate.mode, candidate.name)
+			decision = models.FraudDecisionHold
+		}
+		rules = append(rules, rule{name: candidate.name, decision: decision, check: candidate.check})
+	}
+	return rules
+}
+
+// checkVelocity limits how many transactions and how much money may leave the
+// accounts of a user within the velocity window
+func checkVelocity(ctx context.Context, e *evaluation) (string, error) {
+	activity, err := e.repo.GetOutgoingActivity(ctx, e.op.UserID, e.now.Add(-e.cfg.VelocityWindow))
+	if err != nil {
+		return "", err
+	}
+
+	if e.cfg.VelocityMaxCount > 0 && activity.Count+1 > e.cfg.VelocityMaxCount {
+		return fmt.Sprintf("%d outgoing transactions within %s exceed the limit of %d",
+			activity.Count+1, e.cfg.VelocityWindow, e.cfg.VelocityMaxCount), nil
+	}
+	if e.cfg.VelocityMaxAmount > 0 && activity.Total+e.op.Amount > e.cfg.VelocityMaxAmount {
+		return fmt.Sprintf("%.2f leaving within %s exceeds the limit of %.2f",
+			activity.Total+e.op.Amount, e.cfg.VelocityWindow, e.cfg.VelocityMaxAmount), nil
+	}
+	return "", nil
+}
+
+// checkUnusualAmount compares the amount with the average outgoing transaction
+// of the user, once there is enough history to tell what is usual
+func checkUnusualAmount(ctx context.Context, e *evaluation) (string, error) {
+	activity, err := e.repo.GetOutgoingActivity(ctx, e.op.UserID, e.now.Add(-e.cfg.HistoryLookback))
+	if err != nil {
+		return "", err
+	}
+
+	if activity.Count < e.cfg.UnusualAmountMinHistory || activity.Average <= 0 {
+		return "", nil
+	}
+	if e.op.Amount > activity.Average*e.cfg.UnusualAmountMultiplier {
+		return fmt.Sprintf("amount %.2f is %.1f times the usual %.2f",
+			e.op.Amount, e.op.Amount/activity.Average, activity.Average), nil
+	}
+	return "", nil
+}
+
+// checkNewRecipient catches large transfers to an account of another user the
+// sender has never transferred money to
+func checkNewRecipient(ctx context.Context, e *evaluation) (string, error) {
+	if e.op.Type != models.TransactionTypeTransfer || e.op.ToUserID == e.op.UserID || e.op.Amount < e.cfg.NewRecipientAmount {
+		return "", nil
+	}
+
+	known, err := e.repo.HasTransferredTo(ctx, e.op.UserID, e.op.ToAccountID)
+	if err != nil || known {
+		return "", err
+	}
+	return fmt.Sprintf("first transfer to account %d is %.2f", e.op.ToAccountID, e.op.Amount), nil
+}
+
+// checkNewNetwork catches clients connecting from outside every network the user
+// made transactions from. Users without such history are not checked.
+func checkNewNetwork(ctx context.Context, e *evaluation) (string, error) {
+	ip := net.ParseIP(e.op.ClientIP)
+	if ip == nil {
+		return "", nil
+	}
+
+	locations, err := e.clientLocations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	seen := false
+	for _, location := range locations {
+		known := net.ParseIP(location.IP)
+		if known == nil {
+			continue
+		}
+		if sameNetwork(ip, known) {
+			return "", nil
+		}
+		seen = true
+	}
+	if !seen {
+		return "", nil
+	}
+	return fmt.Sprintf("client address %s is outside the networks used before", e.op.ClientIP), nil
+}
+
+// checkNewCountry catches clients in a country the user never made transactions
+// from. Users without such history are not checked.
+func checkNewCountry(ctx context.Context, e *evaluation) (string, error) {
+	if e.op.ClientCountry == "" {
+		return "", nil
+	}
+
+	locations, err := e.clientLocations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	seen := false
+	for _, location := range locations {
+		if location.Country == "" {
+			continue
+		}
+		if location.Country == e.op.ClientCountry {
+			return "", nil
+		}
+		seen = true
+	}
+	if !seen {
+		return "", nil
+	}
+	return fmt.Sprintf("client country %s was not used before", e.op.ClientCountry), nil
+}
+
+// sameNetwork reports whether two addresses share a /24 IPv4 or /48 IPv6
+// network, which a client keeps while its provider reassigns the address
+func sameNetwork(a, b net.IP) bool {
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(24, 32)
+		return a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+	mask := net.CIDRMask(48, 128)
+	return a.Mask(mask).Equal(b.Mask(mask))
+}
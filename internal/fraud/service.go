@@ -0,0 +1,243 @@
+// Package fraud screens outgoing transfers and withdrawals with configurable
+// rules before they are executed. Depending on the rules a transaction goes
+// through, goes through flagged, waits for an administrator or is rejected.
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityFraudCheck = "fraud_check"
+
+// ErrRejected is returned for transactions rejected by a fraud rule
+var ErrRejected = errors.New("transaction declined by fraud checks")
+
+// HoldError is returned for transactions held for a review by an administrator
+type HoldError struct {
+	CheckID int64
+}
+
+func (e *HoldError) Error() string {
+	return fmt.Sprintf("transaction held for review as fraud check %d", e.CheckID)
+}
+
+// Service evaluates transactions against the fraud rules and manages the review
+// queue of held transactions
+type Service struct {
+	cfg       *config.FraudConfig
+	repo      *repository.FraudRepository
+	auditRepo *repository.AuditRepository
+	rules     []rule
+	logger    *logrus.Logger
+}
+
+// NewService creates a new fraud Service with the rules enabled in the configuration
+func NewService(
+	cfg *config.FraudConfig,
+	repo *repository.FraudRepository,
+	auditRepo *repository.AuditRepository,
+	logger *logrus.Logger,
+) *Service {
+	return &Service{
+		cfg:       cfg,
+		repo:      repo,
+		auditRepo: auditRepo,
+		rules:     newRules(cfg, logger),
+		logger:    logger,
+	}
+}
+
+// Screen evaluates a transaction before it is executed, with the client address and
+// country the caller put on the operation. Held and rejected transactions are recorded
+// at once and reported as a HoldError or ErrRejected. For transactions allowed to
+// proceed the check is returned to be recorded with them by RecordTx; it is nil
+// when fraud detection is disabled.
+func (s *Service) Screen(ctx context.Context, op *models.FraudOperation) (*models.FraudCheck, error) {
+	if !s.cfg.Enabled {
+		return nil, nil
+	}
+	check, err := s.evaluate(ctx, op, time.Now())
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to evaluate fraud rules")
+		return nil, errors.New("internal server error")
+	}
+	metrics.FraudChecks.Inc(op.Type, string(check.Decision))
+
+	logger := s.logger.WithFields(logrus.Fields{
+		"user_id":   op.UserID,
+		"type":      op.Type,
+		"amount":    op.Amount,
+		"rule_hits": check.RuleHits,
+	})
+	switch check.Decision {
+	case models.FraudDecisionAllow:
+		check.Status = models.FraudCheckStatusCompleted
+		return check, nil
+	case models.FraudDecisionFlag:
+		logger.Warn("Transaction flagged by fraud rules")
+		check.Status = models.FraudCheckStatusCompleted
+		return check, nil
+	case models.FraudDecisionHold:
+		check.Status = models.FraudCheckStatusHeld
+	default:
+		check.Status = models.FraudCheckStatusRejected
+	}
+
+	if err := s.repo.Create(ctx, check); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	logger.WithField("check_id", check.ID).Warnf("Transaction %s by fraud rules", check.Status)
+
+	if check.Status == models.FraudCheckStatusHeld {
+		return nil, &HoldError{CheckID: check.ID}
+	}
+	return nil, ErrRejected
+}
+
+// evaluate runs every enabled rule. The most severe decision of the rules that
+// matched becomes the decision of the check.
+func (s *Service) evaluate(ctx context.Context, op *models.FraudOperation, now time.Time) (*models.FraudCheck, error) {
+	check := &models.FraudCheck{
+		UserID:        op.UserID,
+		Type:          op.Type,
+		FromAccountID: op.FromAccountID,
+		Amount:        op.Amount,
+		Currency:      op.Currency,
+		ClientIP:      op.ClientIP,
+		ClientCountry: op.ClientCountry,
+		Decision:      models.FraudDecisionAllow,
+		RuleHits:      []models.FraudRuleHit{},
+	}
+	if op.ToAccountID != 0 {
+		toAccountID := op.ToAccountID
+		check.ToAccountID = &toAccountID
+	}
+
+	e := &evaluation{op: op, now: now, repo: s.repo, cfg: s.cfg}
+	for _, r := range s.rules {
+		reason, err := r.check(ctx, e)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.name, err)
+		}
+		if reason == "" {
+			continue
+		}
+
+		check.RuleHits = append(check.RuleHits, models.FraudRuleHit{Rule: r.name, Decision: r.decision, Reason: reason})
+		if r.decision.Severity() > check.Decision.Severity() {
+			check.Decision = r.decision
+		}
+	}
+
+	return check, nil
+}
+
+// RecordTx stores the check of an executed transaction within its database
+// transaction. A nil check is ignored.
+func (s *Service) RecordTx(ctx context.Context, tx repository.Tx, check *models.FraudCheck, transactionID int64) error {
+	if check == nil {
+		return nil
+	}
+	check.TransactionID = &transactionID
+	return s.repo.CreateTx(ctx, tx, check)
+}
+
+// ListChecks returns fraud checks, optionally filtered by status and decision
+func (s *Service) ListChecks(ctx context.Context, status models.FraudCheckStatus, decision models.FraudDecision) ([]*models.FraudCheck, error) {
+	checks, err := s.repo.List(ctx, status, decision)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if checks == nil {
+		checks = []*models.FraudCheck{}
+	}
+	return checks, nil
+}
+
+// GetCheck returns a fraud check
+func (s *Service) GetCheck(ctx context.Context, checkID int64) (*models.FraudCheck, error) {
+	return s.repo.GetByID(ctx, checkID)
+}
+
+// LockHeldTx locks a held check for its review. Administrators cannot review
+// their own transactions.
+func (s *Service) LockHeldTx(ctx context.Context, tx repository.Tx, adminID, checkID int64) (*models.FraudCheck, error) {
+	check, err := s.repo.GetByIDForUpdate(ctx, tx, checkID)
+	if err != nil {
+		return nil, err
+	}
+	if check.Status != models.FraudCheckStatusHeld {
+		return nil, errors.New("fraud check is not held")
+	}
+	if check.UserID == adminID {
+		return nil, errors.New("fraud check must be reviewed by a different administrator")
+	}
+	return check, nil
+}
+
+// ReleaseTx marks a held check as released with the transaction executed for it
+func (s *Service) ReleaseTx(ctx context.Context, tx repository.Tx, adminID int64, check *models.FraudCheck, transactionID int64, comment string) error {
+	check.TransactionID = &transactionID
+	return s.reviewTx(ctx, tx, adminID, check, models.FraudCheckStatusReleased, models.AuditActionFraudCheckReleased, comment)
+}
+
+// Reject closes a held check without executing its transaction
+func (s *Service) Reject(ctx context.Context, adminID, checkID int64, comment string) (*models.FraudCheck, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	check, err := s.LockHeldTx(ctx, tx, adminID, checkID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reviewTx(ctx, tx, adminID, check, models.FraudCheckStatusRejected, models.AuditActionFraudCheckRejected, comment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return check, nil
+}
+
+func (s *Service) reviewTx(ctx context.Context, tx repository.Tx, adminID int64, check *models.FraudCheck, status models.FraudCheckStatus, action models.AuditAction, comment string) error {
+	now := time.Now()
+	check.Status = status
+	check.ReviewedBy = &adminID
+	check.ReviewComment = comment
+	check.ReviewedAt = &now
+
+	if err := s.repo.UpdateReviewTx(ctx, tx, check); err != nil {
+		return errors.New("internal server error")
+	}
+
+	details, err := json.Marshal(check)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to marshal audit details")
+	}
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: auditEntityFraudCheck,
+		EntityID:   check.ID,
+		Details:    details,
+	}); err != nil {
+		return errors.New("internal server error")
+	}
+
+	return nil
+}
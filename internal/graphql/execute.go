@@ -0,0 +1,669 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// maxFields bounds the fields a request selects once its fragments are
+// expanded, so a small document cannot make the server resolve a huge response
+const maxFields = 1000
+
+// Request is a GraphQL request as posted to the endpoint
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request. Data is left out when the request
+// failed before it was executed.
+type Response struct {
+	Data   *Result  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it occurred on
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Result is an object of the response, which keeps the order of its fields
+type Result struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newResult() *Result {
+	return &Result{values: make(map[string]interface{})}
+}
+
+func (r *Result) set(key string, value interface{}) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+// Get returns the value of a field of the result
+func (r *Result) Get(key string) interface{} {
+	return r.values[key]
+}
+
+// MarshalJSON writes the fields in the order they were selected
+func (r *Result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// OperationType returns whether the operation a request would execute is a
+// query or a mutation
+func OperationType(req *Request) (string, error) {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return "", err
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		return "", err
+	}
+	return op.Type, nil
+}
+
+// Execute parses, validates and executes a request. The fields of an object
+// are resolved in the order they are selected, and each field is resolved for
+// all the objects of its level of the response at once: a Batch field loads
+// its values for every parent in a single call, as a dataloader would.
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	root := s.Query
+	if op.Type == "mutation" {
+		if s.Mutation == nil {
+			return &Response{Errors: []*Error{{Message: "mutations are not supported"}}}
+		}
+		root = s.Mutation
+	}
+
+	v := &validator{doc: doc, vars: make(map[string]Type)}
+	for _, def := range op.Variables {
+		t, err := inputType(def.Type)
+		if err != nil {
+			v.errorf("variable $%s: %s", def.Name, err)
+			continue
+		}
+		v.vars[def.Name] = t
+	}
+	v.selections(root, op.Selections, map[string]bool{})
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+
+	e := &executor{doc: doc, vars: make(map[string]interface{})}
+	for _, def := range op.Variables {
+		value, provided := req.Variables[def.Name]
+		if !provided {
+			value = def.Default
+		}
+		coerced, err := coerce(v.vars[def.Name], value, nil)
+		if err != nil {
+			return &Response{Errors: []*Error{{Message: fmt.Sprintf("variable $%s: %s", def.Name, err)}}}
+		}
+		if provided || def.Default != nil {
+			e.vars[def.Name] = coerced
+		}
+	}
+
+	data := newResult()
+	e.executeFields(ctx, root, []*node{{out: data}}, op.Selections)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// inputType resolves the type of a variable definition
+func inputType(ref *TypeRef) (Type, error) {
+	var t Type
+	if ref.Elem != nil {
+		elem, err := inputType(ref.Elem)
+		if err != nil {
+			return nil, err
+		}
+		t = &List{Of: elem}
+	} else {
+		scalar, ok := builtinScalars[ref.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input type %s", ref.Name)
+		}
+		t = scalar
+	}
+	if ref.NonNull {
+		t = &NonNull{Of: t}
+	}
+	return t, nil
+}
+
+// coerce converts a literal or variable value to an input type. Variables in
+// literals are looked up in vars; a variable left out is missing, which only a
+// nullable type accepts.
+func coerce(t Type, value interface{}, vars map[string]interface{}) (interface{}, error) {
+	if name, ok := value.(Variable); ok {
+		value = vars[string(name)]
+	}
+	if nonNull, ok := t.(*NonNull); ok {
+		if value == nil {
+			return nil, fmt.Errorf("expected a non-null %s", nonNull.Of)
+		}
+		t = nonNull.Of
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := value.([]interface{})
+		if !ok {
+			// A single value stands for a list of one
+			item, err := coerce(t.Of, value, vars)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerce(t.Of, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	case *Scalar:
+		return t.Parse(value)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// validator checks a document against the schema before anything is resolved
+type validator struct {
+	doc    *Document
+	vars   map[string]Type
+	errors []*Error
+	fields int
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// selections validates a selection set on an object; spreading tracks the
+// fragments being expanded, to refuse cycles
+func (v *validator) selections(obj *Object, selections []Selection, spreading map[string]bool) {
+	for _, selection := range selections {
+		if v.fields > maxFields {
+			return
+		}
+		switch sel := selection.(type) {
+		case *Field:
+			v.fields++
+			if v.fields > maxFields {
+				v.errorf("query selects more than %d fields", maxFields)
+				return
+			}
+			v.directives(sel.Directives)
+			v.field(obj, sel, spreading)
+		case *FragmentSpread:
+			v.directives(sel.Directives)
+			fragment, ok := v.doc.Fragments[sel.Name]
+			if !ok {
+				v.errorf("unknown fragment %q", sel.Name)
+				continue
+			}
+			if spreading[sel.Name] {
+				v.errorf("fragment %q spreads itself", sel.Name)
+				continue
+			}
+			if fragment.TypeCondition != obj.Name {
+				v.errorf("fragment %q on %s cannot be spread on %s", sel.Name, fragment.TypeCondition, obj.Name)
+				continue
+			}
+			spreading[sel.Name] = true
+			v.selections(obj, fragment.Selections, spreading)
+			delete(spreading, sel.Name)
+		case *InlineFragment:
+			v.directives(sel.Directives)
+			if sel.TypeCondition != "" && sel.TypeCondition != obj.Name {
+				v.errorf("inline fragment on %s cannot be spread on %s", sel.TypeCondition, obj.Name)
+				continue
+			}
+			v.selections(obj, sel.Selections, spreading)
+		}
+	}
+}
+
+func (v *validator) field(obj *Object, field *Field, spreading map[string]bool) {
+	if field.Name == "__typename" {
+		if len(field.Selections) > 0 {
+			v.errorf("field __typename of %s has no fields to select", obj.Name)
+		}
+		return
+	}
+	def := obj.Field(field.Name)
+	if def == nil {
+		v.errorf("cannot query field %q on type %s", field.Name, obj.Name)
+		return
+	}
+	v.arguments(fmt.Sprintf("field %s.%s", obj.Name, def.Name), def.Args, field.Arguments)
+
+	switch t := namedType(def.Type).(type) {
+	case *Object:
+		if len(field.Selections) == 0 {
+			v.errorf("field %s.%s of type %s must have a selection of fields", obj.Name, def.Name, def.Type)
+			return
+		}
+		v.selections(t, field.Selections, spreading)
+	default:
+		if len(field.Selections) > 0 {
+			v.errorf("field %s.%s of type %s has no fields to select", obj.Name, def.Name, def.Type)
+		}
+	}
+}
+
+func (v *validator) directives(directives []*Directive) {
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			v.errorf("unknown directive @%s", d.Name)
+			continue
+		}
+		v.arguments("directive @"+d.Name, []*ArgDef{{Name: "if", Type: &NonNull{Of: Boolean}}}, d.Arguments)
+	}
+}
+
+// arguments checks that the arguments given are defined, that the required
+// ones are given, and that their literals and variables fit their types
+func (v *validator) arguments(where string, defs []*ArgDef, args []*Argument) {
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		var def *ArgDef
+		for _, d := range defs {
+			if d.Name == arg.Name {
+				def = d
+			}
+		}
+		if def == nil {
+			v.errorf("unknown argument %q of %s", arg.Name, where)
+			continue
+		}
+		if given[arg.Name] {
+			v.errorf("argument %q of %s is given more than once", arg.Name, where)
+			continue
+		}
+		given[arg.Name] = true
+		v.value(fmt.Sprintf("argument %q of %s", arg.Name, where), def.Type, arg.Value)
+	}
+	for _, def := range defs {
+		if _, required := def.Type.(*NonNull); required && def.Default == nil && !given[def.Name] {
+			v.errorf("argument %q of %s is required", def.Name, where)
+		}
+	}
+}
+
+// value checks a literal against an input type; variables must be defined and
+// of a type that fits where they are used
+func (v *validator) value(where string, t Type, value interface{}) {
+	switch val := value.(type) {
+	case Variable:
+		varType, ok := v.vars[string(val)]
+		if !ok {
+			v.errorf("%s: variable $%s is not defined", where, val)
+			return
+		}
+		if !fits(varType, t) {
+			v.errorf("%s: variable $%s of type %s cannot be used as %s", where, val, varType, t)
+		}
+		return
+	case []interface{}:
+		inner := t
+		if nonNull, ok := inner.(*NonNull); ok {
+			inner = nonNull.Of
+		}
+		if list, ok := inner.(*List); ok {
+			for _, item := range val {
+				v.value(where, list.Of, item)
+			}
+			return
+		}
+	}
+	if _, err := coerce(t, value, nil); err != nil {
+		v.errorf("%s: %s", where, err)
+	}
+}
+
+// fits tells whether a variable of type from may be passed where to is expected
+func fits(from, to Type) bool {
+	if nonNull, ok := to.(*NonNull); ok {
+		from, ok := from.(*NonNull)
+		return ok && fits(from.Of, nonNull.Of)
+	}
+	if nonNull, ok := from.(*NonNull); ok {
+		from = nonNull.Of
+	}
+	if list, ok := to.(*List); ok {
+		from, ok := from.(*List)
+		return ok && fits(from.Of, list.Of)
+	}
+	return from == to
+}
+
+// namedType strips the list and non-null wrappers of a type
+func namedType(t Type) Type {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.Of
+		case *NonNull:
+			t = wrapper.Of
+		default:
+			return t
+		}
+	}
+}
+
+type executor struct {
+	doc    *Document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+// node is an object of the response being resolved, with its source value
+type node struct {
+	source interface{}
+	out    *Result
+	path   []interface{}
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+// collectedField is a response key with the fields selected under it, merged
+// across fragments
+type collectedField struct {
+	key    string
+	fields []*Field
+}
+
+func (e *executor) collectFields(obj *Object, selections []Selection, fields []*collectedField, visited map[string]bool) []*collectedField {
+	for _, selection := range selections {
+		switch sel := selection.(type) {
+		case *Field:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			merged := false
+			for _, f := range fields {
+				if f.key == sel.Key() {
+					f.fields = append(f.fields, sel)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				fields = append(fields, &collectedField{key: sel.Key(), fields: []*Field{sel}})
+			}
+		case *FragmentSpread:
+			if visited[sel.Name] || !e.included(sel.Directives) {
+				continue
+			}
+			visited[sel.Name] = true
+			fields = e.collectFields(obj, e.doc.Fragments[sel.Name].Selections, fields, visited)
+		case *InlineFragment:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			fields = e.collectFields(obj, sel.Selections, fields, visited)
+		}
+	}
+	return fields
+}
+
+// included evaluates the @skip and @include directives of a selection
+func (e *executor) included(directives []*Directive) bool {
+	for _, d := range directives {
+		if len(d.Arguments) == 0 {
+			continue
+		}
+		value, _ := coerce(Boolean, d.Arguments[0].Value, e.vars)
+		condition, _ := value.(bool)
+		if d.Name == "skip" && condition || d.Name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// executeFields resolves the selected fields of the objects of a level
+func (e *executor) executeFields(ctx context.Context, obj *Object, nodes []*node, selections []Selection) {
+	for _, cf := range e.collectFields(obj, selections, nil, map[string]bool{}) {
+		field := cf.fields[0]
+		if field.Name == "__typename" {
+			for _, n := range nodes {
+				n.out.set(cf.key, obj.Name)
+			}
+			continue
+		}
+		def := obj.Field(field.Name)
+
+		args := make(Args)
+		var argErr error
+		for _, argDef := range def.Args {
+			var value interface{} = argDef.Default
+			given := argDef.Default != nil
+			for _, arg := range field.Arguments {
+				if arg.Name != argDef.Name {
+					continue
+				}
+				value, given = arg.Value, true
+				if name, ok := arg.Value.(Variable); ok {
+					_, given = e.vars[string(name)]
+					if !given {
+						value = argDef.Default
+						given = argDef.Default != nil
+					}
+				}
+			}
+			coerced, err := coerce(argDef.Type, value, e.vars)
+			if err != nil {
+				argErr = fmt.Errorf("argument %q: %w", argDef.Name, err)
+				break
+			}
+			if given {
+				args[argDef.Name] = coerced
+			}
+		}
+
+		values := make([]interface{}, len(nodes))
+		paths := make([][]interface{}, len(nodes))
+		failed := make([]bool, len(nodes))
+		for i, n := range nodes {
+			paths[i] = appendPath(n.path, cf.key)
+		}
+		switch {
+		case argErr != nil:
+			for i := range nodes {
+				failed[i] = true
+			}
+			e.fail(paths[0], argErr)
+		case def.Batch != nil:
+			sources := make([]interface{}, len(nodes))
+			for i, n := range nodes {
+				sources[i] = n.source
+			}
+			results, err := def.Batch(ctx, sources, args)
+			if err == nil && len(results) != len(sources) {
+				err = fmt.Errorf("internal server error")
+			}
+			if err != nil {
+				for i := range nodes {
+					failed[i] = true
+				}
+				e.fail(paths[0], err)
+				break
+			}
+			copy(values, results)
+		default:
+			for i, n := range nodes {
+				var err error
+				if def.Resolve != nil {
+					values[i], err = def.Resolve(ctx, n.source, args)
+				} else {
+					values[i], err = defaultResolve(n.source, def.Name)
+				}
+				if err != nil {
+					failed[i] = true
+					e.fail(paths[i], err)
+				}
+			}
+		}
+		for i := range values {
+			if failed[i] {
+				values[i] = nil
+			}
+		}
+
+		var subSelections []Selection
+		for _, f := range cf.fields {
+			subSelections = append(subSelections, f.Selections...)
+		}
+		completed := e.complete(ctx, def.Type, values, paths, subSelections)
+		for i, n := range nodes {
+			n.out.set(cf.key, completed[i])
+		}
+	}
+}
+
+// complete turns the resolved values of a field into response values: scalars
+// are serialized and the objects of all the values, list items included, are
+// resolved together as the next level
+func (e *executor) complete(ctx context.Context, t Type, values []interface{}, paths [][]interface{}, selections []Selection) []interface{} {
+	if nonNull, ok := t.(*NonNull); ok {
+		t = nonNull.Of
+	}
+	completed := make([]interface{}, len(values))
+
+	switch t := t.(type) {
+	case *Scalar:
+		for i, value := range values {
+			value = deref(value)
+			if value == nil {
+				continue
+			}
+			serialized, err := t.Serialize(value)
+			if err != nil {
+				e.fail(paths[i], err)
+				continue
+			}
+			completed[i] = serialized
+		}
+	case *List:
+		var items []interface{}
+		var itemPaths [][]interface{}
+		counts := make([]int, len(values))
+		for i, value := range values {
+			value = deref(value)
+			if value == nil {
+				counts[i] = -1
+				continue
+			}
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				e.fail(paths[i], fmt.Errorf("expected a list, got %T", value))
+				counts[i] = -1
+				continue
+			}
+			counts[i] = rv.Len()
+			for j := 0; j < rv.Len(); j++ {
+				items = append(items, rv.Index(j).Interface())
+				itemPaths = append(itemPaths, appendPath(paths[i], j))
+			}
+		}
+		itemValues := e.complete(ctx, t.Of, items, itemPaths, selections)
+		offset := 0
+		for i, count := range counts {
+			if count < 0 {
+				continue
+			}
+			completed[i] = itemValues[offset : offset+count]
+			offset += count
+		}
+	case *Object:
+		var nodes []*node
+		for i, value := range values {
+			if deref(value) == nil {
+				continue
+			}
+			result := newResult()
+			completed[i] = result
+			nodes = append(nodes, &node{source: value, out: result, path: paths[i]})
+		}
+		if len(nodes) > 0 {
+			e.executeFields(ctx, t, nodes, selections)
+		}
+	}
+	return completed
+}
+
+// deref follows pointers, returning nil for a nil pointer, slice or map
+func deref(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	return rv.Interface()
+}
+
+func appendPath(path []interface{}, key interface{}) []interface{} {
+	out := make([]interface{}, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, key)
+}
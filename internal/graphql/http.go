@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRequestSize bounds the body of a GraphQL request
+const maxRequestSize = 1 << 20
+
+// DecodeRequest reads a GraphQL request from the JSON body of an HTTP request.
+// Numbers in variables are kept as json.Number, so integers keep their precision.
+func DecodeRequest(r *http.Request) (*Request, error) {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize))
+	dec.UseNumber()
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if req.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	return &req, nil
+}
+
+// GuardMutations applies guard to the requests executing a mutation, so the
+// endpoint asks for what the REST routes moving money ask for while queries
+// pass as they are. The body is read to find the operation and restored for
+// the guard and the handler.
+func GuardMutations(guard func(http.HandlerFunc) http.HandlerFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		guarded := guard(next)
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var req Request
+			if json.Unmarshal(body, &req) == nil {
+				if opType, err := OperationType(&req); err == nil && opType == "mutation" {
+					guarded(w, r)
+					return
+				}
+			}
+			// Requests that do not parse fail in the handler
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is a *Scalar, *Object, *List or *NonNull
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type. Serialize turns a resolved Go value into its JSON
+// value; Parse coerces an argument or variable value.
+type Scalar struct {
+	Name      string
+	Serialize func(v interface{}) (interface{}, error)
+	Parse     func(v interface{}) (interface{}, error)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// List is a list of values of a type
+type List struct {
+	Of Type
+}
+
+func (l *List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull is a type whose values are never null. Output fields are left
+// nullable, so a failed field nulls only itself; NonNull marks required
+// arguments.
+type NonNull struct {
+	Of Type
+}
+
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// Object is a type with fields
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*FieldDef
+}
+
+func (o *Object) String() string { return o.Name }
+
+// Field returns the field of the object with the name
+func (o *Object) Field(name string) *FieldDef {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// FieldDef defines a field of an object. A field with neither Resolve nor Batch
+// reads the struct field with the same json tag, or the map entry, of its
+// source.
+type FieldDef struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*ArgDef
+	// Resolve computes the field of one object
+	Resolve func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+	// Batch computes the field of all the objects of the level of the response
+	// at once, result i being the field of source i, so nested lists load in one
+	// call per field rather than one per object
+	Batch func(ctx context.Context, sources []interface{}, args Args) ([]interface{}, error)
+}
+
+// ArgDef defines an argument of a field
+type ArgDef struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     interface{}
+}
+
+// Args holds the coerced arguments of a field. Arguments left out and without
+// a default are missing.
+type Args map[string]interface{}
+
+// String returns a String or ID argument
+func (a Args) String(name string) (string, bool) {
+	v, ok := a[name].(string)
+	return v, ok
+}
+
+// Int returns an Int argument
+func (a Args) Int(name string) (int, bool) {
+	v, ok := a[name].(int64)
+	return int(v), ok
+}
+
+// Float returns a Float argument
+func (a Args) Float(name string) (float64, bool) {
+	v, ok := a[name].(float64)
+	return v, ok
+}
+
+// Bool returns a Boolean argument
+func (a Args) Bool(name string) (bool, bool) {
+	v, ok := a[name].(bool)
+	return v, ok
+}
+
+// ID returns an ID argument holding a numeric ID
+func (a Args) ID(name string) (int64, error) {
+	v, ok := a[name].(string)
+	if !ok {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return id, nil
+}
+
+// Schema is the root query and mutation types of an API
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+}
+
+// Built-in scalars
+var (
+	Int = &Scalar{
+		Name: "Int",
+		Serialize: func(v interface{}) (interface{}, error) {
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return rv.Int(), nil
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return rv.Uint(), nil
+			}
+			return nil, fmt.Errorf("cannot represent %T as Int", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			n, ok := integer(v)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("expected a 32-bit integer, got %s", describe(v))
+			}
+			return n, nil
+		},
+	}
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v interface{}) (interface{}, error) {
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Float32, reflect.Float64:
+				return rv.Float(), nil
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return float64(rv.Int()), nil
+			}
+			return nil, fmt.Errorf("cannot represent %T as Float", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			switch v := v.(type) {
+			case float64:
+				return v, nil
+			case int64:
+				return float64(v), nil
+			case json.Number:
+				if f, err := v.Float64(); err == nil {
+					return f, nil
+				}
+			}
+			return nil, fmt.Errorf("expected a number, got %s", describe(v))
+		},
+	}
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v interface{}) (interface{}, error) {
+			switch v := v.(type) {
+			case time.Time:
+				return v.Format(time.RFC3339), nil
+			case fmt.Stringer:
+				return v.String(), nil
+			}
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+				return rv.String(), nil
+			}
+			return nil, fmt.Errorf("cannot represent %T as String", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("expected a string, got %s", describe(v))
+		},
+	}
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("cannot represent %T as Boolean", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("expected a boolean, got %s", describe(v))
+		},
+	}
+	// ID is serialized as a string; integers are accepted as input
+	ID = &Scalar{
+		Name: "ID",
+		Serialize: func(v interface{}) (interface{}, error) {
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.String:
+				return rv.String(), nil
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return strconv.FormatInt(rv.Int(), 10), nil
+			}
+			return nil, fmt.Errorf("cannot represent %T as ID", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			if n, ok := integer(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("expected an ID, got %s", describe(v))
+		},
+	}
+)
+
+var builtinScalars = map[string]*Scalar{"Int": Int, "Float": Float, "String": String, "Boolean": Boolean, "ID": ID}
+
+// integer returns an integral literal or JSON number
+func integer(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case EnumValue:
+		return string(v)
+	case Variable:
+		return "$" + string(v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(v)
+}
+
+// SDL describes the schema in the GraphQL schema definition language
+func (s *Schema) SDL() string {
+	var objects []*Object
+	seen := make(map[*Object]bool)
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := t.(type) {
+		case *List:
+			walk(t.Of)
+		case *NonNull:
+			walk(t.Of)
+		case *Object:
+			if seen[t] {
+				return
+			}
+			seen[t] = true
+			objects = append(objects, t)
+			for _, f := range t.Fields {
+				walk(f.Type)
+			}
+		}
+	}
+	// The root types come first, the others follow by name
+	roots := []*Object{s.Query}
+	if s.Mutation != nil {
+		roots = append(roots, s.Mutation)
+	}
+	for _, root := range roots {
+		seen[root] = true
+		objects = append(objects, root)
+	}
+	for _, root := range roots {
+		for _, f := range root.Fields {
+			walk(f.Type)
+		}
+	}
+	named := objects[len(roots):]
+	sort.Slice(named, func(i, j int) bool { return named[i].Name < named[j].Name })
+
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n")
+	if s.Mutation != nil {
+		b.WriteString("  mutation: " + s.Mutation.Name + "\n")
+	}
+	b.WriteString("}\n")
+	for _, o := range objects {
+		b.WriteString("\n")
+		writeDescription(&b, "", o.Description)
+		b.WriteString("type " + o.Name + " {\n")
+		for _, f := range o.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = a.Name + ": " + a.Type.String()
+					if a.Default != nil {
+						def, _ := json.Marshal(a.Default)
+						args[i] += " = " + string(def)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, description string) {
+	if description != "" {
+		b.WriteString(indent + strconv.Quote(description) + "\n")
+	}
+}
+
+// jsonFields maps the json tags of struct types to their field indexes, for the
+// default resolution of fields
+var jsonFields sync.Map // reflect.Type -> map[string][]int
+
+func fieldByTag(t reflect.Type, name string) ([]int, bool) {
+	cached, ok := jsonFields.Load(t)
+	if !ok {
+		fields := make(map[string][]int)
+		for _, f := range reflect.VisibleFields(t) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			fields[tag] = f.Index
+		}
+		cached, _ = jsonFields.LoadOrStore(t, fields)
+	}
+	index, ok := cached.(map[string][]int)[name]
+	return index, ok
+}
+
+// defaultResolve reads the field with the json tag name of a struct, or its
+// snake_case form for a camelCase name, or the entry of a map
+func defaultResolve(source interface{}, name string) (interface{}, error) {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		index, ok := fieldByTag(v.Type(), name)
+		if !ok {
+			index, ok = fieldByTag(v.Type(), snakeCase(name))
+		}
+		if !ok {
+			return nil, fmt.Errorf("no %s field in %s", name, v.Type())
+		}
+		return v.FieldByIndex(index).Interface(), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			if value := v.MapIndex(reflect.ValueOf(name)); value.IsValid() {
+				return value.Interface(), nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot read %s from %T", name, source)
+}
+
+// snakeCase turns fromAccountId into from_account_id
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('_')
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
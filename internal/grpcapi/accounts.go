@@ -0,0 +1,102 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	bankv1 "github.com/Abigotado/abi_banking/api/bank/v1"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type accountServer struct {
+	bankv1.UnimplementedAccountServiceServer
+	accountService *service.AccountService
+	logger         *logrus.Logger
+}
+
+// GetAccount returns an account of the caller
+func (s *accountServer) GetAccount(ctx context.Context, req *bankv1.GetAccountRequest) (*bankv1.Account, error) {
+	account, err := s.ownAccount(ctx, req.GetAccountId(), false)
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(account), nil
+}
+
+// ListAccounts returns all accounts of the caller
+func (s *accountServer) ListAccounts(ctx context.Context, req *bankv1.ListAccountsRequest) (*bankv1.ListAccountsResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.accountService.GetUserAccounts(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &bankv1.ListAccountsResponse{Accounts: make([]*bankv1.Account, 0, len(accounts))}
+	for _, account := range accounts {
+		resp.Accounts = append(resp.Accounts, toAccount(account))
+	}
+	return resp, nil
+}
+
+// Transfer moves money from an account of the caller to any other account
+func (s *accountServer) Transfer(ctx context.Context, req *bankv1.TransferRequest) (*bankv1.TransferResponse, error) {
+	transfer := &models.TransferRequest{
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+	}
+	if err := middleware.Validate(transfer); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, err := s.ownAccount(ctx, transfer.FromAccountID, true); err != nil {
+		return nil, err
+	}
+
+	if err := s.accountService.Transfer(ctx, transfer, middleware.GetClientInfoFromContext(ctx)); err != nil {
+		s.logger.WithError(err).Error("Failed to transfer money")
+		return nil, toStatus(err)
+	}
+	return &bankv1.TransferResponse{}, nil
+}
+
+// ownAccount loads an account the caller owns or shares, with operate set one
+// they may move money from, and hides other accounts as not found
+func (s *accountServer) ownAccount(ctx context.Context, accountID int64, operate bool) (*models.Account, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountService.AuthorizeAccount(ctx, userID, accountID, operate)
+	if err != nil {
+		if strings.Contains(err.Error(), "view-only") {
+			return nil, toStatus(err)
+		}
+		return nil, status.Error(codes.NotFound, "account not found")
+	}
+	return account, nil
+}
+
+func toAccount(account *models.Account) *bankv1.Account {
+	return &bankv1.Account{
+		Id:                    account.ID,
+		UserId:                account.UserID,
+		Balance:               account.Balance,
+		Currency:              account.Currency,
+		OverdraftLimit:        account.OverdraftLimit,
+		OverdraftInterestRate: account.OverdraftInterestRate,
+		CreatedAt:             timestamppb.New(account.CreatedAt),
+		UpdatedAt:             timestamppb.New(account.UpdatedAt),
+	}
+}
@@ -0,0 +1,95 @@
+package grpcapi
+
+import (
+	"context"
+
+	bankv1 "github.com/Abigotado/abi_banking/api/bank/v1"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type cardServer struct {
+	bankv1.UnimplementedCardServiceServer
+	cardService *service.CardService
+	logger      *logrus.Logger
+}
+
+// GetCard returns a card of the caller with its number masked
+func (s *cardServer) GetCard(ctx context.Context, req *bankv1.GetCardRequest) (*bankv1.Card, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := s.cardService.GetCard(ctx, userID, req.GetCardId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toCard(card), nil
+}
+
+// ListCards returns all cards of the caller
+func (s *cardServer) ListCards(ctx context.Context, req *bankv1.ListCardsRequest) (*bankv1.ListCardsResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := s.cardService.GetUserCards(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &bankv1.ListCardsResponse{Cards: make([]*bankv1.Card, 0, len(cards))}
+	for _, card := range cards {
+		resp.Cards = append(resp.Cards, toCard(card))
+	}
+	return resp, nil
+}
+
+// BlockCard blocks a card of the caller
+func (s *cardServer) BlockCard(ctx context.Context, req *bankv1.BlockCardRequest) (*bankv1.BlockCardResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cardService.BlockCard(ctx, userID, req.GetCardId()); err != nil {
+		s.logger.WithError(err).Error("Failed to block card")
+		return nil, toStatus(err)
+	}
+	return &bankv1.BlockCardResponse{}, nil
+}
+
+// UnblockCard unblocks a card of the caller
+func (s *cardServer) UnblockCard(ctx context.Context, req *bankv1.UnblockCardRequest) (*bankv1.UnblockCardResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cardService.UnblockCard(ctx, userID, req.GetCardId()); err != nil {
+		s.logger.WithError(err).Error("Failed to unblock card")
+		return nil, toStatus(err)
+	}
+	return &bankv1.UnblockCardResponse{}, nil
+}
+
+func toCard(card *models.Card) *bankv1.Card {
+	masked := card.ToResponse()
+	return &bankv1.Card{
+		Id:           masked.ID,
+		UserId:       masked.UserID,
+		AccountId:    masked.AccountID,
+		MaskedNumber: masked.CardNumber,
+		ExpiryDate:   masked.ExpiryDate,
+		CardType:     masked.CardType,
+		Status:       masked.Status,
+		CreatedAt:    timestamppb.New(masked.CreatedAt),
+		UpdatedAt:    timestamppb.New(masked.UpdatedAt),
+	}
+}
@@ -0,0 +1,110 @@
+package grpcapi
+
+import (
+	"context"
+
+	bankv1 "github.com/Abigotado/abi_banking/api/bank/v1"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type creditServer struct {
+	bankv1.UnimplementedCreditServiceServer
+	creditService *service.CreditService
+	logger        *logrus.Logger
+}
+
+// GetCredit returns a credit of the caller
+func (s *creditServer) GetCredit(ctx context.Context, req *bankv1.GetCreditRequest) (*bankv1.Credit, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	credit, err := s.creditService.GetCreditByID(ctx, req.GetCreditId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if credit.UserID != userID {
+		return nil, status.Error(codes.NotFound, "credit not found")
+	}
+	return toCredit(credit), nil
+}
+
+// ListCredits returns all credits of the caller
+func (s *creditServer) ListCredits(ctx context.Context, req *bankv1.ListCreditsRequest) (*bankv1.ListCreditsResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	credits, err := s.creditService.GetCreditsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user credits")
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &bankv1.ListCreditsResponse{Credits: make([]*bankv1.Credit, 0, len(credits))}
+	for _, credit := range credits {
+		resp.Credits = append(resp.Credits, toCredit(credit))
+	}
+	return resp, nil
+}
+
+// GetPaymentSchedule returns the installments of a credit of the caller
+func (s *creditServer) GetPaymentSchedule(ctx context.Context, req *bankv1.GetPaymentScheduleRequest) (*bankv1.GetPaymentScheduleResponse, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.creditService.GetPaymentSchedule(ctx, userID, req.GetCreditId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &bankv1.GetPaymentScheduleResponse{Payments: make([]*bankv1.Payment, 0, len(schedule))}
+	for _, payment := range schedule {
+		resp.Payments = append(resp.Payments, toPayment(payment))
+	}
+	return resp, nil
+}
+
+func toCredit(credit *models.Credit) *bankv1.Credit {
+	return &bankv1.Credit{
+		Id:              credit.ID,
+		UserId:          credit.UserID,
+		AccountId:       credit.AccountID,
+		Amount:          credit.Amount,
+		RemainingAmount: credit.RemainingAmount,
+		InterestRate:    credit.InterestRate,
+		TermMonths:      int32(credit.TermMonths),
+		ScheduleType:    string(credit.ScheduleType),
+		Status:          credit.Status,
+		CreatedAt:       timestamppb.New(credit.CreatedAt),
+		UpdatedAt:       timestamppb.New(credit.UpdatedAt),
+	}
+}
+
+func toPayment(payment *models.PaymentSchedule) *bankv1.Payment {
+	p := &bankv1.Payment{
+		Id:         payment.ID,
+		CreditId:   payment.CreditID,
+		Amount:     payment.Amount,
+		Principal:  payment.Principal,
+		Interest:   payment.Interest,
+		DueDate:    timestamppb.New(payment.DueDate),
+		Status:     string(payment.Status),
+		Penalty:    payment.Penalty,
+		PaidAmount: payment.PaidAmount,
+		Remaining:  payment.Remaining,
+	}
+	if payment.PaidAt != nil {
+		p.PaidAt = timestamppb.New(*payment.PaidAt)
+	}
+	return p
+}
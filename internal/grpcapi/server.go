@@ -0,0 +1,170 @@
+// Package grpcapi serves the core banking operations over gRPC for internal
+// services that prefer protobuf to JSON over HTTP. It shares the service layer
+// with the REST API and authenticates callers with the same JWTs.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	bankv1 "github.com/Abigotado/abi_banking/api/bank/v1"
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewServer creates a gRPC server with the account, credit and card services
+// registered behind the recovery and authentication interceptors. Callers
+// authenticate with the JWTs accepted by the REST API, signed with jwtSecret and
+// issued for a session still active in sessions.
+func NewServer(
+	jwtSecret *secrets.Secret,
+	sessions middleware.SessionValidator,
+	accountService *service.AccountService,
+	creditService *service.CreditService,
+	cardService *service.CardService,
+	logger *logrus.Logger,
+) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		recoveryInterceptor(logger),
+		loggingInterceptor(logger),
+		authInterceptor(jwtSecret, sessions),
+	))
+
+	bankv1.RegisterAccountServiceServer(server, &accountServer{accountService: accountService, logger: logger})
+	bankv1.RegisterCreditServiceServer(server, &creditServer{creditService: creditService, logger: logger})
+	bankv1.RegisterCardServiceServer(server, &cardServer{cardService: cardService, logger: logger})
+
+	return server
+}
+
+// authInterceptor authenticates the caller from the "authorization: Bearer <jwt>"
+// metadata and stores the user in the context like the REST Auth middleware does
+func authInterceptor(jwtSecret *secrets.Secret, sessions middleware.SessionValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+		}
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+		}
+
+		parts := strings.Split(values[0], " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+		}
+
+		claims, err := middleware.ParseToken(ctx, parts[1], jwtSecret)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		active, err := sessions.ValidateSession(ctx, claims.ID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "internal server error")
+		}
+		if !active {
+			return nil, status.Error(codes.Unauthenticated, "session has been revoked or has expired")
+		}
+
+		// Internal services forward the address and country of the end user they act
+		// for, which the fraud rules check
+		ctx = middleware.WithClaims(ctx, claims)
+		ctx = middleware.WithClientInfo(ctx, firstValue(md, "x-client-ip"), firstValue(md, "x-client-country"))
+		return handler(ctx, req)
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// loggingInterceptor logs every call with its status code and duration
+func loggingInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		logger.WithFields(logrus.Fields{
+			"method":   info.FullMethod,
+			"code":     status.Code(err).String(),
+			"duration": time.Since(start),
+		}).Info("gRPC request")
+		return resp, err
+	}
+}
+
+// recoveryInterceptor turns a panic in a handler into an internal error instead
+// of taking the process down
+func recoveryInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.WithFields(logrus.Fields{
+					"method": info.FullMethod,
+					"stack":  string(debug.Stack()),
+				}).Errorf("gRPC handler panicked: %v", p)
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// userID returns the caller authenticated by authInterceptor
+func userID(ctx context.Context) (int64, error) {
+	id, ok := authctx.UserIDFromContext(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return id, nil
+}
+
+// toStatus maps a service error onto a gRPC status. Services report failures as
+// plain errors, so the mapping goes by their wording like the REST handlers do.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var held *fraud.HoldError
+	switch {
+	case err == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case err == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case errors.As(err, &held):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, fraud.ErrRejected):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return status.Error(codes.NotFound, msg)
+	case strings.Contains(msg, "unauthorized"), strings.Contains(msg, "does not belong"):
+		return status.Error(codes.PermissionDenied, msg)
+	case strings.Contains(msg, "internal server error"), strings.HasPrefix(msg, "failed to"):
+		return status.Error(codes.Internal, "internal server error")
+	default:
+		return status.Error(codes.FailedPrecondition, msg)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CloseAccountHandler handles the closure of an account by its owner. The body
+// names the account its balance is swept to and may be left out for an empty
+// account.
+func (h *Handlers) CloseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CloseAccountRequest
+	if r.ContentLength > 0 && !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	closure, err := h.accountClosureService.CloseAccount(r.Context(), userID, accountID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to close account")
+		http.Error(w, err.Error(), accountClosureErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(closure)
+}
+
+func accountClosureErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.HasSuffix(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"):
+		return http.StatusForbidden
+	case strings.HasPrefix(msg, "account "):
+		// The account is closed already or something still uses it
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
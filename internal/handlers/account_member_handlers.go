@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// writeAccountMemberError maps account sharing errors to HTTP statuses
+func (h *Handlers) writeAccountMemberError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error":
+		http.Error(w, msg, http.StatusInternalServerError)
+	case strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "unauthorized"):
+		http.Error(w, msg, http.StatusForbidden)
+	default:
+		http.Error(w, msg, http.StatusBadRequest)
+	}
+}
+
+// InviteAccountMemberHandler handles an invitation of a user to share an account
+func (h *Handlers) InviteAccountMemberHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.InviteAccountMemberRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	member, err := h.accountMemberService.Invite(r.Context(), userID, accountID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to invite account member")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+// GetAccountMembersHandler handles listing of the members of an account
+func (h *Handlers) GetAccountMembersHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	members, err := h.accountMemberService.GetMembers(r.Context(), userID, accountID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get account members")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateAccountMemberHandler handles a change of a member's permission level
+func (h *Handlers) UpdateAccountMemberHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	memberUserID, err := strconv.ParseInt(vars["user_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAccountMemberRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	member, err := h.accountMemberService.UpdateMemberRole(r.Context(), userID, accountID, memberUserID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update account member")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveAccountMemberHandler handles removal of a member from an account
+func (h *Handlers) RemoveAccountMemberHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	memberUserID, err := strconv.ParseInt(vars["user_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.accountMemberService.RemoveMember(r.Context(), userID, accountID, memberUserID); err != nil {
+		h.logger.WithError(err).Error("Failed to remove account member")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAccountInvitationsHandler handles listing of the invitations to the current user
+func (h *Handlers) GetAccountInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invitations, err := h.accountMemberService.GetInvitations(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get account invitations")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// AcceptAccountInvitationHandler handles acceptance of an invitation to an account
+func (h *Handlers) AcceptAccountInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	member, err := h.accountMemberService.AcceptInvitation(r.Context(), userID, invitationID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to accept account invitation")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// DeclineAccountInvitationHandler handles refusal of an invitation to an account
+func (h *Handlers) DeclineAccountInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.accountMemberService.DeclineInvitation(r.Context(), userID, invitationID); err != nil {
+		h.logger.WithError(err).Error("Failed to decline account invitation")
+		h.writeAccountMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/accounting"
+	"github.com/Abigotado/abi_banking/internal/authctx"
+)
+
+// ExportAccountingHandler handles the export of the ledger of a month to the
+// accounting system as a CSV or XML attachment, or a signed URL to download it
+// with ?delivery=link. Without a period the previous month is exported.
+func (h *Handlers) ExportAccountingHandler(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		now := time.Now().UTC()
+		period = now.AddDate(0, 0, -now.Day()).Format("2006-01")
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = accounting.FormatCSV
+	}
+	if format != accounting.FormatCSV && format != accounting.FormatXML {
+		http.Error(w, "format must be csv or xml", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := h.reportingService.AccountingExport(r.Context(), adminID, period)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export accounting entries")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	render, contentType := accounting.RenderCSV, "text/csv; charset=utf-8"
+	if format == accounting.FormatXML {
+		render, contentType = accounting.RenderXML, "application/xml"
+	}
+	payload, err := render(export)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to render accounting export")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeExport(w, r, fmt.Sprintf("accounting-%s.%s", export.Period, format), contentType, payload)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// adminUIPath is where the admin dashboard page and its assets are served
+const adminUIPath = "/admin/ui/"
+
+// adminUIContentSecurityPolicy lets the dashboard page load its own script and
+// stylesheet and call the API of the same origin, nothing else
+const adminUIContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; " +
+	"connect-src 'self'; form-action 'none'; frame-ancestors 'none'"
+
+//go:embed admin_ui
+var adminUIFiles embed.FS
+
+var adminUITemplate = template.Must(template.ParseFS(adminUIFiles, "admin_ui/index.html"))
+
+// adminUIAssets serves the script and the stylesheet of the dashboard page
+var adminUIAssets = func() http.Handler {
+	assets, err := fs.Sub(adminUIFiles, "admin_ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix(adminUIPath, http.FileServer(http.FS(assets)))
+}()
+
+// AdminDashboardHandler handles retrieval of the overview of the admin
+// dashboard: the health of the service, recent failed payments, the depth of
+// the notification queue and the latest reconciliation issues
+func (h *Handlers) AdminDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	dashboard, err := h.dashboardService.Overview(r.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get admin dashboard")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+// AdminUIHandler serves the admin dashboard page and its assets. The page holds
+// no data; it asks for the credential of an administrator and renders the
+// overview fetched from the admin API with it.
+func (h *Handlers) AdminUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", adminUIContentSecurityPolicy)
+
+	name := strings.TrimPrefix(r.URL.Path, adminUIPath)
+	if name != "" && name != "index.html" {
+		adminUIAssets.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := adminUITemplate.Execute(w, map[string]string{"DashboardURL": h.dashboardURL}); err != nil {
+		h.logger.WithError(err).Error("Failed to render admin dashboard")
+	}
+}
@@ -0,0 +1,196 @@
+// The dashboard is rendered from the admin dashboard endpoint of the API. The
+// credential stays in session storage and is sent with every request, so the
+// page itself holds no data and needs no session.
+(function () {
+  "use strict";
+
+  var refreshInterval = 30000;
+  var storageKey = "abi-admin-credential";
+  var dashboardURL = document.body.dataset.dashboardUrl;
+  var timer = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function credential() {
+    try {
+      return JSON.parse(sessionStorage.getItem(storageKey));
+    } catch (e) {
+      return null;
+    }
+  }
+
+  function showError(message) {
+    $("error").textContent = message;
+    $("error").hidden = !message;
+  }
+
+  function signedIn(yes) {
+    $("sign-in").hidden = yes;
+    $("dashboard").hidden = !yes;
+    $("refresh").hidden = !yes;
+    $("sign-out").hidden = !yes;
+  }
+
+  function signOut(message) {
+    sessionStorage.removeItem(storageKey);
+    clearInterval(timer);
+    timer = null;
+    signedIn(false);
+    $("updated").textContent = "";
+    showError(message || "");
+  }
+
+  function formatTime(value) {
+    return value ? new Date(value).toLocaleString() : "";
+  }
+
+  function formatAmount(amount, currency) {
+    return Number(amount).toFixed(2) + " " + currency;
+  }
+
+  function cell(text, className) {
+    var td = document.createElement("td");
+    td.textContent = text === undefined || text === null ? "" : String(text);
+    if (className) {
+      td.className = className;
+    }
+    return td;
+  }
+
+  // fill replaces the rows of a table body with the cells of each item, or
+  // with emptyText when there are none
+  function fill(id, items, columns, emptyText, toCells) {
+    var body = $(id);
+    body.replaceChildren();
+    if (!items || items.length === 0) {
+      var empty = cell(emptyText, "empty");
+      empty.colSpan = columns;
+      items = [null];
+      toCells = function () {
+        return [empty];
+      };
+    }
+    items.forEach(function (item) {
+      var row = document.createElement("tr");
+      toCells(item).forEach(function (td) {
+        row.appendChild(td);
+      });
+      body.appendChild(row);
+    });
+  }
+
+  function render(dashboard) {
+    var health = dashboard.health;
+    $("ready").textContent = health.ready ? "ready" : "not ready";
+    $("ready").className = health.ready ? "ok" : "bad";
+    $("database-error").textContent = health.database_error || "";
+    $("database-error").hidden = !health.database_error;
+
+    fill("heartbeats", health.heartbeats, 4, "No background processes report heartbeats", function (hb) {
+      return [
+        cell(hb.name),
+        cell(formatTime(hb.last_beat)),
+        cell(hb.max_age),
+        cell(hb.stalled ? "stalled" : "running", hb.stalled ? "bad" : "ok")
+      ];
+    });
+
+    fill("queue", dashboard.notification_queue, 5, "The queue is empty", function (depth) {
+      return [
+        cell(depth.topic),
+        cell(depth.pending, "number"),
+        cell(depth.retrying, depth.retrying > 0 ? "number bad" : "number"),
+        cell(depth.publishing, "number"),
+        cell(formatTime(depth.oldest_at))
+      ];
+    });
+
+    fill("failed-payments", dashboard.failed_payments, 6, "No failed payments", function (payment) {
+      return [
+        cell(formatTime(payment.failed_at)),
+        cell(payment.kind.replace(/_/g, " ")),
+        cell(payment.id, "number"),
+        cell(payment.user_id, "number"),
+        cell(formatAmount(payment.amount, payment.currency), "number"),
+        cell(payment.reason)
+      ];
+    });
+
+    var report = dashboard.reconciliation;
+    $("reconciliation-run").textContent = report
+      ? "of " + report.run.run_date.slice(0, 10) + ", " + report.run.accounts + " accounts"
+      : "";
+    fill("reconciliation-issues", report ? report.issues : [], 5, report ? "No issues" : "No reconciliation run yet", function (issue) {
+      return [
+        cell(issue.account_id, "number"),
+        cell(issue.kind.replace(/_/g, " ")),
+        cell(formatAmount(issue.balance, issue.currency), "number"),
+        cell(formatAmount(issue.expected_balance, issue.currency), "number"),
+        cell(formatAmount(issue.difference, issue.currency), "number bad")
+      ];
+    });
+
+    $("updated").textContent = "Updated " + formatTime(dashboard.generated_at);
+  }
+
+  function load() {
+    var cred = credential();
+    if (!cred) {
+      signOut();
+      return;
+    }
+
+    var headers = {Accept: "application/json"};
+    if (cred.kind === "api-key") {
+      headers["X-API-Key"] = cred.value;
+    } else {
+      headers.Authorization = "Bearer " + cred.value;
+    }
+
+    fetch(dashboardURL, {headers: headers, credentials: "omit"})
+      .then(function (resp) {
+        if (resp.status === 401 || resp.status === 403) {
+          signOut("The credential was refused, sign in as an administrator");
+          return null;
+        }
+        if (!resp.ok) {
+          return resp.text().then(function (text) {
+            throw new Error(resp.status + " " + text.trim());
+          });
+        }
+        return resp.json();
+      })
+      .then(function (dashboard) {
+        if (!dashboard) {
+          return;
+        }
+        showError("");
+        signedIn(true);
+        render(dashboard);
+        if (!timer) {
+          timer = setInterval(load, refreshInterval);
+        }
+      })
+      .catch(function (err) {
+        showError("Failed to load the dashboard: " + err.message);
+      });
+  }
+
+  $("sign-in").addEventListener("submit", function (event) {
+    event.preventDefault();
+    sessionStorage.setItem(storageKey, JSON.stringify({
+      kind: $("credential-kind").value,
+      value: $("credential").value.trim()
+    }));
+    $("credential").value = "";
+    load();
+  });
+  $("refresh").addEventListener("click", load);
+  $("sign-out").addEventListener("click", function () {
+    signOut();
+  });
+
+  load();
+})();
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// CreateAlertHandler handles setting up an alert of the current user
+func (h *Handlers) CreateAlertHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateAlertRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	alert, err := h.alertService.CreateAlert(r.Context(), userID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create alert")
+		http.Error(w, err.Error(), alertErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(alert)
+}
+
+// ListAlertsHandler handles listing of the alerts of the current user
+func (h *Handlers) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.AlertPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	alerts, total, err := h.alertService.ListAlerts(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list alerts")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// GetAlertHandler handles retrieval of an alert of the current user
+func (h *Handlers) GetAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alertID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid alert ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	alert, err := h.alertService.GetAlert(r.Context(), userID, alertID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get alert")
+		http.Error(w, err.Error(), alertErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alert)
+}
+
+// UpdateAlertHandler handles a change of the threshold of an alert of the
+// current user or its switching on or off
+func (h *Handlers) UpdateAlertHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.UpdateAlertRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	alertID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid alert ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	alert, err := h.alertService.UpdateAlert(r.Context(), userID, alertID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update alert")
+		http.Error(w, err.Error(), alertErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alert)
+}
+
+// DeleteAlertHandler handles removal of an alert of the current user
+func (h *Handlers) DeleteAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alertID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid alert ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.alertService.DeleteAlert(r.Context(), userID, alertID); err != nil {
+		h.logger.WithError(err).Error("Failed to delete alert")
+		http.Error(w, err.Error(), alertErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func alertErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/aml"
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// ListAMLFlagsHandler handles listing of the AML flags. Open flags form the
+// review queue.
+func (h *Handlers) ListAMLFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r.URL.Query(), repository.AMLFlagPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flags, total, err := h.amlService.ListFlags(r.Context(), r.URL.Query().Get("rule"), page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list AML flags")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
+
+// GetAMLFlagHandler handles AML flag retrieval
+func (h *Handlers) GetAMLFlagHandler(w http.ResponseWriter, r *http.Request) {
+	flagID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid AML flag ID", http.StatusBadRequest)
+		return
+	}
+
+	flag, err := h.amlService.GetFlag(r.Context(), flagID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get AML flag")
+		http.Error(w, err.Error(), amlErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// TakeAMLFlagHandler handles taking an open AML flag under review
+func (h *Handlers) TakeAMLFlagHandler(w http.ResponseWriter, r *http.Request) {
+	flagID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid AML flag ID", http.StatusBadRequest)
+		return
+	}
+
+	reviewerID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flag, err := h.amlService.TakeFlag(r.Context(), reviewerID, flagID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to take AML flag")
+		http.Error(w, err.Error(), amlErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// ReportAMLFlagHandler handles the confirmation of a flag under review, which
+// puts it in the regulator report
+func (h *Handlers) ReportAMLFlagHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeAMLFlag(w, r, true)
+}
+
+// DismissAMLFlagHandler handles the dismissal of a flag under review
+func (h *Handlers) DismissAMLFlagHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeAMLFlag(w, r, false)
+}
+
+func (h *Handlers) closeAMLFlag(w http.ResponseWriter, r *http.Request, report bool) {
+	flagID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid AML flag ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewAMLFlagRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	reviewerID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var flag *models.AMLFlag
+	if report {
+		flag, err = h.amlService.ReportFlag(r.Context(), reviewerID, flagID, req.Comment)
+	} else {
+		flag, err = h.amlService.DismissFlag(r.Context(), reviewerID, flagID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review AML flag")
+		http.Error(w, err.Error(), amlErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// GetAMLReportHandler handles the download of the regulator report of the
+// flags reported within a month as a CSV or XML attachment, or a signed URL to
+// download it with ?delivery=link. Without a period the report of the previous
+// month is returned.
+func (h *Handlers) GetAMLReportHandler(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		now := time.Now().UTC()
+		period = now.AddDate(0, 0, -now.Day()).Format("2006-01")
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = aml.FormatXML
+	}
+	if format != aml.FormatCSV && format != aml.FormatXML {
+		http.Error(w, "format must be csv or xml", http.StatusBadRequest)
+		return
+	}
+
+	actorID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.amlService.Report(r.Context(), actorID, period)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to generate AML report")
+		http.Error(w, err.Error(), amlErrorStatus(err))
+		return
+	}
+
+	render, contentType := aml.RenderXML, "application/xml"
+	if format == aml.FormatCSV {
+		render, contentType = aml.RenderCSV, "text/csv; charset=utf-8"
+	}
+	payload, err := render(report)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to render AML report")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeExport(w, r, fmt.Sprintf("aml-report-%s.%s", report.Period, format), contentType, payload)
+}
+
+func amlErrorStatus(err error) int {
+	switch err.Error() {
+	case "aml flag not found":
+		return http.StatusNotFound
+	case "aml flag was changed by someone else", "aml flag is under review by someone else":
+		return http.StatusConflict
+	case "aml flag must be reviewed by someone else":
+		return http.StatusForbidden
+	case "internal server error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CreateAPIKeyHandler handles issuing of an API key by an administrator
+func (h *Handlers) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	key, err := h.apiKeyService.Issue(r.Context(), adminID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to issue API key")
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "user not found":
+			status = http.StatusNotFound
+		case "internal server error":
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// ListAPIKeysHandler handles listing of API keys, optionally of a single user
+func (h *Handlers) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	keys, err := h.apiKeyService.List(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list API keys")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RotateAPIKeyHandler handles issuing of a successor to an API key
+func (h *Handlers) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyID, adminID, ok := h.apiKeyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.Rotate(r.Context(), adminID, keyID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to rotate API key")
+		http.Error(w, err.Error(), apiKeyErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// RevokeAPIKeyHandler handles revocation of an API key
+func (h *Handlers) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyID, adminID, ok := h.apiKeyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), adminID, keyID); err != nil {
+		h.logger.WithError(err).Error("Failed to revoke API key")
+		http.Error(w, err.Error(), apiKeyErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyRequest reads the key ID of the path and the administrator making the request
+func (h *Handlers) apiKeyRequest(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	keyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid API key ID")
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	return keyID, adminID, true
+}
+
+// apiKeyErrorStatus maps the errors of rotating and revoking a key to a status
+func apiKeyErrorStatus(err error) int {
+	switch err.Error() {
+	case "API key not found":
+		return http.StatusNotFound
+	case "API key is already revoked", "API key has expired":
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
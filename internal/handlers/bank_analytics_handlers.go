@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// GetBankAnalyticsHandler handles retrieval of the portfolio metrics of the
+// whole bank, with the users registered and the transactions posted from the
+// from date up to the to date, the last 30 days by default
+func (h *Handlers) GetBankAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -29)
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			http.Error(w, "Invalid "+name+" date", http.StatusBadRequest)
+			return
+		}
+		*date = parsed
+	}
+
+	analytics, err := h.bankAnalyticsService.GetAnalytics(r.Context(), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get bank analytics")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics)
+}
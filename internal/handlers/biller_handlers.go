@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// ListBillersHandler handles listing of the active billers of the catalog,
+// optionally of one category
+func (h *Handlers) ListBillersHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeBillers(w, r, false)
+}
+
+// AdminListBillersHandler handles listing of all the billers of the catalog,
+// the inactive ones included
+func (h *Handlers) AdminListBillersHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeBillers(w, r, true)
+}
+
+func (h *Handlers) writeBillers(w http.ResponseWriter, r *http.Request, all bool) {
+	billers, err := h.billerService.ListBillers(r.Context(), r.URL.Query().Get("category"), all)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list billers")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(billers)
+}
+
+// GetBillerHandler handles retrieval of an active biller of the catalog
+func (h *Handlers) GetBillerHandler(w http.ResponseWriter, r *http.Request) {
+	billerID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid biller ID", http.StatusBadRequest)
+		return
+	}
+
+	biller, err := h.billerService.GetBiller(r.Context(), billerID, false)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get biller")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(biller)
+}
+
+// CreateBillerHandler handles adding a biller to the catalog
+func (h *Handlers) CreateBillerHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.BillerRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	biller, err := h.billerService.CreateBiller(r.Context(), adminID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create biller")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(biller)
+}
+
+// UpdateBillerHandler handles a change of the details of a biller or taking it
+// out of the catalog
+func (h *Handlers) UpdateBillerHandler(w http.ResponseWriter, r *http.Request) {
+	billerID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid biller ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.BillerRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	biller, err := h.billerService.UpdateBiller(r.Context(), adminID, billerID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update biller")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(biller)
+}
+
+// PayBillHandler handles paying a bill. A payment the provider fails is
+// refunded and answers 502 Bad Gateway.
+func (h *Handlers) PayBillHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateBillPaymentRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payment, err := h.billerService.PayBill(r.Context(), userID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to pay bill")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// ListBillPaymentsHandler handles listing of the bill payments of the current user
+func (h *Handlers) ListBillPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.BillPaymentPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payments, total, err := h.billerService.ListPayments(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list bill payments")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+// GetBillPaymentHandler handles retrieval of a bill payment of the current user
+func (h *Handlers) GetBillPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid bill payment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payment, err := h.billerService.GetPayment(r.Context(), userID, paymentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get bill payment")
+		http.Error(w, err.Error(), billerErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+func billerErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.HasPrefix(msg, "bill payment failed"):
+		return http.StatusBadGateway
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrAccountDormant):
+		return http.StatusForbidden
+	case strings.Contains(msg, "already exists"):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// ListBranchesHandler handles listing of the branches open for registration
+func (h *Handlers) ListBranchesHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeBranches(w, r, true)
+}
+
+// AdminListBranchesHandler handles listing of all branches, closed ones included
+func (h *Handlers) AdminListBranchesHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeBranches(w, r, false)
+}
+
+func (h *Handlers) writeBranches(w http.ResponseWriter, r *http.Request, activeOnly bool) {
+	branches, err := h.branchService.ListBranches(r.Context(), activeOnly)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list branches")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(branches)
+}
+
+// CreateBranchHandler handles the opening of a branch
+func (h *Handlers) CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateBranchRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	branch, err := h.branchService.CreateBranch(r.Context(), adminID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create branch")
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(branch)
+}
+
+// UpdateBranchHandler handles a change of the details or the status of a branch
+func (h *Handlers) UpdateBranchHandler(w http.ResponseWriter, r *http.Request) {
+	branchID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid branch ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateBranchRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	branch, err := h.branchService.UpdateBranch(r.Context(), adminID, branchID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update branch")
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(branch)
+}
+
+// AssignUserBranchHandler handles moving a user to a branch
+func (h *Handlers) AssignUserBranchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignBranchRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.branchService.AssignUser(r.Context(), adminID, userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to assign user to branch")
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// GetBranchReportHandler handles the report of a branch over a period given by
+// the from and to dates, both included; the current month by default
+func (h *Handlers) GetBranchReportHandler(w http.ResponseWriter, r *http.Request) {
+	branchID, ok := h.authorizeBranch(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			http.Error(w, "Invalid "+name+" date", http.StatusBadRequest)
+			return
+		}
+		*date = parsed
+	}
+
+	report, err := h.branchService.GetReport(r.Context(), branchID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get branch report")
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetBranchUsersHandler handles listing of the users of a branch
+func (h *Handlers) GetBranchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	branchID, ok := h.authorizeBranch(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.UserPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, total, err := h.branchService.ListUsers(r.Context(), branchID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list branch users")
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// authorizeBranch reads the branch of a request and checks that the caller may
+// see it, writing the error response when not
+func (h *Handlers) authorizeBranch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	branchID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid branch ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+	role, ok := authctx.RoleFromContext(r.Context())
+	if !ok {
+		h.logger.Error("Role not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	if err := h.branchService.Authorize(r.Context(), userID, role, branchID); err != nil {
+		h.logger.WithError(err).Warnf("Branch %d refused to user %d", branchID, userID)
+		http.Error(w, err.Error(), branchErrorStatus(err))
+		return 0, false
+	}
+	return branchID, true
+}
+
+func branchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrBranchAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrBranchCodeTaken):
+		return http.StatusConflict
+	}
+	switch err.Error() {
+	case "branch not found", "user not found":
+		return http.StatusNotFound
+	case "internal server error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CreateBudgetHandler handles setting the monthly limit of a spending category
+// of the current user
+func (h *Handlers) CreateBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.BudgetRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	budget, err := h.budgetService.CreateBudget(r.Context(), userID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create budget")
+		http.Error(w, err.Error(), budgetErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(budget)
+}
+
+// ListBudgetsHandler handles listing of the budgets of the current user with
+// the spending of the current month
+func (h *Handlers) ListBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	budgets, err := h.budgetService.ListBudgets(r.Context(), userID, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list budgets")
+		http.Error(w, err.Error(), budgetErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(budgets)
+}
+
+// GetBudgetHandler handles retrieval of a budget of the current user with the
+// spending of the current month
+func (h *Handlers) GetBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	budgetID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	budget, err := h.budgetService.GetBudget(r.Context(), userID, budgetID, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get budget")
+		http.Error(w, err.Error(), budgetErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(budget)
+}
+
+// UpdateBudgetHandler handles a change of the monthly limit of a budget of the
+// current user
+func (h *Handlers) UpdateBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.UpdateBudgetRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	budgetID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	budget, err := h.budgetService.UpdateBudget(r.Context(), userID, budgetID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update budget")
+		http.Error(w, err.Error(), budgetErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(budget)
+}
+
+// DeleteBudgetHandler handles removal of a budget of the current user
+func (h *Handlers) DeleteBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	budgetID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.budgetService.DeleteBudget(r.Context(), userID, budgetID); err != nil {
+		h.logger.WithError(err).Error("Failed to delete budget")
+		http.Error(w, err.Error(), budgetErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func budgetErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "budget already exists"):
+		return http.StatusConflict
+	case strings.Contains(msg, "unavailable"):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
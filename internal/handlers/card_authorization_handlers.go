@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// AuthorizeCardPaymentHandler handles a card payment authorization requested by
+// the acquirer. A declined payment is answered with 402 and the decline reason.
+func (h *Handlers) AuthorizeCardPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CardAuthorizationRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authorization, err := h.cardAuthorizationService.Authorize(r.Context(), req)
+	if err != nil {
+		h.logger.WithError(err).Error("Card payment authorization declined")
+		msg := err.Error()
+		if msg == "internal server error" || strings.HasPrefix(msg, "failed to") {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, msg, http.StatusPaymentRequired)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// GetCardAuthorizationHandler handles card authorization retrieval for the acquirer
+func (h *Handlers) GetCardAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid authorization ID", http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.cardAuthorizationService.GetAuthorization(r.Context(), id)
+	if err != nil {
+		h.writeCardAuthorizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// CaptureCardAuthorizationHandler handles the capture of an authorized card payment
+func (h *Handlers) CaptureCardAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid authorization ID", http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.cardAuthorizationService.Capture(r.Context(), id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to capture card authorization")
+		h.writeCardAuthorizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// VoidCardAuthorizationHandler handles the cancellation of an authorized card payment
+func (h *Handlers) VoidCardAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid authorization ID", http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.cardAuthorizationService.Void(r.Context(), id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to void card authorization")
+		h.writeCardAuthorizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+func (h *Handlers) writeCardAuthorizationError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case msg == "authorization not found":
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "authorization "):
+		http.Error(w, msg, http.StatusConflict)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CreateCardBatchHandler handles bulk card issuance for a corporate account.
+// The employee list is sent either as JSON or as CSV; for CSV the account, card
+// type and limits are passed as query parameters.
+func (h *Handlers) CreateCardBatchHandler(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req *models.CreateCardBatchRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		parsed, err := parseCardBatchCSV(r)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to parse card batch CSV")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req = parsed
+	} else {
+		req = &models.CreateCardBatchRequest{}
+		if err := middleware.DecodeJSON(r.Body, req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	if err := middleware.Validate(req); err != nil {
+		h.logger.WithError(err).Warn("Card batch request failed validation")
+		middleware.WriteValidationError(w, err)
+		return
+	}
+
+	batch, err := h.cardBatchService.CreateBatch(r.Context(), adminID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create card batch")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", h.jobService.JobPath(*batch.JobID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetCardBatchHandler handles retrieval of a card batch report
+func (h *Handlers) GetCardBatchHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	batchID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card batch ID")
+		http.Error(w, "Invalid card batch ID", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.cardBatchService.GetBatch(r.Context(), batchID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get card batch")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// UpdateCardDeliveryHandler handles delivery tracking updates for a batch card
+func (h *Handlers) UpdateCardDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	batchID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card batch ID")
+		http.Error(w, "Invalid card batch ID", http.StatusBadRequest)
+		return
+	}
+	itemID, err := strconv.ParseInt(vars["item_id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card batch item ID")
+		http.Error(w, "Invalid card batch item ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateCardDeliveryRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.cardBatchService.UpdateDelivery(r.Context(), batchID, itemID, &req); err != nil {
+		h.logger.WithError(err).Error("Failed to update card delivery")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseCardBatchCSV reads an employee list with a header row. The full_name and
+// delivery_address columns are required, employee_id and email are optional.
+func parseCardBatchCSV(r *http.Request) (*models.CreateCardBatchRequest, error) {
+	query := r.URL.Query()
+	accountID, err := strconv.ParseInt(query.Get("account_id"), 10, 64)
+	if err != nil {
+		return nil, errors.New("account_id query parameter is required")
+	}
+
+	req := &models.CreateCardBatchRequest{
+		AccountID: accountID,
+		CardType:  query.Get("card_type"),
+	}
+	limits := map[string]**float64{
+		"daily_limit":       &req.Limits.DailyLimit,
+		"monthly_limit":     &req.Limits.MonthlyLimit,
+		"transaction_limit": &req.Limits.TransactionLimit,
+	}
+	for name, target := range limits {
+		if value := query.Get(name); value != "" {
+			limit, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			*target = &limit
+		}
+	}
+
+	reader := csv.NewReader(r.Body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV header row is required")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"full_name", "delivery_address"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV column %s is required", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		req.Employees = append(req.Employees, models.CardBatchEmployee{
+			EmployeeID:      field(record, "employee_id"),
+			FullName:        field(record, "full_name"),
+			Email:           field(record, "email"),
+			DeliveryAddress: field(record, "delivery_address"),
+		})
+	}
+
+	return req, nil
+}
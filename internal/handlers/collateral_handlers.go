@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// GetCreditCollateralHandler handles retrieval of the collateral securing a
+// borrower's credit with its loan-to-value ratio
+func (h *Handlers) GetCreditCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.getCreditCollateral(w, r, userID)
+}
+
+// AdminGetCreditCollateralHandler handles retrieval of the collateral securing
+// any credit with its loan-to-value ratio
+func (h *Handlers) AdminGetCreditCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	h.getCreditCollateral(w, r, 0)
+}
+
+func (h *Handlers) getCreditCollateral(w http.ResponseWriter, r *http.Request, userID int64) {
+	creditID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	collateral, err := h.collateralService.CreditCollateral(r.Context(), userID, creditID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit collateral")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// CreateCollateralHandler handles the registration of collateral of a user
+func (h *Handlers) CreateCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCollateralRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collateral, err := h.collateralService.CreateCollateral(r.Context(), adminID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to register collateral")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// ListCollateralHandler handles listing of collateral, optionally of a user or
+// securing a credit
+func (h *Handlers) ListCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	var userID, creditID int64
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("credit_id"); value != "" {
+		var err error
+		if creditID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	collateral, err := h.collateralService.ListCollateral(r.Context(), userID, creditID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list collateral")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// GetCollateralHandler handles retrieval of collateral with its documents
+func (h *Handlers) GetCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+
+	collateral, err := h.collateralService.GetCollateral(r.Context(), collateralID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get collateral")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// UpdateCollateralValuationHandler handles a new appraisal of collateral
+func (h *Handlers) UpdateCollateralValuationHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateCollateralValuationRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collateral, err := h.collateralService.UpdateValuation(r.Context(), adminID, collateralID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update collateral valuation")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// LinkCollateralHandler handles pledging collateral for a credit of its owner
+func (h *Handlers) LinkCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.LinkCollateralRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collateral, err := h.collateralService.LinkCredit(r.Context(), adminID, collateralID, req.CreditID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to link collateral")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// ReleaseCollateralHandler handles releasing collateral from the credit it secures
+func (h *Handlers) ReleaseCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collateral, err := h.collateralService.ReleaseCollateral(r.Context(), adminID, collateralID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to release collateral")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+// UploadCollateralDocumentHandler handles the upload of a document of
+// collateral as a multipart form with the file in the "file" field. The file is
+// read in memory, like identity documents.
+func (h *Handlers) UploadCollateralDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Request must be a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	var fileName string
+	var data []byte
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.logger.WithError(err).Warn("Failed to read multipart form")
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() == "file" {
+			fileName = part.FileName()
+			if data, err = io.ReadAll(part); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+				return
+			}
+		}
+		part.Close()
+	}
+	if data == nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.collateralService.UploadDocument(r.Context(), collateralID, fileName, data)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to upload collateral document")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// GetCollateralDocumentURLHandler handles the issue of a signed URL to view a
+// document of collateral
+func (h *Handlers) GetCollateralDocumentURLHandler(w http.ResponseWriter, r *http.Request) {
+	collateralID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid collateral ID", http.StatusBadRequest)
+		return
+	}
+	documentID, err := strconv.ParseInt(mux.Vars(r)["document_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	url, err := h.collateralService.DocumentURL(r.Context(), adminID, collateralID, documentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get collateral document URL")
+		http.Error(w, err.Error(), collateralErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+func collateralErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error":
+		return http.StatusInternalServerError
+	case strings.HasSuffix(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"):
+		return http.StatusForbidden
+	case msg == "credit is not being repaid", msg == "collateral does not secure a credit":
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
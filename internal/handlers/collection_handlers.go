@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// ListCollectionCasesHandler handles listing of the collection cases, optionally
+// of one stage
+func (h *Handlers) ListCollectionCasesHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r.URL.Query(), repository.CollectionCasePageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cases, total, err := h.collectionService.ListCases(r.Context(), r.URL.Query().Get("stage"), page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list collection cases")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cases)
+}
+
+// GetCollectionSummaryHandler handles retrieval of the collections dashboard:
+// the open cases summed up per stage and currency
+func (h *Handlers) GetCollectionSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.collectionService.Summary(r.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to sum up collection cases")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// CreateCreditApplicationHandler handles credit applications
+func (h *Handlers) CreateCreditApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCreditApplicationRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	app, err := h.creditApplicationService.Apply(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create credit application")
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "identity must be verified to apply for a credit", service.ErrCreditInCollections.Error():
+			status = http.StatusForbidden
+		case "internal server error":
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(app)
+}
+
+// GetCreditPreviewHandler handles previews of the schedule of a credit for
+// loan calculators, described by query parameters. Nothing is created.
+func (h *Handlers) GetCreditPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &models.CreditPreviewRequest{
+		RateType:     models.RateType(query.Get("rate_type")),
+		ScheduleType: models.ScheduleType(query.Get("schedule_type")),
+	}
+
+	var err error
+	if req.Amount, err = strconv.ParseFloat(query.Get("amount"), 64); err != nil {
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+	if req.TermMonths, err = strconv.Atoi(query.Get("term_months")); err != nil {
+		http.Error(w, "Invalid term_months", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("interest_rate"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			http.Error(w, "Invalid interest_rate", http.StatusBadRequest)
+			return
+		}
+		req.InterestRate = &rate
+	}
+	for name, field := range map[string]*float64{
+		"origination_fee":   &req.OriginationFee,
+		"insurance_premium": &req.InsurancePremium,
+	} {
+		if value := query.Get(name); value != "" {
+			if *field, err = strconv.ParseFloat(value, 64); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if err := middleware.Validate(req); err != nil {
+		h.logger.WithError(err).Warn("Credit preview request failed validation")
+		middleware.WriteValidationError(w, err)
+		return
+	}
+
+	preview, err := h.creditApplicationService.Preview(r.Context(), req, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to preview credit")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// GetCreditApplicationHandler handles retrieval of one of the user's credit applications
+func (h *Handlers) GetCreditApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	applicationID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit application ID")
+		http.Error(w, "Invalid credit application ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	app, err := h.creditApplicationService.GetApplication(r.Context(), userID, applicationID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit application")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app)
+}
+
+// GetUserCreditApplicationsHandler handles retrieval of the user's credit applications
+func (h *Handlers) GetUserCreditApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	apps, err := h.creditApplicationService.GetUserApplications(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit applications")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apps)
+}
+
+// ListCreditApplicationsHandler handles credit application listing for administrators
+func (h *Handlers) ListCreditApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.CreditApplicationStatus(r.URL.Query().Get("status"))
+
+	apps, err := h.creditApplicationService.ListApplications(r.Context(), status)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list credit applications")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apps)
+}
+
+// ApproveCreditApplicationHandler handles manual approval of a credit application
+func (h *Handlers) ApproveCreditApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditApplication(w, r, true)
+}
+
+// RejectCreditApplicationHandler handles manual rejection of a credit application
+func (h *Handlers) RejectCreditApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditApplication(w, r, false)
+}
+
+func (h *Handlers) reviewCreditApplication(w http.ResponseWriter, r *http.Request, approve bool) {
+	vars := mux.Vars(r)
+	applicationID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit application ID")
+		http.Error(w, "Invalid credit application ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewCreditApplicationRequest
+	if r.ContentLength > 0 {
+		if err := middleware.DecodeJSON(r.Body, &req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var app *models.CreditApplication
+	if approve {
+		app, err = h.creditApplicationService.ApproveApplication(r.Context(), adminID, applicationID, req.Comment)
+	} else {
+		app, err = h.creditApplicationService.RejectApplication(r.Context(), adminID, applicationID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review credit application")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app)
+}
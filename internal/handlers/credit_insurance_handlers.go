@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// GetCreditInsuranceHandler handles retrieval of the insurance of a borrower's
+// credit with its claims
+func (h *Handlers) GetCreditInsuranceHandler(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit ID")
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	insurance, err := h.creditInsuranceService.GetInsurance(r.Context(), userID, creditID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit insurance")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(insurance)
+}
+
+// FileCreditInsuranceClaimHandler handles claims on the insurance of a borrower's credit
+func (h *Handlers) FileCreditInsuranceClaimHandler(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit ID")
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateCreditInsuranceClaimRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	claim, err := h.creditInsuranceService.FileClaim(r.Context(), userID, creditID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to file credit insurance claim")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(claim)
+}
+
+// ListCreditInsuranceClaimsHandler handles insurance claim listing
+func (h *Handlers) ListCreditInsuranceClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.CreditInsuranceClaimStatus(r.URL.Query().Get("status"))
+
+	claims, err := h.creditInsuranceService.ListClaims(r.Context(), status)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list credit insurance claims")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+// ApproveCreditInsuranceClaimHandler handles approval of a pending insurance claim
+func (h *Handlers) ApproveCreditInsuranceClaimHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditInsuranceClaim(w, r, models.CreditInsuranceClaimStatusApproved)
+}
+
+// RejectCreditInsuranceClaimHandler handles rejection of a pending insurance claim
+func (h *Handlers) RejectCreditInsuranceClaimHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditInsuranceClaim(w, r, models.CreditInsuranceClaimStatusRejected)
+}
+
+// CloseCreditInsuranceClaimHandler handles closing of an approved insurance claim
+func (h *Handlers) CloseCreditInsuranceClaimHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditInsuranceClaim(w, r, models.CreditInsuranceClaimStatusClosed)
+}
+
+func (h *Handlers) reviewCreditInsuranceClaim(w http.ResponseWriter, r *http.Request, status models.CreditInsuranceClaimStatus) {
+	claimID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid insurance claim ID")
+		http.Error(w, "Invalid insurance claim ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewCreditInsuranceClaimRequest
+	if r.ContentLength > 0 {
+		if err := middleware.DecodeJSON(r.Body, &req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var claim *models.CreditInsuranceClaim
+	switch status {
+	case models.CreditInsuranceClaimStatusApproved:
+		claim, err = h.creditInsuranceService.ApproveClaim(r.Context(), adminID, claimID, req.Comment, time.Now())
+	case models.CreditInsuranceClaimStatusRejected:
+		claim, err = h.creditInsuranceService.RejectClaim(r.Context(), adminID, claimID, req.Comment, time.Now())
+	default:
+		claim, err = h.creditInsuranceService.CloseClaim(r.Context(), adminID, claimID, req.Comment, time.Now())
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review credit insurance claim")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claim)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// RestructureCreditHandler handles restructuring requests of a borrower's credit
+func (h *Handlers) RestructureCreditHandler(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit ID")
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateCreditRestructuringRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	restructuring, err := h.creditRestructuringService.RequestRestructuring(r.Context(), userID, creditID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to request credit restructuring")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(restructuring)
+}
+
+// GetCreditRestructuringsHandler handles retrieval of a credit's restructuring history
+func (h *Handlers) GetCreditRestructuringsHandler(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit ID")
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	restructurings, err := h.creditRestructuringService.GetCreditRestructurings(r.Context(), userID, creditID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit restructurings")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restructurings)
+}
+
+// ListCreditRestructuringsHandler handles credit restructuring listing
+func (h *Handlers) ListCreditRestructuringsHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.CreditRestructuringStatus(r.URL.Query().Get("status"))
+
+	restructurings, err := h.creditRestructuringService.ListRestructurings(r.Context(), status)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list credit restructurings")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restructurings)
+}
+
+// ApproveCreditRestructuringHandler handles approval of a pending credit restructuring
+func (h *Handlers) ApproveCreditRestructuringHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditRestructuring(w, r, true)
+}
+
+// RejectCreditRestructuringHandler handles rejection of a pending credit restructuring
+func (h *Handlers) RejectCreditRestructuringHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewCreditRestructuring(w, r, false)
+}
+
+func (h *Handlers) reviewCreditRestructuring(w http.ResponseWriter, r *http.Request, approve bool) {
+	restructuringID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit restructuring ID")
+		http.Error(w, "Invalid credit restructuring ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewCreditRestructuringRequest
+	if r.ContentLength > 0 {
+		if err := middleware.DecodeJSON(r.Body, &req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var restructuring *models.CreditRestructuring
+	if approve {
+		restructuring, err = h.creditRestructuringService.ApproveRestructuring(r.Context(), adminID, restructuringID, req.Comment, time.Now())
+	} else {
+		restructuring, err = h.creditRestructuringService.RejectRestructuring(r.Context(), adminID, restructuringID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review credit restructuring")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restructuring)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// OpenDepositHandler handles opening a term deposit from an account
+func (h *Handlers) OpenDepositHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateDepositRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deposit, err := h.depositService.OpenDeposit(r.Context(), userID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open deposit")
+		http.Error(w, err.Error(), depositErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deposit)
+}
+
+// ListDepositsHandler handles listing of the term deposits of the current user
+func (h *Handlers) ListDepositsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.DepositPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deposits, total, err := h.depositService.ListDeposits(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list deposits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deposits)
+}
+
+// GetDepositHandler handles retrieval of a term deposit of the current user
+func (h *Handlers) GetDepositHandler(w http.ResponseWriter, r *http.Request) {
+	depositID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid deposit ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deposit, err := h.depositService.GetDeposit(r.Context(), userID, depositID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get deposit")
+		http.Error(w, err.Error(), depositErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deposit)
+}
+
+// WithdrawDepositHandler handles paying a term deposit out before it matures
+func (h *Handlers) WithdrawDepositHandler(w http.ResponseWriter, r *http.Request) {
+	depositID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid deposit ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deposit, err := h.depositService.WithdrawDeposit(r.Context(), userID, depositID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to withdraw deposit")
+		http.Error(w, err.Error(), depositErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deposit)
+}
+
+func depositErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrAccountDormant):
+		return http.StatusForbidden
+	case strings.HasPrefix(msg, "deposit is already"):
+		return http.StatusConflict
+	case strings.Contains(msg, "unavailable"):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// CreateDisputeHandler handles a user contesting a transaction. The request is
+// either a JSON body or, to attach files, a multipart form with the reason,
+// description and amount in fields of those names and each file in a "file"
+// field. The parts are read in memory, like identity documents.
+func (h *Handlers) CreateDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	transactionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateDisputeRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if !h.readDisputeForm(w, r, &req) {
+			return
+		}
+	} else if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	dispute, err := h.disputeService.OpenDispute(r.Context(), userID, transactionID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open dispute")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dispute)
+}
+
+// readDisputeForm reads a dispute from a multipart form. It writes the error
+// response and returns false when the form is invalid.
+func (h *Handlers) readDisputeForm(w http.ResponseWriter, r *http.Request, req *models.CreateDisputeRequest) bool {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Request must be a multipart form", http.StatusBadRequest)
+		return false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.logger.WithError(err).Warn("Failed to read multipart form")
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return false
+		}
+
+		var value []byte
+		if part.FormName() == "file" {
+			value, err = io.ReadAll(part)
+		} else {
+			value, err = io.ReadAll(io.LimitReader(part, 4<<10))
+		}
+		part.Close()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return false
+			}
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return false
+		}
+
+		switch part.FormName() {
+		case "reason":
+			req.Reason = string(value)
+		case "description":
+			req.Description = string(value)
+		case "amount":
+			if req.Amount, err = strconv.ParseFloat(string(value), 64); err != nil {
+				http.Error(w, "amount must be a number", http.StatusBadRequest)
+				return false
+			}
+		case "file":
+			req.Files = append(req.Files, models.DisputeFile{FileName: part.FileName(), Data: value})
+		}
+	}
+
+	if err := middleware.Validate(req); err != nil {
+		h.logger.WithError(err).Warn("Request body failed validation")
+		middleware.WriteValidationError(w, err)
+		return false
+	}
+	return true
+}
+
+// ListDisputesHandler handles listing of the disputes the user opened
+func (h *Handlers) ListDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.listDisputes(w, r, userID)
+}
+
+// GetDisputeHandler handles retrieval of a dispute the user opened with its attachments
+func (h *Handlers) GetDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dispute, err := h.disputeService.GetUserDispute(r.Context(), userID, disputeID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get dispute")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispute)
+}
+
+// AdminListDisputesHandler handles listing of all disputes. Open disputes form
+// the review queue.
+func (h *Handlers) AdminListDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	h.listDisputes(w, r, 0)
+}
+
+func (h *Handlers) listDisputes(w http.ResponseWriter, r *http.Request, userID int64) {
+	page, err := pagination.Parse(r.URL.Query(), repository.DisputePageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	disputes, total, err := h.disputeService.ListDisputes(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list disputes")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(disputes)
+}
+
+// AdminGetDisputeHandler handles retrieval of any dispute with its attachments
+func (h *Handlers) AdminGetDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+		return
+	}
+
+	dispute, err := h.disputeService.GetDispute(r.Context(), disputeID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get dispute")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispute)
+}
+
+// GetDisputeAttachmentURLHandler handles the issue of a signed URL to view a
+// file attached to a dispute for review
+func (h *Handlers) GetDisputeAttachmentURLHandler(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+		return
+	}
+	attachmentID, err := strconv.ParseInt(mux.Vars(r)["attachment_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	url, err := h.disputeService.AttachmentURL(r.Context(), adminID, disputeID, attachmentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get dispute attachment URL")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+// TakeDisputeHandler handles taking an open dispute under review, which credits
+// the disputed amount provisionally
+func (h *Handlers) TakeDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+		return
+	}
+
+	reviewerID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dispute, err := h.disputeService.TakeDispute(r.Context(), reviewerID, disputeID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to take dispute")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispute)
+}
+
+// RefundDisputeHandler handles upholding a dispute under review, which makes the
+// provisional credit final
+func (h *Handlers) RefundDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeDispute(w, r, models.DisputeStatusRefunded)
+}
+
+// ResolveDisputeHandler handles closing a dispute under review that was settled
+// otherwise, which reverses the provisional credit
+func (h *Handlers) ResolveDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeDispute(w, r, models.DisputeStatusResolved)
+}
+
+// DeclineDisputeHandler handles turning down a dispute under review, which
+// reverses the provisional credit
+func (h *Handlers) DeclineDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeDispute(w, r, models.DisputeStatusDeclined)
+}
+
+func (h *Handlers) closeDispute(w http.ResponseWriter, r *http.Request, status models.DisputeStatus) {
+	disputeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewDisputeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	reviewerID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dispute *models.Dispute
+	switch status {
+	case models.DisputeStatusRefunded:
+		dispute, err = h.disputeService.RefundDispute(r.Context(), reviewerID, disputeID, req.Comment)
+	case models.DisputeStatusResolved:
+		dispute, err = h.disputeService.ResolveDispute(r.Context(), reviewerID, disputeID, req.Comment)
+	default:
+		dispute, err = h.disputeService.DeclineDispute(r.Context(), reviewerID, disputeID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review dispute")
+		http.Error(w, err.Error(), disputeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispute)
+}
+
+func disputeErrorStatus(err error) int {
+	switch err.Error() {
+	case "transaction not found", "dispute not found", "attachment not found":
+		return http.StatusNotFound
+	case "transaction is already disputed", "dispute is not open", "dispute is not under review":
+		return http.StatusConflict
+	case "dispute must be reviewed by someone else", "dispute is under review by someone else",
+		"unauthorized: account does not belong to user", "unauthorized: view-only access to the account":
+		return http.StatusForbidden
+	case "internal server error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
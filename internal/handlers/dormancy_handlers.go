@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// ReactivateAccountHandler handles the confirmation that a dormant account is
+// in use, after which it may be debited again
+func (h *Handlers) ReactivateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	account, err := h.dormancyService.ReactivateAccount(r.Context(), userID, accountID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reactivate account")
+		http.Error(w, err.Error(), dormancyErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+func dormancyErrorStatus(err error) int {
+	if errors.Is(err, service.ErrAccountNotDormant) {
+		return http.StatusConflict
+	}
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.HasSuffix(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"):
+		return http.StatusForbidden
+	case msg == "account is closed":
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// writeExternalTransferError maps external transfer errors to HTTP statuses
+func (h *Handlers) writeExternalTransferError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	case strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrAccountDormant):
+		http.Error(w, msg, http.StatusForbidden)
+	default:
+		http.Error(w, msg, http.StatusBadRequest)
+	}
+}
+
+// CreateExternalTransferHandler handles a transfer to an account at another bank
+func (h *Handlers) CreateExternalTransferHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateExternalTransferRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.externalTransferService.CreateTransfer(r.Context(), userID, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create external transfer")
+		h.writeExternalTransferError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// GetExternalTransfersHandler handles listing of the current user's transfers to other banks
+func (h *Handlers) GetExternalTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfers, err := h.externalTransferService.GetUserTransfers(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get external transfers")
+		h.writeExternalTransferError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+// GetExternalTransferHandler handles retrieval of a transfer to another bank
+func (h *Handlers) GetExternalTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transferID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.externalTransferService.GetTransfer(r.Context(), userID, transferID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get external transfer")
+		h.writeExternalTransferError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// ReturnExternalTransferHandler handles a return of a transfer reported by the payment rail
+func (h *Handlers) ReturnExternalTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transferID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReturnExternalTransferRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.externalTransferService.ReturnTransfer(r.Context(), adminID, transferID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to return external transfer")
+		h.writeExternalTransferError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/export"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+// GetFileHandler handles the download of a file of the local object storage
+// through a signed URL. The signature stands in for authentication, so the
+// route is public; with S3 storage signed URLs point at the bucket instead.
+func (h *Handlers) GetFileHandler(w http.ResponseWriter, r *http.Request) {
+	local, ok := h.objectStore.(*storage.Local)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	query := r.URL.Query()
+	if err := local.VerifyURL(key, query.Get("expires"), query.Get("signature"), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data, err := local.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		h.logger.WithError(err).Error("Failed to read stored file")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(key)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(data)
+}
+
+// writeExport writes an exported report as an attachment or, with
+// ?delivery=link, stores it and responds with a signed URL to download it from
+func (h *Handlers) writeExport(w http.ResponseWriter, r *http.Request, fileName, contentType string, data []byte) {
+	if r.URL.Query().Get("delivery") == "link" {
+		url, err := h.exportService.Publish(r.Context(), fileName, contentType, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(url)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	w.Write(data)
+}
+
+// streamXLSX writes a workbook as an attachment while it is built. Once the
+// first bytes are sent the status cannot change, so a failure midway is only
+// logged and leaves the client with a truncated file.
+func (h *Handlers) streamXLSX(w http.ResponseWriter, fileName string, write func(io.Writer) error) {
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	if err := write(w); err != nil {
+		h.logger.WithError(err).Errorf("Failed to write %s", fileName)
+	}
+}
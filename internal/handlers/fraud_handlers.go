@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// writeFraudError answers a transaction stopped by the fraud rules and reports
+// whether err was such a stop. Held transactions are accepted for a review, the
+// reasons of a hold or rejection are not disclosed to the client.
+func (h *Handlers) writeFraudError(w http.ResponseWriter, err error) bool {
+	var held *fraud.HoldError
+	switch {
+	case errors.As(err, &held):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.FraudHoldResponse{
+			CheckID: held.CheckID,
+			Status:  models.FraudCheckStatusHeld,
+			Message: "Transaction is held for review",
+		})
+		return true
+	case errors.Is(err, fraud.ErrRejected):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return true
+	default:
+		return false
+	}
+}
+
+// ListFraudChecksHandler handles fraud check listing. Held checks form the review queue.
+func (h *Handlers) ListFraudChecksHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.FraudCheckStatus(r.URL.Query().Get("status"))
+	decision := models.FraudDecision(r.URL.Query().Get("decision"))
+
+	checks, err := h.fraudService.ListChecks(r.Context(), status, decision)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list fraud checks")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checks)
+}
+
+// GetFraudCheckHandler handles fraud check retrieval
+func (h *Handlers) GetFraudCheckHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	checkID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid fraud check ID")
+		http.Error(w, "Invalid fraud check ID", http.StatusBadRequest)
+		return
+	}
+
+	check, err := h.fraudService.GetCheck(r.Context(), checkID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get fraud check")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
+
+// ApproveFraudCheckHandler handles the release of a held transaction, which executes it
+func (h *Handlers) ApproveFraudCheckHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewFraudCheck(w, r, true)
+}
+
+// RejectFraudCheckHandler handles the rejection of a held transaction
+func (h *Handlers) RejectFraudCheckHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewFraudCheck(w, r, false)
+}
+
+func (h *Handlers) reviewFraudCheck(w http.ResponseWriter, r *http.Request, approve bool) {
+	vars := mux.Vars(r)
+	checkID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid fraud check ID")
+		http.Error(w, "Invalid fraud check ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewFraudCheckRequest
+	if r.ContentLength > 0 {
+		if err := middleware.DecodeJSON(r.Body, &req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var check *models.FraudCheck
+	if approve {
+		check, err = h.accountService.ReleaseHeldTransaction(r.Context(), adminID, checkID, req.Comment)
+	} else {
+		check, err = h.fraudService.Reject(r.Context(), adminID, checkID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review fraud check")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The review stands even if a payment request held with the check is not updated
+	if err := h.paymentRequestService.SettleFraudCheck(r.Context(), check); err != nil {
+		h.logger.WithError(err).Errorf("Failed to settle payment request held by fraud check %d", check.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/graphql"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// GraphQLHandler executes a GraphQL request for the authenticated user. Errors
+// of the request are reported in the errors of the response, so it is answered
+// with 200 unless the body cannot be read.
+func (h *Handlers) GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authctx.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req, err := graphql.DecodeRequest(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&graphql.Response{Errors: []*graphql.Error{{Message: err.Error()}}})
+		return
+	}
+
+	resp := h.graphqlSchema.Execute(r.Context(), req)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.WithError(err).Error("Failed to write GraphQL response")
+	}
+}
+
+// GraphQLSchemaHandler returns the GraphQL schema in the schema definition language
+func (h *Handlers) GraphQLSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(h.graphqlSchema.SDL()))
+}
+
+// graphqlUser returns the user a GraphQL request is executed for
+func graphqlUser(ctx context.Context) (int64, error) {
+	userID, ok := authctx.UserIDFromContext(ctx)
+	if !ok {
+		return 0, errors.New("unauthorized")
+	}
+	return userID, nil
+}
+
+// optionalID returns nil for a zero ID, which stands for no account
+func optionalID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// transferResult is the outcome of a transfer mutation: completed, or held for
+// a fraud review identified by the check ID
+type transferResult struct {
+	Status       string `json:"status"`
+	FraudCheckID *int64 `json:"fraud_check_id,omitempty"`
+}
+
+// newGraphQLSchema builds the GraphQL API over the account and credit services.
+// Nested lists are Batch fields, which load the transactions of all the
+// accounts, or the schedules of all the credits, of a response in one query.
+func (h *Handlers) newGraphQLSchema() *graphql.Schema {
+	transactionType := &graphql.Object{
+		Name:        "Transaction",
+		Description: "A transaction posted on an account",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: graphql.ID},
+			{Name: "fromAccountId", Type: graphql.ID, Description: "Null for money entering the bank",
+				Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+					return optionalID(source.(*models.Transaction).FromAccountID), nil
+				}},
+			{Name: "toAccountId", Type: graphql.ID, Description: "Null for money leaving the bank",
+				Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+					return optionalID(source.(*models.Transaction).ToAccountID), nil
+				}},
+			{Name: "amount", Type: graphql.Float},
+			{Name: "type", Type: graphql.String},
+			{Name: "description", Type: graphql.String},
+			{Name: "createdAt", Type: graphql.String},
+		},
+	}
+
+	accountType := &graphql.Object{
+		Name:        "Account",
+		Description: "An account the user owns",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: graphql.ID},
+			{Name: "number", Type: graphql.String},
+			{Name: "name", Type: graphql.String},
+			{Name: "primary", Type: graphql.Boolean},
+			{Name: "currency", Type: graphql.String},
+			{Name: "balance", Type: graphql.Float},
+			{Name: "availableBalance", Type: graphql.Float, Description: "Balance less holds, plus the undrawn overdraft",
+				Resolve: func(_ context.Context, source interface{}, _ graphql.Args) (interface{}, error) {
+					return source.(*models.Account).AvailableBalance(), nil
+				}},
+			{Name: "heldAmount", Type: graphql.Float},
+			{Name: "overdraftLimit", Type: graphql.Float},
+			{Name: "createdAt", Type: graphql.String},
+			{
+				Name:        "transactions",
+				Description: "Latest transactions of the account, newest first",
+				Type:        &graphql.List{Of: transactionType},
+				Args:        transactionFilterArgs(),
+				Batch: func(ctx context.Context, sources []interface{}, args graphql.Args) ([]interface{}, error) {
+					filter, err := transactionFilter(args)
+					if err != nil {
+						return nil, err
+					}
+					ids := make([]int64, 0, len(sources))
+					seen := make(map[int64]bool, len(sources))
+					for _, source := range sources {
+						if id := source.(*models.Account).ID; !seen[id] {
+							seen[id] = true
+							ids = append(ids, id)
+						}
+					}
+					transactions, err := h.accountService.ListTransactionsByAccounts(ctx, ids, filter)
+					if err != nil {
+						return nil, err
+					}
+					results := make([]interface{}, len(sources))
+					for i, source := range sources {
+						list := transactions[source.(*models.Account).ID]
+						if list == nil {
+							list = []*models.Transaction{}
+						}
+						results[i] = list
+					}
+					return results, nil
+				},
+			},
+		},
+	}
+
+	installmentType := &graphql.Object{
+		Name:        "Installment",
+		Description: "An installment of the payment schedule of a credit",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: graphql.ID},
+			{Name: "dueDate", Type: graphql.String},
+			{Name: "amount", Type: graphql.Float},
+			{Name: "principal", Type: graphql.Float},
+			{Name: "interest", Type: graphql.Float},
+			{Name: "penalty", Type: graphql.Float},
+			{Name: "paidAmount", Type: graphql.Float},
+			{Name: "remaining", Type: graphql.Float, Description: "Part of the installment and its penalty still to be paid"},
+			{Name: "status", Type: graphql.String},
+			{Name: "paidAt", Type: graphql.String},
+		},
+	}
+
+	creditType := &graphql.Object{
+		Name:        "Credit",
+		Description: "A credit of the user",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: graphql.ID},
+			{Name: "accountId", Type: graphql.ID},
+			{Name: "amount", Type: graphql.Float},
+			{Name: "remainingAmount", Type: graphql.Float},
+			{Name: "interestRate", Type: graphql.Float},
+			{Name: "rateType", Type: graphql.String},
+			{Name: "termMonths", Type: graphql.Int},
+			{Name: "scheduleType", Type: graphql.String},
+			{Name: "status", Type: graphql.String},
+			{Name: "createdAt", Type: graphql.String},
+			{
+				Name:        "schedule",
+				Description: "Installments of the credit by due date",
+				Type:        &graphql.List{Of: installmentType},
+				Batch: func(ctx context.Context, sources []interface{}, _ graphql.Args) ([]interface{}, error) {
+					ids := make([]int64, len(sources))
+					for i, source := range sources {
+						ids[i] = source.(*models.Credit).ID
+					}
+					schedules, err := h.creditService.GetPaymentSchedules(ctx, ids)
+					if err != nil {
+						return nil, err
+					}
+					results := make([]interface{}, len(sources))
+					for i, id := range ids {
+						schedule := schedules[id]
+						if schedule == nil {
+							schedule = []*models.PaymentSchedule{}
+						}
+						results[i] = schedule
+					}
+					return results, nil
+				},
+			},
+		},
+	}
+
+	userType := &graphql.Object{
+		Name:        "User",
+		Description: "Profile of the authenticated user",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: graphql.ID},
+			{Name: "email", Type: graphql.String},
+			{Name: "username", Type: graphql.String},
+			{Name: "firstName", Type: graphql.String},
+			{Name: "lastName", Type: graphql.String},
+			{Name: "phoneNumber", Type: graphql.String},
+			{Name: "role", Type: graphql.String},
+			{Name: "status", Type: graphql.String},
+			{Name: "kycStatus", Type: graphql.String},
+			{Name: "createdAt", Type: graphql.String},
+		},
+	}
+
+	transferResultType := &graphql.Object{
+		Name:        "TransferResult",
+		Description: "Outcome of a transfer: completed, or held for a fraud review",
+		Fields: []*graphql.FieldDef{
+			{Name: "status", Type: graphql.String},
+			{Name: "fraudCheckId", Type: graphql.ID},
+		},
+	}
+
+	// userCredit returns a credit of the user, hiding the credits of others
+	userCredit := func(ctx context.Context, userID, creditID int64) (*models.Credit, error) {
+		credit, err := h.creditService.GetCreditByID(ctx, creditID)
+		if err != nil || credit.UserID != userID {
+			return nil, errors.New("credit not found")
+		}
+		return credit, nil
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.FieldDef{
+			{
+				Name: "me",
+				Type: userType,
+				Resolve: func(ctx context.Context, _ interface{}, _ graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					return h.userService.GetProfile(ctx, userID)
+				},
+			},
+			{
+				Name: "accounts",
+				Type: &graphql.List{Of: accountType},
+				Resolve: func(ctx context.Context, _ interface{}, _ graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					accounts, err := h.accountService.GetUserAccounts(ctx, userID)
+					if err != nil {
+						return nil, err
+					}
+					if accounts == nil {
+						accounts = []*models.Account{}
+					}
+					return accounts, nil
+				},
+			},
+			{
+				Name: "account",
+				Type: accountType,
+				Args: []*graphql.ArgDef{{Name: "id", Type: &graphql.NonNull{Of: graphql.ID}}},
+				Resolve: func(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					accountID, err := args.ID("id")
+					if err != nil {
+						return nil, err
+					}
+					return h.accountService.AuthorizeAccount(ctx, userID, accountID, false)
+				},
+			},
+			{
+				Name:        "transactions",
+				Description: "Latest transactions of an account the user may view, newest first",
+				Type:        &graphql.List{Of: transactionType},
+				Args: append([]*graphql.ArgDef{{Name: "accountId", Type: &graphql.NonNull{Of: graphql.ID}}},
+					transactionFilterArgs()...),
+				Resolve: func(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					accountID, err := args.ID("accountId")
+					if err != nil {
+						return nil, err
+					}
+					filter, err := transactionFilter(args)
+					if err != nil {
+						return nil, err
+					}
+					if _, err := h.accountService.AuthorizeAccount(ctx, userID, accountID, false); err != nil {
+						return nil, err
+					}
+					transactions, err := h.accountService.ListTransactionsByAccounts(ctx, []int64{accountID}, filter)
+					if err != nil {
+						return nil, err
+					}
+					if transactions[accountID] == nil {
+						return []*models.Transaction{}, nil
+					}
+					return transactions[accountID], nil
+				},
+			},
+			{
+				Name: "credits",
+				Type: &graphql.List{Of: creditType},
+				Resolve: func(ctx context.Context, _ interface{}, _ graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					credits, err := h.creditService.GetCreditsByUserID(ctx, userID)
+					if err != nil {
+						return nil, errors.New("internal server error")
+					}
+					if credits == nil {
+						credits = []*models.Credit{}
+					}
+					return credits, nil
+				},
+			},
+			{
+				Name: "credit",
+				Type: creditType,
+				Args: []*graphql.ArgDef{{Name: "id", Type: &graphql.NonNull{Of: graphql.ID}}},
+				Resolve: func(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					creditID, err := args.ID("id")
+					if err != nil {
+						return nil, err
+					}
+					return userCredit(ctx, userID, creditID)
+				},
+			},
+		},
+	}
+
+	mutation := &graphql.Object{
+		Name: "Mutation",
+		Fields: []*graphql.FieldDef{
+			{
+				Name:        "transfer",
+				Description: "Transfers money from an account the user operates to an account given by its ID or number",
+				Type:        transferResultType,
+				Args: []*graphql.ArgDef{
+					{Name: "fromAccountId", Type: &graphql.NonNull{Of: graphql.ID}},
+					{Name: "toAccountId", Type: graphql.ID},
+					{Name: "toAccountNumber", Type: graphql.String},
+					{Name: "amount", Type: &graphql.NonNull{Of: graphql.Float}},
+				},
+				Resolve: func(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					req := &models.TransferRequest{}
+					if req.FromAccountID, err = args.ID("fromAccountId"); err != nil {
+						return nil, err
+					}
+					if _, ok := args.String("toAccountId"); ok {
+						if req.ToAccountID, err = args.ID("toAccountId"); err != nil {
+							return nil, err
+						}
+					}
+					req.ToAccountNumber, _ = args.String("toAccountNumber")
+					req.Amount, _ = args.Float("amount")
+					if err := middleware.Validate(req); err != nil {
+						return nil, err
+					}
+
+					if _, err := h.accountService.AuthorizeAccount(ctx, userID, req.FromAccountID, true); err != nil {
+						return nil, err
+					}
+					err = h.accountService.Transfer(ctx, req, middleware.GetClientInfoFromContext(ctx))
+					var held *fraud.HoldError
+					switch {
+					case errors.As(err, &held):
+						return &transferResult{Status: string(models.FraudCheckStatusHeld), FraudCheckID: &held.CheckID}, nil
+					case err != nil:
+						return nil, err
+					}
+					return &transferResult{Status: "completed"}, nil
+				},
+			},
+			{
+				Name:        "payCredit",
+				Description: "Pays outstanding installments of a credit of the user from its account",
+				Type:        creditType,
+				Args: []*graphql.ArgDef{
+					{Name: "creditId", Type: &graphql.NonNull{Of: graphql.ID}},
+					{Name: "amount", Type: &graphql.NonNull{Of: graphql.Float}},
+				},
+				Resolve: func(ctx context.Context, _ interface{}, args graphql.Args) (interface{}, error) {
+					userID, err := graphqlUser(ctx)
+					if err != nil {
+						return nil, err
+					}
+					creditID, err := args.ID("creditId")
+					if err != nil {
+						return nil, err
+					}
+					req := &models.PayCreditRequest{}
+					req.Amount, _ = args.Float("amount")
+					if err := middleware.Validate(req); err != nil {
+						return nil, err
+					}
+					if err := h.creditService.PayCredit(ctx, userID, creditID, req); err != nil {
+						return nil, err
+					}
+					return userCredit(ctx, userID, creditID)
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{Query: query, Mutation: mutation}
+}
+
+// transactionFilterArgs defines the arguments filtering the transactions listed
+func transactionFilterArgs() []*graphql.ArgDef {
+	return []*graphql.ArgDef{
+		{Name: "type", Type: graphql.String, Description: "Transaction type, e.g. transfer"},
+		{Name: "from", Type: graphql.String, Description: "Earliest date, YYYY-MM-DD or RFC 3339"},
+		{Name: "to", Type: graphql.String, Description: "Latest date, YYYY-MM-DD (inclusive) or RFC 3339"},
+		{Name: "minAmount", Type: graphql.Float},
+		{Name: "maxAmount", Type: graphql.Float},
+		{Name: "first", Type: graphql.Int, Description: "Transactions listed per account, 20 by default"},
+	}
+}
+
+// transactionFilter reads the filter of the transactions listed from the
+// arguments of transactionFilterArgs
+func transactionFilter(args graphql.Args) (models.TransactionFilter, error) {
+	var filter models.TransactionFilter
+	filter.Type, _ = args.String("type")
+	filter.MinAmount, _ = args.Float("minAmount")
+	filter.MaxAmount, _ = args.Float("maxAmount")
+	if first, ok := args.Int("first"); ok {
+		if first < 1 {
+			return filter, errors.New("first must be positive")
+		}
+		filter.Limit = first
+	}
+
+	for _, bound := range []struct {
+		name string
+		time *time.Time
+		end  bool
+	}{{"from", &filter.From, false}, {"to", &filter.To, true}} {
+		value, ok := args.String(bound.name)
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*bound.time = t
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s date, use YYYY-MM-DD or RFC 3339", bound.name)
+		}
+		if bound.end {
+			// A date bounds the listing to the end of that day
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		*bound.time = t
+	}
+	return filter, nil
+}
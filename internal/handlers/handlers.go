@@ -1,55 +1,455 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/aml"
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/cache"
 	"github.com/Abigotado/abi_banking/internal/config"
 	"github.com/Abigotado/abi_banking/internal/database"
+	"github.com/Abigotado/abi_banking/internal/events"
+	"github.com/Abigotado/abi_banking/internal/export"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/graphql"
+	"github.com/Abigotado/abi_banking/internal/integration/billers"
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/jobs"
 	"github.com/Abigotado/abi_banking/internal/middleware"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/pii"
 	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/scheduler"
 	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/Abigotado/abi_banking/internal/storage"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 type Handlers struct {
-	userService    *service.UserService
-	accountService *service.AccountService
-	creditService  *service.CreditService
-	cardService    *service.CardService
-	logger         *logrus.Logger
+	userService                *service.UserService
+	accountService             *service.AccountService
+	accountClosureService      *service.AccountClosureService
+	creditService              *service.CreditService
+	cardService                *service.CardService
+	cardAuthorizationService   *service.CardAuthorizationService
+	accountMemberService       *service.AccountMemberService
+	externalTransferService    *service.ExternalTransferService
+	incomingPaymentService     *service.IncomingPaymentService
+	ownershipTransferService   *service.OwnershipTransferService
+	creditRestructuringService *service.CreditRestructuringService
+	creditRateService          *service.CreditRateService
+	creditInsuranceService     *service.CreditInsuranceService
+	collectionService          *service.CollectionService
+	depositService             *service.DepositService
+	rateService                *service.RateService
+	scoringService             *service.ScoringService
+	cardBatchService           *service.CardBatchService
+	transferBatchService       *service.TransferBatchService
+	reconciliationService      *service.ReconciliationService
+	dashboardService           *service.DashboardService
+	privacyService             *service.PrivacyService
+	creditApplicationService   *service.CreditApplicationService
+	parameterService           *service.ParameterService
+	searchService              *service.SearchService
+	webhookService             *service.WebhookService
+	fraudService               *fraud.Service
+	limitService               *service.LimitService
+	apiKeyService              *service.APIKeyService
+	branchService              *service.BranchService
+	reportingService           *service.ReportingService
+	amlService                 *aml.Service
+	kycService                 *service.KYCService
+	statementService           *service.StatementService
+	exportService              *service.ExportService
+	jobService                 *service.JobService
+	sandboxService             *service.SandboxService // nil outside sandbox mode
+	receiptService             *service.ReceiptService
+	disputeService             *service.DisputeService
+	collateralService          *service.CollateralService
+	payeeService               *service.PayeeService
+	paymentRequestService      *service.PaymentRequestService
+	dormancyService            *service.DormancyService
+	alertService               *service.AlertService
+	budgetService              *service.BudgetService
+	forecastService            *service.ForecastService
+	transactionReportService   *service.TransactionReportService
+	bankAnalyticsService       *service.BankAnalyticsService
+	paymentQRService           *service.PaymentQRService
+	billerService              *service.BillerService
+	objectStore                storage.Storage
+	paymentScheduler           *scheduler.PaymentScheduler
+	reconciliationScheduler    *scheduler.ReconciliationScheduler
+	jobScheduler               *scheduler.JobScheduler
+	healthMonitor              *alerting.HealthMonitor
+	eventStream                *events.Stream
+	graphqlSchema              *graphql.Schema
+	openAPISpec                []byte
+	openAPISpecURL             string
+	dashboardURL               string
+	logger                     *logrus.Logger
 }
 
-func New(cfg *config.Config, logger *logrus.Logger) *Handlers {
-	creditRepo := repository.NewCreditRepository()
+func New(
+	cfg *config.Config,
+	logger *logrus.Logger,
+	healthMonitor *alerting.HealthMonitor,
+	rateService *service.RateService,
+	jobRunner *jobs.Runner,
+	relay *outbox.Relay,
+	eventPublisher *events.Publisher,
+	eventStream *events.Stream,
+	parameterService *service.ParameterService,
+	appCache cache.Cache,
+	piiCipher *pii.Cipher,
+	objectStore storage.Storage,
+	billerProviders *billers.Registry,
+) *Handlers {
+	creditRepo := repository.NewCreditRepository(database.DB)
 	cardRepo := repository.NewCardRepository(database.DB, logger)
-	accountRepo := repository.NewAccountRepository()
-
-	return &Handlers{
-		userService:    service.NewUserService(logger),
-		accountService: service.NewAccountService(logger),
-		creditService:  service.NewCreditService(creditRepo, logger),
-		cardService:    service.NewCardService(cardRepo, accountRepo, logger),
-		logger:         logger,
+	accountRepo := repository.NewAccountRepository(database.DB, logger)
+	accountRepo.SetCache(appCache, cfg.Cache.AccountTTL)
+	userRepo := repository.NewUserRepository(database.DB)
+	searchRepo := repository.NewSearchRepository(database.DB, logger)
+	userRepo.SetCipher(piiCipher)
+	searchRepo.SetCipher(piiCipher)
+	auditRepo := repository.NewAuditRepository(database.DB, logger)
+	ownershipTransferRepo := repository.NewOwnershipTransferRepository(database.DB, logger)
+	settingsRepo := repository.NewUserSettingsRepository(database.DB, logger)
+	holdRepo := repository.NewHoldRepository(database.DB, logger)
+	sessionRepo := repository.NewSessionRepository(database.DB, logger)
+	sessionRepo.SetCache(appCache, cfg.Cache.SessionTTL)
+
+	notificationService := service.NewNotificationService(&cfg.SMTP, &cfg.SMS, userRepo, settingsRepo, relay, logger)
+	// In sandbox mode emails are captured for GET /sandbox/emails instead of sent
+	var sandboxService *service.SandboxService
+	if cfg.Sandbox.Enabled {
+		mailbox := smtp.NewMailbox()
+		notificationService.SetEmailSender(mailbox)
+		sandboxService = service.NewSandboxService(repository.NewSandboxRepository(database.DB, logger), mailbox, logger)
 	}
+	creditService := service.NewCreditService(creditRepo, accountRepo, parameterService, eventPublisher, logger)
+	creditService.SetCache(appCache, cfg.Cache.AnalyticsTTL)
+	scoringService := service.NewScoringService(
+		repository.NewCreditScoreRepository(database.DB, logger), creditRepo, accountRepo, parameterService, logger,
+	)
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(database.DB, logger), jobRunner, relay, logger)
+	fraudService := fraud.NewService(&cfg.Fraud, repository.NewFraudRepository(database.DB, logger), auditRepo, logger)
+	limitService := service.NewLimitService(
+		repository.NewLimitRepository(database.DB, logger), userRepo, auditRepo, &cfg.Limits, logger,
+	)
+	accountService := service.NewAccountService(
+		accountRepo, creditRepo, userRepo, auditRepo, notificationService, webhookService, eventPublisher,
+		fraudService, limitService, &cfg.Bank, logger,
+	)
+	accountService.SetCache(appCache, cfg.Cache.AnalyticsTTL)
+	payeeRepo := repository.NewPayeeRepository(database.DB, logger)
+	accountService.SetPayees(payeeRepo)
+	externalTransferService := service.NewExternalTransferService(
+		repository.NewExternalTransferRepository(database.DB, logger), holdRepo, accountRepo, auditRepo, limitService,
+		notificationService, webhookService, &cfg.ExternalTransfers, logger,
+	)
+	cardService := service.NewCardService(
+		cardRepo, accountRepo, rateService, parameterService, notificationService, eventPublisher, logger,
+	)
+	reconciliationService := service.NewReconciliationService(
+		repository.NewReconciliationRepository(database.DB, logger), cfg.Reconciliation.Hour, logger,
+	)
+	privacyService := service.NewPrivacyService(
+		userRepo, settingsRepo, accountRepo, cardRepo, creditRepo, sessionRepo, auditRepo, cfg.UserErasure.RetentionDays, logger,
+	)
+	branchService := service.NewBranchService(
+		repository.NewBranchRepository(database.DB, logger), userRepo, auditRepo, &cfg.Branches, logger,
+	)
+	amlService := aml.NewService(
+		&cfg.AML, repository.NewAMLRepository(database.DB, logger), userRepo, auditRepo, rateService, cfg.Bank.BIC, logger,
+	)
+	paymentRequestService := service.NewPaymentRequestService(
+		repository.NewPaymentRequestRepository(database.DB, logger), accountRepo, userRepo, accountService,
+		notificationService, &cfg.PaymentRequests, logger,
+	)
+	creditRateService := service.NewCreditRateService(
+		repository.NewCreditRateRepository(database.DB, logger), creditRepo, rateService, notificationService, logger,
+	)
+	creditApplicationService := service.NewCreditApplicationService(
+		repository.NewCreditApplicationRepository(database.DB, logger), accountRepo, userRepo, auditRepo,
+		creditService, scoringService, parameterService, rateService, notificationService, webhookService, jobRunner,
+		logger,
+	)
+	collectionService := service.NewCollectionService(
+		repository.NewCollectionRepository(database.DB, logger), creditRepo, parameterService, notificationService, logger,
+	)
+	accountService.SetCollections(collectionService)
+	cardService.SetCollections(collectionService)
+	creditApplicationService.SetCollections(collectionService)
+	creditInsuranceService := service.NewCreditInsuranceService(
+		repository.NewCreditInsuranceRepository(database.DB, logger), creditRepo, auditRepo, parameterService,
+		notificationService, logger,
+	)
+	creditApplicationService.SetInsurance(creditInsuranceService)
+	depositService := service.NewDepositService(
+		repository.NewDepositRepository(database.DB, logger), accountRepo, parameterService, rateService, notificationService, logger,
+	)
+	privacyService.SetDeposits(depositService)
+	privacyService.SetJobs(jobRunner, objectStore)
+	alertService := service.NewAlertService(
+		repository.NewAlertRepository(database.DB, logger), accountRepo, notificationService, logger,
+	)
+	budgetService := service.NewBudgetService(
+		repository.NewBudgetRepository(database.DB, logger), settingsRepo, rateService, notificationService, logger,
+	)
+	forecastRepo := repository.NewForecastRepository(database.DB, logger)
+	bankAnalyticsService := service.NewBankAnalyticsService(repository.NewBankAnalyticsRepository(database.DB, logger), logger)
+	bankAnalyticsService.SetCache(appCache, cfg.Cache.AnalyticsTTL)
+	statementService := service.NewStatementService(
+		repository.NewStatementRepository(database.DB, logger), settingsRepo, userRepo, accountRepo,
+		notificationService, jobRunner, objectStore, cfg.Storage.URLExpiry, logger,
+	)
+	accountClosureService := service.NewAccountClosureService(
+		accountRepo, cardRepo, creditRepo, statementService, notificationService, auditRepo, logger,
+	)
+	accountClosureService.SetDeposits(depositService)
+	dormancyService := service.NewDormancyService(accountRepo, parameterService, notificationService, auditRepo, logger)
+
+	h := &Handlers{
+		userService: service.NewUserService(
+			userRepo, settingsRepo, sessionRepo, repository.NewLoginAttemptRepository(database.DB, logger), auditRepo,
+			notificationService, service.NewPasswordPolicy(&cfg.PasswordPolicy, logger), branchService, eventPublisher,
+			&cfg.Login, logger,
+		),
+		accountService:        accountService,
+		accountClosureService: accountClosureService,
+		creditService:         creditService,
+		cardService:           cardService,
+		cardAuthorizationService: service.NewCardAuthorizationService(
+			repository.NewCardAuthorizationRepository(database.DB, logger), holdRepo, cardRepo, accountRepo, cardService,
+			cfg.Acquiring.AuthorizationTTL, logger,
+		),
+		accountMemberService: service.NewAccountMemberService(
+			repository.NewAccountMemberRepository(database.DB, logger), accountRepo, userRepo, notificationService, logger,
+		),
+		externalTransferService: externalTransferService,
+		incomingPaymentService: service.NewIncomingPaymentService(
+			repository.NewIncomingPaymentRepository(database.DB, logger), accountRepo, notificationService, logger,
+		),
+		ownershipTransferService: service.NewOwnershipTransferService(
+			ownershipTransferRepo, accountRepo, cardRepo, userRepo, auditRepo, notificationService, logger,
+		),
+		creditRestructuringService: service.NewCreditRestructuringService(
+			repository.NewCreditRestructuringRepository(database.DB, logger), creditRepo, auditRepo, notificationService, logger,
+		),
+		creditRateService:        creditRateService,
+		collectionService:        collectionService,
+		depositService:           depositService,
+		dormancyService:          dormancyService,
+		alertService:             alertService,
+		budgetService:            budgetService,
+		bankAnalyticsService:     bankAnalyticsService,
+		transactionReportService: service.NewTransactionReportService(forecastRepo, logger),
+		forecastService: service.NewForecastService(
+			forecastRepo, accountRepo, creditRepo, rateService, logger,
+		),
+		rateService:    rateService,
+		scoringService: scoringService,
+		cardBatchService: service.NewCardBatchService(
+			repository.NewCardBatchRepository(database.DB, logger), cardRepo, accountRepo, auditRepo, jobRunner, logger,
+		),
+		transferBatchService: service.NewTransferBatchService(
+			repository.NewTransferBatchRepository(database.DB, logger), accountRepo, accountService, jobRunner,
+			&cfg.TransferBatches, logger,
+		),
+		creditApplicationService: creditApplicationService,
+		creditInsuranceService:   creditInsuranceService,
+		parameterService:         parameterService,
+		searchService:            service.NewSearchService(searchRepo, logger),
+		webhookService:           webhookService,
+		fraudService:             fraudService,
+		limitService:             limitService,
+		branchService:            branchService,
+		reportingService: service.NewReportingService(
+			repository.NewAccountingRepository(database.DB, logger), auditRepo, &cfg.Accounting, logger,
+		),
+		apiKeyService: service.NewAPIKeyService(
+			repository.NewAPIKeyRepository(database.DB, logger), userRepo, auditRepo, cfg.APIKeys.RotationGracePeriod, logger,
+		),
+		paymentScheduler: scheduler.NewPaymentScheduler(
+			creditRepo, accountService, creditService, database.NewAdvisoryLocker(database.DB), cfg.Scheduler.Interval, logger,
+		),
+		reconciliationService: reconciliationService,
+		dashboardService: service.NewDashboardService(
+			repository.NewDashboardRepository(database.DB, logger), repository.NewOutboxRepository(database.DB, logger),
+			reconciliationService, healthMonitor, logger,
+		),
+		privacyService: privacyService,
+		reconciliationScheduler: scheduler.NewReconciliationScheduler(
+			reconciliationService, database.NewAdvisoryLocker(database.DB), cfg.Reconciliation.Interval, logger,
+		),
+		amlService: amlService,
+		kycService: service.NewKYCService(
+			repository.NewKYCRepository(database.DB, logger), auditRepo, objectStore, cfg.Storage.URLExpiry,
+			notificationService, &cfg.KYC, logger,
+		),
+		statementService: statementService,
+		exportService:    service.NewExportService(objectStore, cfg.Storage.URLExpiry, logger),
+		jobService: service.NewJobService(
+			repository.NewJobRepository(database.DB, logger), objectStore, cfg.Storage.URLExpiry, cfg.API.Prefix, logger,
+		),
+		sandboxService: sandboxService,
+		receiptService: service.NewReceiptService(accountRepo, &cfg.Bank, &cfg.Receipts, logger),
+		disputeService: service.NewDisputeService(
+			repository.NewDisputeRepository(database.DB, logger), accountRepo, auditRepo, objectStore,
+			cfg.Storage.URLExpiry, notificationService, &cfg.Disputes, logger,
+		),
+		collateralService: service.NewCollateralService(
+			repository.NewCollateralRepository(database.DB, logger), creditRepo, auditRepo, objectStore,
+			cfg.Storage.URLExpiry, &cfg.Collateral, logger,
+		),
+		payeeService: service.NewPayeeService(
+			payeeRepo, accountRepo, cardRepo, accountService, externalTransferService, auditRepo, notificationService,
+			&cfg.Payees, logger,
+		),
+		paymentRequestService: paymentRequestService,
+		paymentQRService: service.NewPaymentQRService(
+			accountRepo, userRepo, accountService, externalTransferService, &cfg.Bank, logger,
+		),
+		billerService: service.NewBillerService(
+			repository.NewBillerRepository(database.DB, logger), accountRepo, limitService, billerProviders, auditRepo,
+			notificationService, logger,
+		),
+		objectStore:   objectStore,
+		healthMonitor: healthMonitor,
+		eventStream:   eventStream,
+		dashboardURL:  cfg.API.Prefix + "/admin/dashboard",
+		logger:        logger,
+	}
+	h.graphqlSchema = h.newGraphQLSchema()
+
+	// Periodic jobs; those disabled in the configuration are not registered
+	notice := time.Duration(cfg.CardExpiry.NoticeDays) * 24 * time.Hour
+	h.jobScheduler = scheduler.NewJobScheduler(database.NewAdvisoryLocker(database.DB), logger)
+	for _, job := range []struct {
+		enabled bool
+		scheduler.Job
+	}{
+		{cfg.Statements.Enabled, scheduler.Job{
+			Name: "statement_scheduler", LockKey: "scheduler:statements", Interval: cfg.Statements.Interval,
+			Run: statementService.QueueMonthlyStatements,
+		}},
+		{cfg.CardExpiry.Enabled, scheduler.Job{
+			Name: "card_expiry_scheduler", LockKey: "scheduler:card_expiry", Interval: cfg.CardExpiry.Interval,
+			Run: func(ctx context.Context, now time.Time) (int, error) {
+				return cardService.ProcessCardExpiry(ctx, now, notice)
+			},
+		}},
+		{cfg.ExternalTransfers.Enabled, scheduler.Job{
+			Name: "external_transfer_scheduler", LockKey: "scheduler:external_transfers", Interval: cfg.ExternalTransfers.Interval,
+			Run: externalTransferService.ProcessTransfers,
+		}},
+		{cfg.UserErasure.Enabled, scheduler.Job{
+			Name: "user_erasure_scheduler", LockKey: "scheduler:user_erasure", Interval: cfg.UserErasure.Interval,
+			Run: func(ctx context.Context, now time.Time) (int, error) {
+				anonymized, err := privacyService.AnonymizeClosedUsers(ctx, now)
+				return int(anonymized), err
+			},
+		}},
+		{cfg.PaymentRequests.Enabled, scheduler.Job{
+			Name: "payment_request_scheduler", LockKey: "scheduler:payment_requests", Interval: cfg.PaymentRequests.Interval,
+			Run: paymentRequestService.ExpireRequests,
+		}},
+		{cfg.VariableRates.Enabled, scheduler.Job{
+			Name: "credit_rate_scheduler", LockKey: "scheduler:credit_rates", Interval: cfg.VariableRates.Interval,
+			Run: creditRateService.ApplyKeyRate,
+		}},
+		{cfg.Collections.Enabled, scheduler.Job{
+			Name: "collection_scheduler", LockKey: "scheduler:collections", Interval: cfg.Collections.Interval,
+			Run: collectionService.ProcessCollections,
+		}},
+		{cfg.Deposits.Enabled, scheduler.Job{
+			Name: "deposit_scheduler", LockKey: "scheduler:deposits", Interval: cfg.Deposits.Interval,
+			Run: depositService.ProcessDeposits,
+		}},
+		{cfg.Dormancy.Enabled, scheduler.Job{
+			Name: "dormancy_scheduler", LockKey: "scheduler:dormancy", Interval: cfg.Dormancy.Interval,
+			Run: dormancyService.ProcessDormancy,
+		}},
+		{cfg.Alerts.Enabled, scheduler.Job{
+			Name: "alert_scheduler", LockKey: "scheduler:alerts", Interval: cfg.Alerts.Interval,
+			Run: alertService.Evaluate,
+		}},
+		{cfg.Budgets.Enabled, scheduler.Job{
+			Name: "budget_scheduler", LockKey: "scheduler:budgets", Interval: cfg.Budgets.Interval,
+			Run: budgetService.NotifyBudgets,
+		}},
+		{cfg.AML.Enabled, scheduler.Job{
+			Name: "aml_scheduler", LockKey: "scheduler:aml", Interval: cfg.AML.Interval,
+			Run: amlService.Scan,
+		}},
+	} {
+		if !job.enabled {
+			logger.WithField("job", job.Name).Info("Scheduled job is disabled")
+			continue
+		}
+		h.jobScheduler.Register(job.Job)
+	}
+
+	return h
+}
+
+// UserService returns the user service, which validates the sessions of the
+// REST and gRPC API tokens
+func (h *Handlers) UserService() *service.UserService {
+	return h.userService
+}
+
+// APIKeyService returns the API key service, which authenticates the requests
+// of machine-to-machine clients
+func (h *Handlers) APIKeyService() *service.APIKeyService {
+	return h.apiKeyService
+}
+
+// AccountService returns the account service shared with the gRPC API
+func (h *Handlers) AccountService() *service.AccountService {
+	return h.accountService
+}
+
+// CreditService returns the credit service shared with the gRPC API
+func (h *Handlers) CreditService() *service.CreditService {
+	return h.creditService
+}
+
+// CardService returns the card service shared with the gRPC API
+func (h *Handlers) CardService() *service.CardService {
+	return h.cardService
 }
 
 // RegisterHandler handles user registration
 func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var req service.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
 
-	if err := h.userService.Register(&req); err != nil {
+	if err := h.userService.Register(r.Context(), &req); err != nil {
 		h.logger.WithError(err).Error("Failed to register user")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "email already exists", "username already exists":
+			status = http.StatusConflict
+		case "internal server error":
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -59,16 +459,23 @@ func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 // LoginHandler handles user login
 func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req service.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
 
-	resp, err := h.userService.Login(&req)
+	resp, err := h.userService.Login(r.Context(), &req, r.UserAgent())
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to login user")
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		status := http.StatusUnauthorized
+		switch err.Error() {
+		case "too many failed login attempts, try again later":
+			status = http.StatusTooManyRequests
+		case "user is temporarily locked":
+			status = http.StatusLocked
+		case "internal server error":
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -76,6 +483,25 @@ func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// decodeRequest decodes a JSON request body strictly into req and checks its
+// validate tags, responding with 400 and the reason or the failing fields when
+// either step fails
+func (h *Handlers) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := middleware.DecodeJSON(r.Body, req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode request body")
+		middleware.WriteDecodeError(w, err)
+		return false
+	}
+
+	if err := middleware.Validate(req); err != nil {
+		h.logger.WithError(err).Warn("Request body failed validation")
+		middleware.WriteValidationError(w, err)
+		return false
+	}
+
+	return true
+}
+
 // CreateAccountHandler handles account creation
 func (h *Handlers) CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateAccountRequest)
@@ -85,10 +511,14 @@ func (h *Handlers) CreateAccountHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	account, err := h.accountService.CreateAccount(req)
+	account, err := h.accountService.CreateAccount(r.Context(), req)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create account")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrCreditInCollections) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -107,13 +537,72 @@ func (h *Handlers) GetAccountHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, err := h.accountService.GetAccountByID(accountID)
+	account, err := h.accountService.GetAccountByID(r.Context(), accountID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get account")
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	writeJSONWithETag(w, r, account.ETag(), account)
+}
+
+// LookupAccountHandler handles a lookup of a transfer recipient by account number
+func (h *Handlers) LookupAccountHandler(w http.ResponseWriter, r *http.Request) {
+	number := r.URL.Query().Get("number")
+	if number == "" {
+		http.Error(w, "number is required", http.StatusBadRequest)
+		return
+	}
+
+	lookup, err := h.accountService.LookupAccount(r.Context(), number)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to look up account")
+		if err.Error() == "account not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lookup)
+}
+
+// UpdateAccountHandler handles renaming an account and choosing the primary account
+func (h *Handlers) UpdateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid account ID")
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAccountRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	account, err := h.accountService.UpdateAccount(r.Context(), userID, accountID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update account")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(account)
 }
@@ -128,67 +617,59 @@ func (h *Handlers) GetUserAccountsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	accounts, err := h.accountService.GetUserAccounts(userID)
+	page, err := pagination.Parse(r.URL.Query(), repository.AccountPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accounts, total, err := h.accountService.ListUserAccounts(r.Context(), userID, page)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user accounts")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	pagination.WriteHeaders(w, r, page, total)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(accounts)
 }
 
 // TransferHandler handles money transfer between accounts
 func (h *Handlers) TransferHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.TransferRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := h.accountService.Transfer(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to transfer money")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-}
-
-// CreateCreditHandler handles credit creation requests
-func (h *Handlers) CreateCreditHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateCreditRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.TransferRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
+		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if _, err := h.accountService.AuthorizeAccount(r.Context(), userID, req.FromAccountID, true); err != nil {
+		h.logger.WithError(err).Warn("Transfer from an account the user may not operate")
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
-	// Create credit
-	credit, err := h.creditService.CreateCredit(
-		userID,
-		req.Amount,
-		req.TermMonths,
-		req.InterestRate,
-	)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create credit")
-		http.Error(w, "Failed to create credit", http.StatusInternalServerError)
+	if err := h.accountService.Transfer(r.Context(), req, middleware.GetClientInfoFromContext(r.Context())); err != nil {
+		if h.writeFraudError(w, err) {
+			return
+		}
+		h.logger.WithError(err).Error("Failed to transfer money")
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrAccountDormant) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	// Return response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(credit)
+	w.WriteHeader(http.StatusOK)
 }
 
 // GetCreditHandler handles credit retrieval
@@ -201,15 +682,21 @@ func (h *Handlers) GetCreditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	credit, err := h.creditService.GetCreditByID(creditID)
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credit, err := h.creditService.GetCreditWithCost(r.Context(), userID, creditID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get credit")
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(credit)
+	writeJSONWithETag(w, r, credit.ETag(), credit)
 }
 
 // GetUserCreditsHandler handles user credits retrieval
@@ -222,13 +709,20 @@ func (h *Handlers) GetUserCreditsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	credits, err := h.creditService.GetCreditsByUserID(userID)
+	page, err := pagination.Parse(r.URL.Query(), repository.CreditPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	credits, total, err := h.creditService.ListUserCredits(r.Context(), userID, page)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user credits")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	pagination.WriteHeaders(w, r, page, total)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(credits)
 }
@@ -243,23 +737,77 @@ func (h *Handlers) PayCreditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req models.PayCreditRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.PayCreditRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	err = h.creditService.PayCredit(creditID, &req)
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.creditService.PayCredit(r.Context(), userID, creditID, req)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to pay credit")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrAccountDormant):
+			status = http.StatusForbidden
+		case errors.Is(err, repository.ErrCreditModified):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// RepayCreditEarlyHandler handles early repayment of a credit
+func (h *Handlers) RepayCreditEarlyHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid credit ID")
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.EarlyRepaymentRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	result, err := h.creditService.RepayEarly(r.Context(), userID, creditID, &req, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to repay credit early")
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrAccountDormant):
+			status = http.StatusForbidden
+		case errors.Is(err, repository.ErrCreditModified):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // GetPaymentScheduleHandler handles payment schedule retrieval
 func (h *Handlers) GetPaymentScheduleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -270,34 +818,65 @@ func (h *Handlers) GetPaymentScheduleHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	credit, err := h.creditService.GetCreditByID(creditID)
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.PaymentSchedulePageOptions)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get credit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schedule, total, err := h.creditService.ListPaymentSchedule(r.Context(), userID, creditID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get payment schedule")
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	schedule := models.GeneratePaymentSchedule(credit, time.Now())
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(schedule)
+	pagination.WriteHeaders(w, r, page, total)
+	writeJSONWithETag(w, r, models.PaymentScheduleETag(schedule, total), schedule)
 }
 
 // DepositHandler handles account deposits
 func (h *Handlers) DepositHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		AccountID int64   `json:"account_id" validate:"required"`
-		Amount    float64 `json:"amount" validate:"required,gt=0"`
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.DepositRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	// Without an account the deposit goes to the primary account of the currency
+	if req.AccountID == 0 {
+		userID, ok := authctx.UserIDFromContext(r.Context())
+		if !ok {
+			h.logger.Error("User ID not found in context")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		account, err := h.accountService.GetPrimaryAccount(r.Context(), userID, req.Currency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.AccountID = account.ID
 	}
 
-	if err := h.accountService.Deposit(req.AccountID, req.Amount); err != nil {
+	if err := h.accountService.Deposit(r.Context(), req.AccountID, req.Amount); err != nil {
 		h.logger.WithError(err).Error("Failed to deposit money")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		switch err.Error() {
+		case "account not found":
+			status = http.StatusNotFound
+		case "account is closed":
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -306,53 +885,182 @@ func (h *Handlers) DepositHandler(w http.ResponseWriter, r *http.Request) {
 
 // WithdrawHandler handles account withdrawals
 func (h *Handlers) WithdrawHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		AccountID int64   `json:"account_id" validate:"required"`
-		Amount    float64 `json:"amount" validate:"required,gt=0"`
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.WithdrawRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.accountService.AuthorizeAccount(r.Context(), userID, req.AccountID, true); err != nil {
+		h.logger.WithError(err).Warn("Withdrawal from an account the user may not operate")
+		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
 
-	if err := h.accountService.Withdraw(req.AccountID, req.Amount); err != nil {
+	if err := h.accountService.Withdraw(r.Context(), req.AccountID, req.Amount, middleware.GetClientInfoFromContext(r.Context())); err != nil {
+		if h.writeFraudError(w, err) {
+			return
+		}
 		h.logger.WithError(err).Error("Failed to withdraw money")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrAccountDormant) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// GetTransactionHandler handles transaction details retrieval
+func (h *Handlers) GetTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	transactionID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid transaction ID")
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transaction, err := h.accountService.GetTransaction(r.Context(), userID, transactionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get transaction")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// GetAccountStatementHandler handles account statement retrieval for a date range
+func (h *Handlers) GetAccountStatementHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid account ID")
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	start, err := time.Parse("2006-01-02", r.URL.Query().Get("start_date"))
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid start date")
+		http.Error(w, "Invalid start date", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", r.URL.Query().Get("end_date"))
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid end date")
+		http.Error(w, "Invalid end date", http.StatusBadRequest)
+		return
+	}
+
+	// Include the whole end day
+	transactions, err := h.accountService.GetStatement(r.Context(), userID, accountID, start, end.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get account statement")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// SetOverdraftHandler handles overdraft configuration of an account by an administrator
+func (h *Handlers) SetOverdraftHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid account ID")
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetOverdraftRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	account, err := h.accountService.SetOverdraft(r.Context(), adminID, accountID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to set overdraft")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
 // CreateCardHandler handles card creation
 func (h *Handlers) CreateCardHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateCardRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.CreateCardRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	// Get user ID from context (assuming it's set by auth middleware)
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	card, err := h.cardService.CreateCard(userID, &req)
+	card, err := h.cardService.CreateCard(r.Context(), userID, req)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create card")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		msg := err.Error()
+		switch {
+		case msg == "only virtual cards can be single-use or merchant-locked":
+			http.Error(w, msg, http.StatusBadRequest)
+		case msg == "account not found":
+			http.Error(w, msg, http.StatusNotFound)
+		case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrCreditInCollections):
+			http.Error(w, msg, http.StatusForbidden)
+		default:
+			http.Error(w, msg, http.StatusInternalServerError)
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(card.ToResponse())
+	json.NewEncoder(w).Encode(card.ToIssuedResponse())
 }
 
 // GetCardHandler handles card retrieval
@@ -366,35 +1074,40 @@ func (h *Handlers) GetCardHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	card, err := h.cardService.GetCard(userID, cardID)
+	card, err := h.cardService.GetCard(r.Context(), userID, cardID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get card")
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(card.ToResponse())
+	writeJSONWithETag(w, r, card.ETag(), card.ToResponse())
 }
 
 // GetUserCardsHandler handles user cards retrieval
 func (h *Handlers) GetUserCardsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	cards, err := h.cardService.GetUserCards(userID)
+	page, err := pagination.Parse(r.URL.Query(), repository.CardPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cards, total, err := h.cardService.ListUserCards(r.Context(), userID, page)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user cards")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -407,10 +1120,84 @@ func (h *Handlers) GetUserCardsHandler(w http.ResponseWriter, r *http.Request) {
 		responses[i] = card.ToResponse()
 	}
 
+	pagination.WriteHeaders(w, r, page, total)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(responses)
 }
 
+// QuoteCardPurchaseHandler discloses the currency conversion of a card payment before it is made
+func (h *Handlers) QuoteCardPurchaseHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cardID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card ID")
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid amount")
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversion, err := h.cardService.QuotePurchase(r.Context(), userID, cardID, amount, r.URL.Query().Get("currency"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to quote card purchase")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversion)
+}
+
+// CardPurchaseHandler handles card payments
+func (h *Handlers) CardPurchaseHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cardID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card ID")
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CardPurchaseRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transaction, err := h.cardService.Purchase(r.Context(), userID, cardID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to process card purchase")
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrAccountDormant) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+}
+
 // BlockCardHandler handles card blocking
 func (h *Handlers) BlockCardHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -422,14 +1209,14 @@ func (h *Handlers) BlockCardHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.cardService.BlockCard(userID, cardID); err != nil {
+	if err := h.cardService.BlockCard(r.Context(), userID, cardID); err != nil {
 		h.logger.WithError(err).Error("Failed to block card")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -438,6 +1225,45 @@ func (h *Handlers) BlockCardHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// ReissueCardHandler handles reissuing a card that expires or was blocked
+func (h *Handlers) ReissueCardHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cardID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid card ID")
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	card, err := h.cardService.ReissueCard(r.Context(), userID, cardID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reissue card")
+		msg := err.Error()
+		switch {
+		case msg == "internal server error":
+			http.Error(w, msg, http.StatusInternalServerError)
+		case msg == "card not found", msg == "account not found":
+			http.Error(w, msg, http.StatusNotFound)
+		case strings.HasPrefix(msg, "unauthorized"):
+			http.Error(w, msg, http.StatusForbidden)
+		default:
+			http.Error(w, msg, http.StatusConflict)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card.ToIssuedResponse())
+}
+
 // UnblockCardHandler handles card unblocking
 func (h *Handlers) UnblockCardHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -449,14 +1275,14 @@ func (h *Handlers) UnblockCardHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.cardService.UnblockCard(userID, cardID); err != nil {
+	if err := h.cardService.UnblockCard(r.Context(), userID, cardID); err != nil {
 		h.logger.WithError(err).Error("Failed to unblock card")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -476,14 +1302,14 @@ func (h *Handlers) DeleteCardHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.cardService.DeleteCard(userID, cardID); err != nil {
+	if err := h.cardService.DeleteCard(r.Context(), userID, cardID); err != nil {
 		h.logger.WithError(err).Error("Failed to delete card")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -495,7 +1321,7 @@ func (h *Handlers) DeleteCardHandler(w http.ResponseWriter, r *http.Request) {
 // GetTransactionAnalyticsHandler handles transaction analytics retrieval
 func (h *Handlers) GetTransactionAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -521,13 +1347,35 @@ func (h *Handlers) GetTransactionAnalyticsHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	analytics, err := h.accountService.GetTransactionAnalytics(userID, start, end)
+	format, ok := analyticsFormat(w, r)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.accountService.GetTransactionAnalytics(r.Context(), userID, start, end)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get transaction analytics")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if format == export.FormatXLSX {
+		report, err := h.transactionReportService.GetReport(r.Context(), userID, start, end)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get transaction report")
+			status := http.StatusBadRequest
+			if err.Error() == "internal server error" {
+				status = http.StatusInternalServerError
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.streamXLSX(w, fmt.Sprintf("transactions-%s-%s.xlsx", startDate, endDate), func(out io.Writer) error {
+			return export.WriteTransactionAnalytics(out, analytics, report)
+		})
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(analytics)
 }
@@ -535,20 +1383,182 @@ func (h *Handlers) GetTransactionAnalyticsHandler(w http.ResponseWriter, r *http
 // GetCreditAnalyticsHandler handles credit analytics retrieval
 func (h *Handlers) GetCreditAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
-	userID, ok := r.Context().Value("user_id").(int64)
+	userID, ok := authctx.UserIDFromContext(r.Context())
 	if !ok {
 		h.logger.Error("User ID not found in context")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	analytics, err := h.creditService.GetCreditAnalytics(userID)
+	format, ok := analyticsFormat(w, r)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.creditService.GetCreditAnalytics(r.Context(), userID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get credit analytics")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if format == export.FormatXLSX {
+		credits, err := h.creditService.GetCreditsByUserID(r.Context(), userID)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get user credits")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		schedule := func(ctx context.Context, creditID int64) ([]*models.PaymentSchedule, error) {
+			return h.creditService.GetPaymentSchedule(ctx, userID, creditID)
+		}
+		h.streamXLSX(w, fmt.Sprintf("credits-%s.xlsx", time.Now().Format("2006-01-02")), func(out io.Writer) error {
+			return export.WriteCreditAnalytics(r.Context(), out, analytics, credits, schedule)
+		})
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(analytics)
 }
+
+// analyticsFormat returns the format of the analytics requested with ?format=,
+// json or xlsx, answering 400 to any other
+func analyticsFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		return "json", true
+	case export.FormatXLSX:
+		return format, true
+	}
+	http.Error(w, "format must be json or xlsx", http.StatusBadRequest)
+	return "", false
+}
+
+// GetForecastHandler handles projecting the balance of the authenticated user
+// for the next months, given by the months query parameter
+func (h *Handlers) GetForecastHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	months := 0
+	if raw := r.URL.Query().Get("months"); raw != "" {
+		var err error
+		if months, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid months", http.StatusBadRequest)
+			return
+		}
+	}
+
+	forecast, err := h.forecastService.Forecast(r.Context(), userID, months, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to forecast cash flow")
+		http.Error(w, err.Error(), forecastErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecast)
+}
+
+func forecastErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error":
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "unavailable"):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// GetCreditHealthHandler handles retrieval of the authenticated user's credit health score
+func (h *Handlers) GetCreditHealthHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	health, err := h.scoringService.EvaluateCreditHealth(r.Context(), userID, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to evaluate credit health")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// GetCreditScoreHandler handles retrieval of the authenticated user's internal
+// credit score and its history
+func (h *Handlers) GetCreditScoreHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.scoringService.GetCreditScore(r.Context(), userID, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get credit score")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetSettingsHandler handles retrieval of the current user's settings
+func (h *Handlers) GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.userService.GetSettings(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user settings")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateSettingsHandler handles a change of the current user's settings
+func (h *Handlers) UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateUserSettingsRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.userService.UpdateSettings(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update user settings")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
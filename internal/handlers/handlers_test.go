@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGetCreditHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int64
+		wantStatus int
+	}{
+		{"returns the credit of the user", 7, http.StatusOK},
+		{"hides the credit of another user", 8, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			credits := &mock.CreditStore{
+				GetByIDFunc: func(context.Context, int64) (*models.Credit, error) {
+					return &models.Credit{
+						ID: 5, UserID: 7, AccountID: 1, Amount: 120000, InterestRate: 12, TermMonths: 12,
+						Status: string(models.CreditStatusActive), CreatedAt: time.Now().AddDate(0, -1, 0),
+					}, nil
+				},
+				GetPaymentScheduleFunc: func(context.Context, int64) ([]*models.PaymentSchedule, error) {
+					return nil, nil
+				},
+			}
+			h := &Handlers{
+				creditService: service.NewCreditService(credits, &mock.AccountStore{}, nil, nil, logger),
+				logger:        logger,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/credits/5", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "5"})
+			req = req.WithContext(authctx.WithUser(req.Context(), tt.userID, models.RoleUser, ""))
+			rec := httptest.NewRecorder()
+
+			h.GetCreditHandler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GetCreditHandler() status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
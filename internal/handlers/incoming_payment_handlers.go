@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// IncomingPaymentHandler handles a credit advice posted by the payment rail. A
+// new advice is answered with 201 and one received before with 200, so the rail
+// can safely deliver it again.
+func (h *Handlers) IncomingPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.IncomingPaymentRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	payment, duplicate, err := h.incomingPaymentService.Ingest(r.Context(), req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to ingest incoming payment")
+		msg := err.Error()
+		if msg == "internal server error" || strings.HasPrefix(msg, "failed to") {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !duplicate {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(payment)
+}
+
+// ListIncomingPaymentsHandler handles incoming payment listing for administrators
+func (h *Handlers) ListIncomingPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.IncomingPaymentStatus(r.URL.Query().Get("status"))
+
+	payments, err := h.incomingPaymentService.ListPayments(r.Context(), status)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list incoming payments")
+		if err.Error() == "internal server error" {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
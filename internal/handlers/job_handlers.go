@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// GetJobHandler handles polling of a long-running operation of the user. A
+// finished operation links to its result or to the file to download.
+func (h *Handlers) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	jobID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid job ID")
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.jobService.GetJob(r.Context(), userID, jobID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get job")
+		status := http.StatusInternalServerError
+		if err.Error() == "job not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeJobAccepted responds 202 Accepted with the report of a queued job and
+// its polling URL in the Location header
+func (h *Handlers) writeJobAccepted(w http.ResponseWriter, job *models.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", h.jobService.JobPath(job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(h.jobService.Report(job))
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// GetKYCHandler handles retrieval of the user's identity verification and documents
+func (h *Handlers) GetKYCHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	verification, err := h.kycService.GetVerification(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get KYC verification")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+// UploadKYCDocumentHandler handles the upload of an identity document as a
+// multipart form with the document type in the "type" field and the file in
+// the "file" field. The parts are read in memory, so a document never touches
+// the server's disk.
+func (h *Handlers) UploadKYCDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Request must be a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	var docType, fileName string
+	var data []byte
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.logger.WithError(err).Warn("Failed to read multipart form")
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+
+		switch part.FormName() {
+		case "type":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+				return
+			}
+			docType = string(value)
+		case "file":
+			fileName = part.FileName()
+			if data, err = io.ReadAll(part); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+				return
+			}
+		}
+		part.Close()
+	}
+	if data == nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.kycService.UploadDocument(r.Context(), userID, docType, fileName, data)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to upload KYC document")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// SubmitKYCHandler handles the submission of the user's documents for review
+func (h *Handlers) SubmitKYCHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	verification, err := h.kycService.Submit(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to submit KYC verification")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+// ListKYCVerificationsHandler handles listing of the submitted verifications.
+// Pending ones form the review queue.
+func (h *Handlers) ListKYCVerificationsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r.URL.Query(), repository.KYCVerificationPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verifications, total, err := h.kycService.ListVerifications(r.Context(), page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list KYC verifications")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verifications)
+}
+
+// GetUserKYCHandler handles retrieval of a user's verification and documents
+func (h *Handlers) GetUserKYCHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	verification, err := h.kycService.GetVerification(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get KYC verification")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+// GetKYCDocumentFileHandler handles the download of an identity document for review
+func (h *Handlers) GetKYCDocumentFileHandler(w http.ResponseWriter, r *http.Request) {
+	documentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	doc, data, err := h.kycService.GetDocument(r.Context(), adminID, documentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get KYC document")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.FileName))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// GetKYCDocumentURLHandler handles the issue of a signed URL to view an identity
+// document for review
+func (h *Handlers) GetKYCDocumentURLHandler(w http.ResponseWriter, r *http.Request) {
+	documentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	url, err := h.kycService.DocumentURL(r.Context(), adminID, documentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get KYC document URL")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+// ApproveKYCHandler handles the verification of a user's identity
+func (h *Handlers) ApproveKYCHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewKYC(w, r, true)
+}
+
+// RejectKYCHandler handles the rejection of a user's documents
+func (h *Handlers) RejectKYCHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewKYC(w, r, false)
+}
+
+func (h *Handlers) reviewKYC(w http.ResponseWriter, r *http.Request, approve bool) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewKYCRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var verification *models.KYCVerification
+	if approve {
+		verification, err = h.kycService.Approve(r.Context(), adminID, userID, req.Comment)
+	} else {
+		verification, err = h.kycService.Reject(r.Context(), adminID, userID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review KYC verification")
+		http.Error(w, err.Error(), kycErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+func kycErrorStatus(err error) int {
+	switch err.Error() {
+	case "user not found", "document not found":
+		return http.StatusNotFound
+	case "documents are under review", "identity is already verified", "verification is not pending review",
+		"verification was changed in the meantime":
+		return http.StatusConflict
+	case "verification must be reviewed by someone else":
+		return http.StatusForbidden
+	case "internal server error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
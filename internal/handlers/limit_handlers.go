@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// GetMyLimitsHandler handles retrieval of the current user's transaction limits
+// and the allowance remaining in each period
+func (h *Handlers) GetMyLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.writeUserLimits(w, r, userID)
+}
+
+// GetUserLimitsHandler handles retrieval of the transaction limits of any user
+func (h *Handlers) GetUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid user ID")
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	h.writeUserLimits(w, r, userID)
+}
+
+func (h *Handlers) writeUserLimits(w http.ResponseWriter, r *http.Request, userID int64) {
+	limits, err := h.limitService.GetLimits(r.Context(), userID, time.Now())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user limits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// SetUserLimitsHandler handles an override of the transaction limits of a user
+func (h *Handlers) SetUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid user ID")
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetUserLimitsRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limits, err := h.limitService.SetLimits(r.Context(), adminID, userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to set user limits")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
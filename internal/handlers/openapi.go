@@ -0,0 +1,1868 @@
+package handlers
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/graphql"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+//go:embed swagger_ui.html
+var swaggerUIPage string
+
+var swaggerUITemplate = template.Must(template.New("swagger_ui").Parse(swaggerUIPage))
+
+// apiOperation documents a route for the OpenAPI specification. Request and
+// Response are zero values of the body types; their schemas are derived from the
+// json and validate tags.
+type apiOperation struct {
+	Summary     string
+	Description string
+	Query       []apiParam
+	Request     interface{}
+	Response    interface{}
+	Status      int        // success status, 200 when zero
+	ContentType string     // response content type, application/json when empty
+	Public      bool       // no bearer token required
+	Role        string     // roles allowed besides authentication, such as "admin"
+	Replay      bool       // protected against replay with signed X-Request-Nonce and X-Request-Timestamp
+	CSV         bool       // the body may also be sent as text/csv
+	Upload      []apiParam // fields of the multipart form the body is sent as; the file fields have the binary format
+	Screened    bool       // screened by the fraud rules, which may hold or reject it
+}
+
+// apiParam is a query parameter of a route
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+	Format      string
+}
+
+// apiOperations documents the routes registered in the router, keyed by method
+// and path without the API prefix. Routes missing here are still published,
+// without schemas, and reported when the specification is built.
+var apiOperations = map[string]apiOperation{
+	"GET /health/live": {
+		Summary:  "Liveness probe",
+		Public:   true,
+		Response: map[string]string{},
+	},
+	"GET /health/ready": {
+		Summary:     "Readiness probe",
+		Description: "Responds with 503 while the database or a background process is unhealthy.",
+		Public:      true,
+		Response:    map[string]string{},
+	},
+	"GET /metrics": {
+		Summary:     "Prometheus metrics",
+		Public:      true,
+		ContentType: "text/plain",
+	},
+	"GET /docs": {
+		Summary:     "Swagger UI",
+		Public:      true,
+		ContentType: "text/html",
+	},
+	"GET /admin/ui": {
+		Summary:     "Redirect to the admin dashboard",
+		Public:      true,
+		Status:      http.StatusMovedPermanently,
+		ContentType: "text/html",
+	},
+	"GET /admin/ui/": {
+		Summary:     "Admin dashboard page and its assets",
+		Description: "The page holds no data; it asks for an administrator's access token or API key and renders GET /admin/dashboard with it.",
+		Public:      true,
+		ContentType: "text/html",
+	},
+	"GET /openapi.json": {
+		Summary:  "OpenAPI specification of this API",
+		Public:   true,
+		Response: map[string]interface{}{},
+	},
+
+	// Public
+	"POST /public/register": {
+		Summary: "Register a user",
+		Description: "The password is checked against the password policy; a password breaking it is refused with 400 and the broken rule. " +
+			"The user joins the branch of branch_code, the default branch without one.",
+		Public:  true,
+		Request: service.RegisterRequest{},
+		Status:  http.StatusCreated,
+	},
+	"POST /public/login": {
+		Summary: "Log in and receive a JWT",
+		Description: "Opens a session for the device, named by the optional device_name; the token is accepted until it expires or the session is revoked. " +
+			"Too many failed logins in a row lock the user for a while (423) and too many from one address are refused (429).",
+		Public:   true,
+		Request:  service.LoginRequest{},
+		Response: service.LoginResponse{},
+	},
+	"GET /public/branches": {
+		Summary:  "Branches open for registration",
+		Public:   true,
+		Response: []models.Branch{},
+	},
+
+	"GET /public/receipts/verify": {
+		Summary: "Verify a receipt",
+		Description: "Confirms that a receipt was issued by the bank: a valid code returns the details of the transaction " +
+			"to compare with the receipt, an invalid code or an unknown reference returns valid false.",
+		Public: true,
+		Query: []apiParam{
+			{Name: "reference", Description: "Transaction reference printed on the receipt", Required: true},
+			{Name: "code", Description: "Verification code printed on the receipt", Required: true},
+		},
+		Response: models.ReceiptVerification{},
+	},
+	"GET /files/{key}": {
+		Summary:     "Download a file of the local object storage",
+		Description: "Target of the signed URLs issued with the local storage backend; the signature stands in for authentication.",
+		Public:      true,
+		Query: []apiParam{
+			{Name: "expires", Description: "Unix time the URL expires at", Required: true},
+			{Name: "signature", Description: "Signature of the key and the expiry", Required: true},
+		},
+		ContentType: "application/octet-stream",
+	},
+
+	// Sandbox mode, only served when SANDBOX_ENABLED is set
+	"GET /sandbox/emails": {
+		Summary: "Emails captured in sandbox mode",
+		Description: "In sandbox mode emails are kept in memory instead of sent. Newest first; the last 1000 are kept " +
+			"and lost on a restart.",
+		Role: "admin",
+		Query: []apiParam{
+			{Name: "recipient", Description: "Only the emails sent to this address"},
+		},
+		Response: []models.SandboxEmail{},
+	},
+	"POST /sandbox/reset": {
+		Summary: "Wipe the sandbox test data",
+		Description: "Empties every table except the schema migrations and the reference data (branches, billers, key rates) " +
+			"and drops the captured emails. Users, accounts and business parameters are wiped too, the caller's among them.",
+		Role:   "admin",
+		Status: http.StatusNoContent,
+	},
+
+	// Payment rail integration
+	"POST /integrations/incoming-payments": {
+		Summary: "Receive a credit advice from the payment rail",
+		Description: "Signed by the rail instead of a bearer token: X-Rail-Timestamp holds the Unix time in seconds and " +
+			"X-Rail-Signature the hex HMAC-SHA256 of \"<timestamp>.<body>\" keyed with INCOMING_PAYMENTS_SECRET. " +
+			"The payment is credited as a deposit to the account with the given number when its currency matches, " +
+			"otherwise it is stored as unmatched. An advice with a known reference is not posted again and is " +
+			"answered with 200 and the stored payment.",
+		Public:   true,
+		Request:  models.IncomingPaymentRequest{},
+		Response: models.IncomingPayment{},
+		Status:   http.StatusCreated,
+	},
+
+	// Card acquiring
+	"POST /cards/authorize": {
+		Summary: "Authorize a card payment for the acquirer",
+		Description: "Signed by the acquirer like the payment rail messages, with ACQUIRING_SECRET. The card number, " +
+			"expiry date and CVV are checked with the card status, merchant lock and limits, and the amount is held " +
+			"on the card account until the payment is captured, voided or the authorization expires. A declined " +
+			"payment is answered with 402 and the reason. The merchant_country, when reported, is used by the card " +
+			"payment abroad alerts.",
+		Public:   true,
+		Request:  models.CardAuthorizationRequest{},
+		Response: models.CardAuthorization{},
+		Status:   http.StatusCreated,
+	},
+	"GET /cards/authorizations/{id}": {
+		Summary:  "Get a card authorization",
+		Public:   true,
+		Response: models.CardAuthorization{},
+	},
+	"POST /cards/authorizations/{id}/capture": {
+		Summary:     "Capture an authorized card payment",
+		Description: "Debits the held amount from the card account as a card payment and settles the hold.",
+		Public:      true,
+		Response:    models.CardAuthorization{},
+	},
+	"POST /cards/authorizations/{id}/void": {
+		Summary:     "Void an authorized card payment",
+		Description: "Releases the hold without debiting the account.",
+		Public:      true,
+		Response:    models.CardAuthorization{},
+	},
+
+	// Users
+	"GET /users/me": {
+		Summary:  "Profile of the current user",
+		Response: models.UserResponse{},
+	},
+	"PUT /users/me": {
+		Summary:     "Change the profile of the current user",
+		Description: "Replaces the first and last name and the phone number; an empty phone number removes it.",
+		Request:     models.UpdateProfileRequest{},
+		Response:    models.UserResponse{},
+	},
+	"DELETE /users/me": {
+		Summary:     "Close the profile of the current user",
+		Description: "The password must be given, the accounts must be empty, the credits repaid and the term deposits paid out. The cards are blocked, every device is signed out and login is refused; the personal data is anonymized after the retention period while the financial records are kept.",
+		Request:     models.CloseProfileRequest{},
+		Status:      http.StatusNoContent,
+	},
+	"POST /users/me/password": {
+		Summary: "Change the password of the current user",
+		Description: "The current password must be given and the new one must follow the password policy. " +
+			"Tokens issued before the change stay valid until they expire or their sessions are revoked.",
+		Request: models.ChangePasswordRequest{},
+	},
+	"GET /users/me/sessions": {
+		Summary:     "List the devices signed in as the current user",
+		Description: "Active sessions with the device name, address and user agent of the login and the last use; current marks the session of the request.",
+		Response:    []models.Session{},
+	},
+	"DELETE /users/me/sessions/{id}": {
+		Summary:     "Sign a device out",
+		Description: "Revokes the session; its token is refused from then on.",
+		Status:      http.StatusNoContent,
+	},
+	"GET /users/me/credit-health": {
+		Summary:  "Credit health score of the current user",
+		Response: models.CreditHealth{},
+	},
+	"GET /users/me/credit-score": {
+		Summary: "Internal credit score of the current user",
+		Description: "The score from 0 to 100 and its rating from A to E are built from punctuality, overdue installments, " +
+			"income and the share of borrowed money outstanding. History lists the scores recorded when the score changed " +
+			"or a credit was applied for, latest first.",
+		Response: models.CreditScoreReport{},
+	},
+	"GET /users/me/limits": {
+		Summary:     "Transfer and withdrawal limits of the current user",
+		Description: "Daily limits cover the last 24 hours, monthly limits the last 30 days. Transfers between own accounts are not limited. Until the identity is verified the limits are capped at the small limits of unverified users.",
+		Response:    models.UserLimitsResponse{},
+	},
+	"GET /users/me/kyc": {
+		Summary:  "Identity verification of the current user",
+		Response: models.KYCVerification{},
+	},
+	"POST /users/me/kyc/documents": {
+		Summary: "Upload an identity document",
+		Description: "The type is passport (scan of the page with the photo) or selfie (photo holding the passport); the file is " +
+			"a JPEG or PNG image or a PDF file. Documents are accepted until they are submitted and again after a rejection.",
+		Upload: []apiParam{
+			{Name: "type", Required: true},
+			{Name: "file", Required: true, Format: "binary"},
+		},
+		Response: models.KYCDocument{},
+		Status:   http.StatusCreated,
+	},
+	"POST /users/me/kyc/submit": {
+		Summary: "Submit the identity documents for review",
+		Description: "A passport and a selfie must have been uploaded since the last rejection. Until the identity is verified " +
+			"the user cannot apply for credits and transfers and withdrawals are kept to small limits.",
+		Response: models.KYCVerification{},
+	},
+	"GET /users/me/statements": {
+		Summary:     "Stored monthly statements of the current user",
+		Description: "Statements are kept for download once they are emailed. " + pageDescription,
+		Query:       pageParams(repository.StatementFilePageOptions),
+		Response:    []models.StatementFile{},
+	},
+	"GET /users/me/statements/{id}/url": {
+		Summary:     "Get a signed URL to download a stored statement",
+		Description: "The URL works without authentication until it expires.",
+		Response:    models.SignedURL{},
+	},
+	"GET /users/me/settings": {
+		Summary:  "Settings of the current user",
+		Response: models.UserSettings{},
+	},
+	"PUT /users/me/settings": {
+		Summary:     "Change the settings of the current user",
+		Description: "The language (ru or en) and the time zone are used to format the emails and the statements. With monthly_statements set, a PDF statement of every account is emailed on the 1st of each month for the previous month, bounded in the user's time zone.",
+		Request:     models.UpdateUserSettingsRequest{},
+		Response:    models.UserSettings{},
+	},
+	"POST /users/me/alerts": {
+		Summary: "Set up an alert",
+		Description: "A balance_below alert fires when the balance of the account falls below the threshold and again " +
+			"only after it has recovered; an incoming_above alert fires for every payment above the threshold arriving " +
+			"at the account; a card_abroad alert fires for every card payment at a merchant outside Russia, on the " +
+			"account or on every account of the user. Alerts are evaluated every ALERTS_INTERVAL and sent through the " +
+			"notification channels enabled in the user settings.",
+		Request:  models.CreateAlertRequest{},
+		Response: models.Alert{},
+		Status:   http.StatusCreated,
+	},
+	"GET /users/me/alerts": {
+		Summary:     "Alerts of the current user",
+		Description: pageDescription,
+		Query:       pageParams(repository.AlertPageOptions),
+		Response:    []models.Alert{},
+	},
+	"GET /users/me/alerts/{id}": {
+		Summary:  "Alert of the current user",
+		Response: models.Alert{},
+	},
+	"PUT /users/me/alerts/{id}": {
+		Summary:  "Change the threshold of an alert or switch it on or off",
+		Request:  models.UpdateAlertRequest{},
+		Response: models.Alert{},
+	},
+	"DELETE /users/me/alerts/{id}": {
+		Summary: "Delete an alert",
+		Status:  http.StatusNoContent,
+	},
+
+	// Accounts
+	"POST /accounts": {
+		Summary:  "Open an account",
+		Request:  models.CreateAccountRequest{},
+		Response: models.Account{},
+		Status:   http.StatusCreated,
+	},
+	"GET /accounts/lookup": {
+		Summary:     "Find a transfer recipient by account number",
+		Description: "Returns the masked number, the currency and the holder's first name with the last name initial.",
+		Query:       []apiParam{{Name: "number", Required: true, Description: "20-digit account number"}},
+		Response:    models.AccountLookup{},
+	},
+	"GET /accounts/{id}": {
+		Summary: "Get an account",
+		Description: "The account number is shown in full; elsewhere numbers of other people's accounts are masked. " +
+			"held_amount is reserved by card authorizations and transfers to other banks not booked yet; " +
+			"available_balance is the balance with the overdraft limit less the held amount, the most that can be spent. " +
+			etagDescription,
+		Response: models.Account{},
+	},
+	"PATCH /accounts/{id}": {
+		Summary:     "Rename an account or make it primary",
+		Description: "Fields left out stay unchanged. Making an account primary unsets the previous primary account of the same currency.",
+		Request:     models.UpdateAccountRequest{},
+		Response:    models.Account{},
+	},
+	"GET /accounts/user/{user_id}": {
+		Summary:     "List accounts of a user",
+		Description: pageDescription,
+		Query:       pageParams(repository.AccountPageOptions),
+		Response:    []models.Account{},
+	},
+	"POST /accounts/transfer": {
+		Summary:     "Transfer money between accounts",
+		Description: "The recipient is given by to_account_id or by to_account_number.",
+		Request:     models.TransferRequest{},
+		Replay:      true,
+		Screened:    true,
+	},
+	"POST /accounts/deposit": {
+		Summary:     "Deposit money into the primary account",
+		Description: "With account_id left out the deposit goes to the primary account of the user in the given currency.",
+		Request:     models.DepositRequest{},
+		Replay:      true,
+	},
+	"POST /accounts/{id}/deposit": {
+		Summary: "Deposit money into an account",
+		Request: models.DepositRequest{},
+		Replay:  true,
+	},
+	"POST /accounts/{id}/withdraw": {
+		Summary:  "Withdraw money from an account",
+		Request:  models.WithdrawRequest{},
+		Replay:   true,
+		Screened: true,
+	},
+	"POST /accounts/{id}/close": {
+		Summary: "Close an account",
+		Description: "Only the owner closes an account. It must have no open holds, no active cards, no credits being repaid " +
+			"and no term deposits to be paid out to it, and must not be overdrawn. A positive balance is moved to target_account_id " +
+			"or target_account_number, another open account of the owner in the same currency; the body may be left out for an empty account. " +
+			"The final statement of the whole history of the account is rendered by statement_job. A closed account stays readable but can no longer change.",
+		Request:  models.CloseAccountRequest{},
+		Response: models.AccountClosure{},
+		Replay:   true,
+	},
+	"POST /accounts/{id}/reactivate": {
+		Summary: "Reactivate a dormant account",
+		Description: "An account with no activity for account.dormancy_months becomes dormant: it still receives money, " +
+			"but transfers, withdrawals, card payments and other debits from it are refused until a user who may " +
+			"operate it confirms its reactivation here. Reactivation also stops the monthly account.dormancy_fee.",
+		Response: models.Account{},
+	},
+	"GET /accounts/{id}/members": {
+		Summary:     "List the members of a shared account",
+		Description: "Open to the owner and every member. Lists accepted members and pending invitations; the owner has no entry.",
+		Response:    []models.AccountMember{},
+	},
+	"POST /accounts/{id}/members": {
+		Summary:     "Invite a user to share an account",
+		Description: "Only the owner invites. A co_owner may move money, issue cards and read statements; a viewer may only read.",
+		Request:     models.InviteAccountMemberRequest{},
+		Response:    models.AccountMember{},
+		Status:      http.StatusCreated,
+	},
+	"PUT /accounts/{id}/members/{user_id}": {
+		Summary:  "Change the role of an account member",
+		Request:  models.UpdateAccountMemberRequest{},
+		Response: models.AccountMember{},
+	},
+	"DELETE /accounts/{id}/members/{user_id}": {
+		Summary:     "Remove a member from an account",
+		Description: "The owner removes anyone; a member may remove themselves to leave the account.",
+		Status:      http.StatusNoContent,
+	},
+	"GET /accounts/invitations": {
+		Summary:  "Pending invitations of the current user to shared accounts",
+		Response: []models.AccountMember{},
+	},
+	"POST /accounts/invitations/{id}/accept": {
+		Summary:  "Accept an invitation to a shared account",
+		Response: models.AccountMember{},
+	},
+	"POST /accounts/invitations/{id}/decline": {
+		Summary: "Decline an invitation to a shared account",
+		Status:  http.StatusNoContent,
+	},
+	"GET /accounts/{id}/statement": {
+		Summary: "Account statement for a period",
+		Query: []apiParam{
+			{Name: "start_date", Required: true, Format: "date"},
+			{Name: "end_date", Required: true, Format: "date"},
+		},
+		Response: []models.Transaction{},
+	},
+	"POST /accounts/{id}/statement": {
+		Summary: "Render the PDF statement of an account in the background",
+		Description: "Answers 202 Accepted with the job and its URL in the Location header. Once the job has succeeded, " +
+			"GET /jobs/{id} links to a signed URL to download the PDF.",
+		Query: []apiParam{
+			{Name: "start_date", Required: true, Format: "date"},
+			{Name: "end_date", Required: true, Format: "date"},
+		},
+		Response: models.JobReport{},
+		Status:   http.StatusAccepted,
+	},
+	"GET /accounts/{id}/qr": {
+		Summary: "Payment QR code of an account",
+		Description: "The payload follows GOST R 56042-2014, the format Russian banking apps scan, and is encoded in the QR image by " +
+			"the client. Only RUB accounts have a code. The amount and comment are optional; without an amount the payer enters one.",
+		Query: []apiParam{
+			{Name: "amount", Format: "double"},
+			{Name: "comment"},
+		},
+		Response: models.PaymentQR{},
+	},
+
+	// Transfers to the payment details of QR codes
+	"POST /transfers/qr": {
+		Summary: "Transfer money by a scanned payment QR code",
+		Description: "The payload is the text of a GOST R 56042-2014 QR code. The amount is taken from the code, or from the " +
+			"request when the code carries none. Details at this bank are paid at once; details at another bank answer 201 Created " +
+			"with the pending transfer to it.",
+		Request:  models.QRTransferRequest{},
+		Response: models.QRTransferResult{},
+		Replay:   true,
+	},
+
+	// Long-running operations
+	"GET /jobs/{id}": {
+		Summary: "Progress of a long-running operation",
+		Description: "Operations answering 202 Accepted run as persisted jobs and are polled here until the status is " +
+			"succeeded or failed. A failed attempt is retried up to max_attempts times. Once succeeded, links.result points " +
+			"at what the job created and links.download at a signed URL of the file it produced.",
+		Response: models.JobReport{},
+	},
+
+	// Batches of transfers
+	"POST /transfers/batch": {
+		Summary: "Make a batch of transfers from one account",
+		Description: "Every entry is checked before the batch is accepted and the transfers are then made in the background. " +
+			"An atomic batch is made in full or not at all; a per_item batch reports the status of each transfer. " +
+			"A CSV body has the columns to_account_id or to_account_number, amount and reference and takes the other fields as query parameters.",
+		Query: []apiParam{
+			{Name: "from_account_id", Description: "CSV only"},
+			{Name: "mode", Description: "CSV only, atomic or per_item"},
+		},
+		Request:  models.CreateTransferBatchRequest{},
+		Response: models.TransferBatch{},
+		Status:   http.StatusAccepted,
+		CSV:      true,
+		Replay:   true,
+	},
+	"GET /transfers/batch/{id}": {
+		Summary:  "Progress and results of a transfer batch",
+		Response: models.TransferBatch{},
+	},
+
+	// Bill payments
+	"GET /billers": {
+		Summary:     "Billers whose bills users can pay",
+		Description: "Only active billers are listed, by name. The account_label names the customer account the biller expects.",
+		Query: []apiParam{
+			{Name: "category", Description: "One of " + strings.Join(models.BillerCategories, ", ")},
+		},
+		Response: []models.Biller{},
+	},
+	"GET /billers/{id}": {
+		Summary:  "Biller of the catalog",
+		Response: models.Biller{},
+	},
+	"POST /payments/bills": {
+		Summary: "Pay a bill",
+		Description: "The amount is debited from the account in the currency of the biller and counts toward the transfer " +
+			"limits. When the biller's provider declines the payment or cannot be reached the amount is returned to the " +
+			"account and the request answers 502 Bad Gateway with the reason.",
+		Request:  models.CreateBillPaymentRequest{},
+		Response: models.BillPayment{},
+		Status:   http.StatusCreated,
+		Replay:   true,
+	},
+	"GET /payments/bills": {
+		Summary:     "Bill payments of the current user",
+		Description: pageDescription,
+		Query:       pageParams(repository.BillPaymentPageOptions),
+		Response:    []models.BillPayment{},
+	},
+	"GET /payments/bills/{id}": {
+		Summary:  "Bill payment of the current user",
+		Response: models.BillPayment{},
+	},
+
+	// Term deposits
+	"POST /deposits": {
+		Summary: "Open a term deposit",
+		Description: "The amount is taken from the balance of the account, never from its overdraft, and must be at least " +
+			"deposit.min_amount. A fixed-rate deposit earns deposit.interest_rate_percent; a key_rate deposit, in RUB only, " +
+			"earns the CBR key rate plus deposit.key_rate_margin_percent. The rate stays for the whole term. Interest accrues " +
+			"daily on the amount and is paid to the account with the amount when the deposit matures.",
+		Request:  models.CreateDepositRequest{},
+		Response: models.Deposit{},
+		Status:   http.StatusCreated,
+		Replay:   true,
+	},
+	"GET /deposits": {
+		Summary:     "Term deposits of the current user",
+		Description: pageDescription,
+		Query:       pageParams(repository.DepositPageOptions),
+		Response:    []models.Deposit{},
+	},
+	"GET /deposits/{id}": {
+		Summary:  "Term deposit of the current user",
+		Response: models.Deposit{},
+	},
+	"POST /deposits/{id}/withdraw": {
+		Summary: "Withdraw a term deposit early",
+		Description: "The amount is paid back to the account with the interest for the days held at the " +
+			"deposit.early_withdrawal_rate_percent in force when the deposit was opened; the interest accrued above it is " +
+			"forfeited and returned as penalty. A deposit that already matured is paid out in full.",
+		Response: models.Deposit{},
+		Replay:   true,
+	},
+
+	// Transfers to other banks
+	"POST /transfers/external": {
+		Summary: "Transfer money to an account at another bank",
+		Description: "The beneficiary account is given by iban or by account_number, with the bic of its bank. " +
+			"The amount is held on the account at once and the transfer moves from pending to processing when it is sent, " +
+			"then to settled, which debits the account, or to returned, which releases the hold. Every status change is published as the " +
+			"external_transfer.status_changed webhook event and notified to the user.",
+		Request:  models.CreateExternalTransferRequest{},
+		Response: models.ExternalTransfer{},
+		Status:   http.StatusCreated,
+		Replay:   true,
+	},
+	"GET /transfers/external": {
+		Summary:  "List transfers of the current user to other banks",
+		Response: []models.ExternalTransfer{},
+	},
+	"GET /transfers/external/{id}": {
+		Summary:  "Get a transfer to another bank",
+		Response: models.ExternalTransfer{},
+	},
+
+	// Budgets
+	"POST /budgets": {
+		Summary: "Set the monthly limit of a spending category",
+		Description: "The limit is in RUB. Outgoing transactions are categorized by type: card_payments, cash " +
+			"withdrawals, transfers to other users and banks, bill payments by the category of the biller (mobile, " +
+			"utilities, internet, tv, or bills for the others), credit repayments (loans) and overdraft interest (fees). A category has " +
+			"one budget; the user is notified when the spending of a month reaches 80% and 100% of it.",
+		Request:  models.BudgetRequest{},
+		Response: models.Budget{},
+		Status:   http.StatusCreated,
+	},
+	"GET /budgets": {
+		Summary: "Budgets of the current user with the spending of the current month",
+		Description: "The month is bounded in the user's time zone; spending in other currencies is converted to RUB " +
+			"at the CBR rate.",
+		Response: []models.BudgetProgress{},
+	},
+	"GET /budgets/{id}": {
+		Summary:  "Budget of the current user with the spending of the current month",
+		Response: models.BudgetProgress{},
+	},
+	"PUT /budgets/{id}": {
+		Summary:  "Change the monthly limit of a budget",
+		Request:  models.UpdateBudgetRequest{},
+		Response: models.Budget{},
+	},
+	"DELETE /budgets/{id}": {
+		Summary: "Delete a budget",
+		Status:  http.StatusNoContent,
+	},
+
+	// Payees
+	"POST /payees": {
+		Summary: "Save a payee",
+		Description: "An account payee has an account_number at the bank, a card payee a card_number of a card issued by the bank, " +
+			"and an external payee a beneficiary_name, a bic and an iban or an account_number.",
+		Request:  models.PayeeRequest{},
+		Response: models.Payee{},
+		Status:   http.StatusCreated,
+	},
+	"GET /payees": {
+		Summary:     "Payees of the current user",
+		Description: pageDescription,
+		Query:       pageParams(repository.PayeePageOptions),
+		Response:    []models.Payee{},
+	},
+	"GET /payees/{id}": {
+		Summary:  "Get a payee",
+		Response: models.Payee{},
+	},
+	"PUT /payees/{id}": {
+		Summary:     "Update a payee",
+		Description: "Changing the payment details makes the next transfer to the payee need a confirmation code again.",
+		Request:     models.PayeeRequest{},
+		Response:    models.Payee{},
+	},
+	"DELETE /payees/{id}": {
+		Summary:     "Delete a payee",
+		Description: "Transfers made to the payee stay in the transfer analytics.",
+		Status:      http.StatusNoContent,
+	},
+	"POST /payees/{id}/transfer": {
+		Summary: "Transfer money to a payee",
+		Description: "The first transfer to a payee needs a confirmation code: without confirmation_code the code is emailed to the user " +
+			"and the response is 202 Accepted with the status confirmation_required, and nothing is transferred. Repeating the " +
+			"transfer with the code confirms the payee for good. Transfers to other banks answer 201 Created with the pending transfer.",
+		Request:  models.PayeeTransferRequest{},
+		Response: models.PayeeTransferResult{},
+		Replay:   true,
+	},
+
+	// Payment requests
+	"POST /payment-requests": {
+		Summary: "Request money from another user",
+		Description: "The payer is found by email or by phone, and the amount is paid to account_id in its currency. " +
+			"Without expires_at the request waits for the payer for the configured time.",
+		Request:  models.CreatePaymentRequestRequest{},
+		Response: models.PaymentRequest{},
+		Status:   http.StatusCreated,
+	},
+	"GET /payment-requests": {
+		Summary:     "Payment requests sent to the current user",
+		Description: pageDescription + " Filter by status=pending for the requests waiting for the user.",
+		Query:       pageParams(repository.PaymentRequestPageOptions),
+		Response:    []models.PaymentRequest{},
+	},
+	"GET /payment-requests/sent": {
+		Summary:     "Payment requests the current user sent",
+		Description: pageDescription,
+		Query:       pageParams(repository.PaymentRequestPageOptions),
+		Response:    []models.PaymentRequest{},
+	},
+	"GET /payment-requests/{id}": {
+		Summary:  "Get a payment request the current user sent or received",
+		Response: models.PaymentRequest{},
+	},
+	"POST /payment-requests/{id}/accept": {
+		Summary: "Pay a payment request",
+		Description: "The transfer and the change of the request to paid are made in one database transaction. A transfer held " +
+			"by the fraud rules answers 202 Accepted and holds the request until the check is reviewed.",
+		Request:  models.AcceptPaymentRequestRequest{},
+		Response: models.PaymentRequest{},
+		Replay:   true,
+	},
+	"POST /payment-requests/{id}/decline": {
+		Summary:  "Decline a payment request",
+		Request:  models.DeclinePaymentRequestRequest{},
+		Response: models.PaymentRequest{},
+	},
+	"POST /payment-requests/{id}/cancel": {
+		Summary:  "Cancel a payment request the current user sent",
+		Response: models.PaymentRequest{},
+	},
+
+	// Transactions
+	"GET /transactions/{id}": {
+		Summary:  "Get a transaction",
+		Response: models.Transaction{},
+	},
+	"GET /transactions/{id}/receipt": {
+		Summary: "Printable receipt of a transaction",
+		Description: "Bank details, the transaction reference and a verification code, which anyone the receipt is " +
+			"shown to can check with the public verification endpoint. 503 when no receipt signing key is configured.",
+		Query:       []apiParam{{Name: "format", Description: "pdf (default) or html"}},
+		ContentType: "application/pdf",
+	},
+	"POST /transactions/{id}/dispute": {
+		Summary: "Dispute a transaction",
+		Description: "Contests a debit of an account the user may operate: a transfer, withdrawal, card payment, overdraft " +
+			"interest or transfer to another bank, within the dispute window after it. The amount defaults to the whole " +
+			"transaction. Supporting files (JPEG or PNG images or PDF files) are attached by sending the dispute as a " +
+			"multipart form with each file in a file field. A transaction is disputed once.",
+		Request: models.CreateDisputeRequest{},
+		Upload: []apiParam{
+			{Name: "reason", Required: true, Description: "One of " + strings.Join(models.DisputeReasons, ", ")},
+			{Name: "description"},
+			{Name: "amount", Format: "double"},
+			{Name: "file", Format: "binary", Description: "Repeated for every file"},
+		},
+		Response: models.Dispute{},
+		Status:   http.StatusCreated,
+	},
+
+	// Disputes
+	"GET /disputes": {
+		Summary:     "Disputes opened by the current user",
+		Description: pageDescription,
+		Query:       pageParams(repository.DisputePageOptions),
+		Response:    []models.Dispute{},
+	},
+	"GET /disputes/{id}": {
+		Summary:  "Get a dispute with its attachments",
+		Response: models.Dispute{},
+	},
+
+	// Cards
+	"POST /cards": {
+		Summary: "Issue a card",
+		Description: "With account_id left out the card is issued on the primary account of the user in the given currency. " +
+			"A virtual card may be single-use or locked to one merchant; its full number and CVV are returned " +
+			"in pan and cvv of this response only.",
+		Request:  models.CreateCardRequest{},
+		Response: models.CardResponse{},
+		Status:   http.StatusCreated,
+	},
+	"GET /cards/{id}": {
+		Summary:     "Get a card with its number masked",
+		Description: etagDescription,
+		Response:    models.CardResponse{},
+	},
+	"GET /cards/user/{user_id}": {
+		Summary:     "List cards of a user",
+		Description: pageDescription,
+		Query:       pageParams(repository.CardPageOptions),
+		Response:    []models.CardResponse{},
+	},
+	"POST /cards/{id}/block": {
+		Summary: "Block a card",
+	},
+	"POST /cards/{id}/unblock": {
+		Summary: "Unblock a card",
+	},
+	"POST /cards/{id}/reissue": {
+		Summary: "Reissue a card",
+		Description: "Issues a replacement with a new number, expiry date and CVV on the same account, with the same type " +
+			"and limits. The old card keeps its status until it expires; each card is reissued at most once. " +
+			"The full number and CVV of a virtual card are returned in this response only.",
+		Response: models.CardResponse{},
+		Status:   http.StatusCreated,
+	},
+	"DELETE /cards/{id}": {
+		Summary: "Delete a card",
+	},
+	"GET /cards/{id}/fx-quote": {
+		Summary: "Quote a purchase in a foreign currency",
+		Query: []apiParam{
+			{Name: "amount", Required: true, Format: "double"},
+			{Name: "currency", Required: true, Description: "ISO 4217 code of the purchase currency"},
+		},
+		Response: models.CurrencyConversion{},
+	},
+	"POST /cards/{id}/purchases": {
+		Summary:  "Pay with a card",
+		Request:  models.CardPurchaseRequest{},
+		Response: models.Transaction{},
+		Status:   http.StatusCreated,
+		Replay:   true,
+	},
+
+	// Credits
+	"POST /credits/applications": {
+		Summary:     "Apply for a credit",
+		Description: "The application is scored in the background and decided automatically or sent to manual review. The interest rate of a fixed-rate credit is the bank's credit.interest_rate_percent in force when the application is registered; a variable-rate credit follows the CBR key rate plus credit.variable_rate_margin_percent. The applicant's credit score moves the rate, or the margin, by up to half of scoring.rate_spread_percent either way (rate_adjustment), and a discount takes at most half of it. With insurance set, payment protection insurance is taken with the credit: its monthly premium (insurance_premium), credit.insurance_rate_percent a year of the amount, is added to every installment. Only users whose identity is verified and none of whose credits is delinquent or in collections may apply.",
+		Request:     models.CreateCreditApplicationRequest{},
+		Response:    models.CreditApplication{},
+		Status:      http.StatusAccepted,
+		Replay:      true,
+	},
+	"GET /credits/applications": {
+		Summary:  "List credit applications of the current user",
+		Response: []models.CreditApplication{},
+	},
+	"GET /credits/preview": {
+		Summary:     "Preview the schedule of a credit",
+		Description: "Computes the installments, monthly payment and full cost of a credit without creating anything, for loan calculators. The cost adds the origination fee and monthly insurance premium to the interest; apr is the effective annual rate and full_cost_rate the full cost of the credit as the CBR computes it, both of all the payments against the amount less the fee. The first installment is due a month from today. Without interest_rate a fixed rate is the bank's credit.interest_rate_percent and a variable rate the CBR key rate plus credit.variable_rate_margin_percent, before the adjustment by the credit score an application gets.",
+		Query: []apiParam{
+			{Name: "amount", Required: true, Format: "double"},
+			{Name: "term_months", Description: "Up to 360", Required: true, Format: "int32"},
+			{Name: "interest_rate", Description: "Annual rate in percent, the bank's rate when omitted", Format: "double"},
+			{Name: "rate_type", Description: "fixed (default) or variable"},
+			{Name: "schedule_type", Description: "ANNUITY (default) or DIFFERENTIATED"},
+			{Name: "origination_fee", Description: "Withheld from the amount on disbursement", Format: "double"},
+			{Name: "insurance_premium", Description: "Paid monthly with the installments", Format: "double"},
+		},
+		Response: models.CreditPreview{},
+	},
+	"GET /credits/applications/{id}": {
+		Summary:  "Get a credit application",
+		Response: models.CreditApplication{},
+	},
+	"GET /credits/{id}": {
+		Summary: "Get a credit",
+		Description: "The cost discloses the interest, the insurance premiums, the penalties accrued so far and the effective and full cost rates of the credit, " +
+			"from its current schedule: the installments paid and those due after any restructuring or repricing. " +
+			"Another user's credit is not found. " + etagDescription,
+		Response: models.Credit{},
+	},
+	"GET /credits/user/{user_id}": {
+		Summary:     "List credits of a user",
+		Description: pageDescription,
+		Query:       pageParams(repository.CreditPageOptions),
+		Response:    []models.Credit{},
+	},
+	"GET /credits/{id}/schedule": {
+		Summary:     "Payment schedule of a credit",
+		Description: pageDescription + " " + etagDescription,
+		Query:       pageParams(repository.PaymentSchedulePageOptions),
+		Response:    []models.PaymentSchedule{},
+	},
+	"POST /credits/{id}/pay": {
+		Summary: "Pay outstanding installments of a credit",
+		Request: models.PayCreditRequest{},
+		Replay:  true,
+	},
+	"POST /credits/{id}/repay-early": {
+		Summary:     "Repay a credit early",
+		Description: "An omitted amount repays the credit in full.",
+		Request:     models.EarlyRepaymentRequest{},
+		Response:    models.EarlyRepaymentResult{},
+		Replay:      true,
+	},
+	"POST /credits/{id}/restructure": {
+		Summary:  "Request a credit restructuring",
+		Request:  models.CreateCreditRestructuringRequest{},
+		Response: models.CreditRestructuring{},
+		Status:   http.StatusCreated,
+		Replay:   true,
+	},
+	"GET /credits/{id}/restructurings": {
+		Summary:  "List restructurings of a credit",
+		Response: []models.CreditRestructuring{},
+	},
+	"GET /credits/{id}/insurance": {
+		Summary:  "Get the insurance of a credit with its claims",
+		Response: models.CreditInsurance{},
+	},
+	"POST /credits/{id}/insurance/claims": {
+		Summary:     "File a claim on the insurance of a credit",
+		Description: "Once an administrator approves the claim the schedule of the credit is paused: no installment becomes overdue or accrues penalties until the claim is closed.",
+		Request:     models.CreateCreditInsuranceClaimRequest{},
+		Response:    models.CreditInsuranceClaim{},
+		Status:      http.StatusCreated,
+		Replay:      true,
+	},
+	"GET /credits/{id}/collateral": {
+		Summary:     "Get the collateral securing a credit",
+		Description: "ltv is the loan-to-value ratio: the remaining principal as a percentage of the appraised value of the collateral. It is omitted for an unsecured credit.",
+		Response:    models.CreditCollateral{},
+	},
+	"GET /credits/{id}/rate-changes": {
+		Summary:     "List repricings of a variable-rate credit",
+		Description: "Each repricing follows a change of the CBR key rate and rebuilds the installments not yet due or paid into.",
+		Response:    []models.CreditRateChange{},
+	},
+
+	// Analytics
+	"GET /analytics/transactions": {
+		Summary: "Transaction analytics for a period",
+		Description: "With format=xlsx the analytics are downloaded as a spreadsheet with the totals, " +
+			"the transactions by category, the monthly summaries and the transactions per day.",
+		Query: []apiParam{
+			{Name: "start_date", Required: true, Format: "date"},
+			{Name: "end_date", Required: true, Format: "date"},
+			{Name: "format", Description: "json (default) or xlsx"},
+		},
+		Response: service.TransactionAnalytics{},
+	},
+	"GET /analytics/credits": {
+		Summary: "Credit analytics of the current user",
+		Description: "With format=xlsx the analytics are downloaded as a spreadsheet with the totals, " +
+			"the credits and their payment schedules.",
+		Query: []apiParam{
+			{Name: "format", Description: "json (default) or xlsx"},
+		},
+		Response: service.CreditAnalytics{},
+	},
+	"GET /analytics/forecast": {
+		Summary: "Cash-flow forecast of the current user",
+		Description: "Projects the balance of all the user's accounts, in RUB, at the end of each of the next months. " +
+			"Every month the average income and other spending of up to six months of history are expected again, " +
+			"along with the recurring payments found in it, and the credit installments fall due on their schedule.",
+		Query: []apiParam{
+			{Name: "months", Description: "How many months to project, 3 by default and at most 12"},
+		},
+		Response: models.CashFlowForecast{},
+	},
+
+	// Event stream
+	"GET /stream": {
+		Summary: "Server-sent event stream of the current user's accounts and cards",
+		Description: "Pushes balance.changed, transfer.received and card.status_changed events as they are committed; " +
+			"the data of each event is a JSON object. A heartbeat comment is sent every STREAM_HEARTBEAT_INTERVAL and " +
+			"the stream ends with a session.expired event once the session is revoked or expires. Events sent while " +
+			"a client is disconnected are not replayed, so clients read the current state again after reconnecting. " +
+			"429 when the user already has STREAM_MAX_CONNECTIONS_PER_USER streams open.",
+		ContentType: "text/event-stream",
+	},
+
+	// GraphQL
+	"POST /graphql": {
+		Summary: "Execute a GraphQL query or mutation as the current user",
+		Description: "Queries me, accounts, account, transactions and credits, and the mutations transfer and " +
+			"payCredit; see GET /graphql/schema for the types. Nested transactions and schedules are loaded with " +
+			"one query per field for the whole response. Errors are returned in the errors of the response with " +
+			"status 200. Mutations need the replay protection headers of the payment routes.",
+		Request:  graphql.Request{},
+		Response: graphql.Response{},
+	},
+	"GET /graphql/schema": {
+		Summary:     "GraphQL schema in the schema definition language",
+		ContentType: "text/plain",
+	},
+
+	// Rates
+	"GET /rates": {
+		Summary:     "Official CBR exchange rates for today",
+		Description: "With currency set, only the rate of that currency is returned as a single object.",
+		Query: []apiParam{
+			{Name: "currency", Description: "ISO 4217 code"},
+		},
+		Response: models.CurrencyRates{},
+	},
+	"GET /rates/key-rate": {
+		Summary:  "CBR key rate",
+		Response: models.KeyRate{},
+	},
+
+	// Webhooks
+	"POST /webhooks/subscriptions": {
+		Summary:  "Subscribe to webhook events",
+		Request:  models.CreateWebhookSubscriptionRequest{},
+		Response: models.WebhookSubscription{},
+		Status:   http.StatusCreated,
+	},
+	"GET /webhooks/subscriptions": {
+		Summary:  "List webhook subscriptions",
+		Response: []models.WebhookSubscription{},
+	},
+	"GET /webhooks/subscriptions/{id}/events": {
+		Summary: "Event history of a subscription",
+		Query: []apiParam{
+			{Name: "from", Format: "date-time"},
+			{Name: "to", Format: "date-time"},
+		},
+		Response: []models.WebhookEventHistory{},
+	},
+	"POST /webhooks/subscriptions/{id}/events/{event_id}/replay": {
+		Summary:  "Replay an event",
+		Response: models.WebhookDelivery{},
+		Status:   http.StatusAccepted,
+	},
+	"POST /webhooks/subscriptions/{id}/replay": {
+		Summary:  "Replay events of a period",
+		Request:  models.ReplayWebhookEventsRequest{},
+		Response: models.ReplayWebhookEventsResult{},
+		Status:   http.StatusAccepted,
+	},
+	"POST /webhooks/verify-signature": {
+		Summary:  "Verify the signature of a received delivery",
+		Request:  models.VerifyWebhookSignatureRequest{},
+		Response: models.VerifyWebhookSignatureResult{},
+	},
+
+	// Administration
+	"GET /admin/search": {
+		Summary:     "Search users, accounts, credits, cards and transactions",
+		Description: "Support staff get email and phone masked.",
+		Role:        "admin or support",
+		Query: []apiParam{
+			{Name: "q", Required: true},
+		},
+		Response: []models.SearchResult{},
+	},
+	"POST /admin/ownership-transfers": {
+		Summary: "Request an account ownership transfer",
+		Description: "Once the transfer is approved the account is no longer shared: its members lose their access, and " +
+			"the active cards on it not held by the new owner are blocked, the previous owner's too without include_cards.",
+		Role:     "admin",
+		Request:  models.CreateOwnershipTransferRequest{},
+		Response: models.AccountOwnershipTransfer{},
+		Status:   http.StatusCreated,
+	},
+	"GET /admin/ownership-transfers": {
+		Summary:  "List ownership transfers",
+		Role:     "admin",
+		Query:    []apiParam{{Name: "status"}},
+		Response: []models.AccountOwnershipTransfer{},
+	},
+	"GET /admin/ownership-transfers/{id}": {
+		Summary:  "Get an ownership transfer",
+		Role:     "admin",
+		Response: models.AccountOwnershipTransfer{},
+	},
+	"POST /admin/ownership-transfers/{id}/approve": {
+		Summary:     "Approve an ownership transfer",
+		Description: "Must be approved by another administrator than the one who requested it.",
+		Role:        "admin",
+		Request:     models.ReviewOwnershipTransferRequest{},
+		Response:    models.AccountOwnershipTransfer{},
+	},
+	"POST /admin/ownership-transfers/{id}/reject": {
+		Summary:  "Reject an ownership transfer",
+		Role:     "admin",
+		Request:  models.ReviewOwnershipTransferRequest{},
+		Response: models.AccountOwnershipTransfer{},
+	},
+	"POST /admin/external-transfers/{id}/return": {
+		Summary:     "Return a transfer to another bank",
+		Description: "Records a return reported by the payment rail for a pending or processing transfer and releases its hold on the account.",
+		Role:        "admin",
+		Request:     models.ReturnExternalTransferRequest{},
+		Response:    models.ExternalTransfer{},
+	},
+	"GET /admin/incoming-payments": {
+		Summary:  "List payments received from the payment rail",
+		Role:     "admin",
+		Query:    []apiParam{{Name: "status", Description: "posted or unmatched"}},
+		Response: []models.IncomingPayment{},
+	},
+	"GET /admin/accounts/{id}/ownership-history": {
+		Summary:  "Ownership history of an account",
+		Role:     "admin",
+		Response: []models.AuditLog{},
+	},
+	"PUT /admin/accounts/{id}/overdraft": {
+		Summary:  "Set the overdraft limit and rate of an account",
+		Role:     "admin",
+		Request:  models.SetOverdraftRequest{},
+		Response: models.Account{},
+	},
+	"GET /admin/users/{id}/export": {
+		Summary: "Export everything held about a user",
+		Description: "Profile, settings, accounts, cards, credits and transactions as a JSON attachment, or a signed URL " +
+			"to download it. The export is audited.",
+		Role:     "admin",
+		Query:    []apiParam{deliveryParam},
+		Response: models.UserDataExport{},
+	},
+	"POST /admin/users/{id}/export": {
+		Summary: "Export everything held about a user in the background",
+		Description: "Answers 202 Accepted with the job and its URL in the Location header. Once the job has succeeded, " +
+			"GET /jobs/{id} links to a signed URL to download the export. The export is audited.",
+		Role:     "admin",
+		Response: models.JobReport{},
+		Status:   http.StatusAccepted,
+	},
+	"POST /admin/users/{id}/unlock": {
+		Summary:     "Unlock a user locked after failed logins",
+		Description: "Clears the lock and the failed login count. The unlock is audited.",
+		Role:        "admin",
+		Status:      http.StatusNoContent,
+	},
+	"GET /admin/users/{id}/limits": {
+		Summary:  "Get the transfer and withdrawal limits of a user",
+		Role:     "admin",
+		Response: models.UserLimitsResponse{},
+	},
+	"PUT /admin/users/{id}/limits": {
+		Summary:     "Override the transfer and withdrawal limits of a user",
+		Description: "Omitted limits return to the configured defaults; 0 blocks the transactions.",
+		Role:        "admin",
+		Request:     models.SetUserLimitsRequest{},
+		Response:    models.UserLimits{},
+	},
+	"GET /admin/kyc": {
+		Summary:     "List submitted identity verifications",
+		Description: "Pending verifications form the review queue. " + pageDescription,
+		Role:        "admin",
+		Query:       pageParams(repository.KYCVerificationPageOptions),
+		Response:    []models.KYCVerification{},
+	},
+	"GET /admin/kyc/documents/{id}": {
+		Summary:     "Download an identity document",
+		Description: "Every download is audited.",
+		Role:        "admin",
+		ContentType: "application/octet-stream",
+	},
+	"GET /admin/kyc/documents/{id}/url": {
+		Summary:     "Get a signed URL to view an identity document",
+		Description: "The URL works without authentication until it expires. Every URL issued is audited as a view.",
+		Role:        "admin",
+		Response:    models.SignedURL{},
+	},
+	"GET /admin/disputes": {
+		Summary:     "List the disputes",
+		Description: "Open disputes form the review queue. " + pageDescription,
+		Role:        "admin",
+		Query:       pageParams(repository.DisputePageOptions),
+		Response:    []models.Dispute{},
+	},
+	"GET /admin/disputes/{id}": {
+		Summary:  "Get a dispute with its attachments",
+		Role:     "admin",
+		Response: models.Dispute{},
+	},
+	"GET /admin/disputes/{id}/attachments/{attachment_id}/url": {
+		Summary:     "Get a signed URL to view a file attached to a dispute",
+		Description: "The URL works without authentication until it expires. Every URL issued is audited as a view.",
+		Role:        "admin",
+		Response:    models.SignedURL{},
+	},
+	"POST /admin/disputes/{id}/take": {
+		Summary: "Take an open dispute under review",
+		Description: "Credits the disputed amount to the account provisionally. Nobody reviews their own disputes. " +
+			"The user is notified and the review is audited.",
+		Role:     "admin",
+		Response: models.Dispute{},
+	},
+	"POST /admin/disputes/{id}/refund": {
+		Summary:     "Uphold a dispute under review",
+		Description: "Only the reviewer who took the dispute closes it. The provisional credit becomes final.",
+		Role:        "admin",
+		Request:     models.ReviewDisputeRequest{},
+		Response:    models.Dispute{},
+	},
+	"POST /admin/disputes/{id}/resolve": {
+		Summary: "Close a dispute under review that was settled otherwise",
+		Description: "For a dispute settled without the bank, such as by a refund from the merchant. The provisional credit " +
+			"is reversed; the comment is required and sent to the user.",
+		Role:     "admin",
+		Request:  models.ReviewDisputeRequest{},
+		Response: models.Dispute{},
+	},
+	"POST /admin/disputes/{id}/decline": {
+		Summary: "Decline a dispute under review",
+		Description: "The provisional credit is reversed, even if that takes the account into the negative; the comment is " +
+			"required and sent to the user.",
+		Role:     "admin",
+		Request:  models.ReviewDisputeRequest{},
+		Response: models.Dispute{},
+	},
+	"GET /admin/users/{id}/kyc": {
+		Summary:  "Get the identity verification of a user with the documents",
+		Role:     "admin",
+		Response: models.KYCVerification{},
+	},
+	"POST /admin/users/{id}/kyc/approve": {
+		Summary:     "Verify the identity of a user",
+		Description: "Nobody reviews their own documents. The user is notified and the review is audited.",
+		Role:        "admin",
+		Request:     models.ReviewKYCRequest{},
+		Response:    models.KYCVerification{},
+	},
+	"POST /admin/users/{id}/kyc/reject": {
+		Summary:     "Reject the identity documents of a user",
+		Description: "The comment is required and sent to the user, who may upload documents and submit again.",
+		Role:        "admin",
+		Request:     models.ReviewKYCRequest{},
+		Response:    models.KYCVerification{},
+	},
+	"GET /admin/credit-applications": {
+		Summary:  "List credit applications",
+		Role:     "admin",
+		Query:    []apiParam{{Name: "status"}},
+		Response: []models.CreditApplication{},
+	},
+	"POST /admin/credit-applications/{id}/approve": {
+		Summary:  "Approve a credit application and open the credit",
+		Role:     "admin",
+		Request:  models.ReviewCreditApplicationRequest{},
+		Response: models.CreditApplication{},
+	},
+	"POST /admin/credit-applications/{id}/reject": {
+		Summary:  "Reject a credit application",
+		Role:     "admin",
+		Request:  models.ReviewCreditApplicationRequest{},
+		Response: models.CreditApplication{},
+	},
+	"GET /admin/credit-restructurings": {
+		Summary:  "List credit restructurings",
+		Role:     "admin",
+		Query:    []apiParam{{Name: "status"}},
+		Response: []models.CreditRestructuring{},
+	},
+	"POST /admin/credit-restructurings/{id}/approve": {
+		Summary:  "Approve a credit restructuring and rebuild the schedule",
+		Role:     "admin",
+		Request:  models.ReviewCreditRestructuringRequest{},
+		Response: models.CreditRestructuring{},
+	},
+	"POST /admin/credit-restructurings/{id}/reject": {
+		Summary:  "Reject a credit restructuring",
+		Role:     "admin",
+		Request:  models.ReviewCreditRestructuringRequest{},
+		Response: models.CreditRestructuring{},
+	},
+	"GET /admin/insurance-claims": {
+		Summary:  "List credit insurance claims",
+		Role:     "admin",
+		Query:    []apiParam{{Name: "status"}},
+		Response: []models.CreditInsuranceClaim{},
+	},
+	"POST /admin/insurance-claims/{id}/approve": {
+		Summary:  "Approve an insurance claim and pause the schedule of the credit",
+		Role:     "admin",
+		Request:  models.ReviewCreditInsuranceClaimRequest{},
+		Response: models.CreditInsuranceClaim{},
+	},
+	"POST /admin/insurance-claims/{id}/reject": {
+		Summary:  "Reject an insurance claim",
+		Role:     "admin",
+		Request:  models.ReviewCreditInsuranceClaimRequest{},
+		Response: models.CreditInsuranceClaim{},
+	},
+	"POST /admin/insurance-claims/{id}/close": {
+		Summary:     "Close an approved insurance claim and resume the schedule",
+		Description: "The pending installments move forward by the months the schedule was paused, a started month counting in full; overdue installments accrue no penalty for the days of the pause.",
+		Role:        "admin",
+		Request:     models.ReviewCreditInsuranceClaimRequest{},
+		Response:    models.CreditInsuranceClaim{},
+	},
+	"GET /admin/collateral": {
+		Summary: "List collateral",
+		Role:    "admin",
+		Query: []apiParam{
+			{Name: "user_id", Description: "Owner of the collateral"},
+			{Name: "credit_id", Description: "Credit the collateral secures"},
+		},
+		Response: []models.Collateral{},
+	},
+	"POST /admin/collateral": {
+		Summary:     "Register collateral of a user",
+		Description: "The type is real_estate, vehicle, deposit, securities, equipment or other. A credit named in the request must belong to the user and still be repaid. The registration is audited.",
+		Role:        "admin",
+		Request:     models.CreateCollateralRequest{},
+		Response:    models.Collateral{},
+		Status:      http.StatusCreated,
+	},
+	"GET /admin/collateral/{id}": {
+		Summary:  "Get collateral with its documents",
+		Role:     "admin",
+		Response: models.Collateral{},
+	},
+	"PUT /admin/collateral/{id}/valuation": {
+		Summary:     "Record a new appraisal of collateral",
+		Description: "The previous value and the comment are kept in the audit log.",
+		Role:        "admin",
+		Request:     models.UpdateCollateralValuationRequest{},
+		Response:    models.Collateral{},
+	},
+	"POST /admin/collateral/{id}/link": {
+		Summary:     "Pledge collateral for a credit",
+		Description: "The credit must belong to the owner of the collateral and still be repaid; it replaces the credit the collateral secured before.",
+		Role:        "admin",
+		Request:     models.LinkCollateralRequest{},
+		Response:    models.Collateral{},
+	},
+	"POST /admin/collateral/{id}/release": {
+		Summary:  "Release collateral from the credit it secures",
+		Role:     "admin",
+		Response: models.Collateral{},
+	},
+	"POST /admin/collateral/{id}/documents": {
+		Summary:     "Upload a document of collateral",
+		Description: "A title deed, appraisal report or other document, as a JPEG or PNG image or a PDF file.",
+		Role:        "admin",
+		Upload: []apiParam{
+			{Name: "file", Required: true, Format: "binary"},
+		},
+		Response: models.CollateralDocument{},
+		Status:   http.StatusCreated,
+	},
+	"GET /admin/collateral/{id}/documents/{document_id}/url": {
+		Summary:     "Get a signed URL to view a document of collateral",
+		Description: "The URL works without authentication until it expires. Every URL issued is audited as a view.",
+		Role:        "admin",
+		Response:    models.SignedURL{},
+	},
+	"GET /admin/credits/{id}/collateral": {
+		Summary:     "Get the collateral securing any credit",
+		Description: "ltv is the loan-to-value ratio: the remaining principal as a percentage of the appraised value of the collateral.",
+		Role:        "admin",
+		Response:    models.CreditCollateral{},
+	},
+	"GET /admin/collections": {
+		Summary:     "List the collection cases of credits in arrears",
+		Description: "Open cases form the collections queue, the longest overdue first. " + pageDescription,
+		Role:        "admin",
+		Query: append(pageParams(repository.CollectionCasePageOptions),
+			apiParam{Name: "stage", Description: "delinquent or collections"}),
+		Response: []models.CollectionCase{},
+	},
+	"GET /admin/collections/summary": {
+		Summary:  "Collections dashboard: the open cases summed up per stage and currency",
+		Role:     "admin",
+		Response: models.CollectionSummary{},
+	},
+	"GET /admin/analytics": {
+		Summary: "Bank analytics: deposits, credit portfolio, new users and transaction volume",
+		Description: "Deposits and credits are summed up per currency as they stand; new users are counted per day " +
+			"and transactions per currency within the period. Results are cached for CACHE_ANALYTICS_TTL.",
+		Role: "admin",
+		Query: []apiParam{
+			{Name: "from", Description: "YYYY-MM-DD, 29 days before to by default"},
+			{Name: "to", Description: "YYYY-MM-DD, included, today by default; the period is at most 366 days"},
+		},
+		Response: models.BankAnalytics{},
+	},
+	"GET /admin/reconciliation": {
+		Summary:     "Get a nightly reconciliation run with the issues it found",
+		Description: "Every balance is checked against the sum of its ledger entries and against the opening balance plus the transaction log. Without a date the latest run is returned.",
+		Role:        "admin",
+		Query:       []apiParam{{Name: "date", Format: "date"}},
+		Response:    models.ReconciliationReport{},
+	},
+	"GET /admin/dashboard": {
+		Summary:     "Get the overview of the admin dashboard",
+		Description: "Reports the readiness of the service and the heartbeats of its background processes, the payments failed in the last 7 days (refunded bill payments, returned transfers to other banks and overdue credit installments), the outbox messages waiting by topic and the latest reconciliation run with its issues.",
+		Role:        "admin",
+		Response:    models.AdminDashboard{},
+	},
+	"GET /admin/export/accounting": {
+		Summary: "Export the ledger of a month to the accounting system",
+		Description: "Transactions are summed up per day, type, debit and credit balance sheet account and currency. " +
+			"CSV for a generic general ledger import or the XML exchange file of the 1C import.",
+		Role: "admin",
+		Query: []apiParam{
+			{Name: "period", Description: "YYYY-MM, the previous month by default"},
+			{Name: "format", Description: "csv (default) or xml"},
+			deliveryParam,
+		},
+		ContentType: "text/csv",
+	},
+	"GET /admin/fraud-checks": {
+		Summary:     "List transactions caught by the fraud rules",
+		Description: "Without a decision only checks that triggered a rule are listed; status=held gives the review queue.",
+		Role:        "admin",
+		Query:       []apiParam{{Name: "status"}, {Name: "decision"}},
+		Response:    []models.FraudCheck{},
+	},
+	"GET /admin/fraud-checks/{id}": {
+		Summary:  "Get a fraud check with the rules it triggered",
+		Role:     "admin",
+		Response: models.FraudCheck{},
+	},
+	"POST /admin/fraud-checks/{id}/approve": {
+		Summary:     "Release a held transaction",
+		Description: "Executes the held transfer or withdrawal; it fails if the account can no longer cover it.",
+		Role:        "admin",
+		Request:     models.ReviewFraudCheckRequest{},
+		Response:    models.FraudCheck{},
+	},
+	"POST /admin/fraud-checks/{id}/reject": {
+		Summary:  "Reject a held transaction",
+		Role:     "admin",
+		Request:  models.ReviewFraudCheckRequest{},
+		Response: models.FraudCheck{},
+	},
+	"POST /admin/card-batches": {
+		Summary:     "Issue cards to the employees of a corporate account",
+		Description: "A CSV body has the columns employee_id,full_name,email,delivery_address and takes the other fields as query parameters.",
+		Role:        "admin",
+		Query: []apiParam{
+			{Name: "account_id", Description: "CSV only"},
+			{Name: "card_type", Description: "CSV only"},
+			{Name: "daily_limit", Description: "CSV only"},
+			{Name: "monthly_limit", Description: "CSV only"},
+			{Name: "transaction_limit", Description: "CSV only"},
+		},
+		Request:  models.CreateCardBatchRequest{},
+		Response: models.CardBatch{},
+		Status:   http.StatusAccepted,
+		CSV:      true,
+	},
+	"GET /admin/card-batches/{id}": {
+		Summary:  "Card batch report",
+		Role:     "admin",
+		Response: models.CardBatch{},
+	},
+	"PUT /admin/card-batches/{id}/items/{item_id}/delivery": {
+		Summary: "Update the delivery of a batch card",
+		Role:    "admin",
+		Request: models.UpdateCardDeliveryRequest{},
+	},
+	"GET /admin/parameters": {
+		Summary:  "Business parameters with current and scheduled values",
+		Role:     "admin",
+		Response: []models.ParameterValue{},
+	},
+	"GET /admin/parameters/{key}": {
+		Summary:  "Version history of a parameter",
+		Role:     "admin",
+		Response: []models.BusinessParameter{},
+	},
+	"POST /admin/parameters/{key}": {
+		Summary:  "Schedule a new version of a parameter",
+		Role:     "admin",
+		Request:  models.CreateBusinessParameterRequest{},
+		Response: models.BusinessParameter{},
+		Status:   http.StatusCreated,
+	},
+	"POST /admin/scheduler/run": {
+		Summary:     "Run the payment scheduler now",
+		Description: "Responds with 409 while a run is already in progress.",
+		Role:        "admin",
+		Response:    map[string]string{},
+		Status:      http.StatusAccepted,
+	},
+	"POST /admin/api-keys": {
+		Summary: "Issue an API key for a machine-to-machine client",
+		Description: "The key and its request signing key are only returned here. Read-only keys may only read, " +
+			"payments keys act as the owner, admin keys are for administrators. A key with requests_per_hour and " +
+			"burst_size is limited by them on top of the address limits.",
+		Role:     "admin",
+		Request:  models.CreateAPIKeyRequest{},
+		Response: models.IssuedAPIKey{},
+		Status:   http.StatusCreated,
+	},
+	"GET /admin/api-keys": {
+		Summary:     "List API keys",
+		Description: "The optional user_id query parameter lists the keys of a single user.",
+		Role:        "admin",
+		Response:    []models.APIKey{},
+	},
+	"POST /admin/api-keys/{id}/rotate": {
+		Summary:     "Rotate an API key",
+		Description: "Issues a successor with the same owner, scope and limits; the old key keeps working for the rotation grace period.",
+		Role:        "admin",
+		Response:    models.IssuedAPIKey{},
+		Status:      http.StatusCreated,
+	},
+	"DELETE /admin/api-keys/{id}": {
+		Summary: "Revoke an API key",
+		Role:    "admin",
+		Status:  http.StatusNoContent,
+	},
+	"GET /admin/branches": {
+		Summary:  "List all branches",
+		Role:     "admin",
+		Response: []models.Branch{},
+	},
+	"POST /admin/branches": {
+		Summary:  "Open a branch",
+		Role:     "admin",
+		Request:  models.CreateBranchRequest{},
+		Response: models.Branch{},
+		Status:   http.StatusCreated,
+	},
+	"PUT /admin/branches/{id}": {
+		Summary:     "Update or close a branch",
+		Description: "A closed branch keeps its users, accounts and credits but takes no new registrations; the default branch cannot be closed.",
+		Role:        "admin",
+		Request:     models.UpdateBranchRequest{},
+		Response:    models.Branch{},
+	},
+	"GET /admin/billers": {
+		Summary:  "List all billers",
+		Role:     "admin",
+		Response: []models.Biller{},
+	},
+	"POST /admin/billers": {
+		Summary: "Add a biller to the catalog",
+		Description: "The provider must be one of BILLER_PROVIDERS. The account_pattern is a regular expression the whole " +
+			"customer account must match; a zero max_amount sets no maximum.",
+		Role:     "admin",
+		Request:  models.BillerRequest{},
+		Response: models.Biller{},
+		Status:   http.StatusCreated,
+	},
+	"PUT /admin/billers/{id}": {
+		Summary:     "Update a biller or take it out of the catalog",
+		Description: "An inactive biller is hidden from users and takes no payments; past payments keep the name they were made under.",
+		Role:        "admin",
+		Request:     models.BillerRequest{},
+		Response:    models.Biller{},
+	},
+	"PUT /admin/users/{id}/branch": {
+		Summary:     "Move a user to a branch",
+		Description: "The optional role user or branch_admin takes away or grants the administration of the branch.",
+		Role:        "admin",
+		Request:     models.AssignBranchRequest{},
+		Response:    models.UserResponse{},
+	},
+	"GET /admin/branches/{id}/report": {
+		Summary:     "Branch report",
+		Description: "Branch administrators see only their own branch.",
+		Role:        "admin or branch_admin",
+		Query: []apiParam{
+			{Name: "from", Description: "YYYY-MM-DD, the first day of the current month by default"},
+			{Name: "to", Description: "YYYY-MM-DD, included, today by default"},
+		},
+		Response: models.BranchReport{},
+	},
+	"GET /admin/aml/flags": {
+		Summary:     "List AML flags",
+		Description: "Transactions flagged by the anti-money laundering rules; open flags form the review queue. " + pageDescription,
+		Role:        "admin or compliance",
+		Query:       append(pageParams(repository.AMLFlagPageOptions), apiParam{Name: "rule"}),
+		Response:    []models.AMLFlag{},
+	},
+	"GET /admin/aml/flags/{id}": {
+		Summary:  "Get an AML flag",
+		Role:     "admin or compliance",
+		Response: models.AMLFlag{},
+	},
+	"POST /admin/aml/flags/{id}/take": {
+		Summary:     "Take an open AML flag under review",
+		Description: "Nobody reviews the flags of their own transactions.",
+		Role:        "admin or compliance",
+		Response:    models.AMLFlag{},
+	},
+	"POST /admin/aml/flags/{id}/report": {
+		Summary:     "Confirm an AML flag for the regulator report",
+		Description: "Only the reviewer who took the flag can close it.",
+		Role:        "admin or compliance",
+		Request:     models.ReviewAMLFlagRequest{},
+		Response:    models.AMLFlag{},
+	},
+	"POST /admin/aml/flags/{id}/dismiss": {
+		Summary:     "Dismiss an AML flag",
+		Description: "Only the reviewer who took the flag can close it.",
+		Role:        "admin or compliance",
+		Request:     models.ReviewAMLFlagRequest{},
+		Response:    models.AMLFlag{},
+	},
+	"GET /admin/aml/report": {
+		Summary:     "Regulator report of the AML flags reported within a month",
+		Description: "Flags over the threshold are reported under mandatory control, the others as suspicious.",
+		Role:        "admin or compliance",
+		Query: []apiParam{
+			{Name: "period", Description: "YYYY-MM, the previous month by default"},
+			{Name: "format", Description: "xml (default) or csv"},
+			deliveryParam,
+		},
+		ContentType: "application/xml",
+	},
+	"GET /admin/branches/{id}/users": {
+		Summary:     "List users of a branch",
+		Description: "Branch administrators see only their own branch. " + pageDescription,
+		Role:        "admin or branch_admin",
+		Query:       pageParams(repository.UserPageOptions),
+		Response:    []models.UserResponse{},
+	},
+}
+
+// openAPIDocument is the root of an OpenAPI 3.0 specification
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]map[string]interface{} `json:"securitySchemes"`
+}
+
+type openAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+// pageDescription describes the paging of the list operations
+const etagDescription = "The response carries an ETag; a request with If-None-Match naming it is answered 304 Not Modified without a body while the data is unchanged."
+
+// deliveryParam documents the choice of an export delivered as a signed URL
+var deliveryParam = apiParam{
+	Name:        "delivery",
+	Description: "link to store the export and respond with a signed URL to download it, an attachment by default",
+}
+
+const pageDescription = "Paged: X-Total-Count holds the number of items matching the filter and Link the next and previous pages."
+
+// pageParams documents the query parameters of a paged list
+func pageParams(opts pagination.Options) []apiParam {
+	params := []apiParam{
+		{Name: "limit", Description: fmt.Sprintf("Items per page, %d by default and at most %d", pagination.DefaultLimit, pagination.MaxLimit)},
+		{Name: "offset", Description: "Items to skip"},
+		{Name: "sort", Description: fmt.Sprintf("One of %s, %s by default; a leading - sorts descending",
+			strings.Join(opts.SortFields, ", "), opts.DefaultSort)},
+	}
+	if len(opts.Statuses) > 0 {
+		params = append(params, apiParam{Name: "status", Description: "One of " + strings.Join(opts.Statuses, ", ")})
+	}
+	return params
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// BuildOpenAPISpec generates the OpenAPI specification from the routes registered
+// in the router and returns the routes that have no entry in apiOperations, so
+// the caller can report them
+func (h *Handlers) BuildOpenAPISpec(router *mux.Router, apiPrefix, version string) ([]string, error) {
+	registry := newSchemaRegistry()
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   "Banking Service REST API",
+			Version: version,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]map[string]interface{}{
+				"bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": {"type": "apiKey", "in": "header", "name": middleware.APIKeyHeader},
+			},
+		},
+	}
+
+	var undocumented []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Subrouter prefixes match no method of their own
+			return nil
+		}
+
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		relative := strings.TrimPrefix(path, apiPrefix)
+		if relative == "" {
+			relative = "/"
+		}
+
+		for _, method := range methods {
+			op, ok := apiOperations[method+" "+relative]
+			if !ok {
+				undocumented = append(undocumented, method+" "+path)
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(method)] = buildOperation(registry, path, relative, op)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %w", err)
+	}
+	doc.Components.Schemas = registry.schemas
+
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI specification: %w", err)
+	}
+	h.openAPISpec = spec
+	h.openAPISpecURL = apiPrefix + "/openapi.json"
+
+	sort.Strings(undocumented)
+	return undocumented, nil
+}
+
+func buildOperation(registry *schemaRegistry, path, relative string, op apiOperation) *openAPIOperation {
+	operation := &openAPIOperation{
+		Tags:        []string{strings.Split(strings.TrimPrefix(relative, "/"), "/")[0]},
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   make(map[string]*openAPIResponse),
+	}
+	if op.Role != "" {
+		operation.Description = strings.TrimSpace(operation.Description + " Requires the " + op.Role + " role.")
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		schema := &openAPISchema{Type: "integer", Format: "int64"}
+		if !strings.HasSuffix(match[1], "id") {
+			schema = &openAPISchema{Type: "string"}
+		}
+		operation.Parameters = append(operation.Parameters, openAPIParameter{
+			Name: match[1], In: "path", Required: true, Schema: schema,
+		})
+	}
+	for _, param := range op.Query {
+		schema := &openAPISchema{Type: "string", Format: param.Format}
+		if param.Format == "double" {
+			schema.Type = "number"
+		}
+		operation.Parameters = append(operation.Parameters, openAPIParameter{
+			Name: param.Name, In: "query", Description: param.Description, Required: param.Required, Schema: schema,
+		})
+	}
+	if op.Replay {
+		operation.Parameters = append(operation.Parameters,
+			openAPIParameter{
+				Name: "X-Request-Nonce", In: "header",
+				Description: "Unique string of 16 to 128 characters, required when replay protection is enforced",
+				Schema:      &openAPISchema{Type: "string"},
+			},
+			openAPIParameter{
+				Name: "X-Request-Timestamp", In: "header",
+				Description: "Unix time in seconds, required when replay protection is enforced",
+				Schema:      &openAPISchema{Type: "integer", Format: "int64"},
+			},
+			openAPIParameter{
+				Name: "X-Request-Signature", In: "header",
+				Description: "Hex HMAC-SHA256, keyed with the signing_key returned at login, of the method, path with query, hex SHA-256 of the body, nonce and timestamp joined by newlines; required when replay protection is enforced",
+				Schema:      &openAPISchema{Type: "string"},
+			},
+		)
+	}
+
+	if op.Request != nil {
+		operation.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMediaType{"application/json": {Schema: registry.schemaOf(op.Request)}},
+		}
+		if op.CSV {
+			operation.RequestBody.Content["text/csv"] = openAPIMediaType{Schema: &openAPISchema{Type: "string"}}
+		}
+		operation.Responses["400"] = &openAPIResponse{
+			Description: "Invalid request body (unknown or duplicated fields, too deep nesting) or failed validation",
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: registry.schemaOf(middleware.ValidationErrorResponse{})},
+			},
+		}
+		operation.Responses["413"] = &openAPIResponse{Description: "Request body is too large"}
+	}
+	if len(op.Upload) > 0 {
+		form := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		for _, field := range op.Upload {
+			form.Properties[field.Name] = &openAPISchema{Type: "string", Format: field.Format, Description: field.Description}
+			if field.Required {
+				form.Required = append(form.Required, field.Name)
+			}
+		}
+		if operation.RequestBody == nil {
+			operation.RequestBody = &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{}}
+		}
+		operation.RequestBody.Content["multipart/form-data"] = openAPIMediaType{Schema: form}
+		operation.Responses["413"] = &openAPIResponse{Description: "Request body is too large"}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &openAPIResponse{Description: http.StatusText(status)}
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if schema := registry.schemaOf(op.Response); schema != nil || contentType != "application/json" {
+		success.Content = map[string]openAPIMediaType{contentType: {Schema: schema}}
+	}
+	operation.Responses[fmt.Sprint(status)] = success
+
+	if op.Screened {
+		operation.Responses["202"] = &openAPIResponse{
+			Description: "Held by the fraud rules for a review",
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: registry.schemaOf(models.FraudHoldResponse{})},
+			},
+		}
+		operation.Responses["403"] = &openAPIResponse{Description: "Declined by the fraud rules"}
+	}
+	if !op.Public {
+		operation.Security = []map[string][]string{{"bearerAuth": {}}, {"apiKeyAuth": {}}}
+		operation.Responses["401"] = &openAPIResponse{Description: "Missing or invalid bearer token or API key"}
+	}
+	if op.Role != "" {
+		operation.Responses["403"] = &openAPIResponse{Description: "Role not allowed"}
+	}
+	operation.Responses["default"] = &openAPIResponse{Description: "Error message as plain text"}
+
+	return operation
+}
+
+// OpenAPIHandler serves the OpenAPI specification of the REST API
+func (h *Handlers) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if h.openAPISpec == nil {
+		http.Error(w, "OpenAPI specification is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.openAPISpec)
+}
+
+// swaggerUIContentSecurityPolicy lets the documentation page load Swagger UI
+// from its CDN and run only the scripts carrying the nonce of the response
+const swaggerUIContentSecurityPolicy = "default-src 'none'; script-src 'nonce-%s'; style-src https://unpkg.com 'unsafe-inline'; " +
+	"img-src 'self' data: https://unpkg.com; connect-src 'self'; frame-ancestors 'none'"
+
+// SwaggerUIHandler serves Swagger UI for the OpenAPI specification
+func (h *Handlers) SwaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	encoded := base64.StdEncoding.EncodeToString(nonce)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(swaggerUIContentSecurityPolicy, encoded))
+	if err := swaggerUITemplate.Execute(w, map[string]string{"SpecURL": h.openAPISpecURL, "Nonce": encoded}); err != nil {
+		h.logger.WithError(err).Error("Failed to render Swagger UI")
+	}
+}
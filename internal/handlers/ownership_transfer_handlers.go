@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CreateOwnershipTransferHandler handles ownership transfer requests made by an administrator
+func (h *Handlers) CreateOwnershipTransferHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOwnershipTransferRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.ownershipTransferService.RequestTransfer(r.Context(), adminID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to request ownership transfer")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// ListOwnershipTransfersHandler handles ownership transfer listing
+func (h *Handlers) ListOwnershipTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.OwnershipTransferStatus(r.URL.Query().Get("status"))
+
+	transfers, err := h.ownershipTransferService.ListTransfers(r.Context(), status)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list ownership transfers")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+// GetOwnershipTransferHandler handles ownership transfer retrieval
+func (h *Handlers) GetOwnershipTransferHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	transferID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid ownership transfer ID")
+		http.Error(w, "Invalid ownership transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.ownershipTransferService.GetTransfer(r.Context(), transferID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ownership transfer")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// ApproveOwnershipTransferHandler handles approval of a pending ownership transfer
+func (h *Handlers) ApproveOwnershipTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewOwnershipTransfer(w, r, true)
+}
+
+// RejectOwnershipTransferHandler handles rejection of a pending ownership transfer
+func (h *Handlers) RejectOwnershipTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewOwnershipTransfer(w, r, false)
+}
+
+func (h *Handlers) reviewOwnershipTransfer(w http.ResponseWriter, r *http.Request, approve bool) {
+	vars := mux.Vars(r)
+	transferID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid ownership transfer ID")
+		http.Error(w, "Invalid ownership transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReviewOwnershipTransferRequest
+	if r.ContentLength > 0 {
+		if err := middleware.DecodeJSON(r.Body, &req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var transfer *models.AccountOwnershipTransfer
+	if approve {
+		transfer, err = h.ownershipTransferService.ApproveTransfer(r.Context(), adminID, transferID, req.Comment)
+	} else {
+		transfer, err = h.ownershipTransferService.RejectTransfer(r.Context(), adminID, transferID, req.Comment)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to review ownership transfer")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// GetAccountOwnershipHistoryHandler handles retrieval of an account's ownership audit trail
+func (h *Handlers) GetAccountOwnershipHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid account ID")
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.ownershipTransferService.GetOwnershipHistory(r.Context(), accountID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get account ownership history")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// ListParametersHandler handles listing of business parameters with their current values
+func (h *Handlers) ListParametersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.parameterService.List(r.Context(), time.Now()))
+}
+
+// GetParameterHistoryHandler handles retrieval of all versions of a business parameter
+func (h *Handlers) GetParameterHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	versions, err := h.parameterService.History(r.Context(), key)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get parameter history")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// SetParameterHandler handles scheduling of a new business parameter version
+func (h *Handlers) SetParameterHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	var req models.CreateBusinessParameterRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	param, err := h.parameterService.SetParameter(r.Context(), adminID, key, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to set parameter")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(param)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// CreatePayeeHandler handles saving a payee of the current user
+func (h *Handlers) CreatePayeeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.PayeeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	payee, err := h.payeeService.CreatePayee(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create payee")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payee)
+}
+
+// ListPayeesHandler handles listing of the current user's payees
+func (h *Handlers) ListPayeesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.PayeePageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payees, total, err := h.payeeService.ListPayees(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list payees")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payees)
+}
+
+// GetPayeeHandler handles retrieval of a payee of the current user
+func (h *Handlers) GetPayeeHandler(w http.ResponseWriter, r *http.Request) {
+	payeeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payee, err := h.payeeService.GetPayee(r.Context(), userID, payeeID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get payee")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payee)
+}
+
+// UpdatePayeeHandler handles replacing the name and payment details of a payee
+// of the current user
+func (h *Handlers) UpdatePayeeHandler(w http.ResponseWriter, r *http.Request) {
+	payeeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.PayeeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	payee, err := h.payeeService.UpdatePayee(r.Context(), userID, payeeID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update payee")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payee)
+}
+
+// DeletePayeeHandler handles removal of a payee of the current user
+func (h *Handlers) DeletePayeeHandler(w http.ResponseWriter, r *http.Request) {
+	payeeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.payeeService.DeletePayee(r.Context(), userID, payeeID); err != nil {
+		h.logger.WithError(err).Error("Failed to delete payee")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PayeeTransferHandler handles a transfer to a payee of the current user. When
+// the payee still has to be confirmed and no code is given, a code is emailed
+// to the user and the response is 202 Accepted without a transfer.
+func (h *Handlers) PayeeTransferHandler(w http.ResponseWriter, r *http.Request) {
+	payeeID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payee ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.PayeeTransferRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	if _, err := h.accountService.AuthorizeAccount(r.Context(), userID, req.FromAccountID, true); err != nil {
+		h.logger.WithError(err).Warn("Transfer from an account the user may not operate")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	result, err := h.payeeService.Transfer(r.Context(), userID, payeeID, &req, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		if h.writeFraudError(w, err) {
+			return
+		}
+		h.logger.WithError(err).Error("Failed to transfer to payee")
+		http.Error(w, err.Error(), payeeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case result.Status == models.PayeeTransferStatusConfirmationRequired:
+		w.WriteHeader(http.StatusAccepted)
+	case result.ExternalTransfer != nil:
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func payeeErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrAccountDormant):
+		return http.StatusForbidden
+	case strings.HasPrefix(msg, "too many wrong confirmation codes"):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
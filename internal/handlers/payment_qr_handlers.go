@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// GetAccountQRHandler handles generation of the payment QR code of an account,
+// optionally for an amount and with a comment
+func (h *Handlers) GetAccountQRHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var amount float64
+	if value := r.URL.Query().Get("amount"); value != "" {
+		if amount, err = strconv.ParseFloat(value, 64); err != nil {
+			http.Error(w, "amount must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	qr, err := h.paymentQRService.GenerateQR(r.Context(), userID, accountID, amount, r.URL.Query().Get("comment"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to generate payment QR code")
+		http.Error(w, err.Error(), paymentQRErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qr)
+}
+
+// QRTransferHandler handles a transfer to the payment details of a scanned QR
+// code. A transfer to another bank answers 201 Created with the pending
+// transfer.
+func (h *Handlers) QRTransferHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := middleware.GetRequestBodyFromContext(r.Context()).(*models.QRTransferRequest)
+	if !ok {
+		h.logger.Error("Failed to get request body from context")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.paymentQRService.Transfer(r.Context(), userID, req, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		if h.writeFraudError(w, err) {
+			return
+		}
+		h.logger.WithError(err).Error("Failed to transfer by QR code")
+		http.Error(w, err.Error(), paymentQRErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.ExternalTransfer != nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func paymentQRErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "internal server error", strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "unauthorized"), errors.Is(err, service.ErrAccountDormant):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// CreatePaymentRequestHandler handles a user asking another user for money
+func (h *Handlers) CreatePaymentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreatePaymentRequestRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	request, err := h.paymentRequestService.CreateRequest(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create payment request")
+		http.Error(w, err.Error(), paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// ListPaymentRequestsHandler handles listing of the payment requests sent to
+// the current user. Pending ones wait for the user to pay or decline them.
+func (h *Handlers) ListPaymentRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	h.listPaymentRequests(w, r, false)
+}
+
+// ListSentPaymentRequestsHandler handles listing of the payment requests the
+// current user sent
+func (h *Handlers) ListSentPaymentRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	h.listPaymentRequests(w, r, true)
+}
+
+func (h *Handlers) listPaymentRequests(w http.ResponseWriter, r *http.Request, sent bool) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.PaymentRequestPageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var requests []*models.PaymentRequest
+	var total int
+	if sent {
+		requests, total, err = h.paymentRequestService.ListSent(r.Context(), userID, page)
+	} else {
+		requests, total, err = h.paymentRequestService.ListReceived(r.Context(), userID, page)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list payment requests")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// GetPaymentRequestHandler handles retrieval of a payment request the current
+// user sent or received
+func (h *Handlers) GetPaymentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment request ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	request, err := h.paymentRequestService.GetRequest(r.Context(), userID, requestID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get payment request")
+		http.Error(w, err.Error(), paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// AcceptPaymentRequestHandler handles the payer paying a request. A payment
+// held by the fraud rules answers 202 Accepted and holds the request with it.
+func (h *Handlers) AcceptPaymentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment request ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.AcceptPaymentRequestRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	request, err := h.paymentRequestService.AcceptRequest(r.Context(), userID, requestID, &req, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		if h.writeFraudError(w, err) {
+			return
+		}
+		h.logger.WithError(err).Error("Failed to accept payment request")
+		http.Error(w, err.Error(), paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// DeclinePaymentRequestHandler handles the payer turning a request down
+func (h *Handlers) DeclinePaymentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment request ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.DeclinePaymentRequestRequest
+	if r.ContentLength > 0 && !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	request, err := h.paymentRequestService.DeclineRequest(r.Context(), userID, requestID, req.Reason)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to decline payment request")
+		http.Error(w, err.Error(), paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// CancelPaymentRequestHandler handles the requester withdrawing a request
+func (h *Handlers) CancelPaymentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment request ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	request, err := h.paymentRequestService.CancelRequest(r.Context(), userID, requestID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to cancel payment request")
+		http.Error(w, err.Error(), paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+func paymentRequestErrorStatus(err error) int {
+	switch err.Error() {
+	case "payment request not found", "user not found", "account not found":
+		return http.StatusNotFound
+	case "payment request is not pending", "payment request is held for review", "payment request has expired":
+		return http.StatusConflict
+	case "unauthorized: account does not belong to user", "unauthorized: view-only access to the account",
+		service.ErrAccountDormant.Error():
+		return http.StatusForbidden
+	case "internal server error":
+		return http.StatusInternalServerError
+	}
+	if strings.HasPrefix(err.Error(), "failed to") {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/gorilla/mux"
+)
+
+// ExportUserDataHandler handles the export of everything held about a user as
+// a JSON attachment, or a signed URL to download it with ?delivery=link
+func (h *Handlers) ExportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid user ID")
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := h.privacyService.ExportUserData(r.Context(), adminID, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export user data")
+		status := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	payload, err := json.Marshal(export)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to encode user data export")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.writeExport(w, r, fmt.Sprintf("user-%d.json", userID), "application/json", payload)
+}
+
+// QueueUserDataExportHandler handles an export of everything held about a user
+// made in the background, for users whose export takes too long to wait for.
+// The export is downloaded from the job.
+func (h *Handlers) QueueUserDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid user ID")
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.privacyService.QueueUserDataExport(r.Context(), adminID, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to queue user data export")
+		status := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	h.writeJobAccepted(w, job)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// GetProfileHandler handles retrieval of the current user's profile
+func (h *Handlers) GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	profile, err := h.userService.GetProfile(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user profile")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateProfileHandler handles a change of the current user's profile
+func (h *Handlers) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateProfileRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	profile, err := h.userService.UpdateProfile(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update user profile")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// ChangePasswordHandler handles a change of the current user's password
+func (h *Handlers) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.userService.ChangePassword(r.Context(), userID, &req); err != nil {
+		h.logger.WithError(err).Warn("Failed to change password")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CloseProfileHandler handles the closure of the current user's profile. The
+// personal data is anonymized once the retention period has passed.
+func (h *Handlers) CloseProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CloseProfileRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.privacyService.CloseProfile(r.Context(), userID, &req); err != nil {
+		h.logger.WithError(err).Warn("Failed to close user profile")
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "internal server error":
+			status = http.StatusInternalServerError
+		case "user not found":
+			status = http.StatusNotFound
+		case "user is already closed",
+			"accounts must be emptied before closing the profile",
+			"credits must be repaid before closing the profile",
+			"term deposits must be paid out before closing the profile":
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessionsHandler handles listing of the devices signed in as the current user
+func (h *Handlers) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID, _ := authctx.SessionIDFromContext(r.Context())
+
+	sessions, err := h.userService.GetSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get sessions")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSessionHandler handles signing a device of the current user out
+func (h *Handlers) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid session ID")
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.userService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		h.logger.WithError(err).Error("Failed to revoke session")
+		status := http.StatusInternalServerError
+		if err.Error() == "session not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
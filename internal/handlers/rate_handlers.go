@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetRatesHandler handles retrieval of current exchange rates
+func (h *Handlers) GetRatesHandler(w http.ResponseWriter, r *http.Request) {
+	if code := r.URL.Query().Get("currency"); code != "" {
+		rate, err := h.rateService.GetRate(code)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get exchange rate")
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rate)
+		return
+	}
+
+	rates, err := h.rateService.GetRates()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get exchange rates")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// GetKeyRateHandler handles retrieval of the CBR key rate
+func (h *Handlers) GetKeyRateHandler(w http.ResponseWriter, r *http.Request) {
+	keyRate, err := h.rateService.GetKeyRate()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get key rate")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keyRate)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/statement"
+	"github.com/gorilla/mux"
+)
+
+// GetTransactionReceiptHandler handles the download of the printable receipt of
+// a transaction as a PDF document, or an HTML page with ?format=html
+func (h *Handlers) GetTransactionReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	transactionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "html" {
+		http.Error(w, "format must be pdf or html", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	receipt, err := h.receiptService.GetReceipt(r.Context(), userID, transactionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get transaction receipt")
+		http.Error(w, err.Error(), receiptErrorStatus(err))
+		return
+	}
+
+	if format == "html" {
+		page, err := statement.RenderReceiptHTML(receipt)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to render transaction receipt")
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", statement.ReceiptFileName(receipt, format)))
+	w.Write(statement.RenderReceiptPDF(receipt))
+}
+
+// VerifyReceiptHandler handles the check of the verification code of a receipt
+// by anyone it was presented to
+func (h *Handlers) VerifyReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	reference := r.URL.Query().Get("reference")
+	code := r.URL.Query().Get("code")
+	if reference == "" || code == "" {
+		http.Error(w, "reference and code are required", http.StatusBadRequest)
+		return
+	}
+
+	verification, err := h.receiptService.Verify(r.Context(), reference, code)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to verify receipt")
+		http.Error(w, err.Error(), receiptErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+func receiptErrorStatus(err error) int {
+	switch err.Error() {
+	case "transaction not found":
+		return http.StatusNotFound
+	case "receipts are not available":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// GetReconciliationHandler handles retrieval of a reconciliation run with its
+// issues. Without a date the latest run is returned.
+func (h *Handlers) GetReconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	var date time.Time
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			h.logger.WithError(err).Error("Invalid reconciliation date")
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.reconciliationService.GetReport(r.Context(), date)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get reconciliation report")
+		status := http.StatusInternalServerError
+		if err.Error() == "reconciliation run not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ListSandboxEmailsHandler handles listing of the emails captured in sandbox
+// mode, newest first, optionally only those sent to ?recipient=
+func (h *Handlers) ListSandboxEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	emails := h.sandboxService.Emails(r.URL.Query().Get("recipient"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emails)
+}
+
+// ResetSandboxHandler handles wiping of the sandbox test data
+func (h *Handlers) ResetSandboxHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.sandboxService.Reset(r.Context()); err != nil {
+		h.logger.WithError(err).Error("Failed to reset sandbox")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/scheduler"
+)
+
+// PaymentScheduler returns the credit payment scheduler so the application can
+// start and stop it
+func (h *Handlers) PaymentScheduler() *scheduler.PaymentScheduler {
+	return h.paymentScheduler
+}
+
+// ReconciliationScheduler returns the nightly reconciliation scheduler so the
+// application can start and stop it
+func (h *Handlers) ReconciliationScheduler() *scheduler.ReconciliationScheduler {
+	return h.reconciliationScheduler
+}
+
+// JobScheduler returns the scheduler of the periodic jobs enabled in the
+// configuration so the application can start and stop it
+func (h *Handlers) JobScheduler() *scheduler.JobScheduler {
+	return h.jobScheduler
+}
+
+// RunSchedulerHandler handles manual runs of the credit payment scheduler. The
+// run continues in the background after the response is sent.
+func (h *Handlers) RunSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := authctx.UserIDFromContext(r.Context())
+
+	if err := h.paymentScheduler.Trigger(); err != nil {
+		h.logger.WithError(err).Error("Failed to trigger payment scheduler")
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, scheduler.ErrSchedulerRunning) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	h.logger.WithField("admin_id", adminID).Info("Payment scheduler run requested")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+)
+
+// AdminSearchHandler handles lookups across users, accounts, cards, credits and transactions
+func (h *Handlers) AdminSearchHandler(w http.ResponseWriter, r *http.Request) {
+	role, ok := authctx.RoleFromContext(r.Context())
+	if !ok {
+		h.logger.Error("Role not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	results, err := h.searchService.Search(r.Context(), role, r.URL.Query().Get("q"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to search")
+		status := http.StatusBadRequest
+		if err.Error() == "internal server error" {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
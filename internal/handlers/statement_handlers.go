@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// ListStatementsHandler handles listing of the user's stored monthly statements
+func (h *Handlers) ListStatementsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), repository.StatementFilePageOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	files, total, err := h.statementService.ListStatements(r.Context(), userID, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list statements")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, page, total)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// GetStatementURLHandler handles the issue of a signed URL to download a stored statement
+func (h *Handlers) GetStatementURLHandler(w http.ResponseWriter, r *http.Request) {
+	fileID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid statement ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	url, err := h.statementService.StatementURL(r.Context(), userID, fileID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get statement URL")
+		status := http.StatusInternalServerError
+		if err.Error() == "statement not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+// QueueAccountStatementHandler handles a request for the PDF statement of an
+// account for the days from start_date to end_date. The statement is rendered
+// in the background and downloaded from the job.
+func (h *Handlers) QueueAccountStatementHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid account ID")
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	start, err := time.Parse("2006-01-02", r.URL.Query().Get("start_date"))
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid start date")
+		http.Error(w, "Invalid start date", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", r.URL.Query().Get("end_date"))
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid end date")
+		http.Error(w, "Invalid end date", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.statementService.QueueAccountStatement(r.Context(), userID, accountID, start, end)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to queue account statement")
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "account not found":
+			status = http.StatusNotFound
+		case "internal server error":
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	h.writeJobAccepted(w, job)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/events"
+)
+
+// streamRetry is the time clients wait before reconnecting a dropped stream
+const streamRetry = 3 * time.Second
+
+// StreamHandler streams the balance changes, incoming transfers and card status
+// changes of the authenticated user as server-sent events. A comment is sent at
+// every heartbeat to keep proxies from closing the idle connection, and the
+// session of the token is checked again then: the stream ends once the session
+// is revoked or expires.
+func (h *Handlers) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Requests authenticated with an API key have no session
+	sessionID, _ := authctx.SessionIDFromContext(r.Context())
+
+	sub, err := h.eventStream.Subscribe(userID)
+	if err != nil {
+		if errors.Is(err, events.ErrTooManyStreams) {
+			http.Error(w, "Too many open event streams", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Event stream unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	// The stream stays open past the write timeout of the server
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.WithError(err).Error("Failed to clear the write deadline of an event stream")
+		http.Error(w, "Event stream unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.eventStream.HeartbeatInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind or on shutdown; the client reconnects
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+		case <-ticker.C:
+			if sessionID != "" {
+				active, err := h.userService.ValidateSession(r.Context(), sessionID)
+				if err == nil && !active {
+					fmt.Fprint(w, "event: session.expired\ndata: {}\n\n")
+					rc.Flush()
+					return
+				}
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// CreateTransferBatchHandler handles a batch of transfers from one account.
+// The transfers are sent either as JSON or as CSV; for CSV the source account
+// and the mode are passed as query parameters.
+func (h *Handlers) CreateTransferBatchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req *models.CreateTransferBatchRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		parsed, err := parseTransferBatchCSV(r)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to parse transfer batch CSV")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req = parsed
+	} else {
+		req = &models.CreateTransferBatchRequest{}
+		if err := middleware.DecodeJSON(r.Body, req); err != nil {
+			h.logger.WithError(err).Error("Failed to decode request body")
+			middleware.WriteDecodeError(w, err)
+			return
+		}
+	}
+
+	if err := middleware.Validate(req); err != nil {
+		h.logger.WithError(err).Warn("Transfer batch request failed validation")
+		middleware.WriteValidationError(w, err)
+		return
+	}
+
+	batch, err := h.transferBatchService.CreateBatch(r.Context(), userID, req, middleware.GetClientInfoFromContext(r.Context()))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create transfer batch")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", h.jobService.JobPath(*batch.JobID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetTransferBatchHandler handles retrieval of the progress and results of a
+// transfer batch
+func (h *Handlers) GetTransferBatchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	batchID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid transfer batch ID")
+		http.Error(w, "Invalid transfer batch ID", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.transferBatchService.GetBatch(r.Context(), userID, batchID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get transfer batch")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// parseTransferBatchCSV reads a transfer list with a header row. The amount
+// column is required together with to_account_id or to_account_number, the
+// reference column is optional.
+func parseTransferBatchCSV(r *http.Request) (*models.CreateTransferBatchRequest, error) {
+	query := r.URL.Query()
+	fromAccountID, err := strconv.ParseInt(query.Get("from_account_id"), 10, 64)
+	if err != nil {
+		return nil, errors.New("from_account_id query parameter is required")
+	}
+
+	req := &models.CreateTransferBatchRequest{
+		FromAccountID: fromAccountID,
+		Mode:          models.TransferBatchMode(query.Get("mode")),
+	}
+
+	reader := csv.NewReader(r.Body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV header row is required")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, errors.New("CSV column amount is required")
+	}
+	_, byID := columns["to_account_id"]
+	_, byNumber := columns["to_account_number"]
+	if !byID && !byNumber {
+		return nil, errors.New("CSV column to_account_id or to_account_number is required")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		entry := models.TransferBatchEntry{
+			ToAccountNumber: field(record, "to_account_number"),
+			Reference:       field(record, "reference"),
+		}
+		if value := field(record, "to_account_id"); value != "" {
+			if entry.ToAccountID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid to_account_id on line %d", line)
+			}
+		}
+		if entry.Amount, err = strconv.ParseFloat(field(record, "amount"), 64); err != nil {
+			return nil, fmt.Errorf("invalid amount on line %d", line)
+		}
+		req.Transfers = append(req.Transfers, entry)
+	}
+
+	return req, nil
+}
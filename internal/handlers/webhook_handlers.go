@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// defaultWebhookHistoryWindow is the event history listed when no range is given
+const defaultWebhookHistoryWindow = 7 * 24 * time.Hour
+
+// CreateWebhookSubscriptionHandler handles registration of a webhook endpoint
+func (h *Handlers) CreateWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWebhookSubscriptionRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create webhook subscription")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// GetWebhookSubscriptionsHandler handles listing of the user's webhook subscriptions
+func (h *Handlers) GetWebhookSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	subs, err := h.webhookService.GetUserSubscriptions(r.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get webhook subscriptions")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// GetWebhookEventsHandler handles listing of the events sent to a subscription.
+// The optional from and to query parameters are RFC 3339 times.
+func (h *Handlers) GetWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid webhook subscription ID")
+		http.Error(w, "Invalid webhook subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, "Invalid to time", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.Add(-defaultWebhookHistoryWindow)
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, "Invalid from time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	events, err := h.webhookService.GetSubscriptionEvents(r.Context(), userID, subscriptionID, from, to)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get webhook events")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ReplayWebhookEventHandler handles redelivery of a single event to a subscription
+func (h *Handlers) ReplayWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	subscriptionID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid webhook subscription ID")
+		http.Error(w, "Invalid webhook subscription ID", http.StatusBadRequest)
+		return
+	}
+	eventID, err := strconv.ParseInt(vars["event_id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid webhook event ID")
+		http.Error(w, "Invalid webhook event ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayEvent(r.Context(), userID, subscriptionID, eventID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to replay webhook event")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// ReplayWebhookEventsHandler handles redelivery of the events of a time range to a subscription
+func (h *Handlers) ReplayWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("Invalid webhook subscription ID")
+		http.Error(w, "Invalid webhook subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReplayWebhookEventsRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.webhookService.ReplayRange(r.Context(), userID, subscriptionID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to replay webhook events")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
+// VerifyWebhookSignatureHandler handles signature checks of received deliveries
+func (h *Handlers) VerifyWebhookSignatureHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyWebhookSignatureRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, ok := authctx.UserIDFromContext(r.Context())
+	if !ok {
+		h.logger.Error("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.webhookService.VerifySignature(r.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to verify webhook signature")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
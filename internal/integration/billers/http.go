@@ -0,0 +1,108 @@
+package billers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HTTPProvider makes payments through a provider's JSON API. A payment is
+// posted to <url>/payments with the API key as a bearer token and, when a
+// secret is set, the hex HMAC-SHA256 of the body in X-Signature. The provider
+// answers with {"status": "confirmed", "reference": "..."} or
+// {"status": "declined", "reason": "..."}.
+type HTTPProvider struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewHTTPProvider creates a new provider calling the API at baseURL
+func NewHTTPProvider(name, baseURL, apiKey, secret string, httpClient *http.Client) *HTTPProvider {
+	return &HTTPProvider{
+		name:       name,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		secret:     secret,
+		httpClient: httpClient,
+	}
+}
+
+// Name returns the provider name
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+// Pay posts a payment. Client errors other than a declined payment and server
+// errors leave the outcome unknown.
+func (p *HTTPProvider) Pay(ctx context.Context, payment *Payment) (*Confirmation, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"payment_id":       strconv.FormatInt(payment.ID, 10),
+		"biller_code":      payment.BillerCode,
+		"customer_account": payment.CustomerAccount,
+		"amount":           payment.Amount,
+		"currency":         payment.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/payments", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(payment.ID, 10))
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if p.secret != "" {
+		mac := hmac.New(sha256.New, []byte(p.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status    string `json:"status"`
+		Reference string `json:"reference"`
+		Reason    string `json:"reason"`
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%s returned status %d", p.name, resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid %s response with status %d: %w", p.name, resp.StatusCode, err)
+	}
+
+	switch {
+	case result.Status == "declined":
+		reason := result.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return nil, &DeclinedError{Reason: reason}
+	case result.Status == "confirmed" && resp.StatusCode < http.StatusMultipleChoices:
+		return &Confirmation{Reference: result.Reference}, nil
+	}
+	return nil, fmt.Errorf("%s returned status %d with payment status %q", p.name, resp.StatusCode, result.Status)
+}
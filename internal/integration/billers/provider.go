@@ -0,0 +1,113 @@
+// Package billers pays bills through the providers that serve billers such as
+// mobile operators and utilities. Every provider takes payments through the
+// same interface, so a new provider needs an implementation and a type in the
+// configuration and nothing else.
+package billers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+)
+
+// Provider types of the configuration
+const (
+	ProviderTypeHTTP    = "http"
+	ProviderTypeSandbox = "sandbox"
+)
+
+// Payment is a payment the bank asks a provider to make to a biller
+type Payment struct {
+	ID              int64 // the bank's payment ID; providers take a payment once per ID
+	BillerCode      string
+	CustomerAccount string // phone number, contract or personal account the payment is for
+	Amount          float64
+	Currency        string
+}
+
+// Confirmation is a provider's acceptance of a payment
+type Confirmation struct {
+	Reference string // the provider's ID of the payment
+}
+
+// DeclinedError is returned when a provider refuses a payment, for example to a
+// customer account the biller does not know
+type DeclinedError struct {
+	Reason string
+}
+
+func (e *DeclinedError) Error() string {
+	return "payment declined: " + e.Reason
+}
+
+// Provider makes payments to the billers it serves
+type Provider interface {
+	// Name identifies the provider in the catalog, metrics and logs
+	Name() string
+	// Pay makes a payment. It returns a DeclinedError when the provider refuses
+	// it and another error when the outcome is unknown.
+	Pay(ctx context.Context, payment *Payment) (*Confirmation, error)
+}
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry creates the providers of the configuration
+func NewRegistry(cfg *config.BillersConfig) (*Registry, error) {
+	httpClient := &http.Client{Timeout: cfg.Timeout}
+
+	var providers []Provider
+	for name, provider := range cfg.Providers {
+		switch provider.Type {
+		case ProviderTypeHTTP:
+			if provider.URL == "" {
+				return nil, fmt.Errorf("biller provider %q has no URL", name)
+			}
+			providers = append(providers, NewHTTPProvider(name, provider.URL, provider.APIKey, provider.Secret, httpClient))
+		case ProviderTypeSandbox:
+			providers = append(providers, NewSandboxProvider(name))
+		default:
+			return nil, fmt.Errorf("biller provider %q has unknown type %q, use %s or %s",
+				name, provider.Type, ProviderTypeHTTP, ProviderTypeSandbox)
+		}
+	}
+
+	return NewRegistryWithProviders(providers...), nil
+}
+
+// NewRegistryWithProviders creates a registry of the given providers
+func NewRegistryWithProviders(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, provider := range providers {
+		r.providers[provider.Name()] = provider
+	}
+	return r
+}
+
+// Has reports whether a provider of the name is configured
+func (r *Registry) Has(name string) bool {
+	_, ok := r.providers[name]
+	return ok
+}
+
+// Pay makes a payment through the named provider
+func (r *Registry) Pay(ctx context.Context, name string, payment *Payment) (*Confirmation, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("biller provider %q is not configured", name)
+	}
+
+	start := time.Now()
+	confirmation, err := provider.Pay(ctx, payment)
+	metrics.ObserveExternalCall("biller_"+provider.Name(), "pay", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return confirmation, nil
+}
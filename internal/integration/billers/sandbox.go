@@ -0,0 +1,27 @@
+package billers
+
+import (
+	"context"
+	"fmt"
+)
+
+// SandboxProvider confirms every payment without paying anything, so bill
+// payments can be tried out in development
+type SandboxProvider struct {
+	name string
+}
+
+// NewSandboxProvider creates a new sandbox provider
+func NewSandboxProvider(name string) *SandboxProvider {
+	return &SandboxProvider{name: name}
+}
+
+// Name returns the provider name
+func (p *SandboxProvider) Name() string {
+	return p.name
+}
+
+// Pay confirms the payment
+func (p *SandboxProvider) Pay(_ context.Context, payment *Payment) (*Confirmation, error) {
+	return &Confirmation{Reference: fmt.Sprintf("SANDBOX-%d", payment.ID)}, nil
+}
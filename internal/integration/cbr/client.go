@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
 	"github.com/beevik/etree"
 )
 
@@ -34,7 +37,7 @@ func (c *Client) GetKeyRate() (float64, error) {
 	soapRequest := c.buildKeyRateRequest()
 
 	// Send request
-	resp, err := c.sendRequest(soapRequest)
+	resp, err := c.sendRequest(soapRequest, "http://web.cbr.ru/KeyRate")
 	if err != nil {
 		return 0, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -65,27 +68,34 @@ func (c *Client) buildKeyRateRequest() string {
 }
 
 // sendRequest sends a SOAP request to CBR
-func (c *Client) sendRequest(soapRequest string) ([]byte, error) {
-	req, err := http.NewRequest(
-		"POST",
-		c.config.BaseURL+c.config.RateEndpoint,
-		bytes.NewBuffer([]byte(soapRequest)),
-	)
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) sendRequest(soapRequest string, soapAction string) (body []byte, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("cbr", soapAction[strings.LastIndex(soapAction, "/")+1:], start, err)
+	}()
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
-	req.Header.Set("SOAPAction", "http://web.cbr.ru/KeyRate")
-
-	// Send request with retries
+	// Send request with retries; the request is rebuilt on every attempt
+	// because its body is consumed by the previous one
 	var resp *http.Response
 	var lastErr error
 
 	for i := 0; i <= c.config.RetryCount; i++ {
+		req, err := http.NewRequest(
+			"POST",
+			c.config.BaseURL+c.config.RateEndpoint,
+			bytes.NewBuffer([]byte(soapRequest)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Set headers
+		req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+		req.Header.Set("SOAPAction", soapAction)
+
 		resp, err = c.httpClient.Do(req)
 		if err == nil {
+			lastErr = nil
 			break
 		}
 		lastErr = err
@@ -98,7 +108,7 @@ func (c *Client) sendRequest(soapRequest string) ([]byte, error) {
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err = io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -135,6 +145,93 @@ func (c *Client) parseKeyRateResponse(rawBody []byte) (float64, error) {
 	return rate, nil
 }
 
+// CurrencyRate represents the official CBR exchange rate of a foreign currency
+type CurrencyRate struct {
+	Code    string
+	NumCode int
+	Name    string
+	Nominal int
+	Rate    float64 // rubles per Nominal units
+}
+
+// GetCurrencyRates retrieves official exchange rates for the given date from CBR
+func (c *Client) GetCurrencyRates(date time.Time) ([]CurrencyRate, error) {
+	soapRequest := c.buildCursOnDateRequest(date)
+
+	resp, err := c.sendRequest(soapRequest, "http://web.cbr.ru/GetCursOnDate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	rates, err := c.parseCursOnDateResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return rates, nil
+}
+
+// buildCursOnDateRequest creates a SOAP request for daily exchange rates
+func (c *Client) buildCursOnDateRequest(date time.Time) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+		<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope">
+			<soap12:Body>
+				<GetCursOnDate xmlns="http://web.cbr.ru/">
+					<On_date>%s</On_date>
+				</GetCursOnDate>
+			</soap12:Body>
+		</soap12:Envelope>`, date.Format("2006-01-02"))
+}
+
+// parseCursOnDateResponse parses the SOAP response to extract exchange rates
+func (c *Client) parseCursOnDateResponse(rawBody []byte) ([]CurrencyRate, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(rawBody); err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+
+	elements := doc.FindElements("//diffgram/ValuteData/ValuteCursOnDate")
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("no rate data found in response")
+	}
+
+	rates := make([]CurrencyRate, 0, len(elements))
+	for _, element := range elements {
+		rate := CurrencyRate{
+			Code: strings.TrimSpace(childText(element, "./VchCode")),
+			Name: strings.TrimSpace(childText(element, "./Vname")),
+		}
+
+		nominal, err := strconv.Atoi(strings.TrimSpace(childText(element, "./Vnom")))
+		if err != nil {
+			// Nominal is sometimes sent as a decimal, e.g. "100.0"
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(childText(element, "./Vnom")), 64)
+			if ferr != nil {
+				return nil, fmt.Errorf("failed to parse nominal for %s: %w", rate.Code, err)
+			}
+			nominal = int(f)
+		}
+		rate.Nominal = nominal
+
+		if rate.Rate, err = strconv.ParseFloat(strings.TrimSpace(childText(element, "./Vcurs")), 64); err != nil {
+			return nil, fmt.Errorf("failed to parse rate for %s: %w", rate.Code, err)
+		}
+
+		rate.NumCode, _ = strconv.Atoi(strings.TrimSpace(childText(element, "./Vcode")))
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+func childText(element *etree.Element, path string) string {
+	child := element.FindElement(path)
+	if child == nil {
+		return ""
+	}
+	return child.Text()
+}
+
 // KeyRateResponse represents the CBR key rate response
 type KeyRateResponse struct {
 	XMLName xml.Name `xml:"Envelope"`
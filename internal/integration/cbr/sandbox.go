@@ -0,0 +1,33 @@
+package cbr
+
+import "time"
+
+// sandboxRates are the exchange rates reported in sandbox mode, in rubles per
+// nominal units
+var sandboxRates = []CurrencyRate{
+	{Code: "USD", NumCode: 840, Name: "Доллар США", Nominal: 1, Rate: 90},
+	{Code: "EUR", NumCode: 978, Name: "Евро", Nominal: 1, Rate: 100},
+	{Code: "CNY", NumCode: 156, Name: "Китайский юань", Nominal: 1, Rate: 12.5},
+	{Code: "GBP", NumCode: 826, Name: "Фунт стерлингов", Nominal: 1, Rate: 115},
+}
+
+// Sandbox reports fixed rates without calling CBR, so the results that depend
+// on them are the same on every run
+type Sandbox struct {
+	keyRate float64
+}
+
+// NewSandbox creates a client reporting keyRate as the key rate
+func NewSandbox(keyRate float64) *Sandbox {
+	return &Sandbox{keyRate: keyRate}
+}
+
+// GetKeyRate returns the configured key rate
+func (s *Sandbox) GetKeyRate() (float64, error) {
+	return s.keyRate, nil
+}
+
+// GetCurrencyRates returns the same exchange rates for every date
+func (s *Sandbox) GetCurrencyRates(time.Time) ([]CurrencyRate, error) {
+	return append([]CurrencyRate(nil), sandboxRates...), nil
+}
@@ -0,0 +1,74 @@
+package hibp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/metrics"
+)
+
+// Client checks passwords against the Have I Been Pwned Pwned Passwords range
+// API. Only the first five characters of the SHA-1 hash of a password leave the
+// server (k-anonymity); the suffixes of the hashes starting with them are
+// compared locally.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Pwned Passwords client
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// IsBreached tells whether a password appears in a known data breach
+func (c *Client) IsBreached(ctx context.Context, password string) (breached bool, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("hibp", "range", start, err)
+	}()
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/range/"+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the number of suffixes returned for the prefix
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned passwords returned status %d", resp.StatusCode)
+	}
+
+	// Every line is "<suffix>:<count>"; padding lines have a count of zero
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if found && candidate == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("invalid pwned passwords response: %w", err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// Provider sends text messages through an SMS gateway
+type Provider interface {
+	// Name identifies the gateway in metrics and logs
+	Name() string
+	// Send delivers a message to a phone number in E.164 format
+	Send(ctx context.Context, to, text string) error
+}
+
+// Client represents an SMS client sending through the configured provider
+type Client struct {
+	provider Provider
+}
+
+// NewClient creates a new SMS client for the provider named in the configuration
+func NewClient(config *config.SMSConfig) (*Client, error) {
+	httpClient := &http.Client{Timeout: config.Timeout}
+
+	var provider Provider
+	switch config.Provider {
+	case "twilio":
+		provider = NewTwilioProvider(config.TwilioAccountSID, config.TwilioAuthToken, config.From, httpClient)
+	case "smsc":
+		provider = NewSMSCProvider(config.SMSCLogin, config.SMSCPassword, config.From, httpClient)
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", config.Provider)
+	}
+
+	return NewClientWithProvider(provider), nil
+}
+
+// NewClientWithProvider creates a new SMS client sending through the given provider
+func NewClientWithProvider(provider Provider) *Client {
+	return &Client{provider: provider}
+}
+
+// SendSMS sends an SMS notification
+func (c *Client) SendSMS(ctx context.Context, notification *models.Notification) error {
+	start := time.Now()
+	err := c.provider.Send(ctx, notification.Recipient, notification.Content)
+	metrics.ObserveExternalCall(c.provider.Name(), "send_sms", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+
+	return nil
+}
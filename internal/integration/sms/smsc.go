@@ -0,0 +1,83 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const smscBaseURL = "https://smsc.ru"
+
+// SMSCProvider sends messages through the SMSC.ru HTTP API
+type SMSCProvider struct {
+	login      string
+	password   string
+	sender     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewSMSCProvider creates a new SMSC provider. An empty sender uses the default
+// sender name of the account.
+func NewSMSCProvider(login, password, sender string, httpClient *http.Client) *SMSCProvider {
+	return &SMSCProvider{
+		login:      login,
+		password:   password,
+		sender:     sender,
+		baseURL:    smscBaseURL,
+		httpClient: httpClient,
+	}
+}
+
+// Name returns the provider name
+func (p *SMSCProvider) Name() string {
+	return "smsc"
+}
+
+// Send submits a message. SMSC answers with status 200 and reports failures in
+// the body.
+func (p *SMSCProvider) Send(ctx context.Context, to, text string) error {
+	form := url.Values{}
+	form.Set("login", p.login)
+	form.Set("psw", p.password)
+	form.Set("phones", to)
+	form.Set("mes", text)
+	form.Set("charset", "utf-8")
+	form.Set("fmt", "3") // JSON response
+	if p.sender != "" {
+		form.Set("sender", p.sender)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/sys/send.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("smsc returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ID        int64  `json:"id"`
+		Error     string `json:"error"`
+		ErrorCode int    `json:"error_code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid smsc response: %w", err)
+	}
+	if result.Error != "" {
+		return fmt.Errorf("smsc error %d: %s", result.ErrorCode, result.Error)
+	}
+
+	return nil
+}
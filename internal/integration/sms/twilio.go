@@ -0,0 +1,79 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const twilioBaseURL = "https://api.twilio.com"
+
+// TwilioProvider sends messages through the Twilio Programmable Messaging API
+type TwilioProvider struct {
+	accountSID string
+	authToken  string
+	from       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewTwilioProvider creates a new Twilio provider sending from the given number
+// or messaging service SID
+func NewTwilioProvider(accountSID, authToken, from string, httpClient *http.Client) *TwilioProvider {
+	return &TwilioProvider{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		baseURL:    twilioBaseURL,
+		httpClient: httpClient,
+	}
+}
+
+// Name returns the provider name
+func (p *TwilioProvider) Name() string {
+	return "twilio"
+}
+
+// Send creates a message resource
+func (p *TwilioProvider) Send(ctx context.Context, to, text string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", text)
+	if strings.HasPrefix(p.from, "MG") {
+		form.Set("MessagingServiceSid", p.from)
+	} else {
+		form.Set("From", p.from)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.baseURL, url.PathEscape(p.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.accountSID, p.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("twilio returned status %d: %s (code %d)", resp.StatusCode, apiErr.Message, apiErr.Code)
+		}
+		return fmt.Errorf("twilio returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -1,10 +1,13 @@
 package smtp
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"time"
 
 	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
 	"github.com/Abigotado/abi_banking/internal/models"
 	"gopkg.in/mail.v2"
 )
@@ -29,8 +32,20 @@ func NewClient(config *config.SMTPConfig) *Client {
 	}
 }
 
+// Attachment represents a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
 // SendEmail sends an email using the configured SMTP server
 func (c *Client) SendEmail(notification *models.Notification) error {
+	return c.SendEmailWithAttachments(notification, nil)
+}
+
+// SendEmailWithAttachments sends an email with files attached using the configured SMTP server
+func (c *Client) SendEmailWithAttachments(notification *models.Notification, attachments []Attachment) error {
 	m := mail.NewMessage()
 
 	// Set headers
@@ -39,8 +54,17 @@ func (c *Client) SendEmail(notification *models.Notification) error {
 	m.SetHeader("Subject", notification.Subject)
 	m.SetBody("text/html", notification.Content)
 
+	for _, attachment := range attachments {
+		m.AttachReader(attachment.Name, bytes.NewReader(attachment.Data), mail.SetHeader(map[string][]string{
+			"Content-Type": {attachment.ContentType},
+		}))
+	}
+
 	// Send email
-	if err := c.dialer.DialAndSend(m); err != nil {
+	start := time.Now()
+	err := c.dialer.DialAndSend(m)
+	metrics.ObserveExternalCall("smtp", "send_email", start, err)
+	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -0,0 +1,72 @@
+package smtp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// mailboxCapacity bounds the emails a mailbox keeps; the oldest are dropped
+const mailboxCapacity = 1000
+
+// Mailbox captures emails in memory instead of sending them, so sandbox users
+// can read the codes and notifications sent to them through the API. Every
+// instance of the service keeps a mailbox of its own.
+type Mailbox struct {
+	mu     sync.Mutex
+	emails []*models.SandboxEmail
+	nextID int64
+}
+
+// NewMailbox creates an empty mailbox
+func NewMailbox() *Mailbox {
+	return &Mailbox{nextID: 1}
+}
+
+// SendEmailWithAttachments captures an email; attachments are kept by name only
+func (m *Mailbox) SendEmailWithAttachments(notification *models.Notification, attachments []Attachment) error {
+	email := &models.SandboxEmail{
+		Recipient: notification.Recipient,
+		Subject:   notification.Subject,
+		Content:   notification.Content,
+		SentAt:    time.Now(),
+	}
+	for _, attachment := range attachments {
+		email.Attachments = append(email.Attachments, attachment.Name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	email.ID = m.nextID
+	m.nextID++
+	if len(m.emails) == mailboxCapacity {
+		m.emails = m.emails[1:]
+	}
+	m.emails = append(m.emails, email)
+	return nil
+}
+
+// Emails returns the captured emails, newest first, optionally only those sent
+// to recipient
+func (m *Mailbox) Emails(recipient string) []*models.SandboxEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	emails := make([]*models.SandboxEmail, 0, len(m.emails))
+	for i := len(m.emails) - 1; i >= 0; i-- {
+		if recipient == "" || strings.EqualFold(m.emails[i].Recipient, recipient) {
+			emails = append(emails, m.emails[i])
+		}
+	}
+	return emails
+}
+
+// Reset drops every captured email
+func (m *Mailbox) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.emails = nil
+	m.nextID = 1
+}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/worker"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	runnerHeartbeat    = "job_runner"
+	defaultMaxAttempts = 3
+	retryBaseDelay     = 30 * time.Second
+)
+
+// Handler processes a single job. Returning an error schedules a retry while the
+// job has attempts left, so handlers must be safe to run more than once.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Runner polls the jobs table and dispatches due jobs to their registered
+// handlers. A pool of workers polls the table, each running one job at a time.
+type Runner struct {
+	repo     *repository.JobRepository
+	cfg      *config.JobsConfig
+	logger   *logrus.Logger
+	monitor  *alerting.HealthMonitor
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	done     chan bool
+}
+
+// EnqueueOption sets an optional field of a queued job
+type EnqueueOption func(*models.Job)
+
+// OwnedBy records the user a job works for, who can then follow its progress
+func OwnedBy(userID int64) EnqueueOption {
+	return func(job *models.Job) {
+		job.UserID = &userID
+	}
+}
+
+// NewRunner creates a new job runner
+func NewRunner(repo *repository.JobRepository, cfg *config.JobsConfig, logger *logrus.Logger) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		repo:     repo,
+		cfg:      cfg,
+		logger:   logger,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan bool),
+	}
+}
+
+// Register sets the handler for a job type
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+// SetHealthMonitor reports polling runs to the health monitor
+func (r *Runner) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	r.monitor = monitor
+	monitor.RegisterHeartbeat(runnerHeartbeat, 3*r.cfg.PollInterval+r.cfg.LeaseTimeout)
+}
+
+// Enqueue queues a job to run as soon as possible
+func (r *Runner) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...EnqueueOption) (*models.Job, error) {
+	job, err := newJob(jobType, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// EnqueueTx queues a job within a database transaction, so that the job only runs
+// if the work that requested it is committed
+func (r *Runner) EnqueueTx(ctx context.Context, tx repository.Tx, jobType string, payload interface{}, opts ...EnqueueOption) (*models.Job, error) {
+	job, err := newJob(jobType, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.CreateTx(ctx, tx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func newJob(jobType string, payload interface{}, opts []EnqueueOption) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     data,
+		Status:      models.JobStatusQueued,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return job, nil
+}
+
+// SetResult records what a job produced, such as the resource it created or
+// the stored file to download. Handlers call it before returning, so a retry
+// overwrites the result of a failed attempt.
+func (r *Runner) SetResult(ctx context.Context, jobID int64, result *models.JobResult) error {
+	return r.repo.SetResult(ctx, jobID, result)
+}
+
+// Start begins polling for jobs with the configured number of workers
+func (r *Runner) Start() {
+	workers := r.cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	r.logger.Infof("Starting job runner with %d workers", workers)
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.run()
+	}
+}
+
+// Stop stops polling and waits for the jobs in progress to finish, which are
+// cancelled when ctx is done first
+func (r *Runner) Stop(ctx context.Context) {
+	r.logger.Info("Stopping job runner")
+	close(r.done)
+	if worker.Drain(ctx, &r.wg, r.cancel) {
+		r.logger.Warn("Job runner forced to stop")
+	}
+	r.cancel()
+}
+
+// run polls for jobs until Stop is called. The workers poll on tickers of
+// their own; SKIP LOCKED in the claim query keeps them from taking the same job.
+func (r *Runner) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.poll()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// stopping reports whether Stop has been called, after which no more work is
+// claimed
+func (r *Runner) stopping() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// poll drains all due jobs before waiting for the next tick
+func (r *Runner) poll() {
+	for r.ctx.Err() == nil && !r.stopping() {
+		job, err := r.repo.ClaimNext(r.ctx, r.cfg.LeaseTimeout)
+		if err != nil {
+			r.logger.Errorf("Failed to claim job: %v", err)
+			return
+		}
+		if job == nil {
+			break
+		}
+		r.process(job)
+	}
+
+	if r.monitor != nil {
+		r.monitor.Beat(runnerHeartbeat)
+	}
+}
+
+func (r *Runner) process(job *models.Job) {
+	logger := r.logger.WithFields(logrus.Fields{
+		"job_id":   job.ID,
+		"job_type": job.Type,
+		"attempt":  job.Attempts,
+	})
+
+	r.mu.RLock()
+	handler, ok := r.handlers[job.Type]
+	r.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %s", job.Type)
+	} else {
+		err = r.safeHandle(handler, job)
+	}
+
+	if err == nil {
+		logger.Info("Job succeeded")
+		if err := r.repo.MarkSucceeded(r.ctx, job.ID); err != nil {
+			logger.WithError(err).Error("Failed to record job success")
+		}
+		return
+	}
+
+	logger.WithError(err).Warn("Job failed")
+	retryAt := time.Now().Add(retryBaseDelay * time.Duration(job.Attempts*job.Attempts))
+	if err := r.repo.MarkFailed(r.ctx, job.ID, err.Error(), retryAt); err != nil {
+		logger.WithError(err).Error("Failed to record job failure")
+	}
+}
+
+// safeHandle runs a handler and turns a panic into a job failure
+func (r *Runner) safeHandle(handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return handler(r.ctx, job)
+}
@@ -0,0 +1,52 @@
+// Package locale formats dates and times in the language chosen in the user
+// settings. Russian is the default; English is the only other language.
+package locale
+
+import (
+	"fmt"
+	"time"
+)
+
+// Supported languages of the user settings
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+var russianMonths = [...]string{
+	"январь", "февраль", "март", "апрель", "май", "июнь",
+	"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь",
+}
+
+// Normalize returns the language itself when it is supported and Russian otherwise
+func Normalize(language string) string {
+	if language == English {
+		return English
+	}
+	return Russian
+}
+
+// FormatDate formats a date the way it is written in the language
+func FormatDate(language string, t time.Time) string {
+	if Normalize(language) == English {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("02.01.2006")
+}
+
+// FormatDateTime formats a date and time of day with the time zone abbreviation
+func FormatDateTime(language string, t time.Time) string {
+	if Normalize(language) == English {
+		return t.Format("2006-01-02 15:04 MST")
+	}
+	return t.Format("02.01.2006 15:04 MST")
+}
+
+// FormatMonth formats the month and year of a date, e.g. "January 2006" or
+// "январь 2006"
+func FormatMonth(language string, t time.Time) string {
+	if Normalize(language) == English {
+		return t.Format("January 2006")
+	}
+	return fmt.Sprintf("%s %d", russianMonths[t.Month()-1], t.Year())
+}
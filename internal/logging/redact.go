@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redacted replaces a sensitive value
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the field names whose values are never logged. A field
+// matches when its lower-cased name contains one of them, so "new_password"
+// and "refresh_token" match too.
+var sensitiveKeys = []string{
+	"password", "passwd", "cvv", "cvc", "pin_code", "token", "secret", "authorization",
+	"api_key", "apikey", "signing_key", "signature", "card_number",
+}
+
+// sensitiveKeyPattern matches a name containing one of the sensitive keys
+var sensitiveKeyPattern = `[a-z_\-]*(?:` + strings.Join(sensitiveKeys, "|") + `)[a-z_\-]*`
+
+var (
+	// keyValuePattern finds sensitive keys with their values in JSON, query
+	// strings and "key=value" text. Unquoted keys need "=", so that a message
+	// like "failed to hash password: ..." keeps its cause.
+	keyValuePattern = regexp.MustCompile(`(?i)("` + sensitiveKeyPattern + `"\s*:\s*|\b` + sensitiveKeyPattern + `=)("(?:[^"\\]|\\.)*"|[^\s,&;}\]]+)`)
+
+	// bearerPattern finds credentials of Authorization headers
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/]+=*`)
+
+	// jwtPattern finds JSON web tokens outside of a header
+	jwtPattern = regexp.MustCompile(`\beyJ[a-zA-Z0-9_\-]+\.[a-zA-Z0-9_\-]+\.[a-zA-Z0-9_\-]*`)
+
+	// apiKeyPattern finds the API keys of machine-to-machine clients
+	apiKeyPattern = regexp.MustCompile(`\babk_[0-9a-f]{16,}`)
+
+	// cardNumberPattern finds runs of 13 to 19 digits, optionally grouped by
+	// spaces or dashes, which are card numbers when they pass the Luhn check
+	cardNumberPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// IsSensitiveKey reports whether a field name holds a secret or card data
+func IsSensitiveKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact removes card numbers, CVVs, passwords, tokens and keys from text. The
+// last four digits of a card number are kept, as receipts show them.
+func Redact(text string) string {
+	text = keyValuePattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := keyValuePattern.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[2], `"`) {
+			return parts[1] + `"` + redacted + `"`
+		}
+		return parts[1] + redacted
+	})
+	text = bearerPattern.ReplaceAllString(text, "$1 "+redacted)
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	text = apiKeyPattern.ReplaceAllString(text, "abk_"+redacted)
+	return cardNumberPattern.ReplaceAllStringFunc(text, func(match string) string {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, match)
+		if !luhnValid(digits) {
+			return match
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+// luhnValid reports whether a number passes the Luhn check of card numbers
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
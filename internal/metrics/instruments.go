@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	// HTTPRequests counts handled requests by route template and status code
+	HTTPRequests = NewCounterVec(
+		"http_requests_total",
+		"Number of HTTP requests handled.",
+		"method", "route", "status",
+	)
+	// HTTPRequestDuration measures handler latency by route template
+	HTTPRequestDuration = NewHistogramVec(
+		"http_request_duration_seconds",
+		"HTTP request latency in seconds.",
+		DefaultBuckets,
+		"method", "route",
+	)
+	// SchedulerRuns counts background scheduler runs by scheduler and outcome
+	SchedulerRuns = NewCounterVec(
+		"scheduler_runs_total",
+		"Number of background scheduler runs.",
+		"scheduler", "outcome",
+	)
+	// CreditPaymentFailures counts scheduled credit payments that could not be collected
+	CreditPaymentFailures = NewCounterVec(
+		"credit_payment_failures_total",
+		"Number of scheduled credit payments that failed.",
+		"reason",
+	)
+	// OutboxPublished counts outbox message publish attempts by topic and outcome
+	OutboxPublished = NewCounterVec(
+		"outbox_published_total",
+		"Number of outbox message publish attempts.",
+		"topic", "outcome",
+	)
+	// FraudChecks counts fraud rule evaluations by transaction type and decision
+	FraudChecks = NewCounterVec(
+		"fraud_checks_total",
+		"Number of transactions checked by the fraud rules.",
+		"type", "decision",
+	)
+	// CacheRequests counts cache calls by the kind of the cached value, the
+	// operation and its outcome: a hit or a miss for reads
+	CacheRequests = NewCounterVec(
+		"cache_requests_total",
+		"Number of cache calls.",
+		"cache", "operation", "outcome",
+	)
+	// ExternalCallDuration measures calls to external systems such as the CBR and SMTP
+	ExternalCallDuration = NewHistogramVec(
+		"external_call_duration_seconds",
+		"Duration of calls to external systems in seconds.",
+		DefaultBuckets,
+		"system", "operation", "outcome",
+	)
+)
+
+// ObserveExternalCall records the duration and outcome of a call to an external system
+func ObserveExternalCall(system, operation string, start time.Time, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	ExternalCallDuration.Observe(time.Since(start).Seconds(), system, operation, outcome)
+}
+
+// RegisterDBStats exposes the connection pool statistics of db
+func RegisterDBStats(db *sql.DB) {
+	NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(db.Stats().MaxOpenConnections)
+	})
+	NewGaugeFunc("db_open_connections", "Number of established connections, both in use and idle.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	NewGaugeFunc("db_in_use_connections", "Number of connections currently in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	NewGaugeFunc("db_idle_connections", "Number of idle connections.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	NewCounterFunc("db_wait_count_total", "Total number of connections waited for.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	NewCounterFunc("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+}
@@ -0,0 +1,259 @@
+// Package metrics collects application metrics and exposes them in the Prometheus
+// text exposition format. Collectors are registered on a package-level registry,
+// which /metrics renders on every scrape.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds suited to HTTP handlers and
+// external calls
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector writes its samples in the text exposition format
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var registry = struct {
+	sync.Mutex
+	collectors map[string]collector
+}{collectors: make(map[string]collector)}
+
+func register(c collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	registry.collectors[c.name()] = c
+}
+
+// Handler renders all registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registry.Lock()
+		collectors := make([]collector, 0, len(registry.collectors))
+		for _, c := range registry.collectors {
+			collectors = append(collectors, c)
+		}
+		registry.Unlock()
+
+		sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
+
+// series holds the label values of one sample set, keyed by their joined form
+type series struct {
+	mu     sync.Mutex
+	labels []string
+	values map[string][]string
+}
+
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string(nil), values...)
+	}
+	return key
+}
+
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *series) format(values []string, extra ...string) string {
+	var pairs []string
+	for i, label := range s.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels
+type CounterVec struct {
+	metricName string
+	help       string
+	series
+	counts map[string]float64
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metricName: name,
+		help:       help,
+		series:     series{labels: labels, values: make(map[string][]string)},
+		counts:     make(map[string]float64),
+	}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta to the counter with the given label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(values)] += delta
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.format(c.values[key]), formatFloat(c.counts[key]))
+	}
+}
+
+type histogramData struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by labels
+type HistogramVec struct {
+	metricName string
+	help       string
+	bounds     []float64
+	series
+	data map[string]*histogramData
+}
+
+// NewHistogramVec creates and registers a histogram with the given bucket upper bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		bounds:     bounds,
+		series:     series{labels: labels, values: make(map[string][]string)},
+		data:       make(map[string]*histogramData),
+	}
+	register(h)
+	return h
+}
+
+// Observe records a value for the given label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(values)
+	data, ok := h.data[key]
+	if !ok {
+		data = &histogramData{buckets: make([]uint64, len(h.bounds))}
+		h.data[key] = data
+	}
+	for i, bound := range h.bounds {
+		if value <= bound {
+			data.buckets[i]++
+		}
+	}
+	data.count++
+	data.sum += value
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+	for _, key := range h.sortedKeys() {
+		values, data := h.values[key], h.data[key]
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.format(values, "le", formatFloat(bound)), data.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.format(values, "le", "+Inf"), data.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.format(values), formatFloat(data.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.format(values), data.count)
+	}
+}
+
+// GaugeFunc reports a value read at scrape time
+type GaugeFunc struct {
+	metricName string
+	help       string
+	kind       string
+	value      func() float64
+}
+
+// NewGaugeFunc creates and registers a gauge whose value is read on every scrape
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, kind: "gauge", value: value}
+	register(g)
+	return g
+}
+
+// NewCounterFunc creates and registers a counter whose value is read on every
+// scrape, for totals kept by another component
+func NewCounterFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, kind: "counter", value: value}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, g.kind)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.value()))
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader carries the API key of a machine-to-machine client
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyValidator returns the active API key a request presents, or nil for an
+// unknown, revoked or expired key
+type APIKeyValidator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+}
+
+// APIKeyAuth middleware authenticates requests carrying an X-API-Key header as
+// the owner of the key and hands the other requests to jwtAuth. Read-only keys
+// are refused anything but reads, and only admin keys pass the admin role
+// checks. A key with a rate limit of its own is also limited by it, on top of
+// the limits of the client address; store may be nil when rate limiting is off.
+func APIKeyAuth(keys APIKeyValidator, jwtAuth func(http.Handler) http.Handler, store RateLimitStore, logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		tokenAuth := jwtAuth(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get(APIKeyHeader)
+			if presented == "" {
+				tokenAuth.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := keys.AuthenticateAPIKey(r.Context(), presented)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if key == nil {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if !key.Allows(r.Method) {
+				http.Error(w, "API key scope does not allow this request", http.StatusForbidden)
+				return
+			}
+
+			policy := RateLimitPolicy{RequestsPerHour: key.RequestsPerHour, Burst: key.BurstSize}
+			if store != nil && policy.RequestsPerHour > 0 && policy.Burst > 0 {
+				if !takeRateLimitToken(w, r, store, fmt.Sprintf("ratelimit:apikey:%d", key.ID), policy, logger) {
+					return
+				}
+			}
+
+			recordLogUser(r.Context(), key.UserID)
+			ctx := authctx.WithUser(r.Context(), key.UserID, key.Role(), "")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -7,27 +7,48 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/secrets"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID int64           `json:"user_id"`
+	Role   models.UserRole `json:"role"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+var jwtSecret = secrets.Static(os.Getenv("JWT_SECRET"))
+
+// SetJWTSecret sets the secret tokens are signed with and request signing keys
+// are derived from; the same secret has to be given to Auth
+func SetJWTSecret(secret *secrets.Secret) {
+	jwtSecret = secret
+}
+
+// TokenTTL is how long a token, and the session it is issued for, is accepted
+const TokenTTL = 24 * time.Hour
+
+// GenerateToken issues a token for a session, whose ID becomes the jti claim
+func GenerateToken(ctx context.Context, userID int64, role models.UserRole, sessionID string) (string, error) {
+	secret, err := jwtSecret.Value(ctx)
+	if err != nil {
+		return "", err
+	}
 
-func GenerateToken(userID int64) (string, error) {
 	claims := Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString([]byte(secret))
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -44,24 +65,49 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenString := parts[1]
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseToken(r.Context(), parts[1], jwtSecret)
+		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+		ctx := authctx.WithUser(r.Context(), claims.UserID, claims.Role, claims.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func GetUserIDFromContext(ctx context.Context) (int64, bool) {
-	userID, ok := ctx.Value("user_id").(int64)
-	return userID, ok
+// RequireRole middleware restricts access to users with one of the given roles
+func RequireRole(roles ...models.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := authctx.RoleFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireClientCertificate middleware restricts access to clients that presented
+// a certificate verified against the client CAs of the server's TLS config
+func RequireClientCertificate() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				http.Error(w, "Client certificate required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the messages posted by the payment rail
+const (
+	RailTimestampHeader = "X-Rail-Timestamp" // Unix time in seconds
+	RailSignatureHeader = "X-Rail-Signature" // see SignRailMessage
+)
+
+// SignRailMessage returns the signature of a payment rail message: the hex
+// HMAC-SHA256, keyed with the shared secret, of "<timestamp>.<body>"
+func SignRailMessage(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RailSignature middleware admits only messages signed by the payment rail with
+// the shared secret and stamped within the clock skew window. The route answers
+// 503 while no secret is configured.
+func RailSignature(secret string, maxClockSkew time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				http.Error(w, "Payment rail integration is not configured", http.StatusServiceUnavailable)
+				return
+			}
+
+			timestamp := r.Header.Get(RailTimestampHeader)
+			signature := r.Header.Get(RailSignatureHeader)
+			if timestamp == "" || signature == "" {
+				http.Error(w, fmt.Sprintf("%s and %s headers are required", RailTimestampHeader, RailSignatureHeader), http.StatusUnauthorized)
+				return
+			}
+
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid "+RailTimestampHeader+" header", http.StatusBadRequest)
+				return
+			}
+			skew := time.Since(time.Unix(seconds, 0))
+			if skew > maxClockSkew || skew < -maxClockSkew {
+				http.Error(w, "Message timestamp is outside the allowed window", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxSignedBodySize {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := SignRailMessage(secret, timestamp, body)
+			if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+				http.Error(w, "Invalid message signature", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxJSONDepth bounds the nesting of objects and arrays in a request body
+const maxJSONDepth = 32
+
+// BodyLimit middleware refuses request bodies larger than maxBytes with 413. A
+// body without a declared length is cut off at the limit, which makes reading it
+// fail. A zero or negative limit leaves bodies unbounded. Bodies of a media type
+// in mediaTypeLimits, such as uploaded documents, get the limit given there.
+func BodyLimit(maxBytes int64, mediaTypeLimits map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := maxBytes
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+				if typeLimit, ok := mediaTypeLimits[mediaType]; ok {
+					limit = typeLimit
+				}
+			}
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DecodeJSON decodes a JSON request body into v strictly. Fields v does not have,
+// fields given twice (in any letter case, as decoding ignores it), nesting
+// deeper than maxJSONDepth and data after the value are refused, so a client
+// cannot set fields the server fills in or smuggle a second value past a check.
+func DecodeJSON(body io.Reader, v interface{}) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if err := checkJSONStructure(data); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+// jsonFrame is an object or array being walked by checkJSONStructure
+type jsonFrame struct {
+	object  bool
+	wantKey bool // the next token of an object is a key
+	keys    map[string]bool
+}
+
+// checkJSONStructure walks the tokens of a JSON document and refuses duplicated
+// object keys and nesting deeper than maxJSONDepth. Syntax errors are left to
+// the decoder.
+func checkJSONStructure(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var stack []*jsonFrame
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if top != nil && top.wantKey {
+			if key, ok := token.(string); ok {
+				folded := strings.ToLower(key)
+				if top.keys[folded] {
+					return fmt.Errorf("duplicate field %s", strconv.Quote(key))
+				}
+				top.keys[folded] = true
+				top.wantKey = false
+				continue
+			}
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			if len(stack) == maxJSONDepth {
+				return fmt.Errorf("JSON is nested deeper than %d levels", maxJSONDepth)
+			}
+			frame := &jsonFrame{object: token == json.Delim('{')}
+			if frame.object {
+				frame.wantKey = true
+				frame.keys = make(map[string]bool)
+			}
+			stack = append(stack, frame)
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// A value has ended, so the object holding it expects a key next
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].wantKey = true
+		}
+	}
+}
+
+// WriteDecodeError responds to a request body that could not be decoded: 413
+// for a body over the size limit and 400 with the reason otherwise
+func WriteDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Invalid request body: "+strings.TrimPrefix(err.Error(), "json: "), http.StatusBadRequest)
+}
@@ -3,18 +3,47 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"mime"
+	"net"
 	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/authctx"
+	"github.com/Abigotado/abi_banking/internal/metrics"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/secrets"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
-// Logging middleware for request logging
+// contextKey keys the values the middleware stores in the request context;
+// the authenticated user and the request ID are kept by package authctx
+type contextKey int
+
+const (
+	requestBodyKey contextKey = iota
+	clientIPKey
+	clientCountryKey
+	requestLogKey
+)
+
+// requestLog collects what the middleware further down the chain learns about
+// a request for its log entry
+type requestLog struct {
+	userID int64
+}
+
+// Logging middleware logs every request with its status, latency, request ID
+// and the authenticated user, if any. The logger redacts credentials and card
+// data wherever they appear, so the path and the errors are logged as they are.
 func Logging(logger *logrus.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -22,17 +51,51 @@ func Logging(logger *logrus.Logger) func(http.Handler) http.Handler {
 
 			// Create a custom response writer to capture the status code
 			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-			next.ServeHTTP(rw, r)
+			entry := &requestLog{}
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), requestLogKey, entry)))
 
-			// Log the request
-			logger.WithFields(logrus.Fields{
+			fields := logrus.Fields{
 				"method":     r.Method,
 				"path":       r.URL.Path,
 				"status":     rw.statusCode,
-				"duration":   time.Since(start),
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
 				"ip":         r.RemoteAddr,
 				"user_agent": r.UserAgent(),
-			}).Info("HTTP request")
+				"request_id": rw.Header().Get("X-Request-ID"), // set by RequestID further down the chain
+			}
+			if entry.userID != 0 {
+				fields["user_id"] = entry.userID
+			}
+			logger.WithFields(fields).Info("HTTP request")
+		})
+	}
+}
+
+// recordLogUser tells Logging the user a request is authenticated as
+func recordLogUser(ctx context.Context, userID int64) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.userID = userID
+	}
+}
+
+// Metrics middleware for request counts and latency per route. Routes are labelled
+// by their template so path parameters do not create a series per ID.
+func Metrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			route := "unmatched"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(rw.statusCode))
+			metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
 		})
 	}
 }
@@ -60,6 +123,25 @@ func Recovery(logger *logrus.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// Timeout middleware bounds the request context by the server write timeout, so
+// database work is cancelled once the response could no longer be written.
+// The context is also cancelled when the client disconnects. The paths of
+// event streams, which stay open for as long as the client listens, are left
+// unbounded.
+func Timeout(timeout time.Duration, streams ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout <= 0 || slices.Contains(streams, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // CORS middleware for handling cross-origin requests
 func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -70,7 +152,8 @@ func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 					if origin == allowedOrigin {
 						w.Header().Set("Access-Control-Allow-Origin", origin)
 						w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-						w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+						w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, "+APIKeyHeader+", "+NonceHeader+", "+TimestampHeader)
+						w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, "+pagination.TotalCountHeader+", Link, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
 						break
 					}
 				}
@@ -87,7 +170,7 @@ func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 }
 
 // Auth middleware for JWT authentication
-func Auth(jwtSecret string) func(http.Handler) http.Handler {
+func Auth(jwtSecret *secrets.Secret, sessions SessionValidator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -102,82 +185,101 @@ func Auth(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			token, err := jwt.ParseWithClaims(parts[1], &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-				return []byte(jwtSecret), nil
-			})
-
+			claims, err := ParseToken(r.Context(), parts[1], jwtSecret)
 			if err != nil {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
 
-			if claims, ok := token.Claims.(*models.Claims); ok && token.Valid {
-				// Add user ID to request context
-				ctx := r.Context()
-				ctx = context.WithValue(ctx, "user_id", claims.UserID)
-				r = r.WithContext(ctx)
-				next.ServeHTTP(w, r)
-			} else {
-				http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			active, err := sessions.ValidateSession(r.Context(), claims.ID)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				http.Error(w, "Session has been revoked or has expired", http.StatusUnauthorized)
+				return
 			}
+
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
 		})
 	}
 }
 
-// Custom response writer to capture status code
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+// SessionValidator tells whether the session a token was issued for, given by
+// the token's jti claim, is still active
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, tokenID string) (bool, error)
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// ParseToken verifies a JWT signed with the secret, or with the value the secret
+// had before its last rotation, and returns its claims. A signature no known
+// value verifies makes the secret be fetched again, as another instance may
+// already sign with a rotated one.
+func ParseToken(ctx context.Context, tokenString string, jwtSecret *secrets.Secret) (*models.Claims, error) {
+	claims, err := parseToken(ctx, tokenString, jwtSecret)
+	if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		if changed, refreshErr := jwtSecret.Refresh(ctx); refreshErr == nil && changed {
+			return parseToken(ctx, tokenString, jwtSecret)
+		}
+	}
+	return claims, err
 }
 
-// RateLimiter middleware for limiting requests per IP
-func RateLimiter(requestsPerMinute int) func(http.Handler) http.Handler {
-	type client struct {
-		requests int
-		lastTime time.Time
+func parseToken(ctx context.Context, tokenString string, jwtSecret *secrets.Secret) (*models.Claims, error) {
+	keys, err := jwtSecret.Values(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	clients := make(map[string]*client)
-	mutex := &sync.Mutex{}
+	for _, key := range keys {
+		token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
+			return []byte(key), nil
+		})
+		if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
-			mutex.Lock()
-			c, exists := clients[ip]
-			if !exists {
-				c = &client{}
-				clients[ip] = c
-			}
+		claims, ok := token.Claims.(*models.Claims)
+		if !ok || !token.Valid {
+			return nil, errors.New("invalid token claims")
+		}
+		return claims, nil
+	}
+	return nil, jwt.ErrTokenSignatureInvalid
+}
 
-			// Reset counter if more than a minute has passed
-			if time.Since(c.lastTime) > time.Minute {
-				c.requests = 0
-				c.lastTime = time.Now()
-			}
+// WithClaims adds the authenticated user ID, role and session to the context
+func WithClaims(ctx context.Context, claims *models.Claims) context.Context {
+	recordLogUser(ctx, claims.UserID)
+	return authctx.WithUser(ctx, claims.UserID, claims.Role, claims.ID)
+}
 
-			if c.requests >= requestsPerMinute {
-				mutex.Unlock()
-				http.Error(w, "Too many requests", http.StatusTooManyRequests)
-				return
-			}
+// Custom response writer to capture status code
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
 
-			c.requests++
-			c.lastTime = time.Now()
-			mutex.Unlock()
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
 
-			next.ServeHTTP(w, r)
-		})
-	}
+// Unwrap returns the wrapped writer, so http.ResponseController reaches its
+// Flush and deadlines
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
-// ValidateRequest middleware for validating request body
+// ValidateRequest middleware decodes the JSON body into a new value of the schema type,
+// checks its validate tags and stores it in the request context
 func ValidateRequest(schema interface{}) func(http.HandlerFunc) http.HandlerFunc {
+	schemaType := reflect.TypeOf(schema).Elem()
+
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if r.Body == nil {
@@ -185,15 +287,20 @@ func ValidateRequest(schema interface{}) func(http.HandlerFunc) http.HandlerFunc
 				return
 			}
 
-			// Create a new decoder that reads from the original body
-			decoder := json.NewDecoder(r.Body)
-			if err := decoder.Decode(schema); err != nil {
-				http.Error(w, "Invalid request body", http.StatusBadRequest)
+			// Decode into a fresh value so concurrent requests never share state
+			req := reflect.New(schemaType).Interface()
+			if err := DecodeJSON(r.Body, req); err != nil {
+				WriteDecodeError(w, err)
 				return
 			}
 
-			// Store the decoded schema in the context
-			ctx := context.WithValue(r.Context(), "request_body", schema)
+			if err := Validate(req); err != nil {
+				WriteValidationError(w, err)
+				return
+			}
+
+			// Store the decoded request in the context
+			ctx := context.WithValue(r.Context(), requestBodyKey, req)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 	}
@@ -201,17 +308,24 @@ func ValidateRequest(schema interface{}) func(http.HandlerFunc) http.HandlerFunc
 
 // GetRequestBodyFromContext retrieves the request body from context
 func GetRequestBodyFromContext(ctx context.Context) interface{} {
-	return ctx.Value("request_body")
+	return ctx.Value(requestBodyKey)
 }
 
 // ContentType middleware for checking content type
-func ContentType(contentType string) func(http.Handler) http.Handler {
+func ContentType(contentTypes ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == "POST" || r.Method == "PUT" {
-				contentType := r.Header.Get("Content-Type")
-				if contentType != "application/json" {
-					http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+				mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				allowed := false
+				for _, contentType := range contentTypes {
+					if mediaType == contentType {
+						allowed = true
+						break
+					}
+				}
+				if !allowed {
+					http.Error(w, "Content-Type must be one of: "+strings.Join(contentTypes, ", "), http.StatusUnsupportedMediaType)
 					return
 				}
 			}
@@ -225,9 +339,58 @@ func RequestID() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			requestID := uuid.New().String()
-			ctx := context.WithValue(r.Context(), "request_id", requestID)
+			ctx := authctx.WithRequestID(r.Context(), requestID)
 			w.Header().Set("X-Request-ID", requestID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// ClientInfo middleware stores the client address and country in the request
+// context for the fraud rules. Behind a proxy the address is read from ipHeader,
+// taking the first entry of lists such as X-Forwarded-For; the country comes from
+// a header set by the edge and stays empty without one.
+func ClientInfo(ipHeader, countryHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := r.RemoteAddr
+			if ipHeader != "" {
+				if forwarded := strings.TrimSpace(strings.Split(r.Header.Get(ipHeader), ",")[0]); forwarded != "" {
+					ip = forwarded
+				}
+			}
+
+			var country string
+			if countryHeader != "" {
+				country = r.Header.Get(countryHeader)
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClientInfo(r.Context(), ip, country)))
+		})
+	}
+}
+
+// WithClientInfo stores the client address and ISO country code in the context.
+// Ports are dropped from the address and malformed values are left out.
+func WithClientInfo(ctx context.Context, ip, country string) context.Context {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if net.ParseIP(ip) != nil {
+		ctx = context.WithValue(ctx, clientIPKey, ip)
+	}
+
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) == 2 && country != "XX" {
+		ctx = context.WithValue(ctx, clientCountryKey, country)
+	}
+	return ctx
+}
+
+// GetClientInfoFromContext returns the client address and country stored by ClientInfo
+func GetClientInfoFromContext(ctx context.Context) models.ClientInfo {
+	var client models.ClientInfo
+	client.IP, _ = ctx.Value(clientIPKey).(string)
+	client.Country, _ = ctx.Value(clientCountryKey).(string)
+	return client
+}
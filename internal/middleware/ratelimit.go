@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimitPolicy is a token bucket: it holds up to Burst requests and refills
+// at RequestsPerHour. A policy without a positive rate and burst does not limit.
+type RateLimitPolicy struct {
+	RequestsPerHour int
+	Burst           int
+}
+
+// refillRate returns the tokens the bucket gains per second
+func (p RateLimitPolicy) refillRate() float64 {
+	return float64(p.RequestsPerHour) / float64(time.Hour/time.Second)
+}
+
+// RateLimitStore keeps the token buckets of the rate limiter
+type RateLimitStore interface {
+	// Take takes a token from the bucket under key, which starts full, and
+	// tells whether there was one and how many tokens are left
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (bool, float64, error)
+}
+
+// RateLimiter limits requests with token buckets. Every client has a bucket for
+// each endpoint with a policy of its own and a default bucket for the other
+// endpoints. A client is the user of a valid bearer token, or the client
+// address for a request without one, so the limit of a user holds on all their
+// devices. The X-RateLimit-* headers describe the bucket the request was
+// counted against and a refused request gets 429 with Retry-After. While the
+// store fails requests are let through.
+func RateLimiter(store RateLimitStore, defaultPolicy RateLimitPolicy, endpoints map[string]RateLimitPolicy, jwtSecret *secrets.Secret, logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, policy := "default", defaultPolicy
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					if endpoint, ok := endpoints[r.Method+" "+template]; ok {
+						name, policy = r.Method+" "+template, endpoint
+					}
+				}
+			}
+			if policy.RequestsPerHour <= 0 || policy.Burst <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if takeRateLimitToken(w, r, store, "ratelimit:"+name+":"+rateLimitClient(r, jwtSecret), policy, logger) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// takeRateLimitToken takes a token from the bucket under key and sets the
+// X-RateLimit-* headers. It responds 429 and returns false when the bucket is
+// empty; a failing store lets the request through.
+func takeRateLimitToken(w http.ResponseWriter, r *http.Request, store RateLimitStore, key string, policy RateLimitPolicy, logger *logrus.Logger) bool {
+	allowed, tokens, err := store.Take(r.Context(), key, policy)
+	if err != nil {
+		logger.WithError(err).Warn("Rate limiter store failed, letting the request through")
+		return true
+	}
+
+	rate := policy.refillRate()
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(policy.Burst))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Max(tokens, 0))))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(policy.Burst)-tokens)/rate))))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil((1-tokens)/rate))))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// rateLimitClient identifies the client of a request for the rate limiter. The
+// token is only verified here; whether its session is active is left to Auth.
+func rateLimitClient(r *http.Request, jwtSecret *secrets.Secret) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if claims, err := ParseToken(r.Context(), token, jwtSecret); err == nil {
+			return fmt.Sprintf("user:%d", claims.UserID)
+		}
+	}
+	if ip := GetClientInfoFromContext(r.Context()).IP; ip != "" {
+		return "ip:" + ip
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// MemoryRateLimitStore keeps the token buckets in memory, so each instance of
+// the service limits on its own
+type MemoryRateLimitStore struct {
+	idle time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimitStore creates a store dropping the buckets unused for idle.
+// A dropped bucket starts full again, so idle should be at least the time a
+// bucket takes to refill.
+func NewMemoryRateLimitStore(idle time.Duration) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		idle:    idle,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Take takes a token from the bucket under key
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy RateLimitPolicy) (bool, float64, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > s.idle {
+		for k, b := range s.buckets {
+			if now.Sub(b.updated) > s.idle {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(policy.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(policy.Burst), b.tokens+now.Sub(b.updated).Seconds()*policy.refillRate())
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// RedisRateLimitStore keeps the token buckets in Redis, shared by all instances
+// of the service. The bucket is refilled and taken from in one script, on the
+// clock of the Redis server.
+type RedisRateLimitStore struct {
+	client *redis.Client
+	prefix string
+	idle   time.Duration
+}
+
+// NewRedisRateLimitStore creates a store keeping the buckets under keys with
+// the given prefix, expiring those unused for idle
+func NewRedisRateLimitStore(client *redis.Client, prefix string, idle time.Duration) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client: client,
+		prefix: prefix,
+		idle:   idle,
+	}
+}
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) + tonumber(clock[2]) / 1000000
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {allowed, tostring(tokens)}
+`)
+
+// Take takes a token from the bucket under key
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (bool, float64, error) {
+	result, err := tokenBucketScript.Run(ctx, s.client, []string{s.prefix + key},
+		policy.refillRate(), policy.Burst, s.idle.Milliseconds()).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket reply %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	reply, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(reply, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected token count %q: %w", reply, err)
+	}
+	return allowed == 1, tokens, nil
+}
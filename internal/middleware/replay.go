@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/authctx"
+)
+
+// Replay protection headers sent by clients on protected routes
+const (
+	NonceHeader     = "X-Request-Nonce"
+	TimestampHeader = "X-Request-Timestamp" // Unix time in seconds
+	SignatureHeader = "X-Request-Signature" // see SignRequest
+)
+
+// Replay protection modes of a route class
+const (
+	ReplayModeOff      = "off"
+	ReplayModeRequired = "required"
+)
+
+const (
+	minNonceLength = 16
+	maxNonceLength = 128
+
+	// maxSignedBodySize bounds the body buffered to check the signature
+	maxSignedBodySize = 1 << 20
+)
+
+// NonceCache remembers the nonces seen while their requests could still pass the
+// timestamp check. It is kept in memory, so each instance tracks its own requests.
+type NonceCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewNonceCache creates a nonce cache keeping entries for the given TTL
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{
+		ttl:       ttl,
+		seen:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Add records a nonce and reports whether it had not been seen before
+func (c *NonceCache) Add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > c.ttl {
+		for key, expiresAt := range c.seen {
+			if now.After(expiresAt) {
+				delete(c.seen, key)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if expiresAt, ok := c.seen[nonce]; ok && now.Before(expiresAt) {
+		return false
+	}
+	c.seen[nonce] = now.Add(c.ttl)
+	return true
+}
+
+// RequestSigningKey derives the key a client signs protected requests with from
+// its access token or API key. The key is handed out with the token at login, or
+// with the API key when it is issued, and is keyed with the server secret, so the
+// credential and a captured request are not enough to sign a new request.
+func RequestSigningKey(ctx context.Context, token string) (string, error) {
+	secret, err := jwtSecret.Value(ctx)
+	if err != nil {
+		return "", err
+	}
+	return deriveSigningKey(secret, token), nil
+}
+
+// requestSigningKeys returns the signing keys a credential may sign with: the
+// current one and the one derived before the last rotation of the server secret
+func requestSigningKeys(ctx context.Context, token string) ([]string, error) {
+	values, err := jwtSecret.Values(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(values))
+	for _, secret := range values {
+		keys = append(keys, deriveSigningKey(secret, token))
+	}
+	return keys, nil
+}
+
+func deriveSigningKey(secret, token string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("request-signing\n" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest returns the signature of a request: the hex HMAC-SHA256, keyed with
+// the signing key, of the method, the path with the query, the hex SHA-256 of the
+// body, the nonce and the timestamp, each on its own line
+func SignRequest(signingKey, method, requestURI string, body []byte, nonce, timestamp string) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(strings.Join([]string{method, requestURI, hex.EncodeToString(bodyHash[:]), nonce, timestamp}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ReplayProtection middleware rejects captured requests sent again while the
+// caller's JWT is still valid. Clients send a unique nonce, the time the request
+// was signed and the signature of the request over both (see SignRequest); a
+// request with a wrong signature, outside the clock skew window or with a nonce
+// already used by the same user is rejected. Only signed requests are recorded, so
+// a forged nonce cannot use up a real one. An unknown mode is treated as required.
+//
+// The nonces are kept per instance: with several instances behind a load balancer,
+// a request replayed to another instance within the clock skew window is not
+// detected. It complements idempotency keys, which deduplicate retries the client
+// intends rather than replays it never sent.
+func ReplayProtection(mode string, cache *NonceCache, maxClockSkew time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if mode == ReplayModeOff {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			nonce := r.Header.Get(NonceHeader)
+			timestamp := r.Header.Get(TimestampHeader)
+			signature := r.Header.Get(SignatureHeader)
+
+			if nonce == "" || timestamp == "" || signature == "" {
+				http.Error(w, fmt.Sprintf("%s, %s and %s headers are required", NonceHeader, TimestampHeader, SignatureHeader), http.StatusBadRequest)
+				return
+			}
+			if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+				http.Error(w, fmt.Sprintf("%s must be between %d and %d characters", NonceHeader, minNonceLength, maxNonceLength), http.StatusBadRequest)
+				return
+			}
+
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid "+TimestampHeader+" header", http.StatusBadRequest)
+				return
+			}
+			now := time.Now()
+			skew := now.Sub(time.Unix(seconds, 0))
+			if skew > maxClockSkew || skew < -maxClockSkew {
+				http.Error(w, "Request timestamp is outside the allowed window", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxSignedBodySize {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			signingKeys, err := requestSigningKeys(r.Context(), requestCredential(r))
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			valid := false
+			for _, signingKey := range signingKeys {
+				expected := SignRequest(signingKey, r.Method, r.URL.RequestURI(), body, nonce, timestamp)
+				if hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				http.Error(w, "Invalid request signature", http.StatusUnauthorized)
+				return
+			}
+
+			// Nonces are scoped to the user so clients only need them unique per account
+			userID, _ := authctx.UserIDFromContext(r.Context())
+			if !cache.Add(fmt.Sprintf("%d:%s", userID, nonce), now) {
+				http.Error(w, "Request replay detected", http.StatusConflict)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// requestCredential returns the credential a request is signed with: the API
+// key of a machine-to-machine client or the access token of the Authorization
+// header
+func requestCredential(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cards;
+DROP FUNCTION IF EXISTS update_cards_updated_at();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS credits;
+DROP FUNCTION IF EXISTS update_credits_updated_at();
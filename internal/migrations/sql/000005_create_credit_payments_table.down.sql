@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS credit_payments;
+DROP FUNCTION IF EXISTS update_credit_payments_updated_at();
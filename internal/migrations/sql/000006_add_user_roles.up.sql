@@ -0,0 +1,8 @@
+-- Add role and status columns to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'blocked', 'inactive'));
+
+-- Create index on role for faster admin lookups
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS account_ownership_transfers;
+DROP FUNCTION IF EXISTS update_account_ownership_transfers_updated_at();
@@ -0,0 +1,42 @@
+-- Create account_ownership_transfers table
+CREATE TABLE IF NOT EXISTS account_ownership_transfers (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    from_user_id INTEGER NOT NULL REFERENCES users(id),
+    to_user_id INTEGER NOT NULL REFERENCES users(id),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('inheritance', 'assignment')),
+    document_reference VARCHAR(255) NOT NULL,
+    include_cards BOOLEAN NOT NULL DEFAULT FALSE,
+    include_credits BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'completed', 'rejected')),
+    requested_by INTEGER NOT NULL REFERENCES users(id),
+    reviewed_by INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on account_id for faster account history queries
+CREATE INDEX IF NOT EXISTS idx_account_ownership_transfers_account_id ON account_ownership_transfers(account_id);
+
+-- Create index on status for faster review queue queries
+CREATE INDEX IF NOT EXISTS idx_account_ownership_transfers_status ON account_ownership_transfers(status);
+
+-- Only one pending transfer per account
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_ownership_transfers_pending
+    ON account_ownership_transfers(account_id) WHERE status = 'pending';
+
+-- Add trigger to update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_account_ownership_transfers_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_account_ownership_transfers_updated_at
+    BEFORE UPDATE ON account_ownership_transfers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_account_ownership_transfers_updated_at();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_accounts_overdraft;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_interest_accrued_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_interest_rate;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
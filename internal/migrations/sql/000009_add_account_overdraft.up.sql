@@ -0,0 +1,10 @@
+-- Add overdraft facility columns to accounts
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(15,2) NOT NULL DEFAULT 0.00
+    CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_interest_rate DECIMAL(5,2) NOT NULL DEFAULT 0.00
+    CHECK (overdraft_interest_rate >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_interest_accrued_at TIMESTAMP WITH TIME ZONE
+    NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Create index for the overdraft interest accrual job
+CREATE INDEX IF NOT EXISTS idx_accounts_overdraft ON accounts(overdraft_limit) WHERE overdraft_limit > 0;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_transactions_card_id;
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS markup_amount,
+    DROP COLUMN IF EXISTS markup_percent,
+    DROP COLUMN IF EXISTS exchange_rate,
+    DROP COLUMN IF EXISTS billed_currency,
+    DROP COLUMN IF EXISTS original_currency,
+    DROP COLUMN IF EXISTS original_amount,
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS card_id;
@@ -0,0 +1,12 @@
+-- Add card reference and currency conversion details to transactions
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS card_id INTEGER REFERENCES cards(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_amount DECIMAL(15,2);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_currency VARCHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS billed_currency VARCHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(18,8);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS markup_percent DECIMAL(5,2);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS markup_amount DECIMAL(15,2);
+
+-- Create index on card_id for faster card transaction queries
+CREATE INDEX IF NOT EXISTS idx_transactions_card_id ON transactions(card_id);
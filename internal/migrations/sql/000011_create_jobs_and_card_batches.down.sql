@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS card_batch_items;
+DROP TABLE IF EXISTS card_batches;
+DROP TABLE IF EXISTS jobs;
+DROP FUNCTION IF EXISTS update_card_batch_items_updated_at();
+DROP FUNCTION IF EXISTS update_card_batches_updated_at();
+DROP FUNCTION IF EXISTS update_jobs_updated_at();
+
+ALTER TABLE cards
+    DROP COLUMN IF EXISTS transaction_limit,
+    DROP COLUMN IF EXISTS monthly_limit,
+    DROP COLUMN IF EXISTS daily_limit;
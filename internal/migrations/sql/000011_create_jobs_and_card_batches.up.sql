@@ -0,0 +1,110 @@
+-- Create jobs table for asynchronous background work
+CREATE TABLE IF NOT EXISTS jobs (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    last_error TEXT,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for the job runner's polling query
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs(status, run_at);
+
+-- Per-card spending limits, NULL means unlimited
+ALTER TABLE cards
+    ADD COLUMN IF NOT EXISTS daily_limit DECIMAL(15,2),
+    ADD COLUMN IF NOT EXISTS monthly_limit DECIMAL(15,2),
+    ADD COLUMN IF NOT EXISTS transaction_limit DECIMAL(15,2);
+
+-- Create card_batches table for corporate bulk issuance
+CREATE TABLE IF NOT EXISTS card_batches (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    card_type VARCHAR(10) NOT NULL CHECK (card_type IN ('debit', 'credit')),
+    daily_limit DECIMAL(15,2),
+    monthly_limit DECIMAL(15,2),
+    transaction_limit DECIMAL(15,2),
+    status VARCHAR(30) NOT NULL CHECK (status IN ('pending', 'processing', 'completed', 'completed_with_errors', 'failed')),
+    total_cards INTEGER NOT NULL,
+    issued_cards INTEGER NOT NULL DEFAULT 0,
+    failed_cards INTEGER NOT NULL DEFAULT 0,
+    job_id INTEGER REFERENCES jobs(id),
+    requested_by INTEGER NOT NULL REFERENCES users(id),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on account_id for faster corporate account queries
+CREATE INDEX IF NOT EXISTS idx_card_batches_account_id ON card_batches(account_id);
+
+-- Create card_batch_items table with one row per employee card
+CREATE TABLE IF NOT EXISTS card_batch_items (
+    id SERIAL PRIMARY KEY,
+    batch_id INTEGER NOT NULL REFERENCES card_batches(id) ON DELETE CASCADE,
+    employee_id VARCHAR(100),
+    full_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    delivery_address TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'issued', 'failed')),
+    card_id INTEGER REFERENCES cards(id) ON DELETE SET NULL,
+    error TEXT,
+    delivery_status VARCHAR(20) NOT NULL DEFAULT 'not_dispatched'
+        CHECK (delivery_status IN ('not_dispatched', 'dispatched', 'delivered', 'returned')),
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on batch_id for faster batch report queries
+CREATE INDEX IF NOT EXISTS idx_card_batch_items_batch_id ON card_batch_items(batch_id);
+
+-- Add triggers to update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_jobs_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_jobs_updated_at
+    BEFORE UPDATE ON jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_jobs_updated_at();
+
+CREATE OR REPLACE FUNCTION update_card_batches_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_card_batches_updated_at
+    BEFORE UPDATE ON card_batches
+    FOR EACH ROW
+    EXECUTE FUNCTION update_card_batches_updated_at();
+
+CREATE OR REPLACE FUNCTION update_card_batch_items_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_card_batch_items_updated_at
+    BEFORE UPDATE ON card_batch_items
+    FOR EACH ROW
+    EXECUTE FUNCTION update_card_batch_items_updated_at();
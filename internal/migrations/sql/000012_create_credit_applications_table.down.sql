@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS credit_applications;
+DROP FUNCTION IF EXISTS update_credit_applications_updated_at();
@@ -0,0 +1,42 @@
+-- Create credit_applications table
+CREATE TABLE IF NOT EXISTS credit_applications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL,
+    term_months INTEGER NOT NULL,
+    interest_rate DECIMAL(5,2) NOT NULL,
+    declared_income DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'approved', 'rejected', 'manual_review')),
+    score INTEGER,
+    monthly_income DECIMAL(15,2),
+    monthly_payment DECIMAL(15,2),
+    debt_to_income DECIMAL(7,4),
+    decision_reason TEXT,
+    credit_id INTEGER REFERENCES credits(id),
+    reviewed_by INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on user_id for faster user application queries
+CREATE INDEX IF NOT EXISTS idx_credit_applications_user_id ON credit_applications(user_id);
+
+-- Create index on status for faster review queue queries
+CREATE INDEX IF NOT EXISTS idx_credit_applications_status ON credit_applications(status);
+
+-- Add trigger to update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_credit_applications_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_credit_applications_updated_at
+    BEFORE UPDATE ON credit_applications
+    FOR EACH ROW
+    EXECUTE FUNCTION update_credit_applications_updated_at();
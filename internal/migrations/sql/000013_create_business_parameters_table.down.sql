@@ -0,0 +1,3 @@
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS applied_parameters;
+ALTER TABLE transactions DROP COLUMN IF EXISTS applied_parameters;
+DROP TABLE IF EXISTS business_parameters;
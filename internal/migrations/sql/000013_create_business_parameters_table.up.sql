@@ -0,0 +1,19 @@
+-- Create business_parameters table. Every row is an immutable version of a
+-- parameter; the version in force is the latest one whose effective_from has passed.
+CREATE TABLE IF NOT EXISTS business_parameters (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(100) NOT NULL,
+    value DECIMAL(15,6) NOT NULL,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    comment TEXT,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (key, effective_from)
+);
+
+-- Create index on key and effective_from for version lookups
+CREATE INDEX IF NOT EXISTS idx_business_parameters_key_effective_from ON business_parameters(key, effective_from DESC);
+
+-- Record the parameter versions applied to an operation
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS applied_parameters JSONB;
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS applied_parameters JSONB;
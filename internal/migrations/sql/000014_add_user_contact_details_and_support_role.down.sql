@@ -0,0 +1,10 @@
+-- Support users lose their role before the narrower check is restored
+UPDATE users SET role = 'user' WHERE role = 'support';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+DROP INDEX IF EXISTS idx_users_phone_number;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS phone_number,
+    DROP COLUMN IF EXISTS last_name,
+    DROP COLUMN IF EXISTS first_name;
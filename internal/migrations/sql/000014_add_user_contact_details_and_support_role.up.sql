@@ -0,0 +1,11 @@
+-- Add contact details to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS first_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_number VARCHAR(20);
+
+-- Create index on phone_number for faster support lookups
+CREATE INDEX IF NOT EXISTS idx_users_phone_number ON users(phone_number);
+
+-- Allow the support role, which can search customers with personal data redacted
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'support'));
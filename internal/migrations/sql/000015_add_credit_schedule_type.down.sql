@@ -0,0 +1,3 @@
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS interest;
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS principal;
+ALTER TABLE credits DROP COLUMN IF EXISTS schedule_type;
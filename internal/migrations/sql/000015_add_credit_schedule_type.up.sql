@@ -0,0 +1,7 @@
+-- Add repayment schedule type to credits
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS schedule_type VARCHAR(20) NOT NULL DEFAULT 'ANNUITY'
+    CHECK (schedule_type IN ('ANNUITY', 'DIFFERENTIATED'));
+
+-- Store the principal and interest parts of each installment
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS principal DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS interest DECIMAL(15,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- The removed one-off late payment penalty parameter is not restored
+DROP TABLE IF EXISTS credit_penalty_accruals;
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS penalty_accrued_until;
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS penalty;
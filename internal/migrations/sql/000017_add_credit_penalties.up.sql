@@ -0,0 +1,22 @@
+-- Penalty accrued on an overdue installment and the last day it covers
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS penalty DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS penalty_accrued_until DATE;
+
+-- Create credit_penalty_accruals table, one row per overdue installment and day
+CREATE TABLE IF NOT EXISTS credit_penalty_accruals (
+    id SERIAL PRIMARY KEY,
+    credit_id INTEGER NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    payment_id INTEGER NOT NULL REFERENCES payment_schedules(id) ON DELETE CASCADE,
+    accrual_date DATE NOT NULL,
+    overdue_amount DECIMAL(15,2) NOT NULL,
+    rate_percent DECIMAL(15,6) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    parameter_version_id INTEGER REFERENCES business_parameters(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (payment_id, accrual_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_penalty_accruals_credit_id ON credit_penalty_accruals(credit_id);
+
+-- The one-off late payment penalty is replaced by the daily penalty rate
+DELETE FROM business_parameters WHERE key = 'credit.late_penalty_percent';
@@ -0,0 +1,11 @@
+-- Track how much of each installment has been paid so partial payments carry forward
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS paid_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+
+-- Normalise statuses written in upper case by earlier versions
+UPDATE payment_schedules SET status = LOWER(status) WHERE status <> LOWER(status);
+
+UPDATE payment_schedules SET paid_amount = amount + penalty WHERE status = 'paid';
+
+-- Partial payments were never recorded, such installments are still due in full
+UPDATE payment_schedules SET status = 'pending' WHERE status = 'partial';
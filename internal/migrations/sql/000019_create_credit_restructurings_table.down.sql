@@ -0,0 +1,4 @@
+-- Archived installments would reappear as outstanding, so they are removed
+DELETE FROM payment_schedules WHERE status = 'archived';
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS restructuring_id;
+DROP TABLE IF EXISTS credit_restructurings;
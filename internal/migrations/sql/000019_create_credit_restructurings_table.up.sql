@@ -0,0 +1,30 @@
+-- Create credit_restructurings table for term extensions and rate changes
+CREATE TABLE IF NOT EXISTS credit_restructurings (
+    id SERIAL PRIMARY KEY,
+    credit_id INTEGER NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    term_extension_months INTEGER NOT NULL DEFAULT 0 CHECK (term_extension_months >= 0),
+    new_interest_rate DECIMAL(5,2),
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'approved', 'rejected')),
+    previous_interest_rate DECIMAL(5,2) NOT NULL,
+    previous_installments INTEGER,
+    restructured_principal DECIMAL(15,2),
+    new_installments INTEGER,
+    new_monthly_payment DECIMAL(15,2),
+    reviewed_by INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_restructurings_credit_id ON credit_restructurings(credit_id);
+CREATE INDEX IF NOT EXISTS idx_credit_restructurings_status ON credit_restructurings(status);
+
+-- Only one pending restructuring per credit
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_restructurings_pending
+    ON credit_restructurings(credit_id) WHERE status = 'pending';
+
+-- Installments replaced by a restructuring are kept as archived
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS restructuring_id INTEGER REFERENCES credit_restructurings(id);
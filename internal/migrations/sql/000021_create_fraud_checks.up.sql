@@ -0,0 +1,26 @@
+-- Create fraud_checks table recording the fraud rule evaluation of every outgoing
+-- transfer and withdrawal. Held checks form the review queue of administrators.
+CREATE TABLE IF NOT EXISTS fraud_checks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    type VARCHAR(20) NOT NULL CHECK (type IN ('transfer', 'withdrawal')),
+    from_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    to_account_id INTEGER REFERENCES accounts(id),
+    amount DECIMAL(15,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    client_ip VARCHAR(45),
+    client_country VARCHAR(2),
+    decision VARCHAR(10) NOT NULL CHECK (decision IN ('allow', 'flag', 'hold', 'reject')),
+    rule_hits JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('completed', 'held', 'released', 'rejected')),
+    transaction_id INTEGER REFERENCES transactions(id),
+    reviewed_by INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for the review queue and the client history of a user
+CREATE INDEX IF NOT EXISTS idx_fraud_checks_status_decision ON fraud_checks(status, decision);
+CREATE INDEX IF NOT EXISTS idx_fraud_checks_user_id_created_at ON fraud_checks(user_id, created_at);
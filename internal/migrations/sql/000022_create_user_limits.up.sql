@@ -0,0 +1,13 @@
+-- Create user_limits table with per-user overrides of the cumulative transfer and
+-- withdrawal limits. NULL keeps the configured default of a limit.
+CREATE TABLE IF NOT EXISTS user_limits (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    daily_transfer DECIMAL(15,2) CHECK (daily_transfer >= 0),
+    monthly_transfer DECIMAL(15,2) CHECK (monthly_transfer >= 0),
+    daily_withdrawal DECIMAL(15,2) CHECK (daily_withdrawal >= 0),
+    monthly_withdrawal DECIMAL(15,2) CHECK (monthly_withdrawal >= 0),
+    comment TEXT,
+    updated_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
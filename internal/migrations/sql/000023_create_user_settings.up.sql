@@ -0,0 +1,14 @@
+-- Create user_settings table with the preferences of a user. Users without a row
+-- use the column defaults.
+CREATE TABLE IF NOT EXISTS user_settings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    email_notifications BOOLEAN NOT NULL DEFAULT TRUE,
+    sms_notifications BOOLEAN NOT NULL DEFAULT FALSE,
+    language VARCHAR(2) NOT NULL DEFAULT 'ru',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Moscow',
+    monthly_statements BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_settings_monthly_statements ON user_settings(user_id) WHERE monthly_statements;
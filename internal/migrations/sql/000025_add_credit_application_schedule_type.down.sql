@@ -0,0 +1 @@
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS schedule_type;
@@ -0,0 +1,3 @@
+-- Add the repayment schedule chosen by the applicant to credit applications
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS schedule_type VARCHAR(20) NOT NULL DEFAULT 'ANNUITY'
+    CHECK (schedule_type IN ('ANNUITY', 'DIFFERENTIATED'));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_accounts_primary;
+ALTER TABLE accounts DROP COLUMN IF EXISTS is_primary;
+ALTER TABLE accounts DROP COLUMN IF EXISTS name;
@@ -0,0 +1,12 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS name VARCHAR(100);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The oldest account of each currency becomes the primary one
+UPDATE accounts SET is_primary = TRUE
+WHERE id IN (
+    SELECT DISTINCT ON (user_id, currency) id
+    FROM accounts
+    ORDER BY user_id, currency, created_at, id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_primary ON accounts(user_id, currency) WHERE is_primary;
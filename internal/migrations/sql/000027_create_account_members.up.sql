@@ -0,0 +1,16 @@
+-- Users sharing an account with its owner. The owner stays in accounts.user_id
+-- and has no row here.
+CREATE TABLE IF NOT EXISTS account_members (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('co_owner', 'viewer')),
+    status VARCHAR(20) NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active')),
+    invited_by INTEGER NOT NULL REFERENCES users(id),
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_members_user_id ON account_members(user_id);
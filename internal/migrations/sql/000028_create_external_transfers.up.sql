@@ -0,0 +1,28 @@
+-- Outbound transfers to accounts at other banks. The account is debited when the
+-- transfer is accepted and refunded when the payment rail returns it.
+CREATE TABLE IF NOT EXISTS external_transfers (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    beneficiary_name VARCHAR(140) NOT NULL,
+    iban VARCHAR(34),
+    account_number VARCHAR(34),
+    bic VARCHAR(11) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reference VARCHAR(140),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'processing', 'settled', 'returned')),
+    return_reason VARCHAR(255),
+    return_transaction_id INTEGER REFERENCES transactions(id),
+    submitted_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (iban IS NOT NULL OR account_number IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_external_transfers_user_id ON external_transfers(user_id);
+CREATE INDEX IF NOT EXISTS idx_external_transfers_open ON external_transfers(status, updated_at)
+    WHERE status IN ('pending', 'processing');
@@ -0,0 +1,19 @@
+-- Credit advices received from the payment rail. The unique reference makes a
+-- message sent again a no-op.
+CREATE TABLE IF NOT EXISTS incoming_payments (
+    id SERIAL PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL UNIQUE,
+    payer_name VARCHAR(140) NOT NULL,
+    payer_account VARCHAR(34),
+    account_number VARCHAR(34) NOT NULL,
+    account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    description VARCHAR(140),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('posted', 'unmatched')),
+    reason VARCHAR(255),
+    transaction_id INTEGER REFERENCES transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_incoming_payments_unmatched ON incoming_payments(created_at) WHERE status = 'unmatched';
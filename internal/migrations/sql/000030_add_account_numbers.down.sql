@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_accounts_number;
+ALTER TABLE accounts DROP COLUMN IF EXISTS number;
@@ -0,0 +1,5 @@
+-- Account numbers depend on the bank identity in the configuration, so accounts
+-- opened before this migration are numbered by the application when it starts
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS number VARCHAR(20);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_number ON accounts(number);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_cards_replaces_card_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS replaces_card_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS expiry_notified_at;
+
+UPDATE cards SET status = 'blocked' WHERE status = 'expired';
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_status_check;
+ALTER TABLE cards ADD CONSTRAINT cards_status_check CHECK (status IN ('active', 'blocked'));
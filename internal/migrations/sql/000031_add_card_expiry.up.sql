@@ -0,0 +1,9 @@
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_status_check;
+ALTER TABLE cards ADD CONSTRAINT cards_status_check CHECK (status IN ('active', 'blocked', 'expired'));
+
+-- When the holder was told the card expires soon, so they are told once
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP WITH TIME ZONE;
+
+-- A card is reissued at most once
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS replaces_card_id INTEGER REFERENCES cards(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cards_replaces_card_id ON cards(replaces_card_id);
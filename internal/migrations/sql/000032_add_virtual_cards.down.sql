@@ -0,0 +1,6 @@
+ALTER TABLE cards DROP COLUMN IF EXISTS merchant_lock;
+ALTER TABLE cards DROP COLUMN IF EXISTS single_use;
+
+DELETE FROM cards WHERE card_type = 'virtual';
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_card_type_check;
+ALTER TABLE cards ADD CONSTRAINT cards_card_type_check CHECK (card_type IN ('debit', 'credit'));
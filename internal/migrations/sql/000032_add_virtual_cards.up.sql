@@ -0,0 +1,8 @@
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_card_type_check;
+ALTER TABLE cards ADD CONSTRAINT cards_card_type_check CHECK (card_type IN ('debit', 'credit', 'virtual'));
+
+-- A single-use card is blocked by its first payment
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS single_use BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The only merchant a merchant-locked card pays, compared case-insensitively
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS merchant_lock VARCHAR(255);
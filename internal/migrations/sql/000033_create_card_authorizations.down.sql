@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS card_authorizations;
+
+-- The CVVs cannot be recovered from their hashes, the column keeps them
+ALTER TABLE cards RENAME COLUMN cvv_hash TO cvv;
@@ -0,0 +1,26 @@
+-- The CVV is kept only as a bcrypt hash, checked when a card payment is authorized
+ALTER TABLE cards ALTER COLUMN cvv TYPE VARCHAR(60);
+UPDATE cards SET cvv = crypt(cvv, gen_salt('bf', 10)) WHERE LENGTH(cvv) = 3;
+ALTER TABLE cards RENAME COLUMN cvv TO cvv_hash;
+
+-- Card payments authorized by the acquirer. A pending authorization holds its
+-- amount on the account until it is captured, voided or expires.
+CREATE TABLE IF NOT EXISTS card_authorizations (
+    id SERIAL PRIMARY KEY,
+    card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    conversion JSONB,
+    merchant VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'captured', 'voided')),
+    transaction_id INTEGER REFERENCES transactions(id),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_card_id ON card_authorizations(card_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_pending ON card_authorizations(account_id, expires_at)
+    WHERE status = 'pending';
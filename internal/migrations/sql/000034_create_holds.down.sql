@@ -0,0 +1,7 @@
+-- Fails while a transfer accepted under a hold is still unsettled
+ALTER TABLE external_transfers ALTER COLUMN transaction_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_pending ON card_authorizations(account_id, expires_at)
+    WHERE status = 'pending';
+
+DROP TABLE IF EXISTS holds;
@@ -0,0 +1,30 @@
+-- Holds reserve part of an account balance for a payment that is not booked yet.
+-- An active hold lowers the available balance without changing the booked one;
+-- it is settled when the payment is booked or released when it is cancelled.
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    source VARCHAR(30) NOT NULL CHECK (source IN ('card_authorization', 'external_transfer')),
+    source_id INTEGER NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'settled', 'released')),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_active ON holds(account_id) WHERE status = 'active';
+
+INSERT INTO holds (account_id, amount, currency, source, source_id, expires_at, created_at)
+SELECT account_id, amount, currency, 'card_authorization', id, expires_at, created_at
+FROM card_authorizations
+WHERE status = 'pending';
+
+DROP INDEX IF EXISTS idx_card_authorizations_pending;
+
+-- New transfers to other banks are only held when accepted and debited when
+-- they settle, so they have no transaction until then
+ALTER TABLE external_transfers ALTER COLUMN transaction_id DROP NOT NULL;
@@ -0,0 +1,28 @@
+-- Double-entry ledger. Every transaction posts a debit and a credit of the same
+-- amount; accounts.balance caches the credits less the debits of the account.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id INTEGER REFERENCES transactions(id),
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+    ledger_account VARCHAR(30) NOT NULL,
+    side VARCHAR(6) NOT NULL CHECK (side IN ('debit', 'credit')),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((ledger_account = 'customer') = (account_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries(account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+
+-- The balances of the existing accounts are carried over as opening entries
+-- against the opening_balances ledger account
+INSERT INTO ledger_entries (account_id, ledger_account, side, amount, currency)
+SELECT id, 'customer', CASE WHEN balance > 0 THEN 'credit' ELSE 'debit' END, ABS(balance), currency
+FROM accounts
+WHERE balance <> 0;
+
+INSERT INTO ledger_entries (ledger_account, side, amount, currency)
+SELECT 'opening_balances', CASE WHEN balance > 0 THEN 'debit' ELSE 'credit' END, ABS(balance), currency
+FROM accounts
+WHERE balance <> 0;
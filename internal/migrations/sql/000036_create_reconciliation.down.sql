@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reconciliation_issues;
+DROP TABLE IF EXISTS balance_snapshots;
+DROP TABLE IF EXISTS reconciliation_runs;
@@ -0,0 +1,36 @@
+-- Nightly reconciliation. A run snapshots the balance of every account with the
+-- balance its ledger entries and its transaction log give, and records an issue
+-- for every account where they differ.
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    id SERIAL PRIMARY KEY,
+    run_date DATE NOT NULL UNIQUE,
+    accounts INTEGER NOT NULL DEFAULT 0,
+    issues INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    run_id INTEGER NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(15,2) NOT NULL,
+    ledger_balance DECIMAL(15,2) NOT NULL,
+    expected_balance DECIMAL(15,2) NOT NULL,
+    UNIQUE (run_id, account_id)
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_issues (
+    id SERIAL PRIMARY KEY,
+    run_id INTEGER NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('ledger_mismatch', 'transaction_log_mismatch')),
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(15,2) NOT NULL,
+    expected_balance DECIMAL(15,2) NOT NULL,
+    difference DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_issues_run_id ON reconciliation_issues(run_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_issues_account_id ON reconciliation_issues(account_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE credits DROP COLUMN IF EXISTS version;
+ALTER TABLE accounts DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- Row versions for optimistic locking: every update of an account or a credit
+-- increments its version, and credit updates only apply to the version read
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_pending_anonymization;
+
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
+ALTER TABLE users DROP COLUMN IF EXISTS closed_at;
+
+UPDATE users SET status = 'inactive' WHERE status = 'closed';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'blocked', 'inactive'));
@@ -0,0 +1,11 @@
+-- Users can close their profile. A closed user keeps their financial records;
+-- the personal data is anonymized once the retention period has passed.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'blocked', 'inactive', 'closed'));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
+
+-- Create index on the closed users still holding personal data
+CREATE INDEX IF NOT EXISTS idx_users_pending_anonymization ON users(closed_at)
+    WHERE status = 'closed' AND anonymized_at IS NULL;
@@ -0,0 +1,17 @@
+-- Create sessions table. Every token is issued for a session, identified by the
+-- token's jti claim, and stops being accepted once the session is revoked.
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    token_id VARCHAR(36) NOT NULL UNIQUE,
+    device_name VARCHAR(100),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on user_id for listing the devices of a user
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
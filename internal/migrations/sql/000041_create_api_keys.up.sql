@@ -0,0 +1,21 @@
+-- Create api_keys table. Machine-to-machine clients authenticate with a key
+-- issued by an administrator; only its SHA-256 hash is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('read_only', 'payments', 'admin')),
+    requests_per_hour INTEGER NOT NULL DEFAULT 0 CHECK (requests_per_hour >= 0),
+    burst_size INTEGER NOT NULL DEFAULT 0 CHECK (burst_size >= 0),
+    rotated_from BIGINT REFERENCES api_keys(id),
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on user_id for listing the keys of a user
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,12 @@
+-- Fails while encrypted values longer than the plain columns remain
+DROP INDEX IF EXISTS idx_users_phone_hash;
+DROP INDEX IF EXISTS idx_users_email_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS email_hash;
+
+ALTER TABLE users ALTER COLUMN phone_number TYPE VARCHAR(20);
+ALTER TABLE users ALTER COLUMN last_name TYPE VARCHAR(100);
+ALTER TABLE users ALTER COLUMN first_name TYPE VARCHAR(100);
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(255);
+
+DROP TABLE IF EXISTS pii_data_keys;
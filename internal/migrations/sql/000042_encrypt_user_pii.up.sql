@@ -0,0 +1,21 @@
+-- Create the data keys encrypting the personal data of users, each stored
+-- wrapped by the master key of the configuration
+CREATE TABLE IF NOT EXISTS pii_data_keys (
+    id SERIAL PRIMARY KEY,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Encrypted values are longer than the plain ones
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
+ALTER TABLE users ALTER COLUMN first_name TYPE TEXT;
+ALTER TABLE users ALTER COLUMN last_name TYPE TEXT;
+ALTER TABLE users ALTER COLUMN phone_number TYPE TEXT;
+
+-- Blind indexes find users by email and phone number without decrypting them.
+-- The email one also keeps emails unique, which encrypted values cannot.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_hash CHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_hash CHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_hash ON users(email_hash);
+CREATE INDEX IF NOT EXISTS idx_users_phone_hash ON users(phone_hash);
@@ -0,0 +1,14 @@
+-- Branch administrators lose their role before the narrower check is restored
+UPDATE users SET role = 'user' WHERE role = 'branch_admin';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'support'));
+
+DROP INDEX IF EXISTS idx_credits_branch_id;
+DROP INDEX IF EXISTS idx_accounts_branch_id;
+DROP INDEX IF EXISTS idx_users_branch_id;
+
+ALTER TABLE credits DROP COLUMN IF EXISTS branch_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS branch_id;
+ALTER TABLE users DROP COLUMN IF EXISTS branch_id;
+
+DROP TABLE IF EXISTS branches;
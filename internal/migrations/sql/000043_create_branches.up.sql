@@ -0,0 +1,36 @@
+-- Create branches table. Every user, account and credit belongs to an office of
+-- the bank; the head office takes everything created before branches existed.
+CREATE TABLE IF NOT EXISTS branches (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    address TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO branches (code, name) VALUES ('HQ', 'Head office') ON CONFLICT (code) DO NOTHING;
+
+-- Users register with a branch, accounts and credits are booked at the branch of
+-- their owner when opened
+ALTER TABLE users ADD COLUMN IF NOT EXISTS branch_id BIGINT REFERENCES branches(id);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS branch_id BIGINT REFERENCES branches(id);
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS branch_id BIGINT REFERENCES branches(id);
+
+UPDATE users SET branch_id = (SELECT id FROM branches WHERE code = 'HQ') WHERE branch_id IS NULL;
+UPDATE accounts SET branch_id = users.branch_id FROM users WHERE users.id = accounts.user_id AND accounts.branch_id IS NULL;
+UPDATE credits SET branch_id = users.branch_id FROM users WHERE users.id = credits.user_id AND credits.branch_id IS NULL;
+
+ALTER TABLE users ALTER COLUMN branch_id SET NOT NULL;
+ALTER TABLE accounts ALTER COLUMN branch_id SET NOT NULL;
+ALTER TABLE credits ALTER COLUMN branch_id SET NOT NULL;
+
+-- Create indexes on branch_id for the branch reports
+CREATE INDEX IF NOT EXISTS idx_users_branch_id ON users(branch_id);
+CREATE INDEX IF NOT EXISTS idx_accounts_branch_id ON accounts(branch_id);
+CREATE INDEX IF NOT EXISTS idx_credits_branch_id ON credits(branch_id);
+
+-- Allow the branch administrator role, which manages the users of its branch
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'support', 'branch_admin'));
@@ -0,0 +1,7 @@
+-- Compliance staff lose their role before the narrower check is restored
+UPDATE users SET role = 'user' WHERE role = 'compliance';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'support', 'branch_admin'));
+
+DROP TABLE IF EXISTS aml_scan_state;
+DROP TABLE IF EXISTS aml_flags;
@@ -0,0 +1,41 @@
+-- Anti-money laundering monitoring. Posted transactions are scanned after the
+-- fact; a transaction matching a rule is flagged once per rule and reviewed by
+-- compliance staff, who report it to the regulator or dismiss it.
+CREATE TABLE IF NOT EXISTS aml_flags (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    user_id INTEGER REFERENCES users(id),
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    rule VARCHAR(30) NOT NULL,
+    reason TEXT NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    amount_rub DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'under_review', 'reported', 'dismissed')),
+    reviewer_id INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (transaction_id, rule)
+);
+
+CREATE INDEX IF NOT EXISTS idx_aml_flags_status ON aml_flags(status);
+CREATE INDEX IF NOT EXISTS idx_aml_flags_reviewed_at ON aml_flags(reviewed_at) WHERE status = 'reported';
+
+-- The last transaction scanned, so every run picks up where the previous stopped
+CREATE TABLE IF NOT EXISTS aml_scan_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_transaction_id BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Monitoring starts with the transactions posted from now on
+INSERT INTO aml_scan_state (id, last_transaction_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM transactions
+ON CONFLICT (id) DO NOTHING;
+
+-- Allow the compliance role, which reviews the flags and downloads the reports
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'support', 'branch_admin', 'compliance'));
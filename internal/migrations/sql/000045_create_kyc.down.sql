@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS kyc_documents;
+
+DROP INDEX IF EXISTS idx_users_kyc_status;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_reviewed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_reviewer_id;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_submitted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_comment;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_status;
@@ -0,0 +1,28 @@
+-- Know your customer verification. Users upload identity documents, which are
+-- kept in object storage, and submit them for review; an administrator verifies
+-- or rejects the user's identity.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_status VARCHAR(20) NOT NULL DEFAULT 'unverified'
+    CHECK (kyc_status IN ('unverified', 'pending', 'verified', 'rejected'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_comment TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_submitted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_reviewer_id INTEGER REFERENCES users(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_reviewed_at TIMESTAMP;
+
+-- Users who registered before the verification existed were identified when
+-- they opened their accounts at a branch
+UPDATE users SET kyc_status = 'verified', kyc_reviewed_at = CURRENT_TIMESTAMP WHERE kyc_status = 'unverified';
+
+CREATE INDEX IF NOT EXISTS idx_users_kyc_status ON users(kyc_status) WHERE kyc_status = 'pending';
+
+CREATE TABLE IF NOT EXISTS kyc_documents (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('passport', 'selfie')),
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_user_id ON kyc_documents(user_id);
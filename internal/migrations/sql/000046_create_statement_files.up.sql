@@ -0,0 +1,15 @@
+-- Create statement_files table recording the monthly statements kept in object
+-- storage, so that users can download them again after the email
+CREATE TABLE IF NOT EXISTS statement_files (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id INTEGER NOT NULL REFERENCES statement_deliveries(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    file_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_statement_files_user_id ON statement_files(user_id, period_start);
@@ -0,0 +1,38 @@
+-- Disputes of transactions. A user contests a debit of an account they operate
+-- with a reason and supporting files kept in object storage. The bank credits
+-- the disputed amount provisionally while the dispute is under review and keeps
+-- or reverses the credit depending on the outcome.
+CREATE TABLE IF NOT EXISTS disputes (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL UNIQUE REFERENCES transactions(id),
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    reason VARCHAR(30) NOT NULL,
+    description TEXT,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'under_review', 'resolved', 'refunded', 'declined')),
+    reviewer_id INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    provisional_transaction_id INTEGER REFERENCES transactions(id),
+    reversal_transaction_id INTEGER REFERENCES transactions(id),
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_user_id ON disputes(user_id);
+CREATE INDEX IF NOT EXISTS idx_disputes_status ON disputes(status);
+
+CREATE TABLE IF NOT EXISTS dispute_attachments (
+    id BIGSERIAL PRIMARY KEY,
+    dispute_id BIGINT NOT NULL REFERENCES disputes(id) ON DELETE CASCADE,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispute_attachments_dispute_id ON dispute_attachments(dispute_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payee_transfers;
+DROP TABLE IF EXISTS payees;
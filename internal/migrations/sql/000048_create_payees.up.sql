@@ -0,0 +1,39 @@
+-- Payees users save to transfer to again: an account or a card at the bank, or
+-- an account at another bank. The first transfer to a payee is confirmed with a
+-- code emailed to the user, kept only as a bcrypt hash.
+CREATE TABLE IF NOT EXISTS payees (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('account', 'card', 'external')),
+    account_number VARCHAR(34),
+    card_number VARCHAR(16),
+    beneficiary_name VARCHAR(140),
+    iban VARCHAR(34),
+    bic VARCHAR(11),
+    confirmed_at TIMESTAMP,
+    code_hash VARCHAR(255),
+    code_expires_at TIMESTAMP,
+    code_attempts INTEGER NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payees_user_id ON payees(user_id);
+
+-- Transfers made to payees, for the transfer analytics. A payee keeps its
+-- transfers when it is deleted, under the name it had.
+CREATE TABLE IF NOT EXISTS payee_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    payee_id BIGINT REFERENCES payees(id) ON DELETE SET NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payee_name VARCHAR(100) NOT NULL,
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    external_transfer_id INTEGER REFERENCES external_transfers(id),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payee_transfers_user_id_created_at ON payee_transfers(user_id, created_at);
@@ -0,0 +1,28 @@
+-- Requests for money a user sends another user, found by email or phone
+-- number. Accepting a request transfers the amount to the requester's account
+-- in one database transaction with the change of its status; a transfer held by
+-- the fraud rules keeps the request held until the check is reviewed.
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id BIGSERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requester_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    payer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    description VARCHAR(140),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'held', 'paid', 'declined', 'cancelled', 'expired')),
+    payer_account_id INTEGER REFERENCES accounts(id),
+    transaction_id INTEGER REFERENCES transactions(id),
+    fraud_check_id INTEGER REFERENCES fraud_checks(id),
+    decline_reason VARCHAR(140),
+    expires_at TIMESTAMP NOT NULL,
+    responded_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_requests_payer_id ON payment_requests(payer_id);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_requester_id ON payment_requests(requester_id);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_fraud_check_id ON payment_requests(fraud_check_id);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_pending_expires_at ON payment_requests(expires_at) WHERE status = 'pending';
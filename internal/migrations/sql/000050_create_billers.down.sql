@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bill_payments;
+DROP TABLE IF EXISTS billers;
@@ -0,0 +1,43 @@
+-- The catalog of billers whose bills users pay, such as mobile operators and
+-- utilities. A configured provider makes the payments and knows the biller by
+-- its provider code.
+CREATE TABLE IF NOT EXISTS billers (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    category VARCHAR(20) NOT NULL CHECK (category IN ('mobile', 'utilities', 'internet', 'tv', 'other')),
+    provider VARCHAR(50) NOT NULL,
+    provider_code VARCHAR(100) NOT NULL,
+    account_label VARCHAR(100) NOT NULL,
+    account_pattern VARCHAR(255),
+    currency VARCHAR(3) NOT NULL,
+    min_amount DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+    max_amount DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (max_amount >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, provider_code)
+);
+
+-- Bill payments. The account is debited before the provider is asked to pay and
+-- credited back when the provider fails the payment.
+CREATE TABLE IF NOT EXISTS bill_payments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    biller_id BIGINT NOT NULL REFERENCES billers(id),
+    biller_name VARCHAR(100) NOT NULL,
+    customer_account VARCHAR(100) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'completed', 'refunded')),
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    refund_transaction_id INTEGER REFERENCES transactions(id),
+    provider_reference VARCHAR(100),
+    failure_reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bill_payments_user_id ON bill_payments(user_id);
+CREATE INDEX IF NOT EXISTS idx_bill_payments_account_id_created_at ON bill_payments(account_id, created_at);
@@ -0,0 +1,10 @@
+-- Installments archived by repricings would reappear as outstanding, so they are removed
+DELETE FROM payment_schedules WHERE rate_change_id IS NOT NULL;
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS rate_change_id;
+DROP TABLE IF EXISTS credit_rate_changes;
+DROP TABLE IF EXISTS key_rates;
+DROP INDEX IF EXISTS idx_credits_variable_active;
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS rate_margin;
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS rate_type;
+ALTER TABLE credits DROP COLUMN IF EXISTS rate_margin;
+ALTER TABLE credits DROP COLUMN IF EXISTS rate_type;
@@ -0,0 +1,37 @@
+-- Credits at a variable rate follow the CBR key rate plus a margin fixed when
+-- the credit is applied for
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS rate_type VARCHAR(10) NOT NULL DEFAULT 'fixed'
+    CHECK (rate_type IN ('fixed', 'variable'));
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS rate_margin DECIMAL(5,2);
+
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS rate_type VARCHAR(10) NOT NULL DEFAULT 'fixed'
+    CHECK (rate_type IN ('fixed', 'variable'));
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS rate_margin DECIMAL(5,2);
+
+CREATE INDEX IF NOT EXISTS idx_credits_variable_active ON credits(id) WHERE rate_type = 'variable' AND status = 'active';
+
+-- The key rates as the bank saw them change
+CREATE TABLE IF NOT EXISTS key_rates (
+    id BIGSERIAL PRIMARY KEY,
+    rate DECIMAL(5,2) NOT NULL,
+    detected_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Repricings of variable-rate credits after key rate changes
+CREATE TABLE IF NOT EXISTS credit_rate_changes (
+    id BIGSERIAL PRIMARY KEY,
+    credit_id INTEGER NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    key_rate_id BIGINT NOT NULL REFERENCES key_rates(id),
+    key_rate DECIMAL(5,2) NOT NULL,
+    previous_rate DECIMAL(5,2) NOT NULL,
+    new_rate DECIMAL(5,2) NOT NULL,
+    previous_payment DECIMAL(15,2) NOT NULL,
+    new_payment DECIMAL(15,2) NOT NULL,
+    installments INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_rate_changes_credit_id ON credit_rate_changes(credit_id, created_at);
+
+-- Installments replaced by a repricing are kept as archived
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS rate_change_id BIGINT REFERENCES credit_rate_changes(id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS collection_cases;
+UPDATE credits SET status = 'active' WHERE status IN ('delinquent', 'collections');
+ALTER TABLE credits DROP CONSTRAINT IF EXISTS credits_status_check;
+ALTER TABLE credits ADD CONSTRAINT credits_status_check CHECK (status IN ('active', 'paid', 'defaulted'));
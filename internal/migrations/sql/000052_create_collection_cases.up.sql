@@ -0,0 +1,29 @@
+-- Credits overdue for long move to delinquent and then to collections until the
+-- arrears are paid
+ALTER TABLE credits DROP CONSTRAINT IF EXISTS credits_status_check;
+ALTER TABLE credits ADD CONSTRAINT credits_status_check
+    CHECK (status IN ('active', 'paid', 'defaulted', 'delinquent', 'collections'));
+
+-- Collection cases follow a credit from the first day it is delinquent until
+-- its arrears are paid
+CREATE TABLE IF NOT EXISTS collection_cases (
+    id BIGSERIAL PRIMARY KEY,
+    credit_id INTEGER NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stage VARCHAR(20) NOT NULL CHECK (stage IN ('delinquent', 'collections')),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    days_overdue INTEGER NOT NULL,
+    overdue_amount DECIMAL(15,2) NOT NULL,
+    fees DECIMAL(15,2) NOT NULL DEFAULT 0,
+    notices_sent INTEGER NOT NULL DEFAULT 0,
+    last_notice_at TIMESTAMP,
+    escalated_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A credit has at most one open case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_collection_cases_open ON collection_cases(credit_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_collection_cases_status ON collection_cases(status, stage);
+CREATE INDEX IF NOT EXISTS idx_collection_cases_user_id ON collection_cases(user_id);
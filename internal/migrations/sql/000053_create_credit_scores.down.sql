@@ -0,0 +1,3 @@
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS rate_adjustment;
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS credit_score;
+DROP TABLE IF EXISTS credit_scores;
@@ -0,0 +1,17 @@
+-- Credit scores keep the history of each user's internal credit score; a score
+-- is recorded when it changes or when the user applies for a credit
+CREATE TABLE IF NOT EXISTS credit_scores (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL CHECK (score BETWEEN 0 AND 100),
+    rating VARCHAR(1) NOT NULL CHECK (rating IN ('A', 'B', 'C', 'D', 'E')),
+    factors JSONB NOT NULL,
+    reason VARCHAR(30) NOT NULL CHECK (reason IN ('requested', 'credit_application')),
+    calculated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_scores_user_id ON credit_scores(user_id, calculated_at DESC);
+
+-- Applications keep the score the applicant's rate was priced on
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS credit_score INTEGER;
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS rate_adjustment DECIMAL(5,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,30 @@
+-- Term deposits. The amount is taken from the account when the deposit is
+-- opened and paid back to it with the interest at maturity, or earlier at the
+-- early withdrawal rate.
+CREATE TABLE IF NOT EXISTS deposits (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    term_months INTEGER NOT NULL CHECK (term_months > 0),
+    rate_type VARCHAR(20) NOT NULL CHECK (rate_type IN ('fixed', 'key_rate')),
+    interest_rate DECIMAL(5,2) NOT NULL CHECK (interest_rate >= 0),
+    key_rate DECIMAL(5,2),
+    accrued_interest DECIMAL(15,2) NOT NULL DEFAULT 0,
+    interest_accrued_at DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'matured', 'withdrawn')),
+    opened_at TIMESTAMP NOT NULL,
+    matures_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP,
+    paid_interest DECIMAL(15,2) NOT NULL DEFAULT 0,
+    penalty DECIMAL(15,2) NOT NULL DEFAULT 0,
+    opening_transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    return_transaction_id INTEGER REFERENCES transactions(id),
+    interest_transaction_id INTEGER REFERENCES transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits(user_id);
+CREATE INDEX IF NOT EXISTS idx_deposits_active ON deposits(matures_at) WHERE status = 'active';
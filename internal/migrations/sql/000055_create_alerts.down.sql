@@ -0,0 +1,3 @@
+ALTER TABLE card_authorizations DROP COLUMN IF EXISTS merchant_country;
+DROP TABLE IF EXISTS alert_scan_state;
+DROP TABLE IF EXISTS alerts;
@@ -0,0 +1,32 @@
+-- Alerts users set on their accounts: the balance falling below a threshold,
+-- a payment above a threshold arriving, and a card payment made abroad
+CREATE TABLE IF NOT EXISTS alerts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('balance_below', 'incoming_above', 'card_abroad')),
+    threshold DECIMAL(15,2) CHECK (threshold > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    triggered BOOLEAN NOT NULL DEFAULT FALSE,
+    last_triggered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_user_id ON alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_account_id ON alerts(account_id) WHERE enabled;
+
+-- The last transaction evaluated, so every run picks up where the previous stopped
+CREATE TABLE IF NOT EXISTS alert_scan_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_transaction_id BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts are evaluated for the transactions posted from now on
+INSERT INTO alert_scan_state (id, last_transaction_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM transactions
+ON CONFLICT (id) DO NOTHING;
+
+-- The country the acquirer reports for the merchant of a card payment
+ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS merchant_country VARCHAR(2);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bill_payments_transaction_id;
+DROP TABLE IF EXISTS budgets;
@@ -0,0 +1,17 @@
+-- Monthly spending limits users set per category, in RUB. The highest
+-- utilization the user was notified of is kept with its month, so every
+-- threshold is notified once a month.
+CREATE TABLE IF NOT EXISTS budgets (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL,
+    monthly_limit DECIMAL(15,2) NOT NULL CHECK (monthly_limit > 0),
+    notified_percent INTEGER NOT NULL DEFAULT 0,
+    notified_month DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, category)
+);
+
+-- Spending by category looks up the bill payment of a transaction
+CREATE INDEX IF NOT EXISTS idx_bill_payments_transaction_id ON bill_payments(transaction_id);
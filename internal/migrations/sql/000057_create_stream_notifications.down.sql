@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS notify_card_status_changed ON cards;
+DROP TRIGGER IF EXISTS notify_transfer_received ON transactions;
+DROP TRIGGER IF EXISTS notify_balance_changed ON accounts;
+DROP FUNCTION IF EXISTS notify_card_status_changed();
+DROP FUNCTION IF EXISTS notify_transfer_received();
+DROP FUNCTION IF EXISTS notify_balance_changed();
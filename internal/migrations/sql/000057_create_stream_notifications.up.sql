@@ -0,0 +1,88 @@
+-- Changes pushed to the server-sent event stream of the account owner. Each
+-- change is notified on the user_events channel as JSON with the user, the
+-- event type and its data; notifications are sent when the transaction
+-- commits, so a rolled back change is never streamed.
+CREATE OR REPLACE FUNCTION notify_balance_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('user_events', json_build_object(
+        'user_id', NEW.user_id,
+        'type', 'balance.changed',
+        'data', json_build_object(
+            'account_id', NEW.id,
+            'balance', NEW.balance,
+            'previous_balance', OLD.balance,
+            'currency', NEW.currency,
+            'changed_at', CURRENT_TIMESTAMP
+        )
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_balance_changed
+    AFTER UPDATE OF balance ON accounts
+    FOR EACH ROW
+    WHEN (OLD.balance IS DISTINCT FROM NEW.balance)
+    EXECUTE FUNCTION notify_balance_changed();
+
+-- Only transfers from another user are notified; moving money between one's
+-- own accounts shows as balance changes alone
+CREATE OR REPLACE FUNCTION notify_transfer_received()
+RETURNS TRIGGER AS $$
+DECLARE
+    recipient accounts%ROWTYPE;
+    sender_user_id INTEGER;
+BEGIN
+    SELECT * INTO recipient FROM accounts WHERE id = NEW.to_account_id;
+    SELECT user_id INTO sender_user_id FROM accounts WHERE id = NEW.from_account_id;
+    IF recipient.user_id IS NULL OR sender_user_id IS NULL OR sender_user_id = recipient.user_id THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('user_events', json_build_object(
+        'user_id', recipient.user_id,
+        'type', 'transfer.received',
+        'data', json_build_object(
+            'transaction_id', NEW.id,
+            'account_id', NEW.to_account_id,
+            'from_account_id', NEW.from_account_id,
+            'amount', NEW.amount,
+            'currency', recipient.currency,
+            'description', NEW.description,
+            'received_at', NEW.created_at
+        )
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_transfer_received
+    AFTER INSERT ON transactions
+    FOR EACH ROW
+    WHEN (NEW.type = 'transfer' AND NEW.from_account_id IS NOT NULL AND NEW.to_account_id IS NOT NULL)
+    EXECUTE FUNCTION notify_transfer_received();
+
+CREATE OR REPLACE FUNCTION notify_card_status_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('user_events', json_build_object(
+        'user_id', NEW.user_id,
+        'type', 'card.status_changed',
+        'data', json_build_object(
+            'card_id', NEW.id,
+            'account_id', NEW.account_id,
+            'status', NEW.status,
+            'previous_status', OLD.status,
+            'changed_at', CURRENT_TIMESTAMP
+        )
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_card_status_changed
+    AFTER UPDATE OF status ON cards
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION notify_card_status_changed();
@@ -0,0 +1,42 @@
+-- Batches of transfers from one account, such as payroll payouts. A batch is
+-- processed by a background job either atomically, all transfers in one
+-- database transaction, or item by item, each transfer committed together with
+-- the status of its item so a retried job never repeats one.
+CREATE TABLE IF NOT EXISTS transfer_batches (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    mode VARCHAR(10) NOT NULL CHECK (mode IN ('atomic', 'per_item')),
+    status VARCHAR(30) NOT NULL
+        CHECK (status IN ('pending', 'processing', 'completed', 'completed_with_errors', 'failed')),
+    total_transfers INTEGER NOT NULL,
+    total_amount DECIMAL(15,2) NOT NULL,
+    completed_transfers INTEGER NOT NULL DEFAULT 0,
+    held_transfers INTEGER NOT NULL DEFAULT 0,
+    failed_transfers INTEGER NOT NULL DEFAULT 0,
+    client_ip VARCHAR(45),
+    client_country VARCHAR(2),
+    job_id INTEGER REFERENCES jobs(id),
+    error TEXT,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_batches_user_id ON transfer_batches(user_id);
+
+CREATE TABLE IF NOT EXISTS transfer_batch_items (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id BIGINT NOT NULL REFERENCES transfer_batches(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    to_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    reference VARCHAR(140),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'completed', 'held', 'failed')),
+    transaction_id INTEGER REFERENCES transactions(id),
+    fraud_check_id INTEGER REFERENCES fraud_checks(id),
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (batch_id, position)
+);
@@ -0,0 +1,6 @@
+-- The user a job works for, who can follow its progress, and what it produced
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS result JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE credit_applications DROP COLUMN IF EXISTS insurance_premium;
+ALTER TABLE payment_schedules DROP COLUMN IF EXISTS insurance_premium;
+DROP TABLE IF EXISTS credit_insurance_claims;
+DROP TABLE IF EXISTS credit_insurances;
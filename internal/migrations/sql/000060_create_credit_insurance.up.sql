@@ -0,0 +1,39 @@
+-- Payment protection insurance taken with a credit
+CREATE TABLE IF NOT EXISTS credit_insurances (
+    id SERIAL PRIMARY KEY,
+    credit_id INTEGER NOT NULL UNIQUE REFERENCES credits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    monthly_premium DECIMAL(15,2) NOT NULL CHECK (monthly_premium > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'claim')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS credit_insurance_claims (
+    id SERIAL PRIMARY KEY,
+    insurance_id INTEGER NOT NULL REFERENCES credit_insurances(id) ON DELETE CASCADE,
+    credit_id INTEGER NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    event VARCHAR(20) NOT NULL CHECK (event IN ('job_loss', 'illness', 'disability')),
+    description TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'approved', 'rejected', 'closed')),
+    paused_at TIMESTAMP WITH TIME ZONE,
+    resumed_at TIMESTAMP WITH TIME ZONE,
+    paused_months INTEGER,
+    reviewed_by INTEGER REFERENCES users(id),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_insurance_claims_credit_id ON credit_insurance_claims(credit_id);
+CREATE INDEX IF NOT EXISTS idx_credit_insurance_claims_status ON credit_insurance_claims(status);
+
+-- A credit has at most one claim under review or in force
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_insurance_claims_open
+    ON credit_insurance_claims(credit_id) WHERE status IN ('pending', 'approved');
+
+-- The premium is part of the amount of each installment of an insured credit
+ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS insurance_premium DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE credit_applications ADD COLUMN IF NOT EXISTS insurance_premium DECIMAL(15,2);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS collateral_documents;
+DROP TABLE IF EXISTS collateral;
@@ -0,0 +1,31 @@
+-- Property pledged by a borrower to secure a credit. Collateral is registered
+-- for a user and linked to one of their credits, or released from it again.
+CREATE TABLE IF NOT EXISTS collateral (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    credit_id INTEGER REFERENCES credits(id) ON DELETE SET NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('real_estate', 'vehicle', 'deposit', 'securities', 'equipment', 'other')),
+    description TEXT NOT NULL,
+    appraised_value DECIMAL(15,2) NOT NULL CHECK (appraised_value > 0),
+    appraised_by INTEGER NOT NULL REFERENCES users(id),
+    appraised_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_collateral_user_id ON collateral(user_id);
+CREATE INDEX IF NOT EXISTS idx_collateral_credit_id ON collateral(credit_id);
+
+-- Title deeds, appraisal reports and other documents of collateral, kept in
+-- object storage
+CREATE TABLE IF NOT EXISTS collateral_documents (
+    id BIGSERIAL PRIMARY KEY,
+    collateral_id INTEGER NOT NULL REFERENCES collateral(id) ON DELETE CASCADE,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_collateral_documents_collateral_id ON collateral_documents(collateral_id);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS prevent_closed_account_change ON accounts;
+DROP FUNCTION IF EXISTS prevent_closed_account_change();
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
@@ -0,0 +1,16 @@
+-- A closed account keeps its number and history but can no longer change: its
+-- balance has been swept to another account and its final statement issued.
+ALTER TABLE accounts ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE OR REPLACE FUNCTION prevent_closed_account_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'account % is closed', OLD.id;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER prevent_closed_account_change
+    BEFORE UPDATE OR DELETE ON accounts
+    FOR EACH ROW
+    WHEN (OLD.closed_at IS NOT NULL)
+    EXECUTE FUNCTION prevent_closed_account_change();
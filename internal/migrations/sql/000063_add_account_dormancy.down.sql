@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounts_dormant_since;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS reactivated_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS dormancy_fee_charged_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS dormant_since;
@@ -0,0 +1,8 @@
+-- An account with no activity for the configured number of months becomes
+-- dormant: it can still receive money, but its owner has to reactivate it
+-- before it is debited again.
+ALTER TABLE accounts ADD COLUMN dormant_since TIMESTAMP WITH TIME ZONE;
+ALTER TABLE accounts ADD COLUMN dormancy_fee_charged_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE accounts ADD COLUMN reactivated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_dormant_since ON accounts(dormant_since) WHERE dormant_since IS NOT NULL;
@@ -1,51 +1,187 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
+const (
+	TransactionTypeTransfer           = "transfer"
+	TransactionTypeDeposit            = "deposit"
+	TransactionTypeWithdrawal         = "withdrawal"
+	TransactionTypeOverdraftInterest  = "overdraft_interest"
+	TransactionTypeCardPayment        = "card_payment"
+	TransactionTypeCreditRepayment    = "credit_repayment"
+	TransactionTypeCreditDisbursement = "credit_disbursement"
+	TransactionTypeExternalTransfer   = "external_transfer"
+	TransactionTypeExternalReturn     = "external_transfer_return"
+	TransactionTypeDisputeCredit      = "dispute_credit"
+	TransactionTypeDisputeReversal    = "dispute_reversal"
+	TransactionTypeBillPayment        = "bill_payment"
+	TransactionTypeBillRefund         = "bill_payment_refund"
+	TransactionTypeDepositOpening     = "term_deposit_opening"
+	TransactionTypeDepositReturn      = "term_deposit_return"
+	TransactionTypeDepositInterest    = "term_deposit_interest"
+	TransactionTypeDormancyFee        = "dormancy_fee"
+)
+
 // Account represents a bank account
 type Account struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id" validate:"required"`
-	Balance   float64   `json:"balance" validate:"gte=0"`
-	Currency  string    `json:"currency" validate:"required,len=3"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                         int64      `json:"id"`
+	UserID                     int64      `json:"user_id" validate:"required"`
+	BranchID                   int64      `json:"branch_id"` // branch of the owner when the account was opened
+	Number                     string     `json:"number"`    // 20-digit account number, see NewAccountNumber
+	Name                       string     `json:"name,omitempty"`
+	Primary                    bool       `json:"primary"` // default account of the user in its currency
+	Balance                    float64    `json:"balance"`
+	Currency                   string     `json:"currency" validate:"required,iso4217"`
+	OverdraftLimit             float64    `json:"overdraft_limit" validate:"gte=0"`
+	OverdraftInterestRate      float64    `json:"overdraft_interest_rate" validate:"gte=0"`
+	OverdraftInterestAccruedAt time.Time  `json:"-"`
+	HeldAmount                 float64    `json:"held_amount"` // reserved by active holds, still part of Balance
+	Version                    int64      `json:"version"`     // incremented by every update of the account
+	CreatedAt                  time.Time  `json:"created_at"`
+	UpdatedAt                  time.Time  `json:"updated_at"`
+	ClosedAt                   *time.Time `json:"closed_at,omitempty"`     // a closed account can no longer change
+	DormantSince               *time.Time `json:"dormant_since,omitempty"` // no activity for long, debits wait for a reactivation
+	DormancyFeeChargedAt       *time.Time `json:"-"`
+	ReactivatedAt              *time.Time `json:"reactivated_at,omitempty"`
+}
+
+// IsClosed reports whether the account has been closed
+func (a *Account) IsClosed() bool {
+	return a.ClosedAt != nil
+}
+
+// IsDormant reports whether the account has been marked dormant and not
+// reactivated since
+func (a *Account) IsDormant() bool {
+	return a.DormantSince != nil
+}
+
+// MaskedNumber returns the account number for people other than the account
+// holders, falling back to the ID while the account has no number yet
+func (a *Account) MaskedNumber() string {
+	if a.Number == "" {
+		return fmt.Sprintf("#%d", a.ID)
+	}
+	return MaskAccountNumber(a.Number)
+}
+
+// AvailableBalance returns the amount that can be spent: the booked balance and
+// the overdraft limit less the amount held for payments not booked yet
+func (a *Account) AvailableBalance() float64 {
+	return a.Balance + a.OverdraftLimit - a.HeldAmount
+}
+
+// MarshalJSON adds the available balance to the account
+func (a Account) MarshalJSON() ([]byte, error) {
+	type account Account
+	return json.Marshal(struct {
+		account
+		AvailableBalance float64 `json:"available_balance"`
+	}{account(a), a.AvailableBalance()})
+}
+
+// OverdraftUsed returns how much of the overdraft limit is currently drawn
+func (a *Account) OverdraftUsed() float64 {
+	if a.Balance >= 0 {
+		return 0
+	}
+	return -a.Balance
 }
 
 // Transaction represents a financial transaction
 type Transaction struct {
-	ID            int64     `json:"id"`
-	FromAccountID int64     `json:"from_account_id" validate:"required"`
-	ToAccountID   int64     `json:"to_account_id" validate:"required"`
-	Amount        float64   `json:"amount" validate:"required,gt=0"`
-	Type          string    `json:"type" validate:"required,oneof=transfer deposit withdrawal"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID                int64               `json:"id"`
+	FromAccountID     int64               `json:"from_account_id" validate:"required"`
+	ToAccountID       int64               `json:"to_account_id" validate:"required"`
+	Amount            float64             `json:"amount" validate:"required,gt=0"`
+	Type              string              `json:"type" validate:"required,oneof=transfer deposit withdrawal overdraft_interest card_payment"`
+	CardID            int64               `json:"card_id,omitempty"`
+	Description       string              `json:"description,omitempty"`
+	Conversion        *CurrencyConversion `json:"conversion,omitempty"`
+	AppliedParameters []AppliedParameter  `json:"applied_parameters,omitempty"`
+	CreatedAt         time.Time           `json:"created_at"`
+}
+
+// TransactionFilter narrows the transactions listed for accounts. Zero fields
+// do not filter; Limit bounds the transactions listed per account.
+type TransactionFilter struct {
+	Type      string
+	From      time.Time
+	To        time.Time
+	MinAmount float64
+	MaxAmount float64
+	Limit     int
 }
 
 // CreateAccountRequest represents a request to create a new account
 type CreateAccountRequest struct {
 	UserID   int64   `json:"user_id" validate:"required"`
-	Currency string  `json:"currency" validate:"required,len=3"`
+	Currency string  `json:"currency" validate:"required,iso4217"`
 	Balance  float64 `json:"balance" validate:"gte=0"`
 }
 
+// UpdateAccountRequest represents a change of the name or the primary flag of an
+// account; fields left out are not changed
+type UpdateAccountRequest struct {
+	Name    *string `json:"name" validate:"omitempty,max=100"`
+	Primary *bool   `json:"primary"`
+}
+
 // TransferRequest represents a money transfer request
+// The recipient account is given by its ID or by its account number.
 type TransferRequest struct {
-	FromAccountID int64   `json:"from_account_id" validate:"required"`
-	ToAccountID   int64   `json:"to_account_id" validate:"required,nefield=FromAccountID"`
-	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	FromAccountID   int64   `json:"from_account_id" validate:"required"`
+	ToAccountID     int64   `json:"to_account_id" validate:"required_without=ToAccountNumber,omitempty,nefield=FromAccountID"`
+	ToAccountNumber string  `json:"to_account_number,omitempty" validate:"omitempty,len=20,numeric"`
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// AccountLookup represents the public details of an account found by its
+// number, which let the sender check the recipient before a transfer
+type AccountLookup struct {
+	Number   string `json:"number"` // masked
+	Currency string `json:"currency"`
+	Holder   string `json:"holder"` // first name and last name initial
 }
 
-// DepositRequest represents a request to deposit money into an account
+// DepositRequest represents a request to deposit money into an account. Without an
+// account the deposit goes to the primary account in the currency.
 type DepositRequest struct {
-	AccountID string  `json:"account_id" validate:"required"`
+	AccountID int64   `json:"account_id" validate:"required_without=Currency"`
+	Currency  string  `json:"currency" validate:"omitempty,iso4217"`
 	Amount    float64 `json:"amount" validate:"required,gt=0"`
 }
 
 // WithdrawRequest represents a request to withdraw money from an account
 type WithdrawRequest struct {
-	AccountID string  `json:"account_id" validate:"required"`
+	AccountID int64   `json:"account_id" validate:"required"`
 	Amount    float64 `json:"amount" validate:"required,gt=0"`
 }
+
+// CloseAccountRequest represents a request to close an account. A remaining
+// balance is swept to the target account, given by its ID or by its number.
+type CloseAccountRequest struct {
+	TargetAccountID     int64  `json:"target_account_id,omitempty"`
+	TargetAccountNumber string `json:"target_account_number,omitempty" validate:"omitempty,len=20,numeric"`
+}
+
+// AccountClosure represents a closed account, where its balance went and the
+// job rendering its final statement
+type AccountClosure struct {
+	AccountID          int64     `json:"account_id"`
+	ClosedAt           time.Time `json:"closed_at"`
+	SweptAmount        float64   `json:"swept_amount"`
+	TargetAccountID    int64     `json:"target_account_id,omitempty"`
+	SweepTransactionID int64     `json:"sweep_transaction_id,omitempty"`
+	StatementJob       *Job      `json:"statement_job"`
+}
+
+// SetOverdraftRequest represents an admin request to configure an account overdraft
+type SetOverdraftRequest struct {
+	Limit        float64 `json:"limit" validate:"gte=0"`
+	InterestRate float64 `json:"interest_rate" validate:"gte=0"`
+}
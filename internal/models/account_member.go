@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// AccountRole is the permission level of a user on an account
+type AccountRole string
+
+const (
+	// AccountRoleOwner is the holder of the account, who manages its members
+	AccountRoleOwner AccountRole = "owner"
+	// AccountRoleCoOwner may move money, issue cards and read statements
+	AccountRoleCoOwner AccountRole = "co_owner"
+	// AccountRoleViewer may only see the account and its statements
+	AccountRoleViewer AccountRole = "viewer"
+)
+
+// CanOperate reports whether the role may move money and issue cards on the account
+func (r AccountRole) CanOperate() bool {
+	return r == AccountRoleOwner || r == AccountRoleCoOwner
+}
+
+// AccountMemberStatus represents the state of an account membership
+type AccountMemberStatus string
+
+const (
+	AccountMemberStatusInvited AccountMemberStatus = "invited"
+	AccountMemberStatusActive  AccountMemberStatus = "active"
+)
+
+// AccountMember represents a user other than the owner who shares an account.
+// The owner is the user the account belongs to and has no member row.
+type AccountMember struct {
+	ID         int64               `json:"id"`
+	AccountID  int64               `json:"account_id"`
+	UserID     int64               `json:"user_id"`
+	Role       AccountRole         `json:"role"`
+	Status     AccountMemberStatus `json:"status"`
+	InvitedBy  int64               `json:"invited_by"`
+	AcceptedAt *time.Time          `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// InviteAccountMemberRequest represents an invitation of a user to share an account
+type InviteAccountMemberRequest struct {
+	Email string      `json:"email" validate:"required,email"`
+	Role  AccountRole `json:"role" validate:"required,oneof=co_owner viewer"`
+}
+
+// UpdateAccountMemberRequest represents a change of a member's permission level
+type UpdateAccountMemberRequest struct {
+	Role AccountRole `json:"role" validate:"required,oneof=co_owner viewer"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// AccountingEntry aggregates the transactions of a type posted on one day
+// between the same two balance sheet accounts in one currency
+type AccountingEntry struct {
+	Date            time.Time `json:"date"`
+	TransactionType string    `json:"transaction_type"`
+	DebitLedger     string    `json:"debit_ledger"`   // ledger account, see LedgerAccountCustomer and others
+	DebitAccount    string    `json:"debit_account"`  // 5-digit balance sheet account
+	CreditLedger    string    `json:"credit_ledger"`  // ledger account, see LedgerAccountCustomer and others
+	CreditAccount   string    `json:"credit_account"` // 5-digit balance sheet account
+	Currency        string    `json:"currency"`
+	Amount          float64   `json:"amount"`
+	Transactions    int       `json:"transactions"`
+}
+
+// AccountingExport holds the accounting entries of a month for the import into
+// an accounting system
+type AccountingExport struct {
+	Period      string             `json:"period"` // YYYY-MM
+	From        time.Time          `json:"from"`   // inclusive
+	To          time.Time          `json:"to"`     // exclusive
+	Entries     []*AccountingEntry `json:"entries"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
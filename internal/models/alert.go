@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// AlertType represents the condition an alert watches for
+type AlertType string
+
+const (
+	// AlertTypeBalanceBelow fires when the balance of the account falls below the
+	// threshold, and again only after it has recovered
+	AlertTypeBalanceBelow AlertType = "balance_below"
+	// AlertTypeIncomingAbove fires for every payment above the threshold arriving
+	// at the account
+	AlertTypeIncomingAbove AlertType = "incoming_above"
+	// AlertTypeCardAbroad fires for every card payment made at a merchant abroad
+	AlertTypeCardAbroad AlertType = "card_abroad"
+)
+
+// Alert represents a condition a user watches on their accounts. It is sent
+// through the notification channels enabled in the user settings.
+type Alert struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	AccountID       *int64     `json:"account_id,omitempty"` // every account of the user when empty, card_abroad only
+	Type            AlertType  `json:"type"`
+	Threshold       *float64   `json:"threshold,omitempty"` // in the account currency
+	Enabled         bool       `json:"enabled"`
+	Triggered       bool       `json:"triggered"` // a balance alert fired and the balance has not recovered since
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CreateAlertRequest represents a user setting up an alert. Balance and incoming
+// payment alerts need an account and a threshold; card payment alerts take no
+// threshold and watch every account of the user when no account is given.
+type CreateAlertRequest struct {
+	Type      AlertType `json:"type" validate:"required,oneof=balance_below incoming_above card_abroad"`
+	AccountID *int64    `json:"account_id"`
+	Threshold *float64  `json:"threshold" validate:"omitempty,gt=0"`
+}
+
+// UpdateAlertRequest represents a change of the threshold of an alert or its
+// switching on or off; fields left out are not changed
+type UpdateAlertRequest struct {
+	Threshold *float64 `json:"threshold" validate:"omitempty,gt=0"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+// AlertTransaction is a posted transaction as the alert rules see it
+type AlertTransaction struct {
+	ID              int64
+	Type            string
+	FromAccountID   int64 // zero when money arrives from outside the bank
+	ToAccountID     int64 // zero when money leaves the bank
+	Amount          float64
+	Currency        string
+	Description     string
+	MerchantCountry string // of the card authorization a card payment captured
+	CreatedAt       time.Time
+}
+
+// AlertBalance is a balance alert whose account balance crossed its threshold
+// since it was last evaluated
+type AlertBalance struct {
+	Alert    *Alert
+	Balance  float64
+	Currency string
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// AML rules a posted transaction can be flagged by
+const (
+	AMLRuleLargeTransaction = "large_transaction" // at or above the mandatory control threshold
+	AMLRuleStructuring      = "structuring"       // one of several amounts just below the threshold
+	AMLRuleRapidMovement    = "rapid_movement"    // money leaving an account shortly after it arrived
+)
+
+// AMLFlagStatus represents where a flag is in its review
+type AMLFlagStatus string
+
+const (
+	AMLFlagStatusOpen        AMLFlagStatus = "open"
+	AMLFlagStatusUnderReview AMLFlagStatus = "under_review" // taken by a reviewer
+	AMLFlagStatusReported    AMLFlagStatus = "reported"     // confirmed, goes to the regulator report
+	AMLFlagStatusDismissed   AMLFlagStatus = "dismissed"
+)
+
+// AMLTransaction is a posted transaction as the AML rules see it: with the
+// account and user it is monitored for, the sender or else the recipient
+type AMLTransaction struct {
+	ID        int64
+	Type      string
+	AccountID int64
+	UserID    int64
+	Amount    float64
+	Currency  string
+	Outgoing  bool // money leaves the account, which is the sender
+	CreatedAt time.Time
+}
+
+// AMLFlag records a transaction matched by an AML rule and its review
+type AMLFlag struct {
+	ID            int64         `json:"id"`
+	TransactionID int64         `json:"transaction_id"`
+	UserID        int64         `json:"user_id"`
+	AccountID     int64         `json:"account_id"`
+	Rule          string        `json:"rule"`
+	Reason        string        `json:"reason"`
+	Amount        float64       `json:"amount"`
+	Currency      string        `json:"currency"`
+	AmountRUB     float64       `json:"amount_rub"`
+	Status        AMLFlagStatus `json:"status"`
+	ReviewerID    *int64        `json:"reviewer_id,omitempty"`
+	ReviewComment string        `json:"review_comment,omitempty"`
+	ReviewedAt    *time.Time    `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// ReviewAMLFlagRequest represents the outcome of the review of a flag
+type ReviewAMLFlagRequest struct {
+	Comment string `json:"comment" validate:"required,max=2000"`
+}
+
+// AMLReportItem is a reported flag with the details of its transaction and client
+type AMLReportItem struct {
+	Flag              *AMLFlag  `json:"flag"`
+	TransactionType   string    `json:"transaction_type"`
+	TransactionDate   time.Time `json:"transaction_date"`
+	AccountNumber     string    `json:"account_number"`
+	ClientName        string    `json:"client_name"`
+	CounterpartNumber string    `json:"counterpart_number,omitempty"` // other account of a transfer between customers
+}
+
+// AMLReport lists the flags reported within a month for the regulator
+type AMLReport struct {
+	Period      string           `json:"period"` // YYYY-MM
+	From        time.Time        `json:"from"`   // inclusive
+	To          time.Time        `json:"to"`     // exclusive
+	BankBIC     string           `json:"bank_bic"`
+	Items       []*AMLReportItem `json:"items"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
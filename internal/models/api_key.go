@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// APIKeyScope limits what a request authenticated with an API key may do
+type APIKeyScope string
+
+const (
+	APIKeyScopeReadOnly APIKeyScope = "read_only" // reads of the owner's data
+	APIKeyScopePayments APIKeyScope = "payments"  // everything the owner can do as a customer
+	APIKeyScopeAdmin    APIKeyScope = "admin"     // administration, for keys of administrators
+)
+
+// APIKey authenticates a machine-to-machine client as the user owning it. The
+// key itself is returned once when it is issued; only its hash is stored.
+type APIKey struct {
+	ID              int64       `json:"id"`
+	UserID          int64       `json:"user_id"`
+	Name            string      `json:"name"`
+	Prefix          string      `json:"prefix"` // start of the key, to tell keys apart
+	KeyHash         string      `json:"-"`
+	Scope           APIKeyScope `json:"scope"`
+	RequestsPerHour int         `json:"requests_per_hour,omitempty"` // zero leaves the key to the address limits
+	BurstSize       int         `json:"burst_size,omitempty"`
+	RotatedFrom     *int64      `json:"rotated_from,omitempty"`
+	CreatedBy       int64       `json:"created_by"`
+	LastUsedAt      *time.Time  `json:"last_used_at,omitempty"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"`
+	RevokedAt       *time.Time  `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// Role returns the role requests with the key are made in. Only admin keys act
+// as administrators, whatever the role of their owner.
+func (k *APIKey) Role() UserRole {
+	if k.Scope == APIKeyScopeAdmin {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+// Allows tells whether the scope of the key permits a request with the method
+func (k *APIKey) Allows(method string) bool {
+	if k.Scope == APIKeyScopeReadOnly {
+		return method == "GET" || method == "HEAD"
+	}
+	return true
+}
+
+// IssuedAPIKey is a newly issued key together with the key itself and the key
+// its money-moving requests are signed with
+type IssuedAPIKey struct {
+	*APIKey
+	Key        string `json:"key"`
+	SigningKey string `json:"signing_key"`
+}
+
+// CreateAPIKeyRequest represents a request to issue an API key for a user.
+// Admin keys can only be issued for administrators.
+type CreateAPIKeyRequest struct {
+	UserID          int64       `json:"user_id" validate:"required"`
+	Name            string      `json:"name" validate:"required,max=100"`
+	Scope           APIKeyScope `json:"scope" validate:"required,oneof=read_only payments admin"`
+	RequestsPerHour int         `json:"requests_per_hour" validate:"min=0"`
+	BurstSize       int         `json:"burst_size" validate:"min=0"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditAction represents the kind of change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionOwnershipTransferRequested   AuditAction = "ownership_transfer_requested"
+	AuditActionOwnershipTransferApproved    AuditAction = "ownership_transfer_approved"
+	AuditActionOwnershipTransferRejected    AuditAction = "ownership_transfer_rejected"
+	AuditActionOwnershipChanged             AuditAction = "ownership_changed"
+	AuditActionOverdraftChanged             AuditAction = "overdraft_changed"
+	AuditActionAccountClosed                AuditAction = "account_closed"
+	AuditActionAccountReactivated           AuditAction = "account_reactivated"
+	AuditActionCardBatchRequested           AuditAction = "card_batch_requested"
+	AuditActionCardsBlocked                 AuditAction = "cards_blocked"
+	AuditActionCreditApplicationApproved    AuditAction = "credit_application_approved"
+	AuditActionCreditApplicationRejected    AuditAction = "credit_application_rejected"
+	AuditActionParameterChanged             AuditAction = "parameter_changed"
+	AuditActionCreditRestructuringRequested AuditAction = "credit_restructuring_requested"
+	AuditActionCreditRestructuringApproved  AuditAction = "credit_restructuring_approved"
+	AuditActionCreditRestructuringRejected  AuditAction = "credit_restructuring_rejected"
+	AuditActionCreditInsuranceClaimFiled    AuditAction = "credit_insurance_claim_filed"
+	AuditActionCreditInsuranceClaimApproved AuditAction = "credit_insurance_claim_approved"
+	AuditActionCreditInsuranceClaimRejected AuditAction = "credit_insurance_claim_rejected"
+	AuditActionCreditInsuranceClaimClosed   AuditAction = "credit_insurance_claim_closed"
+	AuditActionCollateralRegistered         AuditAction = "collateral_registered"
+	AuditActionCollateralRevalued           AuditAction = "collateral_revalued"
+	AuditActionCollateralLinked             AuditAction = "collateral_linked"
+	AuditActionCollateralReleased           AuditAction = "collateral_released"
+	AuditActionCollateralDocumentViewed     AuditAction = "collateral_document_viewed"
+	AuditActionFraudCheckReleased           AuditAction = "fraud_check_released"
+	AuditActionFraudCheckRejected           AuditAction = "fraud_check_rejected"
+	AuditActionUserLimitsChanged            AuditAction = "user_limits_changed"
+	AuditActionExternalTransferReturned     AuditAction = "external_transfer_returned"
+	AuditActionUserClosed                   AuditAction = "user_closed"
+	AuditActionUserDataExported             AuditAction = "user_data_exported"
+	AuditActionUserUnlocked                 AuditAction = "user_unlocked"
+	AuditActionAdminCreated                 AuditAction = "admin_created"
+	AuditActionAPIKeyIssued                 AuditAction = "api_key_issued"
+	AuditActionAPIKeyRotated                AuditAction = "api_key_rotated"
+	AuditActionAPIKeyRevoked                AuditAction = "api_key_revoked"
+	AuditActionBranchCreated                AuditAction = "branch_created"
+	AuditActionBranchUpdated                AuditAction = "branch_updated"
+	AuditActionUserBranchAssigned           AuditAction = "user_branch_assigned"
+	AuditActionAccountingExported           AuditAction = "accounting_exported"
+	AuditActionAMLFlagReported              AuditAction = "aml_flag_reported"
+	AuditActionAMLFlagDismissed             AuditAction = "aml_flag_dismissed"
+	AuditActionAMLReportGenerated           AuditAction = "aml_report_generated"
+	AuditActionKYCVerified                  AuditAction = "kyc_verified"
+	AuditActionKYCRejected                  AuditAction = "kyc_rejected"
+	AuditActionKYCDocumentViewed            AuditAction = "kyc_document_viewed"
+	AuditActionDisputeTaken                 AuditAction = "dispute_taken"
+	AuditActionDisputeResolved              AuditAction = "dispute_resolved"
+	AuditActionDisputeRefunded              AuditAction = "dispute_refunded"
+	AuditActionDisputeDeclined              AuditAction = "dispute_declined"
+	AuditActionDisputeAttachmentViewed      AuditAction = "dispute_attachment_viewed"
+	AuditActionPayeeConfirmed               AuditAction = "payee_confirmed"
+	AuditActionBillerCreated                AuditAction = "biller_created"
+	AuditActionBillerUpdated                AuditAction = "biller_updated"
+)
+
+// AuditLog represents an immutable audit trail entry
+type AuditLog struct {
+	ID         int64           `json:"id"`
+	ActorID    int64           `json:"actor_id"`
+	Action     AuditAction     `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -8,7 +8,8 @@ import (
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID int64    `json:"user_id"`
+	Role   UserRole `json:"role"`
 	jwt.RegisteredClaims
 }
 
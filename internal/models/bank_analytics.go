@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// BankAnalytics sums up the portfolio of the whole bank for the admin
+// dashboard, with the users registered and the transactions posted within a
+// period. Amounts are per currency and not converted.
+type BankAnalytics struct {
+	From              time.Time                 `json:"from"`
+	To                time.Time                 `json:"to"`
+	Deposits          []*BankDepositStats       `json:"deposits"`           // per currency
+	Credits           []*BankCreditStats        `json:"credits"`            // per currency
+	NewUsers          []*DailyCount             `json:"new_users"`          // per day of the period, days without any included
+	TransactionVolume []*TransactionVolumeStats `json:"transaction_volume"` // per currency, within the period
+	GeneratedAt       time.Time                 `json:"generated_at"`
+}
+
+// BankDepositStats sums up the money customers keep with the bank in a currency
+type BankDepositStats struct {
+	Currency        string  `json:"currency"`
+	AccountBalances float64 `json:"account_balances"` // positive balances only, overdrafts are credit
+	TermDeposits    float64 `json:"term_deposits"`    // active deposits with their accrued interest
+	Total           float64 `json:"total"`
+}
+
+// BankCreditStats sums up the credits being repaid in a currency
+type BankCreditStats struct {
+	Currency           string  `json:"currency"`
+	Active             int     `json:"active"`      // delinquent and in collections included
+	Outstanding        float64 `json:"outstanding"` // remaining principal
+	Overdue            int     `json:"overdue"`     // credits with an overdue payment
+	OverdueOutstanding float64 `json:"overdue_outstanding"`
+	OverdueAmount      float64 `json:"overdue_amount"` // overdue installments with their penalties, unpaid
+	OverdueRatio       float64 `json:"overdue_ratio"`  // overdue outstanding as a percentage of the outstanding principal
+}
+
+// DailyCount counts what happened on a day
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// TransactionVolumeStats sums up the transactions posted in a currency
+type TransactionVolumeStats struct {
+	Currency     string  `json:"currency"`
+	Transactions int     `json:"transactions"`
+	Amount       float64 `json:"amount"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// Categories of the billers in the catalog
+const (
+	BillerCategoryMobile    = "mobile"
+	BillerCategoryUtilities = "utilities"
+	BillerCategoryInternet  = "internet"
+	BillerCategoryTV        = "tv"
+	BillerCategoryOther     = "other"
+)
+
+// BillerCategories lists the categories a biller can be in
+var BillerCategories = []string{
+	BillerCategoryMobile, BillerCategoryUtilities, BillerCategoryInternet, BillerCategoryTV, BillerCategoryOther,
+}
+
+// Biller represents a company whose bills users pay from their accounts, such
+// as a mobile operator or a utility. The provider configured under Provider
+// makes the payments and knows the biller as ProviderCode.
+type Biller struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Category       string    `json:"category"`
+	Provider       string    `json:"provider"`
+	ProviderCode   string    `json:"provider_code"`
+	AccountLabel   string    `json:"account_label"`             // what the customer account is called, e.g. "Phone number"
+	AccountPattern string    `json:"account_pattern,omitempty"` // regular expression the customer account must match
+	Currency       string    `json:"currency"`
+	MinAmount      float64   `json:"min_amount"`
+	MaxAmount      float64   `json:"max_amount,omitempty"` // zero for no maximum
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MatchesAccount reports whether a customer account has the form the biller expects
+func (b *Biller) MatchesAccount(account string) bool {
+	if b.AccountPattern == "" {
+		return true
+	}
+	pattern, err := regexp.Compile(`^(?:` + b.AccountPattern + `)$`)
+	return err == nil && pattern.MatchString(account)
+}
+
+// BillerRequest represents an administrator adding a biller to the catalog or
+// changing one
+type BillerRequest struct {
+	Name           string  `json:"name" validate:"required,max=100"`
+	Category       string  `json:"category" validate:"required,oneof=mobile utilities internet tv other"`
+	Provider       string  `json:"provider" validate:"required,max=50"`
+	ProviderCode   string  `json:"provider_code" validate:"required,max=100"`
+	AccountLabel   string  `json:"account_label" validate:"required,max=100"`
+	AccountPattern string  `json:"account_pattern" validate:"omitempty,max=255"`
+	Currency       string  `json:"currency" validate:"required,iso4217"`
+	MinAmount      float64 `json:"min_amount" validate:"gte=0"`
+	MaxAmount      float64 `json:"max_amount" validate:"gte=0"`
+	Active         *bool   `json:"active"`
+}
+
+// BillPaymentStatus represents the state of a bill payment
+type BillPaymentStatus string
+
+const (
+	// BillPaymentStatusPending is a payment debited from the account and sent to the provider
+	BillPaymentStatusPending BillPaymentStatus = "pending"
+	// BillPaymentStatusCompleted is a payment the provider confirmed
+	BillPaymentStatusCompleted BillPaymentStatus = "completed"
+	// BillPaymentStatusRefunded is a payment the provider failed, credited back to the account
+	BillPaymentStatusRefunded BillPaymentStatus = "refunded"
+)
+
+// BillPayment represents a payment of a bill from an account
+type BillPayment struct {
+	ID                  int64             `json:"id"`
+	UserID              int64             `json:"user_id"`
+	AccountID           int64             `json:"account_id"`
+	BillerID            int64             `json:"biller_id"`
+	BillerName          string            `json:"biller_name"`
+	CustomerAccount     string            `json:"customer_account"`
+	Amount              float64           `json:"amount"`
+	Currency            string            `json:"currency"`
+	Status              BillPaymentStatus `json:"status"`
+	TransactionID       int64             `json:"transaction_id"`                  // the debit of the account
+	RefundTransactionID *int64            `json:"refund_transaction_id,omitempty"` // the credit back of a failed payment
+	ProviderReference   string            `json:"provider_reference,omitempty"`
+	FailureReason       string            `json:"failure_reason,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+// CreateBillPaymentRequest represents a user paying a bill
+type CreateBillPaymentRequest struct {
+	AccountID       int64   `json:"account_id" validate:"required"`
+	BillerID        int64   `json:"biller_id" validate:"required"`
+	CustomerAccount string  `json:"customer_account" validate:"required,max=100"`
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Branch is an office of the bank. Users register with a branch, and their
+// accounts and credits are booked at the branch they belonged to when opened.
+type Branch struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address,omitempty"`
+	Active    bool      `json:"active"` // inactive branches take no new registrations
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateBranchRequest represents the opening of a branch
+type CreateBranchRequest struct {
+	Code    string `json:"code" validate:"required,alphanum,max=20"`
+	Name    string `json:"name" validate:"required,max=255"`
+	Address string `json:"address" validate:"omitempty,max=500"`
+}
+
+// UpdateBranchRequest represents a change of the details of a branch
+type UpdateBranchRequest struct {
+	Name    string `json:"name" validate:"required,max=255"`
+	Address string `json:"address" validate:"omitempty,max=500"`
+	Active  bool   `json:"active"`
+}
+
+// AssignBranchRequest moves a user to a branch, optionally making the user an
+// administrator of it or taking that role away
+type AssignBranchRequest struct {
+	BranchID int64    `json:"branch_id" validate:"required,gt=0"`
+	Role     UserRole `json:"role" validate:"omitempty,oneof=user branch_admin"`
+}
+
+// BranchReport sums up the customers and the portfolio of a branch, with what
+// was opened within a period
+type BranchReport struct {
+	Branch   *Branch               `json:"branch"`
+	From     time.Time             `json:"from"`
+	To       time.Time             `json:"to"`
+	Users    BranchUserStats       `json:"users"`
+	Accounts []*BranchAccountStats `json:"accounts"` // per currency
+	Credits  []*BranchCreditStats  `json:"credits"`  // per currency
+}
+
+// BranchUserStats counts the users of a branch by status
+type BranchUserStats struct {
+	Total      int `json:"total"`
+	Active     int `json:"active"`
+	Blocked    int `json:"blocked"`
+	Closed     int `json:"closed"`
+	Registered int `json:"registered"` // within the period
+}
+
+// BranchAccountStats sums up the accounts of a branch in a currency
+type BranchAccountStats struct {
+	Currency string  `json:"currency"`
+	Accounts int     `json:"accounts"`
+	Balance  float64 `json:"balance"`
+	Opened   int     `json:"opened"` // within the period
+}
+
+// BranchCreditStats sums up the credits of a branch in a currency
+type BranchCreditStats struct {
+	Currency     string  `json:"currency"`
+	Active       int     `json:"active"`      // being repaid, delinquent and in collections included
+	Outstanding  float64 `json:"outstanding"` // remaining principal of active credits
+	Overdue      int     `json:"overdue"`     // active credits with an overdue payment
+	Issued       int     `json:"issued"`      // within the period
+	IssuedAmount float64 `json:"issued_amount"`
+}
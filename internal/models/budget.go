@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Spending categories of the outgoing transactions. Bill payments fall in the
+// category of their biller, with other billers under bills.
+const (
+	SpendingCategoryCardPayments = "card_payments"
+	SpendingCategoryCash         = "cash"
+	SpendingCategoryTransfers    = "transfers"
+	SpendingCategoryMobile       = BillerCategoryMobile
+	SpendingCategoryUtilities    = BillerCategoryUtilities
+	SpendingCategoryInternet     = BillerCategoryInternet
+	SpendingCategoryTV           = BillerCategoryTV
+	SpendingCategoryBills        = "bills"
+	SpendingCategoryLoans        = "loans"
+	SpendingCategoryFees         = "fees"
+)
+
+// SpendingCategories lists the categories a budget can be set for
+var SpendingCategories = []string{
+	SpendingCategoryCardPayments, SpendingCategoryCash, SpendingCategoryTransfers, SpendingCategoryMobile,
+	SpendingCategoryUtilities, SpendingCategoryInternet, SpendingCategoryTV, SpendingCategoryBills,
+	SpendingCategoryLoans, SpendingCategoryFees,
+}
+
+// Budget represents a monthly spending limit a user sets for a category. The
+// limit is in RUB; spending in other currencies is converted at the CBR rate.
+type Budget struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	Category        string     `json:"category"`
+	MonthlyLimit    float64    `json:"monthly_limit"`
+	NotifiedPercent int        `json:"-"` // the highest utilization the user was notified of in NotifiedMonth
+	NotifiedMonth   *time.Time `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// BudgetProgress is a budget with the spending of the current month
+type BudgetProgress struct {
+	Budget
+	MonthStart  time.Time `json:"month_start"`
+	Spent       float64   `json:"spent"`
+	Remaining   float64   `json:"remaining"`   // zero once the limit is exceeded
+	Utilization float64   `json:"utilization"` // spent as a percentage of the limit
+}
+
+// BudgetRequest represents a user setting the monthly limit of a category
+type BudgetRequest struct {
+	Category     string  `json:"category" validate:"required,oneof=card_payments cash transfers mobile utilities internet tv bills loans fees"`
+	MonthlyLimit float64 `json:"monthly_limit" validate:"required,gt=0"`
+}
+
+// UpdateBudgetRequest represents a change of the monthly limit of a budget
+type UpdateBudgetRequest struct {
+	MonthlyLimit float64 `json:"monthly_limit" validate:"required,gt=0"`
+}
+
+// SpendingTotal sums the outgoing transactions of a user of one type, biller
+// category and currency
+type SpendingTotal struct {
+	Type           string
+	BillerCategory string // of the biller a bill payment was made to
+	Currency       string
+	Amount         float64
+}
@@ -5,32 +5,82 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Card types. Virtual cards are issued at once, without a plastic card, and may
+// be single-use or locked to one merchant.
+const (
+	CardTypeDebit   = "debit"
+	CardTypeCredit  = "credit"
+	CardTypeVirtual = "virtual"
 )
 
 const (
 	CardStatusActive  = "active"
 	CardStatusBlocked = "blocked"
+	CardStatusExpired = "expired" // past the expiry date; final, a replacement is reissued instead
 )
 
 // Card represents a bank card
 type Card struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id" validate:"required"`
-	AccountID  int64     `json:"account_id" validate:"required"`
-	CardNumber string    `json:"card_number" validate:"required,len=16"`
-	ExpiryDate string    `json:"expiry_date" validate:"required,len=5"`
-	CVV        string    `json:"-"` // Never exposed in JSON
-	CardType   string    `json:"card_type" validate:"required,oneof=debit credit"`
-	Status     string    `json:"status" validate:"required,oneof=active blocked"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id" validate:"required"`
+	AccountID  int64  `json:"account_id" validate:"required"`
+	CardNumber string `json:"card_number" validate:"required,len=16"`
+	ExpiryDate string `json:"expiry_date" validate:"required,len=5"`
+	CVV        string `json:"-"` // plain CVV, only known while the card is being issued
+	CVVHash    string `json:"-"` // bcrypt hash of the CVV, the only form it is stored in
+	CardType   string `json:"card_type" validate:"required,oneof=debit credit virtual"`
+	Status     string `json:"status" validate:"required,oneof=active blocked expired"`
+	CardLimitProfile
+	SingleUse      bool      `json:"single_use"`                 // blocked after its first payment
+	MerchantLock   string    `json:"merchant_lock,omitempty"`    // the only merchant the card pays
+	ReplacesCardID *int64    `json:"replaces_card_id,omitempty"` // the card this one was reissued for
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// HashCVV stores the bcrypt hash of the plain CVV
+func (c *Card) HashCVV() error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(c.CVV), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	c.CVVHash = string(hash)
+	return nil
+}
+
+// VerifyCVV reports whether the CVV matches the stored hash
+func (c *Card) VerifyCVV(cvv string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(c.CVVHash), []byte(cvv)) == nil
+}
+
+// ExpiresAt returns the moment the card stops being valid, the start of the month
+// after its MM/YY expiry date
+func (c *Card) ExpiresAt() time.Time {
+	month, err := time.ParseInLocation("01/06", c.ExpiryDate, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return month.AddDate(0, 1, 0)
+}
+
+// Expired reports whether the card is past its expiry date at the given time
+func (c *Card) Expired(now time.Time) bool {
+	return c.Status == CardStatusExpired || !now.Before(c.ExpiresAt())
 }
 
 // CreateCardRequest represents a request to create a new card
 type CreateCardRequest struct {
-	UserID    int64  `json:"user_id" validate:"required"`
-	AccountID int64  `json:"account_id" validate:"required"`
-	CardType  string `json:"card_type" validate:"required,oneof=debit credit"`
+	UserID    int64  `json:"user_id"`                                         // ignored, the owner is the authenticated user
+	AccountID int64  `json:"account_id" validate:"required_without=Currency"` // the primary account in Currency when zero
+	Currency  string `json:"currency" validate:"omitempty,iso4217"`
+	CardType  string `json:"card_type" validate:"required,oneof=debit credit virtual"`
+	CardLimitProfile
+	SingleUse    bool   `json:"single_use"`                                 // virtual cards only
+	MerchantLock string `json:"merchant_lock" validate:"omitempty,max=255"` // virtual cards only
 }
 
 // BlockCardRequest represents a request to block a card
@@ -39,17 +89,30 @@ type BlockCardRequest struct {
 	Reason string `json:"reason" validate:"required"`
 }
 
+// CardPurchaseRequest represents a card payment at a merchant
+type CardPurchaseRequest struct {
+	Amount   float64 `json:"amount" validate:"required,gt=0"`
+	Currency string  `json:"currency" validate:"required,iso4217"`
+	Merchant string  `json:"merchant" validate:"required"`
+}
+
 // CardResponse represents a card response with masked number
 type CardResponse struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id"`
-	AccountID  int64     `json:"account_id"`
-	CardNumber string    `json:"card_number"` // Masked number
-	ExpiryDate string    `json:"expiry_date"`
-	CardType   string    `json:"card_type"`
-	Status     string    `json:"status"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	AccountID  int64  `json:"account_id"`
+	CardNumber string `json:"card_number"` // Masked number
+	ExpiryDate string `json:"expiry_date"`
+	CardType   string `json:"card_type"`
+	Status     string `json:"status"`
+	CardLimitProfile
+	SingleUse      bool      `json:"single_use"`
+	MerchantLock   string    `json:"merchant_lock,omitempty"`
+	PAN            string    `json:"pan,omitempty"` // full number, only in the response that issues a virtual card
+	CVV            string    `json:"cvv,omitempty"` // only in the response that issues a virtual card
+	ReplacesCardID *int64    `json:"replaces_card_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 func (c *Card) GenerateHMAC(secret string) string {
@@ -93,14 +156,30 @@ func (c *Card) MaskNumber() string {
 // ToResponse converts a Card to a CardResponse with masked number
 func (c *Card) ToResponse() *CardResponse {
 	return &CardResponse{
-		ID:         c.ID,
-		UserID:     c.UserID,
-		AccountID:  c.AccountID,
-		CardNumber: c.MaskNumber(),
-		ExpiryDate: c.ExpiryDate,
-		CardType:   c.CardType,
-		Status:     c.Status,
-		CreatedAt:  c.CreatedAt,
-		UpdatedAt:  c.UpdatedAt,
+		ID:               c.ID,
+		UserID:           c.UserID,
+		AccountID:        c.AccountID,
+		CardNumber:       c.MaskNumber(),
+		ExpiryDate:       c.ExpiryDate,
+		CardType:         c.CardType,
+		Status:           c.Status,
+		CardLimitProfile: c.CardLimitProfile,
+		SingleUse:        c.SingleUse,
+		MerchantLock:     c.MerchantLock,
+		ReplacesCardID:   c.ReplacesCardID,
+		CreatedAt:        c.CreatedAt,
+		UpdatedAt:        c.UpdatedAt,
+	}
+}
+
+// ToIssuedResponse converts a newly issued card to its response. A virtual card
+// has no plastic to read the details from, so its full number and CVV are shown
+// in this response and never again.
+func (c *Card) ToIssuedResponse() *CardResponse {
+	response := c.ToResponse()
+	if c.CardType == CardTypeVirtual {
+		response.PAN = c.CardNumber
+		response.CVV = c.CVV
 	}
+	return response
 }
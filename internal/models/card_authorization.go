@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// HomeCountry is the ISO code of the country the bank operates in
+const HomeCountry = "RU"
+
+// Abroad reports whether an ISO country code is known and not the home country
+func Abroad(country string) bool {
+	return country != "" && country != HomeCountry
+}
+
+// CardAuthorizationStatus represents the state of a card payment authorized by the acquirer
+type CardAuthorizationStatus string
+
+const (
+	// CardAuthorizationStatusPending is an approved payment holding its amount on
+	// the account until it is captured, voided or expires
+	CardAuthorizationStatusPending CardAuthorizationStatus = "pending"
+	// CardAuthorizationStatusCaptured is a payment debited from the account
+	CardAuthorizationStatusCaptured CardAuthorizationStatus = "captured"
+	// CardAuthorizationStatusVoided is a payment cancelled by the merchant before capture
+	CardAuthorizationStatusVoided CardAuthorizationStatus = "voided"
+)
+
+// CardAuthorizationRequest represents a card payment the acquirer asks to authorize
+// with the details the cardholder gave the merchant
+type CardAuthorizationRequest struct {
+	CardNumber      string  `json:"card_number" validate:"required,len=16,numeric"`
+	ExpiryDate      string  `json:"expiry_date" validate:"required,len=5"` // MM/YY
+	CVV             string  `json:"cvv" validate:"required,len=3,numeric"`
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+	Currency        string  `json:"currency" validate:"required,iso4217"`
+	Merchant        string  `json:"merchant" validate:"required,max=255"`
+	MerchantCountry string  `json:"merchant_country" validate:"omitempty,iso3166_1_alpha2"` // empty when the acquirer does not report it
+}
+
+// CardAuthorization represents an approved card payment. The amount is in the
+// account currency; Conversion discloses how a foreign currency amount was billed.
+type CardAuthorization struct {
+	ID              int64                   `json:"id"`
+	CardID          int64                   `json:"card_id"`
+	AccountID       int64                   `json:"account_id"`
+	Amount          float64                 `json:"amount"`
+	Currency        string                  `json:"currency"`
+	Conversion      *CurrencyConversion     `json:"conversion,omitempty"`
+	Merchant        string                  `json:"merchant"`
+	MerchantCountry string                  `json:"merchant_country,omitempty"`
+	Status          CardAuthorizationStatus `json:"status"`
+	TransactionID   *int64                  `json:"transaction_id,omitempty"` // the card payment made by the capture
+	ExpiresAt       time.Time               `json:"expires_at"`               // a pending authorization no longer holds funds after it
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+}
+
+// Expired reports whether a pending authorization has lapsed without a capture
+func (a *CardAuthorization) Expired(now time.Time) bool {
+	return a.Status == CardAuthorizationStatusPending && !now.Before(a.ExpiresAt)
+}
@@ -0,0 +1,109 @@
+package models
+
+import "time"
+
+// JobTypeCardBatchIssuance is the job that generates the cards of a batch
+const JobTypeCardBatchIssuance = "card_batch_issuance"
+
+// CardBatchStatus represents the processing state of a card batch
+type CardBatchStatus string
+
+const (
+	CardBatchStatusPending             CardBatchStatus = "pending"
+	CardBatchStatusProcessing          CardBatchStatus = "processing"
+	CardBatchStatusCompleted           CardBatchStatus = "completed"
+	CardBatchStatusCompletedWithErrors CardBatchStatus = "completed_with_errors"
+	CardBatchStatusFailed              CardBatchStatus = "failed"
+)
+
+// CardBatchItemStatus represents the issuance state of a single card in a batch
+type CardBatchItemStatus string
+
+const (
+	CardBatchItemStatusPending CardBatchItemStatus = "pending"
+	CardBatchItemStatusIssued  CardBatchItemStatus = "issued"
+	CardBatchItemStatusFailed  CardBatchItemStatus = "failed"
+)
+
+// CardDeliveryStatus represents where a physical card is on its way to the holder
+type CardDeliveryStatus string
+
+const (
+	CardDeliveryStatusNotDispatched CardDeliveryStatus = "not_dispatched"
+	CardDeliveryStatusDispatched    CardDeliveryStatus = "dispatched"
+	CardDeliveryStatusDelivered     CardDeliveryStatus = "delivered"
+	CardDeliveryStatusReturned      CardDeliveryStatus = "returned"
+)
+
+// MaxCardBatchSize limits the number of employees in a single batch
+const MaxCardBatchSize = 1000
+
+// CardLimitProfile represents spending limits shared by the cards of a batch.
+// Nil limits are not enforced.
+type CardLimitProfile struct {
+	DailyLimit       *float64 `json:"daily_limit,omitempty" validate:"omitempty,gt=0"`
+	MonthlyLimit     *float64 `json:"monthly_limit,omitempty" validate:"omitempty,gt=0"`
+	TransactionLimit *float64 `json:"transaction_limit,omitempty" validate:"omitempty,gt=0"`
+}
+
+// CardBatchEmployee represents an employee to receive a card
+type CardBatchEmployee struct {
+	EmployeeID      string `json:"employee_id"`
+	FullName        string `json:"full_name" validate:"required"`
+	Email           string `json:"email" validate:"omitempty,email"`
+	DeliveryAddress string `json:"delivery_address" validate:"required"`
+}
+
+// CreateCardBatchRequest represents a request to issue cards for a corporate account's employees
+type CreateCardBatchRequest struct {
+	AccountID int64               `json:"account_id" validate:"required"`
+	CardType  string              `json:"card_type" validate:"required,oneof=debit credit"`
+	Limits    CardLimitProfile    `json:"limits"`
+	Employees []CardBatchEmployee `json:"employees" validate:"required,min=1,dive"`
+}
+
+// CardBatch represents a bulk card issuance and its report
+type CardBatch struct {
+	ID          int64            `json:"id"`
+	AccountID   int64            `json:"account_id"`
+	CardType    string           `json:"card_type"`
+	Limits      CardLimitProfile `json:"limits"`
+	Status      CardBatchStatus  `json:"status"`
+	TotalCards  int              `json:"total_cards"`
+	IssuedCards int              `json:"issued_cards"`
+	FailedCards int              `json:"failed_cards"`
+	JobID       *int64           `json:"job_id,omitempty"`
+	RequestedBy int64            `json:"requested_by"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Items       []*CardBatchItem `json:"items,omitempty"`
+}
+
+// CardBatchItem represents the issuance and delivery status of one employee's card
+type CardBatchItem struct {
+	ID              int64               `json:"id"`
+	BatchID         int64               `json:"batch_id"`
+	EmployeeID      string              `json:"employee_id,omitempty"`
+	FullName        string              `json:"full_name"`
+	Email           string              `json:"email,omitempty"`
+	DeliveryAddress string              `json:"delivery_address"`
+	Status          CardBatchItemStatus `json:"status"`
+	CardID          *int64              `json:"card_id,omitempty"`
+	CardNumber      string              `json:"card_number,omitempty"` // Masked number
+	Error           string              `json:"error,omitempty"`
+	DeliveryStatus  CardDeliveryStatus  `json:"delivery_status"`
+	Carrier         string              `json:"carrier,omitempty"`
+	TrackingNumber  string              `json:"tracking_number,omitempty"`
+	DispatchedAt    *time.Time          `json:"dispatched_at,omitempty"`
+	DeliveredAt     *time.Time          `json:"delivered_at,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// UpdateCardDeliveryRequest represents a delivery tracking update for a batch card
+type UpdateCardDeliveryRequest struct {
+	DeliveryStatus CardDeliveryStatus `json:"delivery_status" validate:"required,oneof=not_dispatched dispatched delivered returned"`
+	Carrier        string             `json:"carrier"`
+	TrackingNumber string             `json:"tracking_number"`
+}
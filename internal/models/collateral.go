@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// Types of property a credit can be secured with
+const (
+	CollateralTypeRealEstate = "real_estate"
+	CollateralTypeVehicle    = "vehicle"
+	CollateralTypeDeposit    = "deposit" // money on a deposit with the bank
+	CollateralTypeSecurities = "securities"
+	CollateralTypeEquipment  = "equipment"
+	CollateralTypeOther      = "other"
+)
+
+// CollateralTypes lists the types collateral can be registered with
+var CollateralTypes = []string{
+	CollateralTypeRealEstate, CollateralTypeVehicle, CollateralTypeDeposit, CollateralTypeSecurities,
+	CollateralTypeEquipment, CollateralTypeOther,
+}
+
+// Collateral represents property a borrower pledges to secure a credit. It is
+// registered for the user with its appraised value and secures the credit it is
+// linked to until it is released.
+type Collateral struct {
+	ID             int64                 `json:"id"`
+	UserID         int64                 `json:"user_id"`
+	CreditID       *int64                `json:"credit_id,omitempty"`
+	Type           string                `json:"type"`
+	Description    string                `json:"description"`
+	AppraisedValue float64               `json:"appraised_value"`
+	AppraisedBy    int64                 `json:"appraised_by"` // administrator who recorded the latest valuation
+	AppraisedAt    time.Time             `json:"appraised_at"`
+	Documents      []*CollateralDocument `json:"documents,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// CollateralDocument represents a document of collateral, such as a title deed
+// or an appraisal report. The file is kept in object storage under the storage
+// key.
+type CollateralDocument struct {
+	ID           int64     `json:"id"`
+	CollateralID int64     `json:"collateral_id"`
+	StorageKey   string    `json:"-"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateCollateralRequest represents the registration of collateral of a user,
+// optionally linked to one of their credits right away
+type CreateCollateralRequest struct {
+	UserID         int64   `json:"user_id" validate:"required"`
+	CreditID       *int64  `json:"credit_id"`
+	Type           string  `json:"type" validate:"required"`
+	Description    string  `json:"description" validate:"required,max=2000"`
+	AppraisedValue float64 `json:"appraised_value" validate:"required,gt=0"`
+}
+
+// UpdateCollateralValuationRequest represents a new appraisal of collateral
+type UpdateCollateralValuationRequest struct {
+	AppraisedValue float64 `json:"appraised_value" validate:"required,gt=0"`
+	Comment        string  `json:"comment" validate:"max=2000"`
+}
+
+// LinkCollateralRequest represents pledging collateral for a credit
+type LinkCollateralRequest struct {
+	CreditID int64 `json:"credit_id" validate:"required"`
+}
+
+// CreditCollateral represents the collateral securing a credit and its
+// loan-to-value ratio: the outstanding principal as a percentage of the
+// appraised value of the collateral. The ratio is omitted for an unsecured
+// credit.
+type CreditCollateral struct {
+	CreditID           int64         `json:"credit_id"`
+	RemainingPrincipal float64       `json:"remaining_principal"`
+	CollateralValue    float64       `json:"collateral_value"`
+	LTV                *float64      `json:"ltv,omitempty"`
+	Collateral         []*Collateral `json:"collateral"`
+}
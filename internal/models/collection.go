@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// CollectionCaseStatus represents the state of a collection case
+type CollectionCaseStatus string
+
+const (
+	CollectionCaseStatusOpen     CollectionCaseStatus = "open"     // the credit is in arrears
+	CollectionCaseStatusResolved CollectionCaseStatus = "resolved" // the arrears were paid
+)
+
+// CollectionCase follows a credit from the day its oldest unpaid installment is
+// credit.delinquency_days overdue until the arrears are paid. The stage only
+// escalates while the case is open; each escalation charges a collection fee.
+type CollectionCase struct {
+	ID            int64                `json:"id"`
+	CreditID      int64                `json:"credit_id"`
+	UserID        int64                `json:"user_id"`
+	Stage         CreditStatus         `json:"stage"` // delinquent or collections
+	Status        CollectionCaseStatus `json:"status"`
+	DaysOverdue   int                  `json:"days_overdue"`   // of the oldest unpaid installment, at the last check
+	OverdueAmount float64              `json:"overdue_amount"` // overdue installments with their penalties, at the last check
+	Fees          float64              `json:"fees"`           // collection fees charged so far
+	NoticesSent   int                  `json:"notices_sent"`
+	LastNoticeAt  *time.Time           `json:"last_notice_at,omitempty"`
+	EscalatedAt   *time.Time           `json:"escalated_at,omitempty"` // when the case reached its stage
+	ResolvedAt    *time.Time           `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// CollectionSummary sums up the open collection cases for the collections dashboard
+type CollectionSummary struct {
+	Stages        []*CollectionStageStats `json:"stages"` // per stage and currency
+	ResolvedCases int                     `json:"resolved_cases"`
+	GeneratedAt   time.Time               `json:"generated_at"`
+}
+
+// CollectionStageStats sums up the open cases of a stage in a currency
+type CollectionStageStats struct {
+	Stage              CreditStatus `json:"stage"`
+	Currency           string       `json:"currency"`
+	Cases              int          `json:"cases"`
+	OverdueAmount      float64      `json:"overdue_amount"`
+	Outstanding        float64      `json:"outstanding"` // remaining principal of the credits
+	Fees               float64      `json:"fees"`
+	AverageDaysOverdue float64      `json:"average_days_overdue"`
+}
@@ -2,35 +2,85 @@ package models
 
 import (
 	"math"
+	"strings"
 	"time"
 )
 
 type Credit struct {
-	ID              int64     `json:"id"`
-	UserID          int64     `json:"user_id"`
-	AccountID       int64     `json:"account_id"`
-	Amount          float64   `json:"amount"`
-	RemainingAmount float64   `json:"remaining_amount"`
-	InterestRate    float64   `json:"interest_rate"`
-	TermMonths      int       `json:"term_months"`
-	Status          string    `json:"status"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
-}
-
-// CreateCreditRequest represents a request to create a credit
-type CreateCreditRequest struct {
-	UserID       int64   `json:"user_id" validate:"required"`
-	AccountID    int64   `json:"account_id" validate:"required"`
-	Amount       float64 `json:"amount" validate:"required,gt=0"`
-	TermMonths   int     `json:"term_months" validate:"required,gt=0"`
-	InterestRate float64 `json:"interest_rate" validate:"required,gt=0"`
+	ID              int64        `json:"id"`
+	UserID          int64        `json:"user_id"`
+	AccountID       int64        `json:"account_id"`
+	BranchID        int64        `json:"branch_id"` // branch of the borrower when the credit was issued
+	Amount          float64      `json:"amount"`
+	RemainingAmount float64      `json:"remaining_amount"`
+	InterestRate    float64      `json:"interest_rate"`
+	RateType        RateType     `json:"rate_type"`
+	RateMargin      *float64     `json:"rate_margin,omitempty"` // added to the key rate of a variable-rate credit
+	TermMonths      int          `json:"term_months"`
+	ScheduleType    ScheduleType `json:"schedule_type"`
+	Status          string       `json:"status"`
+	Version         int64        `json:"version"` // incremented by every update of the credit
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	// Cost discloses the full cost of the credit where a single credit is shown
+	Cost *CreditCost `json:"cost,omitempty"`
 }
 
+// RateType represents how the interest rate of a credit is set
+type RateType string
+
+const (
+	// RateTypeFixed keeps the rate of the credit for its whole term
+	RateTypeFixed RateType = "fixed"
+	// RateTypeVariable follows the CBR key rate plus the margin of the credit
+	RateTypeVariable RateType = "variable"
+)
+
+// ScheduleType represents how a credit is repaid
+type ScheduleType string
+
+const (
+	// ScheduleTypeAnnuity repays the credit in equal installments
+	ScheduleTypeAnnuity ScheduleType = "ANNUITY"
+	// ScheduleTypeDifferentiated repays the principal in equal parts with interest
+	// on the declining balance, so installments decrease over the term
+	ScheduleTypeDifferentiated ScheduleType = "DIFFERENTIATED"
+)
+
 type PayCreditRequest struct {
 	Amount float64 `json:"amount" validate:"required,gt=0"`
 }
 
+// EarlyRepaymentMode selects how a partial early repayment reshapes the schedule
+type EarlyRepaymentMode string
+
+const (
+	EarlyRepaymentReduceTerm    EarlyRepaymentMode = "reduce_term"
+	EarlyRepaymentReducePayment EarlyRepaymentMode = "reduce_payment"
+)
+
+// EarlyRepaymentRequest represents a request to repay principal ahead of schedule.
+// An omitted amount repays the credit in full.
+type EarlyRepaymentRequest struct {
+	Amount float64            `json:"amount" validate:"omitempty,gt=0"`
+	Mode   EarlyRepaymentMode `json:"mode" validate:"omitempty,oneof=reduce_term reduce_payment"`
+}
+
+// EarlyRepaymentResult represents the outcome of an early repayment
+type EarlyRepaymentResult struct {
+	CreditID              int64              `json:"credit_id"`
+	PrincipalRepaid       float64            `json:"principal_repaid"`
+	AccruedInterest       float64            `json:"accrued_interest"`
+	Fee                   float64            `json:"fee"`
+	TotalCharged          float64            `json:"total_charged"`
+	RemainingPrincipal    float64            `json:"remaining_principal"`
+	InterestSaved         float64            `json:"interest_saved"`
+	MonthlyPayment        float64            `json:"monthly_payment"`
+	RemainingInstallments int                `json:"remaining_installments"`
+	Status                string             `json:"status"`
+	Schedule              []*PaymentSchedule `json:"schedule"`
+}
+
 // CreditStatus represents the status of a credit
 type CreditStatus string
 
@@ -39,8 +89,26 @@ const (
 	CreditStatusPaid    CreditStatus = "paid"
 	CreditStatusDefault CreditStatus = "default"
 	CreditStatusClosed  CreditStatus = "closed"
+	// CreditStatusDelinquent marks a credit whose oldest unpaid installment is
+	// overdue for credit.delinquency_days
+	CreditStatusDelinquent CreditStatus = "delinquent"
+	// CreditStatusCollections marks a credit handed over to collections after
+	// credit.collections_days overdue
+	CreditStatusCollections CreditStatus = "collections"
 )
 
+// InCollections reports whether the credit is delinquent or in collections
+func (c *Credit) InCollections() bool {
+	return strings.EqualFold(c.Status, string(CreditStatusDelinquent)) ||
+		strings.EqualFold(c.Status, string(CreditStatusCollections))
+}
+
+// IsRepaying reports whether the credit is still being repaid: it is active,
+// delinquent or in collections
+func (c *Credit) IsRepaying() bool {
+	return strings.EqualFold(c.Status, string(CreditStatusActive)) || c.InCollections()
+}
+
 // PaymentStatus represents the status of a payment
 type PaymentStatus string
 
@@ -48,17 +116,86 @@ const (
 	PaymentStatusPending PaymentStatus = "pending"
 	PaymentStatusPaid    PaymentStatus = "paid"
 	PaymentStatusLate    PaymentStatus = "late"
+	// PaymentStatusOverdue marks an installment left unpaid past its due date; it
+	// accrues a daily penalty until it is paid
+	PaymentStatusOverdue PaymentStatus = "overdue"
+	// PaymentStatusArchived marks an installment replaced by a credit restructuring
+	// or by the repricing of a variable-rate credit
+	PaymentStatusArchived PaymentStatus = "archived"
 )
 
 // PaymentSchedule represents a scheduled payment for a credit
 type PaymentSchedule struct {
-	ID        int64         `json:"id"`
-	CreditID  int64         `json:"credit_id"`
-	Amount    float64       `json:"amount"`
-	DueDate   time.Time     `json:"due_date"`
-	Status    PaymentStatus `json:"status"`
-	CreatedAt time.Time     `json:"created_at"`
-	UpdatedAt time.Time     `json:"updated_at"`
+	ID        int64   `json:"id"`
+	CreditID  int64   `json:"credit_id"`
+	Amount    float64 `json:"amount"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	// InsurancePremium is the part of the amount paid for the credit's payment
+	// protection insurance
+	InsurancePremium float64       `json:"insurance_premium,omitempty"`
+	DueDate          time.Time     `json:"due_date"`
+	Status           PaymentStatus `json:"status"`
+	// Penalty accrued on the installment while overdue, up to PenaltyAccruedUntil
+	Penalty             float64    `json:"penalty"`
+	PenaltyAccruedUntil *time.Time `json:"penalty_accrued_until,omitempty"`
+	PaidAmount          float64    `json:"paid_amount"`
+	PaidAt              *time.Time `json:"paid_at,omitempty"`
+	RestructuringID     *int64     `json:"restructuring_id,omitempty"` // set on installments archived by a restructuring
+	RateChangeID        *int64     `json:"rate_change_id,omitempty"`   // set on installments archived by a repricing
+	// Remaining is the part of the installment and its penalty still to be paid
+	Remaining float64   `json:"remaining"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsArchived reports whether the installment was replaced by a restructuring
+// or a repricing
+func (p *PaymentSchedule) IsArchived() bool {
+	return p.RestructuringID != nil || p.RateChangeID != nil
+}
+
+// IsOutstanding reports whether the installment still has to be paid
+func (p *PaymentSchedule) IsOutstanding() bool {
+	return strings.EqualFold(string(p.Status), string(PaymentStatusPending)) ||
+		strings.EqualFold(string(p.Status), string(PaymentStatusOverdue))
+}
+
+// AmountDue returns the part of the installment and the penalty accrued on it that
+// has not been paid yet
+func (p *PaymentSchedule) AmountDue() float64 {
+	return math.Max(roundCents(p.Amount+p.Penalty-p.PaidAmount), 0)
+}
+
+// UnpaidInstallment returns the part of the installment, penalty aside, that has
+// not been paid yet
+func (p *PaymentSchedule) UnpaidInstallment() float64 {
+	return math.Max(roundCents(p.Amount-p.PaidAmount), 0)
+}
+
+// PrincipalPaid returns the part of the paid amount that repaid principal. Payments
+// settle the interest of an installment first, then its principal, its insurance
+// premium and the penalty last, so later penalty accruals never change what has
+// been repaid.
+func (p *PaymentSchedule) PrincipalPaid() float64 {
+	if p.Principal == 0 && p.Interest == 0 {
+		// Installments stored before the split was kept are repaid as principal
+		return math.Min(p.PaidAmount, p.Amount)
+	}
+	return math.Min(math.Max(p.PaidAmount-p.Interest, 0), p.Principal)
+}
+
+// CreditPenaltyAccrual represents the penalty accrued on an overdue installment for one day
+type CreditPenaltyAccrual struct {
+	ID                 int64     `json:"id"`
+	CreditID           int64     `json:"credit_id"`
+	PaymentID          int64     `json:"payment_id"`
+	AccrualDate        time.Time `json:"accrual_date"`
+	OverdueAmount      float64   `json:"overdue_amount"`
+	RatePercent        float64   `json:"rate_percent"` // annual rate in force on the day
+	Amount             float64   `json:"amount"`
+	ParameterVersionID *int64    `json:"parameter_version_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
 }
 
 func CalculateAnnuityPayment(amount float64, annualRate float64, termMonths int) float64 {
@@ -67,23 +204,82 @@ func CalculateAnnuityPayment(amount float64, annualRate float64, termMonths int)
 	return amount * (monthlyRate / denominator)
 }
 
+// GeneratePaymentSchedule generates the monthly installments of a credit with the
+// first one due on startDate
 func GeneratePaymentSchedule(credit *Credit, startDate time.Time) []PaymentSchedule {
-	monthlyPayment := CalculateAnnuityPayment(credit.Amount, credit.InterestRate, credit.TermMonths)
-	remainingPrincipal := credit.Amount
-	schedule := make([]PaymentSchedule, credit.TermMonths)
+	schedule := BuildInstallments(credit.Amount, credit.InterestRate/12/100, credit.TermMonths, credit.ScheduleType)
+	for i := range schedule {
+		schedule[i].CreditID = credit.ID
+		schedule[i].DueDate = startDate.AddDate(0, i, 0)
+		schedule[i].Status = PaymentStatusPending
+		schedule[i].Remaining = schedule[i].Amount
+	}
+	return schedule
+}
 
-	for i := 0; i < credit.TermMonths; i++ {
-		interest := remainingPrincipal * (credit.InterestRate / 12 / 100)
-		principal := monthlyPayment - interest
-		remainingPrincipal -= principal
+// AddInsurancePremium adds the monthly premium of payment protection insurance
+// to the installment
+func (p *PaymentSchedule) AddInsurancePremium(premium float64) {
+	if premium <= 0 {
+		return
+	}
+	p.InsurancePremium = premium
+	p.Amount = roundCents(p.Amount + premium)
+}
 
-		schedule[i] = PaymentSchedule{
-			CreditID: credit.ID,
-			Amount:   monthlyPayment,
-			DueDate:  startDate.AddDate(0, i, 0),
-			Status:   PaymentStatusPending,
+// BuildInstallments splits the repayment of principal into count monthly
+// installments with their principal and interest parts, rounded to cents
+func BuildInstallments(principal, monthlyRate float64, count int, scheduleType ScheduleType) []PaymentSchedule {
+	if count <= 0 {
+		return nil
+	}
+
+	if scheduleType == ScheduleTypeDifferentiated {
+		part := roundCents(principal / float64(count))
+		amounts := make([]float64, count)
+		balance := principal
+		for i := range amounts {
+			interest := roundCents(balance * monthlyRate)
+			amounts[i] = part + interest
+			balance -= part
 		}
+		return SplitInstallments(principal, monthlyRate, amounts)
 	}
 
+	payment := principal / float64(count)
+	if monthlyRate > 0 {
+		payment = principal * monthlyRate / (1 - math.Pow(1+monthlyRate, float64(-count)))
+	}
+	amounts := make([]float64, count)
+	for i := range amounts {
+		amounts[i] = roundCents(payment)
+	}
+	return SplitInstallments(principal, monthlyRate, amounts)
+}
+
+// SplitInstallments breaks installment amounts into interest on the outstanding
+// balance and the principal they repay. The last installment repays whatever
+// principal is left, absorbing rounding differences.
+func SplitInstallments(principal, monthlyRate float64, amounts []float64) []PaymentSchedule {
+	schedule := make([]PaymentSchedule, len(amounts))
+	balance := roundCents(principal)
+	for i, amount := range amounts {
+		interest := roundCents(balance * monthlyRate)
+		repaid := roundCents(amount - interest)
+		if i == len(amounts)-1 || repaid > balance {
+			repaid = balance
+		}
+		balance = roundCents(balance - repaid)
+
+		schedule[i] = PaymentSchedule{
+			Amount:    roundCents(repaid + interest),
+			Principal: repaid,
+			Interest:  interest,
+		}
+	}
 	return schedule
 }
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
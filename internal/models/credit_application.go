@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// JobTypeCreditApplicationScoring is the job that scores a pending credit application
+const JobTypeCreditApplicationScoring = "credit_application_scoring"
+
+// CreditApplicationStatus represents the state of a credit application
+type CreditApplicationStatus string
+
+const (
+	CreditApplicationStatusPending      CreditApplicationStatus = "pending"
+	CreditApplicationStatusApproved     CreditApplicationStatus = "approved"
+	CreditApplicationStatusRejected     CreditApplicationStatus = "rejected"
+	CreditApplicationStatusManualReview CreditApplicationStatus = "manual_review"
+)
+
+// CreditApplication represents a request for a credit that is scored before any funds are disbursed
+type CreditApplication struct {
+	ID                int64                   `json:"id"`
+	UserID            int64                   `json:"user_id"`
+	AccountID         int64                   `json:"account_id"`
+	Amount            float64                 `json:"amount"`
+	TermMonths        int                     `json:"term_months"`
+	InterestRate      float64                 `json:"interest_rate"` // set by the bank when the application is registered
+	RateType          RateType                `json:"rate_type"`
+	RateMargin        *float64                `json:"rate_margin,omitempty"` // over the key rate, for a variable rate
+	ScheduleType      ScheduleType            `json:"schedule_type"`
+	DeclaredIncome    float64                 `json:"declared_income"`
+	Status            CreditApplicationStatus `json:"status"`
+	CreditScore       *int                    `json:"credit_score,omitempty"`      // the applicant's score the rate was priced on
+	RateAdjustment    float64                 `json:"rate_adjustment"`             // added to the rate for the credit score
+	InsurancePremium  *float64                `json:"insurance_premium,omitempty"` // monthly, when payment protection is taken
+	Score             *int                    `json:"score,omitempty"`
+	MonthlyIncome     *float64                `json:"monthly_income,omitempty"`
+	MonthlyPayment    *float64                `json:"monthly_payment,omitempty"`
+	DebtToIncome      *float64                `json:"debt_to_income,omitempty"`
+	DecisionReason    string                  `json:"decision_reason,omitempty"`
+	CreditID          *int64                  `json:"credit_id,omitempty"`
+	ReviewedBy        *int64                  `json:"reviewed_by,omitempty"`
+	ReviewComment     string                  `json:"review_comment,omitempty"`
+	DecidedAt         *time.Time              `json:"decided_at,omitempty"`
+	AppliedParameters []AppliedParameter      `json:"applied_parameters,omitempty"`
+	CreatedAt         time.Time               `json:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+}
+
+// CreateCreditApplicationRequest represents a request to apply for a credit
+type CreateCreditApplicationRequest struct {
+	AccountID      int64        `json:"account_id" validate:"required"`
+	Amount         float64      `json:"amount" validate:"required,gt=0"`
+	TermMonths     int          `json:"term_months" validate:"required,gt=0"`
+	ScheduleType   ScheduleType `json:"schedule_type" validate:"omitempty,oneof=ANNUITY DIFFERENTIATED"`
+	RateType       RateType     `json:"rate_type" validate:"omitempty,oneof=fixed variable"`
+	DeclaredIncome float64      `json:"declared_income" validate:"gte=0"`
+	// Insurance attaches payment protection insurance to the credit
+	Insurance bool `json:"insurance"`
+}
+
+// CreditApplicationDecision represents the scoring outcome of a credit application
+type CreditApplicationDecision struct {
+	Status            CreditApplicationStatus `json:"status"`
+	Score             int                     `json:"score"`
+	MonthlyIncome     float64                 `json:"monthly_income"`
+	MonthlyPayment    float64                 `json:"monthly_payment"`
+	DebtToIncome      float64                 `json:"debt_to_income"`
+	Reason            string                  `json:"reason"`
+	AppliedParameters []AppliedParameter      `json:"applied_parameters"`
+}
+
+// ReviewCreditApplicationRequest represents a manual approval or rejection of an application
+type ReviewCreditApplicationRequest struct {
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// CreditCharges are the payments of a borrower besides the installments
+type CreditCharges struct {
+	// OriginationFee is withheld from the amount when the credit is disbursed
+	OriginationFee float64 `json:"origination_fee" validate:"gte=0"`
+	// InsurancePremium is paid every month together with the installment
+	InsurancePremium float64 `json:"insurance_premium" validate:"gte=0"`
+}
+
+// CreditCost discloses what a credit costs the borrower beyond the amount
+// borrowed, as consumer credit rules require before a credit is taken
+type CreditCost struct {
+	TotalInterest  float64 `json:"total_interest"`
+	OriginationFee float64 `json:"origination_fee"`
+	Insurance      float64 `json:"insurance"` // premiums over the whole term
+	Penalties      float64 `json:"penalties"` // accrued on overdue installments
+	// TotalCost is the interest, the fees, the insurance and the penalties
+	TotalCost float64 `json:"total_cost"`
+	// APR is the effective annual rate, in percent, at which all the payments
+	// of the borrower repay the amount they actually received
+	APR float64 `json:"apr"`
+	// FullCostRate is the full cost of the credit, in percent a year, the
+	// monthly rate of the same payments times twelve as the CBR formula has it
+	FullCostRate float64 `json:"full_cost_rate"`
+}
+
+// cashFlow is a payment of the borrower a number of months, possibly
+// fractional, after the disbursement
+type cashFlow struct {
+	months float64
+	amount float64
+}
+
+// CalculateCreditCost computes the cost of a credit of principal repaid by the
+// installments, the first due a month after disbursement and the others
+// monthly after it, with the charges and the penalties accrued on installments
+// due at the given dates
+func CalculateCreditCost(principal float64, installments []PaymentSchedule, charges CreditCharges, disbursedAt time.Time, penalties []PaymentSchedule) *CreditCost {
+	cost := &CreditCost{OriginationFee: roundCents(charges.OriginationFee)}
+
+	flows := make([]cashFlow, 0, len(installments)+len(penalties))
+	for i, installment := range installments {
+		cost.TotalInterest += installment.Interest
+		cost.Insurance += charges.InsurancePremium
+		flows = append(flows, cashFlow{months: float64(i + 1), amount: installment.Amount + charges.InsurancePremium})
+	}
+	return cost.complete(principal, cost.addPenalties(flows, disbursedAt, penalties))
+}
+
+// CalculateScheduledCreditCost computes the cost of a disbursed credit of
+// principal from its stored schedule: the installments paid and still due, at
+// their due dates with the insurance premium they include, and the penalties
+// accrued on them. Installments archived by a restructuring or a repricing
+// were replaced together with their penalties and are left out.
+func CalculateScheduledCreditCost(principal float64, schedule []*PaymentSchedule, disbursedAt time.Time) *CreditCost {
+	cost := &CreditCost{}
+
+	flows := make([]cashFlow, 0, 2*len(schedule))
+	var current []PaymentSchedule
+	for _, installment := range schedule {
+		if installment.IsArchived() {
+			continue
+		}
+		cost.TotalInterest += installment.Interest
+		cost.Insurance += installment.InsurancePremium
+		flows = append(flows, cashFlow{months: monthsBetween(disbursedAt, installment.DueDate), amount: installment.Amount})
+		current = append(current, *installment)
+	}
+	return cost.complete(principal, cost.addPenalties(flows, disbursedAt, current))
+}
+
+// addPenalties adds the penalties accrued on the installments to the cost and
+// to the flows, at the due dates of the installments
+func (cost *CreditCost) addPenalties(flows []cashFlow, disbursedAt time.Time, installments []PaymentSchedule) []cashFlow {
+	for _, installment := range installments {
+		if installment.Penalty <= 0 {
+			continue
+		}
+		cost.Penalties += installment.Penalty
+		flows = append(flows, cashFlow{months: monthsBetween(disbursedAt, installment.DueDate), amount: installment.Penalty})
+	}
+	return flows
+}
+
+// complete rounds the amounts of the cost, totals them and sets the rates at
+// which the flows repay the principal less the origination fee
+func (cost *CreditCost) complete(principal float64, flows []cashFlow) *CreditCost {
+	cost.TotalInterest = roundCents(cost.TotalInterest)
+	cost.Insurance = roundCents(cost.Insurance)
+	cost.Penalties = roundCents(cost.Penalties)
+	cost.TotalCost = roundCents(cost.TotalInterest + cost.OriginationFee + cost.Insurance + cost.Penalties)
+
+	monthlyRate := internalRate(principal-cost.OriginationFee, flows)
+	cost.APR = math.Round((math.Pow(1+monthlyRate, 12)-1)*100*1000) / 1000
+	cost.FullCostRate = math.Round(monthlyRate*12*100*1000) / 1000
+	return cost
+}
+
+// internalRate finds by bisection the monthly rate at which the payments are
+// worth received today. Payments that do not even repay what was received have
+// no positive rate and yield zero.
+func internalRate(received float64, flows []cashFlow) float64 {
+	presentValue := func(monthlyRate float64) float64 {
+		value := 0.0
+		for _, flow := range flows {
+			value += flow.amount / math.Pow(1+monthlyRate, flow.months)
+		}
+		return value
+	}
+
+	// The present value falls as the rate grows
+	if received <= 0 || len(flows) == 0 || presentValue(0) <= received {
+		return 0
+	}
+	low, high := 0.0, 1.0
+	for presentValue(high) > received {
+		high *= 2
+	}
+	for i := 0; i < 100 && high-low > 1e-12; i++ {
+		mid := (low + high) / 2
+		if presentValue(mid) > received {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// monthsBetween returns the whole calendar months from from to to plus the
+// rest of the days as a fraction of a month
+func monthsBetween(from, to time.Time) float64 {
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if from.AddDate(0, months, 0).After(to) {
+		months--
+	}
+	rest := to.Sub(from.AddDate(0, months, 0)).Hours() / 24
+	return float64(months) + rest/30
+}
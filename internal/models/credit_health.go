@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// CreditHealthGrade represents a band of the internal credit health score
+type CreditHealthGrade string
+
+const (
+	CreditHealthExcellent CreditHealthGrade = "excellent"
+	CreditHealthGood      CreditHealthGrade = "good"
+	CreditHealthFair      CreditHealthGrade = "fair"
+	CreditHealthPoor      CreditHealthGrade = "poor"
+)
+
+// GradeForScore maps a 0-100 score to its grade
+func GradeForScore(score int) CreditHealthGrade {
+	switch {
+	case score >= 80:
+		return CreditHealthExcellent
+	case score >= 65:
+		return CreditHealthGood
+	case score >= 50:
+		return CreditHealthFair
+	default:
+		return CreditHealthPoor
+	}
+}
+
+// CreditHealthFactors represents the inputs the credit health score is built from
+type CreditHealthFactors struct {
+	Punctuality        float64 `json:"punctuality"` // share of due installments paid on time
+	Utilization        float64 `json:"utilization"` // share of borrowed amount still outstanding
+	CreditLoad         float64 `json:"credit_load"` // monthly obligations relative to monthly income
+	OnTimePayments     int     `json:"on_time_payments"`
+	LatePayments       int     `json:"late_payments"`
+	OverduePayments    int     `json:"overdue_payments"`
+	MonthlyObligations float64 `json:"monthly_obligations"`
+	MonthlyIncome      float64 `json:"monthly_income"`
+}
+
+// CreditHealthPoint represents the score at a point in time
+type CreditHealthPoint struct {
+	Date  time.Time `json:"date"`
+	Score int       `json:"score"`
+}
+
+// CreditRecommendation represents an actionable suggestion to improve credit health
+type CreditRecommendation struct {
+	Type           string  `json:"type"`
+	CreditID       int64   `json:"credit_id,omitempty"`
+	Message        string  `json:"message"`
+	Amount         float64 `json:"amount,omitempty"`
+	InterestSaving float64 `json:"interest_saving,omitempty"`
+}
+
+// CreditHealth represents a user's internal credit health assessment
+type CreditHealth struct {
+	Score           int                    `json:"score"`
+	Grade           CreditHealthGrade      `json:"grade"`
+	Factors         CreditHealthFactors    `json:"factors"`
+	Trend           []CreditHealthPoint    `json:"trend"`
+	Recommendations []CreditRecommendation `json:"recommendations"`
+	CalculatedAt    time.Time              `json:"calculated_at"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// CreditInsuranceStatus represents the state of the payment protection insurance
+// of a credit
+type CreditInsuranceStatus string
+
+const (
+	// CreditInsuranceStatusActive covers the credit while its schedule runs
+	CreditInsuranceStatusActive CreditInsuranceStatus = "active"
+	// CreditInsuranceStatusClaim marks insurance with an approved claim in force;
+	// the schedule of the credit is paused until the claim is closed
+	CreditInsuranceStatusClaim CreditInsuranceStatus = "claim"
+)
+
+// CreditInsurance represents payment protection insurance taken with a credit.
+// Its monthly premium is added to every installment.
+type CreditInsurance struct {
+	ID             int64                   `json:"id"`
+	CreditID       int64                   `json:"credit_id"`
+	UserID         int64                   `json:"user_id"`
+	MonthlyPremium float64                 `json:"monthly_premium"`
+	Status         CreditInsuranceStatus   `json:"status"`
+	Claims         []*CreditInsuranceClaim `json:"claims,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+}
+
+// CreditInsuranceClaimEvent represents the insured event a claim is made for
+type CreditInsuranceClaimEvent string
+
+const (
+	CreditInsuranceClaimEventJobLoss    CreditInsuranceClaimEvent = "job_loss"
+	CreditInsuranceClaimEventIllness    CreditInsuranceClaimEvent = "illness"
+	CreditInsuranceClaimEventDisability CreditInsuranceClaimEvent = "disability"
+)
+
+// CreditInsuranceClaimStatus represents the state of an insurance claim
+type CreditInsuranceClaimStatus string
+
+const (
+	CreditInsuranceClaimStatusPending  CreditInsuranceClaimStatus = "pending"
+	CreditInsuranceClaimStatusApproved CreditInsuranceClaimStatus = "approved"
+	CreditInsuranceClaimStatusRejected CreditInsuranceClaimStatus = "rejected"
+	// CreditInsuranceClaimStatusClosed marks an approved claim whose insured event
+	// has ended, so the schedule of the credit resumes
+	CreditInsuranceClaimStatusClosed CreditInsuranceClaimStatus = "closed"
+)
+
+// CreditInsuranceClaim represents a borrower's claim on the insurance of a
+// credit. While an approved claim is in force no installment of the credit
+// becomes overdue or accrues penalties; once it is closed the outstanding
+// installments move forward by the months the schedule was paused.
+type CreditInsuranceClaim struct {
+	ID          int64                      `json:"id"`
+	InsuranceID int64                      `json:"insurance_id"`
+	CreditID    int64                      `json:"credit_id"`
+	UserID      int64                      `json:"user_id"`
+	Event       CreditInsuranceClaimEvent  `json:"event"`
+	Description string                     `json:"description"`
+	Status      CreditInsuranceClaimStatus `json:"status"`
+	PausedAt    *time.Time                 `json:"paused_at,omitempty"`
+	ResumedAt   *time.Time                 `json:"resumed_at,omitempty"`
+	// PausedMonths is how far the outstanding installments moved on closing
+	PausedMonths  *int       `json:"paused_months,omitempty"`
+	ReviewedBy    *int64     `json:"reviewed_by,omitempty"`
+	ReviewComment string     `json:"review_comment,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CreateCreditInsuranceClaimRequest represents a claim on the insurance of a credit
+type CreateCreditInsuranceClaimRequest struct {
+	Event       CreditInsuranceClaimEvent `json:"event" validate:"required,oneof=job_loss illness disability"`
+	Description string                    `json:"description" validate:"required"`
+}
+
+// ReviewCreditInsuranceClaimRequest represents an approval, rejection or closing
+// of an insurance claim
+type ReviewCreditInsuranceClaimRequest struct {
+	Comment string `json:"comment"`
+}
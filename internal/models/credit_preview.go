@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// MaxCreditPreviewTermMonths bounds the term of a credit preview
+const MaxCreditPreviewTermMonths = 360
+
+// CreditPreviewRequest describes a credit to compute the schedule of. Without
+// an interest rate the rate of the rate type is used: the bank's fixed rate,
+// or the key rate plus the bank's margin for a variable rate.
+type CreditPreviewRequest struct {
+	Amount       float64      `json:"amount" validate:"required,gt=0"`
+	TermMonths   int          `json:"term_months" validate:"required,gt=0,lte=360"`
+	InterestRate *float64     `json:"interest_rate" validate:"omitempty,gte=0,lte=100"` // annual, percent
+	RateType     RateType     `json:"rate_type" validate:"omitempty,oneof=fixed variable"`
+	ScheduleType ScheduleType `json:"schedule_type" validate:"omitempty,oneof=ANNUITY DIFFERENTIATED"`
+	CreditCharges
+}
+
+// CreditPreview is the computed repayment of a credit that has not been
+// applied for
+type CreditPreview struct {
+	Amount       float64      `json:"amount"`
+	TermMonths   int          `json:"term_months"`
+	InterestRate float64      `json:"interest_rate"`
+	RateType     RateType     `json:"rate_type"`
+	ScheduleType ScheduleType `json:"schedule_type"`
+	// MonthlyPayment is the first installment, the largest one of a
+	// differentiated schedule
+	MonthlyPayment float64 `json:"monthly_payment"`
+	TotalPayment   float64 `json:"total_payment"` // installments alone
+	CreditCost
+	Schedule []*CreditPreviewInstallment `json:"schedule"`
+}
+
+// CreditPreviewInstallment is one monthly installment of a credit preview
+type CreditPreviewInstallment struct {
+	Number    int       `json:"number"`
+	DueDate   time.Time `json:"due_date"`
+	Amount    float64   `json:"amount"`
+	Principal float64   `json:"principal"`
+	Interest  float64   `json:"interest"`
+	Balance   float64   `json:"balance"` // principal left after the installment
+}
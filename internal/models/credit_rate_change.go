@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// KeyRateRecord is a CBR key rate as the bank first saw it. A new record is
+// kept every time the key rate changes.
+type KeyRateRecord struct {
+	ID         int64     `json:"id"`
+	Rate       float64   `json:"rate"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// CreditRateChange represents the repricing of a variable-rate credit after a
+// change of the key rate. The outstanding installments due from then on are
+// archived and rebuilt at the new rate over the same number of installments.
+type CreditRateChange struct {
+	ID              int64     `json:"id"`
+	CreditID        int64     `json:"credit_id"`
+	KeyRateID       int64     `json:"key_rate_id"`
+	KeyRate         float64   `json:"key_rate"`
+	PreviousRate    float64   `json:"previous_rate"`
+	NewRate         float64   `json:"new_rate"`
+	PreviousPayment float64   `json:"previous_payment"` // first installment rebuilt, before the change
+	NewPayment      float64   `json:"new_payment"`
+	Installments    int       `json:"installments"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// CreditRestructuringStatus represents the state of a credit restructuring request
+type CreditRestructuringStatus string
+
+const (
+	CreditRestructuringStatusPending  CreditRestructuringStatus = "pending"
+	CreditRestructuringStatusApproved CreditRestructuringStatus = "approved"
+	CreditRestructuringStatusRejected CreditRestructuringStatus = "rejected"
+)
+
+// MaxTermExtensionMonths bounds how far one restructuring may extend a credit
+const MaxTermExtensionMonths = 60
+
+// CreditRestructuring represents a borrower's request to extend the term or change
+// the rate of a credit. Once approved the outstanding installments are archived and
+// a new schedule is built from the remaining principal.
+type CreditRestructuring struct {
+	ID                   int64                     `json:"id"`
+	CreditID             int64                     `json:"credit_id"`
+	UserID               int64                     `json:"user_id"`
+	TermExtensionMonths  int                       `json:"term_extension_months"`
+	NewInterestRate      *float64                  `json:"new_interest_rate,omitempty"`
+	Reason               string                    `json:"reason"`
+	Status               CreditRestructuringStatus `json:"status"`
+	PreviousInterestRate float64                   `json:"previous_interest_rate"`
+	// Outcome of an approved restructuring; arrears are included in the principal
+	PreviousInstallments  *int       `json:"previous_installments,omitempty"`
+	RestructuredPrincipal *float64   `json:"restructured_principal,omitempty"`
+	NewInstallments       *int       `json:"new_installments,omitempty"`
+	NewMonthlyPayment     *float64   `json:"new_monthly_payment,omitempty"`
+	ReviewedBy            *int64     `json:"reviewed_by,omitempty"`
+	ReviewComment         string     `json:"review_comment,omitempty"`
+	ReviewedAt            *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// CreateCreditRestructuringRequest represents a request to restructure a credit.
+// At least a term extension or a new interest rate is required.
+type CreateCreditRestructuringRequest struct {
+	TermExtensionMonths int      `json:"term_extension_months" validate:"gte=0"`
+	NewInterestRate     *float64 `json:"new_interest_rate,omitempty" validate:"omitempty,gt=0"`
+	Reason              string   `json:"reason" validate:"required"`
+}
+
+// ReviewCreditRestructuringRequest represents an approval or rejection of a restructuring
+type ReviewCreditRestructuringRequest struct {
+	Comment string `json:"comment"`
+}
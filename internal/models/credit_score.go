@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// CreditRating represents a band of the internal credit score used to price credits
+type CreditRating string
+
+const (
+	CreditRatingA CreditRating = "A"
+	CreditRatingB CreditRating = "B"
+	CreditRatingC CreditRating = "C"
+	CreditRatingD CreditRating = "D"
+	CreditRatingE CreditRating = "E"
+)
+
+// RatingForScore maps a 0-100 credit score to its rating
+func RatingForScore(score int) CreditRating {
+	switch {
+	case score >= 85:
+		return CreditRatingA
+	case score >= 70:
+		return CreditRatingB
+	case score >= 55:
+		return CreditRatingC
+	case score >= 40:
+		return CreditRatingD
+	default:
+		return CreditRatingE
+	}
+}
+
+// CreditScoreReason represents why a credit score was calculated
+type CreditScoreReason string
+
+const (
+	CreditScoreReasonRequested         CreditScoreReason = "requested"
+	CreditScoreReasonCreditApplication CreditScoreReason = "credit_application"
+)
+
+// CreditScore represents the user's internal credit score at a point in time
+type CreditScore struct {
+	ID           int64               `json:"id"`
+	UserID       int64               `json:"user_id"`
+	Score        int                 `json:"score"`
+	Rating       CreditRating        `json:"rating"`
+	Factors      CreditHealthFactors `json:"factors"`
+	Reason       CreditScoreReason   `json:"reason"`
+	CalculatedAt time.Time           `json:"calculated_at"`
+}
+
+// CreditScoreReport represents the user's current credit score and how it has changed
+type CreditScoreReport struct {
+	CreditScore
+	History []*CreditScore `json:"history"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// AdminDashboard is the overview of the operation of the service shown on the
+// admin dashboard
+type AdminDashboard struct {
+	Health            *HealthStatus         `json:"health"`
+	FailedPayments    []*FailedPayment      `json:"failed_payments"`
+	NotificationQueue []*OutboxQueueDepth   `json:"notification_queue"`
+	Reconciliation    *ReconciliationReport `json:"reconciliation,omitempty"` // the latest run, absent before the first one
+	GeneratedAt       time.Time             `json:"generated_at"`
+}
+
+// HealthStatus reports the database and the background processes of the service
+type HealthStatus struct {
+	Ready         bool               `json:"ready"`
+	DatabaseError string             `json:"database_error,omitempty"`
+	Heartbeats    []*HeartbeatStatus `json:"heartbeats"`
+}
+
+// HeartbeatStatus reports when a background process last completed a run
+type HeartbeatStatus struct {
+	Name     string    `json:"name"`
+	LastBeat time.Time `json:"last_beat"`
+	MaxAge   string    `json:"max_age"` // the longest expected interval between runs
+	Stalled  bool      `json:"stalled"`
+}
+
+// FailedPaymentKind tells what kind of payment failed
+type FailedPaymentKind string
+
+const (
+	// FailedPaymentBill is a bill payment the provider failed, refunded to the account
+	FailedPaymentBill FailedPaymentKind = "bill_payment"
+	// FailedPaymentExternalTransfer is a transfer to another bank returned before it settled
+	FailedPaymentExternalTransfer FailedPaymentKind = "external_transfer"
+	// FailedPaymentCreditInstallment is a credit installment the scheduler could
+	// not collect by its due date
+	FailedPaymentCreditInstallment FailedPaymentKind = "credit_installment"
+)
+
+// FailedPayment is a payment that did not go through
+type FailedPayment struct {
+	Kind     FailedPaymentKind `json:"kind"`
+	ID       int64             `json:"id"`
+	UserID   int64             `json:"user_id"`
+	Amount   float64           `json:"amount"`
+	Currency string            `json:"currency"`
+	Reason   string            `json:"reason,omitempty"`
+	FailedAt time.Time         `json:"failed_at"`
+}
+
+// OutboxQueueDepth counts the messages of a topic waiting in the outbox
+type OutboxQueueDepth struct {
+	Topic      string `json:"topic"`
+	Pending    int    `json:"pending"`    // not attempted yet
+	Retrying   int    `json:"retrying"`   // waiting for a retry after a failed attempt
+	Publishing int    `json:"publishing"` // claimed by a relay
+	// OldestAt is when the oldest waiting message was written
+	OldestAt time.Time `json:"oldest_at"`
+}
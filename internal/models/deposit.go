@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// DepositRateType represents how the rate of a term deposit is set
+type DepositRateType string
+
+const (
+	// DepositRateFixed is the bank's rate of term deposits
+	DepositRateFixed DepositRateType = "fixed"
+	// DepositRateKeyRate is the CBR key rate plus the bank's margin, fixed for
+	// the term when the deposit is opened
+	DepositRateKeyRate DepositRateType = "key_rate"
+)
+
+// DepositStatus represents the state of a term deposit
+type DepositStatus string
+
+const (
+	// DepositStatusActive is a deposit accruing interest until it matures
+	DepositStatusActive DepositStatus = "active"
+	// DepositStatusMatured is a deposit paid out with its interest at maturity
+	DepositStatusMatured DepositStatus = "matured"
+	// DepositStatusWithdrawn is a deposit paid out early at the early withdrawal rate
+	DepositStatusWithdrawn DepositStatus = "withdrawn"
+)
+
+// Deposit represents a term deposit. The amount is taken from the account when
+// the deposit is opened and paid back to it with the interest at maturity.
+// Interest accrues daily on the amount and is not capitalised.
+type Deposit struct {
+	ID                    int64           `json:"id"`
+	UserID                int64           `json:"user_id"`
+	AccountID             int64           `json:"account_id"`
+	Amount                float64         `json:"amount"`
+	Currency              string          `json:"currency"`
+	TermMonths            int             `json:"term_months"`
+	RateType              DepositRateType `json:"rate_type"`
+	InterestRate          float64         `json:"interest_rate"`
+	KeyRate               *float64        `json:"key_rate,omitempty"` // the key rate the rate was set from
+	AccruedInterest       float64         `json:"accrued_interest"`
+	InterestAccruedAt     time.Time       `json:"interest_accrued_at"`
+	Status                DepositStatus   `json:"status"`
+	OpenedAt              time.Time       `json:"opened_at"`
+	MaturesAt             time.Time       `json:"matures_at"`
+	ClosedAt              *time.Time      `json:"closed_at,omitempty"`
+	PaidInterest          float64         `json:"paid_interest"`
+	Penalty               float64         `json:"penalty"` // interest forfeited by an early withdrawal
+	OpeningTransactionID  int64           `json:"opening_transaction_id"`
+	ReturnTransactionID   *int64          `json:"return_transaction_id,omitempty"`
+	InterestTransactionID *int64          `json:"interest_transaction_id,omitempty"`
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+}
+
+// CreateDepositRequest represents a user opening a term deposit
+type CreateDepositRequest struct {
+	AccountID  int64           `json:"account_id" validate:"required"`
+	Amount     float64         `json:"amount" validate:"required,gt=0"`
+	TermMonths int             `json:"term_months" validate:"required,gte=1,lte=60"`
+	RateType   DepositRateType `json:"rate_type" validate:"omitempty,oneof=fixed key_rate"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// DisputeStatus represents where a dispute is in its review
+type DisputeStatus string
+
+const (
+	DisputeStatusOpen        DisputeStatus = "open"
+	DisputeStatusUnderReview DisputeStatus = "under_review" // taken by a reviewer, the amount is credited provisionally
+	DisputeStatusResolved    DisputeStatus = "resolved"     // settled otherwise, e.g. refunded by the merchant; the credit is reversed
+	DisputeStatusRefunded    DisputeStatus = "refunded"     // upheld, the provisional credit becomes final
+	DisputeStatusDeclined    DisputeStatus = "declined"     // turned down, the credit is reversed
+)
+
+// Reasons a transaction can be disputed for
+const (
+	DisputeReasonUnauthorized    = "unauthorized"     // the user did not make the payment
+	DisputeReasonNotReceived     = "not_received"     // goods or services were not delivered
+	DisputeReasonNotAsDescribed  = "not_as_described" // goods or services differ from what was paid for
+	DisputeReasonDuplicate       = "duplicate"        // the payment was charged more than once
+	DisputeReasonIncorrectAmount = "incorrect_amount"
+	DisputeReasonCancelled       = "cancelled" // a cancelled order or subscription was still charged
+	DisputeReasonOther           = "other"
+)
+
+// DisputeReasons lists the reasons a dispute can be opened with
+var DisputeReasons = []string{
+	DisputeReasonUnauthorized, DisputeReasonNotReceived, DisputeReasonNotAsDescribed, DisputeReasonDuplicate,
+	DisputeReasonIncorrectAmount, DisputeReasonCancelled, DisputeReasonOther,
+}
+
+// DisputableTransactionTypes lists the types of the transactions that can be
+// disputed, all of them debits of a customer account
+var DisputableTransactionTypes = []string{
+	TransactionTypeTransfer, TransactionTypeWithdrawal, TransactionTypeCardPayment,
+	TransactionTypeOverdraftInterest, TransactionTypeExternalTransfer, TransactionTypeBillPayment,
+	TransactionTypeDormancyFee,
+}
+
+// Dispute represents a user contesting a debit of an account. The amount is in
+// the currency of the account and may be part of the transaction amount.
+type Dispute struct {
+	ID                       int64                `json:"id"`
+	TransactionID            int64                `json:"transaction_id"`
+	AccountID                int64                `json:"account_id"`
+	UserID                   int64                `json:"user_id"`
+	Reason                   string               `json:"reason"`
+	Description              string               `json:"description,omitempty"`
+	Amount                   float64              `json:"amount"`
+	Currency                 string               `json:"currency"`
+	Status                   DisputeStatus        `json:"status"`
+	ReviewerID               *int64               `json:"reviewer_id,omitempty"`
+	ReviewComment            string               `json:"review_comment,omitempty"`
+	ProvisionalTransactionID *int64               `json:"provisional_transaction_id,omitempty"` // provisional credit of the amount
+	ReversalTransactionID    *int64               `json:"reversal_transaction_id,omitempty"`    // reversal of the provisional credit
+	ReviewedAt               *time.Time           `json:"reviewed_at,omitempty"`
+	Attachments              []*DisputeAttachment `json:"attachments,omitempty"`
+	CreatedAt                time.Time            `json:"created_at"`
+	UpdatedAt                time.Time            `json:"updated_at"`
+}
+
+// DisputeAttachment represents a file supporting a dispute, such as a receipt or
+// correspondence with the merchant. The file is kept in object storage under the
+// storage key.
+type DisputeAttachment struct {
+	ID          int64     `json:"id"`
+	DisputeID   int64     `json:"dispute_id"`
+	StorageKey  string    `json:"-"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DisputeFile is a file uploaded with a dispute, before it is stored
+type DisputeFile struct {
+	FileName string
+	Data     []byte
+}
+
+// CreateDisputeRequest represents a user contesting a transaction. The amount
+// defaults to the whole transaction amount.
+type CreateDisputeRequest struct {
+	Reason      string        `json:"reason" validate:"required"`
+	Description string        `json:"description" validate:"max=2000"`
+	Amount      float64       `json:"amount" validate:"gte=0"`
+	Files       []DisputeFile `json:"-"`
+}
+
+// ReviewDisputeRequest represents the outcome of the review of a dispute. The
+// comment is shown to the user.
+type ReviewDisputeRequest struct {
+	Comment string `json:"comment" validate:"max=2000"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// entityTag hashes the values a representation is built from into a strong
+// ETag. Times are taken in nanoseconds, so that the location and the monotonic
+// clock reading of a time.Time do not change the tag.
+func entityTag(parts ...interface{}) string {
+	h := sha256.New()
+	for _, part := range parts {
+		if t, ok := part.(time.Time); ok {
+			part = t.UnixNano()
+		}
+		fmt.Fprintf(h, "%v|", part)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// ETag returns the entity tag of the account. The held amount is summed from
+// the holds and changes without an update of the account.
+func (a *Account) ETag() string {
+	return entityTag("account", a.ID, a.Version, a.HeldAmount)
+}
+
+// ETag returns the entity tag of the card
+func (c *Card) ETag() string {
+	return entityTag("card", c.ID, c.Status, c.UpdatedAt)
+}
+
+// ETag returns the entity tag of the credit. The penalties of its cost accrue
+// on the installments without an update of the credit.
+func (c *Credit) ETag() string {
+	var penalties float64
+	if c.Cost != nil {
+		penalties = c.Cost.Penalties
+	}
+	return entityTag("credit", c.ID, c.Version, penalties)
+}
+
+// PaymentScheduleETag returns the entity tag of a page of a payment schedule
+// with the total number of its installments
+func PaymentScheduleETag(schedule []*PaymentSchedule, total int) string {
+	parts := []interface{}{"schedule", total}
+	for _, payment := range schedule {
+		parts = append(parts, payment.ID, payment.Status, payment.UpdatedAt)
+	}
+	return entityTag(parts...)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExternalTransferStatus represents the state of a transfer to another bank
+type ExternalTransferStatus string
+
+const (
+	// ExternalTransferStatusPending is a transfer accepted and held on the account but not yet sent
+	ExternalTransferStatusPending ExternalTransferStatus = "pending"
+	// ExternalTransferStatusProcessing is a transfer submitted to the payment rail
+	ExternalTransferStatusProcessing ExternalTransferStatus = "processing"
+	// ExternalTransferStatusSettled is a transfer credited by the beneficiary bank and debited from the account
+	ExternalTransferStatusSettled ExternalTransferStatus = "settled"
+	// ExternalTransferStatusReturned is a transfer sent back before it settled, releasing its hold
+	ExternalTransferStatusReturned ExternalTransferStatus = "returned"
+)
+
+// WebhookEventExternalTransferStatusChanged is published on every status change
+// of a transfer to another bank
+const WebhookEventExternalTransferStatusChanged = "external_transfer.status_changed"
+
+// ExternalTransfer represents an outbound transfer to an account at another bank
+type ExternalTransfer struct {
+	ID                int64                  `json:"id"`
+	UserID            int64                  `json:"user_id"`
+	AccountID         int64                  `json:"account_id"`
+	TransactionID     *int64                 `json:"transaction_id,omitempty"` // the debit, made when the transfer settles
+	BeneficiaryName   string                 `json:"beneficiary_name"`
+	IBAN              string                 `json:"iban,omitempty"`
+	AccountNumber     string                 `json:"account_number,omitempty"`
+	BIC               string                 `json:"bic"`
+	Amount            float64                `json:"amount"`
+	Currency          string                 `json:"currency"`
+	Reference         string                 `json:"reference,omitempty"`
+	Status            ExternalTransferStatus `json:"status"`
+	ReturnReason      string                 `json:"return_reason,omitempty"`
+	ReturnTransaction *int64                 `json:"return_transaction_id,omitempty"`
+	SubmittedAt       *time.Time             `json:"submitted_at,omitempty"`
+	CompletedAt       *time.Time             `json:"completed_at,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+}
+
+// CreateExternalTransferRequest represents a transfer to an account at another
+// bank, given by IBAN or by a domestic account number
+type CreateExternalTransferRequest struct {
+	AccountID       int64   `json:"account_id" validate:"required"`
+	BeneficiaryName string  `json:"beneficiary_name" validate:"required,max=140"`
+	IBAN            string  `json:"iban" validate:"required_without=AccountNumber,excluded_with=AccountNumber"`
+	AccountNumber   string  `json:"account_number" validate:"omitempty,numeric,min=5,max=34"`
+	BIC             string  `json:"bic" validate:"required,bic"`
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+	Reference       string  `json:"reference" validate:"omitempty,max=140"`
+}
+
+// ReturnExternalTransferRequest represents a return of a transfer reported by the payment rail
+type ReturnExternalTransferRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// NormalizeIBAN removes the spaces an IBAN is usually printed with and upper-cases it
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(iban), " ", ""))
+}
+
+// ValidIBAN reports whether a normalized IBAN has a valid structure and ISO 13616
+// check digits
+func ValidIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	for i, c := range iban {
+		switch {
+		case i < 2 && (c < 'A' || c > 'Z'):
+			return false
+		case i >= 2 && i < 4 && (c < '0' || c > '9'):
+			return false
+		case (c < 'A' || c > 'Z') && (c < '0' || c > '9'):
+			return false
+		}
+	}
+
+	// Move the country code and check digits to the end, replace letters by
+	// numbers (A = 10 ... Z = 35) and the remainder mod 97 must be 1
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// CashFlow is a posted transaction moving money into or out of the accounts of
+// a user, as the cash-flow forecast and the transaction reports see it.
+// Transfers between the user's own accounts are not cash flows.
+type CashFlow struct {
+	Type           string
+	Incoming       bool
+	Counterparty   string // the payee, biller or merchant the recurring payments are grouped by
+	BillerCategory string // of the biller a bill payment was made to
+	Amount         float64
+	Currency       string
+	CreatedAt      time.Time
+}
+
+// RecurringPayment is an outgoing payment made to the same counterparty in most
+// months of the history, expected to be made again every month
+type RecurringPayment struct {
+	Counterparty string  `json:"counterparty"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"` // monthly, in RUB
+	Months       int     `json:"months"` // of the history it was paid in
+}
+
+// ForecastPeriod is a month of the forecast, starting from the day of the
+// forecast, with the projected balance at its end. Amounts are in RUB.
+type ForecastPeriod struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	Income             float64   `json:"income"`
+	RecurringPayments  float64   `json:"recurring_payments"`
+	CreditInstallments float64   `json:"credit_installments"`
+	OtherExpenses      float64   `json:"other_expenses"`
+	NetChange          float64   `json:"net_change"`
+	ProjectedBalance   float64   `json:"projected_balance"`
+}
+
+// CashFlowForecast projects the balance of the accounts of a user for the next
+// months from the average monthly income and spending of the history, the
+// recurring payments found in it and the scheduled credit installments
+type CashFlowForecast struct {
+	Currency         string              `json:"currency"`
+	Balance          float64             `json:"balance"` // of all the user's accounts now
+	HistoryMonths    int                 `json:"history_months"`
+	AverageIncome    float64             `json:"average_income"`
+	AverageExpenses  float64             `json:"average_expenses"` // other than recurring payments and credit installments
+	Recurring        []*RecurringPayment `json:"recurring"`
+	Periods          []*ForecastPeriod   `json:"periods"`
+	ProjectedBalance float64             `json:"projected_balance"` // at the end of the last period
+	GeneratedAt      time.Time           `json:"generated_at"`
+}
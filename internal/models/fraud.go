@@ -0,0 +1,112 @@
+package models
+
+import "time"
+
+// FraudDecision represents what the fraud rules decided about a transaction,
+// ordered from the least to the most severe
+type FraudDecision string
+
+const (
+	FraudDecisionAllow  FraudDecision = "allow"
+	FraudDecisionFlag   FraudDecision = "flag"
+	FraudDecisionHold   FraudDecision = "hold"
+	FraudDecisionReject FraudDecision = "reject"
+)
+
+// Severity ranks the decision so the most severe of several rule hits wins
+func (d FraudDecision) Severity() int {
+	switch d {
+	case FraudDecisionFlag:
+		return 1
+	case FraudDecisionHold:
+		return 2
+	case FraudDecisionReject:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// FraudCheckStatus represents the status of the transaction a fraud check was made for
+type FraudCheckStatus string
+
+const (
+	FraudCheckStatusCompleted FraudCheckStatus = "completed" // allowed or flagged and executed
+	FraudCheckStatusHeld      FraudCheckStatus = "held"      // waiting for an administrator
+	FraudCheckStatusReleased  FraudCheckStatus = "released"  // approved by an administrator and executed
+	FraudCheckStatusRejected  FraudCheckStatus = "rejected"  // rejected by a rule or an administrator
+)
+
+// ClientInfo is the request metadata the fraud rules look at: the client address
+// and ISO country code, empty when unknown
+type ClientInfo struct {
+	IP      string
+	Country string
+}
+
+// FraudOperation describes an outgoing transaction to be checked by the fraud rules
+type FraudOperation struct {
+	UserID        int64
+	Type          string // TransactionTypeTransfer or TransactionTypeWithdrawal
+	FromAccountID int64
+	ToAccountID   int64 // zero for withdrawals
+	ToUserID      int64 // owner of the destination account, zero for withdrawals
+	Amount        float64
+	Currency      string
+	ClientIP      string
+	ClientCountry string
+}
+
+// FraudRuleHit records a rule triggered by a transaction and the action it asked for
+type FraudRuleHit struct {
+	Rule     string        `json:"rule"`
+	Decision FraudDecision `json:"decision"`
+	Reason   string        `json:"reason"`
+}
+
+// FraudCheck represents the fraud rule evaluation of a transfer or withdrawal
+type FraudCheck struct {
+	ID            int64            `json:"id"`
+	UserID        int64            `json:"user_id"`
+	Type          string           `json:"type"`
+	FromAccountID int64            `json:"from_account_id"`
+	ToAccountID   *int64           `json:"to_account_id,omitempty"`
+	Amount        float64          `json:"amount"`
+	Currency      string           `json:"currency"`
+	ClientIP      string           `json:"client_ip,omitempty"`
+	ClientCountry string           `json:"client_country,omitempty"`
+	Decision      FraudDecision    `json:"decision"`
+	RuleHits      []FraudRuleHit   `json:"rule_hits"`
+	Status        FraudCheckStatus `json:"status"`
+	TransactionID *int64           `json:"transaction_id,omitempty"`
+	ReviewedBy    *int64           `json:"reviewed_by,omitempty"`
+	ReviewComment string           `json:"review_comment,omitempty"`
+	ReviewedAt    *time.Time       `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// OutgoingActivity summarizes the outgoing transfers and withdrawals of a user over a period
+type OutgoingActivity struct {
+	Count   int
+	Total   float64
+	Average float64
+}
+
+// ClientLocation is an address and country a user has transacted from before
+type ClientLocation struct {
+	IP      string
+	Country string
+}
+
+// FraudHoldResponse tells the client its transaction waits for a review
+type FraudHoldResponse struct {
+	CheckID int64            `json:"check_id"`
+	Status  FraudCheckStatus `json:"status"`
+	Message string           `json:"message"`
+}
+
+// ReviewFraudCheckRequest represents a release or rejection of a held transaction
+type ReviewFraudCheckRequest struct {
+	Comment string `json:"comment"`
+}
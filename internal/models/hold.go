@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// HoldSource is the kind of payment a hold reserves funds for
+type HoldSource string
+
+const (
+	HoldSourceCardAuthorization HoldSource = "card_authorization"
+	HoldSourceExternalTransfer  HoldSource = "external_transfer"
+)
+
+// HoldStatus represents the state of a hold
+type HoldStatus string
+
+const (
+	// HoldStatusActive is a hold lowering the available balance of the account
+	HoldStatusActive HoldStatus = "active"
+	// HoldStatusSettled is a hold replaced by the booked payment
+	HoldStatusSettled HoldStatus = "settled"
+	// HoldStatusReleased is a hold cancelled without a payment
+	HoldStatusReleased HoldStatus = "released"
+)
+
+// Hold reserves an amount of an account balance for a payment that is not booked
+// yet. An active hold counts against the available balance until it is settled,
+// released or, when it has one, past its expiry.
+type Hold struct {
+	ID        int64      `json:"id"`
+	AccountID int64      `json:"account_id"`
+	Amount    float64    `json:"amount"`
+	Currency  string     `json:"currency"`
+	Source    HoldSource `json:"source"`
+	SourceID  int64      `json:"source_id"`
+	Status    HoldStatus `json:"status"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
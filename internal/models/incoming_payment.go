@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// IncomingPaymentStatus represents the outcome of a credit advice from the payment rail
+type IncomingPaymentStatus string
+
+const (
+	// IncomingPaymentStatusPosted is a payment credited to the matched account
+	IncomingPaymentStatusPosted IncomingPaymentStatus = "posted"
+	// IncomingPaymentStatusUnmatched is a payment kept aside because no account
+	// in its currency has the given number
+	IncomingPaymentStatusUnmatched IncomingPaymentStatus = "unmatched"
+)
+
+// IncomingPaymentRequest represents a credit advice posted by the payment rail.
+// The reference identifies the payment at the rail, a message sent again with the
+// same reference is not posted twice.
+type IncomingPaymentRequest struct {
+	Reference     string  `json:"reference" validate:"required,max=64"`
+	PayerName     string  `json:"payer_name" validate:"required,max=140"`
+	PayerAccount  string  `json:"payer_account" validate:"omitempty,max=34"`
+	AccountNumber string  `json:"account_number" validate:"required,max=34"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	Currency      string  `json:"currency" validate:"required,iso4217"`
+	Description   string  `json:"description" validate:"omitempty,max=140"`
+}
+
+// IncomingPayment represents a received credit advice and how it was posted
+type IncomingPayment struct {
+	ID            int64                 `json:"id"`
+	Reference     string                `json:"reference"`
+	PayerName     string                `json:"payer_name"`
+	PayerAccount  string                `json:"payer_account,omitempty"`
+	AccountNumber string                `json:"account_number"`
+	AccountID     *int64                `json:"account_id,omitempty"`
+	Amount        float64               `json:"amount"`
+	Currency      string                `json:"currency"`
+	Description   string                `json:"description,omitempty"`
+	Status        IncomingPaymentStatus `json:"status"`
+	Reason        string                `json:"reason,omitempty"`
+	TransactionID *int64                `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+}
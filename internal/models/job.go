@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus represents the lifecycle state of a background job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job represents a unit of asynchronous work picked up by the job runner
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	UserID      *int64          `json:"user_id,omitempty"` // the user who can follow the job, none for system jobs
+	Status      JobStatus       `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Result      *JobResult      `json:"result,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// JobResult records what a job produced. Resource is the API path of what the
+// job created; a stored file is downloaded through a signed URL made when the
+// job is looked up.
+type JobResult struct {
+	Resource   string `json:"resource,omitempty"`
+	StorageKey string `json:"storage_key,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+}
+
+// JobLinks points at a job and at what it produced once it succeeded
+type JobLinks struct {
+	Self     string     `json:"self"`
+	Result   string     `json:"result,omitempty"`
+	Download *SignedURL `json:"download,omitempty"`
+}
+
+// JobReport represents the progress of a long-running operation as reported
+// to the user who started it
+type JobReport struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Status      JobStatus  `json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	Error       string     `json:"error,omitempty"` // of the last failed attempt
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Links       JobLinks   `json:"links"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// KYCStatus represents where the verification of a user's identity is
+type KYCStatus string
+
+const (
+	KYCStatusUnverified KYCStatus = "unverified" // no documents submitted yet
+	KYCStatusPending    KYCStatus = "pending"    // documents submitted, waiting for review
+	KYCStatusVerified   KYCStatus = "verified"
+	KYCStatusRejected   KYCStatus = "rejected" // the user may upload documents and submit again
+)
+
+// Kinds of identity documents. A submission needs one of each.
+const (
+	KYCDocumentPassport = "passport" // scan of the passport page with the photo
+	KYCDocumentSelfie   = "selfie"   // photo of the user holding the passport
+)
+
+// KYCDocumentTypes lists the kinds of documents in the order they are asked for
+var KYCDocumentTypes = []string{KYCDocumentPassport, KYCDocumentSelfie}
+
+// KYCDocument represents an identity document uploaded by a user. The file is
+// kept in object storage under the storage key.
+type KYCDocument struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Type        string    `json:"type"`
+	StorageKey  string    `json:"-"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// KYCVerification represents the verification of a user's identity with the
+// documents uploaded for it, the latest of each type first
+type KYCVerification struct {
+	UserID      int64          `json:"user_id"`
+	Status      KYCStatus      `json:"status"`
+	Comment     string         `json:"comment,omitempty"` // reason of a rejection
+	SubmittedAt *time.Time     `json:"submitted_at,omitempty"`
+	ReviewerID  *int64         `json:"reviewer_id,omitempty"`
+	ReviewedAt  *time.Time     `json:"reviewed_at,omitempty"`
+	Documents   []*KYCDocument `json:"documents,omitempty"`
+}
+
+// ReviewKYCRequest represents the outcome of the review of a user's documents.
+// A rejection needs a comment, which is shown to the user.
+type ReviewKYCRequest struct {
+	Comment string `json:"comment" validate:"max=2000"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LedgerSide is the side of the ledger an entry is posted to
+type LedgerSide string
+
+const (
+	LedgerDebit  LedgerSide = "debit"
+	LedgerCredit LedgerSide = "credit"
+)
+
+// Ledger accounts. Customer accounts are liabilities of the bank: a credit raises
+// their balance and a debit lowers it. The bank's own ledger accounts take the
+// other side of the operations that involve a single customer account.
+const (
+	LedgerAccountCustomer         = "customer" // a customer account, given by AccountID
+	LedgerAccountCash             = "cash"
+	LedgerAccountCardSettlement   = "card_settlement"
+	LedgerAccountLoans            = "loans"
+	LedgerAccountInterestIncome   = "interest_income"
+	LedgerAccountExternalClearing = "external_clearing"
+	LedgerAccountDisputes         = "disputes"         // amounts credited on disputes, claimed back from the counterparty
+	LedgerAccountBillers          = "billers"          // bill payments owed to the billers' providers
+	LedgerAccountTermDeposits     = "term_deposits"    // principal of the term deposits of customers
+	LedgerAccountInterestExpense  = "interest_expense" // interest the bank pays on term deposits
+	LedgerAccountFeeIncome        = "fee_income"       // fees the bank charges for its services
+	LedgerAccountOpeningBalances  = "opening_balances" // balances carried over when the ledger was introduced
+)
+
+// ledgerContraAccounts maps the transaction types with a single customer account
+// to the bank ledger account on their other side
+var ledgerContraAccounts = map[string]string{
+	TransactionTypeDeposit:            LedgerAccountCash,
+	TransactionTypeWithdrawal:         LedgerAccountCash,
+	TransactionTypeCardPayment:        LedgerAccountCardSettlement,
+	TransactionTypeCreditDisbursement: LedgerAccountLoans,
+	TransactionTypeCreditRepayment:    LedgerAccountLoans,
+	TransactionTypeOverdraftInterest:  LedgerAccountInterestIncome,
+	TransactionTypeExternalTransfer:   LedgerAccountExternalClearing,
+	TransactionTypeExternalReturn:     LedgerAccountExternalClearing,
+	TransactionTypeDisputeCredit:      LedgerAccountDisputes,
+	TransactionTypeDisputeReversal:    LedgerAccountDisputes,
+	TransactionTypeBillPayment:        LedgerAccountBillers,
+	TransactionTypeBillRefund:         LedgerAccountBillers,
+	TransactionTypeDepositOpening:     LedgerAccountTermDeposits,
+	TransactionTypeDepositReturn:      LedgerAccountTermDeposits,
+	TransactionTypeDepositInterest:    LedgerAccountInterestExpense,
+	TransactionTypeDormancyFee:        LedgerAccountFeeIncome,
+}
+
+// LedgerEntry is one side of a posted transaction. Every transaction posts a
+// debit and a credit of the same amount.
+type LedgerEntry struct {
+	ID            int64      `json:"id"`
+	TransactionID int64      `json:"transaction_id,omitempty"` // zero for the opening balances
+	AccountID     int64      `json:"account_id,omitempty"`     // zero for the bank's own ledger accounts
+	LedgerAccount string     `json:"ledger_account"`
+	Side          LedgerSide `json:"side"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// BalanceDelta returns how the entry changes the balance of its customer account
+func (e *LedgerEntry) BalanceDelta() float64 {
+	if e.Side == LedgerDebit {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+// LedgerEntries splits a transaction into its debit and credit entries: the
+// account money leaves is debited and the account it arrives at is credited.
+func (t *Transaction) LedgerEntries() ([2]LedgerEntry, error) {
+	debit := LedgerEntry{Side: LedgerDebit, Amount: t.Amount, CreatedAt: t.CreatedAt}
+	credit := LedgerEntry{Side: LedgerCredit, Amount: t.Amount, CreatedAt: t.CreatedAt}
+	if t.Amount <= 0 {
+		return [2]LedgerEntry{}, fmt.Errorf("cannot post a %s transaction of %.2f", t.Type, t.Amount)
+	}
+
+	contra, single := ledgerContraAccounts[t.Type]
+	switch {
+	case t.FromAccountID != 0 && t.ToAccountID != 0 && !single:
+		debit.AccountID, credit.AccountID = t.FromAccountID, t.ToAccountID
+		debit.LedgerAccount, credit.LedgerAccount = LedgerAccountCustomer, LedgerAccountCustomer
+	case t.FromAccountID != 0 && t.ToAccountID == 0 && single:
+		debit.AccountID, debit.LedgerAccount = t.FromAccountID, LedgerAccountCustomer
+		credit.LedgerAccount = contra
+	case t.ToAccountID != 0 && t.FromAccountID == 0 && single:
+		debit.LedgerAccount = contra
+		credit.AccountID, credit.LedgerAccount = t.ToAccountID, LedgerAccountCustomer
+	default:
+		return [2]LedgerEntry{}, fmt.Errorf("cannot post a %s transaction from account %d to account %d", t.Type, t.FromAccountID, t.ToAccountID)
+	}
+
+	return [2]LedgerEntry{debit, credit}, nil
+}
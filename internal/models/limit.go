@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Transaction limit periods. Both are rolling windows ending at the time of the transaction.
+const (
+	LimitPeriodDaily   = "daily"   // the last 24 hours
+	LimitPeriodMonthly = "monthly" // the last 30 days
+)
+
+// Sources of a limit value
+const (
+	LimitSourceDefault = "default"
+	LimitSourceUser    = "user"
+	LimitSourceKYC     = "kyc" // the lower limit of users whose identity is not verified
+)
+
+// UserLimits represents the limits an administrator set for a user. A nil limit
+// keeps the configured default.
+type UserLimits struct {
+	UserID            int64     `json:"user_id"`
+	DailyTransfer     *float64  `json:"daily_transfer"`
+	MonthlyTransfer   *float64  `json:"monthly_transfer"`
+	DailyWithdrawal   *float64  `json:"daily_withdrawal"`
+	MonthlyWithdrawal *float64  `json:"monthly_withdrawal"`
+	Comment           string    `json:"comment,omitempty"`
+	UpdatedBy         int64     `json:"updated_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// SetUserLimitsRequest represents an override of the limits of a user. Omitted or
+// null limits return to the configured default, 0 blocks the transactions.
+type SetUserLimitsRequest struct {
+	DailyTransfer     *float64 `json:"daily_transfer" validate:"omitempty,gte=0"`
+	MonthlyTransfer   *float64 `json:"monthly_transfer" validate:"omitempty,gte=0"`
+	DailyWithdrawal   *float64 `json:"daily_withdrawal" validate:"omitempty,gte=0"`
+	MonthlyWithdrawal *float64 `json:"monthly_withdrawal" validate:"omitempty,gte=0"`
+	Comment           string   `json:"comment"`
+}
+
+// LimitUsage represents one limit of a user and how much of it is used. A nil
+// limit means the transactions are not limited.
+type LimitUsage struct {
+	Type      string   `json:"type"`   // TransactionTypeTransfer or TransactionTypeWithdrawal
+	Period    string   `json:"period"` // LimitPeriodDaily or LimitPeriodMonthly
+	Limit     *float64 `json:"limit"`
+	Used      float64  `json:"used"`
+	Remaining *float64 `json:"remaining"`
+	Source    string   `json:"source"` // LimitSourceDefault, LimitSourceUser or LimitSourceKYC
+}
+
+// UserLimitsResponse represents the limits of a user with the remaining allowance
+type UserLimitsResponse struct {
+	UserID int64        `json:"user_id"`
+	Limits []LimitUsage `json:"limits"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt is a recorded login, successful or not. UserID is zero when the
+// email belongs to no user.
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id,omitempty"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxStatus represents the delivery state of an outbox message
+type OutboxStatus string
+
+const (
+	OutboxStatusPending    OutboxStatus = "pending"
+	OutboxStatusPublishing OutboxStatus = "publishing"
+	OutboxStatusPublished  OutboxStatus = "published"
+)
+
+// Outbox topics, each published by the publisher registered for it with the relay
+const (
+	OutboxTopicEmail   = "notification.email"
+	OutboxTopicSMS     = "notification.sms"
+	OutboxTopicWebhook = "webhook.event"
+	OutboxTopicDomain  = "domain.event"
+)
+
+// OutboxMessage represents a message written together with a business change and
+// published by the outbox relay once that change is committed
+type OutboxMessage struct {
+	ID          int64           `json:"id"`
+	Topic       string          `json:"topic"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      OutboxStatus    `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	AvailableAt time.Time       `json:"available_at"`
+	ClaimedAt   *time.Time      `json:"claimed_at,omitempty"`
+	PublishedAt *time.Time      `json:"published_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
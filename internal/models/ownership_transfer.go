@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// OwnershipTransferReason represents the legal ground for an ownership transfer
+type OwnershipTransferReason string
+
+const (
+	OwnershipTransferReasonInheritance OwnershipTransferReason = "inheritance"
+	OwnershipTransferReasonAssignment  OwnershipTransferReason = "assignment"
+)
+
+// OwnershipTransferStatus represents the status of an ownership transfer
+type OwnershipTransferStatus string
+
+const (
+	OwnershipTransferStatusPending   OwnershipTransferStatus = "pending"
+	OwnershipTransferStatusCompleted OwnershipTransferStatus = "completed"
+	OwnershipTransferStatusRejected  OwnershipTransferStatus = "rejected"
+)
+
+// AccountOwnershipTransfer represents a request to move an account to another user
+type AccountOwnershipTransfer struct {
+	ID                int64                   `json:"id"`
+	AccountID         int64                   `json:"account_id"`
+	FromUserID        int64                   `json:"from_user_id"`
+	ToUserID          int64                   `json:"to_user_id"`
+	Reason            OwnershipTransferReason `json:"reason"`
+	DocumentReference string                  `json:"document_reference"`
+	IncludeCards      bool                    `json:"include_cards"`
+	IncludeCredits    bool                    `json:"include_credits"`
+	Status            OwnershipTransferStatus `json:"status"`
+	RequestedBy       int64                   `json:"requested_by"`
+	ReviewedBy        *int64                  `json:"reviewed_by,omitempty"`
+	ReviewComment     string                  `json:"review_comment,omitempty"`
+	ReviewedAt        *time.Time              `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time               `json:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+}
+
+// CreateOwnershipTransferRequest represents a request to start an ownership transfer
+type CreateOwnershipTransferRequest struct {
+	AccountID         int64                   `json:"account_id" validate:"required"`
+	ToUserID          int64                   `json:"to_user_id" validate:"required"`
+	Reason            OwnershipTransferReason `json:"reason" validate:"required,oneof=inheritance assignment"`
+	DocumentReference string                  `json:"document_reference" validate:"required"`
+	IncludeCards      bool                    `json:"include_cards"`
+	IncludeCredits    bool                    `json:"include_credits"`
+}
+
+// ReviewOwnershipTransferRequest represents an approval or rejection of a transfer
+type ReviewOwnershipTransferRequest struct {
+	Comment string `json:"comment"`
+}
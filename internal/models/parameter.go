@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Business parameter keys
+const (
+	ParamAccountDormancyMonths           = "account.dormancy_months"
+	ParamAccountDormancyFee              = "account.dormancy_fee"
+	ParamCardFXMarkupPercent             = "card.fx_markup_percent"
+	ParamCreditEarlyRepaymentFeePercent  = "credit.early_repayment_fee_percent"
+	ParamCreditInterestRatePercent       = "credit.interest_rate_percent"
+	ParamCreditPenaltyRatePercent        = "credit.penalty_rate_percent"
+	ParamCreditPaymentGraceDays          = "credit.payment_grace_days"
+	ParamCreditDelinquencyDays           = "credit.delinquency_days"
+	ParamCreditCollectionsDays           = "credit.collections_days"
+	ParamCreditCollectionFeePercent      = "credit.collection_fee_percent"
+	ParamCreditCollectionNoticeDays      = "credit.collection_notice_days"
+	ParamCreditVariableRateMarginPercent = "credit.variable_rate_margin_percent"
+	ParamCreditInsuranceRatePercent      = "credit.insurance_rate_percent"
+	ParamDepositInterestRatePercent      = "deposit.interest_rate_percent"
+	ParamDepositKeyRateMarginPercent     = "deposit.key_rate_margin_percent"
+	ParamDepositEarlyWithdrawalRate      = "deposit.early_withdrawal_rate_percent"
+	ParamDepositMinAmount                = "deposit.min_amount"
+	ParamScoringApproveScore             = "scoring.approve_score"
+	ParamScoringRejectScore              = "scoring.reject_score"
+	ParamScoringReviewDebtToIncome       = "scoring.review_debt_to_income"
+	ParamScoringMaxDebtToIncome          = "scoring.max_debt_to_income"
+	ParamScoringRateSpreadPercent        = "scoring.rate_spread_percent"
+)
+
+// BusinessParameter represents one version of an administrator-managed business parameter
+type BusinessParameter struct {
+	ID            int64     `json:"id"`
+	Key           string    `json:"key"`
+	Value         float64   `json:"value"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	Comment       string    `json:"comment,omitempty"`
+	CreatedBy     int64     `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CreateBusinessParameterRequest represents a request to schedule a new parameter version.
+// An omitted effective date makes the version effective immediately.
+type CreateBusinessParameterRequest struct {
+	Value         float64    `json:"value"`
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+	Comment       string     `json:"comment"`
+}
+
+// AppliedParameter records the parameter value used by an operation. A missing
+// version ID means no version was configured and the built-in default applied.
+type AppliedParameter struct {
+	Key       string  `json:"key"`
+	Value     float64 `json:"value"`
+	VersionID *int64  `json:"version_id,omitempty"`
+}
+
+// ParameterValue represents the value of a parameter currently in force
+type ParameterValue struct {
+	Key           string               `json:"key"`
+	Description   string               `json:"description"`
+	Value         float64              `json:"value"`
+	DefaultValue  float64              `json:"default_value"`
+	VersionID     *int64               `json:"version_id,omitempty"`
+	EffectiveFrom *time.Time           `json:"effective_from,omitempty"`
+	Scheduled     []*BusinessParameter `json:"scheduled,omitempty"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// PayeeType represents where a payee is paid to
+type PayeeType string
+
+const (
+	PayeeTypeAccount  PayeeType = "account"  // an account at the bank, by its number
+	PayeeTypeCard     PayeeType = "card"     // a card issued by the bank, paid to its account
+	PayeeTypeExternal PayeeType = "external" // an account at another bank
+)
+
+// Payee represents a recipient a user saved to transfer to again. Until the
+// first transfer to it is confirmed with a code sent to the user, transfers to
+// the payee need the code.
+type Payee struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	Name            string     `json:"name"`
+	Type            PayeeType  `json:"type"`
+	AccountNumber   string     `json:"account_number,omitempty"` // domestic account number, at the bank or elsewhere
+	CardNumber      string     `json:"-"`
+	MaskedCard      string     `json:"card_number,omitempty"`
+	BeneficiaryName string     `json:"beneficiary_name,omitempty"`
+	IBAN            string     `json:"iban,omitempty"`
+	BIC             string     `json:"bic,omitempty"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	CodeHash        string     `json:"-"` // bcrypt hash of the pending confirmation code
+	CodeExpiresAt   *time.Time `json:"-"`
+	CodeAttempts    int        `json:"-"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// SamePaymentDetails reports whether two payees are paid to the same place.
+// Changing the details of a payee makes its next transfer need a code again.
+func (p *Payee) SamePaymentDetails(other *Payee) bool {
+	return p.Type == other.Type && p.AccountNumber == other.AccountNumber && p.CardNumber == other.CardNumber &&
+		p.BeneficiaryName == other.BeneficiaryName && p.IBAN == other.IBAN && p.BIC == other.BIC
+}
+
+// PayeeRequest represents the details of a payee to save. An account payee has
+// an account number, a card payee a card number, and an external payee a
+// beneficiary name, a BIC and an IBAN or a domestic account number.
+type PayeeRequest struct {
+	Name            string    `json:"name" validate:"required,max=100"`
+	Type            PayeeType `json:"type" validate:"required,oneof=account card external"`
+	AccountNumber   string    `json:"account_number" validate:"omitempty,numeric,min=5,max=34"`
+	CardNumber      string    `json:"card_number" validate:"omitempty,len=16,numeric"`
+	BeneficiaryName string    `json:"beneficiary_name" validate:"omitempty,max=140"`
+	IBAN            string    `json:"iban" validate:"omitempty,excluded_with=AccountNumber"`
+	BIC             string    `json:"bic" validate:"omitempty,bic"`
+}
+
+// PayeeTransferRequest represents a transfer to a saved payee. The
+// confirmation code is only needed for the first transfer to the payee.
+type PayeeTransferRequest struct {
+	FromAccountID    int64   `json:"from_account_id" validate:"required"`
+	Amount           float64 `json:"amount" validate:"required,gt=0"`
+	Reference        string  `json:"reference" validate:"omitempty,max=140"` // sent with transfers to other banks
+	ConfirmationCode string  `json:"confirmation_code" validate:"omitempty,len=6,numeric"`
+}
+
+// Outcomes of a transfer to a payee
+const (
+	PayeeTransferStatusCompleted            = "completed"
+	PayeeTransferStatusConfirmationRequired = "confirmation_required" // a code was sent, repeat the transfer with it
+)
+
+// PayeeTransferResult represents the outcome of a transfer to a payee. A
+// transfer to another bank is returned while it is pending.
+type PayeeTransferResult struct {
+	Status           string            `json:"status"`
+	Payee            *Payee            `json:"payee"`
+	ExternalTransfer *ExternalTransfer `json:"external_transfer,omitempty"`
+	CodeExpiresAt    *time.Time        `json:"code_expires_at,omitempty"`
+}
+
+// PayeeUsage represents the transfers made to a payee over a period, in the
+// currency of the accounts they were made from
+type PayeeUsage struct {
+	PayeeID   *int64  `json:"payee_id,omitempty"` // nil once the payee is deleted
+	PayeeName string  `json:"payee_name"`
+	Currency  string  `json:"currency"`
+	Count     int     `json:"count"`
+	Amount    float64 `json:"amount"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// paymentQRHeader starts a payment QR payload of GOST R 56042-2014, the format
+// Russian banks scan: format ST0001 followed by the character encoding, 2 for
+// UTF-8, and the separator of the fields
+const paymentQRHeader = "ST0001"
+
+// paymentQRSeparator separates the fields of the payloads the bank issues
+const paymentQRSeparator = '|'
+
+// PaymentQR represents the payment details a payment QR code carries. Payload
+// is the text to encode in the code; the image is drawn by the client.
+type PaymentQR struct {
+	Payload              string  `json:"payload"`
+	Name                 string  `json:"name"`
+	AccountNumber        string  `json:"account_number"`
+	BankName             string  `json:"bank_name"`
+	BIC                  string  `json:"bic"`
+	CorrespondentAccount string  `json:"correspondent_account,omitempty"`
+	Amount               float64 `json:"amount,omitempty"`
+	Purpose              string  `json:"purpose,omitempty"`
+}
+
+// QRTransferRequest represents a transfer to the payment details of a scanned
+// QR code. Amount is needed only when the code carries none.
+type QRTransferRequest struct {
+	FromAccountID int64   `json:"from_account_id" validate:"required"`
+	Payload       string  `json:"payload" validate:"required,max=2000"`
+	Amount        float64 `json:"amount" validate:"omitempty,gt=0"`
+}
+
+// QRTransferResult represents a transfer made from a QR code. Transfers to
+// another bank are returned while they are pending.
+type QRTransferResult struct {
+	QR               *PaymentQR        `json:"qr"`
+	Amount           float64           `json:"amount"`
+	ExternalTransfer *ExternalTransfer `json:"external_transfer,omitempty"`
+}
+
+// EncodePaymentQR returns the payload of the payment details. The amount is
+// written in kopecks and left out when it is zero.
+func EncodePaymentQR(qr *PaymentQR) string {
+	correspondentAccount := qr.CorrespondentAccount
+	if correspondentAccount == "" {
+		correspondentAccount = "0"
+	}
+
+	fields := []string{
+		paymentQRHeader + "2",
+		"Name=" + paymentQRValue(qr.Name),
+		"PersonalAcc=" + paymentQRValue(qr.AccountNumber),
+		"BankName=" + paymentQRValue(qr.BankName),
+		"BIC=" + paymentQRValue(qr.BIC),
+		"CorrespAcc=" + paymentQRValue(correspondentAccount),
+	}
+	if qr.Amount > 0 {
+		fields = append(fields, "Sum="+strconv.FormatInt(int64(math.Round(qr.Amount*100)), 10))
+	}
+	if qr.Purpose != "" {
+		fields = append(fields, "Purpose="+paymentQRValue(qr.Purpose))
+	}
+	return strings.Join(fields, string(paymentQRSeparator))
+}
+
+// paymentQRValue keeps the separator out of a field value
+func paymentQRValue(value string) string {
+	return strings.TrimSpace(strings.ReplaceAll(value, string(paymentQRSeparator), " "))
+}
+
+// ParsePaymentQR reads the payment details of a payload. The payload may use
+// any separator, as the format allows, but only the UTF-8 encoding; fields the
+// bank does not use are skipped.
+func ParsePaymentQR(payload string) (*PaymentQR, error) {
+	payload = strings.TrimSpace(payload)
+	if !strings.HasPrefix(payload, paymentQRHeader) || len(payload) < len(paymentQRHeader)+2 {
+		return nil, errors.New("invalid QR code")
+	}
+	if payload[len(paymentQRHeader)] != '2' {
+		return nil, errors.New("unsupported QR code encoding")
+	}
+	separator := payload[len(paymentQRHeader)+1 : len(paymentQRHeader)+2]
+
+	qr := &PaymentQR{Payload: payload}
+	for _, field := range strings.Split(payload[len(paymentQRHeader)+2:], separator) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "name":
+			qr.Name = value
+		case "personalacc":
+			qr.AccountNumber = value
+		case "bankname":
+			qr.BankName = value
+		case "bic":
+			qr.BIC = value
+		case "correspacc":
+			if value != "0" {
+				qr.CorrespondentAccount = value
+			}
+		case "sum":
+			kopecks, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || kopecks < 0 {
+				return nil, errors.New("invalid QR code amount")
+			}
+			qr.Amount = float64(kopecks) / 100
+		case "purpose":
+			qr.Purpose = value
+		}
+	}
+
+	if qr.Name == "" || qr.AccountNumber == "" || qr.BIC == "" {
+		return nil, errors.New("QR code lacks the payment details")
+	}
+	if len(qr.AccountNumber) != AccountNumberLength || !isDigits(qr.AccountNumber) || len(qr.BIC) != 9 || !isDigits(qr.BIC) {
+		return nil, errors.New("invalid QR code payment details")
+	}
+	return qr, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// PaymentRequestStatus represents the state of a request for money
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestStatusPending   PaymentRequestStatus = "pending"   // waiting for the payer
+	PaymentRequestStatusHeld      PaymentRequestStatus = "held"      // accepted, the transfer is held by the fraud rules
+	PaymentRequestStatusPaid      PaymentRequestStatus = "paid"      // accepted and transferred
+	PaymentRequestStatusDeclined  PaymentRequestStatus = "declined"  // turned down by the payer, or the held transfer was rejected
+	PaymentRequestStatusCancelled PaymentRequestStatus = "cancelled" // withdrawn by the requester
+	PaymentRequestStatusExpired   PaymentRequestStatus = "expired"   // not answered in time
+)
+
+// PaymentRequest represents a user asking another user for money. The amount
+// is in the currency of the requester's account it is paid to.
+type PaymentRequest struct {
+	ID                 int64                `json:"id"`
+	RequesterID        int64                `json:"requester_id"`
+	RequesterAccountID int64                `json:"requester_account_id"`
+	PayerID            int64                `json:"payer_id"`
+	Amount             float64              `json:"amount"`
+	Currency           string               `json:"currency"`
+	Description        string               `json:"description,omitempty"`
+	Status             PaymentRequestStatus `json:"status"`
+	PayerAccountID     *int64               `json:"payer_account_id,omitempty"`
+	TransactionID      *int64               `json:"transaction_id,omitempty"`
+	FraudCheckID       *int64               `json:"fraud_check_id,omitempty"`
+	DeclineReason      string               `json:"decline_reason,omitempty"`
+	ExpiresAt          time.Time            `json:"expires_at"`
+	RespondedAt        *time.Time           `json:"responded_at,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+// CreatePaymentRequestRequest represents a request for money from the user
+// with the given email or phone number, paid to an account of the requester.
+// Without an expiry the request waits for the configured time.
+type CreatePaymentRequestRequest struct {
+	Email       string     `json:"email" validate:"required_without=Phone,excluded_with=Phone,omitempty,email"`
+	Phone       string     `json:"phone" validate:"omitempty,e164"`
+	AccountID   int64      `json:"account_id" validate:"required"`
+	Amount      float64    `json:"amount" validate:"required,gt=0"`
+	Description string     `json:"description" validate:"max=140"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// AcceptPaymentRequestRequest represents the payer paying a request from one
+// of their accounts
+type AcceptPaymentRequestRequest struct {
+	FromAccountID int64 `json:"from_account_id" validate:"required"`
+}
+
+// DeclinePaymentRequestRequest represents the payer turning a request down
+type DeclinePaymentRequestRequest struct {
+	Reason string `json:"reason" validate:"max=140"`
+}
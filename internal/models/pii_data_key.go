@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PIIDataKey encrypts the personal data of users. The key is stored wrapped by
+// the master key of the configuration; a retired key only decrypts values that
+// have not been encrypted again yet.
+type PIIDataKey struct {
+	ID         int64
+	WrappedKey []byte
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// BaseCurrency is the currency official CBR rates are quoted in
+const BaseCurrency = "RUB"
+
+// CurrencyRate represents the official exchange rate of a currency against the ruble
+type CurrencyRate struct {
+	Code     string  `json:"code"`
+	NumCode  int     `json:"num_code"`
+	Name     string  `json:"name"`
+	Nominal  int     `json:"nominal"`
+	Rate     float64 `json:"rate"`      // rubles per Nominal units
+	UnitRate float64 `json:"unit_rate"` // rubles per single unit
+}
+
+// CurrencyRates represents the set of exchange rates published for a date
+type CurrencyRates struct {
+	Date      time.Time      `json:"date"`
+	Base      string         `json:"base"`
+	Rates     []CurrencyRate `json:"rates"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// KeyRate represents the CBR key rate
+type KeyRate struct {
+	Rate      float64   `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CurrencyConversion discloses how a foreign currency amount was converted
+// into the currency it was billed in
+type CurrencyConversion struct {
+	OriginalAmount   float64 `json:"original_amount"`
+	OriginalCurrency string  `json:"original_currency"`
+	BilledAmount     float64 `json:"billed_amount"`
+	BilledCurrency   string  `json:"billed_currency"`
+	ExchangeRate     float64 `json:"exchange_rate"` // billed currency units per original unit, before markup
+	MarkupPercent    float64 `json:"markup_percent"`
+	MarkupAmount     float64 `json:"markup_amount"`
+}
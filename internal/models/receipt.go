@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Receipt represents the printable confirmation of a transaction. The
+// verification code signs its details, so the bank can confirm that a receipt
+// presented to a third party was issued by it and not altered.
+type Receipt struct {
+	TransactionID    int64               `json:"transaction_id"`
+	Reference        string              `json:"reference"`
+	Type             string              `json:"type"`
+	Description      string              `json:"description,omitempty"`
+	Amount           float64             `json:"amount"`
+	Currency         string              `json:"currency"`
+	Conversion       *CurrencyConversion `json:"conversion,omitempty"`
+	FromAccount      string              `json:"from_account,omitempty"` // masked number
+	ToAccount        string              `json:"to_account,omitempty"`   // masked number
+	CreatedAt        time.Time           `json:"created_at"`
+	Bank             ReceiptBank         `json:"bank"`
+	VerificationCode string              `json:"verification_code"`
+	VerificationURL  string              `json:"verification_url"`
+}
+
+// ReceiptBank represents the details of the bank printed on a receipt
+type ReceiptBank struct {
+	Name                 string `json:"name"`
+	BIC                  string `json:"bic"`
+	TaxID                string `json:"tax_id,omitempty"`
+	CorrespondentAccount string `json:"correspondent_account,omitempty"`
+}
+
+// ReceiptVerification represents the outcome of checking the verification code
+// of a receipt. The details of the transaction are returned for a valid code
+// only, so they can be compared with the receipt.
+type ReceiptVerification struct {
+	Valid       bool       `json:"valid"`
+	Reference   string     `json:"reference,omitempty"`
+	Type        string     `json:"type,omitempty"`
+	Amount      float64    `json:"amount,omitempty"`
+	Currency    string     `json:"currency,omitempty"`
+	FromAccount string     `json:"from_account,omitempty"`
+	ToAccount   string     `json:"to_account,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// transactionReferencePrefix starts the references of transactions on receipts
+const transactionReferencePrefix = "TX"
+
+// TransactionReference returns the reference of a transaction printed on its receipt
+func TransactionReference(id int64) string {
+	return fmt.Sprintf("%s%010d", transactionReferencePrefix, id)
+}
+
+// ParseTransactionReference returns the ID of the transaction a reference
+// names. It reports false for a malformed reference.
+func ParseTransactionReference(reference string) (int64, bool) {
+	digits, ok := strings.CutPrefix(strings.ToUpper(strings.TrimSpace(reference)), transactionReferencePrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
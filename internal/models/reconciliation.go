@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// ReconciliationIssueKind tells which check an account balance failed
+type ReconciliationIssueKind string
+
+const (
+	// ReconciliationIssueLedgerMismatch is a balance different from the sum of the
+	// account's ledger entries
+	ReconciliationIssueLedgerMismatch ReconciliationIssueKind = "ledger_mismatch"
+	// ReconciliationIssueTransactionLogMismatch is a balance different from the one
+	// recomputed from the opening balance and the transactions of the account
+	ReconciliationIssueTransactionLogMismatch ReconciliationIssueKind = "transaction_log_mismatch"
+)
+
+// ReconciliationRun is one nightly snapshot of all account balances
+type ReconciliationRun struct {
+	ID        int64     `json:"id"`
+	RunDate   time.Time `json:"run_date"`
+	Accounts  int       `json:"accounts"` // number of accounts snapshotted
+	Issues    int       `json:"issues"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReconciliationIssue is an account balance that failed a check of a run
+type ReconciliationIssue struct {
+	ID              int64                   `json:"id"`
+	RunID           int64                   `json:"run_id"`
+	AccountID       int64                   `json:"account_id"`
+	Kind            ReconciliationIssueKind `json:"kind"`
+	Currency        string                  `json:"currency"`
+	Balance         float64                 `json:"balance"`
+	ExpectedBalance float64                 `json:"expected_balance"`
+	Difference      float64                 `json:"difference"` // balance less expected balance
+	CreatedAt       time.Time               `json:"created_at"`
+}
+
+// ReconciliationReport is a run with the issues it found
+type ReconciliationReport struct {
+	Run    *ReconciliationRun     `json:"run"`
+	Issues []*ReconciliationIssue `json:"issues"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SandboxEmail represents an email captured instead of sent in sandbox mode
+type SandboxEmail struct {
+	ID          int64     `json:"id"`
+	Recipient   string    `json:"recipient"`
+	Subject     string    `json:"subject"`
+	Content     string    `json:"content"`
+	Attachments []string  `json:"attachments,omitempty"` // file names
+	SentAt      time.Time `json:"sent_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// SearchResultType identifies the kind of entity matched by the admin search
+type SearchResultType string
+
+const (
+	SearchResultUser        SearchResultType = "user"
+	SearchResultAccount     SearchResultType = "account"
+	SearchResultCard        SearchResultType = "card"
+	SearchResultCredit      SearchResultType = "credit"
+	SearchResultTransaction SearchResultType = "transaction"
+)
+
+// MaxSearchResults bounds the number of entities returned by one search
+const MaxSearchResults = 50
+
+// SearchResult represents an entity matched by the admin search. Card numbers are
+// always masked; personal data is redacted for roles without access to it.
+type SearchResult struct {
+	Type       SearchResultType `json:"type"`
+	ID         int64            `json:"id"`
+	UserID     int64            `json:"user_id,omitempty"`
+	Title      string           `json:"title"`
+	Email      string           `json:"email,omitempty"`
+	Phone      string           `json:"phone,omitempty"`
+	CardNumber string           `json:"card_number,omitempty"`
+	Amount     *float64         `json:"amount,omitempty"`
+	Currency   string           `json:"currency,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Redacted   bool             `json:"redacted,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session is a device signed in to the API. Every token is issued for a
+// session and is refused once the session is revoked.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"-"`
+	TokenID    string    `json:"-"` // jti claim of the session's token
+	DeviceName string    `json:"device_name,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Current    bool      `json:"current"` // the session of the request listing the sessions
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// JobTypeMonthlyStatement is the job that emails the monthly statements of a user
+const JobTypeMonthlyStatement = "monthly_statement"
+
+// JobTypeAccountStatement is the job that renders the PDF statement of an
+// account for a period requested by the user
+const JobTypeAccountStatement = "account_statement"
+
+// StatementDeliveryStatus represents the state of a monthly statement email
+type StatementDeliveryStatus string
+
+const (
+	StatementDeliveryStatusQueued StatementDeliveryStatus = "queued"
+	StatementDeliveryStatusSent   StatementDeliveryStatus = "sent"
+)
+
+// StatementDelivery represents the monthly statement email of a user for one month
+type StatementDelivery struct {
+	ID          int64                   `json:"id"`
+	UserID      int64                   `json:"user_id"`
+	PeriodStart time.Time               `json:"period_start"` // first day of the month
+	Status      StatementDeliveryStatus `json:"status"`
+	Accounts    int                     `json:"accounts"` // statements attached to the email
+	SentAt      *time.Time              `json:"sent_at,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+}
+
+// StatementRecipient represents a user who opted in to monthly statements
+type StatementRecipient struct {
+	UserID          int64
+	TimeZone        string
+	LastPeriodStart *time.Time // month of the latest statement email, nil before the first one
+}
+
+// StatementFile represents the PDF statement of an account for one month, kept
+// in object storage under the storage key
+type StatementFile struct {
+	ID          int64     `json:"id"`
+	DeliveryID  int64     `json:"delivery_id"`
+	UserID      int64     `json:"user_id"`
+	AccountID   int64     `json:"account_id"`
+	PeriodStart time.Time `json:"period_start"` // first day of the month
+	StorageKey  string    `json:"-"`
+	FileName    string    `json:"file_name"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
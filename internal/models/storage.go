@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SignedURL represents a temporary link to download a stored file without
+// authenticating
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
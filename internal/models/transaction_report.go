@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Report categories of the transactions that are not spending
+const (
+	ReportCategoryIncome = "income" // money coming in, whatever its type
+	ReportCategoryOther  = "other"  // money leaving that is not spending, such as term deposits opened
+)
+
+// TransactionReport breaks the transactions into and out of the accounts of a
+// user within a period down by category and by month. Amounts are per currency
+// and not converted.
+type TransactionReport struct {
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Categories []*CategoryTotal  `json:"categories"`
+	Months     []*MonthlySummary `json:"months"`
+}
+
+// CategoryTotal sums up the transactions of a category in a currency. Outgoing
+// transactions fall in the spending categories of the budgets.
+type CategoryTotal struct {
+	Category     string  `json:"category"`
+	Currency     string  `json:"currency"`
+	Transactions int     `json:"transactions"`
+	Amount       float64 `json:"amount"`
+}
+
+// MonthlySummary sums up the money coming in and going out in a month and a
+// currency
+type MonthlySummary struct {
+	Month        string  `json:"month"` // YYYY-MM
+	Currency     string  `json:"currency"`
+	Transactions int     `json:"transactions"`
+	Income       float64 `json:"income"`
+	Expenses     float64 `json:"expenses"`
+	Net          float64 `json:"net"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// JobTypeTransferBatch is the job that makes the transfers of a batch
+const JobTypeTransferBatch = "transfer_batch"
+
+// TransferBatchMode represents how the transfers of a batch are made
+type TransferBatchMode string
+
+const (
+	// TransferBatchModeAtomic makes all the transfers in one database transaction,
+	// so the batch is made in full or not at all
+	TransferBatchModeAtomic TransferBatchMode = "atomic"
+	// TransferBatchModePerItem makes each transfer on its own and reports the
+	// status of each
+	TransferBatchModePerItem TransferBatchMode = "per_item"
+)
+
+// TransferBatchStatus represents the processing state of a transfer batch
+type TransferBatchStatus string
+
+const (
+	TransferBatchStatusPending             TransferBatchStatus = "pending"
+	TransferBatchStatusProcessing          TransferBatchStatus = "processing"
+	TransferBatchStatusCompleted           TransferBatchStatus = "completed"
+	TransferBatchStatusCompletedWithErrors TransferBatchStatus = "completed_with_errors"
+	TransferBatchStatusFailed              TransferBatchStatus = "failed"
+)
+
+// TransferBatchItemStatus represents the state of a single transfer in a batch
+type TransferBatchItemStatus string
+
+const (
+	TransferBatchItemStatusPending   TransferBatchItemStatus = "pending"
+	TransferBatchItemStatusCompleted TransferBatchItemStatus = "completed"
+	TransferBatchItemStatusHeld      TransferBatchItemStatus = "held" // waiting for a fraud review
+	TransferBatchItemStatusFailed    TransferBatchItemStatus = "failed"
+)
+
+// TransferBatchEntry represents one transfer of a batch request. The recipient
+// account is given by its ID or by its account number.
+type TransferBatchEntry struct {
+	ToAccountID     int64   `json:"to_account_id" validate:"required_without=ToAccountNumber"`
+	ToAccountNumber string  `json:"to_account_number,omitempty" validate:"omitempty,len=20,numeric"`
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+	Reference       string  `json:"reference,omitempty" validate:"max=140"`
+}
+
+// CreateTransferBatchRequest represents a request to make several transfers
+// from one account. The mode defaults to per_item.
+type CreateTransferBatchRequest struct {
+	FromAccountID int64                `json:"from_account_id" validate:"required"`
+	Mode          TransferBatchMode    `json:"mode" validate:"omitempty,oneof=atomic per_item"`
+	Transfers     []TransferBatchEntry `json:"transfers" validate:"required,min=1,dive"`
+}
+
+// TransferBatch represents a batch of transfers and its progress
+type TransferBatch struct {
+	ID                 int64                `json:"id"`
+	UserID             int64                `json:"user_id"`
+	FromAccountID      int64                `json:"from_account_id"`
+	Mode               TransferBatchMode    `json:"mode"`
+	Status             TransferBatchStatus  `json:"status"`
+	TotalTransfers     int                  `json:"total_transfers"`
+	TotalAmount        float64              `json:"total_amount"`
+	CompletedTransfers int                  `json:"completed_transfers"`
+	HeldTransfers      int                  `json:"held_transfers"`
+	FailedTransfers    int                  `json:"failed_transfers"`
+	Client             ClientInfo           `json:"-"` // screened by the fraud rules when the job runs
+	JobID              *int64               `json:"job_id,omitempty"`
+	Error              string               `json:"error,omitempty"` // why an atomic batch failed
+	CompletedAt        *time.Time           `json:"completed_at,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+	Items              []*TransferBatchItem `json:"items,omitempty"`
+}
+
+// TransferBatchItem represents the status of one transfer of a batch
+type TransferBatchItem struct {
+	ID            int64                   `json:"id"`
+	BatchID       int64                   `json:"batch_id"`
+	Position      int                     `json:"position"` // 1-based position in the request
+	ToAccountID   int64                   `json:"to_account_id"`
+	Amount        float64                 `json:"amount"`
+	Reference     string                  `json:"reference,omitempty"`
+	Status        TransferBatchItemStatus `json:"status"`
+	TransactionID *int64                  `json:"transaction_id,omitempty"`
+	FraudCheckID  *int64                  `json:"fraud_check_id,omitempty"`
+	Error         string                  `json:"error,omitempty"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
@@ -10,8 +10,13 @@ import (
 type UserRole string
 
 const (
-	RoleUser  UserRole = "user"
-	RoleAdmin UserRole = "admin"
+	RoleUser    UserRole = "user"
+	RoleAdmin   UserRole = "admin"
+	RoleSupport UserRole = "support"
+	// RoleBranchAdmin administers the users of a single branch
+	RoleBranchAdmin UserRole = "branch_admin"
+	// RoleCompliance reviews the anti-money laundering flags and reports
+	RoleCompliance UserRole = "compliance"
 )
 
 // UserStatus represents user's status
@@ -21,22 +26,28 @@ const (
 	StatusActive   UserStatus = "active"
 	StatusBlocked  UserStatus = "blocked"
 	StatusInactive UserStatus = "inactive"
+	StatusClosed   UserStatus = "closed" // closed by the user, personal data is anonymized later
 )
 
 // User represents a bank user
 type User struct {
-	ID          int64      `json:"id"`
-	Email       string     `json:"email" validate:"required,email"`
-	Username    string     `json:"username" validate:"required,min=3,max=50"`
-	Password    string     `json:"-"` // Password hash is never exposed in JSON
-	FirstName   string     `json:"first_name" validate:"required"`
-	LastName    string     `json:"last_name" validate:"required"`
-	PhoneNumber string     `json:"phone_number" validate:"required,e164"`
-	Role        UserRole   `json:"role" validate:"required,oneof=user admin"`
-	Status      UserStatus `json:"status" validate:"required,oneof=active blocked inactive"`
-	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID           int64      `json:"id"`
+	Email        string     `json:"email" validate:"required,email"`
+	Username     string     `json:"username" validate:"required,min=3,max=50"`
+	Password     string     `json:"-"` // Password hash is never exposed in JSON
+	FirstName    string     `json:"first_name" validate:"required"`
+	LastName     string     `json:"last_name" validate:"required"`
+	PhoneNumber  string     `json:"phone_number" validate:"required,e164"`
+	Role         UserRole   `json:"role" validate:"required,oneof=user admin support branch_admin compliance"`
+	BranchID     int64      `json:"branch_id"`
+	Status       UserStatus `json:"status" validate:"required,oneof=active blocked inactive closed"`
+	KYCStatus    KYCStatus  `json:"kyc_status"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"` // set after too many failed logins
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 // UserResponse represents a safe user response without sensitive data
@@ -48,21 +59,78 @@ type UserResponse struct {
 	LastName    string     `json:"last_name"`
 	PhoneNumber string     `json:"phone_number"`
 	Role        UserRole   `json:"role"`
+	BranchID    int64      `json:"branch_id"`
 	Status      UserStatus `json:"status"`
+	KYCStatus   KYCStatus  `json:"kyc_status"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
+// UpdateProfileRequest represents a change of the user's contact details
+type UpdateProfileRequest struct {
+	FirstName   string `json:"first_name" validate:"required,max=100"`
+	LastName    string `json:"last_name" validate:"required,max=100"`
+	PhoneNumber string `json:"phone_number" validate:"omitempty,e164"`
+}
+
+// CloseProfileRequest represents the closure of the user's profile, confirmed
+// with the password
+type CloseProfileRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// JobTypeUserDataExport is the job that stores the data export of a user for
+// the admin who requested it
+const JobTypeUserDataExport = "user_data_export"
+
+// UserDataExport is everything the bank holds about a user, as handed out on
+// a data access request
+type UserDataExport struct {
+	User         *User          `json:"user"`
+	Settings     *UserSettings  `json:"settings"`
+	Accounts     []*Account     `json:"accounts"`
+	Cards        []*Card        `json:"cards"`
+	Credits      []*Credit      `json:"credits"`
+	Transactions []*Transaction `json:"transactions"`
+	ExportedAt   time.Time      `json:"exported_at"`
+}
+
+// ChangePasswordRequest represents a change of the user's password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"` // checked against the password policy
+}
+
 // UserSettings represents user's preferences and settings
 type UserSettings struct {
 	ID                 int64     `json:"id"`
 	UserID             int64     `json:"user_id"`
 	EmailNotifications bool      `json:"email_notifications"`
 	SMSNotifications   bool      `json:"sms_notifications"`
-	Language           string    `json:"language" validate:"required,len=2"`
+	Language           string    `json:"language" validate:"required,oneof=ru en"`
 	TimeZone           string    `json:"timezone" validate:"required"`
+	MonthlyStatements  bool      `json:"monthly_statements"` // email a PDF statement of every account each month
 	UpdatedAt          time.Time `json:"updated_at"`
 }
 
+// DefaultUserSettings returns the settings of a user who has not changed them
+func DefaultUserSettings(userID int64) *UserSettings {
+	return &UserSettings{
+		UserID:             userID,
+		EmailNotifications: true,
+		Language:           "ru",
+		TimeZone:           "Europe/Moscow",
+	}
+}
+
+// UpdateUserSettingsRequest represents a change of the user's settings
+type UpdateUserSettingsRequest struct {
+	EmailNotifications bool   `json:"email_notifications"`
+	SMSNotifications   bool   `json:"sms_notifications"`
+	Language           string `json:"language" validate:"required,oneof=ru en"`
+	TimeZone           string `json:"timezone" validate:"required,timezone"`
+	MonthlyStatements  bool   `json:"monthly_statements"`
+}
+
 func (u *User) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -87,7 +155,9 @@ func (u *User) ToResponse() *UserResponse {
 		LastName:    u.LastName,
 		PhoneNumber: u.PhoneNumber,
 		Role:        u.Role,
+		BranchID:    u.BranchID,
 		Status:      u.Status,
+		KYCStatus:   u.KYCStatus,
 		CreatedAt:   u.CreatedAt,
 	}
 }
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobTypeWebhookDelivery is the job that posts an event to a subscriber
+const JobTypeWebhookDelivery = "webhook_delivery"
+
+// Webhook event types published to partners
+const (
+	WebhookEventCreditApplicationDecided = "credit_application.decided"
+	WebhookEventTransferCompleted        = "transfer.completed"
+	WebhookEventTransferReceived         = "transfer.received"
+)
+
+// Webhook delivery headers. The signature is a hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventIDHeader   = "X-Webhook-Event-ID"
+)
+
+// MaxWebhookReplayEvents bounds the number of events replayed by one range replay
+const MaxWebhookReplayEvents = 500
+
+// WebhookDeliveryStatus represents the state of a delivery attempt
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookSubscription represents a partner endpoint receiving events of the owning user
+type WebhookSubscription struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"` // only returned when the subscription is created
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Matches reports whether the subscription receives events of the given type.
+// A subscription without event types receives every event.
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookSubscriptionRequest represents a request to subscribe an endpoint to events
+type CreateWebhookSubscriptionRequest struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types"`
+}
+
+// WebhookEvent represents an event published to the subscriptions of a user
+type WebhookEvent struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// WebhookEventBody is the JSON body posted to subscribers
+type WebhookEventBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+	Replay    bool            `json:"replay"`
+}
+
+// WebhookDelivery represents one attempt series to deliver an event to a subscription
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	SubscriptionID int64                 `json:"subscription_id"`
+	EventID        int64                 `json:"event_id"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Replay         bool                  `json:"replay"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus *int                  `json:"response_status,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookEventHistory represents a historical event of a subscription with its latest delivery
+type WebhookEventHistory struct {
+	WebhookEvent
+	Deliveries     int                   `json:"deliveries"`
+	LastStatus     WebhookDeliveryStatus `json:"last_status,omitempty"`
+	LastDeliveryAt *time.Time            `json:"last_delivery_at,omitempty"`
+}
+
+// ReplayWebhookEventsRequest represents a request to replay the events of a time range
+type ReplayWebhookEventsRequest struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	FailedOnly bool      `json:"failed_only"`
+}
+
+// ReplayWebhookEventsResult summarises a range replay
+type ReplayWebhookEventsResult struct {
+	Queued    int     `json:"queued"`
+	EventIDs  []int64 `json:"event_ids"`
+	Truncated bool    `json:"truncated"`
+}
+
+// VerifyWebhookSignatureRequest represents a signature check of a received delivery
+type VerifyWebhookSignatureRequest struct {
+	SubscriptionID int64  `json:"subscription_id"`
+	Timestamp      string `json:"timestamp"`
+	Signature      string `json:"signature"`
+	Body           string `json:"body"`
+}
+
+// VerifyWebhookSignatureResult reports the outcome of a signature check
+type VerifyWebhookSignatureResult struct {
+	Valid             bool   `json:"valid"`
+	ExpectedSignature string `json:"expected_signature"`
+	SignedPayload     string `json:"signed_payload"`
+}
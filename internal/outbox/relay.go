@@ -0,0 +1,229 @@
+// Package outbox implements the transactional outbox. Services write messages in
+// the same database transaction as the change they announce, and the relay
+// publishes them once committed, so a notification is never sent for a rolled
+// back change and never lost for a committed one.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/worker"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	relayHeartbeat = "outbox_relay"
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// Publisher hands a message to its destination, such as SMTP, a webhook or a
+// message queue. Returning an error publishes the message again later, and a
+// message may also be published again after a relay crash, so publishers must
+// tolerate duplicates.
+type Publisher func(ctx context.Context, msg *models.OutboxMessage) error
+
+// Relay polls the outbox and publishes committed messages with the publisher
+// registered for their topic
+type Relay struct {
+	repo       *repository.OutboxRepository
+	cfg        *config.OutboxConfig
+	logger     *logrus.Logger
+	monitor    *alerting.HealthMonitor
+	mu         sync.RWMutex
+	publishers map[string]Publisher
+	ticker     *time.Ticker
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	done       chan bool
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(repo *repository.OutboxRepository, cfg *config.OutboxConfig, logger *logrus.Logger) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		repo:       repo,
+		cfg:        cfg,
+		logger:     logger,
+		publishers: make(map[string]Publisher),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan bool),
+	}
+}
+
+// Register sets the publisher for a topic
+func (r *Relay) Register(topic string, publisher Publisher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.publishers[topic] = publisher
+}
+
+// SetHealthMonitor reports polling runs to the health monitor
+func (r *Relay) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	r.monitor = monitor
+	monitor.RegisterHeartbeat(relayHeartbeat, 3*r.cfg.PollInterval+r.cfg.LeaseTimeout)
+}
+
+// Add writes a message to the outbox for changes made without a transaction
+func (r *Relay) Add(ctx context.Context, topic string, payload interface{}) error {
+	msg, err := newMessage(topic, payload)
+	if err != nil {
+		return err
+	}
+	return r.repo.Create(ctx, msg)
+}
+
+// AddTx writes a message to the outbox within a database transaction. It is
+// published after the transaction commits and discarded with it on rollback.
+func (r *Relay) AddTx(ctx context.Context, tx repository.Tx, topic string, payload interface{}) error {
+	msg, err := newMessage(topic, payload)
+	if err != nil {
+		return err
+	}
+	return r.repo.CreateTx(ctx, tx, msg)
+}
+
+// RetryFailed publishes the messages of the topics whose last attempt failed on
+// the next poll rather than after their backoff, and returns their number. The
+// relay polling the outbox need not be this one, so it may run from a separate
+// process.
+func (r *Relay) RetryFailed(ctx context.Context, topics ...string) (int64, error) {
+	return r.repo.RetryFailed(ctx, topics)
+}
+
+func newMessage(topic string, payload interface{}) (*models.OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+	return &models.OutboxMessage{
+		Topic:   topic,
+		Payload: data,
+		Status:  models.OutboxStatusPending,
+	}, nil
+}
+
+// Start begins polling the outbox
+func (r *Relay) Start() {
+	r.logger.Info("Starting outbox relay")
+	r.ticker = time.NewTicker(r.cfg.PollInterval)
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Stop stops polling and waits for the publish in progress to finish, which is
+// cancelled when ctx is done first
+func (r *Relay) Stop(ctx context.Context) {
+	r.logger.Info("Stopping outbox relay")
+	r.ticker.Stop()
+	close(r.done)
+	if worker.Drain(ctx, &r.wg, r.cancel) {
+		r.logger.Warn("Outbox relay forced to stop")
+	}
+	r.cancel()
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.ticker.C:
+			r.poll()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// stopping reports whether Stop has been called, after which no more work is
+// claimed
+func (r *Relay) stopping() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// poll publishes all due messages before waiting for the next tick
+func (r *Relay) poll() {
+	for r.ctx.Err() == nil && !r.stopping() {
+		msg, err := r.repo.ClaimNext(r.ctx, r.cfg.LeaseTimeout)
+		if err != nil {
+			r.logger.Errorf("Failed to claim outbox message: %v", err)
+			return
+		}
+		if msg == nil {
+			break
+		}
+		r.publish(msg)
+	}
+
+	if r.monitor != nil {
+		r.monitor.Beat(relayHeartbeat)
+	}
+}
+
+func (r *Relay) publish(msg *models.OutboxMessage) {
+	logger := r.logger.WithFields(logrus.Fields{
+		"outbox_id": msg.ID,
+		"topic":     msg.Topic,
+		"attempt":   msg.Attempts,
+	})
+
+	r.mu.RLock()
+	publisher, ok := r.publishers[msg.Topic]
+	r.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no publisher registered for topic %s", msg.Topic)
+	} else {
+		err = r.safePublish(publisher, msg)
+	}
+
+	if err == nil {
+		metrics.OutboxPublished.Inc(msg.Topic, metrics.OutcomeSuccess)
+		if err := r.repo.MarkPublished(r.ctx, msg.ID); err != nil {
+			logger.WithError(err).Error("Failed to record outbox message as published")
+		}
+		return
+	}
+
+	metrics.OutboxPublished.Inc(msg.Topic, metrics.OutcomeError)
+	logger.WithError(err).Warn("Failed to publish outbox message")
+	if err := r.repo.MarkFailed(r.ctx, msg.ID, err.Error(), time.Now().Add(retryDelay(msg.Attempts))); err != nil {
+		logger.WithError(err).Error("Failed to record outbox message failure")
+	}
+}
+
+// retryDelay backs off quadratically with the number of attempts, up to an hour
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay * time.Duration(attempts*attempts)
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// safePublish runs a publisher and turns a panic into a publish failure
+func (r *Relay) safePublish(publisher Publisher, msg *models.OutboxMessage) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("outbox publisher panicked: %v", p)
+		}
+	}()
+	return publisher(r.ctx, msg)
+}
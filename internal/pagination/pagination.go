@@ -0,0 +1,133 @@
+// Package pagination reads the page of a list a client asks for from the query
+// string and tells the client how to get the next one. Lists are paged by
+// limit and offset, sorted by a whitelisted field and optionally filtered by
+// status:
+//
+//	GET /api/v1/cards/user/1?limit=20&offset=40&sort=-created_at&status=active
+//
+// The items go in the body; the total count of the items matching the filter
+// goes in X-Total-Count and the links to the neighbouring pages in Link.
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// TotalCountHeader holds the number of items matching the filter
+const TotalCountHeader = "X-Total-Count"
+
+// Options describes how a list can be paged
+type Options struct {
+	SortFields  []string // fields the list can be sorted by, named as the columns
+	DefaultSort string   // sort without a sort parameter; a leading "-" sorts descending
+	Statuses    []string // statuses the list can be filtered by; none disables the filter
+}
+
+// Params selects a page of a list
+type Params struct {
+	Limit  int
+	Offset int
+	Sort   string // one of the sort fields of the list
+	Desc   bool
+	Status string // empty for every status
+}
+
+// Parse reads limit, offset, sort and status from a query string. Sorting by
+// a field outside the whitelist and filtering by an unknown status fail.
+func Parse(query url.Values, opts Options) (Params, error) {
+	p := Params{Limit: DefaultLimit}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return Params{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		p.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return Params{}, errors.New("offset must be a non-negative number")
+		}
+		p.Offset = offset
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = opts.DefaultSort
+	}
+	p.Sort = strings.TrimPrefix(sort, "-")
+	p.Desc = strings.HasPrefix(sort, "-")
+	if !contains(opts.SortFields, p.Sort) {
+		return Params{}, fmt.Errorf("cannot sort by %q, sort by one of: %s", p.Sort, strings.Join(opts.SortFields, ", "))
+	}
+
+	if status := query.Get("status"); status != "" {
+		if !contains(opts.Statuses, status) {
+			return Params{}, fmt.Errorf("cannot filter by status %q", status)
+		}
+		p.Status = status
+	}
+
+	return p, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderBy returns the ORDER BY clause of the page. The ID breaks ties, so the
+// order, and with it the pages, stay the same between requests.
+func (p Params) OrderBy() string {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, id %s", p.Sort, direction, direction)
+}
+
+// WriteHeaders sets the total count of the list and the links to the next and
+// previous pages of the request
+func WriteHeaders(w http.ResponseWriter, r *http.Request, p Params, total int) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+
+	var links []string
+	if p.Offset+p.Limit < total {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, p.Limit, p.Offset+p.Limit)))
+	}
+	if p.Offset > 0 {
+		previous := p.Offset - p.Limit
+		if previous < 0 {
+			previous = 0
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, p.Limit, previous)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageURL is the request URL, relative to the host, with another page selected
+func pageURL(r *http.Request, limit, offset int) string {
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
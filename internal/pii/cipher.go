@@ -0,0 +1,310 @@
+// Package pii encrypts the personal data of users at rest. Values are sealed
+// with AES-256-GCM under data keys kept in the database, each wrapped by the
+// master key of the configuration (envelope encryption), so rotating the data
+// keys needs no new configuration. Encrypted values cannot be compared in SQL;
+// lookups go through blind indexes, keyed hashes of the normalized values.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// Fields name the encrypted columns. A value is bound to its field, so an
+// encrypted phone number copied into the email column fails to decrypt.
+const (
+	FieldEmail     = "users.email"
+	FieldFirstName = "users.first_name"
+	FieldLastName  = "users.last_name"
+	FieldPhone     = "users.phone_number"
+)
+
+// valuePrefix starts every encrypted value, followed by the data key ID and
+// the base64 of the nonce and the sealed value. Values without it were stored
+// before encryption was turned on and are read as they are.
+const valuePrefix = "pii:v1:"
+
+// wrappedKeyData binds the wrapped data keys to their purpose
+const wrappedKeyData = "pii-data-key"
+
+// keyRefreshInterval is how often the data keys are read again, so that a key
+// rotated by another instance is used for new values without a restart
+const keyRefreshInterval = 5 * time.Minute
+
+// KeyStore keeps the wrapped data keys
+type KeyStore interface {
+	ListKeys(ctx context.Context) ([]*models.PIIDataKey, error)
+	CreateKey(ctx context.Context, wrappedKey []byte) (*models.PIIDataKey, error)
+}
+
+// Cipher encrypts and decrypts personal data and computes its blind indexes.
+// New values are encrypted under the newest data key that is not retired;
+// values under any known key decrypt.
+type Cipher struct {
+	master   cipher.AEAD
+	indexKey []byte
+	store    KeyStore
+
+	mu       sync.RWMutex
+	keys     map[int64]cipher.AEAD
+	active   *models.PIIDataKey
+	loadedAt time.Time
+}
+
+// New creates a Cipher from the master key of the configuration and loads the
+// data keys, creating the first one on a fresh database. Without a master key
+// personal data is stored in plain text and New returns nil.
+func New(ctx context.Context, cfg *config.EncryptionConfig, store KeyStore) (*Cipher, error) {
+	if cfg.PIIMasterKey == "" {
+		return nil, nil
+	}
+	if cfg.HMACSecret == "" {
+		return nil, errors.New("an HMAC secret is required for the blind indexes of personal data")
+	}
+
+	masterKey, err := base64.StdEncoding.DecodeString(cfg.PIIMasterKey)
+	if err != nil || len(masterKey) != 32 {
+		return nil, errors.New("the PII master key must be 32 bytes in base64")
+	}
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cipher{
+		master:   master,
+		indexKey: []byte(cfg.HMACSecret),
+		store:    store,
+	}
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+	if c.active == nil {
+		if _, err := c.Rotate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// load reads and unwraps the data keys
+func (c *Cipher) load(ctx context.Context) error {
+	stored, err := c.store.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load data keys: %w", err)
+	}
+
+	keys := make(map[int64]cipher.AEAD, len(stored))
+	var active *models.PIIDataKey
+	for _, key := range stored {
+		aead, err := c.unwrap(key.WrappedKey)
+		if err != nil {
+			return fmt.Errorf("failed to unwrap data key %d: %w", key.ID, err)
+		}
+		keys[key.ID] = aead
+		if key.RetiredAt == nil && (active == nil || key.ID > active.ID) {
+			active = key
+		}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.active = active
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// Rotate creates a new data key and encrypts new values under it. Values under
+// the previous keys still decrypt until they are encrypted again.
+func (c *Cipher) Rotate(ctx context.Context) (*models.PIIDataKey, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(c.master, dataKey, wrappedKeyData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.store.CreateKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store data key: %w", err)
+	}
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ActiveKey returns the data key new values are encrypted under
+func (c *Cipher) ActiveKey() *models.PIIDataKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// Encrypt encrypts a value of a field. Empty values stay empty, so optional
+// columns keep their NULLs.
+func (c *Cipher) Encrypt(ctx context.Context, field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	c.mu.RLock()
+	stale := time.Since(c.loadedAt) > keyRefreshInterval
+	c.mu.RUnlock()
+	if stale {
+		// A failed refresh keeps encrypting under the known key
+		_ = c.load(ctx)
+	}
+
+	c.mu.RLock()
+	active := c.active
+	aead := c.keys[active.ID]
+	c.mu.RUnlock()
+
+	sealed, err := seal(aead, []byte(value), field)
+	if err != nil {
+		return "", err
+	}
+	return valuePrefix + strconv.FormatInt(active.ID, 10) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value of a field. Values stored before encryption was
+// turned on are returned as they are.
+func (c *Cipher) Decrypt(ctx context.Context, field, value string) (string, error) {
+	keyID, sealed, ok, err := parseValue(value)
+	if err != nil || !ok {
+		return value, err
+	}
+
+	c.mu.RLock()
+	aead, found := c.keys[keyID]
+	c.mu.RUnlock()
+	if !found {
+		// The key may have been created by another instance since the last load
+		if err := c.load(ctx); err != nil {
+			return "", err
+		}
+		c.mu.RLock()
+		aead, found = c.keys[keyID]
+		c.mu.RUnlock()
+		if !found {
+			return "", fmt.Errorf("unknown data key %d", keyID)
+		}
+	}
+
+	plain, err := open(aead, sealed, field)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+	return string(plain), nil
+}
+
+// Stale reports whether a value is in plain text or encrypted under another
+// key than the active one, and so is due to be encrypted again
+func (c *Cipher) Stale(value string) bool {
+	if value == "" {
+		return false
+	}
+	keyID, _, ok, err := parseValue(value)
+	if err != nil || !ok {
+		return true
+	}
+	return keyID != c.ActiveKey().ID
+}
+
+// EmailIndex returns the blind index of an email address, which ignores letter
+// case and surrounding spaces. An empty address has none.
+func (c *Cipher) EmailIndex(email string) string {
+	return c.blindIndex(FieldEmail, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// PhoneIndex returns the blind index of a phone number, which only keeps its
+// digits. A number without digits has none.
+func (c *Cipher) PhoneIndex(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	return c.blindIndex(FieldPhone, digits)
+}
+
+func (c *Cipher) blindIndex(field, normalized string) string {
+	if normalized == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(field + ":" + normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Cipher) unwrap(wrapped []byte) (cipher.AEAD, error) {
+	dataKey, err := open(c.master, wrapped, wrappedKeyData)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(dataKey)
+}
+
+// parseValue splits an encrypted value into its key ID and sealed bytes; ok is
+// false for a value in plain text
+func parseValue(value string) (keyID int64, sealed []byte, ok bool, err error) {
+	if !strings.HasPrefix(value, valuePrefix) {
+		return 0, nil, false, nil
+	}
+	id, encoded, found := strings.Cut(strings.TrimPrefix(value, valuePrefix), ":")
+	if !found {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	if keyID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	if sealed, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	return keyID, sealed, true, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain with a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plain []byte, additionalData string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, []byte(additionalData)), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData string) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+}
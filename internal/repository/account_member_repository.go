@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// AccountMemberRepository handles database operations for shared account members
+type AccountMemberRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAccountMemberRepository creates a new AccountMemberRepository instance
+func NewAccountMemberRepository(db *sql.DB, logger *logrus.Logger) *AccountMemberRepository {
+	return &AccountMemberRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *AccountMemberRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+const accountMemberColumns = `
+	id, account_id, user_id, role, status, invited_by, accepted_at, created_at, updated_at
+`
+
+func scanAccountMember(scanner interface{ Scan(...interface{}) error }) (*models.AccountMember, error) {
+	member := &models.AccountMember{}
+	var acceptedAt sql.NullTime
+
+	err := scanner.Scan(
+		&member.ID,
+		&member.AccountID,
+		&member.UserID,
+		&member.Role,
+		&member.Status,
+		&member.InvitedBy,
+		&acceptedAt,
+		&member.CreatedAt,
+		&member.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if acceptedAt.Valid {
+		member.AcceptedAt = &acceptedAt.Time
+	}
+
+	return member, nil
+}
+
+// CreateTx stores an invitation of a user to an account within a database transaction
+func (r *AccountMemberRepository) CreateTx(ctx context.Context, tx Tx, member *models.AccountMember) error {
+	query := `
+		INSERT INTO account_members (account_id, user_id, role, status, invited_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		member.AccountID,
+		member.UserID,
+		member.Role,
+		member.Status,
+		member.InvitedBy,
+	).Scan(&member.ID, &member.CreatedAt, &member.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("user is already a member of the account")
+		}
+		r.logger.WithError(err).Error("Failed to create account member")
+		return err
+	}
+
+	return nil
+}
+
+// GetByID retrieves an account member by its ID
+func (r *AccountMemberRepository) GetByID(ctx context.Context, id int64) (*models.AccountMember, error) {
+	query := `SELECT ` + accountMemberColumns + ` FROM account_members WHERE id = $1`
+
+	member, err := scanAccountMember(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("account member not found")
+		}
+		r.logger.WithError(err).Error("Failed to get account member")
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// GetByAccountAndUser retrieves the membership of a user in an account
+func (r *AccountMemberRepository) GetByAccountAndUser(ctx context.Context, accountID, userID int64) (*models.AccountMember, error) {
+	query := `SELECT ` + accountMemberColumns + ` FROM account_members WHERE account_id = $1 AND user_id = $2`
+
+	member, err := scanAccountMember(r.db.QueryRowContext(ctx, query, accountID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("account member not found")
+		}
+		r.logger.WithError(err).Error("Failed to get account member")
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// GetByAccountID retrieves the members and pending invitations of an account
+func (r *AccountMemberRepository) GetByAccountID(ctx context.Context, accountID int64) ([]*models.AccountMember, error) {
+	query := `SELECT ` + accountMemberColumns + ` FROM account_members WHERE account_id = $1 ORDER BY created_at`
+	return r.list(ctx, query, accountID)
+}
+
+// GetInvitations retrieves the invitations a user has not answered yet
+func (r *AccountMemberRepository) GetInvitations(ctx context.Context, userID int64) ([]*models.AccountMember, error) {
+	query := `SELECT ` + accountMemberColumns + ` FROM account_members WHERE user_id = $1 AND status = $2 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID, models.AccountMemberStatusInvited)
+}
+
+func (r *AccountMemberRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.AccountMember, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list account members")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.AccountMember
+	for rows.Next() {
+		member, err := scanAccountMember(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan account member row")
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// ActivateTx marks an invitation as accepted within a database transaction
+func (r *AccountMemberRepository) ActivateTx(ctx context.Context, tx Tx, member *models.AccountMember) error {
+	query := `
+		UPDATE account_members
+		SET status = $1, accepted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3
+		RETURNING accepted_at, updated_at
+	`
+
+	var acceptedAt sql.NullTime
+	err := tx.QueryRowContext(ctx, query, models.AccountMemberStatusActive, member.ID, models.AccountMemberStatusInvited).
+		Scan(&acceptedAt, &member.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("invitation not found")
+		}
+		r.logger.WithError(err).Error("Failed to accept account invitation")
+		return err
+	}
+
+	member.Status = models.AccountMemberStatusActive
+	member.AcceptedAt = &acceptedAt.Time
+	return nil
+}
+
+// UpdateRole changes the permission level of a member
+func (r *AccountMemberRepository) UpdateRole(ctx context.Context, id int64, role models.AccountRole) error {
+	query := `UPDATE account_members SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, role, id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update account member role")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("account member not found")
+	}
+
+	return nil
+}
+
+// Delete removes a member or a declined invitation
+func (r *AccountMemberRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM account_members WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		r.logger.WithError(err).Error("Failed to delete account member")
+		return err
+	}
+
+	return nil
+}
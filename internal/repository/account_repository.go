@@ -1,64 +1,538 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/cache"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
 type AccountRepository struct {
-	db     *sql.DB
-	logger *logrus.Logger
+	db       *sql.DB
+	cache    cache.Cache
+	cacheTTL time.Duration
+	logger   *logrus.Logger
 }
 
-func NewAccountRepository() *AccountRepository {
+func NewAccountRepository(db *sql.DB, logger *logrus.Logger) *AccountRepository {
 	return &AccountRepository{
-		logger: logrus.New(),
+		db:     db,
+		cache:  cache.Nop{},
+		logger: logger,
 	}
 }
 
-func (r *AccountRepository) SetDB(db *sql.DB) {
-	r.db = db
+// SetCache makes GetByID serve accounts from c for up to ttl. Every change of an
+// account made through the repository drops its entry; a change made within a
+// transaction, or an account locked for one, drops it again on commit, so that
+// a read in between does not keep the old state.
+func (r *AccountRepository) SetCache(c cache.Cache, ttl time.Duration) {
+	r.cache = c
+	r.cacheTTL = ttl
 }
 
-func (r *AccountRepository) BeginTransaction() (*sql.Tx, error) {
-	return r.db.Begin()
+func accountCacheKey(id int64) string {
+	return fmt.Sprintf("account:%d", id)
 }
 
-func (r *AccountRepository) Create(account *models.Account) error {
+// invalidate drops the cached accounts
+func (r *AccountRepository) invalidate(ctx context.Context, ids ...int64) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 {
+			keys = append(keys, accountCacheKey(id))
+		}
+	}
+	r.cache.Delete(ctx, keys...)
+}
+
+// invalidateTx drops the cached accounts now and once tx has committed
+func (r *AccountRepository) invalidateTx(ctx context.Context, tx Tx, ids ...int64) {
+	r.invalidate(ctx, ids...)
+	AfterCommit(tx, func() {
+		r.invalidate(context.WithoutCancel(ctx), ids...)
+	})
+}
+
+func (r *AccountRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// Create stores a new account. The first account of a user in a currency becomes
+// the primary one of that currency. An opening balance is posted as a deposit.
+func (r *AccountRepository) Create(ctx context.Context, account *models.Account) error {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO accounts (user_id, balance, currency, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
+		INSERT INTO accounts (user_id, branch_id, number, name, is_primary, balance, currency, created_at, updated_at)
+		VALUES ($1, (SELECT branch_id FROM users WHERE id = $1), $2, NULLIF($3, ''), NOT EXISTS (
+			SELECT 1 FROM accounts WHERE user_id = $1 AND currency = $4 AND is_primary
+		), 0, $4, $5, $6)
+		RETURNING id, branch_id, is_primary, version
 	`
-	return r.db.QueryRow(
+	err = tx.QueryRowContext(
+		ctx,
 		query,
 		account.UserID,
-		account.Balance,
+		account.Number,
+		account.Name,
 		account.Currency,
 		account.CreatedAt,
 		account.UpdatedAt,
-	).Scan(&account.ID)
+	).Scan(&account.ID, &account.BranchID, &account.Primary, &account.Version)
+	if isUniqueViolation(err) {
+		return ErrAccountNumberTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	if account.Balance > 0 {
+		err := r.postTransaction(ctx, tx, &models.Transaction{
+			ToAccountID: account.ID,
+			Amount:      account.Balance,
+			Type:        models.TransactionTypeDeposit,
+			Description: "Opening balance",
+			CreatedAt:   account.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		account.Version++
+	}
+
+	return tx.Commit()
 }
 
-func (r *AccountRepository) GetByID(id int64) (*models.Account, error) {
-	account := &models.Account{}
+// ErrAccountNumberTaken is returned when the number given to an account already
+// belongs to another one
+var ErrAccountNumberTaken = errors.New("account number is already taken")
+
+// GetByNumber retrieves an account by its account number
+func (r *AccountRepository) GetByNumber(ctx context.Context, number string) (*models.Account, error) {
 	query := `
-		SELECT id, user_id, balance, currency, created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM accounts
-		WHERE id = $1
+		WHERE number = $1
+	`
+	return r.getAccount(ctx, r.db, query, number)
+}
+
+// GetWithoutNumber lists the accounts opened before account numbers were issued
+func (r *AccountRepository) GetWithoutNumber(ctx context.Context) ([]*models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE number IS NULL AND closed_at IS NULL
+		ORDER BY id
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get accounts without number")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// SetNumber gives an account without a number the given one
+func (r *AccountRepository) SetNumber(ctx context.Context, id int64, number string) error {
+	query := `
+		UPDATE accounts
+		SET number = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND number IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, number, id)
+	if isUniqueViolation(err) {
+		return ErrAccountNumberTaken
+	}
+	if err != nil {
+		return err
+	}
+	r.invalidate(ctx, id)
+	return nil
+}
+
+// GetPrimary retrieves the primary account of a user in a currency
+func (r *AccountRepository) GetPrimary(ctx context.Context, userID int64, currency string) (*models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE user_id = $1 AND currency = $2 AND is_primary
+	`
+	account, err := r.getAccount(ctx, r.db, query, userID, currency)
+	if err != nil && err.Error() == "account not found" {
+		return nil, errors.New("no primary account in this currency")
+	}
+	return account, err
+}
+
+// UpdateName sets the name of an account, an empty name removes it
+func (r *AccountRepository) UpdateName(ctx context.Context, id int64, name string) error {
+	query := `
+		UPDATE accounts
+		SET name = NULLIF($1, ''), version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+	if _, err := r.db.ExecContext(ctx, query, name, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id)
+	return nil
+}
+
+// SetPrimary makes an account the primary one of its user and currency, taking
+// the flag from the previous one, or clears the flag when primary is false
+func (r *AccountRepository) SetPrimary(ctx context.Context, account *models.Account, primary bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if primary {
+		query := `
+			UPDATE accounts
+			SET is_primary = FALSE, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND currency = $2 AND is_primary AND id <> $3
+		`
+		if _, err := tx.ExecContext(ctx, query, account.UserID, account.Currency, account.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE accounts
+		SET is_primary = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, primary, account.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.invalidateUser(ctx, account.UserID)
+	return nil
+}
+
+// CloseTx closes an account within a database transaction, after which the
+// database rejects any change of it. When the account was the primary one of
+// its currency, the oldest open account of the user in that currency takes the
+// flag over.
+func (r *AccountRepository) CloseTx(ctx context.Context, tx Tx, account *models.Account, closedAt time.Time) error {
+	query := `
+		UPDATE accounts
+		SET closed_at = $1, is_primary = FALSE, overdraft_limit = 0, version = version + 1, updated_at = $1
+		WHERE id = $2 AND closed_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, closedAt, account.ID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to close account")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("account is already closed")
+	}
+
+	ids := []int64{account.ID}
+	if account.Primary {
+		query := `
+			UPDATE accounts
+			SET is_primary = TRUE, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = (
+				SELECT id FROM accounts
+				WHERE user_id = $1 AND currency = $2 AND closed_at IS NULL
+				ORDER BY created_at, id
+				LIMIT 1
+			)
+			RETURNING id
+		`
+		var primaryID int64
+		err := tx.QueryRowContext(ctx, query, account.UserID, account.Currency).Scan(&primaryID)
+		switch {
+		case err == nil:
+			ids = append(ids, primaryID)
+		case !errors.Is(err, sql.ErrNoRows):
+			r.logger.WithError(err).Error("Failed to promote primary account")
+			return err
+		}
+	}
+
+	r.invalidateTx(ctx, tx, ids...)
+	account.ClosedAt = &closedAt
+	account.Primary = false
+	account.OverdraftLimit = 0
+	account.Version++
+	return nil
+}
+
+// bankTransactionTypes are the transactions the bank posts on its own. They do
+// not count as activity of an account, or the dormancy fee would keep the
+// account it is charged on active.
+var bankTransactionTypes = []string{
+	models.TransactionTypeOverdraftInterest,
+	models.TransactionTypeDepositInterest,
+	models.TransactionTypeDormancyFee,
+}
+
+// inactiveSince matches the open accounts that are not dormant and had no
+// activity since the cutoff in $1: they were opened and last reactivated before
+// it and no transaction other than those in $2 touched them since
+const inactiveSince = `
+	closed_at IS NULL AND dormant_since IS NULL AND created_at < $1
+	AND (reactivated_at IS NULL OR reactivated_at < $1)
+	AND NOT EXISTS (
+		SELECT 1 FROM transactions
+		WHERE (from_account_id = accounts.id OR to_account_id = accounts.id)
+		AND created_at >= $1 AND type <> ALL($2)
+	)
+`
+
+// ListInactive lists the IDs of the accounts that had no activity since the
+// cutoff and are not dormant yet
+func (r *AccountRepository) ListInactive(ctx context.Context, cutoff time.Time) ([]int64, error) {
+	query := `SELECT id FROM accounts WHERE ` + inactiveSince + ` ORDER BY id`
+	return r.listIDs(ctx, query, cutoff, pq.Array(bankTransactionTypes))
+}
+
+// MarkDormantTx marks an account dormant within a database transaction when it
+// still had no activity since the cutoff, reporting whether it was marked
+func (r *AccountRepository) MarkDormantTx(ctx context.Context, tx Tx, id int64, cutoff, dormantSince time.Time) (bool, error) {
+	query := `
+		UPDATE accounts
+		SET dormant_since = $3, version = version + 1, updated_at = $3
+		WHERE id = $4 AND ` + inactiveSince
+	result, err := tx.ExecContext(ctx, query, cutoff, pq.Array(bankTransactionTypes), dormantSince, id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to mark account dormant")
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+	r.invalidateTx(ctx, tx, id)
+	return true, nil
+}
+
+// ListDormancyFeeDue lists the IDs of the open dormant accounts that were last
+// charged the dormancy fee, or became dormant, no later than the given time
+func (r *AccountRepository) ListDormancyFeeDue(ctx context.Context, before time.Time) ([]int64, error) {
+	query := `
+		SELECT id FROM accounts
+		WHERE closed_at IS NULL AND dormant_since IS NOT NULL
+		AND COALESCE(dormancy_fee_charged_at, dormant_since) <= $1
+		ORDER BY id
 	`
-	err := r.db.QueryRow(query, id).Scan(
+	return r.listIDs(ctx, query, before)
+}
+
+// SetDormancyFeeChargedTx records within a database transaction when the
+// dormancy fee of an account was last due
+func (r *AccountRepository) SetDormancyFeeChargedTx(ctx context.Context, tx Tx, id int64, chargedAt time.Time) error {
+	query := `
+		UPDATE accounts
+		SET dormancy_fee_charged_at = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, chargedAt, id); err != nil {
+		r.logger.WithError(err).Error("Failed to record dormancy fee")
+		return err
+	}
+	r.invalidateTx(ctx, tx, id)
+	return nil
+}
+
+// Reactivate lifts the dormancy of an account. It reports false when the
+// account is not dormant or was closed.
+func (r *AccountRepository) Reactivate(ctx context.Context, id int64, reactivatedAt time.Time) (bool, error) {
+	query := `
+		UPDATE accounts
+		SET dormant_since = NULL, dormancy_fee_charged_at = NULL, reactivated_at = $1,
+			version = version + 1, updated_at = $1
+		WHERE id = $2 AND dormant_since IS NOT NULL AND closed_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, reactivatedAt, id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to reactivate account")
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+	r.invalidate(ctx, id)
+	return true, nil
+}
+
+func (r *AccountRepository) listIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list accounts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// invalidateUser drops the cached accounts of a user
+func (r *AccountRepository) invalidateUser(ctx context.Context, userID int64) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM accounts WHERE user_id = $1`, userID)
+	if err != nil {
+		r.logger.WithError(err).Warn("Failed to list accounts to invalidate")
+		return
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	r.invalidate(ctx, ids...)
+}
+
+const accountColumns = `
+	id, user_id, branch_id, COALESCE(number, ''), COALESCE(name, ''), is_primary, balance, currency, overdraft_limit,
+	overdraft_interest_rate, overdraft_interest_accrued_at, version, created_at, updated_at, closed_at,
+	dormant_since, dormancy_fee_charged_at, reactivated_at,
+	(SELECT COALESCE(SUM(amount), 0) FROM holds WHERE account_id = accounts.id AND ` + activeHolds + `)
+`
+
+func scanAccount(scanner interface{ Scan(...interface{}) error }) (*models.Account, error) {
+	account := &models.Account{}
+	var closedAt, dormantSince, feeChargedAt, reactivatedAt sql.NullTime
+	err := scanner.Scan(
 		&account.ID,
 		&account.UserID,
+		&account.BranchID,
+		&account.Number,
+		&account.Name,
+		&account.Primary,
 		&account.Balance,
 		&account.Currency,
+		&account.OverdraftLimit,
+		&account.OverdraftInterestRate,
+		&account.OverdraftInterestAccruedAt,
+		&account.Version,
 		&account.CreatedAt,
 		&account.UpdatedAt,
+		&closedAt,
+		&dormantSince,
+		&feeChargedAt,
+		&reactivatedAt,
+		&account.HeldAmount,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		account.ClosedAt = &closedAt.Time
+	}
+	if dormantSince.Valid {
+		account.DormantSince = &dormantSince.Time
+	}
+	if feeChargedAt.Valid {
+		account.DormancyFeeChargedAt = &feeChargedAt.Time
+	}
+	if reactivatedAt.Valid {
+		account.ReactivatedAt = &reactivatedAt.Time
+	}
+	return account, nil
+}
+
+// GetByID retrieves an account, from the cache when it holds the account
+func (r *AccountRepository) GetByID(ctx context.Context, id int64) (*models.Account, error) {
+	account := &models.Account{}
+	if r.cache.Get(ctx, accountCacheKey(id), account) {
+		return account, nil
+	}
+
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id = $1
+	`
+	account, err := r.getAccount(ctx, r.db, query, id)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Set(ctx, accountCacheKey(id), account, r.cacheTTL)
+	return account, nil
+}
+
+// GetByIDForUpdate retrieves an account and locks its row until the transaction
+// ends. Holds are placed under this lock, so the held amount is summed again once
+// it is taken to count the holds committed while waiting for it.
+func (r *AccountRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id = $1
+		FOR UPDATE
+	`
+	account, err := r.getAccount(ctx, tx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	// The account is locked to be changed, by its balance or its holds
+	r.invalidateTx(ctx, tx, id)
+
+	heldQuery := `SELECT COALESCE(SUM(amount), 0) FROM holds WHERE account_id = $1 AND ` + activeHolds
+	if err := tx.QueryRowContext(ctx, heldQuery, id).Scan(&account.HeldAmount); err != nil {
+		r.logger.WithError(err).Error("Failed to sum account holds")
+		return nil, err
+	}
+	return account, nil
+}
+
+func (r *AccountRepository) getAccount(ctx context.Context, q queryer, query string, args ...interface{}) (*models.Account, error) {
+	account, err := scanAccount(q.QueryRowContext(ctx, query, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("account not found")
@@ -68,13 +542,13 @@ func (r *AccountRepository) GetByID(id int64) (*models.Account, error) {
 	return account, nil
 }
 
-func (r *AccountRepository) GetByUserID(userID int64) ([]*models.Account, error) {
+func (r *AccountRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Account, error) {
 	query := `
-		SELECT id, user_id, balance, currency, created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM accounts
 		WHERE user_id = $1
 	`
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -82,15 +556,7 @@ func (r *AccountRepository) GetByUserID(userID int64) ([]*models.Account, error)
 
 	var accounts []*models.Account
 	for rows.Next() {
-		account := &models.Account{}
-		err := rows.Scan(
-			&account.ID,
-			&account.UserID,
-			&account.Balance,
-			&account.Currency,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		)
+		account, err := scanAccount(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -99,36 +565,246 @@ func (r *AccountRepository) GetByUserID(userID int64) ([]*models.Account, error)
 	return accounts, nil
 }
 
-func (r *AccountRepository) UpdateBalance(id int64, newBalance float64) error {
-	query := `
-		UPDATE accounts
-		SET balance = $1, updated_at = $2
-		WHERE id = $3
-	`
-	_, err := r.db.Exec(query, newBalance, time.Now(), id)
-	return err
+// ListByUserID retrieves a page of the accounts of a user and how many the
+// user has
+func (r *AccountRepository) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Account, int, error) {
+	rows, total, err := queryPage(ctx, r.db, accountColumns, "accounts", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list accounts by user ID")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, total, rows.Err()
+}
+
+// GetMemberRole retrieves the role of a user who shares an account through an
+// accepted invitation. Owners have no member row.
+func (r *AccountRepository) GetMemberRole(ctx context.Context, accountID, userID int64) (models.AccountRole, error) {
+	query := `SELECT role FROM account_members WHERE account_id = $1 AND user_id = $2 AND status = $3`
+
+	var role models.AccountRole
+	err := r.db.QueryRowContext(ctx, query, accountID, userID, models.AccountMemberStatusActive).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errors.New("account member not found")
+		}
+		r.logger.WithError(err).Error("Failed to get account member role")
+		return "", err
+	}
+
+	return role, nil
+}
+
+// PostTransaction records a transaction and posts it to the ledger in its own
+// database transaction
+func (r *AccountRepository) PostTransaction(ctx context.Context, transaction *models.Transaction) error {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.postTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+	r.invalidateTx(ctx, tx, transaction.FromAccountID, transaction.ToAccountID)
+	return tx.Commit()
+}
+
+// PostTransactionTx records a transaction within a database transaction, posts
+// its debit and credit entries to the ledger and applies them to the cached
+// balances of the customer accounts. The caller holds the locks of the accounts
+// whose balance it checked.
+func (r *AccountRepository) PostTransactionTx(ctx context.Context, tx Tx, transaction *models.Transaction) error {
+	if err := r.postTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+	r.invalidateTx(ctx, tx, transaction.FromAccountID, transaction.ToAccountID)
+	return nil
+}
+
+func (r *AccountRepository) postTransaction(ctx context.Context, q queryer, transaction *models.Transaction) error {
+	entries, err := transaction.LedgerEntries()
+	if err != nil {
+		return err
+	}
+
+	if err := r.createTransaction(ctx, q, transaction); err != nil {
+		r.logger.WithError(err).Error("Failed to create transaction")
+		return err
+	}
+
+	// Both entries are in the currency of the customer account; transfers only
+	// move money between accounts of the same currency
+	customerAccountID := entries[0].AccountID
+	if customerAccountID == 0 {
+		customerAccountID = entries[1].AccountID
+	}
+	for i := range entries {
+		entry := &entries[i]
+		entry.TransactionID = transaction.ID
+
+		query := `
+			INSERT INTO ledger_entries (transaction_id, account_id, ledger_account, side, amount, currency, created_at)
+			SELECT $1, $2, $3, $4, $5, currency, $6 FROM accounts WHERE id = $7
+			RETURNING id, currency
+		`
+		err := q.QueryRowContext(
+			ctx,
+			query,
+			entry.TransactionID,
+			nullableID(entry.AccountID),
+			entry.LedgerAccount,
+			entry.Side,
+			entry.Amount,
+			entry.CreatedAt,
+			customerAccountID,
+		).Scan(&entry.ID, &entry.Currency)
+		if err != nil {
+			r.logger.WithError(err).Errorf("Failed to post ledger entry of transaction %d", transaction.ID)
+			return err
+		}
+
+		if entry.AccountID == 0 {
+			continue
+		}
+		query = `UPDATE accounts SET balance = balance + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+		if _, err := q.ExecContext(ctx, query, entry.BalanceDelta(), entry.AccountID); err != nil {
+			r.logger.WithError(err).Errorf("Failed to update balance of account %d", entry.AccountID)
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (r *AccountRepository) CreateTransaction(transaction *models.Transaction) error {
+func (r *AccountRepository) createTransaction(ctx context.Context, q queryer, transaction *models.Transaction) error {
 	query := `
-		INSERT INTO transactions (from_account_id, to_account_id, amount, type, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO transactions (
+			from_account_id, to_account_id, amount, type, card_id, description,
+			original_amount, original_currency, billed_currency, exchange_rate,
+			markup_percent, markup_amount, applied_parameters, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING id
 	`
-	return r.db.QueryRow(
+
+	var originalAmount, exchangeRate, markupPercent, markupAmount sql.NullFloat64
+	var originalCurrency, billedCurrency sql.NullString
+	if c := transaction.Conversion; c != nil {
+		originalAmount = sql.NullFloat64{Float64: c.OriginalAmount, Valid: true}
+		originalCurrency = sql.NullString{String: c.OriginalCurrency, Valid: true}
+		billedCurrency = sql.NullString{String: c.BilledCurrency, Valid: true}
+		exchangeRate = sql.NullFloat64{Float64: c.ExchangeRate, Valid: true}
+		markupPercent = sql.NullFloat64{Float64: c.MarkupPercent, Valid: true}
+		markupAmount = sql.NullFloat64{Float64: c.MarkupAmount, Valid: true}
+	}
+
+	appliedParameters, err := marshalAppliedParameters(transaction.AppliedParameters)
+	if err != nil {
+		return err
+	}
+
+	return q.QueryRowContext(
+		ctx,
 		query,
-		transaction.FromAccountID,
-		transaction.ToAccountID,
+		nullableID(transaction.FromAccountID),
+		nullableID(transaction.ToAccountID),
 		transaction.Amount,
 		transaction.Type,
+		nullableID(transaction.CardID),
+		sql.NullString{String: transaction.Description, Valid: transaction.Description != ""},
+		originalAmount,
+		originalCurrency,
+		billedCurrency,
+		exchangeRate,
+		markupPercent,
+		markupAmount,
+		appliedParameters,
 		transaction.CreatedAt,
 	).Scan(&transaction.ID)
 }
 
+const transactionColumns = `
+	id, COALESCE(from_account_id, 0), COALESCE(to_account_id, 0), amount, type,
+	COALESCE(card_id, 0), COALESCE(description, ''), original_amount, original_currency,
+	billed_currency, exchange_rate, markup_percent, markup_amount, applied_parameters, created_at
+`
+
+func scanTransaction(scanner interface{ Scan(...interface{}) error }) (*models.Transaction, error) {
+	tx := &models.Transaction{}
+	var originalAmount, exchangeRate, markupPercent, markupAmount sql.NullFloat64
+	var originalCurrency, billedCurrency sql.NullString
+	var appliedParameters []byte
+
+	err := scanner.Scan(
+		&tx.ID,
+		&tx.FromAccountID,
+		&tx.ToAccountID,
+		&tx.Amount,
+		&tx.Type,
+		&tx.CardID,
+		&tx.Description,
+		&originalAmount,
+		&originalCurrency,
+		&billedCurrency,
+		&exchangeRate,
+		&markupPercent,
+		&markupAmount,
+		&appliedParameters,
+		&tx.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.AppliedParameters, err = unmarshalAppliedParameters(appliedParameters); err != nil {
+		return nil, err
+	}
+
+	if originalCurrency.Valid {
+		tx.Conversion = &models.CurrencyConversion{
+			OriginalAmount:   originalAmount.Float64,
+			OriginalCurrency: originalCurrency.String,
+			BilledAmount:     tx.Amount,
+			BilledCurrency:   billedCurrency.String,
+			ExchangeRate:     exchangeRate.Float64,
+			MarkupPercent:    markupPercent.Float64,
+			MarkupAmount:     markupAmount.Float64,
+		}
+	}
+
+	return tx, nil
+}
+
+// GetTransactionByID retrieves a single transaction
+func (r *AccountRepository) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
+
+	tx, err := scanTransaction(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("transaction not found")
+		}
+		r.logger.WithError(err).Error("Failed to get transaction")
+		return nil, err
+	}
+	return tx, nil
+}
+
 // GetTransactions retrieves transactions for an account within a date range
-func (r *AccountRepository) GetTransactions(accountID int64, startDate, endDate time.Time) ([]*models.Transaction, error) {
+func (r *AccountRepository) GetTransactions(ctx context.Context, accountID int64, startDate, endDate time.Time) ([]*models.Transaction, error) {
 	query := `
-		SELECT id, from_account_id, to_account_id, amount, type, created_at
+		SELECT ` + transactionColumns + `
 		FROM transactions
 		WHERE (from_account_id = $1 OR to_account_id = $1)
 		AND created_at >= $2
@@ -136,7 +812,7 @@ func (r *AccountRepository) GetTransactions(accountID int64, startDate, endDate
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, accountID, startDate, endDate)
+	rows, err := r.db.QueryContext(ctx, query, accountID, startDate, endDate)
 	if err != nil {
 		r.logger.WithError(err).Error("Failed to get transactions")
 		return nil, err
@@ -145,15 +821,7 @@ func (r *AccountRepository) GetTransactions(accountID int64, startDate, endDate
 
 	var transactions []*models.Transaction
 	for rows.Next() {
-		tx := &models.Transaction{}
-		err := rows.Scan(
-			&tx.ID,
-			&tx.FromAccountID,
-			&tx.ToAccountID,
-			&tx.Amount,
-			&tx.Type,
-			&tx.CreatedAt,
-		)
+		tx, err := scanTransaction(rows)
 		if err != nil {
 			r.logger.WithError(err).Error("Failed to scan transaction")
 			return nil, err
@@ -163,3 +831,211 @@ func (r *AccountRepository) GetTransactions(accountID int64, startDate, endDate
 
 	return transactions, nil
 }
+
+// ListTransactionsByAccounts retrieves the latest transactions of several
+// accounts matching the filter in one query, up to filter.Limit per account,
+// keyed by account ID. A transfer between two of the accounts is listed for both.
+func (r *AccountRepository) ListTransactionsByAccounts(ctx context.Context, accountIDs []int64, filter models.TransactionFilter) (map[int64][]*models.Transaction, error) {
+	conditions := []string{"(t.from_account_id = a.id OR t.to_account_id = a.id)"}
+	args := []interface{}{pq.Array(accountIDs), filter.Limit}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("t.type = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("t.created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("t.created_at <= $%d", len(args)))
+	}
+	if filter.MinAmount > 0 {
+		args = append(args, filter.MinAmount)
+		conditions = append(conditions, fmt.Sprintf("t.amount >= $%d", len(args)))
+	}
+	if filter.MaxAmount > 0 {
+		args = append(args, filter.MaxAmount)
+		conditions = append(conditions, fmt.Sprintf("t.amount <= $%d", len(args)))
+	}
+
+	query := `
+		SELECT ` + transactionColumns + `, listed_account_id
+		FROM (
+			SELECT t.*, a.id AS listed_account_id,
+				ROW_NUMBER() OVER (PARTITION BY a.id ORDER BY t.created_at DESC, t.id DESC) AS position
+			FROM unnest($1::bigint[]) AS a(id)
+			JOIN transactions t ON ` + strings.Join(conditions, " AND ") + `
+		) ranked
+		WHERE position <= $2
+		ORDER BY listed_account_id, created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list transactions by accounts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make(map[int64][]*models.Transaction, len(accountIDs))
+	for rows.Next() {
+		var accountID int64
+		tx, err := scanTransaction(&extraScanner{scanner: rows, extra: []interface{}{&accountID}})
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan transaction")
+			return nil, err
+		}
+		transactions[accountID] = append(transactions[accountID], tx)
+	}
+	return transactions, rows.Err()
+}
+
+// SetOverdraft updates the overdraft limit and interest rate of an account
+func (r *AccountRepository) SetOverdraft(ctx context.Context, id int64, limit, interestRate float64) error {
+	query := `
+		UPDATE accounts
+		SET overdraft_limit = $1, overdraft_interest_rate = $2, version = version + 1, updated_at = $3
+		WHERE id = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, limit, interestRate, time.Now(), id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to set account overdraft")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("account not found")
+	}
+
+	r.invalidate(ctx, id)
+	return nil
+}
+
+// GetWithOverdraftFacility retrieves all accounts that have an overdraft limit configured
+func (r *AccountRepository) GetWithOverdraftFacility(ctx context.Context) ([]*models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE overdraft_limit > 0 AND closed_at IS NULL
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get accounts with overdraft")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// ChargeOverdraftInterest debits accrued overdraft interest, posts it as a transaction
+// and moves the accrual watermark forward, all in one database transaction
+func (r *AccountRepository) ChargeOverdraftInterest(ctx context.Context, accountID int64, interest float64, accruedAt time.Time) error {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE accounts
+		SET overdraft_interest_accrued_at = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, accruedAt, accountID); err != nil {
+		r.logger.WithError(err).Error("Failed to move overdraft interest accrual")
+		return err
+	}
+
+	// Interest is charged even when it pushes the balance past the limit,
+	// so the limit check is not applied here.
+	if interest > 0 {
+		err := r.postTransaction(ctx, tx, &models.Transaction{
+			FromAccountID: accountID,
+			Amount:        interest,
+			Type:          models.TransactionTypeOverdraftInterest,
+			CreatedAt:     accruedAt,
+		})
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to charge overdraft interest")
+			return err
+		}
+	}
+
+	r.invalidateTx(ctx, tx, accountID)
+	return tx.Commit()
+}
+
+// GetTransactionTotalByType sums transactions of one type on an account within a date range
+func (r *AccountRepository) GetTransactionTotalByType(ctx context.Context, accountID int64, txType string, startDate, endDate time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE (from_account_id = $1 OR to_account_id = $1)
+		AND type = $2
+		AND created_at >= $3
+		AND created_at <= $4
+	`
+	var total float64
+	if err := r.db.QueryRowContext(ctx, query, accountID, txType, startDate, endDate).Scan(&total); err != nil {
+		r.logger.WithError(err).Error("Failed to sum transactions")
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetCardPaymentTotal sums the card payments made with a card since the given
+// time, counting the authorizations still holding funds as payments
+func (r *AccountRepository) GetCardPaymentTotal(ctx context.Context, cardID int64, since time.Time) (float64, error) {
+	return r.getCardPaymentTotal(ctx, r.db, cardID, since)
+}
+
+// GetCardPaymentTotalTx sums the card payments and holding authorizations of a
+// card since the given time within a database transaction
+func (r *AccountRepository) GetCardPaymentTotalTx(ctx context.Context, tx Tx, cardID int64, since time.Time) (float64, error) {
+	return r.getCardPaymentTotal(ctx, tx, cardID, since)
+}
+
+func (r *AccountRepository) getCardPaymentTotal(ctx context.Context, q queryer, cardID int64, since time.Time) (float64, error) {
+	query := `
+		SELECT
+			COALESCE((
+				SELECT SUM(amount) FROM transactions
+				WHERE card_id = $1 AND type = $2 AND created_at >= $3
+			), 0) +
+			COALESCE((
+				SELECT SUM(amount) FROM card_authorizations
+				WHERE card_id = $1 AND status = $4 AND expires_at > NOW() AND created_at >= $3
+			), 0)
+	`
+	var total float64
+	err := q.QueryRowContext(
+		ctx, query, cardID, models.TransactionTypeCardPayment, since, models.CardAuthorizationStatusPending,
+	).Scan(&total)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum card payments")
+		return 0, err
+	}
+	return total, nil
+}
+
+// nullableID maps a zero account reference to NULL so foreign keys are not violated
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// AccountingRepository reads the ledger for the exports to the accounting system
+type AccountingRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAccountingRepository creates a new AccountingRepository instance
+func NewAccountingRepository(db *sql.DB, logger *logrus.Logger) *AccountingRepository {
+	return &AccountingRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListEntries pairs the debit and credit ledger entries of the transactions
+// posted from from up to to and sums them up per day, transaction type, ledger
+// accounts and currency. Customer accounts carry the balance sheet account of
+// their number; the bank's own ledger accounts are left for the caller to map.
+func (r *AccountingRepository) ListEntries(ctx context.Context, from, to time.Time) ([]*models.AccountingEntry, error) {
+	query := `
+		SELECT DATE(d.created_at), t.type,
+			d.ledger_account, COALESCE(SUBSTRING(da.number FROM 1 FOR 5), ''),
+			c.ledger_account, COALESCE(SUBSTRING(ca.number FROM 1 FOR 5), ''),
+			d.currency, SUM(d.amount), COUNT(*)
+		FROM ledger_entries d
+		JOIN ledger_entries c ON c.transaction_id = d.transaction_id AND c.side = 'credit'
+		JOIN transactions t ON t.id = d.transaction_id
+		LEFT JOIN accounts da ON da.id = d.account_id
+		LEFT JOIN accounts ca ON ca.id = c.account_id
+		WHERE d.side = 'debit' AND d.created_at >= $1 AND d.created_at < $2
+		GROUP BY 1, 2, 3, 4, 5, 6, 7
+		ORDER BY 1, 2, 3, 4, 5, 6, 7
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list accounting entries")
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AccountingEntry{}
+	for rows.Next() {
+		entry := &models.AccountingEntry{}
+		if err := rows.Scan(
+			&entry.Date,
+			&entry.TransactionType,
+			&entry.DebitLedger,
+			&entry.DebitAccount,
+			&entry.CreditLedger,
+			&entry.CreditAccount,
+			&entry.Currency,
+			&entry.Amount,
+			&entry.Transactions,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// AlertRepository handles database operations for the alerts users set on their
+// accounts and the scan of posted transactions they are evaluated by
+type AlertRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAlertRepository creates a new AlertRepository instance
+func NewAlertRepository(db *sql.DB, logger *logrus.Logger) *AlertRepository {
+	return &AlertRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *AlertRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// Create stores a new alert
+func (r *AlertRepository) Create(ctx context.Context, alert *models.Alert) error {
+	query := `
+		INSERT INTO alerts (user_id, account_id, type, threshold, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, alert.UserID, alert.AccountID, alert.Type, alert.Threshold, alert.Enabled).
+		Scan(&alert.ID, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create alert")
+		return err
+	}
+	return nil
+}
+
+// alertColumns are qualified with the alias al, which every query gives alerts
+const alertColumns = `
+	al.id, al.user_id, al.account_id, al.type, al.threshold, al.enabled, al.triggered, al.last_triggered_at,
+	al.created_at, al.updated_at
+`
+
+func scanAlert(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Alert, error) {
+	alert := &models.Alert{}
+	var accountID sql.NullInt64
+	var threshold sql.NullFloat64
+	var lastTriggeredAt sql.NullTime
+
+	dest := append([]interface{}{
+		&alert.ID,
+		&alert.UserID,
+		&accountID,
+		&alert.Type,
+		&threshold,
+		&alert.Enabled,
+		&alert.Triggered,
+		&lastTriggeredAt,
+		&alert.CreatedAt,
+		&alert.UpdatedAt,
+	}, extra...)
+	if err := scanner.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if accountID.Valid {
+		alert.AccountID = &accountID.Int64
+	}
+	alert.Threshold = nullableFloat(threshold)
+	if lastTriggeredAt.Valid {
+		alert.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+	return alert, nil
+}
+
+// GetByID retrieves an alert of a user
+func (r *AlertRepository) GetByID(ctx context.Context, userID, id int64) (*models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts al WHERE al.id = $1 AND al.user_id = $2`
+
+	alert, err := scanAlert(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("alert not found")
+		}
+		r.logger.WithError(err).Error("Failed to get alert")
+		return nil, err
+	}
+	return alert, nil
+}
+
+// ListByUser retrieves a page of the alerts of a user and how many there are
+func (r *AlertRepository) ListByUser(ctx context.Context, userID int64, p pagination.Params) ([]*models.Alert, int, error) {
+	rows, total, err := queryPage(ctx, r.db, alertColumns, "alerts al", "al.user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list alerts")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	alerts := []*models.Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, total, rows.Err()
+}
+
+// Update stores the threshold of an alert and whether it is enabled
+func (r *AlertRepository) Update(ctx context.Context, alert *models.Alert) error {
+	query := `
+		UPDATE alerts
+		SET threshold = $1, enabled = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND user_id = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, alert.Threshold, alert.Enabled, alert.ID, alert.UserID).Scan(&alert.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("alert not found")
+		}
+		r.logger.WithError(err).Errorf("Failed to update alert %d", alert.ID)
+		return err
+	}
+	return nil
+}
+
+// Delete removes an alert of a user
+func (r *AlertRepository) Delete(ctx context.Context, userID, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM alerts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to delete alert %d", id)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("alert not found")
+	}
+	return nil
+}
+
+// LastScanned returns the ID of the last transaction the alerts were evaluated for
+func (r *AlertRepository) LastScanned(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT last_transaction_id FROM alert_scan_state WHERE id = 1`).Scan(&id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get alert scan state")
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListTransactionsAfter retrieves up to limit transactions following the given
+// ID and posted before before, oldest first, with the merchant country of the
+// card payments
+func (r *AlertRepository) ListTransactionsAfter(ctx context.Context, afterID int64, before time.Time, limit int) ([]*models.AlertTransaction, error) {
+	query := `
+		SELECT t.id, t.type, COALESCE(t.from_account_id, 0), COALESCE(t.to_account_id, 0), t.amount, a.currency,
+			COALESCE(t.description, ''), COALESCE(ca.merchant_country, ''), t.created_at
+		FROM transactions t
+		JOIN accounts a ON a.id = COALESCE(t.from_account_id, t.to_account_id)
+		LEFT JOIN card_authorizations ca ON ca.transaction_id = t.id
+		WHERE t.id > $1 AND t.created_at < $2
+		ORDER BY t.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, before, limit)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list transactions for alerts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*models.AlertTransaction
+	for rows.Next() {
+		t := &models.AlertTransaction{}
+		if err := rows.Scan(&t.ID, &t.Type, &t.FromAccountID, &t.ToAccountID, &t.Amount, &t.Currency,
+			&t.Description, &t.MerchantCountry, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// ListForAccounts retrieves the enabled transaction alerts watching any of the
+// given accounts, by account. An alert set on no account watches every account
+// its user owns.
+func (r *AlertRepository) ListForAccounts(ctx context.Context, accountIDs []int64) (map[int64][]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `, a.id
+		FROM alerts al
+		JOIN accounts a ON a.id = al.account_id OR (al.account_id IS NULL AND a.user_id = al.user_id)
+		WHERE a.id = ANY($1) AND al.enabled AND al.type <> $2
+		ORDER BY al.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(accountIDs), models.AlertTypeBalanceBelow)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list alerts of accounts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make(map[int64][]*models.Alert)
+	for rows.Next() {
+		var accountID int64
+		alert, err := scanAlert(rows, &accountID)
+		if err != nil {
+			return nil, err
+		}
+		alerts[accountID] = append(alerts[accountID], alert)
+	}
+	return alerts, rows.Err()
+}
+
+// ListBalanceCrossings retrieves the enabled balance alerts whose account
+// balance is below the threshold while they are not triggered, or no longer
+// below it while they are
+func (r *AlertRepository) ListBalanceCrossings(ctx context.Context) ([]*models.AlertBalance, error) {
+	query := `
+		SELECT ` + alertColumns + `, a.balance, a.currency
+		FROM alerts al
+		JOIN accounts a ON a.id = al.account_id
+		WHERE al.type = $1 AND al.enabled AND (a.balance < al.threshold) <> al.triggered
+		ORDER BY al.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.AlertTypeBalanceBelow)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list balance alert crossings")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crossings []*models.AlertBalance
+	for rows.Next() {
+		crossing := &models.AlertBalance{}
+		alert, err := scanAlert(rows, &crossing.Balance, &crossing.Currency)
+		if err != nil {
+			return nil, err
+		}
+		crossing.Alert = alert
+		crossings = append(crossings, crossing)
+	}
+	return crossings, rows.Err()
+}
+
+// SetTriggeredTx records within a database transaction that a balance alert
+// fired or that the balance recovered, unless another run recorded it first.
+// It reports whether the alert was updated.
+func (r *AlertRepository) SetTriggeredTx(ctx context.Context, tx Tx, alert *models.Alert, at time.Time) (bool, error) {
+	query := `
+		UPDATE alerts
+		SET triggered = $1, last_triggered_at = CASE WHEN $1 THEN $2 ELSE last_triggered_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND triggered <> $1
+	`
+
+	result, err := tx.ExecContext(ctx, query, alert.Triggered, at, alert.ID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update alert %d", alert.ID)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SaveScanTx records within a database transaction when the transaction alerts
+// that fired in a scan were last triggered and moves the scan state to the last
+// transaction scanned
+func (r *AlertRepository) SaveScanTx(ctx context.Context, tx Tx, fired map[int64]time.Time, lastTransactionID int64) error {
+	for id, at := range fired {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE alerts SET last_triggered_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+		`, at, id); err != nil {
+			r.logger.WithError(err).Errorf("Failed to update alert %d", id)
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE alert_scan_state SET last_transaction_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = 1
+	`, lastTransactionID); err != nil {
+		r.logger.WithError(err).Error("Failed to update alert scan state")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// AMLRepository handles database operations for the anti-money laundering
+// monitoring: the scan of the posted transactions and the flags it raises
+type AMLRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAMLRepository creates a new AMLRepository instance
+func NewAMLRepository(db *sql.DB, logger *logrus.Logger) *AMLRepository {
+	return &AMLRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// LastScanned returns the ID of the last transaction scanned
+func (r *AMLRepository) LastScanned(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT last_transaction_id FROM aml_scan_state WHERE id = 1`).Scan(&id)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get AML scan state")
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListTransactionsAfter retrieves up to limit transactions following the given
+// ID and posted before before, oldest first. Each is monitored for the account
+// money leaves, or the account it arrives at for deposits.
+func (r *AMLRepository) ListTransactionsAfter(ctx context.Context, afterID int64, before time.Time, limit int) ([]*models.AMLTransaction, error) {
+	query := `
+		SELECT t.id, t.type, a.id, a.user_id, t.amount, a.currency, t.from_account_id IS NOT NULL, t.created_at
+		FROM transactions t
+		JOIN accounts a ON a.id = COALESCE(t.from_account_id, t.to_account_id)
+		WHERE t.id > $1 AND t.created_at < $2
+		ORDER BY t.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, before, limit)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list transactions for AML scan")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*models.AMLTransaction
+	for rows.Next() {
+		t := &models.AMLTransaction{}
+		if err := rows.Scan(&t.ID, &t.Type, &t.AccountID, &t.UserID, &t.Amount, &t.Currency, &t.Outgoing, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// CountSimilar counts the transactions monitored for the user's accounts in a
+// currency with an amount from min up to max, posted from since up to until
+func (r *AMLRepository) CountSimilar(ctx context.Context, userID int64, currency string, min, max float64, since, until time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM transactions t
+		JOIN accounts a ON a.id = COALESCE(t.from_account_id, t.to_account_id)
+		WHERE a.user_id = $1 AND a.currency = $2 AND t.amount >= $3 AND t.amount < $4
+			AND t.created_at >= $5 AND t.created_at <= $6
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID, currency, min, max, since, until).Scan(&count); err != nil {
+		r.logger.WithError(err).Error("Failed to count similar transactions")
+		return 0, err
+	}
+	return count, nil
+}
+
+// IncomingTotal sums the transactions to an account posted from since up to
+// before until
+func (r *AMLRepository) IncomingTotal(ctx context.Context, accountID int64, since, until time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE to_account_id = $1 AND created_at >= $2 AND created_at < $3
+	`
+
+	var total float64
+	if err := r.db.QueryRowContext(ctx, query, accountID, since, until).Scan(&total); err != nil {
+		r.logger.WithError(err).Error("Failed to sum incoming transactions")
+		return 0, err
+	}
+	return total, nil
+}
+
+// SaveScan stores the flags raised by a scan and moves the scan state to the
+// last transaction scanned, in one database transaction. A transaction already
+// flagged by a rule keeps its flag.
+func (r *AMLRepository) SaveScan(ctx context.Context, flags []*models.AMLFlag, lastTransactionID int64) error {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO aml_flags (transaction_id, user_id, account_id, rule, reason, amount, currency, amount_rub, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (transaction_id, rule) DO NOTHING
+	`
+	for _, flag := range flags {
+		if _, err := tx.ExecContext(ctx, query, flag.TransactionID, nullableID(flag.UserID), flag.AccountID, flag.Rule,
+			flag.Reason, flag.Amount, flag.Currency, flag.AmountRUB, flag.Status); err != nil {
+			r.logger.WithError(err).Errorf("Failed to flag transaction %d", flag.TransactionID)
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE aml_scan_state SET last_transaction_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = 1
+	`, lastTransactionID); err != nil {
+		r.logger.WithError(err).Error("Failed to update AML scan state")
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// amlFlagColumns are qualified with the alias f, which every query gives aml_flags
+const amlFlagColumns = `
+	f.id, f.transaction_id, COALESCE(f.user_id, 0), f.account_id, f.rule, f.reason, f.amount, f.currency,
+	f.amount_rub, f.status, f.reviewer_id, COALESCE(f.review_comment, ''), f.reviewed_at, f.created_at, f.updated_at
+`
+
+func scanAMLFlag(scanner interface{ Scan(...interface{}) error }) (*models.AMLFlag, error) {
+	flag := &models.AMLFlag{}
+	var reviewerID sql.NullInt64
+	var reviewedAt sql.NullTime
+
+	err := scanner.Scan(
+		&flag.ID,
+		&flag.TransactionID,
+		&flag.UserID,
+		&flag.AccountID,
+		&flag.Rule,
+		&flag.Reason,
+		&flag.Amount,
+		&flag.Currency,
+		&flag.AmountRUB,
+		&flag.Status,
+		&reviewerID,
+		&flag.ReviewComment,
+		&reviewedAt,
+		&flag.CreatedAt,
+		&flag.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reviewerID.Valid {
+		flag.ReviewerID = &reviewerID.Int64
+	}
+	if reviewedAt.Valid {
+		flag.ReviewedAt = &reviewedAt.Time
+	}
+	return flag, nil
+}
+
+// GetByID retrieves an AML flag
+func (r *AMLRepository) GetByID(ctx context.Context, id int64) (*models.AMLFlag, error) {
+	query := `SELECT ` + amlFlagColumns + ` FROM aml_flags f WHERE f.id = $1`
+
+	flag, err := scanAMLFlag(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("aml flag not found")
+		}
+		r.logger.WithError(err).Error("Failed to get AML flag")
+		return nil, err
+	}
+	return flag, nil
+}
+
+// List retrieves a page of the AML flags, optionally of one rule, and how many
+// there are
+func (r *AMLRepository) List(ctx context.Context, rule string, p pagination.Params) ([]*models.AMLFlag, int, error) {
+	rows, total, err := queryPage(ctx, r.db, amlFlagColumns, "aml_flags f", "($1 = '' OR rule = $1)", p, rule)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list AML flags")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	flags := []*models.AMLFlag{}
+	for rows.Next() {
+		flag, err := scanAMLFlag(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, total, rows.Err()
+}
+
+// UpdateReview stores the status and review of a flag, provided it still has
+// the status it was read with. It returns false when another reviewer changed
+// the flag in the meantime.
+func (r *AMLRepository) UpdateReview(ctx context.Context, flag *models.AMLFlag, previous models.AMLFlagStatus) (bool, error) {
+	query := `
+		UPDATE aml_flags
+		SET status = $1, reviewer_id = $2, review_comment = NULLIF($3, ''), reviewed_at = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND status = $6
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, flag.Status, flag.ReviewerID, flag.ReviewComment, flag.ReviewedAt,
+		flag.ID, previous).Scan(&flag.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update AML flag %d", flag.ID)
+		return false, err
+	}
+	return true, nil
+}
+
+// ListReported retrieves the flags reported from from up to to with their
+// transaction and account numbers, oldest first. The client name is left to
+// the caller, who can decrypt it.
+func (r *AMLRepository) ListReported(ctx context.Context, from, to time.Time) ([]*models.AMLReportItem, error) {
+	query := `
+		SELECT ` + amlFlagColumns + `,
+			t.type, t.created_at, COALESCE(a.number, ''), COALESCE(c.number, '')
+		FROM aml_flags f
+		JOIN transactions t ON t.id = f.transaction_id
+		JOIN accounts a ON a.id = f.account_id
+		LEFT JOIN accounts c ON c.id = CASE WHEN t.from_account_id = f.account_id THEN t.to_account_id ELSE t.from_account_id END
+		WHERE f.status = $1 AND f.reviewed_at >= $2 AND f.reviewed_at < $3
+		ORDER BY f.reviewed_at, f.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.AMLFlagStatusReported, from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list reported AML flags")
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.AMLReportItem{}
+	for rows.Next() {
+		item := &models.AMLReportItem{}
+		item.Flag, err = scanAMLFlag(&extraScanner{
+			scanner: rows,
+			extra:   []interface{}{&item.TransactionType, &item.TransactionDate, &item.AccountNumber, &item.CounterpartNumber},
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// extraScanner scans the columns following those of a row scan function into
+// extra destinations
+type extraScanner struct {
+	scanner interface{ Scan(...interface{}) error }
+	extra   []interface{}
+}
+
+func (s *extraScanner) Scan(dest ...interface{}) error {
+	return s.scanner.Scan(append(dest, s.extra...)...)
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyRepository handles database operations for the API keys of
+// machine-to-machine clients
+type APIKeyRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository instance
+func NewAPIKeyRepository(db *sql.DB, logger *logrus.Logger) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *APIKeyRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+const apiKeyColumns = `
+	k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scope, k.requests_per_hour, k.burst_size,
+	k.rotated_from, k.created_by, k.last_used_at, k.expires_at, k.revoked_at, k.created_at
+`
+
+// activeAPIKeys selects the keys that are still accepted
+const activeAPIKeys = "k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)"
+
+// apiKeyTouchInterval is how stale the last use of a key may get before a
+// request records it again, which spares a write on every request
+const apiKeyTouchInterval = time.Minute
+
+func scanAPIKey(scanner interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var rotatedFrom sql.NullInt64
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+
+	err := scanner.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scope,
+		&key.RequestsPerHour,
+		&key.BurstSize,
+		&rotatedFrom,
+		&key.CreatedBy,
+		&lastUsedAt,
+		&expiresAt,
+		&revokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if rotatedFrom.Valid {
+		key.RotatedFrom = &rotatedFrom.Int64
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// CreateTx stores a new key within a database transaction
+func (r *APIKeyRepository) CreateTx(ctx context.Context, tx Tx, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (
+			user_id, name, prefix, key_hash, scope, requests_per_hour, burst_size,
+			rotated_from, created_by, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.Scope,
+		key.RequestsPerHour,
+		key.BurstSize,
+		key.RotatedFrom,
+		key.CreatedBy,
+		key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create API key")
+		return err
+	}
+	return nil
+}
+
+// GetByIDForUpdate retrieves a key and locks it until the transaction ends
+func (r *APIKeyRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.APIKey, error) {
+	key, err := scanAPIKey(tx.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys k WHERE k.id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("API key not found")
+		}
+		r.logger.WithError(err).Error("Failed to get API key")
+		return nil, err
+	}
+	return key, nil
+}
+
+// List lists the keys of a user, or of all users for a zero user ID, newest first
+func (r *APIKeyRepository) List(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		WHERE $1 = 0 OR k.user_id = $1
+		ORDER BY k.created_at DESC, k.id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list API keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Touch returns the active key with the hash and records its use. Keys of users
+// who are no longer active are not returned.
+func (r *APIKeyRepository) Touch(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND ` + activeAPIKeys + ` AND u.status = $2
+	`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash, models.StatusActive))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyTouchInterval {
+		if _, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, key.ID); err != nil {
+			r.logger.WithError(err).Warn("Failed to record API key use")
+		}
+	}
+	return key, nil
+}
+
+// RevokeTx revokes a key within a database transaction
+func (r *APIKeyRepository) RevokeTx(ctx context.Context, tx Tx, key *models.APIKey) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING revoked_at`
+	if err := tx.QueryRowContext(ctx, query, key.ID).Scan(&key.RevokedAt); err != nil {
+		r.logger.WithError(err).Error("Failed to revoke API key")
+		return err
+	}
+	return nil
+}
+
+// ExpireTx brings the expiry of a key forward to at within a database
+// transaction; a key already expiring earlier keeps its expiry
+func (r *APIKeyRepository) ExpireTx(ctx context.Context, tx Tx, key *models.APIKey, at time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1
+		RETURNING expires_at
+	`
+	if err := tx.QueryRowContext(ctx, query, key.ID, at).Scan(&key.ExpiresAt); err != nil {
+		r.logger.WithError(err).Error("Failed to expire API key")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditRepository handles database operations for the audit log
+type AuditRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewAuditRepository creates a new AuditRepository instance
+func NewAuditRepository(db *sql.DB, logger *logrus.Logger) *AuditRepository {
+	return &AuditRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.create(ctx, r.db, entry)
+}
+
+// CreateTx appends an entry to the audit log within a database transaction
+func (r *AuditRepository) CreateTx(ctx context.Context, tx Tx, entry *models.AuditLog) error {
+	return r.create(ctx, tx, entry)
+}
+
+func (r *AuditRepository) create(ctx context.Context, q queryer, entry *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	var details interface{}
+	if len(entry.Details) > 0 {
+		details = []byte(entry.Details)
+	}
+
+	err := q.QueryRowContext(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		details,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create audit log entry")
+		return err
+	}
+
+	return nil
+}
+
+// GetByEntity retrieves the audit trail of an entity, oldest first
+func (r *AuditRepository) GetByEntity(ctx context.Context, entityType string, entityID int64) ([]*models.AuditLog, error) {
+	query := `
+		SELECT id, COALESCE(actor_id, 0), action, entity_type, entity_id, details, created_at
+		FROM audit_log
+		WHERE entity_type = $1 AND entity_id = $2
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, entityType, entityID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get audit log")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditLog
+	for rows.Next() {
+		entry := &models.AuditLog{}
+		var details []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&details,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan audit log row")
+			return nil, err
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
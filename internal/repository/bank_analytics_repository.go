@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// BankAnalyticsRepository sums up the portfolio of the whole bank in SQL
+type BankAnalyticsRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewBankAnalyticsRepository creates a new BankAnalyticsRepository instance
+func NewBankAnalyticsRepository(db *sql.DB, logger *logrus.Logger) *BankAnalyticsRepository {
+	return &BankAnalyticsRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetAnalytics sums up the deposits and the credits of the bank, counting the
+// users registered and the transactions posted from from up to to. The ratios
+// and the days without new users are left to the caller.
+func (r *BankAnalyticsRepository) GetAnalytics(ctx context.Context, from, to time.Time) (*models.BankAnalytics, error) {
+	analytics := &models.BankAnalytics{
+		From:              from,
+		To:                to,
+		Deposits:          []*models.BankDepositStats{},
+		Credits:           []*models.BankCreditStats{},
+		NewUsers:          []*models.DailyCount{},
+		TransactionVolume: []*models.TransactionVolumeStats{},
+	}
+
+	depositsQuery := `
+		SELECT currency, SUM(balances), SUM(deposits)
+		FROM (
+			SELECT currency, SUM(balance) FILTER (WHERE balance > 0) AS balances, 0 AS deposits
+			FROM accounts
+			GROUP BY currency
+			UNION ALL
+			SELECT currency, 0, SUM(amount + accrued_interest)
+			FROM deposits
+			WHERE status = $1
+			GROUP BY currency
+		) totals
+		GROUP BY currency
+		ORDER BY currency
+	`
+	rows, err := r.db.QueryContext(ctx, depositsQuery, models.DepositStatusActive)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up deposits")
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		stats := &models.BankDepositStats{}
+		var balances sql.NullFloat64
+		if err := rows.Scan(&stats.Currency, &balances, &stats.TermDeposits); err != nil {
+			return nil, err
+		}
+		stats.AccountBalances = balances.Float64
+		analytics.Deposits = append(analytics.Deposits, stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	creditsQuery := `
+		SELECT a.currency, COUNT(*), COALESCE(SUM(c.remaining_amount), 0),
+			COUNT(o.credit_id), COALESCE(SUM(c.remaining_amount) FILTER (WHERE o.credit_id IS NOT NULL), 0),
+			COALESCE(SUM(o.amount_due), 0)
+		FROM credits c
+		JOIN accounts a ON a.id = c.account_id
+		LEFT JOIN (
+			SELECT credit_id, SUM(GREATEST(amount + penalty - paid_amount, 0)) AS amount_due
+			FROM payment_schedules
+			WHERE status = $4
+			GROUP BY credit_id
+		) o ON o.credit_id = c.id
+		WHERE c.status IN ($1, $2, $3)
+		GROUP BY a.currency
+		ORDER BY a.currency
+	`
+	creditRows, err := r.db.QueryContext(ctx, creditsQuery, models.CreditStatusActive, models.CreditStatusDelinquent,
+		models.CreditStatusCollections, models.PaymentStatusOverdue)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up credits")
+		return nil, err
+	}
+	defer creditRows.Close()
+	for creditRows.Next() {
+		stats := &models.BankCreditStats{}
+		if err := creditRows.Scan(
+			&stats.Currency,
+			&stats.Active,
+			&stats.Outstanding,
+			&stats.Overdue,
+			&stats.OverdueOutstanding,
+			&stats.OverdueAmount,
+		); err != nil {
+			return nil, err
+		}
+		analytics.Credits = append(analytics.Credits, stats)
+	}
+	if err := creditRows.Err(); err != nil {
+		return nil, err
+	}
+
+	usersQuery := `
+		SELECT TO_CHAR(created_at, 'YYYY-MM-DD') AS day, COUNT(*)
+		FROM users
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY day
+		ORDER BY day
+	`
+	userRows, err := r.db.QueryContext(ctx, usersQuery, from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to count new users")
+		return nil, err
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		count := &models.DailyCount{}
+		if err := userRows.Scan(&count.Date, &count.Count); err != nil {
+			return nil, err
+		}
+		analytics.NewUsers = append(analytics.NewUsers, count)
+	}
+	if err := userRows.Err(); err != nil {
+		return nil, err
+	}
+
+	volumeQuery := `
+		SELECT COALESCE(src.currency, dst.currency) AS currency, COUNT(*), COALESCE(SUM(t.amount), 0)
+		FROM transactions t
+		LEFT JOIN accounts src ON src.id = t.from_account_id
+		LEFT JOIN accounts dst ON dst.id = t.to_account_id
+		WHERE t.created_at >= $1 AND t.created_at < $2
+		GROUP BY 1
+		ORDER BY 1
+	`
+	volumeRows, err := r.db.QueryContext(ctx, volumeQuery, from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up transaction volume")
+		return nil, err
+	}
+	defer volumeRows.Close()
+	for volumeRows.Next() {
+		stats := &models.TransactionVolumeStats{}
+		if err := volumeRows.Scan(&stats.Currency, &stats.Transactions, &stats.Amount); err != nil {
+			return nil, err
+		}
+		analytics.TransactionVolume = append(analytics.TransactionVolume, stats)
+	}
+	return analytics, volumeRows.Err()
+}
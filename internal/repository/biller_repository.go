@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// BillerRepository handles database operations for the biller catalog and the
+// bill payments made to its billers
+type BillerRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewBillerRepository creates a new BillerRepository instance
+func NewBillerRepository(db *sql.DB, logger *logrus.Logger) *BillerRepository {
+	return &BillerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create adds a biller to the catalog
+func (r *BillerRepository) Create(ctx context.Context, biller *models.Biller) error {
+	query := `
+		INSERT INTO billers (name, category, provider, provider_code, account_label, account_pattern, currency,
+			min_amount, max_amount, active)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, biller.Name, biller.Category, biller.Provider, biller.ProviderCode,
+		biller.AccountLabel, biller.AccountPattern, biller.Currency, biller.MinAmount, biller.MaxAmount, biller.Active,
+	).Scan(&biller.ID, &biller.CreatedAt, &biller.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("biller already exists for the provider code")
+		}
+		r.logger.WithError(err).Error("Failed to create biller")
+		return err
+	}
+	return nil
+}
+
+const billerColumns = `
+	id, name, category, provider, provider_code, account_label, COALESCE(account_pattern, ''), currency,
+	min_amount, max_amount, active, created_at, updated_at
+`
+
+func scanBiller(scanner interface{ Scan(...interface{}) error }) (*models.Biller, error) {
+	biller := &models.Biller{}
+	err := scanner.Scan(
+		&biller.ID,
+		&biller.Name,
+		&biller.Category,
+		&biller.Provider,
+		&biller.ProviderCode,
+		&biller.AccountLabel,
+		&biller.AccountPattern,
+		&biller.Currency,
+		&biller.MinAmount,
+		&biller.MaxAmount,
+		&biller.Active,
+		&biller.CreatedAt,
+		&biller.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return biller, nil
+}
+
+// GetByID retrieves a biller of the catalog
+func (r *BillerRepository) GetByID(ctx context.Context, id int64) (*models.Biller, error) {
+	query := `SELECT ` + billerColumns + ` FROM billers WHERE id = $1`
+
+	biller, err := scanBiller(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("biller not found")
+		}
+		r.logger.WithError(err).Error("Failed to get biller")
+		return nil, err
+	}
+	return biller, nil
+}
+
+// List retrieves the billers of the catalog by name, of a category unless it is
+// empty and only the active ones unless all is set
+func (r *BillerRepository) List(ctx context.Context, category string, all bool) ([]*models.Biller, error) {
+	query := `
+		SELECT ` + billerColumns + ` FROM billers
+		WHERE ($1 = '' OR category = $1) AND ($2 OR active)
+		ORDER BY name, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, category, all)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list billers")
+		return nil, err
+	}
+	defer rows.Close()
+
+	billers := []*models.Biller{}
+	for rows.Next() {
+		biller, err := scanBiller(rows)
+		if err != nil {
+			return nil, err
+		}
+		billers = append(billers, biller)
+	}
+	return billers, rows.Err()
+}
+
+// Update stores the details of a biller
+func (r *BillerRepository) Update(ctx context.Context, biller *models.Biller) error {
+	query := `
+		UPDATE billers
+		SET name = $1, category = $2, provider = $3, provider_code = $4, account_label = $5,
+			account_pattern = NULLIF($6, ''), currency = $7, min_amount = $8, max_amount = $9, active = $10,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $11
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, biller.Name, biller.Category, biller.Provider, biller.ProviderCode,
+		biller.AccountLabel, biller.AccountPattern, biller.Currency, biller.MinAmount, biller.MaxAmount, biller.Active,
+		biller.ID,
+	).Scan(&biller.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("biller not found")
+		}
+		if isUniqueViolation(err) {
+			return errors.New("biller already exists for the provider code")
+		}
+		r.logger.WithError(err).Errorf("Failed to update biller %d", biller.ID)
+		return err
+	}
+	return nil
+}
+
+// CreatePaymentTx saves a new bill payment within a database transaction
+func (r *BillerRepository) CreatePaymentTx(ctx context.Context, tx Tx, payment *models.BillPayment) error {
+	query := `
+		INSERT INTO bill_payments (user_id, account_id, biller_id, biller_name, customer_account, amount, currency,
+			status, transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, payment.UserID, payment.AccountID, payment.BillerID, payment.BillerName,
+		payment.CustomerAccount, payment.Amount, payment.Currency, payment.Status, payment.TransactionID,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create bill payment")
+		return err
+	}
+	return nil
+}
+
+const billPaymentColumns = `
+	id, user_id, account_id, biller_id, biller_name, customer_account, amount, currency, status, transaction_id,
+	refund_transaction_id, COALESCE(provider_reference, ''), COALESCE(failure_reason, ''), created_at, updated_at
+`
+
+func scanBillPayment(scanner interface{ Scan(...interface{}) error }) (*models.BillPayment, error) {
+	payment := &models.BillPayment{}
+	var refundTransactionID sql.NullInt64
+
+	err := scanner.Scan(
+		&payment.ID,
+		&payment.UserID,
+		&payment.AccountID,
+		&payment.BillerID,
+		&payment.BillerName,
+		&payment.CustomerAccount,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.Status,
+		&payment.TransactionID,
+		&refundTransactionID,
+		&payment.ProviderReference,
+		&payment.FailureReason,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if refundTransactionID.Valid {
+		payment.RefundTransactionID = &refundTransactionID.Int64
+	}
+	return payment, nil
+}
+
+// GetPayment retrieves a bill payment of a user. A payment of another user is
+// reported as not found.
+func (r *BillerRepository) GetPayment(ctx context.Context, userID, id int64) (*models.BillPayment, error) {
+	query := `SELECT ` + billPaymentColumns + ` FROM bill_payments WHERE id = $1 AND user_id = $2`
+
+	payment, err := scanBillPayment(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("bill payment not found")
+		}
+		r.logger.WithError(err).Error("Failed to get bill payment")
+		return nil, err
+	}
+	return payment, nil
+}
+
+// GetPaymentForUpdate retrieves and locks a bill payment within a database transaction
+func (r *BillerRepository) GetPaymentForUpdate(ctx context.Context, tx Tx, id int64) (*models.BillPayment, error) {
+	query := `SELECT ` + billPaymentColumns + ` FROM bill_payments WHERE id = $1 FOR UPDATE`
+
+	payment, err := scanBillPayment(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("bill payment not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock bill payment")
+		return nil, err
+	}
+	return payment, nil
+}
+
+// ListPayments retrieves a page of the bill payments of a user and how many there are
+func (r *BillerRepository) ListPayments(ctx context.Context, userID int64, p pagination.Params) ([]*models.BillPayment, int, error) {
+	rows, total, err := queryPage(ctx, r.db, billPaymentColumns, "bill_payments", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list bill payments")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	payments := []*models.BillPayment{}
+	for rows.Next() {
+		payment, err := scanBillPayment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, total, rows.Err()
+}
+
+// UpdatePaymentTx stores the outcome of a bill payment within a database transaction
+func (r *BillerRepository) UpdatePaymentTx(ctx context.Context, tx Tx, payment *models.BillPayment) error {
+	query := `
+		UPDATE bill_payments
+		SET status = $1, refund_transaction_id = $2, provider_reference = NULLIF($3, ''),
+			failure_reason = NULLIF($4, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, payment.Status, payment.RefundTransactionID, payment.ProviderReference,
+		payment.FailureReason, payment.ID,
+	).Scan(&payment.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update bill payment %d", payment.ID)
+		return err
+	}
+	return nil
+}
+
+// BeginTransaction starts a new database transaction
+func (r *BillerRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
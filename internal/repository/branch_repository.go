@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBranchCodeTaken is returned when a branch is created with the code of another
+var ErrBranchCodeTaken = errors.New("branch code already exists")
+
+// BranchRepository handles database operations for the branches of the bank
+type BranchRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewBranchRepository creates a new BranchRepository instance
+func NewBranchRepository(db *sql.DB, logger *logrus.Logger) *BranchRepository {
+	return &BranchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const branchColumns = `id, code, name, COALESCE(address, ''), is_active, created_at, updated_at`
+
+func scanBranch(scanner interface{ Scan(...interface{}) error }) (*models.Branch, error) {
+	branch := &models.Branch{}
+	err := scanner.Scan(
+		&branch.ID,
+		&branch.Code,
+		&branch.Name,
+		&branch.Address,
+		&branch.Active,
+		&branch.CreatedAt,
+		&branch.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// Create opens a branch
+func (r *BranchRepository) Create(ctx context.Context, branch *models.Branch) error {
+	query := `
+		INSERT INTO branches (code, name, address, is_active, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, branch.Code, branch.Name, branch.Address, branch.Active).
+		Scan(&branch.ID, &branch.CreatedAt, &branch.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrBranchCodeTaken
+	}
+	return err
+}
+
+// GetByID retrieves a branch
+func (r *BranchRepository) GetByID(ctx context.Context, id int64) (*models.Branch, error) {
+	query := `SELECT ` + branchColumns + ` FROM branches WHERE id = $1`
+	return r.getBranch(ctx, query, id)
+}
+
+// GetByCode retrieves a branch by its code
+func (r *BranchRepository) GetByCode(ctx context.Context, code string) (*models.Branch, error) {
+	query := `SELECT ` + branchColumns + ` FROM branches WHERE code = $1`
+	return r.getBranch(ctx, query, code)
+}
+
+func (r *BranchRepository) getBranch(ctx context.Context, query string, arg interface{}) (*models.Branch, error) {
+	branch, err := scanBranch(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("branch not found")
+		}
+		r.logger.WithError(err).Error("Failed to get branch")
+		return nil, err
+	}
+	return branch, nil
+}
+
+// List retrieves the branches ordered by code, only the active ones if asked
+func (r *BranchRepository) List(ctx context.Context, activeOnly bool) ([]*models.Branch, error) {
+	query := `
+		SELECT ` + branchColumns + `
+		FROM branches
+		WHERE is_active OR NOT $1
+		ORDER BY code
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, activeOnly)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list branches")
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []*models.Branch{}
+	for rows.Next() {
+		branch, err := scanBranch(rows)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+// Update stores the name, address and status of a branch
+func (r *BranchRepository) Update(ctx context.Context, branch *models.Branch) error {
+	query := `
+		UPDATE branches
+		SET name = $1, address = NULLIF($2, ''), is_active = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, branch.Name, branch.Address, branch.Active, branch.ID).Scan(&branch.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("branch not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// GetReport sums up the users, accounts and credits of a branch, counting those
+// opened from from up to to. The branch of the report is left to the caller.
+func (r *BranchRepository) GetReport(ctx context.Context, branchID int64, from, to time.Time) (*models.BranchReport, error) {
+	report := &models.BranchReport{
+		From:     from,
+		To:       to,
+		Accounts: []*models.BranchAccountStats{},
+		Credits:  []*models.BranchCreditStats{},
+	}
+
+	usersQuery := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = $2),
+			COUNT(*) FILTER (WHERE status = $3),
+			COUNT(*) FILTER (WHERE status = $4),
+			COUNT(*) FILTER (WHERE created_at >= $5 AND created_at < $6)
+		FROM users
+		WHERE branch_id = $1
+	`
+	err := r.db.QueryRowContext(ctx, usersQuery, branchID, models.StatusActive, models.StatusBlocked,
+		models.StatusClosed, from, to).Scan(
+		&report.Users.Total,
+		&report.Users.Active,
+		&report.Users.Blocked,
+		&report.Users.Closed,
+		&report.Users.Registered,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to count branch users")
+		return nil, err
+	}
+
+	accountsQuery := `
+		SELECT currency, COUNT(*), COALESCE(SUM(balance), 0),
+			COUNT(*) FILTER (WHERE created_at >= $2 AND created_at < $3)
+		FROM accounts
+		WHERE branch_id = $1
+		GROUP BY currency
+		ORDER BY currency
+	`
+	rows, err := r.db.QueryContext(ctx, accountsQuery, branchID, from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up branch accounts")
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		stats := &models.BranchAccountStats{}
+		if err := rows.Scan(&stats.Currency, &stats.Accounts, &stats.Balance, &stats.Opened); err != nil {
+			return nil, err
+		}
+		report.Accounts = append(report.Accounts, stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	creditsQuery := `
+		SELECT a.currency,
+			COUNT(*) FILTER (WHERE c.status IN ($2, $6, $7)),
+			COALESCE(SUM(c.remaining_amount) FILTER (WHERE c.status IN ($2, $6, $7)), 0),
+			COUNT(*) FILTER (WHERE c.status IN ($2, $6, $7) AND EXISTS (
+				SELECT 1 FROM payment_schedules p WHERE p.credit_id = c.id AND p.status = $3
+			)),
+			COUNT(*) FILTER (WHERE c.created_at >= $4 AND c.created_at < $5),
+			COALESCE(SUM(c.amount) FILTER (WHERE c.created_at >= $4 AND c.created_at < $5), 0)
+		FROM credits c
+		JOIN accounts a ON a.id = c.account_id
+		WHERE c.branch_id = $1
+		GROUP BY a.currency
+		ORDER BY a.currency
+	`
+	creditRows, err := r.db.QueryContext(ctx, creditsQuery, branchID, models.CreditStatusActive,
+		models.PaymentStatusOverdue, from, to, models.CreditStatusDelinquent, models.CreditStatusCollections)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up branch credits")
+		return nil, err
+	}
+	defer creditRows.Close()
+	for creditRows.Next() {
+		stats := &models.BranchCreditStats{}
+		if err := creditRows.Scan(
+			&stats.Currency,
+			&stats.Active,
+			&stats.Outstanding,
+			&stats.Overdue,
+			&stats.Issued,
+			&stats.IssuedAmount,
+		); err != nil {
+			return nil, err
+		}
+		report.Credits = append(report.Credits, stats)
+	}
+	return report, creditRows.Err()
+}
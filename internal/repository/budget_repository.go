@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// BudgetRepository handles database operations for the monthly spending limits
+// users set per category
+type BudgetRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewBudgetRepository creates a new BudgetRepository instance
+func NewBudgetRepository(db *sql.DB, logger *logrus.Logger) *BudgetRepository {
+	return &BudgetRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *BudgetRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// Create stores a new budget; a user has one budget per category
+func (r *BudgetRepository) Create(ctx context.Context, budget *models.Budget) error {
+	query := `
+		INSERT INTO budgets (user_id, category, monthly_limit)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, budget.UserID, budget.Category, budget.MonthlyLimit).
+		Scan(&budget.ID, &budget.CreatedAt, &budget.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("budget already exists for the category")
+		}
+		r.logger.WithError(err).Error("Failed to create budget")
+		return err
+	}
+	return nil
+}
+
+const budgetColumns = `id, user_id, category, monthly_limit, notified_percent, notified_month, created_at, updated_at`
+
+func scanBudget(scanner interface{ Scan(...interface{}) error }) (*models.Budget, error) {
+	budget := &models.Budget{}
+	var notifiedMonth sql.NullTime
+
+	err := scanner.Scan(
+		&budget.ID,
+		&budget.UserID,
+		&budget.Category,
+		&budget.MonthlyLimit,
+		&budget.NotifiedPercent,
+		&notifiedMonth,
+		&budget.CreatedAt,
+		&budget.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if notifiedMonth.Valid {
+		budget.NotifiedMonth = &notifiedMonth.Time
+	}
+	return budget, nil
+}
+
+// GetByID retrieves a budget of a user
+func (r *BudgetRepository) GetByID(ctx context.Context, userID, id int64) (*models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets WHERE id = $1 AND user_id = $2`
+
+	budget, err := scanBudget(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("budget not found")
+		}
+		r.logger.WithError(err).Error("Failed to get budget")
+		return nil, err
+	}
+	return budget, nil
+}
+
+// ListByUser retrieves the budgets of a user by category
+func (r *BudgetRepository) ListByUser(ctx context.Context, userID int64) ([]*models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets WHERE user_id = $1 ORDER BY category`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list budgets")
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []*models.Budget{}
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+// ListUsers retrieves the IDs of the users who set a budget
+func (r *BudgetRepository) ListUsers(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT user_id FROM budgets ORDER BY user_id`)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list users with budgets")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Update stores the monthly limit of a budget
+func (r *BudgetRepository) Update(ctx context.Context, budget *models.Budget) error {
+	query := `
+		UPDATE budgets
+		SET monthly_limit = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND user_id = $3
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, budget.MonthlyLimit, budget.ID, budget.UserID).Scan(&budget.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("budget not found")
+		}
+		r.logger.WithError(err).Errorf("Failed to update budget %d", budget.ID)
+		return err
+	}
+	return nil
+}
+
+// Delete removes a budget of a user
+func (r *BudgetRepository) Delete(ctx context.Context, userID, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM budgets WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to delete budget %d", id)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("budget not found")
+	}
+	return nil
+}
+
+// SpendingTotals sums the transactions leaving the accounts of a user from
+// since up to before until, by type, biller category and currency. Transfers
+// between the user's own accounts are not spending.
+func (r *BudgetRepository) SpendingTotals(ctx context.Context, userID int64, since, until time.Time) ([]*models.SpendingTotal, error) {
+	query := `
+		SELECT t.type, COALESCE(b.category, ''), a.currency, SUM(t.amount)
+		FROM transactions t
+		JOIN accounts a ON a.id = t.from_account_id
+		LEFT JOIN accounts dst ON dst.id = t.to_account_id
+		LEFT JOIN bill_payments bp ON bp.transaction_id = t.id
+		LEFT JOIN billers b ON b.id = bp.biller_id
+		WHERE a.user_id = $1 AND t.created_at >= $2 AND t.created_at < $3
+			AND (dst.id IS NULL OR dst.user_id <> a.user_id)
+		GROUP BY t.type, b.category, a.currency
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum spending")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*models.SpendingTotal
+	for rows.Next() {
+		total := &models.SpendingTotal{}
+		if err := rows.Scan(&total.Type, &total.BillerCategory, &total.Currency, &total.Amount); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// SetNotifiedTx records within a database transaction the utilization of a
+// budget the user is notified of, unless a higher one was recorded for the month
+// already. It reports whether the budget was updated.
+func (r *BudgetRepository) SetNotifiedTx(ctx context.Context, tx Tx, budget *models.Budget, month time.Time, percent int) (bool, error) {
+	query := `
+		UPDATE budgets
+		SET notified_percent = $1, notified_month = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND (notified_month IS DISTINCT FROM $2 OR notified_percent < $1)
+	`
+
+	result, err := tx.ExecContext(ctx, query, percent, month, budget.ID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update budget %d", budget.ID)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CardAuthorizationRepository handles database operations for card payments
+// authorized by the acquirer
+type CardAuthorizationRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCardAuthorizationRepository creates a new CardAuthorizationRepository instance
+func NewCardAuthorizationRepository(db *sql.DB, logger *logrus.Logger) *CardAuthorizationRepository {
+	return &CardAuthorizationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CardAuthorizationRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+const cardAuthorizationColumns = `
+	id, card_id, account_id, amount, currency, conversion, merchant, COALESCE(merchant_country, ''), status,
+	transaction_id, expires_at, created_at, updated_at
+`
+
+func scanCardAuthorization(scanner interface{ Scan(...interface{}) error }) (*models.CardAuthorization, error) {
+	authorization := &models.CardAuthorization{}
+	var conversion []byte
+	var transactionID sql.NullInt64
+
+	err := scanner.Scan(
+		&authorization.ID,
+		&authorization.CardID,
+		&authorization.AccountID,
+		&authorization.Amount,
+		&authorization.Currency,
+		&conversion,
+		&authorization.Merchant,
+		&authorization.MerchantCountry,
+		&authorization.Status,
+		&transactionID,
+		&authorization.ExpiresAt,
+		&authorization.CreatedAt,
+		&authorization.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conversion) > 0 {
+		if err := json.Unmarshal(conversion, &authorization.Conversion); err != nil {
+			return nil, err
+		}
+	}
+	if transactionID.Valid {
+		authorization.TransactionID = &transactionID.Int64
+	}
+
+	return authorization, nil
+}
+
+// CreateTx stores a pending authorization within a database transaction
+func (r *CardAuthorizationRepository) CreateTx(ctx context.Context, tx Tx, authorization *models.CardAuthorization) error {
+	var conversion []byte
+	if authorization.Conversion != nil {
+		var err error
+		if conversion, err = json.Marshal(authorization.Conversion); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO card_authorizations (
+			card_id, account_id, amount, currency, conversion, merchant, merchant_country, status, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		authorization.CardID,
+		authorization.AccountID,
+		authorization.Amount,
+		authorization.Currency,
+		conversion,
+		authorization.Merchant,
+		authorization.MerchantCountry,
+		authorization.Status,
+		authorization.ExpiresAt,
+	).Scan(&authorization.ID, &authorization.CreatedAt, &authorization.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create card authorization")
+		return err
+	}
+
+	return nil
+}
+
+// GetByID retrieves an authorization by its ID
+func (r *CardAuthorizationRepository) GetByID(ctx context.Context, id int64) (*models.CardAuthorization, error) {
+	return r.getAuthorization(ctx, r.db, `SELECT `+cardAuthorizationColumns+` FROM card_authorizations WHERE id = $1`, id)
+}
+
+// GetByIDForUpdate retrieves an authorization and locks it until the transaction ends
+func (r *CardAuthorizationRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.CardAuthorization, error) {
+	return r.getAuthorization(ctx, tx, `SELECT `+cardAuthorizationColumns+` FROM card_authorizations WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *CardAuthorizationRepository) getAuthorization(ctx context.Context, q queryer, query string, id int64) (*models.CardAuthorization, error) {
+	authorization, err := scanCardAuthorization(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("authorization not found")
+		}
+		r.logger.WithError(err).Error("Failed to get card authorization")
+		return nil, err
+	}
+	return authorization, nil
+}
+
+// UpdateStatusTx records the capture or void of an authorization within a
+// database transaction
+func (r *CardAuthorizationRepository) UpdateStatusTx(ctx context.Context, tx Tx, authorization *models.CardAuthorization) error {
+	query := `
+		UPDATE card_authorizations
+		SET status = $1, transaction_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	if err := tx.QueryRowContext(ctx, query, authorization.Status, authorization.TransactionID, authorization.ID).Scan(&authorization.UpdatedAt); err != nil {
+		r.logger.WithError(err).Error("Failed to update card authorization status")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CardBatchRepository handles database operations for bulk card issuance
+type CardBatchRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCardBatchRepository creates a new CardBatchRepository instance
+func NewCardBatchRepository(db *sql.DB, logger *logrus.Logger) *CardBatchRepository {
+	return &CardBatchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CardBatchRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates a batch together with its items within a database transaction
+func (r *CardBatchRepository) CreateTx(ctx context.Context, tx Tx, batch *models.CardBatch) error {
+	query := `
+		INSERT INTO card_batches (
+			account_id, card_type, daily_limit, monthly_limit, transaction_limit,
+			status, total_cards, requested_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		batch.AccountID,
+		batch.CardType,
+		batch.Limits.DailyLimit,
+		batch.Limits.MonthlyLimit,
+		batch.Limits.TransactionLimit,
+		batch.Status,
+		batch.TotalCards,
+		batch.RequestedBy,
+	).Scan(&batch.ID, &batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create card batch")
+		return err
+	}
+
+	itemQuery := `
+		INSERT INTO card_batch_items (
+			batch_id, employee_id, full_name, email, delivery_address,
+			status, delivery_status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	for _, item := range batch.Items {
+		item.BatchID = batch.ID
+		err := tx.QueryRowContext(
+			ctx,
+			itemQuery,
+			item.BatchID,
+			item.EmployeeID,
+			item.FullName,
+			item.Email,
+			item.DeliveryAddress,
+			item.Status,
+			item.DeliveryStatus,
+		).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to create card batch item")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetJobTx links a batch to the job that processes it
+func (r *CardBatchRepository) SetJobTx(ctx context.Context, tx Tx, batchID, jobID int64) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE card_batches SET job_id = $1 WHERE id = $2`, jobID, batchID); err != nil {
+		r.logger.WithError(err).Error("Failed to link card batch to job")
+		return err
+	}
+	return nil
+}
+
+const cardBatchColumns = `
+	id, account_id, card_type, daily_limit, monthly_limit, transaction_limit,
+	status, total_cards, issued_cards, failed_cards, job_id, requested_by,
+	completed_at, created_at, updated_at
+`
+
+func scanCardBatch(scanner interface{ Scan(...interface{}) error }) (*models.CardBatch, error) {
+	batch := &models.CardBatch{}
+	var dailyLimit, monthlyLimit, transactionLimit sql.NullFloat64
+	var jobID sql.NullInt64
+	var completedAt sql.NullTime
+
+	err := scanner.Scan(
+		&batch.ID,
+		&batch.AccountID,
+		&batch.CardType,
+		&dailyLimit,
+		&monthlyLimit,
+		&transactionLimit,
+		&batch.Status,
+		&batch.TotalCards,
+		&batch.IssuedCards,
+		&batch.FailedCards,
+		&jobID,
+		&batch.RequestedBy,
+		&completedAt,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	batch.Limits = models.CardLimitProfile{
+		DailyLimit:       nullableFloat(dailyLimit),
+		MonthlyLimit:     nullableFloat(monthlyLimit),
+		TransactionLimit: nullableFloat(transactionLimit),
+	}
+	if jobID.Valid {
+		batch.JobID = &jobID.Int64
+	}
+	if completedAt.Valid {
+		batch.CompletedAt = &completedAt.Time
+	}
+
+	return batch, nil
+}
+
+// GetByID retrieves a batch without its items
+func (r *CardBatchRepository) GetByID(ctx context.Context, id int64) (*models.CardBatch, error) {
+	query := `SELECT ` + cardBatchColumns + ` FROM card_batches WHERE id = $1`
+
+	batch, err := scanCardBatch(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("card batch not found")
+		}
+		r.logger.WithError(err).Error("Failed to get card batch")
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// GetItems retrieves the items of a batch with the masked numbers of issued cards
+func (r *CardBatchRepository) GetItems(ctx context.Context, batchID int64) ([]*models.CardBatchItem, error) {
+	query := `
+		SELECT i.id, i.batch_id, COALESCE(i.employee_id, ''), i.full_name, COALESCE(i.email, ''),
+		       i.delivery_address, i.status, i.card_id, COALESCE(c.card_number, ''), COALESCE(i.error, ''),
+		       i.delivery_status, COALESCE(i.carrier, ''), COALESCE(i.tracking_number, ''),
+		       i.dispatched_at, i.delivered_at, i.created_at, i.updated_at
+		FROM card_batch_items i
+		LEFT JOIN cards c ON c.id = i.card_id
+		WHERE i.batch_id = $1
+		ORDER BY i.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, batchID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get card batch items")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.CardBatchItem
+	for rows.Next() {
+		item := &models.CardBatchItem{}
+		var cardID sql.NullInt64
+		var dispatchedAt, deliveredAt sql.NullTime
+
+		err := rows.Scan(
+			&item.ID,
+			&item.BatchID,
+			&item.EmployeeID,
+			&item.FullName,
+			&item.Email,
+			&item.DeliveryAddress,
+			&item.Status,
+			&cardID,
+			&item.CardNumber,
+			&item.Error,
+			&item.DeliveryStatus,
+			&item.Carrier,
+			&item.TrackingNumber,
+			&dispatchedAt,
+			&deliveredAt,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan card batch item row")
+			return nil, err
+		}
+
+		if cardID.Valid {
+			item.CardID = &cardID.Int64
+			item.CardNumber = (&models.Card{CardNumber: item.CardNumber}).MaskNumber()
+		}
+		if dispatchedAt.Valid {
+			item.DispatchedAt = &dispatchedAt.Time
+		}
+		if deliveredAt.Valid {
+			item.DeliveredAt = &deliveredAt.Time
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// UpdateStatus updates the processing status of a batch
+func (r *CardBatchRepository) UpdateStatus(ctx context.Context, id int64, status models.CardBatchStatus) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE card_batches SET status = $1 WHERE id = $2`, status, id); err != nil {
+		r.logger.WithError(err).Error("Failed to update card batch status")
+		return err
+	}
+	return nil
+}
+
+// MarkItemIssuedTx records the card issued for a batch item within a database transaction
+func (r *CardBatchRepository) MarkItemIssuedTx(ctx context.Context, tx Tx, itemID, cardID int64) error {
+	query := `UPDATE card_batch_items SET status = $1, card_id = $2, error = NULL WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, query, models.CardBatchItemStatusIssued, cardID, itemID); err != nil {
+		r.logger.WithError(err).Error("Failed to mark card batch item issued")
+		return err
+	}
+	return nil
+}
+
+// MarkItemFailed records why a card could not be issued for a batch item
+func (r *CardBatchRepository) MarkItemFailed(ctx context.Context, itemID int64, reason string) error {
+	query := `UPDATE card_batch_items SET status = $1, error = $2 WHERE id = $3`
+	if _, err := r.db.ExecContext(ctx, query, models.CardBatchItemStatusFailed, reason, itemID); err != nil {
+		r.logger.WithError(err).Error("Failed to mark card batch item failed")
+		return err
+	}
+	return nil
+}
+
+// Finalize recounts the batch items and sets the final batch status
+func (r *CardBatchRepository) Finalize(ctx context.Context, id int64) error {
+	query := `
+		UPDATE card_batches b
+		SET issued_cards = c.issued,
+			failed_cards = c.failed,
+			status = CASE
+				WHEN c.failed = 0 THEN $1
+				WHEN c.issued = 0 THEN $2
+				ELSE $3
+			END,
+			completed_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE status = $4) AS issued,
+			       COUNT(*) FILTER (WHERE status = $5) AS failed
+			FROM card_batch_items
+			WHERE batch_id = $6
+		) c
+		WHERE b.id = $6
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		models.CardBatchStatusCompleted,
+		models.CardBatchStatusFailed,
+		models.CardBatchStatusCompletedWithErrors,
+		models.CardBatchItemStatusIssued,
+		models.CardBatchItemStatusFailed,
+		id,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to finalize card batch")
+		return err
+	}
+
+	return nil
+}
+
+// UpdateDelivery updates the delivery tracking fields of a batch item. Dispatch and
+// delivery times are stamped the first time the item reaches those states.
+func (r *CardBatchRepository) UpdateDelivery(ctx context.Context, batchID, itemID int64, req *models.UpdateCardDeliveryRequest) error {
+	query := `
+		UPDATE card_batch_items
+		SET delivery_status = $1,
+			carrier = COALESCE(NULLIF($2, ''), carrier),
+			tracking_number = COALESCE(NULLIF($3, ''), tracking_number),
+			dispatched_at = CASE WHEN $1 IN ('dispatched', 'delivered') THEN COALESCE(dispatched_at, CURRENT_TIMESTAMP) ELSE dispatched_at END,
+			delivered_at = CASE WHEN $1 = 'delivered' THEN COALESCE(delivered_at, CURRENT_TIMESTAMP) ELSE delivered_at END
+		WHERE id = $4 AND batch_id = $5 AND status = $6
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		req.DeliveryStatus,
+		req.Carrier,
+		req.TrackingNumber,
+		itemID,
+		batchID,
+		models.CardBatchItemStatusIssued,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update card delivery")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get rows affected")
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("issued card batch item not found")
+	}
+
+	return nil
+}
@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,29 +26,49 @@ func NewCardRepository(db *sql.DB, logger *logrus.Logger) *CardRepository {
 }
 
 // Create creates a new card in the database
-func (r *CardRepository) Create(card *models.Card) error {
+func (r *CardRepository) Create(ctx context.Context, card *models.Card) error {
+	return r.create(ctx, r.db, card)
+}
+
+// CreateTx creates a new card within a database transaction
+func (r *CardRepository) CreateTx(ctx context.Context, tx Tx, card *models.Card) error {
+	return r.create(ctx, tx, card)
+}
+
+func (r *CardRepository) create(ctx context.Context, q queryer, card *models.Card) error {
 	query := `
 		INSERT INTO cards (
-			user_id, account_id, card_number, expiry_date, cvv,
-			card_type, status, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			user_id, account_id, card_number, expiry_date, cvv_hash,
+			card_type, status, daily_limit, monthly_limit, transaction_limit,
+			single_use, merchant_lock, replaces_card_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id
 	`
 
-	err := r.db.QueryRow(
+	err := q.QueryRowContext(
+		ctx,
 		query,
 		card.UserID,
 		card.AccountID,
 		card.CardNumber,
 		card.ExpiryDate,
-		card.CVV,
+		card.CVVHash,
 		card.CardType,
 		card.Status,
+		card.DailyLimit,
+		card.MonthlyLimit,
+		card.TransactionLimit,
+		card.SingleUse,
+		sql.NullString{String: card.MerchantLock, Valid: card.MerchantLock != ""},
+		card.ReplacesCardID,
 		time.Now(),
 		time.Now(),
 	).Scan(&card.ID)
 
 	if err != nil {
+		if card.ReplacesCardID != nil && isUniqueViolation(err) {
+			return errors.New("card has already been reissued")
+		}
 		r.logger.WithError(err).Error("Failed to create card")
 		return err
 	}
@@ -54,29 +76,63 @@ func (r *CardRepository) Create(card *models.Card) error {
 	return nil
 }
 
-// GetByID retrieves a card by its ID
-func (r *CardRepository) GetByID(id int64) (*models.Card, error) {
-	query := `
-		SELECT id, user_id, account_id, card_number, expiry_date, cvv,
-		       card_type, status, created_at, updated_at
-		FROM cards
-		WHERE id = $1
-	`
+const cardColumns = `
+	id, user_id, account_id, card_number, expiry_date, cvv_hash,
+	card_type, status, daily_limit, monthly_limit, transaction_limit,
+	single_use, merchant_lock, replaces_card_id, created_at, updated_at
+`
 
+func scanCard(scanner interface{ Scan(...interface{}) error }) (*models.Card, error) {
 	card := &models.Card{}
-	err := r.db.QueryRow(query, id).Scan(
+	var dailyLimit, monthlyLimit, transactionLimit sql.NullFloat64
+	var merchantLock sql.NullString
+	var replacesCardID sql.NullInt64
+
+	err := scanner.Scan(
 		&card.ID,
 		&card.UserID,
 		&card.AccountID,
 		&card.CardNumber,
 		&card.ExpiryDate,
-		&card.CVV,
+		&card.CVVHash,
 		&card.CardType,
 		&card.Status,
+		&dailyLimit,
+		&monthlyLimit,
+		&transactionLimit,
+		&card.SingleUse,
+		&merchantLock,
+		&replacesCardID,
 		&card.CreatedAt,
 		&card.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
 
+	card.DailyLimit = nullableFloat(dailyLimit)
+	card.MonthlyLimit = nullableFloat(monthlyLimit)
+	card.TransactionLimit = nullableFloat(transactionLimit)
+	card.MerchantLock = merchantLock.String
+	if replacesCardID.Valid {
+		card.ReplacesCardID = &replacesCardID.Int64
+	}
+
+	return card, nil
+}
+
+func nullableFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// GetByID retrieves a card by its ID
+func (r *CardRepository) GetByID(ctx context.Context, id int64) (*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id = $1`
+
+	card, err := scanCard(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -88,16 +144,27 @@ func (r *CardRepository) GetByID(id int64) (*models.Card, error) {
 	return card, nil
 }
 
+// GetByNumber retrieves a card by its full number
+func (r *CardRepository) GetByNumber(ctx context.Context, number string) (*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE card_number = $1`
+
+	card, err := scanCard(r.db.QueryRowContext(ctx, query, number))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get card by number")
+		return nil, err
+	}
+
+	return card, nil
+}
+
 // GetByUserID retrieves all cards for a user
-func (r *CardRepository) GetByUserID(userID int64) ([]*models.Card, error) {
-	query := `
-		SELECT id, user_id, account_id, card_number, expiry_date, cvv,
-		       card_type, status, created_at, updated_at
-		FROM cards
-		WHERE user_id = $1
-	`
+func (r *CardRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE user_id = $1`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		r.logger.WithError(err).Error("Failed to get cards by user ID")
 		return nil, err
@@ -106,19 +173,7 @@ func (r *CardRepository) GetByUserID(userID int64) ([]*models.Card, error) {
 
 	var cards []*models.Card
 	for rows.Next() {
-		card := &models.Card{}
-		err := rows.Scan(
-			&card.ID,
-			&card.UserID,
-			&card.AccountID,
-			&card.CardNumber,
-			&card.ExpiryDate,
-			&card.CVV,
-			&card.CardType,
-			&card.Status,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-		)
+		card, err := scanCard(rows)
 		if err != nil {
 			r.logger.WithError(err).Error("Failed to scan card row")
 			return nil, err
@@ -129,15 +184,107 @@ func (r *CardRepository) GetByUserID(userID int64) ([]*models.Card, error) {
 	return cards, nil
 }
 
+// GetByAccountID retrieves all cards of an account, whichever of its holders
+// they were issued to
+func (r *CardRepository) GetByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE account_id = $1 ORDER BY id`
+	return r.getCards(ctx, r.db, query, accountID)
+}
+
+// GetByAccountIDTx retrieves all cards on an account within a database transaction
+func (r *CardRepository) GetByAccountIDTx(ctx context.Context, tx Tx, accountID int64) ([]*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE account_id = $1 ORDER BY id`
+	return r.getCards(ctx, tx, query, accountID)
+}
+
+// ListByUserID retrieves a page of the cards of a user and how many match the
+// status filter
+func (r *CardRepository) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Card, int, error) {
+	rows, total, err := queryPage(ctx, r.db, cardColumns, "cards", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list cards by user ID")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var cards []*models.Card
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan card row")
+			return nil, 0, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, total, rows.Err()
+}
+
+// cardExpiresAt is the SQL counterpart of models.Card.ExpiresAt
+const cardExpiresAt = `(TO_DATE(expiry_date, 'MM/YY') + INTERVAL '1 month')`
+
+// GetExpiring retrieves the active cards expiring before the given time whose
+// holders have neither been told yet nor reissued them
+func (r *CardRepository) GetExpiring(ctx context.Context, before time.Time) ([]*models.Card, error) {
+	query := `
+		SELECT ` + cardColumns + ` FROM cards
+		WHERE status = $1 AND expiry_notified_at IS NULL AND ` + cardExpiresAt + ` <= $2
+			AND NOT EXISTS (SELECT 1 FROM cards replacement WHERE replacement.replaces_card_id = cards.id)
+		ORDER BY id
+	`
+	return r.getCards(ctx, r.db, query, models.CardStatusActive, before)
+}
+
+// GetExpired retrieves the cards past their expiry date that are not marked expired yet
+func (r *CardRepository) GetExpired(ctx context.Context, now time.Time) ([]*models.Card, error) {
+	query := `
+		SELECT ` + cardColumns + ` FROM cards
+		WHERE status <> $1 AND ` + cardExpiresAt + ` <= $2
+		ORDER BY id
+	`
+	return r.getCards(ctx, r.db, query, models.CardStatusExpired, now)
+}
+
+func (r *CardRepository) getCards(ctx context.Context, q queryer, query string, args ...interface{}) ([]*models.Card, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get cards")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []*models.Card
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan card row")
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// MarkExpiryNotified records that the holder has been told the card expires soon
+func (r *CardRepository) MarkExpiryNotified(ctx context.Context, id int64) error {
+	query := `UPDATE cards SET expiry_notified_at = $1 WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark card expiry notified")
+		return err
+	}
+	return nil
+}
+
 // UpdateStatus updates a card's status
-func (r *CardRepository) UpdateStatus(id int64, status string) error {
+func (r *CardRepository) UpdateStatus(ctx context.Context, id int64, status string) error {
 	query := `
 		UPDATE cards
 		SET status = $1, updated_at = $2
 		WHERE id = $3
 	`
 
-	result, err := r.db.Exec(query, status, time.Now(), id)
+	result, err := r.db.ExecContext(ctx, query, status, time.Now(), id)
 	if err != nil {
 		r.logger.WithError(err).Error("Failed to update card status")
 		return err
@@ -156,11 +303,69 @@ func (r *CardRepository) UpdateStatus(id int64, status string) error {
 	return nil
 }
 
+// UseSingleUseTx blocks a single-use card for the payment being made within a
+// database transaction. It fails if the card is no longer active, so of two
+// concurrent payments only one gets through.
+func (r *CardRepository) UseSingleUseTx(ctx context.Context, tx Tx, id int64) error {
+	query := `
+		UPDATE cards
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND single_use AND status = $4
+	`
+
+	result, err := tx.ExecContext(ctx, query, models.CardStatusBlocked, time.Now(), id, models.CardStatusActive)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to block single-use card")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get rows affected")
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("card is not active")
+	}
+
+	return nil
+}
+
+// BlockByAccountTx blocks the active cards on an account held by anyone but
+// keepUserID within a database transaction and returns their IDs
+func (r *CardRepository) BlockByAccountTx(ctx context.Context, tx Tx, accountID, keepUserID int64) ([]int64, error) {
+	query := `
+		UPDATE cards
+		SET status = $1, updated_at = $2
+		WHERE account_id = $3 AND user_id <> $4 AND status = $5
+		RETURNING id
+	`
+
+	rows, err := tx.QueryContext(ctx, query, models.CardStatusBlocked, time.Now(), accountID, keepUserID, models.CardStatusActive)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to block account cards")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			r.logger.WithError(err).Error("Failed to scan blocked card")
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // Delete deletes a card by its ID
-func (r *CardRepository) Delete(id int64) error {
+func (r *CardRepository) Delete(ctx context.Context, id int64) error {
 	query := `DELETE FROM cards WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		r.logger.WithError(err).Error("Failed to delete card")
 		return err
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CollateralRepository handles database operations for the collateral securing
+// credits and its documents
+type CollateralRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCollateralRepository creates a new CollateralRepository instance
+func NewCollateralRepository(db *sql.DB, logger *logrus.Logger) *CollateralRepository {
+	return &CollateralRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create records collateral with its first valuation
+func (r *CollateralRepository) Create(ctx context.Context, collateral *models.Collateral) error {
+	query := `
+		INSERT INTO collateral (
+			user_id, credit_id, type, description, appraised_value, appraised_by, appraised_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, appraised_at, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		collateral.UserID,
+		collateral.CreditID,
+		collateral.Type,
+		collateral.Description,
+		collateral.AppraisedValue,
+		collateral.AppraisedBy,
+	).Scan(&collateral.ID, &collateral.AppraisedAt, &collateral.CreatedAt, &collateral.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create collateral")
+		return err
+	}
+
+	return nil
+}
+
+const collateralColumns = `
+	id, user_id, credit_id, type, description, appraised_value, appraised_by, appraised_at, created_at, updated_at
+`
+
+func scanCollateral(scanner interface{ Scan(...interface{}) error }) (*models.Collateral, error) {
+	collateral := &models.Collateral{}
+	var creditID sql.NullInt64
+
+	err := scanner.Scan(
+		&collateral.ID,
+		&collateral.UserID,
+		&creditID,
+		&collateral.Type,
+		&collateral.Description,
+		&collateral.AppraisedValue,
+		&collateral.AppraisedBy,
+		&collateral.AppraisedAt,
+		&collateral.CreatedAt,
+		&collateral.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if creditID.Valid {
+		collateral.CreditID = &creditID.Int64
+	}
+	return collateral, nil
+}
+
+// GetByID retrieves collateral
+func (r *CollateralRepository) GetByID(ctx context.Context, id int64) (*models.Collateral, error) {
+	query := `SELECT ` + collateralColumns + ` FROM collateral WHERE id = $1`
+
+	collateral, err := scanCollateral(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("collateral not found")
+		}
+		r.logger.WithError(err).Error("Failed to get collateral")
+		return nil, err
+	}
+
+	return collateral, nil
+}
+
+// List retrieves collateral, newest first, optionally filtered by the user it
+// is registered for and the credit it secures
+func (r *CollateralRepository) List(ctx context.Context, userID, creditID int64) ([]*models.Collateral, error) {
+	query := `
+		SELECT ` + collateralColumns + `
+		FROM collateral
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR credit_id = $2)
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, creditID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list collateral")
+		return nil, err
+	}
+	defer rows.Close()
+
+	collateral := []*models.Collateral{}
+	for rows.Next() {
+		item, err := scanCollateral(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan collateral row")
+			return nil, err
+		}
+		collateral = append(collateral, item)
+	}
+
+	return collateral, rows.Err()
+}
+
+// UpdateValuation stores a new appraisal of collateral
+func (r *CollateralRepository) UpdateValuation(ctx context.Context, collateral *models.Collateral) error {
+	query := `
+		UPDATE collateral
+		SET appraised_value = $1, appraised_by = $2, appraised_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, collateral.AppraisedValue, collateral.AppraisedBy, collateral.AppraisedAt,
+		collateral.ID); err != nil {
+		r.logger.WithError(err).Error("Failed to update collateral valuation")
+		return err
+	}
+
+	return nil
+}
+
+// SetCredit links collateral to the credit it secures, or releases it when the
+// credit ID is nil
+func (r *CollateralRepository) SetCredit(ctx context.Context, id int64, creditID *int64) error {
+	query := `UPDATE collateral SET credit_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, creditID, id); err != nil {
+		r.logger.WithError(err).Error("Failed to update collateral credit")
+		return err
+	}
+
+	return nil
+}
+
+// CreateDocument records an uploaded document of collateral
+func (r *CollateralRepository) CreateDocument(ctx context.Context, doc *models.CollateralDocument) error {
+	query := `
+		INSERT INTO collateral_documents (collateral_id, storage_key, file_name, content_type, size, created_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, doc.CollateralID, doc.StorageKey, doc.FileName, doc.ContentType,
+		doc.Size).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create collateral document")
+		return err
+	}
+	return nil
+}
+
+const collateralDocumentColumns = `id, collateral_id, storage_key, file_name, content_type, size, created_at`
+
+func scanCollateralDocument(scanner interface{ Scan(...interface{}) error }) (*models.CollateralDocument, error) {
+	doc := &models.CollateralDocument{}
+	err := scanner.Scan(&doc.ID, &doc.CollateralID, &doc.StorageKey, &doc.FileName, &doc.ContentType, &doc.Size,
+		&doc.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// GetDocument retrieves a document of collateral
+func (r *CollateralRepository) GetDocument(ctx context.Context, collateralID, id int64) (*models.CollateralDocument, error) {
+	query := `SELECT ` + collateralDocumentColumns + ` FROM collateral_documents WHERE id = $1 AND collateral_id = $2`
+
+	doc, err := scanCollateralDocument(r.db.QueryRowContext(ctx, query, id, collateralID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("document not found")
+		}
+		r.logger.WithError(err).Error("Failed to get collateral document")
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ListDocuments retrieves the documents of collateral, newest first
+func (r *CollateralRepository) ListDocuments(ctx context.Context, collateralID int64) ([]*models.CollateralDocument, error) {
+	query := `
+		SELECT ` + collateralDocumentColumns + `
+		FROM collateral_documents
+		WHERE collateral_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, collateralID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list collateral documents")
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*models.CollateralDocument{}
+	for rows.Next() {
+		doc, err := scanCollateralDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// CollectionRepository handles database operations for the collection cases of
+// credits in arrears
+type CollectionRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCollectionRepository creates a new CollectionRepository instance
+func NewCollectionRepository(db *sql.DB, logger *logrus.Logger) *CollectionRepository {
+	return &CollectionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CollectionRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// ListCreditsToCheck retrieves the IDs of the credits being repaid with an
+// unpaid installment overdue since the cutoff day or earlier, and of the credits
+// delinquent, in collections or with an open case, whose arrears may have been
+// paid since. Active credits paused by an approved insurance claim are not
+// escalated.
+func (r *CollectionRepository) ListCreditsToCheck(ctx context.Context, cutoff time.Time) ([]int64, error) {
+	query := `
+		SELECT c.id FROM credits c
+		WHERE c.status IN ($1, $2)
+			OR EXISTS (SELECT 1 FROM collection_cases cc WHERE cc.credit_id = c.id AND cc.status = $3)
+			OR (c.status = $4 AND EXISTS (
+				SELECT 1 FROM payment_schedules ps
+				WHERE ps.credit_id = c.id AND ps.status = $5 AND ps.due_date <= $6
+			) AND NOT EXISTS (
+				SELECT 1 FROM credit_insurance_claims cl WHERE cl.credit_id = c.id AND cl.status = 'approved'
+			))
+		ORDER BY c.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.CreditStatusDelinquent, models.CreditStatusCollections,
+		models.CollectionCaseStatusOpen, models.CreditStatusActive, models.PaymentStatusOverdue, cutoff)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credits to check for collections")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const collectionCaseColumns = `
+	id, credit_id, user_id, stage, status, days_overdue, overdue_amount, fees, notices_sent, last_notice_at,
+	escalated_at, resolved_at, created_at, updated_at
+`
+
+func scanCollectionCase(scanner interface{ Scan(...interface{}) error }) (*models.CollectionCase, error) {
+	c := &models.CollectionCase{}
+	var lastNoticeAt, escalatedAt, resolvedAt sql.NullTime
+
+	err := scanner.Scan(
+		&c.ID,
+		&c.CreditID,
+		&c.UserID,
+		&c.Stage,
+		&c.Status,
+		&c.DaysOverdue,
+		&c.OverdueAmount,
+		&c.Fees,
+		&c.NoticesSent,
+		&lastNoticeAt,
+		&escalatedAt,
+		&resolvedAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastNoticeAt.Valid {
+		c.LastNoticeAt = &lastNoticeAt.Time
+	}
+	if escalatedAt.Valid {
+		c.EscalatedAt = &escalatedAt.Time
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return c, nil
+}
+
+// GetOpenCaseForUpdate retrieves and locks the open case of a credit within a
+// database transaction, or nil when the credit has none
+func (r *CollectionRepository) GetOpenCaseForUpdate(ctx context.Context, tx Tx, creditID int64) (*models.CollectionCase, error) {
+	query := `SELECT ` + collectionCaseColumns + ` FROM collection_cases WHERE credit_id = $1 AND status = $2 FOR UPDATE`
+
+	c, err := scanCollectionCase(tx.QueryRowContext(ctx, query, creditID, models.CollectionCaseStatusOpen))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Errorf("Failed to lock collection case of credit %d", creditID)
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateTx opens a collection case within a database transaction
+func (r *CollectionRepository) CreateTx(ctx context.Context, tx Tx, c *models.CollectionCase) error {
+	query := `
+		INSERT INTO collection_cases (credit_id, user_id, stage, status, days_overdue, overdue_amount, fees,
+			notices_sent, last_notice_at, escalated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, c.CreditID, c.UserID, c.Stage, c.Status, c.DaysOverdue, c.OverdueAmount,
+		c.Fees, c.NoticesSent, c.LastNoticeAt, c.EscalatedAt,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to create collection case of credit %d", c.CreditID)
+		return err
+	}
+	return nil
+}
+
+// UpdateTx stores the state of a collection case within a database transaction
+func (r *CollectionRepository) UpdateTx(ctx context.Context, tx Tx, c *models.CollectionCase) error {
+	query := `
+		UPDATE collection_cases
+		SET stage = $1, status = $2, days_overdue = $3, overdue_amount = $4, fees = $5, notices_sent = $6,
+			last_notice_at = $7, escalated_at = $8, resolved_at = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $10
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, c.Stage, c.Status, c.DaysOverdue, c.OverdueAmount, c.Fees, c.NoticesSent,
+		c.LastNoticeAt, c.EscalatedAt, c.ResolvedAt, c.ID,
+	).Scan(&c.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update collection case %d", c.ID)
+		return err
+	}
+	return nil
+}
+
+// AddFeeTx charges a collection fee on an installment within a database
+// transaction. The fee is added to the penalty of the installment and paid with it.
+func (r *CollectionRepository) AddFeeTx(ctx context.Context, tx Tx, paymentID int64, fee float64) error {
+	query := `
+		UPDATE payment_schedules
+		SET penalty = penalty + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	if _, err := tx.ExecContext(ctx, query, fee, paymentID); err != nil {
+		return fmt.Errorf("failed to charge collection fee: %w", err)
+	}
+	return nil
+}
+
+// UserInCollections reports whether a credit of the user is delinquent or in collections
+func (r *CollectionRepository) UserInCollections(ctx context.Context, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM credits WHERE user_id = $1 AND status IN ($2, $3))`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, userID, models.CreditStatusDelinquent, models.CreditStatusCollections).Scan(&exists)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to check credits in collections")
+		return false, err
+	}
+	return exists, nil
+}
+
+// List retrieves a page of the collection cases, of a stage unless it is empty,
+// and how many there are
+func (r *CollectionRepository) List(ctx context.Context, stage string, p pagination.Params) ([]*models.CollectionCase, int, error) {
+	rows, total, err := queryPage(ctx, r.db, collectionCaseColumns, "collection_cases", "($1 = '' OR stage = $1)", p, stage)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list collection cases")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	cases := []*models.CollectionCase{}
+	for rows.Next() {
+		c, err := scanCollectionCase(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, total, rows.Err()
+}
+
+// Summary sums up the open collection cases per stage and currency
+func (r *CollectionRepository) Summary(ctx context.Context) (*models.CollectionSummary, error) {
+	summary := &models.CollectionSummary{Stages: []*models.CollectionStageStats{}}
+
+	query := `
+		SELECT cc.stage, a.currency, COUNT(*), COALESCE(SUM(cc.overdue_amount), 0),
+			COALESCE(SUM(c.remaining_amount), 0), COALESCE(SUM(cc.fees), 0), COALESCE(AVG(cc.days_overdue), 0)
+		FROM collection_cases cc
+		JOIN credits c ON c.id = cc.credit_id
+		JOIN accounts a ON a.id = c.account_id
+		WHERE cc.status = $1
+		GROUP BY cc.stage, a.currency
+		ORDER BY cc.stage, a.currency
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.CollectionCaseStatusOpen)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum up collection cases")
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		stats := &models.CollectionStageStats{}
+		if err := rows.Scan(
+			&stats.Stage,
+			&stats.Currency,
+			&stats.Cases,
+			&stats.OverdueAmount,
+			&stats.Outstanding,
+			&stats.Fees,
+			&stats.AverageDaysOverdue,
+		); err != nil {
+			return nil, err
+		}
+		summary.Stages = append(summary.Stages, stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT COUNT(*) FROM collection_cases WHERE status = $1`
+	if err := r.db.QueryRowContext(ctx, query, models.CollectionCaseStatusResolved).Scan(&summary.ResolvedCases); err != nil {
+		r.logger.WithError(err).Error("Failed to count resolved collection cases")
+		return nil, err
+	}
+	return summary, nil
+}
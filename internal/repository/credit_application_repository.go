@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditApplicationRepository handles database operations for credit applications
+type CreditApplicationRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCreditApplicationRepository creates a new CreditApplicationRepository instance
+func NewCreditApplicationRepository(db *sql.DB, logger *logrus.Logger) *CreditApplicationRepository {
+	return &CreditApplicationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CreditApplicationRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates a new credit application within a database transaction
+func (r *CreditApplicationRepository) CreateTx(ctx context.Context, tx Tx, app *models.CreditApplication) error {
+	query := `
+		INSERT INTO credit_applications (
+			user_id, account_id, amount, term_months, interest_rate, rate_type, rate_margin, schedule_type,
+			declared_income, credit_score, rate_adjustment, insurance_premium, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		app.UserID,
+		app.AccountID,
+		app.Amount,
+		app.TermMonths,
+		app.InterestRate,
+		app.RateType,
+		app.RateMargin,
+		app.ScheduleType,
+		app.DeclaredIncome,
+		app.CreditScore,
+		app.RateAdjustment,
+		app.InsurancePremium,
+		app.Status,
+	).Scan(&app.ID, &app.CreatedAt, &app.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create credit application")
+		return err
+	}
+
+	return nil
+}
+
+const creditApplicationColumns = `
+	id, user_id, account_id, amount, term_months, interest_rate, rate_type, rate_margin, schedule_type, declared_income,
+	credit_score, rate_adjustment, insurance_premium, status, score, monthly_income, monthly_payment, debt_to_income,
+	COALESCE(decision_reason, ''), credit_id, reviewed_by, COALESCE(review_comment, ''),
+	decided_at, applied_parameters, created_at, updated_at
+`
+
+func scanCreditApplication(scanner interface{ Scan(...interface{}) error }) (*models.CreditApplication, error) {
+	app := &models.CreditApplication{}
+	var creditScore, score sql.NullInt64
+	var rateMargin, insurancePremium, monthlyIncome, monthlyPayment, debtToIncome sql.NullFloat64
+	var creditID, reviewedBy sql.NullInt64
+	var decidedAt sql.NullTime
+	var appliedParameters []byte
+
+	err := scanner.Scan(
+		&app.ID,
+		&app.UserID,
+		&app.AccountID,
+		&app.Amount,
+		&app.TermMonths,
+		&app.InterestRate,
+		&app.RateType,
+		&rateMargin,
+		&app.ScheduleType,
+		&app.DeclaredIncome,
+		&creditScore,
+		&app.RateAdjustment,
+		&insurancePremium,
+		&app.Status,
+		&score,
+		&monthlyIncome,
+		&monthlyPayment,
+		&debtToIncome,
+		&app.DecisionReason,
+		&creditID,
+		&reviewedBy,
+		&app.ReviewComment,
+		&decidedAt,
+		&appliedParameters,
+		&app.CreatedAt,
+		&app.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if creditScore.Valid {
+		value := int(creditScore.Int64)
+		app.CreditScore = &value
+	}
+	if score.Valid {
+		value := int(score.Int64)
+		app.Score = &value
+	}
+	app.RateMargin = nullableFloat(rateMargin)
+	app.InsurancePremium = nullableFloat(insurancePremium)
+	app.MonthlyIncome = nullableFloat(monthlyIncome)
+	app.MonthlyPayment = nullableFloat(monthlyPayment)
+	app.DebtToIncome = nullableFloat(debtToIncome)
+	if creditID.Valid {
+		app.CreditID = &creditID.Int64
+	}
+	if reviewedBy.Valid {
+		app.ReviewedBy = &reviewedBy.Int64
+	}
+	if decidedAt.Valid {
+		app.DecidedAt = &decidedAt.Time
+	}
+	if app.AppliedParameters, err = unmarshalAppliedParameters(appliedParameters); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// GetByID retrieves a credit application by its ID
+func (r *CreditApplicationRepository) GetByID(ctx context.Context, id int64) (*models.CreditApplication, error) {
+	query := `SELECT ` + creditApplicationColumns + ` FROM credit_applications WHERE id = $1`
+
+	app, err := scanCreditApplication(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credit application not found")
+		}
+		r.logger.WithError(err).Error("Failed to get credit application")
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// GetByIDForUpdate retrieves a credit application and locks its row until the transaction ends
+func (r *CreditApplicationRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.CreditApplication, error) {
+	query := `SELECT ` + creditApplicationColumns + ` FROM credit_applications WHERE id = $1 FOR UPDATE`
+
+	app, err := scanCreditApplication(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credit application not found")
+		}
+		r.logger.WithError(err).Error("Failed to get credit application for update")
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// GetByUserID retrieves all credit applications of a user
+func (r *CreditApplicationRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.CreditApplication, error) {
+	query := `
+		SELECT ` + creditApplicationColumns + `
+		FROM credit_applications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	return r.list(ctx, query, userID)
+}
+
+// List retrieves credit applications, optionally filtered by status
+func (r *CreditApplicationRepository) List(ctx context.Context, status models.CreditApplicationStatus) ([]*models.CreditApplication, error) {
+	query := `
+		SELECT ` + creditApplicationColumns + `
+		FROM credit_applications
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+	`
+
+	return r.list(ctx, query, string(status))
+}
+
+func (r *CreditApplicationRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.CreditApplication, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credit applications")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []*models.CreditApplication
+	for rows.Next() {
+		app, err := scanCreditApplication(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan credit application row")
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+
+	return apps, rows.Err()
+}
+
+// UpdateDecisionTx stores the scoring or review outcome of an application within a database transaction
+func (r *CreditApplicationRepository) UpdateDecisionTx(ctx context.Context, tx Tx, app *models.CreditApplication) error {
+	query := `
+		UPDATE credit_applications
+		SET status = $1, score = $2, monthly_income = $3, monthly_payment = $4,
+			debt_to_income = $5, decision_reason = $6, credit_id = $7, reviewed_by = $8,
+			review_comment = $9, decided_at = $10, applied_parameters = $11,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $12
+	`
+
+	appliedParameters, err := marshalAppliedParameters(app.AppliedParameters)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		app.Status,
+		app.Score,
+		app.MonthlyIncome,
+		app.MonthlyPayment,
+		app.DebtToIncome,
+		app.DecisionReason,
+		app.CreditID,
+		app.ReviewedBy,
+		app.ReviewComment,
+		app.DecidedAt,
+		appliedParameters,
+		app.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update credit application decision")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("credit application not found")
+	}
+
+	return nil
+}
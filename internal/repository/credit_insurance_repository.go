@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditInsuranceRepository handles database operations for the payment
+// protection insurance of credits and its claims
+type CreditInsuranceRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCreditInsuranceRepository creates a new CreditInsuranceRepository instance
+func NewCreditInsuranceRepository(db *sql.DB, logger *logrus.Logger) *CreditInsuranceRepository {
+	return &CreditInsuranceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CreditInsuranceRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates the insurance of a credit within a database transaction
+func (r *CreditInsuranceRepository) CreateTx(ctx context.Context, tx Tx, insurance *models.CreditInsurance) error {
+	query := `
+		INSERT INTO credit_insurances (credit_id, user_id, monthly_premium, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, insurance.CreditID, insurance.UserID, insurance.MonthlyPremium, insurance.Status).
+		Scan(&insurance.ID, &insurance.CreatedAt, &insurance.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create credit insurance")
+		return err
+	}
+
+	return nil
+}
+
+const creditInsuranceColumns = `id, credit_id, user_id, monthly_premium, status, created_at, updated_at`
+
+func scanCreditInsurance(scanner interface{ Scan(...interface{}) error }) (*models.CreditInsurance, error) {
+	insurance := &models.CreditInsurance{}
+	err := scanner.Scan(
+		&insurance.ID,
+		&insurance.CreditID,
+		&insurance.UserID,
+		&insurance.MonthlyPremium,
+		&insurance.Status,
+		&insurance.CreatedAt,
+		&insurance.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return insurance, nil
+}
+
+// GetByCreditID retrieves the insurance of a credit
+func (r *CreditInsuranceRepository) GetByCreditID(ctx context.Context, creditID int64) (*models.CreditInsurance, error) {
+	return r.getByCreditID(ctx, r.db, creditID, "")
+}
+
+// GetByCreditIDForUpdate retrieves the insurance of a credit and locks its row
+// until the transaction ends
+func (r *CreditInsuranceRepository) GetByCreditIDForUpdate(ctx context.Context, tx Tx, creditID int64) (*models.CreditInsurance, error) {
+	return r.getByCreditID(ctx, tx, creditID, " FOR UPDATE")
+}
+
+func (r *CreditInsuranceRepository) getByCreditID(ctx context.Context, q queryer, creditID int64, lock string) (*models.CreditInsurance, error) {
+	query := `SELECT ` + creditInsuranceColumns + ` FROM credit_insurances WHERE credit_id = $1` + lock
+
+	insurance, err := scanCreditInsurance(q.QueryRowContext(ctx, query, creditID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credit insurance not found")
+		}
+		r.logger.WithError(err).Error("Failed to get credit insurance")
+		return nil, err
+	}
+
+	return insurance, nil
+}
+
+// UpdateStatusTx changes the status of an insurance within a database transaction
+func (r *CreditInsuranceRepository) UpdateStatusTx(ctx context.Context, tx Tx, insurance *models.CreditInsurance) error {
+	query := `
+		UPDATE credit_insurances
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	if _, err := tx.ExecContext(ctx, query, insurance.Status, insurance.ID); err != nil {
+		r.logger.WithError(err).Error("Failed to update credit insurance status")
+		return err
+	}
+
+	return nil
+}
+
+// CreateClaimTx creates a claim on an insurance within a database transaction
+func (r *CreditInsuranceRepository) CreateClaimTx(ctx context.Context, tx Tx, claim *models.CreditInsuranceClaim) error {
+	query := `
+		INSERT INTO credit_insurance_claims (
+			insurance_id, credit_id, user_id, event, description, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		claim.InsuranceID,
+		claim.CreditID,
+		claim.UserID,
+		claim.Event,
+		claim.Description,
+		claim.Status,
+	).Scan(&claim.ID, &claim.CreatedAt, &claim.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("credit already has an open insurance claim")
+		}
+		r.logger.WithError(err).Error("Failed to create credit insurance claim")
+		return err
+	}
+
+	return nil
+}
+
+const creditInsuranceClaimColumns = `
+	id, insurance_id, credit_id, user_id, event, description, status, paused_at, resumed_at, paused_months,
+	reviewed_by, COALESCE(review_comment, ''), reviewed_at, created_at, updated_at
+`
+
+func scanCreditInsuranceClaim(scanner interface{ Scan(...interface{}) error }) (*models.CreditInsuranceClaim, error) {
+	claim := &models.CreditInsuranceClaim{}
+	var pausedAt, resumedAt, reviewedAt sql.NullTime
+	var pausedMonths, reviewedBy sql.NullInt64
+
+	err := scanner.Scan(
+		&claim.ID,
+		&claim.InsuranceID,
+		&claim.CreditID,
+		&claim.UserID,
+		&claim.Event,
+		&claim.Description,
+		&claim.Status,
+		&pausedAt,
+		&resumedAt,
+		&pausedMonths,
+		&reviewedBy,
+		&claim.ReviewComment,
+		&reviewedAt,
+		&claim.CreatedAt,
+		&claim.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if pausedAt.Valid {
+		claim.PausedAt = &pausedAt.Time
+	}
+	if resumedAt.Valid {
+		claim.ResumedAt = &resumedAt.Time
+	}
+	if pausedMonths.Valid {
+		months := int(pausedMonths.Int64)
+		claim.PausedMonths = &months
+	}
+	if reviewedBy.Valid {
+		claim.ReviewedBy = &reviewedBy.Int64
+	}
+	if reviewedAt.Valid {
+		claim.ReviewedAt = &reviewedAt.Time
+	}
+
+	return claim, nil
+}
+
+// GetClaimForUpdate retrieves a claim and locks its row until the transaction ends
+func (r *CreditInsuranceRepository) GetClaimForUpdate(ctx context.Context, tx Tx, id int64) (*models.CreditInsuranceClaim, error) {
+	query := `SELECT ` + creditInsuranceClaimColumns + ` FROM credit_insurance_claims WHERE id = $1 FOR UPDATE`
+
+	claim, err := scanCreditInsuranceClaim(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("insurance claim not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock credit insurance claim")
+		return nil, err
+	}
+
+	return claim, nil
+}
+
+// GetClaimsByCreditID retrieves the claims on the insurance of a credit, newest first
+func (r *CreditInsuranceRepository) GetClaimsByCreditID(ctx context.Context, creditID int64) ([]*models.CreditInsuranceClaim, error) {
+	query := `
+		SELECT ` + creditInsuranceClaimColumns + `
+		FROM credit_insurance_claims
+		WHERE credit_id = $1
+		ORDER BY created_at DESC
+	`
+
+	return r.listClaims(ctx, query, creditID)
+}
+
+// ListClaims retrieves insurance claims, optionally filtered by status
+func (r *CreditInsuranceRepository) ListClaims(ctx context.Context, status models.CreditInsuranceClaimStatus) ([]*models.CreditInsuranceClaim, error) {
+	query := `
+		SELECT ` + creditInsuranceClaimColumns + `
+		FROM credit_insurance_claims
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+	`
+
+	return r.listClaims(ctx, query, string(status))
+}
+
+func (r *CreditInsuranceRepository) listClaims(ctx context.Context, query string, args ...interface{}) ([]*models.CreditInsuranceClaim, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credit insurance claims")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []*models.CreditInsuranceClaim
+	for rows.Next() {
+		claim, err := scanCreditInsuranceClaim(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan credit insurance claim row")
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+
+	return claims, rows.Err()
+}
+
+// UpdateClaimTx stores the review and the pause of a claim within a database
+// transaction
+func (r *CreditInsuranceRepository) UpdateClaimTx(ctx context.Context, tx Tx, claim *models.CreditInsuranceClaim) error {
+	query := `
+		UPDATE credit_insurance_claims
+		SET status = $1, paused_at = $2, resumed_at = $3, paused_months = $4, reviewed_by = $5,
+			review_comment = $6, reviewed_at = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+	`
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		claim.Status,
+		claim.PausedAt,
+		claim.ResumedAt,
+		claim.PausedMonths,
+		claim.ReviewedBy,
+		claim.ReviewComment,
+		claim.ReviewedAt,
+		claim.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update credit insurance claim")
+		return err
+	}
+
+	return nil
+}
+
+// ShiftPendingPaymentsTx moves the due dates of the pending installments of a
+// credit forward by months within a database transaction
+func (r *CreditInsuranceRepository) ShiftPendingPaymentsTx(ctx context.Context, tx Tx, creditID int64, months int) error {
+	query := `
+		UPDATE payment_schedules
+		SET due_date = due_date + make_interval(months => $1), updated_at = CURRENT_TIMESTAMP
+		WHERE credit_id = $2 AND status = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, query, months, creditID, models.PaymentStatusPending); err != nil {
+		r.logger.WithError(err).Error("Failed to shift payment schedule")
+		return err
+	}
+
+	return nil
+}
+
+// SkipPenaltyDaysTx marks the penalty of the overdue installments of a credit as
+// accrued up to the given day within a database transaction, so the days before
+// it carry no penalty
+func (r *CreditInsuranceRepository) SkipPenaltyDaysTx(ctx context.Context, tx Tx, creditID int64, until time.Time) error {
+	query := `
+		UPDATE payment_schedules
+		SET penalty_accrued_until = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE credit_id = $2 AND status = $3 AND (penalty_accrued_until IS NULL OR penalty_accrued_until < $1)
+	`
+
+	if _, err := tx.ExecContext(ctx, query, until, creditID, models.PaymentStatusOverdue); err != nil {
+		r.logger.WithError(err).Error("Failed to skip penalty days")
+		return err
+	}
+
+	return nil
+}
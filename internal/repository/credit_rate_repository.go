@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditRateRepository handles database operations for the key rates the bank
+// has seen and the repricings of variable-rate credits they caused
+type CreditRateRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCreditRateRepository creates a new CreditRateRepository instance
+func NewCreditRateRepository(db *sql.DB, logger *logrus.Logger) *CreditRateRepository {
+	return &CreditRateRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CreditRateRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// LatestKeyRate retrieves the key rate recorded last, or nil when none has been
+// recorded yet
+func (r *CreditRateRepository) LatestKeyRate(ctx context.Context) (*models.KeyRateRecord, error) {
+	query := `SELECT id, rate, detected_at FROM key_rates ORDER BY detected_at DESC, id DESC LIMIT 1`
+
+	record := &models.KeyRateRecord{}
+	err := r.db.QueryRowContext(ctx, query).Scan(&record.ID, &record.Rate, &record.DetectedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get latest key rate")
+		return nil, err
+	}
+	return record, nil
+}
+
+// RecordKeyRate saves a key rate the bank has not seen before
+func (r *CreditRateRepository) RecordKeyRate(ctx context.Context, rate float64) (*models.KeyRateRecord, error) {
+	query := `INSERT INTO key_rates (rate) VALUES ($1) RETURNING id, detected_at`
+
+	record := &models.KeyRateRecord{Rate: rate}
+	if err := r.db.QueryRowContext(ctx, query, rate).Scan(&record.ID, &record.DetectedAt); err != nil {
+		r.logger.WithError(err).Error("Failed to record key rate")
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListCreditsToReprice retrieves the IDs of the variable-rate credits being
+// repaid whose rate differs from the key rate plus their margin
+func (r *CreditRateRepository) ListCreditsToReprice(ctx context.Context, keyRate float64) ([]int64, error) {
+	query := `
+		SELECT id FROM credits
+		WHERE rate_type = $1 AND status IN ($2, $3, $4) AND rate_margin IS NOT NULL
+			AND interest_rate <> ROUND(($5 + rate_margin)::numeric, 2)
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.RateTypeVariable, models.CreditStatusActive,
+		models.CreditStatusDelinquent, models.CreditStatusCollections, keyRate)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credits to reprice")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CreateTx saves the repricing of a credit within a database transaction
+func (r *CreditRateRepository) CreateTx(ctx context.Context, tx Tx, change *models.CreditRateChange) error {
+	query := `
+		INSERT INTO credit_rate_changes (credit_id, key_rate_id, key_rate, previous_rate, new_rate,
+			previous_payment, new_payment, installments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, change.CreditID, change.KeyRateID, change.KeyRate, change.PreviousRate,
+		change.NewRate, change.PreviousPayment, change.NewPayment, change.Installments,
+	).Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to create rate change of credit %d", change.CreditID)
+		return err
+	}
+	return nil
+}
+
+// ArchivePaymentTx marks an installment as replaced by a repricing within a
+// database transaction
+func (r *CreditRateRepository) ArchivePaymentTx(ctx context.Context, tx Tx, paymentID, rateChangeID int64) error {
+	query := `
+		UPDATE payment_schedules
+		SET status = $1, rate_change_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, query, models.PaymentStatusArchived, rateChangeID, paymentID); err != nil {
+		return fmt.Errorf("failed to archive payment: %w", err)
+	}
+	return nil
+}
+
+// GetByCreditID retrieves the repricings of a credit, latest first
+func (r *CreditRateRepository) GetByCreditID(ctx context.Context, creditID int64) ([]*models.CreditRateChange, error) {
+	query := `
+		SELECT id, credit_id, key_rate_id, key_rate, previous_rate, new_rate, previous_payment, new_payment,
+			installments, created_at
+		FROM credit_rate_changes
+		WHERE credit_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, creditID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get credit rate changes")
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*models.CreditRateChange{}
+	for rows.Next() {
+		change := &models.CreditRateChange{}
+		if err := rows.Scan(
+			&change.ID,
+			&change.CreditID,
+			&change.KeyRateID,
+			&change.KeyRate,
+			&change.PreviousRate,
+			&change.NewRate,
+			&change.PreviousPayment,
+			&change.NewPayment,
+			&change.Installments,
+			&change.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -1,27 +1,29 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/Abigotado/abi_banking/internal/database"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/lib/pq"
 )
 
 type CreditRepository struct {
 	db *sql.DB
 }
 
-func NewCreditRepository() *CreditRepository {
+func NewCreditRepository(db *sql.DB) *CreditRepository {
 	return &CreditRepository{
-		db: database.DB,
+		db: db,
 	}
 }
 
-func (r *CreditRepository) Create(credit *models.Credit) error {
-	tx, err := r.db.Begin()
+func (r *CreditRepository) Create(ctx context.Context, credit *models.Credit) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -30,22 +32,28 @@ func (r *CreditRepository) Create(credit *models.Credit) error {
 	// Insert credit
 	query := `
 		INSERT INTO credits (
-			user_id, account_id, amount, interest_rate,
-			term_months, status, created_at, updated_at
+			user_id, account_id, branch_id, amount, remaining_amount, interest_rate, rate_type, rate_margin,
+			term_months, schedule_type, status, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		RETURNING id
+		VALUES ($1, $2, (SELECT branch_id FROM users WHERE id = $1), $3, $4, $5, $6, $7, $8, $9, $10,
+			CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, branch_id, version
 	`
 
-	err = tx.QueryRow(
+	err = tx.QueryRowContext(
+		ctx,
 		query,
 		credit.UserID,
 		credit.AccountID,
 		credit.Amount,
+		credit.RemainingAmount,
 		credit.InterestRate,
+		rateTypeOrDefault(credit),
+		credit.RateMargin,
 		credit.TermMonths,
+		scheduleTypeOrDefault(credit),
 		credit.Status,
-	).Scan(&credit.ID)
+	).Scan(&credit.ID, &credit.BranchID, &credit.Version)
 
 	if err != nil {
 		return err
@@ -56,15 +64,18 @@ func (r *CreditRepository) Create(credit *models.Credit) error {
 	for _, payment := range schedule {
 		query := `
 			INSERT INTO payment_schedules (
-				credit_id, amount, due_date, status
+				credit_id, amount, principal, interest, due_date, status
 			)
-			VALUES ($1, $2, $3, $4)
+			VALUES ($1, $2, $3, $4, $5, $6)
 		`
 
-		_, err := tx.Exec(
+		_, err := tx.ExecContext(
+			ctx,
 			query,
 			credit.ID,
 			payment.Amount,
+			payment.Principal,
+			payment.Interest,
 			payment.DueDate,
 			payment.Status,
 		)
@@ -77,27 +88,86 @@ func (r *CreditRepository) Create(credit *models.Credit) error {
 	return tx.Commit()
 }
 
-func (r *CreditRepository) GetByID(id int64) (*models.Credit, error) {
-	credit := &models.Credit{}
+// CreateTx inserts a credit without a payment schedule within a database transaction
+func (r *CreditRepository) CreateTx(ctx context.Context, tx Tx, credit *models.Credit) error {
 	query := `
-		SELECT id, user_id, account_id, amount, interest_rate,
-			term_months, status, created_at, updated_at
-		FROM credits
-		WHERE id = $1
+		INSERT INTO credits (
+			user_id, account_id, branch_id, amount, remaining_amount, interest_rate, rate_type, rate_margin,
+			term_months, schedule_type, status, created_at, updated_at
+		)
+		VALUES ($1, $2, (SELECT branch_id FROM users WHERE id = $1), $3, $4, $5, $6, $7, $8, $9, $10,
+			CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, branch_id, version, created_at, updated_at
 	`
 
-	err := r.db.QueryRow(query, id).Scan(
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		credit.UserID,
+		credit.AccountID,
+		credit.Amount,
+		credit.RemainingAmount,
+		credit.InterestRate,
+		rateTypeOrDefault(credit),
+		credit.RateMargin,
+		credit.TermMonths,
+		scheduleTypeOrDefault(credit),
+		credit.Status,
+	).Scan(&credit.ID, &credit.BranchID, &credit.Version, &credit.CreatedAt, &credit.UpdatedAt)
+}
+
+const creditColumns = `
+	id, user_id, account_id, branch_id, amount, remaining_amount, interest_rate, rate_type, rate_margin,
+	term_months, schedule_type, status, version, created_at, updated_at
+`
+
+func scanCredit(scanner interface{ Scan(...interface{}) error }) (*models.Credit, error) {
+	credit := &models.Credit{}
+	var rateMargin sql.NullFloat64
+	err := scanner.Scan(
 		&credit.ID,
 		&credit.UserID,
 		&credit.AccountID,
+		&credit.BranchID,
 		&credit.Amount,
+		&credit.RemainingAmount,
 		&credit.InterestRate,
+		&credit.RateType,
+		&rateMargin,
 		&credit.TermMonths,
+		&credit.ScheduleType,
 		&credit.Status,
+		&credit.Version,
 		&credit.CreatedAt,
 		&credit.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	credit.RateMargin = nullableFloat(rateMargin)
+	return credit, nil
+}
+
+// rateTypeOrDefault returns the credit's rate type, fixed when unset
+func rateTypeOrDefault(credit *models.Credit) models.RateType {
+	if credit.RateType == "" {
+		return models.RateTypeFixed
+	}
+	return credit.RateType
+}
 
+// scheduleTypeOrDefault returns the credit's schedule type, annuity when unset
+func scheduleTypeOrDefault(credit *models.Credit) models.ScheduleType {
+	if credit.ScheduleType == "" {
+		return models.ScheduleTypeAnnuity
+	}
+	return credit.ScheduleType
+}
+
+func (r *CreditRepository) GetByID(ctx context.Context, id int64) (*models.Credit, error) {
+	query := `SELECT ` + creditColumns + ` FROM credits WHERE id = $1`
+
+	credit, err := scanCredit(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.New("credit not found")
@@ -108,15 +178,25 @@ func (r *CreditRepository) GetByID(id int64) (*models.Credit, error) {
 	return credit, nil
 }
 
-func (r *CreditRepository) GetByUserID(userID int64) ([]*models.Credit, error) {
-	query := `
-		SELECT id, user_id, account_id, amount, interest_rate,
-			term_months, status, created_at, updated_at
-		FROM credits
-		WHERE user_id = $1
-	`
+// GetByIDForUpdate retrieves a credit and locks its row until the transaction ends
+func (r *CreditRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Credit, error) {
+	query := `SELECT ` + creditColumns + ` FROM credits WHERE id = $1 FOR UPDATE`
+
+	credit, err := scanCredit(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credit not found")
+		}
+		return nil, err
+	}
+
+	return credit, nil
+}
 
-	rows, err := r.db.Query(query, userID)
+func (r *CreditRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Credit, error) {
+	query := `SELECT ` + creditColumns + ` FROM credits WHERE user_id = $1`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -124,18 +204,7 @@ func (r *CreditRepository) GetByUserID(userID int64) ([]*models.Credit, error) {
 
 	var credits []*models.Credit
 	for rows.Next() {
-		credit := &models.Credit{}
-		err := rows.Scan(
-			&credit.ID,
-			&credit.UserID,
-			&credit.AccountID,
-			&credit.Amount,
-			&credit.InterestRate,
-			&credit.TermMonths,
-			&credit.Status,
-			&credit.CreatedAt,
-			&credit.UpdatedAt,
-		)
+		credit, err := scanCredit(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -149,15 +218,173 @@ func (r *CreditRepository) GetByUserID(userID int64) ([]*models.Credit, error) {
 	return credits, nil
 }
 
-func (r *CreditRepository) GetPaymentSchedule(creditID int64) ([]*models.PaymentSchedule, error) {
+// GetByAccountIDTx retrieves the credits repaid from an account within a
+// database transaction
+func (r *CreditRepository) GetByAccountIDTx(ctx context.Context, tx Tx, accountID int64) ([]*models.Credit, error) {
+	query := `SELECT ` + creditColumns + ` FROM credits WHERE account_id = $1 ORDER BY id`
+
+	rows, err := tx.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credits []*models.Credit
+	for rows.Next() {
+		credit, err := scanCredit(rows)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, credit)
+	}
+	return credits, rows.Err()
+}
+
+// ListByUserID retrieves a page of the credits of a user and how many match
+// the status filter
+func (r *CreditRepository) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Credit, int, error) {
+	rows, total, err := queryPage(ctx, r.db, creditColumns, "credits", "user_id = $1", p, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var credits []*models.Credit
+	for rows.Next() {
+		credit, err := scanCredit(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		credits = append(credits, credit)
+	}
+	return credits, total, rows.Err()
+}
+
+// ListPaymentSchedule retrieves a page of the installments of a credit and how
+// many match the status filter
+func (r *CreditRepository) ListPaymentSchedule(ctx context.Context, creditID int64, p pagination.Params) ([]*models.PaymentSchedule, int, error) {
+	rows, total, err := queryPage(ctx, r.db, paymentScheduleColumns, "payment_schedules", "credit_id = $1", p, creditID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query payment schedule: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.PaymentSchedule
+	for rows.Next() {
+		payment, err := scanPaymentSchedule(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan payment schedule: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, total, rows.Err()
+}
+
+func (r *CreditRepository) GetPaymentSchedule(ctx context.Context, creditID int64) ([]*models.PaymentSchedule, error) {
+	return r.getPaymentSchedule(ctx, r.db, creditID, "")
+}
+
+// GetPaymentScheduleTx retrieves a credit's payment schedule within a database transaction
+func (r *CreditRepository) GetPaymentScheduleTx(ctx context.Context, tx Tx, creditID int64) ([]*models.PaymentSchedule, error) {
+	return r.getPaymentSchedule(ctx, tx, creditID, "")
+}
+
+// GetPaymentScheduleForUpdate retrieves a credit's payment schedule and locks its
+// installments until the transaction ends
+func (r *CreditRepository) GetPaymentScheduleForUpdate(ctx context.Context, tx Tx, creditID int64) ([]*models.PaymentSchedule, error) {
+	return r.getPaymentSchedule(ctx, tx, creditID, "FOR UPDATE")
+}
+
+// notPausedByClaim excludes the installments of credits whose schedule is paused
+// by an approved insurance claim
+const notPausedByClaim = `NOT EXISTS (
+	SELECT 1 FROM credit_insurance_claims cl
+	WHERE cl.credit_id = payment_schedules.credit_id AND cl.status = 'approved'
+)`
+
+const paymentScheduleColumns = `
+	id, credit_id, amount, principal, interest, insurance_premium, due_date, status, penalty, penalty_accrued_until,
+	paid_amount, paid_at, restructuring_id, rate_change_id, created_at, updated_at
+`
+
+func scanPaymentSchedule(scanner interface{ Scan(...interface{}) error }) (*models.PaymentSchedule, error) {
+	payment := &models.PaymentSchedule{}
+	var penaltyAccruedUntil, paidAt sql.NullTime
+	var restructuringID, rateChangeID sql.NullInt64
+	if err := scanner.Scan(
+		&payment.ID,
+		&payment.CreditID,
+		&payment.Amount,
+		&payment.Principal,
+		&payment.Interest,
+		&payment.InsurancePremium,
+		&payment.DueDate,
+		&payment.Status,
+		&payment.Penalty,
+		&penaltyAccruedUntil,
+		&payment.PaidAmount,
+		&paidAt,
+		&restructuringID,
+		&rateChangeID,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if penaltyAccruedUntil.Valid {
+		payment.PenaltyAccruedUntil = &penaltyAccruedUntil.Time
+	}
+	if paidAt.Valid {
+		payment.PaidAt = &paidAt.Time
+	}
+	if restructuringID.Valid {
+		payment.RestructuringID = &restructuringID.Int64
+	}
+	if rateChangeID.Valid {
+		payment.RateChangeID = &rateChangeID.Int64
+	}
+	if payment.IsOutstanding() {
+		payment.Remaining = payment.AmountDue()
+	}
+	return payment, nil
+}
+
+// GetPaymentSchedules retrieves the payment schedules of several credits in one
+// query, keyed by credit ID
+func (r *CreditRepository) GetPaymentSchedules(ctx context.Context, creditIDs []int64) (map[int64][]*models.PaymentSchedule, error) {
 	query := `
-		SELECT id, credit_id, amount, due_date, status, created_at, updated_at
+		SELECT ` + paymentScheduleColumns + `
 		FROM payment_schedules
-		WHERE credit_id = $1
-		ORDER BY due_date ASC
+		WHERE credit_id = ANY($1)
+		ORDER BY credit_id, due_date ASC, id ASC
 	`
 
-	rows, err := r.db.Query(query, creditID)
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(creditIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make(map[int64][]*models.PaymentSchedule, len(creditIDs))
+	for rows.Next() {
+		payment, err := scanPaymentSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment schedule: %w", err)
+		}
+		schedules[payment.CreditID] = append(schedules[payment.CreditID], payment)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *CreditRepository) getPaymentSchedule(ctx context.Context, q queryer, creditID int64, lock string) ([]*models.PaymentSchedule, error) {
+	query := `
+		SELECT ` + paymentScheduleColumns + `
+		FROM payment_schedules
+		WHERE credit_id = $1
+		ORDER BY due_date ASC, id ASC
+		` + lock
+
+	rows, err := q.QueryContext(ctx, query, creditID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query payment schedule: %w", err)
 	}
@@ -165,16 +392,7 @@ func (r *CreditRepository) GetPaymentSchedule(creditID int64) ([]*models.Payment
 
 	var payments []*models.PaymentSchedule
 	for rows.Next() {
-		payment := &models.PaymentSchedule{}
-		err := rows.Scan(
-			&payment.ID,
-			&payment.CreditID,
-			&payment.Amount,
-			&payment.DueDate,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		)
+		payment, err := scanPaymentSchedule(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan payment schedule: %w", err)
 		}
@@ -184,32 +402,59 @@ func (r *CreditRepository) GetPaymentSchedule(creditID int64) ([]*models.Payment
 	return payments, nil
 }
 
-func (r *CreditRepository) GetOverduePayments() ([]*models.PaymentSchedule, error) {
+// GetOverduePayments retrieves the installments marked overdue, except those of
+// credits paused by an insurance claim
+func (r *CreditRepository) GetOverduePayments(ctx context.Context) ([]*models.PaymentSchedule, error) {
 	query := `
-		SELECT id, credit_id, amount, due_date, status
+		SELECT ` + paymentScheduleColumns + `
 		FROM payment_schedules
-		WHERE status = 'PENDING'
-		AND due_date < CURRENT_TIMESTAMP
+		WHERE status = $1 AND ` + notPausedByClaim + `
+		ORDER BY due_date ASC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, models.PaymentStatusOverdue)
 	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.PaymentSchedule
+	for rows.Next() {
+		payment, err := scanPaymentSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment schedule: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+
+	return payments, nil
+}
+
+// MarkOverduePaymentsTx marks pending installments due before the given time as
+// overdue within a database transaction and returns the installments it marked.
+// Credits paused by an insurance claim are left alone.
+func (r *CreditRepository) MarkOverduePaymentsTx(ctx context.Context, tx Tx, before time.Time) ([]*models.PaymentSchedule, error) {
+	query := `
+		UPDATE payment_schedules
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE status = $2 AND due_date < $3 AND ` + notPausedByClaim + `
+		RETURNING ` + paymentScheduleColumns
+
+	rows, err := tx.QueryContext(ctx, query, models.PaymentStatusOverdue, models.PaymentStatusPending, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark overdue payments: %w", err)
+	}
 	defer rows.Close()
 
 	var payments []*models.PaymentSchedule
 	for rows.Next() {
-		payment := &models.PaymentSchedule{}
-		err := rows.Scan(
-			&payment.ID,
-			&payment.CreditID,
-			&payment.Amount,
-			&payment.DueDate,
-			&payment.Status,
-		)
+		payment, err := scanPaymentSchedule(rows)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to scan payment schedule: %w", err)
 		}
 		payments = append(payments, payment)
 	}
@@ -221,15 +466,77 @@ func (r *CreditRepository) GetOverduePayments() ([]*models.PaymentSchedule, erro
 	return payments, nil
 }
 
-func (r *CreditRepository) UpdateRemainingAmount(creditID int64, amount float64) error {
+// GetPaymentForUpdate retrieves a scheduled payment and locks it until the transaction ends
+func (r *CreditRepository) GetPaymentForUpdate(ctx context.Context, tx Tx, paymentID int64) (*models.PaymentSchedule, error) {
+	query := `
+		SELECT ` + paymentScheduleColumns + `
+		FROM payment_schedules
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	payment, err := scanPaymentSchedule(tx.QueryRowContext(ctx, query, paymentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("payment not found")
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// AccruePenaltyTx records daily penalty accruals of an installment and stores its
+// new penalty total within a database transaction
+func (r *CreditRepository) AccruePenaltyTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule, accruals []*models.CreditPenaltyAccrual) error {
+	query := `
+		INSERT INTO credit_penalty_accruals (
+			credit_id, payment_id, accrual_date, overdue_amount, rate_percent, amount, parameter_version_id, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	for _, accrual := range accruals {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			accrual.CreditID,
+			accrual.PaymentID,
+			accrual.AccrualDate,
+			accrual.OverdueAmount,
+			accrual.RatePercent,
+			accrual.Amount,
+			accrual.ParameterVersionID,
+		).Scan(&accrual.ID, &accrual.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create penalty accrual: %w", err)
+		}
+	}
+
+	update := `
+		UPDATE payment_schedules
+		SET penalty = $1, penalty_accrued_until = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, update, payment.Penalty, payment.PenaltyAccruedUntil, payment.ID); err != nil {
+		return fmt.Errorf("failed to update payment penalty: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CreditRepository) UpdateRemainingAmount(ctx context.Context, creditID int64, amount float64) error {
 	query := `
 		UPDATE credits
 		SET remaining_amount = $1,
+			version = version + 1,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $2
 	`
 
-	result, err := r.db.Exec(query, amount, creditID)
+	result, err := r.db.ExecContext(ctx, query, amount, creditID)
 	if err != nil {
 		return err
 	}
@@ -246,18 +553,18 @@ func (r *CreditRepository) UpdateRemainingAmount(creditID int64, amount float64)
 	return nil
 }
 
-func (r *CreditRepository) BeginTransaction() (*sql.Tx, error) {
-	return r.db.Begin()
+func (r *CreditRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
 }
 
-func (r *CreditRepository) UpdatePaymentSchedule(payment *models.PaymentSchedule) error {
+func (r *CreditRepository) UpdatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error {
 	query := `
 		UPDATE payment_schedules
-		SET status = $1
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $2
 	`
 
-	result, err := r.db.Exec(query, payment.Status, payment.ID)
+	result, err := r.db.ExecContext(ctx, query, payment.Status, payment.ID)
 	if err != nil {
 		return err
 	}
@@ -274,43 +581,98 @@ func (r *CreditRepository) UpdatePaymentSchedule(payment *models.PaymentSchedule
 	return nil
 }
 
-func (r *CreditRepository) Update(credit *models.Credit) error {
+// Update updates a credit's status and remaining amount. It fails with
+// ErrCreditModified when the credit has changed since it was read.
+func (r *CreditRepository) Update(ctx context.Context, credit *models.Credit) error {
+	return r.update(ctx, r.db, credit)
+}
+
+// UpdateTx updates a credit's status and remaining amount within a database transaction
+func (r *CreditRepository) UpdateTx(ctx context.Context, tx Tx, credit *models.Credit) error {
+	return r.update(ctx, tx, credit)
+}
+
+func (r *CreditRepository) update(ctx context.Context, q queryer, credit *models.Credit) error {
 	query := `
 		UPDATE credits
 		SET status = $1,
 			remaining_amount = $2,
+			version = version + 1,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $3
+		WHERE id = $3 AND version = $4
+		RETURNING version, updated_at
 	`
 
-	result, err := r.db.Exec(query, credit.Status, credit.RemainingAmount, credit.ID)
-	if err != nil {
-		return err
+	err := q.QueryRowContext(ctx, query, credit.Status, credit.RemainingAmount, credit.ID, credit.Version).
+		Scan(&credit.Version, &credit.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.staleCredit(ctx, q, credit.ID)
 	}
+	return err
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+// ErrCreditModified is returned by the credit updates when the credit has been
+// updated by someone else since it was read
+var ErrCreditModified = errors.New("credit was modified concurrently")
+
+// staleCredit explains why an update matched no credit: it no longer exists or
+// its version has moved on
+func (r *CreditRepository) staleCredit(ctx context.Context, q queryer, id int64) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM credits WHERE id = $1)`, id).Scan(&exists); err != nil {
 		return err
 	}
-
-	if rowsAffected == 0 {
+	if !exists {
 		return errors.New("credit not found")
 	}
+	return ErrCreditModified
+}
+
+func (r *CreditRepository) CreatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error {
+	query := `
+		INSERT INTO payment_schedules (
+			credit_id, amount, principal, interest, insurance_premium, due_date, status, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		payment.CreditID,
+		payment.Amount,
+		payment.Principal,
+		payment.Interest,
+		payment.InsurancePremium,
+		payment.DueDate,
+		payment.Status,
+	).Scan(&payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create payment schedule: %w", err)
+	}
 
 	return nil
 }
 
-func (r *CreditRepository) CreatePaymentSchedule(payment *models.PaymentSchedule) error {
+// CreatePaymentScheduleTx inserts a scheduled payment within a database transaction
+func (r *CreditRepository) CreatePaymentScheduleTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error {
 	query := `
-		INSERT INTO payment_schedules (credit_id, amount, due_date, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO payment_schedules (
+			credit_id, amount, principal, interest, insurance_premium, due_date, status, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id
 	`
 
-	err := r.db.QueryRow(
+	err := tx.QueryRowContext(
+		ctx,
 		query,
 		payment.CreditID,
 		payment.Amount,
+		payment.Principal,
+		payment.Interest,
+		payment.InsurancePremium,
 		payment.DueDate,
 		payment.Status,
 	).Scan(&payment.ID)
@@ -321,18 +683,23 @@ func (r *CreditRepository) CreatePaymentSchedule(payment *models.PaymentSchedule
 	return nil
 }
 
-// GetCreditsWithDuePayments retrieves all active credits with due payments
-func (r *CreditRepository) GetCreditsWithDuePayments() ([]*models.Credit, error) {
+// GetCreditsWithDuePayments retrieves all credits being repaid with due payments,
+// except those paused by an insurance claim
+func (r *CreditRepository) GetCreditsWithDuePayments(ctx context.Context) ([]*models.Credit, error) {
 	query := `
-		SELECT c.id, c.user_id, c.account_id, c.amount, c.remaining_amount, c.interest_rate, 
-			c.term_months, c.status, c.created_at, c.updated_at
+		SELECT c.id, c.user_id, c.account_id, c.branch_id, c.amount, c.remaining_amount, c.interest_rate,
+			c.rate_type, c.rate_margin, c.term_months, c.schedule_type, c.status, c.version, c.created_at, c.updated_at
 		FROM credits c
 		JOIN payment_schedules ps ON c.id = ps.credit_id
-		WHERE c.status = $1 AND ps.status = $2 AND ps.due_date <= CURRENT_DATE
+		WHERE c.status IN ($1, $2, $3) AND ps.status IN ($4, $5) AND ps.due_date <= CURRENT_DATE
+			AND NOT EXISTS (
+				SELECT 1 FROM credit_insurance_claims cl WHERE cl.credit_id = c.id AND cl.status = 'approved'
+			)
 		GROUP BY c.id
 	`
 
-	rows, err := r.db.Query(query, models.CreditStatusActive, models.PaymentStatusPending)
+	rows, err := r.db.QueryContext(ctx, query, models.CreditStatusActive, models.CreditStatusDelinquent,
+		models.CreditStatusCollections, models.PaymentStatusPending, models.PaymentStatusOverdue)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query credits: %w", err)
 	}
@@ -340,11 +707,7 @@ func (r *CreditRepository) GetCreditsWithDuePayments() ([]*models.Credit, error)
 
 	var credits []*models.Credit
 	for rows.Next() {
-		credit := &models.Credit{}
-		err := rows.Scan(
-			&credit.ID, &credit.UserID, &credit.AccountID, &credit.Amount, &credit.RemainingAmount,
-			&credit.InterestRate, &credit.TermMonths, &credit.Status, &credit.CreatedAt, &credit.UpdatedAt,
-		)
+		credit, err := scanCredit(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan credit: %w", err)
 		}
@@ -354,21 +717,17 @@ func (r *CreditRepository) GetCreditsWithDuePayments() ([]*models.Credit, error)
 	return credits, nil
 }
 
-// GetNextPayment retrieves the next due payment for a credit
-func (r *CreditRepository) GetNextPayment(creditID int64) (*models.PaymentSchedule, error) {
+// GetNextPayment retrieves the oldest due or overdue payment for a credit
+func (r *CreditRepository) GetNextPayment(ctx context.Context, creditID int64) (*models.PaymentSchedule, error) {
 	query := `
-		SELECT id, credit_id, amount, due_date, status, created_at, updated_at
+		SELECT ` + paymentScheduleColumns + `
 		FROM payment_schedules
-		WHERE credit_id = $1 AND status = $2 AND due_date <= CURRENT_DATE
+		WHERE credit_id = $1 AND status IN ($2, $3) AND due_date <= CURRENT_DATE
 		ORDER BY due_date ASC
 		LIMIT 1
 	`
 
-	payment := &models.PaymentSchedule{}
-	err := r.db.QueryRow(query, creditID, models.PaymentStatusPending).Scan(
-		&payment.ID, &payment.CreditID, &payment.Amount, &payment.DueDate,
-		&payment.Status, &payment.CreatedAt, &payment.UpdatedAt,
-	)
+	payment, err := scanPaymentSchedule(r.db.QueryRowContext(ctx, query, creditID, models.PaymentStatusPending, models.PaymentStatusOverdue))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get next payment: %w", err)
 	}
@@ -376,14 +735,14 @@ func (r *CreditRepository) GetNextPayment(creditID int64) (*models.PaymentSchedu
 	return payment, nil
 }
 
-func (r *CreditRepository) UpdatePaymentStatus(paymentID int64, status string) error {
+func (r *CreditRepository) UpdatePaymentStatus(ctx context.Context, paymentID int64, status string) error {
 	query := `
 		UPDATE payment_schedules
 		SET status = $1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $2
 	`
 
-	result, err := r.db.Exec(query, status, paymentID)
+	result, err := r.db.ExecContext(ctx, query, status, paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to update payment status: %w", err)
 	}
@@ -398,3 +757,87 @@ func (r *CreditRepository) UpdatePaymentStatus(paymentID int64, status string) e
 
 	return nil
 }
+
+// UpdatePaymentAmountTx changes the amount, its principal and interest parts and the
+// amount already paid of a scheduled payment within a database transaction
+func (r *CreditRepository) UpdatePaymentAmountTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error {
+	query := `
+		UPDATE payment_schedules
+		SET amount = $1, principal = $2, interest = $3, paid_amount = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`
+
+	if _, err := tx.ExecContext(ctx, query, payment.Amount, payment.Principal, payment.Interest, payment.PaidAmount, payment.ID); err != nil {
+		return fmt.Errorf("failed to update payment amount: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePaymentPaidTx records the amount paid on an installment and its status
+// within a database transaction
+func (r *CreditRepository) UpdatePaymentPaidTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error {
+	query := `
+		UPDATE payment_schedules
+		SET paid_amount = $1, status = $2, paid_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`
+
+	if _, err := tx.ExecContext(ctx, query, payment.PaidAmount, payment.Status, payment.PaidAt, payment.ID); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	return nil
+}
+
+// ArchivePaymentTx marks an installment as replaced by a restructuring within a
+// database transaction
+func (r *CreditRepository) ArchivePaymentTx(ctx context.Context, tx Tx, paymentID, restructuringID int64) error {
+	query := `
+		UPDATE payment_schedules
+		SET status = $1, restructuring_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, query, models.PaymentStatusArchived, restructuringID, paymentID); err != nil {
+		return fmt.Errorf("failed to archive payment: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTermsTx updates a credit's interest rate, term and remaining amount within a
+// database transaction, failing with ErrCreditModified when the credit has changed
+// since it was read
+func (r *CreditRepository) UpdateTermsTx(ctx context.Context, tx Tx, credit *models.Credit) error {
+	query := `
+		UPDATE credits
+		SET interest_rate = $1,
+			term_months = $2,
+			remaining_amount = $3,
+			version = version + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND version = $5
+		RETURNING version, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, credit.InterestRate, credit.TermMonths, credit.RemainingAmount, credit.ID, credit.Version).
+		Scan(&credit.Version, &credit.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.staleCredit(ctx, tx, credit.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update credit terms: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePaymentTx removes a scheduled payment within a database transaction
+func (r *CreditRepository) DeletePaymentTx(ctx context.Context, tx Tx, paymentID int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM payment_schedules WHERE id = $1`, paymentID); err != nil {
+		return fmt.Errorf("failed to delete payment: %w", err)
+	}
+
+	return nil
+}
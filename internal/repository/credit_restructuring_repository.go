@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditRestructuringRepository handles database operations for credit restructurings
+type CreditRestructuringRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCreditRestructuringRepository creates a new CreditRestructuringRepository instance
+func NewCreditRestructuringRepository(db *sql.DB, logger *logrus.Logger) *CreditRestructuringRepository {
+	return &CreditRestructuringRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *CreditRestructuringRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates a new restructuring request within a database transaction
+func (r *CreditRestructuringRepository) CreateTx(ctx context.Context, tx Tx, restructuring *models.CreditRestructuring) error {
+	query := `
+		INSERT INTO credit_restructurings (
+			credit_id, user_id, term_extension_months, new_interest_rate, reason,
+			status, previous_interest_rate, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		restructuring.CreditID,
+		restructuring.UserID,
+		restructuring.TermExtensionMonths,
+		restructuring.NewInterestRate,
+		restructuring.Reason,
+		restructuring.Status,
+		restructuring.PreviousInterestRate,
+	).Scan(&restructuring.ID, &restructuring.CreatedAt, &restructuring.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("credit already has a pending restructuring request")
+		}
+		r.logger.WithError(err).Error("Failed to create credit restructuring")
+		return err
+	}
+
+	return nil
+}
+
+const creditRestructuringColumns = `
+	id, credit_id, user_id, term_extension_months, new_interest_rate, reason, status,
+	previous_interest_rate, previous_installments, restructured_principal, new_installments,
+	new_monthly_payment, reviewed_by, COALESCE(review_comment, ''), reviewed_at, created_at, updated_at
+`
+
+func scanCreditRestructuring(scanner interface{ Scan(...interface{}) error }) (*models.CreditRestructuring, error) {
+	restructuring := &models.CreditRestructuring{}
+	var newInterestRate, restructuredPrincipal, newMonthlyPayment sql.NullFloat64
+	var previousInstallments, newInstallments, reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+
+	err := scanner.Scan(
+		&restructuring.ID,
+		&restructuring.CreditID,
+		&restructuring.UserID,
+		&restructuring.TermExtensionMonths,
+		&newInterestRate,
+		&restructuring.Reason,
+		&restructuring.Status,
+		&restructuring.PreviousInterestRate,
+		&previousInstallments,
+		&restructuredPrincipal,
+		&newInstallments,
+		&newMonthlyPayment,
+		&reviewedBy,
+		&restructuring.ReviewComment,
+		&reviewedAt,
+		&restructuring.CreatedAt,
+		&restructuring.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	restructuring.NewInterestRate = nullableFloat(newInterestRate)
+	restructuring.RestructuredPrincipal = nullableFloat(restructuredPrincipal)
+	restructuring.NewMonthlyPayment = nullableFloat(newMonthlyPayment)
+	if previousInstallments.Valid {
+		count := int(previousInstallments.Int64)
+		restructuring.PreviousInstallments = &count
+	}
+	if newInstallments.Valid {
+		count := int(newInstallments.Int64)
+		restructuring.NewInstallments = &count
+	}
+	if reviewedBy.Valid {
+		restructuring.ReviewedBy = &reviewedBy.Int64
+	}
+	if reviewedAt.Valid {
+		restructuring.ReviewedAt = &reviewedAt.Time
+	}
+
+	return restructuring, nil
+}
+
+// GetByIDForUpdate retrieves a restructuring and locks its row until the transaction ends
+func (r *CreditRestructuringRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.CreditRestructuring, error) {
+	query := `SELECT ` + creditRestructuringColumns + ` FROM credit_restructurings WHERE id = $1 FOR UPDATE`
+
+	restructuring, err := scanCreditRestructuring(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credit restructuring not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock credit restructuring")
+		return nil, err
+	}
+
+	return restructuring, nil
+}
+
+// GetByCreditID retrieves the restructuring history of a credit, newest first
+func (r *CreditRestructuringRepository) GetByCreditID(ctx context.Context, creditID int64) ([]*models.CreditRestructuring, error) {
+	query := `
+		SELECT ` + creditRestructuringColumns + `
+		FROM credit_restructurings
+		WHERE credit_id = $1
+		ORDER BY created_at DESC
+	`
+
+	return r.list(ctx, query, creditID)
+}
+
+// List retrieves credit restructurings, optionally filtered by status
+func (r *CreditRestructuringRepository) List(ctx context.Context, status models.CreditRestructuringStatus) ([]*models.CreditRestructuring, error) {
+	query := `
+		SELECT ` + creditRestructuringColumns + `
+		FROM credit_restructurings
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+	`
+
+	return r.list(ctx, query, string(status))
+}
+
+func (r *CreditRestructuringRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.CreditRestructuring, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credit restructurings")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var restructurings []*models.CreditRestructuring
+	for rows.Next() {
+		restructuring, err := scanCreditRestructuring(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan credit restructuring row")
+			return nil, err
+		}
+		restructurings = append(restructurings, restructuring)
+	}
+
+	return restructurings, rows.Err()
+}
+
+// UpdateReviewTx stores the review outcome of a restructuring within a database transaction
+func (r *CreditRestructuringRepository) UpdateReviewTx(ctx context.Context, tx Tx, restructuring *models.CreditRestructuring) error {
+	query := `
+		UPDATE credit_restructurings
+		SET status = $1, previous_installments = $2, restructured_principal = $3, new_installments = $4,
+			new_monthly_payment = $5, reviewed_by = $6, review_comment = $7, reviewed_at = $8,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9
+	`
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		restructuring.Status,
+		restructuring.PreviousInstallments,
+		restructuring.RestructuredPrincipal,
+		restructuring.NewInstallments,
+		restructuring.NewMonthlyPayment,
+		restructuring.ReviewedBy,
+		restructuring.ReviewComment,
+		restructuring.ReviewedAt,
+		restructuring.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update credit restructuring review")
+		return err
+	}
+
+	return nil
+}
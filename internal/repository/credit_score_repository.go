@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditScoreRepository handles database operations for the history of users'
+// internal credit scores
+type CreditScoreRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewCreditScoreRepository creates a new CreditScoreRepository instance
+func NewCreditScoreRepository(db *sql.DB, logger *logrus.Logger) *CreditScoreRepository {
+	return &CreditScoreRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create records a credit score
+func (r *CreditScoreRepository) Create(ctx context.Context, score *models.CreditScore) error {
+	factors, err := json.Marshal(score.Factors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO credit_scores (user_id, score, rating, factors, reason, calculated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query, score.UserID, score.Score, score.Rating, factors, score.Reason,
+		score.CalculatedAt).Scan(&score.ID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to record credit score of user %d", score.UserID)
+		return err
+	}
+	return nil
+}
+
+// ListByUser retrieves the latest credit scores of a user, latest first
+func (r *CreditScoreRepository) ListByUser(ctx context.Context, userID int64, limit int) ([]*models.CreditScore, error) {
+	query := `
+		SELECT id, user_id, score, rating, factors, reason, calculated_at
+		FROM credit_scores
+		WHERE user_id = $1
+		ORDER BY calculated_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list credit scores")
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []*models.CreditScore{}
+	for rows.Next() {
+		score := &models.CreditScore{}
+		var factors []byte
+		if err := rows.Scan(&score.ID, &score.UserID, &score.Score, &score.Rating, &factors, &score.Reason,
+			&score.CalculatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(factors, &score.Factors); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// DashboardRepository handles the queries of the admin dashboard that span
+// several kinds of records
+type DashboardRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewDashboardRepository creates a new DashboardRepository instance
+func NewDashboardRepository(db *sql.DB, logger *logrus.Logger) *DashboardRepository {
+	return &DashboardRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListFailedPayments retrieves the payments that failed since since, latest
+// first: bill payments refunded after the provider failed them, transfers to
+// other banks returned and credit installments gone overdue
+func (r *DashboardRepository) ListFailedPayments(ctx context.Context, since time.Time, limit int) ([]*models.FailedPayment, error) {
+	query := `
+		SELECT kind, id, user_id, amount, currency, reason, failed_at
+		FROM (
+			SELECT $1::text AS kind, id, user_id, amount, currency, COALESCE(failure_reason, '') AS reason, updated_at AS failed_at
+			FROM bill_payments
+			WHERE status = $2 AND updated_at >= $7
+			UNION ALL
+			SELECT $3::text, id, user_id, amount, currency, COALESCE(return_reason, ''), COALESCE(completed_at, updated_at)
+			FROM external_transfers
+			WHERE status = $4 AND COALESCE(completed_at, updated_at) >= $7
+			UNION ALL
+			SELECT $5::text, ps.id, c.user_id, ps.amount + ps.penalty - ps.paid_amount, a.currency, '', ps.due_date
+			FROM payment_schedules ps
+			JOIN credits c ON c.id = ps.credit_id
+			JOIN accounts a ON a.id = c.account_id
+			WHERE ps.status = $6 AND ps.due_date >= $7
+		) failed
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $8
+	`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		models.FailedPaymentBill, models.BillPaymentStatusRefunded,
+		models.FailedPaymentExternalTransfer, models.ExternalTransferStatusReturned,
+		models.FailedPaymentCreditInstallment, models.PaymentStatusOverdue,
+		since, limit,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list failed payments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []*models.FailedPayment{}
+	for rows.Next() {
+		payment := &models.FailedPayment{}
+		if err := rows.Scan(
+			&payment.Kind,
+			&payment.ID,
+			&payment.UserID,
+			&payment.Amount,
+			&payment.Currency,
+			&payment.Reason,
+			&payment.FailedAt,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to scan failed payment")
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
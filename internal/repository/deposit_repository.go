@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// DepositRepository handles database operations for term deposits
+type DepositRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewDepositRepository creates a new DepositRepository instance
+func NewDepositRepository(db *sql.DB, logger *logrus.Logger) *DepositRepository {
+	return &DepositRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *DepositRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx records an opened deposit within a database transaction
+func (r *DepositRepository) CreateTx(ctx context.Context, tx Tx, deposit *models.Deposit) error {
+	query := `
+		INSERT INTO deposits (
+			user_id, account_id, amount, currency, term_months, rate_type, interest_rate, key_rate,
+			interest_accrued_at, status, opened_at, matures_at, opening_transaction_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, deposit.UserID, deposit.AccountID, deposit.Amount, deposit.Currency,
+		deposit.TermMonths, deposit.RateType, deposit.InterestRate, deposit.KeyRate, deposit.InterestAccruedAt,
+		deposit.Status, deposit.OpenedAt, deposit.MaturesAt, deposit.OpeningTransactionID,
+	).Scan(&deposit.ID, &deposit.CreatedAt, &deposit.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create deposit")
+		return err
+	}
+	return nil
+}
+
+const depositColumns = `
+	id, user_id, account_id, amount, currency, term_months, rate_type, interest_rate, key_rate,
+	accrued_interest, interest_accrued_at, status, opened_at, matures_at, closed_at, paid_interest, penalty,
+	opening_transaction_id, return_transaction_id, interest_transaction_id, created_at, updated_at
+`
+
+func scanDeposit(scanner interface{ Scan(...interface{}) error }) (*models.Deposit, error) {
+	deposit := &models.Deposit{}
+	var keyRate sql.NullFloat64
+	var closedAt sql.NullTime
+	var returnTransactionID, interestTransactionID sql.NullInt64
+
+	err := scanner.Scan(
+		&deposit.ID,
+		&deposit.UserID,
+		&deposit.AccountID,
+		&deposit.Amount,
+		&deposit.Currency,
+		&deposit.TermMonths,
+		&deposit.RateType,
+		&deposit.InterestRate,
+		&keyRate,
+		&deposit.AccruedInterest,
+		&deposit.InterestAccruedAt,
+		&deposit.Status,
+		&deposit.OpenedAt,
+		&deposit.MaturesAt,
+		&closedAt,
+		&deposit.PaidInterest,
+		&deposit.Penalty,
+		&deposit.OpeningTransactionID,
+		&returnTransactionID,
+		&interestTransactionID,
+		&deposit.CreatedAt,
+		&deposit.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deposit.KeyRate = nullableFloat(keyRate)
+	if closedAt.Valid {
+		deposit.ClosedAt = &closedAt.Time
+	}
+	if returnTransactionID.Valid {
+		deposit.ReturnTransactionID = &returnTransactionID.Int64
+	}
+	if interestTransactionID.Valid {
+		deposit.InterestTransactionID = &interestTransactionID.Int64
+	}
+	return deposit, nil
+}
+
+// GetByID retrieves a deposit
+func (r *DepositRepository) GetByID(ctx context.Context, id int64) (*models.Deposit, error) {
+	query := `SELECT ` + depositColumns + ` FROM deposits WHERE id = $1`
+
+	deposit, err := scanDeposit(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("deposit not found")
+		}
+		r.logger.WithError(err).Error("Failed to get deposit")
+		return nil, err
+	}
+	return deposit, nil
+}
+
+// GetByIDForUpdate retrieves and locks a deposit within a database transaction
+func (r *DepositRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Deposit, error) {
+	query := `SELECT ` + depositColumns + ` FROM deposits WHERE id = $1 FOR UPDATE`
+
+	deposit, err := scanDeposit(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("deposit not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock deposit")
+		return nil, err
+	}
+	return deposit, nil
+}
+
+// ListByUser retrieves a page of the deposits of a user and how many there are
+func (r *DepositRepository) ListByUser(ctx context.Context, userID int64, p pagination.Params) ([]*models.Deposit, int, error) {
+	rows, total, err := queryPage(ctx, r.db, depositColumns, "deposits", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list deposits")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deposits := []*models.Deposit{}
+	for rows.Next() {
+		deposit, err := scanDeposit(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deposits = append(deposits, deposit)
+	}
+	return deposits, total, rows.Err()
+}
+
+// HasActive reports whether the user has a deposit that has not been paid out
+func (r *DepositRepository) HasActive(ctx context.Context, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM deposits WHERE user_id = $1 AND status = $2)`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, userID, models.DepositStatusActive).Scan(&exists); err != nil {
+		r.logger.WithError(err).Error("Failed to check active deposits")
+		return false, err
+	}
+	return exists, nil
+}
+
+// HasActiveOnAccount reports whether a deposit that has not been paid out is
+// to be paid out to the account
+func (r *DepositRepository) HasActiveOnAccount(ctx context.Context, accountID int64) (bool, error) {
+	return r.hasActiveOnAccount(ctx, r.db, accountID)
+}
+
+// HasActiveOnAccountTx is HasActiveOnAccount within a database transaction
+func (r *DepositRepository) HasActiveOnAccountTx(ctx context.Context, tx Tx, accountID int64) (bool, error) {
+	return r.hasActiveOnAccount(ctx, tx, accountID)
+}
+
+func (r *DepositRepository) hasActiveOnAccount(ctx context.Context, q queryer, accountID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM deposits WHERE account_id = $1 AND status = $2)`
+
+	var exists bool
+	if err := q.QueryRowContext(ctx, query, accountID, models.DepositStatusActive).Scan(&exists); err != nil {
+		r.logger.WithError(err).Error("Failed to check active deposits of account")
+		return false, err
+	}
+	return exists, nil
+}
+
+// ListToAccrue retrieves the IDs of the active deposits whose interest has not
+// been accrued up to the given day or that matured by then
+func (r *DepositRepository) ListToAccrue(ctx context.Context, day time.Time) ([]int64, error) {
+	query := `
+		SELECT id FROM deposits
+		WHERE status = $1 AND (interest_accrued_at < $2 OR matures_at <= $2)
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.DepositStatusActive, day)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list deposits to accrue")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateTx stores the accrued interest of a deposit and how it was paid out
+// within a database transaction
+func (r *DepositRepository) UpdateTx(ctx context.Context, tx Tx, deposit *models.Deposit) error {
+	query := `
+		UPDATE deposits
+		SET accrued_interest = $1, interest_accrued_at = $2, status = $3, closed_at = $4, paid_interest = $5,
+			penalty = $6, return_transaction_id = $7, interest_transaction_id = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, deposit.AccruedInterest, deposit.InterestAccruedAt, deposit.Status,
+		deposit.ClosedAt, deposit.PaidInterest, deposit.Penalty, deposit.ReturnTransactionID,
+		deposit.InterestTransactionID, deposit.ID,
+	).Scan(&deposit.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update deposit %d", deposit.ID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// DisputeRepository handles database operations for disputes of transactions
+// and the files attached to them
+type DisputeRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewDisputeRepository creates a new DisputeRepository instance
+func NewDisputeRepository(db *sql.DB, logger *logrus.Logger) *DisputeRepository {
+	return &DisputeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateTx records an open dispute within a database transaction. It returns
+// "transaction is already disputed" when the transaction has a dispute.
+func (r *DisputeRepository) CreateTx(ctx context.Context, tx Tx, dispute *models.Dispute) error {
+	query := `
+		INSERT INTO disputes (transaction_id, account_id, user_id, reason, description, amount, currency, status)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, dispute.TransactionID, dispute.AccountID, dispute.UserID, dispute.Reason,
+		dispute.Description, dispute.Amount, dispute.Currency, dispute.Status,
+	).Scan(&dispute.ID, &dispute.CreatedAt, &dispute.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("transaction is already disputed")
+		}
+		r.logger.WithError(err).Error("Failed to create dispute")
+		return err
+	}
+	return nil
+}
+
+// CreateAttachmentTx records a file attached to a dispute within a database transaction
+func (r *DisputeRepository) CreateAttachmentTx(ctx context.Context, tx Tx, attachment *models.DisputeAttachment) error {
+	query := `
+		INSERT INTO dispute_attachments (dispute_id, storage_key, file_name, content_type, size, created_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, attachment.DisputeID, attachment.StorageKey, attachment.FileName,
+		attachment.ContentType, attachment.Size).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create dispute attachment")
+		return err
+	}
+	return nil
+}
+
+const disputeColumns = `
+	id, transaction_id, account_id, user_id, reason, COALESCE(description, ''), amount, currency, status,
+	reviewer_id, COALESCE(review_comment, ''), provisional_transaction_id, reversal_transaction_id, reviewed_at,
+	created_at, updated_at
+`
+
+func scanDispute(scanner interface{ Scan(...interface{}) error }) (*models.Dispute, error) {
+	dispute := &models.Dispute{}
+	var reviewerID, provisionalID, reversalID sql.NullInt64
+	var reviewedAt sql.NullTime
+
+	err := scanner.Scan(
+		&dispute.ID,
+		&dispute.TransactionID,
+		&dispute.AccountID,
+		&dispute.UserID,
+		&dispute.Reason,
+		&dispute.Description,
+		&dispute.Amount,
+		&dispute.Currency,
+		&dispute.Status,
+		&reviewerID,
+		&dispute.ReviewComment,
+		&provisionalID,
+		&reversalID,
+		&reviewedAt,
+		&dispute.CreatedAt,
+		&dispute.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reviewerID.Valid {
+		dispute.ReviewerID = &reviewerID.Int64
+	}
+	if provisionalID.Valid {
+		dispute.ProvisionalTransactionID = &provisionalID.Int64
+	}
+	if reversalID.Valid {
+		dispute.ReversalTransactionID = &reversalID.Int64
+	}
+	if reviewedAt.Valid {
+		dispute.ReviewedAt = &reviewedAt.Time
+	}
+	return dispute, nil
+}
+
+// GetByID retrieves a dispute, without its attachments
+func (r *DisputeRepository) GetByID(ctx context.Context, id int64) (*models.Dispute, error) {
+	query := `SELECT ` + disputeColumns + ` FROM disputes WHERE id = $1`
+
+	dispute, err := scanDispute(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("dispute not found")
+		}
+		r.logger.WithError(err).Error("Failed to get dispute")
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// GetByIDForUpdate retrieves and locks a dispute within a database transaction
+func (r *DisputeRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Dispute, error) {
+	query := `SELECT ` + disputeColumns + ` FROM disputes WHERE id = $1 FOR UPDATE`
+
+	dispute, err := scanDispute(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("dispute not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock dispute")
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// List retrieves a page of the disputes of a user, or of all users when the
+// user ID is zero, and how many there are
+func (r *DisputeRepository) List(ctx context.Context, userID int64, p pagination.Params) ([]*models.Dispute, int, error) {
+	rows, total, err := queryPage(ctx, r.db, disputeColumns, "disputes", "($1 = 0 OR user_id = $1)", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list disputes")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	disputes := []*models.Dispute{}
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		disputes = append(disputes, dispute)
+	}
+	return disputes, total, rows.Err()
+}
+
+// UpdateReviewTx stores the status, review and transactions of a dispute within
+// a database transaction
+func (r *DisputeRepository) UpdateReviewTx(ctx context.Context, tx Tx, dispute *models.Dispute) error {
+	query := `
+		UPDATE disputes
+		SET status = $1, reviewer_id = $2, review_comment = NULLIF($3, ''), provisional_transaction_id = $4,
+			reversal_transaction_id = $5, reviewed_at = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, dispute.Status, dispute.ReviewerID, dispute.ReviewComment,
+		dispute.ProvisionalTransactionID, dispute.ReversalTransactionID, dispute.ReviewedAt, dispute.ID,
+	).Scan(&dispute.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update dispute %d", dispute.ID)
+		return err
+	}
+	return nil
+}
+
+const disputeAttachmentColumns = `id, dispute_id, storage_key, file_name, content_type, size, created_at`
+
+func scanDisputeAttachment(scanner interface{ Scan(...interface{}) error }) (*models.DisputeAttachment, error) {
+	attachment := &models.DisputeAttachment{}
+	err := scanner.Scan(&attachment.ID, &attachment.DisputeID, &attachment.StorageKey, &attachment.FileName,
+		&attachment.ContentType, &attachment.Size, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// GetAttachment retrieves a file attached to a dispute
+func (r *DisputeRepository) GetAttachment(ctx context.Context, disputeID, id int64) (*models.DisputeAttachment, error) {
+	query := `SELECT ` + disputeAttachmentColumns + ` FROM dispute_attachments WHERE id = $1 AND dispute_id = $2`
+
+	attachment, err := scanDisputeAttachment(r.db.QueryRowContext(ctx, query, id, disputeID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("attachment not found")
+		}
+		r.logger.WithError(err).Error("Failed to get dispute attachment")
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// ListAttachments retrieves the files attached to a dispute in the order they were uploaded
+func (r *DisputeRepository) ListAttachments(ctx context.Context, disputeID int64) ([]*models.DisputeAttachment, error) {
+	query := `SELECT ` + disputeAttachmentColumns + ` FROM dispute_attachments WHERE dispute_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, disputeID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list dispute attachments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.DisputeAttachment{}
+	for rows.Next() {
+		attachment, err := scanDisputeAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// BeginTransaction starts a new database transaction
+func (r *DisputeRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres SQLSTATE of a unique constraint violation
+const uniqueViolation = "23505"
+
+// isUniqueViolation reports whether err is a unique constraint violation. It
+// recognises the errors of both lib/pq and pgx, which expose the SQLSTATE
+// through an SQLState method.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == uniqueViolation
+	}
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == uniqueViolation
+}
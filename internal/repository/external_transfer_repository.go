@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ExternalTransferRepository handles database operations for transfers to other banks
+type ExternalTransferRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewExternalTransferRepository creates a new ExternalTransferRepository instance
+func NewExternalTransferRepository(db *sql.DB, logger *logrus.Logger) *ExternalTransferRepository {
+	return &ExternalTransferRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *ExternalTransferRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+const externalTransferColumns = `
+	id, user_id, account_id, transaction_id, beneficiary_name, COALESCE(iban, ''),
+	COALESCE(account_number, ''), bic, amount, currency, COALESCE(reference, ''), status,
+	COALESCE(return_reason, ''), return_transaction_id, submitted_at, completed_at, created_at, updated_at
+`
+
+func scanExternalTransfer(scanner interface{ Scan(...interface{}) error }) (*models.ExternalTransfer, error) {
+	transfer := &models.ExternalTransfer{}
+	var transaction, returnTransaction sql.NullInt64
+	var submittedAt, completedAt sql.NullTime
+
+	err := scanner.Scan(
+		&transfer.ID,
+		&transfer.UserID,
+		&transfer.AccountID,
+		&transaction,
+		&transfer.BeneficiaryName,
+		&transfer.IBAN,
+		&transfer.AccountNumber,
+		&transfer.BIC,
+		&transfer.Amount,
+		&transfer.Currency,
+		&transfer.Reference,
+		&transfer.Status,
+		&transfer.ReturnReason,
+		&returnTransaction,
+		&submittedAt,
+		&completedAt,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.Valid {
+		transfer.TransactionID = &transaction.Int64
+	}
+	if returnTransaction.Valid {
+		transfer.ReturnTransaction = &returnTransaction.Int64
+	}
+	if submittedAt.Valid {
+		transfer.SubmittedAt = &submittedAt.Time
+	}
+	if completedAt.Valid {
+		transfer.CompletedAt = &completedAt.Time
+	}
+
+	return transfer, nil
+}
+
+// CreateTx stores a new transfer within a database transaction
+func (r *ExternalTransferRepository) CreateTx(ctx context.Context, tx Tx, transfer *models.ExternalTransfer) error {
+	query := `
+		INSERT INTO external_transfers (
+			user_id, account_id, transaction_id, beneficiary_name, iban, account_number,
+			bic, amount, currency, reference, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, NULLIF($10, ''), $11, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		transfer.UserID,
+		transfer.AccountID,
+		transfer.TransactionID,
+		transfer.BeneficiaryName,
+		transfer.IBAN,
+		transfer.AccountNumber,
+		transfer.BIC,
+		transfer.Amount,
+		transfer.Currency,
+		transfer.Reference,
+		transfer.Status,
+	).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create external transfer")
+		return err
+	}
+
+	return nil
+}
+
+// GetByID retrieves a transfer by its ID
+func (r *ExternalTransferRepository) GetByID(ctx context.Context, id int64) (*models.ExternalTransfer, error) {
+	query := `SELECT ` + externalTransferColumns + ` FROM external_transfers WHERE id = $1`
+
+	transfer, err := scanExternalTransfer(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("external transfer not found")
+		}
+		r.logger.WithError(err).Error("Failed to get external transfer")
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetByIDForUpdate retrieves a transfer and locks its row until the transaction ends
+func (r *ExternalTransferRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.ExternalTransfer, error) {
+	query := `SELECT ` + externalTransferColumns + ` FROM external_transfers WHERE id = $1 FOR UPDATE`
+
+	transfer, err := scanExternalTransfer(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("external transfer not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock external transfer")
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetByUserID retrieves the transfers of a user, newest first
+func (r *ExternalTransferRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.ExternalTransfer, error) {
+	query := `SELECT ` + externalTransferColumns + ` FROM external_transfers WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list external transfers")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []*models.ExternalTransfer
+	for rows.Next() {
+		transfer, err := scanExternalTransfer(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan external transfer row")
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// GetIDsByStatus retrieves the IDs of the transfers in a status that were last
+// changed no later than before, oldest first
+func (r *ExternalTransferRepository) GetIDsByStatus(ctx context.Context, status models.ExternalTransferStatus, before time.Time) ([]int64, error) {
+	query := `SELECT id FROM external_transfers WHERE status = $1 AND updated_at <= $2 ORDER BY updated_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, status, before)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list external transfers by status")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// UpdateStatusTx stores the status of a transfer with its timestamps, debit and
+// return details within a database transaction
+func (r *ExternalTransferRepository) UpdateStatusTx(ctx context.Context, tx Tx, transfer *models.ExternalTransfer) error {
+	query := `
+		UPDATE external_transfers
+		SET status = $1, transaction_id = $2, return_reason = NULLIF($3, ''), return_transaction_id = $4,
+			submitted_at = $5, completed_at = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		transfer.Status,
+		transfer.TransactionID,
+		transfer.ReturnReason,
+		transfer.ReturnTransaction,
+		transfer.SubmittedAt,
+		transfer.CompletedAt,
+		transfer.ID,
+	).Scan(&transfer.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("external transfer not found")
+		}
+		r.logger.WithError(err).Error("Failed to update external transfer status")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ForecastRepository reads the transaction history the cash-flow forecast and
+// the transaction reports of a user are built from
+type ForecastRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewForecastRepository creates a new ForecastRepository instance
+func NewForecastRepository(db *sql.DB, logger *logrus.Logger) *ForecastRepository {
+	return &ForecastRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListCashFlows retrieves the transactions into and out of the accounts of a
+// user posted from since up to before until, oldest first, in the currency of
+// the user's account. Outgoing payments are keyed by their counterparty: the
+// biller and customer account of a bill payment, the account a transfer went
+// to, or else the type and description, such as the merchant of a card payment.
+func (r *ForecastRepository) ListCashFlows(ctx context.Context, userID int64, since, until time.Time) ([]*models.CashFlow, error) {
+	query := `
+		SELECT t.type, COALESCE(dst.user_id = $1, FALSE),
+			CASE
+				WHEN bp.id IS NOT NULL THEN 'biller:' || bp.biller_id || ':' || bp.customer_account
+				WHEN t.type = $4 AND t.to_account_id IS NOT NULL THEN 'account:' || t.to_account_id
+				ELSE t.type || ':' || COALESCE(t.description, '')
+			END,
+			COALESCE(b.category, ''), t.amount, COALESCE(src.currency, dst.currency), t.created_at
+		FROM transactions t
+		LEFT JOIN accounts src ON src.id = t.from_account_id
+		LEFT JOIN accounts dst ON dst.id = t.to_account_id
+		LEFT JOIN bill_payments bp ON bp.transaction_id = t.id
+		LEFT JOIN billers b ON b.id = bp.biller_id
+		WHERE (src.user_id = $1 OR dst.user_id = $1)
+			AND NOT (COALESCE(src.user_id = $1, FALSE) AND COALESCE(dst.user_id = $1, FALSE))
+			AND t.created_at >= $2 AND t.created_at < $3
+		ORDER BY t.created_at, t.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until, models.TransactionTypeTransfer)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list cash flows")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flows []*models.CashFlow
+	for rows.Next() {
+		flow := &models.CashFlow{}
+		if err := rows.Scan(&flow.Type, &flow.Incoming, &flow.Counterparty, &flow.BillerCategory, &flow.Amount, &flow.Currency, &flow.CreatedAt); err != nil {
+			return nil, err
+		}
+		flows = append(flows, flow)
+	}
+	return flows, rows.Err()
+}
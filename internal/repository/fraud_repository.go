@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// FraudRepository handles database operations for fraud checks and the
+// transaction history the fraud rules are evaluated against
+type FraudRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewFraudRepository creates a new FraudRepository instance
+func NewFraudRepository(db *sql.DB, logger *logrus.Logger) *FraudRepository {
+	return &FraudRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *FraudRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// Create records a fraud check
+func (r *FraudRepository) Create(ctx context.Context, check *models.FraudCheck) error {
+	return r.create(ctx, r.db, check)
+}
+
+// CreateTx records a fraud check within the transaction of the checked transfer or withdrawal
+func (r *FraudRepository) CreateTx(ctx context.Context, tx Tx, check *models.FraudCheck) error {
+	return r.create(ctx, tx, check)
+}
+
+func (r *FraudRepository) create(ctx context.Context, q queryer, check *models.FraudCheck) error {
+	ruleHits, err := json.Marshal(check.RuleHits)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO fraud_checks (
+			user_id, type, from_account_id, to_account_id, amount, currency, client_ip, client_country,
+			decision, rule_hits, status, transaction_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, $11, $12, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = q.QueryRowContext(
+		ctx,
+		query,
+		check.UserID,
+		check.Type,
+		check.FromAccountID,
+		check.ToAccountID,
+		check.Amount,
+		check.Currency,
+		check.ClientIP,
+		check.ClientCountry,
+		check.Decision,
+		ruleHits,
+		check.Status,
+		check.TransactionID,
+	).Scan(&check.ID, &check.CreatedAt, &check.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create fraud check")
+		return err
+	}
+
+	return nil
+}
+
+const fraudCheckColumns = `
+	id, user_id, type, from_account_id, to_account_id, amount, currency,
+	COALESCE(client_ip, ''), COALESCE(client_country, ''), decision, rule_hits, status,
+	transaction_id, reviewed_by, COALESCE(review_comment, ''), reviewed_at, created_at, updated_at
+`
+
+func scanFraudCheck(scanner interface{ Scan(...interface{}) error }) (*models.FraudCheck, error) {
+	check := &models.FraudCheck{}
+	var toAccountID, transactionID, reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	var ruleHits []byte
+
+	err := scanner.Scan(
+		&check.ID,
+		&check.UserID,
+		&check.Type,
+		&check.FromAccountID,
+		&toAccountID,
+		&check.Amount,
+		&check.Currency,
+		&check.ClientIP,
+		&check.ClientCountry,
+		&check.Decision,
+		&ruleHits,
+		&check.Status,
+		&transactionID,
+		&reviewedBy,
+		&check.ReviewComment,
+		&reviewedAt,
+		&check.CreatedAt,
+		&check.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(ruleHits, &check.RuleHits); err != nil {
+		return nil, err
+	}
+	if toAccountID.Valid {
+		check.ToAccountID = &toAccountID.Int64
+	}
+	if transactionID.Valid {
+		check.TransactionID = &transactionID.Int64
+	}
+	if reviewedBy.Valid {
+		check.ReviewedBy = &reviewedBy.Int64
+	}
+	if reviewedAt.Valid {
+		check.ReviewedAt = &reviewedAt.Time
+	}
+
+	return check, nil
+}
+
+// GetByID retrieves a fraud check by its ID
+func (r *FraudRepository) GetByID(ctx context.Context, id int64) (*models.FraudCheck, error) {
+	query := `SELECT ` + fraudCheckColumns + ` FROM fraud_checks WHERE id = $1`
+
+	check, err := scanFraudCheck(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("fraud check not found")
+		}
+		r.logger.WithError(err).Error("Failed to get fraud check")
+		return nil, err
+	}
+
+	return check, nil
+}
+
+// GetByIDForUpdate retrieves a fraud check and locks its row until the transaction ends
+func (r *FraudRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.FraudCheck, error) {
+	query := `SELECT ` + fraudCheckColumns + ` FROM fraud_checks WHERE id = $1 FOR UPDATE`
+
+	check, err := scanFraudCheck(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("fraud check not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock fraud check")
+		return nil, err
+	}
+
+	return check, nil
+}
+
+// List retrieves fraud checks, optionally filtered by status and decision. Checks
+// that triggered no rule are only listed when asked for by their decision.
+func (r *FraudRepository) List(ctx context.Context, status models.FraudCheckStatus, decision models.FraudDecision) ([]*models.FraudCheck, error) {
+	query := `
+		SELECT ` + fraudCheckColumns + `
+		FROM fraud_checks
+		WHERE ($1 = '' OR status = $1)
+			AND (decision = $2 OR ($2 = '' AND decision <> 'allow'))
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, string(status), string(decision))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list fraud checks")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*models.FraudCheck
+	for rows.Next() {
+		check, err := scanFraudCheck(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan fraud check row")
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, rows.Err()
+}
+
+// UpdateReviewTx stores the review outcome of a held check within a database transaction
+func (r *FraudRepository) UpdateReviewTx(ctx context.Context, tx Tx, check *models.FraudCheck) error {
+	query := `
+		UPDATE fraud_checks
+		SET status = $1, transaction_id = $2, reviewed_by = $3, review_comment = $4, reviewed_at = $5,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+	`
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		check.Status,
+		check.TransactionID,
+		check.ReviewedBy,
+		check.ReviewComment,
+		check.ReviewedAt,
+		check.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update fraud check review")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("fraud check not found")
+	}
+
+	return nil
+}
+
+// GetOutgoingActivity summarizes the transfers and withdrawals made from the
+// accounts of a user since the given time
+func (r *FraudRepository) GetOutgoingActivity(ctx context.Context, userID int64, since time.Time) (*models.OutgoingActivity, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(t.amount), 0), COALESCE(AVG(t.amount), 0)
+		FROM transactions t
+		JOIN accounts a ON a.id = t.from_account_id
+		WHERE a.user_id = $1 AND t.type IN ($2, $3) AND t.created_at >= $4
+	`
+
+	activity := &models.OutgoingActivity{}
+	err := r.db.QueryRowContext(
+		ctx, query, userID, models.TransactionTypeTransfer, models.TransactionTypeWithdrawal, since,
+	).Scan(&activity.Count, &activity.Total, &activity.Average)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get outgoing activity")
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+// HasTransferredTo reports whether a user has transferred money to an account before
+func (r *FraudRepository) HasTransferredTo(ctx context.Context, userID, toAccountID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM transactions t
+			JOIN accounts a ON a.id = t.from_account_id
+			WHERE a.user_id = $1 AND t.to_account_id = $2 AND t.type = $3
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, userID, toAccountID, models.TransactionTypeTransfer).Scan(&exists); err != nil {
+		r.logger.WithError(err).Error("Failed to check previous transfers")
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetClientLocations retrieves the addresses and countries a user made executed
+// transactions from since the given time
+func (r *FraudRepository) GetClientLocations(ctx context.Context, userID int64, since time.Time) ([]models.ClientLocation, error) {
+	query := `
+		SELECT DISTINCT COALESCE(client_ip, ''), COALESCE(client_country, '')
+		FROM fraud_checks
+		WHERE user_id = $1 AND status IN ($2, $3) AND created_at >= $4
+	`
+
+	rows, err := r.db.QueryContext(
+		ctx, query, userID, models.FraudCheckStatusCompleted, models.FraudCheckStatusReleased, since,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get client locations")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []models.ClientLocation
+	for rows.Next() {
+		var location models.ClientLocation
+		if err := rows.Scan(&location.IP, &location.Country); err != nil {
+			r.logger.WithError(err).Error("Failed to scan client location row")
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
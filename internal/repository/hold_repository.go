@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// HoldRepository handles database operations for the holds placed on account balances
+type HoldRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewHoldRepository creates a new HoldRepository instance
+func NewHoldRepository(db *sql.DB, logger *logrus.Logger) *HoldRepository {
+	return &HoldRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// activeHolds selects the holds that count against the available balance
+const activeHolds = `status = 'active' AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
+
+// CreateTx places a hold within a database transaction. The caller holds the
+// account lock, so the hold is counted by everyone who locks the account next.
+func (r *HoldRepository) CreateTx(ctx context.Context, tx Tx, hold *models.Hold) error {
+	query := `
+		INSERT INTO holds (account_id, amount, currency, source, source_id, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	hold.Status = models.HoldStatusActive
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		hold.AccountID,
+		hold.Amount,
+		hold.Currency,
+		hold.Source,
+		hold.SourceID,
+		hold.Status,
+		hold.ExpiresAt,
+	).Scan(&hold.ID, &hold.CreatedAt, &hold.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create hold")
+		return err
+	}
+
+	return nil
+}
+
+// CloseTx settles or releases the active hold of a payment within a database
+// transaction. A payment without an active hold, such as a transfer accepted
+// before holds were placed, is left as it is.
+func (r *HoldRepository) CloseTx(ctx context.Context, tx Tx, source models.HoldSource, sourceID int64, status models.HoldStatus) error {
+	if status == models.HoldStatusActive {
+		return errors.New("a hold can only be settled or released")
+	}
+
+	query := `
+		UPDATE holds
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE source = $2 AND source_id = $3 AND status = 'active'
+	`
+	if _, err := tx.ExecContext(ctx, query, status, source, sourceID); err != nil {
+		r.logger.WithError(err).Errorf("Failed to close hold of %s %d", source, sourceID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// IncomingPaymentRepository handles database operations for credit advices from the payment rail
+type IncomingPaymentRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewIncomingPaymentRepository creates a new IncomingPaymentRepository instance
+func NewIncomingPaymentRepository(db *sql.DB, logger *logrus.Logger) *IncomingPaymentRepository {
+	return &IncomingPaymentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *IncomingPaymentRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+const incomingPaymentColumns = `
+	id, reference, payer_name, COALESCE(payer_account, ''), account_number, account_id, amount,
+	currency, COALESCE(description, ''), status, COALESCE(reason, ''), transaction_id, created_at
+`
+
+func scanIncomingPayment(scanner interface{ Scan(...interface{}) error }) (*models.IncomingPayment, error) {
+	payment := &models.IncomingPayment{}
+	var accountID, transactionID sql.NullInt64
+
+	err := scanner.Scan(
+		&payment.ID,
+		&payment.Reference,
+		&payment.PayerName,
+		&payment.PayerAccount,
+		&payment.AccountNumber,
+		&accountID,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.Description,
+		&payment.Status,
+		&payment.Reason,
+		&transactionID,
+		&payment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountID.Valid {
+		payment.AccountID = &accountID.Int64
+	}
+	if transactionID.Valid {
+		payment.TransactionID = &transactionID.Int64
+	}
+
+	return payment, nil
+}
+
+// CreateTx stores a received payment within a database transaction. It reports
+// false without storing anything when a payment with the same reference exists.
+func (r *IncomingPaymentRepository) CreateTx(ctx context.Context, tx Tx, payment *models.IncomingPayment) (bool, error) {
+	query := `
+		INSERT INTO incoming_payments (
+			reference, payer_name, payer_account, account_number, account_id, amount,
+			currency, description, status, reason, transaction_id, created_at
+		) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NULLIF($8, ''), $9, NULLIF($10, ''), $11, CURRENT_TIMESTAMP)
+		ON CONFLICT (reference) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		payment.Reference,
+		payment.PayerName,
+		payment.PayerAccount,
+		payment.AccountNumber,
+		payment.AccountID,
+		payment.Amount,
+		payment.Currency,
+		payment.Description,
+		payment.Status,
+		payment.Reason,
+		payment.TransactionID,
+	).Scan(&payment.ID, &payment.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		r.logger.WithError(err).Error("Failed to create incoming payment")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetByReference retrieves a payment by the reference the rail gave it
+func (r *IncomingPaymentRepository) GetByReference(ctx context.Context, reference string) (*models.IncomingPayment, error) {
+	query := `SELECT ` + incomingPaymentColumns + ` FROM incoming_payments WHERE reference = $1`
+
+	payment, err := scanIncomingPayment(r.db.QueryRowContext(ctx, query, reference))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("incoming payment not found")
+		}
+		r.logger.WithError(err).Error("Failed to get incoming payment")
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// List retrieves received payments, newest first, optionally only those with the given status
+func (r *IncomingPaymentRepository) List(ctx context.Context, status models.IncomingPaymentStatus) ([]*models.IncomingPayment, error) {
+	query := `
+		SELECT ` + incomingPaymentColumns + ` FROM incoming_payments
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, string(status))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get incoming payments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*models.IncomingPayment
+	for rows.Next() {
+		payment, err := scanIncomingPayment(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan incoming payment")
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.WithError(err).Error("Error iterating incoming payments")
+		return nil, err
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// JobRepository handles database operations for background jobs
+type JobRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewJobRepository creates a new JobRepository instance
+func NewJobRepository(db *sql.DB, logger *logrus.Logger) *JobRepository {
+	return &JobRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create queues a new job
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	return r.create(ctx, r.db, job)
+}
+
+// CreateTx queues a new job within a database transaction, so that it only
+// becomes visible to the runner once the transaction commits
+func (r *JobRepository) CreateTx(ctx context.Context, tx Tx, job *models.Job) error {
+	return r.create(ctx, tx, job)
+}
+
+func (r *JobRepository) create(ctx context.Context, q queryer, job *models.Job) error {
+	query := `
+		INSERT INTO jobs (type, payload, user_id, status, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := q.QueryRowContext(
+		ctx,
+		query,
+		job.Type,
+		[]byte(job.Payload),
+		job.UserID,
+		job.Status,
+		job.MaxAttempts,
+		job.RunAt,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create job")
+		return err
+	}
+
+	return nil
+}
+
+const jobColumns = `
+	id, type, payload, user_id, status, attempts, max_attempts, COALESCE(last_error, ''),
+	result, run_at, started_at, finished_at, created_at, updated_at
+`
+
+func scanJob(scanner interface{ Scan(...interface{}) error }) (*models.Job, error) {
+	job := &models.Job{}
+	var payload, result []byte
+	var userID sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+
+	err := scanner.Scan(
+		&job.ID,
+		&job.Type,
+		&payload,
+		&userID,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.LastError,
+		&result,
+		&job.RunAt,
+		&startedAt,
+		&finishedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = payload
+	if userID.Valid {
+		job.UserID = &userID.Int64
+	}
+	if result != nil {
+		job.Result = &models.JobResult{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return nil, err
+		}
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, nil
+}
+
+// GetByID retrieves a job by its ID
+func (r *JobRepository) GetByID(ctx context.Context, id int64) (*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("job not found")
+		}
+		r.logger.WithError(err).Error("Failed to get job")
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ClaimNext marks the next due job as running and returns it, or nil when there is
+// nothing to do. Running jobs whose lease has expired are claimed again, which covers
+// a runner that died mid-job. SKIP LOCKED lets several instances poll the same table.
+func (r *JobRepository) ClaimNext(ctx context.Context, lease time.Duration) (*models.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = attempts + 1, started_at = CURRENT_TIMESTAMP, finished_at = NULL
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = $2 AND run_at <= CURRENT_TIMESTAMP)
+			   OR (status = $1 AND started_at < CURRENT_TIMESTAMP - make_interval(secs => $3))
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, models.JobStatusRunning, models.JobStatusQueued, lease.Seconds()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to claim job")
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// SetResult records what a job produced
+func (r *JobRepository) SetResult(ctx context.Context, id int64, result *models.JobResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE jobs SET result = $1 WHERE id = $2`, data, id); err != nil {
+		r.logger.WithError(err).Error("Failed to set job result")
+		return err
+	}
+
+	return nil
+}
+
+// MarkSucceeded records a successful job run
+func (r *JobRepository) MarkSucceeded(ctx context.Context, id int64) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = NULL, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, models.JobStatusSucceeded, id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark job succeeded")
+		return err
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed job run. The job is queued again at retryAt while it
+// has attempts left, otherwise it is marked as failed for good.
+func (r *JobRepository) MarkFailed(ctx context.Context, id int64, jobErr string, retryAt time.Time) error {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN attempts < max_attempts THEN $1 ELSE $2 END,
+			run_at = CASE WHEN attempts < max_attempts THEN $3 ELSE run_at END,
+			finished_at = CASE WHEN attempts < max_attempts THEN NULL ELSE CURRENT_TIMESTAMP END,
+			last_error = $4
+		WHERE id = $5
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, models.JobStatusQueued, models.JobStatusFailed, retryAt, jobErr, id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark job failed")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// KYCRepository handles database operations for the verification of users'
+// identity: the uploaded documents and the KYC status kept on the user
+type KYCRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewKYCRepository creates a new KYCRepository instance
+func NewKYCRepository(db *sql.DB, logger *logrus.Logger) *KYCRepository {
+	return &KYCRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateDocument records an uploaded document
+func (r *KYCRepository) CreateDocument(ctx context.Context, doc *models.KYCDocument) error {
+	query := `
+		INSERT INTO kyc_documents (user_id, type, storage_key, file_name, content_type, size, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, doc.UserID, doc.Type, doc.StorageKey, doc.FileName, doc.ContentType,
+		doc.Size).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create KYC document")
+		return err
+	}
+	return nil
+}
+
+const kycDocumentColumns = `id, user_id, type, storage_key, file_name, content_type, size, created_at`
+
+func scanKYCDocument(scanner interface{ Scan(...interface{}) error }) (*models.KYCDocument, error) {
+	doc := &models.KYCDocument{}
+	err := scanner.Scan(&doc.ID, &doc.UserID, &doc.Type, &doc.StorageKey, &doc.FileName, &doc.ContentType,
+		&doc.Size, &doc.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// GetDocument retrieves a document
+func (r *KYCRepository) GetDocument(ctx context.Context, id int64) (*models.KYCDocument, error) {
+	query := `SELECT ` + kycDocumentColumns + ` FROM kyc_documents WHERE id = $1`
+
+	doc, err := scanKYCDocument(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("document not found")
+		}
+		r.logger.WithError(err).Error("Failed to get KYC document")
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ListDocuments retrieves the documents of a user, newest first
+func (r *KYCRepository) ListDocuments(ctx context.Context, userID int64) ([]*models.KYCDocument, error) {
+	query := `SELECT ` + kycDocumentColumns + ` FROM kyc_documents WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list KYC documents")
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*models.KYCDocument{}
+	for rows.Next() {
+		doc, err := scanKYCDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+const kycVerificationColumns = `
+	id, kyc_status, COALESCE(kyc_comment, ''), kyc_submitted_at, kyc_reviewer_id, kyc_reviewed_at
+`
+
+func scanKYCVerification(scanner interface{ Scan(...interface{}) error }) (*models.KYCVerification, error) {
+	v := &models.KYCVerification{}
+	var submittedAt, reviewedAt sql.NullTime
+	var reviewerID sql.NullInt64
+
+	if err := scanner.Scan(&v.UserID, &v.Status, &v.Comment, &submittedAt, &reviewerID, &reviewedAt); err != nil {
+		return nil, err
+	}
+	if submittedAt.Valid {
+		v.SubmittedAt = &submittedAt.Time
+	}
+	if reviewerID.Valid {
+		v.ReviewerID = &reviewerID.Int64
+	}
+	if reviewedAt.Valid {
+		v.ReviewedAt = &reviewedAt.Time
+	}
+	return v, nil
+}
+
+// GetVerification retrieves the verification of a user, without its documents
+func (r *KYCRepository) GetVerification(ctx context.Context, userID int64) (*models.KYCVerification, error) {
+	query := `SELECT ` + kycVerificationColumns + ` FROM users WHERE id = $1`
+
+	v, err := scanKYCVerification(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		r.logger.WithError(err).Error("Failed to get KYC verification")
+		return nil, err
+	}
+	return v, nil
+}
+
+// ListVerifications retrieves a page of the verifications of the users who
+// submitted documents, and how many there are. The status filter of the page
+// is on the KYC status.
+func (r *KYCRepository) ListVerifications(ctx context.Context, p pagination.Params) ([]*models.KYCVerification, int, error) {
+	// The status column of users is the user's own, so the filter is applied here
+	status := p.Status
+	p.Status = ""
+	rows, total, err := queryPage(ctx, r.db, kycVerificationColumns, "users",
+		"kyc_submitted_at IS NOT NULL AND ($1 = '' OR kyc_status = $1)", p, status)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list KYC verifications")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	verifications := []*models.KYCVerification{}
+	for rows.Next() {
+		v, err := scanKYCVerification(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, total, rows.Err()
+}
+
+// Submit puts the verification of a user up for review, provided the user is
+// unverified or was rejected. It returns false when the status did not allow it.
+func (r *KYCRepository) Submit(ctx context.Context, userID int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET kyc_status = $1, kyc_comment = NULL, kyc_submitted_at = CURRENT_TIMESTAMP,
+			kyc_reviewer_id = NULL, kyc_reviewed_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND kyc_status IN ($3, $4)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, models.KYCStatusPending, userID,
+		models.KYCStatusUnverified, models.KYCStatusRejected)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to submit KYC verification of user %d", userID)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ReviewTx stores the outcome of the review of a pending verification. It returns
+// false when the verification was no longer pending.
+func (r *KYCRepository) ReviewTx(ctx context.Context, tx Tx, v *models.KYCVerification) (bool, error) {
+	query := `
+		UPDATE users
+		SET kyc_status = $1, kyc_comment = NULLIF($2, ''), kyc_reviewer_id = $3, kyc_reviewed_at = $4,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND kyc_status = $6
+	`
+
+	result, err := tx.ExecContext(ctx, query, v.Status, v.Comment, v.ReviewerID, v.ReviewedAt, v.UserID,
+		models.KYCStatusPending)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to review KYC verification of user %d", v.UserID)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// BeginTransaction starts a new database transaction
+func (r *KYCRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// LimitRepository handles database operations for per-user transaction limits
+type LimitRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewLimitRepository creates a new LimitRepository instance
+func NewLimitRepository(db *sql.DB, logger *logrus.Logger) *LimitRepository {
+	return &LimitRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *LimitRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// GetByUserID retrieves the limits set for a user, or nil when none were set
+func (r *LimitRepository) GetByUserID(ctx context.Context, userID int64) (*models.UserLimits, error) {
+	query := `
+		SELECT user_id, daily_transfer, monthly_transfer, daily_withdrawal, monthly_withdrawal,
+			COALESCE(comment, ''), updated_by, created_at, updated_at
+		FROM user_limits
+		WHERE user_id = $1
+	`
+
+	limits := &models.UserLimits{}
+	var dailyTransfer, monthlyTransfer, dailyWithdrawal, monthlyWithdrawal sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&limits.UserID,
+		&dailyTransfer,
+		&monthlyTransfer,
+		&dailyWithdrawal,
+		&monthlyWithdrawal,
+		&limits.Comment,
+		&limits.UpdatedBy,
+		&limits.CreatedAt,
+		&limits.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get user limits")
+		return nil, err
+	}
+
+	limits.DailyTransfer = nullFloat(dailyTransfer)
+	limits.MonthlyTransfer = nullFloat(monthlyTransfer)
+	limits.DailyWithdrawal = nullFloat(dailyWithdrawal)
+	limits.MonthlyWithdrawal = nullFloat(monthlyWithdrawal)
+
+	return limits, nil
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// UpsertTx stores the limits of a user within a database transaction
+func (r *LimitRepository) UpsertTx(ctx context.Context, tx Tx, limits *models.UserLimits) error {
+	query := `
+		INSERT INTO user_limits (
+			user_id, daily_transfer, monthly_transfer, daily_withdrawal, monthly_withdrawal,
+			comment, updated_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			daily_transfer = EXCLUDED.daily_transfer,
+			monthly_transfer = EXCLUDED.monthly_transfer,
+			daily_withdrawal = EXCLUDED.daily_withdrawal,
+			monthly_withdrawal = EXCLUDED.monthly_withdrawal,
+			comment = EXCLUDED.comment,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		limits.UserID,
+		limits.DailyTransfer,
+		limits.MonthlyTransfer,
+		limits.DailyWithdrawal,
+		limits.MonthlyWithdrawal,
+		limits.Comment,
+		limits.UpdatedBy,
+	).Scan(&limits.CreatedAt, &limits.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to store user limits")
+		return err
+	}
+
+	return nil
+}
+
+// LockUserTx locks the user row until the transaction ends, so that outgoing
+// transactions of one user are checked against the limits one at a time
+func (r *LimitRepository) LockUserTx(ctx context.Context, tx Tx, userID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("user not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock user")
+		return err
+	}
+
+	return nil
+}
+
+// GetOutgoingTotals sums the transactions of a type made by a user to other users
+// since each of the two given times
+func (r *LimitRepository) GetOutgoingTotals(ctx context.Context, userID int64, txType string, dailySince, monthlySince time.Time) (daily, monthly float64, err error) {
+	return r.getOutgoingTotals(ctx, r.db, userID, txType, dailySince, monthlySince)
+}
+
+// GetOutgoingTotalsTx sums the outgoing transactions of a user within a database transaction
+func (r *LimitRepository) GetOutgoingTotalsTx(ctx context.Context, tx Tx, userID int64, txType string, dailySince, monthlySince time.Time) (daily, monthly float64, err error) {
+	return r.getOutgoingTotals(ctx, tx, userID, txType, dailySince, monthlySince)
+}
+
+func (r *LimitRepository) getOutgoingTotals(ctx context.Context, q queryer, userID int64, txType string, dailySince, monthlySince time.Time) (daily, monthly float64, err error) {
+	// Transfers between the user's own accounts do not count, transfers to other
+	// banks count as transfers from the moment they are accepted, before their
+	// debit is booked at settlement, and bill payments count as transfers unless
+	// they were refunded
+	query := `
+		WITH outgoing AS (
+			SELECT t.amount, t.created_at
+			FROM transactions t
+			JOIN accounts a ON a.id = t.from_account_id
+			LEFT JOIN accounts d ON d.id = t.to_account_id
+			WHERE a.user_id = $1 AND t.type = $2
+				AND t.created_at >= LEAST($3, $4)
+				AND (d.user_id IS NULL OR d.user_id <> $1)
+			UNION ALL
+			SELECT e.amount, e.created_at
+			FROM external_transfers e
+			JOIN accounts a ON a.id = e.account_id
+			WHERE $2 = $5 AND a.user_id = $1 AND e.created_at >= LEAST($3, $4)
+			UNION ALL
+			SELECT b.amount, b.created_at
+			FROM bill_payments b
+			JOIN accounts a ON a.id = b.account_id
+			WHERE $2 = $5 AND a.user_id = $1 AND b.status <> $6 AND b.created_at >= LEAST($3, $4)
+		)
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0),
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= $4), 0)
+		FROM outgoing
+	`
+
+	if err := q.QueryRowContext(ctx, query, userID, txType, dailySince, monthlySince, models.TransactionTypeTransfer,
+		models.BillPaymentStatusRefunded,
+	).Scan(&daily, &monthly); err != nil {
+		r.logger.WithError(err).Error("Failed to get outgoing totals")
+		return 0, 0, err
+	}
+
+	return daily, monthly, nil
+}
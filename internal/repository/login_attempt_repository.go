@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// LoginAttemptRepository handles database operations for login attempts
+type LoginAttemptRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewLoginAttemptRepository creates a new LoginAttemptRepository instance
+func NewLoginAttemptRepository(db *sql.DB, logger *logrus.Logger) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create records a login attempt
+func (r *LoginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (user_id, email, ip_address, success)
+		VALUES (NULLIF($1, 0), $2, NULLIF($3, ''), $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, attempt.UserID, attempt.Email, attempt.IPAddress, attempt.Success).
+		Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to record login attempt")
+		return err
+	}
+	return nil
+}
+
+// CountFailedByIP counts the failed logins from an address since the given time
+func (r *LoginAttemptRepository) CountFailedByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM login_attempts WHERE ip_address = $1 AND NOT success AND created_at > $2`
+	if err := r.db.QueryRowContext(ctx, query, ip, since).Scan(&count); err != nil {
+		r.logger.WithError(err).Error("Failed to count failed logins")
+		return 0, err
+	}
+	return count, nil
+}
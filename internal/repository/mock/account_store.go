@@ -0,0 +1,287 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// AccountStore is a repository.AccountStore whose methods call the function field of
+// the same name. Methods without a function return ErrNotConfigured.
+type AccountStore struct {
+	BeginTransactionFunc           func(context.Context) (repository.Tx, error)
+	CreateFunc                     func(context.Context, *models.Account) error
+	GetByIDFunc                    func(context.Context, int64) (*models.Account, error)
+	GetByIDForUpdateFunc           func(context.Context, repository.Tx, int64) (*models.Account, error)
+	GetByUserIDFunc                func(context.Context, int64) ([]*models.Account, error)
+	ListByUserIDFunc               func(context.Context, int64, pagination.Params) ([]*models.Account, int, error)
+	GetByNumberFunc                func(context.Context, string) (*models.Account, error)
+	GetWithoutNumberFunc           func(context.Context) ([]*models.Account, error)
+	SetNumberFunc                  func(context.Context, int64, string) error
+	GetPrimaryFunc                 func(context.Context, int64, string) (*models.Account, error)
+	UpdateNameFunc                 func(context.Context, int64, string) error
+	SetPrimaryFunc                 func(context.Context, *models.Account, bool) error
+	CloseTxFunc                    func(context.Context, repository.Tx, *models.Account, time.Time) error
+	ListInactiveFunc               func(context.Context, time.Time) ([]int64, error)
+	MarkDormantTxFunc              func(context.Context, repository.Tx, int64, time.Time, time.Time) (bool, error)
+	ListDormancyFeeDueFunc         func(context.Context, time.Time) ([]int64, error)
+	SetDormancyFeeChargedTxFunc    func(context.Context, repository.Tx, int64, time.Time) error
+	ReactivateFunc                 func(context.Context, int64, time.Time) (bool, error)
+	GetMemberRoleFunc              func(context.Context, int64, int64) (models.AccountRole, error)
+	PostTransactionFunc            func(context.Context, *models.Transaction) error
+	PostTransactionTxFunc          func(context.Context, repository.Tx, *models.Transaction) error
+	GetTransactionByIDFunc         func(context.Context, int64) (*models.Transaction, error)
+	GetTransactionsFunc            func(context.Context, int64, time.Time, time.Time) ([]*models.Transaction, error)
+	ListTransactionsByAccountsFunc func(context.Context, []int64, models.TransactionFilter) (map[int64][]*models.Transaction, error)
+	SetOverdraftFunc               func(context.Context, int64, float64, float64) error
+	GetWithOverdraftFacilityFunc   func(context.Context) ([]*models.Account, error)
+	ChargeOverdraftInterestFunc    func(context.Context, int64, float64, time.Time) error
+	GetTransactionTotalByTypeFunc  func(context.Context, int64, string, time.Time, time.Time) (float64, error)
+	GetCardPaymentTotalFunc        func(context.Context, int64, time.Time) (float64, error)
+	GetCardPaymentTotalTxFunc      func(context.Context, repository.Tx, int64, time.Time) (float64, error)
+}
+
+var _ repository.AccountStore = (*AccountStore)(nil)
+
+// BeginTransaction calls BeginTransactionFunc
+func (m *AccountStore) BeginTransaction(ctx context.Context) (repository.Tx, error) {
+	if m.BeginTransactionFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.BeginTransactionFunc(ctx)
+}
+
+// Create calls CreateFunc
+func (m *AccountStore) Create(ctx context.Context, account *models.Account) error {
+	if m.CreateFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateFunc(ctx, account)
+}
+
+// GetByID calls GetByIDFunc
+func (m *AccountStore) GetByID(ctx context.Context, id int64) (*models.Account, error) {
+	if m.GetByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDFunc(ctx, id)
+}
+
+// GetByIDForUpdate calls GetByIDForUpdateFunc
+func (m *AccountStore) GetByIDForUpdate(ctx context.Context, tx repository.Tx, id int64) (*models.Account, error) {
+	if m.GetByIDForUpdateFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDForUpdateFunc(ctx, tx, id)
+}
+
+// GetByUserID calls GetByUserIDFunc
+func (m *AccountStore) GetByUserID(ctx context.Context, userID int64) ([]*models.Account, error) {
+	if m.GetByUserIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByUserIDFunc(ctx, userID)
+}
+
+// ListByUserID calls ListByUserIDFunc
+func (m *AccountStore) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Account, int, error) {
+	if m.ListByUserIDFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListByUserIDFunc(ctx, userID, p)
+}
+
+// GetByNumber calls GetByNumberFunc
+func (m *AccountStore) GetByNumber(ctx context.Context, number string) (*models.Account, error) {
+	if m.GetByNumberFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByNumberFunc(ctx, number)
+}
+
+// GetWithoutNumber calls GetWithoutNumberFunc
+func (m *AccountStore) GetWithoutNumber(ctx context.Context) ([]*models.Account, error) {
+	if m.GetWithoutNumberFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetWithoutNumberFunc(ctx)
+}
+
+// SetNumber calls SetNumberFunc
+func (m *AccountStore) SetNumber(ctx context.Context, id int64, number string) error {
+	if m.SetNumberFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.SetNumberFunc(ctx, id, number)
+}
+
+// GetPrimary calls GetPrimaryFunc
+func (m *AccountStore) GetPrimary(ctx context.Context, userID int64, currency string) (*models.Account, error) {
+	if m.GetPrimaryFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPrimaryFunc(ctx, userID, currency)
+}
+
+// UpdateName calls UpdateNameFunc
+func (m *AccountStore) UpdateName(ctx context.Context, id int64, name string) error {
+	if m.UpdateNameFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateNameFunc(ctx, id, name)
+}
+
+// SetPrimary calls SetPrimaryFunc
+func (m *AccountStore) SetPrimary(ctx context.Context, account *models.Account, primary bool) error {
+	if m.SetPrimaryFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.SetPrimaryFunc(ctx, account, primary)
+}
+
+// CloseTx calls CloseTxFunc
+func (m *AccountStore) CloseTx(ctx context.Context, tx repository.Tx, account *models.Account, closedAt time.Time) error {
+	if m.CloseTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CloseTxFunc(ctx, tx, account, closedAt)
+}
+
+// ListInactive calls ListInactiveFunc
+func (m *AccountStore) ListInactive(ctx context.Context, cutoff time.Time) ([]int64, error) {
+	if m.ListInactiveFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ListInactiveFunc(ctx, cutoff)
+}
+
+// MarkDormantTx calls MarkDormantTxFunc
+func (m *AccountStore) MarkDormantTx(ctx context.Context, tx repository.Tx, id int64, cutoff, dormantSince time.Time) (bool, error) {
+	if m.MarkDormantTxFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.MarkDormantTxFunc(ctx, tx, id, cutoff, dormantSince)
+}
+
+// ListDormancyFeeDue calls ListDormancyFeeDueFunc
+func (m *AccountStore) ListDormancyFeeDue(ctx context.Context, before time.Time) ([]int64, error) {
+	if m.ListDormancyFeeDueFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ListDormancyFeeDueFunc(ctx, before)
+}
+
+// SetDormancyFeeChargedTx calls SetDormancyFeeChargedTxFunc
+func (m *AccountStore) SetDormancyFeeChargedTx(ctx context.Context, tx repository.Tx, id int64, chargedAt time.Time) error {
+	if m.SetDormancyFeeChargedTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.SetDormancyFeeChargedTxFunc(ctx, tx, id, chargedAt)
+}
+
+// Reactivate calls ReactivateFunc
+func (m *AccountStore) Reactivate(ctx context.Context, id int64, reactivatedAt time.Time) (bool, error) {
+	if m.ReactivateFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.ReactivateFunc(ctx, id, reactivatedAt)
+}
+
+// GetMemberRole calls GetMemberRoleFunc
+func (m *AccountStore) GetMemberRole(ctx context.Context, accountID, userID int64) (models.AccountRole, error) {
+	if m.GetMemberRoleFunc == nil {
+		return "", ErrNotConfigured
+	}
+	return m.GetMemberRoleFunc(ctx, accountID, userID)
+}
+
+// PostTransaction calls PostTransactionFunc
+func (m *AccountStore) PostTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if m.PostTransactionFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.PostTransactionFunc(ctx, transaction)
+}
+
+// PostTransactionTx calls PostTransactionTxFunc
+func (m *AccountStore) PostTransactionTx(ctx context.Context, tx repository.Tx, transaction *models.Transaction) error {
+	if m.PostTransactionTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.PostTransactionTxFunc(ctx, tx, transaction)
+}
+
+// GetTransactionByID calls GetTransactionByIDFunc
+func (m *AccountStore) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error) {
+	if m.GetTransactionByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetTransactionByIDFunc(ctx, id)
+}
+
+// GetTransactions calls GetTransactionsFunc
+func (m *AccountStore) GetTransactions(ctx context.Context, accountID int64, startDate, endDate time.Time) ([]*models.Transaction, error) {
+	if m.GetTransactionsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetTransactionsFunc(ctx, accountID, startDate, endDate)
+}
+
+// ListTransactionsByAccounts calls ListTransactionsByAccountsFunc
+func (m *AccountStore) ListTransactionsByAccounts(ctx context.Context, accountIDs []int64, filter models.TransactionFilter) (map[int64][]*models.Transaction, error) {
+	if m.ListTransactionsByAccountsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ListTransactionsByAccountsFunc(ctx, accountIDs, filter)
+}
+
+// SetOverdraft calls SetOverdraftFunc
+func (m *AccountStore) SetOverdraft(ctx context.Context, id int64, limit, interestRate float64) error {
+	if m.SetOverdraftFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.SetOverdraftFunc(ctx, id, limit, interestRate)
+}
+
+// GetWithOverdraftFacility calls GetWithOverdraftFacilityFunc
+func (m *AccountStore) GetWithOverdraftFacility(ctx context.Context) ([]*models.Account, error) {
+	if m.GetWithOverdraftFacilityFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetWithOverdraftFacilityFunc(ctx)
+}
+
+// ChargeOverdraftInterest calls ChargeOverdraftInterestFunc
+func (m *AccountStore) ChargeOverdraftInterest(ctx context.Context, accountID int64, interest float64, accruedAt time.Time) error {
+	if m.ChargeOverdraftInterestFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.ChargeOverdraftInterestFunc(ctx, accountID, interest, accruedAt)
+}
+
+// GetTransactionTotalByType calls GetTransactionTotalByTypeFunc
+func (m *AccountStore) GetTransactionTotalByType(ctx context.Context, accountID int64, txType string, startDate, endDate time.Time) (float64, error) {
+	if m.GetTransactionTotalByTypeFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.GetTransactionTotalByTypeFunc(ctx, accountID, txType, startDate, endDate)
+}
+
+// GetCardPaymentTotal calls GetCardPaymentTotalFunc
+func (m *AccountStore) GetCardPaymentTotal(ctx context.Context, cardID int64, since time.Time) (float64, error) {
+	if m.GetCardPaymentTotalFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.GetCardPaymentTotalFunc(ctx, cardID, since)
+}
+
+// GetCardPaymentTotalTx calls GetCardPaymentTotalTxFunc
+func (m *AccountStore) GetCardPaymentTotalTx(ctx context.Context, tx repository.Tx, cardID int64, since time.Time) (float64, error) {
+	if m.GetCardPaymentTotalTxFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.GetCardPaymentTotalTxFunc(ctx, tx, cardID, since)
+}
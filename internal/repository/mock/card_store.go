@@ -0,0 +1,152 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// CardStore is a repository.CardStore whose methods call the function field of
+// the same name. Methods without a function return ErrNotConfigured.
+type CardStore struct {
+	CreateFunc             func(context.Context, *models.Card) error
+	CreateTxFunc           func(context.Context, repository.Tx, *models.Card) error
+	GetByIDFunc            func(context.Context, int64) (*models.Card, error)
+	GetByNumberFunc        func(context.Context, string) (*models.Card, error)
+	GetByUserIDFunc        func(context.Context, int64) ([]*models.Card, error)
+	GetByAccountIDFunc     func(context.Context, int64) ([]*models.Card, error)
+	GetByAccountIDTxFunc   func(context.Context, repository.Tx, int64) ([]*models.Card, error)
+	ListByUserIDFunc       func(context.Context, int64, pagination.Params) ([]*models.Card, int, error)
+	GetExpiringFunc        func(context.Context, time.Time) ([]*models.Card, error)
+	GetExpiredFunc         func(context.Context, time.Time) ([]*models.Card, error)
+	MarkExpiryNotifiedFunc func(context.Context, int64) error
+	UpdateStatusFunc       func(context.Context, int64, string) error
+	UseSingleUseTxFunc     func(context.Context, repository.Tx, int64) error
+	BlockByAccountTxFunc   func(context.Context, repository.Tx, int64, int64) ([]int64, error)
+	DeleteFunc             func(context.Context, int64) error
+}
+
+var _ repository.CardStore = (*CardStore)(nil)
+
+// Create calls CreateFunc
+func (m *CardStore) Create(ctx context.Context, card *models.Card) error {
+	if m.CreateFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateFunc(ctx, card)
+}
+
+// CreateTx calls CreateTxFunc
+func (m *CardStore) CreateTx(ctx context.Context, tx repository.Tx, card *models.Card) error {
+	if m.CreateTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateTxFunc(ctx, tx, card)
+}
+
+// GetByID calls GetByIDFunc
+func (m *CardStore) GetByID(ctx context.Context, id int64) (*models.Card, error) {
+	if m.GetByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDFunc(ctx, id)
+}
+
+// GetByNumber calls GetByNumberFunc
+func (m *CardStore) GetByNumber(ctx context.Context, number string) (*models.Card, error) {
+	if m.GetByNumberFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByNumberFunc(ctx, number)
+}
+
+// GetByUserID calls GetByUserIDFunc
+func (m *CardStore) GetByUserID(ctx context.Context, userID int64) ([]*models.Card, error) {
+	if m.GetByUserIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByUserIDFunc(ctx, userID)
+}
+
+// GetByAccountID calls GetByAccountIDFunc
+func (m *CardStore) GetByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error) {
+	if m.GetByAccountIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByAccountIDFunc(ctx, accountID)
+}
+
+// GetByAccountIDTx calls GetByAccountIDTxFunc
+func (m *CardStore) GetByAccountIDTx(ctx context.Context, tx repository.Tx, accountID int64) ([]*models.Card, error) {
+	if m.GetByAccountIDTxFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByAccountIDTxFunc(ctx, tx, accountID)
+}
+
+// ListByUserID calls ListByUserIDFunc
+func (m *CardStore) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Card, int, error) {
+	if m.ListByUserIDFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListByUserIDFunc(ctx, userID, p)
+}
+
+// GetExpiring calls GetExpiringFunc
+func (m *CardStore) GetExpiring(ctx context.Context, before time.Time) ([]*models.Card, error) {
+	if m.GetExpiringFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetExpiringFunc(ctx, before)
+}
+
+// GetExpired calls GetExpiredFunc
+func (m *CardStore) GetExpired(ctx context.Context, now time.Time) ([]*models.Card, error) {
+	if m.GetExpiredFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetExpiredFunc(ctx, now)
+}
+
+// MarkExpiryNotified calls MarkExpiryNotifiedFunc
+func (m *CardStore) MarkExpiryNotified(ctx context.Context, id int64) error {
+	if m.MarkExpiryNotifiedFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.MarkExpiryNotifiedFunc(ctx, id)
+}
+
+// UpdateStatus calls UpdateStatusFunc
+func (m *CardStore) UpdateStatus(ctx context.Context, id int64, status string) error {
+	if m.UpdateStatusFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateStatusFunc(ctx, id, status)
+}
+
+// UseSingleUseTx calls UseSingleUseTxFunc
+func (m *CardStore) UseSingleUseTx(ctx context.Context, tx repository.Tx, id int64) error {
+	if m.UseSingleUseTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UseSingleUseTxFunc(ctx, tx, id)
+}
+
+// BlockByAccountTx calls BlockByAccountTxFunc
+func (m *CardStore) BlockByAccountTx(ctx context.Context, tx repository.Tx, accountID, keepUserID int64) ([]int64, error) {
+	if m.BlockByAccountTxFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.BlockByAccountTxFunc(ctx, tx, accountID, keepUserID)
+}
+
+// Delete calls DeleteFunc
+func (m *CardStore) Delete(ctx context.Context, id int64) error {
+	if m.DeleteFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.DeleteFunc(ctx, id)
+}
@@ -0,0 +1,296 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// CreditStore is a repository.CreditStore whose methods call the function field of
+// the same name. Methods without a function return ErrNotConfigured.
+type CreditStore struct {
+	CreateFunc                      func(context.Context, *models.Credit) error
+	CreateTxFunc                    func(context.Context, repository.Tx, *models.Credit) error
+	GetByIDFunc                     func(context.Context, int64) (*models.Credit, error)
+	GetByIDForUpdateFunc            func(context.Context, repository.Tx, int64) (*models.Credit, error)
+	GetByUserIDFunc                 func(context.Context, int64) ([]*models.Credit, error)
+	GetByAccountIDTxFunc            func(context.Context, repository.Tx, int64) ([]*models.Credit, error)
+	ListByUserIDFunc                func(context.Context, int64, pagination.Params) ([]*models.Credit, int, error)
+	GetPaymentScheduleFunc          func(context.Context, int64) ([]*models.PaymentSchedule, error)
+	GetPaymentSchedulesFunc         func(context.Context, []int64) (map[int64][]*models.PaymentSchedule, error)
+	ListPaymentScheduleFunc         func(context.Context, int64, pagination.Params) ([]*models.PaymentSchedule, int, error)
+	GetPaymentScheduleTxFunc        func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error)
+	GetPaymentScheduleForUpdateFunc func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error)
+	GetOverduePaymentsFunc          func(context.Context) ([]*models.PaymentSchedule, error)
+	MarkOverduePaymentsTxFunc       func(context.Context, repository.Tx, time.Time) ([]*models.PaymentSchedule, error)
+	GetPaymentForUpdateFunc         func(context.Context, repository.Tx, int64) (*models.PaymentSchedule, error)
+	AccruePenaltyTxFunc             func(context.Context, repository.Tx, *models.PaymentSchedule, []*models.CreditPenaltyAccrual) error
+	UpdateRemainingAmountFunc       func(context.Context, int64, float64) error
+	BeginTransactionFunc            func(context.Context) (repository.Tx, error)
+	UpdatePaymentScheduleFunc       func(context.Context, *models.PaymentSchedule) error
+	UpdateFunc                      func(context.Context, *models.Credit) error
+	UpdateTxFunc                    func(context.Context, repository.Tx, *models.Credit) error
+	CreatePaymentScheduleFunc       func(context.Context, *models.PaymentSchedule) error
+	CreatePaymentScheduleTxFunc     func(context.Context, repository.Tx, *models.PaymentSchedule) error
+	GetCreditsWithDuePaymentsFunc   func(context.Context) ([]*models.Credit, error)
+	GetNextPaymentFunc              func(context.Context, int64) (*models.PaymentSchedule, error)
+	UpdatePaymentStatusFunc         func(context.Context, int64, string) error
+	UpdatePaymentAmountTxFunc       func(context.Context, repository.Tx, *models.PaymentSchedule) error
+	UpdatePaymentPaidTxFunc         func(context.Context, repository.Tx, *models.PaymentSchedule) error
+	ArchivePaymentTxFunc            func(context.Context, repository.Tx, int64, int64) error
+	UpdateTermsTxFunc               func(context.Context, repository.Tx, *models.Credit) error
+	DeletePaymentTxFunc             func(context.Context, repository.Tx, int64) error
+}
+
+var _ repository.CreditStore = (*CreditStore)(nil)
+
+// Create calls CreateFunc
+func (m *CreditStore) Create(ctx context.Context, credit *models.Credit) error {
+	if m.CreateFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateFunc(ctx, credit)
+}
+
+// CreateTx calls CreateTxFunc
+func (m *CreditStore) CreateTx(ctx context.Context, tx repository.Tx, credit *models.Credit) error {
+	if m.CreateTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateTxFunc(ctx, tx, credit)
+}
+
+// GetByID calls GetByIDFunc
+func (m *CreditStore) GetByID(ctx context.Context, id int64) (*models.Credit, error) {
+	if m.GetByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDFunc(ctx, id)
+}
+
+// GetByIDForUpdate calls GetByIDForUpdateFunc
+func (m *CreditStore) GetByIDForUpdate(ctx context.Context, tx repository.Tx, id int64) (*models.Credit, error) {
+	if m.GetByIDForUpdateFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDForUpdateFunc(ctx, tx, id)
+}
+
+// GetByUserID calls GetByUserIDFunc
+func (m *CreditStore) GetByUserID(ctx context.Context, userID int64) ([]*models.Credit, error) {
+	if m.GetByUserIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByUserIDFunc(ctx, userID)
+}
+
+// GetByAccountIDTx calls GetByAccountIDTxFunc
+func (m *CreditStore) GetByAccountIDTx(ctx context.Context, tx repository.Tx, accountID int64) ([]*models.Credit, error) {
+	if m.GetByAccountIDTxFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByAccountIDTxFunc(ctx, tx, accountID)
+}
+
+// ListByUserID calls ListByUserIDFunc
+func (m *CreditStore) ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Credit, int, error) {
+	if m.ListByUserIDFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListByUserIDFunc(ctx, userID, p)
+}
+
+// GetPaymentSchedule calls GetPaymentScheduleFunc
+func (m *CreditStore) GetPaymentSchedule(ctx context.Context, creditID int64) ([]*models.PaymentSchedule, error) {
+	if m.GetPaymentScheduleFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPaymentScheduleFunc(ctx, creditID)
+}
+
+// GetPaymentSchedules calls GetPaymentSchedulesFunc
+func (m *CreditStore) GetPaymentSchedules(ctx context.Context, creditIDs []int64) (map[int64][]*models.PaymentSchedule, error) {
+	if m.GetPaymentSchedulesFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPaymentSchedulesFunc(ctx, creditIDs)
+}
+
+// ListPaymentSchedule calls ListPaymentScheduleFunc
+func (m *CreditStore) ListPaymentSchedule(ctx context.Context, creditID int64, p pagination.Params) ([]*models.PaymentSchedule, int, error) {
+	if m.ListPaymentScheduleFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListPaymentScheduleFunc(ctx, creditID, p)
+}
+
+// GetPaymentScheduleTx calls GetPaymentScheduleTxFunc
+func (m *CreditStore) GetPaymentScheduleTx(ctx context.Context, tx repository.Tx, creditID int64) ([]*models.PaymentSchedule, error) {
+	if m.GetPaymentScheduleTxFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPaymentScheduleTxFunc(ctx, tx, creditID)
+}
+
+// GetPaymentScheduleForUpdate calls GetPaymentScheduleForUpdateFunc
+func (m *CreditStore) GetPaymentScheduleForUpdate(ctx context.Context, tx repository.Tx, creditID int64) ([]*models.PaymentSchedule, error) {
+	if m.GetPaymentScheduleForUpdateFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPaymentScheduleForUpdateFunc(ctx, tx, creditID)
+}
+
+// GetOverduePayments calls GetOverduePaymentsFunc
+func (m *CreditStore) GetOverduePayments(ctx context.Context) ([]*models.PaymentSchedule, error) {
+	if m.GetOverduePaymentsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetOverduePaymentsFunc(ctx)
+}
+
+// MarkOverduePaymentsTx calls MarkOverduePaymentsTxFunc
+func (m *CreditStore) MarkOverduePaymentsTx(ctx context.Context, tx repository.Tx, before time.Time) ([]*models.PaymentSchedule, error) {
+	if m.MarkOverduePaymentsTxFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.MarkOverduePaymentsTxFunc(ctx, tx, before)
+}
+
+// GetPaymentForUpdate calls GetPaymentForUpdateFunc
+func (m *CreditStore) GetPaymentForUpdate(ctx context.Context, tx repository.Tx, paymentID int64) (*models.PaymentSchedule, error) {
+	if m.GetPaymentForUpdateFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPaymentForUpdateFunc(ctx, tx, paymentID)
+}
+
+// AccruePenaltyTx calls AccruePenaltyTxFunc
+func (m *CreditStore) AccruePenaltyTx(ctx context.Context, tx repository.Tx, payment *models.PaymentSchedule, accruals []*models.CreditPenaltyAccrual) error {
+	if m.AccruePenaltyTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.AccruePenaltyTxFunc(ctx, tx, payment, accruals)
+}
+
+// UpdateRemainingAmount calls UpdateRemainingAmountFunc
+func (m *CreditStore) UpdateRemainingAmount(ctx context.Context, creditID int64, amount float64) error {
+	if m.UpdateRemainingAmountFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateRemainingAmountFunc(ctx, creditID, amount)
+}
+
+// BeginTransaction calls BeginTransactionFunc
+func (m *CreditStore) BeginTransaction(ctx context.Context) (repository.Tx, error) {
+	if m.BeginTransactionFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.BeginTransactionFunc(ctx)
+}
+
+// UpdatePaymentSchedule calls UpdatePaymentScheduleFunc
+func (m *CreditStore) UpdatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error {
+	if m.UpdatePaymentScheduleFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePaymentScheduleFunc(ctx, payment)
+}
+
+// Update calls UpdateFunc
+func (m *CreditStore) Update(ctx context.Context, credit *models.Credit) error {
+	if m.UpdateFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateFunc(ctx, credit)
+}
+
+// UpdateTx calls UpdateTxFunc
+func (m *CreditStore) UpdateTx(ctx context.Context, tx repository.Tx, credit *models.Credit) error {
+	if m.UpdateTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateTxFunc(ctx, tx, credit)
+}
+
+// CreatePaymentSchedule calls CreatePaymentScheduleFunc
+func (m *CreditStore) CreatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error {
+	if m.CreatePaymentScheduleFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreatePaymentScheduleFunc(ctx, payment)
+}
+
+// CreatePaymentScheduleTx calls CreatePaymentScheduleTxFunc
+func (m *CreditStore) CreatePaymentScheduleTx(ctx context.Context, tx repository.Tx, payment *models.PaymentSchedule) error {
+	if m.CreatePaymentScheduleTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreatePaymentScheduleTxFunc(ctx, tx, payment)
+}
+
+// GetCreditsWithDuePayments calls GetCreditsWithDuePaymentsFunc
+func (m *CreditStore) GetCreditsWithDuePayments(ctx context.Context) ([]*models.Credit, error) {
+	if m.GetCreditsWithDuePaymentsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetCreditsWithDuePaymentsFunc(ctx)
+}
+
+// GetNextPayment calls GetNextPaymentFunc
+func (m *CreditStore) GetNextPayment(ctx context.Context, creditID int64) (*models.PaymentSchedule, error) {
+	if m.GetNextPaymentFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetNextPaymentFunc(ctx, creditID)
+}
+
+// UpdatePaymentStatus calls UpdatePaymentStatusFunc
+func (m *CreditStore) UpdatePaymentStatus(ctx context.Context, paymentID int64, status string) error {
+	if m.UpdatePaymentStatusFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePaymentStatusFunc(ctx, paymentID, status)
+}
+
+// UpdatePaymentAmountTx calls UpdatePaymentAmountTxFunc
+func (m *CreditStore) UpdatePaymentAmountTx(ctx context.Context, tx repository.Tx, payment *models.PaymentSchedule) error {
+	if m.UpdatePaymentAmountTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePaymentAmountTxFunc(ctx, tx, payment)
+}
+
+// UpdatePaymentPaidTx calls UpdatePaymentPaidTxFunc
+func (m *CreditStore) UpdatePaymentPaidTx(ctx context.Context, tx repository.Tx, payment *models.PaymentSchedule) error {
+	if m.UpdatePaymentPaidTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePaymentPaidTxFunc(ctx, tx, payment)
+}
+
+// ArchivePaymentTx calls ArchivePaymentTxFunc
+func (m *CreditStore) ArchivePaymentTx(ctx context.Context, tx repository.Tx, paymentID, restructuringID int64) error {
+	if m.ArchivePaymentTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.ArchivePaymentTxFunc(ctx, tx, paymentID, restructuringID)
+}
+
+// UpdateTermsTx calls UpdateTermsTxFunc
+func (m *CreditStore) UpdateTermsTx(ctx context.Context, tx repository.Tx, credit *models.Credit) error {
+	if m.UpdateTermsTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateTermsTxFunc(ctx, tx, credit)
+}
+
+// DeletePaymentTx calls DeletePaymentTxFunc
+func (m *CreditStore) DeletePaymentTx(ctx context.Context, tx repository.Tx, paymentID int64) error {
+	if m.DeletePaymentTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.DeletePaymentTxFunc(ctx, tx, paymentID)
+}
@@ -0,0 +1,8 @@
+// Package mock provides configurable implementations of the repository stores
+// for exercising services without a database.
+package mock
+
+import "errors"
+
+// ErrNotConfigured is returned by mock methods whose function field is not set
+var ErrNotConfigured = errors.New("mock: method not configured")
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// Tx is a repository.Tx that records how it ended. Statements are passed to DB,
+// which lets services that also write through concrete repositories run against a
+// test driver; without DB they return ErrNotConfigured.
+type Tx struct {
+	DB         *sql.DB
+	Committed  bool
+	RolledBack bool
+}
+
+var _ repository.Tx = (*Tx)(nil)
+
+// ExecContext runs the statement on DB
+func (m *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if m.DB == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.DB.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs the query on DB
+func (m *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if m.DB == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs the query on DB. It panics without DB, as a *sql.Row
+// carrying an error cannot be built outside database/sql.
+func (m *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return m.DB.QueryRowContext(ctx, query, args...)
+}
+
+// Commit marks the transaction committed
+func (m *Tx) Commit() error {
+	if m.Committed || m.RolledBack {
+		return sql.ErrTxDone
+	}
+	m.Committed = true
+	return nil
+}
+
+// Rollback marks the transaction rolled back unless it was committed
+func (m *Tx) Rollback() error {
+	if m.Committed || m.RolledBack {
+		return sql.ErrTxDone
+	}
+	m.RolledBack = true
+	return nil
+}
@@ -0,0 +1,179 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// UserStore is a repository.UserStore whose methods call the function field of
+// the same name. Methods without a function return ErrNotConfigured.
+type UserStore struct {
+	BeginTransactionFunc    func(context.Context) (repository.Tx, error)
+	CreateFunc              func(context.Context, *models.User) error
+	CreateTxFunc            func(context.Context, repository.Tx, *models.User) error
+	GetByIDFunc             func(context.Context, int64) (*models.User, error)
+	GetByEmailFunc          func(context.Context, string) (*models.User, error)
+	GetByPhoneFunc          func(context.Context, string) (*models.User, error)
+	CheckEmailExistsFunc    func(context.Context, string) (bool, error)
+	CheckUsernameExistsFunc func(context.Context, string) (bool, error)
+	UpdateProfileFunc       func(context.Context, *models.User) error
+	UpdatePasswordFunc      func(context.Context, int64, string) error
+	CloseFunc               func(context.Context, int64, time.Time) error
+	AnonymizeClosedFunc     func(context.Context, time.Time) (int64, error)
+	RecordFailedLoginTxFunc func(context.Context, repository.Tx, int64, time.Time) (int, error)
+	LockTxFunc              func(context.Context, repository.Tx, int64, time.Time) error
+	ResetFailedLoginsFunc   func(context.Context, int64) error
+	UnlockFunc              func(context.Context, int64) error
+	ListByBranchFunc        func(context.Context, int64, pagination.Params) ([]*models.User, int, error)
+	AssignBranchFunc        func(context.Context, int64, int64, models.UserRole) error
+}
+
+var _ repository.UserStore = (*UserStore)(nil)
+
+// BeginTransaction calls BeginTransactionFunc
+func (m *UserStore) BeginTransaction(ctx context.Context) (repository.Tx, error) {
+	if m.BeginTransactionFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.BeginTransactionFunc(ctx)
+}
+
+// Create calls CreateFunc
+func (m *UserStore) Create(ctx context.Context, user *models.User) error {
+	if m.CreateFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateFunc(ctx, user)
+}
+
+// CreateTx calls CreateTxFunc
+func (m *UserStore) CreateTx(ctx context.Context, tx repository.Tx, user *models.User) error {
+	if m.CreateTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateTxFunc(ctx, tx, user)
+}
+
+// GetByID calls GetByIDFunc
+func (m *UserStore) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	if m.GetByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByIDFunc(ctx, id)
+}
+
+// GetByEmail calls GetByEmailFunc
+func (m *UserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if m.GetByEmailFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByEmailFunc(ctx, email)
+}
+
+// GetByPhone calls GetByPhoneFunc
+func (m *UserStore) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	if m.GetByPhoneFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetByPhoneFunc(ctx, phone)
+}
+
+// CheckEmailExists calls CheckEmailExistsFunc
+func (m *UserStore) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	if m.CheckEmailExistsFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.CheckEmailExistsFunc(ctx, email)
+}
+
+// CheckUsernameExists calls CheckUsernameExistsFunc
+func (m *UserStore) CheckUsernameExists(ctx context.Context, username string) (bool, error) {
+	if m.CheckUsernameExistsFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.CheckUsernameExistsFunc(ctx, username)
+}
+
+// UpdateProfile calls UpdateProfileFunc
+func (m *UserStore) UpdateProfile(ctx context.Context, user *models.User) error {
+	if m.UpdateProfileFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateProfileFunc(ctx, user)
+}
+
+// UpdatePassword calls UpdatePasswordFunc
+func (m *UserStore) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	if m.UpdatePasswordFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePasswordFunc(ctx, id, passwordHash)
+}
+
+// Close calls CloseFunc
+func (m *UserStore) Close(ctx context.Context, id int64, closedAt time.Time) error {
+	if m.CloseFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CloseFunc(ctx, id, closedAt)
+}
+
+// AnonymizeClosed calls AnonymizeClosedFunc
+func (m *UserStore) AnonymizeClosed(ctx context.Context, closedBefore time.Time) (int64, error) {
+	if m.AnonymizeClosedFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.AnonymizeClosedFunc(ctx, closedBefore)
+}
+
+// RecordFailedLoginTx calls RecordFailedLoginTxFunc
+func (m *UserStore) RecordFailedLoginTx(ctx context.Context, tx repository.Tx, id int64, windowStart time.Time) (int, error) {
+	if m.RecordFailedLoginTxFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.RecordFailedLoginTxFunc(ctx, tx, id, windowStart)
+}
+
+// LockTx calls LockTxFunc
+func (m *UserStore) LockTx(ctx context.Context, tx repository.Tx, id int64, until time.Time) error {
+	if m.LockTxFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.LockTxFunc(ctx, tx, id, until)
+}
+
+// ResetFailedLogins calls ResetFailedLoginsFunc
+func (m *UserStore) ResetFailedLogins(ctx context.Context, id int64) error {
+	if m.ResetFailedLoginsFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.ResetFailedLoginsFunc(ctx, id)
+}
+
+// Unlock calls UnlockFunc
+func (m *UserStore) Unlock(ctx context.Context, id int64) error {
+	if m.UnlockFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UnlockFunc(ctx, id)
+}
+
+// ListByBranch calls ListByBranchFunc
+func (m *UserStore) ListByBranch(ctx context.Context, branchID int64, p pagination.Params) ([]*models.User, int, error) {
+	if m.ListByBranchFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListByBranchFunc(ctx, branchID, p)
+}
+
+// AssignBranch calls AssignBranchFunc
+func (m *UserStore) AssignBranch(ctx context.Context, id, branchID int64, role models.UserRole) error {
+	if m.AssignBranchFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.AssignBranchFunc(ctx, id, branchID, role)
+}
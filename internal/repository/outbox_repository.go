@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxRepository handles database operations for outbox messages
+type OutboxRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewOutboxRepository creates a new OutboxRepository instance
+func NewOutboxRepository(db *sql.DB, logger *logrus.Logger) *OutboxRepository {
+	return &OutboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create writes a message outside of any transaction
+func (r *OutboxRepository) Create(ctx context.Context, msg *models.OutboxMessage) error {
+	return r.create(ctx, r.db, msg)
+}
+
+// CreateTx writes a message within the transaction of the business change it
+// belongs to, so that it is only published if that change is committed
+func (r *OutboxRepository) CreateTx(ctx context.Context, tx Tx, msg *models.OutboxMessage) error {
+	return r.create(ctx, tx, msg)
+}
+
+func (r *OutboxRepository) create(ctx context.Context, q queryer, msg *models.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox_messages (topic, payload, status, available_at, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, available_at, created_at
+	`
+
+	err := q.QueryRowContext(
+		ctx,
+		query,
+		msg.Topic,
+		[]byte(msg.Payload),
+		msg.Status,
+	).Scan(&msg.ID, &msg.AvailableAt, &msg.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create outbox message")
+		return err
+	}
+
+	return nil
+}
+
+const outboxColumns = `
+	id, topic, payload, status, attempts, COALESCE(last_error, ''),
+	available_at, claimed_at, published_at, created_at
+`
+
+func scanOutboxMessage(scanner interface{ Scan(...interface{}) error }) (*models.OutboxMessage, error) {
+	msg := &models.OutboxMessage{}
+	var payload []byte
+	var claimedAt, publishedAt sql.NullTime
+
+	err := scanner.Scan(
+		&msg.ID,
+		&msg.Topic,
+		&payload,
+		&msg.Status,
+		&msg.Attempts,
+		&msg.LastError,
+		&msg.AvailableAt,
+		&claimedAt,
+		&publishedAt,
+		&msg.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Payload = payload
+	if claimedAt.Valid {
+		msg.ClaimedAt = &claimedAt.Time
+	}
+	if publishedAt.Valid {
+		msg.PublishedAt = &publishedAt.Time
+	}
+
+	return msg, nil
+}
+
+// ClaimNext marks the next due message as publishing and returns it, or nil when
+// there is nothing to publish. Messages whose lease has expired are claimed again,
+// which covers a relay that died mid-publish; this is what makes delivery
+// at-least-once rather than at-most-once.
+func (r *OutboxRepository) ClaimNext(ctx context.Context, lease time.Duration) (*models.OutboxMessage, error) {
+	query := `
+		UPDATE outbox_messages
+		SET status = $1, attempts = attempts + 1, claimed_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM outbox_messages
+			WHERE (status = $2 AND available_at <= CURRENT_TIMESTAMP)
+			   OR (status = $1 AND claimed_at < CURRENT_TIMESTAMP - make_interval(secs => $3))
+			ORDER BY available_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	msg, err := scanOutboxMessage(r.db.QueryRowContext(ctx, query, models.OutboxStatusPublishing, models.OutboxStatusPending, lease.Seconds()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to claim outbox message")
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// MarkPublished records that a message has been handed to its destination
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = $1, last_error = NULL, published_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, models.OutboxStatusPublished, id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark outbox message published")
+		return err
+	}
+
+	return nil
+}
+
+// MarkFailed returns a message to the queue to be published again at retryAt.
+// Messages are never dropped, a destination that stays down keeps them pending.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, publishErr string, retryAt time.Time) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = $1, available_at = $2, claimed_at = NULL, last_error = $3
+		WHERE id = $4
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, models.OutboxStatusPending, retryAt, publishErr, id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark outbox message failed")
+		return err
+	}
+
+	return nil
+}
+
+// RetryFailed makes the pending messages of the topics whose last attempt failed
+// due at once, instead of waiting out their backoff, and returns their number
+func (r *OutboxRepository) RetryFailed(ctx context.Context, topics []string) (int64, error) {
+	query := `
+		UPDATE outbox_messages
+		SET available_at = CURRENT_TIMESTAMP
+		WHERE status = $1 AND last_error IS NOT NULL AND topic = ANY($2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, models.OutboxStatusPending, pq.Array(topics))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to retry failed outbox messages")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// QueueDepth counts the messages waiting to be published by topic
+func (r *OutboxRepository) QueueDepth(ctx context.Context) ([]*models.OutboxQueueDepth, error) {
+	query := `
+		SELECT topic,
+			COUNT(*) FILTER (WHERE status = $1 AND last_error IS NULL),
+			COUNT(*) FILTER (WHERE status = $1 AND last_error IS NOT NULL),
+			COUNT(*) FILTER (WHERE status = $2),
+			MIN(created_at)
+		FROM outbox_messages
+		WHERE status IN ($1, $2)
+		GROUP BY topic
+		ORDER BY topic
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.OutboxStatusPending, models.OutboxStatusPublishing)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to count outbox messages")
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := []*models.OutboxQueueDepth{}
+	for rows.Next() {
+		depth := &models.OutboxQueueDepth{}
+		if err := rows.Scan(&depth.Topic, &depth.Pending, &depth.Retrying, &depth.Publishing, &depth.OldestAt); err != nil {
+			r.logger.WithError(err).Error("Failed to scan outbox queue depth")
+			return nil, err
+		}
+		depths = append(depths, depth)
+	}
+
+	return depths, rows.Err()
+}
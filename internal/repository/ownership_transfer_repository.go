@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// OwnershipTransferRepository handles database operations for account ownership transfers
+type OwnershipTransferRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewOwnershipTransferRepository creates a new OwnershipTransferRepository instance
+func NewOwnershipTransferRepository(db *sql.DB, logger *logrus.Logger) *OwnershipTransferRepository {
+	return &OwnershipTransferRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *OwnershipTransferRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates a new ownership transfer within a database transaction
+func (r *OwnershipTransferRepository) CreateTx(ctx context.Context, tx Tx, transfer *models.AccountOwnershipTransfer) error {
+	query := `
+		INSERT INTO account_ownership_transfers (
+			account_id, from_user_id, to_user_id, reason, document_reference,
+			include_cards, include_credits, status, requested_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		transfer.AccountID,
+		transfer.FromUserID,
+		transfer.ToUserID,
+		transfer.Reason,
+		transfer.DocumentReference,
+		transfer.IncludeCards,
+		transfer.IncludeCredits,
+		transfer.Status,
+		transfer.RequestedBy,
+	).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create ownership transfer")
+		return err
+	}
+
+	return nil
+}
+
+const ownershipTransferColumns = `
+	id, account_id, from_user_id, to_user_id, reason, document_reference,
+	include_cards, include_credits, status, requested_by, reviewed_by,
+	COALESCE(review_comment, ''), reviewed_at, created_at, updated_at
+`
+
+func scanOwnershipTransfer(scanner interface{ Scan(...interface{}) error }) (*models.AccountOwnershipTransfer, error) {
+	transfer := &models.AccountOwnershipTransfer{}
+	var reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+
+	err := scanner.Scan(
+		&transfer.ID,
+		&transfer.AccountID,
+		&transfer.FromUserID,
+		&transfer.ToUserID,
+		&transfer.Reason,
+		&transfer.DocumentReference,
+		&transfer.IncludeCards,
+		&transfer.IncludeCredits,
+		&transfer.Status,
+		&transfer.RequestedBy,
+		&reviewedBy,
+		&transfer.ReviewComment,
+		&reviewedAt,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reviewedBy.Valid {
+		transfer.ReviewedBy = &reviewedBy.Int64
+	}
+	if reviewedAt.Valid {
+		transfer.ReviewedAt = &reviewedAt.Time
+	}
+
+	return transfer, nil
+}
+
+// GetByID retrieves an ownership transfer by its ID
+func (r *OwnershipTransferRepository) GetByID(ctx context.Context, id int64) (*models.AccountOwnershipTransfer, error) {
+	query := `SELECT ` + ownershipTransferColumns + ` FROM account_ownership_transfers WHERE id = $1`
+
+	transfer, err := scanOwnershipTransfer(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("ownership transfer not found")
+		}
+		r.logger.WithError(err).Error("Failed to get ownership transfer")
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetByIDForUpdate retrieves an ownership transfer and locks its row until the transaction ends
+func (r *OwnershipTransferRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.AccountOwnershipTransfer, error) {
+	query := `SELECT ` + ownershipTransferColumns + ` FROM account_ownership_transfers WHERE id = $1 FOR UPDATE`
+
+	transfer, err := scanOwnershipTransfer(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("ownership transfer not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock ownership transfer")
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// List retrieves ownership transfers, optionally filtered by status
+func (r *OwnershipTransferRepository) List(ctx context.Context, status models.OwnershipTransferStatus) ([]*models.AccountOwnershipTransfer, error) {
+	query := `
+		SELECT ` + ownershipTransferColumns + `
+		FROM account_ownership_transfers
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, string(status))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list ownership transfers")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []*models.AccountOwnershipTransfer
+	for rows.Next() {
+		transfer, err := scanOwnershipTransfer(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan ownership transfer row")
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// UpdateReviewTx stores the review outcome of a transfer within a database transaction
+func (r *OwnershipTransferRepository) UpdateReviewTx(ctx context.Context, tx Tx, transfer *models.AccountOwnershipTransfer) error {
+	query := `
+		UPDATE account_ownership_transfers
+		SET status = $1, reviewed_by = $2, review_comment = $3, reviewed_at = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		transfer.Status,
+		transfer.ReviewedBy,
+		transfer.ReviewComment,
+		transfer.ReviewedAt,
+		transfer.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update ownership transfer review")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("ownership transfer not found")
+	}
+
+	return nil
+}
+
+// ReassignTx moves an account, and optionally its cards and credits, to a new owner
+// within a database transaction. The account is no longer shared: its members
+// and pending invitations are removed, and the IDs of the removed users returned.
+func (r *OwnershipTransferRepository) ReassignTx(ctx context.Context, tx Tx, transfer *models.AccountOwnershipTransfer) ([]int64, error) {
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE accounts SET user_id = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND user_id = $3`,
+		transfer.ToUserID, transfer.AccountID, transfer.FromUserID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to reassign account")
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, errors.New("account owner has changed since the transfer was requested")
+	}
+
+	if transfer.IncludeCards {
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE cards SET user_id = $1, updated_at = CURRENT_TIMESTAMP WHERE account_id = $2`,
+			transfer.ToUserID, transfer.AccountID,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to reassign cards")
+			return nil, err
+		}
+	}
+
+	if transfer.IncludeCredits {
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE credits SET user_id = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE account_id = $2`,
+			transfer.ToUserID, transfer.AccountID,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to reassign credits")
+			return nil, err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM account_members WHERE account_id = $1 RETURNING user_id`, transfer.AccountID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to remove account members")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var removed []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			r.logger.WithError(err).Error("Failed to scan removed account member")
+			return nil, err
+		}
+		removed = append(removed, userID)
+	}
+	return removed, rows.Err()
+}
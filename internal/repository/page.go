@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+)
+
+// How the lists of a user's accounts, cards, credits and stored statements, the
+// users of a branch, the payment schedule of a credit, the AML flags and the KYC
+// verifications are paged. The sort fields are column names.
+var (
+	AccountPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "balance", "currency", "name"},
+		DefaultSort: "created_at",
+	}
+	CardPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "status"},
+		DefaultSort: "-created_at",
+		Statuses:    []string{models.CardStatusActive, models.CardStatusBlocked, models.CardStatusExpired},
+	}
+	CreditPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "amount", "remaining_amount", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.CreditStatusActive), string(models.CreditStatusPaid),
+			string(models.CreditStatusDefault), string(models.CreditStatusClosed),
+			string(models.CreditStatusDelinquent), string(models.CreditStatusCollections),
+		},
+	}
+	UserPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "username", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.StatusActive), string(models.StatusBlocked), string(models.StatusInactive),
+			string(models.StatusClosed),
+		},
+	}
+	AMLFlagPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "amount_rub", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.AMLFlagStatusOpen), string(models.AMLFlagStatusUnderReview),
+			string(models.AMLFlagStatusReported), string(models.AMLFlagStatusDismissed),
+		},
+	}
+	KYCVerificationPageOptions = pagination.Options{
+		SortFields:  []string{"kyc_submitted_at", "kyc_reviewed_at"},
+		DefaultSort: "kyc_submitted_at",
+		Statuses: []string{
+			string(models.KYCStatusPending), string(models.KYCStatusVerified), string(models.KYCStatusRejected),
+		},
+	}
+	DisputePageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "amount", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.DisputeStatusOpen), string(models.DisputeStatusUnderReview), string(models.DisputeStatusResolved),
+			string(models.DisputeStatusRefunded), string(models.DisputeStatusDeclined),
+		},
+	}
+	PayeePageOptions = pagination.Options{
+		SortFields:  []string{"name", "created_at", "last_used_at"},
+		DefaultSort: "name",
+	}
+	PaymentRequestPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "expires_at", "amount", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.PaymentRequestStatusPending), string(models.PaymentRequestStatusHeld),
+			string(models.PaymentRequestStatusPaid), string(models.PaymentRequestStatusDeclined),
+			string(models.PaymentRequestStatusCancelled), string(models.PaymentRequestStatusExpired),
+		},
+	}
+	BillPaymentPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "amount", "status"},
+		DefaultSort: "-created_at",
+		Statuses: []string{
+			string(models.BillPaymentStatusPending), string(models.BillPaymentStatusCompleted),
+			string(models.BillPaymentStatusRefunded),
+		},
+	}
+	CollectionCasePageOptions = pagination.Options{
+		SortFields:  []string{"days_overdue", "overdue_amount", "created_at", "status"},
+		DefaultSort: "-days_overdue",
+		Statuses:    []string{string(models.CollectionCaseStatusOpen), string(models.CollectionCaseStatusResolved)},
+	}
+	DepositPageOptions = pagination.Options{
+		SortFields:  []string{"opened_at", "matures_at", "amount", "status"},
+		DefaultSort: "-opened_at",
+		Statuses: []string{
+			string(models.DepositStatusActive), string(models.DepositStatusMatured), string(models.DepositStatusWithdrawn),
+		},
+	}
+	AlertPageOptions = pagination.Options{
+		SortFields:  []string{"created_at", "type", "last_triggered_at"},
+		DefaultSort: "created_at",
+	}
+	StatementFilePageOptions = pagination.Options{
+		SortFields:  []string{"period_start", "created_at"},
+		DefaultSort: "-period_start",
+	}
+	PaymentSchedulePageOptions = pagination.Options{
+		SortFields:  []string{"due_date", "amount", "status"},
+		DefaultSort: "due_date",
+		Statuses: []string{
+			string(models.PaymentStatusPending), string(models.PaymentStatusPaid), string(models.PaymentStatusLate),
+			string(models.PaymentStatusOverdue), string(models.PaymentStatusArchived),
+		},
+	}
+)
+
+// queryPage counts the rows of table matching where and the status filter of
+// the page, and queries the columns of the rows on the page. The params come
+// from pagination.Parse, which keeps the sort field to the whitelist.
+func queryPage(ctx context.Context, q queryer, columns, table, where string, p pagination.Params, args ...interface{}) (*sql.Rows, int, error) {
+	if p.Status != "" {
+		args = append(args, p.Status)
+		where += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	var total int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s %s LIMIT $%d OFFSET $%d`,
+		columns, table, where, p.OrderBy(), len(args)+1, len(args)+2)
+	rows, err := q.QueryContext(ctx, query, append(args, p.Limit, p.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ParameterRepository handles database operations for business parameter versions
+type ParameterRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewParameterRepository creates a new ParameterRepository instance
+func NewParameterRepository(db *sql.DB, logger *logrus.Logger) *ParameterRepository {
+	return &ParameterRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *ParameterRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx stores a new parameter version within a database transaction
+func (r *ParameterRepository) CreateTx(ctx context.Context, tx Tx, param *models.BusinessParameter) error {
+	query := `
+		INSERT INTO business_parameters (key, value, effective_from, comment, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		param.Key,
+		param.Value,
+		param.EffectiveFrom,
+		sql.NullString{String: param.Comment, Valid: param.Comment != ""},
+		param.CreatedBy,
+	).Scan(&param.ID, &param.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("a version of this parameter already takes effect at that time")
+		}
+		r.logger.WithError(err).Error("Failed to create business parameter")
+		return err
+	}
+
+	return nil
+}
+
+const businessParameterColumns = `
+	id, key, value, effective_from, COALESCE(comment, ''), created_by, created_at
+`
+
+// GetAll retrieves every parameter version ordered by key and effective date
+func (r *ParameterRepository) GetAll(ctx context.Context) ([]*models.BusinessParameter, error) {
+	query := `
+		SELECT ` + businessParameterColumns + `
+		FROM business_parameters
+		ORDER BY key, effective_from
+	`
+
+	return r.list(ctx, query)
+}
+
+// GetByKey retrieves the versions of a parameter ordered by effective date
+func (r *ParameterRepository) GetByKey(ctx context.Context, key string) ([]*models.BusinessParameter, error) {
+	query := `
+		SELECT ` + businessParameterColumns + `
+		FROM business_parameters
+		WHERE key = $1
+		ORDER BY effective_from
+	`
+
+	return r.list(ctx, query, key)
+}
+
+func (r *ParameterRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.BusinessParameter, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get business parameters")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var params []*models.BusinessParameter
+	for rows.Next() {
+		param := &models.BusinessParameter{}
+		if err := rows.Scan(
+			&param.ID,
+			&param.Key,
+			&param.Value,
+			&param.EffectiveFrom,
+			&param.Comment,
+			&param.CreatedBy,
+			&param.CreatedAt,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to scan business parameter row")
+			return nil, err
+		}
+		params = append(params, param)
+	}
+
+	return params, rows.Err()
+}
+
+// marshalAppliedParameters encodes the parameters applied to an operation, storing NULL when none applied
+func marshalAppliedParameters(params []models.AppliedParameter) ([]byte, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+func unmarshalAppliedParameters(data []byte) ([]models.AppliedParameter, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var params []models.AppliedParameter
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// PayeeRepository handles database operations for the payees users save and
+// the transfers made to them
+type PayeeRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewPayeeRepository creates a new PayeeRepository instance
+func NewPayeeRepository(db *sql.DB, logger *logrus.Logger) *PayeeRepository {
+	return &PayeeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create saves a new payee
+func (r *PayeeRepository) Create(ctx context.Context, payee *models.Payee) error {
+	query := `
+		INSERT INTO payees (user_id, name, type, account_number, card_number, beneficiary_name, iban, bic)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, payee.UserID, payee.Name, payee.Type, payee.AccountNumber, payee.CardNumber,
+		payee.BeneficiaryName, payee.IBAN, payee.BIC,
+	).Scan(&payee.ID, &payee.CreatedAt, &payee.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create payee")
+		return err
+	}
+	return nil
+}
+
+const payeeColumns = `
+	id, user_id, name, type, COALESCE(account_number, ''), COALESCE(card_number, ''), COALESCE(beneficiary_name, ''),
+	COALESCE(iban, ''), COALESCE(bic, ''), confirmed_at, COALESCE(code_hash, ''), code_expires_at, code_attempts,
+	last_used_at, created_at, updated_at
+`
+
+func scanPayee(scanner interface{ Scan(...interface{}) error }) (*models.Payee, error) {
+	payee := &models.Payee{}
+	var confirmedAt, codeExpiresAt, lastUsedAt sql.NullTime
+
+	err := scanner.Scan(
+		&payee.ID,
+		&payee.UserID,
+		&payee.Name,
+		&payee.Type,
+		&payee.AccountNumber,
+		&payee.CardNumber,
+		&payee.BeneficiaryName,
+		&payee.IBAN,
+		&payee.BIC,
+		&confirmedAt,
+		&payee.CodeHash,
+		&codeExpiresAt,
+		&payee.CodeAttempts,
+		&lastUsedAt,
+		&payee.CreatedAt,
+		&payee.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if payee.CardNumber != "" {
+		payee.MaskedCard = (&models.Card{CardNumber: payee.CardNumber}).MaskNumber()
+	}
+	if confirmedAt.Valid {
+		payee.ConfirmedAt = &confirmedAt.Time
+	}
+	if codeExpiresAt.Valid {
+		payee.CodeExpiresAt = &codeExpiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		payee.LastUsedAt = &lastUsedAt.Time
+	}
+	return payee, nil
+}
+
+// GetByID retrieves a payee of a user. A payee of another user is reported as
+// not found.
+func (r *PayeeRepository) GetByID(ctx context.Context, userID, id int64) (*models.Payee, error) {
+	query := `SELECT ` + payeeColumns + ` FROM payees WHERE id = $1 AND user_id = $2`
+
+	payee, err := scanPayee(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("payee not found")
+		}
+		r.logger.WithError(err).Error("Failed to get payee")
+		return nil, err
+	}
+	return payee, nil
+}
+
+// GetByIDForUpdate retrieves and locks a payee of a user within a database transaction
+func (r *PayeeRepository) GetByIDForUpdate(ctx context.Context, tx Tx, userID, id int64) (*models.Payee, error) {
+	query := `SELECT ` + payeeColumns + ` FROM payees WHERE id = $1 AND user_id = $2 FOR UPDATE`
+
+	payee, err := scanPayee(tx.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("payee not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock payee")
+		return nil, err
+	}
+	return payee, nil
+}
+
+// List retrieves a page of the payees of a user and how many there are
+func (r *PayeeRepository) List(ctx context.Context, userID int64, p pagination.Params) ([]*models.Payee, int, error) {
+	rows, total, err := queryPage(ctx, r.db, payeeColumns, "payees", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list payees")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	payees := []*models.Payee{}
+	for rows.Next() {
+		payee, err := scanPayee(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		payees = append(payees, payee)
+	}
+	return payees, total, rows.Err()
+}
+
+// Update stores the name and payment details of a payee and whether it is
+// confirmed. A pending confirmation code is dropped.
+func (r *PayeeRepository) Update(ctx context.Context, payee *models.Payee) error {
+	query := `
+		UPDATE payees
+		SET name = $1, type = $2, account_number = NULLIF($3, ''), card_number = NULLIF($4, ''),
+			beneficiary_name = NULLIF($5, ''), iban = NULLIF($6, ''), bic = NULLIF($7, ''), confirmed_at = $8,
+			code_hash = NULL, code_expires_at = NULL, code_attempts = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9 AND user_id = $10
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, payee.Name, payee.Type, payee.AccountNumber, payee.CardNumber,
+		payee.BeneficiaryName, payee.IBAN, payee.BIC, payee.ConfirmedAt, payee.ID, payee.UserID,
+	).Scan(&payee.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("payee not found")
+		}
+		r.logger.WithError(err).Errorf("Failed to update payee %d", payee.ID)
+		return err
+	}
+	payee.CodeHash, payee.CodeExpiresAt, payee.CodeAttempts = "", nil, 0
+	return nil
+}
+
+// Delete removes a payee of a user. Transfers made to it stay in the analytics.
+func (r *PayeeRepository) Delete(ctx context.Context, userID, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM payees WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to delete payee %d", id)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("payee not found")
+	}
+	return nil
+}
+
+// SetCode stores the hash of a new confirmation code of a payee and resets the
+// wrong attempts
+func (r *PayeeRepository) SetCode(ctx context.Context, id int64, codeHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE payees
+		SET code_hash = $1, code_expires_at = $2, code_attempts = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, codeHash, expiresAt, id); err != nil {
+		r.logger.WithError(err).Errorf("Failed to set confirmation code of payee %d", id)
+		return err
+	}
+	return nil
+}
+
+// RecordCodeAttemptTx counts a wrong confirmation code entered for a payee
+// within a database transaction
+func (r *PayeeRepository) RecordCodeAttemptTx(ctx context.Context, tx Tx, id int64) error {
+	query := `UPDATE payees SET code_attempts = code_attempts + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		r.logger.WithError(err).Errorf("Failed to record confirmation attempt of payee %d", id)
+		return err
+	}
+	return nil
+}
+
+// ConfirmTx marks a payee as confirmed and drops its confirmation code within a
+// database transaction
+func (r *PayeeRepository) ConfirmTx(ctx context.Context, tx Tx, payee *models.Payee) error {
+	query := `
+		UPDATE payees
+		SET confirmed_at = CURRENT_TIMESTAMP, code_hash = NULL, code_expires_at = NULL, code_attempts = 0,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING confirmed_at, updated_at
+	`
+
+	var confirmedAt time.Time
+	if err := tx.QueryRowContext(ctx, query, payee.ID).Scan(&confirmedAt, &payee.UpdatedAt); err != nil {
+		r.logger.WithError(err).Errorf("Failed to confirm payee %d", payee.ID)
+		return err
+	}
+	payee.ConfirmedAt = &confirmedAt
+	payee.CodeHash, payee.CodeExpiresAt, payee.CodeAttempts = "", nil, 0
+	return nil
+}
+
+// RecordTransfer records a transfer made to a payee and when it was last used
+func (r *PayeeRepository) RecordTransfer(ctx context.Context, payee *models.Payee, accountID int64, externalTransferID *int64, amount float64, currency string) error {
+	tx, err := r.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO payee_transfers (payee_id, user_id, payee_name, account_id, external_transfer_id, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := tx.ExecContext(ctx, query, payee.ID, payee.UserID, payee.Name, accountID, externalTransferID,
+		amount, currency); err != nil {
+		r.logger.WithError(err).Errorf("Failed to record transfer to payee %d", payee.ID)
+		return err
+	}
+
+	var lastUsedAt time.Time
+	query = `UPDATE payees SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING last_used_at`
+	if err := tx.QueryRowContext(ctx, query, payee.ID).Scan(&lastUsedAt); err != nil {
+		r.logger.WithError(err).Errorf("Failed to update last use of payee %d", payee.ID)
+		return err
+	}
+	payee.LastUsedAt = &lastUsedAt
+
+	return tx.Commit()
+}
+
+// GetUsage sums the transfers a user made to each payee over a period, the
+// most used payees first
+func (r *PayeeRepository) GetUsage(ctx context.Context, userID int64, startDate, endDate time.Time) ([]models.PayeeUsage, error) {
+	query := `
+		SELECT payee_id, payee_name, currency, COUNT(*), SUM(amount)
+		FROM payee_transfers
+		WHERE user_id = $1 AND created_at >= $2 AND created_at <= $3
+		GROUP BY payee_id, payee_name, currency
+		ORDER BY COUNT(*) DESC, SUM(amount) DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get payee usage")
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []models.PayeeUsage{}
+	for rows.Next() {
+		var u models.PayeeUsage
+		var payeeID sql.NullInt64
+		if err := rows.Scan(&payeeID, &u.PayeeName, &u.Currency, &u.Count, &u.Amount); err != nil {
+			return nil, err
+		}
+		if payeeID.Valid {
+			u.PayeeID = &payeeID.Int64
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// BeginTransaction starts a new database transaction
+func (r *PayeeRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// PaymentRequestRepository handles database operations for the requests for
+// money users send each other
+type PaymentRequestRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewPaymentRequestRepository creates a new PaymentRequestRepository instance
+func NewPaymentRequestRepository(db *sql.DB, logger *logrus.Logger) *PaymentRequestRepository {
+	return &PaymentRequestRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateTx records a pending payment request within a database transaction
+func (r *PaymentRequestRepository) CreateTx(ctx context.Context, tx Tx, request *models.PaymentRequest) error {
+	query := `
+		INSERT INTO payment_requests (requester_id, requester_account_id, payer_id, amount, currency, description, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, request.RequesterID, request.RequesterAccountID, request.PayerID,
+		request.Amount, request.Currency, request.Description, request.Status, request.ExpiresAt,
+	).Scan(&request.ID, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create payment request")
+		return err
+	}
+	return nil
+}
+
+const paymentRequestColumns = `
+	id, requester_id, requester_account_id, payer_id, amount, currency, COALESCE(description, ''), status,
+	payer_account_id, transaction_id, fraud_check_id, COALESCE(decline_reason, ''), expires_at, responded_at,
+	created_at, updated_at
+`
+
+func scanPaymentRequest(scanner interface{ Scan(...interface{}) error }) (*models.PaymentRequest, error) {
+	request := &models.PaymentRequest{}
+	var payerAccountID, transactionID, fraudCheckID sql.NullInt64
+	var respondedAt sql.NullTime
+
+	err := scanner.Scan(
+		&request.ID,
+		&request.RequesterID,
+		&request.RequesterAccountID,
+		&request.PayerID,
+		&request.Amount,
+		&request.Currency,
+		&request.Description,
+		&request.Status,
+		&payerAccountID,
+		&transactionID,
+		&fraudCheckID,
+		&request.DeclineReason,
+		&request.ExpiresAt,
+		&respondedAt,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if payerAccountID.Valid {
+		request.PayerAccountID = &payerAccountID.Int64
+	}
+	if transactionID.Valid {
+		request.TransactionID = &transactionID.Int64
+	}
+	if fraudCheckID.Valid {
+		request.FraudCheckID = &fraudCheckID.Int64
+	}
+	if respondedAt.Valid {
+		request.RespondedAt = &respondedAt.Time
+	}
+	return request, nil
+}
+
+// GetByID retrieves a payment request
+func (r *PaymentRequestRepository) GetByID(ctx context.Context, id int64) (*models.PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + ` FROM payment_requests WHERE id = $1`
+
+	request, err := scanPaymentRequest(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("payment request not found")
+		}
+		r.logger.WithError(err).Error("Failed to get payment request")
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetByIDForUpdate retrieves and locks a payment request within a database transaction
+func (r *PaymentRequestRepository) GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + ` FROM payment_requests WHERE id = $1 FOR UPDATE`
+
+	request, err := scanPaymentRequest(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("payment request not found")
+		}
+		r.logger.WithError(err).Error("Failed to lock payment request")
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetHeldByFraudCheckForUpdate retrieves and locks the held payment request
+// whose transfer is waiting on a fraud check. It returns nil when there is none.
+func (r *PaymentRequestRepository) GetHeldByFraudCheckForUpdate(ctx context.Context, tx Tx, checkID int64) (*models.PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + ` FROM payment_requests WHERE fraud_check_id = $1 AND status = $2 FOR UPDATE`
+
+	request, err := scanPaymentRequest(tx.QueryRowContext(ctx, query, checkID, models.PaymentRequestStatusHeld))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to lock payment request of fraud check")
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListByPayer retrieves a page of the payment requests sent to a user and how many there are
+func (r *PaymentRequestRepository) ListByPayer(ctx context.Context, payerID int64, p pagination.Params) ([]*models.PaymentRequest, int, error) {
+	return r.list(ctx, "payer_id = $1", payerID, p)
+}
+
+// ListByRequester retrieves a page of the payment requests a user sent and how many there are
+func (r *PaymentRequestRepository) ListByRequester(ctx context.Context, requesterID int64, p pagination.Params) ([]*models.PaymentRequest, int, error) {
+	return r.list(ctx, "requester_id = $1", requesterID, p)
+}
+
+func (r *PaymentRequestRepository) list(ctx context.Context, where string, userID int64, p pagination.Params) ([]*models.PaymentRequest, int, error) {
+	rows, total, err := queryPage(ctx, r.db, paymentRequestColumns, "payment_requests", where, p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list payment requests")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	requests := []*models.PaymentRequest{}
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, total, rows.Err()
+}
+
+// GetExpired retrieves the IDs of the pending payment requests that expired
+// before the given time
+func (r *PaymentRequestRepository) GetExpired(ctx context.Context, now time.Time) ([]int64, error) {
+	query := `SELECT id FROM payment_requests WHERE status = $1 AND expires_at <= $2 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, models.PaymentRequestStatusPending, now)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get expired payment requests")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateStatusTx stores the status of a payment request and how it was
+// answered within a database transaction
+func (r *PaymentRequestRepository) UpdateStatusTx(ctx context.Context, tx Tx, request *models.PaymentRequest) error {
+	query := `
+		UPDATE payment_requests
+		SET status = $1, payer_account_id = $2, transaction_id = $3, fraud_check_id = $4,
+			decline_reason = NULLIF($5, ''), responded_at = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, request.Status, request.PayerAccountID, request.TransactionID,
+		request.FraudCheckID, request.DeclineReason, request.RespondedAt, request.ID,
+	).Scan(&request.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Failed to update payment request %d", request.ID)
+		return err
+	}
+	return nil
+}
+
+// BeginTransaction starts a new database transaction
+func (r *PaymentRequestRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
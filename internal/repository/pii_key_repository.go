@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// PIIKeyRepository handles database operations for the data keys encrypting
+// the personal data of users
+type PIIKeyRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewPIIKeyRepository creates a new PIIKeyRepository instance
+func NewPIIKeyRepository(db *sql.DB, logger *logrus.Logger) *PIIKeyRepository {
+	return &PIIKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListKeys lists all data keys, retired ones included, oldest first
+func (r *PIIKeyRepository) ListKeys(ctx context.Context) ([]*models.PIIDataKey, error) {
+	query := `
+		SELECT id, wrapped_key, created_at, retired_at
+		FROM pii_data_keys
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list data keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.PIIDataKey
+	for rows.Next() {
+		key := &models.PIIDataKey{}
+		var retiredAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.WrappedKey, &key.CreatedAt, &retiredAt); err != nil {
+			r.logger.WithError(err).Error("Failed to scan data key row")
+			return nil, err
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// CreateKey stores a new wrapped data key
+func (r *PIIKeyRepository) CreateKey(ctx context.Context, wrappedKey []byte) (*models.PIIDataKey, error) {
+	key := &models.PIIDataKey{WrappedKey: wrappedKey}
+	query := `
+		INSERT INTO pii_data_keys (wrapped_key)
+		VALUES ($1)
+		RETURNING id, created_at
+	`
+
+	if err := r.db.QueryRowContext(ctx, query, wrappedKey).Scan(&key.ID, &key.CreatedAt); err != nil {
+		r.logger.WithError(err).Error("Failed to create data key")
+		return nil, err
+	}
+	return key, nil
+}
+
+// RetireOthers retires every data key but the given one and returns how many
+// were retired
+func (r *PIIKeyRepository) RetireOthers(ctx context.Context, activeID int64) (int64, error) {
+	query := `
+		UPDATE pii_data_keys
+		SET retired_at = CURRENT_TIMESTAMP
+		WHERE id <> $1 AND retired_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, activeID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to retire data keys")
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so that repository
+// methods can share a single implementation with and without a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Tx is a database transaction as seen by the services. *sql.Tx implements it,
+// and tests substitute a fake so services can run against mock stores.
+type Tx interface {
+	queryer
+	Commit() error
+	Rollback() error
+}
+
+var _ Tx = (*sql.Tx)(nil)
+
+// beginTx starts a transaction on db
+func beginTx(ctx context.Context, db *sql.DB) (Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedTx{Tx: tx}, nil
+}
+
+// hookedTx is a transaction that runs the functions given to AfterCommit once
+// it has committed
+type hookedTx struct {
+	*sql.Tx
+	afterCommit []func()
+}
+
+// Commit commits the transaction and runs the functions waiting for it
+func (t *hookedTx) Commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	for _, fn := range t.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit runs fn once tx has committed, or at once if tx does not run
+// functions after its commit. Nothing is run for a transaction rolled back.
+func AfterCommit(tx Tx, fn func()) {
+	if hooked, ok := tx.(*hookedTx); ok {
+		hooked.afterCommit = append(hooked.afterCommit, fn)
+		return
+	}
+	fn()
+}
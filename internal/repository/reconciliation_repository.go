@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconciliationRepository handles database operations for the nightly balance
+// snapshots and the issues they find
+type ReconciliationRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewReconciliationRepository creates a new ReconciliationRepository instance
+func NewReconciliationRepository(db *sql.DB, logger *logrus.Logger) *ReconciliationRepository {
+	return &ReconciliationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ledgerSum sums the credits less the debits of the ledger entries of account a
+// matching the extra condition
+func ledgerSum(condition string) string {
+	return `(SELECT COALESCE(SUM(CASE WHEN e.side = 'credit' THEN e.amount ELSE -e.amount END), 0)
+		FROM ledger_entries e WHERE e.account_id = a.id` + condition + `)`
+}
+
+// CreateRun snapshots every account balance for the date and records the issues
+// found, in one database transaction. The expected balance is recomputed from
+// the transaction log: the opening balance carried over to the ledger plus the
+// transactions posted since. It returns nil when the date already has a run.
+func (r *ReconciliationRepository) CreateRun(ctx context.Context, date time.Time) (*models.ReconciliationRun, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	run := &models.ReconciliationRun{RunDate: date}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO reconciliation_runs (run_date) VALUES ($1)
+		ON CONFLICT (run_date) DO NOTHING
+		RETURNING id, created_at
+	`, date).Scan(&run.ID, &run.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create reconciliation run")
+		return nil, err
+	}
+
+	// One statement reads every balance, so the snapshot is consistent even while
+	// payments are being posted
+	snapshot := `
+		INSERT INTO balance_snapshots (run_id, account_id, currency, balance, ledger_balance, expected_balance)
+		SELECT $1, a.id, a.currency, a.balance, ` + ledgerSum("") + `,
+			` + ledgerSum(" AND e.transaction_id IS NULL") + `
+			+ (SELECT COALESCE(SUM(CASE WHEN t.to_account_id = a.id THEN t.amount ELSE -t.amount END), 0)
+				FROM transactions t
+				WHERE (t.from_account_id = a.id OR t.to_account_id = a.id)
+					AND EXISTS (SELECT 1 FROM ledger_entries p WHERE p.transaction_id = t.id))
+		FROM accounts a
+	`
+	result, err := tx.ExecContext(ctx, snapshot, run.ID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to snapshot account balances")
+		return nil, err
+	}
+	accounts, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	run.Accounts = int(accounts)
+
+	issues := `
+		INSERT INTO reconciliation_issues (run_id, account_id, kind, currency, balance, expected_balance, difference)
+		SELECT run_id, account_id, $2::VARCHAR, currency, balance, ledger_balance, balance - ledger_balance
+		FROM balance_snapshots
+		WHERE run_id = $1 AND balance <> ledger_balance
+		UNION ALL
+		SELECT run_id, account_id, $3::VARCHAR, currency, balance, expected_balance, balance - expected_balance
+		FROM balance_snapshots
+		WHERE run_id = $1 AND balance <> expected_balance
+	`
+	result, err = tx.ExecContext(ctx, issues, run.ID,
+		models.ReconciliationIssueLedgerMismatch, models.ReconciliationIssueTransactionLogMismatch)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to record reconciliation issues")
+		return nil, err
+	}
+	found, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	run.Issues = int(found)
+
+	if _, err := tx.ExecContext(ctx, `UPDATE reconciliation_runs SET accounts = $1, issues = $2 WHERE id = $3`,
+		run.Accounts, run.Issues, run.ID); err != nil {
+		r.logger.WithError(err).Error("Failed to update reconciliation run")
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRun retrieves the run of a date, or the latest run when date is zero
+func (r *ReconciliationRepository) GetRun(ctx context.Context, date time.Time) (*models.ReconciliationRun, error) {
+	query := `SELECT id, run_date, accounts, issues, created_at FROM reconciliation_runs ORDER BY run_date DESC LIMIT 1`
+	args := []interface{}{}
+	if !date.IsZero() {
+		query = `SELECT id, run_date, accounts, issues, created_at FROM reconciliation_runs WHERE run_date = $1`
+		args = append(args, date)
+	}
+
+	run := &models.ReconciliationRun{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&run.ID, &run.RunDate, &run.Accounts, &run.Issues, &run.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("reconciliation run not found")
+		}
+		r.logger.WithError(err).Error("Failed to get reconciliation run")
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetIssues retrieves the issues found by a run, by account
+func (r *ReconciliationRepository) GetIssues(ctx context.Context, runID int64) ([]*models.ReconciliationIssue, error) {
+	query := `
+		SELECT id, run_id, account_id, kind, currency, balance, expected_balance, difference, created_at
+		FROM reconciliation_issues
+		WHERE run_id = $1
+		ORDER BY account_id, kind
+	`
+	rows, err := r.db.QueryContext(ctx, query, runID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list reconciliation issues")
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []*models.ReconciliationIssue{}
+	for rows.Next() {
+		issue := &models.ReconciliationIssue{}
+		if err := rows.Scan(
+			&issue.ID,
+			&issue.RunID,
+			&issue.AccountID,
+			&issue.Kind,
+			&issue.Currency,
+			&issue.Balance,
+			&issue.ExpectedBalance,
+			&issue.Difference,
+			&issue.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
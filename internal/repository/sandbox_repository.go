@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// sandboxKeptTables survive a sandbox reset: the schema version, the reference
+// data set up by migrations or admins, and the positions of the transaction
+// scans, which are rewound instead
+var sandboxKeptTables = []string{
+	"schema_migrations",
+	"branches",
+	"billers",
+	"key_rates",
+	"aml_scan_state",
+	"alert_scan_state",
+}
+
+// SandboxRepository handles database operations of the sandbox mode
+type SandboxRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewSandboxRepository creates a new SandboxRepository instance
+func NewSandboxRepository(db *sql.DB, logger *logrus.Logger) *SandboxRepository {
+	return &SandboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Reset wipes the data of every table but the kept ones in one transaction and
+// restarts their IDs. It returns the number of tables wiped.
+func (r *SandboxRepository) Reset(ctx context.Context) (int, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> ALL($1)
+		ORDER BY tablename
+	`, pq.Array(sandboxKeptTables))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list tables")
+		return 0, err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tables = append(tables, pq.QuoteIdentifier(table))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(tables) > 0 {
+		if _, err := tx.ExecContext(ctx, `TRUNCATE `+strings.Join(tables, ", ")+` RESTART IDENTITY CASCADE`); err != nil {
+			r.logger.WithError(err).Error("Failed to wipe sandbox data")
+			return 0, err
+		}
+	}
+	for _, table := range []string{"aml_scan_state", "alert_scan_state"} {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET last_transaction_id = 0, updated_at = CURRENT_TIMESTAMP`); err != nil {
+			r.logger.WithError(err).Errorf("Failed to rewind %s", table)
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(tables), nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/sirupsen/logrus"
+)
+
+// SearchRepository handles cross-entity lookups for support and administrators
+type SearchRepository struct {
+	db     *sql.DB
+	cipher *pii.Cipher
+	logger *logrus.Logger
+}
+
+// NewSearchRepository creates a new SearchRepository instance
+func NewSearchRepository(db *sql.DB, logger *logrus.Logger) *SearchRepository {
+	return &SearchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetCipher decrypts the personal data of the users found. Encrypted emails and
+// phone numbers only match the query exactly, through their blind indexes, and
+// encrypted names do not match at all; the username still matches the pattern.
+func (r *SearchRepository) SetCipher(c *pii.Cipher) {
+	r.cipher = c
+}
+
+// Search finds users, accounts, cards, credits and transactions in one query.
+// Users match the text pattern on name, username and email or the digits on
+// their phone number; cards match the digits as a card number suffix; accounts
+// match the digits as their account number; accounts, credits and transactions
+// match the reference as their ID. Empty criteria and a
+// zero reference are skipped. The text is the query as typed, matched against
+// the blind indexes of encrypted emails and phone numbers.
+func (r *SearchRepository) Search(ctx context.Context, text, pattern string, reference int64, digits string, limit int) ([]*models.SearchResult, error) {
+	query := `
+		SELECT type, id, user_id, title, first_name, last_name, email, phone, card_number, amount, currency, status, created_at
+		FROM (
+			SELECT 'user' AS type, u.id, u.id AS user_id, u.username AS title,
+				COALESCE(u.first_name, '') AS first_name, COALESCE(u.last_name, '') AS last_name,
+				u.email, COALESCE(u.phone_number, '') AS phone, '' AS card_number,
+				NULL::DECIMAL AS amount, '' AS currency, u.status, u.created_at
+			FROM users u
+			WHERE ($1 <> '' AND u.username ILIKE $1)
+				OR (NOT $5 AND $1 <> '' AND (
+					u.email ILIKE $1 OR
+					COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '') ILIKE $1
+				))
+				OR (NOT $5 AND LENGTH($3) >= 4 AND REGEXP_REPLACE(COALESCE(u.phone_number, ''), '\D', '', 'g') LIKE '%' || $3 || '%')
+				OR u.email_hash = $6
+				OR u.phone_hash = $7
+
+			UNION ALL
+
+			SELECT 'account', a.id, a.user_id, 'Account #' || a.id, '', '', '', '', '',
+				a.balance, a.currency, '', a.created_at
+			FROM accounts a
+			WHERE a.id = $2 OR a.number = $3
+
+			UNION ALL
+
+			SELECT 'card', c.id, c.user_id, c.card_type || ' card', '', '', '', '', c.card_number,
+				NULL::DECIMAL, '', c.status, c.created_at
+			FROM cards c
+			WHERE LENGTH($3) >= 4 AND c.card_number LIKE '%' || $3
+
+			UNION ALL
+
+			SELECT 'credit', cr.id, cr.user_id, 'Credit #' || cr.id, '', '', '', '', '',
+				cr.amount, COALESCE(a.currency, ''), cr.status, cr.created_at
+			FROM credits cr
+			LEFT JOIN accounts a ON a.id = cr.account_id
+			WHERE cr.id = $2
+
+			UNION ALL
+
+			SELECT 'transaction', t.id, COALESCE(fa.user_id, ta.user_id, 0),
+				t.type || COALESCE(': ' || t.description, ''), '', '', '', '', '',
+				t.amount, COALESCE(fa.currency, ta.currency, ''), '', t.created_at
+			FROM transactions t
+			LEFT JOIN accounts fa ON fa.id = t.from_account_id
+			LEFT JOIN accounts ta ON ta.id = t.to_account_id
+			WHERE t.id = $2
+		) results
+		ORDER BY created_at DESC
+		LIMIT $4
+	`
+
+	var emailIndex, phoneIndex string
+	if r.cipher != nil {
+		emailIndex = r.cipher.EmailIndex(text)
+		if len(digits) >= 4 {
+			phoneIndex = r.cipher.PhoneIndex(digits)
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pattern, reference, digits, limit, r.cipher != nil, emailIndex, phoneIndex)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to search entities")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.SearchResult
+	for rows.Next() {
+		result := &models.SearchResult{}
+		var firstName, lastName string
+		var amount sql.NullFloat64
+		if err := rows.Scan(
+			&result.Type,
+			&result.ID,
+			&result.UserID,
+			&result.Title,
+			&firstName,
+			&lastName,
+			&result.Email,
+			&result.Phone,
+			&result.CardNumber,
+			&amount,
+			&result.Currency,
+			&result.Status,
+			&result.CreatedAt,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to scan search result row")
+			return nil, err
+		}
+		result.Amount = nullableFloat(amount)
+		if result.Type == "user" {
+			if err := r.decryptUser(ctx, result, firstName, lastName); err != nil {
+				r.logger.WithError(err).Error("Failed to decrypt search result")
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// decryptUser fills in the plain contact details of a user found and titles it
+// with the name and the username
+func (r *SearchRepository) decryptUser(ctx context.Context, result *models.SearchResult, firstName, lastName string) error {
+	if r.cipher != nil {
+		var err error
+		for _, field := range []struct {
+			name  string
+			value *string
+		}{
+			{pii.FieldEmail, &result.Email},
+			{pii.FieldFirstName, &firstName},
+			{pii.FieldLastName, &lastName},
+			{pii.FieldPhone, &result.Phone},
+		} {
+			if *field.value, err = r.cipher.Decrypt(ctx, field.name, *field.value); err != nil {
+				return err
+			}
+		}
+	}
+	result.Title = strings.TrimSpace(firstName + " " + lastName + " (" + result.Title + ")")
+	return nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/cache"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// SessionRepository handles database operations for the sessions tokens are
+// issued for
+type SessionRepository struct {
+	db       *sql.DB
+	cache    cache.Cache
+	cacheTTL time.Duration
+	logger   *logrus.Logger
+}
+
+// NewSessionRepository creates a new SessionRepository instance
+func NewSessionRepository(db *sql.DB, logger *logrus.Logger) *SessionRepository {
+	return &SessionRepository{
+		db:     db,
+		cache:  cache.Nop{},
+		logger: logger,
+	}
+}
+
+// SetCache makes Touch take active sessions from c for up to ttl, so a token is
+// not looked up on every request. Revoked sessions are dropped from the cache;
+// their use is recorded only when the session is read from the database.
+func (r *SessionRepository) SetCache(c cache.Cache, ttl time.Duration) {
+	r.cache = c
+	r.cacheTTL = ttl
+}
+
+func sessionCacheKey(tokenID string) string {
+	return "session:" + tokenID
+}
+
+// activeSessions selects the sessions whose tokens are still accepted
+const activeSessions = "revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP"
+
+// sessionTouchInterval is how stale the last use of a session may get before a
+// request records it again, which spares a write on every request
+const sessionTouchInterval = time.Minute
+
+// Create stores a new session
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, token_id, device_name, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6)
+		RETURNING id, last_used_at, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		session.UserID,
+		session.TokenID,
+		session.DeviceName,
+		session.IPAddress,
+		session.UserAgent,
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.LastUsedAt, &session.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create session")
+		return err
+	}
+	return nil
+}
+
+// GetActiveByUserID lists the sessions of a user that are neither revoked nor
+// expired, most recently used first
+func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID int64) ([]*models.Session, error) {
+	query := `
+		SELECT id, user_id, token_id, COALESCE(device_name, ''), COALESCE(ip_address, ''),
+			COALESCE(user_agent, ''), last_used_at, expires_at, created_at
+		FROM sessions
+		WHERE user_id = $1 AND ` + activeSessions + `
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get sessions")
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session := &models.Session{}
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.TokenID,
+			&session.DeviceName,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch tells whether the session of a token is active and records its use
+func (r *SessionRepository) Touch(ctx context.Context, tokenID string) (bool, error) {
+	var active bool
+	if r.cache.Get(ctx, sessionCacheKey(tokenID), &active) {
+		return active, nil
+	}
+
+	var id int64
+	var lastUsedAt, expiresAt time.Time
+	query := `SELECT id, last_used_at, expires_at FROM sessions WHERE token_id = $1 AND ` + activeSessions
+	err := r.db.QueryRowContext(ctx, query, tokenID).Scan(&id, &lastUsedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The session is not cached past its expiry
+	r.cache.Set(ctx, sessionCacheKey(tokenID), true, min(r.cacheTTL, time.Until(expiresAt)))
+
+	if time.Since(lastUsedAt) > sessionTouchInterval {
+		if _, err := r.db.ExecContext(ctx, `UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, id); err != nil {
+			r.logger.WithError(err).Warn("Failed to record session use")
+		}
+	}
+	return true, nil
+}
+
+// Revoke revokes an active session of a user
+func (r *SessionRepository) Revoke(ctx context.Context, userID, id int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND ` + activeSessions + `
+		RETURNING token_id`
+
+	var tokenID string
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("session not found")
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to revoke session")
+		return err
+	}
+	r.cache.Delete(ctx, sessionCacheKey(tokenID))
+	return nil
+}
+
+// RevokeAll revokes every active session of a user
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int64) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND ` + activeSessions + ` RETURNING token_id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to revoke sessions")
+		return err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var tokenID string
+		if err := rows.Scan(&tokenID); err != nil {
+			return err
+		}
+		keys = append(keys, sessionCacheKey(tokenID))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	r.cache.Delete(ctx, keys...)
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// StatementRepository handles database operations for monthly statement
+// deliveries and the statement files kept in object storage
+type StatementRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewStatementRepository creates a new StatementRepository instance
+func NewStatementRepository(db *sql.DB, logger *logrus.Logger) *StatementRepository {
+	return &StatementRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *StatementRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// ListRecipients retrieves the users who opted in to monthly statements with the
+// month of their latest statement
+func (r *StatementRepository) ListRecipients(ctx context.Context) ([]*models.StatementRecipient, error) {
+	query := `
+		SELECT s.user_id, s.timezone, MAX(d.period_start)
+		FROM user_settings s
+		LEFT JOIN statement_deliveries d ON d.user_id = s.user_id
+		WHERE s.monthly_statements
+		GROUP BY s.user_id, s.timezone
+		ORDER BY s.user_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list statement recipients")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*models.StatementRecipient
+	for rows.Next() {
+		recipient := &models.StatementRecipient{}
+		var lastPeriodStart sql.NullTime
+		if err := rows.Scan(&recipient.UserID, &recipient.TimeZone, &lastPeriodStart); err != nil {
+			r.logger.WithError(err).Error("Failed to scan statement recipient")
+			return nil, err
+		}
+		if lastPeriodStart.Valid {
+			recipient.LastPeriodStart = &lastPeriodStart.Time
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+// CreateTx records a statement delivery within a database transaction. It reports
+// false when the user already has a delivery for the month.
+func (r *StatementRepository) CreateTx(ctx context.Context, tx Tx, delivery *models.StatementDelivery) (bool, error) {
+	query := `
+		INSERT INTO statement_deliveries (user_id, period_start, status, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id, period_start) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, delivery.UserID, delivery.PeriodStart, delivery.Status).
+		Scan(&delivery.ID, &delivery.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		r.logger.WithError(err).Error("Failed to create statement delivery")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetByID retrieves a statement delivery by ID
+func (r *StatementRepository) GetByID(ctx context.Context, id int64) (*models.StatementDelivery, error) {
+	query := `
+		SELECT id, user_id, period_start, status, accounts, sent_at, created_at
+		FROM statement_deliveries
+		WHERE id = $1
+	`
+
+	delivery := &models.StatementDelivery{}
+	var sentAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&delivery.ID,
+		&delivery.UserID,
+		&delivery.PeriodStart,
+		&delivery.Status,
+		&delivery.Accounts,
+		&sentAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("statement delivery not found")
+		}
+		r.logger.WithError(err).Error("Failed to get statement delivery")
+		return nil, err
+	}
+	if sentAt.Valid {
+		delivery.SentAt = &sentAt.Time
+	}
+
+	return delivery, nil
+}
+
+// MarkSent records that the statements of a delivery were emailed
+func (r *StatementRepository) MarkSent(ctx context.Context, id int64, accounts int) error {
+	query := `
+		UPDATE statement_deliveries
+		SET status = $1, accounts = $2, sent_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, models.StatementDeliveryStatusSent, accounts, id); err != nil {
+		r.logger.WithError(err).Error("Failed to mark statement delivery sent")
+		return err
+	}
+
+	return nil
+}
+
+// CreateFile records a statement file. A file stored again under the same key
+// by a retried delivery replaces the record.
+func (r *StatementRepository) CreateFile(ctx context.Context, file *models.StatementFile) error {
+	query := `
+		INSERT INTO statement_files (delivery_id, user_id, account_id, period_start, storage_key, file_name, size, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+		ON CONFLICT (storage_key) DO UPDATE SET size = EXCLUDED.size, created_at = EXCLUDED.created_at
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, file.DeliveryID, file.UserID, file.AccountID, file.PeriodStart,
+		file.StorageKey, file.FileName, file.Size).Scan(&file.ID, &file.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create statement file")
+		return err
+	}
+	return nil
+}
+
+const statementFileColumns = `id, delivery_id, user_id, account_id, period_start, storage_key, file_name, size, created_at`
+
+func scanStatementFile(scanner interface{ Scan(...interface{}) error }) (*models.StatementFile, error) {
+	file := &models.StatementFile{}
+	err := scanner.Scan(&file.ID, &file.DeliveryID, &file.UserID, &file.AccountID, &file.PeriodStart,
+		&file.StorageKey, &file.FileName, &file.Size, &file.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetFile retrieves a statement file by ID
+func (r *StatementRepository) GetFile(ctx context.Context, id int64) (*models.StatementFile, error) {
+	query := `SELECT ` + statementFileColumns + ` FROM statement_files WHERE id = $1`
+
+	file, err := scanStatementFile(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("statement not found")
+		}
+		r.logger.WithError(err).Error("Failed to get statement file")
+		return nil, err
+	}
+	return file, nil
+}
+
+// ListFiles retrieves a page of the statement files of a user and how many there are
+func (r *StatementRepository) ListFiles(ctx context.Context, userID int64, p pagination.Params) ([]*models.StatementFile, int, error) {
+	rows, total, err := queryPage(ctx, r.db, statementFileColumns, "statement_files", "user_id = $1", p, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list statement files")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := []*models.StatementFile{}
+	for rows.Next() {
+		file, err := scanStatementFile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, file)
+	}
+	return files, total, rows.Err()
+}
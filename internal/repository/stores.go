@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+)
+
+// The stores below are the repository contracts services depend on, so they can
+// be given in-memory or mock implementations instead of a database.
+
+// AccountStore persists accounts, their balances and transactions
+type AccountStore interface {
+	BeginTransaction(ctx context.Context) (Tx, error)
+	Create(ctx context.Context, account *models.Account) error
+	GetByID(ctx context.Context, id int64) (*models.Account, error)
+	GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Account, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Account, error)
+	ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Account, int, error)
+	GetByNumber(ctx context.Context, number string) (*models.Account, error)
+	GetWithoutNumber(ctx context.Context) ([]*models.Account, error)
+	SetNumber(ctx context.Context, id int64, number string) error
+	GetPrimary(ctx context.Context, userID int64, currency string) (*models.Account, error)
+	UpdateName(ctx context.Context, id int64, name string) error
+	SetPrimary(ctx context.Context, account *models.Account, primary bool) error
+	CloseTx(ctx context.Context, tx Tx, account *models.Account, closedAt time.Time) error
+	ListInactive(ctx context.Context, cutoff time.Time) ([]int64, error)
+	MarkDormantTx(ctx context.Context, tx Tx, id int64, cutoff, dormantSince time.Time) (bool, error)
+	ListDormancyFeeDue(ctx context.Context, before time.Time) ([]int64, error)
+	SetDormancyFeeChargedTx(ctx context.Context, tx Tx, id int64, chargedAt time.Time) error
+	Reactivate(ctx context.Context, id int64, reactivatedAt time.Time) (bool, error)
+	GetMemberRole(ctx context.Context, accountID, userID int64) (models.AccountRole, error)
+	PostTransaction(ctx context.Context, transaction *models.Transaction) error
+	PostTransactionTx(ctx context.Context, tx Tx, transaction *models.Transaction) error
+	GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error)
+	GetTransactions(ctx context.Context, accountID int64, startDate, endDate time.Time) ([]*models.Transaction, error)
+	ListTransactionsByAccounts(ctx context.Context, accountIDs []int64, filter models.TransactionFilter) (map[int64][]*models.Transaction, error)
+	SetOverdraft(ctx context.Context, id int64, limit, interestRate float64) error
+	GetWithOverdraftFacility(ctx context.Context) ([]*models.Account, error)
+	ChargeOverdraftInterest(ctx context.Context, accountID int64, interest float64, accruedAt time.Time) error
+	GetTransactionTotalByType(ctx context.Context, accountID int64, txType string, startDate, endDate time.Time) (float64, error)
+	GetCardPaymentTotal(ctx context.Context, cardID int64, since time.Time) (float64, error)
+	GetCardPaymentTotalTx(ctx context.Context, tx Tx, cardID int64, since time.Time) (float64, error)
+}
+
+// UserStore persists users
+type UserStore interface {
+	BeginTransaction(ctx context.Context) (Tx, error)
+	Create(ctx context.Context, user *models.User) error
+	CreateTx(ctx context.Context, tx Tx, user *models.User) error
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+	CheckEmailExists(ctx context.Context, email string) (bool, error)
+	CheckUsernameExists(ctx context.Context, username string) (bool, error)
+	UpdateProfile(ctx context.Context, user *models.User) error
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	Close(ctx context.Context, id int64, closedAt time.Time) error
+	AnonymizeClosed(ctx context.Context, closedBefore time.Time) (int64, error)
+	RecordFailedLoginTx(ctx context.Context, tx Tx, id int64, windowStart time.Time) (int, error)
+	LockTx(ctx context.Context, tx Tx, id int64, until time.Time) error
+	ResetFailedLogins(ctx context.Context, id int64) error
+	Unlock(ctx context.Context, id int64) error
+	ListByBranch(ctx context.Context, branchID int64, p pagination.Params) ([]*models.User, int, error)
+	AssignBranch(ctx context.Context, id, branchID int64, role models.UserRole) error
+}
+
+// CreditStore persists credits and their payment schedules
+type CreditStore interface {
+	Create(ctx context.Context, credit *models.Credit) error
+	CreateTx(ctx context.Context, tx Tx, credit *models.Credit) error
+	GetByID(ctx context.Context, id int64) (*models.Credit, error)
+	GetByIDForUpdate(ctx context.Context, tx Tx, id int64) (*models.Credit, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Credit, error)
+	GetByAccountIDTx(ctx context.Context, tx Tx, accountID int64) ([]*models.Credit, error)
+	ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Credit, int, error)
+	GetPaymentSchedule(ctx context.Context, creditID int64) ([]*models.PaymentSchedule, error)
+	GetPaymentSchedules(ctx context.Context, creditIDs []int64) (map[int64][]*models.PaymentSchedule, error)
+	ListPaymentSchedule(ctx context.Context, creditID int64, p pagination.Params) ([]*models.PaymentSchedule, int, error)
+	GetPaymentScheduleTx(ctx context.Context, tx Tx, creditID int64) ([]*models.PaymentSchedule, error)
+	GetPaymentScheduleForUpdate(ctx context.Context, tx Tx, creditID int64) ([]*models.PaymentSchedule, error)
+	GetOverduePayments(ctx context.Context) ([]*models.PaymentSchedule, error)
+	MarkOverduePaymentsTx(ctx context.Context, tx Tx, before time.Time) ([]*models.PaymentSchedule, error)
+	GetPaymentForUpdate(ctx context.Context, tx Tx, paymentID int64) (*models.PaymentSchedule, error)
+	AccruePenaltyTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule, accruals []*models.CreditPenaltyAccrual) error
+	UpdateRemainingAmount(ctx context.Context, creditID int64, amount float64) error
+	BeginTransaction(ctx context.Context) (Tx, error)
+	UpdatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error
+	Update(ctx context.Context, credit *models.Credit) error
+	UpdateTx(ctx context.Context, tx Tx, credit *models.Credit) error
+	CreatePaymentSchedule(ctx context.Context, payment *models.PaymentSchedule) error
+	CreatePaymentScheduleTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error
+	GetCreditsWithDuePayments(ctx context.Context) ([]*models.Credit, error)
+	GetNextPayment(ctx context.Context, creditID int64) (*models.PaymentSchedule, error)
+	UpdatePaymentStatus(ctx context.Context, paymentID int64, status string) error
+	UpdatePaymentAmountTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error
+	UpdatePaymentPaidTx(ctx context.Context, tx Tx, payment *models.PaymentSchedule) error
+	ArchivePaymentTx(ctx context.Context, tx Tx, paymentID, restructuringID int64) error
+	UpdateTermsTx(ctx context.Context, tx Tx, credit *models.Credit) error
+	DeletePaymentTx(ctx context.Context, tx Tx, paymentID int64) error
+}
+
+// CardStore persists cards
+type CardStore interface {
+	Create(ctx context.Context, card *models.Card) error
+	CreateTx(ctx context.Context, tx Tx, card *models.Card) error
+	GetByID(ctx context.Context, id int64) (*models.Card, error)
+	GetByNumber(ctx context.Context, number string) (*models.Card, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Card, error)
+	GetByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error)
+	GetByAccountIDTx(ctx context.Context, tx Tx, accountID int64) ([]*models.Card, error)
+	ListByUserID(ctx context.Context, userID int64, p pagination.Params) ([]*models.Card, int, error)
+	GetExpiring(ctx context.Context, before time.Time) ([]*models.Card, error)
+	GetExpired(ctx context.Context, now time.Time) ([]*models.Card, error)
+	MarkExpiryNotified(ctx context.Context, id int64) error
+	UpdateStatus(ctx context.Context, id int64, status string) error
+	UseSingleUseTx(ctx context.Context, tx Tx, id int64) error
+	BlockByAccountTx(ctx context.Context, tx Tx, accountID, keepUserID int64) ([]int64, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+var (
+	_ AccountStore = (*AccountRepository)(nil)
+	_ UserStore    = (*UserRepository)(nil)
+	_ CreditStore  = (*CreditRepository)(nil)
+	_ CardStore    = (*CardRepository)(nil)
+)
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// TransferBatchRepository handles database operations for batches of transfers
+type TransferBatchRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewTransferBatchRepository creates a new TransferBatchRepository instance
+func NewTransferBatchRepository(db *sql.DB, logger *logrus.Logger) *TransferBatchRepository {
+	return &TransferBatchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *TransferBatchRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateTx creates a batch together with its items within a database transaction
+func (r *TransferBatchRepository) CreateTx(ctx context.Context, tx Tx, batch *models.TransferBatch) error {
+	query := `
+		INSERT INTO transfer_batches (
+			user_id, from_account_id, mode, status, total_transfers, total_amount,
+			client_ip, client_country, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		batch.UserID,
+		batch.FromAccountID,
+		batch.Mode,
+		batch.Status,
+		batch.TotalTransfers,
+		batch.TotalAmount,
+		batch.Client.IP,
+		batch.Client.Country,
+	).Scan(&batch.ID, &batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create transfer batch")
+		return err
+	}
+
+	itemQuery := `
+		INSERT INTO transfer_batch_items (
+			batch_id, position, to_account_id, amount, reference, status, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	for _, item := range batch.Items {
+		item.BatchID = batch.ID
+		err := tx.QueryRowContext(
+			ctx,
+			itemQuery,
+			item.BatchID,
+			item.Position,
+			item.ToAccountID,
+			item.Amount,
+			item.Reference,
+			item.Status,
+		).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to create transfer batch item")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetJobTx links a batch to the job that processes it
+func (r *TransferBatchRepository) SetJobTx(ctx context.Context, tx Tx, batchID, jobID int64) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE transfer_batches SET job_id = $1 WHERE id = $2`, jobID, batchID); err != nil {
+		r.logger.WithError(err).Error("Failed to link transfer batch to job")
+		return err
+	}
+	return nil
+}
+
+const transferBatchColumns = `
+	id, user_id, from_account_id, mode, status, total_transfers, total_amount,
+	completed_transfers, held_transfers, failed_transfers, COALESCE(client_ip, ''),
+	COALESCE(client_country, ''), job_id, COALESCE(error, ''), completed_at, created_at, updated_at
+`
+
+func scanTransferBatch(scanner interface{ Scan(...interface{}) error }) (*models.TransferBatch, error) {
+	batch := &models.TransferBatch{}
+	var jobID sql.NullInt64
+	var completedAt sql.NullTime
+
+	err := scanner.Scan(
+		&batch.ID,
+		&batch.UserID,
+		&batch.FromAccountID,
+		&batch.Mode,
+		&batch.Status,
+		&batch.TotalTransfers,
+		&batch.TotalAmount,
+		&batch.CompletedTransfers,
+		&batch.HeldTransfers,
+		&batch.FailedTransfers,
+		&batch.Client.IP,
+		&batch.Client.Country,
+		&jobID,
+		&batch.Error,
+		&completedAt,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if jobID.Valid {
+		batch.JobID = &jobID.Int64
+	}
+	if completedAt.Valid {
+		batch.CompletedAt = &completedAt.Time
+	}
+	return batch, nil
+}
+
+// GetByID retrieves a batch without its items
+func (r *TransferBatchRepository) GetByID(ctx context.Context, id int64) (*models.TransferBatch, error) {
+	query := `SELECT ` + transferBatchColumns + ` FROM transfer_batches WHERE id = $1`
+
+	batch, err := scanTransferBatch(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("transfer batch not found")
+		}
+		r.logger.WithError(err).Error("Failed to get transfer batch")
+		return nil, err
+	}
+	return batch, nil
+}
+
+// GetItems retrieves the items of a batch in the order of the request
+func (r *TransferBatchRepository) GetItems(ctx context.Context, batchID int64) ([]*models.TransferBatchItem, error) {
+	query := `
+		SELECT id, batch_id, position, to_account_id, amount, COALESCE(reference, ''), status,
+		       transaction_id, fraud_check_id, COALESCE(error, ''), created_at, updated_at
+		FROM transfer_batch_items
+		WHERE batch_id = $1
+		ORDER BY position
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, batchID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get transfer batch items")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.TransferBatchItem
+	for rows.Next() {
+		item := &models.TransferBatchItem{}
+		var transactionID, fraudCheckID sql.NullInt64
+
+		err := rows.Scan(
+			&item.ID,
+			&item.BatchID,
+			&item.Position,
+			&item.ToAccountID,
+			&item.Amount,
+			&item.Reference,
+			&item.Status,
+			&transactionID,
+			&fraudCheckID,
+			&item.Error,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan transfer batch item row")
+			return nil, err
+		}
+
+		if transactionID.Valid {
+			item.TransactionID = &transactionID.Int64
+		}
+		if fraudCheckID.Valid {
+			item.FraudCheckID = &fraudCheckID.Int64
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// UpdateStatus updates the processing status of a batch
+func (r *TransferBatchRepository) UpdateStatus(ctx context.Context, id int64, status models.TransferBatchStatus) error {
+	query := `UPDATE transfer_batches SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	if _, err := r.db.ExecContext(ctx, query, status, id); err != nil {
+		r.logger.WithError(err).Error("Failed to update transfer batch status")
+		return err
+	}
+	return nil
+}
+
+// MarkItemCompletedTx records the transaction made for a batch item within the
+// transaction that made it
+func (r *TransferBatchRepository) MarkItemCompletedTx(ctx context.Context, tx Tx, itemID, transactionID int64) error {
+	query := `
+		UPDATE transfer_batch_items
+		SET status = $1, transaction_id = $2, error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+	if _, err := tx.ExecContext(ctx, query, models.TransferBatchItemStatusCompleted, transactionID, itemID); err != nil {
+		r.logger.WithError(err).Error("Failed to mark transfer batch item completed")
+		return err
+	}
+	return nil
+}
+
+// MarkItemHeld records the fraud check holding the transfer of a batch item
+func (r *TransferBatchRepository) MarkItemHeld(ctx context.Context, itemID, checkID int64, reason string) error {
+	query := `
+		UPDATE transfer_batch_items
+		SET status = $1, fraud_check_id = $2, error = NULLIF($3, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`
+	if _, err := r.db.ExecContext(ctx, query, models.TransferBatchItemStatusHeld, checkID, reason, itemID); err != nil {
+		r.logger.WithError(err).Error("Failed to mark transfer batch item held")
+		return err
+	}
+	return nil
+}
+
+// MarkItemFailed records why the transfer of a batch item was not made
+func (r *TransferBatchRepository) MarkItemFailed(ctx context.Context, itemID int64, reason string) error {
+	query := `UPDATE transfer_batch_items SET status = $1, error = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`
+	if _, err := r.db.ExecContext(ctx, query, models.TransferBatchItemStatusFailed, reason, itemID); err != nil {
+		r.logger.WithError(err).Error("Failed to mark transfer batch item failed")
+		return err
+	}
+	return nil
+}
+
+// Fail fails the items of a batch still pending with the reason and records
+// why the batch was not made
+func (r *TransferBatchRepository) Fail(ctx context.Context, id int64, itemReason, batchReason string) error {
+	tx, err := r.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE transfer_batch_items
+		SET status = $1, error = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE batch_id = $3 AND status = $4
+	`
+	if _, err := tx.ExecContext(ctx, query, models.TransferBatchItemStatusFailed, itemReason, id, models.TransferBatchItemStatusPending); err != nil {
+		r.logger.WithError(err).Error("Failed to fail transfer batch items")
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE transfer_batches SET error = $1 WHERE id = $2`, batchReason, id); err != nil {
+		r.logger.WithError(err).Error("Failed to record transfer batch error")
+		return err
+	}
+	if err := r.finalize(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Finalize recounts the batch items and sets the final batch status
+func (r *TransferBatchRepository) Finalize(ctx context.Context, id int64) error {
+	return r.finalize(ctx, r.db, id)
+}
+
+// FinalizeTx recounts the batch items and sets the final batch status within a
+// database transaction
+func (r *TransferBatchRepository) FinalizeTx(ctx context.Context, tx Tx, id int64) error {
+	return r.finalize(ctx, tx, id)
+}
+
+// finalize sets the counts and the status of a batch: completed without failed
+// transfers, failed when none was made or held, completed_with_errors otherwise
+func (r *TransferBatchRepository) finalize(ctx context.Context, q queryer, id int64) error {
+	query := `
+		UPDATE transfer_batches b
+		SET completed_transfers = c.completed,
+			held_transfers = c.held,
+			failed_transfers = c.failed,
+			status = CASE
+				WHEN c.failed = 0 THEN $1
+				WHEN c.completed = 0 AND c.held = 0 THEN $2
+				ELSE $3
+			END,
+			completed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE status = $4) AS completed,
+			       COUNT(*) FILTER (WHERE status = $5) AS held,
+			       COUNT(*) FILTER (WHERE status = $6) AS failed
+			FROM transfer_batch_items
+			WHERE batch_id = $7
+		) c
+		WHERE b.id = $7
+	`
+
+	_, err := q.ExecContext(
+		ctx,
+		query,
+		models.TransferBatchStatusCompleted,
+		models.TransferBatchStatusFailed,
+		models.TransferBatchStatusCompletedWithErrors,
+		models.TransferBatchItemStatusCompleted,
+		models.TransferBatchItemStatusHeld,
+		models.TransferBatchItemStatusFailed,
+		id,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to finalize transfer batch")
+		return err
+	}
+	return nil
+}
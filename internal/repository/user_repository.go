@@ -1,35 +1,74 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
-	"github.com/Abigotado/abi_banking/internal/database"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/pii"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher *pii.Cipher
 }
 
-func NewUserRepository() *UserRepository {
+func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{
-		db: database.DB,
+		db: db,
 	}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
+// SetCipher encrypts the email, names and phone number of users at rest and
+// looks users up by the blind indexes of their email and phone number. Values
+// stored in plain text before keep being read until ReencryptPII encrypts them.
+func (r *UserRepository) SetCipher(c *pii.Cipher) {
+	r.cipher = c
+}
+
+// BeginTransaction starts a new database transaction
+func (r *UserRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.create(ctx, r.db, user)
+}
+
+// CreateTx creates a user within a database transaction
+func (r *UserRepository) CreateTx(ctx context.Context, tx Tx, user *models.User) error {
+	return r.create(ctx, tx, user)
+}
+
+func (r *UserRepository) create(ctx context.Context, q queryer, user *models.User) error {
+	email, err := r.encrypt(ctx, pii.FieldEmail, user.Email)
+	if err != nil {
+		return err
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
 	query := `
-		INSERT INTO users (username, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO users (username, email, email_hash, password, role, branch_id, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id
 	`
 
-	err := r.db.QueryRow(
+	err = q.QueryRowContext(
+		ctx,
 		query,
 		user.Username,
-		user.Email,
+		email,
+		r.emailIndex(user.Email),
 		user.Password,
+		role,
+		user.BranchID,
 	).Scan(&user.ID)
 
 	if err != nil {
@@ -39,19 +78,31 @@ func (r *UserRepository) Create(user *models.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByID(id int64) (*models.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password, created_at, updated_at
+		SELECT id, username, email, password, COALESCE(first_name, ''), COALESCE(last_name, ''),
+			COALESCE(phone_number, ''), role, branch_id, status, kyc_status, closed_at, anonymized_at, locked_until,
+			created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
+		&user.FirstName,
+		&user.LastName,
+		&user.PhoneNumber,
+		&user.Role,
+		&user.BranchID,
+		&user.Status,
+		&user.KYCStatus,
+		&user.ClosedAt,
+		&user.AnonymizedAt,
+		&user.LockedUntil,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -63,22 +114,30 @@ func (r *UserRepository) GetByID(id int64) (*models.User, error) {
 		return nil, err
 	}
 
+	if err := r.decryptUser(ctx, user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password, created_at, updated_at
+		SELECT id, username, email, password, role, branch_id, status, kyc_status, locked_until, created_at, updated_at
 		FROM users
-		WHERE email = $1
+		WHERE email = $1 OR email_hash = $2
 	`
 
-	err := r.db.QueryRow(query, email).Scan(
+	err := r.db.QueryRowContext(ctx, query, email, r.emailIndex(email)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
+		&user.Role,
+		&user.BranchID,
+		&user.Status,
+		&user.KYCStatus,
+		&user.LockedUntil,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -90,18 +149,60 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 		return nil, err
 	}
 
+	if err := r.decryptUser(ctx, user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (r *UserRepository) CheckEmailExists(email string) (bool, error) {
+// GetByPhone retrieves the user with a phone number. When several users share
+// the number, the one registered first is returned.
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	user := &models.User{}
+	query := `
+		SELECT id, username, email, password, role, branch_id, status, kyc_status, locked_until, created_at, updated_at
+		FROM users
+		WHERE phone_number = $1 OR phone_hash = NULLIF($2, '')
+		ORDER BY id
+		LIMIT 1
+	`
+
+	err := r.db.QueryRowContext(ctx, query, phone, r.phoneIndex(phone)).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password,
+		&user.Role,
+		&user.BranchID,
+		&user.Status,
+		&user.KYCStatus,
+		&user.LockedUntil,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	if err := r.decryptUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) CheckEmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := `
 		SELECT EXISTS(
-			SELECT 1 FROM users WHERE email = $1
+			SELECT 1 FROM users WHERE email = $1 OR email_hash = $2
 		)
 	`
 
-	err := r.db.QueryRow(query, email).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, email, r.emailIndex(email)).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
@@ -109,7 +210,7 @@ func (r *UserRepository) CheckEmailExists(email string) (bool, error) {
 	return exists, nil
 }
 
-func (r *UserRepository) CheckUsernameExists(username string) (bool, error) {
+func (r *UserRepository) CheckUsernameExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 	query := `
 		SELECT EXISTS(
@@ -117,10 +218,393 @@ func (r *UserRepository) CheckUsernameExists(username string) (bool, error) {
 		)
 	`
 
-	err := r.db.QueryRow(query, username).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 
 	return exists, nil
 }
+
+// UpdateProfile stores the names and the phone number of a user
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	firstName, err := r.encrypt(ctx, pii.FieldFirstName, user.FirstName)
+	if err != nil {
+		return err
+	}
+	lastName, err := r.encrypt(ctx, pii.FieldLastName, user.LastName)
+	if err != nil {
+		return err
+	}
+	phone, err := r.encrypt(ctx, pii.FieldPhone, user.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET first_name = $1, last_name = $2, phone_number = NULLIF($3, ''), phone_hash = NULLIF($4, ''),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, firstName, lastName, phone, r.phoneIndex(user.PhoneNumber), user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the password hash of a user
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Close marks a user closed. Closing an already closed user fails with "user is
+// already closed".
+func (r *UserRepository) Close(ctx context.Context, id int64, closedAt time.Time) error {
+	query := `
+		UPDATE users
+		SET status = $1, closed_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status <> $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, models.StatusClosed, closedAt, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("user is already closed")
+	}
+
+	return nil
+}
+
+// AnonymizeClosed replaces the personal data of the users closed before the
+// given time with placeholders and returns how many were anonymized. The rows
+// stay, so the accounts, credits and transactions keep their owner.
+func (r *UserRepository) AnonymizeClosed(ctx context.Context, closedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE users
+		SET username = 'deleted-' || id,
+			email = 'deleted-' || id || '@deleted.invalid',
+			email_hash = NULL,
+			password = '',
+			first_name = NULL,
+			last_name = NULL,
+			phone_number = NULL,
+			phone_hash = NULL,
+			anonymized_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE status = $1 AND anonymized_at IS NULL AND closed_at < $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, models.StatusClosed, closedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RecordFailedLoginTx counts a failed login of a user within a database
+// transaction and returns the failures in a row. A failure after the previous
+// one fell out of the window starts the count again.
+func (r *UserRepository) RecordFailedLoginTx(ctx context.Context, tx Tx, id int64, windowStart time.Time) (int, error) {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = CASE
+				WHEN last_failed_login_at > $1 THEN failed_login_attempts + 1
+				ELSE 1
+			END,
+			last_failed_login_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING failed_login_attempts
+	`
+
+	var attempts int
+	if err := tx.QueryRowContext(ctx, query, windowStart, id).Scan(&attempts); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.New("user not found")
+		}
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// LockTx keeps a user from logging in until the given time within a database
+// transaction. The failed logins are counted again from zero.
+func (r *UserRepository) LockTx(ctx context.Context, tx Tx, id int64, until time.Time) error {
+	query := `
+		UPDATE users
+		SET locked_until = $1, failed_login_attempts = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	_, err := tx.ExecContext(ctx, query, until, id)
+	return err
+}
+
+// ResetFailedLogins clears the failed logins of a user after a successful one
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1 AND (failed_login_attempts <> 0 OR locked_until IS NOT NULL)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Unlock lets a locked user log in again and clears the failed logins
+func (r *UserRepository) Unlock(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// ListByBranch retrieves a page of the users of a branch and how many it has
+func (r *UserRepository) ListByBranch(ctx context.Context, branchID int64, p pagination.Params) ([]*models.User, int, error) {
+	columns := `
+		id, username, email, COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(phone_number, ''),
+		role, branch_id, status, kyc_status, created_at
+	`
+	rows, total, err := queryPage(ctx, r.db, columns, "users", "branch_id = $1", p, branchID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.PhoneNumber,
+			&user.Role,
+			&user.BranchID,
+			&user.Status,
+			&user.KYCStatus,
+			&user.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		if err := r.decryptUser(ctx, user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// AssignBranch moves a user to a branch and sets the role of the user in it.
+// The accounts and credits of the user stay booked where they were opened.
+func (r *UserRepository) AssignBranch(ctx context.Context, id, branchID int64, role models.UserRole) error {
+	query := `
+		UPDATE users
+		SET branch_id = $1, role = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, branchID, role, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// ReencryptPII encrypts the personal data of all users again under the active
+// data key, a batch at a time, and returns how many users were rewritten.
+// Values still in plain text are encrypted and get their blind indexes.
+func (r *UserRepository) ReencryptPII(ctx context.Context, batchSize int) (int64, error) {
+	if r.cipher == nil {
+		return 0, errors.New("personal data encryption is not configured")
+	}
+
+	var rewritten int64
+	var afterID int64
+	for {
+		count, lastID, err := r.reencryptBatch(ctx, afterID, batchSize)
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten += count
+		if lastID == 0 {
+			return rewritten, nil
+		}
+		afterID = lastID
+	}
+}
+
+// reencryptBatch rewrites the stale users of a batch after the given ID and
+// returns how many were rewritten and the last ID of the batch, which is zero
+// past the last user. The batch is locked, so no profile update in between is lost.
+func (r *UserRepository) reencryptBatch(ctx context.Context, afterID int64, batchSize int) (int64, int64, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, email, COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(phone_number, ''),
+			email_hash IS NULL
+		FROM users
+		WHERE id > $1 AND anonymized_at IS NULL
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE
+	`
+
+	rows, err := tx.QueryContext(ctx, query, afterID, batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	var users []*models.User
+	var unindexed []bool
+	for rows.Next() {
+		user := &models.User{}
+		var missingIndex bool
+		if err := rows.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.PhoneNumber, &missingIndex); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		users = append(users, user)
+		unindexed = append(unindexed, missingIndex)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(users) == 0 {
+		return 0, 0, nil
+	}
+
+	update := `
+		UPDATE users
+		SET email = $1, email_hash = $2, first_name = NULLIF($3, ''), last_name = NULLIF($4, ''),
+			phone_number = NULLIF($5, ''), phone_hash = NULLIF($6, '')
+		WHERE id = $7
+	`
+
+	var rewritten int64
+	for i, user := range users {
+		if !unindexed[i] && !r.cipher.Stale(user.Email) && !r.cipher.Stale(user.FirstName) &&
+			!r.cipher.Stale(user.LastName) && !r.cipher.Stale(user.PhoneNumber) {
+			continue
+		}
+		if err := r.decryptUser(ctx, user); err != nil {
+			return 0, 0, err
+		}
+
+		values := make([]string, 4)
+		for j, field := range []struct{ name, value string }{
+			{pii.FieldEmail, user.Email},
+			{pii.FieldFirstName, user.FirstName},
+			{pii.FieldLastName, user.LastName},
+			{pii.FieldPhone, user.PhoneNumber},
+		} {
+			if values[j], err = r.cipher.Encrypt(ctx, field.name, field.value); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, update, values[0], r.emailIndex(user.Email), values[1], values[2],
+			values[3], r.phoneIndex(user.PhoneNumber), user.ID); err != nil {
+			return 0, 0, err
+		}
+		rewritten++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return rewritten, users[len(users)-1].ID, nil
+}
+
+// encrypt returns the stored form of a personal value
+func (r *UserRepository) encrypt(ctx context.Context, field, value string) (string, error) {
+	if r.cipher == nil {
+		return value, nil
+	}
+	return r.cipher.Encrypt(ctx, field, value)
+}
+
+// decryptUser replaces the stored personal data of a user with its plain values
+func (r *UserRepository) decryptUser(ctx context.Context, user *models.User) error {
+	if r.cipher == nil {
+		return nil
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{pii.FieldEmail, &user.Email},
+		{pii.FieldFirstName, &user.FirstName},
+		{pii.FieldLastName, &user.LastName},
+		{pii.FieldPhone, &user.PhoneNumber},
+	} {
+		plain, err := r.cipher.Decrypt(ctx, field.name, *field.value)
+		if err != nil {
+			return err
+		}
+		*field.value = plain
+	}
+	return nil
+}
+
+// emailIndex returns the blind index of an email, empty without a cipher
+func (r *UserRepository) emailIndex(email string) string {
+	if r.cipher == nil {
+		return ""
+	}
+	return r.cipher.EmailIndex(email)
+}
+
+// phoneIndex returns the blind index of a phone number, empty without a cipher
+func (r *UserRepository) phoneIndex(phone string) string {
+	if r.cipher == nil {
+		return ""
+	}
+	return r.cipher.PhoneIndex(phone)
+}
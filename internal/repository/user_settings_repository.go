@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// UserSettingsRepository handles database operations for user settings
+type UserSettingsRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewUserSettingsRepository creates a new UserSettingsRepository instance
+func NewUserSettingsRepository(db *sql.DB, logger *logrus.Logger) *UserSettingsRepository {
+	return &UserSettingsRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByUserID retrieves the settings of a user, or the defaults when the user
+// has not changed them
+func (r *UserSettingsRepository) GetByUserID(ctx context.Context, userID int64) (*models.UserSettings, error) {
+	query := `
+		SELECT id, user_id, email_notifications, sms_notifications, language, timezone,
+			monthly_statements, updated_at
+		FROM user_settings
+		WHERE user_id = $1
+	`
+
+	settings := &models.UserSettings{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&settings.ID,
+		&settings.UserID,
+		&settings.EmailNotifications,
+		&settings.SMSNotifications,
+		&settings.Language,
+		&settings.TimeZone,
+		&settings.MonthlyStatements,
+		&settings.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DefaultUserSettings(userID), nil
+		}
+		r.logger.WithError(err).Error("Failed to get user settings")
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// Upsert stores the settings of a user
+func (r *UserSettingsRepository) Upsert(ctx context.Context, settings *models.UserSettings) error {
+	return r.upsert(ctx, r.db, settings)
+}
+
+// UpsertTx stores the settings of a user within a database transaction
+func (r *UserSettingsRepository) UpsertTx(ctx context.Context, tx Tx, settings *models.UserSettings) error {
+	return r.upsert(ctx, tx, settings)
+}
+
+func (r *UserSettingsRepository) upsert(ctx context.Context, q queryer, settings *models.UserSettings) error {
+	query := `
+		INSERT INTO user_settings (
+			user_id, email_notifications, sms_notifications, language, timezone,
+			monthly_statements, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email_notifications = EXCLUDED.email_notifications,
+			sms_notifications = EXCLUDED.sms_notifications,
+			language = EXCLUDED.language,
+			timezone = EXCLUDED.timezone,
+			monthly_statements = EXCLUDED.monthly_statements,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, updated_at
+	`
+
+	err := q.QueryRowContext(
+		ctx,
+		query,
+		settings.UserID,
+		settings.EmailNotifications,
+		settings.SMSNotifications,
+		settings.Language,
+		settings.TimeZone,
+		settings.MonthlyStatements,
+	).Scan(&settings.ID, &settings.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to store user settings")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookRepository handles database operations for partner webhook subscriptions,
+// their events and deliveries
+type WebhookRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+// NewWebhookRepository creates a new WebhookRepository instance
+func NewWebhookRepository(db *sql.DB, logger *logrus.Logger) *WebhookRepository {
+	return &WebhookRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BeginTransaction starts a new database transaction
+func (r *WebhookRepository) BeginTransaction(ctx context.Context) (Tx, error) {
+	return beginTx(ctx, r.db)
+}
+
+// CreateSubscription stores a new webhook subscription
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (user_id, url, secret, event_types, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sub.UserID,
+		sub.URL,
+		sub.Secret,
+		pq.Array(sub.EventTypes),
+		sub.Active,
+	).Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create webhook subscription")
+		return err
+	}
+
+	return nil
+}
+
+const webhookSubscriptionColumns = `
+	id, user_id, url, secret, event_types, active, created_at, updated_at
+`
+
+// GetSubscriptionByID retrieves a webhook subscription by its ID
+func (r *WebhookRepository) GetSubscriptionByID(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`
+
+	sub, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook subscription not found")
+		}
+		r.logger.WithError(err).Error("Failed to get webhook subscription")
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// GetSubscriptionsByUserID retrieves the webhook subscriptions of a user
+func (r *WebhookRepository) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]*models.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get webhook subscriptions")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to scan webhook subscription row")
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func scanWebhookSubscription(scanner interface{ Scan(...interface{}) error }) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{}
+	if err := scanner.Scan(
+		&sub.ID,
+		&sub.UserID,
+		&sub.URL,
+		&sub.Secret,
+		pq.Array(&sub.EventTypes),
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// CreateEventTx stores a published event within a database transaction
+func (r *WebhookRepository) CreateEventTx(ctx context.Context, tx Tx, event *models.WebhookEvent) error {
+	query := `
+		INSERT INTO webhook_events (user_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, event.UserID, event.Type, []byte(event.Payload)).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create webhook event")
+		return err
+	}
+
+	return nil
+}
+
+// GetEventByID retrieves a webhook event by its ID
+func (r *WebhookRepository) GetEventByID(ctx context.Context, id int64) (*models.WebhookEvent, error) {
+	query := `
+		SELECT id, user_id, event_type, payload, created_at
+		FROM webhook_events
+		WHERE id = $1
+	`
+
+	event := &models.WebhookEvent{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&event.ID,
+		&event.UserID,
+		&event.Type,
+		&event.Payload,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook event not found")
+		}
+		r.logger.WithError(err).Error("Failed to get webhook event")
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// CreateDeliveryTx stores a pending delivery within a database transaction
+func (r *WebhookRepository) CreateDeliveryTx(ctx context.Context, tx Tx, delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, status, replay, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		delivery.SubscriptionID,
+		delivery.EventID,
+		delivery.Status,
+		delivery.Replay,
+	).Scan(&delivery.ID, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create webhook delivery")
+		return err
+	}
+
+	return nil
+}
+
+// GetDeliveryByID retrieves a webhook delivery by its ID
+func (r *WebhookRepository) GetDeliveryByID(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	query := `
+		SELECT id, subscription_id, event_id, status, replay, attempts, response_status,
+			COALESCE(last_error, ''), delivered_at, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE id = $1
+	`
+
+	delivery := &models.WebhookDelivery{}
+	var responseStatus sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.Status,
+		&delivery.Replay,
+		&delivery.Attempts,
+		&responseStatus,
+		&delivery.LastError,
+		&deliveredAt,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook delivery not found")
+		}
+		r.logger.WithError(err).Error("Failed to get webhook delivery")
+		return nil, err
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return delivery, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, last_error = $4,
+			delivered_at = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+	`
+
+	var responseStatus sql.NullInt64
+	if delivery.ResponseStatus != nil {
+		responseStatus = sql.NullInt64{Int64: int64(*delivery.ResponseStatus), Valid: true}
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		delivery.Status,
+		delivery.Attempts,
+		responseStatus,
+		sql.NullString{String: delivery.LastError, Valid: delivery.LastError != ""},
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update webhook delivery")
+		return err
+	}
+
+	return nil
+}
+
+// GetSubscriptionEvents retrieves the events sent to a subscription within a time
+// range, newest first, together with the outcome of their latest delivery
+func (r *WebhookRepository) GetSubscriptionEvents(ctx context.Context, subscriptionID int64, from, to time.Time, limit int) ([]*models.WebhookEventHistory, error) {
+	query := `
+		SELECT e.id, e.user_id, e.event_type, e.payload, e.created_at,
+			d.deliveries, d.status, d.updated_at
+		FROM webhook_events e
+		JOIN LATERAL (
+			SELECT COUNT(*) OVER () AS deliveries, status, updated_at
+			FROM webhook_deliveries
+			WHERE subscription_id = $1 AND event_id = e.id
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) d ON TRUE
+		WHERE e.created_at >= $2 AND e.created_at <= $3
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionID, from, to, limit)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to get webhook subscription events")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.WebhookEventHistory
+	for rows.Next() {
+		event := &models.WebhookEventHistory{}
+		var lastDeliveryAt time.Time
+		if err := rows.Scan(
+			&event.ID,
+			&event.UserID,
+			&event.Type,
+			&event.Payload,
+			&event.CreatedAt,
+			&event.Deliveries,
+			&event.LastStatus,
+			&lastDeliveryAt,
+		); err != nil {
+			r.logger.WithError(err).Error("Failed to scan webhook event row")
+			return nil, err
+		}
+		event.LastDeliveryAt = &lastDeliveryAt
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -4,9 +4,12 @@ import (
 	"net/http"
 
 	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/graphql"
 	"github.com/Abigotado/abi_banking/internal/handlers"
+	"github.com/Abigotado/abi_banking/internal/metrics"
 	"github.com/Abigotado/abi_banking/internal/middleware"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/secrets"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
@@ -14,40 +17,232 @@ import (
 func NewRouter(
 	cfg *config.Config,
 	handlers *handlers.Handlers,
+	rateLimitStore middleware.RateLimitStore,
+	jwtSecret *secrets.Secret,
 	logger *logrus.Logger,
 ) http.Handler {
 	router := mux.NewRouter()
 
+	// With mutual TLS, admin and internal routes only serve clients with a
+	// certificate issued by the configured client CA
+	internal := func(h http.Handler) http.Handler { return h }
+	if cfg.Server.MutualTLSEnabled() {
+		internal = middleware.RequireClientCertificate()
+	}
+
+	if cfg.API.SecurityHeaders {
+		router.Use(middleware.SecurityHeaders(cfg.API.HSTSMaxAge))
+	}
+
 	// Apply global middleware
 	router.Use(
+		middleware.Metrics(),
 		middleware.Logging(logger),
 		middleware.Recovery(logger),
+		middleware.Timeout(cfg.Server.WriteTimeout, cfg.API.Prefix+"/stream"),
 		middleware.CORS(cfg.API.CORSAllowedOrigins),
 		middleware.RequestID(),
-		middleware.RateLimiter(cfg.RateLimit.RequestsPerHour),
-		middleware.ContentType("application/json"),
+		middleware.ClientInfo(cfg.Fraud.ClientIPHeader, cfg.Fraud.CountryHeader),
+		middleware.ContentType("application/json", "text/csv", "multipart/form-data"),
+		// Identity documents, the files of disputes and collateral documents are
+		// uploaded as multipart forms, with room for the form fields around the
+		// largest upload
+		middleware.BodyLimit(int64(cfg.API.MaxBodySize), map[string]int64{
+			"multipart/form-data": maxUploadSize(cfg) + 64<<10,
+		}),
+		middleware.CSRF(middleware.CSRFConfig{
+			SessionCookie: cfg.API.CSRFSessionCookie,
+			TokenCookie:   cfg.API.CSRFTokenCookie,
+			TokenHeader:   cfg.API.CSRFTokenHeader,
+		}),
 	)
 
+	// Health checks
+	router.HandleFunc("/health/live", handlers.LivenessHandler).Methods("GET")
+	router.HandleFunc("/health/ready", handlers.ReadinessHandler).Methods("GET")
+
+	// Prometheus metrics
+	router.Handle("/metrics", internal(metrics.Handler())).Methods("GET")
+
+	// API documentation
+	router.HandleFunc("/docs", handlers.SwaggerUIHandler).Methods("GET")
+
+	// Admin dashboard page; its data comes from the admin API, which checks the role
+	router.Handle("/admin/ui", http.RedirectHandler("/admin/ui/", http.StatusMovedPermanently)).Methods("GET")
+	router.PathPrefix("/admin/ui/").Handler(internal(http.HandlerFunc(handlers.AdminUIHandler))).Methods("GET")
+
 	// API version prefix
 	apiRouter := router.PathPrefix(cfg.API.Prefix).Subrouter()
 
+	// Rate limiting of the API; health checks, metrics and documentation are
+	// left out so probes and scrapes are never refused
+	if cfg.RateLimit.Enabled {
+		endpoints := make(map[string]middleware.RateLimitPolicy, len(cfg.RateLimit.Policies))
+		for endpoint, policy := range cfg.RateLimit.Policies {
+			endpoints[endpoint] = middleware.RateLimitPolicy{RequestsPerHour: policy.RequestsPerHour, Burst: policy.BurstSize}
+		}
+		apiRouter.Use(middleware.RateLimiter(
+			rateLimitStore,
+			middleware.RateLimitPolicy{RequestsPerHour: cfg.RateLimit.RequestsPerHour, Burst: cfg.RateLimit.BurstSize},
+			endpoints,
+			jwtSecret,
+			logger,
+		))
+	}
+
+	apiRouter.HandleFunc("/openapi.json", handlers.OpenAPIHandler).Methods("GET")
+
 	// Public routes
 	public := apiRouter.PathPrefix("/public").Subrouter()
 	public.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
 	public.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
+	public.HandleFunc("/branches", handlers.ListBranchesHandler).Methods("GET")
+	public.HandleFunc("/receipts/verify", handlers.VerifyReceiptHandler).Methods("GET")
+
+	// Files of the local object storage, authenticated by the signature of the URL
+	apiRouter.HandleFunc("/files/{key:.+}", handlers.GetFileHandler).Methods("GET")
+
+	// Payment rail integration, authenticated by the message signature instead of a user token
+	railSigned := middleware.RailSignature(cfg.IncomingPayments.Secret, cfg.IncomingPayments.MaxClockSkew)
+	apiRouter.HandleFunc("/integrations/incoming-payments", railSigned(middleware.ValidateRequest(&models.IncomingPaymentRequest{})(handlers.IncomingPaymentHandler))).Methods("POST")
+
+	// Card payments requested by the acquirer, signed the same way with the acquirer's key
+	acquirerSigned := middleware.RailSignature(cfg.Acquiring.Secret, cfg.Acquiring.MaxClockSkew)
+	apiRouter.HandleFunc("/cards/authorize", acquirerSigned(middleware.ValidateRequest(&models.CardAuthorizationRequest{})(handlers.AuthorizeCardPaymentHandler))).Methods("POST")
+	apiRouter.HandleFunc("/cards/authorizations/{id}", acquirerSigned(handlers.GetCardAuthorizationHandler)).Methods("GET")
+	apiRouter.HandleFunc("/cards/authorizations/{id}/capture", acquirerSigned(handlers.CaptureCardAuthorizationHandler)).Methods("POST")
+	apiRouter.HandleFunc("/cards/authorizations/{id}/void", acquirerSigned(handlers.VoidCardAuthorizationHandler)).Methods("POST")
 
 	// Protected routes
 	protected := apiRouter.PathPrefix("/").Subrouter()
-	protected.Use(middleware.Auth(cfg.JWT.Secret))
+	var apiKeyLimits middleware.RateLimitStore
+	if cfg.RateLimit.Enabled {
+		apiKeyLimits = rateLimitStore
+	}
+	protected.Use(middleware.APIKeyAuth(
+		handlers.APIKeyService(), middleware.Auth(jwtSecret, handlers.UserService()), apiKeyLimits, logger,
+	))
+
+	// Replay protection for money-moving routes. A nonce stays cached until a
+	// request stamped at the far edge of the clock skew window would expire.
+	nonceCache := middleware.NewNonceCache(2 * cfg.Replay.MaxClockSkew)
+	payments := middleware.ReplayProtection(cfg.Replay.Payments, nonceCache, cfg.Replay.MaxClockSkew)
+	lending := middleware.ReplayProtection(cfg.Replay.Lending, nonceCache, cfg.Replay.MaxClockSkew)
+
+	// User routes
+	userRouter := protected.PathPrefix("/users").Subrouter()
+	userRouter.HandleFunc("/me", handlers.GetProfileHandler).Methods("GET")
+	userRouter.HandleFunc("/me", handlers.UpdateProfileHandler).Methods("PUT")
+	userRouter.HandleFunc("/me", handlers.CloseProfileHandler).Methods("DELETE")
+	userRouter.HandleFunc("/me/password", handlers.ChangePasswordHandler).Methods("POST")
+	userRouter.HandleFunc("/me/sessions", handlers.GetSessionsHandler).Methods("GET")
+	userRouter.HandleFunc("/me/sessions/{id}", handlers.RevokeSessionHandler).Methods("DELETE")
+	userRouter.HandleFunc("/me/credit-health", handlers.GetCreditHealthHandler).Methods("GET")
+	userRouter.HandleFunc("/me/credit-score", handlers.GetCreditScoreHandler).Methods("GET")
+	userRouter.HandleFunc("/me/limits", handlers.GetMyLimitsHandler).Methods("GET")
+	userRouter.HandleFunc("/me/settings", handlers.GetSettingsHandler).Methods("GET")
+	userRouter.HandleFunc("/me/settings", handlers.UpdateSettingsHandler).Methods("PUT")
+	userRouter.HandleFunc("/me/alerts", middleware.ValidateRequest(&models.CreateAlertRequest{})(handlers.CreateAlertHandler)).Methods("POST")
+	userRouter.HandleFunc("/me/alerts", handlers.ListAlertsHandler).Methods("GET")
+	userRouter.HandleFunc("/me/alerts/{id}", handlers.GetAlertHandler).Methods("GET")
+	userRouter.HandleFunc("/me/alerts/{id}", middleware.ValidateRequest(&models.UpdateAlertRequest{})(handlers.UpdateAlertHandler)).Methods("PUT")
+	userRouter.HandleFunc("/me/alerts/{id}", handlers.DeleteAlertHandler).Methods("DELETE")
+	userRouter.HandleFunc("/me/kyc", handlers.GetKYCHandler).Methods("GET")
+	userRouter.HandleFunc("/me/kyc/documents", handlers.UploadKYCDocumentHandler).Methods("POST")
+	userRouter.HandleFunc("/me/kyc/submit", handlers.SubmitKYCHandler).Methods("POST")
+	userRouter.HandleFunc("/me/statements", handlers.ListStatementsHandler).Methods("GET")
+	userRouter.HandleFunc("/me/statements/{id}/url", handlers.GetStatementURLHandler).Methods("GET")
 
 	// Account routes
 	accountRouter := protected.PathPrefix("/accounts").Subrouter()
 	accountRouter.HandleFunc("", middleware.ValidateRequest(&models.CreateAccountRequest{})(handlers.CreateAccountHandler)).Methods("POST")
+	accountRouter.HandleFunc("/lookup", handlers.LookupAccountHandler).Methods("GET")
+	accountRouter.HandleFunc("/invitations", handlers.GetAccountInvitationsHandler).Methods("GET")
+	accountRouter.HandleFunc("/invitations/{id}/accept", handlers.AcceptAccountInvitationHandler).Methods("POST")
+	accountRouter.HandleFunc("/invitations/{id}/decline", handlers.DeclineAccountInvitationHandler).Methods("POST")
 	accountRouter.HandleFunc("/{id}", handlers.GetAccountHandler).Methods("GET")
+	accountRouter.HandleFunc("/{id}", handlers.UpdateAccountHandler).Methods("PATCH")
 	accountRouter.HandleFunc("/user/{user_id}", handlers.GetUserAccountsHandler).Methods("GET")
-	accountRouter.HandleFunc("/transfer", middleware.ValidateRequest(&models.TransferRequest{})(handlers.TransferHandler)).Methods("POST")
-	accountRouter.HandleFunc("/{id}/deposit", middleware.ValidateRequest(&models.DepositRequest{})(handlers.DepositHandler)).Methods("POST")
-	accountRouter.HandleFunc("/{id}/withdraw", middleware.ValidateRequest(&models.WithdrawRequest{})(handlers.WithdrawHandler)).Methods("POST")
+	accountRouter.HandleFunc("/transfer", payments(middleware.ValidateRequest(&models.TransferRequest{})(handlers.TransferHandler))).Methods("POST")
+	accountRouter.HandleFunc("/deposit", payments(middleware.ValidateRequest(&models.DepositRequest{})(handlers.DepositHandler))).Methods("POST")
+	accountRouter.HandleFunc("/{id}/deposit", payments(middleware.ValidateRequest(&models.DepositRequest{})(handlers.DepositHandler))).Methods("POST")
+	accountRouter.HandleFunc("/{id}/withdraw", payments(middleware.ValidateRequest(&models.WithdrawRequest{})(handlers.WithdrawHandler))).Methods("POST")
+	accountRouter.HandleFunc("/{id}/close", payments(handlers.CloseAccountHandler)).Methods("POST")
+	accountRouter.HandleFunc("/{id}/reactivate", handlers.ReactivateAccountHandler).Methods("POST")
+	accountRouter.HandleFunc("/{id}/statement", handlers.GetAccountStatementHandler).Methods("GET")
+	accountRouter.HandleFunc("/{id}/statement", handlers.QueueAccountStatementHandler).Methods("POST")
+	accountRouter.HandleFunc("/{id}/qr", handlers.GetAccountQRHandler).Methods("GET")
+	accountRouter.HandleFunc("/{id}/members", handlers.GetAccountMembersHandler).Methods("GET")
+	accountRouter.HandleFunc("/{id}/members", handlers.InviteAccountMemberHandler).Methods("POST")
+	accountRouter.HandleFunc("/{id}/members/{user_id}", handlers.UpdateAccountMemberHandler).Methods("PUT")
+	accountRouter.HandleFunc("/{id}/members/{user_id}", handlers.RemoveAccountMemberHandler).Methods("DELETE")
+
+	// Transfers to other banks
+	externalRouter := protected.PathPrefix("/transfers/external").Subrouter()
+	externalRouter.HandleFunc("", payments(middleware.ValidateRequest(&models.CreateExternalTransferRequest{})(handlers.CreateExternalTransferHandler))).Methods("POST")
+	externalRouter.HandleFunc("", handlers.GetExternalTransfersHandler).Methods("GET")
+	externalRouter.HandleFunc("/{id}", handlers.GetExternalTransferHandler).Methods("GET")
+
+	// Transfers to the payment details of scanned QR codes
+	protected.HandleFunc("/transfers/qr", payments(middleware.ValidateRequest(&models.QRTransferRequest{})(handlers.QRTransferHandler))).Methods("POST")
+
+	// Progress of long-running operations
+	protected.HandleFunc("/jobs/{id}", handlers.GetJobHandler).Methods("GET")
+
+	// Batches of transfers from one account, such as payroll payouts
+	protected.HandleFunc("/transfers/batch", payments(handlers.CreateTransferBatchHandler)).Methods("POST")
+	protected.HandleFunc("/transfers/batch/{id}", handlers.GetTransferBatchHandler).Methods("GET")
+
+	// Bill payments to the billers of the catalog
+	protected.HandleFunc("/billers", handlers.ListBillersHandler).Methods("GET")
+	protected.HandleFunc("/billers/{id}", handlers.GetBillerHandler).Methods("GET")
+	billPaymentRouter := protected.PathPrefix("/payments/bills").Subrouter()
+	billPaymentRouter.HandleFunc("", payments(middleware.ValidateRequest(&models.CreateBillPaymentRequest{})(handlers.PayBillHandler))).Methods("POST")
+	billPaymentRouter.HandleFunc("", handlers.ListBillPaymentsHandler).Methods("GET")
+	billPaymentRouter.HandleFunc("/{id}", handlers.GetBillPaymentHandler).Methods("GET")
+
+	// Term deposits
+	depositRouter := protected.PathPrefix("/deposits").Subrouter()
+	depositRouter.HandleFunc("", payments(middleware.ValidateRequest(&models.CreateDepositRequest{})(handlers.OpenDepositHandler))).Methods("POST")
+	depositRouter.HandleFunc("", handlers.ListDepositsHandler).Methods("GET")
+	depositRouter.HandleFunc("/{id}", handlers.GetDepositHandler).Methods("GET")
+	depositRouter.HandleFunc("/{id}/withdraw", payments(handlers.WithdrawDepositHandler)).Methods("POST")
+
+	// Monthly budgets
+	budgetRouter := protected.PathPrefix("/budgets").Subrouter()
+	budgetRouter.HandleFunc("", middleware.ValidateRequest(&models.BudgetRequest{})(handlers.CreateBudgetHandler)).Methods("POST")
+	budgetRouter.HandleFunc("", handlers.ListBudgetsHandler).Methods("GET")
+	budgetRouter.HandleFunc("/{id}", handlers.GetBudgetHandler).Methods("GET")
+	budgetRouter.HandleFunc("/{id}", middleware.ValidateRequest(&models.UpdateBudgetRequest{})(handlers.UpdateBudgetHandler)).Methods("PUT")
+	budgetRouter.HandleFunc("/{id}", handlers.DeleteBudgetHandler).Methods("DELETE")
+
+	// Saved payees
+	payeeRouter := protected.PathPrefix("/payees").Subrouter()
+	payeeRouter.HandleFunc("", handlers.CreatePayeeHandler).Methods("POST")
+	payeeRouter.HandleFunc("", handlers.ListPayeesHandler).Methods("GET")
+	payeeRouter.HandleFunc("/{id}", handlers.GetPayeeHandler).Methods("GET")
+	payeeRouter.HandleFunc("/{id}", handlers.UpdatePayeeHandler).Methods("PUT")
+	payeeRouter.HandleFunc("/{id}", handlers.DeletePayeeHandler).Methods("DELETE")
+	payeeRouter.HandleFunc("/{id}/transfer", payments(handlers.PayeeTransferHandler)).Methods("POST")
+
+	// Requests for money between users
+	paymentRequestRouter := protected.PathPrefix("/payment-requests").Subrouter()
+	paymentRequestRouter.HandleFunc("", handlers.CreatePaymentRequestHandler).Methods("POST")
+	paymentRequestRouter.HandleFunc("", handlers.ListPaymentRequestsHandler).Methods("GET")
+	paymentRequestRouter.HandleFunc("/sent", handlers.ListSentPaymentRequestsHandler).Methods("GET")
+	paymentRequestRouter.HandleFunc("/{id}", handlers.GetPaymentRequestHandler).Methods("GET")
+	paymentRequestRouter.HandleFunc("/{id}/accept", payments(handlers.AcceptPaymentRequestHandler)).Methods("POST")
+	paymentRequestRouter.HandleFunc("/{id}/decline", handlers.DeclinePaymentRequestHandler).Methods("POST")
+	paymentRequestRouter.HandleFunc("/{id}/cancel", handlers.CancelPaymentRequestHandler).Methods("POST")
+
+	// Transaction routes
+	protected.HandleFunc("/transactions/{id}", handlers.GetTransactionHandler).Methods("GET")
+	protected.HandleFunc("/transactions/{id}/receipt", handlers.GetTransactionReceiptHandler).Methods("GET")
+	protected.HandleFunc("/transactions/{id}/dispute", handlers.CreateDisputeHandler).Methods("POST")
+
+	// Dispute routes
+	protected.HandleFunc("/disputes", handlers.ListDisputesHandler).Methods("GET")
+	protected.HandleFunc("/disputes/{id}", handlers.GetDisputeHandler).Methods("GET")
 
 	// Card routes
 	cardRouter := protected.PathPrefix("/cards").Subrouter()
@@ -56,20 +251,180 @@ func NewRouter(
 	cardRouter.HandleFunc("/user/{user_id}", handlers.GetUserCardsHandler).Methods("GET")
 	cardRouter.HandleFunc("/{id}/block", handlers.BlockCardHandler).Methods("POST")
 	cardRouter.HandleFunc("/{id}/unblock", handlers.UnblockCardHandler).Methods("POST")
+	cardRouter.HandleFunc("/{id}/reissue", handlers.ReissueCardHandler).Methods("POST")
 	cardRouter.HandleFunc("/{id}", handlers.DeleteCardHandler).Methods("DELETE")
+	cardRouter.HandleFunc("/{id}/fx-quote", handlers.QuoteCardPurchaseHandler).Methods("GET")
+	cardRouter.HandleFunc("/{id}/purchases", payments(handlers.CardPurchaseHandler)).Methods("POST")
 
 	// Credit routes
 	creditRouter := protected.PathPrefix("/credits").Subrouter()
-	creditRouter.HandleFunc("", middleware.ValidateRequest(&models.CreateCreditRequest{})(handlers.CreateCreditHandler)).Methods("POST")
+	creditRouter.HandleFunc("/applications", lending(handlers.CreateCreditApplicationHandler)).Methods("POST")
+	creditRouter.HandleFunc("/applications", handlers.GetUserCreditApplicationsHandler).Methods("GET")
+	creditRouter.HandleFunc("/applications/{id}", handlers.GetCreditApplicationHandler).Methods("GET")
+	creditRouter.HandleFunc("/preview", handlers.GetCreditPreviewHandler).Methods("GET")
 	creditRouter.HandleFunc("/{id}", handlers.GetCreditHandler).Methods("GET")
 	creditRouter.HandleFunc("/user/{user_id}", handlers.GetUserCreditsHandler).Methods("GET")
 	creditRouter.HandleFunc("/{id}/schedule", handlers.GetPaymentScheduleHandler).Methods("GET")
-	creditRouter.HandleFunc("/{id}/pay", middleware.ValidateRequest(&models.PayCreditRequest{})(handlers.PayCreditHandler)).Methods("POST")
+	creditRouter.HandleFunc("/{id}/pay", payments(middleware.ValidateRequest(&models.PayCreditRequest{})(handlers.PayCreditHandler))).Methods("POST")
+	creditRouter.HandleFunc("/{id}/repay-early", payments(handlers.RepayCreditEarlyHandler)).Methods("POST")
+	creditRouter.HandleFunc("/{id}/restructure", lending(handlers.RestructureCreditHandler)).Methods("POST")
+	creditRouter.HandleFunc("/{id}/restructurings", handlers.GetCreditRestructuringsHandler).Methods("GET")
+	creditRouter.HandleFunc("/{id}/rate-changes", handlers.GetCreditRateChangesHandler).Methods("GET")
+	creditRouter.HandleFunc("/{id}/insurance", handlers.GetCreditInsuranceHandler).Methods("GET")
+	creditRouter.HandleFunc("/{id}/insurance/claims", lending(handlers.FileCreditInsuranceClaimHandler)).Methods("POST")
+	creditRouter.HandleFunc("/{id}/collateral", handlers.GetCreditCollateralHandler).Methods("GET")
 
 	// Analytics routes
 	analyticsRouter := protected.PathPrefix("/analytics").Subrouter()
 	analyticsRouter.HandleFunc("/transactions", handlers.GetTransactionAnalyticsHandler).Methods("GET")
 	analyticsRouter.HandleFunc("/credits", handlers.GetCreditAnalyticsHandler).Methods("GET")
+	analyticsRouter.HandleFunc("/forecast", handlers.GetForecastHandler).Methods("GET")
+
+	// Event stream of the user's accounts and cards
+	if cfg.Stream.Enabled {
+		protected.HandleFunc("/stream", handlers.StreamHandler).Methods("GET")
+	}
+
+	// GraphQL API over accounts, transactions and credits; mutations move money,
+	// so they are replay protected as the REST payment routes are
+	protected.HandleFunc("/graphql", graphql.GuardMutations(payments)(handlers.GraphQLHandler)).Methods("POST")
+	protected.HandleFunc("/graphql/schema", handlers.GraphQLSchemaHandler).Methods("GET")
+
+	// Rate routes
+	rateRouter := protected.PathPrefix("/rates").Subrouter()
+	rateRouter.HandleFunc("", handlers.GetRatesHandler).Methods("GET")
+	rateRouter.HandleFunc("/key-rate", handlers.GetKeyRateHandler).Methods("GET")
+
+	// Webhook routes
+	webhookRouter := protected.PathPrefix("/webhooks").Subrouter()
+	webhookRouter.HandleFunc("/subscriptions", handlers.CreateWebhookSubscriptionHandler).Methods("POST")
+	webhookRouter.HandleFunc("/subscriptions", handlers.GetWebhookSubscriptionsHandler).Methods("GET")
+	webhookRouter.HandleFunc("/subscriptions/{id}/events", handlers.GetWebhookEventsHandler).Methods("GET")
+	webhookRouter.HandleFunc("/subscriptions/{id}/events/{event_id}/replay", handlers.ReplayWebhookEventHandler).Methods("POST")
+	webhookRouter.HandleFunc("/subscriptions/{id}/replay", handlers.ReplayWebhookEventsHandler).Methods("POST")
+	webhookRouter.HandleFunc("/verify-signature", handlers.VerifyWebhookSignatureHandler).Methods("POST")
+
+	// Admin search is also open to support staff, who get personal data redacted.
+	// It is registered ahead of the admin subrouter, which would otherwise claim it.
+	protected.Handle("/admin/search", internal(middleware.RequireRole(models.RoleAdmin, models.RoleSupport)(http.HandlerFunc(handlers.AdminSearchHandler)))).Methods("GET")
+
+	// Branch reports and users are also open to branch administrators, who only
+	// see their own branch. Registered ahead of the admin subrouter as well.
+	branchAdmin := func(handler http.HandlerFunc) http.Handler {
+		return internal(middleware.RequireRole(models.RoleAdmin, models.RoleBranchAdmin)(handler))
+	}
+	protected.Handle("/admin/branches/{id}/report", branchAdmin(handlers.GetBranchReportHandler)).Methods("GET")
+	protected.Handle("/admin/branches/{id}/users", branchAdmin(handlers.GetBranchUsersHandler)).Methods("GET")
+
+	// AML flags and reports are reviewed by compliance staff as well as
+	// administrators. Registered ahead of the admin subrouter as well.
+	compliance := func(handler http.HandlerFunc) http.Handler {
+		return internal(middleware.RequireRole(models.RoleAdmin, models.RoleCompliance)(handler))
+	}
+	protected.Handle("/admin/aml/flags", compliance(handlers.ListAMLFlagsHandler)).Methods("GET")
+	protected.Handle("/admin/aml/flags/{id}", compliance(handlers.GetAMLFlagHandler)).Methods("GET")
+	protected.Handle("/admin/aml/flags/{id}/take", compliance(handlers.TakeAMLFlagHandler)).Methods("POST")
+	protected.Handle("/admin/aml/flags/{id}/report", compliance(handlers.ReportAMLFlagHandler)).Methods("POST")
+	protected.Handle("/admin/aml/flags/{id}/dismiss", compliance(handlers.DismissAMLFlagHandler)).Methods("POST")
+	protected.Handle("/admin/aml/report", compliance(handlers.GetAMLReportHandler)).Methods("GET")
+
+	// Sandbox mode; the reset wipes every table, so both routes are kept to
+	// admins like the admin routes
+	if cfg.Sandbox.Enabled {
+		sandbox := func(handler http.HandlerFunc) http.Handler {
+			return internal(middleware.RequireRole(models.RoleAdmin)(handler))
+		}
+		protected.Handle("/sandbox/emails", sandbox(handlers.ListSandboxEmailsHandler)).Methods("GET")
+		protected.Handle("/sandbox/reset", sandbox(handlers.ResetSandboxHandler)).Methods("POST")
+	}
+
+	// Admin routes
+	adminRouter := protected.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(internal, middleware.RequireRole(models.RoleAdmin))
+	adminRouter.HandleFunc("/ownership-transfers", handlers.CreateOwnershipTransferHandler).Methods("POST")
+	adminRouter.HandleFunc("/ownership-transfers", handlers.ListOwnershipTransfersHandler).Methods("GET")
+	adminRouter.HandleFunc("/ownership-transfers/{id}", handlers.GetOwnershipTransferHandler).Methods("GET")
+	adminRouter.HandleFunc("/ownership-transfers/{id}/approve", handlers.ApproveOwnershipTransferHandler).Methods("POST")
+	adminRouter.HandleFunc("/ownership-transfers/{id}/reject", handlers.RejectOwnershipTransferHandler).Methods("POST")
+	adminRouter.HandleFunc("/accounts/{id}/ownership-history", handlers.GetAccountOwnershipHistoryHandler).Methods("GET")
+	adminRouter.HandleFunc("/external-transfers/{id}/return", handlers.ReturnExternalTransferHandler).Methods("POST")
+	adminRouter.HandleFunc("/incoming-payments", handlers.ListIncomingPaymentsHandler).Methods("GET")
+	adminRouter.HandleFunc("/accounts/{id}/overdraft", handlers.SetOverdraftHandler).Methods("PUT")
+	adminRouter.HandleFunc("/users/{id}/export", handlers.ExportUserDataHandler).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/export", handlers.QueueUserDataExportHandler).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/unlock", handlers.UnlockUserHandler).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/branch", handlers.AssignUserBranchHandler).Methods("PUT")
+	adminRouter.HandleFunc("/branches", handlers.AdminListBranchesHandler).Methods("GET")
+	adminRouter.HandleFunc("/branches", handlers.CreateBranchHandler).Methods("POST")
+	adminRouter.HandleFunc("/branches/{id}", handlers.UpdateBranchHandler).Methods("PUT")
+	adminRouter.HandleFunc("/billers", handlers.AdminListBillersHandler).Methods("GET")
+	adminRouter.HandleFunc("/billers", handlers.CreateBillerHandler).Methods("POST")
+	adminRouter.HandleFunc("/billers/{id}", handlers.UpdateBillerHandler).Methods("PUT")
+	adminRouter.HandleFunc("/users/{id}/limits", handlers.GetUserLimitsHandler).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/limits", handlers.SetUserLimitsHandler).Methods("PUT")
+	adminRouter.HandleFunc("/kyc", handlers.ListKYCVerificationsHandler).Methods("GET")
+	adminRouter.HandleFunc("/kyc/documents/{id}", handlers.GetKYCDocumentFileHandler).Methods("GET")
+	adminRouter.HandleFunc("/kyc/documents/{id}/url", handlers.GetKYCDocumentURLHandler).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/kyc", handlers.GetUserKYCHandler).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/kyc/approve", handlers.ApproveKYCHandler).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/kyc/reject", handlers.RejectKYCHandler).Methods("POST")
+	adminRouter.HandleFunc("/disputes", handlers.AdminListDisputesHandler).Methods("GET")
+	adminRouter.HandleFunc("/disputes/{id}", handlers.AdminGetDisputeHandler).Methods("GET")
+	adminRouter.HandleFunc("/disputes/{id}/attachments/{attachment_id}/url", handlers.GetDisputeAttachmentURLHandler).Methods("GET")
+	adminRouter.HandleFunc("/disputes/{id}/take", handlers.TakeDisputeHandler).Methods("POST")
+	adminRouter.HandleFunc("/disputes/{id}/refund", handlers.RefundDisputeHandler).Methods("POST")
+	adminRouter.HandleFunc("/disputes/{id}/resolve", handlers.ResolveDisputeHandler).Methods("POST")
+	adminRouter.HandleFunc("/disputes/{id}/decline", handlers.DeclineDisputeHandler).Methods("POST")
+	adminRouter.HandleFunc("/credit-applications", handlers.ListCreditApplicationsHandler).Methods("GET")
+	adminRouter.HandleFunc("/credit-applications/{id}/approve", handlers.ApproveCreditApplicationHandler).Methods("POST")
+	adminRouter.HandleFunc("/credit-applications/{id}/reject", handlers.RejectCreditApplicationHandler).Methods("POST")
+	adminRouter.HandleFunc("/credit-restructurings", handlers.ListCreditRestructuringsHandler).Methods("GET")
+	adminRouter.HandleFunc("/credit-restructurings/{id}/approve", handlers.ApproveCreditRestructuringHandler).Methods("POST")
+	adminRouter.HandleFunc("/credit-restructurings/{id}/reject", handlers.RejectCreditRestructuringHandler).Methods("POST")
+	adminRouter.HandleFunc("/insurance-claims", handlers.ListCreditInsuranceClaimsHandler).Methods("GET")
+	adminRouter.HandleFunc("/insurance-claims/{id}/approve", handlers.ApproveCreditInsuranceClaimHandler).Methods("POST")
+	adminRouter.HandleFunc("/insurance-claims/{id}/reject", handlers.RejectCreditInsuranceClaimHandler).Methods("POST")
+	adminRouter.HandleFunc("/insurance-claims/{id}/close", handlers.CloseCreditInsuranceClaimHandler).Methods("POST")
+	adminRouter.HandleFunc("/collateral", handlers.ListCollateralHandler).Methods("GET")
+	adminRouter.HandleFunc("/collateral", handlers.CreateCollateralHandler).Methods("POST")
+	adminRouter.HandleFunc("/collateral/{id}", handlers.GetCollateralHandler).Methods("GET")
+	adminRouter.HandleFunc("/collateral/{id}/valuation", handlers.UpdateCollateralValuationHandler).Methods("PUT")
+	adminRouter.HandleFunc("/collateral/{id}/link", handlers.LinkCollateralHandler).Methods("POST")
+	adminRouter.HandleFunc("/collateral/{id}/release", handlers.ReleaseCollateralHandler).Methods("POST")
+	adminRouter.HandleFunc("/collateral/{id}/documents", handlers.UploadCollateralDocumentHandler).Methods("POST")
+	adminRouter.HandleFunc("/collateral/{id}/documents/{document_id}/url", handlers.GetCollateralDocumentURLHandler).Methods("GET")
+	adminRouter.HandleFunc("/credits/{id}/collateral", handlers.AdminGetCreditCollateralHandler).Methods("GET")
+	adminRouter.HandleFunc("/collections", handlers.ListCollectionCasesHandler).Methods("GET")
+	adminRouter.HandleFunc("/collections/summary", handlers.GetCollectionSummaryHandler).Methods("GET")
+	adminRouter.HandleFunc("/analytics", handlers.GetBankAnalyticsHandler).Methods("GET")
+	adminRouter.HandleFunc("/reconciliation", handlers.GetReconciliationHandler).Methods("GET")
+	adminRouter.HandleFunc("/dashboard", handlers.AdminDashboardHandler).Methods("GET")
+	adminRouter.HandleFunc("/export/accounting", handlers.ExportAccountingHandler).Methods("GET")
+	adminRouter.HandleFunc("/fraud-checks", handlers.ListFraudChecksHandler).Methods("GET")
+	adminRouter.HandleFunc("/fraud-checks/{id}", handlers.GetFraudCheckHandler).Methods("GET")
+	adminRouter.HandleFunc("/fraud-checks/{id}/approve", handlers.ApproveFraudCheckHandler).Methods("POST")
+	adminRouter.HandleFunc("/fraud-checks/{id}/reject", handlers.RejectFraudCheckHandler).Methods("POST")
+	adminRouter.HandleFunc("/card-batches", handlers.CreateCardBatchHandler).Methods("POST")
+	adminRouter.HandleFunc("/card-batches/{id}", handlers.GetCardBatchHandler).Methods("GET")
+	adminRouter.HandleFunc("/card-batches/{id}/items/{item_id}/delivery", handlers.UpdateCardDeliveryHandler).Methods("PUT")
+	adminRouter.HandleFunc("/parameters", handlers.ListParametersHandler).Methods("GET")
+	adminRouter.HandleFunc("/parameters/{key}", handlers.GetParameterHistoryHandler).Methods("GET")
+	adminRouter.HandleFunc("/parameters/{key}", handlers.SetParameterHandler).Methods("POST")
+	adminRouter.HandleFunc("/scheduler/run", handlers.RunSchedulerHandler).Methods("POST")
+	adminRouter.HandleFunc("/api-keys", handlers.CreateAPIKeyHandler).Methods("POST")
+	adminRouter.HandleFunc("/api-keys", handlers.ListAPIKeysHandler).Methods("GET")
+	adminRouter.HandleFunc("/api-keys/{id}/rotate", handlers.RotateAPIKeyHandler).Methods("POST")
+	adminRouter.HandleFunc("/api-keys/{id}", handlers.RevokeAPIKeyHandler).Methods("DELETE")
+
+	// The specification is generated from the routes above, so it lists every route
+	// even when its entry in the documentation is missing
+	undocumented, err := handlers.BuildOpenAPISpec(router, cfg.API.Prefix, cfg.API.Version)
+	if err != nil {
+		logger.WithError(err).Error("Failed to build OpenAPI specification")
+	}
+	for _, route := range undocumented {
+		logger.Warnf("Route %s is missing from the OpenAPI documentation", route)
+	}
 
 	return router
 }
@@ -97,3 +452,16 @@ func handleCORS(next http.Handler, allowedOrigins []string) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// maxUploadSize returns the largest file content a multipart form may carry:
+// an identity document, all the files of a dispute or a collateral document
+func maxUploadSize(cfg *config.Config) int64 {
+	size := cfg.KYC.MaxDocumentSize
+	if disputes := int64(cfg.Disputes.MaxAttachments) * cfg.Disputes.MaxAttachmentSize; disputes > size {
+		size = disputes
+	}
+	if cfg.Collateral.MaxDocumentSize > size {
+		size = cfg.Collateral.MaxDocumentSize
+	}
+	return size
+}
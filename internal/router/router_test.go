@@ -0,0 +1,51 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/handlers"
+	"github.com/Abigotado/abi_banking/internal/secrets"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSandboxRoutesRequireAuthentication(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Sandbox.Enabled = true
+	cfg.RateLimit.Enabled = false
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	// The routes are refused before any service is reached, so the handlers
+	// need none
+	r := NewRouter(cfg, &handlers.Handlers{}, nil, secrets.Static("test-secret"), logger)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+	}{
+		{"lists the emails without a token", http.MethodGet, "/sandbox/emails", ""},
+		{"resets without a token", http.MethodPost, "/sandbox/reset", ""},
+		{"resets with an invalid token", http.MethodPost, "/sandbox/reset", "Bearer invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, cfg.API.Prefix+tt.path, nil)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s = %d, want 401 or 403", tt.method, tt.path, rec.Code)
+			}
+		})
+	}
+}
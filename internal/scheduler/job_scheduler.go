@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// Job is a task run every interval by one instance at a time
+type Job struct {
+	Name     string // names the heartbeat, the metrics and the log entries of the job
+	LockKey  string // advisory lock held by the instance running the job
+	Interval time.Duration
+	// Run does the work due at now and returns the number of items it processed
+	Run func(ctx context.Context, now time.Time) (int, error)
+}
+
+// JobScheduler runs the registered jobs, each at once on start and then every
+// interval of its own
+type JobScheduler struct {
+	locker  Locker
+	logger  *logrus.Logger
+	monitor *alerting.HealthMonitor
+	jobs    []Job
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan bool
+}
+
+// NewJobScheduler creates a new job scheduler with no jobs
+func NewJobScheduler(locker Locker, logger *logrus.Logger) *JobScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobScheduler{
+		locker: locker,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan bool),
+	}
+}
+
+// Register adds a job to run once the scheduler is started
+func (s *JobScheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// SetHealthMonitor reports the runs of the jobs to the health monitor
+func (s *JobScheduler) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	s.monitor = monitor
+}
+
+// Start runs every registered job at once and then every interval of the job
+func (s *JobScheduler) Start() {
+	for _, job := range s.jobs {
+		s.logger.WithFields(logrus.Fields{"job": job.Name, "interval": job.Interval}).Info("Starting scheduled job")
+		if s.monitor != nil {
+			s.monitor.RegisterHeartbeat(job.Name, 3*job.Interval)
+		}
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop stops the jobs and waits for the runs in progress to finish, which are
+// cancelled when ctx is done first. It is safe to call on a scheduler that was
+// never started.
+func (s *JobScheduler) Stop(ctx context.Context) {
+	s.logger.Info("Stopping scheduled jobs")
+	close(s.done)
+	if worker.Drain(ctx, &s.wg, s.cancel) {
+		s.logger.Warn("Scheduled jobs forced to stop")
+	}
+	s.cancel()
+}
+
+func (s *JobScheduler) loop(job Job) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	s.run(s.ctx, job)
+	for {
+		select {
+		case <-ticker.C:
+			s.run(s.ctx, job)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// run runs a job unless another instance is running it
+func (s *JobScheduler) run(ctx context.Context, job Job) {
+	logger := s.logger.WithField("job", job.Name)
+	unlock, acquired, err := s.locker.TryLock(ctx, job.LockKey)
+	if err != nil {
+		logger.Errorf("Failed to acquire scheduled job lock: %v", err)
+		metrics.SchedulerRuns.Inc(job.Name, metrics.OutcomeError)
+		return
+	}
+	if !acquired {
+		metrics.SchedulerRuns.Inc(job.Name, outcomeSkipped)
+		s.beat(job)
+		return
+	}
+	defer unlock()
+
+	processed, err := job.Run(ctx, time.Now())
+	if err != nil {
+		logger.Errorf("Scheduled job failed: %v", err)
+		metrics.SchedulerRuns.Inc(job.Name, metrics.OutcomeError)
+		return
+	}
+	if processed > 0 {
+		logger.Infof("Scheduled job processed %d items", processed)
+	}
+	metrics.SchedulerRuns.Inc(job.Name, metrics.OutcomeSuccess)
+	s.beat(job)
+}
+
+func (s *JobScheduler) beat(job Job) {
+	if s.monitor != nil {
+		s.monitor.Beat(job.Name)
+	}
+}
@@ -1,77 +1,204 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/metrics"
 	"github.com/Abigotado/abi_banking/internal/models"
 	"github.com/Abigotado/abi_banking/internal/repository"
 	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/Abigotado/abi_banking/internal/worker"
 	"github.com/sirupsen/logrus"
 )
 
-// PaymentScheduler handles automatic payment processing
+// ErrSchedulerRunning is returned when a run is requested while another is in progress
+var ErrSchedulerRunning = errors.New("payment scheduler is already running")
+
+// Locker provides a lock shared by all instances of the service
+type Locker interface {
+	TryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+}
+
+// PaymentScheduler handles automatic payment processing. Every instance runs the
+// scheduler, but a run only proceeds on the instance holding the shared lock,
+// so payments are not collected twice.
 type PaymentScheduler struct {
-	creditRepo *repository.CreditRepository
+	creditRepo repository.CreditStore
 	accountSvc *service.AccountService
+	creditSvc  *service.CreditService
+	locker     Locker
+	interval   time.Duration
 	logger     *logrus.Logger
+	monitor    *alerting.HealthMonitor
 	ticker     *time.Ticker
+	running    atomic.Bool
+	wg         sync.WaitGroup
+	ctx        context.Context
+	cancel     context.CancelFunc
 	done       chan bool
 }
 
-// NewPaymentScheduler creates a new payment scheduler
+const (
+	paymentSchedulerHeartbeat = "payment_scheduler"
+	paymentSchedulerLock      = "scheduler:payments"
+	outcomeSkipped            = "skipped"
+)
+
+// NewPaymentScheduler creates a new payment scheduler that runs every interval
+// once started
 func NewPaymentScheduler(
-	creditRepo *repository.CreditRepository,
+	creditRepo repository.CreditStore,
 	accountSvc *service.AccountService,
+	creditSvc *service.CreditService,
+	locker Locker,
+	interval time.Duration,
 	logger *logrus.Logger,
 ) *PaymentScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &PaymentScheduler{
 		creditRepo: creditRepo,
 		accountSvc: accountSvc,
+		creditSvc:  creditSvc,
+		locker:     locker,
+		interval:   interval,
 		logger:     logger,
-		ticker:     time.NewTicker(12 * time.Hour),
+		ctx:        ctx,
+		cancel:     cancel,
 		done:       make(chan bool),
 	}
 }
 
+// SetHealthMonitor reports scheduler runs to the health monitor so a stalled
+// scheduler raises an alert
+func (s *PaymentScheduler) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	s.monitor = monitor
+	monitor.RegisterHeartbeat(paymentSchedulerHeartbeat, 2*s.interval+time.Hour)
+}
+
 // Start begins the scheduler
 func (s *PaymentScheduler) Start() {
-	s.logger.Info("Starting payment scheduler")
-	go s.run()
+	s.logger.WithField("interval", s.interval).Info("Starting payment scheduler")
+	s.ticker = time.NewTicker(s.interval)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop()
+	}()
 }
 
-// Stop stops the scheduler
-func (s *PaymentScheduler) Stop() {
+// Stop stops the scheduler and waits for the run in progress to finish, which
+// is cancelled when ctx is done first. It is safe to call on a scheduler that
+// was never started.
+func (s *PaymentScheduler) Stop(ctx context.Context) {
 	s.logger.Info("Stopping payment scheduler")
-	s.ticker.Stop()
-	s.done <- true
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	close(s.done)
+	if worker.Drain(ctx, &s.wg, s.cancel) {
+		s.logger.Warn("Payment scheduler forced to stop")
+	}
+	s.cancel()
+}
+
+// Trigger starts a run in the background without waiting for the next tick
+func (s *PaymentScheduler) Trigger() error {
+	if s.stopped() {
+		return errors.New("payment scheduler is stopped")
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return ErrSchedulerRunning
+	}
+
+	s.logger.Info("Payment scheduler run triggered manually")
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Store(false)
+		s.run(s.ctx)
+	}()
+	return nil
 }
 
-// run executes the scheduler loop
-func (s *PaymentScheduler) run() {
+// stopped reports whether Stop has been called
+func (s *PaymentScheduler) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// loop runs the scheduler on every tick
+func (s *PaymentScheduler) loop() {
 	for {
 		select {
 		case <-s.ticker.C:
-			s.processPayments()
+			// A manual run still in progress covers this tick
+			if !s.running.CompareAndSwap(false, true) {
+				s.logger.Warn("Skipping payment scheduler tick, a run is in progress")
+				continue
+			}
+			s.run(s.ctx)
+			s.running.Store(false)
 		case <-s.done:
 			return
 		}
 	}
 }
 
-// processPayments handles automatic payment processing
-func (s *PaymentScheduler) processPayments() {
+// run executes one scheduler run unless another instance is running one
+func (s *PaymentScheduler) run(ctx context.Context) {
+	unlock, acquired, err := s.locker.TryLock(ctx, paymentSchedulerLock)
+	if err != nil {
+		s.logger.Errorf("Failed to acquire payment scheduler lock: %v", err)
+		metrics.SchedulerRuns.Inc(paymentSchedulerHeartbeat, metrics.OutcomeError)
+		return
+	}
+	if !acquired {
+		// The scheduler itself is healthy, another instance does the work
+		s.logger.Info("Payment scheduler is running on another instance, skipping run")
+		metrics.SchedulerRuns.Inc(paymentSchedulerHeartbeat, outcomeSkipped)
+		if s.monitor != nil {
+			s.monitor.Beat(paymentSchedulerHeartbeat)
+		}
+		return
+	}
+	defer unlock()
+
+	outcome := metrics.OutcomeSuccess
+	if err := s.processPayments(ctx); err != nil {
+		outcome = metrics.OutcomeError
+	}
+	s.accruePenalties(ctx)
+	s.accrueOverdraftInterest(ctx)
+	metrics.SchedulerRuns.Inc(paymentSchedulerHeartbeat, outcome)
+	if s.monitor != nil {
+		s.monitor.Beat(paymentSchedulerHeartbeat)
+	}
+}
+
+// processPayments handles automatic payment processing. Failures of individual
+// payments are logged and counted; an error means the run could not start.
+func (s *PaymentScheduler) processPayments(ctx context.Context) error {
 	s.logger.Info("Processing scheduled payments")
 
 	// Get all active credits with due payments
-	credits, err := s.creditRepo.GetCreditsWithDuePayments()
+	credits, err := s.creditRepo.GetCreditsWithDuePayments(ctx)
 	if err != nil {
 		s.logger.Errorf("Failed to get credits with due payments: %v", err)
-		return
+		return err
 	}
 
 	for _, credit := range credits {
 		// Get the next payment
-		payment, err := s.creditRepo.GetNextPayment(credit.ID)
+		payment, err := s.creditRepo.GetNextPayment(ctx, credit.ID)
 		if err != nil {
 			s.logger.Errorf("Failed to get next payment for credit %d: %v", credit.ID, err)
 			continue
@@ -83,52 +210,53 @@ func (s *PaymentScheduler) processPayments() {
 		}
 
 		// Process payment
-		if err := s.processPayment(credit, payment); err != nil {
+		if err := s.processPayment(ctx, credit, payment); err != nil {
 			s.logger.Errorf("Failed to process payment for credit %d: %v", credit.ID, err)
+			metrics.CreditPaymentFailures.Inc("error")
 			continue
 		}
 	}
+
+	return nil
 }
 
-// processPayment handles a single payment
-func (s *PaymentScheduler) processPayment(credit *models.Credit, payment *models.PaymentSchedule) error {
-	// Start transaction
-	tx, err := s.creditRepo.BeginTransaction()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+// accruePenalties marks unpaid installments overdue and accrues their daily penalty
+func (s *PaymentScheduler) accruePenalties(ctx context.Context) {
+	s.logger.Info("Accruing credit penalties")
 
-	// Check if account has sufficient funds
-	account, err := s.accountSvc.GetAccountByID(credit.AccountID)
-	if err != nil {
-		return err
+	if err := s.creditSvc.AccruePenalties(ctx, time.Now()); err != nil {
+		s.logger.Errorf("Failed to accrue credit penalties: %v", err)
 	}
+}
 
-	if account.Balance < payment.Amount {
-		// Apply penalty for insufficient funds
-		penalty := payment.Amount * 0.1 // 10% penalty
-		payment.Amount += penalty
-		s.logger.Warnf("Insufficient funds for credit %d, applying penalty of %.2f", credit.ID, penalty)
-	}
+// accrueOverdraftInterest charges interest on overdrawn account balances
+func (s *PaymentScheduler) accrueOverdraftInterest(ctx context.Context) {
+	s.logger.Info("Accruing overdraft interest")
 
-	// Withdraw funds from account
-	if err := s.accountSvc.Withdraw(credit.AccountID, payment.Amount); err != nil {
-		return err
+	if err := s.accountSvc.AccrueOverdraftInterest(ctx, time.Now()); err != nil {
+		s.logger.Errorf("Failed to accrue overdraft interest: %v", err)
 	}
+}
 
-	// Update payment status
-	if err := s.creditRepo.UpdatePaymentStatus(payment.ID, string(models.PaymentStatusPaid)); err != nil {
+// processPayment collects a due installment, with any penalty accrued on it, from
+// the credit's account
+func (s *PaymentScheduler) processPayment(ctx context.Context, credit *models.Credit, payment *models.PaymentSchedule) error {
+	account, err := s.accountSvc.GetAccountByID(ctx, credit.AccountID)
+	if err != nil {
 		return err
 	}
 
-	// Update credit remaining amount
-	if err := s.creditRepo.UpdateRemainingAmount(credit.ID, credit.RemainingAmount-payment.Amount); err != nil {
-		return err
+	// An installment the account cannot cover stays outstanding and becomes overdue,
+	// accruing a daily penalty until a later run collects it together with the penalty
+	amountDue := payment.AmountDue()
+	if account.Balance < amountDue {
+		s.logger.Warnf("Insufficient funds for credit %d, %.2f due on payment %d", credit.ID, amountDue, payment.ID)
+		metrics.CreditPaymentFailures.Inc("insufficient_funds")
+		return nil
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
+	// Installments are paid oldest first, so the amount due settles this one
+	if err := s.creditSvc.PayCredit(ctx, credit.UserID, credit.ID, &models.PayCreditRequest{Amount: amountDue}); err != nil {
 		return err
 	}
 
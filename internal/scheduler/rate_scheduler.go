@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/Abigotado/abi_banking/internal/worker"
+	"github.com/sirupsen/logrus"
+)
+
+const rateSchedulerHeartbeat = "rate_scheduler"
+
+// RateScheduler periodically refreshes the cached CBR rates
+type RateScheduler struct {
+	rateService *service.RateService
+	interval    time.Duration
+	logger      *logrus.Logger
+	monitor     *alerting.HealthMonitor
+	ticker      *time.Ticker
+	wg          sync.WaitGroup
+	done        chan bool
+}
+
+// NewRateScheduler creates a new rate scheduler
+func NewRateScheduler(rateService *service.RateService, interval time.Duration, logger *logrus.Logger) *RateScheduler {
+	return &RateScheduler{
+		rateService: rateService,
+		interval:    interval,
+		logger:      logger,
+		done:        make(chan bool),
+	}
+}
+
+// SetHealthMonitor reports refresh runs to the health monitor
+func (s *RateScheduler) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	s.monitor = monitor
+	monitor.RegisterHeartbeat(rateSchedulerHeartbeat, 3*s.interval)
+}
+
+// Start warms the cache and begins periodic refreshes
+func (s *RateScheduler) Start() {
+	s.logger.Info("Starting rate scheduler")
+	s.ticker = time.NewTicker(s.interval)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.refresh()
+		s.run()
+	}()
+}
+
+// Stop stops the scheduler and waits for the refresh in progress to finish, up
+// to when ctx is done; a refresh is bounded by the CBR client timeout anyway
+func (s *RateScheduler) Stop(ctx context.Context) {
+	s.logger.Info("Stopping rate scheduler")
+	s.ticker.Stop()
+	close(s.done)
+	if worker.Drain(ctx, &s.wg, nil) {
+		s.logger.Warn("Rate scheduler stopped before its refresh finished")
+	}
+}
+
+func (s *RateScheduler) run() {
+	for {
+		select {
+		case <-s.ticker.C:
+			s.refresh()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *RateScheduler) refresh() {
+	if err := s.rateService.Refresh(); err != nil {
+		s.logger.Errorf("Failed to refresh rates: %v", err)
+		metrics.SchedulerRuns.Inc(rateSchedulerHeartbeat, metrics.OutcomeError)
+		return
+	}
+	metrics.SchedulerRuns.Inc(rateSchedulerHeartbeat, metrics.OutcomeSuccess)
+	if s.monitor != nil {
+		s.monitor.Beat(rateSchedulerHeartbeat)
+	}
+}
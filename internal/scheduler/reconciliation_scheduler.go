@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/alerting"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+	"github.com/Abigotado/abi_banking/internal/service"
+	"github.com/Abigotado/abi_banking/internal/worker"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reconciliationSchedulerHeartbeat = "reconciliation_scheduler"
+	reconciliationSchedulerLock      = "scheduler:reconciliation"
+	reconciliationIssuesAlert        = "reconciliation_issues"
+)
+
+// ReconciliationScheduler runs the nightly reconciliation of account balances:
+// every interval it checks whether the run of the day is due, and alerts the
+// operators when a run finds issues.
+type ReconciliationScheduler struct {
+	reconciliationSvc *service.ReconciliationService
+	locker            Locker
+	interval          time.Duration
+	logger            *logrus.Logger
+	monitor           *alerting.HealthMonitor
+	ticker            *time.Ticker
+	wg                sync.WaitGroup
+	ctx               context.Context
+	cancel            context.CancelFunc
+	done              chan bool
+}
+
+// NewReconciliationScheduler creates a new reconciliation scheduler that checks
+// every interval once started
+func NewReconciliationScheduler(reconciliationSvc *service.ReconciliationService, locker Locker, interval time.Duration, logger *logrus.Logger) *ReconciliationScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReconciliationScheduler{
+		reconciliationSvc: reconciliationSvc,
+		locker:            locker,
+		interval:          interval,
+		logger:            logger,
+		ctx:               ctx,
+		cancel:            cancel,
+		done:              make(chan bool),
+	}
+}
+
+// SetHealthMonitor reports scheduler runs to the health monitor and issues to
+// its alerter
+func (s *ReconciliationScheduler) SetHealthMonitor(monitor *alerting.HealthMonitor) {
+	s.monitor = monitor
+	monitor.RegisterHeartbeat(reconciliationSchedulerHeartbeat, 3*s.interval)
+}
+
+// Start runs the scheduler at once and then every interval
+func (s *ReconciliationScheduler) Start() {
+	s.logger.WithField("interval", s.interval).Info("Starting reconciliation scheduler")
+	s.ticker = time.NewTicker(s.interval)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(s.ctx)
+		for {
+			select {
+			case <-s.ticker.C:
+				s.run(s.ctx)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler and waits for the run in progress to finish, which
+// is cancelled when ctx is done first. It is safe to call on a scheduler that
+// was never started.
+func (s *ReconciliationScheduler) Stop(ctx context.Context) {
+	s.logger.Info("Stopping reconciliation scheduler")
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	close(s.done)
+	if worker.Drain(ctx, &s.wg, s.cancel) {
+		s.logger.Warn("Reconciliation scheduler forced to stop")
+	}
+	s.cancel()
+}
+
+// run reconciles the balances when due unless another instance is doing it
+func (s *ReconciliationScheduler) run(ctx context.Context) {
+	unlock, acquired, err := s.locker.TryLock(ctx, reconciliationSchedulerLock)
+	if err != nil {
+		s.logger.Errorf("Failed to acquire reconciliation scheduler lock: %v", err)
+		metrics.SchedulerRuns.Inc(reconciliationSchedulerHeartbeat, metrics.OutcomeError)
+		return
+	}
+	if !acquired {
+		metrics.SchedulerRuns.Inc(reconciliationSchedulerHeartbeat, outcomeSkipped)
+		s.beat()
+		return
+	}
+	defer unlock()
+
+	run, err := s.reconciliationSvc.Reconcile(ctx, time.Now())
+	if err != nil {
+		s.logger.Errorf("Failed to reconcile account balances: %v", err)
+		metrics.SchedulerRuns.Inc(reconciliationSchedulerHeartbeat, metrics.OutcomeError)
+		return
+	}
+
+	if run != nil && s.monitor != nil {
+		if run.Issues > 0 {
+			s.monitor.Alerter().Alert(&alerting.Alert{
+				Key:      reconciliationIssuesAlert,
+				Severity: alerting.SeverityCritical,
+				Title:    "Account balances do not reconcile",
+				Message:  fmt.Sprintf("Reconciliation run %d of %s found %d issues in %d accounts", run.ID, run.RunDate.Format("2006-01-02"), run.Issues, run.Accounts),
+			})
+		} else {
+			s.monitor.Alerter().Resolve(reconciliationIssuesAlert)
+		}
+	}
+
+	metrics.SchedulerRuns.Inc(reconciliationSchedulerHeartbeat, metrics.OutcomeSuccess)
+	s.beat()
+}
+
+func (s *ReconciliationScheduler) beat() {
+	if s.monitor != nil {
+		s.monitor.Beat(reconciliationSchedulerHeartbeat)
+	}
+}
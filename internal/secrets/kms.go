@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/awssig"
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+)
+
+// kmsService is the name of AWS KMS in request signatures
+const kmsService = "kms"
+
+// KMSProvider decrypts secrets encrypted with AWS KMS. The ciphertext names the
+// key it was encrypted under, so only the credentials allowed to use that key
+// are configured.
+type KMSProvider struct {
+	config     *config.KMSConfig
+	httpClient *http.Client
+}
+
+// NewKMSProvider creates a new KMSProvider instance
+func NewKMSProvider(cfg *config.KMSConfig) *KMSProvider {
+	return &KMSProvider{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// kmsDecryptResponse is the response of the Decrypt action, or its error
+type kmsDecryptResponse struct {
+	Plaintext string `json:"Plaintext"`
+	Type      string `json:"__type"`
+	Message   string `json:"message"`
+}
+
+// Fetch decrypts a secret; ref is the base64 ciphertext blob returned by the
+// Encrypt action, e.g. by "aws kms encrypt"
+func (p *KMSProvider) Fetch(ctx context.Context, ref string) (value string, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("kms", "decrypt", start, err)
+	}()
+
+	payload, err := json.Marshal(map[string]string{"CiphertextBlob": ref})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := p.config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + p.config.Region + ".amazonaws.com/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	awssig.Sign(req, payload, awssig.Credentials{
+		AccessKeyID:     p.config.AccessKeyID,
+		SecretAccessKey: p.config.SecretAccessKey,
+		SessionToken:    p.config.SessionToken,
+	}, p.config.Region, kmsService, time.Now().UTC())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var decrypted kmsDecryptResponse
+	if err := json.Unmarshal(body, &decrypted); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("failed to parse KMS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("KMS returned status %d: %s %s", resp.StatusCode, decrypted.Type, decrypted.Message)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(decrypted.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("invalid KMS plaintext: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,218 @@
+// Package secrets resolves secret settings through key management systems, so
+// the JWT secret, the database password and the encryption keys need not be
+// configured in plain text. A setting refers to its secret by a scheme prefix,
+// "vault:" or "kms:"; the secret is fetched on first use, cached, and fetched
+// again once the cache expires, which picks up rotations without a restart.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Schemes of the settings referring to a secret
+const (
+	SchemeVault = "vault"
+	SchemeKMS   = "kms"
+)
+
+// minRefreshInterval bounds how often a secret is fetched ahead of its cache
+// expiry, so that requests with forged credentials cannot flood the key
+// management system
+const minRefreshInterval = 30 * time.Second
+
+// Provider fetches a secret from a key management system by the reference that
+// follows the scheme of a setting
+type Provider interface {
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// Manager resolves secret settings through the configured providers
+type Manager struct {
+	providers map[string]Provider
+	ttl       time.Duration
+	logger    *logrus.Logger
+}
+
+// NewManager creates a Manager with a provider for each configured key
+// management system
+func NewManager(cfg *config.SecretsConfig, logger *logrus.Logger) *Manager {
+	m := &Manager{
+		providers: make(map[string]Provider),
+		ttl:       cfg.CacheTTL,
+		logger:    logger,
+	}
+	if cfg.Vault.Address != "" {
+		m.providers[SchemeVault] = NewVaultProvider(&cfg.Vault)
+	}
+	if cfg.KMS.Region != "" {
+		m.providers[SchemeKMS] = NewKMSProvider(&cfg.KMS)
+	}
+	return m
+}
+
+// Secret returns the secret of a setting. A setting without a known scheme is
+// the secret itself. Nothing is fetched until the secret is first used.
+func (m *Manager) Secret(setting string) (*Secret, error) {
+	scheme, ref, found := strings.Cut(setting, ":")
+	if !found || (scheme != SchemeVault && scheme != SchemeKMS) {
+		return Static(setting), nil
+	}
+
+	provider, ok := m.providers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("secret setting refers to %s, which is not configured", scheme)
+	}
+	return &Secret{
+		name:     scheme + ":" + redact(ref),
+		ref:      ref,
+		provider: provider,
+		ttl:      m.ttl,
+		logger:   m.logger,
+	}, nil
+}
+
+// Resolve fetches the secret of a setting once, for secrets only read at startup
+func (m *Manager) Resolve(ctx context.Context, setting string) (string, error) {
+	secret, err := m.Secret(setting)
+	if err != nil {
+		return "", err
+	}
+	return secret.Value(ctx)
+}
+
+// ResolveEncryptionKeys replaces the encryption key settings that refer to a
+// key management system with the keys themselves
+func (m *Manager) ResolveEncryptionKeys(ctx context.Context, cfg *config.EncryptionConfig) error {
+	for _, setting := range []*string{&cfg.CardDataKey, &cfg.PIIMasterKey, &cfg.HMACSecret, &cfg.PGPPrivateKey} {
+		value, err := m.Resolve(ctx, *setting)
+		if err != nil {
+			return err
+		}
+		*setting = value
+	}
+	return nil
+}
+
+// Secret is a secret that may be rotated in its key management system. After a
+// rotation the value it replaced is kept as the previous one, so credentials
+// issued with it can still be verified.
+type Secret struct {
+	name     string // scheme and redacted reference, for logs
+	ref      string
+	provider Provider // nil for a secret configured in plain text
+	ttl      time.Duration
+	logger   *logrus.Logger
+
+	mu          sync.Mutex
+	value       string
+	previous    string
+	fetched     bool
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// Static returns a secret configured in plain text, which never changes
+func Static(value string) *Secret {
+	return &Secret{value: value, fetched: true}
+}
+
+// Value returns the current value of the secret, fetching it when it is not
+// cached yet or the cache has expired. Once fetched, a failed fetch keeps the
+// cached value and is tried again after minRefreshInterval.
+func (s *Secret) Value(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensure(ctx); err != nil {
+		return "", err
+	}
+	return s.value, nil
+}
+
+// Values returns the current value of the secret followed by the one it
+// replaced at the last rotation, if any
+func (s *Secret) Values(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensure(ctx); err != nil {
+		return nil, err
+	}
+	if s.previous == "" {
+		return []string{s.value}, nil
+	}
+	return []string{s.value, s.previous}, nil
+}
+
+// Refresh fetches the secret ahead of its cache expiry, at most once every
+// minRefreshInterval, and reports whether it has changed. It is called when a
+// credential fails to verify or a login is refused, which is how a secret
+// rotated elsewhere shows.
+func (s *Secret) Refresh(ctx context.Context) (bool, error) {
+	if s.provider == nil {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.attemptedAt) < minRefreshInterval {
+		return false, nil
+	}
+	old := s.value
+	if err := s.fetch(ctx); err != nil {
+		return false, err
+	}
+	return s.value != old, nil
+}
+
+// ensure fetches the secret when it is not cached or the cache has expired;
+// the caller holds the lock
+func (s *Secret) ensure(ctx context.Context) error {
+	if s.provider == nil {
+		return nil
+	}
+	if !s.fetched {
+		return s.fetch(ctx)
+	}
+	if time.Since(s.fetchedAt) < s.ttl || time.Since(s.attemptedAt) < minRefreshInterval {
+		return nil
+	}
+	if err := s.fetch(ctx); err != nil {
+		s.logger.WithError(err).Warnf("Failed to fetch secret %s, using the cached value", s.name)
+	}
+	return nil
+}
+
+// fetch reads the secret from its provider; the caller holds the lock
+func (s *Secret) fetch(ctx context.Context) error {
+	s.attemptedAt = time.Now()
+	value, err := s.provider.Fetch(ctx, s.ref)
+	if err != nil {
+		return fmt.Errorf("failed to fetch secret %s: %w", s.name, err)
+	}
+
+	if s.fetched && value != s.value {
+		s.logger.Infof("Secret %s has been rotated", s.name)
+		s.previous = s.value
+	}
+	s.value = value
+	s.fetched = true
+	s.fetchedAt = s.attemptedAt
+	return nil
+}
+
+// redact shortens a reference for logs; KMS ciphertexts are long and opaque
+func redact(ref string) string {
+	if len(ref) > 32 {
+		return ref[:32] + "..."
+	}
+	return ref
+}
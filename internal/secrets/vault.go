@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+)
+
+// VaultProvider reads secrets from the KV version 2 secrets engine of
+// HashiCorp Vault, authenticating with a token
+type VaultProvider struct {
+	config     *config.VaultConfig
+	httpClient *http.Client
+}
+
+// NewVaultProvider creates a new VaultProvider instance
+func NewVaultProvider(cfg *config.VaultConfig) *VaultProvider {
+	return &VaultProvider{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// vaultKVResponse is the response to a read of a KV version 2 secret
+type vaultKVResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Fetch reads a field of the latest version of a secret; ref is
+// "<path>#<field>", the path relative to the mount of the engine
+func (p *VaultProvider) Fetch(ctx context.Context, ref string) (value string, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("vault", "read", start, err)
+	}()
+
+	path, field, found := strings.Cut(ref, "#")
+	if !found || path == "" || field == "" {
+		return "", fmt.Errorf("invalid Vault reference %q, want <path>#<field>", ref)
+	}
+
+	url := strings.TrimSuffix(p.config.Address, "/") + "/v1/" + strings.Trim(p.config.Mount, "/") + "/data/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var secret vaultKVResponse
+	if err := json.Unmarshal(body, &secret); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("failed to parse Vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault returned status %d: %s", resp.StatusCode, strings.Join(secret.Errors, "; "))
+	}
+
+	raw, ok := secret.Data.Data[field]
+	if !ok {
+		return "", fmt.Errorf("Vault secret %s has no field %s", path, field)
+	}
+	value, ok = raw.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s of Vault secret %s is not a string", field, path)
+	}
+	return value, nil
+}
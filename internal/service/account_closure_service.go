@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// AccountClosureService handles the closure of accounts by their owners: the
+// remaining balance is swept to another account of the owner and the final
+// statement of the account is queued in the same transaction that closes it
+type AccountClosureService struct {
+	accountRepo         repository.AccountStore
+	cardRepo            repository.CardStore
+	creditRepo          repository.CreditStore
+	statementService    *StatementService
+	notificationService *NotificationService
+	auditRepo           *repository.AuditRepository
+	deposits            *DepositService
+	logger              *logrus.Logger
+}
+
+// NewAccountClosureService creates a new AccountClosureService instance
+func NewAccountClosureService(
+	accountRepo repository.AccountStore,
+	cardRepo repository.CardStore,
+	creditRepo repository.CreditStore,
+	statementService *StatementService,
+	notificationService *NotificationService,
+	auditRepo *repository.AuditRepository,
+	logger *logrus.Logger,
+) *AccountClosureService {
+	return &AccountClosureService{
+		accountRepo:         accountRepo,
+		cardRepo:            cardRepo,
+		creditRepo:          creditRepo,
+		statementService:    statementService,
+		notificationService: notificationService,
+		auditRepo:           auditRepo,
+		logger:              logger,
+	}
+}
+
+// SetDeposits keeps accounts that term deposits are to be paid out to from
+// being closed
+func (s *AccountClosureService) SetDeposits(deposits *DepositService) {
+	s.deposits = deposits
+}
+
+// CloseAccount closes an account of the user. The account must have no open
+// holds, no active cards, no credits being repaid from it and no term deposits
+// to be paid out to it; a positive balance is swept to the target account of
+// the request, which must be another open account of the user in the same
+// currency.
+func (s *AccountClosureService) CloseAccount(ctx context.Context, userID, accountID int64, req *models.CloseAccountRequest) (*models.AccountClosure, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	// Members may have access to the account, but it is the owner's to close
+	if account.UserID != userID {
+		if authorizeAccount(ctx, s.accountRepo, account, userID, false) != nil {
+			return nil, errors.New("account not found")
+		}
+		return nil, errors.New("unauthorized: only the account owner can close the account")
+	}
+	if err := checkClosable(account); err != nil {
+		return nil, err
+	}
+
+	target, err := s.target(ctx, account, req)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both accounts, lower ID first like transfers, and check the account
+	// again: a payment may have been made since it was read, and a card issued
+	ids := []int64{account.ID}
+	if target != nil {
+		ids = append(ids, target.ID)
+		if target.ID < account.ID {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+	}
+	for _, id := range ids {
+		locked, err := s.accountRepo.GetByIDForUpdate(ctx, tx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account %d: %w", id, err)
+		}
+		switch id {
+		case account.ID:
+			account = locked
+		default:
+			target = locked
+		}
+	}
+	if err := checkClosable(account); err != nil {
+		return nil, err
+	}
+	if err := s.checkLinkedProducts(ctx, tx, account); err != nil {
+		return nil, err
+	}
+	if account.Balance > 0 && target == nil {
+		return nil, errors.New("target account is required to sweep the balance")
+	}
+	if target != nil && target.IsClosed() {
+		return nil, errors.New("target account is closed")
+	}
+
+	closedAt := time.Now()
+	closure := &models.AccountClosure{AccountID: account.ID, ClosedAt: closedAt}
+	if account.Balance > 0 {
+		sweep := &models.Transaction{
+			FromAccountID: account.ID,
+			ToAccountID:   target.ID,
+			Amount:        account.Balance,
+			Type:          models.TransactionTypeTransfer,
+			Description:   fmt.Sprintf("Closing balance of account %s", account.MaskedNumber()),
+			CreatedAt:     closedAt,
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, sweep); err != nil {
+			s.logger.WithError(err).Errorf("Failed to sweep the balance of account %d", account.ID)
+			return nil, errors.New("internal server error")
+		}
+		closure.SweptAmount = sweep.Amount
+		closure.TargetAccountID = target.ID
+		closure.SweepTransactionID = sweep.ID
+		account.Balance = 0
+	}
+
+	if err := s.accountRepo.CloseTx(ctx, tx, account, closedAt); err != nil {
+		if err.Error() == "account is already closed" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	if closure.StatementJob, err = s.statementService.QueueFinalStatementTx(ctx, tx, userID, account, closedAt); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(closure)
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    userID,
+		Action:     models.AuditActionAccountClosed,
+		EntityType: "account",
+		EntityID:   account.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	content := fmt.Sprintf("Account %s has been closed. Its final statement is being prepared.", account.MaskedNumber())
+	if closure.SweptAmount > 0 {
+		content = fmt.Sprintf("Account %s has been closed and its balance of %.2f %s was moved to account %s. Its final statement is being prepared.",
+			account.MaskedNumber(), closure.SweptAmount, account.Currency, target.MaskedNumber())
+	}
+	if err := s.notificationService.NotifyUserTx(ctx, tx, userID, "Account closed", content); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return closure, nil
+}
+
+// checkClosable checks the state of the account itself: it is open, nothing is
+// held on it and it does not owe an overdraft
+func checkClosable(account *models.Account) error {
+	switch {
+	case account.IsClosed():
+		return errors.New("account is already closed")
+	case account.HeldAmount > 0:
+		return errors.New("account has open holds")
+	case account.Balance < 0:
+		return errors.New("account is overdrawn")
+	}
+	return nil
+}
+
+// target resolves the account the balance is swept to, nil when the request
+// names none
+func (s *AccountClosureService) target(ctx context.Context, account *models.Account, req *models.CloseAccountRequest) (*models.Account, error) {
+	var target *models.Account
+	var err error
+	switch {
+	case req.TargetAccountNumber != "":
+		if target, err = s.accountRepo.GetByNumber(ctx, req.TargetAccountNumber); err != nil {
+			return nil, errors.New("target account not found")
+		}
+		if req.TargetAccountID != 0 && req.TargetAccountID != target.ID {
+			return nil, errors.New("target_account_id and target_account_number refer to different accounts")
+		}
+	case req.TargetAccountID != 0:
+		if target, err = s.accountRepo.GetByID(ctx, req.TargetAccountID); err != nil {
+			return nil, errors.New("target account not found")
+		}
+	default:
+		if account.Balance > 0 {
+			return nil, errors.New("target account is required to sweep the balance")
+		}
+		return nil, nil
+	}
+
+	switch {
+	case target.ID == account.ID:
+		return nil, errors.New("cannot sweep the balance to the account being closed")
+	case target.UserID != account.UserID:
+		return nil, errors.New("unauthorized: target account does not belong to user")
+	case target.IsClosed():
+		return nil, errors.New("target account is closed")
+	case target.Currency != account.Currency:
+		return nil, errors.New("currency mismatch between accounts")
+	}
+	return target, nil
+}
+
+// checkLinkedProducts checks within the transaction that no card, credit or
+// term deposit still uses the account
+func (s *AccountClosureService) checkLinkedProducts(ctx context.Context, tx repository.Tx, account *models.Account) error {
+	cards, err := s.cardRepo.GetByAccountIDTx(ctx, tx, account.ID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account cards")
+		return errors.New("internal server error")
+	}
+	for _, card := range cards {
+		if card.Status == models.CardStatusActive {
+			return errors.New("account has active cards")
+		}
+	}
+
+	credits, err := s.creditRepo.GetByAccountIDTx(ctx, tx, account.ID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account credits")
+		return errors.New("internal server error")
+	}
+	for _, credit := range credits {
+		if credit.IsRepaying() || models.CreditStatus(credit.Status) == models.CreditStatusDefault {
+			return errors.New("account has credits being repaid")
+		}
+	}
+
+	if s.deposits != nil {
+		active, err := s.deposits.HasActiveOnAccount(ctx, tx, account.ID)
+		if err != nil {
+			return err
+		}
+		if active {
+			return errors.New("account has term deposits to be paid out")
+		}
+	}
+	return nil
+}
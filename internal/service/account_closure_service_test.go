@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestAccountClosureServiceCloseAccount(t *testing.T) {
+	closedAt := time.Now()
+	tests := []struct {
+		name     string
+		accounts []models.Account
+		locked   []models.Account
+		cards    []*models.Card
+		credits  []*models.Credit
+		userID   int64
+		req      models.CloseAccountRequest
+		wantErr  string
+		balances map[int64]float64
+	}{
+		{
+			name: "sweeps the balance to another own account",
+			accounts: []models.Account{
+				{ID: 3, UserID: 7, Balance: 150.5, Currency: "RUB"},
+				{ID: 2, UserID: 7, Number: "40817810300007654321", Balance: 10, Currency: "RUB"},
+			},
+			cards:    []*models.Card{{ID: 1, AccountID: 3, Status: models.CardStatusBlocked}},
+			credits:  []*models.Credit{{ID: 1, UserID: 7, AccountID: 2, Status: string(models.CreditStatusActive)}},
+			userID:   7,
+			req:      models.CloseAccountRequest{TargetAccountNumber: "40817810300007654321"},
+			balances: map[int64]float64{3: 0, 2: 160.5},
+		},
+		{
+			name:     "closes an empty account without a target",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			userID:   7,
+		},
+		{
+			name:     "rejects a member of the account",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			userID:   8,
+			wantErr:  "unauthorized: only the account owner can close the account",
+		},
+		{
+			name:     "rejects a closed account",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB", ClosedAt: &closedAt}},
+			userID:   7,
+			wantErr:  "account is already closed",
+		},
+		{
+			name:     "rejects open holds",
+			accounts: []models.Account{{ID: 3, UserID: 7, Balance: 100, HeldAmount: 40, Currency: "RUB"}},
+			userID:   7,
+			wantErr:  "account has open holds",
+		},
+		{
+			name:     "rejects an overdrawn account",
+			accounts: []models.Account{{ID: 3, UserID: 7, Balance: -20, OverdraftLimit: 50, Currency: "RUB"}},
+			userID:   7,
+			wantErr:  "account is overdrawn",
+		},
+		{
+			name:     "requires a target for the balance",
+			accounts: []models.Account{{ID: 3, UserID: 7, Balance: 100, Currency: "RUB"}},
+			userID:   7,
+			wantErr:  "target account is required to sweep the balance",
+		},
+		{
+			name: "rejects a target of another user",
+			accounts: []models.Account{
+				{ID: 3, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 4, UserID: 8, Currency: "RUB"},
+			},
+			userID:  7,
+			req:     models.CloseAccountRequest{TargetAccountID: 4},
+			wantErr: "unauthorized: target account does not belong to user",
+		},
+		{
+			name: "rejects a target in another currency",
+			accounts: []models.Account{
+				{ID: 3, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 4, UserID: 7, Currency: "USD"},
+			},
+			userID:  7,
+			req:     models.CloseAccountRequest{TargetAccountID: 4},
+			wantErr: "currency mismatch between accounts",
+		},
+		{
+			name:     "rejects active cards",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			cards:    []*models.Card{{ID: 1, AccountID: 3, Status: models.CardStatusActive}},
+			userID:   7,
+			wantErr:  "account has active cards",
+		},
+		{
+			name:     "rejects a credit being repaid",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			credits:  []*models.Credit{{ID: 1, UserID: 7, AccountID: 3, Status: string(models.CreditStatusDelinquent)}},
+			userID:   7,
+			wantErr:  "account has credits being repaid",
+		},
+		{
+			name:     "rejects a credit of the previous owner repaid from the account",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			credits:  []*models.Credit{{ID: 1, UserID: 6, AccountID: 3, Status: string(models.CreditStatusActive)}},
+			userID:   7,
+			wantErr:  "account has credits being repaid",
+		},
+		{
+			name:     "rechecks holds under the lock",
+			accounts: []models.Account{{ID: 3, UserID: 7, Currency: "RUB"}},
+			locked:   []models.Account{{ID: 3, UserID: 7, Balance: 30, HeldAmount: 30, Currency: "RUB"}},
+			userID:   7,
+			wantErr:  "account has open holds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			tx := env.tx()
+			book := newAccountBook(tt.accounts...)
+			for _, account := range tt.locked {
+				book.locked[account.ID] = account
+			}
+			var closed []int64
+			accounts := book.store(tx)
+			accounts.GetMemberRoleFunc = func(context.Context, int64, int64) (models.AccountRole, error) {
+				return models.AccountRoleCoOwner, nil
+			}
+			accounts.CloseTxFunc = func(_ context.Context, _ repository.Tx, account *models.Account, closedAt time.Time) error {
+				closed = append(closed, account.ID)
+				account.ClosedAt = &closedAt
+				return nil
+			}
+			cards := &mock.CardStore{
+				GetByAccountIDTxFunc: func(context.Context, repository.Tx, int64) ([]*models.Card, error) { return tt.cards, nil },
+			}
+			credits := &mock.CreditStore{
+				GetByAccountIDTxFunc: func(_ context.Context, _ repository.Tx, accountID int64) ([]*models.Credit, error) {
+					var onAccount []*models.Credit
+					for _, credit := range tt.credits {
+						if credit.AccountID == accountID {
+							onAccount = append(onAccount, credit)
+						}
+					}
+					return onAccount, nil
+				},
+			}
+
+			jobRunner := jobs.NewRunner(repository.NewJobRepository(env.db, env.logger), &env.cfg.Jobs, env.logger)
+			notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+				repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+			statementService := NewStatementService(
+				repository.NewStatementRepository(env.db, env.logger), repository.NewUserSettingsRepository(env.db, env.logger),
+				&mock.UserStore{}, accounts, notificationService, jobRunner, nil, time.Minute, env.logger,
+			)
+			s := NewAccountClosureService(accounts, cards, credits, statementService, notificationService,
+				repository.NewAuditRepository(env.db, env.logger), env.logger)
+
+			closure, err := s.CloseAccount(context.Background(), tt.userID, 3, &tt.req)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("CloseAccount() error = %v, want %q", err, tt.wantErr)
+				}
+				if tx.Committed || len(closed) != 0 || len(book.transactions) != 0 {
+					t.Errorf("refused closure changed state: committed %v, closed %v, transactions %d",
+						tx.Committed, closed, len(book.transactions))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CloseAccount() error = %v", err)
+			}
+			if !tx.Committed || len(closed) != 1 || closed[0] != 3 {
+				t.Errorf("committed %v, closed %v, want account 3 closed", tx.Committed, closed)
+			}
+			if closure.StatementJob == nil {
+				t.Error("final statement was not queued")
+			}
+			for id, want := range tt.balances {
+				if got := book.balance(id); got != want {
+					t.Errorf("balance of account %d = %v, want %v", id, got, want)
+				}
+			}
+			if len(tt.balances) == 0 && len(book.transactions) != 0 {
+				t.Errorf("empty account swept: %+v", book.transactions)
+			}
+			if len(tt.balances) != 0 && (closure.SweptAmount != 150.5 || closure.TargetAccountID != 2 ||
+				closure.SweepTransactionID != book.transactions[0].ID) {
+				t.Errorf("closure = %+v, want 150.5 swept to account 2", closure)
+			}
+		})
+	}
+}
+
+func TestAuthorizeClosedAccount(t *testing.T) {
+	closedAt := time.Now()
+	account := &models.Account{ID: 3, UserID: 7, Currency: "RUB", ClosedAt: &closedAt}
+
+	accounts := &mock.AccountStore{
+		GetMemberRoleFunc: func(context.Context, int64, int64) (models.AccountRole, error) {
+			return "", errors.New("account member not found")
+		},
+	}
+	if err := authorizeAccount(context.Background(), accounts, account, 7, false); err != nil {
+		t.Errorf("authorizeAccount() to view = %v, want a closed account readable", err)
+	}
+	if err := authorizeAccount(context.Background(), accounts, account, 7, true); err == nil || err.Error() != "account is closed" {
+		t.Errorf("authorizeAccount() to operate = %v, want %q", err, "account is closed")
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// accountRole resolves the permission level of a user on an account: the owner
+// holds the account, anyone else needs an accepted invitation
+func accountRole(ctx context.Context, accounts repository.AccountStore, account *models.Account, userID int64) (models.AccountRole, error) {
+	if account.UserID == userID {
+		return models.AccountRoleOwner, nil
+	}
+
+	role, err := accounts.GetMemberRole(ctx, account.ID, userID)
+	if err != nil {
+		return "", errors.New("unauthorized: account does not belong to user")
+	}
+	return role, nil
+}
+
+// authorizeAccount checks that a user may see an account, or with operate set,
+// also move money and issue cards on it. A closed account can only be seen.
+func authorizeAccount(ctx context.Context, accounts repository.AccountStore, account *models.Account, userID int64, operate bool) error {
+	role, err := accountRole(ctx, accounts, account, userID)
+	if err != nil {
+		return err
+	}
+	if operate && !role.CanOperate() {
+		return errors.New("unauthorized: view-only access to the account")
+	}
+	if operate && account.IsClosed() {
+		return errors.New("account is closed")
+	}
+	return nil
+}
+
+// AccountMemberService handles sharing accounts with other users
+type AccountMemberService struct {
+	memberRepo          *repository.AccountMemberRepository
+	accountRepo         repository.AccountStore
+	userRepo            repository.UserStore
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewAccountMemberService creates a new AccountMemberService instance
+func NewAccountMemberService(
+	memberRepo *repository.AccountMemberRepository,
+	accountRepo repository.AccountStore,
+	userRepo repository.UserStore,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *AccountMemberService {
+	return &AccountMemberService{
+		memberRepo:          memberRepo,
+		accountRepo:         accountRepo,
+		userRepo:            userRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// ownedAccount loads an account whose members the user manages
+func (s *AccountMemberService) ownedAccount(ctx context.Context, userID, accountID int64) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if account.UserID != userID {
+		return nil, errors.New("unauthorized: only the account owner can manage members")
+	}
+	return account, nil
+}
+
+// Invite invites a user, found by email, to share an account of the owner
+func (s *AccountMemberService) Invite(ctx context.Context, userID, accountID int64, req *models.InviteAccountMemberRequest) (*models.AccountMember, error) {
+	account, err := s.ownedAccount(ctx, userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitee, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if invitee.ID == account.UserID {
+		return nil, errors.New("the owner cannot be invited to their own account")
+	}
+
+	tx, err := s.memberRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	member := &models.AccountMember{
+		AccountID: account.ID,
+		UserID:    invitee.ID,
+		Role:      req.Role,
+		Status:    models.AccountMemberStatusInvited,
+		InvitedBy: userID,
+	}
+	if err := s.memberRepo.CreateTx(ctx, tx, member); err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("You have been invited to share account #%d (%s) as %s. Accept or decline the invitation in your account invitations.",
+		account.ID, account.Currency, roleName(member.Role))
+	if err := s.notificationService.NotifyUserTx(ctx, tx, invitee.ID, "Account invitation", content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue invitation to account %d", account.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return member, nil
+}
+
+// GetMembers lists the members and pending invitations of an account the user can see
+func (s *AccountMemberService) GetMembers(ctx context.Context, userID, accountID int64) ([]*models.AccountMember, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, false); err != nil {
+		return nil, err
+	}
+
+	members, err := s.memberRepo.GetByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return members, nil
+}
+
+// GetInvitations lists the invitations the user has not answered yet
+func (s *AccountMemberService) GetInvitations(ctx context.Context, userID int64) ([]*models.AccountMember, error) {
+	invitations, err := s.memberRepo.GetInvitations(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return invitations, nil
+}
+
+// invitation loads a pending invitation addressed to the user
+func (s *AccountMemberService) invitation(ctx context.Context, userID, invitationID int64) (*models.AccountMember, error) {
+	member, err := s.memberRepo.GetByID(ctx, invitationID)
+	if err != nil || member.UserID != userID || member.Status != models.AccountMemberStatusInvited {
+		return nil, errors.New("invitation not found")
+	}
+	return member, nil
+}
+
+// AcceptInvitation makes the user a member of the account they were invited to
+func (s *AccountMemberService) AcceptInvitation(ctx context.Context, userID, invitationID int64) (*models.AccountMember, error) {
+	member, err := s.invitation(ctx, userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.memberRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.memberRepo.ActivateTx(ctx, tx, member); err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("User #%d accepted your invitation to account #%d as %s.", member.UserID, member.AccountID, roleName(member.Role))
+	if err := s.notificationService.NotifyUserTx(ctx, tx, member.InvitedBy, "Account invitation accepted", content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue acceptance of invitation %d", member.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return member, nil
+}
+
+// DeclineInvitation drops an invitation addressed to the user
+func (s *AccountMemberService) DeclineInvitation(ctx context.Context, userID, invitationID int64) error {
+	member, err := s.invitation(ctx, userID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.memberRepo.Delete(ctx, member.ID); err != nil {
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// UpdateMemberRole changes the permission level of a member of an account of the owner
+func (s *AccountMemberService) UpdateMemberRole(ctx context.Context, userID, accountID, memberUserID int64, req *models.UpdateAccountMemberRequest) (*models.AccountMember, error) {
+	if _, err := s.ownedAccount(ctx, userID, accountID); err != nil {
+		return nil, err
+	}
+
+	member, err := s.memberRepo.GetByAccountAndUser(ctx, accountID, memberUserID)
+	if err != nil {
+		return nil, errors.New("account member not found")
+	}
+
+	if err := s.memberRepo.UpdateRole(ctx, member.ID, req.Role); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	member.Role = req.Role
+
+	return member, nil
+}
+
+// RemoveMember takes a member off an account. The owner may remove anyone and
+// a member may leave on their own.
+func (s *AccountMemberService) RemoveMember(ctx context.Context, userID, accountID, memberUserID int64) error {
+	if memberUserID != userID {
+		if _, err := s.ownedAccount(ctx, userID, accountID); err != nil {
+			return err
+		}
+	}
+
+	member, err := s.memberRepo.GetByAccountAndUser(ctx, accountID, memberUserID)
+	if err != nil {
+		return errors.New("account member not found")
+	}
+
+	if err := s.memberRepo.Delete(ctx, member.ID); err != nil {
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// roleName describes a role in notifications
+func roleName(role models.AccountRole) string {
+	if role == models.AccountRoleCoOwner {
+		return "co-owner"
+	}
+	return "view-only member"
+}
@@ -1,30 +1,103 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/cache"
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/events"
+	"github.com/Abigotado/abi_banking/internal/fraud"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
 	"github.com/Abigotado/abi_banking/internal/repository"
 	"github.com/sirupsen/logrus"
 )
 
 type AccountService struct {
-	accountRepo *repository.AccountRepository
-	creditRepo  *repository.CreditRepository
-	logger      *logrus.Logger
+	accountRepo         repository.AccountStore
+	creditRepo          repository.CreditStore
+	userRepo            repository.UserStore
+	auditRepo           *repository.AuditRepository
+	notificationService *NotificationService
+	webhookService      *WebhookService
+	events              *events.Publisher
+	fraud               *fraud.Service
+	limits              *LimitService
+	bank                *config.BankConfig
+	cache               cache.Cache
+	analyticsTTL        time.Duration
+	payees              *repository.PayeeRepository
+	collections         *CollectionService
+	logger              *logrus.Logger
 }
 
-func NewAccountService(logger *logrus.Logger) *AccountService {
+func NewAccountService(
+	accountRepo repository.AccountStore,
+	creditRepo repository.CreditStore,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	webhookService *WebhookService,
+	publisher *events.Publisher,
+	fraudService *fraud.Service,
+	limitService *LimitService,
+	bank *config.BankConfig,
+	logger *logrus.Logger,
+) *AccountService {
 	return &AccountService{
-		accountRepo: repository.NewAccountRepository(),
-		creditRepo:  repository.NewCreditRepository(),
-		logger:      logger,
+		accountRepo:         accountRepo,
+		creditRepo:          creditRepo,
+		userRepo:            userRepo,
+		auditRepo:           auditRepo,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		events:              publisher,
+		fraud:               fraudService,
+		limits:              limitService,
+		bank:                bank,
+		cache:               cache.Nop{},
+		logger:              logger,
 	}
 }
 
-func (s *AccountService) CreateAccount(req *models.CreateAccountRequest) (*models.Account, error) {
+// SetCache keeps the transaction analytics in c for up to ttl. The entries are
+// keyed by the versions of the accounts, so a balance change is seen at once.
+func (s *AccountService) SetCache(c cache.Cache, ttl time.Duration) {
+	s.cache = c
+	s.analyticsTTL = ttl
+}
+
+// SetPayees adds the transfers the users made to their saved payees to the
+// transaction analytics
+func (s *AccountService) SetPayees(payees *repository.PayeeRepository) {
+	s.payees = payees
+}
+
+// SetCollections refuses new accounts to users whose credit is delinquent or
+// in collections
+func (s *AccountService) SetCollections(collections *CollectionService) {
+	s.collections = collections
+}
+
+// maxAccountNumberAttempts bounds the retries when a random account number is
+// already taken
+const maxAccountNumberAttempts = 5
+
+func (s *AccountService) CreateAccount(ctx context.Context, req *models.CreateAccountRequest) (*models.Account, error) {
+	if s.collections != nil {
+		if err := s.collections.CheckGoodStanding(ctx, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
 	account := &models.Account{
 		UserID:    req.UserID,
 		Balance:   req.Balance,
@@ -33,7 +106,20 @@ func (s *AccountService) CreateAccount(req *models.CreateAccountRequest) (*model
 		UpdatedAt: time.Now(),
 	}
 
-	if err := s.accountRepo.Create(account); err != nil {
+	for attempt := 1; ; attempt++ {
+		number, err := s.newAccountNumber(account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		account.Number = number
+
+		err = s.accountRepo.Create(ctx, account)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, repository.ErrAccountNumberTaken) && attempt < maxAccountNumberAttempts {
+			continue
+		}
 		s.logger.WithError(err).Error("Failed to create account")
 		return nil, errors.New("internal server error")
 	}
@@ -41,8 +127,74 @@ func (s *AccountService) CreateAccount(req *models.CreateAccountRequest) (*model
 	return account, nil
 }
 
-func (s *AccountService) GetAccountByID(accountID int64) (*models.Account, error) {
-	account, err := s.accountRepo.GetByID(accountID)
+// newAccountNumber draws an account number in the currency with a random serial
+func (s *AccountService) newAccountNumber(currency string) (string, error) {
+	number, err := models.NewAccountNumber(s.bank.BIC, s.bank.BalanceAccount, currency, s.bank.BranchCode, randomDigits(7))
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not supported") {
+			return "", err
+		}
+		s.logger.WithError(err).Error("Invalid bank configuration for account numbers")
+		return "", errors.New("internal server error")
+	}
+	return number, nil
+}
+
+// AssignAccountNumbers numbers the accounts opened before account numbers were
+// issued. It returns how many accounts got a number.
+func (s *AccountService) AssignAccountNumbers(ctx context.Context) (int, error) {
+	accounts, err := s.accountRepo.GetWithoutNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	assigned := 0
+	for _, account := range accounts {
+		for attempt := 1; ; attempt++ {
+			number, err := s.newAccountNumber(account.Currency)
+			if err != nil {
+				return assigned, fmt.Errorf("failed to number account %d: %w", account.ID, err)
+			}
+			err = s.accountRepo.SetNumber(ctx, account.ID, number)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, repository.ErrAccountNumberTaken) || attempt == maxAccountNumberAttempts {
+				return assigned, fmt.Errorf("failed to number account %d: %w", account.ID, err)
+			}
+		}
+		assigned++
+	}
+
+	return assigned, nil
+}
+
+// LookupAccount finds an account by its number and discloses only what a sender
+// needs to check the recipient
+func (s *AccountService) LookupAccount(ctx context.Context, number string) (*models.AccountLookup, error) {
+	account, err := s.accountRepo.GetByNumber(ctx, strings.TrimSpace(number))
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	user, err := s.userRepo.GetByID(ctx, account.UserID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account holder")
+		return nil, errors.New("internal server error")
+	}
+
+	holder := user.FirstName
+	if initial := []rune(user.LastName); len(initial) > 0 {
+		holder += " " + string(initial[0]) + "."
+	}
+	return &models.AccountLookup{
+		Number:   account.MaskedNumber(),
+		Currency: account.Currency,
+		Holder:   holder,
+	}, nil
+}
+
+func (s *AccountService) GetAccountByID(ctx context.Context, accountID int64) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get account by ID")
 		return nil, errors.New("account not found")
@@ -51,8 +203,8 @@ func (s *AccountService) GetAccountByID(accountID int64) (*models.Account, error
 	return account, nil
 }
 
-func (s *AccountService) GetUserAccounts(userID int64) ([]*models.Account, error) {
-	accounts, err := s.accountRepo.GetByUserID(userID)
+func (s *AccountService) GetUserAccounts(ctx context.Context, userID int64) ([]*models.Account, error) {
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user accounts")
 		return nil, errors.New("internal server error")
@@ -61,249 +213,533 @@ func (s *AccountService) GetUserAccounts(userID int64) ([]*models.Account, error
 	return accounts, nil
 }
 
-func (s *AccountService) Transfer(req *models.TransferRequest) error {
+// ListUserAccounts retrieves a page of the accounts of a user and how many the
+// user has
+func (s *AccountService) ListUserAccounts(ctx context.Context, userID int64, p pagination.Params) ([]*models.Account, int, error) {
+	accounts, total, err := s.accountRepo.ListByUserID(ctx, userID, p)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list user accounts")
+		return nil, 0, errors.New("internal server error")
+	}
+
+	return accounts, total, nil
+}
+
+// UpdateAccount renames an account of the user or changes whether it is the
+// primary account of its currency
+func (s *AccountService) UpdateAccount(ctx context.Context, userID, accountID int64, req *models.UpdateAccountRequest) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+	// The primary flag picks among the owner's accounts, so only the owner sets it
+	if req.Primary != nil && account.UserID != userID {
+		return nil, errors.New("unauthorized: only the account owner can change the primary account")
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := s.accountRepo.UpdateName(ctx, account.ID, name); err != nil {
+			s.logger.WithError(err).Error("Failed to rename account")
+			return nil, errors.New("internal server error")
+		}
+		account.Name = name
+	}
+
+	if req.Primary != nil && *req.Primary != account.Primary {
+		if err := s.accountRepo.SetPrimary(ctx, account, *req.Primary); err != nil {
+			s.logger.WithError(err).Error("Failed to change primary account")
+			return nil, errors.New("internal server error")
+		}
+		account.Primary = *req.Primary
+	}
+
+	return account, nil
+}
+
+// AuthorizeAccount loads an account the user may see, or with operate set, also
+// move money from
+func (s *AccountService) AuthorizeAccount(ctx context.Context, userID, accountID int64, operate bool) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, operate); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetPrimaryAccount retrieves the primary account of a user in a currency
+func (s *AccountService) GetPrimaryAccount(ctx context.Context, userID int64, currency string) (*models.Account, error) {
+	account, err := s.accountRepo.GetPrimary(ctx, userID, strings.ToUpper(currency))
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to get primary account")
+		return nil, errors.New("no primary account in this currency")
+	}
+	return account, nil
+}
+
+func (s *AccountService) Transfer(ctx context.Context, req *models.TransferRequest, client models.ClientInfo) error {
+	if req.ToAccountNumber != "" {
+		recipient, err := s.accountRepo.GetByNumber(ctx, req.ToAccountNumber)
+		if err != nil {
+			return errors.New("account not found")
+		}
+		if req.ToAccountID != 0 && req.ToAccountID != recipient.ID {
+			return errors.New("to_account_id and to_account_number refer to different accounts")
+		}
+		if recipient.ID == req.FromAccountID {
+			return errors.New("cannot transfer to the same account")
+		}
+		req.ToAccountID = recipient.ID
+	}
+
+	check, err := s.screenTransfer(ctx, req, client)
+	if err != nil {
+		return err
+	}
+
 	// Start a database transaction
-	tx, err := s.accountRepo.BeginTransaction()
+	tx, err := s.accountRepo.BeginTransaction(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Get source account
-	srcAccount, err := s.accountRepo.GetByID(req.FromAccountID)
+	if _, err := s.screenedTransferTx(ctx, tx, req, check); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// TransferTx screens a transfer between two accounts given by ID and makes it
+// within the given transaction, so that it commits together with the caller's
+// changes. A transfer held by the fraud rules returns a *fraud.HoldError and
+// is made once it is released.
+func (s *AccountService) TransferTx(ctx context.Context, tx repository.Tx, req *models.TransferRequest, client models.ClientInfo) (*models.Transaction, error) {
+	check, err := s.screenTransfer(ctx, req, client)
 	if err != nil {
-		return fmt.Errorf("failed to get source account: %w", err)
+		return nil, err
 	}
+	return s.screenedTransferTx(ctx, tx, req, check)
+}
 
-	// Get destination account
-	dstAccount, err := s.accountRepo.GetByID(req.ToAccountID)
+// screenedTransferTx makes a transfer the fraud rules allowed and records the
+// check against it
+func (s *AccountService) screenedTransferTx(ctx context.Context, tx repository.Tx, req *models.TransferRequest, check *models.FraudCheck) (*models.Transaction, error) {
+	transaction, err := s.transferTx(ctx, tx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get destination account: %w", err)
+		return nil, err
 	}
 
-	// Validate currencies match
+	if err := s.fraud.RecordTx(ctx, tx, check, transaction.ID); err != nil {
+		return nil, fmt.Errorf("failed to record fraud check: %w", err)
+	}
+	return transaction, nil
+}
+
+// screenTransfer runs the fraud rules on a transfer. Transfers that would fail
+// anyway, including those over the user's limits, are turned down before they
+// are screened, so they are never held.
+func (s *AccountService) screenTransfer(ctx context.Context, req *models.TransferRequest, client models.ClientInfo) (*models.FraudCheck, error) {
+	srcAccount, err := s.accountRepo.GetByID(ctx, req.FromAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	dstAccount, err := s.accountRepo.GetByID(ctx, req.ToAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if srcAccount.IsClosed() || dstAccount.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if srcAccount.IsDormant() {
+		return nil, ErrAccountDormant
+	}
 	if srcAccount.Currency != dstAccount.Currency {
-		return errors.New("currency mismatch between accounts")
+		return nil, errors.New("currency mismatch between accounts")
+	}
+	if srcAccount.AvailableBalance() < req.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+	if dstAccount.UserID != srcAccount.UserID {
+		if err := s.limits.Check(ctx, srcAccount.UserID, models.TransactionTypeTransfer, req.Amount); err != nil {
+			return nil, err
+		}
 	}
 
-	// Check if source account has sufficient funds
-	if srcAccount.Balance < req.Amount {
-		return errors.New("insufficient funds")
+	return s.fraud.Screen(ctx, &models.FraudOperation{
+		UserID:        srcAccount.UserID,
+		Type:          models.TransactionTypeTransfer,
+		FromAccountID: srcAccount.ID,
+		ToAccountID:   dstAccount.ID,
+		ToUserID:      dstAccount.UserID,
+		Amount:        req.Amount,
+		Currency:      srcAccount.Currency,
+		ClientIP:      client.IP,
+		ClientCountry: client.Country,
+	})
+}
+
+// transferTx moves money between two accounts within the given transaction
+func (s *AccountService) transferTx(ctx context.Context, tx repository.Tx, req *models.TransferRequest) (*models.Transaction, error) {
+	// Lock both accounts, lower ID first so that opposite transfers cannot deadlock
+	firstID, secondID := req.FromAccountID, req.ToAccountID
+	if secondID < firstID {
+		firstID, secondID = secondID, firstID
+	}
+	first, err := s.accountRepo.GetByIDForUpdate(ctx, tx, firstID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", firstID, err)
+	}
+	second, err := s.accountRepo.GetByIDForUpdate(ctx, tx, secondID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", secondID, err)
+	}
+	srcAccount, dstAccount := first, second
+	if srcAccount.ID != req.FromAccountID {
+		srcAccount, dstAccount = second, first
 	}
 
-	// Update balances
-	srcAccount.Balance -= req.Amount
-	dstAccount.Balance += req.Amount
+	// Either account may have been closed since the transfer was screened
+	if srcAccount.IsClosed() || dstAccount.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if srcAccount.IsDormant() {
+		return nil, ErrAccountDormant
+	}
 
-	// Update source account
-	if err := s.accountRepo.UpdateBalance(srcAccount.ID, srcAccount.Balance); err != nil {
-		return fmt.Errorf("failed to update source account balance: %w", err)
+	// Validate currencies match
+	if srcAccount.Currency != dstAccount.Currency {
+		return nil, errors.New("currency mismatch between accounts")
 	}
 
-	// Update destination account
-	if err := s.accountRepo.UpdateBalance(dstAccount.ID, dstAccount.Balance); err != nil {
-		return fmt.Errorf("failed to update destination account balance: %w", err)
+	// Check if source account has sufficient funds, including its overdraft limit
+	if srcAccount.AvailableBalance() < req.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+
+	// Transfers between the user's own accounts are not limited
+	if dstAccount.UserID != srcAccount.UserID {
+		if err := s.limits.CheckTx(ctx, tx, srcAccount.UserID, models.TransactionTypeTransfer, req.Amount); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create transaction record
+	// Post the transfer, which debits the source account and credits the destination
 	transaction := &models.Transaction{
 		FromAccountID: req.FromAccountID,
 		ToAccountID:   req.ToAccountID,
 		Amount:        req.Amount,
-		Type:          "transfer",
+		Type:          models.TransactionTypeTransfer,
 		CreatedAt:     time.Now(),
 	}
 
-	if err := s.accountRepo.CreateTransaction(transaction); err != nil {
-		return fmt.Errorf("failed to create transaction record: %w", err)
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		return nil, fmt.Errorf("failed to post transfer: %w", err)
 	}
+	srcAccount.Balance -= req.Amount
+	dstAccount.Balance += req.Amount
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Queue the notifications and the domain event in the same transaction, so they
+	// are sent if and only if the transfer is committed
+	if err := s.notifyTransferTx(ctx, tx, srcAccount, dstAccount, transaction); err != nil {
+		return nil, fmt.Errorf("failed to queue transfer notifications: %w", err)
+	}
+	if err := s.events.PublishTx(ctx, tx, events.TypeTransferCompleted, srcAccount.ID, events.TransferCompleted{
+		TransactionID: transaction.ID,
+		FromAccountID: srcAccount.ID,
+		FromUserID:    srcAccount.UserID,
+		ToAccountID:   dstAccount.ID,
+		ToUserID:      dstAccount.UserID,
+		Amount:        transaction.Amount,
+		Currency:      srcAccount.Currency,
+		CompletedAt:   transaction.CreatedAt.UTC(),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record transfer event: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// notifyTransferTx queues the webhook events of both account owners and the email
+// of the recipient in the transfer's transaction
+func (s *AccountService) notifyTransferTx(ctx context.Context, tx repository.Tx, src, dst *models.Account, transaction *models.Transaction) error {
+	if err := s.webhookService.PublishTx(ctx, tx, src.UserID, models.WebhookEventTransferCompleted, transaction); err != nil {
+		return err
+	}
+	if err := s.webhookService.PublishTx(ctx, tx, dst.UserID, models.WebhookEventTransferReceived, transaction); err != nil {
+		return err
 	}
 
-	return nil
+	content := fmt.Sprintf("Account %s has received a transfer of %.2f %s from account %s.", dst.MaskedNumber(), transaction.Amount, dst.Currency, src.MaskedNumber())
+	return s.notificationService.NotifyUserTx(ctx, tx, dst.UserID, "Incoming transfer", content)
 }
 
-func (s *AccountService) Deposit(accountID int64, amount float64) error {
-	account, err := s.accountRepo.GetByID(accountID)
+func (s *AccountService) Deposit(ctx context.Context, accountID int64, amount float64) error {
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The lock keeps the account from being closed before the deposit is posted
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, accountID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get account")
 		return errors.New("account not found")
 	}
-
-	newBalance := account.Balance + amount
-	if err := s.accountRepo.UpdateBalance(accountID, newBalance); err != nil {
-		s.logger.WithError(err).Error("Failed to update account balance")
-		return errors.New("internal server error")
+	if account.IsClosed() {
+		return errors.New("account is closed")
 	}
 
-	// Create transaction record
 	transaction := &models.Transaction{
-		ToAccountID: accountID,
+		ToAccountID: account.ID,
 		Amount:      amount,
-		Type:        "deposit",
+		Type:        models.TransactionTypeDeposit,
 		CreatedAt:   time.Now(),
 	}
 
-	if err := s.accountRepo.CreateTransaction(transaction); err != nil {
-		s.logger.WithError(err).Error("Failed to create transaction record")
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post deposit")
 		return errors.New("internal server error")
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-func (s *AccountService) Withdraw(accountID int64, amount float64) error {
-	account, err := s.accountRepo.GetByID(accountID)
+func (s *AccountService) Withdraw(ctx context.Context, accountID int64, amount float64, client models.ClientInfo) error {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get account")
 		return errors.New("account not found")
 	}
+	if account.IsClosed() {
+		return errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return ErrAccountDormant
+	}
 
-	if account.Balance < amount {
+	if account.AvailableBalance() < amount {
 		return errors.New("insufficient funds")
 	}
-
-	newBalance := account.Balance - amount
-	if err := s.accountRepo.UpdateBalance(accountID, newBalance); err != nil {
-		s.logger.WithError(err).Error("Failed to update account balance")
-		return errors.New("internal server error")
+	if err := s.limits.Check(ctx, account.UserID, models.TransactionTypeWithdrawal, amount); err != nil {
+		return err
 	}
 
-	// Create transaction record
-	transaction := &models.Transaction{
-		FromAccountID: accountID,
+	check, err := s.fraud.Screen(ctx, &models.FraudOperation{
+		UserID:        account.UserID,
+		Type:          models.TransactionTypeWithdrawal,
+		FromAccountID: account.ID,
 		Amount:        amount,
-		Type:          "withdrawal",
-		CreatedAt:     time.Now(),
+		Currency:      account.Currency,
+		ClientIP:      client.IP,
+		ClientCountry: client.Country,
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := s.accountRepo.CreateTransaction(transaction); err != nil {
-		s.logger.WithError(err).Error("Failed to create transaction record")
-		return errors.New("internal server error")
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
-}
-
-// Credit-related methods
+	transaction, err := s.withdrawTx(ctx, tx, accountID, amount)
+	if err != nil {
+		return err
+	}
 
-func (s *AccountService) CreateCredit(req *models.CreateCreditRequest) (*models.Credit, error) {
-	credit := &models.Credit{
-		UserID:          req.UserID,
-		AccountID:       req.AccountID,
-		Amount:          req.Amount,
-		InterestRate:    req.InterestRate,
-		TermMonths:      req.TermMonths,
-		RemainingAmount: req.Amount,
-		Status:          "ACTIVE",
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-	}
-
-	if err := s.creditRepo.Create(credit); err != nil {
-		s.logger.WithError(err).Error("Failed to create credit")
-		return nil, errors.New("internal server error")
+	if err := s.fraud.RecordTx(ctx, tx, check, transaction.ID); err != nil {
+		return fmt.Errorf("failed to record fraud check: %w", err)
 	}
 
-	// Generate payment schedule
-	schedule := models.GeneratePaymentSchedule(credit, time.Now())
-	for _, payment := range schedule {
-		payment.CreditID = credit.ID
-		if err := s.creditRepo.CreatePaymentSchedule(&payment); err != nil {
-			s.logger.WithError(err).Error("Failed to create payment schedule")
-			return nil, errors.New("internal server error")
-		}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return credit, nil
+	return nil
 }
 
-func (s *AccountService) GetCreditByID(creditID int64) (*models.Credit, error) {
-	credit, err := s.creditRepo.GetByID(creditID)
+// withdrawTx takes money out of an account within the given transaction
+func (s *AccountService) withdrawTx(ctx context.Context, tx repository.Tx, accountID int64, amount float64) (*models.Transaction, error) {
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, accountID)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get credit by ID")
-		return nil, errors.New("credit not found")
+		s.logger.WithError(err).Error("Failed to get account")
+		return nil, errors.New("account not found")
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
 	}
-	return credit, nil
-}
 
-func (s *AccountService) GetCreditsByUserID(userID int64) ([]*models.Credit, error) {
-	credits, err := s.creditRepo.GetByUserID(userID)
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to get credits by user ID")
+	if account.AvailableBalance() < amount {
+		return nil, errors.New("insufficient funds")
+	}
+
+	if err := s.limits.CheckTx(ctx, tx, account.UserID, models.TransactionTypeWithdrawal, amount); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		FromAccountID: accountID,
+		Amount:        amount,
+		Type:          models.TransactionTypeWithdrawal,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post withdrawal")
 		return nil, errors.New("internal server error")
 	}
-	return credits, nil
+
+	return transaction, nil
 }
 
-func (s *AccountService) PayCredit(creditID int64, amount float64) error {
-	credit, err := s.creditRepo.GetByID(creditID)
+// ReleaseHeldTransaction executes a transfer or withdrawal held by the fraud rules
+// once an administrator approves it. Balances are checked again at this point, so
+// a transaction the account can no longer cover stays held until it is rejected.
+func (s *AccountService) ReleaseHeldTransaction(ctx context.Context, adminID, checkID int64, comment string) (*models.FraudCheck, error) {
+	tx, err := s.accountRepo.BeginTransaction(ctx)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get credit")
-		return errors.New("credit not found")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if credit.Status != "ACTIVE" {
-		return errors.New("credit is not active")
+	check, err := s.fraud.LockHeldTx(ctx, tx, adminID, checkID)
+	if err != nil {
+		return nil, err
 	}
 
-	if amount <= 0 {
-		return errors.New("payment amount must be greater than zero")
+	var transaction *models.Transaction
+	if check.Type == models.TransactionTypeTransfer && check.ToAccountID != nil {
+		transaction, err = s.transferTx(ctx, tx, &models.TransferRequest{
+			FromAccountID: check.FromAccountID,
+			ToAccountID:   *check.ToAccountID,
+			Amount:        check.Amount,
+		})
+	} else {
+		transaction, err = s.withdrawTx(ctx, tx, check.FromAccountID, check.Amount)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	if amount > credit.RemainingAmount {
-		return errors.New("payment amount exceeds remaining credit amount")
+	if err := s.fraud.ReleaseTx(ctx, tx, adminID, check, transaction.ID, comment); err != nil {
+		return nil, err
 	}
 
-	// Start transaction
-	tx, err := s.creditRepo.BeginTransaction()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	defer tx.Rollback()
 
-	// Get next pending payment
-	schedule, err := s.creditRepo.GetPaymentSchedule(creditID)
+	return check, nil
+}
+
+// GetTransaction retrieves a transaction visible to the user, including its
+// conversion breakdown. Members of a shared account see its transactions.
+func (s *AccountService) GetTransaction(ctx context.Context, userID, transactionID int64) (*models.Transaction, error) {
+	transaction, err := s.accountRepo.GetTransactionByID(ctx, transactionID)
 	if err != nil {
-		return fmt.Errorf("failed to get payment schedule: %w", err)
+		return nil, errors.New("transaction not found")
 	}
 
-	var nextPayment *models.PaymentSchedule
-	for _, payment := range schedule {
-		if payment.Status == "PENDING" {
-			nextPayment = payment
-			break
+	for _, accountID := range []int64{transaction.FromAccountID, transaction.ToAccountID} {
+		if accountID == 0 {
+			continue
+		}
+		account, err := s.accountRepo.GetByID(ctx, accountID)
+		if err == nil && authorizeAccount(ctx, s.accountRepo, account, userID, false) == nil {
+			return transaction, nil
 		}
 	}
 
-	if nextPayment == nil {
-		return errors.New("no pending payments found")
+	return nil, errors.New("transaction not found")
+}
+
+// GetStatement retrieves the transactions of a user's account within a date range
+func (s *AccountService) GetStatement(ctx context.Context, userID, accountID int64, startDate, endDate time.Time) ([]*models.Transaction, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || authorizeAccount(ctx, s.accountRepo, account, userID, false) != nil {
+		return nil, errors.New("account not found")
 	}
 
-	// Update payment status
-	nextPayment.Status = "PAID"
-	if err := s.creditRepo.UpdatePaymentSchedule(nextPayment); err != nil {
-		return fmt.Errorf("failed to update payment schedule: %w", err)
+	transactions, err := s.accountRepo.GetTransactions(ctx, accountID, startDate, endDate)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account transactions")
+		return nil, errors.New("internal server error")
 	}
 
-	// Calculate new remaining amount and update credit status if needed
-	credit.RemainingAmount -= amount
-	if credit.RemainingAmount == 0 {
-		credit.Status = "COMPLETED"
-		if err := s.creditRepo.Update(credit); err != nil {
-			return fmt.Errorf("failed to update credit: %w", err)
-		}
+	return transactions, nil
+}
+
+// ListTransactionsByAccounts retrieves the latest transactions of several
+// accounts matching the filter, keyed by account ID. The caller has authorized
+// the accounts. Without a limit the default page size is listed per account.
+func (s *AccountService) ListTransactionsByAccounts(ctx context.Context, accountIDs []int64, filter models.TransactionFilter) (map[int64][]*models.Transaction, error) {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = pagination.DefaultLimit
+	case filter.Limit < 0 || filter.Limit > pagination.MaxLimit:
+		return nil, fmt.Errorf("limit must be between 1 and %d", pagination.MaxLimit)
+	}
+	if filter.MinAmount < 0 || filter.MaxAmount < 0 {
+		return nil, errors.New("amounts must not be negative")
+	}
+	if filter.MaxAmount > 0 && filter.MinAmount > filter.MaxAmount {
+		return nil, errors.New("minimum amount exceeds maximum amount")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, errors.New("start date is after end date")
+	}
+	if len(accountIDs) == 0 {
+		return map[int64][]*models.Transaction{}, nil
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	transactions, err := s.accountRepo.ListTransactionsByAccounts(ctx, accountIDs, filter)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list account transactions")
+		return nil, errors.New("internal server error")
 	}
+	return transactions, nil
+}
 
-	return nil
+// Credit-related methods
+
+func (s *AccountService) GetCreditByID(ctx context.Context, creditID int64) (*models.Credit, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get credit by ID")
+		return nil, errors.New("credit not found")
+	}
+	return credit, nil
+}
+
+func (s *AccountService) GetCreditsByUserID(ctx context.Context, userID int64) ([]*models.Credit, error) {
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get credits by user ID")
+		return nil, errors.New("internal server error")
+	}
+	return credits, nil
 }
 
 // TransactionAnalytics represents transaction analytics data
@@ -314,26 +750,55 @@ type TransactionAnalytics struct {
 	MaxAmount         float64        `json:"max_amount"`
 	MinAmount         float64        `json:"min_amount"`
 	TransactionsByDay map[string]int `json:"transactions_by_day"`
+
+	OverdraftLimit           float64 `json:"overdraft_limit"`
+	OverdraftUsed            float64 `json:"overdraft_used"`
+	OverdraftInterestCharged float64 `json:"overdraft_interest_charged"`
+
+	Payees []models.PayeeUsage `json:"payees,omitempty"` // transfers to saved payees, the most used first
 }
 
 // GetTransactionAnalytics retrieves transaction analytics for a user
-func (s *AccountService) GetTransactionAnalytics(userID int64, startDate, endDate time.Time) (*TransactionAnalytics, error) {
+func (s *AccountService) GetTransactionAnalytics(ctx context.Context, userID int64, startDate, endDate time.Time) (*TransactionAnalytics, error) {
 	// Get user accounts
-	accounts, err := s.accountRepo.GetByUserID(userID)
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user accounts")
 		return nil, err
 	}
 
+	versions := make([]int64, 0, 2*len(accounts))
+	for _, account := range accounts {
+		versions = append(versions, account.ID, account.Version)
+	}
+	key := fmt.Sprintf("analytics:transactions:%d:%s:%s:%s",
+		userID, startDate.Format(time.RFC3339), endDate.Format(time.RFC3339), versionStamp(versions...))
+	cached := &TransactionAnalytics{}
+	if s.cache.Get(ctx, key, cached) {
+		return cached, nil
+	}
+
 	// Get transactions for each account
 	var totalTransactions int
 	var totalAmount float64
 	var maxAmount float64
 	var minAmount float64
+	var overdraftLimit, overdraftUsed, overdraftInterest float64
 	transactionsByDay := make(map[string]int)
 
 	for _, account := range accounts {
-		transactions, err := s.accountRepo.GetTransactions(account.ID, startDate, endDate)
+		overdraftLimit += account.OverdraftLimit
+		overdraftUsed += account.OverdraftUsed()
+		if account.OverdraftLimit > 0 || account.Balance < 0 {
+			interest, err := s.accountRepo.GetTransactionTotalByType(ctx, account.ID, models.TransactionTypeOverdraftInterest, startDate, endDate)
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to get overdraft interest")
+				return nil, err
+			}
+			overdraftInterest += interest
+		}
+
+		transactions, err := s.accountRepo.GetTransactions(ctx, account.ID, startDate, endDate)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to get account transactions")
 			return nil, err
@@ -361,12 +826,113 @@ func (s *AccountService) GetTransactionAnalytics(userID int64, startDate, endDat
 		averageAmount = totalAmount / float64(totalTransactions)
 	}
 
-	return &TransactionAnalytics{
+	analytics := &TransactionAnalytics{
 		TotalTransactions: totalTransactions,
 		TotalAmount:       totalAmount,
 		AverageAmount:     averageAmount,
 		MaxAmount:         maxAmount,
 		MinAmount:         minAmount,
 		TransactionsByDay: transactionsByDay,
-	}, nil
+
+		OverdraftLimit:           overdraftLimit,
+		OverdraftUsed:            overdraftUsed,
+		OverdraftInterestCharged: overdraftInterest,
+	}
+	if s.payees != nil {
+		if analytics.Payees, err = s.payees.GetUsage(ctx, userID, startDate, endDate); err != nil {
+			s.logger.WithError(err).Error("Failed to get payee usage")
+			return nil, err
+		}
+	}
+	s.cache.Set(ctx, key, analytics, s.analyticsTTL)
+	return analytics, nil
+}
+
+// versionStamp condenses ID and version pairs into a short cache key part, which
+// changes whenever one of the entities is updated
+func versionStamp(pairs ...int64) string {
+	h := sha256.New()
+	for _, v := range pairs {
+		fmt.Fprintf(h, "%d,", v)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Overdraft-related methods
+
+// SetOverdraft configures the overdraft limit and interest rate of an account
+func (s *AccountService) SetOverdraft(ctx context.Context, adminID, accountID int64, req *models.SetOverdraftRequest) (*models.Account, error) {
+	if req.Limit < 0 || req.InterestRate < 0 {
+		return nil, errors.New("overdraft limit and interest rate must not be negative")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account")
+		return nil, errors.New("account not found")
+	}
+
+	if account.Balance < -req.Limit {
+		return nil, errors.New("overdraft limit is below the amount already drawn")
+	}
+
+	if err := s.accountRepo.SetOverdraft(ctx, accountID, req.Limit, req.InterestRate); err != nil {
+		s.logger.WithError(err).Error("Failed to set overdraft")
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(map[string]float64{
+		"previous_limit":         account.OverdraftLimit,
+		"previous_interest_rate": account.OverdraftInterestRate,
+		"limit":                  req.Limit,
+		"interest_rate":          req.InterestRate,
+	})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionOverdraftChanged,
+		EntityType: "account",
+		EntityID:   accountID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit overdraft change")
+	}
+
+	account.OverdraftLimit = req.Limit
+	account.OverdraftInterestRate = req.InterestRate
+	return account, nil
+}
+
+// AccrueOverdraftInterest charges daily interest on overdrawn balances for every
+// full day elapsed since the previous accrual of each account
+func (s *AccountService) AccrueOverdraftInterest(ctx context.Context, now time.Time) error {
+	accounts, err := s.accountRepo.GetWithOverdraftFacility(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get accounts with overdraft")
+		return err
+	}
+
+	for _, account := range accounts {
+		days := int(now.Sub(account.OverdraftInterestAccruedAt).Hours() / 24)
+		if days < 1 {
+			continue
+		}
+
+		var interest float64
+		if account.Balance < 0 && account.OverdraftInterestRate > 0 {
+			interest = -account.Balance * account.OverdraftInterestRate / 100 / 365 * float64(days)
+			interest = math.Round(interest*100) / 100
+		}
+
+		accruedAt := account.OverdraftInterestAccruedAt.AddDate(0, 0, days)
+		if err := s.accountRepo.ChargeOverdraftInterest(ctx, account.ID, interest, accruedAt); err != nil {
+			s.logger.WithError(err).Errorf("Failed to accrue overdraft interest for account %d", account.ID)
+			continue
+		}
+
+		if interest > 0 {
+			s.logger.Infof("Charged %.2f overdraft interest to account %d", interest, account.ID)
+		}
+	}
+
+	return nil
 }
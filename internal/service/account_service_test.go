@@ -0,0 +1,471 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+// accountBook is an in-memory account table behind a mock.AccountStore. Reads
+// outside a transaction see accounts, locked reads see locked where it has an
+// entry, which stands for a concurrent change made between the two.
+type accountBook struct {
+	accounts     map[int64]models.Account
+	locked       map[int64]models.Account
+	lockOrder    []int64
+	balances     map[int64]float64
+	transactions []*models.Transaction
+}
+
+func newAccountBook(accounts ...models.Account) *accountBook {
+	b := &accountBook{
+		accounts: make(map[int64]models.Account),
+		locked:   make(map[int64]models.Account),
+		balances: make(map[int64]float64),
+	}
+	for _, account := range accounts {
+		b.accounts[account.ID] = account
+	}
+	return b
+}
+
+func (b *accountBook) store(tx repository.Tx) *mock.AccountStore {
+	return &mock.AccountStore{
+		BeginTransactionFunc: beginTx(tx),
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Account, error) {
+			account, ok := b.accounts[id]
+			if !ok {
+				return nil, errors.New("account not found")
+			}
+			return &account, nil
+		},
+		GetByNumberFunc: func(_ context.Context, number string) (*models.Account, error) {
+			for _, account := range b.accounts {
+				if account.Number == number {
+					return &account, nil
+				}
+			}
+			return nil, errors.New("account not found")
+		},
+		GetByIDForUpdateFunc: func(_ context.Context, _ repository.Tx, id int64) (*models.Account, error) {
+			b.lockOrder = append(b.lockOrder, id)
+			account, ok := b.locked[id]
+			if !ok {
+				if account, ok = b.accounts[id]; !ok {
+					return nil, errors.New("account not found")
+				}
+			}
+			return &account, nil
+		},
+		PostTransactionTxFunc: func(_ context.Context, _ repository.Tx, transaction *models.Transaction) error {
+			entries, err := transaction.LedgerEntries()
+			if err != nil {
+				return err
+			}
+			transaction.ID = int64(len(b.transactions) + 1)
+			b.transactions = append(b.transactions, transaction)
+			for _, entry := range entries {
+				if entry.AccountID != 0 {
+					b.balances[entry.AccountID] = b.balance(entry.AccountID) + entry.BalanceDelta()
+				}
+			}
+			return nil
+		},
+		GetCardPaymentTotalTxFunc: func(context.Context, repository.Tx, int64, time.Time) (float64, error) {
+			return 0, nil
+		},
+	}
+}
+
+// balance returns the balance of an account as the transaction under test sees it
+func (b *accountBook) balance(id int64) float64 {
+	if balance, ok := b.balances[id]; ok {
+		return balance
+	}
+	if account, ok := b.locked[id]; ok {
+		return account.Balance
+	}
+	return b.accounts[id].Balance
+}
+
+func newTestAccountService(t *testing.T, env *testEnv, accounts repository.AccountStore) *AccountService {
+	t.Helper()
+	jobRunner := jobs.NewRunner(repository.NewJobRepository(env.db, env.logger), &env.cfg.Jobs, env.logger)
+	webhookService := NewWebhookService(repository.NewWebhookRepository(env.db, env.logger), jobRunner, env.relay, env.logger)
+	notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+		repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+	fraudService := fraud.NewService(&config.FraudConfig{}, repository.NewFraudRepository(env.db, env.logger),
+		repository.NewAuditRepository(env.db, env.logger), env.logger)
+
+	// Transfers between different users would check the user limits, which the
+	// cases below only reach for transfers refused before that
+	return NewAccountService(accounts, &mock.CreditStore{}, &mock.UserStore{}, repository.NewAuditRepository(env.db, env.logger),
+		notificationService, webhookService, env.publisher(t), fraudService, nil, &env.cfg.Bank, env.logger)
+}
+
+func TestAccountServiceTransfer(t *testing.T) {
+	dormantSince := time.Now().AddDate(0, -2, 0)
+	tests := []struct {
+		name      string
+		accounts  []models.Account
+		locked    []models.Account
+		req       models.TransferRequest
+		wantErr   string
+		balances  map[int64]float64
+		lockOrder []int64
+	}{
+		{
+			name: "moves funds between own accounts",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Balance: 10, Currency: "RUB"},
+			},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			balances:  map[int64]float64{1: 70, 2: 40},
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name: "draws on the overdraft",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 10, OverdraftLimit: 50, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 40},
+			balances:  map[int64]float64{1: -30, 2: 40},
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name: "locks the lower account id first",
+			accounts: []models.Account{
+				{ID: 5, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			req:       models.TransferRequest{FromAccountID: 5, ToAccountID: 2, Amount: 25},
+			balances:  map[int64]float64{5: 75, 2: 25},
+			lockOrder: []int64{2, 5},
+		},
+		{
+			name: "rejects insufficient funds",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 20, Currency: "RUB"},
+				{ID: 2, UserID: 8, Currency: "RUB"},
+			},
+			req:     models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr: "insufficient funds",
+		},
+		{
+			name: "leaves held funds alone",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, HeldAmount: 80, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			req:     models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr: "insufficient funds",
+		},
+		{
+			name: "rechecks the balance under the lock",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			locked:    []models.Account{{ID: 1, UserID: 7, Balance: 20, Currency: "RUB"}},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr:   "insufficient funds",
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name: "rejects a currency mismatch",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "USD"},
+			},
+			req:     models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr: "currency mismatch between accounts",
+		},
+		{
+			name: "rejects a dormant sending account",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB", DormantSince: &dormantSince},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			req:     models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr: ErrAccountDormant.Error(),
+		},
+		{
+			name: "rechecks dormancy under the lock",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB"},
+			},
+			locked:    []models.Account{{ID: 1, UserID: 7, Balance: 100, Currency: "RUB", DormantSince: &dormantSince}},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr:   ErrAccountDormant.Error(),
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name: "credits a dormant receiving account",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Currency: "RUB", DormantSince: &dormantSince},
+			},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			balances:  map[int64]float64{1: 70, 2: 30},
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name:     "rejects an unknown account",
+			accounts: []models.Account{{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"}},
+			req:      models.TransferRequest{FromAccountID: 1, ToAccountID: 2, Amount: 30},
+			wantErr:  "account not found",
+		},
+		{
+			name: "finds the recipient by account number",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Number: "40817810600001234567", Balance: 100, Currency: "RUB"},
+				{ID: 2, UserID: 7, Number: "40817810300007654321", Currency: "RUB"},
+			},
+			req:       models.TransferRequest{FromAccountID: 1, ToAccountNumber: "40817810300007654321", Amount: 30},
+			balances:  map[int64]float64{1: 70, 2: 30},
+			lockOrder: []int64{1, 2},
+		},
+		{
+			name:     "rejects an unknown account number",
+			accounts: []models.Account{{ID: 1, UserID: 7, Balance: 100, Currency: "RUB"}},
+			req:      models.TransferRequest{FromAccountID: 1, ToAccountNumber: "40817810300007654321", Amount: 30},
+			wantErr:  "account not found",
+		},
+		{
+			name: "rejects an account number of the sending account",
+			accounts: []models.Account{
+				{ID: 1, UserID: 7, Number: "40817810600001234567", Balance: 100, Currency: "RUB"},
+			},
+			req:     models.TransferRequest{FromAccountID: 1, ToAccountNumber: "40817810600001234567", Amount: 30},
+			wantErr: "cannot transfer to the same account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			tx := env.tx()
+			book := newAccountBook(tt.accounts...)
+			for _, account := range tt.locked {
+				book.locked[account.ID] = account
+			}
+			s := newTestAccountService(t, env, book.store(tx))
+
+			err := s.Transfer(context.Background(), &tt.req, models.ClientInfo{})
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Transfer() error = %v, want %q", err, tt.wantErr)
+				}
+				if tx.Committed || len(book.balances) != 0 || len(book.transactions) != 0 {
+					t.Errorf("refused transfer changed state: committed %v, balances %v, transactions %d",
+						tx.Committed, book.balances, len(book.transactions))
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Transfer() error = %v", err)
+				}
+				if !tx.Committed {
+					t.Error("transaction was not committed")
+				}
+				if len(book.balances) != len(tt.balances) {
+					t.Errorf("balances = %v, want %v", book.balances, tt.balances)
+				}
+				for id, want := range tt.balances {
+					if got := book.balances[id]; got != want {
+						t.Errorf("balance of account %d = %v, want %v", id, got, want)
+					}
+				}
+				if len(book.transactions) != 1 || book.transactions[0].Amount != tt.req.Amount ||
+					book.transactions[0].Type != models.TransactionTypeTransfer {
+					t.Errorf("transactions = %+v, want one transfer of %v", book.transactions, tt.req.Amount)
+				}
+			}
+			if len(book.lockOrder) != len(tt.lockOrder) {
+				t.Fatalf("lock order = %v, want %v", book.lockOrder, tt.lockOrder)
+			}
+			for i := range tt.lockOrder {
+				if book.lockOrder[i] != tt.lockOrder[i] {
+					t.Fatalf("lock order = %v, want %v", book.lockOrder, tt.lockOrder)
+				}
+			}
+		})
+	}
+}
+
+// memoryCache is a cache.Cache kept in a map, encoding the values like Redis does
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(_ context.Context, key string, dest interface{}) bool {
+	data, ok := c[key]
+	return ok && gob.NewDecoder(bytes.NewReader(data)).Decode(dest) == nil
+}
+
+func (c memoryCache) Set(_ context.Context, key string, value interface{}, _ time.Duration) {
+	var buf bytes.Buffer
+	if gob.NewEncoder(&buf).Encode(value) == nil {
+		c[key] = buf.Bytes()
+	}
+}
+
+func (c memoryCache) Delete(_ context.Context, keys ...string) {
+	for _, key := range keys {
+		delete(c, key)
+	}
+}
+
+func (c memoryCache) Close() error { return nil }
+
+func TestAccountServiceTransactionAnalyticsCache(t *testing.T) {
+	env := newTestEnv(t)
+	account := &models.Account{ID: 1, UserID: 7, Balance: 100, Currency: "RUB", Version: 1}
+	transactions := []*models.Transaction{{ID: 1, ToAccountID: 1, Amount: 100, Type: models.TransactionTypeDeposit}}
+	var scans int
+
+	store := &mock.AccountStore{
+		GetByUserIDFunc: func(context.Context, int64) ([]*models.Account, error) {
+			return []*models.Account{account}, nil
+		},
+		GetTransactionsFunc: func(context.Context, int64, time.Time, time.Time) ([]*models.Transaction, error) {
+			scans++
+			return transactions, nil
+		},
+	}
+	s := newTestAccountService(t, env, store)
+	s.SetCache(memoryCache{}, time.Minute)
+
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		analytics, err := s.GetTransactionAnalytics(context.Background(), 7, start, end)
+		if err != nil {
+			t.Fatalf("GetTransactionAnalytics() error = %v", err)
+		}
+		if analytics.TotalTransactions != 1 {
+			t.Fatalf("TotalTransactions = %d, want 1", analytics.TotalTransactions)
+		}
+	}
+	if scans != 1 {
+		t.Fatalf("transactions scanned %d times, want 1 with the second call cached", scans)
+	}
+
+	// A new transaction bumps the account version, which the cached entry misses
+	transactions = append(transactions, &models.Transaction{ID: 2, FromAccountID: 1, Amount: 40, Type: models.TransactionTypeWithdrawal})
+	account.Version++
+	analytics, err := s.GetTransactionAnalytics(context.Background(), 7, start, end)
+	if err != nil {
+		t.Fatalf("GetTransactionAnalytics() error = %v", err)
+	}
+	if scans != 2 || analytics.TotalTransactions != 2 {
+		t.Fatalf("after a balance change: scans = %d, TotalTransactions = %d, want 2 and 2", scans, analytics.TotalTransactions)
+	}
+}
+
+func TestAccountServiceListTransactionsByAccounts(t *testing.T) {
+	env := newTestEnv(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		ids       []int64
+		filter    models.TransactionFilter
+		wantErr   bool
+		wantLimit int
+		wantQuery bool
+	}{
+		{name: "default limit", ids: []int64{1, 2}, wantLimit: 20, wantQuery: true},
+		{name: "given limit", ids: []int64{1}, filter: models.TransactionFilter{Limit: 5}, wantLimit: 5, wantQuery: true},
+		{name: "limit above maximum", ids: []int64{1}, filter: models.TransactionFilter{Limit: 101}, wantErr: true},
+		{name: "negative limit", ids: []int64{1}, filter: models.TransactionFilter{Limit: -1}, wantErr: true},
+		{name: "minimum above maximum", ids: []int64{1}, filter: models.TransactionFilter{MinAmount: 50, MaxAmount: 10}, wantErr: true},
+		{name: "negative amount", ids: []int64{1}, filter: models.TransactionFilter{MinAmount: -1}, wantErr: true},
+		{name: "start after end", ids: []int64{1}, filter: models.TransactionFilter{From: day, To: day.AddDate(0, 0, -1)}, wantErr: true},
+		{name: "no accounts", ids: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queried bool
+			var gotLimit int
+			store := &mock.AccountStore{
+				ListTransactionsByAccountsFunc: func(_ context.Context, ids []int64, filter models.TransactionFilter) (map[int64][]*models.Transaction, error) {
+					queried, gotLimit = true, filter.Limit
+					return map[int64][]*models.Transaction{ids[0]: {{ID: 1, ToAccountID: ids[0]}}}, nil
+				},
+			}
+			s := newTestAccountService(t, env, store)
+
+			transactions, err := s.ListTransactionsByAccounts(context.Background(), tt.ids, tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListTransactionsByAccounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if queried != tt.wantQuery {
+				t.Fatalf("store queried = %v, want %v", queried, tt.wantQuery)
+			}
+			if tt.wantQuery && (gotLimit != tt.wantLimit || len(transactions[tt.ids[0]]) != 1) {
+				t.Fatalf("limit = %d, transactions = %v, want limit %d and the stored transaction", gotLimit, transactions, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestAccountServiceGetTransaction(t *testing.T) {
+	members := map[int64]models.AccountRole{8: models.AccountRoleViewer}
+	tests := []struct {
+		name    string
+		userID  int64
+		wantErr bool
+	}{
+		{name: "shows the sender", userID: 7},
+		{name: "shows the recipient", userID: 9},
+		{name: "shows a member of the sending account", userID: 8},
+		{name: "hides from a user without access", userID: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			accounts := newAccountBook(
+				models.Account{ID: 1, UserID: 7, Currency: "RUB"},
+				models.Account{ID: 2, UserID: 9, Currency: "RUB"},
+			).store(env.tx())
+			accounts.GetTransactionByIDFunc = func(_ context.Context, id int64) (*models.Transaction, error) {
+				return &models.Transaction{ID: id, FromAccountID: 1, ToAccountID: 2, Amount: 100}, nil
+			}
+			accounts.GetMemberRoleFunc = func(_ context.Context, accountID, userID int64) (models.AccountRole, error) {
+				role, ok := members[userID]
+				if accountID != 1 || !ok {
+					return "", errors.New("account member not found")
+				}
+				return role, nil
+			}
+			s := newTestAccountService(t, env, accounts)
+
+			transaction, err := s.GetTransaction(context.Background(), tt.userID, 5)
+
+			if tt.wantErr {
+				if err == nil || err.Error() != "transaction not found" {
+					t.Fatalf("GetTransaction() error = %v, want %q", err, "transaction not found")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetTransaction() error = %v", err)
+			}
+			if transaction.ID != 5 {
+				t.Errorf("transaction = %+v, want transaction 5", transaction)
+			}
+		})
+	}
+}
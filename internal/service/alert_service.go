@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// alertScanBatchSize is the number of transactions evaluated at a time
+	alertScanBatchSize = 500
+	// alertScanSettleTime keeps the scan off the transactions posted in the last
+	// moments, so one committed late with a lower ID is not skipped
+	alertScanSettleTime = time.Minute
+)
+
+// AlertService manages the alerts users set on their accounts and evaluates
+// them: every posted transaction is checked against the incoming payment and
+// card payment alerts of its accounts, and the balance alerts against the
+// balances. Alerts are sent through the notification channels of the user.
+type AlertService struct {
+	repo                *repository.AlertRepository
+	accountRepo         repository.AccountStore
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewAlertService creates a new AlertService instance
+func NewAlertService(
+	repo *repository.AlertRepository,
+	accountRepo repository.AccountStore,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *AlertService {
+	return &AlertService{
+		repo:                repo,
+		accountRepo:         accountRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// CreateAlert sets up an alert of the user on an account they have access to
+func (s *AlertService) CreateAlert(ctx context.Context, userID int64, req *models.CreateAlertRequest) (*models.Alert, error) {
+	alert := &models.Alert{
+		UserID:    userID,
+		AccountID: req.AccountID,
+		Type:      req.Type,
+		Threshold: req.Threshold,
+		Enabled:   true,
+	}
+	if err := checkAlert(alert); err != nil {
+		return nil, err
+	}
+
+	if alert.AccountID != nil {
+		account, err := s.accountRepo.GetByID(ctx, *alert.AccountID)
+		if err != nil {
+			return nil, errors.New("account not found")
+		}
+		if err := authorizeAccount(ctx, s.accountRepo, account, userID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Create(ctx, alert); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return alert, nil
+}
+
+// GetAlert retrieves an alert of the user
+func (s *AlertService) GetAlert(ctx context.Context, userID, id int64) (*models.Alert, error) {
+	alert, err := s.repo.GetByID(ctx, userID, id)
+	if err != nil {
+		if err.Error() == "alert not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return alert, nil
+}
+
+// ListAlerts retrieves a page of the alerts of the user and how many there are
+func (s *AlertService) ListAlerts(ctx context.Context, userID int64, p pagination.Params) ([]*models.Alert, int, error) {
+	alerts, total, err := s.repo.ListByUser(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return alerts, total, nil
+}
+
+// UpdateAlert changes the threshold of an alert of the user or switches it on
+// or off
+func (s *AlertService) UpdateAlert(ctx context.Context, userID, id int64, req *models.UpdateAlertRequest) (*models.Alert, error) {
+	alert, err := s.GetAlert(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Threshold != nil {
+		alert.Threshold = req.Threshold
+	}
+	if req.Enabled != nil {
+		alert.Enabled = *req.Enabled
+	}
+	if err := checkAlert(alert); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, alert); err != nil {
+		if err.Error() == "alert not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return alert, nil
+}
+
+// DeleteAlert removes an alert of the user
+func (s *AlertService) DeleteAlert(ctx context.Context, userID, id int64) error {
+	if err := s.repo.Delete(ctx, userID, id); err != nil {
+		if err.Error() == "alert not found" {
+			return err
+		}
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// checkAlert checks that an alert has the account and threshold its type needs
+func checkAlert(alert *models.Alert) error {
+	switch alert.Type {
+	case models.AlertTypeBalanceBelow, models.AlertTypeIncomingAbove:
+		if alert.AccountID == nil {
+			return errors.New("account is required for balance and incoming payment alerts")
+		}
+		if alert.Threshold == nil {
+			return errors.New("threshold is required for balance and incoming payment alerts")
+		}
+	case models.AlertTypeCardAbroad:
+		if alert.Threshold != nil {
+			return errors.New("card abroad alerts take no threshold")
+		}
+	default:
+		return errors.New("alert type must be balance_below, incoming_above or card_abroad")
+	}
+	return nil
+}
+
+// Evaluate sends the alerts fired by the transactions posted since the previous
+// run and by the balances that fell below their thresholds, and returns how
+// many were sent
+func (s *AlertService) Evaluate(ctx context.Context, now time.Time) (int, error) {
+	sent, err := s.scanTransactions(ctx, now)
+	if err != nil {
+		return sent, err
+	}
+	balances, err := s.checkBalances(ctx, now)
+	return sent + balances, err
+}
+
+// scanTransactions evaluates the transaction alerts for the transactions posted
+// since the previous scan. The alerts of a batch are queued with the move of the
+// scan state, so each is sent once; a scan stopped by an error resumes with the
+// batch it failed on.
+func (s *AlertService) scanTransactions(ctx context.Context, now time.Time) (int, error) {
+	lastID, err := s.repo.LastScanned(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for {
+		transactions, err := s.repo.ListTransactionsAfter(ctx, lastID, now.Add(-alertScanSettleTime), alertScanBatchSize)
+		if err != nil || len(transactions) == 0 {
+			return sent, err
+		}
+
+		var accountIDs []int64
+		for _, t := range transactions {
+			for _, id := range []int64{t.FromAccountID, t.ToAccountID} {
+				if id != 0 {
+					accountIDs = append(accountIDs, id)
+				}
+			}
+		}
+		alerts, err := s.repo.ListForAccounts(ctx, accountIDs)
+		if err != nil {
+			return sent, err
+		}
+
+		lastID = transactions[len(transactions)-1].ID
+		n, err := s.sendTransactionAlerts(ctx, transactions, alerts, lastID)
+		if err != nil {
+			return sent, fmt.Errorf("failed to send alerts up to transaction %d: %w", lastID, err)
+		}
+		sent += n
+
+		if len(transactions) < alertScanBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// sendTransactionAlerts queues the alerts fired by a batch of transactions and
+// moves the scan state past it in one database transaction
+func (s *AlertService) sendTransactionAlerts(ctx context.Context, transactions []*models.AlertTransaction, alerts map[int64][]*models.Alert, lastID int64) (int, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sent := 0
+	fired := make(map[int64]time.Time)
+	for _, t := range transactions {
+		for _, accountID := range []int64{t.FromAccountID, t.ToAccountID} {
+			for _, alert := range alerts[accountID] {
+				if !alertFires(alert, t, accountID) {
+					continue
+				}
+				subject, content := transactionAlertMessage(alert, t, accountID)
+				if err := s.notificationService.NotifyUserTx(ctx, tx, alert.UserID, subject, content); err != nil {
+					return 0, err
+				}
+				fired[alert.ID] = t.CreatedAt
+				sent++
+			}
+		}
+	}
+
+	if err := s.repo.SaveScanTx(ctx, tx, fired, lastID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
+
+// alertFires reports whether a transaction alert fires for a transaction of the
+// account it watches: an incoming payment alert for money arriving at the
+// account above the threshold, a card payment alert for a card payment from the
+// account at a merchant abroad
+func alertFires(alert *models.Alert, t *models.AlertTransaction, accountID int64) bool {
+	switch alert.Type {
+	case models.AlertTypeIncomingAbove:
+		return t.ToAccountID == accountID && alert.Threshold != nil && t.Amount > *alert.Threshold
+	case models.AlertTypeCardAbroad:
+		return t.FromAccountID == accountID && t.Type == models.TransactionTypeCardPayment && models.Abroad(t.MerchantCountry)
+	}
+	return false
+}
+
+func transactionAlertMessage(alert *models.Alert, t *models.AlertTransaction, accountID int64) (string, string) {
+	if alert.Type == models.AlertTypeCardAbroad {
+		return "Card payment abroad", fmt.Sprintf("A card payment of %.2f %s was made from account #%d at %s (%s).",
+			t.Amount, t.Currency, accountID, t.Description, t.MerchantCountry)
+	}
+	return "Incoming payment", fmt.Sprintf("Account #%d has received %.2f %s, above your alert threshold of %.2f %s.",
+		accountID, t.Amount, t.Currency, *alert.Threshold, t.Currency)
+}
+
+// checkBalances sends the balance alerts whose account balance fell below the
+// threshold and rearms the ones whose balance recovered
+func (s *AlertService) checkBalances(ctx context.Context, now time.Time) (int, error) {
+	crossings, err := s.repo.ListBalanceCrossings(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, crossing := range crossings {
+		fired, err := s.crossBalance(ctx, crossing, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to evaluate balance alert %d", crossing.Alert.ID)
+			continue
+		}
+		if fired {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// crossBalance records that the balance of a balance alert crossed its threshold
+// and, when it fell below it, queues the alert. It reports whether the alert was
+// sent.
+func (s *AlertService) crossBalance(ctx context.Context, crossing *models.AlertBalance, now time.Time) (bool, error) {
+	alert := crossing.Alert
+	alert.Triggered = !alert.Triggered
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	updated, err := s.repo.SetTriggeredTx(ctx, tx, alert, now)
+	if err != nil || !updated {
+		return false, err
+	}
+	if alert.Triggered {
+		content := fmt.Sprintf("The balance of account #%d is %.2f %s, below your alert threshold of %.2f %s.",
+			*alert.AccountID, crossing.Balance, crossing.Currency, *alert.Threshold, crossing.Currency)
+		if err := s.notificationService.NotifyUserTx(ctx, tx, alert.UserID, "Low balance", content); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return alert.Triggered, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestAlertFires(t *testing.T) {
+	threshold := 1000.0
+	incoming := &models.Alert{Type: models.AlertTypeIncomingAbove, Threshold: &threshold}
+	abroad := &models.Alert{Type: models.AlertTypeCardAbroad}
+
+	tests := []struct {
+		name      string
+		alert     *models.Alert
+		t         *models.AlertTransaction
+		accountID int64
+		want      bool
+	}{
+		{name: "incoming above threshold", alert: incoming, t: &models.AlertTransaction{Type: models.TransactionTypeTransfer, FromAccountID: 2, ToAccountID: 1, Amount: 1500}, accountID: 1, want: true},
+		{name: "incoming at threshold", alert: incoming, t: &models.AlertTransaction{Type: models.TransactionTypeTransfer, FromAccountID: 2, ToAccountID: 1, Amount: 1000}, accountID: 1},
+		{name: "outgoing above threshold", alert: incoming, t: &models.AlertTransaction{Type: models.TransactionTypeTransfer, FromAccountID: 1, ToAccountID: 2, Amount: 1500}, accountID: 1},
+		{name: "card payment abroad", alert: abroad, t: &models.AlertTransaction{Type: models.TransactionTypeCardPayment, FromAccountID: 1, MerchantCountry: "TR"}, accountID: 1, want: true},
+		{name: "card payment at home", alert: abroad, t: &models.AlertTransaction{Type: models.TransactionTypeCardPayment, FromAccountID: 1, MerchantCountry: "RU"}, accountID: 1},
+		{name: "card payment of unknown country", alert: abroad, t: &models.AlertTransaction{Type: models.TransactionTypeCardPayment, FromAccountID: 1}, accountID: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alertFires(tt.alert, tt.t, tt.accountID); got != tt.want {
+				t.Errorf("alertFires() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAlert(t *testing.T) {
+	accountID := int64(1)
+	threshold := 500.0
+
+	tests := []struct {
+		name    string
+		alert   *models.Alert
+		wantErr bool
+	}{
+		{name: "balance alert", alert: &models.Alert{Type: models.AlertTypeBalanceBelow, AccountID: &accountID, Threshold: &threshold}},
+		{name: "balance alert without account", alert: &models.Alert{Type: models.AlertTypeBalanceBelow, Threshold: &threshold}, wantErr: true},
+		{name: "incoming alert without threshold", alert: &models.Alert{Type: models.AlertTypeIncomingAbove, AccountID: &accountID}, wantErr: true},
+		{name: "card alert on every account", alert: &models.Alert{Type: models.AlertTypeCardAbroad}},
+		{name: "card alert with threshold", alert: &models.Alert{Type: models.AlertTypeCardAbroad, Threshold: &threshold}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkAlert(tt.alert); (err != nil) != tt.wantErr {
+				t.Errorf("checkAlert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to recognise
+const apiKeyPrefix = "abk_"
+
+// apiKeyDisplayLength is how much of a key is kept in the clear to tell keys apart
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+// APIKeyService issues, rotates and revokes the API keys of machine-to-machine
+// clients and authenticates the requests made with them
+type APIKeyService struct {
+	repo                *repository.APIKeyRepository
+	userRepo            repository.UserStore
+	auditRepo           *repository.AuditRepository
+	rotationGracePeriod time.Duration
+	logger              *logrus.Logger
+}
+
+// NewAPIKeyService creates a new APIKeyService instance. A rotated key keeps
+// working for rotationGracePeriod so clients can switch to its successor.
+func NewAPIKeyService(
+	repo *repository.APIKeyRepository,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	rotationGracePeriod time.Duration,
+	logger *logrus.Logger,
+) *APIKeyService {
+	return &APIKeyService{
+		repo:                repo,
+		userRepo:            userRepo,
+		auditRepo:           auditRepo,
+		rotationGracePeriod: rotationGracePeriod,
+		logger:              logger,
+	}
+}
+
+// hashAPIKey returns the hash a key is stored and looked up by. Keys are random
+// and long, so an unsalted hash is enough to keep them from being read back.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random key
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(secret), nil
+}
+
+// Issue issues a key for a user. The key and its signing key are only returned
+// here.
+func (s *APIKeyService) Issue(ctx context.Context, adminID int64, req *models.CreateAPIKeyRequest) (*models.IssuedAPIKey, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+	if (req.RequestsPerHour > 0) != (req.BurstSize > 0) {
+		return nil, errors.New("requests per hour and burst size must be set together")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, req.UserID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.Status != models.StatusActive {
+		return nil, errors.New("user is not active")
+	}
+	if req.Scope == models.APIKeyScopeAdmin && user.Role != models.RoleAdmin {
+		return nil, errors.New("admin keys can only be issued for administrators")
+	}
+
+	key := &models.APIKey{
+		UserID:          user.ID,
+		Name:            strings.TrimSpace(req.Name),
+		Scope:           req.Scope,
+		RequestsPerHour: req.RequestsPerHour,
+		BurstSize:       req.BurstSize,
+		CreatedBy:       adminID,
+		ExpiresAt:       req.ExpiresAt,
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	issued, err := s.createTx(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionAPIKeyIssued,
+		EntityType: "api_key",
+		EntityID:   key.ID,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"api_key_id": key.ID,
+		"user_id":    key.UserID,
+		"scope":      key.Scope,
+	}).Info("API key issued")
+	return issued, nil
+}
+
+// createTx generates the key for a new API key and stores it
+func (s *APIKeyService) createTx(ctx context.Context, tx repository.Tx, key *models.APIKey) (*models.IssuedAPIKey, error) {
+	secret, err := generateAPIKey()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to generate API key")
+		return nil, errors.New("internal server error")
+	}
+	key.Prefix = secret[:apiKeyDisplayLength]
+	key.KeyHash = hashAPIKey(secret)
+
+	signingKey, err := middleware.RequestSigningKey(ctx, secret)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to derive request signing key")
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.repo.CreateTx(ctx, tx, key); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return &models.IssuedAPIKey{APIKey: key, Key: secret, SigningKey: signingKey}, nil
+}
+
+// List lists the keys of a user, or of all users for a zero user ID
+func (s *APIKeyService) List(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	keys, err := s.repo.List(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return keys, nil
+}
+
+// Rotate issues a successor of a key with the same owner, scope and limits. The
+// old key expires once the rotation grace period is over.
+func (s *APIKeyService) Rotate(ctx context.Context, adminID, id int64) (*models.IssuedAPIKey, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := s.lockActiveTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	successor := &models.APIKey{
+		UserID:          old.UserID,
+		Name:            old.Name,
+		Scope:           old.Scope,
+		RequestsPerHour: old.RequestsPerHour,
+		BurstSize:       old.BurstSize,
+		RotatedFrom:     &old.ID,
+		CreatedBy:       adminID,
+		ExpiresAt:       old.ExpiresAt,
+	}
+	issued, err := s.createTx(ctx, tx, successor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ExpireTx(ctx, tx, old, time.Now().Add(s.rotationGracePeriod)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionAPIKeyRotated,
+		EntityType: "api_key",
+		EntityID:   old.ID,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"api_key_id":   old.ID,
+		"successor_id": successor.ID,
+	}).Info("API key rotated")
+	return issued, nil
+}
+
+// Revoke revokes a key at once
+func (s *APIKeyService) Revoke(ctx context.Context, adminID, id int64) error {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	key, err := s.lockActiveTx(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeTx(ctx, tx, key); err != nil {
+		return errors.New("internal server error")
+	}
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionAPIKeyRevoked,
+		EntityType: "api_key",
+		EntityID:   key.ID,
+	}); err != nil {
+		return errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockActiveTx locks a key that has neither been revoked nor expired
+func (s *APIKeyService) lockActiveTx(ctx context.Context, tx repository.Tx, id int64) (*models.APIKey, error) {
+	key, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		if err.Error() == "API key not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("API key is already revoked")
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("API key has expired")
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the active key a request presents and records its
+// use, or nil for an unknown, revoked or expired key
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+	apiKey, err := s.repo.Touch(ctx, hashAPIKey(key))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to authenticate API key")
+		return nil, err
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/middleware"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestAPIKeyServiceIssue(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		req     models.CreateAPIKeyRequest
+		wantErr string
+	}{
+		{
+			name: "issues a payments key",
+			user: &models.User{ID: 7, Role: models.RoleUser, Status: models.StatusActive},
+			req:  models.CreateAPIKeyRequest{UserID: 7, Name: " billing ", Scope: models.APIKeyScopePayments, RequestsPerHour: 600, BurstSize: 20},
+		},
+		{
+			name: "issues an admin key for an administrator",
+			user: &models.User{ID: 1, Role: models.RoleAdmin, Status: models.StatusActive},
+			req:  models.CreateAPIKeyRequest{UserID: 1, Name: "ops", Scope: models.APIKeyScopeAdmin},
+		},
+		{
+			name:    "admin key for a customer",
+			user:    &models.User{ID: 7, Role: models.RoleUser, Status: models.StatusActive},
+			req:     models.CreateAPIKeyRequest{UserID: 7, Name: "ops", Scope: models.APIKeyScopeAdmin},
+			wantErr: "admin keys can only be issued for administrators",
+		},
+		{
+			name:    "blocked user",
+			user:    &models.User{ID: 7, Role: models.RoleUser, Status: models.StatusBlocked},
+			req:     models.CreateAPIKeyRequest{UserID: 7, Name: "billing", Scope: models.APIKeyScopeReadOnly},
+			wantErr: "user is not active",
+		},
+		{
+			name:    "rate without a burst",
+			user:    &models.User{ID: 7, Role: models.RoleUser, Status: models.StatusActive},
+			req:     models.CreateAPIKeyRequest{UserID: 7, Name: "billing", Scope: models.APIKeyScopeReadOnly, RequestsPerHour: 600},
+			wantErr: "requests per hour and burst size must be set together",
+		},
+		{
+			name:    "expiry in the past",
+			user:    &models.User{ID: 7, Role: models.RoleUser, Status: models.StatusActive},
+			req:     models.CreateAPIKeyRequest{UserID: 7, Name: "billing", Scope: models.APIKeyScopeReadOnly, ExpiresAt: &past},
+			wantErr: "expiry must be in the future",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			users := &mock.UserStore{
+				GetByIDFunc: func(context.Context, int64) (*models.User, error) { return tt.user, nil },
+			}
+			s := NewAPIKeyService(repository.NewAPIKeyRepository(env.db, env.logger), users,
+				repository.NewAuditRepository(env.db, env.logger), env.cfg.APIKeys.RotationGracePeriod, env.logger)
+
+			issued, err := s.Issue(context.Background(), 1, &tt.req)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Issue() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+
+			if !strings.HasPrefix(issued.Key, apiKeyPrefix) || !strings.HasPrefix(issued.Key, issued.Prefix) {
+				t.Errorf("key %q does not start with %q and its prefix %q", issued.Key, apiKeyPrefix, issued.Prefix)
+			}
+			if issued.KeyHash != hashAPIKey(issued.Key) {
+				t.Error("key is not stored as its hash")
+			}
+			if signingKey, _ := middleware.RequestSigningKey(context.Background(), issued.Key); issued.SigningKey != signingKey {
+				t.Error("signing key is not derived from the key")
+			}
+			if issued.Name != strings.TrimSpace(tt.req.Name) || issued.Scope != tt.req.Scope || issued.CreatedBy != 1 {
+				t.Errorf("issued key = %+v, want the request of %+v", issued.APIKey, tt.req)
+			}
+		})
+	}
+}
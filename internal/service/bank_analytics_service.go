@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/cache"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBankAnalyticsDays bounds the period of the bank analytics, and so the
+// number of days new users are counted for
+const maxBankAnalyticsDays = 366
+
+// BankAnalyticsService builds the portfolio metrics of the whole bank for the
+// admin dashboard
+type BankAnalyticsService struct {
+	repo   *repository.BankAnalyticsRepository
+	cache  cache.Cache
+	ttl    time.Duration
+	logger *logrus.Logger
+}
+
+// NewBankAnalyticsService creates a new BankAnalyticsService instance
+func NewBankAnalyticsService(repo *repository.BankAnalyticsRepository, logger *logrus.Logger) *BankAnalyticsService {
+	return &BankAnalyticsService{
+		repo:   repo,
+		cache:  cache.Nop{},
+		logger: logger,
+	}
+}
+
+// SetCache keeps the bank analytics of a period in c for up to ttl. The
+// aggregation scans the whole portfolio, so the dashboard may lag behind the
+// ledger by up to ttl.
+func (s *BankAnalyticsService) SetCache(c cache.Cache, ttl time.Duration) {
+	s.cache = c
+	s.ttl = ttl
+}
+
+// GetAnalytics sums up the deposits and the credits of the bank, with the
+// users registered and the transactions posted from from up to to
+func (s *BankAnalyticsService) GetAnalytics(ctx context.Context, from, to time.Time) (*models.BankAnalytics, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if to.Sub(from) > maxBankAnalyticsDays*24*time.Hour {
+		return nil, fmt.Errorf("period must not exceed %d days", maxBankAnalyticsDays)
+	}
+
+	key := fmt.Sprintf("analytics:bank:%s:%s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	cached := &models.BankAnalytics{}
+	if s.cache.Get(ctx, key, cached) {
+		return cached, nil
+	}
+
+	analytics, err := s.repo.GetAnalytics(ctx, from, to)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	for _, stats := range analytics.Credits {
+		stats.OverdueRatio = overdueRatio(stats.OverdueOutstanding, stats.Outstanding)
+	}
+	for _, stats := range analytics.Deposits {
+		stats.Total = roundMoney(stats.AccountBalances + stats.TermDeposits)
+	}
+	analytics.NewUsers = fillDailyCounts(analytics.NewUsers, from, to)
+	analytics.GeneratedAt = time.Now()
+
+	s.cache.Set(ctx, key, analytics, s.ttl)
+	return analytics, nil
+}
+
+// overdueRatio returns the overdue outstanding principal as a percentage of the
+// outstanding principal
+func overdueRatio(overdue, outstanding float64) float64 {
+	if outstanding <= 0 {
+		return 0
+	}
+	return roundMoney(overdue / outstanding * 100)
+}
+
+// fillDailyCounts returns the counts for every day from from up to to, with
+// the days missing from counts at zero
+func fillDailyCounts(counts []*models.DailyCount, from, to time.Time) []*models.DailyCount {
+	byDate := make(map[string]int, len(counts))
+	for _, count := range counts {
+		byDate[count.Date] = count.Count
+	}
+
+	filled := []*models.DailyCount{}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		filled = append(filled, &models.DailyCount{Date: date, Count: byDate[date]})
+	}
+	return filled
+}
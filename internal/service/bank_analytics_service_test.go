@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestOverdueRatio(t *testing.T) {
+	tests := []struct {
+		name        string
+		overdue     float64
+		outstanding float64
+		want        float64
+	}{
+		{name: "no overdue credits", overdue: 0, outstanding: 1000000, want: 0},
+		{name: "part overdue", overdue: 125000, outstanding: 1000000, want: 12.5},
+		{name: "rounded to hundredths", overdue: 1, outstanding: 3, want: 33.33},
+		{name: "no credits", overdue: 0, outstanding: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overdueRatio(tt.overdue, tt.outstanding); got != tt.want {
+				t.Errorf("overdueRatio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillDailyCounts(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	counts := []*models.DailyCount{{Date: "2026-10-02", Count: 5}, {Date: "2026-10-04", Count: 1}}
+
+	got := fillDailyCounts(counts, from, from.AddDate(0, 0, 4))
+	want := []int{0, 5, 0, 1}
+	if len(got) != len(want) {
+		t.Fatalf("fillDailyCounts() = %d days, want %d", len(got), len(want))
+	}
+	for i, count := range got {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		if count.Date != date || count.Count != want[i] {
+			t.Errorf("day %d = %s: %d, want %s: %d", i, count.Date, count.Count, date, want[i])
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/integration/billers"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// BillerService manages the biller catalog and pays bills through the providers
+// of the billers. A payment is debited from the account before the provider is
+// asked to pay and credited back when the provider fails it.
+type BillerService struct {
+	repo                *repository.BillerRepository
+	accountRepo         repository.AccountStore
+	limits              *LimitService
+	providers           *billers.Registry
+	auditRepo           *repository.AuditRepository
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewBillerService creates a new BillerService instance
+func NewBillerService(
+	repo *repository.BillerRepository,
+	accountRepo repository.AccountStore,
+	limits *LimitService,
+	providers *billers.Registry,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *BillerService {
+	return &BillerService{
+		repo:                repo,
+		accountRepo:         accountRepo,
+		limits:              limits,
+		providers:           providers,
+		auditRepo:           auditRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// ListBillers retrieves the billers of the catalog, of a category unless it is
+// empty and only the active ones unless all is set
+func (s *BillerService) ListBillers(ctx context.Context, category string, all bool) ([]*models.Biller, error) {
+	if category != "" && !slices.Contains(models.BillerCategories, category) {
+		return nil, fmt.Errorf("unknown biller category %q", category)
+	}
+
+	billerList, err := s.repo.List(ctx, category, all)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return billerList, nil
+}
+
+// GetBiller retrieves a biller of the catalog. An inactive biller is reported
+// as not found unless all is set.
+func (s *BillerService) GetBiller(ctx context.Context, id int64, all bool) (*models.Biller, error) {
+	biller, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "biller not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if !biller.Active && !all {
+		return nil, errors.New("biller not found")
+	}
+	return biller, nil
+}
+
+// CreateBiller adds a biller to the catalog
+func (s *BillerService) CreateBiller(ctx context.Context, adminID int64, req *models.BillerRequest) (*models.Biller, error) {
+	biller := &models.Biller{Active: true}
+	if err := s.applyBillerRequest(biller, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, biller); err != nil {
+		if strings.HasPrefix(err.Error(), "biller already exists") {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionBillerCreated, biller)
+	return biller, nil
+}
+
+// UpdateBiller replaces the details of a biller. Payments already made keep the
+// biller name they were made under.
+func (s *BillerService) UpdateBiller(ctx context.Context, adminID, id int64, req *models.BillerRequest) (*models.Biller, error) {
+	biller, err := s.GetBiller(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyBillerRequest(biller, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, biller); err != nil {
+		if strings.HasPrefix(err.Error(), "biller already exists") {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionBillerUpdated, biller)
+	return biller, nil
+}
+
+// applyBillerRequest checks the details of a biller and copies them onto it
+func (s *BillerService) applyBillerRequest(biller *models.Biller, req *models.BillerRequest) error {
+	provider := strings.TrimSpace(req.Provider)
+	if !s.providers.Has(provider) {
+		return fmt.Errorf("biller provider %q is not configured", provider)
+	}
+	pattern := strings.TrimSpace(req.AccountPattern)
+	if pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.New("invalid account pattern")
+		}
+	}
+	if req.MaxAmount > 0 && req.MaxAmount < req.MinAmount {
+		return errors.New("max_amount must not be below min_amount")
+	}
+
+	biller.Name = strings.TrimSpace(req.Name)
+	biller.Category = req.Category
+	biller.Provider = provider
+	biller.ProviderCode = strings.TrimSpace(req.ProviderCode)
+	biller.AccountLabel = strings.TrimSpace(req.AccountLabel)
+	biller.AccountPattern = pattern
+	biller.Currency = strings.ToUpper(req.Currency)
+	biller.MinAmount = req.MinAmount
+	biller.MaxAmount = req.MaxAmount
+	if req.Active != nil {
+		biller.Active = *req.Active
+	}
+	return nil
+}
+
+// PayBill pays a bill from an account the user may operate. The provider is
+// asked to pay once the debit is committed; when it declines the payment or
+// cannot be reached, the amount is credited back and an error is returned.
+func (s *BillerService) PayBill(ctx context.Context, userID int64, req *models.CreateBillPaymentRequest) (*models.BillPayment, error) {
+	biller, err := s.GetBiller(ctx, req.BillerID, false)
+	if err != nil {
+		return nil, err
+	}
+	if !s.providers.Has(biller.Provider) {
+		return nil, errors.New("biller is not available")
+	}
+	customerAccount := strings.TrimSpace(req.CustomerAccount)
+	if !biller.MatchesAccount(customerAccount) {
+		return nil, fmt.Errorf("invalid %s", strings.ToLower(biller.AccountLabel))
+	}
+	if req.Amount < biller.MinAmount {
+		return nil, fmt.Errorf("amount must be at least %.2f", biller.MinAmount)
+	}
+	if biller.MaxAmount > 0 && req.Amount > biller.MaxAmount {
+		return nil, fmt.Errorf("amount must not exceed %.2f", biller.MaxAmount)
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+	if account.Currency != biller.Currency {
+		return nil, fmt.Errorf("biller accepts payments in %s only", biller.Currency)
+	}
+
+	payment, err := s.debit(ctx, userID, biller, customerAccount, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account is debited, so the outcome is recorded even if the client
+	// goes away while the provider answers
+	ctx = context.WithoutCancel(ctx)
+	confirmation, err := s.providers.Pay(ctx, biller.Provider, &billers.Payment{
+		ID:              payment.ID,
+		BillerCode:      biller.ProviderCode,
+		CustomerAccount: customerAccount,
+		Amount:          payment.Amount,
+		Currency:        payment.Currency,
+	})
+	if err != nil {
+		reason := "provider is unavailable"
+		var declined *billers.DeclinedError
+		if errors.As(err, &declined) {
+			reason = declined.Reason
+		}
+		s.logger.WithError(err).WithField("bill_payment_id", payment.ID).Warn("Bill payment failed at the provider")
+
+		if err := s.refund(ctx, payment, reason); err != nil {
+			s.logger.WithError(err).WithField("bill_payment_id", payment.ID).Error("Failed to refund bill payment")
+			return nil, errors.New("internal server error")
+		}
+		return nil, fmt.Errorf("bill payment failed: %s; the amount was refunded", reason)
+	}
+
+	if err := s.complete(ctx, payment, confirmation.Reference); err != nil {
+		s.logger.WithError(err).WithField("bill_payment_id", payment.ID).Error("Failed to complete bill payment")
+		return nil, errors.New("internal server error")
+	}
+	return payment, nil
+}
+
+// debit takes the amount of a bill payment out of the account and saves the
+// payment as pending
+func (s *BillerService) debit(ctx context.Context, userID int64, biller *models.Biller, customerAccount string, req *models.CreateBillPaymentRequest) (*models.BillPayment, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", req.AccountID, err)
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+	if account.AvailableBalance() < req.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+	if err := s.limits.CheckTx(ctx, tx, account.UserID, models.TransactionTypeTransfer, req.Amount); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        req.Amount,
+		Type:          models.TransactionTypeBillPayment,
+		Description:   fmt.Sprintf("Payment to %s, %s", biller.Name, customerAccount),
+		CreatedAt:     time.Now(),
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post bill payment")
+		return nil, errors.New("internal server error")
+	}
+
+	payment := &models.BillPayment{
+		UserID:          userID,
+		AccountID:       account.ID,
+		BillerID:        biller.ID,
+		BillerName:      biller.Name,
+		CustomerAccount: customerAccount,
+		Amount:          req.Amount,
+		Currency:        account.Currency,
+		Status:          models.BillPaymentStatusPending,
+		TransactionID:   transaction.ID,
+	}
+	if err := s.repo.CreatePaymentTx(ctx, tx, payment); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return payment, nil
+}
+
+// complete records the provider's confirmation of a payment
+func (s *BillerService) complete(ctx context.Context, payment *models.BillPayment, reference string) error {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment.Status = models.BillPaymentStatusCompleted
+	payment.ProviderReference = reference
+	if err := s.repo.UpdatePaymentTx(ctx, tx, payment); err != nil {
+		return err
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, payment.UserID, "Bill paid",
+		fmt.Sprintf("Your payment of %.2f %s to %s for %s is complete.",
+			payment.Amount, payment.Currency, payment.BillerName, payment.CustomerAccount),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// refund credits a failed payment back to its account
+func (s *BillerService) refund(ctx context.Context, payment *models.BillPayment, reason string) error {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	locked, err := s.repo.GetPaymentForUpdate(ctx, tx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if locked.Status != models.BillPaymentStatusPending {
+		return fmt.Errorf("bill payment %d is %s", payment.ID, locked.Status)
+	}
+	if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, payment.AccountID); err != nil {
+		return fmt.Errorf("failed to get account %d: %w", payment.AccountID, err)
+	}
+
+	refund := &models.Transaction{
+		ToAccountID: payment.AccountID,
+		Amount:      payment.Amount,
+		Type:        models.TransactionTypeBillRefund,
+		Description: fmt.Sprintf("Refund of payment to %s: %s", payment.BillerName, reason),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, refund); err != nil {
+		return fmt.Errorf("failed to post refund: %w", err)
+	}
+
+	payment.Status = models.BillPaymentStatusRefunded
+	payment.RefundTransactionID = &refund.ID
+	payment.FailureReason = reason
+	if err := s.repo.UpdatePaymentTx(ctx, tx, payment); err != nil {
+		return err
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, payment.UserID, "Bill payment failed",
+		fmt.Sprintf("Your payment of %.2f %s to %s failed (%s) and the amount was returned to your account.",
+			payment.Amount, payment.Currency, payment.BillerName, reason),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPayment retrieves a bill payment of the user
+func (s *BillerService) GetPayment(ctx context.Context, userID, id int64) (*models.BillPayment, error) {
+	payment, err := s.repo.GetPayment(ctx, userID, id)
+	if err != nil {
+		if err.Error() == "bill payment not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return payment, nil
+}
+
+// ListPayments retrieves a page of the bill payments of the user and how many there are
+func (s *BillerService) ListPayments(ctx context.Context, userID int64, p pagination.Params) ([]*models.BillPayment, int, error) {
+	payments, total, err := s.repo.ListPayments(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return payments, total, nil
+}
+
+// audit records a change of the catalog; a failure to record it does not undo
+// the change
+func (s *BillerService) audit(ctx context.Context, adminID int64, action models.AuditAction, biller *models.Biller) {
+	details, _ := json.Marshal(biller)
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: "biller",
+		EntityID:   biller.ID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit %s of biller %d", action, biller.ID)
+	}
+}
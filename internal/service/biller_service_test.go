@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/integration/billers"
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestBillerServiceRejectsInvalidBillers(t *testing.T) {
+	env := newTestEnv(t)
+	s := NewBillerService(nil, nil, nil, billers.NewRegistryWithProviders(billers.NewSandboxProvider("sandbox")), nil, nil, env.logger)
+
+	valid := models.BillerRequest{
+		Name: "Mobile Operator", Category: models.BillerCategoryMobile, Provider: "sandbox", ProviderCode: "mts",
+		AccountLabel: "Phone number", AccountPattern: `9\d{9}`, Currency: "rub", MinAmount: 10, MaxAmount: 15000,
+	}
+	tests := []struct {
+		name   string
+		change func(*models.BillerRequest)
+	}{
+		{"unknown provider", func(r *models.BillerRequest) { r.Provider = "qiwi" }},
+		{"invalid pattern", func(r *models.BillerRequest) { r.AccountPattern = `9[0-9` }},
+		{"max below min", func(r *models.BillerRequest) { r.MaxAmount = 5 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.change(&req)
+			if _, err := s.CreateBiller(context.Background(), 1, &req); err == nil {
+				t.Fatal("expected the biller to be rejected")
+			}
+		})
+	}
+
+	biller := &models.Biller{}
+	if err := s.applyBillerRequest(biller, &valid); err != nil {
+		t.Fatalf("apply valid request: %v", err)
+	}
+	if biller.Currency != "RUB" {
+		t.Errorf("biller %+v", biller)
+	}
+	if !biller.MatchesAccount("9161234567") || biller.MatchesAccount("89161234567") {
+		t.Error("the account pattern must match the whole customer account")
+	}
+}
+
+func TestBillerServiceListBillersRejectsUnknownCategory(t *testing.T) {
+	env := newTestEnv(t)
+	s := NewBillerService(nil, nil, nil, billers.NewRegistryWithProviders(), nil, nil, env.logger)
+
+	if _, err := s.ListBillers(context.Background(), "groceries", false); err == nil {
+		t.Fatal("expected an unknown category to be rejected")
+	}
+}
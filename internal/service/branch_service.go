@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBranchAccessDenied is returned when a branch administrator asks for a
+// branch other than their own
+var ErrBranchAccessDenied = errors.New("access to this branch is denied")
+
+// BranchService manages the branches of the bank, the branch of each user and
+// the reports of a branch. Administrators see every branch, branch
+// administrators only their own.
+type BranchService struct {
+	repo      *repository.BranchRepository
+	userRepo  repository.UserStore
+	auditRepo *repository.AuditRepository
+	cfg       *config.BranchesConfig
+	logger    *logrus.Logger
+}
+
+// NewBranchService creates a new BranchService instance
+func NewBranchService(
+	repo *repository.BranchRepository,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	cfg *config.BranchesConfig,
+	logger *logrus.Logger,
+) *BranchService {
+	return &BranchService{
+		repo:      repo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// ListBranches retrieves the branches, only those open for registration if asked
+func (s *BranchService) ListBranches(ctx context.Context, activeOnly bool) ([]*models.Branch, error) {
+	branches, err := s.repo.List(ctx, activeOnly)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return branches, nil
+}
+
+// CreateBranch opens a branch
+func (s *BranchService) CreateBranch(ctx context.Context, adminID int64, req *models.CreateBranchRequest) (*models.Branch, error) {
+	branch := &models.Branch{
+		Code:    req.Code,
+		Name:    req.Name,
+		Address: req.Address,
+		Active:  true,
+	}
+	if err := s.repo.Create(ctx, branch); err != nil {
+		if errors.Is(err, repository.ErrBranchCodeTaken) {
+			return nil, err
+		}
+		s.logger.WithError(err).Error("Failed to create branch")
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionBranchCreated, "branch", branch.ID, branch)
+	return branch, nil
+}
+
+// UpdateBranch changes the name, address and status of a branch. A closed
+// branch keeps its users, accounts and credits but takes no new registrations.
+func (s *BranchService) UpdateBranch(ctx context.Context, adminID, id int64, req *models.UpdateBranchRequest) (*models.Branch, error) {
+	branch, err := s.getBranch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Active && branch.Code == s.cfg.DefaultCode {
+		return nil, errors.New("the default branch cannot be closed")
+	}
+
+	branch.Name = req.Name
+	branch.Address = req.Address
+	branch.Active = req.Active
+	if err := s.repo.Update(ctx, branch); err != nil {
+		s.logger.WithError(err).Errorf("Failed to update branch %d", id)
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionBranchUpdated, "branch", branch.ID, branch)
+	return branch, nil
+}
+
+// RegistrationBranch returns the branch a new user registers with: the one of
+// the code, or the default branch without one
+func (s *BranchService) RegistrationBranch(ctx context.Context, code string) (*models.Branch, error) {
+	if code == "" {
+		code = s.cfg.DefaultCode
+	}
+	branch, err := s.repo.GetByCode(ctx, code)
+	if err != nil {
+		if err.Error() == "branch not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if !branch.Active {
+		return nil, errors.New("branch is closed for registration")
+	}
+	return branch, nil
+}
+
+// AssignUser moves a user to a branch and, if asked, makes the user an
+// administrator of the branch or takes that role away. Administrators and
+// support staff may be moved, but keep their role.
+func (s *BranchService) AssignUser(ctx context.Context, adminID, userID int64, req *models.AssignBranchRequest) (*models.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, err
+		}
+		s.logger.WithError(err).Errorf("Failed to get user %d", userID)
+		return nil, errors.New("internal server error")
+	}
+
+	branch, err := s.getBranch(ctx, req.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	if !branch.Active {
+		return nil, errors.New("branch is closed")
+	}
+
+	role := user.Role
+	if req.Role != "" {
+		if user.Role == models.RoleAdmin || user.Role == models.RoleSupport {
+			return nil, errors.New("the role of administrators and support staff cannot be changed")
+		}
+		role = req.Role
+	}
+
+	previousBranchID, previousRole := user.BranchID, user.Role
+	if err := s.userRepo.AssignBranch(ctx, userID, branch.ID, role); err != nil {
+		s.logger.WithError(err).Errorf("Failed to assign user %d to branch %d", userID, branch.ID)
+		return nil, errors.New("internal server error")
+	}
+	user.BranchID, user.Role = branch.ID, role
+
+	s.audit(ctx, adminID, models.AuditActionUserBranchAssigned, "user", userID, map[string]interface{}{
+		"previous_branch_id": previousBranchID,
+		"previous_role":      previousRole,
+		"branch_id":          branch.ID,
+		"role":               role,
+	})
+	return user.ToResponse(), nil
+}
+
+// Authorize checks that a user with the given role may see a branch: any
+// branch for administrators, their own for branch administrators
+func (s *BranchService) Authorize(ctx context.Context, userID int64, role models.UserRole, branchID int64) error {
+	if role == models.RoleAdmin {
+		return nil
+	}
+	if role != models.RoleBranchAdmin {
+		return ErrBranchAccessDenied
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to get branch administrator %d", userID)
+		return errors.New("internal server error")
+	}
+	// The role in the token may be older than a change of the role or branch
+	if user.Role != models.RoleBranchAdmin || user.BranchID != branchID {
+		return ErrBranchAccessDenied
+	}
+	return nil
+}
+
+// GetReport sums up the users, accounts and credits of a branch, with those
+// opened from from up to to
+func (s *BranchService) GetReport(ctx context.Context, branchID int64, from, to time.Time) (*models.BranchReport, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+
+	branch, err := s.getBranch(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.repo.GetReport(ctx, branchID, from, to)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	report.Branch = branch
+	return report, nil
+}
+
+// ListUsers retrieves a page of the users of a branch and how many it has
+func (s *BranchService) ListUsers(ctx context.Context, branchID int64, p pagination.Params) ([]*models.UserResponse, int, error) {
+	if _, err := s.getBranch(ctx, branchID); err != nil {
+		return nil, 0, err
+	}
+
+	users, total, err := s.userRepo.ListByBranch(ctx, branchID, p)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to list users of branch %d", branchID)
+		return nil, 0, errors.New("internal server error")
+	}
+
+	responses := make([]*models.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, user.ToResponse())
+	}
+	return responses, total, nil
+}
+
+func (s *BranchService) getBranch(ctx context.Context, id int64) (*models.Branch, error) {
+	branch, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "branch not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return branch, nil
+}
+
+// audit records a change made by an administrator; a failure to record it does
+// not undo the change
+func (s *BranchService) audit(ctx context.Context, adminID int64, action models.AuditAction, entityType string, entityID int64, details interface{}) {
+	payload, _ := json.Marshal(details)
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Details:    payload,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit %s of %s %d", action, entityType, entityID)
+	}
+}
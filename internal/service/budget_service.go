@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// budgetThresholds are the utilizations of a budget, in percent, the user is
+// notified of once a month, the highest first
+var budgetThresholds = []int{100, 80}
+
+// BudgetService manages the monthly spending limits users set per category and
+// computes the spending of the current month from the posted transactions. The
+// month is bounded in the time zone of the user settings.
+type BudgetService struct {
+	repo                *repository.BudgetRepository
+	settingsRepo        *repository.UserSettingsRepository
+	rateService         *RateService
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewBudgetService creates a new BudgetService instance
+func NewBudgetService(
+	repo *repository.BudgetRepository,
+	settingsRepo *repository.UserSettingsRepository,
+	rateService *RateService,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *BudgetService {
+	return &BudgetService{
+		repo:                repo,
+		settingsRepo:        settingsRepo,
+		rateService:         rateService,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// CreateBudget sets the monthly limit of a category for the user
+func (s *BudgetService) CreateBudget(ctx context.Context, userID int64, req *models.BudgetRequest) (*models.Budget, error) {
+	if !spendingCategoryValid(req.Category) {
+		return nil, errors.New("invalid spending category")
+	}
+
+	budget := &models.Budget{
+		UserID:       userID,
+		Category:     req.Category,
+		MonthlyLimit: roundMoney(req.MonthlyLimit),
+	}
+	if err := s.repo.Create(ctx, budget); err != nil {
+		if err.Error() == "budget already exists for the category" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return budget, nil
+}
+
+// GetBudget retrieves a budget of the user with the spending of the current month
+func (s *BudgetService) GetBudget(ctx context.Context, userID, id int64, now time.Time) (*models.BudgetProgress, error) {
+	budget, err := s.getBudget(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := s.progress(ctx, userID, []*models.Budget{budget}, now)
+	if err != nil {
+		return nil, err
+	}
+	return progress[0], nil
+}
+
+// ListBudgets retrieves the budgets of the user with the spending of the current
+// month
+func (s *BudgetService) ListBudgets(ctx context.Context, userID int64, now time.Time) ([]*models.BudgetProgress, error) {
+	budgets, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return s.progress(ctx, userID, budgets, now)
+}
+
+// UpdateBudget changes the monthly limit of a budget of the user
+func (s *BudgetService) UpdateBudget(ctx context.Context, userID, id int64, req *models.UpdateBudgetRequest) (*models.Budget, error) {
+	budget, err := s.getBudget(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	budget.MonthlyLimit = roundMoney(req.MonthlyLimit)
+	if err := s.repo.Update(ctx, budget); err != nil {
+		if err.Error() == "budget not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return budget, nil
+}
+
+// DeleteBudget removes a budget of the user
+func (s *BudgetService) DeleteBudget(ctx context.Context, userID, id int64) error {
+	if err := s.repo.Delete(ctx, userID, id); err != nil {
+		if err.Error() == "budget not found" {
+			return err
+		}
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+func (s *BudgetService) getBudget(ctx context.Context, userID, id int64) (*models.Budget, error) {
+	budget, err := s.repo.GetByID(ctx, userID, id)
+	if err != nil {
+		if err.Error() == "budget not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return budget, nil
+}
+
+// progress computes the spending of the current month of the user against each
+// of the budgets
+func (s *BudgetService) progress(ctx context.Context, userID int64, budgets []*models.Budget, now time.Time) ([]*models.BudgetProgress, error) {
+	progress := []*models.BudgetProgress{}
+	if len(budgets) == 0 {
+		return progress, nil
+	}
+
+	settings, err := s.settingsRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	local := now.In(userLocation(s.logger, settings.TimeZone))
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, local.Location())
+
+	totals, err := s.repo.SpendingTotals(ctx, userID, monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	spent, err := s.spendingByCategory(totals)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, budget := range budgets {
+		progress = append(progress, budgetProgress(budget, monthStart, spent[budget.Category]))
+	}
+	return progress, nil
+}
+
+// spendingByCategory sums spending totals by category in RUB at the CBR rates
+func (s *BudgetService) spendingByCategory(totals []*models.SpendingTotal) (map[string]float64, error) {
+	spent := make(map[string]float64)
+	for _, total := range totals {
+		category, ok := spendingCategory(total.Type, total.BillerCategory)
+		if !ok {
+			continue
+		}
+		rate, err := s.rateService.CrossRate(total.Currency, models.BaseCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("budgets are unavailable while the %s rate is unknown", total.Currency)
+		}
+		spent[category] += total.Amount * rate
+	}
+	return spent, nil
+}
+
+// spendingCategory returns the category of an outgoing transaction of a type,
+// made to a biller of a category for bill payments. Money set aside or moved
+// by the bank, such as term deposits and dispute reversals, is not spending.
+func spendingCategory(transactionType, billerCategory string) (string, bool) {
+	switch transactionType {
+	case models.TransactionTypeCardPayment:
+		return models.SpendingCategoryCardPayments, true
+	case models.TransactionTypeWithdrawal:
+		return models.SpendingCategoryCash, true
+	case models.TransactionTypeTransfer, models.TransactionTypeExternalTransfer:
+		return models.SpendingCategoryTransfers, true
+	case models.TransactionTypeBillPayment:
+		switch billerCategory {
+		case models.BillerCategoryMobile, models.BillerCategoryUtilities, models.BillerCategoryInternet, models.BillerCategoryTV:
+			return billerCategory, true
+		}
+		return models.SpendingCategoryBills, true
+	case models.TransactionTypeCreditRepayment:
+		return models.SpendingCategoryLoans, true
+	case models.TransactionTypeOverdraftInterest, models.TransactionTypeDormancyFee:
+		return models.SpendingCategoryFees, true
+	}
+	return "", false
+}
+
+func spendingCategoryValid(category string) bool {
+	for _, c := range models.SpendingCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func budgetProgress(budget *models.Budget, monthStart time.Time, spent float64) *models.BudgetProgress {
+	spent = roundMoney(spent)
+	return &models.BudgetProgress{
+		Budget:      *budget,
+		MonthStart:  monthStart,
+		Spent:       spent,
+		Remaining:   math.Max(roundMoney(budget.MonthlyLimit-spent), 0),
+		Utilization: roundMoney(spent / budget.MonthlyLimit * 100),
+	}
+}
+
+// budgetThresholdReached returns the highest threshold the utilization of a
+// budget reached that the user was not notified of in the month yet, or zero
+func budgetThresholdReached(progress *models.BudgetProgress) int {
+	notified := 0
+	if progress.NotifiedMonth != nil && sameDate(*progress.NotifiedMonth, progress.MonthStart) {
+		notified = progress.NotifiedPercent
+	}
+	for _, threshold := range budgetThresholds {
+		if progress.Utilization >= float64(threshold) {
+			if threshold > notified {
+				return threshold
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// NotifyBudgets notifies the users whose spending reached 80% or 100% of a
+// budget in the current month, once for each threshold, and returns how many
+// notifications were sent. A user whose budgets fail to evaluate is retried on
+// the next run.
+func (s *BudgetService) NotifyBudgets(ctx context.Context, now time.Time) (int, error) {
+	userIDs, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		n, err := s.notifyUser(ctx, userID, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to evaluate budgets of user %d", userID)
+			continue
+		}
+		sent += n
+	}
+	return sent, nil
+}
+
+func (s *BudgetService) notifyUser(ctx context.Context, userID int64, now time.Time) (int, error) {
+	progress, err := s.ListBudgets(ctx, userID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, p := range progress {
+		threshold := budgetThresholdReached(p)
+		if threshold == 0 {
+			continue
+		}
+		notified, err := s.notifyThreshold(ctx, p, threshold)
+		if err != nil {
+			return sent, err
+		}
+		if notified {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// notifyThreshold records that the user is notified of a threshold of a budget
+// and queues the notification in one database transaction
+func (s *BudgetService) notifyThreshold(ctx context.Context, p *models.BudgetProgress, threshold int) (bool, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	updated, err := s.repo.SetNotifiedTx(ctx, tx, &p.Budget, p.MonthStart, threshold)
+	if err != nil || !updated {
+		return false, err
+	}
+
+	subject := "Budget almost used"
+	content := fmt.Sprintf("You have spent %.2f RUB on %s this month, %.0f%% of your budget of %.2f RUB.",
+		p.Spent, p.Category, p.Utilization, p.MonthlyLimit)
+	if threshold >= 100 {
+		subject = "Budget exceeded"
+		content = fmt.Sprintf("You have spent %.2f RUB on %s this month, over your budget of %.2f RUB.",
+			p.Spent, p.Category, p.MonthlyLimit)
+	}
+	if err := s.notificationService.NotifyUserTx(ctx, tx, p.UserID, subject, content); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestSpendingCategory(t *testing.T) {
+	tests := []struct {
+		transactionType string
+		billerCategory  string
+		want            string
+		wantOK          bool
+	}{
+		{transactionType: models.TransactionTypeCardPayment, want: models.SpendingCategoryCardPayments, wantOK: true},
+		{transactionType: models.TransactionTypeWithdrawal, want: models.SpendingCategoryCash, wantOK: true},
+		{transactionType: models.TransactionTypeExternalTransfer, want: models.SpendingCategoryTransfers, wantOK: true},
+		{transactionType: models.TransactionTypeBillPayment, billerCategory: models.BillerCategoryMobile, want: models.SpendingCategoryMobile, wantOK: true},
+		{transactionType: models.TransactionTypeBillPayment, billerCategory: models.BillerCategoryOther, want: models.SpendingCategoryBills, wantOK: true},
+		{transactionType: models.TransactionTypeOverdraftInterest, want: models.SpendingCategoryFees, wantOK: true},
+		{transactionType: models.TransactionTypeDepositOpening},
+		{transactionType: models.TransactionTypeDisputeReversal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transactionType+"/"+tt.billerCategory, func(t *testing.T) {
+			got, ok := spendingCategory(tt.transactionType, tt.billerCategory)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("spendingCategory() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBudgetThresholdReached(t *testing.T) {
+	month := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	previous := month.AddDate(0, -1, 0)
+
+	tests := []struct {
+		name          string
+		spent         float64
+		notified      int
+		notifiedMonth *time.Time
+		want          int
+	}{
+		{name: "below 80%", spent: 7999, want: 0},
+		{name: "80% reached", spent: 8000, want: 80},
+		{name: "80% already notified", spent: 9000, notified: 80, notifiedMonth: &month, want: 0},
+		{name: "100% after 80% notified", spent: 10500, notified: 80, notifiedMonth: &month, want: 100},
+		{name: "100% already notified", spent: 12000, notified: 100, notifiedMonth: &month, want: 0},
+		{name: "notified in a previous month", spent: 8500, notified: 100, notifiedMonth: &previous, want: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &models.Budget{MonthlyLimit: 10000, NotifiedPercent: tt.notified, NotifiedMonth: tt.notifiedMonth}
+			if got := budgetThresholdReached(budgetProgress(budget, month, tt.spent)); got != tt.want {
+				t.Errorf("budgetThresholdReached() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// CardAuthorizationService authorizes card payments for the acquirer the way a
+// card scheme would: the card details are checked, the amount is held on the
+// account, and the merchant later captures the payment or voids it.
+type CardAuthorizationService struct {
+	authorizationRepo *repository.CardAuthorizationRepository
+	holdRepo          *repository.HoldRepository
+	cardRepo          repository.CardStore
+	accountRepo       repository.AccountStore
+	cardService       *CardService
+	ttl               time.Duration
+	logger            *logrus.Logger
+}
+
+// NewCardAuthorizationService creates a new CardAuthorizationService instance.
+// Authorizations not captured within ttl release their hold.
+func NewCardAuthorizationService(
+	authorizationRepo *repository.CardAuthorizationRepository,
+	holdRepo *repository.HoldRepository,
+	cardRepo repository.CardStore,
+	accountRepo repository.AccountStore,
+	cardService *CardService,
+	ttl time.Duration,
+	logger *logrus.Logger,
+) *CardAuthorizationService {
+	return &CardAuthorizationService{
+		authorizationRepo: authorizationRepo,
+		holdRepo:          holdRepo,
+		cardRepo:          cardRepo,
+		accountRepo:       accountRepo,
+		cardService:       cardService,
+		ttl:               ttl,
+		logger:            logger,
+	}
+}
+
+// Authorize checks the card details and the card and account state, and holds
+// the amount in the account currency on the card account. The declines are
+// returned as errors; a single-use card is blocked by the authorization.
+func (s *CardAuthorizationService) Authorize(ctx context.Context, req *models.CardAuthorizationRequest) (*models.CardAuthorization, error) {
+	if !models.LuhnCheck(req.CardNumber) {
+		return nil, errors.New("invalid card number")
+	}
+
+	card, err := s.cardRepo.GetByNumber(ctx, req.CardNumber)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if card == nil {
+		return nil, errors.New("card not found")
+	}
+
+	now := time.Now()
+	if req.ExpiryDate != card.ExpiryDate {
+		return nil, errors.New("invalid expiry date")
+	}
+	if !card.VerifyCVV(req.CVV) {
+		return nil, errors.New("invalid CVV")
+	}
+	if card.Expired(now) {
+		return nil, errors.New("card has expired")
+	}
+	if card.Status != models.CardStatusActive {
+		return nil, errors.New("card is not active")
+	}
+	merchant := strings.TrimSpace(req.Merchant)
+	if card.MerchantLock != "" && !strings.EqualFold(merchant, card.MerchantLock) {
+		return nil, errors.New("card is locked to another merchant")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get card account")
+		return nil, errors.New("account not found")
+	}
+
+	authorization := &models.CardAuthorization{
+		CardID:          card.ID,
+		AccountID:       account.ID,
+		Amount:          req.Amount,
+		Currency:        account.Currency,
+		Merchant:        merchant,
+		MerchantCountry: req.MerchantCountry,
+		Status:          models.CardAuthorizationStatusPending,
+		ExpiresAt:       now.Add(s.ttl),
+	}
+	if !strings.EqualFold(req.Currency, account.Currency) {
+		conversion, _, err := s.cardService.convert(ctx, req.Amount, req.Currency, account.Currency, now)
+		if err != nil {
+			return nil, err
+		}
+		authorization.Amount = conversion.BilledAmount
+		authorization.Conversion = conversion
+	}
+
+	tx, err := s.authorizationRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Holding the account lock keeps the held amount and the card totals from
+	// changing until the authorization and its hold are stored
+	account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, account.ID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to lock card account")
+		return nil, errors.New("internal server error")
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+
+	if err := s.cardService.checkCardLimitsTx(ctx, tx, card, authorization.Amount, now); err != nil {
+		return nil, err
+	}
+
+	if account.AvailableBalance() < authorization.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+
+	if card.SingleUse {
+		if err := s.cardRepo.UseSingleUseTx(ctx, tx, card.ID); err != nil {
+			if err.Error() == "card is not active" {
+				return nil, err
+			}
+			s.logger.WithError(err).Error("Failed to block single-use card")
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	if err := s.authorizationRepo.CreateTx(ctx, tx, authorization); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.holdRepo.CreateTx(ctx, tx, &models.Hold{
+		AccountID: account.ID,
+		Amount:    authorization.Amount,
+		Currency:  authorization.Currency,
+		Source:    models.HoldSourceCardAuthorization,
+		SourceID:  authorization.ID,
+		ExpiresAt: &authorization.ExpiresAt,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return authorization, nil
+}
+
+// GetAuthorization retrieves an authorization by its ID
+func (s *CardAuthorizationService) GetAuthorization(ctx context.Context, id int64) (*models.CardAuthorization, error) {
+	return s.authorizationRepo.GetByID(ctx, id)
+}
+
+// Capture debits the held amount from the account as a card payment and settles
+// the hold
+func (s *CardAuthorizationService) Capture(ctx context.Context, id int64) (*models.CardAuthorization, error) {
+	authorization, err := s.authorizationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.authorizationRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The account is locked first, in the same order as Authorize
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, authorization.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to lock card account")
+		return nil, errors.New("internal server error")
+	}
+
+	authorization, err = s.lockPendingTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        authorization.Amount,
+		Type:          models.TransactionTypeCardPayment,
+		CardID:        authorization.CardID,
+		Description:   authorization.Merchant,
+		Conversion:    authorization.Conversion,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post card payment")
+		return nil, errors.New("internal server error")
+	}
+
+	authorization.Status = models.CardAuthorizationStatusCaptured
+	authorization.TransactionID = &transaction.ID
+	if err := s.authorizationRepo.UpdateStatusTx(ctx, tx, authorization); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.holdRepo.CloseTx(ctx, tx, models.HoldSourceCardAuthorization, authorization.ID, models.HoldStatusSettled); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return authorization, nil
+}
+
+// Void cancels a pending authorization and releases its hold
+func (s *CardAuthorizationService) Void(ctx context.Context, id int64) (*models.CardAuthorization, error) {
+	authorization, err := s.authorizationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.authorizationRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The account is locked first, in the same order as Authorize, and its
+	// cached held amount is dropped once the hold is released
+	if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, authorization.AccountID); err != nil {
+		s.logger.WithError(err).Error("Failed to lock card account")
+		return nil, errors.New("internal server error")
+	}
+
+	authorization, err = s.lockPendingTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization.Status = models.CardAuthorizationStatusVoided
+	if err := s.authorizationRepo.UpdateStatusTx(ctx, tx, authorization); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.holdRepo.CloseTx(ctx, tx, models.HoldSourceCardAuthorization, authorization.ID, models.HoldStatusReleased); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return authorization, nil
+}
+
+// lockPendingTx locks an authorization that can still be captured or voided
+func (s *CardAuthorizationService) lockPendingTx(ctx context.Context, tx repository.Tx, id int64) (*models.CardAuthorization, error) {
+	authorization, err := s.authorizationRepo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if authorization.Status != models.CardAuthorizationStatusPending {
+		return nil, fmt.Errorf("authorization is already %s", authorization.Status)
+	}
+	if authorization.Expired(time.Now()) {
+		return nil, errors.New("authorization has expired")
+	}
+	return authorization, nil
+}
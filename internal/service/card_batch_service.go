@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// cardIssueAttempts bounds retries when a generated card number is already taken
+const cardIssueAttempts = 3
+
+// cardBatchJobPayload identifies the batch processed by a card batch issuance job
+type cardBatchJobPayload struct {
+	BatchID int64 `json:"batch_id"`
+}
+
+// CardBatchService handles bulk card issuance for corporate accounts
+type CardBatchService struct {
+	batchRepo   *repository.CardBatchRepository
+	cardRepo    repository.CardStore
+	accountRepo repository.AccountStore
+	auditRepo   *repository.AuditRepository
+	jobRunner   *jobs.Runner
+	logger      *logrus.Logger
+}
+
+// NewCardBatchService creates a new CardBatchService instance and registers its
+// issuance job with the job runner
+func NewCardBatchService(
+	batchRepo *repository.CardBatchRepository,
+	cardRepo repository.CardStore,
+	accountRepo repository.AccountStore,
+	auditRepo *repository.AuditRepository,
+	jobRunner *jobs.Runner,
+	logger *logrus.Logger,
+) *CardBatchService {
+	s := &CardBatchService{
+		batchRepo:   batchRepo,
+		cardRepo:    cardRepo,
+		accountRepo: accountRepo,
+		auditRepo:   auditRepo,
+		jobRunner:   jobRunner,
+		logger:      logger,
+	}
+	jobRunner.Register(models.JobTypeCardBatchIssuance, s.processBatchJob)
+	return s
+}
+
+// CreateBatch records a batch of cards for a corporate account's employees and
+// queues the job that generates them
+func (s *CardBatchService) CreateBatch(ctx context.Context, adminID int64, req *models.CreateCardBatchRequest) (*models.CardBatch, error) {
+	if req.CardType != "debit" && req.CardType != "credit" {
+		return nil, errors.New("card type must be debit or credit")
+	}
+	if len(req.Employees) == 0 {
+		return nil, errors.New("employee list is empty")
+	}
+	if len(req.Employees) > models.MaxCardBatchSize {
+		return nil, fmt.Errorf("a batch can contain at most %d employees", models.MaxCardBatchSize)
+	}
+	for _, limit := range []*float64{req.Limits.DailyLimit, req.Limits.MonthlyLimit, req.Limits.TransactionLimit} {
+		if limit != nil && *limit <= 0 {
+			return nil, errors.New("card limits must be greater than zero")
+		}
+	}
+
+	items := make([]*models.CardBatchItem, 0, len(req.Employees))
+	for i, employee := range req.Employees {
+		fullName := strings.TrimSpace(employee.FullName)
+		address := strings.TrimSpace(employee.DeliveryAddress)
+		if fullName == "" || address == "" {
+			return nil, fmt.Errorf("employee %d: full name and delivery address are required", i+1)
+		}
+		items = append(items, &models.CardBatchItem{
+			EmployeeID:      strings.TrimSpace(employee.EmployeeID),
+			FullName:        fullName,
+			Email:           strings.TrimSpace(employee.Email),
+			DeliveryAddress: address,
+			Status:          models.CardBatchItemStatusPending,
+			DeliveryStatus:  models.CardDeliveryStatusNotDispatched,
+		})
+	}
+
+	if _, err := s.accountRepo.GetByID(ctx, req.AccountID); err != nil {
+		return nil, err
+	}
+
+	batch := &models.CardBatch{
+		AccountID:   req.AccountID,
+		CardType:    req.CardType,
+		Limits:      req.Limits,
+		Status:      models.CardBatchStatusPending,
+		TotalCards:  len(items),
+		RequestedBy: adminID,
+		Items:       items,
+	}
+
+	tx, err := s.batchRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.batchRepo.CreateTx(ctx, tx, batch); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	job, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeCardBatchIssuance, cardBatchJobPayload{BatchID: batch.ID}, jobs.OwnedBy(adminID))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to queue card batch job")
+		return nil, errors.New("internal server error")
+	}
+	if err := s.batchRepo.SetJobTx(ctx, tx, batch.ID, job.ID); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	batch.JobID = &job.ID
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"account_id": batch.AccountID,
+		"card_type":  batch.CardType,
+		"total":      batch.TotalCards,
+		"limits":     batch.Limits,
+	})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionCardBatchRequested,
+		EntityType: "card_batch",
+		EntityID:   batch.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return batch, nil
+}
+
+// GetBatch retrieves a batch report with the per-card issuance and delivery status
+func (s *CardBatchService) GetBatch(ctx context.Context, batchID int64) (*models.CardBatch, error) {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.batchRepo.GetItems(ctx, batchID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	batch.Items = items
+
+	return batch, nil
+}
+
+// UpdateDelivery records delivery tracking information for an issued batch card
+func (s *CardBatchService) UpdateDelivery(ctx context.Context, batchID, itemID int64, req *models.UpdateCardDeliveryRequest) error {
+	switch req.DeliveryStatus {
+	case models.CardDeliveryStatusNotDispatched, models.CardDeliveryStatusDispatched,
+		models.CardDeliveryStatusDelivered, models.CardDeliveryStatusReturned:
+	default:
+		return errors.New("invalid delivery status")
+	}
+
+	return s.batchRepo.UpdateDelivery(ctx, batchID, itemID, req)
+}
+
+// processBatchJob issues the cards of a batch and links the job to the batch
+// report
+func (s *CardBatchService) processBatchJob(ctx context.Context, job *models.Job) error {
+	var payload cardBatchJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid card batch job payload: %w", err)
+	}
+
+	if err := s.processBatch(ctx, payload.BatchID); err != nil {
+		return err
+	}
+	return s.jobRunner.SetResult(ctx, job.ID, &models.JobResult{Resource: fmt.Sprintf("/admin/card-batches/%d", payload.BatchID)})
+}
+
+// processBatch issues the cards of a batch that are still pending, so a retried
+// job resumes where the previous attempt stopped
+func (s *CardBatchService) processBatch(ctx context.Context, batchID int64) error {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if batch.CompletedAt != nil {
+		return nil
+	}
+
+	if err := s.batchRepo.UpdateStatus(ctx, batch.ID, models.CardBatchStatusProcessing); err != nil {
+		return err
+	}
+
+	items, err := s.batchRepo.GetItems(ctx, batch.ID)
+	if err != nil {
+		return err
+	}
+
+	// A closed account fails the remaining cards, any other error is retried
+	account, err := s.accountRepo.GetByID(ctx, batch.AccountID)
+	accountMissing := err != nil && err.Error() == "account not found"
+	if err != nil && !accountMissing {
+		return err
+	}
+
+	for _, item := range items {
+		if item.Status != models.CardBatchItemStatusPending {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if accountMissing {
+			if err := s.batchRepo.MarkItemFailed(ctx, item.ID, "account not found"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.issueBatchCard(ctx, batch, account, item); err != nil {
+			s.logger.WithError(err).WithField("batch_item_id", item.ID).Error("Failed to issue batch card")
+			if err := s.batchRepo.MarkItemFailed(ctx, item.ID, "card issuance failed"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.batchRepo.Finalize(ctx, batch.ID)
+}
+
+// issueBatchCard creates the card of one batch item on the corporate account with
+// the batch limit profile, retrying with a new number if the generated one is taken
+func (s *CardBatchService) issueBatchCard(ctx context.Context, batch *models.CardBatch, account *models.Account, item *models.CardBatchItem) error {
+	var err error
+	for attempt := 0; attempt < cardIssueAttempts; attempt++ {
+		card := newCard(account.UserID, account.ID, batch.CardType)
+		card.CardLimitProfile = batch.Limits
+
+		if err = s.createBatchCard(ctx, card, item.ID); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *CardBatchService) createBatchCard(ctx context.Context, card *models.Card, itemID int64) error {
+	tx, err := s.batchRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.cardRepo.CreateTx(ctx, tx, card); err != nil {
+		return err
+	}
+	if err := s.batchRepo.MarkItemIssuedTx(ctx, tx, itemID, card.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,38 +1,82 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/events"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
 	"github.com/Abigotado/abi_banking/internal/repository"
 	"github.com/sirupsen/logrus"
 )
 
 // CardService handles business logic for card operations
 type CardService struct {
-	cardRepo    *repository.CardRepository
-	accountRepo *repository.AccountRepository
-	logger      *logrus.Logger
+	cardRepo            repository.CardStore
+	accountRepo         repository.AccountStore
+	rateService         *RateService
+	paramService        *ParameterService
+	notificationService *NotificationService
+	events              *events.Publisher
+	collections         *CollectionService
+	logger              *logrus.Logger
 }
 
 // NewCardService creates a new CardService instance
 func NewCardService(
-	cardRepo *repository.CardRepository,
-	accountRepo *repository.AccountRepository,
+	cardRepo repository.CardStore,
+	accountRepo repository.AccountStore,
+	rateService *RateService,
+	paramService *ParameterService,
+	notificationService *NotificationService,
+	publisher *events.Publisher,
 	logger *logrus.Logger,
 ) *CardService {
 	return &CardService{
-		cardRepo:    cardRepo,
-		accountRepo: accountRepo,
-		logger:      logger,
+		cardRepo:            cardRepo,
+		accountRepo:         accountRepo,
+		rateService:         rateService,
+		paramService:        paramService,
+		notificationService: notificationService,
+		events:              publisher,
+		logger:              logger,
 	}
 }
 
-// CreateCard creates a new card for a user's account
-func (s *CardService) CreateCard(userID int64, req *models.CreateCardRequest) (*models.Card, error) {
-	// Validate account ownership
-	account, err := s.accountRepo.GetByID(req.AccountID)
+// SetCollections refuses new cards to users whose credit is delinquent or in
+// collections
+func (s *CardService) SetCollections(collections *CollectionService) {
+	s.collections = collections
+}
+
+// CreateCard creates a new card for a user's account. Virtual cards may be
+// single-use or locked to one merchant; any card may get its own limits.
+func (s *CardService) CreateCard(ctx context.Context, userID int64, req *models.CreateCardRequest) (*models.Card, error) {
+	merchantLock := strings.TrimSpace(req.MerchantLock)
+	if req.CardType != models.CardTypeVirtual && (req.SingleUse || merchantLock != "") {
+		return nil, errors.New("only virtual cards can be single-use or merchant-locked")
+	}
+	if s.collections != nil {
+		if err := s.collections.CheckGoodStanding(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without an account the card is issued on the primary account of the currency
+	var account *models.Account
+	var err error
+	if req.AccountID == 0 {
+		account, err = s.accountRepo.GetPrimary(ctx, userID, strings.ToUpper(req.Currency))
+	} else {
+		account, err = s.accountRepo.GetByID(ctx, req.AccountID)
+	}
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get account")
 		return nil, err
@@ -40,38 +84,46 @@ func (s *CardService) CreateCard(userID int64, req *models.CreateCardRequest) (*
 	if account == nil {
 		return nil, errors.New("account not found")
 	}
-	if account.UserID != userID {
-		return nil, errors.New("unauthorized: account does not belong to user")
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
 	}
 
-	// Generate card number and expiry date
-	cardNumber := generateCardNumber()
-	expiryDate := time.Now().AddDate(5, 0, 0).Format("01/06")
-	cvv := generateCVV()
+	// The card is issued under the lock of the account, which a closure takes to
+	// check that no active card is left on it
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	card := &models.Card{
-		UserID:     userID,
-		AccountID:  req.AccountID,
-		CardNumber: cardNumber,
-		ExpiryDate: expiryDate,
-		CVV:        cvv,
-		CardType:   req.CardType,
-		Status:     models.CardStatusActive,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	if account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, account.ID); err != nil {
+		s.logger.WithError(err).Error("Failed to lock account")
+		return nil, errors.New("account not found")
 	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+
+	card := newCard(userID, account.ID, req.CardType)
+	card.CardLimitProfile = req.CardLimitProfile
+	card.SingleUse = req.SingleUse
+	card.MerchantLock = merchantLock
 
-	if err := s.cardRepo.Create(card); err != nil {
+	if err := s.cardRepo.CreateTx(ctx, tx, card); err != nil {
 		s.logger.WithError(err).Error("Failed to create card")
 		return nil, err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return card, nil
 }
 
 // GetCard retrieves a card by its ID
-func (s *CardService) GetCard(userID int64, cardID int64) (*models.Card, error) {
-	card, err := s.cardRepo.GetByID(cardID)
+func (s *CardService) GetCard(ctx context.Context, userID int64, cardID int64) (*models.Card, error) {
+	card, err := s.cardRepo.GetByID(ctx, cardID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get card")
 		return nil, err
@@ -87,8 +139,8 @@ func (s *CardService) GetCard(userID int64, cardID int64) (*models.Card, error)
 }
 
 // GetUserCards retrieves all cards for a user
-func (s *CardService) GetUserCards(userID int64) ([]*models.Card, error) {
-	cards, err := s.cardRepo.GetByUserID(userID)
+func (s *CardService) GetUserCards(ctx context.Context, userID int64) ([]*models.Card, error) {
+	cards, err := s.cardRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user cards")
 		return nil, err
@@ -97,28 +149,52 @@ func (s *CardService) GetUserCards(userID int64) ([]*models.Card, error) {
 	return cards, nil
 }
 
+// ListUserCards retrieves a page of the cards of a user and how many match the
+// status filter
+func (s *CardService) ListUserCards(ctx context.Context, userID int64, p pagination.Params) ([]*models.Card, int, error) {
+	cards, total, err := s.cardRepo.ListByUserID(ctx, userID, p)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list user cards")
+		return nil, 0, errors.New("internal server error")
+	}
+
+	return cards, total, nil
+}
+
 // BlockCard blocks a card
-func (s *CardService) BlockCard(userID int64, cardID int64) error {
-	card, err := s.GetCard(userID, cardID)
+func (s *CardService) BlockCard(ctx context.Context, userID int64, cardID int64) error {
+	card, err := s.GetCard(ctx, userID, cardID)
 	if err != nil {
 		return err
 	}
 
+	if card.Status == models.CardStatusExpired {
+		return errors.New("card has expired")
+	}
 	if card.Status == models.CardStatusBlocked {
 		return errors.New("card is already blocked")
 	}
 
-	if err := s.cardRepo.UpdateStatus(cardID, models.CardStatusBlocked); err != nil {
+	if err := s.cardRepo.UpdateStatus(ctx, cardID, models.CardStatusBlocked); err != nil {
 		s.logger.WithError(err).Error("Failed to block card")
 		return err
 	}
 
+	if err := s.events.Publish(ctx, events.TypeCardBlocked, card.ID, events.CardBlocked{
+		CardID:    card.ID,
+		AccountID: card.AccountID,
+		UserID:    userID,
+		BlockedAt: time.Now().UTC(),
+	}); err != nil {
+		s.logger.WithError(err).Errorf("Failed to record card blocked event for card %d", card.ID)
+	}
+
 	return nil
 }
 
 // UnblockCard unblocks a card
-func (s *CardService) UnblockCard(userID int64, cardID int64) error {
-	card, err := s.GetCard(userID, cardID)
+func (s *CardService) UnblockCard(ctx context.Context, userID int64, cardID int64) error {
+	card, err := s.GetCard(ctx, userID, cardID)
 	if err != nil {
 		return err
 	}
@@ -126,8 +202,31 @@ func (s *CardService) UnblockCard(userID int64, cardID int64) error {
 	if card.Status == models.CardStatusActive {
 		return errors.New("card is already active")
 	}
+	if card.Expired(time.Now()) {
+		return errors.New("card has expired")
+	}
+	// The card may outlive the holder's access to the account, which an
+	// ownership transfer ends
+	account, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get card account")
+		return errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return err
+	}
+	if card.SingleUse {
+		spent, err := s.accountRepo.GetCardPaymentTotal(ctx, card.ID, time.Time{})
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to get card spending")
+			return errors.New("internal server error")
+		}
+		if spent > 0 {
+			return errors.New("single-use card has already been used")
+		}
+	}
 
-	if err := s.cardRepo.UpdateStatus(cardID, models.CardStatusActive); err != nil {
+	if err := s.cardRepo.UpdateStatus(ctx, cardID, models.CardStatusActive); err != nil {
 		s.logger.WithError(err).Error("Failed to unblock card")
 		return err
 	}
@@ -136,17 +235,17 @@ func (s *CardService) UnblockCard(userID int64, cardID int64) error {
 }
 
 // DeleteCard deletes a card
-func (s *CardService) DeleteCard(userID int64, cardID int64) error {
-	card, err := s.GetCard(userID, cardID)
+func (s *CardService) DeleteCard(ctx context.Context, userID int64, cardID int64) error {
+	card, err := s.GetCard(ctx, userID, cardID)
 	if err != nil {
 		return err
 	}
 
-	if card.Status != models.CardStatusBlocked {
+	if card.Status == models.CardStatusActive {
 		return errors.New("card must be blocked before deletion")
 	}
 
-	if err := s.cardRepo.Delete(cardID); err != nil {
+	if err := s.cardRepo.Delete(ctx, cardID); err != nil {
 		s.logger.WithError(err).Error("Failed to delete card")
 		return err
 	}
@@ -154,13 +253,354 @@ func (s *CardService) DeleteCard(userID int64, cardID int64) error {
 	return nil
 }
 
+// ReissueCard issues a replacement for a card of the user, with a new number,
+// expiry date and CVV on the same account and with the same type, limits and
+// virtual card mode. The old card keeps its status until it expires; a card is
+// reissued at most once.
+func (s *CardService) ReissueCard(ctx context.Context, userID int64, cardID int64) (*models.Card, error) {
+	card, err := s.GetCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	replacement := newCard(userID, card.AccountID, card.CardType)
+	replacement.CardLimitProfile = card.CardLimitProfile
+	replacement.SingleUse = card.SingleUse
+	replacement.MerchantLock = card.MerchantLock
+	replacement.ReplacesCardID = &card.ID
+
+	if err := s.cardRepo.Create(ctx, replacement); err != nil {
+		if err.Error() == "card has already been reissued" {
+			return nil, err
+		}
+		s.logger.WithError(err).Error("Failed to create replacement card")
+		return nil, errors.New("internal server error")
+	}
+
+	return replacement, nil
+}
+
+// ProcessCardExpiry tells the holders of active cards expiring within the notice
+// period and marks the cards past their expiry date expired. It returns the
+// number of cards it notified about or marked.
+func (s *CardService) ProcessCardExpiry(ctx context.Context, now time.Time, notice time.Duration) (int, error) {
+	processed := 0
+
+	expiring, err := s.cardRepo.GetExpiring(ctx, now.Add(notice))
+	if err != nil {
+		return processed, err
+	}
+	for _, card := range expiring {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		if !now.Before(card.ExpiresAt()) {
+			// Expires in this run, the expiry notice below covers it
+			continue
+		}
+		content := fmt.Sprintf("Your %s card %s expires at the end of %s. Reissue it to keep paying without interruption.",
+			card.CardType, card.MaskNumber(), card.ExpiryDate)
+		if err := s.notificationService.NotifyUser(ctx, card.UserID, "Your card expires soon", content); err != nil {
+			s.logger.WithError(err).Errorf("Failed to notify about expiring card %d", card.ID)
+			continue
+		}
+		if err := s.cardRepo.MarkExpiryNotified(ctx, card.ID); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	expired, err := s.cardRepo.GetExpired(ctx, now)
+	if err != nil {
+		return processed, err
+	}
+	for _, card := range expired {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		if err := s.cardRepo.UpdateStatus(ctx, card.ID, models.CardStatusExpired); err != nil {
+			return processed, err
+		}
+		processed++
+
+		content := fmt.Sprintf("Your %s card %s has expired and can no longer be used.", card.CardType, card.MaskNumber())
+		if err := s.notificationService.NotifyUser(ctx, card.UserID, "Your card has expired", content); err != nil {
+			s.logger.WithError(err).Errorf("Failed to notify about expired card %d", card.ID)
+		}
+	}
+
+	return processed, nil
+}
+
+// QuotePurchase discloses the conversion that would apply to a card payment
+// before it is authorized
+func (s *CardService) QuotePurchase(ctx context.Context, userID int64, cardID int64, amount float64, currency string) (*models.CurrencyConversion, error) {
+	if amount <= 0 {
+		return nil, errors.New("amount must be greater than zero")
+	}
+
+	card, err := s.GetCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get card account")
+		return nil, errors.New("account not found")
+	}
+
+	conversion, _, err := s.convert(ctx, amount, currency, account.Currency, time.Now())
+	return conversion, err
+}
+
+// Purchase authorizes a card payment, converting foreign currency amounts into
+// the account currency with the card scheme markup. A single-use card is blocked
+// by the payment it authorizes.
+func (s *CardService) Purchase(ctx context.Context, userID int64, cardID int64, req *models.CardPurchaseRequest) (*models.Transaction, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be greater than zero")
+	}
+
+	card, err := s.GetCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Expired(time.Now()) {
+		return nil, errors.New("card has expired")
+	}
+	if card.Status != models.CardStatusActive {
+		return nil, errors.New("card is not active")
+	}
+	if card.MerchantLock != "" && !strings.EqualFold(strings.TrimSpace(req.Merchant), card.MerchantLock) {
+		return nil, errors.New("card is locked to another merchant")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get card account")
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        req.Amount,
+		Type:          models.TransactionTypeCardPayment,
+		CardID:        card.ID,
+		Description:   req.Merchant,
+		CreatedAt:     time.Now(),
+	}
+
+	if !strings.EqualFold(req.Currency, account.Currency) {
+		conversion, markup, err := s.convert(ctx, req.Amount, req.Currency, account.Currency, transaction.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Amount = conversion.BilledAmount
+		transaction.Conversion = conversion
+		transaction.AppliedParameters = []models.AppliedParameter{markup}
+	}
+
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Card payments debit the account, so holding its lock also keeps the card
+	// totals from changing until the payment is recorded
+	account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, account.ID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to lock card account")
+		return nil, errors.New("internal server error")
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+
+	if err := s.checkCardLimitsTx(ctx, tx, card, transaction.Amount, transaction.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if account.AvailableBalance() < transaction.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+
+	if card.SingleUse {
+		if err := s.cardRepo.UseSingleUseTx(ctx, tx, card.ID); err != nil {
+			if err.Error() == "card is not active" {
+				return nil, err
+			}
+			s.logger.WithError(err).Error("Failed to block single-use card")
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post card payment")
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if card.SingleUse {
+		if err := s.events.Publish(ctx, events.TypeCardBlocked, card.ID, events.CardBlocked{
+			CardID:    card.ID,
+			AccountID: card.AccountID,
+			UserID:    card.UserID,
+			BlockedAt: transaction.CreatedAt.UTC(),
+		}); err != nil {
+			s.logger.WithError(err).Errorf("Failed to record card blocked event for card %d", card.ID)
+		}
+	}
+
+	return transaction, nil
+}
+
+// checkCardLimitsTx enforces the card's spending limits on a payment in the
+// account currency within a database transaction
+func (s *CardService) checkCardLimitsTx(ctx context.Context, tx repository.Tx, card *models.Card, amount float64, now time.Time) error {
+	if card.TransactionLimit != nil && amount > *card.TransactionLimit {
+		return errors.New("card transaction limit exceeded")
+	}
+
+	windows := []struct {
+		name  string
+		limit *float64
+		since time.Time
+	}{
+		{"daily", card.DailyLimit, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())},
+		{"monthly", card.MonthlyLimit, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())},
+	}
+	for _, window := range windows {
+		if window.limit == nil {
+			continue
+		}
+		spent, err := s.accountRepo.GetCardPaymentTotalTx(ctx, tx, card.ID, window.since)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to get card spending")
+			return errors.New("internal server error")
+		}
+		if spent+amount > *window.limit {
+			return fmt.Errorf("card %s limit exceeded", window.name)
+		}
+	}
+
+	return nil
+}
+
+// convert builds the conversion breakdown of a foreign currency amount with the
+// markup in force at the given time
+func (s *CardService) convert(ctx context.Context, amount float64, from, to string, at time.Time) (*models.CurrencyConversion, models.AppliedParameter, error) {
+	from = strings.ToUpper(from)
+	to = strings.ToUpper(to)
+
+	markup := s.paramService.Get(ctx, models.ParamCardFXMarkupPercent, at)
+
+	rate, err := s.rateService.CrossRate(from, to)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get exchange rate")
+		return nil, markup, err
+	}
+
+	markupPercent := markup.Value
+	if from == to {
+		markupPercent = 0
+	}
+
+	base := amount * rate
+	markupAmount := roundMoney(base * markupPercent / 100)
+
+	return &models.CurrencyConversion{
+		OriginalAmount:   amount,
+		OriginalCurrency: from,
+		BilledAmount:     roundMoney(base) + markupAmount,
+		BilledCurrency:   to,
+		ExchangeRate:     math.Round(rate*1e8) / 1e8,
+		MarkupPercent:    markupPercent,
+		MarkupAmount:     markupAmount,
+	}, markup, nil
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// cardBIN is the issuer identification number that prefixes all card numbers
+const cardBIN = "220070"
+
+// newCard builds an active card with a freshly generated number, expiry date and
+// CVV. The plain CVV stays on the card only until it is shown to the holder.
+func newCard(userID, accountID int64, cardType string) *models.Card {
+	card := &models.Card{
+		UserID:     userID,
+		AccountID:  accountID,
+		CardNumber: generateCardNumber(),
+		ExpiryDate: time.Now().AddDate(5, 0, 0).Format("01/06"),
+		CVV:        generateCVV(),
+		CardType:   cardType,
+		Status:     models.CardStatusActive,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := card.HashCVV(); err != nil {
+		panic(fmt.Sprintf("bcrypt failed: %v", err))
+	}
+	return card
+}
+
 // Helper functions
 func generateCardNumber() string {
-	// TODO: Implement proper card number generation with Luhn algorithm
-	return "4111111111111111"
+	payload := cardBIN + randomDigits(16-len(cardBIN)-1)
+	return payload + luhnCheckDigit(payload)
 }
 
 func generateCVV() string {
-	// TODO: Implement proper CVV generation
-	return "123"
+	return randomDigits(3)
+}
+
+func randomDigits(n int) string {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(fmt.Sprintf("crypto/rand failed: %v", err))
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits)
+}
+
+// luhnCheckDigit computes the digit that makes payload+digit pass models.LuhnCheck
+func luhnCheckDigit(payload string) string {
+	sum := 0
+	double := true
+	for i := len(payload) - 1; i >= 0; i-- {
+		digit := int(payload[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return string(rune('0' + (10-sum%10)%10))
 }
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestCardServicePurchase(t *testing.T) {
+	activeCard := models.Card{
+		ID: 3, UserID: 7, AccountID: 1, Status: models.CardStatusActive,
+		ExpiryDate: time.Now().AddDate(2, 0, 0).Format("01/06"),
+	}
+	account := models.Account{ID: 1, UserID: 7, Balance: 500, Currency: "RUB"}
+	closedAt := time.Now()
+
+	withLimits := func(card models.Card, limits models.CardLimitProfile) models.Card {
+		card.CardLimitProfile = limits
+		return card
+	}
+
+	tests := []struct {
+		name        string
+		card        models.Card
+		locked      *models.Account
+		spent       float64
+		userID      int64
+		amount      float64
+		wantErr     string
+		balance     float64
+		wantBlocked bool
+	}{
+		{
+			name:    "debits the card account",
+			card:    activeCard,
+			userID:  7,
+			amount:  120,
+			balance: 380,
+		},
+		{
+			name:    "rejects a non-positive amount",
+			card:    activeCard,
+			userID:  7,
+			amount:  0,
+			wantErr: "amount must be greater than zero",
+		},
+		{
+			name:    "rejects a card of another user",
+			card:    activeCard,
+			userID:  8,
+			amount:  120,
+			wantErr: "unauthorized: card does not belong to user",
+		},
+		{
+			name: "rejects a blocked card",
+			card: func() models.Card {
+				card := activeCard
+				card.Status = models.CardStatusBlocked
+				return card
+			}(),
+			userID:  7,
+			amount:  120,
+			wantErr: "card is not active",
+		},
+		{
+			name: "rejects a card past its expiry date",
+			card: func() models.Card {
+				card := activeCard
+				card.ExpiryDate = time.Now().AddDate(0, -1, 0).Format("01/06")
+				return card
+			}(),
+			userID:  7,
+			amount:  120,
+			wantErr: "card has expired",
+		},
+		{
+			name:    "rejects a payment over the transaction limit",
+			card:    withLimits(activeCard, models.CardLimitProfile{TransactionLimit: float64Ptr(100)}),
+			userID:  7,
+			amount:  120,
+			wantErr: "card transaction limit exceeded",
+		},
+		{
+			name:    "counts earlier payments against the daily limit",
+			card:    withLimits(activeCard, models.CardLimitProfile{DailyLimit: float64Ptr(300)}),
+			spent:   200,
+			userID:  7,
+			amount:  120,
+			wantErr: "card daily limit exceeded",
+		},
+		{
+			name:    "allows a payment within the monthly limit",
+			card:    withLimits(activeCard, models.CardLimitProfile{MonthlyLimit: float64Ptr(1000)}),
+			spent:   800,
+			userID:  7,
+			amount:  120,
+			balance: 380,
+		},
+		{
+			name: "blocks a single-use card with its payment",
+			card: func() models.Card {
+				card := activeCard
+				card.CardType = models.CardTypeVirtual
+				card.SingleUse = true
+				return card
+			}(),
+			userID:      7,
+			amount:      120,
+			balance:     380,
+			wantBlocked: true,
+		},
+		{
+			name: "rejects a payment at another merchant on a merchant-locked card",
+			card: func() models.Card {
+				card := activeCard
+				card.CardType = models.CardTypeVirtual
+				card.MerchantLock = "Cinema"
+				return card
+			}(),
+			userID:  7,
+			amount:  120,
+			wantErr: "card is locked to another merchant",
+		},
+		{
+			name: "allows a payment at the merchant of a merchant-locked card",
+			card: func() models.Card {
+				card := activeCard
+				card.CardType = models.CardTypeVirtual
+				card.MerchantLock = "bookshop"
+				return card
+			}(),
+			userID:  7,
+			amount:  120,
+			balance: 380,
+		},
+		{
+			name:    "rechecks the balance under the lock",
+			card:    activeCard,
+			locked:  &models.Account{ID: 1, UserID: 7, Balance: 50, Currency: "RUB"},
+			userID:  7,
+			amount:  120,
+			wantErr: "insufficient funds",
+		},
+		{
+			name:    "rechecks the account is open under the lock",
+			card:    activeCard,
+			locked:  &models.Account{ID: 1, UserID: 7, Currency: "RUB", ClosedAt: &closedAt},
+			userID:  7,
+			amount:  120,
+			wantErr: "account is closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			tx := env.tx()
+			book := newAccountBook(account)
+			if tt.locked != nil {
+				book.locked[tt.locked.ID] = *tt.locked
+			}
+			accounts := book.store(tx)
+			accounts.GetCardPaymentTotalTxFunc = func(_ context.Context, _ repository.Tx, cardID int64, _ time.Time) (float64, error) {
+				if cardID != tt.card.ID {
+					return 0, errors.New("unexpected card")
+				}
+				return tt.spent, nil
+			}
+			blocked := false
+			cards := &mock.CardStore{
+				GetByIDFunc: func(_ context.Context, id int64) (*models.Card, error) {
+					card := tt.card
+					return &card, nil
+				},
+				UseSingleUseTxFunc: func(_ context.Context, _ repository.Tx, id int64) error {
+					blocked = id == tt.card.ID
+					return nil
+				},
+			}
+			s := NewCardService(cards, accounts, nil, env.parameterService(), nil, env.publisher(t), env.logger)
+
+			transaction, err := s.Purchase(context.Background(), tt.userID, tt.card.ID, &models.CardPurchaseRequest{
+				Amount:   tt.amount,
+				Currency: "RUB",
+				Merchant: "Bookshop",
+			})
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Purchase() error = %v, want %q", err, tt.wantErr)
+				}
+				if tx.Committed || len(book.balances) != 0 || len(book.transactions) != 0 {
+					t.Errorf("refused payment changed state: committed %v, balances %v, transactions %d",
+						tx.Committed, book.balances, len(book.transactions))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Purchase() error = %v", err)
+			}
+			if !tx.Committed {
+				t.Error("transaction was not committed")
+			}
+			if got := book.balances[account.ID]; got != tt.balance {
+				t.Errorf("balance = %v, want %v", got, tt.balance)
+			}
+			if transaction.Amount != tt.amount || transaction.CardID != tt.card.ID || transaction.Type != models.TransactionTypeCardPayment {
+				t.Errorf("transaction = %+v, want a card payment of %v", transaction, tt.amount)
+			}
+			if blocked != tt.wantBlocked {
+				t.Errorf("card blocked = %v, want %v", blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestCardServiceCreateCardOnSharedAccount(t *testing.T) {
+	account := models.Account{ID: 1, UserID: 7, Balance: 500, Currency: "RUB"}
+	members := map[int64]models.AccountRole{8: models.AccountRoleCoOwner, 9: models.AccountRoleViewer}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		wantErr string
+	}{
+		{name: "issues a card to the owner", userID: 7},
+		{name: "issues a card to a co-owner", userID: 8},
+		{name: "rejects a view-only member", userID: 9, wantErr: "unauthorized: view-only access to the account"},
+		{name: "rejects a user without access", userID: 10, wantErr: "unauthorized: account does not belong to user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			accounts := newAccountBook(account).store(env.tx())
+			accounts.GetMemberRoleFunc = func(_ context.Context, accountID, userID int64) (models.AccountRole, error) {
+				role, ok := members[userID]
+				if accountID != account.ID || !ok {
+					return "", errors.New("account member not found")
+				}
+				return role, nil
+			}
+			var created *models.Card
+			cards := &mock.CardStore{
+				CreateTxFunc: func(_ context.Context, _ repository.Tx, card *models.Card) error {
+					created = card
+					return nil
+				},
+			}
+			s := NewCardService(cards, accounts, nil, env.parameterService(), nil, env.publisher(t), env.logger)
+
+			card, err := s.CreateCard(context.Background(), tt.userID, &models.CreateCardRequest{AccountID: account.ID, CardType: "debit"})
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("CreateCard() error = %v, want %q", err, tt.wantErr)
+				}
+				if created != nil {
+					t.Errorf("refused card was stored: %+v", created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateCard() error = %v", err)
+			}
+			if card.UserID != tt.userID || card.AccountID != account.ID {
+				t.Errorf("card holder %d on account %d, want %d on %d", card.UserID, card.AccountID, tt.userID, account.ID)
+			}
+		})
+	}
+}
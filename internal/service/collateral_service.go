@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityCollateral = "collateral"
+
+// CollateralService handles the collateral securing credits: its registration
+// and valuation by administrators, the documents of it kept in object storage
+// and the loan-to-value ratio of the credits it secures
+type CollateralService struct {
+	repo       *repository.CollateralRepository
+	creditRepo repository.CreditStore
+	auditRepo  *repository.AuditRepository
+	store      storage.Storage
+	urlExpiry  time.Duration
+	cfg        *config.CollateralConfig
+	logger     *logrus.Logger
+}
+
+// NewCollateralService creates a new CollateralService instance
+func NewCollateralService(
+	repo *repository.CollateralRepository,
+	creditRepo repository.CreditStore,
+	auditRepo *repository.AuditRepository,
+	store storage.Storage,
+	urlExpiry time.Duration,
+	cfg *config.CollateralConfig,
+	logger *logrus.Logger,
+) *CollateralService {
+	return &CollateralService{
+		repo:       repo,
+		creditRepo: creditRepo,
+		auditRepo:  auditRepo,
+		store:      store,
+		urlExpiry:  urlExpiry,
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+// CreateCollateral registers collateral of a user with its appraised value,
+// linked to one of their credits when the request names it
+func (s *CollateralService) CreateCollateral(ctx context.Context, adminID int64, req *models.CreateCollateralRequest) (*models.Collateral, error) {
+	if !slices.Contains(models.CollateralTypes, req.Type) {
+		return nil, fmt.Errorf("type must be one of %v", models.CollateralTypes)
+	}
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		return nil, errors.New("description is required")
+	}
+	if req.AppraisedValue <= 0 {
+		return nil, errors.New("appraised value must be positive")
+	}
+	if req.CreditID != nil {
+		if err := s.checkCredit(ctx, req.UserID, *req.CreditID); err != nil {
+			return nil, err
+		}
+	}
+
+	collateral := &models.Collateral{
+		UserID:         req.UserID,
+		CreditID:       req.CreditID,
+		Type:           req.Type,
+		Description:    description,
+		AppraisedValue: roundMoney(req.AppraisedValue),
+		AppraisedBy:    adminID,
+	}
+	if err := s.repo.Create(ctx, collateral); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionCollateralRegistered, collateral.ID, collateral)
+	return collateral, nil
+}
+
+// checkCredit ensures collateral of a user can secure a credit: the credit is
+// theirs and still being repaid
+func (s *CollateralService) checkCredit(ctx context.Context, userID, creditID int64) error {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		if err.Error() == "credit not found" {
+			return err
+		}
+		return errors.New("internal server error")
+	}
+	if credit.UserID != userID {
+		return errors.New("unauthorized: credit does not belong to the owner of the collateral")
+	}
+	if !credit.IsRepaying() {
+		return errors.New("credit is not being repaid")
+	}
+	return nil
+}
+
+// GetCollateral returns collateral with its documents
+func (s *CollateralService) GetCollateral(ctx context.Context, id int64) (*models.Collateral, error) {
+	collateral, err := s.getCollateral(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if collateral.Documents, err = s.repo.ListDocuments(ctx, id); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return collateral, nil
+}
+
+func (s *CollateralService) getCollateral(ctx context.Context, id int64) (*models.Collateral, error) {
+	collateral, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "collateral not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return collateral, nil
+}
+
+// ListCollateral returns collateral, of a user or securing a credit unless the
+// IDs are zero
+func (s *CollateralService) ListCollateral(ctx context.Context, userID, creditID int64) ([]*models.Collateral, error) {
+	collateral, err := s.repo.List(ctx, userID, creditID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return collateral, nil
+}
+
+// UpdateValuation records a new appraisal of collateral. The previous value is
+// kept in the audit log.
+func (s *CollateralService) UpdateValuation(ctx context.Context, adminID, id int64, req *models.UpdateCollateralValuationRequest) (*models.Collateral, error) {
+	if req.AppraisedValue <= 0 {
+		return nil, errors.New("appraised value must be positive")
+	}
+
+	collateral, err := s.getCollateral(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := collateral.AppraisedValue
+
+	collateral.AppraisedValue = roundMoney(req.AppraisedValue)
+	collateral.AppraisedBy = adminID
+	collateral.AppraisedAt = time.Now()
+	if err := s.repo.UpdateValuation(ctx, collateral); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionCollateralRevalued, collateral.ID, map[string]interface{}{
+		"previous_value":  previous,
+		"appraised_value": collateral.AppraisedValue,
+		"comment":         strings.TrimSpace(req.Comment),
+	})
+	return collateral, nil
+}
+
+// LinkCredit pledges collateral for a credit of its owner, in place of the
+// credit it secured before
+func (s *CollateralService) LinkCredit(ctx context.Context, adminID, id, creditID int64) (*models.Collateral, error) {
+	collateral, err := s.getCollateral(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCredit(ctx, collateral.UserID, creditID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetCredit(ctx, id, &creditID); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	collateral.CreditID = &creditID
+
+	s.audit(ctx, adminID, models.AuditActionCollateralLinked, collateral.ID, map[string]interface{}{"credit_id": creditID})
+	return collateral, nil
+}
+
+// ReleaseCollateral releases collateral from the credit it secures
+func (s *CollateralService) ReleaseCollateral(ctx context.Context, adminID, id int64) (*models.Collateral, error) {
+	collateral, err := s.getCollateral(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if collateral.CreditID == nil {
+		return nil, errors.New("collateral does not secure a credit")
+	}
+	creditID := *collateral.CreditID
+
+	if err := s.repo.SetCredit(ctx, id, nil); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	collateral.CreditID = nil
+
+	s.audit(ctx, adminID, models.AuditActionCollateralReleased, collateral.ID, map[string]interface{}{"credit_id": creditID})
+	return collateral, nil
+}
+
+// UploadDocument stores a document of collateral, such as a title deed or an
+// appraisal report
+func (s *CollateralService) UploadDocument(ctx context.Context, id int64, fileName string, data []byte) (*models.CollateralDocument, error) {
+	if len(data) == 0 {
+		return nil, errors.New("document is empty")
+	}
+	if int64(len(data)) > s.cfg.MaxDocumentSize {
+		return nil, fmt.Errorf("document is larger than %d bytes", s.cfg.MaxDocumentSize)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := documentContentTypes[contentType]
+	if !ok {
+		return nil, errors.New("document must be a JPEG or PNG image or a PDF file")
+	}
+
+	if _, err := s.getCollateral(ctx, id); err != nil {
+		return nil, err
+	}
+
+	doc := &models.CollateralDocument{
+		CollateralID: id,
+		StorageKey:   fmt.Sprintf("collateral/%d/%s%s", id, uuid.New().String(), ext),
+		FileName:     documentFileName(fileName, "document"+ext),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+	}
+	if err := s.store.Put(ctx, doc.StorageKey, contentType, data); err != nil {
+		s.logger.WithError(err).Errorf("Failed to store document of collateral %d", id)
+		return nil, errors.New("internal server error")
+	}
+	if err := s.repo.CreateDocument(ctx, doc); err != nil {
+		if err := s.store.Delete(ctx, doc.StorageKey); err != nil {
+			s.logger.WithError(err).Warnf("Failed to delete orphaned collateral document %s", doc.StorageKey)
+		}
+		return nil, errors.New("internal server error")
+	}
+	return doc, nil
+}
+
+// DocumentURL returns a signed URL to view a document of collateral. Handing
+// out the URL is audited as a view of the document.
+func (s *CollateralService) DocumentURL(ctx context.Context, adminID, collateralID, documentID int64) (*models.SignedURL, error) {
+	doc, err := s.repo.GetDocument(ctx, collateralID, documentID)
+	if err != nil {
+		if err.Error() == "document not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	url, err := signedURL(ctx, s.store, doc.StorageKey, s.urlExpiry)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign URL of collateral document %d", doc.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	s.audit(ctx, adminID, models.AuditActionCollateralDocumentViewed, collateralID,
+		map[string]interface{}{"document_id": doc.ID, "file_name": doc.FileName})
+	return url, nil
+}
+
+// CreditCollateral returns the collateral securing a credit of the user, or any
+// credit when the user ID is zero, with its loan-to-value ratio
+func (s *CollateralService) CreditCollateral(ctx context.Context, userID, creditID int64) (*models.CreditCollateral, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		if err.Error() == "credit not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if userID != 0 && credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	collateral, err := s.repo.List(ctx, 0, creditID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	result := &models.CreditCollateral{
+		CreditID:           credit.ID,
+		RemainingPrincipal: credit.RemainingAmount,
+		Collateral:         collateral,
+	}
+	for _, item := range collateral {
+		result.CollateralValue = roundMoney(result.CollateralValue + item.AppraisedValue)
+	}
+	result.LTV = loanToValue(credit.RemainingAmount, result.CollateralValue)
+	return result, nil
+}
+
+// loanToValue returns the principal as a percentage of the value of the
+// collateral securing it, or nil without collateral
+func loanToValue(principal, collateralValue float64) *float64 {
+	if collateralValue <= 0 {
+		return nil
+	}
+	ltv := roundMoney(principal / collateralValue * 100)
+	return &ltv
+}
+
+func (s *CollateralService) audit(ctx context.Context, adminID int64, action models.AuditAction, id int64, details interface{}) {
+	raw, _ := json.Marshal(details)
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: auditEntityCollateral,
+		EntityID:   id,
+		Details:    raw,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit %s of collateral %d", action, id)
+	}
+}
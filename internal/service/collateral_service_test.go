@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestLoanToValue(t *testing.T) {
+	tests := []struct {
+		name            string
+		principal       float64
+		collateralValue float64
+		want            *float64
+	}{
+		{name: "unsecured", principal: 500000, collateralValue: 0, want: nil},
+		{name: "half covered", principal: 500000, collateralValue: 1000000, want: float64Ptr(50)},
+		{name: "under water", principal: 900000, collateralValue: 600000, want: float64Ptr(150)},
+		{name: "repaid", principal: 0, collateralValue: 600000, want: float64Ptr(0)},
+		{name: "rounded", principal: 100000, collateralValue: 300000, want: float64Ptr(33.33)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loanToValue(tt.principal, tt.collateralValue)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("loanToValue() = %.2f, want nil", *got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("loanToValue() = %v, want %.2f", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestCollateralServiceValidation(t *testing.T) {
+	env := newTestEnv(t)
+	credits := map[int64]*models.Credit{
+		1: {ID: 1, UserID: 7, Status: string(models.CreditStatusActive)},
+		2: {ID: 2, UserID: 7, Status: string(models.CreditStatusPaid)},
+	}
+	creditStore := &mock.CreditStore{
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Credit, error) {
+			credit, ok := credits[id]
+			if !ok {
+				return nil, errors.New("credit not found")
+			}
+			return credit, nil
+		},
+	}
+	s := NewCollateralService(
+		repository.NewCollateralRepository(env.db, env.logger), creditStore,
+		repository.NewAuditRepository(env.db, env.logger), nil, time.Minute, &env.cfg.Collateral, env.logger,
+	)
+	request := func(creditID int64, collateralType, description string, value float64) *models.CreateCollateralRequest {
+		req := &models.CreateCollateralRequest{UserID: 7, Type: collateralType, Description: description, AppraisedValue: value}
+		if creditID != 0 {
+			req.CreditID = &creditID
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     *models.CreateCollateralRequest
+		wantErr string
+	}{
+		{
+			name:    "unknown type",
+			req:     request(0, "artwork", "Painting", 100000),
+			wantErr: "type must be one of",
+		},
+		{
+			name:    "no description",
+			req:     request(0, models.CollateralTypeVehicle, " ", 100000),
+			wantErr: "description is required",
+		},
+		{
+			name:    "no value",
+			req:     request(0, models.CollateralTypeVehicle, "Car", 0),
+			wantErr: "appraised value must be positive",
+		},
+		{
+			name:    "unknown credit",
+			req:     request(3, models.CollateralTypeRealEstate, "Flat", 5000000),
+			wantErr: "credit not found",
+		},
+		{
+			name:    "repaid credit",
+			req:     request(2, models.CollateralTypeRealEstate, "Flat", 5000000),
+			wantErr: "credit is not being repaid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateCollateral(context.Background(), 1, tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CreateCollateral() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("credit of another user", func(t *testing.T) {
+		req := request(1, models.CollateralTypeVehicle, "Car", 1500000)
+		req.UserID = 8
+		if _, err := s.CreateCollateral(context.Background(), 1, req); err == nil || !strings.HasPrefix(err.Error(), "unauthorized") {
+			t.Fatalf("CreateCollateral() error = %v, want unauthorized", err)
+		}
+	})
+
+	t.Run("document of unsupported type", func(t *testing.T) {
+		_, err := s.UploadDocument(context.Background(), 1, "deed.txt", bytes.Repeat([]byte("deed "), 20))
+		if err == nil || err.Error() != "document must be a JPEG or PNG image or a PDF file" {
+			t.Fatalf("UploadDocument() error = %v", err)
+		}
+	})
+}
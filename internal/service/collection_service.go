@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCreditInCollections is returned when a user whose credit is delinquent or
+// in collections asks for a new account, card or credit
+var ErrCreditInCollections = errors.New("new accounts, cards and credits are unavailable while a credit is delinquent")
+
+// CollectionService moves credits in arrears through collections. A credit
+// whose oldest unpaid installment is credit.delinquency_days overdue becomes
+// delinquent and, after credit.collections_days, goes to collections. Each
+// escalation charges a collection fee and notifies the borrower, who is then
+// reminded every credit.collection_notice_days until the arrears are paid.
+type CollectionService struct {
+	repo                *repository.CollectionRepository
+	creditRepo          repository.CreditStore
+	paramService        *ParameterService
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewCollectionService creates a new CollectionService instance
+func NewCollectionService(
+	repo *repository.CollectionRepository,
+	creditRepo repository.CreditStore,
+	paramService *ParameterService,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *CollectionService {
+	return &CollectionService{
+		repo:                repo,
+		creditRepo:          creditRepo,
+		paramService:        paramService,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// CheckGoodStanding returns ErrCreditInCollections when a credit of the user is
+// delinquent or in collections
+func (s *CollectionService) CheckGoodStanding(ctx context.Context, userID int64) error {
+	inCollections, err := s.repo.UserInCollections(ctx, userID)
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	if inCollections {
+		return ErrCreditInCollections
+	}
+	return nil
+}
+
+// collectionPolicy holds the collection parameters in force for a run
+type collectionPolicy struct {
+	delinquencyDays int
+	collectionsDays int
+	feePercent      float64
+	noticeDays      int
+}
+
+// ProcessCollections checks the credits in arrears and those whose arrears may
+// have been paid, escalating, reminding and resolving their cases. A credit that
+// fails to be checked is logged and left for the next run. It returns how many
+// credits were checked.
+func (s *CollectionService) ProcessCollections(ctx context.Context, now time.Time) (int, error) {
+	policy := collectionPolicy{
+		delinquencyDays: int(s.paramService.Get(ctx, models.ParamCreditDelinquencyDays, now).Value),
+		collectionsDays: int(s.paramService.Get(ctx, models.ParamCreditCollectionsDays, now).Value),
+		feePercent:      s.paramService.Get(ctx, models.ParamCreditCollectionFeePercent, now).Value,
+		noticeDays:      int(s.paramService.Get(ctx, models.ParamCreditCollectionNoticeDays, now).Value),
+	}
+	// A credit is never handed over to collections before it is delinquent
+	policy.collectionsDays = max(policy.collectionsDays, policy.delinquencyDays)
+
+	today := calendarDay(now, now.Location())
+	ids, err := s.repo.ListCreditsToCheck(ctx, today.AddDate(0, 0, -policy.delinquencyDays))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list credits to check: %w", err)
+	}
+
+	checked := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return checked, ctx.Err()
+		}
+		if err := s.checkCredit(ctx, id, policy, now); err != nil {
+			s.logger.WithError(err).Errorf("Failed to check credit %d for collections", id)
+			continue
+		}
+		checked++
+	}
+	return checked, nil
+}
+
+// checkCredit brings the status and the collection case of a credit in line
+// with how long its oldest unpaid installment is overdue
+func (s *CollectionService) checkCredit(ctx context.Context, creditID int64, policy collectionPolicy, now time.Time) error {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	credit, err := s.creditRepo.GetByIDForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return err
+	}
+	schedule, err := s.creditRepo.GetPaymentScheduleForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return fmt.Errorf("failed to get payment schedule: %w", err)
+	}
+	collectionCase, err := s.repo.GetOpenCaseForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return err
+	}
+
+	today := calendarDay(now, now.Location())
+	var oldest *models.PaymentSchedule
+	var overdueAmount float64
+	for _, payment := range schedule {
+		if payment.Status != models.PaymentStatusOverdue || payment.AmountDue() == 0 {
+			continue
+		}
+		if oldest == nil || payment.DueDate.Before(oldest.DueDate) {
+			oldest = payment
+		}
+		overdueAmount += payment.AmountDue()
+	}
+	daysOverdue := 0
+	if oldest != nil {
+		// Dates read from the database carry no zone, their calendar day is what counts
+		due := calendarDay(oldest.DueDate, today.Location())
+		daysOverdue = int(math.Round(today.Sub(due).Hours() / 24))
+	}
+
+	// A case only escalates while open, a partial payment does not take it back
+	var stage models.CreditStatus
+	switch {
+	case !credit.IsRepaying() || daysOverdue < policy.delinquencyDays:
+	case daysOverdue >= policy.collectionsDays:
+		stage = models.CreditStatusCollections
+	default:
+		stage = models.CreditStatusDelinquent
+	}
+	if stage == models.CreditStatusDelinquent && collectionCase != nil {
+		stage = collectionCase.Stage
+	}
+
+	if stage == "" {
+		if err := s.resolve(ctx, tx, credit, collectionCase, now); err != nil {
+			return err
+		}
+	} else if err := s.escalate(ctx, tx, credit, collectionCase, stage, oldest, overdueAmount, daysOverdue, policy, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// escalate opens or updates the case of a credit in arrears, charging the fee
+// and notifying the borrower when it reaches a new stage and reminding them
+// when the last notice is older than the notice period
+func (s *CollectionService) escalate(
+	ctx context.Context,
+	tx repository.Tx,
+	credit *models.Credit,
+	collectionCase *models.CollectionCase,
+	stage models.CreditStatus,
+	oldest *models.PaymentSchedule,
+	overdueAmount float64,
+	daysOverdue int,
+	policy collectionPolicy,
+	now time.Time,
+) error {
+	if collectionCase == nil {
+		collectionCase = &models.CollectionCase{
+			CreditID: credit.ID,
+			UserID:   credit.UserID,
+			Status:   models.CollectionCaseStatusOpen,
+		}
+	}
+	collectionCase.DaysOverdue = daysOverdue
+	collectionCase.OverdueAmount = roundMoney(overdueAmount)
+
+	subject, content := "", ""
+	if stage != collectionCase.Stage {
+		// Each escalation charges the fee on the overdue amount, added to the
+		// penalty of the oldest overdue installment so it is paid with it
+		fee := roundMoney(overdueAmount * policy.feePercent / 100)
+		if fee > 0 {
+			if err := s.repo.AddFeeTx(ctx, tx, oldest.ID, fee); err != nil {
+				return err
+			}
+			collectionCase.Fees = roundMoney(collectionCase.Fees + fee)
+			collectionCase.OverdueAmount = roundMoney(collectionCase.OverdueAmount + fee)
+		}
+		collectionCase.Stage = stage
+		collectionCase.EscalatedAt = &now
+		subject, content = escalationNotice(credit, collectionCase, fee)
+	} else if collectionCase.LastNoticeAt == nil ||
+		!now.Before(collectionCase.LastNoticeAt.AddDate(0, 0, policy.noticeDays)) {
+		subject, content = reminderNotice(credit, collectionCase)
+	}
+
+	if subject != "" {
+		if err := s.notificationService.NotifyUserTx(ctx, tx, credit.UserID, subject, content); err != nil {
+			return err
+		}
+		collectionCase.NoticesSent++
+		collectionCase.LastNoticeAt = &now
+	}
+
+	var err error
+	if collectionCase.ID == 0 {
+		err = s.repo.CreateTx(ctx, tx, collectionCase)
+	} else {
+		err = s.repo.UpdateTx(ctx, tx, collectionCase)
+	}
+	if err != nil {
+		return errors.New("internal server error")
+	}
+
+	if credit.Status != string(stage) {
+		credit.Status = string(stage)
+		if err := s.creditRepo.UpdateTx(ctx, tx, credit); err != nil {
+			return err
+		}
+		s.logger.Infof("Credit %d is %s, %d days overdue", credit.ID, stage, daysOverdue)
+	}
+	return nil
+}
+
+// resolve closes the open case of a credit whose arrears were paid and puts the
+// credit back in good standing
+func (s *CollectionService) resolve(ctx context.Context, tx repository.Tx, credit *models.Credit, collectionCase *models.CollectionCase, now time.Time) error {
+	if credit.InCollections() {
+		credit.Status = string(models.CreditStatusActive)
+		if err := s.creditRepo.UpdateTx(ctx, tx, credit); err != nil {
+			return err
+		}
+	}
+	if collectionCase == nil {
+		return nil
+	}
+
+	collectionCase.Status = models.CollectionCaseStatusResolved
+	collectionCase.ResolvedAt = &now
+	collectionCase.DaysOverdue = 0
+	collectionCase.OverdueAmount = 0
+	if err := s.repo.UpdateTx(ctx, tx, collectionCase); err != nil {
+		return errors.New("internal server error")
+	}
+
+	content := fmt.Sprintf("The arrears of your credit #%d are paid and the credit is back in good standing. "+
+		"You can open accounts, issue cards and apply for credits again.", credit.ID)
+	if err := s.notificationService.NotifyUserTx(ctx, tx, credit.UserID, "Credit back in good standing", content); err != nil {
+		return err
+	}
+	s.logger.Infof("Collection case %d of credit %d resolved", collectionCase.ID, credit.ID)
+	return nil
+}
+
+// escalationNotice words the notice of a credit reaching a collection stage
+func escalationNotice(credit *models.Credit, c *models.CollectionCase, fee float64) (string, string) {
+	var subject, content string
+	if c.Stage == models.CreditStatusCollections {
+		subject = "Credit handed over to collections"
+		content = fmt.Sprintf("Your credit #%d is %d days overdue and has been handed over to collections. "+
+			"Pay the overdue amount of %.2f at once.", credit.ID, c.DaysOverdue, c.OverdueAmount)
+	} else {
+		subject = "Credit payment overdue"
+		content = fmt.Sprintf("Your credit #%d is %d days overdue and is now delinquent. "+
+			"Pay the overdue amount of %.2f to avoid collections.", credit.ID, c.DaysOverdue, c.OverdueAmount)
+	}
+	if fee > 0 {
+		content += fmt.Sprintf(" A collection fee of %.2f has been charged and is included in that amount.", fee)
+	}
+	content += " Until then you cannot open accounts, issue cards or apply for credits."
+	return subject, content
+}
+
+// reminderNotice words a reminder of the arrears of a credit at its collection
+// stage, more urgent with every reminder sent
+func reminderNotice(credit *models.Credit, c *models.CollectionCase) (string, string) {
+	content := fmt.Sprintf("Your credit #%d is %d days overdue, %.2f is still to be paid.",
+		credit.ID, c.DaysOverdue, c.OverdueAmount)
+	if c.Stage == models.CreditStatusCollections {
+		return "Final reminder: credit in collections",
+			content + " The credit is in collections; unpaid arrears may be recovered through the courts."
+	}
+	if c.NoticesSent >= 2 {
+		return "Urgent reminder: credit payment overdue",
+			content + " Without payment the credit will be handed over to collections and further fees charged."
+	}
+	return "Reminder: credit payment overdue", content + " Please pay the overdue amount as soon as possible."
+}
+
+// ListCases retrieves a page of the collection cases, of a stage unless it is empty
+func (s *CollectionService) ListCases(ctx context.Context, stage string, p pagination.Params) ([]*models.CollectionCase, int, error) {
+	switch models.CreditStatus(stage) {
+	case "", models.CreditStatusDelinquent, models.CreditStatusCollections:
+	default:
+		return nil, 0, errors.New("stage must be delinquent or collections")
+	}
+
+	cases, total, err := s.repo.List(ctx, stage, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return cases, total, nil
+}
+
+// Summary sums up the open collection cases for the collections dashboard
+func (s *CollectionService) Summary(ctx context.Context) (*models.CollectionSummary, error) {
+	summary, err := s.repo.Summary(ctx)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	summary.GeneratedAt = time.Now()
+	return summary, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestCollectionServiceCheckCredit(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	policy := collectionPolicy{delinquencyDays: 30, collectionsDays: 90, feePercent: 5, noticeDays: 7}
+
+	tests := []struct {
+		name        string
+		status      models.CreditStatus
+		dueDaysAgo  int
+		paid        bool
+		wantStatus  models.CreditStatus
+		wantUpdated bool
+	}{
+		{name: "overdue within the delinquency period", status: models.CreditStatusActive, dueDaysAgo: 29, wantStatus: models.CreditStatusActive},
+		{name: "delinquent", status: models.CreditStatusActive, dueDaysAgo: 30, wantStatus: models.CreditStatusDelinquent, wantUpdated: true},
+		{name: "handed over to collections", status: models.CreditStatusDelinquent, dueDaysAgo: 95, wantStatus: models.CreditStatusCollections, wantUpdated: true},
+		{name: "arrears paid", status: models.CreditStatusCollections, dueDaysAgo: 95, paid: true, wantStatus: models.CreditStatusActive, wantUpdated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &models.PaymentSchedule{
+				ID: 3, CreditID: 5, Amount: 10000, Penalty: 200, DueDate: now.AddDate(0, 0, -tt.dueDaysAgo),
+				Status: models.PaymentStatusOverdue,
+			}
+			if tt.paid {
+				payment.Status = models.PaymentStatusPaid
+				payment.PaidAmount = 10200
+			}
+
+			var updated *models.Credit
+			credits := &mock.CreditStore{
+				GetByIDForUpdateFunc: func(context.Context, repository.Tx, int64) (*models.Credit, error) {
+					return &models.Credit{ID: 5, UserID: 7, RemainingAmount: 40000, Status: string(tt.status)}, nil
+				},
+				GetPaymentScheduleForUpdateFunc: func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error) {
+					return []*models.PaymentSchedule{payment}, nil
+				},
+				UpdateTxFunc: func(_ context.Context, _ repository.Tx, c *models.Credit) error {
+					updated = c
+					return nil
+				},
+			}
+			notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+				repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+			s := NewCollectionService(repository.NewCollectionRepository(env.db, env.logger), credits, nil, notificationService, env.logger)
+
+			if err := s.checkCredit(context.Background(), 5, policy, now); err != nil {
+				t.Fatalf("check credit: %v", err)
+			}
+			if (updated != nil) != tt.wantUpdated {
+				t.Fatalf("credit updated %v, want %v", updated != nil, tt.wantUpdated)
+			}
+			if updated != nil && updated.Status != string(tt.wantStatus) {
+				t.Errorf("credit status %s, want %s", updated.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEscalationNoticeMentionsFee(t *testing.T) {
+	credit := &models.Credit{ID: 5}
+	c := &models.CollectionCase{Stage: models.CreditStatusDelinquent, DaysOverdue: 31, OverdueAmount: 10710}
+
+	subject, content := escalationNotice(credit, c, 510)
+	if subject != "Credit payment overdue" {
+		t.Errorf("subject %q", subject)
+	}
+	for _, want := range []string{"31 days overdue", "10710.00", "collection fee of 510.00", "cannot open accounts"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("notice %q does not mention %q", content, want)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityCreditApplication = "credit_application"
+
+// creditApplicationJobPayload identifies the application scored by a scoring job
+type creditApplicationJobPayload struct {
+	ApplicationID int64 `json:"application_id"`
+}
+
+// CreditApplicationService handles the credit application and approval workflow
+type CreditApplicationService struct {
+	appRepo             *repository.CreditApplicationRepository
+	accountRepo         repository.AccountStore
+	userRepo            repository.UserStore
+	auditRepo           *repository.AuditRepository
+	creditService       *CreditService
+	scoringService      *ScoringService
+	paramService        *ParameterService
+	rateService         *RateService
+	notificationService *NotificationService
+	webhookService      *WebhookService
+	jobRunner           *jobs.Runner
+	collections         *CollectionService
+	insurance           *CreditInsuranceService
+	logger              *logrus.Logger
+}
+
+// NewCreditApplicationService creates a new CreditApplicationService instance and
+// registers its scoring job with the job runner
+func NewCreditApplicationService(
+	appRepo *repository.CreditApplicationRepository,
+	accountRepo repository.AccountStore,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	creditService *CreditService,
+	scoringService *ScoringService,
+	paramService *ParameterService,
+	rateService *RateService,
+	notificationService *NotificationService,
+	webhookService *WebhookService,
+	jobRunner *jobs.Runner,
+	logger *logrus.Logger,
+) *CreditApplicationService {
+	s := &CreditApplicationService{
+		appRepo:             appRepo,
+		accountRepo:         accountRepo,
+		userRepo:            userRepo,
+		auditRepo:           auditRepo,
+		creditService:       creditService,
+		scoringService:      scoringService,
+		paramService:        paramService,
+		rateService:         rateService,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		jobRunner:           jobRunner,
+		logger:              logger,
+	}
+	jobRunner.Register(models.JobTypeCreditApplicationScoring, s.processScoringJob)
+	return s
+}
+
+// SetCollections refuses credits to users whose credit is delinquent or in
+// collections
+func (s *CreditApplicationService) SetCollections(collections *CollectionService) {
+	s.collections = collections
+}
+
+// SetInsurance offers payment protection insurance with credits
+func (s *CreditApplicationService) SetInsurance(insurance *CreditInsuranceService) {
+	s.insurance = insurance
+}
+
+// Apply registers a pending credit application and queues it for scoring. Only
+// users whose identity is verified may apply.
+func (s *CreditApplicationService) Apply(ctx context.Context, userID int64, req *models.CreateCreditApplicationRequest) (*models.CreditApplication, error) {
+	if req.Amount <= 0 || req.TermMonths <= 0 {
+		return nil, errors.New("amount and term must be greater than zero")
+	}
+	scheduleType := req.ScheduleType
+	if scheduleType == "" {
+		scheduleType = models.ScheduleTypeAnnuity
+	}
+	if scheduleType != models.ScheduleTypeAnnuity && scheduleType != models.ScheduleTypeDifferentiated {
+		return nil, errors.New("schedule type must be ANNUITY or DIFFERENTIATED")
+	}
+	rateType := req.RateType
+	if rateType == "" {
+		rateType = models.RateTypeFixed
+	}
+	if rateType != models.RateTypeFixed && rateType != models.RateTypeVariable {
+		return nil, errors.New("rate type must be fixed or variable")
+	}
+	if req.DeclaredIncome < 0 {
+		return nil, errors.New("declared income cannot be negative")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user")
+		return nil, errors.New("internal server error")
+	}
+	if user.KYCStatus != models.KYCStatusVerified {
+		return nil, errors.New("identity must be verified to apply for a credit")
+	}
+	if s.collections != nil {
+		if err := s.collections.CheckGoodStanding(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.UserID != userID {
+		return nil, errors.New("unauthorized: account does not belong to user")
+	}
+
+	// The rate is the bank's, fixed when the application is registered so the
+	// applicant is scored and credited at the rate in force when they applied
+	rate := s.paramService.Get(ctx, models.ParamCreditInterestRatePercent, time.Now())
+	if rate.Value <= 0 {
+		s.logger.Error("Credit interest rate parameter is not positive")
+		return nil, errors.New("internal server error")
+	}
+
+	// The applicant's credit score moves the rate, or the margin of a variable
+	// rate, around the bank's; a discount takes at most half of it
+	score, err := s.scoringService.ScoreUser(ctx, userID, req.DeclaredIncome, models.CreditScoreReasonCreditApplication, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	spread := s.paramService.Get(ctx, models.ParamScoringRateSpreadPercent, time.Now()).Value
+	adjustment := scoreRateAdjustment(score.Score, spread)
+
+	applied := math.Max(adjustment, -rate.Value/2)
+	interestRate := roundMoney(rate.Value + applied)
+	var rateMargin *float64
+	if rateType == models.RateTypeVariable {
+		// A variable rate starts at the key rate plus the bank's margin, which
+		// stays with the credit as the key rate changes
+		keyRate, err := s.rateService.GetKeyRate()
+		if err != nil {
+			return nil, errors.New("variable rate credits are unavailable while the key rate is unknown")
+		}
+		margin := s.paramService.Get(ctx, models.ParamCreditVariableRateMarginPercent, time.Now()).Value
+		applied = math.Max(adjustment, -margin/2)
+		margin = roundMoney(margin + applied)
+		rateMargin = &margin
+		interestRate = roundMoney(keyRate.Rate + margin)
+	}
+
+	var insurancePremium *float64
+	if req.Insurance {
+		if s.insurance == nil {
+			return nil, errors.New("credit insurance is not offered")
+		}
+		premium := s.insurance.Premium(ctx, req.Amount, time.Now())
+		insurancePremium = &premium
+	}
+
+	app := &models.CreditApplication{
+		UserID:           userID,
+		AccountID:        req.AccountID,
+		Amount:           req.Amount,
+		TermMonths:       req.TermMonths,
+		InterestRate:     interestRate,
+		RateType:         rateType,
+		RateMargin:       rateMargin,
+		ScheduleType:     scheduleType,
+		DeclaredIncome:   req.DeclaredIncome,
+		CreditScore:      &score.Score,
+		RateAdjustment:   roundMoney(applied),
+		InsurancePremium: insurancePremium,
+		Status:           models.CreditApplicationStatusPending,
+	}
+
+	tx, err := s.appRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.appRepo.CreateTx(ctx, tx, app); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if _, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeCreditApplicationScoring, creditApplicationJobPayload{ApplicationID: app.ID}); err != nil {
+		s.logger.WithError(err).Error("Failed to queue credit application scoring")
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return app, nil
+}
+
+// Preview computes the schedule of a credit without applying for it, for loan
+// calculators. The first installment is due a month after now. Without a
+// rate the bank's current one is used, before the adjustment by the
+// applicant's credit score that an application gets. The cost of the credit
+// includes the origination fee and insurance premium of the request.
+func (s *CreditApplicationService) Preview(ctx context.Context, req *models.CreditPreviewRequest, now time.Time) (*models.CreditPreview, error) {
+	if req.Amount <= 0 || req.TermMonths <= 0 {
+		return nil, errors.New("amount and term must be greater than zero")
+	}
+	if req.TermMonths > models.MaxCreditPreviewTermMonths {
+		return nil, fmt.Errorf("term must not exceed %d months", models.MaxCreditPreviewTermMonths)
+	}
+	if req.OriginationFee < 0 || req.InsurancePremium < 0 {
+		return nil, errors.New("fees must not be negative")
+	}
+	if req.OriginationFee >= req.Amount {
+		return nil, errors.New("origination fee must be less than the amount")
+	}
+	scheduleType := req.ScheduleType
+	if scheduleType == "" {
+		scheduleType = models.ScheduleTypeAnnuity
+	}
+	if scheduleType != models.ScheduleTypeAnnuity && scheduleType != models.ScheduleTypeDifferentiated {
+		return nil, errors.New("schedule type must be ANNUITY or DIFFERENTIATED")
+	}
+	rateType := req.RateType
+	if rateType == "" {
+		rateType = models.RateTypeFixed
+	}
+	if rateType != models.RateTypeFixed && rateType != models.RateTypeVariable {
+		return nil, errors.New("rate type must be fixed or variable")
+	}
+
+	var interestRate float64
+	switch {
+	case req.InterestRate != nil:
+		if *req.InterestRate < 0 || *req.InterestRate > 100 {
+			return nil, errors.New("interest rate must be between 0 and 100")
+		}
+		interestRate = *req.InterestRate
+	case rateType == models.RateTypeVariable:
+		keyRate, err := s.rateService.GetKeyRate()
+		if err != nil {
+			return nil, errors.New("variable rate credits are unavailable while the key rate is unknown")
+		}
+		margin := s.paramService.Get(ctx, models.ParamCreditVariableRateMarginPercent, now).Value
+		interestRate = roundMoney(keyRate.Rate + margin)
+	default:
+		interestRate = s.paramService.Get(ctx, models.ParamCreditInterestRatePercent, now).Value
+		if interestRate <= 0 {
+			s.logger.Error("Credit interest rate parameter is not positive")
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	credit := &models.Credit{
+		Amount:       roundMoney(req.Amount),
+		TermMonths:   req.TermMonths,
+		InterestRate: interestRate,
+		ScheduleType: scheduleType,
+	}
+	installments := models.GeneratePaymentSchedule(credit, now.AddDate(0, 1, 0))
+
+	preview := &models.CreditPreview{
+		Amount:       credit.Amount,
+		TermMonths:   credit.TermMonths,
+		InterestRate: interestRate,
+		RateType:     rateType,
+		ScheduleType: scheduleType,
+		Schedule:     make([]*models.CreditPreviewInstallment, len(installments)),
+	}
+	balance := credit.Amount
+	for i, installment := range installments {
+		balance = roundMoney(balance - installment.Principal)
+		preview.Schedule[i] = &models.CreditPreviewInstallment{
+			Number:    i + 1,
+			DueDate:   installment.DueDate,
+			Amount:    installment.Amount,
+			Principal: installment.Principal,
+			Interest:  installment.Interest,
+			Balance:   balance,
+		}
+		preview.TotalPayment += installment.Amount
+	}
+	preview.MonthlyPayment = installments[0].Amount
+	preview.TotalPayment = roundMoney(preview.TotalPayment)
+	preview.CreditCost = *models.CalculateCreditCost(credit.Amount, installments, req.CreditCharges, now, nil)
+	return preview, nil
+}
+
+// GetApplication retrieves a credit application of a user
+func (s *CreditApplicationService) GetApplication(ctx context.Context, userID, applicationID int64) (*models.CreditApplication, error) {
+	app, err := s.appRepo.GetByID(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	if app.UserID != userID {
+		return nil, errors.New("unauthorized: application does not belong to user")
+	}
+	return app, nil
+}
+
+// GetUserApplications retrieves all credit applications of a user
+func (s *CreditApplicationService) GetUserApplications(ctx context.Context, userID int64) ([]*models.CreditApplication, error) {
+	apps, err := s.appRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return apps, nil
+}
+
+// ListApplications retrieves credit applications for administrators, optionally filtered by status
+func (s *CreditApplicationService) ListApplications(ctx context.Context, status models.CreditApplicationStatus) ([]*models.CreditApplication, error) {
+	apps, err := s.appRepo.List(ctx, status)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return apps, nil
+}
+
+// ApproveApplication approves an application routed to manual review and disburses the credit
+func (s *CreditApplicationService) ApproveApplication(ctx context.Context, adminID, applicationID int64, comment string) (*models.CreditApplication, error) {
+	return s.review(ctx, adminID, applicationID, comment, true)
+}
+
+// RejectApplication rejects an application routed to manual review
+func (s *CreditApplicationService) RejectApplication(ctx context.Context, adminID, applicationID int64, comment string) (*models.CreditApplication, error) {
+	return s.review(ctx, adminID, applicationID, comment, false)
+}
+
+func (s *CreditApplicationService) review(ctx context.Context, adminID, applicationID int64, comment string, approve bool) (*models.CreditApplication, error) {
+	tx, err := s.appRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	app, err := s.appRepo.GetByIDForUpdate(ctx, tx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	if app.Status != models.CreditApplicationStatusManualReview {
+		return nil, errors.New("credit application is not awaiting manual review")
+	}
+
+	now := time.Now()
+	app.ReviewedBy = &adminID
+	app.ReviewComment = comment
+	app.DecidedAt = &now
+
+	action := models.AuditActionCreditApplicationRejected
+	app.Status = models.CreditApplicationStatusRejected
+	if approve {
+		action = models.AuditActionCreditApplicationApproved
+		app.Status = models.CreditApplicationStatusApproved
+		if err := s.disburseTx(ctx, tx, app); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.appRepo.UpdateDecisionTx(ctx, tx, app); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"status":    app.Status,
+		"comment":   comment,
+		"credit_id": app.CreditID,
+	})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: auditEntityCreditApplication,
+		EntityID:   app.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyDecisionTx(ctx, tx, app); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return app, nil
+}
+
+// processScoringJob scores a pending application. Approved applications are
+// disbursed straight away, borderline ones wait for an administrator.
+func (s *CreditApplicationService) processScoringJob(ctx context.Context, job *models.Job) error {
+	var payload creditApplicationJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid credit application job payload: %w", err)
+	}
+
+	app, err := s.appRepo.GetByID(ctx, payload.ApplicationID)
+	if err != nil {
+		return err
+	}
+	if app.Status != models.CreditApplicationStatusPending {
+		return nil
+	}
+
+	now := time.Now()
+	decision, err := s.scoringService.EvaluateApplication(ctx, app, now)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.appRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	app, err = s.appRepo.GetByIDForUpdate(ctx, tx, payload.ApplicationID)
+	if err != nil {
+		return err
+	}
+	if app.Status != models.CreditApplicationStatusPending {
+		return nil
+	}
+
+	app.Status = decision.Status
+	app.Score = &decision.Score
+	app.MonthlyIncome = &decision.MonthlyIncome
+	app.MonthlyPayment = &decision.MonthlyPayment
+	app.DebtToIncome = &decision.DebtToIncome
+	app.DecisionReason = decision.Reason
+	app.AppliedParameters = decision.AppliedParameters
+	if decision.Status != models.CreditApplicationStatusManualReview {
+		app.DecidedAt = &now
+	}
+
+	if app.Status == models.CreditApplicationStatusApproved {
+		if err := s.disburseTx(ctx, tx, app); err != nil {
+			return err
+		}
+	}
+
+	if err := s.appRepo.UpdateDecisionTx(ctx, tx, app); err != nil {
+		return err
+	}
+
+	if err := s.notifyDecisionTx(ctx, tx, app); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// disburseTx opens the credit of an approved application with the insurance
+// taken with it
+func (s *CreditApplicationService) disburseTx(ctx context.Context, tx repository.Tx, app *models.CreditApplication) error {
+	credit := &models.Credit{
+		UserID:       app.UserID,
+		AccountID:    app.AccountID,
+		Amount:       app.Amount,
+		InterestRate: app.InterestRate,
+		RateType:     app.RateType,
+		RateMargin:   app.RateMargin,
+		TermMonths:   app.TermMonths,
+		ScheduleType: app.ScheduleType,
+	}
+	var premium float64
+	if app.InsurancePremium != nil {
+		if s.insurance == nil {
+			return errors.New("credit insurance is not offered")
+		}
+		premium = *app.InsurancePremium
+	}
+	if err := s.creditService.openCreditTx(ctx, tx, credit, premium); err != nil {
+		return err
+	}
+	if premium > 0 {
+		if err := s.insurance.AttachTx(ctx, tx, credit, premium); err != nil {
+			return err
+		}
+	}
+	app.CreditID = &credit.ID
+	return nil
+}
+
+// notifyDecisionTx queues the notifications of the applicant and their webhook
+// subscribers in the decision's transaction; they are sent once it commits
+func (s *CreditApplicationService) notifyDecisionTx(ctx context.Context, tx repository.Tx, app *models.CreditApplication) error {
+	if err := s.webhookService.PublishTx(ctx, tx, app.UserID, models.WebhookEventCreditApplicationDecided, app); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue webhook event for credit application %d", app.ID)
+		return err
+	}
+
+	var subject, content string
+	switch app.Status {
+	case models.CreditApplicationStatusApproved:
+		subject = "Credit application approved"
+		content = fmt.Sprintf("Your credit application #%d for %.2f has been approved and the funds were credited to account #%d.", app.ID, app.Amount, app.AccountID)
+	case models.CreditApplicationStatusRejected:
+		subject = "Credit application declined"
+		content = fmt.Sprintf("Your credit application #%d for %.2f has been declined.", app.ID, app.Amount)
+	case models.CreditApplicationStatusManualReview:
+		subject = "Credit application under review"
+		content = fmt.Sprintf("Your credit application #%d for %.2f has been passed to a credit officer for review.", app.ID, app.Amount)
+	default:
+		return nil
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, app.UserID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about credit application %d", app.ID)
+		return err
+	}
+	return nil
+}
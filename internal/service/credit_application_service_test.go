@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+func TestCreditApplicationServicePreview(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		req          models.CreditPreviewRequest
+		wantErr      string
+		wantRate     float64
+		wantMonthly  float64
+		wantInterest float64
+		wantAPR      float64
+		wantCost     float64
+	}{
+		{
+			name:         "annuity",
+			req:          models.CreditPreviewRequest{Amount: 120000, TermMonths: 12, InterestRate: float64Ptr(12)},
+			wantRate:     12,
+			wantMonthly:  10661.85,
+			wantInterest: 7942.26,
+			wantAPR:      12.683,
+		},
+		{
+			name:         "differentiated",
+			req:          models.CreditPreviewRequest{Amount: 120000, TermMonths: 12, InterestRate: float64Ptr(12), ScheduleType: models.ScheduleTypeDifferentiated},
+			wantRate:     12,
+			wantMonthly:  11200,
+			wantInterest: 7800,
+			wantAPR:      12.683,
+		},
+		{
+			name: "with fees",
+			req: models.CreditPreviewRequest{
+				Amount: 120000, TermMonths: 12, InterestRate: float64Ptr(12),
+				CreditCharges: models.CreditCharges{OriginationFee: 2400, InsurancePremium: 100},
+			},
+			wantRate:     12,
+			wantMonthly:  10661.85,
+			wantInterest: 7942.26,
+			wantAPR:      19.147,
+			wantCost:     11542.26,
+		},
+		{
+			name:        "interest free",
+			req:         models.CreditPreviewRequest{Amount: 120000, TermMonths: 12, InterestRate: float64Ptr(0)},
+			wantMonthly: 10000,
+		},
+		{
+			name:     "bank rate",
+			req:      models.CreditPreviewRequest{Amount: 120000, TermMonths: 12},
+			wantRate: -1, // the credit.interest_rate_percent parameter
+		},
+		{
+			name:    "term too long",
+			req:     models.CreditPreviewRequest{Amount: 120000, TermMonths: 361, InterestRate: float64Ptr(12)},
+			wantErr: "term must not exceed 360 months",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			jobRunner := jobs.NewRunner(repository.NewJobRepository(env.db, env.logger), &env.cfg.Jobs, env.logger)
+			s := NewCreditApplicationService(nil, nil, nil, nil, nil, nil, env.parameterService(), nil, nil, nil, jobRunner, env.logger)
+
+			preview, err := s.Preview(context.Background(), &tt.req, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Preview() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Preview() error = %v", err)
+			}
+
+			if len(preview.Schedule) != tt.req.TermMonths {
+				t.Fatalf("schedule has %d installments, want %d", len(preview.Schedule), tt.req.TermMonths)
+			}
+			last := preview.Schedule[len(preview.Schedule)-1]
+			if last.Balance != 0 || !preview.Schedule[0].DueDate.Equal(now.AddDate(0, 1, 0)) {
+				t.Errorf("first installment due %v, balance left %.2f", preview.Schedule[0].DueDate, last.Balance)
+			}
+			if roundMoney(preview.TotalPayment-preview.TotalInterest) != tt.req.Amount {
+				t.Errorf("total payment %.2f less interest %.2f does not repay the amount", preview.TotalPayment, preview.TotalInterest)
+			}
+
+			if tt.wantRate < 0 {
+				if want := env.cfg.Credit.InterestRatePercent; preview.InterestRate != want || preview.APR <= want {
+					t.Errorf("rate %.2f, apr %.3f, want the bank's rate %.2f", preview.InterestRate, preview.APR, want)
+				}
+				return
+			}
+			if preview.InterestRate != tt.wantRate || preview.MonthlyPayment != tt.wantMonthly ||
+				preview.TotalInterest != tt.wantInterest || preview.APR != tt.wantAPR {
+				t.Errorf("rate %.2f, monthly %.2f, interest %.2f, apr %.3f; want %.2f, %.2f, %.2f, %.3f",
+					preview.InterestRate, preview.MonthlyPayment, preview.TotalInterest, preview.APR,
+					tt.wantRate, tt.wantMonthly, tt.wantInterest, tt.wantAPR)
+			}
+			wantCost := tt.wantInterest
+			if tt.wantCost != 0 {
+				wantCost = tt.wantCost
+			}
+			if preview.TotalCost != wantCost || preview.FullCostRate > preview.APR {
+				t.Errorf("total cost %.2f, want %.2f; full cost rate %.3f above apr %.3f", preview.TotalCost, wantCost, preview.FullCostRate, preview.APR)
+			}
+		})
+	}
+}
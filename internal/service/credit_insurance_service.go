@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityCreditInsuranceClaim = "credit_insurance_claim"
+
+// CreditInsuranceService handles the payment protection insurance taken with
+// credits and the claims on it. An approved claim pauses the schedule of the
+// credit until an administrator closes it.
+type CreditInsuranceService struct {
+	repo                *repository.CreditInsuranceRepository
+	creditRepo          repository.CreditStore
+	auditRepo           *repository.AuditRepository
+	paramService        *ParameterService
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewCreditInsuranceService creates a new CreditInsuranceService instance
+func NewCreditInsuranceService(
+	repo *repository.CreditInsuranceRepository,
+	creditRepo repository.CreditStore,
+	auditRepo *repository.AuditRepository,
+	paramService *ParameterService,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *CreditInsuranceService {
+	return &CreditInsuranceService{
+		repo:                repo,
+		creditRepo:          creditRepo,
+		auditRepo:           auditRepo,
+		paramService:        paramService,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// Premium returns the monthly premium of the insurance of a credit of amount
+// taken at the given time
+func (s *CreditInsuranceService) Premium(ctx context.Context, amount float64, at time.Time) float64 {
+	rate := s.paramService.Get(ctx, models.ParamCreditInsuranceRatePercent, at)
+	return roundMoney(amount * rate.Value / 100 / 12)
+}
+
+// AttachTx insures a credit being opened in the caller's transaction. The
+// premium has been added to its installments.
+func (s *CreditInsuranceService) AttachTx(ctx context.Context, tx repository.Tx, credit *models.Credit, premium float64) error {
+	insurance := &models.CreditInsurance{
+		CreditID:       credit.ID,
+		UserID:         credit.UserID,
+		MonthlyPremium: premium,
+		Status:         models.CreditInsuranceStatusActive,
+	}
+	if err := s.repo.CreateTx(ctx, tx, insurance); err != nil {
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// GetInsurance retrieves the insurance of the user's credit with its claims
+func (s *CreditInsuranceService) GetInsurance(ctx context.Context, userID, creditID int64) (*models.CreditInsurance, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	insurance, err := s.repo.GetByCreditID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if insurance.Claims, err = s.repo.GetClaimsByCreditID(ctx, creditID); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return insurance, nil
+}
+
+// FileClaim registers a pending claim on the insurance of the user's credit. The
+// schedule is only paused once an administrator approves the claim.
+func (s *CreditInsuranceService) FileClaim(ctx context.Context, userID, creditID int64, req *models.CreateCreditInsuranceClaimRequest) (*models.CreditInsuranceClaim, error) {
+	switch req.Event {
+	case models.CreditInsuranceClaimEventJobLoss, models.CreditInsuranceClaimEventIllness, models.CreditInsuranceClaimEventDisability:
+	default:
+		return nil, errors.New("event must be job_loss, illness or disability")
+	}
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		return nil, errors.New("description is required")
+	}
+
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+	if !credit.IsRepaying() {
+		return nil, errors.New("credit is not being repaid")
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insurance, err := s.repo.GetByCreditIDForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &models.CreditInsuranceClaim{
+		InsuranceID: insurance.ID,
+		CreditID:    creditID,
+		UserID:      userID,
+		Event:       req.Event,
+		Description: description,
+		Status:      models.CreditInsuranceClaimStatusPending,
+	}
+	if err := s.repo.CreateClaimTx(ctx, tx, claim); err != nil {
+		return nil, err
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(userID, models.AuditActionCreditInsuranceClaimFiled, claim)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return claim, nil
+}
+
+// ApproveClaim approves a pending claim and pauses the schedule of the credit:
+// no installment becomes overdue or accrues penalties until the claim is closed
+func (s *CreditInsuranceService) ApproveClaim(ctx context.Context, adminID, claimID int64, comment string, now time.Time) (*models.CreditInsuranceClaim, error) {
+	return s.review(ctx, adminID, claimID, comment, now, models.CreditInsuranceClaimStatusPending, models.CreditInsuranceClaimStatusApproved)
+}
+
+// RejectClaim closes a pending claim without pausing the schedule
+func (s *CreditInsuranceService) RejectClaim(ctx context.Context, adminID, claimID int64, comment string, now time.Time) (*models.CreditInsuranceClaim, error) {
+	return s.review(ctx, adminID, claimID, comment, now, models.CreditInsuranceClaimStatusPending, models.CreditInsuranceClaimStatusRejected)
+}
+
+// CloseClaim ends an approved claim once the insured event is over and resumes
+// the schedule. The pending installments move forward by the months the
+// schedule was paused, started months counting in full, and the overdue ones
+// accrue no penalty for the days of the pause.
+func (s *CreditInsuranceService) CloseClaim(ctx context.Context, adminID, claimID int64, comment string, now time.Time) (*models.CreditInsuranceClaim, error) {
+	return s.review(ctx, adminID, claimID, comment, now, models.CreditInsuranceClaimStatusApproved, models.CreditInsuranceClaimStatusClosed)
+}
+
+func (s *CreditInsuranceService) review(ctx context.Context, adminID, claimID int64, comment string, now time.Time, from, to models.CreditInsuranceClaimStatus) (*models.CreditInsuranceClaim, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claim, err := s.repo.GetClaimForUpdate(ctx, tx, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if claim.Status != from {
+		return nil, fmt.Errorf("insurance claim is not %s", from)
+	}
+	insurance, err := s.repo.GetByCreditIDForUpdate(ctx, tx, claim.CreditID)
+	if err != nil {
+		return nil, err
+	}
+
+	claim.Status = to
+	claim.ReviewComment = comment
+	action := models.AuditActionCreditInsuranceClaimRejected
+	switch to {
+	case models.CreditInsuranceClaimStatusApproved:
+		action = models.AuditActionCreditInsuranceClaimApproved
+		claim.PausedAt = &now
+		insurance.Status = models.CreditInsuranceStatusClaim
+	case models.CreditInsuranceClaimStatusClosed:
+		action = models.AuditActionCreditInsuranceClaimClosed
+		months := pausedMonths(*claim.PausedAt, now)
+		claim.ResumedAt = &now
+		claim.PausedMonths = &months
+		insurance.Status = models.CreditInsuranceStatusActive
+		if err := s.resumeScheduleTx(ctx, tx, claim.CreditID, months, now); err != nil {
+			return nil, err
+		}
+	}
+	if to != models.CreditInsuranceClaimStatusClosed {
+		claim.ReviewedBy = &adminID
+		claim.ReviewedAt = &now
+	}
+
+	if err := s.repo.UpdateClaimTx(ctx, tx, claim); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if to != models.CreditInsuranceClaimStatusRejected {
+		if err := s.repo.UpdateStatusTx(ctx, tx, insurance); err != nil {
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, action, claim)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyBorrowerTx(ctx, tx, claim); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return claim, nil
+}
+
+// resumeScheduleTx moves the pending installments of a credit past the pause and
+// skips the penalty of the paused days on the overdue ones
+func (s *CreditInsuranceService) resumeScheduleTx(ctx context.Context, tx repository.Tx, creditID int64, months int, now time.Time) error {
+	if err := s.repo.ShiftPendingPaymentsTx(ctx, tx, creditID, months); err != nil {
+		return errors.New("internal server error")
+	}
+	yesterday := calendarDay(now, now.Location()).AddDate(0, 0, -1)
+	if err := s.repo.SkipPenaltyDaysTx(ctx, tx, creditID, yesterday); err != nil {
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// pausedMonths returns the months from the pause of a schedule to its resumption,
+// a started month counting in full
+func pausedMonths(pausedAt, resumedAt time.Time) int {
+	months := 1
+	for pausedAt.AddDate(0, months, 0).Before(resumedAt) {
+		months++
+	}
+	return months
+}
+
+// ListClaims retrieves insurance claims, optionally filtered by status
+func (s *CreditInsuranceService) ListClaims(ctx context.Context, status models.CreditInsuranceClaimStatus) ([]*models.CreditInsuranceClaim, error) {
+	claims, err := s.repo.ListClaims(ctx, status)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return claims, nil
+}
+
+func (s *CreditInsuranceService) auditEntry(actorID int64, action models.AuditAction, claim *models.CreditInsuranceClaim) *models.AuditLog {
+	raw, err := json.Marshal(claim)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to marshal audit details")
+	}
+
+	return &models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: auditEntityCreditInsuranceClaim,
+		EntityID:   claim.ID,
+		Details:    raw,
+	}
+}
+
+// notifyBorrowerTx queues the notification of the claim outcome to the borrower
+// in the review's transaction; it is sent once the review commits
+func (s *CreditInsuranceService) notifyBorrowerTx(ctx context.Context, tx repository.Tx, claim *models.CreditInsuranceClaim) error {
+	subject := "Insurance claim " + string(claim.Status)
+
+	var content string
+	switch claim.Status {
+	case models.CreditInsuranceClaimStatusApproved:
+		content = fmt.Sprintf("Your insurance claim on credit #%d has been approved. The payments of the credit are paused until the claim is closed.", claim.CreditID)
+	case models.CreditInsuranceClaimStatusClosed:
+		content = fmt.Sprintf("Your insurance claim on credit #%d has been closed. The payments of the credit resume, moved %d months later.", claim.CreditID, *claim.PausedMonths)
+	default:
+		content = fmt.Sprintf("Your insurance claim on credit #%d has been %s.", claim.CreditID, claim.Status)
+	}
+	if claim.ReviewComment != "" {
+		content += " Comment: " + claim.ReviewComment
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, claim.UserID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about insurance claim %d", claim.ID)
+		return err
+	}
+	return nil
+}
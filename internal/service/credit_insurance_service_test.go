@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestCreditInsuranceServiceFileClaim(t *testing.T) {
+	env := newTestEnv(t)
+	credits := map[int64]*models.Credit{
+		1: {ID: 1, UserID: 7, Status: string(models.CreditStatusActive)},
+		2: {ID: 2, UserID: 7, Status: string(models.CreditStatusPaid)},
+	}
+	creditStore := &mock.CreditStore{
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Credit, error) {
+			credit, ok := credits[id]
+			if !ok {
+				return nil, errors.New("credit not found")
+			}
+			return credit, nil
+		},
+	}
+	s := NewCreditInsuranceService(
+		repository.NewCreditInsuranceRepository(env.db, env.logger), creditStore,
+		repository.NewAuditRepository(env.db, env.logger), env.parameterService(), nil, env.logger,
+	)
+
+	if premium := s.Premium(context.Background(), 120000, time.Now()); premium != 120 {
+		t.Errorf("premium %.2f, want 120 at the default rate", premium)
+	}
+
+	claim := func(event models.CreditInsuranceClaimEvent, description string) *models.CreateCreditInsuranceClaimRequest {
+		return &models.CreateCreditInsuranceClaimRequest{Event: event, Description: description}
+	}
+	tests := []struct {
+		name     string
+		userID   int64
+		creditID int64
+		req      *models.CreateCreditInsuranceClaimRequest
+		wantErr  string
+	}{
+		{
+			name:     "unknown event",
+			userID:   7,
+			creditID: 1,
+			req:      claim("divorce", "Separated"),
+			wantErr:  "event must be job_loss, illness or disability",
+		},
+		{
+			name:     "no description",
+			userID:   7,
+			creditID: 1,
+			req:      claim(models.CreditInsuranceClaimEventJobLoss, "  "),
+			wantErr:  "description is required",
+		},
+		{
+			name:     "credit of another user",
+			userID:   8,
+			creditID: 1,
+			req:      claim(models.CreditInsuranceClaimEventIllness, "Hospitalised"),
+			wantErr:  "unauthorized",
+		},
+		{
+			name:     "repaid credit",
+			userID:   7,
+			creditID: 2,
+			req:      claim(models.CreditInsuranceClaimEventIllness, "Hospitalised"),
+			wantErr:  "credit is not being repaid",
+		},
+		{
+			name:     "uninsured credit",
+			userID:   7,
+			creditID: 1,
+			req:      claim(models.CreditInsuranceClaimEventJobLoss, "Laid off"),
+			wantErr:  "credit insurance not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.FileClaim(context.Background(), tt.userID, tt.creditID, tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FileClaim() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPausedMonths(t *testing.T) {
+	pausedAt := time.Date(2026, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		resumedAt time.Time
+		want      int
+	}{
+		{name: "same day", resumedAt: pausedAt.Add(time.Hour), want: 1},
+		{name: "exactly a month", resumedAt: pausedAt.AddDate(0, 1, 0), want: 1},
+		{name: "a day into the second month", resumedAt: pausedAt.AddDate(0, 1, 1), want: 2},
+		{name: "half a year", resumedAt: pausedAt.AddDate(0, 6, 0), want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pausedMonths(pausedAt, tt.resumedAt); got != tt.want {
+				t.Errorf("pausedMonths() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// CreditRateService keeps variable-rate credits at the CBR key rate plus their
+// margin. When the key rate changes, the installments of a credit that are due
+// from then on and not yet paid into are rebuilt at the new rate on the same
+// due dates, and the borrower is told the new payment.
+type CreditRateService struct {
+	repo                *repository.CreditRateRepository
+	creditRepo          repository.CreditStore
+	rateService         *RateService
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewCreditRateService creates a new CreditRateService instance
+func NewCreditRateService(
+	repo *repository.CreditRateRepository,
+	creditRepo repository.CreditStore,
+	rateService *RateService,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *CreditRateService {
+	return &CreditRateService{
+		repo:                repo,
+		creditRepo:          creditRepo,
+		rateService:         rateService,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// ApplyKeyRate records the current key rate when it has changed and reprices
+// the variable-rate credits that are not at it yet. It returns how many credits
+// were repriced.
+func (s *CreditRateService) ApplyKeyRate(ctx context.Context, now time.Time) (int, error) {
+	keyRate, err := s.rateService.GetKeyRate()
+	if err != nil {
+		return 0, err
+	}
+
+	latest, err := s.repo.LatestKeyRate(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest key rate: %w", err)
+	}
+	if latest == nil || latest.Rate != roundMoney(keyRate.Rate) {
+		if latest, err = s.repo.RecordKeyRate(ctx, roundMoney(keyRate.Rate)); err != nil {
+			return 0, fmt.Errorf("failed to record key rate: %w", err)
+		}
+		s.logger.WithField("key_rate", latest.Rate).Info("Key rate changed")
+	}
+
+	return s.RepriceCredits(ctx, latest, now)
+}
+
+// RepriceCredits moves the variable-rate credits that are not at the key rate
+// plus their margin to it. A credit that fails to be repriced is logged and
+// left for the next run.
+func (s *CreditRateService) RepriceCredits(ctx context.Context, keyRate *models.KeyRateRecord, now time.Time) (int, error) {
+	ids, err := s.repo.ListCreditsToReprice(ctx, keyRate.Rate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list credits to reprice: %w", err)
+	}
+
+	repriced := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return repriced, ctx.Err()
+		}
+		change, err := s.repriceCredit(ctx, id, keyRate, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to reprice credit %d", id)
+			continue
+		}
+		if change != nil {
+			repriced++
+		}
+	}
+	return repriced, nil
+}
+
+// repriceCredit moves one credit to the key rate plus its margin, returning nil
+// when it is already there
+func (s *CreditRateService) repriceCredit(ctx context.Context, creditID int64, keyRate *models.KeyRateRecord, now time.Time) (*models.CreditRateChange, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	credit, err := s.creditRepo.GetByIDForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.RateType != models.RateTypeVariable || credit.RateMargin == nil || !credit.IsRepaying() {
+		return nil, nil
+	}
+	rate := roundMoney(keyRate.Rate + *credit.RateMargin)
+	if rate == credit.InterestRate {
+		return nil, nil
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate of credit %d would be %.2f", credit.ID, rate)
+	}
+
+	schedule, err := s.creditRepo.GetPaymentScheduleForUpdate(ctx, tx, credit.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment schedule: %w", err)
+	}
+
+	// Installments already due or paid into stay as they are
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var future []*models.PaymentSchedule
+	var principal float64
+	for _, payment := range schedule {
+		if !strings.EqualFold(string(payment.Status), string(models.PaymentStatusPending)) ||
+			payment.PaidAmount > 0 || payment.DueDate.Before(today) {
+			continue
+		}
+		future = append(future, payment)
+		principal += unpaidPrincipal(payment)
+	}
+
+	change := &models.CreditRateChange{
+		CreditID:     credit.ID,
+		KeyRateID:    keyRate.ID,
+		KeyRate:      keyRate.Rate,
+		PreviousRate: credit.InterestRate,
+		NewRate:      rate,
+		Installments: len(future),
+	}
+	installments := models.BuildInstallments(roundMoney(principal), rate/12/100, len(future), credit.ScheduleType)
+	for i, payment := range future {
+		installments[i].AddInsurancePremium(payment.InsurancePremium)
+	}
+	if len(future) > 0 {
+		change.PreviousPayment = future[0].Amount
+		change.NewPayment = installments[0].Amount
+	}
+	if err := s.repo.CreateTx(ctx, tx, change); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	for i, payment := range future {
+		if err := s.repo.ArchivePaymentTx(ctx, tx, payment.ID, change.ID); err != nil {
+			return nil, err
+		}
+		installment := &installments[i]
+		installment.CreditID = credit.ID
+		installment.DueDate = payment.DueDate
+		installment.Status = models.PaymentStatusPending
+		if err := s.creditRepo.CreatePaymentScheduleTx(ctx, tx, installment); err != nil {
+			return nil, fmt.Errorf("failed to create payment schedule: %w", err)
+		}
+	}
+
+	credit.InterestRate = rate
+	if err := s.creditRepo.UpdateTermsTx(ctx, tx, credit); err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("The CBR key rate is now %.2f%%, so the interest rate of your credit #%d changes from %.2f%% to %.2f%%.",
+		keyRate.Rate, credit.ID, change.PreviousRate, change.NewRate)
+	if len(future) > 0 {
+		content += fmt.Sprintf(" Your payment due on %s is %.2f instead of %.2f.",
+			future[0].DueDate.Format("2006-01-02"), change.NewPayment, change.PreviousPayment)
+	}
+	if err := s.notificationService.NotifyUserTx(ctx, tx, credit.UserID, "Credit interest rate changed", content); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
+}
+
+// GetRateChanges retrieves the repricings of the user's credit, latest first
+func (s *CreditRateService) GetRateChanges(ctx context.Context, userID, creditID int64) ([]*models.CreditRateChange, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	changes, err := s.repo.GetByCreditID(ctx, creditID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return changes, nil
+}
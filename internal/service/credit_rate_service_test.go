@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestCreditRateServiceRepriceCredit(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	margin := 4.0
+	credit := &models.Credit{
+		ID: 5, UserID: 7, Amount: 100000, RemainingAmount: 60000, InterestRate: 20, RateType: models.RateTypeVariable,
+		RateMargin: &margin, TermMonths: 4, ScheduleType: models.ScheduleTypeAnnuity, Status: string(models.CreditStatusActive),
+	}
+	schedule := []*models.PaymentSchedule{
+		{ID: 1, Amount: 26000, Principal: 25000, Interest: 1000, DueDate: now.AddDate(0, -2, 0), Status: models.PaymentStatusPaid, PaidAmount: 26000},
+		{ID: 2, Amount: 26000, Principal: 25000, Interest: 1000, DueDate: now.AddDate(0, -1, 0), Status: models.PaymentStatusOverdue},
+		{ID: 3, Amount: 26000, Principal: 25000, Interest: 1000, DueDate: now.AddDate(0, 0, 5), Status: models.PaymentStatusPending},
+		{ID: 4, Amount: 25400, Principal: 25000, Interest: 400, DueDate: now.AddDate(0, 1, 5), Status: models.PaymentStatusPending},
+	}
+
+	var created []*models.PaymentSchedule
+	var updated *models.Credit
+	credits := &mock.CreditStore{
+		GetByIDForUpdateFunc: func(context.Context, repository.Tx, int64) (*models.Credit, error) {
+			copied := *credit
+			return &copied, nil
+		},
+		GetPaymentScheduleForUpdateFunc: func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error) {
+			return schedule, nil
+		},
+		CreatePaymentScheduleTxFunc: func(_ context.Context, _ repository.Tx, payment *models.PaymentSchedule) error {
+			created = append(created, payment)
+			return nil
+		},
+		UpdateTermsTxFunc: func(_ context.Context, _ repository.Tx, c *models.Credit) error {
+			updated = c
+			return nil
+		},
+	}
+	notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+		repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+	s := NewCreditRateService(repository.NewCreditRateRepository(env.db, env.logger), credits, nil, notificationService, env.logger)
+
+	change, err := s.repriceCredit(context.Background(), credit.ID, &models.KeyRateRecord{ID: 3, Rate: 18}, now)
+	if err != nil {
+		t.Fatalf("reprice credit: %v", err)
+	}
+	if change == nil || change.PreviousRate != 20 || change.NewRate != 22 || change.Installments != 2 {
+		t.Fatalf("rate change %+v, want 20%% to 22%% over 2 installments", change)
+	}
+	if updated == nil || updated.InterestRate != 22 || updated.TermMonths != 4 || updated.RemainingAmount != 60000 {
+		t.Errorf("credit updated to %+v, want only the rate changed", updated)
+	}
+
+	// Only the installments not yet due are rebuilt, on their due dates and
+	// for the principal they were repaying
+	if len(created) != 2 {
+		t.Fatalf("created %d installments, want 2", len(created))
+	}
+	var principal float64
+	for i, payment := range created {
+		if !payment.DueDate.Equal(schedule[2+i].DueDate) || payment.Status != models.PaymentStatusPending {
+			t.Errorf("installment %d due %v with status %s", i, payment.DueDate, payment.Status)
+		}
+		principal += payment.Principal
+	}
+	if math.Abs(principal-50000) > 0.01 {
+		t.Errorf("rebuilt principal %.2f, want 50000", principal)
+	}
+	if change.PreviousPayment != 26000 || change.NewPayment != created[0].Amount {
+		t.Errorf("payments %.2f -> %.2f, want 26000 -> %.2f", change.PreviousPayment, change.NewPayment, created[0].Amount)
+	}
+
+	// A credit already at the key rate plus its margin is left alone
+	credit.InterestRate = 22
+	created = nil
+	if change, err := s.repriceCredit(context.Background(), credit.ID, &models.KeyRateRecord{ID: 3, Rate: 18}, now); err != nil || change != nil {
+		t.Fatalf("reprice credit at the rate: change %+v, error %v", change, err)
+	}
+	if len(created) != 0 {
+		t.Errorf("created %d installments for a credit at the rate", len(created))
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityCreditRestructuring = "credit_restructuring"
+
+// CreditRestructuringService handles restructuring requests of struggling borrowers and
+// their review by administrators
+type CreditRestructuringService struct {
+	restructuringRepo   *repository.CreditRestructuringRepository
+	creditRepo          repository.CreditStore
+	auditRepo           *repository.AuditRepository
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewCreditRestructuringService creates a new CreditRestructuringService instance
+func NewCreditRestructuringService(
+	restructuringRepo *repository.CreditRestructuringRepository,
+	creditRepo repository.CreditStore,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *CreditRestructuringService {
+	return &CreditRestructuringService{
+		restructuringRepo:   restructuringRepo,
+		creditRepo:          creditRepo,
+		auditRepo:           auditRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// RequestRestructuring registers a pending restructuring of the user's credit. The
+// schedule is only changed once an administrator approves the request.
+func (s *CreditRestructuringService) RequestRestructuring(ctx context.Context, userID, creditID int64, req *models.CreateCreditRestructuringRequest) (*models.CreditRestructuring, error) {
+	if req.TermExtensionMonths < 0 || req.TermExtensionMonths > models.MaxTermExtensionMonths {
+		return nil, fmt.Errorf("term extension must be between 0 and %d months", models.MaxTermExtensionMonths)
+	}
+	if req.NewInterestRate != nil && (*req.NewInterestRate <= 0 || *req.NewInterestRate > 100) {
+		return nil, errors.New("new interest rate must be greater than 0 and at most 100")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+	if !strings.EqualFold(credit.Status, string(models.CreditStatusActive)) {
+		return nil, errors.New("credit is not active")
+	}
+
+	if req.NewInterestRate != nil && credit.RateType == models.RateTypeVariable {
+		return nil, errors.New("the rate of a variable-rate credit follows the key rate and cannot be restructured")
+	}
+
+	rateChanged := req.NewInterestRate != nil && *req.NewInterestRate != credit.InterestRate
+	if req.TermExtensionMonths == 0 && !rateChanged {
+		return nil, errors.New("restructuring must extend the term or change the interest rate")
+	}
+
+	restructuring := &models.CreditRestructuring{
+		CreditID:             credit.ID,
+		UserID:               userID,
+		TermExtensionMonths:  req.TermExtensionMonths,
+		Reason:               reason,
+		Status:               models.CreditRestructuringStatusPending,
+		PreviousInterestRate: credit.InterestRate,
+	}
+	if rateChanged {
+		restructuring.NewInterestRate = req.NewInterestRate
+	}
+
+	tx, err := s.restructuringRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.restructuringRepo.CreateTx(ctx, tx, restructuring); err != nil {
+		return nil, err
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(userID, models.AuditActionCreditRestructuringRequested, restructuring.ID, restructuring)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return restructuring, nil
+}
+
+// ApproveRestructuring applies a pending restructuring. The outstanding installments
+// are archived and a new schedule is built from the remaining principal, with the
+// unpaid interest and penalties of overdue installments added to it, over the
+// remaining installments plus the term extension.
+func (s *CreditRestructuringService) ApproveRestructuring(ctx context.Context, adminID, restructuringID int64, comment string, now time.Time) (*models.CreditRestructuring, error) {
+	tx, err := s.restructuringRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	restructuring, err := s.restructuringRepo.GetByIDForUpdate(ctx, tx, restructuringID)
+	if err != nil {
+		return nil, err
+	}
+	if restructuring.Status != models.CreditRestructuringStatusPending {
+		return nil, errors.New("credit restructuring is not pending")
+	}
+
+	credit, err := s.creditRepo.GetByIDForUpdate(ctx, tx, restructuring.CreditID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(credit.Status, string(models.CreditStatusActive)) {
+		return nil, errors.New("credit is not active")
+	}
+
+	schedule, err := s.creditRepo.GetPaymentScheduleForUpdate(ctx, tx, credit.ID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get payment schedule")
+		return nil, errors.New("internal server error")
+	}
+
+	var outstanding []*models.PaymentSchedule
+	var arrears float64
+	firstDueDate := now.AddDate(0, 1, 0)
+	nextFound := false
+	for _, payment := range schedule {
+		if !payment.IsOutstanding() {
+			continue
+		}
+		outstanding = append(outstanding, payment)
+		if strings.EqualFold(string(payment.Status), string(models.PaymentStatusOverdue)) {
+			arrears += math.Max(payment.AmountDue()-unpaidPrincipal(payment), 0)
+			continue
+		}
+		if !nextFound && !payment.DueDate.Before(now) {
+			firstDueDate = payment.DueDate
+			nextFound = true
+		}
+	}
+	if len(outstanding) == 0 {
+		return nil, errors.New("credit has no outstanding installments")
+	}
+
+	rate := credit.InterestRate
+	if restructuring.NewInterestRate != nil {
+		rate = *restructuring.NewInterestRate
+	}
+	principal := roundMoney(credit.RemainingAmount + arrears)
+	count := len(outstanding) + restructuring.TermExtensionMonths
+	installments := models.BuildInstallments(principal, rate/12/100, count, credit.ScheduleType)
+
+	for _, payment := range outstanding {
+		if err := s.creditRepo.ArchivePaymentTx(ctx, tx, payment.ID, restructuring.ID); err != nil {
+			s.logger.WithError(err).Error("Failed to archive payment")
+			return nil, errors.New("internal server error")
+		}
+	}
+	// The insurance of the credit keeps covering the new installments
+	premium := outstanding[len(outstanding)-1].InsurancePremium
+	for i := range installments {
+		payment := &installments[i]
+		payment.CreditID = credit.ID
+		payment.DueDate = firstDueDate.AddDate(0, i, 0)
+		payment.Status = models.PaymentStatusPending
+		payment.AddInsurancePremium(premium)
+		if err := s.creditRepo.CreatePaymentScheduleTx(ctx, tx, payment); err != nil {
+			s.logger.WithError(err).Error("Failed to create payment schedule")
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	credit.InterestRate = rate
+	credit.TermMonths += restructuring.TermExtensionMonths
+	credit.RemainingAmount = principal
+	if err := s.creditRepo.UpdateTermsTx(ctx, tx, credit); err != nil {
+		s.logger.WithError(err).Error("Failed to update credit terms")
+		return nil, errors.New("internal server error")
+	}
+
+	previousInstallments := len(outstanding)
+	newInstallments := len(installments)
+	monthlyPayment := installments[0].Amount
+	restructuring.Status = models.CreditRestructuringStatusApproved
+	restructuring.PreviousInstallments = &previousInstallments
+	restructuring.RestructuredPrincipal = &principal
+	restructuring.NewInstallments = &newInstallments
+	restructuring.NewMonthlyPayment = &monthlyPayment
+	restructuring.ReviewedBy = &adminID
+	restructuring.ReviewComment = comment
+	restructuring.ReviewedAt = &now
+
+	if err := s.restructuringRepo.UpdateReviewTx(ctx, tx, restructuring); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionCreditRestructuringApproved, restructuring.ID, restructuring)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyBorrowerTx(ctx, tx, restructuring); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return restructuring, nil
+}
+
+// RejectRestructuring closes a pending restructuring without changing the credit
+func (s *CreditRestructuringService) RejectRestructuring(ctx context.Context, adminID, restructuringID int64, comment string) (*models.CreditRestructuring, error) {
+	tx, err := s.restructuringRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	restructuring, err := s.restructuringRepo.GetByIDForUpdate(ctx, tx, restructuringID)
+	if err != nil {
+		return nil, err
+	}
+	if restructuring.Status != models.CreditRestructuringStatusPending {
+		return nil, errors.New("credit restructuring is not pending")
+	}
+
+	now := time.Now()
+	restructuring.Status = models.CreditRestructuringStatusRejected
+	restructuring.ReviewedBy = &adminID
+	restructuring.ReviewComment = comment
+	restructuring.ReviewedAt = &now
+
+	if err := s.restructuringRepo.UpdateReviewTx(ctx, tx, restructuring); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionCreditRestructuringRejected, restructuring.ID, restructuring)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyBorrowerTx(ctx, tx, restructuring); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return restructuring, nil
+}
+
+// GetCreditRestructurings retrieves the restructuring history of the user's credit
+func (s *CreditRestructuringService) GetCreditRestructurings(ctx context.Context, userID, creditID int64) ([]*models.CreditRestructuring, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	restructurings, err := s.restructuringRepo.GetByCreditID(ctx, creditID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return restructurings, nil
+}
+
+// ListRestructurings retrieves credit restructurings, optionally filtered by status
+func (s *CreditRestructuringService) ListRestructurings(ctx context.Context, status models.CreditRestructuringStatus) ([]*models.CreditRestructuring, error) {
+	restructurings, err := s.restructuringRepo.List(ctx, status)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return restructurings, nil
+}
+
+// unpaidPrincipal returns the part of an installment's principal that has not been repaid
+func unpaidPrincipal(payment *models.PaymentSchedule) float64 {
+	if payment.Principal == 0 && payment.Interest == 0 {
+		return payment.Amount - payment.PrincipalPaid()
+	}
+	return payment.Principal - payment.PrincipalPaid()
+}
+
+func (s *CreditRestructuringService) auditEntry(actorID int64, action models.AuditAction, entityID int64, details interface{}) *models.AuditLog {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to marshal audit details")
+	}
+
+	return &models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: auditEntityCreditRestructuring,
+		EntityID:   entityID,
+		Details:    raw,
+	}
+}
+
+// notifyBorrowerTx queues the notification of the review outcome to the borrower in
+// the review's transaction; it is sent once the review commits
+func (s *CreditRestructuringService) notifyBorrowerTx(ctx context.Context, tx repository.Tx, restructuring *models.CreditRestructuring) error {
+	subject := "Credit restructuring " + string(restructuring.Status)
+
+	content := fmt.Sprintf("Your request to restructure credit #%d has been %s.", restructuring.CreditID, restructuring.Status)
+	if restructuring.Status == models.CreditRestructuringStatusApproved {
+		content += fmt.Sprintf(
+			" The new schedule has %d installments of %.2f.",
+			*restructuring.NewInstallments, *restructuring.NewMonthlyPayment,
+		)
+	}
+	if restructuring.ReviewComment != "" {
+		content += " Comment: " + restructuring.ReviewComment
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, restructuring.UserID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about restructuring of credit %d", restructuring.CreditID)
+		return err
+	}
+	return nil
+}
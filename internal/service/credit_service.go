@@ -1,30 +1,59 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"errors"
 
+	"github.com/Abigotado/abi_banking/internal/cache"
+	"github.com/Abigotado/abi_banking/internal/events"
 	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
 	"github.com/Abigotado/abi_banking/internal/repository"
 	"github.com/sirupsen/logrus"
 )
 
 // CreditService handles business logic for credit operations
 type CreditService struct {
-	creditRepo *repository.CreditRepository
-	logger     *logrus.Logger
+	creditRepo   repository.CreditStore
+	accountRepo  repository.AccountStore
+	paramService *ParameterService
+	events       *events.Publisher
+	cache        cache.Cache
+	analyticsTTL time.Duration
+	logger       *logrus.Logger
 }
 
 // NewCreditService creates a new CreditService instance
-func NewCreditService(creditRepo *repository.CreditRepository, logger *logrus.Logger) *CreditService {
+func NewCreditService(
+	creditRepo repository.CreditStore,
+	accountRepo repository.AccountStore,
+	paramService *ParameterService,
+	publisher *events.Publisher,
+	logger *logrus.Logger,
+) *CreditService {
 	return &CreditService{
-		creditRepo: creditRepo,
-		logger:     logger,
+		creditRepo:   creditRepo,
+		accountRepo:  accountRepo,
+		paramService: paramService,
+		events:       publisher,
+		cache:        cache.Nop{},
+		logger:       logger,
 	}
 }
 
+// SetCache keeps the credit analytics in c for up to ttl. The entries are keyed
+// by the versions of the credits, so a payment is seen at once; installments
+// falling overdue are seen once the entry expires.
+func (s *CreditService) SetCache(c cache.Cache, ttl time.Duration) {
+	s.cache = c
+	s.analyticsTTL = ttl
+}
+
 // CreditAnalytics represents credit analytics data
 type CreditAnalytics struct {
 	TotalCredits      int            `json:"total_credits"`
@@ -35,17 +64,30 @@ type CreditAnalytics struct {
 	CreditsByStatus   map[string]int `json:"credits_by_status"`
 	NextPaymentDate   *time.Time     `json:"next_payment_date"`
 	NextPaymentAmount float64        `json:"next_payment_amount"`
+	OverduePayments   int            `json:"overdue_payments"`
+	OverdueAmount     float64        `json:"overdue_amount"` // overdue installments with their penalties
+	TotalPenalties    float64        `json:"total_penalties"`
 }
 
 // GetCreditAnalytics retrieves credit analytics for a user
-func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, error) {
+func (s *CreditService) GetCreditAnalytics(ctx context.Context, userID int64) (*CreditAnalytics, error) {
 	// Get user credits
-	credits, err := s.creditRepo.GetByUserID(userID)
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user credits")
 		return nil, err
 	}
 
+	versions := make([]int64, 0, 2*len(credits))
+	for _, credit := range credits {
+		versions = append(versions, credit.ID, credit.Version)
+	}
+	key := fmt.Sprintf("analytics:credits:%d:%s", userID, versionStamp(versions...))
+	cached := &CreditAnalytics{}
+	if s.cache.Get(ctx, key, cached) {
+		return cached, nil
+	}
+
 	// Calculate analytics
 	var totalCredits int
 	var totalAmount float64
@@ -55,6 +97,9 @@ func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, erro
 	creditsByStatus := make(map[string]int)
 	var nextPaymentDate *time.Time
 	var nextPaymentAmount float64
+	var overduePayments int
+	var overdueAmount float64
+	var totalPenalties float64
 
 	for _, credit := range credits {
 		totalCredits++
@@ -63,7 +108,7 @@ func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, erro
 		creditsByStatus[credit.Status]++
 
 		// Get payment schedule for the credit
-		schedule, err := s.creditRepo.GetPaymentSchedule(credit.ID)
+		schedule, err := s.creditRepo.GetPaymentSchedule(ctx, credit.ID)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to get payment schedule")
 			return nil, err
@@ -71,16 +116,26 @@ func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, erro
 
 		// Calculate paid and remaining amounts
 		for _, payment := range schedule {
-			if payment.Status == "PAID" {
+			if strings.EqualFold(string(payment.Status), string(models.PaymentStatusArchived)) {
+				// Replaced by a restructured installment
+				continue
+			}
+			if strings.EqualFold(string(payment.Status), string(models.PaymentStatusPaid)) {
 				totalPaid += payment.Amount
 			} else {
 				totalRemaining += payment.Amount
 			}
+			totalPenalties += payment.Penalty
+
+			if strings.EqualFold(string(payment.Status), string(models.PaymentStatusOverdue)) {
+				overduePayments++
+				overdueAmount += payment.AmountDue()
+			}
 
 			// Find the next payment
-			if payment.Status == "PENDING" && (nextPaymentDate == nil || payment.DueDate.Before(*nextPaymentDate)) {
+			if payment.IsOutstanding() && (nextPaymentDate == nil || payment.DueDate.Before(*nextPaymentDate)) {
 				nextPaymentDate = &payment.DueDate
-				nextPaymentAmount = payment.Amount
+				nextPaymentAmount = payment.AmountDue()
 			}
 		}
 	}
@@ -91,7 +146,7 @@ func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, erro
 		averageInterest = totalInterest / float64(totalCredits)
 	}
 
-	return &CreditAnalytics{
+	analytics := &CreditAnalytics{
 		TotalCredits:      totalCredits,
 		TotalAmount:       totalAmount,
 		TotalPaid:         totalPaid,
@@ -100,57 +155,62 @@ func (s *CreditService) GetCreditAnalytics(userID int64) (*CreditAnalytics, erro
 		CreditsByStatus:   creditsByStatus,
 		NextPaymentDate:   nextPaymentDate,
 		NextPaymentAmount: nextPaymentAmount,
-	}, nil
-}
-
-// CreateCredit creates a new credit
-func (s *CreditService) CreateCredit(userID int64, amount float64, termMonths int, interestRate float64) (*models.Credit, error) {
-	// Create credit record
-	credit := &models.Credit{
-		UserID:          userID,
-		Amount:          amount,
-		RemainingAmount: amount,
-		TermMonths:      termMonths,
-		InterestRate:    interestRate,
-		Status:          string(models.CreditStatusActive),
+		OverduePayments:   overduePayments,
+		OverdueAmount:     roundMoney(overdueAmount),
+		TotalPenalties:    roundMoney(totalPenalties),
 	}
+	s.cache.Set(ctx, key, analytics, s.analyticsTTL)
+	return analytics, nil
+}
 
-	// Start transaction
-	tx, err := s.creditRepo.BeginTransaction()
+// openCreditTx creates an active credit with its payment schedule and disburses the
+// principal to the linked account, all within the caller's transaction. The
+// monthly insurance premium, if any, is added to every installment.
+func (s *CreditService) openCreditTx(ctx context.Context, tx repository.Tx, credit *models.Credit, insurancePremium float64) error {
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, credit.AccountID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer tx.Rollback()
 
-	// Create credit
-	if err := s.creditRepo.Create(credit); err != nil {
-		return nil, err
+	credit.RemainingAmount = credit.Amount
+	credit.Status = string(models.CreditStatusActive)
+	if credit.ScheduleType == "" {
+		credit.ScheduleType = models.ScheduleTypeAnnuity
+	}
+	if err := s.creditRepo.CreateTx(ctx, tx, credit); err != nil {
+		s.logger.WithError(err).Error("Failed to create credit")
+		return errors.New("internal server error")
 	}
 
-	// Generate payment schedule
 	schedule, err := s.GeneratePaymentSchedule(credit)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	// Save payment schedule
 	for _, payment := range schedule {
-		if err := s.creditRepo.CreatePaymentSchedule(payment); err != nil {
-			return nil, err
+		payment.AddInsurancePremium(insurancePremium)
+		if err := s.creditRepo.CreatePaymentScheduleTx(ctx, tx, payment); err != nil {
+			s.logger.WithError(err).Error("Failed to create payment schedule")
+			return errors.New("internal server error")
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, &models.Transaction{
+		ToAccountID: account.ID,
+		Amount:      credit.Amount,
+		Type:        models.TransactionTypeCreditDisbursement,
+		Description: fmt.Sprintf("Disbursement of credit #%d", credit.ID),
+		CreatedAt:   time.Now(),
+	}); err != nil {
+		s.logger.WithError(err).Error("Failed to post transaction")
+		return errors.New("internal server error")
 	}
 
-	return credit, nil
+	return nil
 }
 
 // GetCreditByID retrieves a credit by its ID
-func (s *CreditService) GetCreditByID(creditID int64) (*models.Credit, error) {
-	credit, err := s.creditRepo.GetByID(creditID)
+func (s *CreditService) GetCreditByID(ctx context.Context, creditID int64) (*models.Credit, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get credit by ID")
 		return nil, err
@@ -158,9 +218,85 @@ func (s *CreditService) GetCreditByID(creditID int64) (*models.Credit, error) {
 	return credit, nil
 }
 
+// GetCreditWithCost retrieves a credit with its full cost disclosed. The cost
+// is that of its stored schedule, as restructurings and repricings left it,
+// with the premium of its insurance and the penalties accrued so far. The bank
+// charges no origination fee. A credit of another user is reported as not found.
+func (s *CreditService) GetCreditWithCost(ctx context.Context, userID, creditID int64) (*models.Credit, error) {
+	credit, err := s.GetCreditByID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("credit not found")
+	}
+
+	schedule, err := s.creditRepo.GetPaymentSchedule(ctx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get payment schedule")
+		return nil, errors.New("internal server error")
+	}
+	credit.Cost = models.CalculateScheduledCreditCost(credit.Amount, schedule, credit.CreatedAt)
+	return credit, nil
+}
+
+// GetPaymentSchedule retrieves the stored payment schedule of the user's credit with
+// the status, payments and penalties of each installment
+func (s *CreditService) GetPaymentSchedule(ctx context.Context, userID, creditID int64) ([]*models.PaymentSchedule, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get credit by ID")
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	schedule, err := s.creditRepo.GetPaymentSchedule(ctx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get payment schedule")
+		return nil, errors.New("internal server error")
+	}
+	return schedule, nil
+}
+
+// GetPaymentSchedules retrieves the payment schedules of several credits,
+// keyed by credit ID. The caller has checked that the credits belong to the user.
+func (s *CreditService) GetPaymentSchedules(ctx context.Context, creditIDs []int64) (map[int64][]*models.PaymentSchedule, error) {
+	if len(creditIDs) == 0 {
+		return map[int64][]*models.PaymentSchedule{}, nil
+	}
+	schedules, err := s.creditRepo.GetPaymentSchedules(ctx, creditIDs)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get payment schedules")
+		return nil, errors.New("internal server error")
+	}
+	return schedules, nil
+}
+
+// ListPaymentSchedule retrieves a page of the installments of a user's credit
+// and how many match the status filter
+func (s *CreditService) ListPaymentSchedule(ctx context.Context, userID, creditID int64, p pagination.Params) ([]*models.PaymentSchedule, int, error) {
+	credit, err := s.creditRepo.GetByID(ctx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get credit by ID")
+		return nil, 0, err
+	}
+	if credit.UserID != userID {
+		return nil, 0, errors.New("unauthorized: credit does not belong to user")
+	}
+
+	schedule, total, err := s.creditRepo.ListPaymentSchedule(ctx, creditID, p)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list payment schedule")
+		return nil, 0, errors.New("internal server error")
+	}
+	return schedule, total, nil
+}
+
 // GetCreditsByUserID retrieves all credits for a user
-func (s *CreditService) GetCreditsByUserID(userID int64) ([]*models.Credit, error) {
-	credits, err := s.creditRepo.GetByUserID(userID)
+func (s *CreditService) GetCreditsByUserID(ctx context.Context, userID int64) ([]*models.Credit, error) {
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user credits")
 		return nil, err
@@ -168,94 +304,547 @@ func (s *CreditService) GetCreditsByUserID(userID int64) ([]*models.Credit, erro
 	return credits, nil
 }
 
-// PayCredit processes a credit payment
-func (s *CreditService) PayCredit(creditID int64, req *models.PayCreditRequest) error {
-	// Get credit
-	credit, err := s.creditRepo.GetByID(creditID)
+// ListUserCredits retrieves a page of the credits of a user and how many match
+// the status filter
+func (s *CreditService) ListUserCredits(ctx context.Context, userID int64, p pagination.Params) ([]*models.Credit, int, error) {
+	credits, total, err := s.creditRepo.ListByUserID(ctx, userID, p)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get credit")
-		return err
+		s.logger.WithError(err).Error("Failed to list user credits")
+		return nil, 0, errors.New("internal server error")
+	}
+	return credits, total, nil
+}
+
+// creditUpdateAttempts bounds the retries of a credit payment that lost the
+// race for the credit to a concurrent update
+const creditUpdateAttempts = 3
+
+// PayCredit pays outstanding installments of a user's credit from its account,
+// oldest first. An amount that does not cover an installment is recorded as a
+// partial payment and the rest of the installment stays due; the next payment
+// continues where it stopped. The credit is written only if it has not changed
+// since it was read; otherwise the payment is retried on a fresh read.
+func (s *CreditService) PayCredit(ctx context.Context, userID, creditID int64, req *models.PayCreditRequest) error {
+	for attempt := 1; ; attempt++ {
+		err := s.payCredit(ctx, userID, creditID, req)
+		if !errors.Is(err, repository.ErrCreditModified) || attempt == creditUpdateAttempts {
+			return err
+		}
+		s.logger.WithField("credit_id", creditID).Warn("Credit changed during payment, retrying")
 	}
+}
 
-	// Validate payment amount
-	if req.Amount <= 0 {
+func (s *CreditService) payCredit(ctx context.Context, userID, creditID int64, req *models.PayCreditRequest) error {
+	amount := roundMoney(req.Amount)
+	if amount <= 0 {
 		return errors.New("invalid payment amount")
 	}
-	if req.Amount > credit.RemainingAmount {
-		return errors.New("payment amount exceeds remaining credit amount")
+
+	tx, err := s.creditRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Update remaining amount
-	newRemainingAmount := credit.RemainingAmount - req.Amount
-	err = s.creditRepo.UpdateRemainingAmount(creditID, newRemainingAmount)
+	credit, err := s.creditRepo.GetByIDForUpdate(ctx, tx, creditID)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to update credit remaining amount")
+		s.logger.WithError(err).Error("Failed to get credit")
 		return err
 	}
+	if credit.UserID != userID {
+		return errors.New("unauthorized: credit does not belong to user")
+	}
+	if !credit.IsRepaying() {
+		return errors.New("credit is not active")
+	}
 
-	// Update payment schedule
-	schedule, err := s.creditRepo.GetPaymentSchedule(creditID)
+	schedule, err := s.creditRepo.GetPaymentScheduleForUpdate(ctx, tx, creditID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get payment schedule")
+		return errors.New("internal server error")
+	}
+
+	var due float64
+	for _, payment := range schedule {
+		if payment.IsOutstanding() {
+			due += payment.AmountDue()
+		}
+	}
+	if due == 0 {
+		return errors.New("credit has no outstanding installments")
+	}
+	if amount > roundMoney(due) {
+		return errors.New("payment amount exceeds the amount due")
+	}
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, credit.AccountID)
+	if err != nil {
 		return err
 	}
+	if account.IsClosed() {
+		return errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return ErrAccountDormant
+	}
+	if account.AvailableBalance() < amount {
+		return errors.New("insufficient funds")
+	}
 
-	// Find and update the next pending payment
+	now := time.Now()
+	left := amount
+	outstanding := false
 	for _, payment := range schedule {
-		if payment.Status == "PENDING" {
-			if req.Amount >= payment.Amount {
-				// Full payment
-				err = s.creditRepo.UpdatePaymentStatus(payment.ID, "PAID")
-				if err != nil {
-					s.logger.WithError(err).Error("Failed to update payment status")
-					return err
-				}
-				req.Amount -= payment.Amount
-			} else {
-				// Partial payment - update the payment amount
-				err = s.creditRepo.UpdatePaymentStatus(payment.ID, "PARTIAL")
-				if err != nil {
-					s.logger.WithError(err).Error("Failed to update payment status")
-					return err
-				}
-				break
-			}
+		if !payment.IsOutstanding() {
+			continue
+		}
+		if left <= 0 {
+			outstanding = true
+			break
+		}
+
+		repaid := payment.PrincipalPaid()
+		pay := math.Min(left, payment.AmountDue())
+		payment.PaidAmount = roundMoney(payment.PaidAmount + pay)
+		left = roundMoney(left - pay)
+		if payment.AmountDue() == 0 {
+			payment.Status = models.PaymentStatusPaid
+			payment.PaidAt = &now
+		} else {
+			outstanding = true
+		}
+		payment.Remaining = payment.AmountDue()
+
+		if err := s.creditRepo.UpdatePaymentPaidTx(ctx, tx, payment); err != nil {
+			s.logger.WithError(err).Error("Failed to update payment")
+			return errors.New("internal server error")
+		}
+		credit.RemainingAmount = math.Max(roundMoney(credit.RemainingAmount-(payment.PrincipalPaid()-repaid)), 0)
+	}
+
+	if !outstanding {
+		credit.Status = string(models.CreditStatusPaid)
+		credit.RemainingAmount = 0
+	} else if credit.InCollections() && !hasOverdue(schedule) {
+		// Paying the arrears in full puts the credit back in good standing; the
+		// collection case is resolved by the next collections run
+		credit.Status = string(models.CreditStatusActive)
+	}
+	if err := s.creditRepo.UpdateTx(ctx, tx, credit); err != nil {
+		if errors.Is(err, repository.ErrCreditModified) {
+			return err
 		}
+		s.logger.WithError(err).Error("Failed to update credit")
+		return errors.New("internal server error")
+	}
+
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        amount,
+		Type:          models.TransactionTypeCreditRepayment,
+		Description:   fmt.Sprintf("Payment of credit #%d", credit.ID),
+		CreatedAt:     now,
+	}); err != nil {
+		s.logger.WithError(err).Error("Failed to post transaction")
+		return errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-// GeneratePaymentSchedule generates a payment schedule for a credit
-func (s *CreditService) GeneratePaymentSchedule(credit *models.Credit) ([]*models.PaymentSchedule, error) {
-	// Calculate monthly payment using annuity formula
+// hasOverdue reports whether any installment of the schedule is overdue and unpaid
+func hasOverdue(schedule []*models.PaymentSchedule) bool {
+	for _, payment := range schedule {
+		if payment.Status == models.PaymentStatusOverdue && payment.AmountDue() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RepayEarly repays principal ahead of schedule from the credit's account. A full
+// repayment also settles the interest accrued since the last installment and closes
+// the credit; a partial one either shortens the schedule or lowers the installments.
+// Like PayCredit it is retried when the credit changed since it was read.
+func (s *CreditService) RepayEarly(ctx context.Context, userID, creditID int64, req *models.EarlyRepaymentRequest, now time.Time) (*models.EarlyRepaymentResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := s.repayEarly(ctx, userID, creditID, req, now)
+		if !errors.Is(err, repository.ErrCreditModified) || attempt == creditUpdateAttempts {
+			return result, err
+		}
+		s.logger.WithField("credit_id", creditID).Warn("Credit changed during early repayment, retrying")
+	}
+}
+
+func (s *CreditService) repayEarly(ctx context.Context, userID, creditID int64, req *models.EarlyRepaymentRequest, now time.Time) (*models.EarlyRepaymentResult, error) {
+	if req.Amount < 0 {
+		return nil, errors.New("invalid repayment amount")
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = models.EarlyRepaymentReduceTerm
+	}
+	if mode != models.EarlyRepaymentReduceTerm && mode != models.EarlyRepaymentReducePayment {
+		return nil, errors.New("mode must be reduce_term or reduce_payment")
+	}
+
+	tx, err := s.creditRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	credit, err := s.creditRepo.GetByIDForUpdate(ctx, tx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	if credit.UserID != userID {
+		return nil, errors.New("unauthorized: credit does not belong to user")
+	}
+	if !strings.EqualFold(credit.Status, string(models.CreditStatusActive)) {
+		return nil, errors.New("credit is not active")
+	}
+
+	schedule, err := s.creditRepo.GetPaymentScheduleTx(ctx, tx, creditID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get payment schedule")
+		return nil, errors.New("internal server error")
+	}
+
+	var paid, pending []*models.PaymentSchedule
+	for _, payment := range schedule {
+		if strings.EqualFold(string(payment.Status), string(models.PaymentStatusPaid)) {
+			paid = append(paid, payment)
+			continue
+		}
+		if strings.EqualFold(string(payment.Status), string(models.PaymentStatusArchived)) {
+			continue
+		}
+		if payment.DueDate.Before(now) {
+			return nil, errors.New("overdue installments must be paid before early repayment")
+		}
+		pending = append(pending, payment)
+	}
+	if len(pending) == 0 {
+		return nil, errors.New("credit has no outstanding installments")
+	}
+
 	monthlyRate := credit.InterestRate / 12 / 100
-	monthlyPayment := credit.Amount * (monthlyRate * math.Pow(1+monthlyRate, float64(credit.TermMonths))) / (math.Pow(1+monthlyRate, float64(credit.TermMonths)) - 1)
-
-	// Generate schedule
-	var schedule []*models.PaymentSchedule
-	remainingAmount := credit.Amount
-	dueDate := time.Now().AddDate(0, 1, 0) // First payment due in 1 month
-
-	for i := 0; i < credit.TermMonths; i++ {
-		// Calculate interest for this period
-		interest := remainingAmount * monthlyRate
-		principal := monthlyPayment - interest
-
-		// Create payment entry
-		payment := &models.PaymentSchedule{
-			CreditID: credit.ID,
-			Amount:   monthlyPayment,
-			DueDate:  dueDate,
-			Status:   models.PaymentStatusPending,
+	var pendingTotal float64
+	amounts := make([]float64, len(pending))
+	for i, payment := range pending {
+		// Insurance premiums repay no principal and are kept on the installments
+		amounts[i] = math.Max(payment.Amount-payment.InsurancePremium-payment.PaidAmount, 0)
+		pendingTotal += amounts[i]
+	}
+	principal := roundMoney(outstandingPrincipal(amounts, monthlyRate))
+
+	repay := roundMoney(req.Amount)
+	full := repay == 0 || repay >= principal
+	if full {
+		repay = principal
+	}
+
+	// Interest for the current period is only settled on full repayment; after a
+	// partial one the next installment still carries it on the reduced principal
+	var accrued float64
+	if full {
+		periodStart := pending[0].DueDate.AddDate(0, -1, 0)
+		if elapsed := now.Sub(periodStart); elapsed > 0 {
+			accrued = roundMoney(principal * credit.InterestRate / 100 * elapsed.Hours() / 24 / 365)
+		}
+	}
+	feePercent := s.paramService.Get(ctx, models.ParamCreditEarlyRepaymentFeePercent, now)
+	fee := roundMoney(repay * feePercent.Value / 100)
+	total := roundMoney(repay + accrued + fee)
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, credit.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to lock credit account")
+		return nil, errors.New("internal server error")
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+	if account.AvailableBalance() < total {
+		return nil, errors.New("insufficient funds")
+	}
+
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, &models.Transaction{
+		FromAccountID:     account.ID,
+		Amount:            total,
+		Type:              models.TransactionTypeCreditRepayment,
+		Description:       fmt.Sprintf("Early repayment of credit #%d", credit.ID),
+		AppliedParameters: []models.AppliedParameter{feePercent},
+		CreatedAt:         now,
+	}); err != nil {
+		s.logger.WithError(err).Error("Failed to post transaction")
+		return nil, errors.New("internal server error")
+	}
+
+	remaining := roundMoney(principal - repay)
+	var installments []models.PaymentSchedule
+	switch {
+	case full:
+		credit.Status = string(models.CreditStatusPaid)
+	case credit.ScheduleType == models.ScheduleTypeDifferentiated:
+		// Reducing the term keeps the original principal part of each installment
+		count := len(pending)
+		if mode == models.EarlyRepaymentReduceTerm {
+			part := roundMoney(credit.Amount / float64(credit.TermMonths))
+			if n := int(math.Ceil(remaining/part - 1e-9)); n < count {
+				count = n
+			}
+		}
+		installments = models.BuildInstallments(remaining, monthlyRate, count, credit.ScheduleType)
+	case mode == models.EarlyRepaymentReducePayment:
+		installments = models.BuildInstallments(remaining, monthlyRate, len(pending), credit.ScheduleType)
+	default:
+		installments = models.SplitInstallments(remaining, monthlyRate, shortenedInstallments(remaining, monthlyRate, pending[0].Amount-pending[0].InsurancePremium))
+	}
+
+	var newTotal float64
+	for i, payment := range pending {
+		if i >= len(installments) {
+			if err := s.creditRepo.DeletePaymentTx(ctx, tx, payment.ID); err != nil {
+				s.logger.WithError(err).Error("Failed to truncate payment schedule")
+				return nil, errors.New("internal server error")
+			}
+			continue
+		}
+		payment.Amount = roundMoney(installments[i].Amount + payment.InsurancePremium)
+		payment.Principal = installments[i].Principal
+		payment.Interest = installments[i].Interest
+		// A partial payment is part of the principal the new installments are built from
+		payment.PaidAmount = 0
+		payment.Remaining = payment.Amount
+		if err := s.creditRepo.UpdatePaymentAmountTx(ctx, tx, payment); err != nil {
+			s.logger.WithError(err).Error("Failed to update payment schedule")
+			return nil, errors.New("internal server error")
+		}
+		newTotal += installments[i].Amount
+	}
+
+	credit.RemainingAmount = remaining
+	if err := s.creditRepo.UpdateTx(ctx, tx, credit); err != nil {
+		if errors.Is(err, repository.ErrCreditModified) {
+			return nil, err
+		}
+		s.logger.WithError(err).Error("Failed to update credit")
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result := &models.EarlyRepaymentResult{
+		CreditID:              credit.ID,
+		PrincipalRepaid:       repay,
+		AccruedInterest:       accrued,
+		Fee:                   fee,
+		TotalCharged:          total,
+		RemainingPrincipal:    remaining,
+		InterestSaved:         math.Max(roundMoney(pendingTotal-repay-accrued-newTotal), 0),
+		RemainingInstallments: len(installments),
+		Status:                credit.Status,
+		Schedule:              append(paid, pending[:len(installments)]...),
+	}
+	if len(installments) > 0 {
+		result.MonthlyPayment = pending[0].Amount
+	}
+
+	return result, nil
+}
+
+// outstandingPrincipal discounts the remaining installments back to today, which
+// for an annuity schedule equals the principal still owed
+func outstandingPrincipal(installments []float64, monthlyRate float64) float64 {
+	var principal float64
+	discount := 1.0
+	for _, amount := range installments {
+		discount /= 1 + monthlyRate
+		principal += amount * discount
+	}
+	return principal
+}
+
+// shortenedInstallments keeps the installment amount and returns the shorter
+// schedule that repays principal, with the final installment covering the residue
+func shortenedInstallments(principal, monthlyRate, installment float64) []float64 {
+	var installments []float64
+	balance := principal
+	for balance > 0.005 {
+		due := balance * (1 + monthlyRate)
+		if due <= installment {
+			installments = append(installments, roundMoney(due))
+			break
+		}
+		installments = append(installments, installment)
+		balance = due - installment
+	}
+	return installments
+}
+
+// AccruePenalties marks installments left unpaid past their due date and the grace
+// period as overdue and accrues the daily penalty on each of them up to the end of
+// the previous day. Days within the grace period carry no penalty. Every day is
+// recorded with the rate in force on it, so a rate change applies from its
+// effective date and days missed by an earlier run are caught up.
+func (s *CreditService) AccruePenalties(ctx context.Context, now time.Time) error {
+	today := calendarDay(now, now.Location())
+	graceDays := int(s.paramService.Get(ctx, models.ParamCreditPaymentGraceDays, now).Value)
+
+	if err := s.markOverdue(ctx, today.AddDate(0, 0, -graceDays)); err != nil {
+		s.logger.WithError(err).Error("Failed to mark overdue payments")
+		return err
+	}
+
+	payments, err := s.creditRepo.GetOverduePayments(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get overdue payments")
+		return err
+	}
+
+	for _, payment := range payments {
+		if err := s.accruePenalty(ctx, payment.ID, graceDays, today); err != nil {
+			s.logger.WithError(err).Errorf("Failed to accrue penalty for payment %d", payment.ID)
+		}
+	}
+
+	return nil
+}
+
+// markOverdue marks installments due before the cutoff day as overdue and records
+// a CreditOverdue event for each of them in the same transaction
+func (s *CreditService) markOverdue(ctx context.Context, cutoff time.Time) error {
+	tx, err := s.creditRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	marked, err := s.creditRepo.MarkOverduePaymentsTx(ctx, tx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	credits := make(map[int64]*models.Credit)
+	for _, payment := range marked {
+		credit, ok := credits[payment.CreditID]
+		if !ok {
+			if credit, err = s.creditRepo.GetByID(ctx, payment.CreditID); err != nil {
+				return err
+			}
+			credits[payment.CreditID] = credit
+		}
+
+		if err := s.events.PublishTx(ctx, tx, events.TypeCreditOverdue, payment.CreditID, events.CreditOverdue{
+			CreditID:  payment.CreditID,
+			PaymentID: payment.ID,
+			UserID:    credit.UserID,
+			Amount:    payment.AmountDue(),
+			DueDate:   payment.DueDate,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if len(marked) > 0 {
+		s.logger.Infof("Marked %d installments as overdue", len(marked))
+	}
+	return nil
+}
+
+// accruePenalty accrues the penalty of an overdue installment for every full day
+// after its due date and grace period that has not been accrued yet. The penalty is
+// charged on the part of the installment still unpaid.
+func (s *CreditService) accruePenalty(ctx context.Context, paymentID int64, graceDays int, today time.Time) error {
+	tx, err := s.creditRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := s.creditRepo.GetPaymentForUpdate(ctx, tx, paymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentStatusOverdue {
+		return nil
+	}
+	overdueAmount := payment.UnpaidInstallment()
+	if overdueAmount == 0 {
+		return nil
+	}
+
+	// Dates read from the database carry no zone, their calendar day is what counts
+	day := calendarDay(payment.DueDate, today.Location()).AddDate(0, 0, graceDays+1)
+	if payment.PenaltyAccruedUntil != nil {
+		day = calendarDay(*payment.PenaltyAccruedUntil, today.Location()).AddDate(0, 0, 1)
+	}
+
+	var accruals []*models.CreditPenaltyAccrual
+	penalty := payment.Penalty
+	for ; day.Before(today); day = day.AddDate(0, 0, 1) {
+		rate := s.paramService.Get(ctx, models.ParamCreditPenaltyRatePercent, day)
+		accrual := &models.CreditPenaltyAccrual{
+			CreditID:           payment.CreditID,
+			PaymentID:          payment.ID,
+			AccrualDate:        day,
+			OverdueAmount:      overdueAmount,
+			RatePercent:        rate.Value,
+			Amount:             roundMoney(overdueAmount * rate.Value / 100 / 365),
+			ParameterVersionID: rate.VersionID,
 		}
+		accruals = append(accruals, accrual)
+		penalty += accrual.Amount
+	}
+	if len(accruals) == 0 {
+		return nil
+	}
+
+	accruedUntil := accruals[len(accruals)-1].AccrualDate
+	payment.Penalty = roundMoney(penalty)
+	payment.PenaltyAccruedUntil = &accruedUntil
+	if err := s.creditRepo.AccruePenaltyTx(ctx, tx, payment, accruals); err != nil {
+		return err
+	}
 
-		// Add to schedule
-		schedule = append(schedule, payment)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Infof("Accrued %d days of penalty on payment %d of credit %d, total penalty %.2f",
+		len(accruals), payment.ID, payment.CreditID, payment.Penalty)
+	return nil
+}
+
+// calendarDay returns midnight in loc of the calendar day of t
+func calendarDay(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// GeneratePaymentSchedule generates a payment schedule for a credit with the first
+// payment due in one month
+func (s *CreditService) GeneratePaymentSchedule(credit *models.Credit) ([]*models.PaymentSchedule, error) {
+	installments := models.GeneratePaymentSchedule(credit, time.Now().AddDate(0, 1, 0))
 
-		// Update for next period
-		remainingAmount -= principal
-		dueDate = dueDate.AddDate(0, 1, 0)
+	schedule := make([]*models.PaymentSchedule, len(installments))
+	for i := range installments {
+		schedule[i] = &installments[i]
 	}
 
 	return schedule, nil
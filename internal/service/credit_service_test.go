@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestCreditServicePayCredit(t *testing.T) {
+	now := time.Now()
+	credit := models.Credit{ID: 9, UserID: 7, AccountID: 1, Amount: 1000, RemainingAmount: 1000, Status: string(models.CreditStatusActive)}
+	schedule := []models.PaymentSchedule{
+		{ID: 1, CreditID: 9, Amount: 100, Principal: 80, Interest: 20, Status: models.PaymentStatusPending},
+		{ID: 2, CreditID: 9, Amount: 100, Principal: 85, Interest: 15, Status: models.PaymentStatusPending},
+	}
+
+	tests := []struct {
+		name          string
+		credit        models.Credit
+		balance       float64
+		account       func(*models.Account) // the state of the account once locked
+		userID        int64
+		amount        float64
+		wantErr       string
+		wantPaid      map[int64]float64
+		wantStatus    string
+		wantRemaining float64
+	}{
+		{
+			name:          "pays the earliest installment",
+			credit:        credit,
+			balance:       500,
+			userID:        7,
+			amount:        100,
+			wantPaid:      map[int64]float64{1: 100},
+			wantStatus:    string(models.CreditStatusActive),
+			wantRemaining: 920,
+		},
+		{
+			name:          "settles interest before principal on a partial payment",
+			credit:        credit,
+			balance:       500,
+			userID:        7,
+			amount:        50,
+			wantPaid:      map[int64]float64{1: 50},
+			wantStatus:    string(models.CreditStatusActive),
+			wantRemaining: 970,
+		},
+		{
+			name:          "closes the credit with the last installment",
+			credit:        credit,
+			balance:       500,
+			userID:        7,
+			amount:        200,
+			wantPaid:      map[int64]float64{1: 100, 2: 100},
+			wantStatus:    string(models.CreditStatusPaid),
+			wantRemaining: 0,
+		},
+		{
+			name:    "rejects more than is due",
+			credit:  credit,
+			balance: 500,
+			userID:  7,
+			amount:  250,
+			wantErr: "payment amount exceeds the amount due",
+		},
+		{
+			name:    "rejects insufficient funds",
+			credit:  credit,
+			balance: 60,
+			userID:  7,
+			amount:  100,
+			wantErr: "insufficient funds",
+		},
+		{
+			name:    "rejects a credit of another user",
+			credit:  credit,
+			balance: 500,
+			userID:  8,
+			amount:  100,
+			wantErr: "unauthorized: credit does not belong to user",
+		},
+		{
+			name: "rejects a closed credit",
+			credit: func() models.Credit {
+				closed := credit
+				closed.Status = string(models.CreditStatusPaid)
+				return closed
+			}(),
+			balance: 500,
+			userID:  7,
+			amount:  100,
+			wantErr: "credit is not active",
+		},
+		{
+			name:    "rejects a dormant account",
+			credit:  credit,
+			balance: 500,
+			account: func(account *models.Account) { account.DormantSince = &now },
+			userID:  7,
+			amount:  100,
+			wantErr: ErrAccountDormant.Error(),
+		},
+		{
+			name:    "rejects a closed account",
+			credit:  credit,
+			balance: 500,
+			account: func(account *models.Account) { account.ClosedAt = &now },
+			userID:  7,
+			amount:  100,
+			wantErr: "account is closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			tx := env.tx()
+			account := models.Account{ID: 1, UserID: 7, Balance: tt.balance, Currency: "RUB"}
+			book := newAccountBook(account)
+			if tt.account != nil {
+				tt.account(&account)
+				book.locked[account.ID] = account
+			}
+
+			paid := make(map[int64]float64)
+			var updated *models.Credit
+			credits := &mock.CreditStore{
+				BeginTransactionFunc: beginTx(tx),
+				GetByIDForUpdateFunc: func(context.Context, repository.Tx, int64) (*models.Credit, error) {
+					credit := tt.credit
+					return &credit, nil
+				},
+				GetPaymentScheduleForUpdateFunc: func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error) {
+					payments := make([]*models.PaymentSchedule, len(schedule))
+					for i := range schedule {
+						payment := schedule[i]
+						payments[i] = &payment
+					}
+					return payments, nil
+				},
+				UpdatePaymentPaidTxFunc: func(_ context.Context, _ repository.Tx, payment *models.PaymentSchedule) error {
+					paid[payment.ID] = payment.PaidAmount
+					return nil
+				},
+				UpdateTxFunc: func(_ context.Context, _ repository.Tx, credit *models.Credit) error {
+					updated = credit
+					return nil
+				},
+			}
+			s := NewCreditService(credits, book.store(tx), env.parameterService(), env.publisher(t), env.logger)
+
+			err := s.PayCredit(context.Background(), tt.userID, tt.credit.ID, &models.PayCreditRequest{Amount: tt.amount})
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("PayCredit() error = %v, want %q", err, tt.wantErr)
+				}
+				if tx.Committed || len(paid) != 0 || len(book.balances) != 0 {
+					t.Errorf("refused payment changed state: committed %v, payments %v, balances %v", tx.Committed, paid, book.balances)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PayCredit() error = %v", err)
+			}
+			if !tx.Committed {
+				t.Error("transaction was not committed")
+			}
+			if len(paid) != len(tt.wantPaid) {
+				t.Errorf("paid installments = %v, want %v", paid, tt.wantPaid)
+			}
+			for id, want := range tt.wantPaid {
+				if paid[id] != want {
+					t.Errorf("paid amount of installment %d = %v, want %v", id, paid[id], want)
+				}
+			}
+			if updated == nil || updated.Status != tt.wantStatus || updated.RemainingAmount != tt.wantRemaining {
+				t.Errorf("credit = %+v, want status %s and remaining %v", updated, tt.wantStatus, tt.wantRemaining)
+			}
+			if got := book.balances[1]; got != tt.balance-tt.amount {
+				t.Errorf("balance = %v, want %v", got, tt.balance-tt.amount)
+			}
+		})
+	}
+}
+
+func TestCreditServicePayCreditRetriesConcurrentUpdate(t *testing.T) {
+	// The first update loses the race: an installment was paid meanwhile, so
+	// the retry must start from the fresh schedule and remaining amount
+	reads := []struct {
+		credit   models.Credit
+		schedule []models.PaymentSchedule
+	}{
+		{
+			credit: models.Credit{ID: 9, UserID: 7, AccountID: 1, RemainingAmount: 165, Status: string(models.CreditStatusActive), Version: 1},
+			schedule: []models.PaymentSchedule{
+				{ID: 1, CreditID: 9, Amount: 100, Principal: 80, Interest: 20, Status: models.PaymentStatusPending},
+				{ID: 2, CreditID: 9, Amount: 100, Principal: 85, Interest: 15, Status: models.PaymentStatusPending},
+			},
+		},
+		{
+			credit: models.Credit{ID: 9, UserID: 7, AccountID: 1, RemainingAmount: 85, Status: string(models.CreditStatusActive), Version: 2},
+			schedule: []models.PaymentSchedule{
+				{ID: 1, CreditID: 9, Amount: 100, Principal: 80, Interest: 20, PaidAmount: 100, Status: models.PaymentStatusPaid},
+				{ID: 2, CreditID: 9, Amount: 100, Principal: 85, Interest: 15, Status: models.PaymentStatusPending},
+			},
+		},
+	}
+
+	env := newTestEnv(t)
+	var txs []*mock.Tx
+	book := newAccountBook(models.Account{ID: 1, UserID: 7, Balance: 500, Currency: "RUB"})
+	accounts := book.store(nil)
+	read := 0
+	paid := make(map[int64]float64)
+	var updates []models.Credit
+	credits := &mock.CreditStore{
+		BeginTransactionFunc: func(context.Context) (repository.Tx, error) {
+			txs = append(txs, env.tx())
+			return txs[len(txs)-1], nil
+		},
+		GetByIDForUpdateFunc: func(context.Context, repository.Tx, int64) (*models.Credit, error) {
+			credit := reads[read].credit
+			return &credit, nil
+		},
+		GetPaymentScheduleForUpdateFunc: func(context.Context, repository.Tx, int64) ([]*models.PaymentSchedule, error) {
+			payments := make([]*models.PaymentSchedule, len(reads[read].schedule))
+			for i := range reads[read].schedule {
+				payment := reads[read].schedule[i]
+				payments[i] = &payment
+			}
+			return payments, nil
+		},
+		UpdatePaymentPaidTxFunc: func(_ context.Context, _ repository.Tx, payment *models.PaymentSchedule) error {
+			paid[payment.ID] = payment.PaidAmount
+			return nil
+		},
+		UpdateTxFunc: func(_ context.Context, _ repository.Tx, credit *models.Credit) error {
+			updates = append(updates, *credit)
+			if credit.Version != reads[len(reads)-1].credit.Version {
+				read++
+				return repository.ErrCreditModified
+			}
+			return nil
+		},
+	}
+	s := NewCreditService(credits, accounts, env.parameterService(), env.publisher(t), env.logger)
+
+	if err := s.PayCredit(context.Background(), 7, 9, &models.PayCreditRequest{Amount: 100}); err != nil {
+		t.Fatalf("PayCredit() error = %v", err)
+	}
+	if len(txs) != 2 || txs[0].Committed || !txs[1].Committed {
+		t.Fatalf("transactions = %d, want the first rolled back and the retry committed", len(txs))
+	}
+	if len(updates) != 2 || updates[1].Status != string(models.CreditStatusPaid) || updates[1].RemainingAmount != 0 {
+		t.Errorf("credit updates = %+v, want a retry paying the credit off", updates)
+	}
+	if paid[2] != 100 {
+		t.Errorf("paid amount of installment 2 = %v, want 100", paid[2])
+	}
+	if len(book.transactions) != 1 || book.balance(1) != 400 {
+		t.Errorf("account debited %d times to %v, want once to 400", len(book.transactions), book.balance(1))
+	}
+}
+
+func TestCreditServiceAccruePenalties(t *testing.T) {
+	now := time.Date(2026, 3, 20, 10, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	accruedUntil := day(17)
+
+	tests := []struct {
+		name             string
+		payment          models.PaymentSchedule
+		wantDays         int
+		wantOverdue      float64
+		wantPenalty      float64
+		wantAccruedUntil time.Time
+	}{
+		{
+			name:             "accrues on the unpaid remainder after the grace period",
+			payment:          models.PaymentSchedule{DueDate: day(10), Amount: 1000, PaidAmount: 400},
+			wantDays:         6,
+			wantOverdue:      600,
+			wantPenalty:      3.6,
+			wantAccruedUntil: day(19),
+		},
+		{
+			name:    "charges nothing within the grace period",
+			payment: models.PaymentSchedule{DueDate: day(17), Amount: 1000},
+		},
+		{
+			name:             "continues after the last accrued day",
+			payment:          models.PaymentSchedule{DueDate: day(10), Amount: 1000, Penalty: 1, PenaltyAccruedUntil: &accruedUntil},
+			wantDays:         2,
+			wantOverdue:      1000,
+			wantPenalty:      3,
+			wantAccruedUntil: day(19),
+		},
+		{
+			name:    "stops once only the penalty is unpaid",
+			payment: models.PaymentSchedule{DueDate: day(10), Amount: 1000, PaidAmount: 1000, Penalty: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			// 36.5% a year is 0.1% of the overdue amount a day
+			env.cfg.Credit.PenaltyRatePercent = 36.5
+
+			payment := tt.payment
+			payment.ID, payment.CreditID, payment.Status = 1, 9, models.PaymentStatusOverdue
+
+			var cutoff time.Time
+			var accrued *models.PaymentSchedule
+			var accruals []*models.CreditPenaltyAccrual
+			credits := &mock.CreditStore{
+				BeginTransactionFunc: func(context.Context) (repository.Tx, error) { return env.tx(), nil },
+				MarkOverduePaymentsTxFunc: func(_ context.Context, _ repository.Tx, before time.Time) ([]*models.PaymentSchedule, error) {
+					cutoff = before
+					return nil, nil
+				},
+				GetOverduePaymentsFunc: func(context.Context) ([]*models.PaymentSchedule, error) {
+					return []*models.PaymentSchedule{{ID: payment.ID}}, nil
+				},
+				GetPaymentForUpdateFunc: func(context.Context, repository.Tx, int64) (*models.PaymentSchedule, error) {
+					locked := payment
+					return &locked, nil
+				},
+				AccruePenaltyTxFunc: func(_ context.Context, _ repository.Tx, p *models.PaymentSchedule, a []*models.CreditPenaltyAccrual) error {
+					accrued, accruals = p, a
+					return nil
+				},
+			}
+			s := NewCreditService(credits, &mock.AccountStore{}, env.parameterService(), env.publisher(t), env.logger)
+
+			if err := s.AccruePenalties(context.Background(), now); err != nil {
+				t.Fatalf("AccruePenalties() error = %v", err)
+			}
+
+			if want := day(17); !cutoff.Equal(want) {
+				t.Errorf("overdue cutoff = %v, want %v", cutoff, want)
+			}
+			if len(accruals) != tt.wantDays {
+				t.Fatalf("accrued %d days, want %d", len(accruals), tt.wantDays)
+			}
+			if tt.wantDays == 0 {
+				return
+			}
+			for _, accrual := range accruals {
+				if accrual.OverdueAmount != tt.wantOverdue {
+					t.Errorf("overdue amount on %v = %v, want %v", accrual.AccrualDate, accrual.OverdueAmount, tt.wantOverdue)
+				}
+			}
+			if math.Abs(accrued.Penalty-tt.wantPenalty) > 1e-9 {
+				t.Errorf("penalty = %v, want %v", accrued.Penalty, tt.wantPenalty)
+			}
+			if !accrued.PenaltyAccruedUntil.Equal(tt.wantAccruedUntil) {
+				t.Errorf("penalty accrued until %v, want %v", accrued.PenaltyAccruedUntil, tt.wantAccruedUntil)
+			}
+		})
+	}
+}
+
+func TestCreditServiceGetCreditWithCost(t *testing.T) {
+	disbursedAt := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+	credit := models.Credit{
+		ID: 9, UserID: 7, AccountID: 1, Amount: 120000, InterestRate: 12, TermMonths: 12,
+		Status: string(models.CreditStatusActive), CreatedAt: disbursedAt,
+	}
+	original := models.GeneratePaymentSchedule(&credit, disbursedAt.AddDate(0, 1, 0))
+	restructuringID := int64(4)
+
+	// Two installments paid, the other ten archived by a restructuring that
+	// spread the rest of the principal over twenty installments with the
+	// insurance premium, the first of them overdue
+	remaining := credit.Amount - original[0].Principal - original[1].Principal
+	installments := models.BuildInstallments(remaining, credit.InterestRate/12/100, 20, credit.ScheduleType)
+	wantInterest := original[0].Interest + original[1].Interest
+	for _, installment := range installments {
+		wantInterest += installment.Interest
+	}
+	restructured := func() []*models.PaymentSchedule {
+		var schedule []*models.PaymentSchedule
+		for i, installment := range original {
+			installment := installment
+			switch {
+			case i < 2:
+				installment.Status = models.PaymentStatusPaid
+				installment.PaidAmount = installment.Amount
+			case i == 2:
+				installment.Status, installment.RestructuringID, installment.Penalty = models.PaymentStatusArchived, &restructuringID, 300
+			default:
+				installment.Status, installment.RestructuringID = models.PaymentStatusArchived, &restructuringID
+			}
+			schedule = append(schedule, &installment)
+		}
+		for i := range installments {
+			installment := installments[i]
+			installment.DueDate, installment.Status = disbursedAt.AddDate(0, 3+i, 0), models.PaymentStatusPending
+			installment.AddInsurancePremium(100)
+			if i == 0 {
+				installment.Status, installment.Penalty = models.PaymentStatusOverdue, 50
+			}
+			schedule = append(schedule, &installment)
+		}
+		return schedule
+	}()
+
+	originalCost := models.CalculateCreditCost(credit.Amount, original, models.CreditCharges{}, disbursedAt, nil)
+	tests := []struct {
+		name          string
+		schedule      []*models.PaymentSchedule
+		wantInterest  float64
+		wantInsurance float64
+		wantPenalties float64
+		wantAPR       float64 // zero for any rate above that of the original terms, raised by the insurance
+	}{
+		{
+			name: "costs the original schedule",
+			schedule: func() []*models.PaymentSchedule {
+				schedule := make([]*models.PaymentSchedule, len(original))
+				for i := range original {
+					schedule[i] = &original[i]
+				}
+				return schedule
+			}(),
+			wantInterest: originalCost.TotalInterest,
+			wantAPR:      originalCost.APR,
+		},
+		{
+			name:          "costs the installments paid and those of the restructuring",
+			schedule:      restructured,
+			wantInterest:  roundMoney(wantInterest),
+			wantInsurance: 2000,
+			wantPenalties: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			credits := &mock.CreditStore{
+				GetByIDFunc: func(context.Context, int64) (*models.Credit, error) {
+					credit := credit
+					return &credit, nil
+				},
+				GetPaymentScheduleFunc: func(context.Context, int64) ([]*models.PaymentSchedule, error) {
+					return tt.schedule, nil
+				},
+			}
+			s := NewCreditService(credits, &mock.AccountStore{}, env.parameterService(), env.publisher(t), env.logger)
+
+			got, err := s.GetCreditWithCost(context.Background(), 7, 9)
+			if err != nil {
+				t.Fatalf("GetCreditWithCost() error = %v", err)
+			}
+
+			cost := got.Cost
+			if cost.TotalInterest != tt.wantInterest || cost.Insurance != tt.wantInsurance || cost.Penalties != tt.wantPenalties {
+				t.Errorf("interest, insurance, penalties = %v, %v, %v, want %v, %v, %v",
+					cost.TotalInterest, cost.Insurance, cost.Penalties, tt.wantInterest, tt.wantInsurance, tt.wantPenalties)
+			}
+			if want := roundMoney(tt.wantInterest + tt.wantInsurance + tt.wantPenalties); cost.TotalCost != want {
+				t.Errorf("total cost = %v, want %v", cost.TotalCost, want)
+			}
+			if tt.wantAPR != 0 && cost.APR != tt.wantAPR {
+				t.Errorf("APR = %v, want %v", cost.APR, tt.wantAPR)
+			}
+			if tt.wantAPR == 0 && cost.APR <= originalCost.APR {
+				t.Errorf("APR = %v, want above %v of the original terms", cost.APR, originalCost.APR)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dashboardFailedPaymentsPeriod is how far back the dashboard lists failed payments
+	dashboardFailedPaymentsPeriod = 7 * 24 * time.Hour
+	// dashboardFailedPaymentsLimit bounds the failed payments on the dashboard
+	dashboardFailedPaymentsLimit = 50
+)
+
+// healthReporter reports the readiness and the background processes of the
+// service, implemented by alerting.HealthMonitor
+type healthReporter interface {
+	Status(ctx context.Context) *models.HealthStatus
+}
+
+// DashboardService gathers the overview of the admin dashboard
+type DashboardService struct {
+	repo           *repository.DashboardRepository
+	outboxRepo     *repository.OutboxRepository
+	reconciliation *ReconciliationService
+	health         healthReporter
+	logger         *logrus.Logger
+}
+
+// NewDashboardService creates a new DashboardService instance
+func NewDashboardService(
+	repo *repository.DashboardRepository,
+	outboxRepo *repository.OutboxRepository,
+	reconciliation *ReconciliationService,
+	health healthReporter,
+	logger *logrus.Logger,
+) *DashboardService {
+	return &DashboardService{
+		repo:           repo,
+		outboxRepo:     outboxRepo,
+		reconciliation: reconciliation,
+		health:         health,
+		logger:         logger,
+	}
+}
+
+// Overview reports the health of the service, the payments that failed in the
+// last week, the messages waiting in the outbox and the latest reconciliation
+// run with its issues
+func (s *DashboardService) Overview(ctx context.Context) (*models.AdminDashboard, error) {
+	now := time.Now()
+	dashboard := &models.AdminDashboard{
+		Health:      s.health.Status(ctx),
+		GeneratedAt: now,
+	}
+
+	var err error
+	dashboard.FailedPayments, err = s.repo.ListFailedPayments(ctx, now.Add(-dashboardFailedPaymentsPeriod), dashboardFailedPaymentsLimit)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if dashboard.NotificationQueue, err = s.outboxRepo.QueueDepth(ctx); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	dashboard.Reconciliation, err = s.reconciliation.GetReport(ctx, time.Time{})
+	if err != nil && err.Error() != "reconciliation run not found" {
+		return nil, err
+	}
+	return dashboard, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+type testHealth struct {
+	status *models.HealthStatus
+}
+
+func (f testHealth) Status(context.Context) *models.HealthStatus {
+	return f.status
+}
+
+func TestDashboardServiceOverview(t *testing.T) {
+	tests := []struct {
+		name   string
+		health *models.HealthStatus
+	}{
+		{
+			name:   "healthy",
+			health: &models.HealthStatus{Ready: true, Heartbeats: []*models.HeartbeatStatus{{Name: "outbox_relay", LastBeat: time.Now()}}},
+		},
+		{
+			name:   "database unreachable",
+			health: &models.HealthStatus{DatabaseError: "database is unreachable", Heartbeats: []*models.HeartbeatStatus{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			s := NewDashboardService(
+				repository.NewDashboardRepository(env.db, env.logger), repository.NewOutboxRepository(env.db, env.logger),
+				NewReconciliationService(repository.NewReconciliationRepository(env.db, env.logger), 2, env.logger),
+				testHealth{tt.health}, env.logger,
+			)
+
+			dashboard, err := s.Overview(context.Background())
+			if err != nil {
+				t.Fatalf("Overview() error = %v", err)
+			}
+			if dashboard.Health != tt.health {
+				t.Errorf("health = %+v, want %+v", dashboard.Health, tt.health)
+			}
+			if dashboard.FailedPayments == nil || len(dashboard.FailedPayments) != 0 {
+				t.Errorf("failed payments = %v, want an empty list", dashboard.FailedPayments)
+			}
+			if dashboard.NotificationQueue == nil || len(dashboard.NotificationQueue) != 0 {
+				t.Errorf("notification queue = %v, want an empty list", dashboard.NotificationQueue)
+			}
+			if dashboard.Reconciliation != nil {
+				t.Errorf("reconciliation = %+v before the first run", dashboard.Reconciliation)
+			}
+		})
+	}
+}
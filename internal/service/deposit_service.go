@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// daysInYear is the day count convention of deposit interest
+const daysInYear = 365
+
+// DepositService opens term deposits from accounts, accrues their interest and
+// pays them out. The amount leaves the account when the deposit is opened and
+// comes back to it with the interest at maturity. A deposit withdrawn early
+// earns the early withdrawal rate instead of its own.
+type DepositService struct {
+	repo                *repository.DepositRepository
+	accountRepo         repository.AccountStore
+	paramService        *ParameterService
+	rateService         *RateService
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewDepositService creates a new DepositService instance
+func NewDepositService(
+	repo *repository.DepositRepository,
+	accountRepo repository.AccountStore,
+	paramService *ParameterService,
+	rateService *RateService,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *DepositService {
+	return &DepositService{
+		repo:                repo,
+		accountRepo:         accountRepo,
+		paramService:        paramService,
+		rateService:         rateService,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// OpenDeposit opens a term deposit from an account the user may operate. The
+// rate is the bank's rate of term deposits or, for a key-rate deposit, the CBR
+// key rate plus the bank's margin; either stays for the whole term.
+func (s *DepositService) OpenDeposit(ctx context.Context, userID int64, req *models.CreateDepositRequest) (*models.Deposit, error) {
+	now := time.Now()
+	rateType := req.RateType
+	if rateType == "" {
+		rateType = models.DepositRateFixed
+	}
+	if rateType != models.DepositRateFixed && rateType != models.DepositRateKeyRate {
+		return nil, errors.New("rate type must be fixed or key_rate")
+	}
+	if req.TermMonths < 1 || req.TermMonths > 60 {
+		return nil, errors.New("term must be between 1 and 60 months")
+	}
+	if minAmount := s.paramService.Get(ctx, models.ParamDepositMinAmount, now).Value; req.Amount < minAmount {
+		return nil, fmt.Errorf("amount must be at least %.2f", minAmount)
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	deposit := &models.Deposit{
+		UserID:     userID,
+		AccountID:  account.ID,
+		Amount:     roundMoney(req.Amount),
+		Currency:   account.Currency,
+		TermMonths: req.TermMonths,
+		RateType:   rateType,
+		Status:     models.DepositStatusActive,
+		OpenedAt:   now,
+		MaturesAt:  now.AddDate(0, req.TermMonths, 0),
+	}
+	deposit.InterestAccruedAt = calendarDay(now, now.Location())
+
+	deposit.InterestRate = s.paramService.Get(ctx, models.ParamDepositInterestRatePercent, now).Value
+	if rateType == models.DepositRateKeyRate {
+		if !strings.EqualFold(account.Currency, "RUB") {
+			return nil, errors.New("key rate deposits are only available in RUB")
+		}
+		keyRate, err := s.rateService.GetKeyRate()
+		if err != nil {
+			return nil, errors.New("key rate deposits are unavailable while the key rate is unknown")
+		}
+		margin := s.paramService.Get(ctx, models.ParamDepositKeyRateMarginPercent, now).Value
+		deposit.KeyRate = &keyRate.Rate
+		deposit.InterestRate = max(keyRate.Rate+margin, 0)
+	}
+	deposit.InterestRate = roundMoney(deposit.InterestRate)
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, account.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", req.AccountID, err)
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+	// A deposit is funded from the balance, never from the overdraft
+	if account.Balance-account.HeldAmount < deposit.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+
+	transaction := &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        deposit.Amount,
+		Type:          models.TransactionTypeDepositOpening,
+		Description:   fmt.Sprintf("Term deposit for %d months at %.2f%%", deposit.TermMonths, deposit.InterestRate),
+		CreatedAt:     now,
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		s.logger.WithError(err).Error("Failed to post deposit opening")
+		return nil, errors.New("internal server error")
+	}
+	deposit.OpeningTransactionID = transaction.ID
+
+	if err := s.repo.CreateTx(ctx, tx, deposit); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, userID, "Term deposit opened",
+		fmt.Sprintf("Your term deposit #%d of %.2f %s at %.2f%% is open. It matures on %s.",
+			deposit.ID, deposit.Amount, deposit.Currency, deposit.InterestRate, deposit.MaturesAt.Format("2006-01-02")),
+	); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deposit, nil
+}
+
+// GetDeposit retrieves a deposit of the user
+func (s *DepositService) GetDeposit(ctx context.Context, userID, id int64) (*models.Deposit, error) {
+	deposit, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deposit.UserID != userID {
+		return nil, errors.New("unauthorized: deposit does not belong to user")
+	}
+	return deposit, nil
+}
+
+// ListDeposits retrieves a page of the deposits of the user and how many there are
+func (s *DepositService) ListDeposits(ctx context.Context, userID int64, p pagination.Params) ([]*models.Deposit, int, error) {
+	deposits, total, err := s.repo.ListByUser(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return deposits, total, nil
+}
+
+// HasActive reports whether the user has a deposit that has not been paid out
+func (s *DepositService) HasActive(ctx context.Context, userID int64) (bool, error) {
+	active, err := s.repo.HasActive(ctx, userID)
+	if err != nil {
+		return false, errors.New("internal server error")
+	}
+	return active, nil
+}
+
+// HasActiveOnAccount reports whether a deposit that has not been paid out is to
+// be paid out to the account, as seen within the transaction
+func (s *DepositService) HasActiveOnAccount(ctx context.Context, tx repository.Tx, accountID int64) (bool, error) {
+	active, err := s.repo.HasActiveOnAccountTx(ctx, tx, accountID)
+	if err != nil {
+		return false, errors.New("internal server error")
+	}
+	return active, nil
+}
+
+// WithdrawDeposit pays a deposit out before it matures. The interest is
+// recalculated at the early withdrawal rate in force when the deposit was
+// opened, and the interest accrued above it is forfeited.
+func (s *DepositService) WithdrawDeposit(ctx context.Context, userID, id int64) (*models.Deposit, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deposit, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deposit.UserID != userID {
+		return nil, errors.New("unauthorized: deposit does not belong to user")
+	}
+	if deposit.Status != models.DepositStatusActive {
+		return nil, fmt.Errorf("deposit is already %s", deposit.Status)
+	}
+
+	now := time.Now()
+	if !now.Before(deposit.MaturesAt) {
+		// The scheduler has not got to it yet; it is paid out in full
+		if err := s.payOut(ctx, tx, deposit, now); err != nil {
+			return nil, err
+		}
+	} else {
+		earlyRate := s.paramService.Get(ctx, models.ParamDepositEarlyWithdrawalRate, deposit.OpenedAt).Value
+		accrueDeposit(deposit, now)
+		interest := min(depositInterest(deposit.Amount, earlyRate, depositDays(deposit, deposit.InterestAccruedAt)), deposit.AccruedInterest)
+		deposit.Penalty = roundMoney(deposit.AccruedInterest - interest)
+		deposit.AccruedInterest = interest
+		if err := s.settle(ctx, tx, deposit, models.DepositStatusWithdrawn, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deposit, nil
+}
+
+// ProcessDeposits accrues the interest of the active deposits up to today and
+// pays out the ones that matured. It returns how many deposits were processed;
+// a deposit that fails is logged and left for the next run.
+func (s *DepositService) ProcessDeposits(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.repo.ListToAccrue(ctx, calendarDay(now, now.Location()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list deposits to accrue: %w", err)
+	}
+
+	processed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		if err := s.processDeposit(ctx, id, now); err != nil {
+			s.logger.WithError(err).Errorf("Failed to process deposit %d", id)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *DepositService) processDeposit(ctx context.Context, id int64, now time.Time) error {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deposit, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if deposit.Status != models.DepositStatusActive {
+		return nil
+	}
+
+	if now.Before(deposit.MaturesAt) {
+		accrueDeposit(deposit, now)
+		if err := s.repo.UpdateTx(ctx, tx, deposit); err != nil {
+			return err
+		}
+	} else if err := s.payOut(ctx, tx, deposit, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// payOut pays a matured deposit out with the interest for its whole term
+func (s *DepositService) payOut(ctx context.Context, tx repository.Tx, deposit *models.Deposit, now time.Time) error {
+	accrueDeposit(deposit, deposit.MaturesAt)
+	return s.settle(ctx, tx, deposit, models.DepositStatusMatured, now)
+}
+
+// settle credits the amount and the accrued interest of a deposit back to its
+// account and closes the deposit with the given status
+func (s *DepositService) settle(ctx context.Context, tx repository.Tx, deposit *models.Deposit, status models.DepositStatus, now time.Time) error {
+	if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, deposit.AccountID); err != nil {
+		return fmt.Errorf("failed to get account %d: %w", deposit.AccountID, err)
+	}
+
+	principal := &models.Transaction{
+		ToAccountID: deposit.AccountID,
+		Amount:      deposit.Amount,
+		Type:        models.TransactionTypeDepositReturn,
+		Description: fmt.Sprintf("Return of term deposit #%d", deposit.ID),
+		CreatedAt:   now,
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, principal); err != nil {
+		return fmt.Errorf("failed to post deposit return: %w", err)
+	}
+	deposit.ReturnTransactionID = &principal.ID
+
+	if deposit.AccruedInterest > 0 {
+		interest := &models.Transaction{
+			ToAccountID: deposit.AccountID,
+			Amount:      deposit.AccruedInterest,
+			Type:        models.TransactionTypeDepositInterest,
+			Description: fmt.Sprintf("Interest on term deposit #%d", deposit.ID),
+			CreatedAt:   now,
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, interest); err != nil {
+			return fmt.Errorf("failed to post deposit interest: %w", err)
+		}
+		deposit.InterestTransactionID = &interest.ID
+	}
+
+	deposit.Status = status
+	deposit.ClosedAt = &now
+	deposit.PaidInterest = deposit.AccruedInterest
+	if err := s.repo.UpdateTx(ctx, tx, deposit); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("Your term deposit #%d matured: %.2f %s and interest of %.2f %s were paid to your account.",
+		deposit.ID, deposit.Amount, deposit.Currency, deposit.PaidInterest, deposit.Currency)
+	if status == models.DepositStatusWithdrawn {
+		content = fmt.Sprintf("Your term deposit #%d was withdrawn early: %.2f %s and interest of %.2f %s were paid to your account; "+
+			"%.2f %s of interest was forfeited.",
+			deposit.ID, deposit.Amount, deposit.Currency, deposit.PaidInterest, deposit.Currency, deposit.Penalty, deposit.Currency)
+	}
+	return s.notificationService.NotifyUserTx(ctx, tx, deposit.UserID, "Term deposit paid out", content)
+}
+
+// accrueDeposit brings the accrued interest of a deposit up to the given day,
+// never past its maturity. The interest is recalculated from the opening day
+// so that rounding does not build up.
+func accrueDeposit(deposit *models.Deposit, at time.Time) {
+	day := calendarDay(at, deposit.OpenedAt.Location())
+	if maturity := calendarDay(deposit.MaturesAt, deposit.OpenedAt.Location()); day.After(maturity) {
+		day = maturity
+	}
+	if day.Before(deposit.InterestAccruedAt) {
+		return
+	}
+	deposit.InterestAccruedAt = day
+	deposit.AccruedInterest = depositInterest(deposit.Amount, deposit.InterestRate, depositDays(deposit, day))
+}
+
+// depositDays counts the days from the opening of a deposit to the given day
+func depositDays(deposit *models.Deposit, day time.Time) int {
+	opened := deposit.OpenedAt
+	from := time.Date(opened.Year(), opened.Month(), opened.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return max(int(to.Sub(from).Hours()/24), 0)
+}
+
+// depositInterest is the simple interest on an amount at an annual rate for a
+// number of days
+func depositInterest(amount, annualRate float64, days int) float64 {
+	return roundMoney(amount * annualRate / 100 * float64(days) / daysInYear)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestAccrueDeposit(t *testing.T) {
+	opened := time.Date(2026, 1, 10, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		at           time.Time
+		wantInterest float64
+		wantDay      time.Time
+	}{
+		{name: "opening day", at: opened.Add(time.Hour), wantInterest: 0, wantDay: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{name: "after 30 days", at: time.Date(2026, 2, 9, 8, 0, 0, 0, time.UTC), wantInterest: 986.3, wantDay: time.Date(2026, 2, 9, 0, 0, 0, 0, time.UTC)},
+		{name: "capped at maturity", at: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), wantInterest: 5950.68, wantDay: time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deposit := &models.Deposit{
+				Amount:            100000,
+				InterestRate:      12,
+				OpenedAt:          opened,
+				MaturesAt:         opened.AddDate(0, 6, 0),
+				InterestAccruedAt: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC),
+			}
+			accrueDeposit(deposit, tt.at)
+			if deposit.AccruedInterest != tt.wantInterest {
+				t.Errorf("accrued interest = %v, want %v", deposit.AccruedInterest, tt.wantInterest)
+			}
+			if !deposit.InterestAccruedAt.Equal(tt.wantDay) {
+				t.Errorf("accrued up to %s, want %s", deposit.InterestAccruedAt, tt.wantDay)
+			}
+		})
+	}
+}
+
+func TestDepositInterestAtEarlyWithdrawalRate(t *testing.T) {
+	// 100 days at 12% accrue 32876.71, of which 27.40 is kept at the 0.01% early withdrawal rate
+	accrued := depositInterest(1000000, 12, 100)
+	early := depositInterest(1000000, 0.01, 100)
+	if accrued != 32876.71 || early != 27.4 {
+		t.Fatalf("accrued %v, early %v", accrued, early)
+	}
+	if penalty := roundMoney(accrued - early); penalty != 32849.31 {
+		t.Errorf("penalty = %v, want 32849.31", penalty)
+	}
+}
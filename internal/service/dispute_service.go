@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityDispute = "dispute"
+
+// DisputeService handles disputes of transactions. A user opens a dispute of a
+// debit of an account they operate; a reviewer taking it credits the amount
+// provisionally, and the outcome of the review keeps the credit or reverses it.
+type DisputeService struct {
+	repo                *repository.DisputeRepository
+	accountRepo         repository.AccountStore
+	auditRepo           *repository.AuditRepository
+	store               storage.Storage
+	urlExpiry           time.Duration
+	notificationService *NotificationService
+	cfg                 *config.DisputesConfig
+	logger              *logrus.Logger
+}
+
+// NewDisputeService creates a new DisputeService instance
+func NewDisputeService(
+	repo *repository.DisputeRepository,
+	accountRepo repository.AccountStore,
+	auditRepo *repository.AuditRepository,
+	store storage.Storage,
+	urlExpiry time.Duration,
+	notificationService *NotificationService,
+	cfg *config.DisputesConfig,
+	logger *logrus.Logger,
+) *DisputeService {
+	return &DisputeService{
+		repo:                repo,
+		accountRepo:         accountRepo,
+		auditRepo:           auditRepo,
+		store:               store,
+		urlExpiry:           urlExpiry,
+		notificationService: notificationService,
+		cfg:                 cfg,
+		logger:              logger,
+	}
+}
+
+// OpenDispute opens a dispute of a transaction debiting an account the user may
+// operate, with the files attached to it
+func (s *DisputeService) OpenDispute(ctx context.Context, userID, transactionID int64, req *models.CreateDisputeRequest) (*models.Dispute, error) {
+	if !isDisputeReason(req.Reason) {
+		return nil, fmt.Errorf("reason must be one of %v", models.DisputeReasons)
+	}
+	attachments, err := s.checkFiles(req.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.accountRepo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		if err.Error() == "transaction not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if transaction.FromAccountID == 0 || !isDisputableTransaction(transaction.Type) {
+		return nil, fmt.Errorf("%s transactions cannot be disputed", transaction.Type)
+	}
+	if time.Since(transaction.CreatedAt) > s.cfg.Window {
+		return nil, errors.New("transaction is too old to be disputed")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, transaction.FromAccountID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = transaction.Amount
+	}
+	if amount > transaction.Amount {
+		return nil, errors.New("amount cannot exceed the transaction amount")
+	}
+
+	dispute := &models.Dispute{
+		TransactionID: transaction.ID,
+		AccountID:     account.ID,
+		UserID:        userID,
+		Reason:        req.Reason,
+		Description:   strings.TrimSpace(req.Description),
+		Amount:        amount,
+		Currency:      account.Currency,
+		Status:        models.DisputeStatusOpen,
+	}
+
+	// The files are stored ahead of the dispute and removed again unless it is
+	// recorded with them
+	committed := false
+	defer func() {
+		if !committed {
+			s.deleteFiles(ctx, attachments)
+		}
+	}()
+	for i, attachment := range attachments {
+		attachment.StorageKey = fmt.Sprintf("disputes/%d/%s%s", userID, uuid.New().String(), documentContentTypes[attachment.ContentType])
+		if err := s.store.Put(ctx, attachment.StorageKey, attachment.ContentType, req.Files[i].Data); err != nil {
+			attachment.StorageKey = ""
+			s.logger.WithError(err).Errorf("Failed to store dispute attachment of user %d", userID)
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.CreateTx(ctx, tx, dispute); err != nil {
+		if err.Error() == "transaction is already disputed" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	for _, attachment := range attachments {
+		attachment.DisputeID = dispute.ID
+		if err := s.repo.CreateAttachmentTx(ctx, tx, attachment); err != nil {
+			return nil, errors.New("internal server error")
+		}
+	}
+	dispute.Attachments = attachments
+
+	if err := s.notifyTx(ctx, tx, dispute); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return dispute, nil
+}
+
+// checkFiles validates the files of a dispute and returns the attachments they
+// are recorded as, not stored yet
+func (s *DisputeService) checkFiles(files []models.DisputeFile) ([]*models.DisputeAttachment, error) {
+	if len(files) > s.cfg.MaxAttachments {
+		return nil, fmt.Errorf("a dispute can have at most %d attachments", s.cfg.MaxAttachments)
+	}
+
+	attachments := make([]*models.DisputeAttachment, 0, len(files))
+	for _, file := range files {
+		if len(file.Data) == 0 {
+			return nil, errors.New("attachment is empty")
+		}
+		if int64(len(file.Data)) > s.cfg.MaxAttachmentSize {
+			return nil, fmt.Errorf("attachment is larger than %d bytes", s.cfg.MaxAttachmentSize)
+		}
+		contentType := http.DetectContentType(file.Data)
+		ext, ok := documentContentTypes[contentType]
+		if !ok {
+			return nil, errors.New("attachment must be a JPEG or PNG image or a PDF file")
+		}
+		attachments = append(attachments, &models.DisputeAttachment{
+			FileName:    documentFileName(file.FileName, fmt.Sprintf("attachment-%d%s", len(attachments)+1, ext)),
+			ContentType: contentType,
+			Size:        int64(len(file.Data)),
+		})
+	}
+	return attachments, nil
+}
+
+// deleteFiles removes the stored files of a dispute that was not recorded
+func (s *DisputeService) deleteFiles(ctx context.Context, attachments []*models.DisputeAttachment) {
+	for _, attachment := range attachments {
+		if attachment.StorageKey == "" {
+			continue
+		}
+		if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+			s.logger.WithError(err).Warnf("Failed to delete orphaned dispute attachment %s", attachment.StorageKey)
+		}
+	}
+}
+
+func isDisputeReason(reason string) bool {
+	for _, r := range models.DisputeReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func isDisputableTransaction(transactionType string) bool {
+	for _, t := range models.DisputableTransactionTypes {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserDispute returns a dispute the user opened with its attachments
+func (s *DisputeService) GetUserDispute(ctx context.Context, userID, id int64) (*models.Dispute, error) {
+	dispute, err := s.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.UserID != userID {
+		return nil, errors.New("dispute not found")
+	}
+	return dispute, nil
+}
+
+// GetDispute returns a dispute with its attachments
+func (s *DisputeService) GetDispute(ctx context.Context, id int64) (*models.Dispute, error) {
+	dispute, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "dispute not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	dispute.Attachments, err = s.repo.ListAttachments(ctx, dispute.ID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return dispute, nil
+}
+
+// ListDisputes returns a page of the disputes a user opened, or of all disputes
+// when the user ID is zero, and how many there are
+func (s *DisputeService) ListDisputes(ctx context.Context, userID int64, p pagination.Params) ([]*models.Dispute, int, error) {
+	disputes, total, err := s.repo.List(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return disputes, total, nil
+}
+
+// AttachmentURL returns a signed URL to view a file attached to a dispute for
+// review. Handing out the URL is audited as a view of the file.
+func (s *DisputeService) AttachmentURL(ctx context.Context, adminID, disputeID, attachmentID int64) (*models.SignedURL, error) {
+	attachment, err := s.repo.GetAttachment(ctx, disputeID, attachmentID)
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	url, err := signedURL(ctx, s.store, attachment.StorageKey, s.urlExpiry)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign URL of dispute attachment %d", attachment.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"attachment_id": attachment.ID, "file_name": attachment.FileName})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionDisputeAttachmentViewed,
+		EntityType: auditEntityDispute,
+		EntityID:   disputeID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit view of dispute attachment %d", attachment.ID)
+	}
+	return url, nil
+}
+
+// TakeDispute puts an open dispute under the review of the reviewer and credits
+// the disputed amount to the account provisionally. Nobody reviews their own
+// disputes.
+func (s *DisputeService) TakeDispute(ctx context.Context, reviewerID, id int64) (*models.Dispute, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dispute, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		if err.Error() == "dispute not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if dispute.Status != models.DisputeStatusOpen {
+		return nil, errors.New("dispute is not open")
+	}
+	if dispute.UserID == reviewerID {
+		return nil, errors.New("dispute must be reviewed by someone else")
+	}
+
+	if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, dispute.AccountID); err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", dispute.AccountID, err)
+	}
+	credit := &models.Transaction{
+		ToAccountID: dispute.AccountID,
+		Amount:      dispute.Amount,
+		Type:        models.TransactionTypeDisputeCredit,
+		Description: fmt.Sprintf("Provisional credit for dispute #%d", dispute.ID),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, credit); err != nil {
+		return nil, fmt.Errorf("failed to post provisional credit: %w", err)
+	}
+
+	dispute.Status = models.DisputeStatusUnderReview
+	dispute.ReviewerID = &reviewerID
+	dispute.ProvisionalTransactionID = &credit.ID
+	if err := s.repo.UpdateReviewTx(ctx, tx, dispute); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.auditTx(ctx, tx, reviewerID, dispute, models.AuditActionDisputeTaken); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.notifyTx(ctx, tx, dispute); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return dispute, nil
+}
+
+// RefundDispute upholds a dispute under the review of the reviewer; the
+// provisional credit becomes final
+func (s *DisputeService) RefundDispute(ctx context.Context, reviewerID, id int64, comment string) (*models.Dispute, error) {
+	return s.closeDispute(ctx, reviewerID, id, comment, models.DisputeStatusRefunded, models.AuditActionDisputeRefunded)
+}
+
+// ResolveDispute closes a dispute under the review of the reviewer that was
+// settled otherwise, such as by a refund from the merchant, and reverses the
+// provisional credit. The comment tells the user how it was settled.
+func (s *DisputeService) ResolveDispute(ctx context.Context, reviewerID, id int64, comment string) (*models.Dispute, error) {
+	if comment == "" {
+		return nil, errors.New("comment is required to resolve a dispute")
+	}
+	return s.closeDispute(ctx, reviewerID, id, comment, models.DisputeStatusResolved, models.AuditActionDisputeResolved)
+}
+
+// DeclineDispute turns down a dispute under the review of the reviewer and
+// reverses the provisional credit. The comment tells the user why.
+func (s *DisputeService) DeclineDispute(ctx context.Context, reviewerID, id int64, comment string) (*models.Dispute, error) {
+	if comment == "" {
+		return nil, errors.New("comment is required to decline a dispute")
+	}
+	return s.closeDispute(ctx, reviewerID, id, comment, models.DisputeStatusDeclined, models.AuditActionDisputeDeclined)
+}
+
+func (s *DisputeService) closeDispute(ctx context.Context, reviewerID, id int64, comment string, status models.DisputeStatus, action models.AuditAction) (*models.Dispute, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dispute, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		if err.Error() == "dispute not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if dispute.Status != models.DisputeStatusUnderReview {
+		return nil, errors.New("dispute is not under review")
+	}
+	if dispute.ReviewerID == nil || *dispute.ReviewerID != reviewerID {
+		return nil, errors.New("dispute is under review by someone else")
+	}
+
+	// The reversal is posted whatever the balance: the amount was never the
+	// user's to spend, so it may take the account into the negative
+	if status != models.DisputeStatusRefunded && dispute.ProvisionalTransactionID != nil {
+		if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, dispute.AccountID); err != nil {
+			return nil, fmt.Errorf("failed to get account %d: %w", dispute.AccountID, err)
+		}
+		reversal := &models.Transaction{
+			FromAccountID: dispute.AccountID,
+			Amount:        dispute.Amount,
+			Type:          models.TransactionTypeDisputeReversal,
+			Description:   fmt.Sprintf("Reversal of provisional credit for dispute #%d", dispute.ID),
+			CreatedAt:     time.Now(),
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, reversal); err != nil {
+			return nil, fmt.Errorf("failed to post reversal: %w", err)
+		}
+		dispute.ReversalTransactionID = &reversal.ID
+	}
+
+	now := time.Now().UTC()
+	dispute.Status = status
+	dispute.ReviewComment = comment
+	dispute.ReviewedAt = &now
+	if err := s.repo.UpdateReviewTx(ctx, tx, dispute); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.auditTx(ctx, tx, reviewerID, dispute, action); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.notifyTx(ctx, tx, dispute); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return dispute, nil
+}
+
+func (s *DisputeService) auditTx(ctx context.Context, tx repository.Tx, reviewerID int64, dispute *models.Dispute, action models.AuditAction) error {
+	details, _ := json.Marshal(map[string]interface{}{
+		"transaction_id": dispute.TransactionID,
+		"account_id":     dispute.AccountID,
+		"amount":         dispute.Amount,
+		"currency":       dispute.Currency,
+		"comment":        dispute.ReviewComment,
+	})
+	return s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    reviewerID,
+		Action:     action,
+		EntityType: auditEntityDispute,
+		EntityID:   dispute.ID,
+		Details:    details,
+	})
+}
+
+// notifyTx queues the notification of a step of a dispute in the transaction
+// that made it
+func (s *DisputeService) notifyTx(ctx context.Context, tx repository.Tx, dispute *models.Dispute) error {
+	var subject, content string
+	switch dispute.Status {
+	case models.DisputeStatusOpen:
+		subject = "Dispute opened"
+		content = fmt.Sprintf("Your dispute #%d of %.2f %s for transaction #%d has been received and is waiting for review.",
+			dispute.ID, dispute.Amount, dispute.Currency, dispute.TransactionID)
+	case models.DisputeStatusUnderReview:
+		subject = "Dispute under review"
+		content = fmt.Sprintf("Your dispute #%d is under review. %.2f %s have been credited provisionally to account #%d until it is decided.",
+			dispute.ID, dispute.Amount, dispute.Currency, dispute.AccountID)
+	case models.DisputeStatusRefunded:
+		subject = "Dispute upheld"
+		content = fmt.Sprintf("Your dispute #%d has been upheld and the credit of %.2f %s to account #%d is final.",
+			dispute.ID, dispute.Amount, dispute.Currency, dispute.AccountID)
+	case models.DisputeStatusResolved:
+		subject = "Dispute resolved"
+		content = fmt.Sprintf("Your dispute #%d has been resolved: %s The provisional credit of %.2f %s has been reversed.",
+			dispute.ID, dispute.ReviewComment, dispute.Amount, dispute.Currency)
+	case models.DisputeStatusDeclined:
+		subject = "Dispute declined"
+		content = fmt.Sprintf("Your dispute #%d has been declined: %s The provisional credit of %.2f %s has been reversed.",
+			dispute.ID, dispute.ReviewComment, dispute.Amount, dispute.Currency)
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, dispute.UserID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about dispute %d", dispute.ID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrAccountDormant is returned when a dormant account is to be debited before
+// its owner confirmed the reactivation
+var ErrAccountDormant = errors.New("account is dormant, confirm its reactivation before debiting it")
+
+// ErrAccountNotDormant is returned when an account to reactivate is not dormant
+var ErrAccountNotDormant = errors.New("account is not dormant")
+
+// DormancyService marks accounts with no activity for account.dormancy_months
+// as dormant and charges them the monthly account.dormancy_fee. A dormant
+// account still receives money, but it is not debited on the instruction of
+// its holders until one of them reactivates it. Transactions the bank posts on
+// its own, the fee among them, are not activity.
+type DormancyService struct {
+	accountRepo         repository.AccountStore
+	paramService        *ParameterService
+	notificationService *NotificationService
+	auditRepo           *repository.AuditRepository
+	logger              *logrus.Logger
+}
+
+// NewDormancyService creates a new DormancyService instance
+func NewDormancyService(
+	accountRepo repository.AccountStore,
+	paramService *ParameterService,
+	notificationService *NotificationService,
+	auditRepo *repository.AuditRepository,
+	logger *logrus.Logger,
+) *DormancyService {
+	return &DormancyService{
+		accountRepo:         accountRepo,
+		paramService:        paramService,
+		notificationService: notificationService,
+		auditRepo:           auditRepo,
+		logger:              logger,
+	}
+}
+
+// ProcessDormancy marks the accounts inactive since the dormancy period as
+// dormant and charges the dormancy fee on the dormant accounts a month after
+// they became dormant or were last charged. It returns the number of accounts
+// marked or charged.
+func (s *DormancyService) ProcessDormancy(ctx context.Context, now time.Time) (int, error) {
+	months := int(s.paramService.Get(ctx, models.ParamAccountDormancyMonths, now).Value)
+	cutoff := now.AddDate(0, -months, 0)
+	ids, err := s.accountRepo.ListInactive(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list inactive accounts: %w", err)
+	}
+
+	fee := s.paramService.Get(ctx, models.ParamAccountDormancyFee, now)
+	processed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		marked, err := s.markDormant(ctx, id, cutoff, months, fee.Value, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to mark account %d dormant", id)
+			continue
+		}
+		if marked {
+			processed++
+		}
+	}
+
+	if fee.Value <= 0 {
+		return processed, nil
+	}
+	due := now.AddDate(0, -1, 0)
+	ids, err = s.accountRepo.ListDormancyFeeDue(ctx, due)
+	if err != nil {
+		return processed, fmt.Errorf("failed to list dormant accounts: %w", err)
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		charged, err := s.chargeFee(ctx, id, fee, due, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to charge the dormancy fee of account %d", id)
+			continue
+		}
+		if charged {
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// markDormant marks an account dormant and notifies its owner, unless it saw
+// activity since it was listed
+func (s *DormancyService) markDormant(ctx context.Context, id int64, cutoff time.Time, months int, fee float64, now time.Time) (bool, error) {
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to get account %d: %w", id, err)
+	}
+	marked, err := s.accountRepo.MarkDormantTx(ctx, tx, id, cutoff, now)
+	if err != nil || !marked {
+		return false, err
+	}
+
+	content := fmt.Sprintf("Account %s had no activity for %d months and is now dormant. "+
+		"It still receives payments, but confirm its reactivation before paying from it.", account.MaskedNumber(), months)
+	if fee > 0 {
+		content += fmt.Sprintf(" A fee of %.2f %s is charged every month while it stays dormant.", fee, account.Currency)
+	}
+	if err := s.notificationService.NotifyUserTx(ctx, tx, account.UserID, "Account is dormant", content); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// chargeFee charges the dormancy fee of an account that was last charged, or
+// became dormant, no later than due. The fee never draws on the overdraft: an
+// account with less available takes what it has and a month with nothing
+// available goes uncharged.
+func (s *DormancyService) chargeFee(ctx context.Context, id int64, fee models.AppliedParameter, due, now time.Time) (bool, error) {
+	tx, err := s.accountRepo.BeginTransaction(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to get account %d: %w", id, err)
+	}
+	// The account may have been reactivated or charged since it was listed
+	if account.IsClosed() || !account.IsDormant() {
+		return false, nil
+	}
+	lastCharged := *account.DormantSince
+	if account.DormancyFeeChargedAt != nil {
+		lastCharged = *account.DormancyFeeChargedAt
+	}
+	if lastCharged.After(due) {
+		return false, nil
+	}
+
+	amount := dormancyFee(account, fee.Value)
+	if amount > 0 {
+		transaction := &models.Transaction{
+			FromAccountID:     account.ID,
+			Amount:            amount,
+			Type:              models.TransactionTypeDormancyFee,
+			Description:       "Dormant account fee",
+			AppliedParameters: []models.AppliedParameter{fee},
+			CreatedAt:         now,
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+			return false, fmt.Errorf("failed to post dormancy fee: %w", err)
+		}
+	}
+	if err := s.accountRepo.SetDormancyFeeChargedTx(ctx, tx, account.ID, now); err != nil {
+		return false, err
+	}
+	if amount > 0 {
+		content := fmt.Sprintf("A dormant account fee of %.2f %s was charged on account %s. "+
+			"Reactivate the account to stop the fee.", amount, account.Currency, account.MaskedNumber())
+		if err := s.notificationService.NotifyUserTx(ctx, tx, account.UserID, "Dormant account fee", content); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return amount > 0, nil
+}
+
+// dormancyFee returns the part of the fee the account can pay from its balance
+// less the held amount
+func dormancyFee(account *models.Account, fee float64) float64 {
+	return roundMoney(max(min(fee, account.Balance-account.HeldAmount), 0))
+}
+
+// ReactivateAccount lifts the dormancy of an account the user may operate,
+// which confirms that it is in use and may be debited again
+func (s *DormancyService) ReactivateAccount(ctx context.Context, userID, accountID int64) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+	if !account.IsDormant() {
+		return nil, ErrAccountNotDormant
+	}
+
+	now := time.Now()
+	reactivated, err := s.accountRepo.Reactivate(ctx, account.ID, now)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	// The account may have been reactivated or closed since it was read
+	if !reactivated {
+		return nil, ErrAccountNotDormant
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"dormant_since": account.DormantSince})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    userID,
+		Action:     models.AuditActionAccountReactivated,
+		EntityType: "account",
+		EntityID:   account.ID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit account reactivation")
+	}
+
+	account.DormantSince = nil
+	account.DormancyFeeChargedAt = nil
+	account.ReactivatedAt = &now
+	account.Version++
+	return account, nil
+}
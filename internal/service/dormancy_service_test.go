@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func newTestDormancyService(env *testEnv, accounts repository.AccountStore) *DormancyService {
+	notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+		repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+	return NewDormancyService(accounts, env.parameterService(), notificationService,
+		repository.NewAuditRepository(env.db, env.logger), env.logger)
+}
+
+func TestDormancyServiceProcessDormancy(t *testing.T) {
+	now := time.Date(2026, 5, 20, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		inactive      []int64
+		active        map[int64]bool // accounts with activity since they were listed
+		wantProcessed int
+		wantMarked    []int64
+	}{
+		{
+			name:          "marks an inactive account dormant",
+			inactive:      []int64{3},
+			wantProcessed: 1,
+			wantMarked:    []int64{3},
+		},
+		{
+			name:     "leaves an account active since it was listed",
+			inactive: []int64{3},
+			active:   map[int64]bool{3: true},
+		},
+		{
+			name:          "goes on after an account fails",
+			inactive:      []int64{9, 3},
+			wantProcessed: 1,
+			wantMarked:    []int64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			book := newAccountBook(models.Account{ID: 3, UserID: 7, Balance: 50, Currency: "RUB"})
+			accounts := book.store(nil)
+			// Every account is processed in a transaction of its own
+			var txs []*mock.Tx
+			accounts.BeginTransactionFunc = func(context.Context) (repository.Tx, error) {
+				tx := env.tx()
+				txs = append(txs, tx)
+				return tx, nil
+			}
+			var cutoffs []time.Time
+			accounts.ListInactiveFunc = func(_ context.Context, cutoff time.Time) ([]int64, error) {
+				cutoffs = append(cutoffs, cutoff)
+				return tt.inactive, nil
+			}
+			var marked []int64
+			accounts.MarkDormantTxFunc = func(_ context.Context, _ repository.Tx, id int64, cutoff, dormantSince time.Time) (bool, error) {
+				cutoffs = append(cutoffs, cutoff)
+				if tt.active[id] {
+					return false, nil
+				}
+				if !dormantSince.Equal(now) {
+					t.Errorf("account %d dormant since %v, want %v", id, dormantSince, now)
+				}
+				marked = append(marked, id)
+				return true, nil
+			}
+			s := newTestDormancyService(env, accounts)
+
+			// The fee is disabled by default, so no dormant account is charged
+			processed, err := s.ProcessDormancy(context.Background(), now)
+			if err != nil {
+				t.Fatalf("ProcessDormancy() error = %v", err)
+			}
+			if processed != tt.wantProcessed {
+				t.Errorf("processed = %d, want %d", processed, tt.wantProcessed)
+			}
+			if len(marked) != len(tt.wantMarked) || (len(marked) > 0 && marked[0] != tt.wantMarked[0]) {
+				t.Errorf("marked = %v, want %v", marked, tt.wantMarked)
+			}
+			committed := 0
+			for _, tx := range txs {
+				if tx.Committed {
+					committed++
+				}
+			}
+			if len(txs) != len(tt.inactive) || committed != len(tt.wantMarked) {
+				t.Errorf("%d transactions with %d committed, want %d with %d", len(txs), committed, len(tt.inactive), len(tt.wantMarked))
+			}
+			wantCutoff := now.AddDate(-1, 0, 0)
+			for _, cutoff := range cutoffs {
+				if !cutoff.Equal(wantCutoff) {
+					t.Errorf("cutoff = %v, want %v", cutoff, wantCutoff)
+				}
+			}
+			if len(book.transactions) != 0 {
+				t.Errorf("transactions = %+v, want none", book.transactions)
+			}
+		})
+	}
+}
+
+func TestDormancyFee(t *testing.T) {
+	tests := []struct {
+		name    string
+		account models.Account
+		fee     float64
+		want    float64
+	}{
+		{"charges the whole fee", models.Account{Balance: 500}, 150, 150},
+		{"takes what is left", models.Account{Balance: 80.456}, 150, 80.46},
+		{"leaves held funds alone", models.Account{Balance: 200, HeldAmount: 120}, 150, 80},
+		{"never draws on the overdraft", models.Account{Balance: -20, OverdraftLimit: 100}, 150, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dormancyFee(&tt.account, tt.fee); got != tt.want {
+				t.Errorf("dormancyFee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDormancyServiceReactivateAccount(t *testing.T) {
+	dormantSince := time.Now().AddDate(0, -3, 0)
+	closedAt := time.Now()
+	tests := []struct {
+		name    string
+		account models.Account
+		userID  int64
+		role    models.AccountRole
+		stale   bool // reactivated or closed since it was read
+		wantErr error
+	}{
+		{
+			name:    "reactivates a dormant account of the user",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB", DormantSince: &dormantSince},
+			userID:  7,
+		},
+		{
+			name:    "lets a co-owner reactivate",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB", DormantSince: &dormantSince},
+			userID:  8,
+			role:    models.AccountRoleCoOwner,
+		},
+		{
+			name:    "rejects a viewer",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB", DormantSince: &dormantSince},
+			userID:  8,
+			role:    models.AccountRoleViewer,
+			wantErr: errors.New("unauthorized: view-only access to the account"),
+		},
+		{
+			name:    "rejects an account that is not dormant",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB"},
+			userID:  7,
+			wantErr: ErrAccountNotDormant,
+		},
+		{
+			name:    "rejects an account reactivated since it was read",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB", DormantSince: &dormantSince},
+			userID:  7,
+			stale:   true,
+			wantErr: ErrAccountNotDormant,
+		},
+		{
+			name:    "rejects a closed account",
+			account: models.Account{ID: 3, UserID: 7, Currency: "RUB", DormantSince: &dormantSince, ClosedAt: &closedAt},
+			userID:  7,
+			wantErr: errors.New("account is closed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			accounts := newAccountBook(tt.account).store(env.tx())
+			accounts.GetMemberRoleFunc = func(context.Context, int64, int64) (models.AccountRole, error) {
+				if tt.role == "" {
+					return "", errors.New("account member not found")
+				}
+				return tt.role, nil
+			}
+			var reactivated []int64
+			accounts.ReactivateFunc = func(_ context.Context, id int64, _ time.Time) (bool, error) {
+				if tt.stale {
+					return false, nil
+				}
+				reactivated = append(reactivated, id)
+				return true, nil
+			}
+			s := newTestDormancyService(env, accounts)
+
+			account, err := s.ReactivateAccount(context.Background(), tt.userID, 3)
+
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("ReactivateAccount() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == ErrAccountNotDormant && !errors.Is(err, ErrAccountNotDormant) {
+					t.Errorf("ReactivateAccount() error = %v, want ErrAccountNotDormant", err)
+				}
+				if len(reactivated) != 0 {
+					t.Errorf("refused reactivation reached the store: %v", reactivated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReactivateAccount() error = %v", err)
+			}
+			if len(reactivated) != 1 || reactivated[0] != 3 {
+				t.Errorf("reactivated = %v, want account 3", reactivated)
+			}
+			if account.IsDormant() || account.ReactivatedAt == nil {
+				t.Errorf("account = %+v, want it reactivated", account)
+			}
+		})
+	}
+}
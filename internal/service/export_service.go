@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ExportService keeps exported reports in object storage, so that large ones are
+// downloaded through a signed URL instead of the response of the request that
+// generated them
+type ExportService struct {
+	store     storage.Storage
+	urlExpiry time.Duration
+	logger    *logrus.Logger
+}
+
+// NewExportService creates a new ExportService instance
+func NewExportService(store storage.Storage, urlExpiry time.Duration, logger *logrus.Logger) *ExportService {
+	return &ExportService{
+		store:     store,
+		urlExpiry: urlExpiry,
+		logger:    logger,
+	}
+}
+
+// Publish stores an exported report under a key of its own and returns a signed
+// URL to download it under its file name
+func (s *ExportService) Publish(ctx context.Context, fileName, contentType string, data []byte) (*models.SignedURL, error) {
+	key := fmt.Sprintf("exports/%s/%s", uuid.New().String(), fileName)
+	if err := s.store.Put(ctx, key, contentType, data); err != nil {
+		s.logger.WithError(err).Errorf("Failed to store export %s", key)
+		return nil, errors.New("internal server error")
+	}
+
+	url, err := signedURL(ctx, s.store, key, s.urlExpiry)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign URL of export %s", key)
+		return nil, errors.New("internal server error")
+	}
+	return url, nil
+}
+
+// signedURL returns a signed URL of a stored object with its expiry
+func signedURL(ctx context.Context, store storage.Storage, key string, expiry time.Duration) (*models.SignedURL, error) {
+	expiresAt := time.Now().Add(expiry).UTC().Truncate(time.Second)
+	url, err := store.SignedURL(ctx, key, expiry)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SignedURL{URL: url, ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ExternalTransferService handles transfers to accounts at other banks. A transfer
+// holds its amount on the account when it is accepted; the processor then submits
+// it to the payment rail and debits the account when it settles, and a return
+// reported by the rail releases the hold.
+type ExternalTransferService struct {
+	transferRepo        *repository.ExternalTransferRepository
+	holdRepo            *repository.HoldRepository
+	accountRepo         repository.AccountStore
+	auditRepo           *repository.AuditRepository
+	limits              *LimitService
+	notificationService *NotificationService
+	webhookService      *WebhookService
+	cfg                 *config.ExternalTransfersConfig
+	logger              *logrus.Logger
+}
+
+// NewExternalTransferService creates a new ExternalTransferService instance
+func NewExternalTransferService(
+	transferRepo *repository.ExternalTransferRepository,
+	holdRepo *repository.HoldRepository,
+	accountRepo repository.AccountStore,
+	auditRepo *repository.AuditRepository,
+	limitService *LimitService,
+	notificationService *NotificationService,
+	webhookService *WebhookService,
+	cfg *config.ExternalTransfersConfig,
+	logger *logrus.Logger,
+) *ExternalTransferService {
+	return &ExternalTransferService{
+		transferRepo:        transferRepo,
+		holdRepo:            holdRepo,
+		accountRepo:         accountRepo,
+		auditRepo:           auditRepo,
+		limits:              limitService,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		cfg:                 cfg,
+		logger:              logger,
+	}
+}
+
+// CreateTransfer records a pending transfer to another bank from an account the
+// user may operate and holds the amount on the account until the transfer settles
+func (s *ExternalTransferService) CreateTransfer(ctx context.Context, userID int64, req *models.CreateExternalTransferRequest) (*models.ExternalTransfer, error) {
+	transfer := &models.ExternalTransfer{
+		UserID:          userID,
+		AccountID:       req.AccountID,
+		BeneficiaryName: strings.TrimSpace(req.BeneficiaryName),
+		IBAN:            models.NormalizeIBAN(req.IBAN),
+		AccountNumber:   strings.TrimSpace(req.AccountNumber),
+		BIC:             strings.ToUpper(strings.TrimSpace(req.BIC)),
+		Amount:          req.Amount,
+		Reference:       strings.TrimSpace(req.Reference),
+		Status:          models.ExternalTransferStatusPending,
+	}
+	if transfer.BeneficiaryName == "" {
+		return nil, errors.New("beneficiary name is required")
+	}
+	if transfer.IBAN != "" && !models.ValidIBAN(transfer.IBAN) {
+		return nil, errors.New("invalid IBAN")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+	if account.AvailableBalance() < req.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+	if err := s.limits.Check(ctx, account.UserID, models.TransactionTypeTransfer, req.Amount); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", req.AccountID, err)
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.IsDormant() {
+		return nil, ErrAccountDormant
+	}
+	if account.AvailableBalance() < req.Amount {
+		return nil, errors.New("insufficient funds")
+	}
+	if err := s.limits.CheckTx(ctx, tx, account.UserID, models.TransactionTypeTransfer, req.Amount); err != nil {
+		return nil, err
+	}
+
+	transfer.Currency = account.Currency
+	if err := s.transferRepo.CreateTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.holdRepo.CreateTx(ctx, tx, &models.Hold{
+		AccountID: account.ID,
+		Amount:    transfer.Amount,
+		Currency:  transfer.Currency,
+		Source:    models.HoldSourceExternalTransfer,
+		SourceID:  transfer.ID,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyStatusTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// GetTransfer retrieves a transfer of the user
+func (s *ExternalTransferService) GetTransfer(ctx context.Context, userID, transferID int64) (*models.ExternalTransfer, error) {
+	transfer, err := s.transferRepo.GetByID(ctx, transferID)
+	if err != nil || transfer.UserID != userID {
+		return nil, errors.New("external transfer not found")
+	}
+	return transfer, nil
+}
+
+// GetUserTransfers lists the transfers of the user
+func (s *ExternalTransferService) GetUserTransfers(ctx context.Context, userID int64) ([]*models.ExternalTransfer, error) {
+	transfers, err := s.transferRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return transfers, nil
+}
+
+// ProcessTransfers submits the pending transfers to the payment rail and settles
+// those submitted at least the settlement delay ago. It returns the number of
+// transfers moved on.
+func (s *ExternalTransferService) ProcessTransfers(ctx context.Context, now time.Time) (int, error) {
+	steps := []struct {
+		from, to models.ExternalTransferStatus
+		before   time.Time
+	}{
+		{models.ExternalTransferStatusPending, models.ExternalTransferStatusProcessing, now},
+		{models.ExternalTransferStatusProcessing, models.ExternalTransferStatusSettled, now.Add(-s.cfg.SettlementDelay)},
+	}
+
+	processed := 0
+	for _, step := range steps {
+		ids, err := s.transferRepo.GetIDsByStatus(ctx, step.from, step.before)
+		if err != nil {
+			return processed, err
+		}
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return processed, ctx.Err()
+			}
+			moved, err := s.advance(ctx, id, step.from, step.to, now)
+			if err != nil {
+				s.logger.WithError(err).Errorf("Failed to move external transfer %d to %s", id, step.to)
+				continue
+			}
+			if moved {
+				processed++
+			}
+		}
+	}
+
+	return processed, nil
+}
+
+// advance moves a transfer from one status to the next unless it has left the
+// status since it was listed
+func (s *ExternalTransferService) advance(ctx context.Context, transferID int64, from, to models.ExternalTransferStatus, now time.Time) (bool, error) {
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := s.transferRepo.GetByIDForUpdate(ctx, tx, transferID)
+	if err != nil {
+		return false, err
+	}
+	if transfer.Status != from {
+		return false, nil
+	}
+
+	transfer.Status = to
+	if to == models.ExternalTransferStatusProcessing {
+		transfer.SubmittedAt = &now
+	} else {
+		transfer.CompletedAt = &now
+		if err := s.settleTx(ctx, tx, transfer); err != nil {
+			return false, err
+		}
+	}
+	if err := s.transferRepo.UpdateStatusTx(ctx, tx, transfer); err != nil {
+		return false, err
+	}
+	if err := s.notifyStatusTx(ctx, tx, transfer); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// settleTx debits the account for a settled transfer in place of its hold. A
+// transfer accepted before holds were placed was debited then and is left as it is.
+func (s *ExternalTransferService) settleTx(ctx context.Context, tx repository.Tx, transfer *models.ExternalTransfer) error {
+	if transfer.TransactionID != nil {
+		return nil
+	}
+
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, transfer.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to get account %d: %w", transfer.AccountID, err)
+	}
+	beneficiaryAccount := transfer.IBAN
+	if beneficiaryAccount == "" {
+		beneficiaryAccount = transfer.AccountNumber
+	}
+	transaction := &models.Transaction{
+		FromAccountID: account.ID,
+		Amount:        transfer.Amount,
+		Type:          models.TransactionTypeExternalTransfer,
+		Description:   fmt.Sprintf("Transfer to %s, %s (%s)", transfer.BeneficiaryName, beneficiaryAccount, transfer.BIC),
+		CreatedAt:     time.Now(),
+	}
+	if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+		return fmt.Errorf("failed to post transfer debit: %w", err)
+	}
+	transfer.TransactionID = &transaction.ID
+
+	return s.holdRepo.CloseTx(ctx, tx, models.HoldSourceExternalTransfer, transfer.ID, models.HoldStatusSettled)
+}
+
+// ReturnTransfer records a return of a transfer reported by the payment rail and
+// releases its hold, refunding the transfers that were debited when accepted
+func (s *ExternalTransferService) ReturnTransfer(ctx context.Context, adminID, transferID int64, req *models.ReturnExternalTransferRequest) (*models.ExternalTransfer, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("return reason is required")
+	}
+
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := s.transferRepo.GetByIDForUpdate(ctx, tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != models.ExternalTransferStatusPending && transfer.Status != models.ExternalTransferStatusProcessing {
+		return nil, fmt.Errorf("a %s transfer cannot be returned", transfer.Status)
+	}
+
+	// The account is locked for the refund or the release of the hold alike,
+	// which also drops its cached balance and held amount on commit
+	account, err := s.accountRepo.GetByIDForUpdate(ctx, tx, transfer.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", transfer.AccountID, err)
+	}
+
+	if transfer.TransactionID != nil {
+		refund := &models.Transaction{
+			ToAccountID: account.ID,
+			Amount:      transfer.Amount,
+			Type:        models.TransactionTypeExternalReturn,
+			Description: fmt.Sprintf("Return of transfer #%d: %s", transfer.ID, reason),
+			CreatedAt:   time.Now(),
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, refund); err != nil {
+			return nil, fmt.Errorf("failed to post refund: %w", err)
+		}
+		transfer.ReturnTransaction = &refund.ID
+	} else if err := s.holdRepo.CloseTx(ctx, tx, models.HoldSourceExternalTransfer, transfer.ID, models.HoldStatusReleased); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	now := time.Now()
+	transfer.Status = models.ExternalTransferStatusReturned
+	transfer.ReturnReason = reason
+	transfer.CompletedAt = &now
+	if err := s.transferRepo.UpdateStatusTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"account_id": transfer.AccountID,
+		"amount":     transfer.Amount,
+		"currency":   transfer.Currency,
+		"reason":     reason,
+	})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionExternalTransferReturned,
+		EntityType: "external_transfer",
+		EntityID:   transfer.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.notifyStatusTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// notifyStatusTx queues the webhook event and the notification of a status change
+// in the transaction that made it
+func (s *ExternalTransferService) notifyStatusTx(ctx context.Context, tx repository.Tx, transfer *models.ExternalTransfer) error {
+	if err := s.webhookService.PublishTx(ctx, tx, transfer.UserID, models.WebhookEventExternalTransferStatusChanged, transfer); err != nil {
+		return err
+	}
+
+	var subject, content string
+	switch transfer.Status {
+	case models.ExternalTransferStatusPending:
+		subject = "Transfer accepted"
+		content = fmt.Sprintf("Your transfer #%d of %.2f %s to %s has been accepted.", transfer.ID, transfer.Amount, transfer.Currency, transfer.BeneficiaryName)
+	case models.ExternalTransferStatusProcessing:
+		subject = "Transfer sent"
+		content = fmt.Sprintf("Your transfer #%d of %.2f %s to %s has been sent to the beneficiary bank.", transfer.ID, transfer.Amount, transfer.Currency, transfer.BeneficiaryName)
+	case models.ExternalTransferStatusSettled:
+		subject = "Transfer completed"
+		content = fmt.Sprintf("Your transfer #%d of %.2f %s to %s has been credited by the beneficiary bank.", transfer.ID, transfer.Amount, transfer.Currency, transfer.BeneficiaryName)
+	case models.ExternalTransferStatusReturned:
+		subject = "Transfer returned"
+		outcome := "the amount is available again on"
+		if transfer.ReturnTransaction != nil {
+			outcome = "the amount refunded to"
+		}
+		content = fmt.Sprintf("Your transfer #%d of %.2f %s to %s has been returned (%s) and %s account #%d.",
+			transfer.ID, transfer.Amount, transfer.Currency, transfer.BeneficiaryName, transfer.ReturnReason, outcome, transfer.AccountID)
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, transfer.UserID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about external transfer %d", transfer.ID)
+		return err
+	}
+	return nil
+}
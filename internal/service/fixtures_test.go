@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/events"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+	"github.com/sirupsen/logrus"
+)
+
+// The services also write through concrete repositories (outbox, parameters,
+// jobs) that take no store interface. Those run on testDriver, which accepts
+// every statement without a database: INSERT ... RETURNING yields one row of
+// generated values and every other query yields no rows.
+
+func init() {
+	sql.Register("servicetest", testDriver{})
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt{query: query}, nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return testConn{}, nil }
+func (testConn) Commit() error                             { return nil }
+func (testConn) Rollback() error                           { return nil }
+
+type testStmt struct {
+	query string
+}
+
+func (testStmt) Close() error  { return nil }
+func (testStmt) NumInput() int { return -1 }
+
+func (testStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	rows := &testRows{}
+	if i := strings.LastIndex(strings.ToUpper(s.query), "RETURNING"); i >= 0 {
+		for _, column := range strings.Split(s.query[i+len("RETURNING"):], ",") {
+			rows.columns = append(rows.columns, strings.TrimSpace(column))
+		}
+	}
+	return rows, nil
+}
+
+type testRows struct {
+	columns []string
+	done    bool
+}
+
+func (r *testRows) Columns() []string { return r.columns }
+func (r *testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done || len(r.columns) == 0 {
+		return io.EOF
+	}
+	r.done = true
+	for i, column := range r.columns {
+		if strings.HasSuffix(column, "_at") {
+			dest[i] = time.Now()
+		} else {
+			dest[i] = int64(1)
+		}
+	}
+	return nil
+}
+
+// testEnv holds the database and dependencies shared by the services under test
+type testEnv struct {
+	db     *sql.DB
+	cfg    *config.Config
+	logger *logrus.Logger
+	relay  *outbox.Relay
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db, err := sql.Open("servicetest", t.Name())
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.DefaultConfig()
+
+	return &testEnv{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+		relay:  outbox.NewRelay(repository.NewOutboxRepository(db, logger), &cfg.Outbox, logger),
+	}
+}
+
+// tx returns a transaction whose statements run on the test database
+func (e *testEnv) tx() *mock.Tx {
+	return &mock.Tx{DB: e.db}
+}
+
+func (e *testEnv) parameterService() *ParameterService {
+	return NewParameterService(repository.NewParameterRepository(e.db, e.logger), repository.NewAuditRepository(e.db, e.logger), e.cfg, e.logger)
+}
+
+func (e *testEnv) publisher(t *testing.T) *events.Publisher {
+	t.Helper()
+	publisher, err := events.NewPublisher(&config.EventsConfig{}, e.relay, e.logger)
+	if err != nil {
+		t.Fatalf("create event publisher: %v", err)
+	}
+	return publisher
+}
+
+// beginTx makes a BeginTransactionFunc handing out tx
+func beginTx(tx repository.Tx) func(context.Context) (repository.Tx, error) {
+	return func(context.Context) (repository.Tx, error) { return tx, nil }
+}
+
+func float64Ptr(v float64) *float64 { return &v }
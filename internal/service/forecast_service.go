@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultForecastMonths is how far the balance is projected when no horizon is given
+	defaultForecastMonths = 3
+	maxForecastMonths     = 12
+	// forecastHistoryMonths is how much history the averages and the recurring
+	// payments are taken from, at most
+	forecastHistoryMonths = 6
+	// recurringMinMonths is the fewest months a payment to the same counterparty
+	// has to be made in to be recurring
+	recurringMinMonths = 3
+	// recurringTolerance is how far, as a fraction of the median, the monthly
+	// payments to a counterparty may vary and still be recurring
+	recurringTolerance = 0.2
+)
+
+// ForecastService projects the balance of the accounts of a user for the next
+// months from the transaction history and the credit schedules. Amounts are
+// converted to RUB at the CBR rates.
+type ForecastService struct {
+	repo        *repository.ForecastRepository
+	accountRepo *repository.AccountRepository
+	creditRepo  *repository.CreditRepository
+	rateService *RateService
+	logger      *logrus.Logger
+}
+
+// NewForecastService creates a new ForecastService instance
+func NewForecastService(
+	repo *repository.ForecastRepository,
+	accountRepo *repository.AccountRepository,
+	creditRepo *repository.CreditRepository,
+	rateService *RateService,
+	logger *logrus.Logger,
+) *ForecastService {
+	return &ForecastService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		creditRepo:  creditRepo,
+		rateService: rateService,
+		logger:      logger,
+	}
+}
+
+// forecastInstallment is an outstanding credit installment in RUB
+type forecastInstallment struct {
+	DueDate time.Time
+	Amount  float64
+}
+
+// Forecast projects the balance of all the accounts of a user at the end of
+// each of the next months, 3 unless given, up to 12. Every month the average
+// income and other spending of the history is expected again along with the
+// recurring payments, and the credit installments fall due on their schedule.
+func (s *ForecastService) Forecast(ctx context.Context, userID int64, months int, now time.Time) (*models.CashFlowForecast, error) {
+	if months == 0 {
+		months = defaultForecastMonths
+	}
+	if months < 1 || months > maxForecastMonths {
+		return nil, fmt.Errorf("months must be between 1 and %d", maxForecastMonths)
+	}
+
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	balance := 0.0
+	currencies := make(map[int64]string, len(accounts))
+	var opened time.Time
+	for _, account := range accounts {
+		amount, err := s.toBase(account.Balance, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		balance += amount
+		currencies[account.ID] = account.Currency
+		if opened.IsZero() || account.CreatedAt.Before(opened) {
+			opened = account.CreatedAt
+		}
+	}
+
+	history := forecastHistory(opened, now)
+	since := now.AddDate(0, -history, 0)
+	flows, err := s.repo.ListCashFlows(ctx, userID, since, now)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	for _, flow := range flows {
+		if flow.Amount, err = s.toBase(flow.Amount, flow.Currency); err != nil {
+			return nil, err
+		}
+		flow.Currency = models.BaseCurrency
+	}
+
+	installments, err := s.installments(ctx, userID, currencies)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := detectRecurring(flows, since, history)
+	income, expenses := averageCashFlows(flows, recurring, history)
+	periods := projectForecast(balance, income, expenses, recurring, installments, now, months)
+
+	return &models.CashFlowForecast{
+		Currency:         models.BaseCurrency,
+		Balance:          roundMoney(balance),
+		HistoryMonths:    history,
+		AverageIncome:    roundMoney(income),
+		AverageExpenses:  roundMoney(expenses),
+		Recurring:        recurring,
+		Periods:          periods,
+		ProjectedBalance: periods[len(periods)-1].ProjectedBalance,
+		GeneratedAt:      now,
+	}, nil
+}
+
+func (s *ForecastService) toBase(amount float64, currency string) (float64, error) {
+	rate, err := s.rateService.CrossRate(currency, models.BaseCurrency)
+	if err != nil {
+		return 0, fmt.Errorf("forecast is unavailable while the %s rate is unknown", currency)
+	}
+	return amount * rate, nil
+}
+
+// installments collects the outstanding installments of the credits of a user
+// being repaid, in RUB
+func (s *ForecastService) installments(ctx context.Context, userID int64, currencies map[int64]string) ([]forecastInstallment, error) {
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	var installments []forecastInstallment
+	for _, credit := range credits {
+		if !credit.IsRepaying() {
+			continue
+		}
+		currency, ok := currencies[credit.AccountID]
+		if !ok {
+			currency = models.BaseCurrency
+		}
+		schedule, err := s.creditRepo.GetPaymentSchedule(ctx, credit.ID)
+		if err != nil {
+			return nil, errors.New("internal server error")
+		}
+		for _, payment := range schedule {
+			if !payment.IsOutstanding() {
+				continue
+			}
+			amount, err := s.toBase(payment.AmountDue(), currency)
+			if err != nil {
+				return nil, err
+			}
+			installments = append(installments, forecastInstallment{DueDate: payment.DueDate, Amount: amount})
+		}
+	}
+	return installments, nil
+}
+
+// forecastHistory returns how many months of history the forecast is taken
+// from: the months since the first account of the user was opened, at least
+// one and at most six
+func forecastHistory(opened, now time.Time) int {
+	history := 1
+	for history < forecastHistoryMonths && !now.AddDate(0, -(history+1), 0).Before(opened) {
+		history++
+	}
+	return history
+}
+
+// forecastIncome reports whether an incoming transaction is income. Credits
+// disbursed, deposits returned and payments refunded or returned are one-off.
+func forecastIncome(transactionType string) bool {
+	switch transactionType {
+	case models.TransactionTypeCreditDisbursement, models.TransactionTypeDepositReturn,
+		models.TransactionTypeExternalReturn, models.TransactionTypeBillRefund,
+		models.TransactionTypeDisputeCredit:
+		return false
+	}
+	return true
+}
+
+// forecastExpense reports whether an outgoing transaction is spending. Credit
+// repayments are forecast from the schedules, and money set aside in term
+// deposits or reversed after disputes is not spent.
+func forecastExpense(transactionType string) bool {
+	switch transactionType {
+	case models.TransactionTypeCreditRepayment, models.TransactionTypeDepositOpening,
+		models.TransactionTypeDisputeReversal:
+		return false
+	}
+	return true
+}
+
+// detectRecurring finds the counterparties paid in at least three of the
+// months of the history, and in at least half of them, with monthly amounts
+// within 20% of their median. The median is expected to be paid every month.
+func detectRecurring(flows []*models.CashFlow, since time.Time, history int) []*models.RecurringPayment {
+	type group struct {
+		typ     string
+		monthly map[int]float64
+	}
+	groups := make(map[string]*group)
+	for _, flow := range flows {
+		if flow.Incoming || !forecastExpense(flow.Type) {
+			continue
+		}
+		month := historyMonth(flow.CreatedAt, since, history)
+		if month < 0 {
+			continue
+		}
+		g, ok := groups[flow.Counterparty]
+		if !ok {
+			g = &group{typ: flow.Type, monthly: make(map[int]float64)}
+			groups[flow.Counterparty] = g
+		}
+		g.monthly[month] += flow.Amount
+	}
+
+	recurring := []*models.RecurringPayment{}
+	for counterparty, g := range groups {
+		if len(g.monthly) < recurringMinMonths || len(g.monthly)*2 < history {
+			continue
+		}
+		amounts := make([]float64, 0, len(g.monthly))
+		for _, amount := range g.monthly {
+			amounts = append(amounts, amount)
+		}
+		sort.Float64s(amounts)
+		median := amounts[len(amounts)/2]
+		if len(amounts)%2 == 0 {
+			median = (amounts[len(amounts)/2-1] + median) / 2
+		}
+		if median <= 0 || amounts[0] < median*(1-recurringTolerance) || amounts[len(amounts)-1] > median*(1+recurringTolerance) {
+			continue
+		}
+		recurring = append(recurring, &models.RecurringPayment{
+			Counterparty: counterparty,
+			Type:         g.typ,
+			Amount:       roundMoney(median),
+			Months:       len(g.monthly),
+		})
+	}
+	sort.Slice(recurring, func(i, j int) bool {
+		if recurring[i].Amount != recurring[j].Amount {
+			return recurring[i].Amount > recurring[j].Amount
+		}
+		return recurring[i].Counterparty < recurring[j].Counterparty
+	})
+	return recurring
+}
+
+// historyMonth returns which month of the history, counted from since, a time
+// falls in, or -1 outside the history
+func historyMonth(at, since time.Time, history int) int {
+	for month := 0; month < history; month++ {
+		if !at.Before(since.AddDate(0, month, 0)) && at.Before(since.AddDate(0, month+1, 0)) {
+			return month
+		}
+	}
+	return -1
+}
+
+// averageCashFlows returns the average monthly income of the history and the
+// average monthly spending other than the recurring payments
+func averageCashFlows(flows []*models.CashFlow, recurring []*models.RecurringPayment, history int) (float64, float64) {
+	isRecurring := make(map[string]bool, len(recurring))
+	for _, payment := range recurring {
+		isRecurring[payment.Counterparty] = true
+	}
+
+	income, expenses := 0.0, 0.0
+	for _, flow := range flows {
+		switch {
+		case flow.Incoming && forecastIncome(flow.Type):
+			income += flow.Amount
+		case !flow.Incoming && forecastExpense(flow.Type) && !isRecurring[flow.Counterparty]:
+			expenses += flow.Amount
+		}
+	}
+	return income / float64(history), expenses / float64(history)
+}
+
+// projectForecast projects the balance over the next months, each starting on
+// the day of the forecast. Installments already overdue fall in the first month.
+func projectForecast(
+	balance, income, expenses float64,
+	recurring []*models.RecurringPayment,
+	installments []forecastInstallment,
+	now time.Time,
+	months int,
+) []*models.ForecastPeriod {
+	recurringTotal := 0.0
+	for _, payment := range recurring {
+		recurringTotal += payment.Amount
+	}
+
+	periods := make([]*models.ForecastPeriod, 0, months)
+	for i := 0; i < months; i++ {
+		period := &models.ForecastPeriod{
+			Start:             now.AddDate(0, i, 0),
+			End:               now.AddDate(0, i+1, 0),
+			Income:            roundMoney(income),
+			RecurringPayments: roundMoney(recurringTotal),
+			OtherExpenses:     roundMoney(expenses),
+		}
+		due := 0.0
+		for _, installment := range installments {
+			if installment.DueDate.Before(period.End) && (i == 0 || !installment.DueDate.Before(period.Start)) {
+				due += installment.Amount
+			}
+		}
+		period.CreditInstallments = roundMoney(due)
+		period.NetChange = roundMoney(period.Income - period.RecurringPayments - period.CreditInstallments - period.OtherExpenses)
+		balance = roundMoney(balance + period.NetChange)
+		period.ProjectedBalance = balance
+		periods = append(periods, period)
+	}
+	return periods
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestDetectRecurring(t *testing.T) {
+	since := time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC)
+	payment := func(month int, counterparty, transactionType string, amount float64) *models.CashFlow {
+		return &models.CashFlow{
+			Type:         transactionType,
+			Counterparty: counterparty,
+			Amount:       amount,
+			CreatedAt:    since.AddDate(0, month, 3),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		flows []*models.CashFlow
+		want  float64 // monthly amount, zero when not recurring
+	}{
+		{
+			name: "same amount every month",
+			flows: []*models.CashFlow{
+				payment(0, "biller:1:42", models.TransactionTypeBillPayment, 500),
+				payment(1, "biller:1:42", models.TransactionTypeBillPayment, 500),
+				payment(2, "biller:1:42", models.TransactionTypeBillPayment, 500),
+				payment(3, "biller:1:42", models.TransactionTypeBillPayment, 500),
+			},
+			want: 500,
+		},
+		{
+			name: "amounts within 20% of the median",
+			flows: []*models.CashFlow{
+				payment(0, "account:7", models.TransactionTypeTransfer, 1000),
+				payment(2, "account:7", models.TransactionTypeTransfer, 900),
+				payment(3, "account:7", models.TransactionTypeTransfer, 1100),
+			},
+			want: 1000,
+		},
+		{
+			name: "payments in a month summed",
+			flows: []*models.CashFlow{
+				payment(0, "account:7", models.TransactionTypeTransfer, 1000),
+				payment(1, "account:7", models.TransactionTypeTransfer, 400),
+				payment(1, "account:7", models.TransactionTypeTransfer, 600),
+				payment(2, "account:7", models.TransactionTypeTransfer, 1000),
+			},
+			want: 1000,
+		},
+		{
+			name: "paid in two months only",
+			flows: []*models.CashFlow{
+				payment(0, "biller:1:42", models.TransactionTypeBillPayment, 500),
+				payment(1, "biller:1:42", models.TransactionTypeBillPayment, 500),
+			},
+		},
+		{
+			name: "amounts varying too much",
+			flows: []*models.CashFlow{
+				payment(0, "card_payment:Shop", models.TransactionTypeCardPayment, 300),
+				payment(1, "card_payment:Shop", models.TransactionTypeCardPayment, 1000),
+				payment(2, "card_payment:Shop", models.TransactionTypeCardPayment, 1000),
+			},
+		},
+		{
+			name: "credit repayments left to the schedules",
+			flows: []*models.CashFlow{
+				payment(0, "credit_repayment:", models.TransactionTypeCreditRepayment, 5000),
+				payment(1, "credit_repayment:", models.TransactionTypeCreditRepayment, 5000),
+				payment(2, "credit_repayment:", models.TransactionTypeCreditRepayment, 5000),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recurring := detectRecurring(tt.flows, since, 6)
+			if tt.want == 0 {
+				if len(recurring) != 0 {
+					t.Errorf("detectRecurring() = %+v, want none", recurring[0])
+				}
+				return
+			}
+			if len(recurring) != 1 || recurring[0].Amount != tt.want {
+				t.Fatalf("detectRecurring() = %d payments, want one of %.2f", len(recurring), tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectForecast(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	recurring := []*models.RecurringPayment{{Counterparty: "biller:1:42", Amount: 500}}
+	installments := []forecastInstallment{
+		{DueDate: now.AddDate(0, 0, -10), Amount: 2000}, // overdue
+		{DueDate: now.AddDate(0, 0, 5), Amount: 3000},
+		{DueDate: now.AddDate(0, 1, 5), Amount: 3000},
+		{DueDate: now.AddDate(0, 5, 0), Amount: 3000}, // beyond the forecast
+	}
+
+	periods := projectForecast(10000, 50000, 40000, recurring, installments, now, 2)
+	if len(periods) != 2 {
+		t.Fatalf("projectForecast() = %d periods, want 2", len(periods))
+	}
+
+	tests := []struct {
+		installments float64
+		netChange    float64
+		balance      float64
+	}{
+		{installments: 5000, netChange: 4500, balance: 14500},
+		{installments: 3000, netChange: 6500, balance: 21000},
+	}
+	for i, tt := range tests {
+		period := periods[i]
+		if period.CreditInstallments != tt.installments || period.NetChange != tt.netChange || period.ProjectedBalance != tt.balance {
+			t.Errorf("period %d: installments %.2f, net change %.2f, balance %.2f, want %.2f, %.2f, %.2f",
+				i, period.CreditInstallments, period.NetChange, period.ProjectedBalance, tt.installments, tt.netChange, tt.balance)
+		}
+	}
+	if !periods[1].Start.Equal(periods[0].End) {
+		t.Errorf("second period starts %v, want %v", periods[1].Start, periods[0].End)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// IncomingPaymentService posts the credit advices received from the payment rail
+// to the accounts they are addressed to. An advice is identified by its reference,
+// so the rail may deliver it any number of times and it is credited once.
+type IncomingPaymentService struct {
+	paymentRepo         *repository.IncomingPaymentRepository
+	accountRepo         repository.AccountStore
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewIncomingPaymentService creates a new IncomingPaymentService instance
+func NewIncomingPaymentService(
+	paymentRepo *repository.IncomingPaymentRepository,
+	accountRepo repository.AccountStore,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *IncomingPaymentService {
+	return &IncomingPaymentService{
+		paymentRepo:         paymentRepo,
+		accountRepo:         accountRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// Ingest records a credit advice and credits the matched account with a deposit.
+// An advice that matches no account in its currency is kept as unmatched. The
+// second result reports whether the advice had already been received, in which
+// case the stored payment is returned unchanged.
+func (s *IncomingPaymentService) Ingest(ctx context.Context, req *models.IncomingPaymentRequest) (*models.IncomingPayment, bool, error) {
+	payment := &models.IncomingPayment{
+		Reference:     strings.TrimSpace(req.Reference),
+		PayerName:     strings.TrimSpace(req.PayerName),
+		PayerAccount:  strings.TrimSpace(req.PayerAccount),
+		AccountNumber: strings.TrimSpace(req.AccountNumber),
+		Amount:        roundMoney(req.Amount),
+		Currency:      strings.ToUpper(req.Currency),
+		Description:   strings.TrimSpace(req.Description),
+	}
+	if payment.Reference == "" {
+		return nil, false, errors.New("reference is required")
+	}
+	if payment.Amount <= 0 {
+		return nil, false, errors.New("amount must be greater than zero")
+	}
+
+	if existing, err := s.paymentRepo.GetByReference(ctx, payment.Reference); err == nil {
+		return existing, true, nil
+	}
+
+	tx, err := s.paymentRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, reason := s.matchAccountTx(ctx, tx, payment)
+	if account == nil {
+		payment.Status = models.IncomingPaymentStatusUnmatched
+		payment.Reason = reason
+	} else {
+		description := fmt.Sprintf("Incoming payment from %s, ref %s", payment.PayerName, payment.Reference)
+		if payment.Description != "" {
+			description += ": " + payment.Description
+		}
+		transaction := &models.Transaction{
+			ToAccountID: account.ID,
+			Amount:      payment.Amount,
+			Type:        models.TransactionTypeDeposit,
+			Description: description,
+			CreatedAt:   time.Now(),
+		}
+		if err := s.accountRepo.PostTransactionTx(ctx, tx, transaction); err != nil {
+			return nil, false, fmt.Errorf("failed to post incoming payment: %w", err)
+		}
+
+		payment.Status = models.IncomingPaymentStatusPosted
+		payment.AccountID = &account.ID
+		payment.TransactionID = &transaction.ID
+	}
+
+	created, err := s.paymentRepo.CreateTx(ctx, tx, payment)
+	if err != nil {
+		return nil, false, errors.New("internal server error")
+	}
+	if !created {
+		// Delivered concurrently by another request: drop this posting and
+		// answer with the payment that request stored
+		tx.Rollback()
+		existing, err := s.paymentRepo.GetByReference(ctx, payment.Reference)
+		if err != nil {
+			return nil, false, errors.New("internal server error")
+		}
+		return existing, true, nil
+	}
+
+	if account != nil {
+		content := fmt.Sprintf("%.2f %s from %s has been credited to account %s.", payment.Amount, payment.Currency, payment.PayerName, account.MaskedNumber())
+		if err := s.notificationService.NotifyUserTx(ctx, tx, account.UserID, "Incoming payment", content); err != nil {
+			s.logger.WithError(err).Errorf("Failed to queue notification about incoming payment %s", payment.Reference)
+			return nil, false, errors.New("internal server error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if account == nil {
+		s.logger.Warnf("Incoming payment %s is unmatched: %s", payment.Reference, payment.Reason)
+	}
+	return payment, false, nil
+}
+
+// matchAccountTx locks the account a payment is addressed to. Without a match,
+// a closed account included, it returns the reason the payment cannot be posted.
+func (s *IncomingPaymentService) matchAccountTx(ctx context.Context, tx repository.Tx, payment *models.IncomingPayment) (*models.Account, string) {
+	account, err := s.accountRepo.GetByNumber(ctx, payment.AccountNumber)
+	if err != nil {
+		return nil, "account not found"
+	}
+
+	account, err = s.accountRepo.GetByIDForUpdate(ctx, tx, account.ID)
+	if err != nil {
+		return nil, "account not found"
+	}
+	if account.IsClosed() {
+		return nil, "account is closed"
+	}
+	if account.Currency != payment.Currency {
+		return nil, fmt.Sprintf("account currency is %s", account.Currency)
+	}
+
+	return account, ""
+}
+
+// ListPayments lists the received payments, optionally only those with the given status
+func (s *IncomingPaymentService) ListPayments(ctx context.Context, status models.IncomingPaymentStatus) ([]*models.IncomingPayment, error) {
+	switch status {
+	case "", models.IncomingPaymentStatusPosted, models.IncomingPaymentStatusUnmatched:
+	default:
+		return nil, fmt.Errorf("unknown incoming payment status %q", status)
+	}
+
+	payments, err := s.paymentRepo.List(ctx, status)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return payments, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// JobService reports the progress of long-running operations to the users who
+// started them. The operations answer 202 Accepted with the job and are
+// followed by polling it until it has finished.
+type JobService struct {
+	jobRepo   *repository.JobRepository
+	store     storage.Storage
+	urlExpiry time.Duration
+	apiPrefix string
+	logger    *logrus.Logger
+}
+
+// NewJobService creates a new JobService instance. The links of the reports
+// are paths under apiPrefix.
+func NewJobService(jobRepo *repository.JobRepository, store storage.Storage, urlExpiry time.Duration, apiPrefix string, logger *logrus.Logger) *JobService {
+	return &JobService{
+		jobRepo:   jobRepo,
+		store:     store,
+		urlExpiry: urlExpiry,
+		apiPrefix: apiPrefix,
+		logger:    logger,
+	}
+}
+
+// GetJob reports the progress of a job of the user. Once the job has succeeded
+// the report links to what it produced, a stored file through a fresh signed URL.
+func (s *JobService) GetJob(ctx context.Context, userID, jobID int64) (*models.JobReport, error) {
+	job, err := s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		if err.Error() == "job not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	// System jobs and jobs of other users are reported as missing
+	if job.UserID == nil || *job.UserID != userID {
+		return nil, errors.New("job not found")
+	}
+
+	report := s.Report(job)
+	if job.Status == models.JobStatusSucceeded && job.Result != nil && job.Result.StorageKey != "" {
+		url, err := signedURL(ctx, s.store, job.Result.StorageKey, s.urlExpiry)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to sign URL of the result of job %d", job.ID)
+			return nil, errors.New("internal server error")
+		}
+		report.Links.Download = url
+	}
+	return report, nil
+}
+
+// Report returns the report of a job without signing a download link, as the
+// operations starting a job answer with it
+func (s *JobService) Report(job *models.Job) *models.JobReport {
+	report := &models.JobReport{
+		ID:          job.ID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		Error:       job.LastError,
+		StartedAt:   job.StartedAt,
+		FinishedAt:  job.FinishedAt,
+		CreatedAt:   job.CreatedAt,
+		Links:       models.JobLinks{Self: s.JobPath(job.ID)},
+	}
+	if job.Status == models.JobStatusSucceeded && job.Result != nil && job.Result.Resource != "" {
+		report.Links.Result = s.apiPrefix + job.Result.Resource
+	}
+	return report
+}
+
+// JobPath returns the path where the progress of a job is reported
+func (s *JobService) JobPath(jobID int64) string {
+	return fmt.Sprintf("%s/jobs/%d", s.apiPrefix, jobID)
+}
+
+// storeJobFile keeps a file produced by a job in object storage and records it
+// as the result of the job. The key is fixed by the job, so a retry replaces
+// the file.
+func storeJobFile(ctx context.Context, store storage.Storage, jobRunner *jobs.Runner, job *models.Job, fileName, contentType string, data []byte) error {
+	key := fmt.Sprintf("exports/jobs/%d/%s", job.ID, fileName)
+	if err := store.Put(ctx, key, contentType, data); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return jobRunner.SetResult(ctx, job.ID, &models.JobResult{StorageKey: key, FileName: fileName})
+}
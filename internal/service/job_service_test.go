@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+func TestJobServiceReport(t *testing.T) {
+	env := newTestEnv(t)
+	s := NewJobService(repository.NewJobRepository(env.db, env.logger), nil, env.cfg.Storage.URLExpiry, "/api/v1", env.logger)
+
+	tests := []struct {
+		name       string
+		status     models.JobStatus
+		result     *models.JobResult
+		wantResult string
+	}{
+		{name: "queued", status: models.JobStatusQueued, result: nil},
+		{name: "running with the result of a failed attempt", status: models.JobStatusRunning, result: &models.JobResult{Resource: "/transfers/batch/3"}},
+		{name: "succeeded", status: models.JobStatusSucceeded, result: &models.JobResult{Resource: "/transfers/batch/3"}, wantResult: "/api/v1/transfers/batch/3"},
+		{name: "succeeded with a file", status: models.JobStatusSucceeded, result: &models.JobResult{StorageKey: "exports/jobs/7/user-1.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := s.Report(&models.Job{ID: 7, Type: models.JobTypeTransferBatch, Status: tt.status, Result: tt.result})
+			if report.Links.Self != "/api/v1/jobs/7" {
+				t.Errorf("self link %q", report.Links.Self)
+			}
+			if report.Links.Result != tt.wantResult {
+				t.Errorf("result link %q, want %q", report.Links.Result, tt.wantResult)
+			}
+			if report.Links.Download != nil {
+				t.Error("report was given a download link without signing it")
+			}
+		})
+	}
+
+	if _, err := s.GetJob(context.Background(), 1, 7); err == nil || err.Error() != "job not found" {
+		t.Errorf("missing job: error %v", err)
+	}
+}
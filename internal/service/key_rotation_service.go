@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/pii"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+// reencryptBatchSize is how many users are locked and rewritten at a time
+const reencryptBatchSize = 500
+
+// KeyRotationResult reports a rotation of the data key of personal data
+type KeyRotationResult struct {
+	KeyID       int64 // the active data key after the rotation
+	Rotated     bool  // whether a new data key was created
+	Reencrypted int64 // users whose personal data was rewritten
+	Retired     int64 // data keys taken out of use
+}
+
+// KeyRotationService rotates the data key of users' personal data
+type KeyRotationService struct {
+	cipher       *pii.Cipher
+	userRepo     *repository.UserRepository
+	keyRepo      *repository.PIIKeyRepository
+	rotationDays int
+}
+
+// NewKeyRotationService creates a new KeyRotationService instance. cipher is
+// nil when personal data encryption is not configured, and userRepo encrypts
+// with it. The data key is due for rotation once older than rotationDays.
+func NewKeyRotationService(cipher *pii.Cipher, userRepo *repository.UserRepository, keyRepo *repository.PIIKeyRepository, rotationDays int) *KeyRotationService {
+	return &KeyRotationService{
+		cipher:       cipher,
+		userRepo:     userRepo,
+		keyRepo:      keyRepo,
+		rotationDays: rotationDays,
+	}
+}
+
+// Rotate creates a new data key once the active one is due, or at once with
+// force, encrypts all personal data under the active key and retires the
+// previous keys
+func (s *KeyRotationService) Rotate(ctx context.Context, force bool) (*KeyRotationResult, error) {
+	if s.cipher == nil {
+		return nil, errors.New("personal data encryption is not configured")
+	}
+
+	result := &KeyRotationResult{}
+	active := s.cipher.ActiveKey()
+	maxAge := time.Duration(s.rotationDays) * 24 * time.Hour
+	if force || (s.rotationDays > 0 && time.Since(active.CreatedAt) > maxAge) {
+		var err error
+		if active, err = s.cipher.Rotate(ctx); err != nil {
+			return nil, err
+		}
+		result.Rotated = true
+	}
+	result.KeyID = active.ID
+
+	rewritten, err := s.userRepo.ReencryptPII(ctx, reencryptBatchSize)
+	result.Reencrypted = rewritten
+	if err != nil {
+		return result, fmt.Errorf("re-encrypted %d users before failing: %w", rewritten, err)
+	}
+
+	if result.Retired, err = s.keyRepo.RetireOthers(ctx, active.ID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const auditEntityKYCDocument = "kyc_document"
+
+// documentContentTypes are the file types accepted for documents users upload,
+// as sniffed from their content, with the extension they are stored under
+var documentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// maxDocumentFileNameLength bounds the file name kept with a document
+const maxDocumentFileNameLength = 255
+
+// KYCService handles the verification of users' identity: the upload of their
+// documents to object storage, their submission and the review by administrators
+type KYCService struct {
+	repo                *repository.KYCRepository
+	auditRepo           *repository.AuditRepository
+	store               storage.Storage
+	urlExpiry           time.Duration
+	notificationService *NotificationService
+	cfg                 *config.KYCConfig
+	logger              *logrus.Logger
+}
+
+// NewKYCService creates a new KYCService instance
+func NewKYCService(
+	repo *repository.KYCRepository,
+	auditRepo *repository.AuditRepository,
+	store storage.Storage,
+	urlExpiry time.Duration,
+	notificationService *NotificationService,
+	cfg *config.KYCConfig,
+	logger *logrus.Logger,
+) *KYCService {
+	return &KYCService{
+		repo:                repo,
+		auditRepo:           auditRepo,
+		store:               store,
+		urlExpiry:           urlExpiry,
+		notificationService: notificationService,
+		cfg:                 cfg,
+		logger:              logger,
+	}
+}
+
+// GetVerification returns the verification of a user with the uploaded documents
+func (s *KYCService) GetVerification(ctx context.Context, userID int64) (*models.KYCVerification, error) {
+	v, err := s.repo.GetVerification(ctx, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	v.Documents, err = s.repo.ListDocuments(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return v, nil
+}
+
+// UploadDocument stores an identity document of a user. Documents are accepted
+// until the user submits them and again after a rejection; the latest of each
+// type is the one reviewed.
+func (s *KYCService) UploadDocument(ctx context.Context, userID int64, docType, fileName string, data []byte) (*models.KYCDocument, error) {
+	if !isKYCDocumentType(docType) {
+		return nil, fmt.Errorf("document type must be one of %v", models.KYCDocumentTypes)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("document is empty")
+	}
+	if int64(len(data)) > s.cfg.MaxDocumentSize {
+		return nil, fmt.Errorf("document is larger than %d bytes", s.cfg.MaxDocumentSize)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := documentContentTypes[contentType]
+	if !ok {
+		return nil, errors.New("document must be a JPEG or PNG image or a PDF file")
+	}
+
+	v, err := s.repo.GetVerification(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	switch v.Status {
+	case models.KYCStatusPending:
+		return nil, errors.New("documents are under review")
+	case models.KYCStatusVerified:
+		return nil, errors.New("identity is already verified")
+	}
+
+	doc := &models.KYCDocument{
+		UserID:      userID,
+		Type:        docType,
+		StorageKey:  fmt.Sprintf("kyc/%d/%s%s", userID, uuid.New().String(), ext),
+		FileName:    documentFileName(fileName, docType+ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	if err := s.store.Put(ctx, doc.StorageKey, contentType, data); err != nil {
+		s.logger.WithError(err).Errorf("Failed to store KYC document of user %d", userID)
+		return nil, errors.New("internal server error")
+	}
+	if err := s.repo.CreateDocument(ctx, doc); err != nil {
+		if err := s.store.Delete(ctx, doc.StorageKey); err != nil {
+			s.logger.WithError(err).Warnf("Failed to delete orphaned KYC document %s", doc.StorageKey)
+		}
+		return nil, errors.New("internal server error")
+	}
+	return doc, nil
+}
+
+func isKYCDocumentType(docType string) bool {
+	for _, t := range models.KYCDocumentTypes {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// documentFileName keeps the base name of an uploaded file, or the fallback
+// when the client sent none
+func documentFileName(name, fallback string) string {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		return fallback
+	}
+	if len(name) > maxDocumentFileNameLength {
+		name = name[len(name)-maxDocumentFileNameLength:]
+	}
+	return name
+}
+
+// Submit puts the documents of a user up for review. A document of every type
+// must have been uploaded since the last rejection.
+func (s *KYCService) Submit(ctx context.Context, userID int64) (*models.KYCVerification, error) {
+	v, err := s.GetVerification(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != models.KYCStatusUnverified && v.Status != models.KYCStatusRejected {
+		return nil, fmt.Errorf("verification cannot be submitted while it is %s", v.Status)
+	}
+
+	for _, docType := range models.KYCDocumentTypes {
+		latest := latestKYCDocument(v.Documents, docType)
+		if latest == nil || (v.ReviewedAt != nil && latest.CreatedAt.Before(*v.ReviewedAt)) {
+			return nil, fmt.Errorf("%s document must be uploaded before submitting", docType)
+		}
+	}
+
+	submitted, err := s.repo.Submit(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if !submitted {
+		return nil, errors.New("verification was changed in the meantime")
+	}
+	return s.GetVerification(ctx, userID)
+}
+
+// latestKYCDocument returns the newest document of a type from a list ordered
+// newest first
+func latestKYCDocument(docs []*models.KYCDocument, docType string) *models.KYCDocument {
+	for _, doc := range docs {
+		if doc.Type == docType {
+			return doc
+		}
+	}
+	return nil
+}
+
+// ListVerifications returns a page of the submitted verifications and how many there are
+func (s *KYCService) ListVerifications(ctx context.Context, p pagination.Params) ([]*models.KYCVerification, int, error) {
+	verifications, total, err := s.repo.ListVerifications(ctx, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return verifications, total, nil
+}
+
+// GetDocument returns a document with its file for review. Every view of a
+// document is audited.
+func (s *KYCService) GetDocument(ctx context.Context, adminID, documentID int64) (*models.KYCDocument, []byte, error) {
+	doc, err := s.repo.GetDocument(ctx, documentID)
+	if err != nil {
+		if err.Error() == "document not found" {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("internal server error")
+	}
+
+	data, err := s.store.Get(ctx, doc.StorageKey)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to read KYC document %d from storage", doc.ID)
+		return nil, nil, errors.New("internal server error")
+	}
+
+	s.auditDocumentView(ctx, adminID, doc)
+	return doc, data, nil
+}
+
+// DocumentURL returns a signed URL to view a document for review. Handing out
+// the URL is audited as a view of the document.
+func (s *KYCService) DocumentURL(ctx context.Context, adminID, documentID int64) (*models.SignedURL, error) {
+	doc, err := s.repo.GetDocument(ctx, documentID)
+	if err != nil {
+		if err.Error() == "document not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	url, err := signedURL(ctx, s.store, doc.StorageKey, s.urlExpiry)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign URL of KYC document %d", doc.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	s.auditDocumentView(ctx, adminID, doc)
+	return url, nil
+}
+
+func (s *KYCService) auditDocumentView(ctx context.Context, adminID int64, doc *models.KYCDocument) {
+	details, _ := json.Marshal(map[string]interface{}{"user_id": doc.UserID, "type": doc.Type})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionKYCDocumentViewed,
+		EntityType: auditEntityKYCDocument,
+		EntityID:   doc.ID,
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warnf("Failed to audit view of KYC document %d", doc.ID)
+	}
+}
+
+// Approve verifies the identity of a user whose documents are pending review
+func (s *KYCService) Approve(ctx context.Context, adminID, userID int64, comment string) (*models.KYCVerification, error) {
+	return s.review(ctx, adminID, userID, comment, models.KYCStatusVerified)
+}
+
+// Reject turns down the documents of a user pending review; the comment tells
+// the user why
+func (s *KYCService) Reject(ctx context.Context, adminID, userID int64, comment string) (*models.KYCVerification, error) {
+	if comment == "" {
+		return nil, errors.New("comment is required to reject a verification")
+	}
+	return s.review(ctx, adminID, userID, comment, models.KYCStatusRejected)
+}
+
+func (s *KYCService) review(ctx context.Context, adminID, userID int64, comment string, status models.KYCStatus) (*models.KYCVerification, error) {
+	if adminID == userID {
+		return nil, errors.New("verification must be reviewed by someone else")
+	}
+
+	v, err := s.GetVerification(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != models.KYCStatusPending {
+		return nil, errors.New("verification is not pending review")
+	}
+
+	now := time.Now().UTC()
+	v.Status = status
+	v.Comment = comment
+	v.ReviewerID = &adminID
+	v.ReviewedAt = &now
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	reviewed, err := s.repo.ReviewTx(ctx, tx, v)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if !reviewed {
+		return nil, errors.New("verification was changed in the meantime")
+	}
+
+	action := models.AuditActionKYCVerified
+	if status == models.KYCStatusRejected {
+		action = models.AuditActionKYCRejected
+	}
+	details, _ := json.Marshal(map[string]interface{}{"comment": comment})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     action,
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	content := "Your identity has been verified."
+	if status == models.KYCStatusRejected {
+		content = "Your identity documents have been rejected: " + comment + " Please upload them again."
+	}
+	if err := s.notificationService.NotifyUserTx(ctx, tx, userID, "Identity verification", content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about KYC review of user %d", userID)
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return v, nil
+}
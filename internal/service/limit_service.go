@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Lengths of the rolling limit periods
+const (
+	limitDailyWindow   = 24 * time.Hour
+	limitMonthlyWindow = 30 * 24 * time.Hour
+)
+
+// LimitService enforces cumulative transfer and withdrawal limits per user. The
+// configured limits apply to every user unless an administrator overrides them.
+type LimitService struct {
+	repo      *repository.LimitRepository
+	userRepo  repository.UserStore
+	auditRepo *repository.AuditRepository
+	cfg       *config.LimitsConfig
+	logger    *logrus.Logger
+}
+
+// NewLimitService creates a new LimitService instance
+func NewLimitService(
+	repo *repository.LimitRepository,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	cfg *config.LimitsConfig,
+	logger *logrus.Logger,
+) *LimitService {
+	return &LimitService{
+		repo:      repo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// effectiveLimit picks the limit set for the user over the configured default.
+// A default of 0 leaves the transactions unlimited.
+func effectiveLimit(override *float64, defaultLimit float64) (*float64, string) {
+	if override != nil {
+		return override, models.LimitSourceUser
+	}
+	if defaultLimit > 0 {
+		return &defaultLimit, models.LimitSourceDefault
+	}
+	return nil, models.LimitSourceDefault
+}
+
+// usages lists the limits of a transaction type with the amounts used in each
+// period. The limits of a user whose identity is not verified are capped at the
+// configured unverified limits.
+func (s *LimitService) usages(limits *models.UserLimits, verified bool, txType string, daily, monthly float64) []models.LimitUsage {
+	if limits == nil {
+		limits = &models.UserLimits{}
+	}
+
+	var dailyOverride, monthlyOverride *float64
+	var dailyDefault, monthlyDefault float64
+	if txType == models.TransactionTypeTransfer {
+		dailyOverride, monthlyOverride = limits.DailyTransfer, limits.MonthlyTransfer
+		dailyDefault, monthlyDefault = s.cfg.DailyTransfer, s.cfg.MonthlyTransfer
+	} else {
+		dailyOverride, monthlyOverride = limits.DailyWithdrawal, limits.MonthlyWithdrawal
+		dailyDefault, monthlyDefault = s.cfg.DailyWithdrawal, s.cfg.MonthlyWithdrawal
+	}
+
+	usage := func(period string, override *float64, defaultLimit, unverifiedLimit, used float64) models.LimitUsage {
+		limit, source := effectiveLimit(override, defaultLimit)
+		if !verified && unverifiedLimit > 0 && (limit == nil || unverifiedLimit < *limit) {
+			limit, source = &unverifiedLimit, models.LimitSourceKYC
+		}
+		result := models.LimitUsage{Type: txType, Period: period, Limit: limit, Used: used, Source: source}
+		if limit != nil {
+			remaining := *limit - used
+			if remaining < 0 {
+				remaining = 0
+			}
+			result.Remaining = &remaining
+		}
+		return result
+	}
+
+	return []models.LimitUsage{
+		usage(models.LimitPeriodDaily, dailyOverride, dailyDefault, s.cfg.UnverifiedDaily, daily),
+		usage(models.LimitPeriodMonthly, monthlyOverride, monthlyDefault, s.cfg.UnverifiedMonthly, monthly),
+	}
+}
+
+// GetLimits returns the limits of a user and the allowance remaining at the given time
+func (s *LimitService) GetLimits(ctx context.Context, userID int64, now time.Time) (*models.UserLimitsResponse, error) {
+	limits, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	verified, err := s.verified(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserLimitsResponse{UserID: userID}
+	for _, txType := range []string{models.TransactionTypeTransfer, models.TransactionTypeWithdrawal} {
+		daily, monthly, err := s.repo.GetOutgoingTotals(ctx, userID, txType, now.Add(-limitDailyWindow), now.Add(-limitMonthlyWindow))
+		if err != nil {
+			return nil, errors.New("internal server error")
+		}
+		response.Limits = append(response.Limits, s.usages(limits, verified, txType, daily, monthly)...)
+	}
+
+	return response, nil
+}
+
+// SetLimits overrides the limits of a user. Limits left out of the request return
+// to the configured defaults.
+func (s *LimitService) SetLimits(ctx context.Context, adminID, userID int64, req *models.SetUserLimitsRequest) (*models.UserLimits, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to get user")
+		return nil, errors.New("user not found")
+	}
+
+	previous, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	limits := &models.UserLimits{
+		UserID:            userID,
+		DailyTransfer:     req.DailyTransfer,
+		MonthlyTransfer:   req.MonthlyTransfer,
+		DailyWithdrawal:   req.DailyWithdrawal,
+		MonthlyWithdrawal: req.MonthlyWithdrawal,
+		Comment:           req.Comment,
+		UpdatedBy:         adminID,
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.UpsertTx(ctx, tx, limits); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"previous": previous,
+		"limits":   limits,
+	})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionUserLimitsChanged,
+		EntityType: "user",
+		EntityID:   userID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return limits, nil
+}
+
+// Check verifies that a transaction fits into the limits of the user. It lets a
+// transaction over the limits be turned down before it is screened or locked,
+// CheckTx decides within the transaction.
+func (s *LimitService) Check(ctx context.Context, userID int64, txType string, amount float64) error {
+	now := time.Now()
+	daily, monthly, err := s.repo.GetOutgoingTotals(ctx, userID, txType, now.Add(-limitDailyWindow), now.Add(-limitMonthlyWindow))
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	return s.check(ctx, userID, txType, amount, daily, monthly)
+}
+
+// CheckTx verifies that a transaction fits into the limits of the user within
+// the transaction that executes it. The user is locked, so concurrent
+// transactions of the user cannot exceed the limits together.
+func (s *LimitService) CheckTx(ctx context.Context, tx repository.Tx, userID int64, txType string, amount float64) error {
+	if err := s.repo.LockUserTx(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	daily, monthly, err := s.repo.GetOutgoingTotalsTx(ctx, tx, userID, txType, now.Add(-limitDailyWindow), now.Add(-limitMonthlyWindow))
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	return s.check(ctx, userID, txType, amount, daily, monthly)
+}
+
+func (s *LimitService) check(ctx context.Context, userID int64, txType string, amount, daily, monthly float64) error {
+	limits, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	verified, err := s.verified(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range s.usages(limits, verified, txType, daily, monthly) {
+		if usage.Remaining != nil && amount > *usage.Remaining {
+			return fmt.Errorf("%s %s limit exceeded, %.2f remaining", usage.Period, txType, *usage.Remaining)
+		}
+	}
+
+	return nil
+}
+
+// verified tells whether the identity of a user is verified
+func (s *LimitService) verified(ctx context.Context, userID int64) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to get user %d for limits", userID)
+		return false, errors.New("internal server error")
+	}
+	return user.KYCStatus == models.KYCStatusVerified, nil
+}
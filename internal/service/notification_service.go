@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/integration/sms"
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/locale"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// notificationOutboxPayload is the outbox message of a notification; the topic
+// of the message selects the channel
+type notificationOutboxPayload struct {
+	UserID  int64  `json:"user_id"`
+	Subject string `json:"subject"`
+	Content string `json:"content"`
+}
+
+// notificationFrames are the greeting and the footer wrapped around the content
+// of an email notification in every language
+var notificationFrames = map[string]struct{ greeting, footer string }{
+	locale.Russian: {
+		greeting: "Здравствуйте, %s!",
+		footer:   "Отправлено %s. Это автоматическое уведомление, отвечать на него не нужно.",
+	},
+	locale.English: {
+		greeting: "Hello, %s!",
+		footer:   "Sent %s. This is an automated notification, please do not reply.",
+	},
+}
+
+// emailSender sends emails: the SMTP client, or the mailbox capturing them in
+// sandbox mode
+type emailSender interface {
+	SendEmailWithAttachments(notification *models.Notification, attachments []smtp.Attachment) error
+}
+
+// NotificationService delivers notifications to users by email and, for users
+// who enabled it in their settings, by SMS
+type NotificationService struct {
+	smtpClient   emailSender
+	smsClient    *sms.Client
+	userRepo     repository.UserStore
+	settingsRepo *repository.UserSettingsRepository
+	relay        *outbox.Relay
+	logger       *logrus.Logger
+}
+
+// NewNotificationService creates a new NotificationService instance and registers
+// its email and SMS publishers with the outbox relay.
+// Email delivery is disabled when no SMTP host is configured, SMS delivery when
+// no SMS provider is.
+func NewNotificationService(
+	smtpCfg *config.SMTPConfig,
+	smsCfg *config.SMSConfig,
+	userRepo repository.UserStore,
+	settingsRepo *repository.UserSettingsRepository,
+	relay *outbox.Relay,
+	logger *logrus.Logger,
+) *NotificationService {
+	var smtpClient emailSender
+	if smtpCfg.Host != "" {
+		smtpClient = smtp.NewClient(smtpCfg)
+	}
+
+	var smsClient *sms.Client
+	if smsCfg.Provider != "" {
+		client, err := sms.NewClient(smsCfg)
+		if err != nil {
+			logger.WithError(err).Error("SMS notifications are disabled")
+		}
+		smsClient = client
+	}
+
+	s := &NotificationService{
+		smtpClient:   smtpClient,
+		smsClient:    smsClient,
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		relay:        relay,
+		logger:       logger,
+	}
+	relay.Register(models.OutboxTopicEmail, s.publishEmail)
+	relay.Register(models.OutboxTopicSMS, s.publishSMS)
+	return s
+}
+
+// SetEmailSender replaces the SMTP client, so that emails are captured by the
+// sandbox mailbox instead of sent
+func (s *NotificationService) SetEmailSender(sender emailSender) {
+	s.smtpClient = sender
+}
+
+// NotifyUserTx queues a notification within a database transaction, so that it is
+// sent once the change it announces is committed and never for a rolled back one.
+// Every channel is queued separately, so a failed SMS is retried without sending
+// the email again.
+func (s *NotificationService) NotifyUserTx(ctx context.Context, tx repository.Tx, userID int64, subject, content string) error {
+	payload := notificationOutboxPayload{
+		UserID:  userID,
+		Subject: subject,
+		Content: content,
+	}
+	if err := s.relay.AddTx(ctx, tx, models.OutboxTopicEmail, payload); err != nil {
+		return err
+	}
+	if s.smsClient == nil {
+		return nil
+	}
+	return s.relay.AddTx(ctx, tx, models.OutboxTopicSMS, payload)
+}
+
+// publishEmail sends an email notification written to the outbox
+func (s *NotificationService) publishEmail(ctx context.Context, msg *models.OutboxMessage) error {
+	var payload notificationOutboxPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid email outbox payload: %w", err)
+	}
+	return s.NotifyUserWithAttachments(ctx, payload.UserID, payload.Subject, payload.Content, nil)
+}
+
+// publishSMS sends an SMS notification written to the outbox
+func (s *NotificationService) publishSMS(ctx context.Context, msg *models.OutboxMessage) error {
+	var payload notificationOutboxPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid SMS outbox payload: %w", err)
+	}
+	return s.notifyUserBySMS(ctx, payload.UserID, payload.Subject, payload.Content)
+}
+
+// NotifyUser sends a notification to a user by email and by SMS, on the channels
+// the user enabled
+func (s *NotificationService) NotifyUser(ctx context.Context, userID int64, subject, content string) error {
+	return errors.Join(
+		s.NotifyUserWithAttachments(ctx, userID, subject, content, nil),
+		s.notifyUserBySMS(ctx, userID, subject, content),
+	)
+}
+
+// NotifyUserWithAttachments sends an email notification with files attached to a
+// user who has not turned email notifications off
+func (s *NotificationService) NotifyUserWithAttachments(ctx context.Context, userID int64, subject, content string, attachments []smtp.Attachment) error {
+	return s.email(ctx, userID, subject, content, attachments, false)
+}
+
+// EmailUser sends an email the user subscribed to separately, such as the monthly
+// statements, whether or not email notifications are turned on
+func (s *NotificationService) EmailUser(ctx context.Context, userID int64, subject, content string, attachments []smtp.Attachment) error {
+	return s.email(ctx, userID, subject, content, attachments, true)
+}
+
+// email sends an email to a user, skipping users who turned email notifications
+// off unless subscribed is set. The content is framed with a greeting and the time
+// it was sent in the language and time zone of the user settings.
+func (s *NotificationService) email(ctx context.Context, userID int64, subject, content string, attachments []smtp.Attachment, subscribed bool) error {
+	settings, err := s.settingsRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !settings.EmailNotifications && !subscribed {
+		s.logger.WithField("user_id", userID).Debug("User turned email notifications off, skipping email")
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get notification recipient")
+		return err
+	}
+
+	content = s.renderEmail(user, settings, content, time.Now())
+	return s.dispatch(ctx, newNotification(models.NotificationTypeEmail, user.ID, user.Email, subject, content), attachments)
+}
+
+// renderEmail wraps the content of an email notification with the greeting and
+// the footer of the user's language
+func (s *NotificationService) renderEmail(user *models.User, settings *models.UserSettings, content string, sentAt time.Time) string {
+	language := locale.Normalize(settings.Language)
+	frame := notificationFrames[language]
+	sent := locale.FormatDateTime(language, sentAt.In(userLocation(s.logger, settings.TimeZone)))
+
+	return fmt.Sprintf(frame.greeting, user.Username) + "\n\n" + content + "\n\n" + fmt.Sprintf(frame.footer, sent)
+}
+
+// notifyUserBySMS sends an SMS notification to a user who enabled SMS notifications
+// and has a phone number
+func (s *NotificationService) notifyUserBySMS(ctx context.Context, userID int64, subject, content string) error {
+	if s.smsClient == nil {
+		return nil
+	}
+
+	settings, err := s.settingsRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !settings.SMSNotifications {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get notification recipient")
+		return err
+	}
+	if user.PhoneNumber == "" {
+		s.logger.WithField("user_id", userID).Debug("User has no phone number, skipping SMS notification")
+		return nil
+	}
+
+	return s.dispatch(ctx, newNotification(models.NotificationTypeSMS, user.ID, user.PhoneNumber, subject, subject+": "+content), nil)
+}
+
+func newNotification(notificationType models.NotificationType, userID int64, recipient, subject, content string) *models.Notification {
+	return &models.Notification{
+		UserID:    userID,
+		Type:      notificationType,
+		Priority:  models.PriorityNormal,
+		Status:    models.NotificationStatusPending,
+		Subject:   subject,
+		Content:   content,
+		Recipient: recipient,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// dispatch sends a notification over the channel of its type. Attachments are
+// only sent by email.
+func (s *NotificationService) dispatch(ctx context.Context, notification *models.Notification, attachments []smtp.Attachment) error {
+	var err error
+	switch notification.Type {
+	case models.NotificationTypeEmail:
+		if s.smtpClient == nil {
+			s.logger.WithFields(logrus.Fields{
+				"user_id": notification.UserID,
+				"subject": notification.Subject,
+			}).Debug("SMTP is not configured, skipping notification")
+			return nil
+		}
+		err = s.smtpClient.SendEmailWithAttachments(notification, attachments)
+	case models.NotificationTypeSMS:
+		if s.smsClient == nil {
+			return nil
+		}
+		err = s.smsClient.SendSMS(ctx, notification)
+	default:
+		err = fmt.Errorf("unsupported notification type %q", notification.Type)
+	}
+
+	if err != nil {
+		s.logger.WithError(err).WithField("type", notification.Type).Error("Failed to send notification")
+		return err
+	}
+
+	return nil
+}
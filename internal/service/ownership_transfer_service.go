@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	auditEntityAccount           = "account"
+	auditEntityOwnershipTransfer = "ownership_transfer"
+)
+
+// OwnershipTransferService handles the maker-checker workflow for moving accounts between users
+type OwnershipTransferService struct {
+	transferRepo        *repository.OwnershipTransferRepository
+	accountRepo         repository.AccountStore
+	cardRepo            repository.CardStore
+	userRepo            repository.UserStore
+	auditRepo           *repository.AuditRepository
+	notificationService *NotificationService
+	logger              *logrus.Logger
+}
+
+// NewOwnershipTransferService creates a new OwnershipTransferService instance
+func NewOwnershipTransferService(
+	transferRepo *repository.OwnershipTransferRepository,
+	accountRepo repository.AccountStore,
+	cardRepo repository.CardStore,
+	userRepo repository.UserStore,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	logger *logrus.Logger,
+) *OwnershipTransferService {
+	return &OwnershipTransferService{
+		transferRepo:        transferRepo,
+		accountRepo:         accountRepo,
+		cardRepo:            cardRepo,
+		userRepo:            userRepo,
+		auditRepo:           auditRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// RequestTransfer registers a pending ownership transfer on behalf of an administrator (the maker)
+func (s *OwnershipTransferService) RequestTransfer(ctx context.Context, adminID int64, req *models.CreateOwnershipTransferRequest) (*models.AccountOwnershipTransfer, error) {
+	if req.Reason != models.OwnershipTransferReasonInheritance && req.Reason != models.OwnershipTransferReasonAssignment {
+		return nil, errors.New("reason must be inheritance or assignment")
+	}
+	if strings.TrimSpace(req.DocumentReference) == "" {
+		return nil, errors.New("document reference is required")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account")
+		return nil, errors.New("account not found")
+	}
+	if account.IsClosed() {
+		return nil, errors.New("account is closed")
+	}
+	if account.UserID == req.ToUserID {
+		return nil, errors.New("account already belongs to the target user")
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, req.ToUserID); err != nil {
+		s.logger.WithError(err).Error("Failed to get target user")
+		return nil, errors.New("target user not found")
+	}
+
+	transfer := &models.AccountOwnershipTransfer{
+		AccountID:         account.ID,
+		FromUserID:        account.UserID,
+		ToUserID:          req.ToUserID,
+		Reason:            req.Reason,
+		DocumentReference: strings.TrimSpace(req.DocumentReference),
+		IncludeCards:      req.IncludeCards,
+		IncludeCredits:    req.IncludeCredits,
+		Status:            models.OwnershipTransferStatusPending,
+		RequestedBy:       adminID,
+	}
+
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.transferRepo.CreateTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("failed to create ownership transfer, a pending transfer may already exist for this account")
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionOwnershipTransferRequested, auditEntityOwnershipTransfer, transfer.ID, transfer)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// ApproveTransfer executes a pending transfer. The approving administrator (the checker)
+// must differ from the one who requested it.
+func (s *OwnershipTransferService) ApproveTransfer(ctx context.Context, adminID, transferID int64, comment string) (*models.AccountOwnershipTransfer, error) {
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := s.transferRepo.GetByIDForUpdate(ctx, tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != models.OwnershipTransferStatusPending {
+		return nil, errors.New("ownership transfer is not pending")
+	}
+	if transfer.RequestedBy == adminID {
+		return nil, errors.New("ownership transfer must be approved by a different administrator")
+	}
+
+	if err := s.completeTransferTx(ctx, tx, adminID, transfer, comment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// completeTransferTx moves the account of an approved transfer to its new owner
+// and records the change. The new owner does not inherit the sharing of the
+// account: its members lose their access, and the cards on it not held by the
+// new owner, those left out of the transfer among them, are blocked.
+func (s *OwnershipTransferService) completeTransferTx(ctx context.Context, tx repository.Tx, adminID int64, transfer *models.AccountOwnershipTransfer, comment string) error {
+	// The account is locked so that no payment runs while it changes hands and
+	// its cached copy with the previous holder is dropped on commit
+	if _, err := s.accountRepo.GetByIDForUpdate(ctx, tx, transfer.AccountID); err != nil {
+		s.logger.WithError(err).Error("Failed to lock transferred account")
+		return errors.New("internal server error")
+	}
+
+	removedMembers, err := s.transferRepo.ReassignTx(ctx, tx, transfer)
+	if err != nil {
+		return err
+	}
+
+	blocked, err := s.cardRepo.BlockByAccountTx(ctx, tx, transfer.AccountID, transfer.ToUserID)
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	if len(blocked) > 0 {
+		details := map[string]interface{}{
+			"transfer_id":  transfer.ID,
+			"from_user_id": transfer.FromUserID,
+			"card_ids":     blocked,
+		}
+		if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionCardsBlocked, auditEntityAccount, transfer.AccountID, details)); err != nil {
+			return errors.New("internal server error")
+		}
+	}
+
+	now := time.Now()
+	transfer.Status = models.OwnershipTransferStatusCompleted
+	transfer.ReviewedBy = &adminID
+	transfer.ReviewComment = comment
+	transfer.ReviewedAt = &now
+
+	if err := s.transferRepo.UpdateReviewTx(ctx, tx, transfer); err != nil {
+		return errors.New("internal server error")
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionOwnershipTransferApproved, auditEntityOwnershipTransfer, transfer.ID, transfer)); err != nil {
+		return errors.New("internal server error")
+	}
+
+	ownershipChange := map[string]interface{}{
+		"transfer_id":        transfer.ID,
+		"from_user_id":       transfer.FromUserID,
+		"to_user_id":         transfer.ToUserID,
+		"reason":             transfer.Reason,
+		"document_reference": transfer.DocumentReference,
+		"include_cards":      transfer.IncludeCards,
+		"include_credits":    transfer.IncludeCredits,
+		"removed_member_ids": removedMembers,
+	}
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionOwnershipChanged, auditEntityAccount, transfer.AccountID, ownershipChange)); err != nil {
+		return errors.New("internal server error")
+	}
+
+	if err := s.notifyPartiesTx(ctx, tx, transfer, removedMembers); err != nil {
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// RejectTransfer closes a pending transfer without moving the account
+func (s *OwnershipTransferService) RejectTransfer(ctx context.Context, adminID, transferID int64, comment string) (*models.AccountOwnershipTransfer, error) {
+	tx, err := s.transferRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := s.transferRepo.GetByIDForUpdate(ctx, tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != models.OwnershipTransferStatusPending {
+		return nil, errors.New("ownership transfer is not pending")
+	}
+
+	now := time.Now()
+	transfer.Status = models.OwnershipTransferStatusRejected
+	transfer.ReviewedBy = &adminID
+	transfer.ReviewComment = comment
+	transfer.ReviewedAt = &now
+
+	if err := s.transferRepo.UpdateReviewTx(ctx, tx, transfer); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.auditRepo.CreateTx(ctx, tx, s.auditEntry(adminID, models.AuditActionOwnershipTransferRejected, auditEntityOwnershipTransfer, transfer.ID, transfer)); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// GetTransfer retrieves an ownership transfer by its ID
+func (s *OwnershipTransferService) GetTransfer(ctx context.Context, transferID int64) (*models.AccountOwnershipTransfer, error) {
+	return s.transferRepo.GetByID(ctx, transferID)
+}
+
+// ListTransfers retrieves ownership transfers, optionally filtered by status
+func (s *OwnershipTransferService) ListTransfers(ctx context.Context, status models.OwnershipTransferStatus) ([]*models.AccountOwnershipTransfer, error) {
+	transfers, err := s.transferRepo.List(ctx, status)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return transfers, nil
+}
+
+// GetOwnershipHistory retrieves the audit trail of ownership changes for an account
+func (s *OwnershipTransferService) GetOwnershipHistory(ctx context.Context, accountID int64) ([]*models.AuditLog, error) {
+	entries, err := s.auditRepo.GetByEntity(ctx, auditEntityAccount, accountID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	var history []*models.AuditLog
+	for _, entry := range entries {
+		if entry.Action == models.AuditActionOwnershipChanged {
+			history = append(history, entry)
+		}
+	}
+
+	return history, nil
+}
+
+func (s *OwnershipTransferService) auditEntry(actorID int64, action models.AuditAction, entityType string, entityID int64, details interface{}) *models.AuditLog {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to marshal audit details")
+	}
+
+	return &models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Details:    raw,
+	}
+}
+
+// notifyPartiesTx queues the notifications of the previous and the new owner and
+// of the removed members in the transfer's transaction; they are sent once it commits
+func (s *OwnershipTransferService) notifyPartiesTx(ctx context.Context, tx repository.Tx, transfer *models.AccountOwnershipTransfer, removedMembers []int64) error {
+	subject := "Account ownership changed"
+
+	fromContent := fmt.Sprintf(
+		"Account #%d has been transferred to another owner (%s, document %s).",
+		transfer.AccountID, transfer.Reason, transfer.DocumentReference,
+	)
+	if err := s.notificationService.NotifyUserTx(ctx, tx, transfer.FromUserID, subject, fromContent); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification of previous owner of account %d", transfer.AccountID)
+		return err
+	}
+
+	toContent := fmt.Sprintf(
+		"Account #%d has been transferred to you (%s, document %s).",
+		transfer.AccountID, transfer.Reason, transfer.DocumentReference,
+	)
+	if err := s.notificationService.NotifyUserTx(ctx, tx, transfer.ToUserID, subject, toContent); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification of new owner of account %d", transfer.AccountID)
+		return err
+	}
+
+	memberContent := fmt.Sprintf("Account #%d has been transferred to another owner and is no longer shared with you.", transfer.AccountID)
+	for _, userID := range removedMembers {
+		if userID == transfer.ToUserID {
+			continue
+		}
+		if err := s.notificationService.NotifyUserTx(ctx, tx, userID, subject, memberContent); err != nil {
+			s.logger.WithError(err).Errorf("Failed to queue notification of member %d of account %d", userID, transfer.AccountID)
+			return err
+		}
+	}
+	return nil
+}
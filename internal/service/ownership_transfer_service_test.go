@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestOwnershipTransferServiceCompleteTransfer(t *testing.T) {
+	tests := []struct {
+		name         string
+		holder       int64 // user holding the card on the account
+		includeCards bool
+		wantStatus   string
+	}{
+		{
+			name:       "blocks the cards left with the previous owner",
+			holder:     7,
+			wantStatus: models.CardStatusBlocked,
+		},
+		{
+			name:         "keeps the cards moved with the account",
+			holder:       7,
+			includeCards: true,
+			wantStatus:   models.CardStatusActive,
+		},
+		{
+			name:       "blocks the cards of a member losing access",
+			holder:     9,
+			wantStatus: models.CardStatusBlocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			tx := env.tx()
+			book := newAccountBook(models.Account{ID: 1, UserID: 7, Balance: 500, Currency: "RUB"})
+			accounts := book.store(tx)
+			accounts.GetCardPaymentTotalTxFunc = func(context.Context, repository.Tx, int64, time.Time) (float64, error) {
+				return 0, nil
+			}
+			card := models.Card{
+				ID: 3, UserID: tt.holder, AccountID: 1, Status: models.CardStatusActive,
+				ExpiryDate: time.Now().AddDate(2, 0, 0).Format("01/06"),
+			}
+			cards := &mock.CardStore{
+				GetByIDFunc: func(context.Context, int64) (*models.Card, error) {
+					card := card
+					return &card, nil
+				},
+				BlockByAccountTxFunc: func(_ context.Context, _ repository.Tx, accountID, keepUserID int64) ([]int64, error) {
+					if accountID != card.AccountID || keepUserID != 8 {
+						t.Errorf("blocked the cards on account %d but those of user %d, want account 1 but user 8", accountID, keepUserID)
+						return nil, nil
+					}
+					// The previous owner's cards were moved with the account
+					if tt.includeCards && card.UserID == 7 {
+						card.UserID = 8
+					}
+					if card.UserID == keepUserID {
+						return nil, nil
+					}
+					card.Status = models.CardStatusBlocked
+					return []int64{card.ID}, nil
+				},
+				UpdateStatusFunc: func(_ context.Context, _ int64, status string) error {
+					card.Status = status
+					return nil
+				},
+			}
+			notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, &mock.UserStore{},
+				repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+			s := NewOwnershipTransferService(repository.NewOwnershipTransferRepository(env.db, env.logger), accounts, cards,
+				&mock.UserStore{}, repository.NewAuditRepository(env.db, env.logger), notificationService, env.logger)
+			transfer := &models.AccountOwnershipTransfer{
+				ID: 5, AccountID: 1, FromUserID: 7, ToUserID: 8,
+				Reason:       models.OwnershipTransferReasonInheritance,
+				IncludeCards: tt.includeCards,
+				Status:       models.OwnershipTransferStatusPending,
+				RequestedBy:  1,
+			}
+
+			if err := s.completeTransferTx(context.Background(), tx, 2, transfer, ""); err != nil {
+				t.Fatalf("completeTransferTx() error = %v", err)
+			}
+			if transfer.Status != models.OwnershipTransferStatusCompleted {
+				t.Errorf("transfer status = %s, want completed", transfer.Status)
+			}
+			if card.Status != tt.wantStatus {
+				t.Errorf("card status = %s, want %s", card.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == models.CardStatusActive {
+				return
+			}
+
+			// The account now belongs to the new owner and is shared with no one,
+			// so the holder can neither pay with the card nor unblock it
+			account := book.accounts[1]
+			account.UserID = 8
+			book.accounts[1] = account
+			purchaseTx := env.tx()
+			accounts.BeginTransactionFunc = beginTx(purchaseTx)
+			cardService := NewCardService(cards, accounts, nil, env.parameterService(), nil, env.publisher(t), env.logger)
+
+			_, err := cardService.Purchase(context.Background(), tt.holder, card.ID, &models.CardPurchaseRequest{
+				Amount:   120,
+				Currency: "RUB",
+				Merchant: "Bookshop",
+			})
+			if err == nil || err.Error() != "card is not active" {
+				t.Fatalf("Purchase() error = %v, want %q", err, "card is not active")
+			}
+			if purchaseTx.Committed || len(book.transactions) != 0 {
+				t.Errorf("refused payment changed state: committed %v, transactions %d", purchaseTx.Committed, len(book.transactions))
+			}
+
+			err = cardService.UnblockCard(context.Background(), tt.holder, card.ID)
+			if want := "unauthorized: account does not belong to user"; err == nil || err.Error() != want {
+				t.Fatalf("UnblockCard() error = %v, want %q", err, want)
+			}
+			if card.Status != models.CardStatusBlocked {
+				t.Errorf("card status after the refused unblock = %s, want blocked", card.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// parameterDefinition describes a business parameter, the value used while no
+// version is configured and the range of values it accepts
+type parameterDefinition struct {
+	description  string
+	defaultValue float64
+	min, max     float64
+}
+
+// ParameterService serves administrator-managed business parameters. Versions are
+// cached in memory: changes made through this instance drop the cache at once,
+// changes made by other instances are picked up when the cache expires.
+type ParameterService struct {
+	repo        *repository.ParameterRepository
+	auditRepo   *repository.AuditRepository
+	definitions map[string]parameterDefinition
+	cacheTTL    time.Duration
+	logger      *logrus.Logger
+
+	mu       sync.RWMutex
+	versions map[string][]*models.BusinessParameter
+	loadedAt time.Time
+}
+
+// NewParameterService creates a new ParameterService instance. Values from the
+// configuration serve as defaults for parameters without a configured version.
+func NewParameterService(
+	repo *repository.ParameterRepository,
+	auditRepo *repository.AuditRepository,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *ParameterService {
+	return &ParameterService{
+		repo:      repo,
+		auditRepo: auditRepo,
+		definitions: map[string]parameterDefinition{
+			models.ParamAccountDormancyMonths:           {"Months without activity after which an account becomes dormant", 12, 1, 120},
+			models.ParamAccountDormancyFee:              {"Monthly fee charged on dormant accounts in their currency, 0 disables it", 0, 0, 1e6},
+			models.ParamCardFXMarkupPercent:             {"Card scheme markup on foreign currency payments, %", cfg.Card.FXMarkupPercent, 0, 100},
+			models.ParamCreditEarlyRepaymentFeePercent:  {"Fee on credit principal repaid early, %", cfg.Credit.EarlyRepaymentFeePercent, 0, 100},
+			models.ParamCreditInterestRatePercent:       {"Annual interest rate of new credits, %", cfg.Credit.InterestRatePercent, 0, 100},
+			models.ParamCreditPenaltyRatePercent:        {"Annual penalty rate accrued daily on overdue installments, %", cfg.Credit.PenaltyRatePercent, 0, 100},
+			models.ParamCreditPaymentGraceDays:          {"Days an installment may be paid late and still count as on time", 3, 0, 31},
+			models.ParamCreditDelinquencyDays:           {"Days overdue after which a credit becomes delinquent", 30, 1, 365},
+			models.ParamCreditCollectionsDays:           {"Days overdue after which a delinquent credit goes to collections", 90, 1, 730},
+			models.ParamCreditCollectionFeePercent:      {"Collection fee charged on the overdue amount at each escalation, %", 5, 0, 100},
+			models.ParamCreditCollectionNoticeDays:      {"Days between reminders to borrowers of delinquent credits", 7, 1, 90},
+			models.ParamCreditVariableRateMarginPercent: {"Margin over the CBR key rate of new variable-rate credits, %", cfg.Credit.VariableRateMarginPercent, 0, 100},
+			models.ParamCreditInsuranceRatePercent:      {"Annual payment protection insurance premium of new credits, % of the amount", 1.2, 0, 20},
+			models.ParamDepositInterestRatePercent:      {"Annual interest rate of new fixed-rate term deposits, %", 12, 0, 100},
+			models.ParamDepositKeyRateMarginPercent:     {"Margin over the CBR key rate of new key-rate term deposits, %", -2, -20, 20},
+			models.ParamDepositEarlyWithdrawalRate:      {"Annual interest rate paid on term deposits withdrawn before maturity, %", 0.01, 0, 100},
+			models.ParamDepositMinAmount:                {"Minimum amount of a term deposit", 1000, 0, 1e9},
+			models.ParamScoringApproveScore:             {"Minimum score for automatic credit approval", 70, 0, 100},
+			models.ParamScoringRejectScore:              {"Score below which credit applications are rejected", 40, 0, 100},
+			models.ParamScoringReviewDebtToIncome:       {"Debt-to-income ratio above which applications need manual review", 0.35, 0, 1},
+			models.ParamScoringMaxDebtToIncome:          {"Debt-to-income ratio above which applications are rejected", 0.5, 0, 1},
+			models.ParamScoringRateSpreadPercent:        {"Spread between the credit rates of the lowest and highest credit scores, %", 4, 0, 20},
+		},
+		cacheTTL: cfg.Parameters.CacheTTL,
+		logger:   logger,
+	}
+}
+
+// Get returns the value of a parameter in force at the given time together with
+// the version it comes from, so callers can record it with the operation
+func (s *ParameterService) Get(ctx context.Context, key string, at time.Time) models.AppliedParameter {
+	definition, ok := s.definitions[key]
+	if !ok {
+		s.logger.Errorf("Unknown business parameter %s", key)
+	}
+
+	applied := models.AppliedParameter{Key: key, Value: definition.defaultValue}
+	if version := versionAt(s.cachedVersions(ctx)[key], at); version != nil {
+		id := version.ID
+		applied.Value = version.Value
+		applied.VersionID = &id
+	}
+
+	return applied
+}
+
+// List returns every parameter with its current value and the versions scheduled to replace it
+func (s *ParameterService) List(ctx context.Context, now time.Time) []*models.ParameterValue {
+	versions := s.cachedVersions(ctx)
+
+	values := make([]*models.ParameterValue, 0, len(s.definitions))
+	for key, definition := range s.definitions {
+		value := &models.ParameterValue{
+			Key:          key,
+			Description:  definition.description,
+			Value:        definition.defaultValue,
+			DefaultValue: definition.defaultValue,
+		}
+		for _, version := range versions[key] {
+			if version.EffectiveFrom.After(now) {
+				value.Scheduled = append(value.Scheduled, version)
+				continue
+			}
+			id, effectiveFrom := version.ID, version.EffectiveFrom
+			value.Value = version.Value
+			value.VersionID = &id
+			value.EffectiveFrom = &effectiveFrom
+		}
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// History returns every version of a parameter, oldest first
+func (s *ParameterService) History(ctx context.Context, key string) ([]*models.BusinessParameter, error) {
+	if _, ok := s.definitions[key]; !ok {
+		return nil, errors.New("unknown parameter")
+	}
+
+	versions, err := s.repo.GetByKey(ctx, key)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return versions, nil
+}
+
+// SetParameter schedules a new version of a parameter. Versions cannot take effect
+// in the past, so operations already performed keep the values they were priced with.
+func (s *ParameterService) SetParameter(ctx context.Context, adminID int64, key string, req *models.CreateBusinessParameterRequest) (*models.BusinessParameter, error) {
+	definition, ok := s.definitions[key]
+	if !ok {
+		return nil, errors.New("unknown parameter")
+	}
+	if req.Value < definition.min || req.Value > definition.max {
+		return nil, fmt.Errorf("%s must be between %g and %g", key, definition.min, definition.max)
+	}
+
+	now := time.Now()
+	effectiveFrom := now
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.Before(now) {
+			return nil, errors.New("effective date cannot be in the past")
+		}
+		effectiveFrom = *req.EffectiveFrom
+	}
+
+	param := &models.BusinessParameter{
+		Key:           key,
+		Value:         req.Value,
+		EffectiveFrom: effectiveFrom,
+		Comment:       req.Comment,
+		CreatedBy:     adminID,
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.CreateTx(ctx, tx, param); err != nil {
+		return nil, err
+	}
+
+	previous := s.Get(ctx, key, effectiveFrom)
+	details, _ := json.Marshal(map[string]interface{}{
+		"key":            key,
+		"value":          param.Value,
+		"previous_value": previous.Value,
+		"effective_from": param.EffectiveFrom,
+		"comment":        param.Comment,
+	})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionParameterChanged,
+		EntityType: "business_parameter",
+		EntityID:   param.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.Invalidate()
+
+	return param, nil
+}
+
+// Invalidate drops the cached versions so the next lookup reloads them
+func (s *ParameterService) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions = nil
+}
+
+// cachedVersions returns the parameter versions by key, reloading them once the
+// cache has expired. If the reload fails the stale versions keep being served.
+func (s *ParameterService) cachedVersions(ctx context.Context) map[string][]*models.BusinessParameter {
+	s.mu.RLock()
+	versions := s.versions
+	fresh := versions != nil && time.Since(s.loadedAt) < s.cacheTTL
+	s.mu.RUnlock()
+	if fresh {
+		return versions
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions != nil && time.Since(s.loadedAt) < s.cacheTTL {
+		return s.versions
+	}
+
+	params, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load business parameters")
+		return s.versions
+	}
+
+	loaded := make(map[string][]*models.BusinessParameter)
+	for _, param := range params {
+		loaded[param.Key] = append(loaded[param.Key], param)
+	}
+	s.versions = loaded
+	s.loadedAt = time.Now()
+
+	return loaded
+}
+
+// versionAt picks the latest version effective at the given time from versions
+// ordered by effective date
+func versionAt(versions []*models.BusinessParameter, at time.Time) *models.BusinessParameter {
+	var current *models.BusinessParameter
+	for _, version := range versions {
+		if version.EffectiveFrom.After(at) {
+			break
+		}
+		current = version
+	}
+	return current
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/integration/hibp"
+	"github.com/sirupsen/logrus"
+)
+
+// identityMinLength is the shortest username or email local part a password
+// is checked for; shorter ones would refuse too many passwords by chance
+const identityMinLength = 3
+
+// PasswordPolicy checks new passwords against the configured rules and,
+// optionally, against the passwords known from data breaches
+type PasswordPolicy struct {
+	cfg      *config.PasswordPolicyConfig
+	breaches *hibp.Client
+	logger   *logrus.Logger
+}
+
+// NewPasswordPolicy creates a new PasswordPolicy instance
+func NewPasswordPolicy(cfg *config.PasswordPolicyConfig, logger *logrus.Logger) *PasswordPolicy {
+	policy := &PasswordPolicy{
+		cfg:    cfg,
+		logger: logger,
+	}
+	if cfg.BreachCheck {
+		policy.breaches = hibp.NewClient(cfg.BreachCheckURL, cfg.BreachCheckTimeout)
+	}
+	return policy
+}
+
+// Check returns the first rule a new password of the user with the given
+// username and email breaks. The breach check runs last and lets the password
+// through when the service cannot be reached.
+func (p *PasswordPolicy) Check(ctx context.Context, password, username, email string) error {
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.cfg.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUppercase && !upper {
+		return errors.New("password must contain an uppercase letter")
+	}
+	if p.cfg.RequireLowercase && !lower {
+		return errors.New("password must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		return errors.New("password must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		return errors.New("password must contain a symbol")
+	}
+
+	if p.cfg.DisallowIdentity {
+		lowered := strings.ToLower(password)
+		localPart, _, _ := strings.Cut(email, "@")
+		for _, identity := range []string{username, localPart} {
+			identity = strings.ToLower(strings.TrimSpace(identity))
+			if utf8.RuneCountInString(identity) >= identityMinLength && strings.Contains(lowered, identity) {
+				return errors.New("password must not contain the username or email")
+			}
+		}
+	}
+
+	if p.breaches != nil {
+		breached, err := p.breaches.IsBreached(ctx, password)
+		if err != nil {
+			p.logger.WithError(err).Warn("Failed to check password against known breaches")
+			return nil
+		}
+		if breached {
+			return errors.New("password has appeared in a data breach, choose another one")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	// The range API answers with the suffixes of the hashes sharing the prefix;
+	// "Password123" has the SHA-1 hash B2E98AD6F6EB8508DD6A14CFA704BAD7F05F6FB1
+	rangeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/range/B2E98" {
+			fmt.Fprintln(w, "0000000000000000000000000000000000A:0")
+			return
+		}
+		fmt.Fprintln(w, "AD6F6EB8508DD6A14CFA704BAD7F05F6FB1:251682")
+		fmt.Fprintln(w, "0000000000000000000000000000000000B:0")
+	}))
+	defer rangeAPI.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.DefaultConfig().PasswordPolicy
+	cfg.BreachCheck = true
+	cfg.BreachCheckURL = rangeAPI.URL
+	policy := NewPasswordPolicy(&cfg, logger)
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{name: "meets the policy", password: "Tr0ub4dor&3x"},
+		{name: "too short", password: "Ab1", wantErr: "password must be at least 8 characters long"},
+		{name: "no uppercase letter", password: "tr0ub4dor&3x", wantErr: "password must contain an uppercase letter"},
+		{name: "no digit", password: "Troubador&x", wantErr: "password must contain a digit"},
+		{name: "contains the username", password: "MyJohnDoe2024", wantErr: "password must not contain the username or email"},
+		{name: "contains the email local part", password: "Jdoe.Mail99", wantErr: "password must not contain the username or email"},
+		{name: "breached", password: "Password123", wantErr: "password has appeared in a data breach, choose another one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(context.Background(), tt.password, "johndoe", "jdoe.mail@example.com")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// payeeCodeLength is the number of digits of a payee confirmation code
+const payeeCodeLength = 6
+
+// PayeeService manages the payees users save and the transfers made to them.
+// The first transfer to a payee, and the first after its payment details
+// change, is confirmed with a code emailed to the user.
+type PayeeService struct {
+	repo                    *repository.PayeeRepository
+	accountRepo             repository.AccountStore
+	cardRepo                repository.CardStore
+	accountService          *AccountService
+	externalTransferService *ExternalTransferService
+	auditRepo               *repository.AuditRepository
+	notificationService     *NotificationService
+	cfg                     *config.PayeesConfig
+	logger                  *logrus.Logger
+}
+
+// NewPayeeService creates a new PayeeService instance
+func NewPayeeService(
+	repo *repository.PayeeRepository,
+	accountRepo repository.AccountStore,
+	cardRepo repository.CardStore,
+	accountService *AccountService,
+	externalTransferService *ExternalTransferService,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	cfg *config.PayeesConfig,
+	logger *logrus.Logger,
+) *PayeeService {
+	return &PayeeService{
+		repo:                    repo,
+		accountRepo:             accountRepo,
+		cardRepo:                cardRepo,
+		accountService:          accountService,
+		externalTransferService: externalTransferService,
+		auditRepo:               auditRepo,
+		notificationService:     notificationService,
+		cfg:                     cfg,
+		logger:                  logger,
+	}
+}
+
+// CreatePayee saves a payee for the user after checking its payment details
+func (s *PayeeService) CreatePayee(ctx context.Context, userID int64, req *models.PayeeRequest) (*models.Payee, error) {
+	payee, err := s.buildPayee(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, payee); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return payee, nil
+}
+
+// GetPayee retrieves a payee of the user
+func (s *PayeeService) GetPayee(ctx context.Context, userID, id int64) (*models.Payee, error) {
+	payee, err := s.repo.GetByID(ctx, userID, id)
+	if err != nil {
+		if err.Error() == "payee not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return payee, nil
+}
+
+// ListPayees retrieves a page of the payees of the user and how many there are
+func (s *PayeeService) ListPayees(ctx context.Context, userID int64, p pagination.Params) ([]*models.Payee, int, error) {
+	payees, total, err := s.repo.List(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return payees, total, nil
+}
+
+// UpdatePayee replaces the name and payment details of a payee of the user. A
+// payee whose payment details change has to be confirmed again.
+func (s *PayeeService) UpdatePayee(ctx context.Context, userID, id int64, req *models.PayeeRequest) (*models.Payee, error) {
+	payee, err := s.GetPayee(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.buildPayee(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	updated.ID = payee.ID
+	updated.CreatedAt = payee.CreatedAt
+	updated.LastUsedAt = payee.LastUsedAt
+	if payee.SamePaymentDetails(updated) {
+		updated.ConfirmedAt = payee.ConfirmedAt
+	}
+
+	if err := s.repo.Update(ctx, updated); err != nil {
+		if err.Error() == "payee not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	return updated, nil
+}
+
+// DeletePayee removes a payee of the user. The transfers made to it stay in the
+// transfer analytics.
+func (s *PayeeService) DeletePayee(ctx context.Context, userID, id int64) error {
+	if err := s.repo.Delete(ctx, userID, id); err != nil {
+		if err.Error() == "payee not found" {
+			return err
+		}
+		return errors.New("internal server error")
+	}
+	return nil
+}
+
+// buildPayee checks the payment details of a payee of the given type: an
+// account or a card must be at the bank, and an account at another bank needs
+// the beneficiary name, the BIC and either an IBAN or an account number
+func (s *PayeeService) buildPayee(ctx context.Context, userID int64, req *models.PayeeRequest) (*models.Payee, error) {
+	payee := &models.Payee{
+		UserID:          userID,
+		Name:            strings.TrimSpace(req.Name),
+		Type:            req.Type,
+		AccountNumber:   strings.TrimSpace(req.AccountNumber),
+		CardNumber:      strings.TrimSpace(req.CardNumber),
+		BeneficiaryName: strings.TrimSpace(req.BeneficiaryName),
+		IBAN:            models.NormalizeIBAN(req.IBAN),
+		BIC:             strings.ToUpper(strings.TrimSpace(req.BIC)),
+	}
+	if payee.Name == "" {
+		return nil, errors.New("payee name is required")
+	}
+
+	switch payee.Type {
+	case models.PayeeTypeAccount:
+		if payee.CardNumber != "" || payee.IBAN != "" || payee.BIC != "" || payee.BeneficiaryName != "" {
+			return nil, errors.New("an account payee only has an account number")
+		}
+		if len(payee.AccountNumber) != models.AccountNumberLength {
+			return nil, fmt.Errorf("account number must have %d digits", models.AccountNumberLength)
+		}
+		if _, err := s.accountRepo.GetByNumber(ctx, payee.AccountNumber); err != nil {
+			return nil, errors.New("account not found")
+		}
+	case models.PayeeTypeCard:
+		if payee.AccountNumber != "" || payee.IBAN != "" || payee.BIC != "" || payee.BeneficiaryName != "" {
+			return nil, errors.New("a card payee only has a card number")
+		}
+		if !models.LuhnCheck(payee.CardNumber) {
+			return nil, errors.New("invalid card number")
+		}
+		card, err := s.cardRepo.GetByNumber(ctx, payee.CardNumber)
+		if err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if card == nil {
+			return nil, errors.New("card not found")
+		}
+	case models.PayeeTypeExternal:
+		if payee.CardNumber != "" {
+			return nil, errors.New("a payee at another bank has no card number")
+		}
+		if payee.BeneficiaryName == "" || payee.BIC == "" {
+			return nil, errors.New("beneficiary name and BIC are required")
+		}
+		if (payee.IBAN == "") == (payee.AccountNumber == "") {
+			return nil, errors.New("either an IBAN or an account number is required")
+		}
+		if payee.IBAN != "" && !models.ValidIBAN(payee.IBAN) {
+			return nil, errors.New("invalid IBAN")
+		}
+	default:
+		return nil, errors.New("invalid payee type")
+	}
+	return payee, nil
+}
+
+// Transfer moves money from an account the user operates to a payee. While the
+// payee is not confirmed, a transfer without a code emails a new one to the
+// user and is not made; the code makes the payee confirmed and the transfer
+// goes ahead. Transfers to another bank are returned while they are pending.
+func (s *PayeeService) Transfer(ctx context.Context, userID, id int64, req *models.PayeeTransferRequest, client models.ClientInfo) (*models.PayeeTransferResult, error) {
+	payee, err := s.GetPayee(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if payee.ConfirmedAt == nil {
+		if req.ConfirmationCode == "" {
+			return s.sendCode(ctx, payee)
+		}
+		if payee, err = s.confirm(ctx, userID, id, req.ConfirmationCode); err != nil {
+			return nil, err
+		}
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.FromAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+
+	result := &models.PayeeTransferResult{Status: models.PayeeTransferStatusCompleted, Payee: payee}
+	var externalTransferID *int64
+	switch payee.Type {
+	case models.PayeeTypeAccount:
+		err = s.accountService.Transfer(ctx, &models.TransferRequest{
+			FromAccountID:   req.FromAccountID,
+			ToAccountNumber: payee.AccountNumber,
+			Amount:          req.Amount,
+		}, client)
+	case models.PayeeTypeCard:
+		card, cardErr := s.cardRepo.GetByNumber(ctx, payee.CardNumber)
+		if cardErr != nil {
+			return nil, errors.New("internal server error")
+		}
+		if card == nil {
+			return nil, errors.New("card not found")
+		}
+		if card.Status != models.CardStatusActive {
+			return nil, errors.New("card is not active")
+		}
+		if card.AccountID == req.FromAccountID {
+			return nil, errors.New("cannot transfer to the same account")
+		}
+		err = s.accountService.Transfer(ctx, &models.TransferRequest{
+			FromAccountID: req.FromAccountID,
+			ToAccountID:   card.AccountID,
+			Amount:        req.Amount,
+		}, client)
+	default:
+		result.ExternalTransfer, err = s.externalTransferService.CreateTransfer(ctx, userID, &models.CreateExternalTransferRequest{
+			AccountID:       req.FromAccountID,
+			BeneficiaryName: payee.BeneficiaryName,
+			IBAN:            payee.IBAN,
+			AccountNumber:   payee.AccountNumber,
+			BIC:             payee.BIC,
+			Amount:          req.Amount,
+			Reference:       req.Reference,
+		})
+		if err == nil {
+			externalTransferID = &result.ExternalTransfer.ID
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The transfer is made, so failing to count it in the analytics only gets logged
+	if err := s.repo.RecordTransfer(ctx, payee, account.ID, externalTransferID, req.Amount, account.Currency); err != nil {
+		s.logger.WithError(err).WithField("payee_id", payee.ID).Error("Failed to record transfer to payee")
+	}
+	return result, nil
+}
+
+// sendCode emails a new confirmation code for the first transfer to a payee,
+// replacing any code sent before
+func (s *PayeeService) sendCode(ctx context.Context, payee *models.Payee) (*models.PayeeTransferResult, error) {
+	code := randomDigits(payeeCodeLength)
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	expiresAt := time.Now().Add(s.cfg.CodeTTL)
+	if err := s.repo.SetCode(ctx, payee.ID, string(hash), expiresAt); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	content := fmt.Sprintf("Your code to confirm the first transfer to %s is %s. It is valid for %d minutes. "+
+		"If you did not start this transfer, do not share the code and contact the bank.",
+		payee.Name, code, int(s.cfg.CodeTTL.Minutes()))
+	if err := s.notificationService.EmailUser(ctx, payee.UserID, "Confirm transfer to a new payee", content, nil); err != nil {
+		s.logger.WithError(err).WithField("payee_id", payee.ID).Error("Failed to send payee confirmation code")
+		return nil, errors.New("failed to send confirmation code")
+	}
+
+	return &models.PayeeTransferResult{
+		Status:        models.PayeeTransferStatusConfirmationRequired,
+		Payee:         payee,
+		CodeExpiresAt: &expiresAt,
+	}, nil
+}
+
+// confirm checks a confirmation code of a payee and marks the payee confirmed.
+// Wrong codes are counted, and once there are too many or the code expires a
+// new one has to be requested.
+func (s *PayeeService) confirm(ctx context.Context, userID, id int64, code string) (*models.Payee, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payee, err := s.repo.GetByIDForUpdate(ctx, tx, userID, id)
+	if err != nil {
+		if err.Error() == "payee not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if payee.ConfirmedAt != nil {
+		return payee, nil
+	}
+	if payee.CodeHash == "" || payee.CodeExpiresAt == nil || time.Now().After(*payee.CodeExpiresAt) {
+		return nil, errors.New("confirmation code expired, request a new one")
+	}
+	if payee.CodeAttempts >= s.cfg.MaxCodeAttempts {
+		return nil, errors.New("too many wrong confirmation codes, request a new one")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(payee.CodeHash), []byte(code)) != nil {
+		if err := s.repo.RecordCodeAttemptTx(ctx, tx, payee.ID); err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, errors.New("invalid confirmation code")
+	}
+
+	if err := s.repo.ConfirmTx(ctx, tx, payee); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	details, _ := json.Marshal(map[string]interface{}{"type": payee.Type})
+	if err := s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+		ActorID:    userID,
+		Action:     models.AuditActionPayeeConfirmed,
+		EntityType: "payee",
+		EntityID:   payee.ID,
+		Details:    details,
+	}); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return payee, nil
+}
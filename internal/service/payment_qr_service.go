@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// paymentQRMaxPurpose is the longest purpose of payment the QR format allows
+const paymentQRMaxPurpose = 210
+
+// PaymentQRService issues payment QR codes for accounts and makes the transfers
+// scanned codes ask for. The codes carry ruble payment details only, so both
+// sides of a QR payment are RUB accounts.
+type PaymentQRService struct {
+	accountRepo             repository.AccountStore
+	userRepo                repository.UserStore
+	accountService          *AccountService
+	externalTransferService *ExternalTransferService
+	bank                    *config.BankConfig
+	logger                  *logrus.Logger
+}
+
+// NewPaymentQRService creates a new PaymentQRService instance
+func NewPaymentQRService(
+	accountRepo repository.AccountStore,
+	userRepo repository.UserStore,
+	accountService *AccountService,
+	externalTransferService *ExternalTransferService,
+	bank *config.BankConfig,
+	logger *logrus.Logger,
+) *PaymentQRService {
+	return &PaymentQRService{
+		accountRepo:             accountRepo,
+		userRepo:                userRepo,
+		accountService:          accountService,
+		externalTransferService: externalTransferService,
+		bank:                    bank,
+		logger:                  logger,
+	}
+}
+
+// GenerateQR returns the payment QR code of an account the user may see. The
+// amount and comment are optional; a code without an amount lets the payer
+// enter one.
+func (s *PaymentQRService) GenerateQR(ctx context.Context, userID, accountID int64, amount float64, comment string) (*models.PaymentQR, error) {
+	if amount < 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	comment = strings.TrimSpace(comment)
+	if utf8.RuneCountInString(comment) > paymentQRMaxPurpose {
+		return nil, errors.New("comment is too long")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, false); err != nil {
+		return nil, err
+	}
+	if account.Currency != "RUB" {
+		return nil, errors.New("QR payments are only available for RUB accounts")
+	}
+	if account.Number == "" {
+		return nil, errors.New("account has no number yet")
+	}
+
+	holder, err := s.userRepo.GetByID(ctx, account.UserID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get account holder")
+		return nil, errors.New("internal server error")
+	}
+
+	qr := &models.PaymentQR{
+		Name:                 strings.TrimSpace(holder.FirstName + " " + holder.LastName),
+		AccountNumber:        account.Number,
+		BankName:             s.bank.Name,
+		BIC:                  s.bank.BIC,
+		CorrespondentAccount: s.bank.CorrespondentAccount,
+		Amount:               amount,
+		Purpose:              comment,
+	}
+	qr.Payload = models.EncodePaymentQR(qr)
+	return qr, nil
+}
+
+// Transfer pays the payment details of a scanned QR code from an account the
+// user may operate. Details at this bank are paid by an internal transfer,
+// details at another bank by a transfer to it.
+func (s *PaymentQRService) Transfer(ctx context.Context, userID int64, req *models.QRTransferRequest, client models.ClientInfo) (*models.QRTransferResult, error) {
+	qr, err := models.ParsePaymentQR(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := qr.Amount
+	switch {
+	case amount == 0:
+		amount = req.Amount
+	case req.Amount != 0 && req.Amount != amount:
+		return nil, errors.New("amount does not match the QR code")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount is required")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, req.FromAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+	if account.Currency != "RUB" {
+		return nil, errors.New("QR payments are only available for RUB accounts")
+	}
+
+	result := &models.QRTransferResult{QR: qr, Amount: amount}
+	if qr.BIC == s.bank.BIC {
+		err = s.accountService.Transfer(ctx, &models.TransferRequest{
+			FromAccountID:   req.FromAccountID,
+			ToAccountNumber: qr.AccountNumber,
+			Amount:          amount,
+		}, client)
+	} else {
+		reference := qr.Purpose
+		if runes := []rune(reference); len(runes) > 140 {
+			reference = string(runes[:140])
+		}
+		result.ExternalTransfer, err = s.externalTransferService.CreateTransfer(ctx, userID, &models.CreateExternalTransferRequest{
+			AccountID:       req.FromAccountID,
+			BeneficiaryName: qr.Name,
+			AccountNumber:   qr.AccountNumber,
+			BIC:             qr.BIC,
+			Amount:          amount,
+			Reference:       reference,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
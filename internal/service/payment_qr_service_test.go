@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func newPaymentQRTestService(t *testing.T) *PaymentQRService {
+	t.Helper()
+	env := newTestEnv(t)
+	accounts := map[int64]*models.Account{
+		1: {ID: 1, UserID: 7, Number: "40817810000000000011", Currency: "RUB"},
+		2: {ID: 2, UserID: 7, Number: "40817840000000000022", Currency: "USD"},
+	}
+	accountStore := &mock.AccountStore{
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Account, error) {
+			account, ok := accounts[id]
+			if !ok {
+				return nil, errors.New("account not found")
+			}
+			copied := *account
+			return &copied, nil
+		},
+		GetMemberRoleFunc: func(context.Context, int64, int64) (models.AccountRole, error) {
+			return "", errors.New("not a member")
+		},
+	}
+	userStore := &mock.UserStore{
+		GetByIDFunc: func(_ context.Context, id int64) (*models.User, error) {
+			return &models.User{ID: id, FirstName: "Ivan", LastName: "Petrov"}, nil
+		},
+	}
+	return NewPaymentQRService(accountStore, userStore, nil, nil, &env.cfg.Bank, env.logger)
+}
+
+func TestPaymentQRServiceGenerateQR(t *testing.T) {
+	s := newPaymentQRTestService(t)
+
+	qr, err := s.GenerateQR(context.Background(), 7, 1, 1234.5, "Dinner | split")
+	if err != nil {
+		t.Fatalf("generate QR: %v", err)
+	}
+	want := "ST00012|Name=Ivan Petrov|PersonalAcc=40817810000000000011|BankName=Abi Bank|BIC=044525999|CorrespAcc=0|Sum=123450|Purpose=Dinner   split"
+	if qr.Payload != want {
+		t.Fatalf("payload %q, want %q", qr.Payload, want)
+	}
+
+	parsed, err := models.ParsePaymentQR(qr.Payload)
+	if err != nil {
+		t.Fatalf("parse QR: %v", err)
+	}
+	if parsed.AccountNumber != qr.AccountNumber || parsed.BIC != qr.BIC || parsed.Amount != 1234.5 || parsed.CorrespondentAccount != "" {
+		t.Errorf("parsed %+v does not match %+v", parsed, qr)
+	}
+
+	if _, err := s.GenerateQR(context.Background(), 8, 1, 0, ""); err == nil {
+		t.Error("QR code of another user's account was generated")
+	}
+	if _, err := s.GenerateQR(context.Background(), 7, 2, 0, ""); err == nil {
+		t.Error("QR code of a USD account was generated")
+	}
+}
+
+func TestParsePaymentQRRejectsInvalidPayloads(t *testing.T) {
+	for _, payload := range []string{
+		"",
+		"hello",
+		"ST00011|Name=Ivan|PersonalAcc=40817810000000000011|BIC=044525999",
+		"ST00012|Name=Ivan|BIC=044525999",
+		"ST00012|Name=Ivan|PersonalAcc=408178|BIC=044525999",
+		"ST00012|Name=Ivan|PersonalAcc=40817810000000000011|BIC=044525999|Sum=ten",
+	} {
+		if _, err := models.ParsePaymentQR(payload); err == nil {
+			t.Errorf("payload %q was accepted", payload)
+		}
+	}
+
+	// Other apps may pick another separator
+	qr, err := models.ParsePaymentQR("ST00012#Name=Ivan#PersonalAcc=40817810000000000011#BIC=044525999#Sum=500")
+	if err != nil {
+		t.Fatalf("parse QR: %v", err)
+	}
+	if qr.Amount != 5 {
+		t.Errorf("amount %v, want 5", qr.Amount)
+	}
+}
@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// PaymentRequestService handles requests for money between users. The payer
+// accepts a request by paying it from one of their accounts, which transfers
+// the amount and marks the request paid in one database transaction.
+type PaymentRequestService struct {
+	repo                *repository.PaymentRequestRepository
+	accountRepo         repository.AccountStore
+	userRepo            repository.UserStore
+	accountService      *AccountService
+	notificationService *NotificationService
+	cfg                 *config.PaymentRequestsConfig
+	logger              *logrus.Logger
+}
+
+// NewPaymentRequestService creates a new PaymentRequestService instance
+func NewPaymentRequestService(
+	repo *repository.PaymentRequestRepository,
+	accountRepo repository.AccountStore,
+	userRepo repository.UserStore,
+	accountService *AccountService,
+	notificationService *NotificationService,
+	cfg *config.PaymentRequestsConfig,
+	logger *logrus.Logger,
+) *PaymentRequestService {
+	return &PaymentRequestService{
+		repo:                repo,
+		accountRepo:         accountRepo,
+		userRepo:            userRepo,
+		accountService:      accountService,
+		notificationService: notificationService,
+		cfg:                 cfg,
+		logger:              logger,
+	}
+}
+
+// CreateRequest asks the user with the given email or phone number for money,
+// to be paid to an account the requester operates
+func (s *PaymentRequestService) CreateRequest(ctx context.Context, userID int64, req *models.CreatePaymentRequestRequest) (*models.PaymentRequest, error) {
+	account, err := s.accountRepo.GetByID(ctx, req.AccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	var payer *models.User
+	if email := strings.TrimSpace(req.Email); email != "" {
+		payer, err = s.userRepo.GetByEmail(ctx, email)
+	} else {
+		payer, err = s.userRepo.GetByPhone(ctx, strings.TrimSpace(req.Phone))
+	}
+	if err != nil || payer.Status != models.StatusActive {
+		return nil, errors.New("user not found")
+	}
+	if payer.ID == userID {
+		return nil, errors.New("cannot request money from yourself")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.TTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, errors.New("expiry must be in the future")
+		}
+		if req.ExpiresAt.After(now.Add(s.cfg.MaxTTL)) {
+			return nil, fmt.Errorf("expiry must be within %d days", int(s.cfg.MaxTTL.Hours()/24))
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	request := &models.PaymentRequest{
+		RequesterID:        userID,
+		RequesterAccountID: account.ID,
+		PayerID:            payer.ID,
+		Amount:             req.Amount,
+		Currency:           account.Currency,
+		Description:        strings.TrimSpace(req.Description),
+		Status:             models.PaymentRequestStatusPending,
+		ExpiresAt:          expiresAt,
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.CreateTx(ctx, tx, request); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if err := s.notifyTx(ctx, tx, request); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return request, nil
+}
+
+// GetRequest retrieves a payment request the user sent or received
+func (s *PaymentRequestService) GetRequest(ctx context.Context, userID, id int64) (*models.PaymentRequest, error) {
+	request, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err.Error() == "payment request not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if request.RequesterID != userID && request.PayerID != userID {
+		return nil, errors.New("payment request not found")
+	}
+	return request, nil
+}
+
+// ListReceived retrieves a page of the payment requests sent to the user and
+// how many there are. Pending ones wait for the user to pay or decline them.
+func (s *PaymentRequestService) ListReceived(ctx context.Context, userID int64, p pagination.Params) ([]*models.PaymentRequest, int, error) {
+	requests, total, err := s.repo.ListByPayer(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return requests, total, nil
+}
+
+// ListSent retrieves a page of the payment requests the user sent and how many there are
+func (s *PaymentRequestService) ListSent(ctx context.Context, userID int64, p pagination.Params) ([]*models.PaymentRequest, int, error) {
+	requests, total, err := s.repo.ListByRequester(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return requests, total, nil
+}
+
+// AcceptRequest pays a pending request sent to the user from an account they
+// operate. The transfer is screened by the fraud rules like any other; when it
+// is held the request is held with it and the *fraud.HoldError is returned.
+func (s *PaymentRequestService) AcceptRequest(ctx context.Context, userID, id int64, req *models.AcceptPaymentRequestRequest, client models.ClientInfo) (*models.PaymentRequest, error) {
+	account, err := s.accountRepo.GetByID(ctx, req.FromAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, account, userID, true); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	request, err := s.lockPending(ctx, tx, userID, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(request.ExpiresAt) {
+		if err := s.closeTx(ctx, tx, request, models.PaymentRequestStatusExpired, ""); err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, errors.New("payment request has expired")
+	}
+	if account.Currency != request.Currency {
+		return nil, errors.New("currency mismatch between accounts")
+	}
+	if account.ID == request.RequesterAccountID {
+		return nil, errors.New("cannot transfer to the same account")
+	}
+
+	request.PayerAccountID = &account.ID
+	transaction, err := s.accountService.TransferTx(ctx, tx, &models.TransferRequest{
+		FromAccountID: account.ID,
+		ToAccountID:   request.RequesterAccountID,
+		Amount:        request.Amount,
+	}, client)
+	var held *fraud.HoldError
+	switch {
+	case errors.As(err, &held):
+		request.FraudCheckID = &held.CheckID
+		if err := s.closeTx(ctx, tx, request, models.PaymentRequestStatusHeld, ""); err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+
+	request.TransactionID = &transaction.ID
+	if err := s.closeTx(ctx, tx, request, models.PaymentRequestStatusPaid, ""); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return request, nil
+}
+
+// DeclineRequest turns down a pending request sent to the user
+func (s *PaymentRequestService) DeclineRequest(ctx context.Context, userID, id int64, reason string) (*models.PaymentRequest, error) {
+	return s.close(ctx, userID, id, false, models.PaymentRequestStatusDeclined, strings.TrimSpace(reason))
+}
+
+// CancelRequest withdraws a pending request the user sent
+func (s *PaymentRequestService) CancelRequest(ctx context.Context, userID, id int64) (*models.PaymentRequest, error) {
+	return s.close(ctx, userID, id, true, models.PaymentRequestStatusCancelled, "")
+}
+
+func (s *PaymentRequestService) close(ctx context.Context, userID, id int64, requester bool, status models.PaymentRequestStatus, reason string) (*models.PaymentRequest, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	request, err := s.lockPending(ctx, tx, userID, id, requester)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.closeTx(ctx, tx, request, status, reason); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return request, nil
+}
+
+// lockPending locks a pending request the user received, or sent when
+// requester is set. Requests of other users are reported as not found.
+func (s *PaymentRequestService) lockPending(ctx context.Context, tx repository.Tx, userID, id int64, requester bool) (*models.PaymentRequest, error) {
+	request, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		if err.Error() == "payment request not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	if (requester && request.RequesterID != userID) || (!requester && request.PayerID != userID) {
+		return nil, errors.New("payment request not found")
+	}
+
+	switch request.Status {
+	case models.PaymentRequestStatusPending:
+		return request, nil
+	case models.PaymentRequestStatusHeld:
+		return nil, errors.New("payment request is held for review")
+	default:
+		return nil, errors.New("payment request is not pending")
+	}
+}
+
+// closeTx stores the new status of a request and notifies the other side
+func (s *PaymentRequestService) closeTx(ctx context.Context, tx repository.Tx, request *models.PaymentRequest, status models.PaymentRequestStatus, reason string) error {
+	now := time.Now()
+	request.Status = status
+	request.DeclineReason = reason
+	if request.RespondedAt == nil && status != models.PaymentRequestStatusExpired {
+		request.RespondedAt = &now
+	}
+	if err := s.repo.UpdateStatusTx(ctx, tx, request); err != nil {
+		return err
+	}
+	return s.notifyTx(ctx, tx, request)
+}
+
+// SettleFraudCheck completes a request held with the fraud check once the check
+// is reviewed: a released transfer pays the request and a rejected one declines
+// it. Checks without a held request are ignored.
+func (s *PaymentRequestService) SettleFraudCheck(ctx context.Context, check *models.FraudCheck) error {
+	if check.Status != models.FraudCheckStatusReleased && check.Status != models.FraudCheckStatusRejected {
+		return nil
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	request, err := s.repo.GetHeldByFraudCheckForUpdate(ctx, tx, check.ID)
+	if err != nil || request == nil {
+		return err
+	}
+
+	if check.Status == models.FraudCheckStatusReleased {
+		request.TransactionID = check.TransactionID
+		err = s.closeTx(ctx, tx, request, models.PaymentRequestStatusPaid, "")
+	} else {
+		err = s.closeTx(ctx, tx, request, models.PaymentRequestStatusDeclined, "The payment was rejected by the bank")
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireRequests marks the pending requests past their expiry expired and
+// notifies the requesters. It returns how many requests expired.
+func (s *PaymentRequestService) ExpireRequests(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.repo.GetExpired(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return expired, err
+		}
+		ok, err := s.expire(ctx, id, now)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to expire payment request %d", id)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *PaymentRequestService) expire(ctx context.Context, id int64, now time.Time) (bool, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	request, err := s.repo.GetByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+	// The payer may have answered since the expired requests were listed
+	if request.Status != models.PaymentRequestStatusPending || request.ExpiresAt.After(now) {
+		return false, nil
+	}
+	if err := s.closeTx(ctx, tx, request, models.PaymentRequestStatusExpired, ""); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// notifyTx tells the payer about a new request and the requester about the
+// outcome, in the same transaction as the change
+func (s *PaymentRequestService) notifyTx(ctx context.Context, tx repository.Tx, request *models.PaymentRequest) error {
+	userID := request.RequesterID
+	var subject, content string
+	switch request.Status {
+	case models.PaymentRequestStatusPending:
+		userID = request.PayerID
+		subject = "New payment request"
+		content = fmt.Sprintf("You have been asked to pay %.2f %s (request #%d). Pay or decline it before %s.",
+			request.Amount, request.Currency, request.ID, request.ExpiresAt.UTC().Format("2006-01-02 15:04 UTC"))
+		if request.Description != "" {
+			content += " Description: " + request.Description
+		}
+	case models.PaymentRequestStatusHeld:
+		subject = "Payment request accepted"
+		content = fmt.Sprintf("Your payment request #%d of %.2f %s has been accepted. The payment is being checked by the bank.",
+			request.ID, request.Amount, request.Currency)
+	case models.PaymentRequestStatusPaid:
+		subject = "Payment request paid"
+		content = fmt.Sprintf("Your payment request #%d has been paid: %.2f %s have been credited to account #%d.",
+			request.ID, request.Amount, request.Currency, request.RequesterAccountID)
+	case models.PaymentRequestStatusDeclined:
+		subject = "Payment request declined"
+		content = fmt.Sprintf("Your payment request #%d of %.2f %s has been declined.", request.ID, request.Amount, request.Currency)
+		if request.DeclineReason != "" {
+			content += " Reason: " + request.DeclineReason
+		}
+	case models.PaymentRequestStatusCancelled:
+		userID = request.PayerID
+		subject = "Payment request cancelled"
+		content = fmt.Sprintf("Payment request #%d of %.2f %s has been cancelled and no longer needs to be paid.",
+			request.ID, request.Amount, request.Currency)
+	case models.PaymentRequestStatusExpired:
+		subject = "Payment request expired"
+		content = fmt.Sprintf("Your payment request #%d of %.2f %s has expired without being paid.",
+			request.ID, request.Amount, request.Currency)
+	}
+
+	if err := s.notificationService.NotifyUserTx(ctx, tx, userID, subject, content); err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue notification about payment request %d", request.ID)
+		return err
+	}
+	return nil
+}
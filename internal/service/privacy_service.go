@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// userDataExportJobPayload identifies the user exported by a data export job
+// and the admin who requested it
+type userDataExportJobPayload struct {
+	AdminID int64 `json:"admin_id"`
+	UserID  int64 `json:"user_id"`
+}
+
+// PrivacyService handles the personal data of users: closing a profile on the
+// user's request, anonymizing closed profiles once they are no longer needed
+// and exporting everything held about a user. Financial records are never
+// deleted; they stay attached to the anonymized user.
+type PrivacyService struct {
+	userRepo     repository.UserStore
+	settingsRepo *repository.UserSettingsRepository
+	accountRepo  repository.AccountStore
+	cardRepo     repository.CardStore
+	creditRepo   repository.CreditStore
+	sessionRepo  *repository.SessionRepository
+	auditRepo    *repository.AuditRepository
+	deposits     *DepositService
+	jobRunner    *jobs.Runner
+	store        storage.Storage
+	retention    time.Duration
+	logger       *logrus.Logger
+}
+
+// NewPrivacyService creates a new PrivacyService instance that keeps the
+// personal data of closed users for retentionDays
+func NewPrivacyService(
+	userRepo repository.UserStore,
+	settingsRepo *repository.UserSettingsRepository,
+	accountRepo repository.AccountStore,
+	cardRepo repository.CardStore,
+	creditRepo repository.CreditStore,
+	sessionRepo *repository.SessionRepository,
+	auditRepo *repository.AuditRepository,
+	retentionDays int,
+	logger *logrus.Logger,
+) *PrivacyService {
+	return &PrivacyService{
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		accountRepo:  accountRepo,
+		cardRepo:     cardRepo,
+		creditRepo:   creditRepo,
+		sessionRepo:  sessionRepo,
+		auditRepo:    auditRepo,
+		retention:    time.Duration(retentionDays) * 24 * time.Hour,
+		logger:       logger,
+	}
+}
+
+// SetDeposits keeps profiles with term deposits not paid out yet from being closed
+func (s *PrivacyService) SetDeposits(deposits *DepositService) {
+	s.deposits = deposits
+}
+
+// SetJobs lets exports run as background jobs that keep the export in store,
+// and registers the job handler with the runner
+func (s *PrivacyService) SetJobs(jobRunner *jobs.Runner, store storage.Storage) {
+	s.jobRunner = jobRunner
+	s.store = store
+	jobRunner.Register(models.JobTypeUserDataExport, s.processUserDataExportJob)
+}
+
+// CloseProfile closes the profile of a user after checking the password. The
+// accounts must be empty, the credits repaid and the term deposits paid out
+// first; the cards are blocked and every device is signed out.
+func (s *PrivacyService) CloseProfile(ctx context.Context, userID int64, req *models.CloseProfileRequest) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user by ID")
+		return errors.New("user not found")
+	}
+	if user.Status == models.StatusClosed {
+		return errors.New("user is already closed")
+	}
+	if !user.CheckPassword(req.Password) {
+		return errors.New("password is incorrect")
+	}
+
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user accounts")
+		return errors.New("internal server error")
+	}
+	for _, account := range accounts {
+		if account.Balance != 0 || account.HeldAmount != 0 {
+			return errors.New("accounts must be emptied before closing the profile")
+		}
+	}
+
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user credits")
+		return errors.New("internal server error")
+	}
+	for _, credit := range credits {
+		switch models.CreditStatus(credit.Status) {
+		case models.CreditStatusActive, models.CreditStatusDefault, models.CreditStatusDelinquent, models.CreditStatusCollections:
+			return errors.New("credits must be repaid before closing the profile")
+		}
+	}
+	if s.deposits != nil {
+		active, err := s.deposits.HasActive(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if active {
+			return errors.New("term deposits must be paid out before closing the profile")
+		}
+	}
+
+	// The cards are blocked first: a failure leaves the profile open and the
+	// closure can simply be requested again
+	cards, err := s.cardRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user cards")
+		return errors.New("internal server error")
+	}
+	for _, card := range cards {
+		if card.Status != models.CardStatusActive {
+			continue
+		}
+		if err := s.cardRepo.UpdateStatus(ctx, card.ID, models.CardStatusBlocked); err != nil {
+			s.logger.WithError(err).Errorf("Failed to block card %d of closing user %d", card.ID, userID)
+			return errors.New("internal server error")
+		}
+	}
+
+	if err := s.userRepo.Close(ctx, userID, time.Now()); err != nil {
+		if err.Error() == "user is already closed" {
+			return err
+		}
+		s.logger.WithError(err).Error("Failed to close user")
+		return errors.New("internal server error")
+	}
+
+	// A closed user can no longer log in, so a failure only leaves the current
+	// tokens valid until they expire
+	if err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign out closed user %d", userID)
+	}
+
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    userID,
+		Action:     models.AuditActionUserClosed,
+		EntityType: "user",
+		EntityID:   userID,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit user closure")
+	}
+
+	return nil
+}
+
+// AnonymizeClosedUsers erases the personal data of the users closed longer
+// than the retention period ago and returns how many were anonymized
+func (s *PrivacyService) AnonymizeClosedUsers(ctx context.Context, now time.Time) (int64, error) {
+	anonymized, err := s.userRepo.AnonymizeClosed(ctx, now.Add(-s.retention))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to anonymize closed users")
+		return 0, err
+	}
+	return anonymized, nil
+}
+
+// QueueUserDataExport queues the data export of a user, for users with more
+// history than one request should gather. The admin follows the returned job
+// and downloads the export from it.
+func (s *PrivacyService) QueueUserDataExport(ctx context.Context, adminID, userID int64) (*models.Job, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	payload := userDataExportJobPayload{AdminID: adminID, UserID: userID}
+	job, err := s.jobRunner.Enqueue(ctx, models.JobTypeUserDataExport, payload, jobs.OwnedBy(adminID))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to queue user data export job")
+		return nil, errors.New("internal server error")
+	}
+	return job, nil
+}
+
+// processUserDataExportJob exports a user and stores the export as the result
+// of the job
+func (s *PrivacyService) processUserDataExportJob(ctx context.Context, job *models.Job) error {
+	var payload userDataExportJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid user data export job payload: %w", err)
+	}
+
+	export, err := s.ExportUserData(ctx, payload.AdminID, payload.UserID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+	return storeJobFile(ctx, s.store, s.jobRunner, job, fmt.Sprintf("user-%d.json", payload.UserID), "application/json", data)
+}
+
+// ExportUserData collects everything held about a user for an admin: the
+// profile, the settings, the accounts with their transactions, the cards and
+// the credits. The export is audited.
+func (s *PrivacyService) ExportUserData(ctx context.Context, adminID, userID int64) (*models.UserDataExport, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user by ID")
+		return nil, errors.New("user not found")
+	}
+	user.Password = ""
+
+	export := &models.UserDataExport{User: user, ExportedAt: time.Now()}
+
+	if export.Settings, err = s.settingsRepo.GetByUserID(ctx, userID); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	if export.Accounts, err = s.accountRepo.GetByUserID(ctx, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to get user accounts")
+		return nil, errors.New("internal server error")
+	}
+	if export.Cards, err = s.cardRepo.GetByUserID(ctx, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to get user cards")
+		return nil, errors.New("internal server error")
+	}
+	if export.Credits, err = s.creditRepo.GetByUserID(ctx, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to get user credits")
+		return nil, errors.New("internal server error")
+	}
+
+	// A transfer between two of the user's accounts is listed by both
+	seen := make(map[int64]bool)
+	for _, account := range export.Accounts {
+		transactions, err := s.accountRepo.GetTransactions(ctx, account.ID, time.Time{}, export.ExportedAt)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to get transactions of account %d", account.ID)
+			return nil, errors.New("internal server error")
+		}
+		for _, transaction := range transactions {
+			if !seen[transaction.ID] {
+				seen[transaction.ID] = true
+				export.Transactions = append(export.Transactions, transaction)
+			}
+		}
+	}
+
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionUserDataExported,
+		EntityType: "user",
+		EntityID:   userID,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit user data export")
+	}
+
+	return export, nil
+}
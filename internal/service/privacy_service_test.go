@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestPrivacyServiceCloseProfile(t *testing.T) {
+	user := &models.User{ID: 7, Password: "correct horse", Status: models.StatusActive}
+	if err := user.HashPassword(); err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		accounts    []*models.Account
+		credits     []*models.Credit
+		wantErr     string
+		wantBlocked []int64
+	}{
+		{
+			name:        "closes the profile and blocks the active cards",
+			password:    "correct horse",
+			accounts:    []*models.Account{{ID: 1, UserID: 7}},
+			credits:     []*models.Credit{{ID: 3, Status: string(models.CreditStatusPaid)}},
+			wantBlocked: []int64{10},
+		},
+		{
+			name:     "wrong password",
+			password: "battery staple",
+			wantErr:  "password is incorrect",
+		},
+		{
+			name:     "account still holds funds",
+			password: "correct horse",
+			accounts: []*models.Account{{ID: 1, UserID: 7, Balance: 0.01}},
+			wantErr:  "accounts must be emptied before closing the profile",
+		},
+		{
+			name:     "credit not repaid",
+			password: "correct horse",
+			credits:  []*models.Credit{{ID: 3, Status: string(models.CreditStatusActive)}},
+			wantErr:  "credits must be repaid before closing the profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			var blocked []int64
+			var closed bool
+
+			users := &mock.UserStore{
+				GetByIDFunc: func(context.Context, int64) (*models.User, error) {
+					u := *user
+					return &u, nil
+				},
+				CloseFunc: func(context.Context, int64, time.Time) error {
+					closed = true
+					return nil
+				},
+			}
+			accounts := &mock.AccountStore{
+				GetByUserIDFunc: func(context.Context, int64) ([]*models.Account, error) { return tt.accounts, nil },
+			}
+			credits := &mock.CreditStore{
+				GetByUserIDFunc: func(context.Context, int64) ([]*models.Credit, error) { return tt.credits, nil },
+			}
+			cards := &mock.CardStore{
+				GetByUserIDFunc: func(context.Context, int64) ([]*models.Card, error) {
+					return []*models.Card{
+						{ID: 10, Status: models.CardStatusActive},
+						{ID: 11, Status: models.CardStatusExpired},
+					}, nil
+				},
+				UpdateStatusFunc: func(_ context.Context, id int64, status string) error {
+					if status == models.CardStatusBlocked {
+						blocked = append(blocked, id)
+					}
+					return nil
+				},
+			}
+
+			s := NewPrivacyService(users, repository.NewUserSettingsRepository(env.db, env.logger), accounts, cards, credits,
+				repository.NewSessionRepository(env.db, env.logger), repository.NewAuditRepository(env.db, env.logger), 30, env.logger)
+
+			err := s.CloseProfile(context.Background(), user.ID, &models.CloseProfileRequest{Password: tt.password})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("CloseProfile() error = %v, want %q", err, tt.wantErr)
+				}
+				if closed || len(blocked) > 0 {
+					t.Errorf("refused closure changed state: closed = %v, blocked = %v", closed, blocked)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CloseProfile() error = %v", err)
+			}
+			if !closed {
+				t.Error("user was not closed")
+			}
+			if len(blocked) != len(tt.wantBlocked) || (len(blocked) > 0 && blocked[0] != tt.wantBlocked[0]) {
+				t.Errorf("blocked cards = %v, want %v", blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
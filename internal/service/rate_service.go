@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/cache"
+	"github.com/Abigotado/abi_banking/internal/integration/cbr"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// RateSource fetches the official rates: the CBR client, or its sandbox fake
+type RateSource interface {
+	GetCurrencyRates(date time.Time) ([]cbr.CurrencyRate, error)
+	GetKeyRate() (float64, error)
+}
+
+// RateService serves CBR exchange and key rates from an in-memory cache so the
+// SOAP service is not called on every request. With a shared cache set, the
+// rates fetched by one instance are taken up by the others.
+type RateService struct {
+	client RateSource
+	ttl    time.Duration
+	cache  cache.Cache
+	logger *logrus.Logger
+
+	mu      sync.RWMutex
+	rates   *models.CurrencyRates
+	keyRate *models.KeyRate
+}
+
+// NewRateService creates a new RateService instance
+func NewRateService(client RateSource, ttl time.Duration, logger *logrus.Logger) *RateService {
+	return &RateService{
+		client: client,
+		ttl:    ttl,
+		cache:  cache.Nop{},
+		logger: logger,
+	}
+}
+
+// Keys of the rates in the shared cache
+const (
+	ratesCacheKey   = "rates:exchange"
+	keyRateCacheKey = "rates:key"
+)
+
+// SetCache shares the fetched rates with the other instances through c
+func (s *RateService) SetCache(c cache.Cache) {
+	s.cache = c
+}
+
+// Refresh reloads exchange rates and the key rate from CBR
+func (s *RateService) Refresh() error {
+	_, ratesErr := s.refreshRates()
+	_, keyRateErr := s.refreshKeyRate()
+	if ratesErr != nil {
+		return ratesErr
+	}
+	return keyRateErr
+}
+
+// GetRates returns current exchange rates, using the cache while it is fresh.
+// Stale data is served if CBR is unavailable.
+func (s *RateService) GetRates() (*models.CurrencyRates, error) {
+	s.mu.RLock()
+	rates := s.rates
+	s.mu.RUnlock()
+
+	if rates != nil && time.Since(rates.UpdatedAt) < s.ttl {
+		return rates, nil
+	}
+
+	shared := &models.CurrencyRates{}
+	if s.cache.Get(context.Background(), ratesCacheKey, shared) && time.Since(shared.UpdatedAt) < s.ttl {
+		s.mu.Lock()
+		s.rates = shared
+		s.mu.Unlock()
+		return shared, nil
+	}
+
+	fresh, err := s.refreshRates()
+	if err != nil {
+		if rates != nil {
+			s.logger.WithError(err).Warn("Serving stale exchange rates")
+			return rates, nil
+		}
+		return nil, errors.New("exchange rates are unavailable")
+	}
+	return fresh, nil
+}
+
+// GetRate returns the current exchange rate of a single currency
+func (s *RateService) GetRate(code string) (*models.CurrencyRate, error) {
+	code = strings.ToUpper(code)
+	if code == models.BaseCurrency {
+		return &models.CurrencyRate{Code: models.BaseCurrency, Nominal: 1, Rate: 1, UnitRate: 1}, nil
+	}
+
+	rates, err := s.GetRates()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rates.Rates {
+		if rates.Rates[i].Code == code {
+			return &rates.Rates[i], nil
+		}
+	}
+	return nil, errors.New("unknown currency " + code)
+}
+
+// CrossRate returns how many units of the target currency one unit of the source currency buys
+func (s *RateService) CrossRate(from, to string) (float64, error) {
+	if strings.EqualFold(from, to) {
+		return 1, nil
+	}
+
+	fromRate, err := s.GetRate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := s.GetRate(to)
+	if err != nil {
+		return 0, err
+	}
+
+	return fromRate.UnitRate / toRate.UnitRate, nil
+}
+
+// GetKeyRate returns the current key rate, using the cache while it is fresh
+func (s *RateService) GetKeyRate() (*models.KeyRate, error) {
+	s.mu.RLock()
+	keyRate := s.keyRate
+	s.mu.RUnlock()
+
+	if keyRate != nil && time.Since(keyRate.UpdatedAt) < s.ttl {
+		return keyRate, nil
+	}
+
+	shared := &models.KeyRate{}
+	if s.cache.Get(context.Background(), keyRateCacheKey, shared) && time.Since(shared.UpdatedAt) < s.ttl {
+		s.mu.Lock()
+		s.keyRate = shared
+		s.mu.Unlock()
+		return shared, nil
+	}
+
+	fresh, err := s.refreshKeyRate()
+	if err != nil {
+		if keyRate != nil {
+			s.logger.WithError(err).Warn("Serving stale key rate")
+			return keyRate, nil
+		}
+		return nil, errors.New("key rate is unavailable")
+	}
+	return fresh, nil
+}
+
+func (s *RateService) refreshRates() (*models.CurrencyRates, error) {
+	now := time.Now()
+	cbrRates, err := s.client.GetCurrencyRates(now)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to fetch exchange rates from CBR")
+		return nil, err
+	}
+
+	rates := &models.CurrencyRates{
+		Date:      now.Truncate(24 * time.Hour),
+		Base:      models.BaseCurrency,
+		Rates:     make([]models.CurrencyRate, 0, len(cbrRates)),
+		UpdatedAt: now,
+	}
+	for _, r := range cbrRates {
+		unitRate := r.Rate
+		if r.Nominal > 0 {
+			unitRate = r.Rate / float64(r.Nominal)
+		}
+		rates.Rates = append(rates.Rates, models.CurrencyRate{
+			Code:     r.Code,
+			NumCode:  r.NumCode,
+			Name:     r.Name,
+			Nominal:  r.Nominal,
+			Rate:     r.Rate,
+			UnitRate: unitRate,
+		})
+	}
+
+	s.mu.Lock()
+	s.rates = rates
+	s.mu.Unlock()
+	s.cache.Set(context.Background(), ratesCacheKey, rates, s.ttl)
+
+	return rates, nil
+}
+
+func (s *RateService) refreshKeyRate() (*models.KeyRate, error) {
+	rate, err := s.client.GetKeyRate()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to fetch key rate from CBR")
+		return nil, err
+	}
+
+	keyRate := &models.KeyRate{
+		Rate:      rate,
+		UpdatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.keyRate = keyRate
+	s.mu.Unlock()
+	s.cache.Set(context.Background(), keyRateCacheKey, keyRate, s.ttl)
+
+	return keyRate, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// receiptCodeBytes is how much of the HMAC the verification code keeps: 80
+// bits, printed as 16 characters
+const receiptCodeBytes = 10
+
+// ReceiptService issues receipts of transactions and verifies the codes printed on them
+type ReceiptService struct {
+	accountRepo repository.AccountStore
+	bank        *config.BankConfig
+	cfg         *config.ReceiptConfig
+	logger      *logrus.Logger
+}
+
+// NewReceiptService creates a new ReceiptService instance
+func NewReceiptService(
+	accountRepo repository.AccountStore,
+	bank *config.BankConfig,
+	cfg *config.ReceiptConfig,
+	logger *logrus.Logger,
+) *ReceiptService {
+	return &ReceiptService{
+		accountRepo: accountRepo,
+		bank:        bank,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+// GetReceipt returns the receipt of a transaction of one of the user's accounts
+func (s *ReceiptService) GetReceipt(ctx context.Context, userID, transactionID int64) (*models.Receipt, error) {
+	if s.cfg.Secret == "" {
+		return nil, errors.New("receipts are not available")
+	}
+
+	receipt, accounts, err := s.receipt(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if authorizeAccount(ctx, s.accountRepo, account, userID, false) == nil {
+			return receipt, nil
+		}
+	}
+	return nil, errors.New("transaction not found")
+}
+
+// Verify checks the verification code of a receipt against the transaction its
+// reference names. A code that does not match, like a reference to no
+// transaction, is reported as invalid rather than as an error.
+func (s *ReceiptService) Verify(ctx context.Context, reference, code string) (*models.ReceiptVerification, error) {
+	if s.cfg.Secret == "" {
+		return nil, errors.New("receipts are not available")
+	}
+
+	transactionID, ok := models.ParseTransactionReference(reference)
+	if !ok {
+		return &models.ReceiptVerification{}, nil
+	}
+	receipt, _, err := s.receipt(ctx, transactionID)
+	if err != nil {
+		if err.Error() == "transaction not found" {
+			return &models.ReceiptVerification{}, nil
+		}
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(normalizeReceiptCode(code)), []byte(normalizeReceiptCode(receipt.VerificationCode))) {
+		return &models.ReceiptVerification{}, nil
+	}
+	return &models.ReceiptVerification{
+		Valid:       true,
+		Reference:   receipt.Reference,
+		Type:        receipt.Type,
+		Amount:      receipt.Amount,
+		Currency:    receipt.Currency,
+		FromAccount: receipt.FromAccount,
+		ToAccount:   receipt.ToAccount,
+		CreatedAt:   &receipt.CreatedAt,
+	}, nil
+}
+
+// receipt builds the receipt of a transaction and returns the accounts it moved
+// money between
+func (s *ReceiptService) receipt(ctx context.Context, transactionID int64) (*models.Receipt, []*models.Account, error) {
+	transaction, err := s.accountRepo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		if err.Error() == "transaction not found" {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("internal server error")
+	}
+
+	receipt := &models.Receipt{
+		TransactionID: transaction.ID,
+		Reference:     models.TransactionReference(transaction.ID),
+		Type:          transaction.Type,
+		Description:   transaction.Description,
+		Amount:        transaction.Amount,
+		Conversion:    transaction.Conversion,
+		CreatedAt:     transaction.CreatedAt.UTC(),
+		Bank: models.ReceiptBank{
+			Name:                 s.bank.Name,
+			BIC:                  s.bank.BIC,
+			TaxID:                s.bank.TaxID,
+			CorrespondentAccount: s.bank.CorrespondentAccount,
+		},
+		VerificationURL: s.cfg.VerifyURL,
+	}
+
+	// The full account numbers are signed, the masked ones printed
+	var accounts []*models.Account
+	var fromNumber, toNumber string
+	for _, side := range []struct {
+		id      int64
+		number  *string
+		printed *string
+	}{
+		{transaction.FromAccountID, &fromNumber, &receipt.FromAccount},
+		{transaction.ToAccountID, &toNumber, &receipt.ToAccount},
+	} {
+		if side.id == 0 {
+			continue
+		}
+		account, err := s.accountRepo.GetByID(ctx, side.id)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to get account %d of transaction %d", side.id, transaction.ID)
+			return nil, nil, errors.New("internal server error")
+		}
+		accounts = append(accounts, account)
+		*side.number = account.Number
+		*side.printed = account.MaskedNumber()
+		// The amount is in the currency of the account it left, or of the
+		// account it arrived on for money from outside the bank
+		if receipt.Currency == "" {
+			receipt.Currency = account.Currency
+		}
+	}
+
+	receipt.VerificationCode = receiptCode(s.cfg.Secret, receipt, fromNumber, toNumber)
+	return receipt, accounts, nil
+}
+
+// receiptCode signs the details of a receipt, grouped by four characters for printing
+func receiptCode(secret string, r *models.Receipt, fromNumber, toNumber string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d|%s|%.2f|%s|%s|%s|%d", r.TransactionID, r.Type, r.Amount, r.Currency, fromNumber, toNumber,
+		r.CreatedAt.Unix())
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil)[:receiptCodeBytes])
+
+	groups := make([]string, 0, len(code)/4)
+	for i := 0; i < len(code); i += 4 {
+		groups = append(groups, code[i:i+4])
+	}
+	return strings.Join(groups, "-")
+}
+
+// normalizeReceiptCode drops the separators and the case of a code as typed by
+// the person verifying it
+func normalizeReceiptCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func newReceiptTestService(t *testing.T, secret string) *ReceiptService {
+	t.Helper()
+	env := newTestEnv(t)
+	accounts := map[int64]*models.Account{
+		1: {ID: 1, UserID: 7, Number: "40817810000000000011", Currency: "RUB"},
+		2: {ID: 2, UserID: 8, Number: "40817810000000000022", Currency: "RUB"},
+	}
+	store := &mock.AccountStore{
+		GetTransactionByIDFunc: func(_ context.Context, id int64) (*models.Transaction, error) {
+			if id != 42 {
+				return nil, errors.New("transaction not found")
+			}
+			return &models.Transaction{
+				ID:            42,
+				FromAccountID: 1,
+				ToAccountID:   2,
+				Amount:        1500,
+				Type:          models.TransactionTypeTransfer,
+				CreatedAt:     time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+			}, nil
+		},
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Account, error) {
+			account := *accounts[id]
+			return &account, nil
+		},
+		GetMemberRoleFunc: func(context.Context, int64, int64) (models.AccountRole, error) {
+			return "", errors.New("not a member")
+		},
+	}
+	return NewReceiptService(store, &env.cfg.Bank, &config.ReceiptConfig{Secret: secret}, env.logger)
+}
+
+func TestReceiptServiceGetReceipt(t *testing.T) {
+	s := newReceiptTestService(t, "receipt secret")
+
+	for _, userID := range []int64{7, 8} {
+		receipt, err := s.GetReceipt(context.Background(), userID, 42)
+		if err != nil {
+			t.Fatalf("user %d: get receipt: %v", userID, err)
+		}
+		if receipt.Reference != "TX0000000042" || receipt.Currency != "RUB" {
+			t.Errorf("user %d: reference %q currency %q", userID, receipt.Reference, receipt.Currency)
+		}
+		if strings.Contains(receipt.FromAccount, "0000000011") {
+			t.Errorf("user %d: account number %q is not masked", userID, receipt.FromAccount)
+		}
+	}
+
+	if _, err := s.GetReceipt(context.Background(), 9, 42); err == nil || err.Error() != "transaction not found" {
+		t.Errorf("stranger: got %v, want transaction not found", err)
+	}
+
+	unconfigured := newReceiptTestService(t, "")
+	if _, err := unconfigured.GetReceipt(context.Background(), 7, 42); err == nil || err.Error() != "receipts are not available" {
+		t.Errorf("without secret: got %v, want receipts are not available", err)
+	}
+}
+
+func TestReceiptServiceVerify(t *testing.T) {
+	s := newReceiptTestService(t, "receipt secret")
+	receipt, err := s.GetReceipt(context.Background(), 7, 42)
+	if err != nil {
+		t.Fatalf("get receipt: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		reference string
+		code      string
+		wantValid bool
+	}{
+		{"issued code", receipt.Reference, receipt.VerificationCode, true},
+		{"typed without separators in lower case", "tx0000000042", strings.ToLower(strings.ReplaceAll(receipt.VerificationCode, "-", "")), true},
+		{"altered code", receipt.Reference, "AAAA-AAAA-AAAA-AAAA", false},
+		{"code of another transaction", "TX0000000043", receipt.VerificationCode, false},
+		{"malformed reference", "42", receipt.VerificationCode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification, err := s.Verify(context.Background(), tt.reference, tt.code)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if verification.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v", verification.Valid, tt.wantValid)
+			}
+			if !tt.wantValid && verification.Amount != 0 {
+				t.Errorf("invalid receipt disclosed amount %v", verification.Amount)
+			}
+		})
+	}
+
+	// A code issued with another secret does not verify
+	other := newReceiptTestService(t, "another secret")
+	if verification, _ := other.Verify(context.Background(), receipt.Reference, receipt.VerificationCode); verification.Valid {
+		t.Error("code verified with another secret")
+	}
+}
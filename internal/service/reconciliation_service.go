@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconciliationService snapshots the account balances every night and checks
+// them against the ledger and the transaction log
+type ReconciliationService struct {
+	repo   *repository.ReconciliationRepository
+	hour   int
+	logger *logrus.Logger
+}
+
+// NewReconciliationService creates a new ReconciliationService instance that
+// reconciles once a day from the given hour on
+func NewReconciliationService(repo *repository.ReconciliationRepository, hour int, logger *logrus.Logger) *ReconciliationService {
+	return &ReconciliationService{
+		repo:   repo,
+		hour:   hour,
+		logger: logger,
+	}
+}
+
+// Reconcile runs the reconciliation of the day once its hour has come. It
+// returns nil when the run is not due or the day has already been reconciled.
+func (s *ReconciliationService) Reconcile(ctx context.Context, now time.Time) (*models.ReconciliationRun, error) {
+	if now.Hour() < s.hour {
+		return nil, nil
+	}
+
+	run, err := s.repo.CreateRun(ctx, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil || run == nil {
+		return nil, err
+	}
+
+	if run.Issues > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"run_id": run.ID,
+			"issues": run.Issues,
+		}).Error("Reconciliation found account balances that do not match")
+	}
+	return run, nil
+}
+
+// GetReport retrieves the run of a date with its issues, the latest run when
+// date is zero
+func (s *ReconciliationService) GetReport(ctx context.Context, date time.Time) (*models.ReconciliationReport, error) {
+	run, err := s.repo.GetRun(ctx, date)
+	if err != nil {
+		if err.Error() == "reconciliation run not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+
+	issues, err := s.repo.GetIssues(ctx, run.ID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return &models.ReconciliationReport{Run: run, Issues: issues}, nil
+}
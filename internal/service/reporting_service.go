@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ReportingService builds the reports of the bank for external systems, such as
+// the monthly export of the ledger to the accounting system
+type ReportingService struct {
+	accountingRepo *repository.AccountingRepository
+	auditRepo      *repository.AuditRepository
+	cfg            *config.AccountingConfig
+	logger         *logrus.Logger
+}
+
+// NewReportingService creates a new ReportingService instance
+func NewReportingService(
+	accountingRepo *repository.AccountingRepository,
+	auditRepo *repository.AuditRepository,
+	cfg *config.AccountingConfig,
+	logger *logrus.Logger,
+) *ReportingService {
+	return &ReportingService{
+		accountingRepo: accountingRepo,
+		auditRepo:      auditRepo,
+		cfg:            cfg,
+		logger:         logger,
+	}
+}
+
+// AccountingExport sums up the transactions posted in a month, given as
+// YYYY-MM, into accounting entries between balance sheet accounts. The current
+// month can be exported while it runs; the export is audited.
+func (s *ReportingService) AccountingExport(ctx context.Context, adminID int64, period string) (*models.AccountingExport, error) {
+	from, err := time.Parse("2006-01", period)
+	if err != nil {
+		return nil, errors.New("period must be given as YYYY-MM")
+	}
+	now := time.Now().UTC()
+	if from.After(now) {
+		return nil, errors.New("period is in the future")
+	}
+
+	export := &models.AccountingExport{
+		Period:      period,
+		From:        from,
+		To:          from.AddDate(0, 1, 0),
+		GeneratedAt: now,
+	}
+	export.Entries, err = s.accountingRepo.ListEntries(ctx, export.From, export.To)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to export accounting period %s", period)
+		return nil, errors.New("internal server error")
+	}
+
+	bankAccounts := map[string]string{
+		models.LedgerAccountCash:             s.cfg.CashAccount,
+		models.LedgerAccountCardSettlement:   s.cfg.CardSettlementAccount,
+		models.LedgerAccountLoans:            s.cfg.LoansAccount,
+		models.LedgerAccountInterestIncome:   s.cfg.InterestIncomeAccount,
+		models.LedgerAccountExternalClearing: s.cfg.ExternalClearingAccount,
+		models.LedgerAccountDisputes:         s.cfg.DisputesAccount,
+		models.LedgerAccountBillers:          s.cfg.BillersAccount,
+		models.LedgerAccountTermDeposits:     s.cfg.TermDepositsAccount,
+		models.LedgerAccountInterestExpense:  s.cfg.InterestExpenseAccount,
+		models.LedgerAccountFeeIncome:        s.cfg.FeeIncomeAccount,
+	}
+	for _, entry := range export.Entries {
+		if entry.DebitLedger != models.LedgerAccountCustomer {
+			entry.DebitAccount = bankAccounts[entry.DebitLedger]
+		}
+		if entry.CreditLedger != models.LedgerAccountCustomer {
+			entry.CreditAccount = bankAccounts[entry.CreditLedger]
+		}
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"period": period, "entries": len(export.Entries)})
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionAccountingExported,
+		EntityType: "accounting_period",
+		EntityID:   int64(from.Year()*100 + int(from.Month())),
+		Details:    details,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit accounting export")
+	}
+
+	return export, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// SandboxService serves the sandbox mode integrators develop against: it shows
+// the emails captured instead of sent and wipes the test data on request
+type SandboxService struct {
+	repo    *repository.SandboxRepository
+	mailbox *smtp.Mailbox
+	logger  *logrus.Logger
+}
+
+// NewSandboxService creates a new SandboxService instance
+func NewSandboxService(repo *repository.SandboxRepository, mailbox *smtp.Mailbox, logger *logrus.Logger) *SandboxService {
+	return &SandboxService{
+		repo:    repo,
+		mailbox: mailbox,
+		logger:  logger,
+	}
+}
+
+// Emails returns the captured emails, newest first, optionally only those sent
+// to recipient
+func (s *SandboxService) Emails(recipient string) []*models.SandboxEmail {
+	return s.mailbox.Emails(recipient)
+}
+
+// Reset wipes the test data and the captured emails
+func (s *SandboxService) Reset(ctx context.Context) error {
+	tables, err := s.repo.Reset(ctx)
+	if err != nil {
+		return errors.New("internal server error")
+	}
+	s.mailbox.Reset()
+
+	s.logger.WithField("tables", tables).Warn("Sandbox data was reset")
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+)
+
+func TestSandboxServiceEmails(t *testing.T) {
+	env := newTestEnv(t)
+	mailbox := smtp.NewMailbox()
+	s := NewSandboxService(repository.NewSandboxRepository(env.db, env.logger), mailbox, env.logger)
+
+	for _, recipient := range []string{"anna@example.com", "boris@example.com", "Anna@Example.com"} {
+		notification := &models.Notification{Recipient: recipient, Subject: "Code", Content: "123456"}
+		if err := mailbox.SendEmailWithAttachments(notification, []smtp.Attachment{{Name: "statement.pdf"}}); err != nil {
+			t.Fatalf("capture email: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		recipient string
+		wantIDs   []int64
+	}{
+		{name: "all emails newest first", recipient: "", wantIDs: []int64{3, 2, 1}},
+		{name: "recipient in any case", recipient: "ANNA@example.com", wantIDs: []int64{3, 1}},
+		{name: "unknown recipient", recipient: "vera@example.com", wantIDs: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emails := s.Emails(tt.recipient)
+			if len(emails) != len(tt.wantIDs) {
+				t.Fatalf("got %d emails, want %d", len(emails), len(tt.wantIDs))
+			}
+			for i, email := range emails {
+				if email.ID != tt.wantIDs[i] {
+					t.Errorf("email %d has ID %d, want %d", i, email.ID, tt.wantIDs[i])
+				}
+				if len(email.Attachments) != 1 || email.Attachments[0] != "statement.pdf" {
+					t.Errorf("email %d attachments %v", i, email.Attachments)
+				}
+			}
+		})
+	}
+
+	if err := s.Reset(context.Background()); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if emails := s.Emails(""); len(emails) != 0 {
+		t.Errorf("%d emails left after reset", len(emails))
+	}
+}
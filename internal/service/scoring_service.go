@@ -0,0 +1,488 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	scoreWeightPunctuality = 50.0
+	scoreWeightUtilization = 25.0
+	scoreWeightCreditLoad  = 25.0
+
+	// creditLoadCeiling is the obligations-to-income ratio at which the load component drops to zero
+	creditLoadCeiling = 0.6
+
+	// overduePenalty is taken off the credit score for each overdue installment, up to overduePenaltyCap
+	overduePenalty    = 5
+	overduePenaltyCap = 30
+
+	creditHealthTrendMonths = 6
+	creditScoreHistoryLimit = 24
+	incomeLookbackMonths    = 3
+)
+
+// ScoringService evaluates users' credit behaviour from their credit and payment history
+type ScoringService struct {
+	scoreRepo    *repository.CreditScoreRepository
+	creditRepo   repository.CreditStore
+	accountRepo  repository.AccountStore
+	paramService *ParameterService
+	logger       *logrus.Logger
+}
+
+// NewScoringService creates a new ScoringService instance
+func NewScoringService(
+	scoreRepo *repository.CreditScoreRepository,
+	creditRepo repository.CreditStore,
+	accountRepo repository.AccountStore,
+	paramService *ParameterService,
+	logger *logrus.Logger,
+) *ScoringService {
+	return &ScoringService{
+		scoreRepo:    scoreRepo,
+		creditRepo:   creditRepo,
+		accountRepo:  accountRepo,
+		paramService: paramService,
+		logger:       logger,
+	}
+}
+
+// creditHistory pairs a credit with its payment schedule
+type creditHistory struct {
+	credit   *models.Credit
+	schedule []*models.PaymentSchedule
+}
+
+// EvaluateCreditHealth computes the user's credit health score, its trend over the
+// last months and recommendations to improve it
+func (s *ScoringService) EvaluateCreditHealth(ctx context.Context, userID int64, now time.Time) (*models.CreditHealth, error) {
+	history, err := s.loadCreditHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	income, err := s.monthlyIncome(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	grace := graceDuration(s.paramService.Get(ctx, models.ParamCreditPaymentGraceDays, now))
+	factors := creditFactorsAt(history, income, grace, now)
+	score := scoreFactors(factors)
+
+	// Historical points are rebuilt from the schedule as it stood at each month end;
+	// income is not versioned, so the current figure is used throughout
+	trend := make([]models.CreditHealthPoint, 0, creditHealthTrendMonths)
+	for i := creditHealthTrendMonths - 1; i > 0; i-- {
+		monthStart := time.Date(now.Year(), now.Month()-time.Month(i)+1, 1, 0, 0, 0, 0, now.Location())
+		at := monthStart.Add(-time.Nanosecond)
+		trend = append(trend, models.CreditHealthPoint{
+			Date:  at,
+			Score: scoreFactors(creditFactorsAt(history, income, grace, at)),
+		})
+	}
+	trend = append(trend, models.CreditHealthPoint{Date: now, Score: score})
+
+	return &models.CreditHealth{
+		Score:           score,
+		Grade:           models.GradeForScore(score),
+		Factors:         factors,
+		Trend:           trend,
+		Recommendations: recommendCreditActions(history, factors, now),
+		CalculatedAt:    now,
+	}, nil
+}
+
+// EvaluateApplication scores a credit application on income, existing debt and
+// overdue history, deciding between approval, rejection and manual review
+func (s *ScoringService) EvaluateApplication(ctx context.Context, app *models.CreditApplication, now time.Time) (*models.CreditApplicationDecision, error) {
+	history, err := s.loadCreditHistory(ctx, app.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	observedIncome, err := s.monthlyIncome(ctx, app.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Declared income is only trusted when the account history shows none
+	income := observedIncome
+	if income == 0 {
+		income = app.DeclaredIncome
+	}
+
+	graceDays := s.paramService.Get(ctx, models.ParamCreditPaymentGraceDays, now)
+	approveScore := s.paramService.Get(ctx, models.ParamScoringApproveScore, now)
+	rejectScore := s.paramService.Get(ctx, models.ParamScoringRejectScore, now)
+	reviewDebtToIncome := s.paramService.Get(ctx, models.ParamScoringReviewDebtToIncome, now)
+	maxDebtToIncome := s.paramService.Get(ctx, models.ParamScoringMaxDebtToIncome, now)
+
+	factors := creditFactorsAt(history, income, graceDuration(graceDays), now)
+	// A differentiated schedule starts with its largest installment
+	payment := models.BuildInstallments(app.Amount, app.InterestRate/12/100, app.TermMonths, app.ScheduleType)[0].Amount
+	if app.InsurancePremium != nil {
+		payment = roundMoney(payment + *app.InsurancePremium)
+	}
+
+	decision := &models.CreditApplicationDecision{
+		MonthlyIncome:  roundMoney(income),
+		MonthlyPayment: payment,
+		AppliedParameters: []models.AppliedParameter{
+			graceDays, approveScore, rejectScore, reviewDebtToIncome, maxDebtToIncome,
+		},
+	}
+	if income <= 0 {
+		decision.Status = models.CreditApplicationStatusRejected
+		decision.Reason = "no income to service the credit"
+		return decision, nil
+	}
+
+	// Score the applicant as if the new credit were already granted
+	factors.MonthlyObligations += payment
+	factors.CreditLoad = factors.MonthlyObligations / income
+	decision.DebtToIncome = math.Round(factors.CreditLoad*10000) / 10000
+	decision.Score = creditScore(factors)
+
+	switch {
+	case factors.OverduePayments > 0:
+		decision.Status = models.CreditApplicationStatusRejected
+		decision.Reason = fmt.Sprintf("%d overdue installments on existing credits", factors.OverduePayments)
+	case factors.CreditLoad > maxDebtToIncome.Value:
+		decision.Status = models.CreditApplicationStatusRejected
+		decision.Reason = fmt.Sprintf("debt-to-income ratio of %.0f%% exceeds %.0f%%", factors.CreditLoad*100, maxDebtToIncome.Value*100)
+	case float64(decision.Score) < rejectScore.Value:
+		decision.Status = models.CreditApplicationStatusRejected
+		decision.Reason = fmt.Sprintf("score %d is below %g", decision.Score, rejectScore.Value)
+	case observedIncome == 0:
+		decision.Status = models.CreditApplicationStatusManualReview
+		decision.Reason = "declared income is not confirmed by account history"
+	case factors.LatePayments > 0:
+		decision.Status = models.CreditApplicationStatusManualReview
+		decision.Reason = fmt.Sprintf("%d late installments on existing credits", factors.LatePayments)
+	case factors.CreditLoad > reviewDebtToIncome.Value:
+		decision.Status = models.CreditApplicationStatusManualReview
+		decision.Reason = fmt.Sprintf("debt-to-income ratio of %.0f%% requires review", factors.CreditLoad*100)
+	case float64(decision.Score) < approveScore.Value:
+		decision.Status = models.CreditApplicationStatusManualReview
+		decision.Reason = fmt.Sprintf("score %d is below automatic approval threshold %g", decision.Score, approveScore.Value)
+	default:
+		decision.Status = models.CreditApplicationStatusApproved
+		decision.Reason = "approved automatically"
+	}
+
+	return decision, nil
+}
+
+// GetCreditScore computes the user's current internal credit score and returns it
+// with the scores recorded before
+func (s *ScoringService) GetCreditScore(ctx context.Context, userID int64, now time.Time) (*models.CreditScoreReport, error) {
+	score, err := s.ScoreUser(ctx, userID, 0, models.CreditScoreReasonRequested, now)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.scoreRepo.ListByUser(ctx, userID, creditScoreHistoryLimit)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return &models.CreditScoreReport{CreditScore: *score, History: history}, nil
+}
+
+// ScoreUser computes the user's internal credit score from their payment
+// history, overdue installments, income and utilization. Declared income is
+// only used when the account history shows none. The score is recorded when it
+// is calculated for a credit application or differs from the last one recorded.
+func (s *ScoringService) ScoreUser(ctx context.Context, userID int64, declaredIncome float64, reason models.CreditScoreReason, now time.Time) (*models.CreditScore, error) {
+	history, err := s.loadCreditHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	income, err := s.monthlyIncome(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if income == 0 {
+		income = declaredIncome
+	}
+
+	grace := graceDuration(s.paramService.Get(ctx, models.ParamCreditPaymentGraceDays, now))
+	factors := creditFactorsAt(history, income, grace, now)
+	score := &models.CreditScore{
+		UserID:       userID,
+		Score:        creditScore(factors),
+		Factors:      factors,
+		Reason:       reason,
+		CalculatedAt: now,
+	}
+	score.Rating = models.RatingForScore(score.Score)
+
+	if reason == models.CreditScoreReasonRequested {
+		latest, err := s.scoreRepo.ListByUser(ctx, userID, 1)
+		if err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if len(latest) > 0 && latest[0].Score == score.Score {
+			return score, nil
+		}
+	}
+	if err := s.scoreRepo.Create(ctx, score); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return score, nil
+}
+
+func (s *ScoringService) loadCreditHistory(ctx context.Context, userID int64) ([]creditHistory, error) {
+	credits, err := s.creditRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user credits")
+		return nil, errors.New("internal server error")
+	}
+
+	history := make([]creditHistory, 0, len(credits))
+	for _, credit := range credits {
+		schedule, err := s.creditRepo.GetPaymentSchedule(ctx, credit.ID)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to get payment schedule")
+			return nil, errors.New("internal server error")
+		}
+		history = append(history, creditHistory{credit: credit, schedule: schedule})
+	}
+
+	return history, nil
+}
+
+// monthlyIncome averages money received from outside the user's own accounts
+func (s *ScoringService) monthlyIncome(ctx context.Context, userID int64, now time.Time) (float64, error) {
+	accounts, err := s.accountRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user accounts")
+		return 0, errors.New("internal server error")
+	}
+
+	own := make(map[int64]bool, len(accounts))
+	for _, account := range accounts {
+		own[account.ID] = true
+	}
+
+	var inflow float64
+	from := now.AddDate(0, -incomeLookbackMonths, 0)
+	for _, account := range accounts {
+		transactions, err := s.accountRepo.GetTransactions(ctx, account.ID, from, now)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to get account transactions")
+			return 0, errors.New("internal server error")
+		}
+		for _, tx := range transactions {
+			if tx.ToAccountID == account.ID && !own[tx.FromAccountID] {
+				inflow += tx.Amount
+			}
+		}
+	}
+
+	return inflow / incomeLookbackMonths, nil
+}
+
+// graceDuration converts the grace period parameter into how late an installment
+// can be paid and still count as on time
+func graceDuration(days models.AppliedParameter) time.Duration {
+	return time.Duration(days.Value * float64(24*time.Hour))
+}
+
+func isInstallmentPaid(payment *models.PaymentSchedule) bool {
+	return strings.EqualFold(string(payment.Status), string(models.PaymentStatusPaid))
+}
+
+func isInstallmentArchived(payment *models.PaymentSchedule) bool {
+	return strings.EqualFold(string(payment.Status), string(models.PaymentStatusArchived))
+}
+
+// creditFactorsAt rebuilds the score inputs as they stood at the given moment.
+// An installment counts as paid from the time its status last changed, and one
+// replaced by a restructuring drops out of the schedule from the time it was archived.
+func creditFactorsAt(history []creditHistory, monthlyIncome float64, gracePeriod time.Duration, at time.Time) models.CreditHealthFactors {
+	var factors models.CreditHealthFactors
+	var total, outstanding float64
+
+	for _, h := range history {
+		if h.credit.CreatedAt.After(at) {
+			continue
+		}
+
+		var nextInstallment float64
+		for _, payment := range h.schedule {
+			if isInstallmentArchived(payment) && !payment.UpdatedAt.After(at) {
+				// Arrears carried into the restructured schedule still count against punctuality
+				if payment.DueDate.Before(payment.UpdatedAt) {
+					factors.OverduePayments++
+				}
+				continue
+			}
+
+			total += payment.Amount
+			paid := isInstallmentPaid(payment) && !payment.UpdatedAt.After(at)
+			if !paid {
+				outstanding += payment.Amount
+				if nextInstallment == 0 {
+					nextInstallment = payment.Amount
+				}
+			}
+
+			if payment.DueDate.After(at) {
+				continue
+			}
+			switch {
+			case paid && !payment.UpdatedAt.After(payment.DueDate.Add(gracePeriod)):
+				factors.OnTimePayments++
+			case paid:
+				factors.LatePayments++
+			default:
+				factors.OverduePayments++
+			}
+		}
+		factors.MonthlyObligations += nextInstallment
+	}
+
+	factors.Punctuality = 1
+	if due := factors.OnTimePayments + factors.LatePayments + factors.OverduePayments; due > 0 {
+		factors.Punctuality = float64(factors.OnTimePayments) / float64(due)
+	}
+
+	if total > 0 {
+		factors.Utilization = outstanding / total
+	}
+
+	factors.MonthlyIncome = roundMoney(monthlyIncome)
+	factors.MonthlyObligations = roundMoney(factors.MonthlyObligations)
+	switch {
+	case monthlyIncome > 0:
+		factors.CreditLoad = factors.MonthlyObligations / monthlyIncome
+	case factors.MonthlyObligations > 0:
+		factors.CreditLoad = 1
+	}
+
+	return factors
+}
+
+// scoreFactors combines the factors into a 0-100 score
+func scoreFactors(factors models.CreditHealthFactors) int {
+	load := math.Min(factors.CreditLoad/creditLoadCeiling, 1)
+	score := scoreWeightPunctuality*factors.Punctuality +
+		scoreWeightUtilization*(1-factors.Utilization) +
+		scoreWeightCreditLoad*(1-load)
+	return int(math.Round(score))
+}
+
+// creditScore is the factor score less a penalty for every installment overdue
+// now, so arrears weigh on the credit score beyond their share of punctuality
+func creditScore(factors models.CreditHealthFactors) int {
+	penalty := math.Min(float64(factors.OverduePayments*overduePenalty), overduePenaltyCap)
+	return int(math.Max(float64(scoreFactors(factors))-penalty, 0))
+}
+
+// scoreRateAdjustment is the change to the credit rate for a credit score: the
+// middle score is priced at the bank's rate, a perfect score at half the spread
+// below it and a zero score at half the spread above it
+func scoreRateAdjustment(score int, spread float64) float64 {
+	return roundMoney(spread * (50 - float64(score)) / 100)
+}
+
+// earlyRepaymentSaving estimates the interest saved by repaying an extra amount of
+// principal on an annuity credit with the given remaining number of installments,
+// assuming the installment is recalculated over the same term
+func earlyRepaymentSaving(amount, annualRate float64, remainingInstallments int) float64 {
+	if remainingInstallments <= 0 || annualRate <= 0 {
+		return 0
+	}
+	monthlyRate := annualRate / 12 / 100
+	annuityFactor := monthlyRate / (1 - math.Pow(1+monthlyRate, float64(-remainingInstallments)))
+	return amount * (float64(remainingInstallments)*annuityFactor - 1)
+}
+
+func recommendCreditActions(history []creditHistory, factors models.CreditHealthFactors, now time.Time) []models.CreditRecommendation {
+	var recommendations []models.CreditRecommendation
+
+	// Overdue installments hurt the score the most, so they come first
+	var bestCredit *models.Credit
+	var bestPending []*models.PaymentSchedule
+	for _, h := range history {
+		var pending []*models.PaymentSchedule
+		for _, payment := range h.schedule {
+			if !payment.IsOutstanding() {
+				continue
+			}
+			pending = append(pending, payment)
+			if payment.DueDate.Before(now) {
+				recommendations = append(recommendations, models.CreditRecommendation{
+					Type:     "pay_overdue",
+					CreditID: h.credit.ID,
+					Amount:   roundMoney(payment.Amount),
+					Message: fmt.Sprintf(
+						"Pay the overdue installment of %.2f due %s on credit #%d to stop late penalties",
+						payment.Amount, payment.DueDate.Format("2006-01-02"), h.credit.ID,
+					),
+				})
+			}
+		}
+		if len(pending) > 1 && (bestCredit == nil || h.credit.InterestRate > bestCredit.InterestRate) {
+			bestCredit = h.credit
+			bestPending = pending
+		}
+	}
+
+	// Suggest repaying one extra installment on the most expensive credit
+	if bestCredit != nil {
+		sort.Slice(bestPending, func(i, j int) bool { return bestPending[i].DueDate.Before(bestPending[j].DueDate) })
+		amount := roundMoney(bestPending[0].Amount)
+		saving := roundMoney(earlyRepaymentSaving(amount, bestCredit.InterestRate, len(bestPending)))
+		if saving > 0 {
+			recommendations = append(recommendations, models.CreditRecommendation{
+				Type:           "repay_early",
+				CreditID:       bestCredit.ID,
+				Amount:         amount,
+				InterestSaving: saving,
+				Message: fmt.Sprintf(
+					"Pay %.2f early on credit #%d to reduce interest by about %.2f",
+					amount, bestCredit.ID, saving,
+				),
+			})
+		}
+	}
+
+	if factors.Utilization > 0.8 {
+		recommendations = append(recommendations, models.CreditRecommendation{
+			Type:    "reduce_utilization",
+			Message: "Avoid new borrowing until less than 80% of your borrowed amount is outstanding",
+		})
+	}
+
+	if factors.CreditLoad > 0.4 {
+		recommendations = append(recommendations, models.CreditRecommendation{
+			Type: "reduce_load",
+			Message: fmt.Sprintf(
+				"Credit payments take %.0f%% of your monthly income; consider a longer term or restructuring",
+				factors.CreditLoad*100,
+			),
+		})
+	}
+
+	if len(recommendations) == 0 {
+		recommendations = append(recommendations, models.CreditRecommendation{
+			Type:    "keep_on_track",
+			Message: "Keep paying installments on time to maintain your score",
+		})
+	}
+
+	return recommendations
+}
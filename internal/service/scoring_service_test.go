@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestCreditScorePenalizesOverdueInstallments(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	credit := &models.Credit{ID: 1, CreatedAt: now.AddDate(0, -6, 0)}
+
+	schedule := func(overdue int) []*models.PaymentSchedule {
+		var payments []*models.PaymentSchedule
+		for i := 5; i > 0; i-- {
+			due := now.AddDate(0, -i, 0)
+			payment := &models.PaymentSchedule{Amount: 1000, DueDate: due, Status: models.PaymentStatusPaid, UpdatedAt: due}
+			if i <= overdue {
+				payment.Status = models.PaymentStatusOverdue
+			}
+			payments = append(payments, payment)
+		}
+		return append(payments, &models.PaymentSchedule{Amount: 1000, DueDate: now.AddDate(0, 1, 0), Status: models.PaymentStatusPending})
+	}
+
+	tests := []struct {
+		name       string
+		overdue    int
+		wantScore  int
+		wantRating models.CreditRating
+	}{
+		{name: "all paid on time", overdue: 0, wantScore: 92, wantRating: models.CreditRatingA},
+		{name: "one overdue", overdue: 1, wantScore: 73, wantRating: models.CreditRatingB},
+		{name: "three overdue", overdue: 3, wantScore: 34, wantRating: models.CreditRatingE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := []creditHistory{{credit: credit, schedule: schedule(tt.overdue)}}
+			factors := creditFactorsAt(history, 10000, 0, now)
+
+			score := creditScore(factors)
+			if score != tt.wantScore {
+				t.Errorf("score = %d, want %d", score, tt.wantScore)
+			}
+			if rating := models.RatingForScore(score); rating != tt.wantRating {
+				t.Errorf("rating = %s, want %s", rating, tt.wantRating)
+			}
+		})
+	}
+}
+
+func TestScoreRateAdjustment(t *testing.T) {
+	tests := []struct {
+		score int
+		want  float64
+	}{
+		{score: 100, want: -2},
+		{score: 50, want: 0},
+		{score: 35, want: 0.6},
+		{score: 0, want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := scoreRateAdjustment(tt.score, 4); got != tt.want {
+			t.Errorf("scoreRateAdjustment(%d, 4) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// minSearchTextLength is the shortest query matched as text, so that a couple of
+// letters cannot enumerate every customer
+const minSearchTextLength = 3
+
+// SearchService handles entity lookups for support staff and administrators
+type SearchService struct {
+	searchRepo *repository.SearchRepository
+	logger     *logrus.Logger
+}
+
+// NewSearchService creates a new SearchService instance
+func NewSearchService(searchRepo *repository.SearchRepository, logger *logrus.Logger) *SearchService {
+	return &SearchService{
+		searchRepo: searchRepo,
+		logger:     logger,
+	}
+}
+
+// Search looks the query up across users, accounts, cards, credits and
+// transactions. Card numbers are always masked; email addresses and phone
+// numbers are only shown to administrators.
+func (s *SearchService) Search(ctx context.Context, role models.UserRole, query string) ([]*models.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("search query is required")
+	}
+
+	var pattern string
+	if len([]rune(query)) >= minSearchTextLength {
+		pattern = "%" + escapeLikePattern(query) + "%"
+	}
+
+	// Card numbers and phone numbers are often typed with separators or a mask
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ' || r == '-' || r == '*' || r == '+' || r == '(' || r == ')':
+			return -1
+		default:
+			return 'x'
+		}
+	}, query)
+	if strings.ContainsRune(digits, 'x') {
+		digits = ""
+	}
+
+	reference, _ := strconv.ParseInt(strings.TrimPrefix(query, "#"), 10, 64)
+
+	if pattern == "" && digits == "" && reference <= 0 {
+		return nil, errors.New("search query is too short")
+	}
+
+	results, err := s.searchRepo.Search(ctx, query, pattern, reference, digits, models.MaxSearchResults)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	for _, result := range results {
+		if result.CardNumber != "" {
+			result.CardNumber = (&models.Card{CardNumber: result.CardNumber}).MaskNumber()
+		}
+		if role != models.RoleAdmin && (result.Email != "" || result.Phone != "") {
+			result.Email = redactEmail(result.Email)
+			result.Phone = redactPhone(result.Phone)
+			result.Redacted = true
+		}
+	}
+
+	return results, nil
+}
+
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// redactEmail keeps the first character of the local part and the domain
+func redactEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return ""
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// redactPhone keeps the last two digits of a phone number
+func redactPhone(phone string) string {
+	if len(phone) <= 2 {
+		return ""
+	}
+	return strings.Repeat("*", len(phone)-2) + phone[len(phone)-2:]
+}
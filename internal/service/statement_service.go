@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/integration/smtp"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/locale"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/pagination"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/statement"
+	"github.com/Abigotado/abi_banking/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// statementJobPayload identifies the delivery emailed by a monthly statement job
+type statementJobPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// accountStatementJobPayload identifies the account and the period of a
+// requested statement. The end of the period is exclusive.
+type accountStatementJobPayload struct {
+	UserID    int64     `json:"user_id"`
+	AccountID int64     `json:"account_id"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// StatementService emails monthly account statements to the users who opted in
+// and keeps them in object storage for download
+type StatementService struct {
+	repo                *repository.StatementRepository
+	settingsRepo        *repository.UserSettingsRepository
+	userRepo            repository.UserStore
+	accountRepo         repository.AccountStore
+	notificationService *NotificationService
+	jobRunner           *jobs.Runner
+	store               storage.Storage
+	urlExpiry           time.Duration
+	logger              *logrus.Logger
+}
+
+// NewStatementService creates a new StatementService instance and registers its
+// job handler with the runner
+func NewStatementService(
+	repo *repository.StatementRepository,
+	settingsRepo *repository.UserSettingsRepository,
+	userRepo repository.UserStore,
+	accountRepo repository.AccountStore,
+	notificationService *NotificationService,
+	jobRunner *jobs.Runner,
+	store storage.Storage,
+	urlExpiry time.Duration,
+	logger *logrus.Logger,
+) *StatementService {
+	s := &StatementService{
+		repo:                repo,
+		settingsRepo:        settingsRepo,
+		userRepo:            userRepo,
+		accountRepo:         accountRepo,
+		notificationService: notificationService,
+		jobRunner:           jobRunner,
+		store:               store,
+		urlExpiry:           urlExpiry,
+		logger:              logger,
+	}
+	jobRunner.Register(models.JobTypeMonthlyStatement, s.processStatementJob)
+	jobRunner.Register(models.JobTypeAccountStatement, s.processAccountStatementJob)
+	return s
+}
+
+// QueueMonthlyStatements queues the statements of the previous month for every
+// user whose month, in their own time zone, has ended. A user is queued on the
+// 1st; users who already received a statement are also caught up later, so a
+// missed 1st does not skip their month. It returns the number of users queued.
+func (s *StatementService) QueueMonthlyStatements(ctx context.Context, now time.Time) (int, error) {
+	recipients, err := s.repo.ListRecipients(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, recipient := range recipients {
+		local := now.In(userLocation(s.logger, recipient.TimeZone))
+		periodStart := time.Date(local.Year(), local.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+
+		if recipient.LastPeriodStart != nil && !recipient.LastPeriodStart.Before(periodStart) {
+			continue
+		}
+		if recipient.LastPeriodStart == nil && local.Day() != 1 {
+			continue
+		}
+
+		created, err := s.queueStatement(ctx, recipient.UserID, periodStart)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", recipient.UserID).Error("Failed to queue monthly statement")
+			continue
+		}
+		if created {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// queueStatement records the delivery of a month and queues its job in one transaction
+func (s *StatementService) queueStatement(ctx context.Context, userID int64, periodStart time.Time) (bool, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	delivery := &models.StatementDelivery{
+		UserID:      userID,
+		PeriodStart: periodStart,
+		Status:      models.StatementDeliveryStatusQueued,
+	}
+	created, err := s.repo.CreateTx(ctx, tx, delivery)
+	if err != nil || !created {
+		return false, err
+	}
+
+	if _, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeMonthlyStatement, statementJobPayload{DeliveryID: delivery.ID}); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// userLocation loads a time zone of the user settings, falling back to UTC
+func userLocation(logger *logrus.Logger, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.WithError(err).WithField("timezone", name).Warn("Unknown time zone, using UTC")
+		return time.UTC
+	}
+	return loc
+}
+
+// processStatementJob renders the statement of every account of the user for the
+// month of a delivery, stores them and emails them as PDF attachments. A retry
+// after a failed send renders and stores the statements again over the same
+// files; a delivery already sent is skipped.
+func (s *StatementService) processStatementJob(ctx context.Context, job *models.Job) error {
+	var payload statementJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid statement job payload: %w", err)
+	}
+
+	delivery, err := s.repo.GetByID(ctx, payload.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == models.StatementDeliveryStatusSent {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, delivery.UserID)
+	if err != nil {
+		return err
+	}
+	settings, err := s.settingsRepo.GetByUserID(ctx, delivery.UserID)
+	if err != nil {
+		return err
+	}
+
+	// The month is bounded in the user's time zone
+	loc := userLocation(s.logger, settings.TimeZone)
+	start := time.Date(delivery.PeriodStart.Year(), delivery.PeriodStart.Month(), 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 1, 0)
+
+	statements, err := s.renderStatements(ctx, user, settings.Language, start, end)
+	if err != nil {
+		return err
+	}
+
+	attachments := make([]smtp.Attachment, 0, len(statements))
+	for _, st := range statements {
+		if err := s.storeStatement(ctx, delivery, st); err != nil {
+			return err
+		}
+		attachments = append(attachments, st.attachment)
+	}
+
+	if len(attachments) > 0 {
+		subject, content := statementEmail(settings.Language, start)
+		if err := s.notificationService.EmailUser(ctx, user.ID, subject, content, attachments); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.MarkSent(ctx, delivery.ID, len(attachments))
+}
+
+// statementEmail returns the subject and the content of the email with the
+// statements of a month in a language
+func statementEmail(language string, month time.Time) (string, string) {
+	name := locale.FormatMonth(language, month)
+	if locale.Normalize(language) == locale.English {
+		return "Account statements for " + name, fmt.Sprintf("Your account statements for %s are attached.", name)
+	}
+	return "Выписки по счетам за " + name, fmt.Sprintf("Выписки по вашим счетам за %s приложены к письму.", name)
+}
+
+// renderedStatement is the PDF statement of an account
+type renderedStatement struct {
+	accountID  int64
+	attachment smtp.Attachment
+}
+
+// renderStatements renders the statements of the accounts a user held during a period
+func (s *StatementService) renderStatements(ctx context.Context, user *models.User, language string, start, end time.Time) ([]renderedStatement, error) {
+	accounts, err := s.accountRepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, errors.New("failed to get user accounts")
+	}
+
+	// The closing balance is the current balance less what happened since
+	now := time.Now()
+	var statements []renderedStatement
+	for _, account := range accounts {
+		// Accounts opened after the month or closed before it have no statement
+		if !account.CreatedAt.Before(end) || (account.IsClosed() && account.ClosedAt.Before(start)) {
+			continue
+		}
+
+		// The end of the statement period is exclusive, the repository bounds are not
+		transactions, err := s.accountRepo.GetTransactions(ctx, account.ID, start, end.Add(-time.Nanosecond))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transactions of account %d: %w", account.ID, err)
+		}
+		later, err := s.accountRepo.GetTransactions(ctx, account.ID, end, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transactions of account %d: %w", account.ID, err)
+		}
+
+		st := statement.New(account, user.Username, language, start, end, transactions, later)
+		statements = append(statements, renderedStatement{
+			accountID: account.ID,
+			attachment: smtp.Attachment{
+				Name:        st.FileName(),
+				ContentType: "application/pdf",
+				Data:        statement.RenderPDF(st),
+			},
+		})
+	}
+
+	return statements, nil
+}
+
+// storeStatement keeps a rendered statement of a delivery in object storage. The
+// key is fixed by the user, the month and the file name, so storing it again
+// replaces the file.
+func (s *StatementService) storeStatement(ctx context.Context, delivery *models.StatementDelivery, st renderedStatement) error {
+	file := &models.StatementFile{
+		DeliveryID:  delivery.ID,
+		UserID:      delivery.UserID,
+		AccountID:   st.accountID,
+		PeriodStart: delivery.PeriodStart,
+		StorageKey: fmt.Sprintf("statements/%d/%s/%s", delivery.UserID, delivery.PeriodStart.Format("2006-01"),
+			st.attachment.Name),
+		FileName: st.attachment.Name,
+		Size:     int64(len(st.attachment.Data)),
+	}
+	if err := s.store.Put(ctx, file.StorageKey, st.attachment.ContentType, st.attachment.Data); err != nil {
+		return fmt.Errorf("failed to store statement %s: %w", file.StorageKey, err)
+	}
+	return s.repo.CreateFile(ctx, file)
+}
+
+// QueueAccountStatement queues the rendering of the PDF statement of an account
+// for the days from start to end inclusive. The user follows the returned job
+// and downloads the statement from it.
+func (s *StatementService) QueueAccountStatement(ctx context.Context, userID, accountID int64, start, end time.Time) (*models.Job, error) {
+	if end.Before(start) {
+		return nil, errors.New("end date is before start date")
+	}
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil || authorizeAccount(ctx, s.accountRepo, account, userID, false) != nil {
+		return nil, errors.New("account not found")
+	}
+
+	payload := accountStatementJobPayload{
+		UserID:    userID,
+		AccountID: account.ID,
+		Start:     start,
+		End:       end.AddDate(0, 0, 1),
+	}
+	job, err := s.jobRunner.Enqueue(ctx, models.JobTypeAccountStatement, payload, jobs.OwnedBy(userID))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to queue account statement job")
+		return nil, errors.New("internal server error")
+	}
+	return job, nil
+}
+
+// QueueFinalStatementTx queues, within the transaction closing an account, the
+// statement of its whole history from the day it was opened up to its closure
+func (s *StatementService) QueueFinalStatementTx(ctx context.Context, tx repository.Tx, userID int64, account *models.Account, closedAt time.Time) (*models.Job, error) {
+	payload := accountStatementJobPayload{
+		UserID:    userID,
+		AccountID: account.ID,
+		Start:     calendarDay(account.CreatedAt, account.CreatedAt.Location()),
+		End:       calendarDay(closedAt, closedAt.Location()).AddDate(0, 0, 1),
+	}
+	job, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeAccountStatement, payload, jobs.OwnedBy(userID))
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to queue final statement of account %d", account.ID)
+		return nil, err
+	}
+	return job, nil
+}
+
+// processAccountStatementJob renders a requested statement in the language of
+// the user's settings and stores it as the result of the job
+func (s *StatementService) processAccountStatementJob(ctx context.Context, job *models.Job) error {
+	var payload accountStatementJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid account statement job payload: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, payload.UserID)
+	if err != nil {
+		return err
+	}
+	settings, err := s.settingsRepo.GetByUserID(ctx, payload.UserID)
+	if err != nil {
+		return err
+	}
+	// The user may have lost access to the account since the job was queued
+	account, err := s.accountRepo.GetByID(ctx, payload.AccountID)
+	if err != nil || authorizeAccount(ctx, s.accountRepo, account, payload.UserID, false) != nil {
+		return errors.New("account not found")
+	}
+
+	// The end of the statement period is exclusive, the repository bounds are not
+	transactions, err := s.accountRepo.GetTransactions(ctx, account.ID, payload.Start, payload.End.Add(-time.Nanosecond))
+	if err != nil {
+		return fmt.Errorf("failed to get transactions of account %d: %w", account.ID, err)
+	}
+	later, err := s.accountRepo.GetTransactions(ctx, account.ID, payload.End, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get transactions of account %d: %w", account.ID, err)
+	}
+
+	st := statement.New(account, user.Username, settings.Language, payload.Start, payload.End, transactions, later)
+	return storeJobFile(ctx, s.store, s.jobRunner, job, st.FileName(), "application/pdf", statement.RenderPDF(st))
+}
+
+// ListStatements returns a page of the stored statements of a user and how many there are
+func (s *StatementService) ListStatements(ctx context.Context, userID int64, p pagination.Params) ([]*models.StatementFile, int, error) {
+	files, total, err := s.repo.ListFiles(ctx, userID, p)
+	if err != nil {
+		return nil, 0, errors.New("internal server error")
+	}
+	return files, total, nil
+}
+
+// StatementURL returns a signed URL to download a stored statement of a user
+func (s *StatementService) StatementURL(ctx context.Context, userID, fileID int64) (*models.SignedURL, error) {
+	file, err := s.repo.GetFile(ctx, fileID)
+	if err != nil {
+		if err.Error() == "statement not found" {
+			return nil, err
+		}
+		return nil, errors.New("internal server error")
+	}
+	// Another user's statement is reported as missing, not as forbidden
+	if file.UserID != userID {
+		return nil, errors.New("statement not found")
+	}
+
+	url, err := signedURL(ctx, s.store, file.StorageKey, s.urlExpiry)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to sign URL of statement %d", file.ID)
+		return nil, errors.New("internal server error")
+	}
+	return url, nil
+}
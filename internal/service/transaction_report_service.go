@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// TransactionReportService breaks the transactions of a user down by category
+// and by month for the spreadsheet exports of the analytics
+type TransactionReportService struct {
+	repo   *repository.ForecastRepository
+	logger *logrus.Logger
+}
+
+// NewTransactionReportService creates a new TransactionReportService instance
+func NewTransactionReportService(repo *repository.ForecastRepository, logger *logrus.Logger) *TransactionReportService {
+	return &TransactionReportService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// GetReport breaks the transactions of a user from from up to to down by
+// category and by month, the months bounded in the location of from.
+// Transfers between the user's own accounts are left out.
+func (s *TransactionReportService) GetReport(ctx context.Context, userID int64, from, to time.Time) (*models.TransactionReport, error) {
+	if !from.Before(to) {
+		return nil, errors.New("start date must be before end date")
+	}
+
+	flows, err := s.repo.ListCashFlows(ctx, userID, from, to)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	report := buildTransactionReport(flows, from.Location())
+	report.From, report.To = from, to
+	return report, nil
+}
+
+// buildTransactionReport sums up cash flows by category and currency, and by
+// month and currency, sorted by category or month and then currency
+func buildTransactionReport(flows []*models.CashFlow, loc *time.Location) *models.TransactionReport {
+	type key struct{ group, currency string }
+	categories := make(map[key]*models.CategoryTotal)
+	months := make(map[key]*models.MonthlySummary)
+
+	for _, flow := range flows {
+		category := models.ReportCategoryIncome
+		if !flow.Incoming {
+			var ok bool
+			if category, ok = spendingCategory(flow.Type, flow.BillerCategory); !ok {
+				category = models.ReportCategoryOther
+			}
+		}
+		c, ok := categories[key{category, flow.Currency}]
+		if !ok {
+			c = &models.CategoryTotal{Category: category, Currency: flow.Currency}
+			categories[key{category, flow.Currency}] = c
+		}
+		c.Transactions++
+		c.Amount = roundMoney(c.Amount + flow.Amount)
+
+		month := flow.CreatedAt.In(loc).Format("2006-01")
+		m, ok := months[key{month, flow.Currency}]
+		if !ok {
+			m = &models.MonthlySummary{Month: month, Currency: flow.Currency}
+			months[key{month, flow.Currency}] = m
+		}
+		m.Transactions++
+		if flow.Incoming {
+			m.Income = roundMoney(m.Income + flow.Amount)
+		} else {
+			m.Expenses = roundMoney(m.Expenses + flow.Amount)
+		}
+		m.Net = roundMoney(m.Income - m.Expenses)
+	}
+
+	report := &models.TransactionReport{
+		Categories: make([]*models.CategoryTotal, 0, len(categories)),
+		Months:     make([]*models.MonthlySummary, 0, len(months)),
+	}
+	for _, c := range categories {
+		report.Categories = append(report.Categories, c)
+	}
+	for _, m := range months {
+		report.Months = append(report.Months, m)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Currency < b.Currency
+	})
+	sort.Slice(report.Months, func(i, j int) bool {
+		a, b := report.Months[i], report.Months[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		return a.Currency < b.Currency
+	})
+	return report
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+func TestBuildTransactionReport(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	flows := []*models.CashFlow{
+		{Type: models.TransactionTypeDeposit, Incoming: true, Amount: 50000, Currency: "RUB",
+			CreatedAt: time.Date(2026, 9, 5, 10, 0, 0, 0, time.UTC)},
+		{Type: models.TransactionTypeCardPayment, Amount: 1200.5, Currency: "RUB",
+			CreatedAt: time.Date(2026, 9, 10, 10, 0, 0, 0, time.UTC)},
+		{Type: models.TransactionTypeBillPayment, BillerCategory: models.BillerCategoryMobile, Amount: 600, Currency: "RUB",
+			CreatedAt: time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC)}, // October 1st in Moscow
+		{Type: models.TransactionTypeCardPayment, Amount: 99.5, Currency: "RUB",
+			CreatedAt: time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC)},
+		{Type: models.TransactionTypeDepositOpening, Amount: 10000, Currency: "RUB",
+			CreatedAt: time.Date(2026, 10, 3, 10, 0, 0, 0, time.UTC)},
+		{Type: models.TransactionTypeCardPayment, Amount: 20, Currency: "USD",
+			CreatedAt: time.Date(2026, 10, 4, 10, 0, 0, 0, time.UTC)},
+	}
+
+	report := buildTransactionReport(flows, moscow)
+
+	wantCategories := []models.CategoryTotal{
+		{Category: models.SpendingCategoryCardPayments, Currency: "RUB", Transactions: 2, Amount: 1300},
+		{Category: models.SpendingCategoryCardPayments, Currency: "USD", Transactions: 1, Amount: 20},
+		{Category: models.ReportCategoryIncome, Currency: "RUB", Transactions: 1, Amount: 50000},
+		{Category: models.SpendingCategoryMobile, Currency: "RUB", Transactions: 1, Amount: 600},
+		{Category: models.ReportCategoryOther, Currency: "RUB", Transactions: 1, Amount: 10000},
+	}
+	if len(report.Categories) != len(wantCategories) {
+		t.Fatalf("buildTransactionReport() = %d categories, want %d", len(report.Categories), len(wantCategories))
+	}
+	for i, want := range wantCategories {
+		if got := *report.Categories[i]; got != want {
+			t.Errorf("category %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	wantMonths := []models.MonthlySummary{
+		{Month: "2026-09", Currency: "RUB", Transactions: 2, Income: 50000, Expenses: 1200.5, Net: 48799.5},
+		{Month: "2026-10", Currency: "RUB", Transactions: 3, Expenses: 10699.5, Net: -10699.5},
+		{Month: "2026-10", Currency: "USD", Transactions: 1, Expenses: 20, Net: -20},
+	}
+	if len(report.Months) != len(wantMonths) {
+		t.Fatalf("buildTransactionReport() = %d months, want %d", len(report.Months), len(wantMonths))
+	}
+	for i, want := range wantMonths {
+		if got := *report.Months[i]; got != want {
+			t.Errorf("month %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/fraud"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// transferBatchJobPayload identifies the batch processed by a transfer batch job
+type transferBatchJobPayload struct {
+	BatchID int64 `json:"batch_id"`
+}
+
+// TransferBatchService handles batches of transfers from one account, such as
+// payroll payouts. The entries are checked when the batch is created and the
+// transfers are made by a background job, screened by the fraud rules like
+// single transfers.
+type TransferBatchService struct {
+	batchRepo      *repository.TransferBatchRepository
+	accountRepo    repository.AccountStore
+	accountService *AccountService
+	jobRunner      *jobs.Runner
+	cfg            *config.TransferBatchesConfig
+	logger         *logrus.Logger
+}
+
+// NewTransferBatchService creates a new TransferBatchService instance and
+// registers its job with the job runner
+func NewTransferBatchService(
+	batchRepo *repository.TransferBatchRepository,
+	accountRepo repository.AccountStore,
+	accountService *AccountService,
+	jobRunner *jobs.Runner,
+	cfg *config.TransferBatchesConfig,
+	logger *logrus.Logger,
+) *TransferBatchService {
+	s := &TransferBatchService{
+		batchRepo:      batchRepo,
+		accountRepo:    accountRepo,
+		accountService: accountService,
+		jobRunner:      jobRunner,
+		cfg:            cfg,
+		logger:         logger,
+	}
+	jobRunner.Register(models.JobTypeTransferBatch, s.processBatchJob)
+	return s
+}
+
+// CreateBatch checks every entry of a batch, records it and queues the job that
+// makes its transfers. An entry that cannot be made, such as one to an unknown
+// account or in another currency, turns the whole batch down.
+func (s *TransferBatchService) CreateBatch(ctx context.Context, userID int64, req *models.CreateTransferBatchRequest, client models.ClientInfo) (*models.TransferBatch, error) {
+	batch, err := s.prepareBatch(ctx, userID, req, client)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.batchRepo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.batchRepo.CreateTx(ctx, tx, batch); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	job, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeTransferBatch, transferBatchJobPayload{BatchID: batch.ID}, jobs.OwnedBy(userID))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to queue transfer batch job")
+		return nil, errors.New("internal server error")
+	}
+	if err := s.batchRepo.SetJobTx(ctx, tx, batch.ID, job.ID); err != nil {
+		return nil, errors.New("internal server error")
+	}
+	batch.JobID = &job.ID
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return batch, nil
+}
+
+// prepareBatch validates a batch request and resolves the recipient of each
+// entry. An atomic batch must be covered by the available balance as a whole.
+func (s *TransferBatchService) prepareBatch(ctx context.Context, userID int64, req *models.CreateTransferBatchRequest, client models.ClientInfo) (*models.TransferBatch, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = models.TransferBatchModePerItem
+	}
+	if mode != models.TransferBatchModeAtomic && mode != models.TransferBatchModePerItem {
+		return nil, errors.New("mode must be atomic or per_item")
+	}
+	if len(req.Transfers) == 0 {
+		return nil, errors.New("transfer list is empty")
+	}
+	if len(req.Transfers) > s.cfg.MaxTransfers {
+		return nil, fmt.Errorf("a batch can contain at most %d transfers", s.cfg.MaxTransfers)
+	}
+
+	source, err := s.accountRepo.GetByID(ctx, req.FromAccountID)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if err := authorizeAccount(ctx, s.accountRepo, source, userID, true); err != nil {
+		return nil, err
+	}
+
+	batch := &models.TransferBatch{
+		UserID:         userID,
+		FromAccountID:  source.ID,
+		Mode:           mode,
+		Status:         models.TransferBatchStatusPending,
+		TotalTransfers: len(req.Transfers),
+		Client:         client,
+		Items:          make([]*models.TransferBatchItem, 0, len(req.Transfers)),
+	}
+	for i, entry := range req.Transfers {
+		recipient, err := s.resolveRecipient(ctx, entry)
+		if err != nil {
+			return nil, fmt.Errorf("transfer %d: %w", i+1, err)
+		}
+		switch {
+		case entry.Amount <= 0 || math.IsNaN(entry.Amount):
+			return nil, fmt.Errorf("transfer %d: amount must be greater than zero", i+1)
+		case recipient.ID == source.ID:
+			return nil, fmt.Errorf("transfer %d: cannot transfer to the same account", i+1)
+		case recipient.Currency != source.Currency:
+			return nil, fmt.Errorf("transfer %d: currency mismatch between accounts", i+1)
+		}
+
+		batch.TotalAmount += entry.Amount
+		batch.Items = append(batch.Items, &models.TransferBatchItem{
+			Position:    i + 1,
+			ToAccountID: recipient.ID,
+			Amount:      entry.Amount,
+			Reference:   strings.TrimSpace(entry.Reference),
+			Status:      models.TransferBatchItemStatusPending,
+		})
+	}
+	batch.TotalAmount = math.Round(batch.TotalAmount*100) / 100
+
+	if mode == models.TransferBatchModeAtomic && source.AvailableBalance() < batch.TotalAmount {
+		return nil, errors.New("insufficient funds for the batch")
+	}
+	return batch, nil
+}
+
+// resolveRecipient finds the account an entry transfers to by its ID or number
+func (s *TransferBatchService) resolveRecipient(ctx context.Context, entry models.TransferBatchEntry) (*models.Account, error) {
+	if entry.ToAccountNumber == "" {
+		if entry.ToAccountID == 0 {
+			return nil, errors.New("to_account_id or to_account_number is required")
+		}
+		recipient, err := s.accountRepo.GetByID(ctx, entry.ToAccountID)
+		if err != nil {
+			return nil, errors.New("account not found")
+		}
+		return recipient, nil
+	}
+
+	recipient, err := s.accountRepo.GetByNumber(ctx, entry.ToAccountNumber)
+	if err != nil {
+		return nil, errors.New("account not found")
+	}
+	if entry.ToAccountID != 0 && entry.ToAccountID != recipient.ID {
+		return nil, errors.New("to_account_id and to_account_number refer to different accounts")
+	}
+	return recipient, nil
+}
+
+// GetBatch retrieves a batch of the user with the status of each transfer
+func (s *TransferBatchService) GetBatch(ctx context.Context, userID, batchID int64) (*models.TransferBatch, error) {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, errors.New("transfer batch not found")
+	}
+
+	items, err := s.batchRepo.GetItems(ctx, batchID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	batch.Items = items
+	return batch, nil
+}
+
+// processBatchJob makes the transfers of a batch and links the job to the
+// batch report
+func (s *TransferBatchService) processBatchJob(ctx context.Context, job *models.Job) error {
+	var payload transferBatchJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid transfer batch job payload: %w", err)
+	}
+
+	if err := s.processBatch(ctx, payload.BatchID); err != nil {
+		return err
+	}
+	return s.jobRunner.SetResult(ctx, job.ID, &models.JobResult{Resource: fmt.Sprintf("/transfers/batch/%d", payload.BatchID)})
+}
+
+// processBatch makes the transfers of a batch that are still pending, so a
+// retried job resumes where the previous attempt stopped
+func (s *TransferBatchService) processBatch(ctx context.Context, batchID int64) error {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if batch.CompletedAt != nil {
+		return nil
+	}
+
+	if err := s.batchRepo.UpdateStatus(ctx, batch.ID, models.TransferBatchStatusProcessing); err != nil {
+		return err
+	}
+	items, err := s.batchRepo.GetItems(ctx, batch.ID)
+	if err != nil {
+		return err
+	}
+
+	// The user may have lost the right to operate the account since the batch
+	// was created
+	source, err := s.accountRepo.GetByID(ctx, batch.FromAccountID)
+	if err == nil {
+		err = authorizeAccount(ctx, s.accountRepo, source, batch.UserID, true)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return s.batchRepo.Fail(ctx, batch.ID, err.Error(), err.Error())
+	}
+
+	if batch.Mode == models.TransferBatchModeAtomic {
+		return s.processAtomic(ctx, batch, items)
+	}
+
+	for _, item := range items {
+		if item.Status != models.TransferBatchItemStatusPending {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := s.transferItem(ctx, batch, item)
+		var held *fraud.HoldError
+		switch {
+		case errors.As(err, &held):
+			err = s.batchRepo.MarkItemHeld(ctx, item.ID, held.CheckID, "")
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.WithError(err).WithField("batch_item_id", item.ID).Warn("Transfer of a batch failed")
+			err = s.batchRepo.MarkItemFailed(ctx, item.ID, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.batchRepo.Finalize(ctx, batch.ID)
+}
+
+// transferItem makes the transfer of one item and records it in the same
+// transaction
+func (s *TransferBatchService) transferItem(ctx context.Context, batch *models.TransferBatch, item *models.TransferBatchItem) error {
+	tx, err := s.batchRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transaction, err := s.accountService.TransferTx(ctx, tx, itemTransfer(batch, item), batch.Client)
+	if err != nil {
+		return err
+	}
+	if err := s.batchRepo.MarkItemCompletedTx(ctx, tx, item.ID, transaction.ID); err != nil {
+		return errors.New("internal server error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// processAtomic makes all the transfers of a batch in one transaction. The
+// first transfer that fails or is held by the fraud rules rolls back the others
+// and fails the batch.
+func (s *TransferBatchService) processAtomic(ctx context.Context, batch *models.TransferBatch, items []*models.TransferBatchItem) error {
+	tx, err := s.batchRepo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		transaction, err := s.accountService.TransferTx(ctx, tx, itemTransfer(batch, item), batch.Client)
+		if err == nil {
+			err = s.batchRepo.MarkItemCompletedTx(ctx, tx, item.ID, transaction.ID)
+			if err == nil {
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tx.Rollback()
+
+		// The held check stays in the review queue: releasing it makes this
+		// transfer alone
+		var held *fraud.HoldError
+		if errors.As(err, &held) {
+			err = s.batchRepo.MarkItemHeld(ctx, item.ID, held.CheckID, "held for fraud review, the batch was not made")
+		} else {
+			err = s.batchRepo.MarkItemFailed(ctx, item.ID, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("transfer %d was not made", item.Position)
+		return s.batchRepo.Fail(ctx, batch.ID, "not made: "+reason, reason)
+	}
+
+	if err := s.batchRepo.FinalizeTx(ctx, tx, batch.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// itemTransfer returns the transfer request of a batch item
+func itemTransfer(batch *models.TransferBatch, item *models.TransferBatchItem) *models.TransferRequest {
+	return &models.TransferRequest{
+		FromAccountID: batch.FromAccountID,
+		ToAccountID:   item.ToAccountID,
+		Amount:        item.Amount,
+	}
+}
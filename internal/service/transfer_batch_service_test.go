@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func newTransferBatchTestService(t *testing.T) *TransferBatchService {
+	t.Helper()
+	env := newTestEnv(t)
+	accounts := map[int64]*models.Account{
+		1: {ID: 1, UserID: 7, Number: "40817810000000000011", Currency: "RUB", Balance: 1000},
+		2: {ID: 2, UserID: 8, Number: "40817810000000000022", Currency: "RUB"},
+		3: {ID: 3, UserID: 9, Number: "40817840000000000033", Currency: "USD"},
+	}
+	accountStore := &mock.AccountStore{
+		GetByIDFunc: func(_ context.Context, id int64) (*models.Account, error) {
+			account, ok := accounts[id]
+			if !ok {
+				return nil, errors.New("account not found")
+			}
+			copied := *account
+			return &copied, nil
+		},
+		GetByNumberFunc: func(_ context.Context, number string) (*models.Account, error) {
+			for _, account := range accounts {
+				if account.Number == number {
+					copied := *account
+					return &copied, nil
+				}
+			}
+			return nil, errors.New("account not found")
+		},
+		GetMemberRoleFunc: func(context.Context, int64, int64) (models.AccountRole, error) {
+			return "", errors.New("not a member")
+		},
+	}
+	jobRunner := jobs.NewRunner(repository.NewJobRepository(env.db, env.logger), &env.cfg.Jobs, env.logger)
+	return NewTransferBatchService(
+		repository.NewTransferBatchRepository(env.db, env.logger), accountStore, nil, jobRunner,
+		&config.TransferBatchesConfig{MaxTransfers: 3}, env.logger,
+	)
+}
+
+func TestTransferBatchServiceCreateBatchValidation(t *testing.T) {
+	s := newTransferBatchTestService(t)
+	entry := func(toAccountID int64, amount float64) models.TransferBatchEntry {
+		return models.TransferBatchEntry{ToAccountID: toAccountID, Amount: amount}
+	}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		req     models.CreateTransferBatchRequest
+		wantErr string
+	}{
+		{
+			name:    "empty batch",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1},
+			wantErr: "transfer list is empty",
+		},
+		{
+			name:    "too many transfers",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{entry(2, 1), entry(2, 1), entry(2, 1), entry(2, 1)}},
+			wantErr: "at most 3 transfers",
+		},
+		{
+			name:    "unknown mode",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Mode: "parallel", Transfers: []models.TransferBatchEntry{entry(2, 1)}},
+			wantErr: "mode must be",
+		},
+		{
+			name:    "account of another user",
+			userID:  8,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{entry(2, 1)}},
+			wantErr: "unauthorized",
+		},
+		{
+			name:    "unknown recipient",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{entry(2, 1), entry(42, 1)}},
+			wantErr: "transfer 2: account not found",
+		},
+		{
+			name:    "same account",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{entry(1, 1)}},
+			wantErr: "transfer 1: cannot transfer to the same account",
+		},
+		{
+			name:    "currency mismatch",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{entry(2, 1), entry(3, 1)}},
+			wantErr: "transfer 2: currency mismatch",
+		},
+		{
+			name:   "number of another account",
+			userID: 7,
+			req: models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{
+				{ToAccountID: 2, ToAccountNumber: "40817840000000000033", Amount: 1},
+			}},
+			wantErr: "refer to different accounts",
+		},
+		{
+			name:    "atomic batch over the balance",
+			userID:  7,
+			req:     models.CreateTransferBatchRequest{FromAccountID: 1, Mode: models.TransferBatchModeAtomic, Transfers: []models.TransferBatchEntry{entry(2, 600), entry(2, 500)}},
+			wantErr: "insufficient funds",
+		},
+		{
+			name:   "per_item batch over the balance",
+			userID: 7,
+			req: models.CreateTransferBatchRequest{FromAccountID: 1, Transfers: []models.TransferBatchEntry{
+				entry(2, 600), {ToAccountNumber: "40817810000000000022", Amount: 500},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := s.CreateBatch(context.Background(), tt.userID, &tt.req, models.ClientInfo{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("create batch: %v", err)
+			}
+			if batch.Mode != models.TransferBatchModePerItem || batch.TotalAmount != 1100 || len(batch.Items) != 2 {
+				t.Fatalf("batch %+v", batch)
+			}
+			for i, item := range batch.Items {
+				if item.Position != i+1 || item.ToAccountID != 2 || item.Status != models.TransferBatchItemStatusPending {
+					t.Errorf("item %d: %+v", i, item)
+				}
+			}
+			if batch.JobID == nil {
+				t.Error("batch was not linked to its job")
+			}
+		})
+	}
+}
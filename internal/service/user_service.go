@@ -1,61 +1,153 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/events"
 	"github.com/Abigotado/abi_banking/internal/middleware"
 	"github.com/Abigotado/abi_banking/internal/models"
 	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
-	logger   *logrus.Logger
+	userRepo            repository.UserStore
+	settingsRepo        *repository.UserSettingsRepository
+	sessionRepo         *repository.SessionRepository
+	loginAttemptRepo    *repository.LoginAttemptRepository
+	auditRepo           *repository.AuditRepository
+	notificationService *NotificationService
+	passwordPolicy      *PasswordPolicy
+	branchService       *BranchService
+	events              *events.Publisher
+	cfg                 *config.LoginConfig
+	logger              *logrus.Logger
 }
 
-func NewUserService(logger *logrus.Logger) *UserService {
+var _ middleware.SessionValidator = (*UserService)(nil)
+
+func NewUserService(
+	userRepo repository.UserStore,
+	settingsRepo *repository.UserSettingsRepository,
+	sessionRepo *repository.SessionRepository,
+	loginAttemptRepo *repository.LoginAttemptRepository,
+	auditRepo *repository.AuditRepository,
+	notificationService *NotificationService,
+	passwordPolicy *PasswordPolicy,
+	branchService *BranchService,
+	publisher *events.Publisher,
+	cfg *config.LoginConfig,
+	logger *logrus.Logger,
+) *UserService {
 	return &UserService{
-		userRepo: repository.NewUserRepository(),
-		logger:   logger,
+		userRepo:            userRepo,
+		settingsRepo:        settingsRepo,
+		sessionRepo:         sessionRepo,
+		loginAttemptRepo:    loginAttemptRepo,
+		auditRepo:           auditRepo,
+		notificationService: notificationService,
+		passwordPolicy:      passwordPolicy,
+		branchService:       branchService,
+		events:              publisher,
+		cfg:                 cfg,
+		logger:              logger,
 	}
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"` // checked against the password policy
+	// BranchCode selects the branch of the user, the default branch when empty
+	BranchCode string `json:"branch_code" validate:"omitempty,alphanum,max=20"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"device_name" validate:"omitempty,max=100"` // shown in the list of sessions
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token      string `json:"token"`
+	SigningKey string `json:"signing_key"` // signs requests to replay-protected routes
+}
+
+func (s *UserService) Register(ctx context.Context, req *RegisterRequest) error {
+	user, err := s.createUser(ctx, req, models.RoleUser, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.events.Publish(ctx, events.TypeUserRegistered, user.ID, events.UserRegistered{
+		UserID:       user.ID,
+		Username:     user.Username,
+		BranchID:     user.BranchID,
+		RegisteredAt: user.CreatedAt.UTC(),
+	}); err != nil {
+		s.logger.WithError(err).Errorf("Failed to record user registered event for user %d", user.ID)
+	}
+
+	return nil
 }
 
-func (s *UserService) Register(req *RegisterRequest) error {
+// CreateAdmin creates an administrator. The API only lets administrators
+// manage users, so the first one is created by an operator with abictl; the
+// creation is audited as done by the new administrator.
+func (s *UserService) CreateAdmin(ctx context.Context, req *RegisterRequest) (*models.User, error) {
+	user, err := s.createUser(ctx, req, models.RoleAdmin, func(tx repository.Tx, user *models.User) error {
+		return s.auditRepo.CreateTx(ctx, tx, &models.AuditLog{
+			ActorID:    user.ID,
+			Action:     models.AuditActionAdminCreated,
+			EntityType: "user",
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// createUser creates a user with the role and the default settings after the
+// checks of a registration. record, when given, writes in the same transaction,
+// so the user is never created without it.
+func (s *UserService) createUser(ctx context.Context, req *RegisterRequest, role models.UserRole, record func(repository.Tx, *models.User) error) (*models.User, error) {
 	// Check if email exists
-	emailExists, err := s.userRepo.CheckEmailExists(req.Email)
+	emailExists, err := s.userRepo.CheckEmailExists(ctx, req.Email)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to check email existence")
-		return errors.New("internal server error")
+		return nil, errors.New("internal server error")
 	}
 	if emailExists {
-		return errors.New("email already exists")
+		return nil, errors.New("email already exists")
 	}
 
 	// Check if username exists
-	usernameExists, err := s.userRepo.CheckUsernameExists(req.Username)
+	usernameExists, err := s.userRepo.CheckUsernameExists(ctx, req.Username)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to check username existence")
-		return errors.New("internal server error")
+		return nil, errors.New("internal server error")
 	}
 	if usernameExists {
-		return errors.New("username already exists")
+		return nil, errors.New("username already exists")
+	}
+
+	if err := s.passwordPolicy.Check(ctx, req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
+	branch, err := s.branchService.RegistrationBranch(ctx, req.BranchCode)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create user
@@ -63,6 +155,8 @@ func (s *UserService) Register(req *RegisterRequest) error {
 		Username:  req.Username,
 		Email:     req.Email,
 		Password:  req.Password,
+		Role:      role,
+		BranchID:  branch.ID,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -70,45 +164,202 @@ func (s *UserService) Register(req *RegisterRequest) error {
 	// Hash password
 	if err := user.HashPassword(); err != nil {
 		s.logger.WithError(err).Error("Failed to hash password")
-		return errors.New("internal server error")
+		return nil, errors.New("internal server error")
+	}
+
+	// Save the user together with the default settings
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to begin transaction")
+		return nil, errors.New("internal server error")
 	}
+	defer tx.Rollback()
 
-	// Save user
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.CreateTx(ctx, tx, user); err != nil {
 		s.logger.WithError(err).Error("Failed to create user")
-		return errors.New("internal server error")
+		return nil, errors.New("internal server error")
 	}
 
-	return nil
+	if err := s.settingsRepo.UpsertTx(ctx, tx, models.DefaultUserSettings(user.ID)); err != nil {
+		s.logger.WithError(err).Errorf("Failed to create default settings for user %d", user.ID)
+		return nil, errors.New("internal server error")
+	}
+
+	if record != nil {
+		if err := record(tx, user); err != nil {
+			s.logger.WithError(err).Errorf("Failed to record the creation of user %d", user.ID)
+			return nil, errors.New("internal server error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.WithError(err).Error("Failed to commit user registration")
+		return nil, errors.New("internal server error")
+	}
+
+	return user, nil
 }
 
-func (s *UserService) Login(req *LoginRequest) (*LoginResponse, error) {
+// Login checks the credentials and opens a session for the device, described
+// by the user agent and the client address in ctx, with a token issued for it.
+// Too many failed logins from the client address are refused for a while, and
+// too many in a row for a user lock the user out.
+func (s *UserService) Login(ctx context.Context, req *LoginRequest, userAgent string) (*LoginResponse, error) {
+	now := time.Now()
+	ip := middleware.GetClientInfoFromContext(ctx).IP
+	attempt := &models.LoginAttempt{Email: req.Email, IPAddress: ip}
+
+	if ip != "" && s.cfg.MaxFailedAttemptsPerIP > 0 {
+		failures, err := s.loginAttemptRepo.CountFailedByIP(ctx, ip, now.Add(-s.cfg.FailureWindow))
+		if err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if failures >= s.cfg.MaxFailedAttemptsPerIP {
+			return nil, errors.New("too many failed login attempts, try again later")
+		}
+	}
+
 	// Get user by email
-	user, err := s.userRepo.GetByEmail(req.Email)
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user by email")
+		s.recordLoginAttempt(ctx, attempt)
 		return nil, errors.New("invalid credentials")
 	}
+	attempt.UserID = user.ID
+
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		s.recordLoginAttempt(ctx, attempt)
+		return nil, errors.New("user is temporarily locked")
+	}
 
 	// Check password
 	if !user.CheckPassword(req.Password) {
+		s.recordLoginAttempt(ctx, attempt)
+		if err := s.recordFailedLogin(ctx, user, now); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("invalid credentials")
 	}
+	if user.Status == models.StatusClosed {
+		return nil, errors.New("user is closed")
+	}
+
+	if err := s.userRepo.ResetFailedLogins(ctx, user.ID); err != nil {
+		s.logger.WithError(err).Errorf("Failed to reset failed logins of user %d", user.ID)
+		return nil, errors.New("internal server error")
+	}
+	attempt.Success = true
+	s.recordLoginAttempt(ctx, attempt)
+
+	session := &models.Session{
+		UserID:     user.ID,
+		TokenID:    uuid.New().String(),
+		DeviceName: strings.TrimSpace(req.DeviceName),
+		IPAddress:  ip,
+		UserAgent:  userAgent,
+		ExpiresAt:  now.Add(middleware.TokenTTL),
+	}
 
 	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID)
+	token, err := middleware.GenerateToken(ctx, user.ID, user.Role, session.TokenID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to generate token")
 		return nil, errors.New("internal server error")
 	}
+	signingKey, err := middleware.RequestSigningKey(ctx, token)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to derive request signing key")
+		return nil, errors.New("internal server error")
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, errors.New("internal server error")
+	}
 
 	return &LoginResponse{
-		Token: token,
+		Token:      token,
+		SigningKey: signingKey,
 	}, nil
 }
 
-func (s *UserService) GetUserByID(userID int64) (*models.User, error) {
-	user, err := s.userRepo.GetByID(userID)
+// recordFailedLogin counts a wrong password for a user. The failure that
+// reaches the limit locks the user and tells them about it, so that the owner
+// learns of the guessing and can change the password.
+func (s *UserService) recordFailedLogin(ctx context.Context, user *models.User, now time.Time) error {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to begin transaction")
+		return errors.New("internal server error")
+	}
+	defer tx.Rollback()
+
+	failures, err := s.userRepo.RecordFailedLoginTx(ctx, tx, user.ID, now.Add(-s.cfg.FailureWindow))
+	if err != nil {
+		s.logger.WithError(err).Errorf("Failed to record failed login of user %d", user.ID)
+		return errors.New("internal server error")
+	}
+
+	if s.cfg.MaxFailedAttempts > 0 && failures >= s.cfg.MaxFailedAttempts {
+		lockedUntil := now.Add(s.cfg.LockoutDuration)
+		if err := s.userRepo.LockTx(ctx, tx, user.ID, lockedUntil); err != nil {
+			s.logger.WithError(err).Errorf("Failed to lock user %d", user.ID)
+			return errors.New("internal server error")
+		}
+
+		content := fmt.Sprintf(
+			"There were %d failed attempts to log in to your account in a row, so logging in is blocked until %s. "+
+				"If it was not you, change your password once the block is lifted.",
+			failures, lockedUntil.UTC().Format("2006-01-02 15:04 MST"),
+		)
+		if err := s.notificationService.NotifyUserTx(ctx, tx, user.ID, "Security alert: account locked", content); err != nil {
+			s.logger.WithError(err).Errorf("Failed to queue lockout notification for user %d", user.ID)
+			return errors.New("internal server error")
+		}
+		s.logger.Warnf("User %d locked until %s after %d failed logins", user.ID, lockedUntil.Format(time.RFC3339), failures)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.WithError(err).Error("Failed to commit failed login")
+		return errors.New("internal server error")
+	}
+
+	return nil
+}
+
+// recordLoginAttempt keeps a login attempt for the throttling by client
+// address; a failure to keep it does not fail the login
+func (s *UserService) recordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) {
+	if err := s.loginAttemptRepo.Create(ctx, attempt); err != nil {
+		s.logger.WithError(err).Warn("Failed to record login attempt")
+	}
+}
+
+// UnlockUser lets a user locked after failed logins log in again. The unlock
+// is audited.
+func (s *UserService) UnlockUser(ctx context.Context, adminID, userID int64) error {
+	if err := s.userRepo.Unlock(ctx, userID); err != nil {
+		if err.Error() == "user not found" {
+			return err
+		}
+		s.logger.WithError(err).Errorf("Failed to unlock user %d", userID)
+		return errors.New("internal server error")
+	}
+
+	if err := s.auditRepo.Create(ctx, &models.AuditLog{
+		ActorID:    adminID,
+		Action:     models.AuditActionUserUnlocked,
+		EntityType: "user",
+		EntityID:   userID,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit user unlock")
+	}
+
+	return nil
+}
+
+func (s *UserService) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get user by ID")
 		return nil, errors.New("user not found")
@@ -119,3 +370,140 @@ func (s *UserService) GetUserByID(userID int64) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetProfile retrieves the profile of a user
+func (s *UserService) GetProfile(ctx context.Context, userID int64) (*models.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user by ID")
+		return nil, errors.New("user not found")
+	}
+
+	return user.ToResponse(), nil
+}
+
+// UpdateProfile changes the names and the phone number of a user
+func (s *UserService) UpdateProfile(ctx context.Context, userID int64, req *models.UpdateProfileRequest) (*models.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user by ID")
+		return nil, errors.New("user not found")
+	}
+
+	user.FirstName = strings.TrimSpace(req.FirstName)
+	user.LastName = strings.TrimSpace(req.LastName)
+	user.PhoneNumber = req.PhoneNumber
+	if user.FirstName == "" || user.LastName == "" {
+		return nil, errors.New("first and last name are required")
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+		s.logger.WithError(err).Error("Failed to update user profile")
+		return nil, errors.New("internal server error")
+	}
+
+	return user.ToResponse(), nil
+}
+
+// ChangePassword replaces the password of a user after verifying the current one
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, req *models.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get user by ID")
+		return errors.New("user not found")
+	}
+
+	if !user.CheckPassword(req.OldPassword) {
+		return errors.New("current password is incorrect")
+	}
+	if req.NewPassword == req.OldPassword {
+		return errors.New("new password must differ from the current one")
+	}
+	if err := s.passwordPolicy.Check(ctx, req.NewPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	user.Password = req.NewPassword
+	if err := user.HashPassword(); err != nil {
+		s.logger.WithError(err).Error("Failed to hash password")
+		return errors.New("internal server error")
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, user.Password); err != nil {
+		s.logger.WithError(err).Error("Failed to update password")
+		return errors.New("internal server error")
+	}
+
+	return nil
+}
+
+// GetSettings retrieves the settings of a user
+func (s *UserService) GetSettings(ctx context.Context, userID int64) (*models.UserSettings, error) {
+	settings, err := s.settingsRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	return settings, nil
+}
+
+// UpdateSettings replaces the settings of a user
+func (s *UserService) UpdateSettings(ctx context.Context, userID int64, req *models.UpdateUserSettingsRequest) (*models.UserSettings, error) {
+	if _, err := time.LoadLocation(req.TimeZone); err != nil {
+		return nil, errors.New("unknown time zone")
+	}
+
+	settings := &models.UserSettings{
+		UserID:             userID,
+		EmailNotifications: req.EmailNotifications,
+		SMSNotifications:   req.SMSNotifications,
+		Language:           req.Language,
+		TimeZone:           req.TimeZone,
+		MonthlyStatements:  req.MonthlyStatements,
+	}
+	if err := s.settingsRepo.Upsert(ctx, settings); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	return settings, nil
+}
+
+// ValidateSession tells whether the session of a token is still active and
+// records its use. Tokens issued before sessions were tracked have no session
+// and are refused.
+func (s *UserService) ValidateSession(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+	active, err := s.sessionRepo.Touch(ctx, tokenID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to validate session")
+		return false, err
+	}
+	return active, nil
+}
+
+// GetSessions lists the active sessions of a user, marking the one with the
+// given token ID as current
+func (s *UserService) GetSessions(ctx context.Context, userID int64, currentTokenID string) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	for _, session := range sessions {
+		session.Current = session.TokenID == currentTokenID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs a device of a user out: the token of the session is no
+// longer accepted
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	if err := s.sessionRepo.Revoke(ctx, userID, sessionID); err != nil {
+		if err.Error() == "session not found" {
+			return err
+		}
+		return errors.New("internal server error")
+	}
+	return nil
+}
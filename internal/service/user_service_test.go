@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/Abigotado/abi_banking/internal/repository/mock"
+)
+
+func TestUserServiceLoginLocksAfterFailedAttempts(t *testing.T) {
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name        string
+		password    string
+		lockedUntil *time.Time
+		failures    int
+		wantErr     string
+		wantLocked  bool
+	}{
+		{
+			name:     "failure below the limit",
+			password: "battery staple",
+			failures: 4,
+			wantErr:  "invalid credentials",
+		},
+		{
+			name:       "failure reaching the limit locks the user",
+			password:   "battery staple",
+			failures:   5,
+			wantErr:    "invalid credentials",
+			wantLocked: true,
+		},
+		{
+			name:        "locked user with the right password",
+			password:    "correct horse",
+			lockedUntil: &lockedUntil,
+			wantErr:     "user is temporarily locked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := &models.User{ID: 7, Email: "user@example.com", Password: "correct horse", Status: models.StatusActive,
+				LockedUntil: tt.lockedUntil}
+			if err := user.HashPassword(); err != nil {
+				t.Fatalf("hash password: %v", err)
+			}
+
+			var locked bool
+			users := &mock.UserStore{
+				BeginTransactionFunc: beginTx(env.tx()),
+				GetByEmailFunc:       func(context.Context, string) (*models.User, error) { return user, nil },
+				RecordFailedLoginTxFunc: func(context.Context, repository.Tx, int64, time.Time) (int, error) {
+					return tt.failures, nil
+				},
+				LockTxFunc: func(_ context.Context, _ repository.Tx, _ int64, until time.Time) error {
+					locked = until.After(time.Now())
+					return nil
+				},
+			}
+			notificationService := NewNotificationService(&config.SMTPConfig{}, &config.SMSConfig{}, users,
+				repository.NewUserSettingsRepository(env.db, env.logger), env.relay, env.logger)
+
+			s := NewUserService(users, repository.NewUserSettingsRepository(env.db, env.logger),
+				repository.NewSessionRepository(env.db, env.logger), repository.NewLoginAttemptRepository(env.db, env.logger),
+				repository.NewAuditRepository(env.db, env.logger), notificationService, NewPasswordPolicy(&env.cfg.PasswordPolicy, env.logger),
+				nil, env.publisher(t), &env.cfg.Login, env.logger)
+
+			_, err := s.Login(context.Background(), &LoginRequest{Email: user.Email, Password: tt.password}, "test")
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Login() error = %v, want %q", err, tt.wantErr)
+			}
+			if locked != tt.wantLocked {
+				t.Errorf("locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestUserServiceCreateAdminValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		emailExists    bool
+		usernameExists bool
+		password       string
+		wantErr        string
+	}{
+		{
+			name:        "email taken",
+			emailExists: true,
+			password:    "Correct-Horse-42-Battery",
+			wantErr:     "email already exists",
+		},
+		{
+			name:           "username taken",
+			usernameExists: true,
+			password:       "Correct-Horse-42-Battery",
+			wantErr:        "username already exists",
+		},
+		{
+			name:     "weak password",
+			password: "short",
+			wantErr:  "password must be at least",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			users := &mock.UserStore{
+				CheckEmailExistsFunc:    func(context.Context, string) (bool, error) { return tt.emailExists, nil },
+				CheckUsernameExistsFunc: func(context.Context, string) (bool, error) { return tt.usernameExists, nil },
+			}
+			s := NewUserService(users, repository.NewUserSettingsRepository(env.db, env.logger), nil, nil,
+				repository.NewAuditRepository(env.db, env.logger), nil, NewPasswordPolicy(&env.cfg.PasswordPolicy, env.logger),
+				nil, nil, &env.cfg.Login, env.logger)
+
+			_, err := s.CreateAdmin(context.Background(), &RegisterRequest{Username: "operator", Email: "ops@example.com", Password: tt.password})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CreateAdmin() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/jobs"
+	"github.com/Abigotado/abi_banking/internal/models"
+	"github.com/Abigotado/abi_banking/internal/outbox"
+	"github.com/Abigotado/abi_banking/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	webhookSecretPrefix    = "whsec_"
+	webhookDeliveryTimeout = 10 * time.Second
+	webhookHistoryLimit    = 200
+)
+
+// webhookOutboxPayload is the outbox message of an event published in a transaction
+type webhookOutboxPayload struct {
+	UserID    int64           `json:"user_id"`
+	EventType string          `json:"event_type"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// webhookDeliveryJobPayload identifies the delivery posted by a delivery job
+type webhookDeliveryJobPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// WebhookService handles partner webhook subscriptions, event delivery and replay
+type WebhookService struct {
+	repo       *repository.WebhookRepository
+	jobRunner  *jobs.Runner
+	relay      *outbox.Relay
+	httpClient *http.Client
+	logger     *logrus.Logger
+}
+
+// NewWebhookService creates a new WebhookService instance and registers its
+// delivery job with the job runner and its event publisher with the outbox relay
+func NewWebhookService(repo *repository.WebhookRepository, jobRunner *jobs.Runner, relay *outbox.Relay, logger *logrus.Logger) *WebhookService {
+	s := &WebhookService{
+		repo:       repo,
+		jobRunner:  jobRunner,
+		relay:      relay,
+		httpClient: newWebhookHTTPClient(),
+		logger:     logger,
+	}
+	jobRunner.Register(models.JobTypeWebhookDelivery, s.processDeliveryJob)
+	relay.Register(models.OutboxTopicWebhook, s.publishOutboxEvent)
+	return s
+}
+
+// newWebhookHTTPClient returns the client deliveries are posted with. It only
+// connects to public addresses, checked on the resolved address of every
+// connection so that a host re-pointed after the subscription was created, or a
+// redirect, cannot reach internal services, and it follows https redirects only.
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is not public", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: webhookDeliveryTimeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookDeliveryTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errors.New("webhook redirect to a non-https url")
+			}
+			if len(via) >= 5 {
+				return errors.New("too many webhook redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// reservedPrefixes are special-purpose ranges not covered by the netip predicates:
+// "this network" and the carrier-grade NAT range internal to providers
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// isPublicAddr reports whether an address is reachable on the internet rather
+// than a loopback, private, link-local (cloud metadata) or otherwise special one
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWebhookURL checks that a subscription endpoint is an https url whose
+// host resolves to public addresses only
+func validateWebhookURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Hostname() == "" || endpoint.User != nil {
+		return nil, errors.New("webhook url must be an absolute https url")
+	}
+
+	host := endpoint.Hostname()
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil || len(addrs) == 0 {
+			return nil, errors.New("webhook url host cannot be resolved")
+		}
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return nil, errors.New("webhook url must not point to a loopback, private or link-local address")
+		}
+	}
+
+	return endpoint, nil
+}
+
+// SignWebhookPayload computes the signature of a delivery body sent at the given
+// Unix timestamp. Subscribers recompute it with their secret to authenticate a delivery.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateSubscription registers an endpoint for the events of a user. The generated
+// secret is only returned here.
+func (s *WebhookService) CreateSubscription(ctx context.Context, userID int64, req *models.CreateWebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	endpoint, err := validateWebhookURL(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	sub := &models.WebhookSubscription{
+		UserID:     userID,
+		URL:        endpoint.String(),
+		Secret:     webhookSecretPrefix + hex.EncodeToString(secret),
+		EventTypes: req.EventTypes,
+		Active:     true,
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if err := s.repo.CreateSubscription(ctx, sub); err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	return sub, nil
+}
+
+// GetUserSubscriptions retrieves the webhook subscriptions of a user without their secrets
+func (s *WebhookService) GetUserSubscriptions(ctx context.Context, userID int64) ([]*models.WebhookSubscription, error) {
+	subs, err := s.repo.GetSubscriptionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+// getSubscription retrieves a subscription owned by the user, secret included
+func (s *WebhookService) getSubscription(ctx context.Context, userID, subscriptionID int64) (*models.WebhookSubscription, error) {
+	sub, err := s.repo.GetSubscriptionByID(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.UserID != userID {
+		return nil, errors.New("unauthorized: webhook subscription does not belong to user")
+	}
+	return sub, nil
+}
+
+// Publish records an event of a user and queues its delivery to every active
+// subscription of that user interested in the event type. Publishing failures are
+// returned to the caller, which decides whether they matter.
+func (s *WebhookService) Publish(ctx context.Context, userID int64, eventType string, data interface{}) error {
+	subs, err := s.repo.GetSubscriptionsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var targets []*models.WebhookSubscription
+	for _, sub := range subs {
+		if sub.Active && sub.Matches(eventType) {
+			targets = append(targets, sub)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event := &models.WebhookEvent{
+		UserID:  userID,
+		Type:    eventType,
+		Payload: payload,
+	}
+	if err := s.repo.CreateEventTx(ctx, tx, event); err != nil {
+		return err
+	}
+
+	for _, sub := range targets {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			Status:         models.WebhookDeliveryPending,
+		}
+		if err := s.repo.CreateDeliveryTx(ctx, tx, delivery); err != nil {
+			return err
+		}
+		if _, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeWebhookDelivery, webhookDeliveryJobPayload{DeliveryID: delivery.ID}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PublishTx writes an event of a user to the outbox within a database transaction.
+// It is published to the subscriptions once the transaction commits, so an event is
+// never announced for a rolled back change.
+func (s *WebhookService) PublishTx(ctx context.Context, tx repository.Tx, userID int64, eventType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	return s.relay.AddTx(ctx, tx, models.OutboxTopicWebhook, webhookOutboxPayload{
+		UserID:    userID,
+		EventType: eventType,
+		Data:      raw,
+	})
+}
+
+// publishOutboxEvent publishes an event written to the outbox
+func (s *WebhookService) publishOutboxEvent(ctx context.Context, msg *models.OutboxMessage) error {
+	var payload webhookOutboxPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid webhook outbox payload: %w", err)
+	}
+	return s.Publish(ctx, payload.UserID, payload.EventType, payload.Data)
+}
+
+// GetSubscriptionEvents lists the events sent to a subscription within a time range
+// with the outcome of their latest delivery
+func (s *WebhookService) GetSubscriptionEvents(ctx context.Context, userID, subscriptionID int64, from, to time.Time) ([]*models.WebhookEventHistory, error) {
+	if _, err := s.getSubscription(ctx, userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, errors.New("end of the range must not be before its start")
+	}
+
+	events, err := s.repo.GetSubscriptionEvents(ctx, subscriptionID, from, to, webhookHistoryLimit)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+	return events, nil
+}
+
+// ReplayEvent queues a new delivery of an event to a subscription
+func (s *WebhookService) ReplayEvent(ctx context.Context, userID, subscriptionID, eventID int64) (*models.WebhookDelivery, error) {
+	sub, err := s.getSubscription(ctx, userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Active {
+		return nil, errors.New("webhook subscription is not active")
+	}
+
+	event, err := s.repo.GetEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.UserID != sub.UserID || !sub.Matches(event.Type) {
+		return nil, errors.New("webhook event not found")
+	}
+
+	deliveries, err := s.queueReplays(ctx, sub, []int64{event.ID})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries[0], nil
+}
+
+// ReplayRange queues new deliveries of the events sent to a subscription within a
+// time range, oldest first. With FailedOnly only events whose latest delivery
+// failed are replayed.
+func (s *WebhookService) ReplayRange(ctx context.Context, userID, subscriptionID int64, req *models.ReplayWebhookEventsRequest) (*models.ReplayWebhookEventsResult, error) {
+	sub, err := s.getSubscription(ctx, userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Active {
+		return nil, errors.New("webhook subscription is not active")
+	}
+	if req.From.IsZero() || req.To.IsZero() || req.To.Before(req.From) {
+		return nil, errors.New("a valid from and to range is required")
+	}
+
+	// One extra row tells whether the range holds more events than a replay may cover
+	events, err := s.repo.GetSubscriptionEvents(ctx, subscriptionID, req.From, req.To, models.MaxWebhookReplayEvents+1)
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	result := &models.ReplayWebhookEventsResult{EventIDs: []int64{}}
+	if len(events) > models.MaxWebhookReplayEvents {
+		events = events[:models.MaxWebhookReplayEvents]
+		result.Truncated = true
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if req.FailedOnly && events[i].LastStatus != models.WebhookDeliveryFailed {
+			continue
+		}
+		result.EventIDs = append(result.EventIDs, events[i].ID)
+	}
+	if len(result.EventIDs) == 0 {
+		return result, nil
+	}
+
+	if _, err := s.queueReplays(ctx, sub, result.EventIDs); err != nil {
+		return nil, err
+	}
+	result.Queued = len(result.EventIDs)
+
+	return result, nil
+}
+
+func (s *WebhookService) queueReplays(ctx context.Context, sub *models.WebhookSubscription, eventIDs []int64) ([]*models.WebhookDelivery, error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deliveries := make([]*models.WebhookDelivery, 0, len(eventIDs))
+	for _, eventID := range eventIDs {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			Status:         models.WebhookDeliveryPending,
+			Replay:         true,
+		}
+		if err := s.repo.CreateDeliveryTx(ctx, tx, delivery); err != nil {
+			return nil, errors.New("internal server error")
+		}
+		if _, err := s.jobRunner.EnqueueTx(ctx, tx, models.JobTypeWebhookDelivery, webhookDeliveryJobPayload{DeliveryID: delivery.ID}); err != nil {
+			s.logger.WithError(err).Error("Failed to queue webhook replay")
+			return nil, errors.New("internal server error")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// VerifySignature checks a signature the way a subscriber should, so partners can
+// debug their verification code against the signature the bank computes
+func (s *WebhookService) VerifySignature(ctx context.Context, userID int64, req *models.VerifyWebhookSignatureRequest) (*models.VerifyWebhookSignatureResult, error) {
+	sub, err := s.getSubscription(ctx, userID, req.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Timestamp == "" || req.Body == "" {
+		return nil, errors.New("timestamp and body are required")
+	}
+
+	expected := SignWebhookPayload(sub.Secret, req.Timestamp, []byte(req.Body))
+	return &models.VerifyWebhookSignatureResult{
+		Valid:             hmac.Equal([]byte(expected), []byte(req.Signature)),
+		ExpectedSignature: expected,
+		SignedPayload:     req.Timestamp + "." + req.Body,
+	}, nil
+}
+
+// processDeliveryJob posts an event to its subscriber. A failed post is retried by
+// the job runner and the delivery is marked failed once the job gives up.
+func (s *WebhookService) processDeliveryJob(ctx context.Context, job *models.Job) error {
+	var payload webhookDeliveryJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid webhook delivery job payload: %w", err)
+	}
+
+	delivery, err := s.repo.GetDeliveryByID(ctx, payload.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == models.WebhookDeliveryDelivered {
+		return nil
+	}
+
+	sub, err := s.repo.GetSubscriptionByID(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	event, err := s.repo.GetEventByID(ctx, delivery.EventID)
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	if !sub.Active {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = "webhook subscription is not active"
+		return s.repo.UpdateDelivery(ctx, delivery)
+	}
+
+	statusCode, postErr := s.post(ctx, sub, event, delivery.Replay)
+	if statusCode != 0 {
+		delivery.ResponseStatus = &statusCode
+	}
+	if postErr == nil {
+		now := time.Now()
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return s.repo.UpdateDelivery(ctx, delivery)
+	}
+
+	delivery.LastError = postErr.Error()
+	delivery.Status = models.WebhookDeliveryPending
+	if job.Attempts >= job.MaxAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+	}
+	if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+		s.logger.WithError(err).Errorf("Failed to record webhook delivery %d failure", delivery.ID)
+	}
+	return postErr
+}
+
+// post sends a signed event to the subscriber and returns the response status
+func (s *WebhookService) post(ctx context.Context, sub *models.WebhookSubscription, event *models.WebhookEvent, replay bool) (int, error) {
+	body, err := json.Marshal(models.WebhookEventBody{
+		ID:        event.ID,
+		Type:      event.Type,
+		Data:      event.Payload,
+		CreatedAt: event.CreatedAt,
+		Replay:    replay,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	// Subscriptions created before https was required are not delivered to
+	if req.URL.Scheme != "https" {
+		return 0, errors.New("webhook url is not https")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(models.WebhookTimestampHeader, timestamp)
+	req.Header.Set(models.WebhookSignatureHeader, SignWebhookPayload(sub.Secret, timestamp, body))
+	req.Header.Set(models.WebhookEventIDHeader, strconv.FormatInt(event.ID, 10))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
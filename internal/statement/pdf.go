@@ -0,0 +1,240 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/Abigotado/abi_banking/internal/locale"
+)
+
+// A4 page in points and the layout of the transaction table
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+
+	rowFontSize    = 9
+	rowHeight      = 13
+	bottomRowY     = 60
+	firstPageRowY  = 612
+	otherPagesRowY = 774
+)
+
+// The table is set in Courier, so that its columns line up by padding
+const rowFormat = "%-10s %-19s %-31s %13s %13s"
+
+// Fonts of the document resources: the standard Type 1 fonts need no embedding
+var pdfFonts = []string{"Helvetica", "Helvetica-Bold", "Courier"}
+
+// RenderPDF renders the statement as a PDF document. The standard fonts only cover
+// Latin characters, so Cyrillic text is transliterated.
+func RenderPDF(st *Statement) []byte {
+	rows := make([]string, 0, len(st.Transactions))
+	for _, t := range st.Transactions {
+		debit, credit := "", ""
+		if amount := st.SignedAmount(t); amount >= 0 {
+			credit = formatAmount(amount)
+		} else {
+			debit = formatAmount(-amount)
+		}
+		rows = append(rows, fmt.Sprintf(rowFormat,
+			locale.FormatDate(st.Language, t.CreatedAt.In(st.PeriodStart.Location())),
+			truncate(t.Type, 19),
+			truncate(pdfText(st.description(t)), 31),
+			debit,
+			credit,
+		))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "No transactions in this period")
+	}
+
+	// Split the rows into pages
+	var pages [][]string
+	capacity := (firstPageRowY-bottomRowY)/rowHeight + 1
+	for len(rows) > 0 {
+		n := min(capacity, len(rows))
+		pages = append(pages, rows[:n])
+		rows = rows[n:]
+		capacity = (otherPagesRowY-bottomRowY)/rowHeight + 1
+	}
+
+	contents := make([][]byte, len(pages))
+	for i, pageRows := range pages {
+		var c pdfContent
+		y := otherPagesRowY + rowHeight
+		if i == 0 {
+			st.writeSummary(&c)
+			y = firstPageRowY + rowHeight
+		}
+
+		c.text("F3", rowFontSize, margin, float64(y), fmt.Sprintf(rowFormat, "Date", "Type", "Description", "Debit", "Credit"))
+		c.line(margin, float64(y-4), pageWidth-margin, float64(y-4))
+		for _, row := range pageRows {
+			y -= rowHeight
+			c.text("F3", rowFontSize, margin, float64(y), row)
+		}
+
+		c.text("F1", 8, margin, 30, fmt.Sprintf("Account #%d, %s", st.Account.ID, pdfText(locale.FormatMonth(st.Language, st.PeriodStart))))
+		c.text("F1", 8, pageWidth-margin-50, 30, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+		contents[i] = c.Bytes()
+	}
+
+	return buildPDF(contents)
+}
+
+// writeSummary writes the title, the account details and the balances of the first page
+func (st *Statement) writeSummary(c *pdfContent) {
+	currency := st.Account.Currency
+	lastDay := st.PeriodEnd.AddDate(0, 0, -1)
+
+	c.text("F2", 16, margin, 792, "Account statement")
+	lines := []string{
+		fmt.Sprintf("Account #%d, %s", st.Account.ID, currency),
+		fmt.Sprintf("Holder: %s", pdfText(st.Holder)),
+		fmt.Sprintf("Period: %s - %s", locale.FormatDate(st.Language, st.PeriodStart), locale.FormatDate(st.Language, lastDay)),
+		fmt.Sprintf("Generated: %s", locale.FormatDateTime(st.Language, st.GeneratedAt.In(st.PeriodStart.Location()))),
+	}
+	for i, line := range lines {
+		c.text("F1", 10, margin, float64(770-14*i), line)
+	}
+
+	balances := []struct {
+		label  string
+		amount float64
+	}{
+		{"Opening balance", st.OpeningBalance},
+		{"Incoming", st.Incoming},
+		{"Outgoing", st.Outgoing},
+		{"Closing balance", st.ClosingBalance},
+	}
+	for i, b := range balances {
+		font := "F1"
+		if i == 0 || i == len(balances)-1 {
+			font = "F2"
+		}
+		c.text(font, 10, margin, float64(700-14*i), b.label+":")
+		c.text(font, 10, margin+110, float64(700-14*i), formatAmount(b.amount)+" "+currency)
+	}
+}
+
+// pdfContent is the content stream of a page
+type pdfContent struct {
+	bytes.Buffer
+}
+
+func (c *pdfContent) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(c, "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escapePDFString(s))
+}
+
+func (c *pdfContent) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(c, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// buildPDF assembles a document from the content streams of its pages
+func buildPDF(contents [][]byte) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 and 2 are the catalog and the page tree, the fonts follow, then
+	// every page with its content stream
+	firstPage := 3 + len(pdfFonts)
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	fonts := make([]string, len(pdfFonts))
+	for i := range pdfFonts {
+		fonts[i] = fmt.Sprintf("/F%d %d 0 R", i+1, 3+i)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents)))
+	for _, font := range pdfFonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font))
+	}
+	for i, content := range contents {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, strings.Join(fonts, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// escapePDFString escapes the characters with a meaning in PDF literal strings
+func escapePDFString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "ie", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia",
+}
+
+// pdfText makes text printable with the standard fonts: Cyrillic letters are
+// transliterated, other characters outside ASCII are replaced
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			b.WriteRune(r)
+			continue
+		}
+		latin, ok := cyrillic[unicode.ToLower(r)]
+		if !ok {
+			b.WriteByte('?')
+			continue
+		}
+		if unicode.IsUpper(r) && latin != "" {
+			latin = strings.ToUpper(latin[:1]) + latin[1:]
+		}
+		b.WriteString(latin)
+	}
+	return b.String()
+}
+
+// formatAmount formats an amount with two decimals and thousands separated by spaces
+func formatAmount(amount float64) string {
+	s := fmt.Sprintf("%.2f", math.Abs(amount))
+	whole, fraction := s[:len(s)-3], s[len(s)-3:]
+
+	var b strings.Builder
+	if amount < 0 && s != "0.00" {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(digit)
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,141 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// receiptTimeLayout formats the time of a transaction on its receipt, in UTC
+const receiptTimeLayout = "2006-01-02 15:04:05 UTC"
+
+// receiptLine is a labelled detail of a receipt
+type receiptLine struct {
+	Label string
+	Value string
+}
+
+// receiptLines returns the details of a receipt in the order they are printed,
+// leaving out those the transaction does not have
+func receiptLines(r *models.Receipt) []receiptLine {
+	lines := []receiptLine{
+		{"Reference", r.Reference},
+		{"Date", r.CreatedAt.Format(receiptTimeLayout)},
+		{"Type", r.Type},
+		{"Amount", formatAmount(r.Amount) + " " + r.Currency},
+	}
+	if r.Conversion != nil {
+		lines = append(lines, receiptLine{"Converted", fmt.Sprintf("%s %s at %.4f",
+			formatAmount(r.Conversion.BilledAmount), r.Conversion.BilledCurrency, r.Conversion.ExchangeRate)})
+	}
+	if r.FromAccount != "" {
+		lines = append(lines, receiptLine{"From account", r.FromAccount})
+	}
+	if r.ToAccount != "" {
+		lines = append(lines, receiptLine{"To account", r.ToAccount})
+	}
+	if r.Description != "" {
+		lines = append(lines, receiptLine{"Description", r.Description})
+	}
+	return lines
+}
+
+// bankLines returns the details of the issuing bank printed on a receipt
+func bankLines(bank models.ReceiptBank) []receiptLine {
+	lines := []receiptLine{{"Bank", bank.Name}, {"BIC", bank.BIC}}
+	if bank.CorrespondentAccount != "" {
+		lines = append(lines, receiptLine{"Corr. account", bank.CorrespondentAccount})
+	}
+	if bank.TaxID != "" {
+		lines = append(lines, receiptLine{"INN", bank.TaxID})
+	}
+	return lines
+}
+
+// ReceiptFileName returns the name of the file of a receipt with an extension
+func ReceiptFileName(r *models.Receipt, ext string) string {
+	return fmt.Sprintf("receipt-%s.%s", r.Reference, ext)
+}
+
+// RenderReceiptPDF renders a receipt as a one-page PDF document. Like
+// statements, Cyrillic text is transliterated.
+func RenderReceiptPDF(r *models.Receipt) []byte {
+	var c pdfContent
+	c.text("F2", 16, margin, 792, "Transaction receipt")
+
+	y := 760.0
+	for _, line := range bankLines(r.Bank) {
+		c.text("F1", 10, margin, y, line.Label+":")
+		c.text("F1", 10, margin+110, y, pdfText(line.Value))
+		y -= 14
+	}
+	y -= 6
+	c.line(margin, y, pageWidth-margin, y)
+	y -= 20
+
+	for _, line := range receiptLines(r) {
+		font := "F1"
+		if line.Label == "Amount" {
+			font = "F2"
+		}
+		c.text(font, 11, margin, y, line.Label+":")
+		c.text(font, 11, margin+110, y, truncate(pdfText(line.Value), 70))
+		y -= 16
+	}
+	y -= 6
+	c.line(margin, y, pageWidth-margin, y)
+	y -= 20
+
+	c.text("F2", 10, margin, y, "Verification code:")
+	c.text("F3", 12, margin+110, y, r.VerificationCode)
+	c.text("F1", 8, margin, y-16, "Check the authenticity of this receipt with its reference and code at")
+	c.text("F1", 8, margin, y-28, pdfText(r.VerificationURL))
+
+	return buildPDF([][]byte{c.Bytes()})
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.Receipt.Reference}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; max-width: 40em; margin: 2em auto; }
+table { border-collapse: collapse; width: 100%; margin: 1em 0; }
+th { text-align: left; width: 12em; font-weight: normal; color: #555; }
+th, td { padding: 0.25em 0; }
+.code { font-family: Courier, monospace; font-size: 1.2em; }
+hr { border: 0; border-top: 1px solid #999; }
+</style>
+</head>
+<body>
+<h1>Transaction receipt</h1>
+<table>
+{{range .Bank}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+<hr>
+<table>
+{{range .Lines}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+<hr>
+<p>Verification code: <span class="code">{{.Receipt.VerificationCode}}</span></p>
+<p><small>Check the authenticity of this receipt with its reference and code at {{.Receipt.VerificationURL}}</small></p>
+</body>
+</html>
+`))
+
+// RenderReceiptHTML renders a receipt as an HTML page for printing
+func RenderReceiptHTML(r *models.Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	err := receiptTemplate.Execute(&buf, struct {
+		Receipt *models.Receipt
+		Bank    []receiptLine
+		Lines   []receiptLine
+	}{r, bankLines(r.Bank), receiptLines(r)})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
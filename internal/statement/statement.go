@@ -0,0 +1,102 @@
+// Package statement builds account statements for a period and exports them as
+// PDF documents, and renders printable receipts of single transactions.
+package statement
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/models"
+)
+
+// Statement represents the transactions of an account over a period with the
+// balances at its start and end
+type Statement struct {
+	Account        *models.Account
+	Holder         string
+	Language       string    // of the dates, see package locale
+	PeriodStart    time.Time // inclusive
+	PeriodEnd      time.Time // exclusive
+	OpeningBalance float64
+	ClosingBalance float64
+	Incoming       float64
+	Outgoing       float64
+	Transactions   []*models.Transaction // oldest first
+	GeneratedAt    time.Time
+}
+
+// New builds the statement of an account. The balances are derived from the
+// current balance of the account, so besides the transactions of the period it
+// takes those made after the period. The dates are formatted in the language
+// of the holder's settings.
+func New(account *models.Account, holder, language string, start, end time.Time, transactions, later []*models.Transaction) *Statement {
+	st := &Statement{
+		Account:      account,
+		Holder:       holder,
+		Language:     language,
+		PeriodStart:  start,
+		PeriodEnd:    end,
+		Transactions: append([]*models.Transaction(nil), transactions...),
+		GeneratedAt:  time.Now(),
+	}
+	sort.SliceStable(st.Transactions, func(i, j int) bool {
+		return st.Transactions[i].CreatedAt.Before(st.Transactions[j].CreatedAt)
+	})
+
+	st.ClosingBalance = account.Balance
+	for _, t := range later {
+		st.ClosingBalance -= st.SignedAmount(t)
+	}
+
+	st.OpeningBalance = st.ClosingBalance
+	for _, t := range st.Transactions {
+		amount := st.SignedAmount(t)
+		st.OpeningBalance -= amount
+		if amount >= 0 {
+			st.Incoming += amount
+		} else {
+			st.Outgoing -= amount
+		}
+	}
+
+	return st
+}
+
+// SignedAmount returns the amount of a transaction as it changed the account
+// balance: positive when credited, negative when debited
+func (st *Statement) SignedAmount(t *models.Transaction) float64 {
+	if t.ToAccountID == st.Account.ID {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+// FileName returns the name of the statement's PDF file
+func (st *Statement) FileName() string {
+	return fmt.Sprintf("statement-%d-%s.pdf", st.Account.ID, st.PeriodStart.Format("2006-01"))
+}
+
+// description describes a transaction from the point of view of the account
+func (st *Statement) description(t *models.Transaction) string {
+	if t.Description != "" {
+		return t.Description
+	}
+	switch t.Type {
+	case models.TransactionTypeTransfer:
+		if t.ToAccountID == st.Account.ID {
+			return fmt.Sprintf("From account #%d", t.FromAccountID)
+		}
+		return fmt.Sprintf("To account #%d", t.ToAccountID)
+	case models.TransactionTypeDeposit:
+		return "Deposit"
+	case models.TransactionTypeWithdrawal:
+		return "Withdrawal"
+	case models.TransactionTypeOverdraftInterest:
+		return "Overdraft interest"
+	case models.TransactionTypeDormancyFee:
+		return "Dormant account fee"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local keeps objects as files in a directory, readable by the server's user
+// only. Its signed URLs point at the API, which serves the file after checking
+// the signature with VerifyURL.
+type Local struct {
+	dir       string
+	baseURL   string
+	urlSecret []byte
+}
+
+// NewLocal creates a Local storage in dir, creating the directory if needed.
+// Signed URLs start with baseURL and are signed with secret, or with a random
+// key when it is empty.
+func NewLocal(dir, baseURL, secret string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	urlSecret := []byte(secret)
+	if secret == "" {
+		urlSecret = make([]byte, 32)
+		if _, err := rand.Read(urlSecret); err != nil {
+			return nil, fmt.Errorf("failed to generate URL signing key: %w", err)
+		}
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/"), urlSecret: urlSecret}, nil
+}
+
+// path maps a key to a file within the directory. Keys that would lead out of
+// it are refused.
+func (s *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes an object, replacing one under the same key. The content type is
+// not kept: it is stored with the object's metadata by the caller, and files
+// are served with the type of their extension.
+func (s *Local) Put(_ context.Context, key, _ string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Written aside and renamed, so a reader never sees a partial object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads an object
+func (s *Local) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete removes an object; removing one that does not exist succeeds
+func (s *Local) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL returns the URL of the API's file route for an object, carrying
+// its expiry and a signature of both
+func (s *Local) SignedURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return fmt.Sprintf("%s/files/%s?expires=%s&signature=%s", s.baseURL, escapeKey(key), expires,
+		s.sign(key, expires)), nil
+}
+
+// VerifyURL checks the expiry and the signature of a signed URL of an object
+func (s *Local) VerifyURL(key, expires, signature string, now time.Time) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return ErrInvalidURL
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func (s *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.urlSecret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
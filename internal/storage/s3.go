@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/awssig"
+	"github.com/Abigotado/abi_banking/internal/config"
+	"github.com/Abigotado/abi_banking/internal/metrics"
+)
+
+// s3Service is the name of S3 in request signatures
+const s3Service = "s3"
+
+// S3 keeps objects in an S3 bucket, encrypted at rest by S3
+type S3 struct {
+	config     *config.S3Config
+	httpClient *http.Client
+}
+
+// NewS3 creates a new S3 storage instance
+func NewS3(cfg *config.S3Config) *S3 {
+	return &S3{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// Put uploads an object, replacing one under the same key
+func (s *S3) Put(ctx context.Context, key, contentType string, data []byte) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("s3", "put", start, err)
+	}()
+
+	headers := map[string]string{
+		"Content-Type":                 contentType,
+		"X-Amz-Server-Side-Encryption": "AES256",
+	}
+	_, err = s.do(ctx, http.MethodPut, key, data, headers)
+	return err
+}
+
+// Get downloads an object
+func (s *S3) Get(ctx context.Context, key string) (data []byte, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("s3", "get", start, err)
+	}()
+
+	return s.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+// Delete removes an object; removing one that does not exist succeeds
+func (s *S3) Delete(ctx context.Context, key string) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveExternalCall("s3", "delete", start, err)
+	}()
+
+	_, err = s.do(ctx, http.MethodDelete, key, nil, nil)
+	return err
+}
+
+// SignedURL returns a URL presigned with the bucket's credentials
+func (s *S3) SignedURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return awssig.Presign(s.objectURL(key), s.credentials(), s.config.Region, s3Service, time.Now().UTC(), expiry)
+}
+
+// objectURL returns the path-style URL of an object
+func (s *S3) objectURL(key string) string {
+	endpoint := s.config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.config.Region + ".amazonaws.com"
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(s.config.Bucket) + "/" + escapeKey(key)
+}
+
+func (s *S3) credentials() awssig.Credentials {
+	return awssig.Credentials{
+		AccessKeyID:     s.config.AccessKeyID,
+		SecretAccessKey: s.config.SecretAccessKey,
+		SessionToken:    s.config.SessionToken,
+	}
+}
+
+// do sends a signed request for an object and returns the response body
+func (s *S3) do(ctx context.Context, method, key string, payload []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", awssig.PayloadHash(payload))
+	awssig.Sign(req, payload, s.credentials(), s.config.Region, s3Service, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
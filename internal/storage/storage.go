@@ -0,0 +1,56 @@
+// Package storage keeps files such as identity documents, statements and
+// exported reports in object storage, on the local disk or in an S3 bucket, and
+// hands them out through signed URLs.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Abigotado/abi_banking/internal/config"
+)
+
+// ErrNotFound is returned for an object that does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidURL is returned for a signed URL that was tampered with or expired
+var ErrInvalidURL = errors.New("invalid or expired signed URL")
+
+// Storage keeps objects under keys of slash-separated path segments. The last
+// segment of a key is the file name the object is downloaded under.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL anyone holding it may download an object from
+	// without authenticating, until the expiry has passed
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// New creates the storage of the configured backend
+func New(cfg *config.StorageConfig) (Storage, error) {
+	switch cfg.Backend {
+	case config.StorageBackendLocal:
+		return NewLocal(cfg.LocalDir, cfg.LocalBaseURL, cfg.LocalURLSecret)
+	case config.StorageBackendS3:
+		if cfg.S3.Bucket == "" || cfg.S3.Region == "" {
+			return nil, errors.New("S3 storage requires a bucket and a region")
+		}
+		return NewS3(&cfg.S3), nil
+	}
+	return nil, fmt.Errorf("unsupported storage backend %q, use %s or %s", cfg.Backend,
+		config.StorageBackendLocal, config.StorageBackendS3)
+}
+
+// escapeKey escapes the segments of a key, keeping the slashes between them
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,30 @@
+// Package worker holds the shutdown logic shared by the background workers:
+// schedulers, the job runner and the outbox relay
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Drain waits for the work tracked by wg to finish. When ctx is done first the
+// work is cancelled through cancel and waited for once more; a nil cancel gives
+// up waiting instead. Drain reports whether the work had to be cut short.
+func Drain(ctx context.Context, wg *sync.WaitGroup, cancel context.CancelFunc) bool {
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return false
+	case <-ctx.Done():
+	}
+	if cancel != nil {
+		cancel()
+		<-finished
+	}
+	return true
+}
@@ -1,88 +0,0 @@
--- Enable pgcrypto extension for encryption
-CREATE EXTENSION IF NOT EXISTS pgcrypto;
-
--- Users table
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    username VARCHAR(50) UNIQUE NOT NULL,
-    email VARCHAR(255) UNIQUE NOT NULL,
-    password VARCHAR(255) NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Accounts table
-CREATE TABLE accounts (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-    balance DECIMAL(15,2) DEFAULT 0.00,
-    currency VARCHAR(3) NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Cards table
-CREATE TABLE cards (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
-    card_number BYTEA NOT NULL, -- Encrypted with PGP
-    expiry_date BYTEA NOT NULL, -- Encrypted with PGP
-    cvv_hash VARCHAR(255) NOT NULL, -- Hashed with bcrypt
-    card_type VARCHAR(20) NOT NULL,
-    status VARCHAR(20) NOT NULL,
-    hmac VARCHAR(64) NOT NULL, -- For data integrity
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Transactions table
-CREATE TABLE transactions (
-    id SERIAL PRIMARY KEY,
-    from_account_id INTEGER REFERENCES accounts(id),
-    to_account_id INTEGER REFERENCES accounts(id),
-    amount DECIMAL(15,2) NOT NULL,
-    currency VARCHAR(3) NOT NULL,
-    description TEXT,
-    transaction_type VARCHAR(20) NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Credits table
-CREATE TABLE credits (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
-    amount DECIMAL(15,2) NOT NULL,
-    interest_rate DECIMAL(5,2) NOT NULL,
-    term_months INTEGER NOT NULL,
-    status VARCHAR(20) NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Payment schedules table
-CREATE TABLE payment_schedules (
-    id SERIAL PRIMARY KEY,
-    credit_id INTEGER REFERENCES credits(id) ON DELETE CASCADE,
-    payment_number INTEGER NOT NULL,
-    payment_date TIMESTAMP WITH TIME ZONE NOT NULL,
-    amount DECIMAL(15,2) NOT NULL,
-    principal DECIMAL(15,2) NOT NULL,
-    interest DECIMAL(15,2) NOT NULL,
-    status VARCHAR(20) NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Create indexes
-CREATE INDEX idx_users_email ON users(email);
-CREATE INDEX idx_users_username ON users(username);
-CREATE INDEX idx_accounts_user_id ON accounts(user_id);
-CREATE INDEX idx_cards_user_id ON cards(user_id);
-CREATE INDEX idx_cards_account_id ON cards(account_id);
-CREATE INDEX idx_transactions_from_account_id ON transactions(from_account_id);
-CREATE INDEX idx_transactions_to_account_id ON transactions(to_account_id);
-CREATE INDEX idx_credits_user_id ON credits(user_id);
-CREATE INDEX idx_credits_account_id ON credits(account_id);
-CREATE INDEX idx_payment_schedules_credit_id ON payment_schedules(credit_id);
-CREATE INDEX idx_payment_schedules_payment_date ON payment_schedules(payment_date); 
\ No newline at end of file